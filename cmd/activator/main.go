@@ -23,6 +23,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"time"
@@ -32,6 +33,8 @@ import (
 
 	// Injection related imports.
 	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	filteredpodinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/pod/filtered"
+	filteredinformerfactory "knative.dev/pkg/client/injection/kube/informers/factory/filtered"
 	"knative.dev/pkg/injection"
 	"knative.dev/serving/pkg/activator"
 
@@ -57,10 +60,12 @@ import (
 	activatorconfig "knative.dev/serving/pkg/activator/config"
 	activatorhandler "knative.dev/serving/pkg/activator/handler"
 	activatornet "knative.dev/serving/pkg/activator/net"
+	"knative.dev/serving/pkg/apis/serving"
 	asmetrics "knative.dev/serving/pkg/autoscaler/metrics"
 	pkghttp "knative.dev/serving/pkg/http"
 	"knative.dev/serving/pkg/logging"
 	"knative.dev/serving/pkg/networking"
+	"knative.dev/serving/pkg/queue"
 )
 
 const (
@@ -68,6 +73,16 @@ const (
 
 	// The port on which autoscaler WebSocket server listens.
 	autoscalerPort = ":8080"
+	// The port on which autoscaler gRPC server listens.
+	autoscalerGRPCPort = ":8081"
+
+	// grpcStatMaxPending bounds the stat messages kept to be sent again, once
+	// the gRPC stream to the autoscaler is re-established.
+	grpcStatMaxPending = 300
+
+	// certReloadPeriod is the interval of time between checks whether the
+	// certificate to talk to the queue-proxies with mutual TLS changed.
+	certReloadPeriod = 10 * time.Second
 )
 
 type config struct {
@@ -78,6 +93,37 @@ type config struct {
 	// TODO: run loadtests using these flags to determine optimal default values.
 	MaxIdleProxyConns        int `split_words:"true" default:"1000"`
 	MaxIdleProxyConnsPerHost int `split_words:"true" default:"100"`
+
+	// RequestBodyBufferBytes bounds how much of a request body is read from
+	// the client while the request waits for a pod. If 0, the body is only
+	// streamed once a pod is ready.
+	RequestBodyBufferBytes int `split_words:"true"` // optional
+
+	// RequestBodyBufferDir is the directory request bodies buffered beyond
+	// RequestBodyBufferBytes, as allowed by the annotations of a revision,
+	// spill to. If empty, bodies never spill.
+	RequestBodyBufferDir string `split_words:"true"` // optional
+	// RequestBodySpillMaxBytes bounds the disk space all the spilled request
+	// bodies may take up at once.
+	RequestBodySpillMaxBytes int64 `split_words:"true" default:"1073741824"`
+
+	// MTLSCertDir is the directory of the certificate, key and CA, as issued
+	// by the serving-certs infrastructure, the activator talks to the
+	// queue-proxies with once mutual TLS is enabled in config-network.
+	MTLSCertDir string `split_words:"true"` // optional
+
+	// StatTransport is how stats are sent to the autoscaler, "websocket" or
+	// "grpc".
+	StatTransport string `split_words:"true" default:"websocket"`
+
+	// EnableDebugServer serves the pprof profiles and the Go runtime metrics
+	// on the localhost-only debug port, to profile the data path in
+	// production.
+	EnableDebugServer bool `split_words:"true"` // optional
+
+	// EnableColdStartBreakdownHeader summarizes the phases of the cold start
+	// in a header of the responses to the cold-started requests.
+	EnableColdStartBreakdownHeader bool `split_words:"true"` // optional
 }
 
 func main() {
@@ -94,6 +140,8 @@ func main() {
 	log.Printf("Registering %d informer factories", len(injection.Default.GetInformerFactories()))
 	log.Printf("Registering %d informers", len(injection.Default.GetInformers()))
 
+	// The pods of the revisions are watched to break down the cold starts.
+	ctx = filteredinformerfactory.WithSelectors(ctx, serving.RevisionUID)
 	ctx, informers := injection.Default.SetupInformers(ctx, cfg)
 
 	var env config
@@ -141,6 +189,25 @@ func main() {
 	logger.Debugf("MaxIdleProxyConns: %d, MaxIdleProxyConnsPerHost: %d", env.MaxIdleProxyConns, env.MaxIdleProxyConnsPerHost)
 	transport := pkgnet.NewProxyAutoTransport(env.MaxIdleProxyConns, env.MaxIdleProxyConnsPerHost)
 
+	var (
+		mtlsTransport http.RoundTripper
+		certs         *queue.CertificateReloader
+	)
+	if env.MTLSCertDir != "" {
+		certs, err = queue.NewMutualCertificateReloader(
+			filepath.Join(env.MTLSCertDir, networking.CertificateFile),
+			filepath.Join(env.MTLSCertDir, networking.KeyFile),
+			filepath.Join(env.MTLSCertDir, networking.CAFile))
+		if err != nil {
+			logger.Fatalw("Failed to load the mutual TLS certificate", zap.Error(err))
+		}
+		tlsTransport := activatornet.NewMTLSTransport(certs, env.MaxIdleProxyConns, env.MaxIdleProxyConnsPerHost)
+		// Establish the new connections with the rotated certificate.
+		certs.OnReload(tlsTransport.CloseIdleConnections)
+		go certs.Run(ctx, certReloadPeriod, logger)
+		mtlsTransport = tlsTransport
+	}
+
 	// Fetch networking configuration to determine whether EnableMeshPodAddressability
 	// is enabled or not.
 	networkCM, err := kubeclient.Get(ctx).CoreV1().ConfigMaps(system.Namespace()).Get(ctx, network.ConfigName, metav1.GetOptions{})
@@ -174,21 +241,49 @@ func main() {
 	statCh := make(chan []asmetrics.StatMessage)
 	defer close(statCh)
 
-	// Open a WebSocket connection to the autoscaler.
-	autoscalerEndpoint := "ws://" + pkgnet.GetServiceHostname("autoscaler", system.Namespace()) + autoscalerPort
-	logger.Info("Connecting to Autoscaler at ", autoscalerEndpoint)
-	statSink := websocket.NewDurableSendingConnection(autoscalerEndpoint, logger)
-	defer statSink.Shutdown()
-	go activator.ReportStats(logger, statSink, statCh)
+	var statSink interface{ Status() error }
+	switch env.StatTransport {
+	case "websocket":
+		// Open a WebSocket connection to the autoscaler.
+		autoscalerEndpoint := "ws://" + pkgnet.GetServiceHostname("autoscaler", system.Namespace()) + autoscalerPort
+		logger.Info("Connecting to Autoscaler at ", autoscalerEndpoint)
+		wsSink := websocket.NewDurableSendingConnection(autoscalerEndpoint, logger)
+		defer wsSink.Shutdown()
+		go activator.ReportStats(logger, wsSink, statCh)
+		statSink = wsSink
+	case "grpc":
+		autoscalerEndpoint := pkgnet.GetServiceHostname("autoscaler", system.Namespace()) + autoscalerGRPCPort
+		logger.Info("Connecting to Autoscaler over gRPC at ", autoscalerEndpoint)
+		grpcSink, err := activator.NewGRPCStatSink(autoscalerEndpoint, grpcStatMaxPending, logger)
+		if err != nil {
+			logger.Fatalw("Failed to create the gRPC stat sink", zap.Error(err))
+		}
+		defer grpcSink.Shutdown()
+		go grpcSink.Run(statCh)
+		statSink = grpcSink
+	default:
+		logger.Fatalw("Invalid stat transport, must be websocket or grpc", zap.String("transport", env.StatTransport))
+	}
 
 	// Create and run our concurrency reporter
-	concurrencyReporter := activatorhandler.NewConcurrencyReporter(ctx, env.PodName, statCh)
+	concurrencyReporter := activatorhandler.NewConcurrencyReporter(ctx, env.PodName, statCh, throttler)
 	go concurrencyReporter.Run(ctx.Done())
 
 	// Create activation handler chain
 	// Note: innermost handlers are specified first, ie. the last handler in the chain will be executed first
-	ah := activatorhandler.New(ctx, throttler, transport, networkConfig.EnableMeshPodAddressability, logger)
+	coldStarts := activatorhandler.NewColdStartReporter(filteredpodinformer.Get(ctx, serving.RevisionUID).Lister(),
+		env.EnableColdStartBreakdownHeader)
+	ah := activatorhandler.New(ctx, throttler, transport, mtlsTransport, networkConfig.EnableMeshPodAddressability, coldStarts, logger)
+	var spill *activatorhandler.SpillBuffer
+	if env.RequestBodyBufferDir != "" {
+		spill, err = activatorhandler.NewSpillBuffer(env.RequestBodyBufferDir, env.RequestBodySpillMaxBytes, env.PodName)
+		if err != nil {
+			logger.Fatalw("Failed to create the request body spill buffer", zap.Error(err))
+		}
+	}
+	ah = activatorhandler.NewBodyBufferHandler(ah, env.RequestBodyBufferBytes, spill)
 	ah = concurrencyReporter.Handler(ah)
+	ah = activatorhandler.NewRequestIDHandler(ah)
 	ah = activatorhandler.NewTracingHandler(ah)
 	reqLogHandler, err := pkghttp.NewRequestLogHandler(ah, logging.NewSyncFileWriter(os.Stdout), "",
 		requestLogTemplateInputGetter, false /*enableProbeRequestLog*/)
@@ -200,6 +295,7 @@ func main() {
 	// NOTE: MetricHandler is being used as the outermost handler of the meaty bits. We're not interested in measuring
 	// the healthchecks or probes.
 	ah = activatorhandler.NewMetricHandler(env.PodName, ah)
+	ah = activatorhandler.NewPlaintextMetricHandler(env.PodName, ah)
 	ah = activatorhandler.NewContextHandler(ctx, ah, configStore)
 
 	// Network probe handlers.
@@ -230,12 +326,26 @@ func main() {
 		"h2c":     pkgnet.NewServer(":"+strconv.Itoa(networking.BackendHTTP2Port), ah),
 		"profile": profiling.NewServer(profilingHandler),
 	}
+	if certs != nil {
+		// Serve the ingress with TLS, once internal encryption is enabled.
+		https := pkgnet.NewServer(":"+strconv.Itoa(networking.BackendHTTPSPort), ah)
+		https.TLSConfig = certs.TLSConfig()
+		servers["https"] = https
+	}
+	if env.EnableDebugServer {
+		servers["debug"] = pkghttp.NewDebugServer(networking.DebugPort)
+	}
 
 	errCh := make(chan error, len(servers))
 	for name, server := range servers {
 		go func(name string, s *http.Server) {
 			// Don't forward ErrServerClosed as that indicates we're already shutting down.
-			if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serve := s.ListenAndServe
+			if s.TLSConfig != nil {
+				// The certificate is provided by the TLSConfig.
+				serve = func() error { return s.ListenAndServeTLS("", "") }
+			}
+			if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 				errCh <- fmt.Errorf("%s server failed: %w", name, err)
 			}
 		}(name, server)
@@ -266,7 +376,7 @@ func main() {
 	logger.Info("Servers shutdown.")
 }
 
-func newHealthCheck(sigCtx context.Context, logger *zap.SugaredLogger, statSink *websocket.ManagedConnection) func() error {
+func newHealthCheck(sigCtx context.Context, logger *zap.SugaredLogger, statSink interface{ Status() error }) func() error {
 	once := sync.Once{}
 	return func() error {
 		select {
@@ -23,12 +23,18 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
 
@@ -49,9 +55,11 @@ import (
 	"knative.dev/pkg/signals"
 	"knative.dev/pkg/system"
 	"knative.dev/pkg/version"
+	"knative.dev/serving/pkg/apis/autoscaling"
 	autoscalingv1alpha1 "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
 	"knative.dev/serving/pkg/apis/serving"
 	"knative.dev/serving/pkg/autoscaler/bucket"
+	"knative.dev/serving/pkg/autoscaler/historystore"
 	asmetrics "knative.dev/serving/pkg/autoscaler/metrics"
 	"knative.dev/serving/pkg/autoscaler/scaling"
 	"knative.dev/serving/pkg/autoscaler/statforwarder"
@@ -63,10 +71,16 @@ import (
 )
 
 const (
-	statsServerAddr = ":8080"
-	statsBufferLen  = 1000
-	component       = "autoscaler"
-	controllerNum   = 2
+	statsServerAddr     = ":8080"
+	grpcStatsServerAddr = ":8081"
+	debugServerAddr     = ":8082"
+	statsBufferLen      = 1000
+	component           = "autoscaler"
+	controllerNum       = 2
+
+	// statefulSetOrdinalEnv is set to the pod name when the autoscaler runs as
+	// a StatefulSet, whose ordinals each own a bucket of the revisions.
+	statefulSetOrdinalEnv = "STATEFUL_CONTROLLER_ORDINAL"
 )
 
 func main() {
@@ -138,6 +152,9 @@ func main() {
 
 	collector := asmetrics.NewMetricCollector(
 		statsScraperFactoryFunc(podLister, networkConfig.EnableMeshPodAddressability), logger)
+	// The load histories to predict the load from outlive the autoscaler.
+	collector.PersistHistory(historystore.NewConfigMapStore(ctx, kubeClient))
+	collector.ScrapeCustomMetrics(customMetricScraperFactoryFunc(podLister))
 
 	// Set up scalers.
 	multiScaler := scaling.NewMultiScaler(ctx.Done(),
@@ -167,7 +184,14 @@ func main() {
 	}
 
 	var f *statforwarder.Forwarder
-	if b, bs, err := leaderelection.NewStatefulSetBucketAndSet(int(cc.Buckets)); err == nil {
+	b, bs, err := leaderelection.NewStatefulSetBucketAndSet(int(cc.Buckets))
+	if err != nil && os.Getenv(statefulSetOrdinalEnv) != "" {
+		// Falling back to the standard leader election would make every
+		// ordinal compete for all the buckets rather than own its shard.
+		logger.Fatalw("Failed to set up the StatefulSet bucket, the buckets of the leader election must match the replicas",
+			zap.Error(err))
+	}
+	if err == nil {
 		logger.Info("Running with StatefulSet leader election")
 		ctx = leaderelection.WithStatefulSetElectorBuilder(ctx, cc, b)
 		f = statforwarder.New(ctx, bs)
@@ -185,6 +209,7 @@ func main() {
 
 	// Set up a statserver.
 	statsServer := statserver.New(statsServerAddr, statsCh, logger, f.IsBucketOwner)
+	grpcStatsServer := statserver.NewGRPC(grpcStatsServerAddr, statsCh, logger, f.IsBucketOwner)
 
 	defer f.Cancel()
 
@@ -202,16 +227,29 @@ func main() {
 
 	profilingServer := profiling.NewServer(profilingHandler)
 
+	// The explanations of the scale decisions are served to those allowed to
+	// read the PodAutoscalers of the revisions.
+	debugMux := http.NewServeMux()
+	debugMux.Handle(scaling.ExplainPath, scaling.NewExplainHandler(multiScaler, reviewAuthorizer(kubeClient), logger))
+	debugServer := &http.Server{
+		Addr:    debugServerAddr,
+		Handler: debugMux,
+	}
+
 	eg, egCtx := errgroup.WithContext(ctx)
 	eg.Go(statsServer.ListenAndServe)
+	eg.Go(grpcStatsServer.ListenAndServe)
 	eg.Go(profilingServer.ListenAndServe)
+	eg.Go(debugServer.ListenAndServe)
 
 	// This will block until either a signal arrives or one of the grouped functions
 	// returns an error.
 	<-egCtx.Done()
 
 	statsServer.Shutdown(5 * time.Second)
+	grpcStatsServer.Shutdown(5 * time.Second)
 	profilingServer.Shutdown(context.Background())
+	debugServer.Shutdown(context.Background())
 	// Don't forward ErrServerClosed as that indicates we're already shutting down.
 	if err := eg.Wait(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		logger.Errorw("Error while running server", zap.Error(err))
@@ -235,8 +273,8 @@ func uniScalerFactoryFunc(podLister corev1listers.PodLister,
 		ctx := smetrics.RevisionContext(decider.Namespace, serviceName, configName, revisionName)
 
 		podAccessor := resources.NewPodAccessor(podLister, decider.Namespace, revisionName)
-		return scaling.New(ctx, decider.Namespace, decider.Name, metricClient,
-			podAccessor, &decider.Spec), nil
+		return scaling.NewForAlgorithm(ctx, decider.Namespace, decider.Name, metricClient,
+			podAccessor, &decider.Spec)
 	}
 }
 
@@ -256,6 +294,88 @@ func statsScraperFactoryFunc(podLister corev1listers.PodLister, usePassthroughLb
 	}
 }
 
+func customMetricScraperFactoryFunc(podLister corev1listers.PodLister) asmetrics.CustomMetricScraperFactory {
+	return func(metric *autoscalingv1alpha1.Metric, logger *zap.SugaredLogger) (asmetrics.CustomMetricScraper, error) {
+		if !scalesOnCustomMetric(metric) {
+			return nil, nil
+		}
+
+		revisionName := metric.Labels[serving.RevisionLabelKey]
+		if revisionName == "" {
+			return nil, fmt.Errorf("label %q not found or empty in Metric %s", serving.RevisionLabelKey, metric.Name)
+		}
+
+		podAccessor := resources.NewPodAccessor(podLister, metric.Namespace, revisionName)
+		return asmetrics.NewCustomMetricScraper(metric, podAccessor, logger), nil
+	}
+}
+
+// scalesOnCustomMetric returns whether the revision is scaled on the custom
+// metric, either solely or combined with other metrics, or on the gpu metric,
+// which is scraped the same way.
+func scalesOnCustomMetric(metric *autoscalingv1alpha1.Metric) bool {
+	switch metric.Annotations[autoscaling.MetricAnnotationKey] {
+	case autoscaling.Custom, autoscaling.GPU:
+		return true
+	}
+	combined, _ := autoscaling.ParseMetricValues(metric.Annotations[autoscaling.CombinedMetricsAnnotationKey])
+	for _, m := range combined {
+		if m.Metric == autoscaling.Custom {
+			return true
+		}
+	}
+	return false
+}
+
+// reviewAuthorizer returns a function authorizing the requests by their
+// bearer token, if its user is allowed to get the PodAutoscaler of the
+// revision, as reviewed by the API server.
+func reviewAuthorizer(kubeClient kubernetes.Interface) func(*http.Request, types.NamespacedName) error {
+	return func(r *http.Request, key types.NamespacedName) error {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || token == r.Header.Get("Authorization") {
+			return errors.New("no bearer token given")
+		}
+
+		tr, err := kubeClient.AuthenticationV1().TokenReviews().Create(r.Context(), &authenticationv1.TokenReview{
+			Spec: authenticationv1.TokenReviewSpec{Token: token},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to review the token: %w", err)
+		}
+		if !tr.Status.Authenticated {
+			return errors.New("token is not authenticated")
+		}
+
+		extra := make(map[string]authorizationv1.ExtraValue, len(tr.Status.User.Extra))
+		for k, v := range tr.Status.User.Extra {
+			extra[k] = authorizationv1.ExtraValue(v)
+		}
+		sar, err := kubeClient.AuthorizationV1().SubjectAccessReviews().Create(r.Context(), &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   tr.Status.User.Username,
+				UID:    tr.Status.User.UID,
+				Groups: tr.Status.User.Groups,
+				Extra:  extra,
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: key.Namespace,
+					Name:      key.Name,
+					Verb:      "get",
+					Group:     autoscalingv1alpha1.SchemeGroupVersion.Group,
+					Resource:  "podautoscalers",
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to review the access: %w", err)
+		}
+		if !sar.Status.Allowed {
+			return fmt.Errorf("%s is not allowed to get the PodAutoscaler %s", tr.Status.User.Username, key)
+		}
+		return nil
+	}
+}
+
 func flush(logger *zap.SugaredLogger) {
 	logger.Sync()
 	metrics.FlushExporter()
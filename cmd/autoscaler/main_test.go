@@ -17,14 +17,24 @@ limitations under the License.
 package main
 
 import (
+	"errors"
 	"fmt"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	kubeinformers "k8s.io/client-go/informers"
 	fakek8s "k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
 
+	logtesting "knative.dev/pkg/logging/testing"
+	"knative.dev/serving/pkg/apis/autoscaling"
+	autoscalingv1alpha1 "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
 	"knative.dev/serving/pkg/apis/serving"
 	"knative.dev/serving/pkg/autoscaler/scaling"
 )
@@ -111,3 +121,113 @@ func TestUniScalerFactoryFunc(t *testing.T) {
 func testUniScalerFactory() func(decider *scaling.Decider) (scaling.UniScaler, error) {
 	return uniScalerFactoryFunc(kubeInformer.Core().V1().Pods().Lister(), nil)
 }
+
+func TestCustomMetricScraperFactoryFunc(t *testing.T) {
+	factory := customMetricScraperFactoryFunc(kubeInformer.Core().V1().Pods().Lister())
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		wantScraper bool
+	}{{
+		name: "concurrency",
+	}, {
+		name:        "custom",
+		annotations: map[string]string{autoscaling.MetricAnnotationKey: autoscaling.Custom},
+		wantScraper: true,
+	}, {
+		name:        "custom combined",
+		annotations: map[string]string{autoscaling.CombinedMetricsAnnotationKey: "rps=100, custom=5"},
+		wantScraper: true,
+	}, {
+		name:        "rps combined",
+		annotations: map[string]string{autoscaling.CombinedMetricsAnnotationKey: "rps=100"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			metric := &autoscalingv1alpha1.Metric{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   "some-namespace",
+					Name:        "some-revision",
+					Labels:      map[string]string{serving.RevisionLabelKey: "some-revision"},
+					Annotations: tc.annotations,
+				},
+			}
+			scraper, err := factory(metric, logtesting.TestLogger(t))
+			if err != nil {
+				t.Fatal("customMetricScraperFactory() =", err)
+			}
+			if got := scraper != nil; got != tc.wantScraper {
+				t.Errorf("Got a scraper = %v, want: %v", got, tc.wantScraper)
+			}
+		})
+	}
+}
+
+func TestReviewAuthorizer(t *testing.T) {
+	kubeClient := fakek8s.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "tokenreviews", func(action clientgotesting.Action) (bool, runtime.Object, error) {
+		tr := action.(clientgotesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		switch tr.Spec.Token {
+		case "error":
+			return true, nil, errors.New("boom")
+		case "bad":
+			return true, tr, nil
+		}
+		tr.Status.Authenticated = true
+		tr.Status.User.Username = tr.Spec.Token
+		return true, tr, nil
+	})
+	kubeClient.PrependReactor("create", "subjectaccessreviews", func(action clientgotesting.Action) (bool, runtime.Object, error) {
+		sar := action.(clientgotesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attrs := sar.Spec.ResourceAttributes
+		sar.Status.Allowed = sar.Spec.User == "admin" && attrs.Namespace == "a-ns" &&
+			attrs.Resource == "podautoscalers" && attrs.Verb == "get"
+		return true, sar, nil
+	})
+	authorize := reviewAuthorizer(kubeClient)
+
+	for _, tc := range []struct {
+		name   string
+		header string
+		ns     string
+		want   bool
+	}{{
+		name:   "allowed",
+		header: "Bearer admin",
+		ns:     "a-ns",
+		want:   true,
+	}, {
+		name:   "other namespace",
+		header: "Bearer admin",
+		ns:     "another-ns",
+	}, {
+		name:   "other user",
+		header: "Bearer somebody",
+		ns:     "a-ns",
+	}, {
+		name:   "not authenticated",
+		header: "Bearer bad",
+		ns:     "a-ns",
+	}, {
+		name:   "review failed",
+		header: "Bearer error",
+		ns:     "a-ns",
+	}, {
+		name: "no token",
+		ns:   "a-ns",
+	}, {
+		name:   "not a bearer token",
+		header: "Basic admin",
+		ns:     "a-ns",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/debug/scaling/"+tc.ns+"/a-rev", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			err := authorize(req, types.NamespacedName{Namespace: tc.ns, Name: "a-rev"})
+			if got := err == nil; got != tc.want {
+				t.Errorf("Authorized = %v, want: %v, err: %v", got, tc.want, err)
+			}
+		})
+	}
+}
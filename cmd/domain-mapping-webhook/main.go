@@ -33,6 +33,7 @@ import (
 	servingv1alpha1 "knative.dev/serving/pkg/apis/serving/v1alpha1"
 	servingv1beta1 "knative.dev/serving/pkg/apis/serving/v1beta1"
 	"knative.dev/serving/pkg/reconciler/domainmapping/config"
+	extravalidation "knative.dev/serving/pkg/webhook"
 )
 
 var types = map[schema.GroupVersionKind]resourcesemantics.GenericCRD{
@@ -40,6 +41,14 @@ var types = map[schema.GroupVersionKind]resourcesemantics.GenericCRD{
 	servingv1beta1.SchemeGroupVersion.WithKind("DomainMapping"):  &servingv1beta1.DomainMapping{},
 }
 
+var domainMappingValidation = validation.NewCallback(
+	extravalidation.ValidateDomainMapping, webhook.Create, webhook.Update)
+
+var callbacks = map[schema.GroupVersionKind]validation.Callback{
+	servingv1alpha1.SchemeGroupVersion.WithKind("DomainMapping"): domainMappingValidation,
+	servingv1beta1.SchemeGroupVersion.WithKind("DomainMapping"):  domainMappingValidation,
+}
+
 func newDefaultingAdmissionController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
 	// Decorate contexts with the current state of the config.
 	store := config.NewStore(ctx)
@@ -85,6 +94,9 @@ func newValidatingAdmissionController(ctx context.Context, cmw configmap.Watcher
 
 		// Whether to disallow unknown fields.
 		true,
+
+		// Extra validating callbacks to be applied to resources.
+		callbacks,
 	)
 }
 
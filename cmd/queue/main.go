@@ -23,16 +23,26 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"go.opencensus.io/plugin/ochttp"
-	"go.uber.org/automaxprocs/maxprocs"
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	network "knative.dev/networking/pkg"
 	pkglogging "knative.dev/pkg/logging"
@@ -44,8 +54,8 @@ import (
 	"knative.dev/pkg/signals"
 	"knative.dev/pkg/tracing"
 	tracingconfig "knative.dev/pkg/tracing/config"
-	"knative.dev/pkg/tracing/propagation/tracecontextb3"
 	"knative.dev/serving/pkg/activator"
+	asmetrics "knative.dev/serving/pkg/autoscaler/metrics"
 	pkghttp "knative.dev/serving/pkg/http"
 	"knative.dev/serving/pkg/http/handler"
 	"knative.dev/serving/pkg/logging"
@@ -63,6 +73,22 @@ const (
 	// This is to give networking a little bit more time to remove the pod
 	// from its configuration and propagate that to all loadbalancers and nodes.
 	drainSleepDuration = 30 * time.Second
+
+	// configReloadPeriod is the interval of time between checks whether the
+	// runtime configuration in QueueConfigDir changed on disk.
+	configReloadPeriod = 10 * time.Second
+
+	// certReloadPeriod is the interval of time between checks whether the TLS
+	// certificate of the main server changed on disk.
+	certReloadPeriod = 10 * time.Second
+
+	// proxyProtocolTimeout is how long a client of the main server has to
+	// send its PROXY protocol header, if enabled.
+	proxyProtocolTimeout = 5 * time.Second
+
+	// statPushMaxPending bounds the stats kept to be pushed again, once the
+	// gRPC stream to the autoscaler is re-established.
+	statPushMaxPending = 30
 )
 
 var (
@@ -79,7 +105,41 @@ type config struct {
 	RevisionTimeoutSeconds   int    `split_words:"true" required:"true"`
 	ServingReadinessProbe    string `split_words:"true" required:"true"`
 	EnableProfiling          bool   `split_words:"true"` // optional
+	EnableDebugServer        bool   `split_words:"true"` // optional
 	EnableHTTP2AutoDetection bool   `split_words:"true"` // optional
+	EnableStatCompression    bool   `split_words:"true"` // optional
+	EnableRequestsDebug      bool   `split_words:"true"` // optional
+
+	// Whether the connections to the main server start with a PROXY protocol
+	// header carrying the address of the client. Connections without the
+	// header are rejected, including the ones of the kubelet's HTTP probes.
+	EnableProxyProtocol bool `split_words:"true"` // optional
+
+	// Whether responses tell the time their request waited for the breaker and
+	// spent in the user container, for debugging. Off by default, as the
+	// timing shouldn't be leaked to clients.
+	EnableTimingHeaders bool `split_words:"true"` // optional
+
+	// Policy for the forwarded chain of incoming requests, one of "append"
+	// (the default), "replace" or "trust-hops", which trusts the hops added
+	// by the ForwardedTrustedHops proxies in front of the queue-proxy.
+	ForwardedPolicy      string `split_words:"true"` // optional
+	ForwardedTrustedHops int    `split_words:"true"` // optional
+
+	// TLS configuration of the main server, plaintext if unset
+	QueueServingCertFile string `split_words:"true"` // optional
+	QueueServingKeyFile  string `split_words:"true"` // optional
+
+	// The directory of the certificate, its key and the CA bundle the
+	// activator, and the ingress once internal encryption is enabled, is
+	// served with TLS with, which is disabled if unset, and the SAN the
+	// certificate of the activator has, to require it from the clients.
+	ActivatorMTLSCertDir    string `split_words:"true"` // optional
+	ActivatorCertificateSAN string `split_words:"true"` // optional
+
+	// Whether the hops to the queue-proxy are meant to be TLS, so the
+	// plain text requests are counted as fallbacks.
+	InternalEncryption bool `split_words:"true"` // optional
 
 	// Logging configuration
 	ServingLoggingConfig         string `split_words:"true" required:"true"`
@@ -88,6 +148,22 @@ type config struct {
 	ServingEnableRequestLog      bool   `split_words:"true"` // optional
 	ServingEnableProbeRequestLog bool   `split_words:"true"` // optional
 
+	// Access log configuration, disabled if the format is unset
+	ServingAccessLogFormat      string `split_words:"true"` // optional
+	ServingAccessLogTemplate    string `split_words:"true"` // optional
+	ServingAccessLogDestination string `split_words:"true"` // optional
+
+	// OTLP metrics exporter configuration, disabled if the endpoint is unset
+	ServingOTLPEndpoint       string        `split_words:"true"` // optional
+	ServingOTLPProtocol       string        `split_words:"true"` // optional
+	ServingOTLPExportInterval time.Duration `split_words:"true"` // optional
+
+	// OTLP request event exporter configuration, disabled if the endpoint is unset
+	ServingOTLPLogsEndpoint           string        `split_words:"true"` // optional
+	ServingOTLPLogsProtocol           string        `split_words:"true"` // optional
+	ServingOTLPLogsExportInterval     time.Duration `split_words:"true"` // optional
+	ServingOTLPLogsMaxEventsPerSecond float64       `split_words:"true"` // optional
+
 	// Metrics configuration
 	ServingNamespace             string `split_words:"true" required:"true"`
 	ServingRevision              string `split_words:"true" required:"true"`
@@ -103,13 +179,277 @@ type config struct {
 	TracingConfigBackend        tracingconfig.BackendType `split_words:"true"` // optional
 	TracingConfigSampleRate     float64                   `split_words:"true"` // optional
 	TracingConfigZipkinEndpoint string                    `split_words:"true"` // optional
+	// The formats the trace context is passed on in, both W3C Trace Context
+	// and B3 by default.
+	TracingConfigPropagation pkghttp.TracePropagation `split_words:"true"` // optional
 
 	// Concurrency State Endpoint configuration
-	ConcurrencyStateEndpoint string `split_words:"true"` // optional
+	ConcurrencyStateEndpoint  string `split_words:"true"` // optional
+	ConcurrencyStateTokenPath string `split_words:"true"` // optional
+	// The client certificate, its key and the CA bundle for mTLS with the
+	// concurrency state endpoint. They're reloaded when they change.
+	ConcurrencyStateCertPath string `split_words:"true"` // optional
+	ConcurrencyStateKeyPath  string `split_words:"true"` // optional
+	ConcurrencyStateCAPath   string `split_words:"true"` // optional
+	// ConcurrencyStateBackend selects whether pause and resume call the
+	// ConcurrencyStateEndpoint or freeze the cgroup at
+	// ConcurrencyStateCgroupPath.
+	ConcurrencyStateBackend    string `split_words:"true" default:"endpoint"` // optional
+	ConcurrencyStateCgroupPath string `split_words:"true"`                    // optional
+	// Failed pause and resume requests are attempted up to
+	// ConcurrencyStateAttempts times, backing off exponentially from
+	// ConcurrencyStateBackoff up to ConcurrencyStateMaxBackoff. Each attempt
+	// times out after ConcurrencyStateTimeout. Once all attempts failed, the
+	// pod reports not ready.
+	ConcurrencyStateAttempts   int           `split_words:"true" default:"3"`     // optional
+	ConcurrencyStateBackoff    time.Duration `split_words:"true" default:"100ms"` // optional
+	ConcurrencyStateMaxBackoff time.Duration `split_words:"true" default:"2s"`    // optional
+	ConcurrencyStateTimeout    time.Duration `split_words:"true" default:"5s"`    // optional
+	// The user container is only paused once there were no requests for
+	// ConcurrencyStatePauseDelay.
+	ConcurrencyStatePauseDelay time.Duration `split_words:"true"` // optional
+	// With ConcurrencyStateFailFast, requests are rejected once the user
+	// container couldn't be resumed, and a warning event is recorded about
+	// the pod with ServingPodUID.
+	ConcurrencyStateFailFast bool   `split_words:"true"` // optional
+	ServingPodUID            string `split_words:"true"` // optional
+	// With ConcurrencyStateProbeInterposition, the kubelet probes of the user
+	// container are answered on its behalf while it's paused.
+	ConcurrencyStateProbeInterposition bool `split_words:"true"` // optional
+
+	// Response header rules, JSON encoded
+	ResponseHeaderRules string `split_words:"true"` // optional
+
+	// Request rate limiting configuration
+	RequestRateLimit float64 `split_words:"true"` // optional
+	RequestRateBurst int     `split_words:"true"` // optional
+
+	// Per client request rate limiting configuration, clients are identified
+	// by their IP or, if ClientRateLimitKey is "header:<name>", a header
+	ClientRequestRateLimit float64 `split_words:"true"` // optional
+	ClientRequestRateBurst int     `split_words:"true"` // optional
+	ClientRateLimitKey     string  `split_words:"true"` // optional
+
+	// Requests waiting for capacity are admitted by the priority carried in
+	// RequestPriorityHeader or assigned by the JSON encoded
+	// RequestPriorityRules, e.g. {"/checkout": 9}. A request is admitted
+	// regardless of its priority, once RequestPriorityStarvationLimit
+	// requests of higher priority were admitted ahead of it.
+	RequestPriorityHeader          string `split_words:"true"`              // optional
+	RequestPriorityRules           string `split_words:"true"`              // optional
+	RequestPriorityStarvationLimit int    `split_words:"true" default:"10"` // optional
+
+	// Requests take as many slots of the breaker as their weight carried in
+	// RequestWeightHeader or assigned by the JSON encoded RequestWeightRules,
+	// e.g. {"/batch": 4}, up to the container concurrency.
+	RequestWeightHeader string `split_words:"true"` // optional
+	RequestWeightRules  string `split_words:"true"` // optional
+
+	// If set, the capacity of the breaker is adjusted between 1 and the
+	// container concurrency to keep the latency of the user container near
+	// AdaptiveConcurrencyTargetLatency.
+	AdaptiveConcurrencyTargetLatency time.Duration `split_words:"true"` // optional
+
+	// Requests with one of RetryMethods, the idempotent methods by default,
+	// are attempted up to RetryAttempts times if they fail to reach the user
+	// container, get one of RetryStatusCodes or get no response headers
+	// within RetryPerTryTimeout. If RetryBudget is set, retries are bounded
+	// to that fraction of the requests.
+	RetryAttempts      int           `split_words:"true"` // optional
+	RetryMethods       []string      `split_words:"true"` // optional
+	RetryStatusCodes   []int         `split_words:"true"` // optional
+	RetryPerTryTimeout time.Duration `split_words:"true"` // optional
+	RetryBudget        float64       `split_words:"true"` // optional
+
+	// TimeoutRules are the JSON encoded time to first byte timeouts in
+	// seconds by path prefix, optionally preceded by a method, overriding
+	// RevisionTimeoutSeconds, e.g. {"/batch": 600, "POST /upload": 60}.
+	TimeoutRules string `split_words:"true"` // optional
+
+	// Requests arriving without an ID in RequestIDHeader get one generated
+	// by RequestIDGenerator. Request IDs are disabled if the header is set
+	// to "".
+	RequestIDHeader    string `split_words:"true" default:"X-Request-Id"` // optional
+	RequestIDGenerator string `split_words:"true"`                        // optional
+
+	// Comma separated list of port=weight pairs of user container ports to
+	// split the traffic across, e.g. "8080=90,8081=10".
+	UserBackends string `split_words:"true"` // optional
+
+	// UserSocket is the path of the Unix domain socket the user container
+	// listens on. If set, requests and probes are sent over it instead of
+	// the user port. It can't be combined with UserBackends.
+	UserSocket string `split_words:"true"` // optional
+
+	// UserProtocol is the protocol spoken by the user container. If it's
+	// queue.ProtocolH2C, all requests are proxied over h2c, whatever protocol
+	// they were received with.
+	UserProtocol string `split_words:"true"` // optional
+
+	// If ServingReadinessProbeGRPC is set, the TCP socket readiness probe is
+	// made by the gRPC Health Checking Protocol, checking
+	// ServingReadinessProbeGRPCService.
+	ServingReadinessProbeGRPC        bool   `split_words:"true"` // optional
+	ServingReadinessProbeGRPCService string `split_words:"true"` // optional
+
+	// If ServingReadinessProbePassive is set, the serving container is only
+	// probed until it first passes the readiness probe, the outcomes of the
+	// requests telling whether it's ready after.
+	ServingReadinessProbePassive bool `split_words:"true"` // optional
+
+	// WarmUp is the JSON encoded call made to the serving container once
+	// it's ready, the pod only being ready once the call succeeded.
+	WarmUp string `split_words:"true"` // optional
+
+	// ServingSidecarReadinessProbes are the JSON encoded readiness probes of
+	// the sidecar containers by name, probed along with the one of the
+	// serving container, ServingContainerName. The queue-proxy is only ready
+	// if all of them pass.
+	ServingSidecarReadinessProbes string `split_words:"true"`                          // optional
+	ServingContainerName          string `split_words:"true" default:"user-container"` // optional
+
+	// StreamMode defines how WebSocket connections and server-sent event
+	// streams are accounted for, see queue.StreamMode.
+	StreamMode string `split_words:"true"` // optional
+
+	// Once the queue-proxy drains, the WebSocket connections and server-sent
+	// event streams get StreamDrainSettleTime to finish, WebSocket clients
+	// being sent a close frame, before they're closed. If 0, they're cut as
+	// the queue-proxy exits. With StreamDrainGoAway the HTTP/2 connections are
+	// sent a GOAWAY frame as the drain starts.
+	StreamDrainSettleTime time.Duration `split_words:"true"` // optional
+	StreamDrainGoAway     bool          `split_words:"true"` // optional
+
+	// DrainSignal is the JSON encoded signal telling the serving container
+	// the queue-proxy drains, see queue.DrainSignal. Its termination is then
+	// held off until the streaming responses in flight completed.
+	DrainSignal string `split_words:"true"` // optional
+
+	// Requests with a body of more than MaxRequestBodyBytes bytes are rejected
+	// with a 413. If 0, request bodies aren't limited.
+	MaxRequestBodyBytes int64 `split_words:"true"` // optional
+
+	// At most MaxUpstreamConnections connections to the user container are
+	// in use at once, and its responses are read at MaxResponseBytesPerSecond
+	// together. Either is unlimited if 0.
+	MaxUpstreamConnections    int   `split_words:"true"` // optional
+	MaxResponseBytesPerSecond int64 `split_words:"true"` // optional
+
+	// Request bodies larger than RequestSpoolThreshold bytes are spooled to a
+	// temporary file in RequestSpoolDir before being proxied. If 0, request
+	// bodies are streamed.
+	RequestSpoolThreshold int64  `split_words:"true"` // optional
+	RequestSpoolDir       string `split_words:"true"` // optional
+
+	// Requests preferring to respond asynchronously are stored in
+	// AsyncRequestsDir and run in the background by AsyncRequestsWorkers
+	// workers, their response being POSTed to their callback URL, if its
+	// host is one of AsyncCallbackAllowedHosts. If AsyncRequestsDir is unset,
+	// all the requests are run synchronously.
+	AsyncRequestsDir          string   `split_words:"true"`              // optional
+	AsyncRequestsWorkers      int      `split_words:"true" default:"10"` // optional
+	AsyncCallbackAllowedHosts []string `split_words:"true"`              // optional
+
+	// Circuit breaker configuration
+	CircuitBreakerFailureRatio float64       `split_words:"true"` // optional
+	CircuitBreakerWindow       time.Duration `split_words:"true"` // optional
+	CircuitBreakerMinRequests  int           `split_words:"true"` // optional
+	CircuitBreakerCooldown     time.Duration `split_words:"true"` // optional
+
+	// Requests whose MirrorHeader has MirrorHeaderValue are also sent to
+	// MirrorTarget, e.g. "http://127.0.0.1:9999", for debugging. The responses
+	// of the mirror are discarded. If MirrorTarget is unset, nothing is mirrored.
+	MirrorTarget      string `split_words:"true"`                           // optional
+	MirrorHeader      string `split_words:"true" default:"X-Debug-Capture"` // optional
+	MirrorHeaderValue string `split_words:"true" default:"1"`               // optional
+
+	// The pod is unhealthy at the saturation health path of the admin port
+	// once its breaker's queue has been full for SaturationUnhealthyThreshold.
+	// If SaturationAffectsReadiness is set, it's also reported as not ready.
+	SaturationUnhealthyThreshold time.Duration `split_words:"true" default:"30s"` // optional
+	SaturationAffectsReadiness   bool          `split_words:"true"`               // optional
+
+	// If set, the stats are pushed to the autoscaler at this gRPC endpoint,
+	// rather than only being served to be scraped.
+	StatPushEndpoint string `split_words:"true"` // optional
+
+	// Whether to set GOMAXPROCS to the CPU quota of the container, unless
+	// GOMAXPROCS is set explicitly.
+	AutoGomaxprocs bool `split_words:"true"` // optional
+
+	// If set, the directory of the mounted ConfigMap whose settings override
+	// the log level, the revision timeout, the metrics backend, the drain
+	// timeout and the concurrency state endpoint while the queue-proxy runs.
+	QueueConfigDir string `split_words:"true"` // optional
+}
+
+// runtimeSettings are the settings of the queue-proxy that are reloaded from
+// the QueueConfigDir while it runs. The settings missing from the ConfigMap
+// revert to those of the environment.
+type runtimeSettings struct {
+	logLevel     zap.AtomicLevel
+	envLogLevel  zapcore.Level
+	timeout      *atomic.Duration
+	drainTimeout *atomic.Duration
+	// metricsBackend is the backend the request metrics are exported to, if
+	// they're enabled.
+	metricsBackend string
+	// concurrencyEndpoint is set if pause and resume call the concurrency
+	// state endpoint.
+	concurrencyEndpoint *queue.ConcurrencyEndpoint
+}
+
+func newRuntimeSettings(env config, logLevel zap.AtomicLevel) *runtimeSettings {
+	return &runtimeSettings{
+		logLevel:     logLevel,
+		envLogLevel:  logLevel.Level(),
+		timeout:      atomic.NewDuration(time.Duration(env.RevisionTimeoutSeconds) * time.Second),
+		drainTimeout: atomic.NewDuration(drainSleepDuration),
+	}
 }
 
-func init() {
-	maxprocs.Set()
+// apply applies the runtime configuration c.
+func (s *runtimeSettings) apply(ctx context.Context, logger *zap.SugaredLogger, env config, c *queue.RuntimeConfig) {
+	level := s.envLogLevel
+	if c.LogLevel != "" {
+		// The level was validated as it was loaded.
+		level.UnmarshalText([]byte(c.LogLevel))
+	}
+	s.logLevel.SetLevel(level)
+
+	timeout := time.Duration(env.RevisionTimeoutSeconds) * time.Second
+	if c.Timeout > 0 {
+		timeout = c.Timeout
+	}
+	s.timeout.Store(timeout)
+
+	drainTimeout := drainSleepDuration
+	if c.DrainTimeout > 0 {
+		drainTimeout = c.DrainTimeout
+	}
+	s.drainTimeout.Store(drainTimeout)
+
+	backend := env.ServingRequestMetricsBackend
+	if c.MetricsBackend != "" {
+		backend = c.MetricsBackend
+	}
+	// The backend can only be switched if the request metrics were enabled
+	// as the queue-proxy started.
+	if s.metricsBackend != "" && backend != s.metricsBackend {
+		if err := setupMetricsExporter(ctx, logger, backend, env.MetricsCollectorAddress); err != nil {
+			logger.Errorw("Failed to switch the request metrics backend to "+backend, zap.Error(err))
+		} else {
+			s.metricsBackend = backend
+		}
+	}
+
+	if s.concurrencyEndpoint != nil {
+		endpoint := env.ConcurrencyStateEndpoint
+		if c.ConcurrencyStateEndpoint != "" {
+			endpoint = c.ConcurrencyStateEndpoint
+		}
+		s.concurrencyEndpoint.SetEndpoint(endpoint)
+	}
 }
 
 func main() {
@@ -138,8 +478,9 @@ func main() {
 	}
 
 	// Setup the logger.
-	logger, _ := pkglogging.NewLogger(env.ServingLoggingConfig, env.ServingLoggingLevel)
+	logger, logLevel := pkglogging.NewLogger(env.ServingLoggingConfig, env.ServingLoggingLevel)
 	defer flush(logger)
+	settings := newRuntimeSettings(env, logLevel)
 
 	logger = logger.Named("queueproxy").With(
 		zap.String(logkey.Key, types.NamespacedName{
@@ -148,44 +489,124 @@ func main() {
 		}.String()),
 		zap.String(logkey.Pod, env.ServingPod))
 
+	cpuQuota := setMaxProcs(logger, env, queue.CgroupRoot)
+
 	// Report stats on Go memory usage every 30 seconds.
 	metrics.MemStatsOrDie(ctx)
 
 	// Setup reporters and processes to handle stat reporting.
 	promStatReporter, err := queue.NewPrometheusStatsReporter(
 		env.ServingNamespace, env.ServingConfiguration, env.ServingRevision,
-		env.ServingPod, reportingPeriod)
+		env.ServingPod, reportingPeriod, env.ContainerConcurrency)
 	if err != nil {
 		logger.Fatalw("Failed to create stats reporter", zap.Error(err))
 	}
 
-	protoStatReporter := queue.NewProtobufStatsReporter(env.ServingPod, reportingPeriod)
+	protoStatReporter := queue.NewProtobufStatsReporter(env.ServingPod, reportingPeriod, env.EnableStatCompression)
 
 	reportTicker := time.NewTicker(reportingPeriod)
 	defer reportTicker.Stop()
 
-	stats := network.NewRequestStats(time.Now())
+	var statCh chan []asmetrics.StatMessage
+	if env.StatPushEndpoint != "" {
+		logger.Info("Pushing stats to the autoscaler at ", env.StatPushEndpoint)
+		statSink, err := activator.NewGRPCStatSink(env.StatPushEndpoint, statPushMaxPending, logger)
+		if err != nil {
+			logger.Fatalw("Failed to create the gRPC stat sink", zap.Error(err))
+		}
+		defer statSink.Shutdown()
+		statCh = make(chan []asmetrics.StatMessage)
+		go statSink.Run(statCh)
+	}
+
+	stats := queue.NewRequestStats(time.Now())
 	go func() {
+		key := types.NamespacedName{Namespace: env.ServingNamespace, Name: env.ServingRevision}
 		for now := range reportTicker.C {
 			stat := stats.Report(now)
 			promStatReporter.Report(stat)
 			protoStatReporter.Report(stat)
+			if statCh != nil {
+				statCh <- []asmetrics.StatMessage{{Key: key, Stat: protoStatReporter.Stat()}}
+			}
 		}
 	}()
 
+	if env.ServingOTLPEndpoint != "" {
+		go otlpExporter(logger, promStatReporter, env).Run(ctx, env.ServingOTLPExportInterval)
+	}
+
 	// Setup probe to run for checking user-application healthiness.
 	probe := buildProbe(logger, env)
 	healthState := health.NewState()
+	drainer := health.NewDrainer(healthState)
+	streamDrainer := buildStreamDrainer(logger, env)
+	drainNotifier := buildDrainNotifier(logger, env)
+	async := buildAsyncRequests(logger, env, stats)
+
+	var inFlight *queue.InFlightRequests
+	if env.EnableRequestsDebug {
+		inFlight = queue.NewInFlightRequests()
+	}
+
+	var backends *queue.WeightedBackends
+	if env.UserBackends != "" {
+		if env.UserSocket != "" {
+			logger.Fatal("User backends can't be combined with a user socket")
+		}
+		weights, err := queue.ParseBackendWeights(env.UserBackends)
+		if err != nil {
+			logger.Fatalw("Failed to parse user backends", zap.Error(err))
+		}
+		if backends, err = queue.NewWeightedBackends(weights); err != nil {
+			logger.Fatalw("Failed to create user backends", zap.Error(err))
+		}
+	}
 
-	mainServer := buildServer(ctx, env, healthState, probe, stats, logger)
+	breaker := buildBreaker(logger, env)
+	var saturation *queue.SaturationHealth
+	if breaker != nil {
+		saturation = queue.NewSaturationHealth(breaker, env.SaturationUnhealthyThreshold)
+	}
+
+	mainServer := buildServer(ctx, env, healthState, drainer, streamDrainer, drainNotifier, async, probe, stats, promStatReporter, protoStatReporter, inFlight, backends, breaker, saturation, settings, logger)
+	if env.QueueConfigDir != "" {
+		watcher := queue.NewRuntimeConfigWatcher(env.QueueConfigDir, func(c *queue.RuntimeConfig) {
+			settings.apply(ctx, logger, env, c)
+		})
+		go watcher.Run(ctx, configReloadPeriod, logger)
+	}
+	if env.QueueServingCertFile != "" {
+		certs, err := queue.NewCertificateReloader(env.QueueServingCertFile, env.QueueServingKeyFile)
+		if err != nil {
+			logger.Fatalw("Failed to load TLS certificate", zap.Error(err))
+		}
+		go certs.Run(ctx, certReloadPeriod, logger)
+		tlsConfig := certs.TLSConfig()
+		if mainServer.TLSConfig != nil {
+			// Keep the protocols of the HTTP/2 configuration of the server.
+			tlsConfig.NextProtos = mainServer.TLSConfig.NextProtos
+		}
+		mainServer.TLSConfig = tlsConfig
+	}
 	servers := map[string]*http.Server{
 		"main":    mainServer,
-		"admin":   buildAdminServer(logger, healthState),
+		"admin":   buildAdminServer(logger, healthState, promStatReporter, inFlight, breaker, saturation, probe, cpuQuota),
 		"metrics": buildMetricsServer(promStatReporter, protoStatReporter),
+		// Draining and updating the backend weights are left to the
+		// containers of the pod, the admin port being reachable by the other
+		// pods.
+		"local-admin": buildLocalAdminServer(drainer, backends),
 	}
 	if env.EnableProfiling {
 		servers["profile"] = profiling.NewServer(profiling.NewHandler(logger, true))
 	}
+	if env.EnableDebugServer {
+		servers["debug"] = pkghttp.NewDebugServer(networking.DebugPort)
+	}
+	if env.ActivatorMTLSCertDir != "" {
+		servers["mtls"] = buildMTLSServer(ctx, logger, env, mainServer.Handler)
+	}
 
 	errCh := make(chan error)
 	listenCh := make(chan struct{})
@@ -199,11 +620,20 @@ func main() {
 
 			// Notify the unix socket setup that the tcp socket for the main server is ready.
 			if s == mainServer {
+				if env.EnableProxyProtocol {
+					l = queue.NewProxyProtocolListener(l, proxyProtocolTimeout)
+				}
 				close(listenCh)
 			}
 
+			serve := s.Serve
+			if s.TLSConfig != nil {
+				// The certificate is provided by the TLSConfig.
+				serve = func(l net.Listener) error { return s.ServeTLS(l, "", "") }
+			}
+
 			// Don't forward ErrServerClosed as that indicates we're already shutting down.
-			if err := s.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			if err := serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
 				errCh <- fmt.Errorf("%s server failed to serve: %w", name, err)
 			}
 		}(name, server)
@@ -240,15 +670,37 @@ func main() {
 	case <-ctx.Done():
 		logger.Info("Received TERM signal, attempting to gracefully shutdown servers.")
 		healthState.Shutdown(func() {
-			logger.Infof("Sleeping %v to allow K8s propagation of non-ready state", drainSleepDuration)
-			time.Sleep(drainSleepDuration)
+			if drainNotifier != nil {
+				go notifyDrain(logger, drainNotifier)
+			}
+			drainTimeout := settings.drainTimeout.Load()
+			logger.Infof("Sleeping %v to allow K8s propagation of non-ready state", drainTimeout)
+			time.Sleep(drainTimeout)
+
+			// The user container, which is only sent SIGTERM once the drain
+			// completes, gets to finish its streaming responses.
+			if drainNotifier != nil {
+				if left := drainNotifier.Wait(drainTimeout); left > 0 {
+					logger.Infof("%d streaming responses were still in flight as the drain timed out", left)
+				}
+			}
 
 			// Calling server.Shutdown() allows pending requests to
-			// complete, while no new work is accepted.
+			// complete, while no new work is accepted. Meanwhile the
+			// streams, which might never complete on their own, are
+			// drained.
 			logger.Info("Shutting down main server")
+			streamsDrained := drainStreams(logger, streamDrainer, env.StreamDrainSettleTime)
 			if err := mainServer.Shutdown(context.Background()); err != nil {
 				logger.Errorw("Failed to shutdown proxy server", zap.Error(err))
 			}
+			<-streamsDrained
+			// The user container is only sent SIGTERM once the asynchronous
+			// requests accepted before are run.
+			if async != nil {
+				logger.Info("Draining the asynchronous requests")
+				async.Drain()
+			}
 			// Removing the main server from the shutdown logic as we've already shut it down.
 			delete(servers, "main")
 		})
@@ -263,74 +715,457 @@ func main() {
 	}
 }
 
-func buildProbe(logger *zap.SugaredLogger, env config) *readiness.Probe {
+// buildMTLSServer builds the server serving the activator with mutual TLS, or
+// the activator and the ingress with TLS if no activator SAN is required, by
+// the same handler as the main server.
+func buildMTLSServer(ctx context.Context, logger *zap.SugaredLogger, env config, h http.Handler) *http.Server {
+	certs, err := queue.NewMutualCertificateReloader(
+		filepath.Join(env.ActivatorMTLSCertDir, networking.CertificateFile),
+		filepath.Join(env.ActivatorMTLSCertDir, networking.KeyFile),
+		filepath.Join(env.ActivatorMTLSCertDir, networking.CAFile))
+	if err != nil {
+		logger.Fatalw("Failed to load the mutual TLS certificate", zap.Error(err))
+	}
+	go certs.Run(ctx, certReloadPeriod, logger)
+	tlsConfig := certs.TLSConfig()
+	if env.ActivatorCertificateSAN != "" {
+		tlsConfig = certs.ServerMutualTLSConfig(env.ActivatorCertificateSAN)
+	}
+	return &http.Server{
+		Addr:      ":" + strconv.Itoa(networking.BackendHTTPSPort),
+		Handler:   h,
+		TLSConfig: tlsConfig,
+	}
+}
+
+// setMaxProcs detects the CPU quota of the container from the cgroup
+// filesystem at cgroupRoot and, if enabled, sets GOMAXPROCS to match it. The
+// detected quota is returned.
+func setMaxProcs(logger *zap.SugaredLogger, env config, cgroupRoot string) float64 {
+	cpuQuota, err := queue.ReadCPUQuota(cgroupRoot)
+	if err != nil {
+		logger.Warnw("Failed to read the CPU quota", zap.Error(err))
+	}
+	if _, ok := os.LookupEnv("GOMAXPROCS"); env.AutoGomaxprocs && !ok {
+		queue.SetMaxProcs(cpuQuota)
+	}
+	logger.Infof("CPU quota = %v, GOMAXPROCS = %d", cpuQuota, runtime.GOMAXPROCS(0))
+	return cpuQuota
+}
+
+func buildProbe(logger *zap.SugaredLogger, env config) *readiness.AggregateProbe {
 	coreProbe, err := readiness.DecodeProbe(env.ServingReadinessProbe)
 	if err != nil {
 		logger.Fatalw("Queue container failed to parse readiness probe", zap.Error(err))
 	}
-	if env.EnableHTTP2AutoDetection {
-		return readiness.NewProbeWithHTTP2AutoDetection(coreProbe)
+	var probe *readiness.Probe
+	switch {
+	case env.ServingReadinessProbeGRPC:
+		if coreProbe.TCPSocket == nil {
+			logger.Fatal("Queue container requires a TCP socket readiness probe to probe by gRPC")
+		}
+		probe = readiness.NewGRPCProbe(coreProbe, env.ServingReadinessProbeGRPCService)
+	case env.EnableHTTP2AutoDetection:
+		probe = readiness.NewProbeWithHTTP2AutoDetection(coreProbe)
+	default:
+		probe = readiness.NewProbe(coreProbe)
+	}
+	if env.UserSocket != "" {
+		probe.UseUnixSocket(env.UserSocket)
+	}
+	if env.ServingReadinessProbePassive {
+		probe.UsePassiveChecks()
+	}
+
+	probes := map[string]*readiness.Probe{env.ServingContainerName: probe}
+	if env.ServingSidecarReadinessProbes != "" {
+		sidecarProbes, err := readiness.DecodeProbes(env.ServingSidecarReadinessProbes)
+		if err != nil {
+			logger.Fatalw("Queue container failed to parse the sidecar readiness probes", zap.Error(err))
+		}
+		for name, p := range sidecarProbes {
+			if env.EnableHTTP2AutoDetection {
+				probes[name] = readiness.NewProbeWithHTTP2AutoDetection(p)
+			} else {
+				probes[name] = readiness.NewProbe(p)
+			}
+		}
+	}
+	return readiness.NewAggregateProbe(probes)
+}
+
+// observeRequests reports the outcomes of the requests proxied to the named
+// container to its readiness probe, for passive checks. The requests the
+// clients gave up on don't count.
+func observeRequests(proxy *httputil.ReverseProxy, rp *readiness.AggregateProbe, name string) {
+	errorHandler, modifyResponse := proxy.ErrorHandler, proxy.ModifyResponse
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if r.Context().Err() == nil {
+			rp.ObserveRequest(name, err)
+		}
+		errorHandler(w, r, err)
+	}
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		rp.ObserveRequest(name, nil)
+		return modifyResponse(resp)
 	}
-	return readiness.NewProbe(coreProbe)
 }
 
-func buildServer(ctx context.Context, env config, healthState *health.State, rp *readiness.Probe, stats *network.RequestStats,
-	logger *zap.SugaredLogger) *http.Server {
+func buildServer(ctx context.Context, env config, healthState *health.State, drainer *health.Drainer, streamDrainer *queue.StreamDrainer, drainNotifier *queue.DrainNotifier, async *queue.AsyncRequests, rp *readiness.AggregateProbe,
+	stats *queue.RequestStats, promStatReporter *queue.PrometheusStatsReporter, protoStatReporter *queue.ProtobufStatsReporter, inFlight *queue.InFlightRequests, backends *queue.WeightedBackends,
+	breaker *queue.Breaker, saturation *queue.SaturationHealth, settings *runtimeSettings, logger *zap.SugaredLogger) *http.Server {
 
 	maxIdleConns := 1000 // TODO: somewhat arbitrary value for CC=0, needs experimental validation.
 	if env.ContainerConcurrency > 0 {
 		maxIdleConns = env.ContainerConcurrency
 	}
+	if env.MaxUpstreamConnections > 0 && env.MaxUpstreamConnections < maxIdleConns {
+		// No more connections are kept idle than can be in use.
+		maxIdleConns = env.MaxUpstreamConnections
+	}
 
-	target := net.JoinHostPort("127.0.0.1", env.UserPort)
+	transport := buildTransport(env, logger, maxIdleConns, "" /*socket*/, false /*h2c*/)
+	userTransport := transport
+	if h2c := env.UserProtocol == queue.ProtocolH2C; env.UserSocket != "" || h2c {
+		userTransport = buildTransport(env, logger, maxIdleConns, env.UserSocket, h2c)
+	}
+	// The mirrored requests aren't limited, they don't go to the user container.
+	userTransport = queue.NewUpstreamLimitTransport(userTransport, env.MaxUpstreamConnections, env.MaxResponseBytesPerSecond)
+	proxyTransport := retryTransport(env, promStatReporter.ConnectionTransport(userTransport))
+	bufferPool := network.NewBufferPool()
+	newProxy := func(port string) http.Handler {
+		target := net.JoinHostPort("127.0.0.1", port)
+		httpProxy := pkghttp.NewHeaderPruningReverseProxy(target, pkghttp.NoHostOverride, activator.RevisionHeaders)
+		httpProxy.Transport = proxyTransport
+		httpProxy.ErrorHandler = pkghandler.Error(logger)
+		httpProxy.BufferPool = bufferPool
+		httpProxy.FlushInterval = network.FlushInterval
+		httpProxy.ModifyResponse = queue.PreserveTrailers
+		if env.ServingReadinessProbePassive {
+			observeRequests(httpProxy, rp, env.ServingContainerName)
+		}
+		return httpProxy
+	}
 
-	httpProxy := pkghttp.NewHeaderPruningReverseProxy(target, pkghttp.NoHostOverride, activator.RevisionHeaders)
-	httpProxy.Transport = buildTransport(env, logger, maxIdleConns)
-	httpProxy.ErrorHandler = pkghandler.Error(logger)
-	httpProxy.BufferPool = network.NewBufferPool()
-	httpProxy.FlushInterval = network.FlushInterval
+	httpProxy := newProxy(env.UserPort)
+	if backends != nil {
+		handlers := make(map[string]http.Handler, len(backends.Names()))
+		for _, port := range backends.Names() {
+			handlers[port] = promStatReporter.BackendRequestHandler(port, newProxy(port))
+		}
+		var err error
+		if httpProxy, err = backends.Handler(handlers); err != nil {
+			logger.Fatalw("Failed to create user backends handler", zap.Error(err))
+		}
+	}
 
-	breaker := buildBreaker(logger, env)
+	if breaker != nil {
+		promStatReporter.ObserveBreaker(breaker)
+	}
 	metricsSupported := supportsMetrics(ctx, logger, env)
+	if metricsSupported {
+		settings.metricsBackend = env.ServingRequestMetricsBackend
+	}
 	tracingEnabled := env.TracingConfigBackend != tracingconfig.None
-	concurrencyStateEnabled := env.ConcurrencyStateEndpoint != ""
-	firstByteTimeout := time.Duration(env.RevisionTimeoutSeconds) * time.Second
+	concurrencyStateEnabled := env.ConcurrencyStateEndpoint != "" ||
+		queue.ConcurrencyStateBackend(env.ConcurrencyStateBackend) == queue.ConcurrencyStateBackendFreezer
+	firstByteTimeout := firstByteTimeoutFunc(logger, env, settings.timeout)
 	// hardcoded to always disable idle timeout for now, will expose this later
 	var idleTimeout time.Duration
 
 	// Create queue handler chain.
 	// Note: innermost handlers are specified first, ie. the last handler in the chain will be executed first.
 	var composedHandler http.Handler = httpProxy
+	// Set once a pause or resume hook failed for good.
+	concurrencyStateFailed := atomic.NewBool(false)
+	// Set once the resume hook failed for good, if failing fast.
+	resumeFailed := atomic.NewBool(false)
+	// Set while the user container is paused.
+	paused := atomic.NewBool(false)
 	if concurrencyStateEnabled {
-		logger.Info("Concurrency state endpoint set, tracking request counts")
-		composedHandler = queue.ConcurrencyStateHandler(logger, composedHandler, nil, nil)
+		logger.Infof("Concurrency state backend %s set, tracking request counts", env.ConcurrencyStateBackend)
+		pause, resume := concurrencyStateHooks(logger, env, settings)
+		retry := queue.HookRetryPolicy{
+			Attempts:   env.ConcurrencyStateAttempts,
+			Backoff:    env.ConcurrencyStateBackoff,
+			MaxBackoff: env.ConcurrencyStateMaxBackoff,
+			Timeout:    env.ConcurrencyStateTimeout,
+		}
+		failed := func(error) {
+			logger.Error("Reporting not ready, as the state of the user container is unknown")
+			concurrencyStateFailed.Store(true)
+		}
+		failedResume := failed
+		if env.ConcurrencyStateFailFast {
+			failedResume = func(err error) {
+				failed(err)
+				if resumeFailed.CAS(false, true) {
+					logger.Error("Rejecting requests, as the user container couldn't be resumed")
+					go recordPodWarning(logger, env, queue.ResumeFailedReason, "Failed to resume the user container: "+err.Error())
+				}
+			}
+			composedHandler = queue.ResumeFailFastHandler(resumeFailed, composedHandler)
+		}
+		pause, resume = pausedStateHooks(paused, pause, resume)
+		pause = promStatReporter.ConcurrencyStateHook("pause", pause)
+		resume = promStatReporter.ConcurrencyStateHook("resume", resume)
+		if metricsSupported {
+			pause, resume = concurrencyStateMetricsHooks(logger, env, pause, resume)
+		}
+		composedHandler = queue.ConcurrencyStateHandler(logger, composedHandler,
+			retry.Wrap(logger, "pause", pause, failed),
+			retry.Wrap(logger, "resume", resume, failedResume),
+			env.ConcurrencyStatePauseDelay)
+		if env.ConcurrencyStateProbeInterposition {
+			// Rather than resuming the container for every probe.
+			composedHandler = queue.PausedProbeHandler(paused, composedHandler)
+		}
+		if env.ConcurrencyStateFailFast {
+			composedHandler = queue.ResumeFailFastHandler(resumeFailed, composedHandler)
+		}
 	}
 	if metricsSupported {
 		composedHandler = requestAppMetricsHandler(logger, composedHandler, breaker, env)
 	}
-	composedHandler = queue.ProxyHandler(breaker, stats, tracingEnabled, composedHandler)
+	var circuitBreaker *queue.CircuitBreaker
+	if env.CircuitBreakerFailureRatio > 0 && env.CircuitBreakerWindow > 0 {
+		circuitBreaker = queue.NewCircuitBreaker(queue.CircuitBreakerParams{
+			FailureRatio: env.CircuitBreakerFailureRatio,
+			Window:       env.CircuitBreakerWindow,
+			MinRequests:  env.CircuitBreakerMinRequests,
+			Cooldown:     env.CircuitBreakerCooldown,
+		})
+		composedHandler = circuitBreaker.Handler(composedHandler)
+	}
+	if inFlight != nil {
+		composedHandler = inFlight.RunningHandler(composedHandler)
+	}
+	if breaker != nil && env.AdaptiveConcurrencyTargetLatency > 0 {
+		adaptive := queue.NewAdaptiveConcurrency(breaker, queue.AdaptiveConcurrencyParams{
+			TargetLatency: env.AdaptiveConcurrencyTargetLatency,
+			MinLimit:      1,
+			MaxLimit:      env.ContainerConcurrency,
+		})
+		promStatReporter.ObserveAdaptiveConcurrency(adaptive)
+		go adaptive.Run(ctx)
+		composedHandler = adaptive.Handler(composedHandler)
+	}
+	composedHandler = queue.ProxyHandler(breaker, stats, tracingEnabled, env.EnableTimingHeaders, requestMirror(logger, transport, env), composedHandler)
+	// The Routes mirroring their traffic to a shadow Revision tell by a header.
+	composedHandler = queue.NewShadowMirror(env.ServingNamespace, transport, logger).Handler(composedHandler)
+	if streams := buildStreams(logger, env); streams != nil {
+		promStatReporter.ObserveStreams(streams)
+		composedHandler = streams.Handler(composedHandler)
+	}
+	if streamDrainer != nil {
+		composedHandler = streamDrainer.Handler(composedHandler)
+	}
+	if drainNotifier != nil {
+		composedHandler = drainNotifier.Handler(composedHandler)
+	}
+	if circuitBreaker != nil {
+		// Reject requests while the circuit is open before they're queued.
+		composedHandler = circuitBreaker.AdmissionHandler(composedHandler)
+	}
+	if inFlight != nil {
+		composedHandler = inFlight.TrackHandler(composedHandler)
+	}
+	if breaker != nil {
+		composedHandler = requestPriorityHandler(logger, composedHandler, env)
+		composedHandler = requestWeightHandler(logger, composedHandler, env)
+	}
+	composedHandler = queue.NewRequestSpoolHandler(composedHandler, env.RequestSpoolThreshold, env.RequestSpoolDir, logger)
+	composedHandler = requestBodyLimitHandler(logger, composedHandler, env)
+	composedHandler = rateLimitHandler(logger, composedHandler, env)
+	// Rejecting the requests of clients over their limit first keeps them
+	// from using up the shared rate.
+	composedHandler = clientRateLimitHandler(logger, composedHandler, env)
 	composedHandler = queue.ForwardedShimHandler(composedHandler)
-	composedHandler = handler.NewTimeoutHandler(composedHandler, "request timeout", firstByteTimeout, idleTimeout)
+	composedHandler = forwardedChainHandler(logger, composedHandler, env)
+	composedHandler = handler.NewTimeoutFuncHandler(composedHandler, "request timeout", firstByteTimeout, idleTimeout)
+	// The asynchronous requests are run through the timeout handler, the 202
+	// they're answered with right away isn't.
+	if async != nil {
+		go async.Run(composedHandler, env.AsyncRequestsWorkers)
+		composedHandler = async.Handler(composedHandler)
+	}
+	// The preflight requests of the CORS policy of the Route or the
+	// DomainMapping are answered without going through the queue.
+	composedHandler = pkghttp.NewCORSHandler(composedHandler)
+	composedHandler = queue.ResponseHeadersHandler(buildHeaderRules(logger, env), composedHandler)
+	// Count the requests outside of the timeout handler, so timeouts are
+	// counted as 5xx.
+	composedHandler = promStatReporter.RequestMethodHandler(composedHandler)
+	composedHandler = protoStatReporter.ErrorHandler(composedHandler)
+	composedHandler = promStatReporter.ResponseTimingHandler(composedHandler)
 
 	if metricsSupported {
 		composedHandler = requestMetricsHandler(logger, composedHandler, env)
+		if env.InternalEncryption {
+			composedHandler = plaintextMetricsHandler(logger, composedHandler, env)
+		}
 	}
+	// Log the requests inside of the tracing middleware and the request ID
+	// handler, so the entries carry the trace ID of the request's span and
+	// the request ID.
+	composedHandler = accessLogHandler(logger, composedHandler, env)
+	if env.ServingOTLPLogsEndpoint != "" {
+		exporter := requestEventExporter(logger, env)
+		go exporter.Run(ctx, env.ServingOTLPLogsExportInterval)
+		composedHandler = exporter.Handler(composedHandler)
+	}
+	composedHandler = requestIDHandler(logger, composedHandler, env)
 	if tracingEnabled {
-		composedHandler = tracing.HTTPSpanMiddleware(composedHandler)
+		composedHandler = pkghttp.NewTracingHandler(composedHandler, env.TracingConfigPropagation.HTTPFormat(), nil /*sampler*/)
 	}
 
-	composedHandler = health.ProbeHandler(healthState, rp.ProbeContainer, tracingEnabled, composedHandler)
+	prober := readinessProber(logger, env, userTransport, saturation, rp.ProbeContainer)
+	if concurrencyStateEnabled {
+		containerProber := prober
+		prober = func() bool {
+			// A paused user container can't answer its probes, but it was
+			// ready when paused and is resumed by the next request, so the
+			// pod stays ready, e.g. while it's kept warm at scale zero.
+			return !concurrencyStateFailed.Load() && (paused.Load() || containerProber())
+		}
+	}
+	composedHandler = drainer.Handler(composedHandler)
+	composedHandler = health.ProbeHandler(healthState, prober, tracingEnabled, composedHandler)
 	composedHandler = network.NewProbeHandler(composedHandler)
 	// We might want sometimes capture the probes/healthchecks in the request
 	// logs. Hence we need to have RequestLogHandler to be the first one.
 	composedHandler = pushRequestLogHandler(logger, composedHandler, env)
 
-	return pkgnet.NewServer(":"+env.QueueServingPort, composedHandler)
+	server := pkgnet.NewServer(":"+env.QueueServingPort, composedHandler)
+	if env.StreamDrainGoAway {
+		// The HTTP/2 server of pkgnet.NewServer isn't told about the shutdown
+		// of the server, so it doesn't send its connections a GOAWAY frame.
+		h2s := &http2.Server{}
+		server.Handler = h2c.NewHandler(composedHandler, h2s)
+		if err := http2.ConfigureServer(server, h2s); err != nil {
+			logger.Errorw("Failed to configure HTTP/2 connections to be sent a GOAWAY frame.", zap.Error(err))
+		}
+	}
+	if t, ok := userTransport.(interface{ CloseIdleConnections() }); ok {
+		// Let go of the connections to the user container once the server
+		// drains, so it isn't kept busy with them while shutting down.
+		server.RegisterOnShutdown(t.CloseIdleConnections)
+	}
+	return server
 }
 
-func buildTransport(env config, logger *zap.SugaredLogger, maxConns int) http.RoundTripper {
+// firstByteTimeoutFunc returns the time to first byte timeout of requests,
+// the revision timeout, which is reloaded, unless overridden by the timeout
+// rules.
+func firstByteTimeoutFunc(logger *zap.SugaredLogger, env config, timeout *atomic.Duration) handler.TimeoutFunc {
+	rules, err := queue.ParseTimeoutRules(env.TimeoutRules)
+	if err != nil {
+		logger.Fatalw("Queue container failed to parse timeout rules", zap.Error(err))
+	}
+	return func(r *http.Request) time.Duration {
+		return rules.Timeout(r, timeout.Load())
+	}
+}
+
+func buildHeaderRules(logger *zap.SugaredLogger, env config) *queue.HeaderRules {
+	rules, err := queue.ParseHeaderRules(env.ResponseHeaderRules)
+	if err != nil {
+		logger.Fatalw("Queue container failed to parse response header rules", zap.Error(err))
+	}
+	return rules
+}
+
+func requestPrioritiesEnabled(env config) bool {
+	return env.RequestPriorityHeader != "" || env.RequestPriorityRules != ""
+}
+
+func requestPriorityHandler(logger *zap.SugaredLogger, currentHandler http.Handler, env config) http.Handler {
+	if !requestPrioritiesEnabled(env) {
+		return currentHandler
+	}
+	p, err := queue.NewPrioritizer(env.RequestPriorityHeader, env.RequestPriorityRules)
+	if err != nil {
+		logger.Fatalw("Queue container failed to parse request priority rules", zap.Error(err))
+	}
+	return p.Handler(currentHandler)
+}
+
+func requestWeightsEnabled(env config) bool {
+	return env.RequestWeightHeader != "" || env.RequestWeightRules != ""
+}
+
+func requestWeightHandler(logger *zap.SugaredLogger, currentHandler http.Handler, env config) http.Handler {
+	if !requestWeightsEnabled(env) {
+		return currentHandler
+	}
+	w, err := queue.NewWeigher(env.RequestWeightHeader, env.RequestWeightRules)
+	if err != nil {
+		logger.Fatalw("Queue container failed to parse request weight rules", zap.Error(err))
+	}
+	return w.Handler(currentHandler)
+}
+
+func retryTransport(env config, transport http.RoundTripper) http.RoundTripper {
+	if env.RetryAttempts <= 1 {
+		return transport
+	}
+	policy := queue.RetryPolicy{
+		Attempts:      env.RetryAttempts,
+		Methods:       sets.NewString(env.RetryMethods...),
+		StatusCodes:   sets.NewInt(env.RetryStatusCodes...),
+		PerTryTimeout: env.RetryPerTryTimeout,
+	}
+	if env.RetryBudget > 0 {
+		policy.Budget = queue.NewRetryBudget(env.RetryBudget, clock.RealClock{})
+	}
+	return queue.NewRetryTransport(transport, policy)
+}
+
+func buildAsyncRequests(logger *zap.SugaredLogger, env config, stats *queue.RequestStats) *queue.AsyncRequests {
+	if env.AsyncRequestsDir == "" {
+		return nil
+	}
+	if env.AsyncRequestsWorkers < 1 {
+		logger.Fatalw("Queue container requires at least one asynchronous request worker", zap.Int("workers", env.AsyncRequestsWorkers))
+	}
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		// A callback on an allowed host mustn't redirect to any other.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	async, err := queue.NewAsyncRequests(env.AsyncRequestsDir, client, env.AsyncCallbackAllowedHosts, stats, logger)
+	if err != nil {
+		logger.Fatalw("Queue container failed to set up the asynchronous requests", zap.Error(err))
+	}
+	return async
+}
+
+func requestMirror(logger *zap.SugaredLogger, transport http.RoundTripper, env config) *queue.RequestMirror {
+	if env.MirrorTarget == "" {
+		return nil
+	}
+	target, err := url.Parse(env.MirrorTarget)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		logger.Fatalw("Queue container failed to parse the mirror target", zap.String("target", env.MirrorTarget), zap.Error(err))
+	}
+	return queue.NewRequestMirror(target, env.MirrorHeader, env.MirrorHeaderValue, transport, logger)
+}
+
+// buildTransport builds the transport to the user container, connecting to
+// the Unix domain socket at socket if set and speaking h2c only if h2c is set.
+func buildTransport(env config, logger *zap.SugaredLogger, maxConns int, socket string, h2c bool) http.RoundTripper {
 	// set max-idle and max-idle-per-host to same value since we're always proxying to the same host.
 	transport := pkgnet.NewProxyAutoTransport(maxConns /* max-idle */, maxConns /* max-idle-per-host */)
+	switch {
+	case h2c && socket != "":
+		transport = queue.NewH2CTransport(queue.UnixSocketDialer(socket))
+	case h2c:
+		transport = queue.NewH2CTransport(nil /*dial*/)
+	case socket != "":
+		transport = queue.NewUnixSocketTransport(socket, maxConns)
+	}
 
 	if env.TracingConfigBackend == tracingconfig.None {
 		return transport
@@ -346,7 +1181,7 @@ func buildTransport(env config, logger *zap.SugaredLogger, maxConns int) http.Ro
 
 	return &ochttp.Transport{
 		Base:        transport,
-		Propagation: tracecontextb3.TraceContextB3Egress,
+		Propagation: env.TracingConfigPropagation.HTTPFormat(),
 	}
 }
 
@@ -363,6 +1198,13 @@ func buildBreaker(logger *zap.SugaredLogger, env config) *queue.Breaker {
 		MaxConcurrency:  env.ContainerConcurrency,
 		InitialCapacity: env.ContainerConcurrency,
 	}
+	if requestPrioritiesEnabled(env) {
+		params.Priorities = queue.RequestPriorities
+		params.StarvationLimit = env.RequestPriorityStarvationLimit
+	}
+	if requestWeightsEnabled(env) {
+		params.MaxRequestWeight = env.ContainerConcurrency
+	}
 	logger.Infof("Queue container is starting with BreakerParams = %#v", params)
 	return queue.NewBreaker(params)
 }
@@ -381,13 +1223,42 @@ func supportsMetrics(ctx context.Context, logger *zap.SugaredLogger, env config)
 	return true
 }
 
-func buildAdminServer(logger *zap.SugaredLogger, healthState *health.State) *http.Server {
+func buildAdminServer(logger *zap.SugaredLogger, healthState *health.State, promStatReporter *queue.PrometheusStatsReporter,
+	inFlight *queue.InFlightRequests, breaker *queue.Breaker, saturation *queue.SaturationHealth,
+	probe *readiness.AggregateProbe, cpuQuota float64) *http.Server {
 	adminMux := http.NewServeMux()
 	drainHandler := healthState.DrainHandlerFunc()
 	adminMux.HandleFunc(queue.RequestQueueDrainPath, func(w http.ResponseWriter, r *http.Request) {
 		logger.Info("Attached drain handler from user-container")
 		drainHandler(w, r)
 	})
+	adminMux.HandleFunc(queue.WaitForStartupPath, func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, queue.WaitForStartupPath)
+		logger.Info("Waiting for the startup of container ", name)
+		if err := probe.WaitReady(r.Context(), name); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
+	})
+	adminMux.HandleFunc(queue.WaitForExitPath, func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, queue.WaitForExitPath)
+		drainHandler(w, r)
+		logger.Info("Waiting for the exit of container ", name)
+		if err := probe.WaitGone(r.Context(), name); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
+	})
+	if inFlight != nil {
+		adminMux.Handle(queue.RequestsDebugPath, inFlight)
+	}
+	if breaker != nil {
+		adminMux.Handle(queue.QueueStatePath, queue.NewQueueStateHandler(breaker))
+	}
+	if saturation != nil {
+		adminMux.Handle(queue.SaturationHealthPath, saturation)
+	}
+	adminMux.Handle(queue.ReadinessDebugPath, probe)
+	adminMux.Handle(queue.InfoPath, queue.NewInfoHandler(cpuQuota))
+	adminMux.HandleFunc(queue.StatsPath, promStatReporter.ServeJSON)
 
 	return &http.Server{
 		Addr:    ":" + strconv.Itoa(networking.QueueAdminPort),
@@ -395,6 +1266,18 @@ func buildAdminServer(logger *zap.SugaredLogger, healthState *health.State) *htt
 	}
 }
 
+func buildLocalAdminServer(drainer *health.Drainer, backends *queue.WeightedBackends) *http.Server {
+	localAdminMux := http.NewServeMux()
+	localAdminMux.Handle(queue.DrainPath, drainer)
+	if backends != nil {
+		localAdminMux.Handle(queue.BackendWeightsPath, backends)
+	}
+	return &http.Server{
+		Addr:    net.JoinHostPort("127.0.0.1", strconv.Itoa(networking.QueueLocalAdminPort)),
+		Handler: localAdminMux,
+	}
+}
+
 func buildMetricsServer(promStatReporter *queue.PrometheusStatsReporter, protobufStatReporter *queue.ProtobufStatsReporter) *http.Server {
 	metricsMux := http.NewServeMux()
 	metricsMux.Handle("/metrics", queue.NewStatsHandler(promStatReporter, protobufStatReporter))
@@ -426,6 +1309,193 @@ func pushRequestLogHandler(logger *zap.SugaredLogger, currentHandler http.Handle
 	return handler
 }
 
+func otlpExporter(logger *zap.SugaredLogger, promStatReporter *queue.PrometheusStatsReporter, env config) *queue.OTLPExporter {
+	protocol, err := queue.ParseOTLPProtocol(env.ServingOTLPProtocol)
+	if err != nil {
+		logger.Fatalw("Queue container failed to parse the OTLP protocol", zap.Error(err))
+	}
+	if env.ServingOTLPExportInterval <= 0 {
+		logger.Fatalw("Queue container requires a positive OTLP export interval", zap.Duration("interval", env.ServingOTLPExportInterval))
+	}
+	resource := queue.OTLPResource(env.ServingNamespace, env.ServingService, env.ServingConfiguration,
+		env.ServingRevision, env.ServingPod)
+	exporter, err := promStatReporter.NewOTLPExporter(env.ServingOTLPEndpoint, protocol, resource, logger)
+	if err != nil {
+		logger.Fatalw("Failed to create the OTLP metrics exporter", zap.Error(err))
+	}
+	return exporter
+}
+
+func requestEventExporter(logger *zap.SugaredLogger, env config) *queue.RequestEventExporter {
+	protocol, err := queue.ParseOTLPProtocol(env.ServingOTLPLogsProtocol)
+	if err != nil {
+		logger.Fatalw("Queue container failed to parse the OTLP logs protocol", zap.Error(err))
+	}
+	if env.ServingOTLPLogsExportInterval <= 0 {
+		logger.Fatalw("Queue container requires a positive OTLP logs export interval", zap.Duration("interval", env.ServingOTLPLogsExportInterval))
+	}
+	if env.ServingOTLPLogsMaxEventsPerSecond <= 0 {
+		logger.Fatalw("Queue container requires a positive rate of request events", zap.Float64("rate", env.ServingOTLPLogsMaxEventsPerSecond))
+	}
+	resource := queue.OTLPResource(env.ServingNamespace, env.ServingService, env.ServingConfiguration,
+		env.ServingRevision, env.ServingPod)
+	exporter, err := queue.NewRequestEventExporter(env.ServingOTLPLogsEndpoint, protocol, resource,
+		env.ServingOTLPLogsMaxEventsPerSecond, logger)
+	if err != nil {
+		logger.Fatalw("Failed to create the OTLP request event exporter", zap.Error(err))
+	}
+	return exporter
+}
+
+func accessLogHandler(logger *zap.SugaredLogger, currentHandler http.Handler, env config) http.Handler {
+	format, err := queue.ParseAccessLogFormat(env.ServingAccessLogFormat)
+	if err != nil {
+		logger.Fatalw("Queue container failed to parse the access log format", zap.Error(err))
+	}
+	if format == queue.AccessLogFormatNone {
+		return currentHandler
+	}
+
+	var template *queue.AccessLogTemplate
+	if format == queue.AccessLogFormatTemplate {
+		if template, err = queue.ParseAccessLogTemplate(env.ServingAccessLogTemplate); err != nil {
+			logger.Fatalw("Queue container failed to parse the access log template", zap.Error(err))
+		}
+	}
+	handler, err := queue.NewAccessLogHandler(currentHandler, format, template, env.ServingAccessLogDestination,
+		&pkghttp.RequestLogRevision{
+			Name:          env.ServingRevision,
+			Namespace:     env.ServingNamespace,
+			Service:       env.ServingService,
+			Configuration: env.ServingConfiguration,
+			PodName:       env.ServingPod,
+			PodIP:         env.ServingPodIP,
+		})
+	if err != nil {
+		logger.Fatalw("Queue container failed to set up the access log", zap.Error(err))
+	}
+	return handler
+}
+
+func requestIDHandler(logger *zap.SugaredLogger, currentHandler http.Handler, env config) http.Handler {
+	generator, err := pkghttp.ParseRequestIDGenerator(env.RequestIDGenerator)
+	if err != nil {
+		logger.Fatalw("Queue container failed to parse the request ID generator", zap.Error(err))
+	}
+	return pkghttp.NewRequestIDHandler(currentHandler, env.RequestIDHeader, generator)
+}
+
+func rateLimitHandler(logger *zap.SugaredLogger, currentHandler http.Handler, env config) http.Handler {
+	h, err := queue.NewRateLimitHandler(currentHandler, env.RequestRateLimit, env.RequestRateBurst,
+		env.ServingNamespace, env.ServingService, env.ServingConfiguration, env.ServingRevision, env.ServingPod)
+	if err != nil {
+		logger.Errorw("Error setting up request rate limiter. Requests will not be rate limited.", zap.Error(err))
+		return currentHandler
+	}
+	return h
+}
+
+func clientRateLimitHandler(logger *zap.SugaredLogger, currentHandler http.Handler, env config) http.Handler {
+	key, err := queue.ParseClientRateLimitKey(env.ClientRateLimitKey)
+	if err != nil {
+		logger.Fatalw("Queue container failed to parse the client rate limit key", zap.Error(err))
+	}
+	h, err := queue.NewClientRateLimitHandler(currentHandler, env.ClientRequestRateLimit, env.ClientRequestRateBurst, key,
+		env.ServingNamespace, env.ServingService, env.ServingConfiguration, env.ServingRevision, env.ServingPod)
+	if err != nil {
+		logger.Errorw("Error setting up client request rate limiter. Requests will not be rate limited per client.", zap.Error(err))
+		return currentHandler
+	}
+	return h
+}
+
+func buildStreams(logger *zap.SugaredLogger, env config) *queue.Streams {
+	mode, err := queue.ParseStreamMode(env.StreamMode)
+	if err != nil {
+		logger.Fatalw("Queue container failed to parse the stream mode", zap.Error(err))
+	}
+	if mode == queue.StreamModeNone {
+		return nil
+	}
+	return queue.NewStreams(mode)
+}
+
+func buildStreamDrainer(logger *zap.SugaredLogger, env config) *queue.StreamDrainer {
+	if env.StreamDrainSettleTime <= 0 {
+		return nil
+	}
+	d, err := queue.NewStreamDrainer(env.ServingNamespace, env.ServingService, env.ServingConfiguration, env.ServingRevision, env.ServingPod)
+	if err != nil {
+		logger.Errorw("Error setting up the stream drainer. Streams will be cut on shutdown.", zap.Error(err))
+		return nil
+	}
+	return d
+}
+
+func buildDrainNotifier(logger *zap.SugaredLogger, env config) *queue.DrainNotifier {
+	if env.DrainSignal == "" {
+		return nil
+	}
+	signal, err := queue.ParseDrainSignal(env.DrainSignal)
+	if err != nil {
+		logger.Fatalw("Queue container failed to parse the drain signal", zap.Error(err))
+	}
+	// A single call is made, through a transport of its own.
+	transport := buildTransport(env, logger, 1, env.UserSocket, env.UserProtocol == queue.ProtocolH2C)
+	target := "http://" + net.JoinHostPort("127.0.0.1", env.UserPort)
+	n, err := queue.NewDrainNotifier(signal, target, transport,
+		env.ServingNamespace, env.ServingService, env.ServingConfiguration, env.ServingRevision, env.ServingPod)
+	if err != nil {
+		logger.Errorw("Error setting up the drain signal. The user container will not be told of the drain.", zap.Error(err))
+		return nil
+	}
+	return n
+}
+
+// notifyDrain tells the user container the queue-proxy drains.
+func notifyDrain(logger *zap.SugaredLogger, n *queue.DrainNotifier) {
+	if err := n.Notify(); err != nil {
+		logger.Errorw("Failed to notify the user container of the drain", zap.Error(err))
+		return
+	}
+	logger.Info("Notified the user container of the drain")
+}
+
+// drainStreams drains the streams of d, if any, returning a channel closed
+// once they're drained.
+func drainStreams(logger *zap.SugaredLogger, d *queue.StreamDrainer, settle time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	if d == nil {
+		close(done)
+		return done
+	}
+	go func() {
+		defer close(done)
+		if closed := d.Drain(settle); closed > 0 {
+			logger.Infof("Closed %d streams that didn't finish within %v", closed, settle)
+		}
+	}()
+	return done
+}
+
+func requestBodyLimitHandler(logger *zap.SugaredLogger, currentHandler http.Handler, env config) http.Handler {
+	h, err := queue.NewRequestBodyLimitHandler(currentHandler, env.MaxRequestBodyBytes,
+		env.ServingNamespace, env.ServingService, env.ServingConfiguration, env.ServingRevision, env.ServingPod)
+	if err != nil {
+		logger.Errorw("Error setting up the request body limit. Request bodies will not be limited.", zap.Error(err))
+		return currentHandler
+	}
+	return h
+}
+
+func forwardedChainHandler(logger *zap.SugaredLogger, currentHandler http.Handler, env config) http.Handler {
+	h, err := queue.NewForwardedChainHandler(currentHandler, queue.ForwardedPolicy(env.ForwardedPolicy), env.ForwardedTrustedHops)
+	if err != nil {
+		logger.Fatalw("Queue container failed to set up the forwarded policy", zap.Error(err))
+	}
+	return h
+}
+
 func requestMetricsHandler(logger *zap.SugaredLogger, currentHandler http.Handler, env config) http.Handler {
 	h, err := queue.NewRequestMetricsHandler(currentHandler, env.ServingNamespace,
 		env.ServingService, env.ServingConfiguration, env.ServingRevision, env.ServingPod)
@@ -436,6 +1506,16 @@ func requestMetricsHandler(logger *zap.SugaredLogger, currentHandler http.Handle
 	return h
 }
 
+func plaintextMetricsHandler(logger *zap.SugaredLogger, currentHandler http.Handler, env config) http.Handler {
+	h, err := queue.NewPlaintextMetricsHandler(currentHandler, env.ServingNamespace,
+		env.ServingService, env.ServingConfiguration, env.ServingRevision, env.ServingPod)
+	if err != nil {
+		logger.Errorw("Error setting up plain text request metrics reporter. Plain text fallbacks will be unavailable.", zap.Error(err))
+		return currentHandler
+	}
+	return h
+}
+
 func requestAppMetricsHandler(logger *zap.SugaredLogger, currentHandler http.Handler, breaker *queue.Breaker, env config) http.Handler {
 	h, err := queue.NewAppRequestMetricsHandler(currentHandler, breaker, env.ServingNamespace,
 		env.ServingService, env.ServingConfiguration, env.ServingRevision, env.ServingPod)
@@ -446,6 +1526,117 @@ func requestAppMetricsHandler(logger *zap.SugaredLogger, currentHandler http.Han
 	return h
 }
 
+func concurrencyStateHooks(logger *zap.SugaredLogger, env config, settings *runtimeSettings) (func(context.Context) error, func(context.Context) error) {
+	switch queue.ConcurrencyStateBackend(env.ConcurrencyStateBackend) {
+	case queue.ConcurrencyStateBackendEndpoint:
+		endpoint := queue.NewConcurrencyEndpoint(env.ConcurrencyStateEndpoint, env.ConcurrencyStateTokenPath,
+			queue.ConcurrencyEndpointTLS{
+				CertPath: env.ConcurrencyStateCertPath,
+				KeyPath:  env.ConcurrencyStateKeyPath,
+				CAPath:   env.ConcurrencyStateCAPath,
+			})
+		settings.concurrencyEndpoint = endpoint
+		return endpoint.Pause, endpoint.Resume
+	case queue.ConcurrencyStateBackendFreezer:
+		freezer, err := queue.NewCgroupFreezer(env.ConcurrencyStateCgroupPath)
+		if err != nil {
+			logger.Fatalw("Queue container failed to set up the cgroup freezer", zap.Error(err))
+		}
+		return freezer.Pause, freezer.Resume
+	default:
+		logger.Fatalf("Unknown concurrency state backend %q", env.ConcurrencyStateBackend)
+		return nil, nil
+	}
+}
+
+// recordPodWarning records a warning event about the pod, e.g. as its user
+// container couldn't be resumed.
+func recordPodWarning(logger *zap.SugaredLogger, env config, reason, message string) {
+	events, err := queue.NewInClusterPodEvents(env.ServingNamespace, env.ServingPod, types.UID(env.ServingPodUID))
+	if err != nil {
+		logger.Errorw("Failed to set up recording events about the pod", zap.Error(err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := events.Warning(ctx, reason, message); err != nil {
+		logger.Errorw("Failed to record an event about the pod", zap.String("reason", reason), zap.Error(err))
+	}
+}
+
+// readinessProber wraps the prober of the user container to also wait for
+// the warm-up call and, if set up to, report a saturated pod as not ready.
+func readinessProber(logger *zap.SugaredLogger, env config, transport http.RoundTripper, saturation *queue.SaturationHealth, prober func() bool) func() bool {
+	if env.WarmUp != "" {
+		prober = warmUpProber(logger, env, transport, prober)
+	}
+	if saturation != nil && env.SaturationAffectsReadiness {
+		containerProber := prober
+		prober = func() bool {
+			return saturation.Healthy() && containerProber()
+		}
+	}
+	return prober
+}
+
+// warmUpProber wraps the prober of the user container to make the warm-up
+// call once it's ready, and only be ready once the call succeeded. The
+// calls are recorded as metrics, and the failed ones as events.
+func warmUpProber(logger *zap.SugaredLogger, env config, transport http.RoundTripper, prober func() bool) func() bool {
+	hook, err := queue.ParseWarmUpHook(env.WarmUp)
+	if err != nil {
+		logger.Fatalw("Queue container failed to parse the warm-up hook", zap.Error(err))
+	}
+	m, err := queue.NewWarmUpMetrics(env.ServingNamespace,
+		env.ServingService, env.ServingConfiguration, env.ServingRevision, env.ServingPod)
+	if err != nil {
+		logger.Errorw("Error setting up warm-up metrics reporter. Warm-up metrics will be unavailable.", zap.Error(err))
+	}
+	report := func(latency time.Duration, err error) {
+		if m != nil {
+			m.Record(latency, err)
+		}
+		if err != nil {
+			logger.Errorw("Warm-up of the user container failed", zap.Error(err))
+			recordPodWarning(logger, env, queue.WarmUpFailedReason, err.Error())
+			return
+		}
+		logger.Infof("Warmed up the user container in %v", latency)
+	}
+	target := "http://" + net.JoinHostPort("127.0.0.1", env.UserPort)
+	return queue.NewWarmUp(hook, target, transport, report).Prober(prober)
+}
+
+// pausedStateHooks wraps the pause and resume hooks to set paused while the
+// user container is paused.
+func pausedStateHooks(paused *atomic.Bool, pause, resume func(context.Context) error) (func(context.Context) error, func(context.Context) error) {
+	pausing := func(ctx context.Context) error {
+		if err := pause(ctx); err != nil {
+			return err
+		}
+		paused.Store(true)
+		return nil
+	}
+	resuming := func(ctx context.Context) error {
+		if err := resume(ctx); err != nil {
+			return err
+		}
+		paused.Store(false)
+		return nil
+	}
+	return pausing, resuming
+}
+
+func concurrencyStateMetricsHooks(logger *zap.SugaredLogger, env config, pause, resume func(context.Context) error) (func(context.Context) error, func(context.Context) error) {
+	m, err := queue.NewConcurrencyStateMetrics(env.ServingNamespace,
+		env.ServingService, env.ServingConfiguration, env.ServingRevision, env.ServingPod)
+	if err != nil {
+		logger.Errorw("Error setting up concurrency state metrics reporter. Concurrency state metrics will be unavailable.", zap.Error(err))
+		return pause, resume
+	}
+	return m.Hook("pause", pause), m.Hook("resume", resume)
+}
+
 func setupMetricsExporter(ctx context.Context, logger *zap.SugaredLogger, backend string, collectorAddress string) error {
 	// Set up OpenCensus exporter.
 	// NOTE: We use revision as the component instead of queue because queue is
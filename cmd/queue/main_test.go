@@ -17,16 +17,28 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"go.opencensus.io/plugin/ochttp"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	network "knative.dev/networking/pkg"
+	logtesting "knative.dev/pkg/logging/testing"
 	pkgnet "knative.dev/pkg/network"
 	"knative.dev/pkg/tracing"
 	tracingconfig "knative.dev/pkg/tracing/config"
@@ -49,7 +61,7 @@ func TestQueueTraceSpans(t *testing.T) {
 	}{{
 		name:          "proxy trace",
 		prober:        func() bool { return true },
-		wantSpans:     3,
+		wantSpans:     4,
 		requestHeader: "",
 		probeWillFail: false,
 		probeTrace:    false,
@@ -57,7 +69,7 @@ func TestQueueTraceSpans(t *testing.T) {
 	}, {
 		name:          "proxy trace, no breaker",
 		prober:        func() bool { return true },
-		wantSpans:     2,
+		wantSpans:     3,
 		requestHeader: "",
 		probeWillFail: false,
 		probeTrace:    false,
@@ -146,7 +158,7 @@ func TestQueueTraceSpans(t *testing.T) {
 					Propagation: tracecontextb3.TraceContextB3Egress,
 				}
 
-				h := queue.ProxyHandler(breaker, network.NewRequestStats(time.Now()), true /*tracingEnabled*/, proxy)
+				h := queue.ProxyHandler(breaker, queue.NewRequestStats(time.Now()), true /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, proxy)
 				h(writer, req)
 			} else {
 				h := health.ProbeHandler(healthState, tc.prober, true /*tracingEnabled*/, nil)
@@ -158,7 +170,7 @@ func TestQueueTraceSpans(t *testing.T) {
 			if len(gotSpans) != tc.wantSpans {
 				t.Errorf("Got %d spans, expected %d", len(gotSpans), tc.wantSpans)
 			}
-			spanNames := []string{"probe", "/", "queue_proxy"}
+			spanNames := []string{"probe", "/", "queue_backend", "queue_proxy"}
 			if !tc.probeTrace {
 				spanNames = spanNames[1:]
 			}
@@ -188,3 +200,195 @@ func TestQueueTraceSpans(t *testing.T) {
 		})
 	}
 }
+
+func TestPausedStateHooks(t *testing.T) {
+	ctx := context.Background()
+	var hookErr error
+	hook := func(context.Context) error { return hookErr }
+	paused := atomic.NewBool(false)
+	pause, resume := pausedStateHooks(paused, hook, hook)
+
+	hookErr = errors.New("failed")
+	if err := pause(ctx); err == nil || paused.Load() {
+		t.Errorf("Failed pause = %v, paused = %v, want an error and not paused", err, paused.Load())
+	}
+	hookErr = nil
+	if err := pause(ctx); err != nil || !paused.Load() {
+		t.Errorf("Pause = %v, paused = %v, want paused", err, paused.Load())
+	}
+	hookErr = errors.New("failed")
+	if err := resume(ctx); err == nil || !paused.Load() {
+		t.Errorf("Failed resume = %v, paused = %v, want an error and still paused", err, paused.Load())
+	}
+	hookErr = nil
+	if err := resume(ctx); err != nil || paused.Load() {
+		t.Errorf("Resume = %v, paused = %v, want resumed", err, paused.Load())
+	}
+}
+
+func TestReadinessProberWarmUpAndSaturation(t *testing.T) {
+	warmedUp := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-warmedUp
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	breaker := queue.NewBreaker(queue.BreakerParams{QueueDepth: 1, MaxConcurrency: 1}) // Breaker capacity = 2, no slots.
+	env := config{
+		UserPort:                   u.Port(),
+		WarmUp:                     `{"path": "/warm-up"}`,
+		SaturationAffectsReadiness: true,
+	}
+	prober := readinessProber(logtesting.TestLogger(t), env, http.DefaultTransport,
+		queue.NewSaturationHealth(breaker, 0), func() bool { return true })
+
+	// The pod isn't ready before the warm-up call succeeded, even though its
+	// breaker isn't saturated.
+	if prober() {
+		t.Error("Ready before the warm-up call succeeded")
+	}
+	close(warmedUp)
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return prober(), nil
+	}); err != nil {
+		t.Error("Not ready after the warm-up call succeeded")
+	}
+
+	// A saturated breaker still makes it not ready.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			breaker.Maybe(ctx, func() {})
+			done <- struct{}{}
+		}()
+	}
+	if err := wait.PollImmediate(time.Millisecond, 5*time.Second, func() (bool, error) {
+		return breaker.InFlight() == 2, nil
+	}); err != nil {
+		t.Fatalf("InFlight() = %d, want: 2", breaker.InFlight())
+	}
+	if prober() {
+		t.Error("Ready while saturated")
+	}
+	cancel()
+	<-done
+	<-done
+	if !prober() {
+		t.Error("Not ready once the queue has room")
+	}
+}
+
+func TestSetMaxProcs(t *testing.T) {
+	if _, ok := os.LookupEnv("GOMAXPROCS"); ok {
+		t.Skip("GOMAXPROCS is set explicitly")
+	}
+	prev := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(prev)
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "cpu.max"), []byte("250000 100000\n"), 0o644); err != nil {
+		t.Fatal("WriteFile() =", err)
+	}
+
+	runtime.GOMAXPROCS(7)
+	if got, want := setMaxProcs(logtesting.TestLogger(t), config{}, root), 2.5; got != want {
+		t.Errorf("CPU quota = %v, want: %v", got, want)
+	}
+	if got, want := runtime.GOMAXPROCS(0), 7; got != want {
+		t.Errorf("GOMAXPROCS without auto-set = %d, want: %d", got, want)
+	}
+
+	if got, want := setMaxProcs(logtesting.TestLogger(t), config{AutoGomaxprocs: true}, root), 2.5; got != want {
+		t.Errorf("CPU quota = %v, want: %v", got, want)
+	}
+	if got, want := runtime.GOMAXPROCS(0), 2; got != want {
+		t.Errorf("GOMAXPROCS with auto-set = %d, want: %d", got, want)
+	}
+}
+
+func TestRuntimeSettings(t *testing.T) {
+	env := config{RevisionTimeoutSeconds: 300}
+	settings := newRuntimeSettings(env, zap.NewAtomicLevelAt(zapcore.InfoLevel))
+	logger := logtesting.TestLogger(t)
+
+	settings.apply(context.Background(), logger, env, &queue.RuntimeConfig{
+		LogLevel:     "debug",
+		Timeout:      10 * time.Second,
+		DrainTimeout: 5 * time.Second,
+	})
+	if got, want := settings.logLevel.Level(), zapcore.DebugLevel; got != want {
+		t.Errorf("Log level = %v, want: %v", got, want)
+	}
+	if got, want := settings.timeout.Load(), 10*time.Second; got != want {
+		t.Errorf("Timeout = %v, want: %v", got, want)
+	}
+	if got, want := settings.drainTimeout.Load(), 5*time.Second; got != want {
+		t.Errorf("Drain timeout = %v, want: %v", got, want)
+	}
+
+	// The settings removed from the ConfigMap revert to the environment.
+	settings.apply(context.Background(), logger, env, &queue.RuntimeConfig{})
+	if got, want := settings.logLevel.Level(), zapcore.InfoLevel; got != want {
+		t.Errorf("Log level = %v, want: %v", got, want)
+	}
+	if got, want := settings.timeout.Load(), 300*time.Second; got != want {
+		t.Errorf("Timeout = %v, want: %v", got, want)
+	}
+	if got, want := settings.drainTimeout.Load(), drainSleepDuration; got != want {
+		t.Errorf("Drain timeout = %v, want: %v", got, want)
+	}
+}
+
+func TestBackendWeightsLocalOnly(t *testing.T) {
+	backends, err := queue.NewWeightedBackends([]queue.BackendWeight{{Name: "8080", Weight: 90}, {Name: "8081", Weight: 10}})
+	if err != nil {
+		t.Fatal("NewWeightedBackends() =", err)
+	}
+
+	healthState := health.NewState()
+	admin := buildAdminServer(logtesting.TestLogger(t), healthState, nil, nil, nil, nil, nil, 1)
+	rec := httptest.NewRecorder()
+	admin.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "http://localhost"+queue.BackendWeightsPath, strings.NewReader(`{"8080": 0, "8081": 100}`)))
+	if got, want := rec.Code, http.StatusNotFound; got != want {
+		t.Errorf("PUT on the admin port = %d, want: %d", got, want)
+	}
+
+	local := buildLocalAdminServer(health.NewDrainer(healthState), backends)
+	if host, _, _ := net.SplitHostPort(local.Addr); !net.ParseIP(host).IsLoopback() {
+		t.Errorf("Local admin server address = %s, want a loopback address", local.Addr)
+	}
+	rec = httptest.NewRecorder()
+	local.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "http://localhost"+queue.BackendWeightsPath, strings.NewReader(`{"8080": 0, "8081": 100}`)))
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("PUT on the local admin port = %d, want: %d", got, want)
+	}
+	if got := backends.Weights(); got["8081"] != 100 {
+		t.Errorf("Weights = %v, want the weights updated", got)
+	}
+}
+
+func TestDrainLocalOnly(t *testing.T) {
+	healthState := health.NewState()
+	drainer := health.NewDrainer(healthState)
+	admin := buildAdminServer(logtesting.TestLogger(t), healthState, nil, nil, nil, nil, nil, 1)
+	rec := httptest.NewRecorder()
+	admin.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://localhost"+queue.DrainPath, nil))
+	if got, want := rec.Code, http.StatusNotFound; got != want {
+		t.Errorf("POST on the admin port = %d, want: %d", got, want)
+	}
+	if drainer.Draining() {
+		t.Error("Draining after a POST on the admin port")
+	}
+
+	local := buildLocalAdminServer(drainer, nil)
+	rec = httptest.NewRecorder()
+	local.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://localhost"+queue.DrainPath, nil))
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("POST on the local admin port = %d, want: %d", got, want)
+	}
+	if !drainer.Draining() {
+		t.Error("Not draining after a POST on the local admin port")
+	}
+}
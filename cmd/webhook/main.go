@@ -20,6 +20,8 @@ import (
 	"context"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	filteredconfigmapinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/configmap/filtered"
+	filteredinformerfactory "knative.dev/pkg/client/injection/kube/informers/factory/filtered"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/injection/sharedmain"
@@ -80,6 +82,10 @@ func newDefaultingAdmissionController(ctx context.Context, cmw configmap.Watcher
 	store := apisconfig.NewStore(logging.FromContext(ctx).Named("config-store"))
 	store.WatchConfigs(cmw)
 
+	// Decorate them with the defaults of the namespaces too.
+	namespaceDefaults := extravalidation.NamespaceDefaults(
+		filteredconfigmapinformer.Get(ctx, apisconfig.NamespaceDefaultsLabelKey).Lister())
+
 	return defaulting.NewAdmissionController(ctx,
 
 		// Name of the resource webhook.
@@ -92,7 +98,9 @@ func newDefaultingAdmissionController(ctx context.Context, cmw configmap.Watcher
 		types,
 
 		// A function that infuses the context passed to Validate/SetDefaults with custom metadata.
-		store.ToContext,
+		func(ctx context.Context) context.Context {
+			return apisconfig.WithNamespaceDefaults(store.ToContext(ctx), namespaceDefaults)
+		},
 
 		// Whether to disallow unknown fields.
 		true,
@@ -104,7 +112,13 @@ func newValidationAdmissionController(ctx context.Context, cmw configmap.Watcher
 	store := apisconfig.NewStore(logging.FromContext(ctx).Named("config-store"))
 	store.WatchConfigs(cmw)
 
-	return validation.NewAdmissionController(ctx,
+	// Validate against the defaults of the namespaces too, e.g. their max
+	// revision timeouts.
+	namespaceDefaults := extravalidation.NamespaceDefaults(
+		filteredconfigmapinformer.Get(ctx, apisconfig.NamespaceDefaultsLabelKey).Lister())
+
+	// Answer the allowed requests with the advice on their resources too.
+	return extravalidation.WithWarnings(validation.NewAdmissionController(ctx,
 
 		// Name of the resource webhook.
 		"validation.webhook.serving.knative.dev",
@@ -116,14 +130,16 @@ func newValidationAdmissionController(ctx context.Context, cmw configmap.Watcher
 		types,
 
 		// A function that infuses the context passed to Validate/SetDefaults with custom metadata.
-		store.ToContext,
+		func(ctx context.Context) context.Context {
+			return apisconfig.WithNamespaceDefaults(store.ToContext(ctx), namespaceDefaults)
+		},
 
 		// Whether to disallow unknown fields.
 		true,
 
 		// Extra validating callbacks to be applied to resources.
 		callbacks,
-	)
+	))
 }
 
 func newConfigValidationController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
@@ -159,6 +175,8 @@ func main() {
 		Port:        webhook.PortFromEnv(8443),
 		SecretName:  "webhook-certs",
 	})
+	// Watch the namespace defaults ConfigMaps of all the namespaces.
+	ctx = filteredinformerfactory.WithSelectors(ctx, apisconfig.NamespaceDefaultsLabelKey)
 
 	sharedmain.WebhookMainWithContext(ctx, "webhook",
 		certificates.NewController,
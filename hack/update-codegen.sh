@@ -44,7 +44,7 @@ if (( generate_protobufs )); then
   protos=$(find "${REPO_ROOT_DIR}/pkg" "${REPO_ROOT_DIR}/test" -name '*.proto')
   for proto in $protos
   do
-    protoc "$proto" -I="${REPO_ROOT_DIR}" --gogofaster_out=plugins=grpc:.
+    protoc "$proto" -I="${REPO_ROOT_DIR}" --gogofaster_out=plugins=grpc,Mgoogle/protobuf/empty.proto=github.com/gogo/protobuf/types:.
 
     # Add license headers to the generated files too.
     dir=$(dirname "$proto")
@@ -23,13 +23,23 @@ const (
 	RevisionHeaderName = "Knative-Serving-Revision"
 	// RevisionHeaderNamespace is the header key for revision's namespace.
 	RevisionHeaderNamespace = "Knative-Serving-Namespace"
+	// RouteHeaderName is the header key for the name of the route the request
+	// was routed by.
+	RouteHeaderName = "Knative-Serving-Route"
+	// ColdStartTimeoutHeaderName is the header set on the response to a
+	// request that timed out waiting for its revision to scale from zero.
+	ColdStartTimeoutHeaderName = "K-Cold-Start-Timeout"
+	// ColdStartBreakdownHeaderName is the header summarizing the phases of
+	// the cold start on the response to a cold-started request, if enabled.
+	ColdStartBreakdownHeaderName = "K-Cold-Start-Breakdown"
 )
 
 var (
 	// RevisionHeaders are the headers the activator uses to identify the
-	// revision. They are removed before reaching the user container.
+	// revision and route. They are removed before reaching the user container.
 	RevisionHeaders = []string{
 		RevisionHeaderName,
 		RevisionHeaderNamespace,
+		RouteHeaderName,
 	}
 )
@@ -20,15 +20,22 @@ import (
 	"context"
 
 	"go.uber.org/atomic"
+	corev1 "k8s.io/api/core/v1"
+	network "knative.dev/networking/pkg"
 	"knative.dev/pkg/configmap"
 	tracingconfig "knative.dev/pkg/tracing/config"
+	pkghttp "knative.dev/serving/pkg/http"
+	"knative.dev/serving/pkg/networking"
 )
 
 type cfgKey struct{}
 
 // Config is the configuration for the activator.
 type Config struct {
-	Tracing *tracingconfig.Config
+	Tracing          *tracingconfig.Config
+	TracePropagation *pkghttp.TracePropagationConfig
+	RequestID        *pkghttp.RequestIDConfig
+	InternalTLS      *networking.InternalTLSConfig
 }
 
 // FromContext obtains a Config injected into the passed context.
@@ -40,6 +47,13 @@ func FromContext(ctx context.Context) *Config {
 type Store struct {
 	*configmap.UntypedStore
 
+	// internalTLSStore parses config-network a second time, for the
+	// settings of the TLS between the activator and the queue-proxies.
+	internalTLSStore *configmap.UntypedStore
+	// tracePropagationStore parses config-tracing a second time, for the
+	// formats the trace context is passed on in.
+	tracePropagationStore *configmap.UntypedStore
+
 	// current is the current Config.
 	current atomic.Value
 }
@@ -51,21 +65,69 @@ func NewStore(logger configmap.Logger, onAfterStore ...func(name string, value i
 	// Append an update function to run after a ConfigMap has updated to update the
 	// current state of the Config.
 	onAfterStore = append(onAfterStore, func(_ string, _ interface{}) {
-		s.current.Store(&Config{
-			Tracing: s.UntypedLoad(tracingconfig.ConfigName).(*tracingconfig.Config).DeepCopy(),
-		})
+		cfg := &Config{}
+		// The ConfigMaps are stored one at a time, so either may be missing.
+		if tr, ok := s.UntypedLoad(tracingconfig.ConfigName).(*tracingconfig.Config); ok {
+			cfg.Tracing = tr.DeepCopy()
+		}
+		if rid, ok := s.UntypedLoad(network.ConfigName).(*pkghttp.RequestIDConfig); ok {
+			cfg.RequestID = rid.DeepCopy()
+		}
+		if itls, ok := s.internalTLSStore.UntypedLoad(network.ConfigName).(*networking.InternalTLSConfig); ok {
+			cfg.InternalTLS = itls.DeepCopy()
+		}
+		if tp, ok := s.tracePropagationStore.UntypedLoad(tracingconfig.ConfigName).(*pkghttp.TracePropagationConfig); ok {
+			cfg.TracePropagation = tp.DeepCopy()
+		}
+		s.current.Store(cfg)
 	})
 	s.UntypedStore = configmap.NewUntypedStore(
 		"activator",
 		logger,
 		configmap.Constructors{
 			tracingconfig.ConfigName: tracingconfig.NewTracingConfigFromConfigMap,
+			network.ConfigName:       pkghttp.NewRequestIDConfigFromConfigMap,
+		},
+		onAfterStore...,
+	)
+	s.internalTLSStore = configmap.NewUntypedStore(
+		"activator-internal-tls",
+		logger,
+		configmap.Constructors{
+			network.ConfigName: networking.NewInternalTLSConfigFromConfigMap,
+		},
+		onAfterStore...,
+	)
+	s.tracePropagationStore = configmap.NewUntypedStore(
+		"activator-trace-propagation",
+		logger,
+		configmap.Constructors{
+			tracingconfig.ConfigName: pkghttp.NewTracePropagationConfigFromConfigMap,
 		},
 		onAfterStore...,
 	)
 	return s
 }
 
+// WatchConfigs uses the provided configmap.Watcher to setup watches for the
+// ConfigMaps of the Config.
+func (s *Store) WatchConfigs(w configmap.Watcher) {
+	s.UntypedStore.WatchConfigs(w)
+	s.internalTLSStore.WatchConfigs(w)
+	s.tracePropagationStore.WatchConfigs(w)
+}
+
+// OnConfigChanged stores the configuration of the passed ConfigMap.
+func (s *Store) OnConfigChanged(c *corev1.ConfigMap) {
+	s.UntypedStore.OnConfigChanged(c)
+	if c.Name == network.ConfigName {
+		s.internalTLSStore.OnConfigChanged(c)
+	}
+	if c.Name == tracingconfig.ConfigName {
+		s.tracePropagationStore.OnConfigChanged(c)
+	}
+}
+
 // ToContext stores the configuration Store in the passed context.
 func (s *Store) ToContext(ctx context.Context) context.Context {
 	return context.WithValue(ctx, cfgKey{}, s.current.Load())
@@ -22,8 +22,11 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	network "knative.dev/networking/pkg"
 	ltesting "knative.dev/pkg/logging/testing"
 	tracingconfig "knative.dev/pkg/tracing/config"
+	pkghttp "knative.dev/serving/pkg/http"
+	"knative.dev/serving/pkg/networking"
 )
 
 var tracingConfig = &corev1.ConfigMap{
@@ -46,14 +49,18 @@ func TestStore(t *testing.T) {
 	if got, want := cfg.Tracing.Backend, tracingconfig.None; got != want {
 		t.Fatalf("Tracing.Backend = %v, want %v", got, want)
 	}
+	if got, want := cfg.TracePropagation.Propagation, pkghttp.TracePropagationTraceContextB3; got != want {
+		t.Fatalf("TracePropagation.Propagation = %v, want %v", got, want)
+	}
 
 	newConfig := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: tracingconfig.ConfigName,
 		},
 		Data: map[string]string{
-			"backend":         "zipkin",
-			"zipkin-endpoint": "foo.bar",
+			"backend":                   "zipkin",
+			"zipkin-endpoint":           "foo.bar",
+			pkghttp.TracePropagationKey: "b3",
 		},
 	}
 	store.OnConfigChanged(newConfig)
@@ -64,6 +71,53 @@ func TestStore(t *testing.T) {
 	if got, want := cfg.Tracing.Backend, tracingconfig.Zipkin; got != want {
 		t.Fatalf("Tracing.Backend = %v, want %v", got, want)
 	}
+	if got, want := cfg.TracePropagation.Propagation, pkghttp.TracePropagationB3; got != want {
+		t.Fatalf("TracePropagation.Propagation = %v, want %v", got, want)
+	}
+	if cfg.RequestID != nil {
+		t.Fatalf("RequestID = %v, want nil without config-network", cfg.RequestID)
+	}
+
+	store.OnConfigChanged(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: network.ConfigName,
+		},
+		Data: map[string]string{
+			pkghttp.RequestIDHeaderKey: "X-Correlation-Id",
+		},
+	})
+
+	ctx = store.ToContext(context.Background())
+	cfg = FromContext(ctx)
+
+	if got, want := cfg.RequestID.Header, "X-Correlation-Id"; got != want {
+		t.Fatalf("RequestID.Header = %q, want %q", got, want)
+	}
+	if got, want := cfg.Tracing.Backend, tracingconfig.Zipkin; got != want {
+		t.Fatalf("Tracing.Backend = %v, want %v", got, want)
+	}
+	if cfg.InternalTLS.ActivatorMTLS {
+		t.Fatal("InternalTLS.ActivatorMTLS = true, want false by default")
+	}
+
+	store.OnConfigChanged(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: network.ConfigName,
+		},
+		Data: map[string]string{
+			networking.ActivatorMTLSKey: "true",
+		},
+	})
+
+	ctx = store.ToContext(context.Background())
+	cfg = FromContext(ctx)
+
+	if !cfg.InternalTLS.ActivatorMTLS {
+		t.Fatal("InternalTLS.ActivatorMTLS = false, want true")
+	}
+	if got, want := cfg.RequestID.Header, "X-Request-Id"; got != want {
+		t.Fatalf("RequestID.Header = %q, want the default %q", got, want)
+	}
 }
 
 func BenchmarkStoreToContext(b *testing.B) {
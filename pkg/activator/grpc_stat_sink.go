@@ -0,0 +1,251 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"knative.dev/serving/pkg/autoscaler/metrics"
+)
+
+const (
+	// grpcStatKeepaliveTime is how often the connection to the autoscaler is
+	// pinged, matching the least time the autoscaler permits between pings.
+	grpcStatKeepaliveTime = 30 * time.Second
+	// grpcStatKeepaliveTimeout is how long a ping may go unanswered before
+	// the connection is re-established.
+	grpcStatKeepaliveTimeout = 10 * time.Second
+	// grpcStatRetryInterval is the time between attempts to open a stream.
+	grpcStatRetryInterval = time.Second
+)
+
+var errStatStreamClosed = errors.New("the stat stream isn't established")
+
+// GRPCStatSink sends stats to the autoscaler over a gRPC stream. The stats
+// the autoscaler didn't acknowledge yet are sent again once the stream is
+// re-established, so that none are dropped while reconnecting, unless more
+// than maxPending messages pile up.
+type GRPCStatSink struct {
+	conn       *grpc.ClientConn
+	client     metrics.StatServiceClient
+	maxPending int
+	logger     *zap.SugaredLogger
+	done       chan struct{}
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// pending are the messages the autoscaler didn't acknowledge yet, oldest
+	// first. The first sent of them were sent on the current stream.
+	pending []*metrics.WireStatMessages
+	sent    int
+	status  error
+	closed  bool
+}
+
+// NewGRPCStatSink creates a GRPCStatSink sending stats to the autoscaler at
+// target, which is connected to lazily.
+func NewGRPCStatSink(target string, maxPending int, logger *zap.SugaredLogger) (*GRPCStatSink, error) {
+	conn, err := grpc.Dial(target,
+		grpc.WithInsecure(),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                grpcStatKeepaliveTime,
+			Timeout:             grpcStatKeepaliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	if err != nil {
+		return nil, err
+	}
+	s := &GRPCStatSink{
+		conn:       conn,
+		client:     metrics.NewStatServiceClient(conn),
+		maxPending: maxPending,
+		logger:     logger.With(zap.String("target", target)),
+		done:       make(chan struct{}),
+		status:     errStatStreamClosed,
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s, nil
+}
+
+// Run sends the messages received on source to the autoscaler until source
+// is closed or Shutdown is called. source is always drained right away, so
+// that the senders aren't held back by the autoscaler.
+func (s *GRPCStatSink) Run(source <-chan []metrics.StatMessage) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for sms := range source {
+			s.push(metrics.ToWireStatMessages(sms))
+		}
+		s.Shutdown()
+	}()
+	go func() {
+		<-s.done
+		cancel()
+	}()
+
+	for {
+		// stream only returns nil once the sink was closed.
+		err := s.stream(ctx)
+		s.setStatus(err)
+		if err == nil {
+			return
+		}
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		s.logger.Errorw("Stat stream failed, retrying", zap.Error(err))
+		select {
+		case <-time.After(grpcStatRetryInterval):
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// stream sends the pending messages over a new stream until it breaks, or
+// returns nil once the sink is closed.
+func (s *GRPCStatSink) stream(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := s.client.Report(ctx, grpc.WaitForReady(true))
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	// The messages of the former stream weren't acknowledged, so they're
+	// sent again.
+	s.sent = 0
+	s.status = nil
+	s.mu.Unlock()
+
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			if _, err := stream.Recv(); err != nil {
+				recvErr <- err
+				s.mu.Lock()
+				s.cond.Broadcast()
+				s.mu.Unlock()
+				return
+			}
+			s.ack()
+		}
+	}()
+	defer func() {
+		// Acknowledgements must not race with the next stream.
+		cancel()
+		<-recvErr
+	}()
+
+	for {
+		wsms, ok := s.next(ctx, recvErr)
+		if !ok {
+			select {
+			case err := <-recvErr:
+				recvErr <- err
+				return err
+			default:
+				return nil
+			}
+		}
+		if err := stream.Send(wsms); err != nil {
+			return err
+		}
+	}
+}
+
+// push adds a message to the pending ones. If there are too many, the oldest
+// one that wasn't sent yet is dropped.
+func (s *GRPCStatSink) push(wsms metrics.WireStatMessages) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) >= s.maxPending {
+		if s.sent == len(s.pending) {
+			s.logger.Warn("Dropping stats, as too many weren't acknowledged")
+			return
+		}
+		s.logger.Warn("Dropping the oldest stats, as too many weren't acknowledged")
+		s.pending = append(s.pending[:s.sent], s.pending[s.sent+1:]...)
+	}
+	s.pending = append(s.pending, &wsms)
+	s.cond.Broadcast()
+}
+
+// next waits for a message to send on the current stream, and returns false
+// if the sink was closed or the stream broke.
+func (s *GRPCStatSink) next(ctx context.Context, recvErr <-chan error) (*metrics.WireStatMessages, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.sent == len(s.pending) && !s.closed && ctx.Err() == nil && len(recvErr) == 0 {
+		s.cond.Wait()
+	}
+	if s.closed || ctx.Err() != nil || len(recvErr) > 0 {
+		return nil, false
+	}
+	s.sent++
+	return s.pending[s.sent-1], true
+}
+
+// ack drops the oldest pending message, which the autoscaler acknowledged.
+func (s *GRPCStatSink) ack() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sent > 0 {
+		s.pending[0] = nil
+		s.pending = s.pending[1:]
+		s.sent--
+	}
+}
+
+func (s *GRPCStatSink) setStatus(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		err = errStatStreamClosed
+	}
+	s.status = err
+}
+
+// Status returns nil while the stream to the autoscaler is established, or
+// why it isn't otherwise.
+func (s *GRPCStatSink) Status() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Shutdown stops sending stats and closes the connection to the autoscaler.
+func (s *GRPCStatSink) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.cond.Broadcast()
+	close(s.done)
+	s.conn.Close()
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	gogotypes "github.com/gogo/protobuf/types"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"k8s.io/apimachinery/pkg/types"
+	logtesting "knative.dev/pkg/logging/testing"
+	"knative.dev/serving/pkg/autoscaler/metrics"
+)
+
+// flakyStatServer fails its first stream after receiving a message, without
+// acknowledging it, like an autoscaler restarting.
+type flakyStatServer struct {
+	received chan string
+	failed   bool
+}
+
+func (s *flakyStatServer) Report(stream metrics.StatService_ReportServer) error {
+	for {
+		wsms, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		for _, wsm := range wsms.Messages {
+			s.received <- wsm.Name
+		}
+		if !s.failed {
+			s.failed = true
+			return errors.New("restarting")
+		}
+		if err := stream.Send(&gogotypes.Empty{}); err != nil {
+			return err
+		}
+	}
+}
+
+func TestGRPCStatSinkResendsUnacknowledged(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	server := grpc.NewServer()
+	fake := &flakyStatServer{received: make(chan string, 10)}
+	metrics.RegisterStatServiceServer(server, fake)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	sink, err := NewGRPCStatSink(listener.Addr().String(), 10, logtesting.TestLogger(t))
+	if err != nil {
+		t.Fatal("NewGRPCStatSink() =", err)
+	}
+	source := make(chan []metrics.StatMessage)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sink.Run(source)
+	}()
+
+	send := func(name string) {
+		source <- []metrics.StatMessage{{
+			Key:  types.NamespacedName{Namespace: "ns", Name: name},
+			Stat: metrics.Stat{PodName: "activator", RequestCount: 1},
+		}}
+	}
+	send("first")
+	send("second")
+
+	// The first stream fails on the first message, which is sent again on
+	// the next stream, followed by the second.
+	var got []string
+	for len(got) < 3 {
+		select {
+		case name := <-fake.received:
+			got = append(got, name)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Got %v, want the stats to be resent", got)
+		}
+	}
+	if want := []string{"first", "first", "second"}; !cmp.Equal(got, want) {
+		t.Errorf("Received %v, want: %v", got, want)
+	}
+	if err := sink.Status(); err != nil {
+		t.Error("Status() =", err)
+	}
+
+	close(source)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() didn't return once the source was closed")
+	}
+	if err := sink.Status(); err == nil {
+		t.Error("Status() = nil, want an error once shut down")
+	}
+}
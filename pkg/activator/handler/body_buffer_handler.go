@@ -0,0 +1,348 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+
+	pkgmetrics "knative.dev/pkg/metrics"
+	"knative.dev/serving/pkg/activator"
+	"knative.dev/serving/pkg/apis/serving"
+	"knative.dev/serving/pkg/metrics"
+)
+
+// bodyBufferChunkSize is the maximum size of a single read from the client.
+const bodyBufferChunkSize = 32 * 1024
+
+// NewBodyBufferHandler creates a handler that reads the request body into a
+// buffer of up to maxBytes while next is running, most notably while the
+// request waits for a pod to be ready during a scale from zero. That way a
+// client slowly streaming its body has made progress by the time the request
+// is proxied. Once the buffer is full, reading from the client stops until
+// the proxy catches up.
+// Revisions may bound the buffer with
+// serving.ActivatorRequestBufferMaxBytesAnnotation. If that's beyond maxBytes
+// and spill isn't nil, the rest of the body spills to spill.
+// The requests of revisions with serving.ActivatorRequestBufferMaxAgeAnnotation
+// wait for a pod at most that long while their body is buffered.
+// If maxBytes is 0 or less and spill is nil, next is returned as is, which
+// streams the body only once a pod is ready.
+func NewBodyBufferHandler(next http.Handler, maxBytes int, spill *SpillBuffer) http.Handler {
+	if maxBytes <= 0 && spill == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		memBytes, totalBytes, maxAge := maxBytes, int64(maxBytes), time.Duration(0)
+		rev := RevisionFrom(r.Context())
+		if rev != nil {
+			// The annotations are validated by the webhook.
+			if v, ok := rev.Annotations[serving.ActivatorRequestBufferMaxBytesAnnotation]; ok {
+				totalBytes, _ = strconv.ParseInt(v, 10, 64)
+				if totalBytes < int64(memBytes) {
+					memBytes = int(totalBytes)
+				}
+			}
+			if v, ok := rev.Annotations[serving.ActivatorRequestBufferMaxAgeAnnotation]; ok {
+				maxAge, _ = time.ParseDuration(v)
+			}
+		}
+		reqSpill := spill
+		if totalBytes <= int64(memBytes) {
+			reqSpill = nil
+		}
+		if memBytes <= 0 && reqSpill == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var statsCtx context.Context
+		if reqSpill != nil {
+			var err error
+			statsCtx, err = metrics.PodRevisionContext(reqSpill.podName, activator.Name,
+				rev.Namespace, rev.Labels[serving.ServiceLabelKey], rev.Labels[serving.ConfigurationLabelKey], rev.Name)
+			if err != nil {
+				statsCtx = reqSpill.statsCtx
+			}
+		}
+		body := newBufferedBody(r.Body, memBytes, totalBytes, reqSpill, statsCtx)
+		// The proxy must not read the request body after the handler
+		// returned. A read from the client in progress, which a stalled
+		// client may hold up for good, isn't waited for, its bytes are
+		// dropped once it returns.
+		defer body.Close()
+		r.Body = body
+		if maxAge > 0 {
+			r = r.WithContext(withBufferDeadline(r.Context(), time.Now().Add(maxAge)))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SpillBuffer is a bounded on-disk buffer the request bodies beyond the
+// memory buffer spill to, shared by all requests.
+type SpillBuffer struct {
+	dir     string
+	podName string
+	// maxBytes bounds the bytes that are spilled to dir at a time.
+	maxBytes int64
+	used     atomic.Int64
+	statsCtx context.Context
+}
+
+// NewSpillBuffer creates a SpillBuffer spilling up to maxBytes to files in
+// dir, which is created if it doesn't exist. The metrics of the buffer are
+// recorded for podName.
+func NewSpillBuffer(dir string, maxBytes int64, podName string) (*SpillBuffer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	statsCtx, err := metrics.PodContext(podName, activator.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &SpillBuffer{
+		dir:      dir,
+		podName:  podName,
+		maxBytes: maxBytes,
+		statsCtx: statsCtx,
+	}, nil
+}
+
+// reserve reserves n bytes of the buffer, or returns false if they don't fit.
+func (s *SpillBuffer) reserve(n int64) bool {
+	for {
+		used := s.used.Load()
+		if used+n > s.maxBytes {
+			return false
+		}
+		if s.used.CAS(used, used+n) {
+			pkgmetrics.Record(s.statsCtx, spillUsedBytesM.M(used+n))
+			return true
+		}
+	}
+}
+
+// release releases n reserved bytes.
+func (s *SpillBuffer) release(n int64) {
+	if n > 0 {
+		pkgmetrics.Record(s.statsCtx, spillUsedBytesM.M(s.used.Sub(n)))
+	}
+}
+
+// bufferedBody is an io.ReadCloser reading from a buffer that's concurrently
+// filled from src. The first memBytes are buffered in memory, the rest of up
+// to maxBytes spill to a file, if spill isn't nil.
+type bufferedBody struct {
+	src      io.ReadCloser
+	memBytes int
+	maxBytes int64
+	spill    *SpillBuffer
+	statsCtx context.Context
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  bytes.Buffer
+	// file holds the spilled bytes, which follow those of buf. The bytes
+	// from fileOff to fileEnd are yet to be read. All up to fileEnd are
+	// reserved of spill, until the file is reused.
+	file             *os.File
+	fileOff, fileEnd int64
+	spilled          bool
+	err              error
+	closed           bool
+}
+
+func newBufferedBody(src io.ReadCloser, memBytes int, maxBytes int64, spill *SpillBuffer, statsCtx context.Context) *bufferedBody {
+	b := &bufferedBody{
+		src:      src,
+		memBytes: memBytes,
+		maxBytes: maxBytes,
+		spill:    spill,
+		statsCtx: statsCtx,
+	}
+	b.cond = sync.NewCond(&b.mu)
+	go b.fill()
+	return b
+}
+
+// fill reads from src into the buffer until src is exhausted or the body is
+// closed. The body may be closed while it's reading from src, in which case
+// the bytes read are dropped.
+func (b *bufferedBody) fill() {
+	chunkSize := bodyBufferChunkSize
+	if b.spill == nil && b.memBytes < chunkSize {
+		chunkSize = b.memBytes
+	}
+	chunk := make([]byte, chunkSize)
+	for {
+		b.mu.Lock()
+		toFile, ok := b.waitForRoom(int64(chunkSize))
+		b.mu.Unlock()
+		if !ok {
+			return
+		}
+
+		n, err := b.src.Read(chunk)
+
+		b.mu.Lock()
+		if toFile {
+			b.spill.release(int64(chunkSize - n))
+			if werr := b.writeFile(chunk[:n]); werr != nil && err == nil {
+				err = werr
+			}
+		} else if !b.closed {
+			b.buf.Write(chunk[:n])
+		}
+		b.err = err
+		closed := b.closed
+		b.cond.Broadcast()
+		b.mu.Unlock()
+		if err != nil || closed {
+			return
+		}
+	}
+}
+
+// waitForRoom waits until there's room for a chunk of up to n bytes, and
+// returns whether it's to be spilled, or false if the body was closed. If the
+// chunk is to be spilled, it was reserved of the spill buffer.
+// It must be called with the lock held.
+func (b *bufferedBody) waitForRoom(n int64) (toFile, ok bool) {
+	full := false
+	for !b.closed {
+		// The bytes must be read in order, so nothing goes to memory while
+		// there are spilled bytes left.
+		if b.fileOff == b.fileEnd && b.buf.Len() < b.memBytes {
+			return false, true
+		}
+		if b.spill != nil && int64(b.buf.Len())+b.fileEnd-b.fileOff < b.maxBytes {
+			if b.spill.reserve(n) {
+				return true, true
+			}
+			if !full {
+				full = true
+				pkgmetrics.Record(b.statsCtx, spillFullCountM.M(1))
+			}
+		}
+		// Woken up once the proxy read from the buffer.
+		b.cond.Wait()
+	}
+	return false, false
+}
+
+// writeFile spills p. It must be called with the lock held.
+func (b *bufferedBody) writeFile(p []byte) error {
+	if b.closed {
+		b.spill.release(int64(len(p)))
+		return nil
+	}
+	if b.file == nil {
+		f, err := os.CreateTemp(b.spill.dir, "body-")
+		if err != nil {
+			b.spill.release(int64(len(p)))
+			return err
+		}
+		b.file = f
+	}
+	n, err := b.file.WriteAt(p, b.fileEnd)
+	b.fileEnd += int64(n)
+	b.spill.release(int64(len(p) - n))
+	if n > 0 {
+		if !b.spilled {
+			b.spilled = true
+			pkgmetrics.Record(b.statsCtx, spillCountM.M(1))
+		}
+		pkgmetrics.Record(b.statsCtx, spillBytesM.M(int64(n)))
+	}
+	return err
+}
+
+// Read implements io.Reader.
+func (b *bufferedBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.buf.Len() == 0 && b.fileOff == b.fileEnd && b.err == nil && !b.closed {
+		b.cond.Wait()
+	}
+	switch {
+	case b.closed:
+		return 0, http.ErrBodyReadAfterClose
+	case b.buf.Len() > 0:
+		n, _ := b.buf.Read(p)
+		// Wake up fill if it waits for room in the buffer.
+		b.cond.Broadcast()
+		return n, nil
+	case b.fileOff < b.fileEnd:
+		if left := b.fileEnd - b.fileOff; int64(len(p)) > left {
+			p = p[:left]
+		}
+		n, err := b.file.ReadAt(p, b.fileOff)
+		if n < len(p) {
+			if err == nil || errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+		b.fileOff += int64(n)
+		if b.fileOff == b.fileEnd {
+			// All spilled bytes were read, so the file is reused from its
+			// start.
+			b.file.Truncate(0)
+			b.spill.release(b.fileEnd)
+			b.fileOff, b.fileEnd = 0, 0
+		}
+		b.cond.Broadcast()
+		return n, nil
+	default:
+		return 0, b.err
+	}
+}
+
+// Close implements io.Closer. It stops reading from the client, but leaves
+// closing src to the server.
+func (b *bufferedBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.buf.Reset()
+	if b.file != nil {
+		b.spill.release(b.fileEnd)
+		b.fileOff, b.fileEnd = 0, 0
+		b.file.Close()
+		os.Remove(b.file.Name())
+	}
+	b.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opencensus.io/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/metrics/metricstest"
+	pkgnet "knative.dev/pkg/network"
+	rtesting "knative.dev/pkg/reconciler/testing"
+	"knative.dev/serving/pkg/activator"
+	"knative.dev/serving/pkg/apis/serving"
+	"knative.dev/serving/pkg/metrics"
+)
+
+// coldStartThrottler holds requests until ready is closed, like a revision
+// scaling from zero.
+type coldStartThrottler struct {
+	ready chan struct{}
+}
+
+func (t coldStartThrottler) Try(ctx context.Context, _ types.NamespacedName, f func(string) error) error {
+	select {
+	case <-t.ready:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return f("10.10.10.10:1234")
+}
+
+func TestBodyBufferHandlerColdStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int
+		// wantBuffered is whether the slow client's first write is consumed
+		// while the request waits for a pod.
+		wantBuffered bool
+	}{{
+		name:     "stream once ready",
+		maxBytes: 0,
+	}, {
+		name:         "buffer while waiting",
+		maxBytes:     1024,
+		wantBuffered: true,
+	}, {
+		name:     "buffer exceeds bound",
+		maxBytes: 4,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+			defer cancel()
+
+			gotBody := make(chan string, 1)
+			rt := pkgnet.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					return nil, err
+				}
+				gotBody <- string(b)
+				return httptest.NewRecorder().Result(), nil
+			})
+			throttler := coldStartThrottler{ready: make(chan struct{})}
+			handler := NewBodyBufferHandler(New(ctx, throttler, rt, nil /*mtlsTransport*/, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx)), test.maxBytes, nil /*spill*/)
+
+			body, bodyWriter := io.Pipe()
+			req := httptest.NewRequest(http.MethodPost, "http://example.com", body)
+			configStore := setupConfigStore(t, logging.FromContext(ctx))
+			reqCtx := configStore.ToContext(req.Context())
+			reqCtx = WithRevisionAndID(reqCtx, nil, types.NamespacedName{Namespace: testNamespace, Name: testRevName})
+
+			resp := httptest.NewRecorder()
+			served := make(chan struct{})
+			go func() {
+				defer close(served)
+				handler.ServeHTTP(resp, req.WithContext(reqCtx))
+			}()
+
+			// The client slowly streams its body while the revision is cold.
+			written := make(chan struct{})
+			go func() {
+				defer close(written)
+				bodyWriter.Write([]byte("hello"))
+			}()
+			select {
+			case <-written:
+				if !test.wantBuffered {
+					t.Error("Body was read before a pod was ready")
+				}
+			case <-time.After(100 * time.Millisecond):
+				if test.wantBuffered {
+					t.Error("Body wasn't read while waiting for a pod")
+				}
+			}
+
+			close(throttler.ready)
+			<-written
+			bodyWriter.Write([]byte(" world"))
+			bodyWriter.Close()
+			<-served
+
+			if got, want := resp.Code, http.StatusOK; got != want {
+				t.Errorf("Code = %d, want: %d", got, want)
+			}
+			if got, want := <-gotBody, "hello world"; got != want {
+				t.Errorf("Body = %q, want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestBodyBufferHandlerThrottlerTimeout(t *testing.T) {
+	ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+	defer cancel()
+
+	throttler := coldStartThrottler{ready: make(chan struct{})}
+	handler := NewBodyBufferHandler(New(ctx, throttler, nil /*transport*/, nil /*mtlsTransport*/, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx)), 1024, nil /*spill*/)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com", strings.NewReader(wantBody))
+	configStore := setupConfigStore(t, logging.FromContext(ctx))
+	reqCtx, reqCancel := context.WithTimeout(configStore.ToContext(req.Context()), 50*time.Millisecond)
+	defer reqCancel()
+	reqCtx = WithRevisionAndID(reqCtx, nil, types.NamespacedName{Namespace: testNamespace, Name: testRevName})
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req.WithContext(reqCtx))
+	if got, want := resp.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+}
+
+// stalledBody is a request body whose client never sends anything, its reads
+// block until unblock is closed.
+type stalledBody struct {
+	unblock chan struct{}
+}
+
+func (b stalledBody) Read([]byte) (int, error) {
+	<-b.unblock
+	return 0, io.ErrUnexpectedEOF
+}
+
+func (b stalledBody) Close() error {
+	return nil
+}
+
+func TestBodyBufferHandlerStalledClient(t *testing.T) {
+	ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+	defer cancel()
+
+	throttler := coldStartThrottler{ready: make(chan struct{})}
+	handler := NewBodyBufferHandler(New(ctx, throttler, nil /*transport*/, nil /*mtlsTransport*/, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx)), 1024, nil /*spill*/)
+
+	body := stalledBody{unblock: make(chan struct{})}
+	defer close(body.unblock)
+	req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
+	req.Body = body
+	configStore := setupConfigStore(t, logging.FromContext(ctx))
+	reqCtx, reqCancel := context.WithTimeout(configStore.ToContext(req.Context()), 50*time.Millisecond)
+	defer reqCancel()
+	reqCtx = WithRevisionAndID(reqCtx, nil, types.NamespacedName{Namespace: testNamespace, Name: testRevName})
+
+	resp := httptest.NewRecorder()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		handler.ServeHTTP(resp, req.WithContext(reqCtx))
+	}()
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Handler didn't return while the client's body was stalled")
+	}
+	if got, want := resp.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+}
+
+func TestBufferedBodyReadAfterClose(t *testing.T) {
+	b := newBufferedBody(io.NopCloser(strings.NewReader(wantBody)), 1024, 1024, nil /*spill*/, nil /*statsCtx*/)
+	b.Close()
+	if _, err := b.Read(make([]byte, 1)); err != http.ErrBodyReadAfterClose {
+		t.Errorf("Read() = %v, want: %v", err, http.ErrBodyReadAfterClose)
+	}
+}
+
+func newTestSpillBuffer(t *testing.T, maxBytes int64) *SpillBuffer {
+	t.Cleanup(reset)
+	spill, err := NewSpillBuffer(filepath.Join(t.TempDir(), "spill"), maxBytes, "testPod")
+	if err != nil {
+		t.Fatal("NewSpillBuffer() =", err)
+	}
+	return spill
+}
+
+// assertSpillReleased asserts that nothing is left spilled to the buffer.
+func assertSpillReleased(t *testing.T, spill *SpillBuffer) {
+	t.Helper()
+	if got := spill.used.Load(); got != 0 {
+		t.Errorf("Spilled bytes in use = %d, want: 0", got)
+	}
+	if files, _ := os.ReadDir(spill.dir); len(files) != 0 {
+		t.Errorf("Spill files = %v, want none", files)
+	}
+}
+
+func TestBodyBufferHandlerSpill(t *testing.T) {
+	ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+	defer cancel()
+
+	gotBody := make(chan string, 1)
+	rt := pkgnet.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		gotBody <- string(b)
+		return httptest.NewRecorder().Result(), nil
+	})
+	throttler := coldStartThrottler{ready: make(chan struct{})}
+	spill := newTestSpillBuffer(t, 1<<20)
+	handler := NewBodyBufferHandler(New(ctx, throttler, rt, nil /*mtlsTransport*/, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx)), 4, spill)
+
+	rev := revision(testNamespace, testRevName)
+	rev.Annotations = map[string]string{serving.ActivatorRequestBufferMaxBytesAnnotation: "1048576"}
+	body, bodyWriter := io.Pipe()
+	req := httptest.NewRequest(http.MethodPost, "http://example.com", body)
+	configStore := setupConfigStore(t, logging.FromContext(ctx))
+	reqCtx := WithRevisionAndID(configStore.ToContext(req.Context()), rev, types.NamespacedName{Namespace: testNamespace, Name: testRevName})
+
+	resp := httptest.NewRecorder()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		handler.ServeHTTP(resp, req.WithContext(reqCtx))
+	}()
+
+	// Far more than the memory buffer is read while the revision is cold.
+	wantBody := strings.Repeat("spilled ", 3*bodyBufferChunkSize/8)
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		bodyWriter.Write([]byte(wantBody))
+		bodyWriter.Close()
+	}()
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Body wasn't read while waiting for a pod")
+	}
+
+	close(throttler.ready)
+	<-served
+	if got, want := resp.Code, http.StatusOK; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+	if got := <-gotBody; got != wantBody {
+		t.Errorf("Body of %d bytes differs from the %d bytes sent", len(got), len(wantBody))
+	}
+	assertSpillReleased(t, spill)
+
+	wantResource := &resource.Resource{
+		Type: "knative_revision",
+		Labels: map[string]string{
+			metrics.LabelNamespaceName:     rev.Namespace,
+			metrics.LabelServiceName:       rev.Labels[serving.ServiceLabelKey],
+			metrics.LabelConfigurationName: rev.Labels[serving.ConfigurationLabelKey],
+			metrics.LabelRevisionName:      rev.Name,
+		},
+	}
+	wantTags := map[string]string{
+		metrics.LabelPodName:       "testPod",
+		metrics.LabelContainerName: activator.Name,
+	}
+	metricstest.AssertMetric(t, metricstest.IntMetric(spillCountM.Name(), 1, wantTags).WithResource(wantResource))
+	metricstest.AssertMetricExists(t, spillBytesM.Name())
+}
+
+func TestBufferedBodySpillFull(t *testing.T) {
+	// The spill buffer fits a single chunk, so the client isn't read from
+	// until the proxy read what was spilled.
+	spill := newTestSpillBuffer(t, bodyBufferChunkSize)
+	wantBody := strings.Repeat("a", 3*bodyBufferChunkSize)
+	b := newBufferedBody(io.NopCloser(strings.NewReader(wantBody)), 0, 1<<20, spill, spill.statsCtx)
+
+	got, err := io.ReadAll(b)
+	if err != nil {
+		t.Fatal("ReadAll() =", err)
+	}
+	if string(got) != wantBody {
+		t.Errorf("Body of %d bytes differs from the %d bytes sent", len(got), len(wantBody))
+	}
+	b.Close()
+	assertSpillReleased(t, spill)
+	metricstest.AssertMetricExists(t, spillFullCountM.Name())
+}
+
+func TestBodyBufferHandlerMaxAge(t *testing.T) {
+	ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+	defer cancel()
+
+	throttler := coldStartThrottler{ready: make(chan struct{})}
+	handler := NewBodyBufferHandler(New(ctx, throttler, nil /*transport*/, nil /*mtlsTransport*/, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx)), 1024, nil /*spill*/)
+
+	rev := revision(testNamespace, testRevName)
+	rev.Annotations = map[string]string{serving.ActivatorRequestBufferMaxAgeAnnotation: "50ms"}
+	req := httptest.NewRequest(http.MethodPost, "http://example.com", strings.NewReader(wantBody))
+	configStore := setupConfigStore(t, logging.FromContext(ctx))
+	reqCtx := WithRevisionAndID(configStore.ToContext(req.Context()), rev, types.NamespacedName{Namespace: testNamespace, Name: testRevName})
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req.WithContext(reqCtx))
+	if got, want := resp.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opencensus.io/stats"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"knative.dev/serving/pkg/apis/serving"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// coldStartPhase is a phase of the latency of a cold-started request.
+type coldStartPhase struct {
+	// name is the name of the phase in the summary header.
+	name    string
+	measure *stats.Float64Measure
+}
+
+// The phases of a cold start. The request waits in the activator queue while
+// its pod is scheduled, pulls the image, starts the user container and
+// passes the readiness probe, and its readiness reaches the activator. The
+// pod's response to the request is the first byte.
+var (
+	coldStartActivatorQueue = coldStartPhase{"activator-queue", coldStartActivatorQueueM}
+	coldStartSchedule       = coldStartPhase{"schedule", coldStartScheduleM}
+	coldStartImagePull      = coldStartPhase{"image-pull", coldStartImagePullM}
+	coldStartContainerStart = coldStartPhase{"container-start", coldStartContainerStartM}
+	coldStartReadiness      = coldStartPhase{"readiness", coldStartReadinessM}
+	coldStartFirstByte      = coldStartPhase{"first-byte", coldStartFirstByteM}
+)
+
+// ColdStartReporter breaks the latency of the cold-started requests down into
+// the phases of the scale from zero, looking up the pod's phases in its
+// status, to be recorded by the MetricHandler.
+type ColdStartReporter struct {
+	pods corev1listers.PodLister
+	// header is whether the breakdown is summarized in a header of the
+	// responses to the cold-started requests.
+	header bool
+}
+
+// NewColdStartReporter creates a ColdStartReporter looking up the pods in pods,
+// which lists those with the serving.RevisionUID label.
+func NewColdStartReporter(pods corev1listers.PodLister, header bool) *ColdStartReporter {
+	return &ColdStartReporter{
+		pods:   pods,
+		header: header,
+	}
+}
+
+// coldStart is the breakdown of a cold-started request.
+type coldStart struct {
+	phases    []coldStartPhase
+	durations []time.Duration
+	// dispatched is when the request was proxied to the pod.
+	dispatched time.Time
+	// header is whether the breakdown is summarized in a response header.
+	header bool
+}
+
+func (c *coldStart) add(phase coldStartPhase, d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	c.phases = append(c.phases, phase)
+	c.durations = append(c.durations, d)
+}
+
+// String returns the summary of the phases, in milliseconds.
+func (c *coldStart) String() string {
+	parts := make([]string, len(c.phases))
+	for i, p := range c.phases {
+		parts[i] = p.name + "=" + strconv.FormatInt(c.durations[i].Milliseconds(), 10) + "ms"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// breakdown breaks down the phases of the cold-started request, which arrived
+// at arrived and was proxied to dest at dispatched, up to the first byte. The
+// pod's phases are left out if it isn't found.
+func (r *ColdStartReporter) breakdown(rev *v1.Revision, dest string, arrived, dispatched time.Time) *coldStart {
+	c := &coldStart{dispatched: dispatched, header: r.header}
+	c.add(coldStartActivatorQueue, dispatched.Sub(arrived))
+
+	pod := r.pod(rev, dest)
+	if pod == nil {
+		return c
+	}
+	var scheduled, ready time.Time
+	for _, cond := range pod.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case corev1.PodScheduled:
+			scheduled = cond.LastTransitionTime.Time
+		case corev1.PodReady:
+			ready = cond.LastTransitionTime.Time
+		}
+	}
+	var started time.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == rev.Spec.GetContainer().Name && status.State.Running != nil {
+			started = status.State.Running.StartedAt.Time
+		}
+	}
+	if scheduled.IsZero() || started.IsZero() || ready.IsZero() {
+		return c
+	}
+	c.add(coldStartSchedule, scheduled.Sub(pod.CreationTimestamp.Time))
+	c.add(coldStartImagePull, started.Sub(scheduled))
+	c.add(coldStartContainerStart, ready.Sub(started))
+	c.add(coldStartReadiness, dispatched.Sub(ready))
+	return c
+}
+
+// pod looks up the pod of the revision at dest.
+func (r *ColdStartReporter) pod(rev *v1.Revision, dest string) *corev1.Pod {
+	if r.pods == nil || rev == nil {
+		return nil
+	}
+	ip, _, err := net.SplitHostPort(dest)
+	if err != nil {
+		ip = dest
+	}
+	pods, err := r.pods.Pods(rev.Namespace).List(labels.SelectorFromSet(labels.Set{
+		serving.RevisionUID: string(rev.UID),
+	}))
+	if err != nil {
+		return nil
+	}
+	for _, p := range pods {
+		if p.Status.PodIP == ip {
+			return p
+		}
+	}
+	return nil
+}
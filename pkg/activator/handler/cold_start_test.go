@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/metrics/metricstest"
+	pkgnet "knative.dev/pkg/network"
+	rtesting "knative.dev/pkg/reconciler/testing"
+	"knative.dev/serving/pkg/activator"
+	activatornet "knative.dev/serving/pkg/activator/net"
+	activatortest "knative.dev/serving/pkg/activator/testing"
+	"knative.dev/serving/pkg/apis/serving"
+)
+
+// coldThrottler reports the requests as cold starts, proxied to the pod at
+// 10.10.10.10.
+type coldThrottler struct {
+	cold bool
+}
+
+func (t coldThrottler) Try(ctx context.Context, _ types.NamespacedName, f func(string) error) error {
+	activatornet.ReportColdStart(ctx, t.cold)
+	return f("10.10.10.10:8012")
+}
+
+func TestColdStartBreakdown(t *testing.T) {
+	rev := revision(testNamespace, testRevName)
+	rev.UID = "rev-uid"
+	rev.Spec.Containers = []corev1.Container{{Name: "user-container"}}
+
+	created := time.Now().Add(-10 * time.Second)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         testNamespace,
+			Name:              "pod",
+			Labels:            map[string]string{serving.RevisionUID: "rev-uid"},
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Status: corev1.PodStatus{
+			PodIP: "10.10.10.10",
+			Conditions: []corev1.PodCondition{{
+				Type:               corev1.PodScheduled,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(created.Add(time.Second)),
+			}, {
+				Type:               corev1.PodReady,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(created.Add(6 * time.Second)),
+			}},
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "user-container",
+				State: corev1.ContainerState{
+					Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(created.Add(4 * time.Second))},
+				},
+			}},
+		},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	indexer.Add(pod)
+	pods := corev1listers.NewPodLister(indexer)
+
+	tests := []struct {
+		name       string
+		cold       bool
+		header     bool
+		wantHeader string
+	}{{
+		name: "warm",
+	}, {
+		name: "cold",
+		cold: true,
+	}, {
+		name:       "cold with header",
+		cold:       true,
+		header:     true,
+		wantHeader: "activator-queue=0ms, schedule=1000ms, image-pull=3000ms, container-start=2000ms, readiness=",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer reset()
+			ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+			defer cancel()
+
+			fakeRT := activatortest.FakeRoundTripper{
+				RequestResponse: &activatortest.FakeResponse{
+					Code: http.StatusOK,
+					Body: wantBody,
+				},
+			}
+			handler := NewMetricHandler("testPod",
+				New(ctx, coldThrottler{cold: test.cold}, pkgnet.RoundTripperFunc(fakeRT.RT), nil /*mtlsTransport*/, false /*usePassthroughLb*/,
+					NewColdStartReporter(pods, test.header), logging.FromContext(ctx)))
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			reqCtx := setupConfigStore(t, logging.FromContext(ctx)).ToContext(req.Context())
+			reqCtx = WithRevisionAndID(reqCtx, rev, types.NamespacedName{Namespace: testNamespace, Name: testRevName})
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req.WithContext(reqCtx))
+
+			got := resp.Header().Get(activator.ColdStartBreakdownHeaderName)
+			if !strings.HasPrefix(got, test.wantHeader) || (test.wantHeader == "") != (got == "") {
+				t.Errorf("%s = %q, want prefix: %q", activator.ColdStartBreakdownHeaderName, got, test.wantHeader)
+			}
+			if test.wantHeader != "" && !strings.Contains(got, "first-byte=") {
+				t.Errorf("%s = %q, want the first byte", activator.ColdStartBreakdownHeaderName, got)
+			}
+
+			names := []string{coldStartActivatorQueueM.Name(), coldStartScheduleM.Name(), coldStartImagePullM.Name(),
+				coldStartContainerStartM.Name(), coldStartReadinessM.Name(), coldStartFirstByteM.Name()}
+			if test.cold {
+				metricstest.AssertMetricExists(t, names...)
+			} else {
+				metricstest.AssertNoMetric(t, names...)
+			}
+		})
+	}
+}
+
+func TestColdStartBreakdownWithoutPod(t *testing.T) {
+	rev := revision(testNamespace, testRevName)
+	r := NewColdStartReporter(corev1listers.NewPodLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})), true)
+	now := time.Now()
+	cs := r.breakdown(rev, "10.10.10.10:8012", now.Add(-1500*time.Millisecond), now)
+	if got, want := cs.String(), "activator-queue=1500ms"; got != want {
+		t.Errorf("breakdown() = %q, want: %q", got, want)
+	}
+}
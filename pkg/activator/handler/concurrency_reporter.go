@@ -51,11 +51,20 @@ type revisionStats struct {
 	refs         atomic.Int64
 }
 
+// QueueDepther reports the number of requests of a revision queued in the
+// activator, waiting for capacity.
+type QueueDepther interface {
+	QueueDepth(revID types.NamespacedName) int
+}
+
 // ConcurrencyReporter reports stats based on incoming requests and ticks.
 type ConcurrencyReporter struct {
 	logger  *zap.SugaredLogger
 	podName string
 
+	// queueDepths reports the queue depths of the revisions, if not nil.
+	queueDepths QueueDepther
+
 	// Stat reporting channel
 	statCh chan []asmetrics.StatMessage
 
@@ -68,12 +77,16 @@ type ConcurrencyReporter struct {
 
 // NewConcurrencyReporter creates a ConcurrencyReporter which listens to incoming
 // ReqEvents on reqCh and ticks on reportCh and reports stats on statCh.
-func NewConcurrencyReporter(ctx context.Context, podName string, statCh chan []asmetrics.StatMessage) *ConcurrencyReporter {
+// The queue depths of the revisions reported by queueDepths, if not nil, are
+// reported along.
+func NewConcurrencyReporter(ctx context.Context, podName string, statCh chan []asmetrics.StatMessage,
+	queueDepths QueueDepther) *ConcurrencyReporter {
 	return &ConcurrencyReporter{
-		logger:  logging.FromContext(ctx),
-		podName: podName,
-		statCh:  statCh,
-		rl:      revisioninformer.Get(ctx).Lister(),
+		logger:      logging.FromContext(ctx),
+		podName:     podName,
+		queueDepths: queueDepths,
+		statCh:      statCh,
+		rl:          revisioninformer.Get(ctx).Lister(),
 
 		stats: make(map[types.NamespacedName]*revisionStats),
 	}
@@ -187,12 +200,17 @@ func (cr *ConcurrencyReporter) computeReport(now time.Time) (msgs []asmetrics.St
 		// the reporting period might be < 1.
 		adjustedConcurrency := math.Max(report.AverageConcurrency-firstAdj, 0)
 		adjustedCount := report.RequestCount - firstAdj
+		var queueDepth float64
+		if cr.queueDepths != nil {
+			queueDepth = float64(cr.queueDepths.QueueDepth(key))
+		}
 		msgs = append(msgs, asmetrics.StatMessage{
 			Key: key,
 			Stat: asmetrics.Stat{
 				PodName:                   cr.podName,
 				AverageConcurrentRequests: adjustedConcurrency,
 				RequestCount:              adjustedCount,
+				ActivatorQueueDepth:       queueDepth,
 			},
 		})
 	}
@@ -422,6 +422,33 @@ func TestConcurrencyReporterRun(t *testing.T) {
 	}
 }
 
+// fakeQueueDepther reports fixed queue depths.
+type fakeQueueDepther map[types.NamespacedName]int
+
+func (f fakeQueueDepther) QueueDepth(revID types.NamespacedName) int {
+	return f[revID]
+}
+
+func TestConcurrencyReporterQueueDepth(t *testing.T) {
+	cr, _, cancel := newTestReporter(t)
+	defer cancel()
+	cr.queueDepths = fakeQueueDepther{rev1: 7}
+
+	now := time.Time{}
+	cr.handleRequestIn(network.ReqEvent{Key: rev1, Type: network.ReqIn, Time: now})
+	cr.handleRequestIn(network.ReqEvent{Key: rev2, Type: network.ReqIn, Time: now})
+	<-cr.statCh
+	<-cr.statCh
+
+	got := map[types.NamespacedName]float64{}
+	for _, msg := range cr.report(now.Add(time.Second)) {
+		got[msg.Key] = msg.Stat.ActivatorQueueDepth
+	}
+	if want := map[types.NamespacedName]float64{rev1: 7, rev2: 0}; !cmp.Equal(got, want) {
+		t.Error("Unexpected queue depths (-want +got):", cmp.Diff(want, got))
+	}
+}
+
 func TestConcurrencyReporterHandler(t *testing.T) {
 	cr, ctx, cancel := newTestReporter(t)
 	defer cancel()
@@ -594,7 +621,7 @@ func newTestReporter(t *testing.T) (*ConcurrencyReporter, context.Context, conte
 	// Buffered channel permits avoiding sending the test commands on the separate go routine
 	// simplifying main test process.
 	statCh := make(chan []asmetrics.StatMessage, 10)
-	return NewConcurrencyReporter(ctx, activatorPodName, statCh), ctx, cancel
+	return NewConcurrencyReporter(ctx, activatorPodName, statCh, nil), ctx, cancel
 }
 
 func revisionInformer(ctx context.Context, revs ...*v1.Revision) {
@@ -613,7 +640,7 @@ func BenchmarkConcurrencyReporterHandler(b *testing.B) {
 
 	// Buffer equal to the activator.
 	statCh := make(chan []asmetrics.StatMessage)
-	cr := NewConcurrencyReporter(ctx, activatorPodName, statCh)
+	cr := NewConcurrencyReporter(ctx, activatorPodName, statCh, nil)
 
 	stopCh := make(chan struct{})
 	defer close(stopCh)
@@ -683,7 +710,7 @@ func BenchmarkConcurrencyReporterReport(b *testing.B) {
 
 			// Different to the activator but doesn't matter as it isn't used in the test.
 			statCh := make(chan []asmetrics.StatMessage, revs)
-			cr := NewConcurrencyReporter(ctx, activatorPodName, statCh)
+			cr := NewConcurrencyReporter(ctx, activatorPodName, statCh, nil)
 
 			fake := fakeservingclient.Get(ctx)
 			revisions := fakerevisioninformer.Get(ctx)
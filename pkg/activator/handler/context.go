@@ -23,13 +23,17 @@ package handler
 
 import (
 	"context"
+	"time"
 
+	"go.opencensus.io/trace"
 	"k8s.io/apimachinery/pkg/types"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 )
 
 type (
-	revCtxKey struct{}
+	revCtxKey            struct{}
+	latenciesCtxKey      struct{}
+	bufferDeadlineCtxKey struct{}
 )
 
 type revCtx struct {
@@ -54,3 +58,47 @@ func RevisionFrom(ctx context.Context) *v1.Revision {
 func RevIDFrom(ctx context.Context) types.NamespacedName {
 	return ctx.Value(revCtxKey{}).(*revCtx).revID
 }
+
+// latencies are the phases of the request handled by the activationHandler,
+// to be reported by the MetricHandler.
+type latencies struct {
+	// wait is the time spent waiting for capacity. It's zero if the request
+	// was proxied right away.
+	wait time.Duration
+	// proxy is the time spent proxying the request.
+	proxy time.Duration
+	// proxied is whether the request was proxied at all.
+	proxied bool
+	// span is the span of the request, set by the tracing handler, for the
+	// latencies to link to its trace as their exemplar.
+	span trace.SpanContext
+	// coldStart is the breakdown of the request if it's a cold start, nil
+	// otherwise.
+	coldStart *coldStart
+}
+
+// withLatencies attaches a latencies struct to the context, to be populated
+// down the handler chain.
+func withLatencies(ctx context.Context) (context.Context, *latencies) {
+	l := &latencies{}
+	return context.WithValue(ctx, latenciesCtxKey{}, l), l
+}
+
+// latenciesFrom retrieves the latencies from the context, if any.
+func latenciesFrom(ctx context.Context) *latencies {
+	l, _ := ctx.Value(latenciesCtxKey{}).(*latencies)
+	return l
+}
+
+// withBufferDeadline attaches the time until which a request whose body is
+// buffered may wait for a pod to the context.
+func withBufferDeadline(ctx context.Context, deadline time.Time) context.Context {
+	return context.WithValue(ctx, bufferDeadlineCtxKey{}, deadline)
+}
+
+// bufferDeadlineFrom retrieves the time until which the request may wait for a
+// pod, if it's bounded.
+func bufferDeadlineFrom(ctx context.Context) (time.Time, bool) {
+	deadline, ok := ctx.Value(bufferDeadlineCtxKey{}).(time.Time)
+	return deadline, ok
+}
@@ -75,6 +75,12 @@ func (h *contextHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		sendError(err, w)
 		return
 	}
+	if revision.Spec.Hibernated {
+		// Rejecting the request before it's reported keeps the revision
+		// from being scaled up.
+		http.Error(w, fmt.Sprintf("Revision %q is hibernated", revID.String()), http.StatusServiceUnavailable)
+		return
+	}
 
 	ctx := r.Context()
 	ctx = WithRevisionAndID(ctx, revision, revID)
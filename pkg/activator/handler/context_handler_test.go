@@ -100,6 +100,31 @@ func TestContextHandlerError(t *testing.T) {
 	}
 }
 
+func TestContextHandlerHibernated(t *testing.T) {
+	ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+	defer cancel()
+	revID := types.NamespacedName{Namespace: testNamespace, Name: testRevName}
+	revision := revision(revID.Namespace, revID.Name)
+	revision.Spec.Hibernated = true
+	revisionInformer(ctx, revision)
+	configStore := setupConfigStore(t, logging.FromContext(ctx))
+
+	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("The request to the hibernated revision was passed on")
+	})
+
+	handler := NewContextHandler(ctx, baseHandler, configStore)
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://example.com", bytes.NewBufferString(""))
+	req.Header.Set(activator.RevisionHeaderNamespace, revID.Namespace)
+	req.Header.Set(activator.RevisionHeaderName, revID.Name)
+	handler.ServeHTTP(resp, req)
+
+	if got, want := resp.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("StatusCode = %d, want %d", got, want)
+	}
+}
+
 func BenchmarkContextHandler(b *testing.B) {
 	tests := []struct {
 		label        string
@@ -18,12 +18,20 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httputil"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/trace"
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/types"
 
@@ -31,14 +39,21 @@ import (
 	"knative.dev/pkg/logging/logkey"
 	pkghandler "knative.dev/pkg/network/handlers"
 	tracingconfig "knative.dev/pkg/tracing/config"
-	"knative.dev/pkg/tracing/propagation/tracecontextb3"
 	"knative.dev/serving/pkg/activator"
 	activatorconfig "knative.dev/serving/pkg/activator/config"
+	activatornet "knative.dev/serving/pkg/activator/net"
+	"knative.dev/serving/pkg/apis/serving"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
 	pkghttp "knative.dev/serving/pkg/http"
+	"knative.dev/serving/pkg/networking"
 	"knative.dev/serving/pkg/queue"
 	"knative.dev/serving/pkg/reconciler/serverlessservice/resources/names"
 )
 
+// minWaitLatency is the time a request must have waited in the throttler to
+// be considered as waiting for capacity, rather than being proxied right away.
+const minWaitLatency = time.Millisecond
+
 // Throttler is the interface that Handler calls to Try to proxy the user request.
 type Throttler interface {
 	Try(ctx context.Context, revID types.NamespacedName, fn func(string) error) error
@@ -49,25 +64,48 @@ type Throttler interface {
 type activationHandler struct {
 	transport        http.RoundTripper
 	tracingTransport http.RoundTripper
+	// mtlsTransport talks to the queue-proxies with mutual TLS, if enabled.
+	// It's nil if the activator has no certificate to do so.
+	mtlsTransport        http.RoundTripper
+	tracingMTLSTransport http.RoundTripper
+	// mtlsPort is the port the queue-proxies serve mutual TLS on.
+	mtlsPort         int
 	usePassthroughLb bool
 	throttler        Throttler
-	bufferPool       httputil.BufferPool
-	logger           *zap.SugaredLogger
+	// coldStarts breaks down the cold-started requests. It's nil if they
+	// aren't.
+	coldStarts *ColdStartReporter
+	bufferPool httputil.BufferPool
+	logger     *zap.SugaredLogger
 }
 
 // New constructs a new http.Handler that deals with revision activation.
-func New(_ context.Context, t Throttler, transport http.RoundTripper, usePassthroughLb bool, logger *zap.SugaredLogger) http.Handler {
-	return &activationHandler{
+// mtlsTransport is used instead of transport once mutual TLS with the
+// queue-proxies is enabled, and may be nil if the activator can't.
+// coldStarts breaks down the cold-started requests, unless nil.
+func New(_ context.Context, t Throttler, transport, mtlsTransport http.RoundTripper, usePassthroughLb bool,
+	coldStarts *ColdStartReporter, logger *zap.SugaredLogger) http.Handler {
+	a := &activationHandler{
 		transport: transport,
 		tracingTransport: &ochttp.Transport{
 			Base:        transport,
-			Propagation: tracecontextb3.TraceContextB3Egress,
+			Propagation: configPropagation{},
 		},
+		mtlsTransport:    mtlsTransport,
+		mtlsPort:         networking.BackendHTTPSPort,
 		usePassthroughLb: usePassthroughLb,
 		throttler:        t,
+		coldStarts:       coldStarts,
 		bufferPool:       network.NewBufferPool(),
 		logger:           logger,
 	}
+	if mtlsTransport != nil {
+		a.tracingMTLSTransport = &ochttp.Transport{
+			Base:        mtlsTransport,
+			Propagation: configPropagation{},
+		}
+	}
+	return a
 }
 
 func (a *activationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -80,52 +118,264 @@ func (a *activationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	revID := RevIDFrom(r.Context())
+	rev := RevisionFrom(r.Context())
+	if rev != nil {
+		// The annotations are validated by the webhook.
+		if header, ok := rev.Annotations[serving.ActivatorLoadBalancingHashHeaderAnnotation]; ok {
+			tryContext = activatornet.WithLBHashKey(tryContext, r.Header.Get(header))
+		}
+		if v, ok := rev.Annotations[serving.ActivatorMaxQueueLengthAnnotation]; ok {
+			n, _ := strconv.Atoi(v)
+			tryContext = activatornet.WithMaxQueueLength(tryContext, n)
+		}
+		if v, ok := rev.Annotations[serving.ActivatorMaxQueueWaitAnnotation]; ok {
+			wait, _ := time.ParseDuration(v)
+			var cancel context.CancelFunc
+			tryContext, cancel = context.WithTimeout(tryContext, wait)
+			defer cancel()
+		}
+		if v, ok := rev.Annotations[serving.ActivatorColdStartTimeoutAnnotation]; ok {
+			timeout, _ := time.ParseDuration(v)
+			tryContext = activatornet.WithColdStartTimeout(tryContext, timeout)
+		}
+	}
+	if isStreamRequest(r) {
+		tryContext = activatornet.WithStream(tryContext)
+	}
+	if deadline, ok := bufferDeadlineFrom(r.Context()); ok {
+		var cancel context.CancelFunc
+		tryContext, cancel = context.WithDeadline(tryContext, deadline)
+		defer cancel()
+	}
+	lat := latenciesFrom(r.Context())
+	var cold bool
+	if a.coldStarts != nil && lat != nil {
+		tryContext = activatornet.WithColdStart(tryContext, &cold)
+	}
+	start := time.Now()
 	if err := a.throttler.Try(tryContext, revID, func(dest string) error {
 		trySpan.End()
+		proxyStart := time.Now()
+		if cold {
+			// Broken down as of being proxied, up to the first byte.
+			lat.coldStart = a.coldStarts.breakdown(rev, dest, start, proxyStart)
+		}
 
 		proxyCtx, proxySpan := r.Context(), (*trace.Span)(nil)
 		if tracingEnabled {
 			proxyCtx, proxySpan = trace.StartSpan(r.Context(), "activator_proxy")
 		}
-		a.proxyRequest(revID, w, r.WithContext(proxyCtx), dest, tracingEnabled, a.usePassthroughLb)
+		var err error
+		if config.InternalTLS != nil && config.InternalTLS.QueueTLS() {
+			err = a.proxyMTLSRequest(revID, w, r.WithContext(proxyCtx), dest, tracingEnabled)
+		} else {
+			err = a.proxyRequest(revID, w, r.WithContext(proxyCtx), dest, tracingEnabled, a.usePassthroughLb)
+		}
 		proxySpan.End()
 
-		return nil
+		if lat != nil {
+			if wait := proxyStart.Sub(start); wait >= minWaitLatency {
+				lat.wait = wait
+			}
+			lat.proxy = time.Since(proxyStart)
+			lat.proxied = true
+		}
+
+		return err
 	}); err != nil {
+		var retryErr *activatornet.RetryableError
+		if errors.As(err, &retryErr) {
+			// The request failed on all the pods it was retried on.
+			pkghandler.Error(a.logger.With(zap.String(logkey.Key, revID.String())))(w, r, retryErr.Err)
+			return
+		}
+
 		// Set error on our capacity waiting span and end it.
 		trySpan.Annotate([]trace.Attribute{trace.StringAttribute("activator.throttler.error", err.Error())}, "ThrottlerTry")
 		trySpan.End()
 
 		a.logger.Errorw("Throttler try error", zap.String(logkey.Key, revID.String()), zap.Error(err))
 
-		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, queue.ErrRequestQueueFull) {
-			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		if errors.Is(err, activatornet.ErrColdStartTimeout) {
+			// Told apart from the app's errors and the activator shedding.
+			w.Header().Set(activator.ColdStartTimeoutHeaderName, "true")
+			http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		} else if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, queue.ErrRequestQueueFull) ||
+			errors.Is(err, activatornet.ErrRetryBudgetExhausted) {
+			a.shed(w, rev, revID, err)
 		} else {
 			w.WriteHeader(http.StatusInternalServerError)
 		}
 	}
 }
 
-func (a *activationHandler) proxyRequest(revID types.NamespacedName, w http.ResponseWriter,
-	r *http.Request, target string, tracingEnabled bool, usePassthroughLb bool) {
-	network.RewriteHostIn(r)
-	r.Header.Set(network.ProxyHeaderName, activator.Name)
+// proxyMTLSRequest proxies the request to the TLS port of the queue-proxy at
+// target, which must present a certificate for the revision. The activator
+// presents its certificate, for when mutual TLS is required.
+func (a *activationHandler) proxyMTLSRequest(revID types.NamespacedName, w http.ResponseWriter,
+	r *http.Request, target string, tracingEnabled bool) error {
+	if a.mtlsTransport == nil {
+		// Falling back to plain text would defeat the purpose.
+		a.logger.Errorw("TLS with the queue-proxies is enabled, but the activator has no certificate",
+			zap.String(logkey.Key, revID.String()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil
+	}
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		pkghandler.Error(a.logger.With(zap.String(logkey.Key, revID.String())))(w, r, err)
+		return nil
+	}
+	target = net.JoinHostPort(host, strconv.Itoa(a.mtlsPort))
 
-	// Set up the reverse proxy.
+	transport := a.mtlsTransport
+	if tracingEnabled {
+		transport = a.tracingMTLSTransport
+	}
+	r = r.WithContext(activatornet.WithPeerSAN(r.Context(), networking.RevisionCertificateSAN(revID.Namespace, revID.Name)))
+	return a.proxy(revID, w, r, target, pkghttp.NoHostOverride, "https", transport)
+}
+
+// proxyRequest proxies the request to target. If that failed without side
+// effects, it returns an activatornet.RetryableError rather than sending the
+// error, so that the request may be retried on another pod.
+func (a *activationHandler) proxyRequest(revID types.NamespacedName, w http.ResponseWriter,
+	r *http.Request, target string, tracingEnabled bool, usePassthroughLb bool) error {
 	hostOverride := pkghttp.NoHostOverride
 	if usePassthroughLb {
 		hostOverride = names.PrivateService(revID.Name) + "." + revID.Namespace
 	}
-	proxy := pkghttp.NewHeaderPruningReverseProxy(target, hostOverride, activator.RevisionHeaders)
-	proxy.BufferPool = a.bufferPool
-	proxy.Transport = a.transport
+	transport := a.transport
 	if tracingEnabled {
-		proxy.Transport = a.tracingTransport
+		transport = a.tracingTransport
+	}
+	return a.proxy(revID, w, r, target, hostOverride, "http", transport)
+}
+
+// proxy proxies the request to target with the scheme over the transport. If
+// that failed without side effects, it returns an activatornet.RetryableError.
+// If the pod failed the request otherwise, it returns an activatornet.PodError
+// once the response was sent.
+func (a *activationHandler) proxy(revID types.NamespacedName, w http.ResponseWriter,
+	r *http.Request, target, hostOverride, scheme string, transport http.RoundTripper) error {
+	network.RewriteHostIn(r)
+	r.Header.Set(network.ProxyHeaderName, activator.Name)
+
+	// Set up the reverse proxy.
+	proxy := pkghttp.NewHeaderPruningReverseProxy(target, hostOverride, activator.RevisionHeaders)
+	if scheme != "http" {
+		director := proxy.Director
+		proxy.Director = func(req *http.Request) {
+			director(req)
+			req.URL.Scheme = scheme
+		}
 	}
+	proxy.BufferPool = a.bufferPool
+	proxy.Transport = transport
 	proxy.FlushInterval = network.FlushInterval
+
+	var body *retryableBody
+	if r.Body != nil && r.Body != http.NoBody {
+		body = &retryableBody{ReadCloser: r.Body}
+		r.Body = body
+	}
+	var retryErr, podErr error
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		if lat := latenciesFrom(r.Context()); lat != nil && lat.coldStart != nil {
+			cs := lat.coldStart
+			cs.add(coldStartFirstByte, time.Since(cs.dispatched))
+			if cs.header {
+				resp.Header.Set(activator.ColdStartBreakdownHeaderName, cs.String())
+			}
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			podErr = &activatornet.PodError{Err: fmt.Errorf("the pod answered with status %d", resp.StatusCode)}
+		}
+		return nil
+	}
 	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		if req.Context().Err() == nil {
+			if isRetryableProxyError(err) && !body.wasRead() {
+				retryErr = &activatornet.RetryableError{Err: err}
+				return
+			}
+			podErr = &activatornet.PodError{Err: err}
+		}
 		pkghandler.Error(a.logger.With(zap.String(logkey.Key, revID.String())))(w, req, err)
 	}
 
 	proxy.ServeHTTP(w, r)
+	if retryErr != nil {
+		return retryErr
+	}
+	return podErr
+}
+
+// shed responds to a request the activator sheds with a 503, with the body
+// and headers of the revision's annotations, if any, or the error otherwise.
+func (a *activationHandler) shed(w http.ResponseWriter, rev *v1.Revision, revID types.NamespacedName, err error) {
+	var annotations map[string]string
+	if rev != nil {
+		annotations = rev.Annotations
+	}
+	if v, ok := annotations[serving.ActivatorShedResponseHeadersAnnotation]; ok {
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(v), &headers); err != nil {
+			a.logger.Errorw("Invalid shed response headers", zap.String(logkey.Key, revID.String()), zap.Error(err))
+		}
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+	}
+	body, ok := annotations[serving.ActivatorShedResponseBodyAnnotation]
+	if !ok {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	io.WriteString(w, body)
+}
+
+// isStreamRequest returns whether the request is likely a long-lived stream:
+// a WebSocket connection, a server-sent event stream or a gRPC call, which
+// may stream.
+func isStreamRequest(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream") ||
+		strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// isRetryableProxyError returns whether proxying a request failed before
+// anything of it was sent, as the connection to the pod couldn't be
+// established, e.g. as it was refused or reset.
+func isRetryableProxyError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// retryableBody tracks whether the request body was read while proxying the
+// request, as it can't be retried then. It's not closed by the proxy, so that
+// it can be read by a retry.
+type retryableBody struct {
+	io.ReadCloser
+	read atomic.Bool
+}
+
+// Read implements io.Reader.
+func (b *retryableBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 || err != nil {
+		b.read.Store(true)
+	}
+	return n, err
+}
+
+// Close implements io.Closer. Closing the body is left to the server.
+func (b *retryableBody) Close() error {
+	return nil
+}
+
+// wasRead returns whether the body was read from. A nil body never is.
+func (b *retryableBody) wasRead() bool {
+	return b != nil && b.read.Load()
 }
@@ -22,12 +22,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
@@ -40,9 +45,11 @@ import (
 	tracetesting "knative.dev/pkg/tracing/testing"
 	"knative.dev/serving/pkg/activator"
 	activatorconfig "knative.dev/serving/pkg/activator/config"
+	activatornet "knative.dev/serving/pkg/activator/net"
 	activatortest "knative.dev/serving/pkg/activator/testing"
 	"knative.dev/serving/pkg/apis/serving"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	"knative.dev/serving/pkg/networking"
 	"knative.dev/serving/pkg/queue"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -65,7 +72,13 @@ func (ft fakeThrottler) Try(_ context.Context, _ types.NamespacedName, f func(st
 	if ft.err != nil {
 		return ft.err
 	}
-	return f("10.10.10.10:1234")
+	err := f("10.10.10.10:1234")
+	// Like the throttler, PodErrors only count towards ejecting the pod.
+	var podErr *activatornet.PodError
+	if errors.As(err, &podErr) {
+		return nil
+	}
+	return err
 }
 
 func TestActivationHandler(t *testing.T) {
@@ -78,6 +91,9 @@ func TestActivationHandler(t *testing.T) {
 		probeCode int
 		probeResp []string
 		throttler Throttler
+		// wantColdStartTimeout is whether the response is marked as a cold
+		// start timeout.
+		wantColdStartTimeout bool
 	}{{
 		name:      "active endpoint",
 		wantBody:  wantBody,
@@ -99,6 +115,12 @@ func TestActivationHandler(t *testing.T) {
 		wantBody:  "pending request queue full\n",
 		wantCode:  http.StatusServiceUnavailable,
 		throttler: fakeThrottler{err: queue.ErrRequestQueueFull},
+	}, {
+		name:                 "cold start timeout",
+		wantBody:             activatornet.ErrColdStartTimeout.Error() + "\n",
+		wantCode:             http.StatusGatewayTimeout,
+		throttler:            fakeThrottler{err: activatornet.ErrColdStartTimeout},
+		wantColdStartTimeout: true,
 	}}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -123,7 +145,7 @@ func TestActivationHandler(t *testing.T) {
 
 			ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
 			defer cancel()
-			handler := New(ctx, test.throttler, rt, false /*usePassthroughLb*/, logging.FromContext(ctx))
+			handler := New(ctx, test.throttler, rt, nil /*mtlsTransport*/, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx))
 
 			resp := httptest.NewRecorder()
 			req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
@@ -147,6 +169,9 @@ func TestActivationHandler(t *testing.T) {
 			if string(gotBody) != test.wantBody {
 				t.Errorf("Response body = %q, want: %q", gotBody, test.wantBody)
 			}
+			if got := resp.Header().Get(activator.ColdStartTimeoutHeaderName) == "true"; got != test.wantColdStartTimeout {
+				t.Errorf("Cold start timeout = %v, want: %v", got, test.wantColdStartTimeout)
+			}
 		})
 	}
 }
@@ -162,7 +187,7 @@ func TestActivationHandlerProxyHeader(t *testing.T) {
 	ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
 	defer cancel()
 
-	handler := New(ctx, fakeThrottler{}, rt, false /*usePassthroughLb*/, logging.FromContext(ctx))
+	handler := New(ctx, fakeThrottler{}, rt, nil /*mtlsTransport*/, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx))
 
 	writer := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
@@ -184,6 +209,370 @@ func TestActivationHandlerProxyHeader(t *testing.T) {
 	}
 }
 
+func TestActivationHandlerShed(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		throttler   Throttler
+		wantBody    string
+		wantHeaders map[string]string
+	}{{
+		name:      "generic",
+		throttler: fakeThrottler{err: queue.ErrRequestQueueFull},
+		wantBody:  "pending request queue full\n",
+	}, {
+		name: "custom",
+		annotations: map[string]string{
+			serving.ActivatorShedResponseBodyAnnotation:    `{"error": "overloaded"}`,
+			serving.ActivatorShedResponseHeadersAnnotation: `{"Content-Type": "application/json", "Retry-After": "5"}`,
+		},
+		throttler:   fakeThrottler{err: queue.ErrRequestQueueFull},
+		wantBody:    `{"error": "overloaded"}`,
+		wantHeaders: map[string]string{"Content-Type": "application/json", "Retry-After": "5"},
+	}, {
+		name: "headers only",
+		annotations: map[string]string{
+			serving.ActivatorShedResponseHeadersAnnotation: `{"Retry-After": "5"}`,
+		},
+		throttler:   fakeThrottler{err: context.DeadlineExceeded},
+		wantBody:    context.DeadlineExceeded.Error() + "\n",
+		wantHeaders: map[string]string{"Retry-After": "5"},
+	}, {
+		name: "max queue wait",
+		annotations: map[string]string{
+			serving.ActivatorMaxQueueWaitAnnotation:     "50ms",
+			serving.ActivatorShedResponseBodyAnnotation: "overloaded",
+		},
+		throttler: coldStartThrottler{ready: make(chan struct{})},
+		wantBody:  "overloaded",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+			defer cancel()
+
+			handler := New(ctx, test.throttler, nil /*transport*/, nil /*mtlsTransport*/, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx))
+
+			rev := revision(testNamespace, testRevName)
+			rev.Annotations = test.annotations
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
+			configStore := setupConfigStore(t, logging.FromContext(ctx))
+			ctx = configStore.ToContext(req.Context())
+			ctx = WithRevisionAndID(ctx, rev, types.NamespacedName{Namespace: testNamespace, Name: testRevName})
+			handler.ServeHTTP(resp, req.WithContext(ctx))
+
+			if got, want := resp.Code, http.StatusServiceUnavailable; got != want {
+				t.Errorf("Code = %d, want: %d", got, want)
+			}
+			if got := resp.Body.String(); got != test.wantBody {
+				t.Errorf("Body = %q, want: %q", got, test.wantBody)
+			}
+			for name, want := range test.wantHeaders {
+				if got := resp.Header().Get(name); got != want {
+					t.Errorf("Header %q = %q, want: %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// retryingThrottler proxies to its dests in turn while the proxy fails
+// retryably.
+type retryingThrottler struct {
+	dests []string
+}
+
+func (rt retryingThrottler) Try(_ context.Context, _ types.NamespacedName, f func(string) error) error {
+	var err error
+	for _, dest := range rt.dests {
+		var retryErr *activatornet.RetryableError
+		if err = f(dest); !errors.As(err, &retryErr) {
+			var podErr *activatornet.PodError
+			if errors.As(err, &podErr) {
+				return nil
+			}
+			return err
+		}
+	}
+	return err
+}
+
+// recordingThrottler records the error the function passed to Try returned.
+type recordingThrottler struct {
+	err *error
+}
+
+func (rt recordingThrottler) Try(_ context.Context, _ types.NamespacedName, f func(string) error) error {
+	*rt.err = f("10.10.10.10:1234")
+	return nil
+}
+
+func TestActivationHandlerPodError(t *testing.T) {
+	tests := []struct {
+		name        string
+		resp        *http.Response
+		err         error
+		wantCode    int
+		wantPodErr  bool
+		wantRetried bool
+	}{{
+		name:     "success",
+		resp:     &http.Response{StatusCode: http.StatusOK, Body: http.NoBody},
+		wantCode: http.StatusOK,
+	}, {
+		name:     "client error",
+		resp:     &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody},
+		wantCode: http.StatusNotFound,
+	}, {
+		name:       "server error",
+		resp:       &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody},
+		wantCode:   http.StatusServiceUnavailable,
+		wantPodErr: true,
+	}, {
+		name:       "connection reset",
+		err:        errors.New("connection reset by peer"),
+		wantCode:   http.StatusBadGateway,
+		wantPodErr: true,
+	}, {
+		name:        "connection refused",
+		err:         &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED},
+		wantRetried: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := pkgnet.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				if test.err != nil {
+					return nil, test.err
+				}
+				return test.resp, nil
+			})
+
+			ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+			defer cancel()
+			var err error
+			handler := New(ctx, recordingThrottler{err: &err}, rt, nil /*mtlsTransport*/, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx))
+
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			ctx = setupConfigStore(t, logging.FromContext(ctx)).ToContext(req.Context())
+			ctx = WithRevisionAndID(ctx, nil, types.NamespacedName{Namespace: testNamespace, Name: testRevName})
+			handler.ServeHTTP(writer, req.WithContext(ctx))
+
+			var (
+				podErr   *activatornet.PodError
+				retryErr *activatornet.RetryableError
+			)
+			if got := errors.As(err, &podErr); got != test.wantPodErr {
+				t.Errorf("PodError = %v, want: %v", err, test.wantPodErr)
+			}
+			if got := errors.As(err, &retryErr); got != test.wantRetried {
+				t.Errorf("RetryableError = %v, want: %v", err, test.wantRetried)
+			}
+			if test.wantCode != 0 {
+				if got := writer.Code; got != test.wantCode {
+					t.Errorf("StatusCode = %d, want: %d", got, test.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestActivationHandlerRetry(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name     string
+		dests    []string
+		body     io.Reader
+		wantCode int
+		wantHits []string
+	}{{
+		name:     "retried on another pod",
+		dests:    []string{"refusing:80", "serving:80"},
+		wantCode: http.StatusOK,
+		wantHits: []string{"refusing:80", "serving:80"},
+	}, {
+		name:     "retried with body",
+		dests:    []string{"refusing:80", "serving:80"},
+		body:     bytes.NewBufferString(wantBody),
+		wantCode: http.StatusOK,
+		wantHits: []string{"refusing:80", "serving:80"},
+	}, {
+		name:     "all pods refusing",
+		dests:    []string{"refusing:80", "refusing-too:80"},
+		wantCode: http.StatusBadGateway,
+		wantHits: []string{"refusing:80", "refusing-too:80"},
+	}, {
+		name:     "not retried once the body was sent",
+		dests:    []string{"reading:80", "serving:80"},
+		body:     bytes.NewBufferString(wantBody),
+		wantCode: http.StatusBadGateway,
+		wantHits: []string{"reading:80"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var hits []string
+			rt := pkgnet.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				hits = append(hits, r.URL.Host)
+				switch {
+				case strings.HasPrefix(r.URL.Host, "refusing"):
+					return nil, refused
+				case r.URL.Host == "reading:80":
+					io.Copy(ioutil.Discard, r.Body)
+					return nil, errors.New("connection reset by peer")
+				}
+				if test.body != nil {
+					if body, _ := ioutil.ReadAll(r.Body); string(body) != wantBody {
+						t.Errorf("Body = %q, want: %q", body, wantBody)
+					}
+				}
+				return httptest.NewRecorder().Result(), nil
+			})
+
+			ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+			defer cancel()
+			handler := New(ctx, retryingThrottler{dests: test.dests}, rt, nil /*mtlsTransport*/, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx))
+
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://example.com", test.body)
+			ctx = setupConfigStore(t, logging.FromContext(ctx)).ToContext(req.Context())
+			ctx = WithRevisionAndID(ctx, nil, types.NamespacedName{Namespace: testNamespace, Name: testRevName})
+			handler.ServeHTTP(writer, req.WithContext(ctx))
+
+			if got, want := writer.Code, test.wantCode; got != want {
+				t.Errorf("StatusCode = %d, want: %d", got, want)
+			}
+			if !cmp.Equal(hits, test.wantHits) {
+				t.Errorf("Proxied to %v, want: %v", hits, test.wantHits)
+			}
+		})
+	}
+}
+
+func TestIsRetryableProxyError(t *testing.T) {
+	// A port nothing listens on anymore.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	l.Close()
+	req := httptest.NewRequest(http.MethodGet, "http://"+l.Addr().String(), nil)
+	_, err = http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		t.Fatal("RoundTrip() succeeded, want connection refused")
+	}
+	if !isRetryableProxyError(err) {
+		t.Errorf("isRetryableProxyError(%v) = false, want true", err)
+	}
+	if err := errors.New("unexpected EOF"); isRetryableProxyError(err) {
+		t.Errorf("isRetryableProxyError(%v) = true, want false", err)
+	}
+}
+
+func TestActivationHandlerMTLS(t *testing.T) {
+	tests := []struct {
+		name          string
+		mtlsTransport bool
+		wantCode      int
+		wantURL       string
+	}{{
+		name:          "proxied with mutual TLS",
+		mtlsTransport: true,
+		wantCode:      http.StatusOK,
+		wantURL:       "https://10.10.10.10:8112",
+	}, {
+		name:     "no certificate",
+		wantCode: http.StatusInternalServerError,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := pkgnet.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				t.Errorf("Request proxied in plain text to %s", r.URL)
+				return httptest.NewRecorder().Result(), nil
+			})
+			var gotURL string
+			var mtlsTransport http.RoundTripper
+			if test.mtlsTransport {
+				mtlsTransport = pkgnet.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+					gotURL = r.URL.String()
+					return httptest.NewRecorder().Result(), nil
+				})
+			}
+
+			ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+			defer cancel()
+			handler := New(ctx, fakeThrottler{}, rt, mtlsTransport, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx))
+
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			configStore := setupConfigStore(t, logging.FromContext(ctx))
+			configStore.OnConfigChanged(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: network.ConfigName,
+				},
+				Data: map[string]string{
+					networking.ActivatorMTLSKey: "true",
+				},
+			})
+			ctx = configStore.ToContext(req.Context())
+			ctx = WithRevisionAndID(ctx, nil, types.NamespacedName{Namespace: testNamespace, Name: testRevName})
+			handler.ServeHTTP(writer, req.WithContext(ctx))
+
+			if got, want := writer.Code, test.wantCode; got != want {
+				t.Errorf("StatusCode = %d, want: %d", got, want)
+			}
+			if gotURL != test.wantURL {
+				t.Errorf("Proxied to %q, want: %q", gotURL, test.wantURL)
+			}
+		})
+	}
+}
+
+func TestIsStreamRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{{
+		name: "plain request",
+		header: http.Header{
+			"Accept": []string{"application/json"},
+		},
+	}, {
+		name: "websocket",
+		header: http.Header{
+			"Connection": []string{"Upgrade"},
+			"Upgrade":    []string{"WebSocket"},
+		},
+		want: true,
+	}, {
+		name: "server-sent events",
+		header: http.Header{
+			"Accept": []string{"text/event-stream"},
+		},
+		want: true,
+	}, {
+		name: "grpc",
+		header: http.Header{
+			"Content-Type": []string{"application/grpc+proto"},
+		},
+		want: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req.Header = test.header
+			if got := isStreamRequest(req); got != test.want {
+				t.Errorf("isStreamRequest() = %v, want: %v", got, test.want)
+			}
+		})
+	}
+}
+
 func TestActivationHandlerPassthroughLb(t *testing.T) {
 	interceptCh := make(chan *http.Request, 1)
 	rt := pkgnet.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
@@ -195,7 +584,7 @@ func TestActivationHandlerPassthroughLb(t *testing.T) {
 	ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
 	defer cancel()
 
-	handler := New(ctx, fakeThrottler{}, rt, true /*usePassthroughLb*/, logging.FromContext(ctx))
+	handler := New(ctx, fakeThrottler{}, rt, nil /*mtlsTransport*/, true /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx))
 
 	writer := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
@@ -276,7 +665,7 @@ func TestActivationHandlerTraceSpans(t *testing.T) {
 				oct.Finish()
 			}()
 
-			handler := New(ctx, fakeThrottler{}, rt, false /*usePassthroughLb*/, logging.FromContext(ctx))
+			handler := New(ctx, fakeThrottler{}, rt, nil /*mtlsTransport*/, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx))
 
 			// Set up config store to populate context.
 			configStore := setupConfigStore(t, logging.FromContext(ctx))
@@ -345,7 +734,7 @@ func BenchmarkHandler(b *testing.B) {
 			}, nil
 		})
 
-		handler := New(ctx, fakeThrottler{}, rt, false /*usePassthroughLb*/, logging.FromContext(ctx))
+		handler := New(ctx, fakeThrottler{}, rt, nil /*mtlsTransport*/, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx))
 
 		request := func() *http.Request {
 			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
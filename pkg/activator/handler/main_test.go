@@ -46,7 +46,7 @@ func BenchmarkHandlerChain(b *testing.B) {
 
 	// Buffer equal to the activator.
 	statCh := make(chan []asmetrics.StatMessage)
-	concurrencyReporter := NewConcurrencyReporter(ctx, activatorPodName, statCh)
+	concurrencyReporter := NewConcurrencyReporter(ctx, activatorPodName, statCh, nil)
 	go concurrencyReporter.Run(ctx.Done())
 
 	// Just read and ignore all stat messages.
@@ -69,7 +69,7 @@ func BenchmarkHandlerChain(b *testing.B) {
 	})
 
 	// Make sure to update this if the activator's main file changes.
-	ah := New(ctx, fakeThrottler{}, rt, false, logger)
+	ah := New(ctx, fakeThrottler{}, rt, nil, false, nil, logger)
 	ah = concurrencyReporter.Handler(ah)
 	ah = NewTracingHandler(ah)
 	ah, _ = pkghttp.NewRequestLogHandler(ah, io.Discard, "", nil, false)
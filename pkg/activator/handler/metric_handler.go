@@ -20,11 +20,14 @@ import (
 	"net/http"
 	"time"
 
+	"go.opencensus.io/tag"
 	pkgmetrics "knative.dev/pkg/metrics"
 	"knative.dev/serving/pkg/activator"
+	activatorconfig "knative.dev/serving/pkg/activator/config"
 	"knative.dev/serving/pkg/apis/serving"
 	pkghttp "knative.dev/serving/pkg/http"
 	"knative.dev/serving/pkg/metrics"
+	"knative.dev/serving/pkg/queue"
 )
 
 // NewMetricHandler creates a handler that collects and reports request metrics.
@@ -45,21 +48,66 @@ func (h *MetricHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	rev := RevisionFrom(r.Context())
 	reporterCtx, _ := metrics.PodRevisionContext(h.podName, activator.Name,
 		rev.Namespace, rev.Labels[serving.ServiceLabelKey], rev.Labels[serving.ConfigurationLabelKey], rev.Name)
+	reporterCtx = metrics.AugmentWithRoute(reporterCtx,
+		r.Header.Get(activator.RouteHeaderName), queue.GetRouteTagNameFromRequest(r))
 
 	start := time.Now()
+	ctx, lat := withLatencies(r.Context())
 
 	rr := pkghttp.NewResponseRecorder(w, http.StatusOK)
 	defer func() {
 		err := recover()
 		latency := time.Since(start)
+		// The latencies link to the trace of the request, if it's sampled.
+		exemplar := metrics.ExemplarOptions(lat.span)
 		if err != nil {
 			reporterCtx := metrics.AugmentWithResponse(reporterCtx, http.StatusInternalServerError)
-			pkgmetrics.RecordBatch(reporterCtx, responseTimeInMsecM.M(float64(latency.Milliseconds())), requestCountM.M(1))
+			pkgmetrics.Record(reporterCtx, requestCountM.M(1))
+			pkgmetrics.Record(reporterCtx, responseTimeInMsecM.M(float64(latency.Milliseconds())), exemplar...)
 			panic(err)
 		}
 		reporterCtx := metrics.AugmentWithResponse(reporterCtx, rr.ResponseCode)
-		pkgmetrics.RecordBatch(reporterCtx, responseTimeInMsecM.M(float64(latency.Milliseconds())), requestCountM.M(1))
+		pkgmetrics.Record(reporterCtx, requestCountM.M(1))
+		pkgmetrics.Record(reporterCtx, responseTimeInMsecM.M(float64(latency.Milliseconds())), exemplar...)
+		if lat.proxied {
+			pkgmetrics.Record(reporterCtx, proxyTimeInMsecM.M(float64(lat.proxy.Milliseconds())), exemplar...)
+		}
+		if lat.wait > 0 {
+			pkgmetrics.Record(reporterCtx, waitTimeInMsecM.M(float64(lat.wait.Milliseconds())), exemplar...)
+		}
+		if cs := lat.coldStart; cs != nil {
+			for i, phase := range cs.phases {
+				pkgmetrics.Record(reporterCtx, phase.measure.M(float64(cs.durations[i].Milliseconds())), exemplar...)
+			}
+		}
 	}()
 
-	h.nextHandler.ServeHTTP(rr, r)
+	h.nextHandler.ServeHTTP(rr, r.WithContext(ctx))
+}
+
+// NewPlaintextMetricHandler creates a handler that counts the requests that
+// fell back to plain text on their hop from the ingress, once internal
+// encryption is enabled. It must run within the ContextHandler.
+func NewPlaintextMetricHandler(podName string, next http.Handler) *PlaintextMetricHandler {
+	return &PlaintextMetricHandler{
+		nextHandler: next,
+		podName:     podName,
+	}
+}
+
+// PlaintextMetricHandler is a handler that records the plain text fallbacks.
+type PlaintextMetricHandler struct {
+	podName     string
+	nextHandler http.Handler
+}
+
+func (h *PlaintextMetricHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if itls := activatorconfig.FromContext(r.Context()).InternalTLS; r.TLS == nil && itls != nil && itls.InternalEncryption {
+		rev := RevisionFrom(r.Context())
+		reporterCtx, _ := metrics.PodRevisionContext(h.podName, activator.Name,
+			rev.Namespace, rev.Labels[serving.ServiceLabelKey], rev.Labels[serving.ConfigurationLabelKey], rev.Name)
+		reporterCtx, _ = tag.New(reporterCtx, tag.Upsert(metrics.HopKey, metrics.HopIngressActivator))
+		pkgmetrics.Record(reporterCtx, plaintextRequestCountM.M(1))
+	}
+	h.nextHandler.ServeHTTP(w, r)
 }
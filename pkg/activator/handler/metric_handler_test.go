@@ -19,19 +19,29 @@ package handler
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"testing"
+	"time"
 
 	"go.opencensus.io/resource"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	network "knative.dev/networking/pkg"
+	"knative.dev/pkg/logging"
 	"knative.dev/pkg/metrics/metricstest"
 	_ "knative.dev/pkg/metrics/testing"
+	pkgnet "knative.dev/pkg/network"
+	rtesting "knative.dev/pkg/reconciler/testing"
 	"knative.dev/serving/pkg/activator"
+	activatortest "knative.dev/serving/pkg/activator/testing"
 	"knative.dev/serving/pkg/apis/serving"
 	"knative.dev/serving/pkg/metrics"
+	"knative.dev/serving/pkg/networking"
 )
 
 func TestRequestMetricHandler(t *testing.T) {
@@ -45,13 +55,43 @@ func TestRequestMetricHandler(t *testing.T) {
 		newHeader   map[string]string
 		wantCode    int
 		wantPanic   bool
+		wantRoute   string
+		wantTag     string
 	}{
 		{
 			label: "normal response",
 			baseHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK)
 			}),
-			wantCode: http.StatusOK,
+			wantCode:  http.StatusOK,
+			wantRoute: metrics.ValueUnknown,
+			wantTag:   "DISABLED",
+		},
+		{
+			label: "tagged route",
+			baseHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}),
+			newHeader: map[string]string{
+				activator.RouteHeaderName: "real-route",
+				network.TagHeaderName:     "canary",
+			},
+			wantCode:  http.StatusOK,
+			wantRoute: "real-route",
+			wantTag:   "canary",
+		},
+		{
+			label: "default route",
+			baseHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}),
+			newHeader: map[string]string{
+				activator.RouteHeaderName:      "real-route",
+				network.DefaultRouteHeaderName: "true",
+			},
+			wantCode:  http.StatusOK,
+			wantRoute: "real-route",
+			wantTag:   "DEFAULT",
 		},
 		{
 			label: "panic response",
@@ -61,6 +101,8 @@ func TestRequestMetricHandler(t *testing.T) {
 			}),
 			wantCode:  http.StatusBadRequest,
 			wantPanic: true,
+			wantRoute: metrics.ValueUnknown,
+			wantTag:   "DISABLED",
 		},
 	}
 
@@ -108,6 +150,8 @@ func TestRequestMetricHandler(t *testing.T) {
 					metrics.LabelContainerName:     activator.Name,
 					metrics.LabelResponseCode:      strconv.Itoa(labelCode),
 					metrics.LabelResponseCodeClass: strconv.Itoa(labelCode/100) + "xx",
+					metrics.LabelRouteName:         test.wantRoute,
+					metrics.LabelRouteTag:          test.wantTag,
 				}
 
 				metricstest.AssertMetric(t, metricstest.IntMetric(requestCountM.Name(), 1, wantTags).WithResource(wantResource))
@@ -120,8 +164,105 @@ func TestRequestMetricHandler(t *testing.T) {
 	}
 }
 
+type slowThrottler struct {
+	delay time.Duration
+}
+
+func (st slowThrottler) Try(_ context.Context, _ types.NamespacedName, f func(string) error) error {
+	time.Sleep(st.delay)
+	return f("10.10.10.10:1234")
+}
+
+func TestMetricHandlerWaitAndProxyLatencies(t *testing.T) {
+	tests := []struct {
+		name      string
+		throttler Throttler
+		wantWait  bool
+	}{{
+		name:      "warm",
+		throttler: fakeThrottler{},
+	}, {
+		name:      "cold",
+		throttler: slowThrottler{delay: 20 * time.Millisecond},
+		wantWait:  true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer reset()
+			ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+			defer cancel()
+
+			fakeRT := activatortest.FakeRoundTripper{
+				RequestResponse: &activatortest.FakeResponse{
+					Code: http.StatusOK,
+					Body: wantBody,
+				},
+			}
+			handler := NewMetricHandler("testPod",
+				New(ctx, test.throttler, pkgnet.RoundTripperFunc(fakeRT.RT), nil /*mtlsTransport*/, false /*usePassthroughLb*/, nil /*coldStarts*/, logging.FromContext(ctx)))
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			reqCtx := setupConfigStore(t, logging.FromContext(ctx)).ToContext(req.Context())
+			reqCtx = WithRevisionAndID(reqCtx, revision(testNamespace, testRevName), types.NamespacedName{Namespace: testNamespace, Name: testRevName})
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req.WithContext(reqCtx))
+			if got, want := resp.Code, http.StatusOK; got != want {
+				t.Fatalf("Response Status = %d, want: %d", got, want)
+			}
+
+			metricstest.AssertMetricExists(t, responseTimeInMsecM.Name(), proxyTimeInMsecM.Name())
+			if test.wantWait {
+				metricstest.AssertMetricExists(t, waitTimeInMsecM.Name())
+			} else {
+				metricstest.AssertNoMetric(t, waitTimeInMsecM.Name())
+			}
+		})
+	}
+}
+
+func TestPlaintextMetricHandler(t *testing.T) {
+	defer reset()
+	ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+	t.Cleanup(cancel)
+	configStore := setupConfigStore(t, logging.FromContext(ctx))
+	configStore.OnConfigChanged(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: network.ConfigName},
+		Data:       map[string]string{networking.InternalEncryptionKey: "true"},
+	})
+	handler := NewPlaintextMetricHandler("testPod", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for _, conn := range []*tls.ConnectionState{nil, {}, nil} {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		req.TLS = conn
+		reqCtx := configStore.ToContext(req.Context())
+		reqCtx = WithRevisionAndID(reqCtx, revision(testNamespace, testRevName), types.NamespacedName{Namespace: testNamespace, Name: testRevName})
+		handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(reqCtx))
+	}
+
+	wantTags := map[string]string{
+		metrics.LabelPodName:       "testPod",
+		metrics.LabelContainerName: activator.Name,
+		metrics.LabelHop:           metrics.HopIngressActivator,
+	}
+	wantResource := &resource.Resource{
+		Type: "knative_revision",
+		Labels: map[string]string{
+			metrics.LabelNamespaceName:     testNamespace,
+			metrics.LabelRevisionName:      testRevName,
+			metrics.LabelConfigurationName: "config-" + testRevName,
+			metrics.LabelServiceName:       "service-" + testRevName,
+		},
+	}
+	metricstest.AssertMetric(t, metricstest.IntMetric("plaintext_request_count", 2, wantTags).WithResource(wantResource))
+}
+
 func reset() {
-	metricstest.Unregister(requestConcurrencyM.Name(), requestCountM.Name(), responseTimeInMsecM.Name())
+	metricstest.Unregister(requestConcurrencyM.Name(), requestCountM.Name(), responseTimeInMsecM.Name(),
+		waitTimeInMsecM.Name(), proxyTimeInMsecM.Name(), spillCountM.Name(), spillBytesM.Name(),
+		spillFullCountM.Name(), spillUsedBytesM.Name(), plaintextRequestCountM.Name(),
+		coldStartActivatorQueueM.Name(), coldStartScheduleM.Name(), coldStartImagePullM.Name(),
+		coldStartContainerStartM.Name(), coldStartReadinessM.Name(), coldStartFirstByteM.Name())
 	register()
 }
 
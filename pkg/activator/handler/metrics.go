@@ -38,6 +38,58 @@ var (
 		"request_latencies",
 		"The response time in millisecond",
 		stats.UnitMilliseconds)
+	waitTimeInMsecM = stats.Float64(
+		"request_wait_latencies",
+		"The time in millisecond requests waited for capacity, e.g. for a scale from zero",
+		stats.UnitMilliseconds)
+	proxyTimeInMsecM = stats.Float64(
+		"request_proxy_latencies",
+		"The time in millisecond it took to proxy requests to the revision",
+		stats.UnitMilliseconds)
+	spillCountM = stats.Int64(
+		"request_body_spill_count",
+		"The number of requests whose body spilled to the disk buffer",
+		stats.UnitDimensionless)
+	spillBytesM = stats.Int64(
+		"request_body_spill_bytes",
+		"The bytes of request bodies spilled to the disk buffer",
+		stats.UnitBytes)
+	spillFullCountM = stats.Int64(
+		"request_body_spill_full_count",
+		"The number of times a request body couldn't spill as the disk buffer was full",
+		stats.UnitDimensionless)
+	spillUsedBytesM = stats.Int64(
+		"request_body_spill_used_bytes",
+		"The bytes of the disk buffer in use by request bodies",
+		stats.UnitBytes)
+	plaintextRequestCountM = stats.Int64(
+		"plaintext_request_count",
+		"The number of requests that reached Activator in plain text although internal encryption is enabled",
+		stats.UnitDimensionless)
+	coldStartActivatorQueueM = stats.Float64(
+		"cold_start_activator_queue_latencies",
+		"The time in millisecond cold-started requests waited in the activator for a pod",
+		stats.UnitMilliseconds)
+	coldStartScheduleM = stats.Float64(
+		"cold_start_schedule_latencies",
+		"The time in millisecond the pods serving cold-started requests took to be scheduled",
+		stats.UnitMilliseconds)
+	coldStartImagePullM = stats.Float64(
+		"cold_start_image_pull_latencies",
+		"The time in millisecond the pods serving cold-started requests took from being scheduled to starting the user container, i.e. to pull its image and create it",
+		stats.UnitMilliseconds)
+	coldStartContainerStartM = stats.Float64(
+		"cold_start_container_start_latencies",
+		"The time in millisecond the user containers serving cold-started requests took from starting to passing their readiness probe",
+		stats.UnitMilliseconds)
+	coldStartReadinessM = stats.Float64(
+		"cold_start_readiness_latencies",
+		"The time in millisecond it took the readiness of the pods serving cold-started requests to reach the activator",
+		stats.UnitMilliseconds)
+	coldStartFirstByteM = stats.Float64(
+		"cold_start_first_byte_latencies",
+		"The time in millisecond the pods took to respond to cold-started requests once proxied",
+		stats.UnitMilliseconds)
 
 	// NOTE: 0 should not be used as boundary. See
 	// https://github.com/census-ecosystem/opencensus-go-exporter-stackdriver/issues/98
@@ -63,13 +115,91 @@ func register() {
 			Description: "The number of requests that are routed to Activator",
 			Measure:     requestCountM,
 			Aggregation: view.Count(),
-			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.ResponseCodeKey, metrics.ResponseCodeClassKey},
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.ResponseCodeKey, metrics.ResponseCodeClassKey, metrics.RouteKey, metrics.RouteTagKey},
 		},
 		&view.View{
 			Description: "The response time in millisecond",
 			Measure:     responseTimeInMsecM,
 			Aggregation: defaultLatencyDistribution,
-			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.ResponseCodeKey, metrics.ResponseCodeClassKey},
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.ResponseCodeKey, metrics.ResponseCodeClassKey, metrics.RouteKey, metrics.RouteTagKey},
+		},
+		&view.View{
+			Description: "The time in millisecond requests waited for capacity, e.g. for a scale from zero",
+			Measure:     waitTimeInMsecM,
+			Aggregation: defaultLatencyDistribution,
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.ResponseCodeKey, metrics.ResponseCodeClassKey, metrics.RouteKey, metrics.RouteTagKey},
+		},
+		&view.View{
+			Description: "The time in millisecond it took to proxy requests to the revision",
+			Measure:     proxyTimeInMsecM,
+			Aggregation: defaultLatencyDistribution,
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.ResponseCodeKey, metrics.ResponseCodeClassKey, metrics.RouteKey, metrics.RouteTagKey},
+		},
+		&view.View{
+			Description: "The number of requests whose body spilled to the disk buffer",
+			Measure:     spillCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey},
+		},
+		&view.View{
+			Description: "The bytes of request bodies spilled to the disk buffer",
+			Measure:     spillBytesM,
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey},
+		},
+		&view.View{
+			Description: "The number of times a request body couldn't spill as the disk buffer was full",
+			Measure:     spillFullCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey},
+		},
+		&view.View{
+			Description: "The bytes of the disk buffer in use by request bodies",
+			Measure:     spillUsedBytesM,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey},
+		},
+		&view.View{
+			Description: "The number of requests that reached Activator in plain text although internal encryption is enabled",
+			Measure:     plaintextRequestCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.HopKey},
+		},
+		&view.View{
+			Description: "The time in millisecond cold-started requests waited in the activator for a pod",
+			Measure:     coldStartActivatorQueueM,
+			Aggregation: defaultLatencyDistribution,
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.RouteKey, metrics.RouteTagKey},
+		},
+		&view.View{
+			Description: "The time in millisecond the pods serving cold-started requests took to be scheduled",
+			Measure:     coldStartScheduleM,
+			Aggregation: defaultLatencyDistribution,
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.RouteKey, metrics.RouteTagKey},
+		},
+		&view.View{
+			Description: "The time in millisecond the pods serving cold-started requests took from being scheduled to starting the user container, i.e. to pull its image and create it",
+			Measure:     coldStartImagePullM,
+			Aggregation: defaultLatencyDistribution,
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.RouteKey, metrics.RouteTagKey},
+		},
+		&view.View{
+			Description: "The time in millisecond the user containers serving cold-started requests took from starting to passing their readiness probe",
+			Measure:     coldStartContainerStartM,
+			Aggregation: defaultLatencyDistribution,
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.RouteKey, metrics.RouteTagKey},
+		},
+		&view.View{
+			Description: "The time in millisecond it took the readiness of the pods serving cold-started requests to reach the activator",
+			Measure:     coldStartReadinessM,
+			Aggregation: defaultLatencyDistribution,
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.RouteKey, metrics.RouteTagKey},
+		},
+		&view.View{
+			Description: "The time in millisecond the pods took to respond to cold-started requests once proxied",
+			Measure:     coldStartFirstByteM,
+			Aggregation: defaultLatencyDistribution,
+			TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.RouteKey, metrics.RouteTagKey},
 		},
 	); err != nil {
 		panic(err)
@@ -0,0 +1,39 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+
+	activatorconfig "knative.dev/serving/pkg/activator/config"
+	pkghttp "knative.dev/serving/pkg/http"
+)
+
+// NewRequestIDHandler creates a wrapper around pkghttp.NewRequestIDHandler
+// using the request ID settings of the Activator's configuration, so the
+// requests carry their ID on to the queue-proxy. Requests are passed on
+// unchanged if request IDs are disabled or not configured.
+func NewRequestIDHandler(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := activatorconfig.FromContext(r.Context()).RequestID
+		if cfg == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		pkghttp.NewRequestIDHandler(next, cfg.Header, cfg.Generator).ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	network "knative.dev/networking/pkg"
+	"knative.dev/pkg/logging"
+	rtesting "knative.dev/pkg/reconciler/testing"
+	activatorconfig "knative.dev/serving/pkg/activator/config"
+	pkghttp "knative.dev/serving/pkg/http"
+)
+
+func TestRequestIDHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       map[string]string
+		incoming   string
+		wantHeader string
+		wantID     func(string) bool
+	}{{
+		name:       "generated",
+		data:       map[string]string{},
+		wantHeader: pkghttp.DefaultRequestIDHeader,
+		wantID:     func(id string) bool { return len(id) == len("01890a5d-ac96-774b-bcce-b302099a8057") },
+	}, {
+		name:       "propagated",
+		data:       map[string]string{pkghttp.RequestIDHeaderKey: "X-Correlation-Id"},
+		incoming:   "incoming",
+		wantHeader: "X-Correlation-Id",
+		wantID:     func(id string) bool { return id == "incoming" },
+	}, {
+		name:   "disabled",
+		data:   map[string]string{pkghttp.RequestIDHeaderKey: ""},
+		wantID: func(id string) bool { return id == "" },
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+			defer cancel()
+
+			configStore := activatorconfig.NewStore(logging.FromContext(ctx))
+			configStore.OnConfigChanged(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: network.ConfigName,
+				},
+				Data: test.data,
+			})
+			ctx = configStore.ToContext(ctx)
+
+			var gotID string
+			handler := NewRequestIDHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotID = pkghttp.RequestIDFromContext(r.Context())
+			}))
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
+			if test.incoming != "" {
+				req.Header.Set(test.wantHeader, test.incoming)
+			}
+			handler.ServeHTTP(resp, req.WithContext(ctx))
+
+			if !test.wantID(gotID) {
+				t.Errorf("Request ID = %q, not as expected", gotID)
+			}
+			if test.wantHeader != "" {
+				if got := resp.Header().Get(test.wantHeader); got != gotID {
+					t.Errorf("Response %s = %q, want %q", test.wantHeader, got, gotID)
+				}
+			}
+		})
+	}
+}
@@ -19,15 +19,28 @@ package handler
 import (
 	"net/http"
 
-	"knative.dev/pkg/tracing"
+	"go.opencensus.io/trace"
+	ocpropagation "go.opencensus.io/trace/propagation"
 	tracingconfig "knative.dev/pkg/tracing/config"
 	activatorconfig "knative.dev/serving/pkg/activator/config"
+	"knative.dev/serving/pkg/apis/serving"
+	pkghttp "knative.dev/serving/pkg/http"
 )
 
-// NewTracingHandler creates a wrapper around tracing.HTTPSpanMiddleware that completely
+// NewTracingHandler creates a wrapper around pkghttp.NewTracingHandler that completely
 // bypasses said handler when tracing is disabled via the Activator's configuration.
+// The traces of the revisions with a sample rate annotation are sampled at that rate.
+// The span of the request is passed on to the MetricHandler, if any, for the
+// latencies to link to its trace.
 func NewTracingHandler(next http.Handler) http.HandlerFunc {
-	tracingHandler := tracing.HTTPSpanMiddleware(next)
+	tracingHandler := pkghttp.NewTracingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lat := latenciesFrom(r.Context()); lat != nil {
+			if span := trace.FromContext(r.Context()); span != nil {
+				lat.span = span.SpanContext()
+			}
+		}
+		next.ServeHTTP(w, r)
+	}), configPropagation{}, revisionSampler)
 	return func(w http.ResponseWriter, r *http.Request) {
 		tracingEnabled := activatorconfig.FromContext(r.Context()).Tracing.Backend != tracingconfig.None
 		if !tracingEnabled {
@@ -38,3 +51,46 @@ func NewTracingHandler(next http.Handler) http.HandlerFunc {
 		tracingHandler.ServeHTTP(w, r)
 	}
 }
+
+// revisionSampler returns the sampler of the rate of the revision of r, if
+// it's annotated with one.
+func revisionSampler(r *http.Request) trace.Sampler {
+	rev := RevisionFrom(r.Context())
+	if rev == nil {
+		return nil
+	}
+	v, ok := rev.Annotations[serving.TracingSampleRateAnnotation]
+	if !ok {
+		return nil
+	}
+	rate, err := pkghttp.ParseTraceSampleRate(v)
+	if err != nil {
+		// The annotation is validated by the webhook.
+		return nil
+	}
+	return trace.ProbabilitySampler(rate)
+}
+
+// configPropagation reads and writes the trace context in the formats of the
+// configuration of the request's context, so they're updated with
+// config-tracing.
+type configPropagation struct{}
+
+var _ ocpropagation.HTTPFormat = configPropagation{}
+
+func (configPropagation) format(r *http.Request) ocpropagation.HTTPFormat {
+	if tp := activatorconfig.FromContext(r.Context()).TracePropagation; tp != nil {
+		return tp.Propagation.HTTPFormat()
+	}
+	return pkghttp.TracePropagationTraceContextB3.HTTPFormat()
+}
+
+// SpanContextFromRequest implements ocpropagation.HTTPFormat.
+func (p configPropagation) SpanContextFromRequest(r *http.Request) (trace.SpanContext, bool) {
+	return p.format(r).SpanContextFromRequest(r)
+}
+
+// SpanContextToRequest implements ocpropagation.HTTPFormat.
+func (p configPropagation) SpanContextToRequest(sc trace.SpanContext, r *http.Request) {
+	p.format(r).SpanContextToRequest(sc, r)
+}
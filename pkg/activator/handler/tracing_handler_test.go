@@ -17,30 +17,43 @@ limitations under the License.
 package handler
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"go.opencensus.io/trace"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"knative.dev/pkg/logging"
 	rtesting "knative.dev/pkg/reconciler/testing"
 	"knative.dev/pkg/tracing"
 	"knative.dev/pkg/tracing/config"
 	tracetesting "knative.dev/pkg/tracing/testing"
 	activatorconfig "knative.dev/serving/pkg/activator/config"
+	"knative.dev/serving/pkg/apis/serving"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	pkghttp "knative.dev/serving/pkg/http"
 )
 
 func TestTracingHandler(t *testing.T) {
 	tests := []struct {
 		name           string
 		tracingEnabled bool
+		sampleRate     string
+		wantSpans      int
 	}{{
 		name:           "enabled",
 		tracingEnabled: true,
+		wantSpans:      1,
 	}, {
 		name:           "disabled",
 		tracingEnabled: false,
+	}, {
+		name:           "revision sample rate",
+		tracingEnabled: true,
+		sampleRate:     "0",
 	}}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -77,26 +90,81 @@ func TestTracingHandler(t *testing.T) {
 				t.Error("Failed to apply tracer config:", err)
 			}
 
+			rev := &v1.Revision{}
+			if test.sampleRate != "" {
+				rev.Annotations = map[string]string{serving.TracingSampleRateAnnotation: test.sampleRate}
+			}
+			ctx = WithRevisionAndID(ctx, rev, types.NamespacedName{Namespace: testNamespace, Name: testRevName})
+			ctx, lat := withLatencies(ctx)
 			handler.ServeHTTP(resp, req.WithContext(ctx))
 
 			spans := reporter.Flush()
 
-			if test.tracingEnabled {
-				if len(spans) != 1 {
-					t.Errorf("Got %d spans, expected 1: spans = %v", len(spans), spans)
-				}
+			if len(spans) != test.wantSpans {
+				t.Fatalf("Got %d spans, expected %d: spans = %v", len(spans), test.wantSpans, spans)
+			}
+			if test.wantSpans > 0 {
 				if got := spans[0].TraceID.String(); got != traceID {
 					t.Errorf("spans[0].TraceID = %s, want %s", got, traceID)
 				}
+				if got := lat.span.TraceID.String(); got != traceID {
+					t.Errorf("latencies.span.TraceID = %s, want %s", got, traceID)
+				}
 			} else {
-				if len(spans) != 0 {
-					t.Errorf("Got %d spans, expected 0: spans = %v", len(spans), spans)
+				if lat.span.IsSampled() {
+					t.Error("latencies.span is set while tracing is disabled")
 				}
 			}
 		})
 	}
 }
 
+func TestConfigPropagation(t *testing.T) {
+	sc := trace.SpanContext{
+		TraceID:      trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:       trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceOptions: 1,
+	}
+	tests := []struct {
+		propagation string
+		wantW3C     bool
+		wantB3      bool
+	}{{
+		propagation: "",
+		wantW3C:     true,
+		wantB3:      true,
+	}, {
+		propagation: "tracecontext",
+		wantW3C:     true,
+	}, {
+		propagation: "b3",
+		wantB3:      true,
+	}}
+	for _, test := range tests {
+		t.Run(test.propagation, func(t *testing.T) {
+			cm := tracingConfig(true)
+			if test.propagation != "" {
+				cm.Data[pkghttp.TracePropagationKey] = test.propagation
+			}
+			configStore := activatorconfig.NewStore(logging.FromContext(context.Background()))
+			configStore.OnConfigChanged(cm)
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req = req.WithContext(configStore.ToContext(req.Context()))
+
+			configPropagation{}.SpanContextToRequest(sc, req)
+			if got := req.Header.Get("traceparent") != ""; got != test.wantW3C {
+				t.Errorf("traceparent set = %v, want: %v", got, test.wantW3C)
+			}
+			if got := req.Header.Get("X-B3-Traceid") != ""; got != test.wantB3 {
+				t.Errorf("X-B3-Traceid set = %v, want: %v", got, test.wantB3)
+			}
+			if got, ok := (configPropagation{}).SpanContextFromRequest(req); !ok || got.TraceID != sc.TraceID {
+				t.Errorf("SpanContextFromRequest() = %v, %v, want: %v", got, ok, sc)
+			}
+		})
+	}
+}
+
 func tracingConfig(enabled bool) *corev1.ConfigMap {
 	cm := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -21,7 +21,10 @@ package net
 import (
 	"context"
 	"math/rand"
+	"sort"
 	"sync"
+
+	"knative.dev/serving/pkg/apis/serving"
 )
 
 // lbPolicy is a functor that selects a target pod from the list, or (noop, nil) if
@@ -40,14 +43,16 @@ func randomLBPolicy(_ context.Context, targets []*podTracker) (func(), *podTrack
 }
 
 // randomChoice2Policy implements the Power of 2 choices LB algorithm
-func randomChoice2Policy(_ context.Context, targets []*podTracker) (func(), *podTracker) {
+func randomChoice2Policy(ctx context.Context, targets []*podTracker) (func(), *podTracker) {
 	// Avoid random if possible.
 	l := len(targets)
 	// One tracker = no choice.
 	if l == 1 {
 		pick := targets[0]
-		pick.increaseWeight()
-		return pick.decreaseWeight, pick
+		if cb, ok := reserveWeighted(ctx, pick); ok {
+			return cb, pick
+		}
+		return noop, nil
 	}
 	r1, r2 := 0, 1
 	// Two trackers - we know both contestants,
@@ -63,13 +68,83 @@ func randomChoice2Policy(_ context.Context, targets []*podTracker) (func(), *pod
 	}
 
 	pick, alt := targets[r1], targets[r2]
-	// Possible race here, but this policy is for CC=0,
-	// so fine.
+	// Possible race here, but the weights only guide the choice, the
+	// capacity of the trackers, if any, is reserved.
 	if pick.getWeight() > alt.getWeight() {
-		pick = alt
+		pick, alt = alt, pick
+	}
+	if cb, ok := reserveWeighted(ctx, pick); ok {
+		return cb, pick
+	}
+	if cb, ok := reserveWeighted(ctx, alt); ok {
+		return cb, alt
+	}
+	return noop, nil
+}
+
+// reserveWeighted reserves a slot of the target, if it has a breaker, and
+// increases its weight, returning the function undoing both, or false if the
+// target has no capacity left.
+func reserveWeighted(ctx context.Context, t *podTracker) (func(), bool) {
+	if t.b == nil {
+		t.increaseWeight()
+		return t.decreaseWeight, true
+	}
+	cb, ok := t.b.Reserve(ctx)
+	if !ok {
+		return nil, false
+	}
+	t.increaseWeight()
+	return func() {
+		t.decreaseWeight()
+		cb()
+	}, true
+}
+
+// leastInflightPolicy is a load balancer policy that picks the target with the
+// least requests in flight, among those with capacity left.
+func leastInflightPolicy(ctx context.Context, targets []*podTracker) (func(), *podTracker) {
+	var pick *podTracker
+	for _, t := range targets {
+		w := t.getWeight()
+		// The streams take capacity too, they're just not weighted.
+		if t.b != nil && int(w+t.streams.Load()) >= t.Capacity() {
+			continue
+		}
+		if pick == nil || w < pick.getWeight() {
+			pick = t
+		}
+	}
+	if pick == nil {
+		return noop, nil
+	}
+	if cb, ok := reserveWeighted(ctx, pick); ok {
+		return cb, pick
+	}
+	return noop, nil
+}
+
+// leastStreamsPolicy is the load balancer policy of the streams, see
+// WithStream. It picks the target with the least streams among those with
+// capacity left, regardless of the other requests in flight, so that the
+// long-lived streams spread evenly, and doesn't weigh the target, so that the
+// streams don't skew the balancing of the other requests.
+func leastStreamsPolicy(ctx context.Context, targets []*podTracker) (func(), *podTracker) {
+	ranked := make([]*podTracker, len(targets))
+	copy(ranked, targets)
+	streams := make(map[*podTracker]int32, len(ranked))
+	for _, t := range ranked {
+		streams[t] = t.streams.Load()
 	}
-	pick.increaseWeight()
-	return pick.decreaseWeight, pick
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return streams[ranked[i]] < streams[ranked[j]]
+	})
+	for _, t := range ranked {
+		if cb, ok := t.Reserve(ctx); ok {
+			return cb, t
+		}
+	}
+	return noop, nil
 }
 
 // firstAvailableLBPolicy is a load balancer policy, that picks the first target
@@ -111,3 +186,111 @@ func newRoundRobinPolicy() lbPolicy {
 		return noop, nil
 	}
 }
+
+// consistentHashPolicy is a load balancer policy that picks the same target
+// for the same hash key, see WithLBHashKey, as long as it's assigned and has
+// capacity, by rendezvous hashing. Requests without a key are balanced by
+// leastInflightPolicy.
+func consistentHashPolicy(ctx context.Context, targets []*podTracker) (func(), *podTracker) {
+	key := lbHashKeyFrom(ctx)
+	if key == "" {
+		return leastInflightPolicy(ctx, targets)
+	}
+	// The targets by descending score, so that the next in line takes the
+	// request if the target of the key has no capacity left.
+	ranked := make([]*podTracker, len(targets))
+	copy(ranked, targets)
+	scores := make(map[*podTracker]uint64, len(ranked))
+	for _, t := range ranked {
+		scores[t] = rendezvousScore(key, t.dest)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return scores[ranked[i]] > scores[ranked[j]]
+	})
+	for _, t := range ranked {
+		if cb, ok := reserveWeighted(ctx, t); ok {
+			return cb, t
+		}
+	}
+	return noop, nil
+}
+
+// rendezvousScore returns the score of the destination for the key, the
+// FNV-1a hash of both, mixed so similar destinations score independently.
+func rendezvousScore(key, dest string) uint64 {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+	h := uint64(offset)
+	for i := 0; i < len(key); i++ {
+		h = (h ^ uint64(key[i])) * prime
+	}
+	// Separate the key from the destination, so that "ab"+"c" and "a"+"bc"
+	// differ.
+	h = (h ^ 0xff) * prime
+	for i := 0; i < len(dest); i++ {
+		h = (h ^ uint64(dest[i])) * prime
+	}
+	// The finalizer of SplitMix64.
+	h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+	h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+	return h ^ (h >> 31)
+}
+
+type lbHashKey struct{}
+
+// WithLBHashKey attaches the key the request is consistently hashed to a pod
+// by to ctx, if the revision's load balancing policy is consistent hashing.
+func WithLBHashKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, lbHashKey{}, key)
+}
+
+func lbHashKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(lbHashKey{}).(string)
+	return key
+}
+
+type streamKey struct{}
+
+// WithStream marks the request as a long-lived stream, e.g. a WebSocket
+// connection, so that it's balanced and counted apart from the other
+// requests.
+func WithStream(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamKey{}, struct{}{})
+}
+
+func isStream(ctx context.Context) bool {
+	return ctx.Value(streamKey{}) != nil
+}
+
+// The names of the load balancing policies, as selected by
+// serving.ActivatorLoadBalancingPolicyAnnotation.
+const (
+	randomChoice2PolicyName  = "random-choice-2"
+	leastInflightPolicyName  = "least-inflight"
+	roundRobinPolicyName     = "round-robin"
+	firstAvailablePolicyName = "first-available"
+	consistentHashPolicyName = "consistent-hash"
+)
+
+// lbPolicyFromAnnotations returns the load balancing policy selected by
+// serving.ActivatorLoadBalancingPolicyAnnotation and its name, or nil if
+// none is.
+func lbPolicyFromAnnotations(annotations map[string]string) (lbPolicy, string) {
+	name := annotations[serving.ActivatorLoadBalancingPolicyAnnotation]
+	// The annotation is validated by the webhook.
+	switch name {
+	case randomChoice2PolicyName:
+		return randomChoice2Policy, name
+	case leastInflightPolicyName:
+		return leastInflightPolicy, name
+	case roundRobinPolicyName:
+		return newRoundRobinPolicy(), name
+	case firstAvailablePolicyName:
+		return firstAvailableLBPolicy, name
+	case consistentHashPolicyName:
+		return consistentHashPolicy, name
+	}
+	return nil, ""
+}
@@ -22,6 +22,7 @@ import (
 	"testing"
 	"time"
 
+	"knative.dev/serving/pkg/apis/serving"
 	"knative.dev/serving/pkg/queue"
 )
 
@@ -234,6 +235,175 @@ func TestRoundRobin(t *testing.T) {
 	})
 }
 
+func TestRandomChoice2WithCapacity(t *testing.T) {
+	podTrackers := makeTrackers(2, 1)
+	cb, pt := randomChoice2Policy(context.Background(), podTrackers)
+	t.Cleanup(cb)
+	// The other one is picked, whichever is less loaded as per its weight.
+	cb, other := randomChoice2Policy(context.Background(), podTrackers)
+	t.Cleanup(cb)
+	if other == nil || other == pt {
+		t.Fatalf("Tracker = %v, want the other one than %v", other, pt)
+	}
+	// Neither has capacity left.
+	if _, pt := randomChoice2Policy(context.Background(), podTrackers); pt != nil {
+		t.Fatal("Wanted nil, got: ", pt)
+	}
+}
+
+func TestLeastInflight(t *testing.T) {
+	t.Run("without capacity", func(t *testing.T) {
+		podTrackers := makeTrackers(3, 0)
+		podTrackers[0].increaseWeight()
+		podTrackers[2].increaseWeight()
+		cb, pt := leastInflightPolicy(context.Background(), podTrackers)
+		if got, want := pt, podTrackers[1]; got != want {
+			t.Fatalf("Tracker = %v, want: %v", got, want)
+		}
+		if got, want := pt.getWeight(), int32(1); got != want {
+			t.Errorf("pt.weight = %d, want: %d", got, want)
+		}
+		cb()
+		if got, want := pt.getWeight(), int32(0); got != want {
+			t.Errorf("pt.weight = %d, want: %d", got, want)
+		}
+	})
+	t.Run("with cc=1", func(t *testing.T) {
+		podTrackers := makeTrackers(2, 1)
+		cb, pt := leastInflightPolicy(context.Background(), podTrackers)
+		t.Cleanup(cb)
+		if got, want := pt, podTrackers[0]; got != want {
+			t.Fatalf("Tracker = %v, want: %v", got, want)
+		}
+		cb, pt = leastInflightPolicy(context.Background(), podTrackers)
+		if got, want := pt, podTrackers[1]; got != want {
+			t.Fatalf("Tracker = %v, want: %v", got, want)
+		}
+		if _, pt := leastInflightPolicy(context.Background(), podTrackers); pt != nil {
+			t.Fatal("Wanted nil, got: ", pt)
+		}
+		// Releasing the second one makes it available again.
+		cb()
+		cb, pt = leastInflightPolicy(context.Background(), podTrackers)
+		t.Cleanup(cb)
+		if got, want := pt, podTrackers[1]; got != want {
+			t.Fatalf("Tracker = %v, want: %v", got, want)
+		}
+	})
+}
+
+func TestLeastStreams(t *testing.T) {
+	t.Run("without capacity", func(t *testing.T) {
+		podTrackers := makeTrackers(3, 0)
+		podTrackers[0].streams.Store(2)
+		podTrackers[1].streams.Store(1)
+		podTrackers[2].streams.Store(3)
+		// The requests other than the streams don't matter.
+		podTrackers[1].increaseWeight()
+		podTrackers[1].increaseWeight()
+		cb, pt := leastStreamsPolicy(context.Background(), podTrackers)
+		defer cb()
+		if got, want := pt, podTrackers[1]; got != want {
+			t.Fatalf("Tracker = %v, want: %v", got, want)
+		}
+		if got, want := pt.getWeight(), int32(2); got != want {
+			t.Errorf("pt.weight = %d, want: %d, the streams aren't weighted", got, want)
+		}
+	})
+	t.Run("with cc=1", func(t *testing.T) {
+		podTrackers := makeTrackers(2, 1)
+		podTrackers[0].streams.Store(1)
+		cb, pt := leastStreamsPolicy(context.Background(), podTrackers)
+		if got, want := pt, podTrackers[1]; got != want {
+			t.Fatalf("Tracker = %v, want: %v", got, want)
+		}
+		// The pod with the least streams is full, so the next is picked.
+		cb2, pt := leastStreamsPolicy(context.Background(), podTrackers)
+		if got, want := pt, podTrackers[0]; got != want {
+			t.Fatalf("Tracker = %v, want: %v", got, want)
+		}
+		if _, pt := leastStreamsPolicy(context.Background(), podTrackers); pt != nil {
+			t.Fatal("Wanted nil, got: ", pt)
+		}
+		cb()
+		cb2()
+	})
+}
+
+func TestConsistentHash(t *testing.T) {
+	t.Run("same key, same tracker", func(t *testing.T) {
+		podTrackers := makeTrackers(10, 0)
+		ctx := WithLBHashKey(context.Background(), "session-1")
+		cb, want := consistentHashPolicy(ctx, podTrackers)
+		cb()
+		for i := 0; i < 10; i++ {
+			cb, got := consistentHashPolicy(ctx, podTrackers)
+			cb()
+			if got != want {
+				t.Fatalf("Tracker = %v, want: %v", got, want)
+			}
+		}
+		// Removing another tracker doesn't move the key.
+		var rest []*podTracker
+		for _, pt := range podTrackers {
+			if pt != want && len(rest) < 5 {
+				rest = append(rest, pt)
+			}
+		}
+		rest = append(rest, want)
+		cb, got := consistentHashPolicy(ctx, rest)
+		cb()
+		if got != want {
+			t.Errorf("Tracker = %v after removing others, want: %v", got, want)
+		}
+	})
+	t.Run("keys spread", func(t *testing.T) {
+		podTrackers := makeTrackers(4, 0)
+		picked := map[*podTracker]bool{}
+		for i := 0; i < 100; i++ {
+			cb, pt := consistentHashPolicy(WithLBHashKey(context.Background(), fmt.Sprint("session-", i)), podTrackers)
+			cb()
+			picked[pt] = true
+		}
+		if got, want := len(picked), len(podTrackers); got != want {
+			t.Errorf("100 keys were mapped to %d trackers, want: %d", got, want)
+		}
+	})
+	t.Run("next in line when full", func(t *testing.T) {
+		podTrackers := makeTrackers(3, 1)
+		ctx := WithLBHashKey(context.Background(), "session-1")
+		cb, first := consistentHashPolicy(ctx, podTrackers)
+		t.Cleanup(cb)
+		cb, second := consistentHashPolicy(ctx, podTrackers)
+		t.Cleanup(cb)
+		if second == nil || second == first {
+			t.Fatalf("Tracker = %v, want another one than %v", second, first)
+		}
+	})
+	t.Run("no key", func(t *testing.T) {
+		podTrackers := makeTrackers(2, 0)
+		podTrackers[0].increaseWeight()
+		cb, pt := consistentHashPolicy(context.Background(), podTrackers)
+		t.Cleanup(cb)
+		if got, want := pt, podTrackers[1]; got != want {
+			t.Fatalf("Tracker = %v, want the least loaded: %v", got, want)
+		}
+	})
+}
+
+func TestLBPolicyFromAnnotations(t *testing.T) {
+	if lbp, name := lbPolicyFromAnnotations(nil); lbp != nil || name != "" {
+		t.Errorf("lbPolicyFromAnnotations(nil) = %q, want none", name)
+	}
+	for _, name := range []string{randomChoice2PolicyName, leastInflightPolicyName, roundRobinPolicyName,
+		firstAvailablePolicyName, consistentHashPolicyName} {
+		lbp, got := lbPolicyFromAnnotations(map[string]string{serving.ActivatorLoadBalancingPolicyAnnotation: name})
+		if lbp == nil || got != name {
+			t.Errorf("lbPolicyFromAnnotations(%s) = %q, want: %q", name, got, name)
+		}
+	}
+}
+
 func BenchmarkPolicy(b *testing.B) {
 	for _, test := range []struct {
 		name   string
@@ -250,6 +420,9 @@ func BenchmarkPolicy(b *testing.B) {
 	}, {
 		name:   "round-robin",
 		policy: newRoundRobinPolicy(),
+	}, {
+		name:   "least-inflight",
+		policy: leastInflightPolicy,
 	}} {
 		for _, n := range []int{1, 2, 3, 10, 100} {
 			b.Run(fmt.Sprintf("%s-%d-trackers-sequential", test.name, n), func(b *testing.B) {
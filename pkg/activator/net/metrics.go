@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	pkgmetrics "knative.dev/pkg/metrics"
+)
+
+var (
+	podInflightSkewM = stats.Int64(
+		"pod_inflight_skew",
+		"The difference between the most and the least requests in flight to a pod of the revision through the activator",
+		stats.UnitDimensionless)
+	podStreamCountM = stats.Int64(
+		"pod_stream_count",
+		"The number of streams through the activator to a pod of the revision",
+		stats.UnitDimensionless)
+	proxyRetryCountM = stats.Int64(
+		"proxy_retry_count",
+		"The number of requests through the activator retried on another pod of the revision",
+		stats.UnitDimensionless)
+	podEjectionCountM = stats.Int64(
+		"pod_ejection_count",
+		"The number of times a pod of the revision was ejected from the load balancing of the activator",
+		stats.UnitDimensionless)
+
+	// lbPolicyKey tags the metrics with the load balancing policy of the revision.
+	lbPolicyKey = tag.MustNewKey("lb_policy")
+	// podAddressKey tags the metrics with the address of the pod, as the
+	// activator knows the pods by.
+	podAddressKey = tag.MustNewKey("pod_address")
+)
+
+func init() {
+	register()
+}
+
+func register() {
+	if err := pkgmetrics.RegisterResourceView(
+		&view.View{
+			Description: "The difference between the most and the least requests in flight to a pod of the revision through the activator",
+			Measure:     podInflightSkewM,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{lbPolicyKey},
+		},
+		&view.View{
+			Description: "The number of streams through the activator to a pod of the revision",
+			Measure:     podStreamCountM,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{podAddressKey},
+		},
+		&view.View{
+			Description: "The number of requests through the activator retried on another pod of the revision",
+			Measure:     proxyRetryCountM,
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Description: "The number of times a pod of the revision was ejected from the load balancing of the activator",
+			Measure:     podEjectionCountM,
+			Aggregation: view.Count(),
+		},
+	); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"knative.dev/serving/pkg/queue"
+)
+
+type peerSANKey struct{}
+
+// WithPeerSAN attaches the DNS SAN the certificate of the queue-proxy a request
+// is proxied to must have to the context, for the mutual TLS transport.
+func WithPeerSAN(ctx context.Context, san string) context.Context {
+	return context.WithValue(ctx, peerSANKey{}, san)
+}
+
+func peerSANFrom(ctx context.Context) string {
+	san, _ := ctx.Value(peerSANKey{}).(string)
+	return san
+}
+
+// NewMTLSTransport creates a transport talking to the queue-proxies with mutual
+// TLS, presenting the certificate of certs. The certificate of the queue-proxy
+// must be issued by the CAs of certs and have the DNS SAN attached to the
+// context of the request with WithPeerSAN. The pooled connections keep the
+// certificate they were established with, so they're to be closed once it's
+// rotated.
+func NewMTLSTransport(certs *queue.CertificateReloader, maxIdleConns, maxIdleConnsPerHost int) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 5 * time.Second,
+	}
+	return &http.Transport{
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        maxIdleConns,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		IdleConnTimeout:     5 * time.Second,
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			san := peerSANFrom(ctx)
+			if san == "" {
+				return nil, errors.New("no SAN to verify the queue-proxy at " + addr + " with")
+			}
+			// Dial errors are returned as they are, so that the request may
+			// be retried on another pod.
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			cfg := certs.ClientMutualTLSConfig(san)
+			cfg.NextProtos = []string{"h2", "http/1.1"}
+			tlsConn := tls.Client(conn, cfg)
+			if deadline, ok := ctx.Deadline(); ok {
+				conn.SetDeadline(deadline)
+			}
+			if err := tlsConn.Handshake(); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			conn.SetDeadline(time.Time{})
+			return tlsConn, nil
+		},
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"knative.dev/serving/pkg/queue"
+)
+
+// issueCert writes a certificate with the DNS SAN for the usage, issued by
+// the CA with caKey, with its key and the CA to a temporary directory, and
+// loads them.
+func issueCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, san string, usage x509.ExtKeyUsage) *queue.CertificateReloader {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("GenerateKey() =", err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: san},
+		DNSNames:     []string{san},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal("CreateCertificate() =", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal("MarshalECPrivateKey() =", err)
+	}
+
+	dir := t.TempDir()
+	for name, block := range map[string]*pem.Block{
+		"tls.crt": {Type: "CERTIFICATE", Bytes: der},
+		"tls.key": {Type: "EC PRIVATE KEY", Bytes: keyDER},
+		"ca.crt":  {Type: "CERTIFICATE", Bytes: ca.Raw},
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal("WriteFile() =", err)
+		}
+	}
+	certs, err := queue.NewMutualCertificateReloader(
+		filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		t.Fatal("NewMutualCertificateReloader() =", err)
+	}
+	return certs
+}
+
+func TestMTLSTransport(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("GenerateKey() =", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal("CreateCertificate() =", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal("ParseCertificate() =", err)
+	}
+
+	const (
+		activatorSAN = "activator-service.knative-serving.svc"
+		revisionSAN  = "rev.ns.revision.serving.knative.internal"
+	)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.TLS = issueCert(t, ca, caKey, revisionSAN, x509.ExtKeyUsageServerAuth).ServerMutualTLSConfig(activatorSAN)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		san     string
+		wantErr bool
+	}{{
+		name: "revision SAN",
+		san:  revisionSAN,
+	}, {
+		name:    "other revision SAN",
+		san:     "other.ns.revision.serving.knative.internal",
+		wantErr: true,
+	}, {
+		name:    "no SAN",
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := NewMTLSTransport(issueCert(t, ca, caKey, activatorSAN, x509.ExtKeyUsageClientAuth), 10, 10)
+			defer transport.CloseIdleConnections()
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			if test.san != "" {
+				req = req.WithContext(WithPeerSAN(req.Context(), test.san))
+			}
+			resp, err := transport.RoundTrip(req)
+			if test.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("RoundTrip() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("RoundTrip() =", err)
+			}
+			defer resp.Body.Close()
+			if got, want := resp.ProtoMajor, 2; got != want {
+				t.Errorf("ProtoMajor = %d, want: %d", got, want)
+			}
+		})
+	}
+
+	t.Run("certificates of another CA", func(t *testing.T) {
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal("GenerateKey() =", err)
+		}
+		otherDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &otherKey.PublicKey, otherKey)
+		if err != nil {
+			t.Fatal("CreateCertificate() =", err)
+		}
+		other, err := x509.ParseCertificate(otherDER)
+		if err != nil {
+			t.Fatal("ParseCertificate() =", err)
+		}
+		transport := NewMTLSTransport(issueCert(t, other, otherKey, activatorSAN, x509.ExtKeyUsageClientAuth), 10, 10)
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		if resp, err := transport.RoundTrip(req.WithContext(WithPeerSAN(req.Context(), revisionSAN))); err == nil {
+			resp.Body.Close()
+			t.Fatal("RoundTrip() succeeded, want an error")
+		}
+	})
+}
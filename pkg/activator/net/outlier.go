@@ -0,0 +1,256 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	pkgmetrics "knative.dev/pkg/metrics"
+)
+
+const (
+	// outlierConsecutiveFailures is the number of requests in a row a pod
+	// must fail to be ejected from load balancing.
+	outlierConsecutiveFailures = 5
+	// outlierBaseEjectionTime is how long a pod is ejected the first time.
+	// It doubles with every ejection in a row, up to outlierMaxEjectionTime.
+	outlierBaseEjectionTime = 10 * time.Second
+	// outlierMaxEjectionTime is the longest a pod is ejected for. A pod that
+	// wasn't ejected for as long since it was readmitted starts over with
+	// outlierBaseEjectionTime.
+	outlierMaxEjectionTime = 5 * time.Minute
+	// outlierMaxEjectionPercent is the most of the pods assigned to the
+	// activator ejected at once, so that failures of the revision as a whole
+	// don't leave a few pods with all of its requests.
+	outlierMaxEjectionPercent = 50
+)
+
+// PodError is returned by the functions passed to Throttler.Try if the pod
+// failed the request after it was sent, e.g. as it answered with a 5xx or the
+// connection to it broke. The request isn't retried, as the response was
+// sent, but the failure counts towards ejecting the pod. Try doesn't return
+// it.
+type PodError struct {
+	Err error
+}
+
+// Error implements error.
+func (e *PodError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error the request failed with.
+func (e *PodError) Unwrap() error {
+	return e.Err
+}
+
+// outlierDetector ejects the pods of a revision that failed too many requests
+// in a row from load balancing for a while. Ejected pods are probed before
+// they're readmitted.
+type outlierDetector struct {
+	consecutiveFailures int32
+	baseEjectionTime    time.Duration
+	maxEjectionTime     time.Duration
+	maxEjectionPercent  int
+	// probe returns whether the pod at dest is healthy enough to be
+	// readmitted. Nil if pods are readmitted without probing.
+	probe func(dest string) bool
+
+	// ejected is the number of pod trackers ejected.
+	ejected atomic.Int32
+
+	// mux guards the ejections of the pod trackers, and stopped.
+	mux sync.Mutex
+	// stopped is set once the revision is gone, so that the ejected pods
+	// aren't probed anymore.
+	stopped bool
+}
+
+func newOutlierDetector() *outlierDetector {
+	return &outlierDetector{
+		consecutiveFailures: outlierConsecutiveFailures,
+		baseEjectionTime:    outlierBaseEjectionTime,
+		maxEjectionTime:     outlierMaxEjectionTime,
+		maxEjectionPercent:  outlierMaxEjectionPercent,
+	}
+}
+
+// stop stops probing the ejected pods.
+func (od *outlierDetector) stop() {
+	od.mux.Lock()
+	defer od.mux.Unlock()
+	od.stopped = true
+}
+
+// ejectionTime returns how long the tracker is to be ejected for and counts
+// the ejection. It must be called with od.mux held.
+func (od *outlierDetector) ejectionTime(t *podTracker) time.Duration {
+	d := od.maxEjectionTime
+	if t.ejections < 32 && od.baseEjectionTime<<t.ejections < od.maxEjectionTime {
+		d = od.baseEjectionTime << t.ejections
+	}
+	t.ejections++
+	return d
+}
+
+// availableTrackers returns the trackers other than those in failed and
+// those ejected. If all of them are ejected, they're returned nonetheless.
+func availableTrackers(trackers []*podTracker, failed sets.String) []*podTracker {
+	alternates := make([]*podTracker, 0, len(trackers))
+	for _, t := range trackers {
+		if !failed.Has(t.dest) && !t.ejected.Load() {
+			alternates = append(alternates, t)
+		}
+	}
+	if len(alternates) > 0 {
+		return alternates
+	}
+	// Ejected pods are better than none.
+	for _, t := range trackers {
+		if !failed.Has(t.dest) {
+			alternates = append(alternates, t)
+		}
+	}
+	return alternates
+}
+
+// recordResult counts the result of proxying a request to the pod of the
+// tracker towards ejecting it. It returns the error the request failed with,
+// other than PodError.
+func (rt *revisionThrottler) recordResult(t *podTracker, err error) error {
+	if err == nil {
+		if t.failures.Load() != 0 {
+			t.failures.Store(0)
+		}
+		return nil
+	}
+	var (
+		podErr   *PodError
+		retryErr *RetryableError
+	)
+	failedOnPod := errors.As(err, &podErr)
+	if !failedOnPod && !errors.As(err, &retryErr) {
+		return err
+	}
+	if t.failures.Inc() >= rt.outlier.consecutiveFailures {
+		rt.eject(t)
+	}
+	if failedOnPod {
+		return nil
+	}
+	return err
+}
+
+// eject ejects the pod of the tracker from load balancing, unless too many
+// are ejected already.
+func (rt *revisionThrottler) eject(t *podTracker) {
+	od := rt.outlier
+	d, ok := func() (time.Duration, bool) {
+		od.mux.Lock()
+		defer od.mux.Unlock()
+		if t.ejected.Load() || od.stopped {
+			return 0, false
+		}
+		rt.mux.RLock()
+		direct, assigned := rt.clusterIPTracker == nil, len(rt.assignedTrackers)
+		rt.mux.RUnlock()
+		if !direct {
+			// Requests through the private clusterIP can't be told apart by pod.
+			return 0, false
+		}
+		if ejected := int(od.ejected.Load()) + 1; ejected >= assigned || ejected*100 > od.maxEjectionPercent*assigned {
+			rt.logger.Warnf("Not ejecting pod %s, as %d of %d pods are ejected already", t.dest, ejected-1, assigned)
+			return 0, false
+		}
+		if time.Since(t.readmitted) > od.maxEjectionTime {
+			t.ejections = 0
+		}
+		d := od.ejectionTime(t)
+		t.failures.Store(0)
+		t.ejected.Store(true)
+		od.ejected.Inc()
+		return d, true
+	}()
+	if !ok {
+		return
+	}
+	rt.logger.Warnf("Ejecting pod %s for %v, as it failed %d requests in a row", t.dest, d, od.consecutiveFailures)
+	if rt.statsCtx != nil {
+		pkgmetrics.Record(rt.statsCtx, podEjectionCountM.M(1))
+	}
+	rt.refreshCapacity()
+	time.AfterFunc(d, func() { rt.readmit(t) })
+}
+
+// readmit readmits the ejected pod of the tracker to load balancing if it
+// passes the probe, or ejects it for longer otherwise.
+func (rt *revisionThrottler) readmit(t *podTracker) {
+	od := rt.outlier
+	od.mux.Lock()
+	if !t.ejected.Load() || od.stopped {
+		od.mux.Unlock()
+		return
+	}
+	od.mux.Unlock()
+
+	// Not probed under the lock, as it takes a while.
+	healthy := od.probe == nil || od.probe(t.dest)
+
+	od.mux.Lock()
+	if !t.ejected.Load() || od.stopped {
+		od.mux.Unlock()
+		return
+	}
+	if !healthy {
+		d := od.ejectionTime(t)
+		od.mux.Unlock()
+		rt.logger.Warnf("Pod %s failed the probe for readmission, ejecting it for %v", t.dest, d)
+		time.AfterFunc(d, func() { rt.readmit(t) })
+		return
+	}
+	t.ejected.Store(false)
+	t.readmitted = time.Now()
+	od.ejected.Dec()
+	od.mux.Unlock()
+
+	rt.logger.Infof("Readmitting pod %s", t.dest)
+	rt.refreshCapacity()
+}
+
+// forget drops the ejection of the tracker, whose pod is gone.
+func (rt *revisionThrottler) forget(t *podTracker) {
+	od := rt.outlier
+	od.mux.Lock()
+	defer od.mux.Unlock()
+	if t.ejected.Load() {
+		t.ejected.Store(false)
+		od.ejected.Dec()
+	}
+}
+
+// refreshCapacity updates the capacity of the throttler after a pod was
+// ejected or readmitted.
+func (rt *revisionThrottler) refreshCapacity() {
+	rt.updateMux.Lock()
+	defer rt.updateMux.Unlock()
+	rt.updateCapacity(rt.backendCount)
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	pkgnet "knative.dev/networking/pkg/apis/networking"
+	. "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/metrics/metricstest"
+	"knative.dev/serving/pkg/queue"
+)
+
+// newOutlierTestThrottler creates a revisionThrottler with the pods at dests,
+// which ejects pods for short.
+func newOutlierTestThrottler(t *testing.T, cc int, dests ...string) *revisionThrottler {
+	revID := types.NamespacedName{Namespace: testNamespace, Name: testRevision}
+	rt := newRevisionThrottler(revID, cc, pkgnet.ServicePortNameHTTP1, queue.BreakerParams{
+		QueueDepth:     10,
+		MaxConcurrency: revisionMaxConcurrency,
+	}, TestLogger(t))
+	rt.outlier.baseEjectionTime = 20 * time.Millisecond
+	rt.outlier.maxEjectionTime = time.Second
+	rt.statsCtx = context.Background()
+	rt.handleUpdate(revisionDestsUpdate{Rev: revID, Dests: sets.NewString(dests...)})
+	t.Cleanup(rt.outlier.stop)
+	return rt
+}
+
+func trackerFor(rt *revisionThrottler, dest string) *podTracker {
+	rt.mux.RLock()
+	defer rt.mux.RUnlock()
+	for _, t := range rt.assignedTrackers {
+		if t.dest == dest {
+			return t
+		}
+	}
+	return nil
+}
+
+func TestThrottlerOutlierEjection(t *testing.T) {
+	resetThrottlerMetrics(t)
+	rt := newOutlierTestThrottler(t, 0 /*cc*/, "sick:80", "healthy:80", "healthy-too:80")
+	var healthy atomic.Bool
+	rt.outlier.probe = func(dest string) bool {
+		return healthy.Load()
+	}
+	sick := trackerFor(rt, "sick:80")
+	failed := &PodError{Err: errors.New("the pod answered with status 500")}
+
+	// A success resets the failures.
+	for i := 0; i < outlierConsecutiveFailures-1; i++ {
+		if err := rt.recordResult(sick, failed); err != nil {
+			t.Errorf("recordResult() = %v, want the PodError dropped", err)
+		}
+	}
+	rt.recordResult(sick, nil)
+	for i := 0; i < outlierConsecutiveFailures-1; i++ {
+		rt.recordResult(sick, failed)
+	}
+	if sick.ejected.Load() {
+		t.Fatal("The pod was ejected, though it didn't fail enough requests in a row")
+	}
+
+	refused := &RetryableError{Err: errors.New("connection refused")}
+	if err := rt.recordResult(sick, refused); !errors.Is(err, refused) {
+		t.Errorf("recordResult() = %v, want: %v", err, refused)
+	}
+	if !sick.ejected.Load() {
+		t.Fatal("The pod wasn't ejected")
+	}
+	metricstest.AssertMetric(t, metricstest.IntMetric("pod_ejection_count", 1, nil))
+
+	// The ejected pod gets no requests.
+	for i := 0; i < 20; i++ {
+		if err := rt.try(context.Background(), func(dest string) error {
+			if dest == sick.dest {
+				t.Error("The request was proxied to the ejected pod")
+			}
+			return nil
+		}); err != nil {
+			t.Errorf("try() = %v, want no error", err)
+		}
+	}
+
+	// It stays ejected while failing the probe, and is readmitted once it
+	// passes.
+	time.Sleep(3 * rt.outlier.baseEjectionTime)
+	if !sick.ejected.Load() {
+		t.Fatal("The pod was readmitted, though it failed the probe")
+	}
+	healthy.Store(true)
+	if err := wait.PollImmediate(10*time.Millisecond, 3*time.Second, func() (bool, error) {
+		return !sick.ejected.Load(), nil
+	}); err != nil {
+		t.Fatal("The pod wasn't readmitted once it passed the probe")
+	}
+	if got := rt.outlier.ejected.Load(); got != 0 {
+		t.Errorf("ejected = %d, want: 0", got)
+	}
+
+	// Ejections right after the readmission take longer, those a while
+	// after it start over.
+	ejections := func() int {
+		rt.outlier.mux.Lock()
+		defer rt.outlier.mux.Unlock()
+		return sick.ejections
+	}
+	if got := ejections(); got < 2 {
+		t.Errorf("ejections = %d, want at least 2 after a failed probe", got)
+	}
+	rt.outlier.mux.Lock()
+	sick.readmitted = time.Now().Add(-2 * rt.outlier.maxEjectionTime)
+	rt.outlier.mux.Unlock()
+	healthy.Store(false)
+	for i := 0; i < outlierConsecutiveFailures; i++ {
+		rt.recordResult(sick, failed)
+	}
+	if got, want := ejections(), 1; got != want {
+		t.Errorf("ejections = %d, want: %d", got, want)
+	}
+}
+
+func TestThrottlerOutlierEjectionTime(t *testing.T) {
+	od := newOutlierDetector()
+	tracker := newPodTracker("sick:80", nil)
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second,
+		160 * time.Second, 5 * time.Minute, 5 * time.Minute}
+	for i, want := range want {
+		if got := od.ejectionTime(tracker); got != want {
+			t.Errorf("ejection #%d = %v, want: %v", i+1, got, want)
+		}
+	}
+}
+
+func TestThrottlerOutlierMaxEjectionPercent(t *testing.T) {
+	resetThrottlerMetrics(t)
+	rt := newOutlierTestThrottler(t, 0 /*cc*/, "a:80", "b:80", "c:80")
+	rt.outlier.probe = func(string) bool { return false }
+	failed := &PodError{Err: errors.New("the pod answered with status 500")}
+
+	// Only one of the three pods may be ejected at once.
+	for _, dest := range []string{"a:80", "b:80", "c:80"} {
+		tracker := trackerFor(rt, dest)
+		for i := 0; i < outlierConsecutiveFailures; i++ {
+			rt.recordResult(tracker, failed)
+		}
+	}
+	if got, want := rt.outlier.ejected.Load(), int32(1); got != want {
+		t.Errorf("ejected = %d, want: %d", got, want)
+	}
+
+	// A single pod is never ejected.
+	rt = newOutlierTestThrottler(t, 0 /*cc*/, "only:80")
+	only := trackerFor(rt, "only:80")
+	for i := 0; i < 2*outlierConsecutiveFailures; i++ {
+		rt.recordResult(only, failed)
+	}
+	if only.ejected.Load() {
+		t.Error("The only pod was ejected")
+	}
+}
+
+func TestThrottlerOutlierCapacity(t *testing.T) {
+	rt := newOutlierTestThrottler(t, 10 /*cc*/, "sick:80", "healthy:80", "healthy-too:80")
+	rt.outlier.probe = func(string) bool { return false }
+	if got, want := rt.breaker.Capacity(), 30; got != want {
+		t.Fatalf("Capacity = %d, want: %d", got, want)
+	}
+
+	sick := trackerFor(rt, "sick:80")
+	for i := 0; i < outlierConsecutiveFailures; i++ {
+		rt.recordResult(sick, &PodError{Err: errors.New("connection reset by peer")})
+	}
+	if got, want := rt.breaker.Capacity(), 20; got != want {
+		t.Errorf("Capacity with the pod ejected = %d, want: %d", got, want)
+	}
+
+	// The ejection is dropped once the pod is gone.
+	rt.handleUpdate(revisionDestsUpdate{Rev: rt.revID, Dests: sets.NewString("healthy:80", "healthy-too:80")})
+	if got := rt.outlier.ejected.Load(); got != 0 {
+		t.Errorf("ejected = %d, want: 0", got)
+	}
+	if got, want := rt.breaker.Capacity(), 20; got != want {
+		t.Errorf("Capacity with the pod gone = %d, want: %d", got, want)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	network "knative.dev/networking/pkg"
+	"knative.dev/serving/pkg/apis/serving"
+	"knative.dev/serving/pkg/reconciler/serverlessservice/resources/names"
+)
+
+// prewarmTimeout bounds each warm-up request, as warming up may take a
+// while, e.g. to load a model.
+const prewarmTimeout = 2 * time.Minute
+
+// prewarmConfig is how the new pods of a revision are warmed up before they
+// get requests through the activator.
+type prewarmConfig struct {
+	path    string
+	headers map[string]string
+	count   int
+}
+
+// prewarmConfigFromAnnotations returns the warm-up configured by
+// serving.ActivatorPrewarmPathAnnotation and the annotations accompanying it,
+// or nil if it's not set.
+func prewarmConfigFromAnnotations(annotations map[string]string) *prewarmConfig {
+	path, ok := annotations[serving.ActivatorPrewarmPathAnnotation]
+	if !ok {
+		return nil
+	}
+	// The annotations are validated by the webhook.
+	cfg := &prewarmConfig{path: path, count: 1}
+	if v, ok := annotations[serving.ActivatorPrewarmHeadersAnnotation]; ok {
+		json.Unmarshal([]byte(v), &cfg.headers)
+	}
+	if v, ok := annotations[serving.ActivatorPrewarmCountAnnotation]; ok {
+		if count, err := strconv.Atoi(v); err == nil && count > 0 {
+			cfg.count = count
+		}
+	}
+	return cfg
+}
+
+// needsPrewarm returns whether the pod at dest, which passed the probe, is
+// yet to be warmed up, and starts warming it up if it isn't already. The pod
+// is reported to warmedCh once warmed up.
+func (rw *revisionWatcher) needsPrewarm(dest string) bool {
+	if rw.prewarm == nil || rw.warmedPods.Has(dest) {
+		return false
+	}
+	if !rw.warmingPods.Has(dest) {
+		rw.warmingPods.Insert(dest)
+		go func() {
+			if err := rw.prewarmPod(dest); err != nil {
+				// Warming up is best effort, the pod is healthy nonetheless.
+				rw.logger.Warnw("Failed to warm up pod "+dest, zap.Error(err))
+			}
+			select {
+			case rw.warmedCh <- dest:
+			case <-rw.stopCh:
+			}
+		}()
+	}
+	return true
+}
+
+// prewarmPod sends the warm-up requests to the pod at dest, one after the
+// other.
+func (rw *revisionWatcher) prewarmPod(dest string) error {
+	rw.logger.Infof("Warming up pod %s with %d requests", dest, rw.prewarm.count)
+	for i := 0; i < rw.prewarm.count; i++ {
+		if err := rw.sendPrewarmRequest(dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rw *revisionWatcher) sendPrewarmRequest(dest string) error {
+	ctx, cancel := context.WithTimeout(rw.ctx, prewarmTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+dest+rw.prewarm.path, nil)
+	if err != nil {
+		return err
+	}
+	for name, value := range rw.prewarm.headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set(network.UserAgentKey, network.ActivatorUserAgent)
+	if rw.usePassthroughLb {
+		// Like the probes, so that the request lands on the pod.
+		req.Host = names.PrivateService(rw.rev.Name) + "." + rw.rev.Namespace
+		req.Header.Set(network.PassthroughLoadbalancingHeaderName, "true")
+	}
+
+	resp, err := rw.transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("the warm-up request was answered with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	network "knative.dev/networking/pkg"
+	pkgnet "knative.dev/networking/pkg/apis/networking"
+	fakeserviceinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/service/fake"
+	pkgnetwork "knative.dev/pkg/network"
+	rtesting "knative.dev/pkg/reconciler/testing"
+	activatortest "knative.dev/serving/pkg/activator/testing"
+	"knative.dev/serving/pkg/apis/serving"
+
+	. "knative.dev/pkg/logging/testing"
+)
+
+func TestPrewarmConfigFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *prewarmConfig
+	}{{
+		name: "not set",
+	}, {
+		name: "path",
+		annotations: map[string]string{
+			serving.ActivatorPrewarmPathAnnotation: "/warmup",
+		},
+		want: &prewarmConfig{path: "/warmup", count: 1},
+	}, {
+		name: "all of them",
+		annotations: map[string]string{
+			serving.ActivatorPrewarmPathAnnotation:    "/warmup?model=big",
+			serving.ActivatorPrewarmHeadersAnnotation: `{"X-Warmup":"true"}`,
+			serving.ActivatorPrewarmCountAnnotation:   "3",
+		},
+		want: &prewarmConfig{
+			path:    "/warmup?model=big",
+			headers: map[string]string{"X-Warmup": "true"},
+			count:   3,
+		},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := prewarmConfigFromAnnotations(tc.annotations)
+			if !cmp.Equal(got, tc.want, cmp.AllowUnexported(prewarmConfig{})) {
+				t.Errorf("prewarmConfigFromAnnotations() = %+v, want: %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRevisionWatcherPrewarm(t *testing.T) {
+	const dest = "128.0.0.1:1234"
+	fakeRT := activatortest.FakeRoundTripper{}
+
+	var (
+		mux       sync.Mutex
+		warmups   []*http.Request
+		unblock   = make(chan struct{})
+		unblocked bool
+	)
+	rt := pkgnetwork.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get(network.ProbeHeaderName) != "" {
+			return fakeRT.RT(req)
+		}
+		mux.Lock()
+		warmups = append(warmups, req)
+		mux.Unlock()
+		<-unblock
+		recorder := httptest.NewRecorder()
+		recorder.WriteHeader(http.StatusOK)
+		return recorder.Result(), nil
+	})
+
+	ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+	informer := fakeserviceinformer.Get(ctx)
+	waitInformers, err := rtesting.RunAndSyncInformers(ctx, informer.Informer())
+	if err != nil {
+		t.Fatal("Failed to start informers:", err)
+	}
+
+	updateCh := make(chan revisionDestsUpdate, 10)
+	destsCh := make(chan dests)
+	revID := types.NamespacedName{Namespace: testNamespace, Name: testRevision}
+	rw := newRevisionWatcher(ctx, revID, pkgnet.ProtocolHTTP1, updateCh, destsCh, rt,
+		informer.Lister(), false /*usePassthroughLb*/, TestLogger(t))
+	rw.prewarm = &prewarmConfig{
+		path:    "/warmup",
+		headers: map[string]string{"X-Warmup": "true"},
+		count:   2,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rw.run(probeFreq)
+	}()
+	defer func() {
+		mux.Lock()
+		if !unblocked {
+			close(unblock)
+		}
+		mux.Unlock()
+		cancel()
+		wg.Wait()
+		waitInformers()
+	}()
+
+	destsCh <- dests{ready: sets.NewString(dest)}
+
+	// The pod passed the probe, but isn't reported healthy while being warmed
+	// up, nor is the revision routed through the clusterIP.
+	select {
+	case update := <-updateCh:
+		t.Fatalf("Got update %v while the pod was being warmed up", update)
+	case <-time.After(updateTimeout):
+	}
+
+	mux.Lock()
+	unblocked = true
+	close(unblock)
+	mux.Unlock()
+
+	select {
+	case update := <-updateCh:
+		if got, want := update.Dests, sets.NewString(dest); !got.Equal(want) {
+			t.Errorf("Dests = %v, want: %v", got, want)
+		}
+		if update.ClusterIPDest != "" {
+			t.Errorf("ClusterIPDest = %q, want the pod routed to directly", update.ClusterIPDest)
+		}
+	case <-time.After(updateTimeout):
+		t.Fatal("Timed out waiting for the pod to be reported healthy")
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if got, want := len(warmups), 2; got != want {
+		t.Fatalf("Number of warm-up requests = %d, want: %d", got, want)
+	}
+	for _, req := range warmups {
+		if got, want := req.URL.String(), "http://"+dest+"/warmup"; got != want {
+			t.Errorf("Warm-up URL = %q, want: %q", got, want)
+		}
+		if got, want := req.Header.Get("X-Warmup"), "true"; got != want {
+			t.Errorf("X-Warmup header = %q, want: %q", got, want)
+		}
+		if got, want := req.Header.Get(network.UserAgentKey), network.ActivatorUserAgent; got != want {
+			t.Errorf("User-Agent = %q, want: %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"errors"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"knative.dev/serving/pkg/apis/serving"
+	"knative.dev/serving/pkg/queue"
+)
+
+// ErrRetryBudgetExhausted indicates that a request needed a retry, but the
+// revision's retry budget didn't permit one.
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// RetryableError is returned by the functions passed to Throttler.Try if
+// proxying the request failed without side effects, e.g. as its pod refused
+// the connection. The request is then retried on another pod, if any is left
+// and the revision's retry budget permits.
+type RetryableError struct {
+	Err error
+}
+
+// Error implements error.
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error the request failed with.
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// retryBudgetFromAnnotations creates the retry budget configured by
+// serving.ActivatorRetryBudgetAnnotation, or nil if it's not set.
+func retryBudgetFromAnnotations(annotations map[string]string, clock clock.PassiveClock) *queue.RetryBudget {
+	v, ok := annotations[serving.ActivatorRetryBudgetAnnotation]
+	if !ok {
+		return nil
+	}
+	// The annotation is validated by the webhook.
+	ratio, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil
+	}
+	return queue.NewRetryBudget(ratio, clock)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package net
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	pkgnet "knative.dev/networking/pkg/apis/networking"
+	. "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/metrics/metricstest"
+	"knative.dev/serving/pkg/apis/serving"
+	"knative.dev/serving/pkg/queue"
+)
+
+func TestRetryBudgetFromAnnotations(t *testing.T) {
+	if b := retryBudgetFromAnnotations(nil, clock.RealClock{}); b != nil {
+		t.Errorf("Budget without annotation = %#v, want nil", b)
+	}
+	b := retryBudgetFromAnnotations(map[string]string{
+		serving.ActivatorRetryBudgetAnnotation: "0.3",
+	}, clock.RealClock{})
+	if b == nil {
+		t.Fatal("Budget = nil, want ratio 0.3")
+	}
+	// 10 requests permit 3 retries.
+	for i := 0; i < 10; i++ {
+		b.Request()
+	}
+	for i := 0; i < 3; i++ {
+		if !b.AllowRetry() {
+			t.Errorf("Retry %d within the budget was denied", i)
+		}
+	}
+	if b.AllowRetry() {
+		t.Error("Retry beyond the budget was allowed")
+	}
+}
+
+func TestThrottlerRetryBudget(t *testing.T) {
+	logger := TestLogger(t)
+	rt := newRevisionThrottler(types.NamespacedName{Namespace: testNamespace, Name: testRevision},
+		1 /*cc*/, pkgnet.ServicePortNameHTTP1, queue.BreakerParams{
+			QueueDepth:      10,
+			MaxConcurrency:  revisionMaxConcurrency,
+			InitialCapacity: 1,
+		}, logger)
+	// Requests pass the breaker without finding a destination, as if the
+	// revision's pods went away while they were waiting for capacity, so that
+	// they are retried.
+	rt.retryBudget = queue.NewRetryBudget(0.5, clock.RealClock{})
+	for i := 0; i < 4; i++ {
+		rt.retryBudget.Request()
+	}
+
+	// The request is retried until the budget is exhausted: with 5 requests
+	// that is twice.
+	err := rt.try(context.Background(), func(string) error {
+		t.Error("Request was proxied without destination")
+		return nil
+	})
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Errorf("try() = %v, want: %v", err, ErrRetryBudgetExhausted)
+	}
+	// Two more requests leave room for exactly one more retry, if two
+	// retries were spent.
+	rt.retryBudget.Request()
+	rt.retryBudget.Request()
+	if !rt.retryBudget.AllowRetry() {
+		t.Error("Retry within the budget was denied")
+	}
+	if rt.retryBudget.AllowRetry() {
+		t.Error("Retry beyond the budget was allowed")
+	}
+}
+
+func TestThrottlerRetryOnAnotherPod(t *testing.T) {
+	logger := TestLogger(t)
+	rt := newRevisionThrottler(types.NamespacedName{Namespace: testNamespace, Name: testRevision},
+		0 /*cc*/, pkgnet.ServicePortNameHTTP1, queue.BreakerParams{}, logger)
+	rt.breaker.UpdateConcurrency(1)
+	rt.assignedTrackers = makeTrackers(3, 0)
+	rt.statsCtx = context.Background()
+	resetThrottlerMetrics(t)
+	refused := &RetryableError{Err: errors.New("connection refused")}
+
+	// The request is retried on the other pods until it succeeds.
+	var dests []string
+	err := rt.try(context.Background(), func(dest string) error {
+		dests = append(dests, dest)
+		if len(dests) < 3 {
+			return refused
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("try() = %v, want no error", err)
+	}
+	if got := sets.NewString(dests...); got.Len() != 3 {
+		t.Errorf("Request was proxied to %v, want to each pod once", dests)
+	}
+	metricstest.AssertMetric(t, metricstest.IntMetric("proxy_retry_count", 2, nil))
+
+	// It fails once no other pod is left.
+	dests = nil
+	err = rt.try(context.Background(), func(dest string) error {
+		dests = append(dests, dest)
+		return refused
+	})
+	if !errors.Is(err, refused) {
+		t.Errorf("try() = %v, want: %v", err, refused)
+	}
+	if got, want := len(dests), 3; got != want {
+		t.Errorf("Request was proxied %d times, want: %d", got, want)
+	}
+}
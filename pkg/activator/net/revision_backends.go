@@ -92,6 +92,7 @@ const (
 // revisionWatcher watches the podIPs and ClusterIP of the service for a revision. It implements the logic
 // to supply revisionDestsUpdate events on updateCh
 type revisionWatcher struct {
+	ctx      context.Context
 	stopCh   <-chan struct{}
 	cancel   context.CancelFunc
 	rev      types.NamespacedName
@@ -116,6 +117,15 @@ type revisionWatcher struct {
 	// usePassthroughLb makes the probing use the passthrough lb headers to enable
 	// pod addressability even in meshes.
 	usePassthroughLb bool
+
+	// prewarm is how the pods are warmed up before they're reported healthy,
+	// nil if they aren't.
+	prewarm *prewarmConfig
+	// warmedPods are the pods that were warmed up, and warmingPods those being
+	// warmed up, which are sent to warmedCh once done.
+	warmedPods  sets.String
+	warmingPods sets.String
+	warmedCh    chan string
 }
 
 func newRevisionWatcher(ctx context.Context, rev types.NamespacedName, protocol pkgnet.ProtocolType,
@@ -125,6 +135,7 @@ func newRevisionWatcher(ctx context.Context, rev types.NamespacedName, protocol
 	logger *zap.SugaredLogger) *revisionWatcher {
 	ctx, cancel := context.WithCancel(ctx)
 	return &revisionWatcher{
+		ctx:              ctx,
 		stopCh:           ctx.Done(),
 		cancel:           cancel,
 		rev:              rev,
@@ -136,6 +147,9 @@ func newRevisionWatcher(ctx context.Context, rev types.NamespacedName, protocol
 		serviceLister:    serviceLister,
 		podsAddressable:  true, // By default we presume we can talk to pods directly.
 		usePassthroughLb: usePassthroughLb,
+		warmedPods:       sets.NewString(),
+		warmingPods:      sets.NewString(),
+		warmedCh:         make(chan string),
 		logger:           logger.With(zap.String(logkey.Key, rev.String())),
 	}
 }
@@ -144,6 +158,13 @@ func newRevisionWatcher(ctx context.Context, rev types.NamespacedName, protocol
 // the probe. If the failure is not compatible with having been caused by mesh
 // being enabled, notMesh will be true.
 func (rw *revisionWatcher) probe(ctx context.Context, dest string) (pass bool, notMesh bool, err error) {
+	return probeDest(ctx, rw.transport, rw.rev, dest, rw.usePassthroughLb)
+}
+
+// probeDest probes the destination of the revision over the transport, like
+// revisionWatcher.probe.
+func probeDest(ctx context.Context, transport http.RoundTripper, rev types.NamespacedName,
+	dest string, usePassthroughLb bool) (pass bool, notMesh bool, err error) {
 	httpDest := url.URL{
 		Scheme: "http",
 		Host:   dest,
@@ -167,7 +188,7 @@ func (rw *revisionWatcher) probe(ctx context.Context, dest string) (pass bool, n
 		prober.ExpectsBody(queue.Name),
 	}
 
-	if rw.usePassthroughLb {
+	if usePassthroughLb {
 		// Add the passthrough header + force the Host header to point to the service
 		// we're targeting, to make sure ingress can correctly route it.
 		// We cannot set these headers unconditionally as the Host header will cause the
@@ -175,11 +196,11 @@ func (rw *revisionWatcher) probe(ctx context.Context, dest string) (pass bool, n
 		// configured, which will cause the request to "pass" but doesn't guarantee it
 		// actually lands on the correct pod, which breaks our state keeping.
 		options = append(options,
-			prober.WithHost(names.PrivateService(rw.rev.Name)+"."+rw.rev.Namespace),
+			prober.WithHost(names.PrivateService(rev.Name)+"."+rev.Namespace),
 			prober.WithHeader(network.PassthroughLoadbalancingHeaderName, "true"))
 	}
 
-	match, err := prober.Do(ctx, rw.transport, httpDest.String(), options...)
+	match, err := prober.Do(ctx, transport, httpDest.String(), options...)
 	return match, notMesh, err
 }
 
@@ -230,6 +251,10 @@ func (rw *revisionWatcher) probePodIPs(dests sets.String) (succeeded sets.String
 	if toProbe.Len() == 0 {
 		return healthy, false, false, nil
 	}
+	if rw.prewarm != nil {
+		// Forget the pods that are gone.
+		rw.warmedPods = rw.warmedPods.Intersection(dests)
+	}
 
 	// Context used for our probe requests.
 	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
@@ -257,10 +282,14 @@ func (rw *revisionWatcher) probePodIPs(dests sets.String) (succeeded sets.String
 
 	err = probeGroup.Wait()
 	close(healthyDests)
-	unchanged := len(healthyDests) == 0
+	unchanged := true
 
 	for d := range healthyDests {
-		healthy.Insert(d)
+		// The pods are reported healthy once they're warmed up, if they're to be.
+		if !rw.needsPrewarm(d) {
+			healthy.Insert(d)
+			unchanged = false
+		}
 	}
 	return healthy, unchanged, sawNotMesh.Load(), err
 }
@@ -329,6 +358,10 @@ func (rw *revisionWatcher) checkDests(curDests, prevDests dests) {
 		if notMesh {
 			return
 		}
+		// The pods being warmed up passed the probe, so they're addressable.
+		if rw.warmingPods.Len() > 0 {
+			return
+		}
 	}
 
 	if rw.usePassthroughLb {
@@ -397,6 +430,10 @@ func (rw *revisionWatcher) run(probeFrequency time.Duration) {
 		case x := <-rw.destsCh:
 			rw.logger.Debugf("Updating Endpoints: ready backends: %d, not-ready backends: %d", len(x.ready), len(x.notReady))
 			prevDests, curDests = curDests, x
+		case dest := <-rw.warmedCh:
+			rw.logger.Debug("Warmed up pod ", dest)
+			rw.warmingPods.Delete(dest)
+			rw.warmedPods.Insert(dest)
 		case <-tickCh:
 		}
 
@@ -479,27 +516,20 @@ func (rbm *revisionBackendsManager) updates() <-chan revisionDestsUpdate {
 	return rbm.updateCh
 }
 
-func (rbm *revisionBackendsManager) getRevisionProtocol(revID types.NamespacedName) (pkgnet.ProtocolType, error) {
-	revision, err := rbm.revisionLister.Revisions(revID.Namespace).Get(revID.Name)
-	if err != nil {
-		return "", err
-	}
-	return revision.GetProtocol(), nil
-}
-
 func (rbm *revisionBackendsManager) getOrCreateRevisionWatcher(rev types.NamespacedName) (*revisionWatcher, error) {
 	rbm.revisionWatchersMux.Lock()
 	defer rbm.revisionWatchersMux.Unlock()
 
 	rwCh, ok := rbm.revisionWatchers[rev]
 	if !ok {
-		proto, err := rbm.getRevisionProtocol(rev)
+		revision, err := rbm.revisionLister.Revisions(rev.Namespace).Get(rev.Name)
 		if err != nil {
 			return nil, err
 		}
 
 		destsCh := make(chan dests)
-		rw := newRevisionWatcher(rbm.ctx, rev, proto, rbm.updateCh, destsCh, rbm.transport, rbm.serviceLister, rbm.usePassthroughLb, rbm.logger)
+		rw := newRevisionWatcher(rbm.ctx, rev, revision.GetProtocol(), rbm.updateCh, destsCh, rbm.transport, rbm.serviceLister, rbm.usePassthroughLb, rbm.logger)
+		rw.prewarm = prewarmConfigFromAnnotations(revision.Annotations)
 		rbm.revisionWatchers[rev] = rw
 		go rw.run(rbm.probeFrequency)
 		return rw, nil
@@ -18,18 +18,23 @@ package net
 
 import (
 	"context"
+	"errors"
 	"math"
 	"math/rand"
 	"net/http"
 	"sort"
 	"sync"
+	"time"
 
+	"go.opencensus.io/tag"
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
 
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/tools/cache"
 
 	pkgnet "knative.dev/networking/pkg/apis/networking"
@@ -37,11 +42,13 @@ import (
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/logging/logkey"
+	pkgmetrics "knative.dev/pkg/metrics"
 	"knative.dev/pkg/reconciler"
 	"knative.dev/serving/pkg/apis/serving"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 	revisioninformer "knative.dev/serving/pkg/client/injection/informers/serving/v1/revision"
 	servinglisters "knative.dev/serving/pkg/client/listers/serving/v1"
+	"knative.dev/serving/pkg/metrics"
 	"knative.dev/serving/pkg/networking"
 	"knative.dev/serving/pkg/queue"
 )
@@ -76,8 +83,23 @@ type podTracker struct {
 
 	// weight is used for LB policy implementations.
 	weight atomic.Int32
+	// inFlight is the number of requests being proxied to dest, other than
+	// the streams.
+	inFlight atomic.Int32
+	// streams is the number of streams being proxied to dest.
+	streams atomic.Int32
 	// decreaseWeight is an allocation optimization for the randomChoice2 policy.
 	decreaseWeight func()
+
+	// failures is the number of requests in a row the pod failed.
+	failures atomic.Int32
+	// ejected is whether the pod is ejected from load balancing.
+	ejected atomic.Bool
+	// ejections is the number of times in a row the pod was ejected, and
+	// readmitted is when it was readmitted last. They're guarded by the
+	// mutex of the outlierDetector.
+	ejections  int
+	readmitted time.Time
 }
 
 func (p *podTracker) increaseWeight() {
@@ -118,8 +140,69 @@ type breaker interface {
 	Maybe(ctx context.Context, thunk func()) error
 	UpdateConcurrency(int)
 	Reserve(ctx context.Context) (func(), bool)
+	QueueDepth() int
+}
+
+type maxQueueLengthKey struct{}
+
+// WithMaxQueueLength bounds the number of requests of the revision waiting
+// for a pod, including the request with the context. Requests beyond it fail
+// with queue.ErrRequestQueueFull.
+func WithMaxQueueLength(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxQueueLengthKey{}, n)
+}
+
+func maxQueueLengthFrom(ctx context.Context) int {
+	n, _ := ctx.Value(maxQueueLengthKey{}).(int)
+	return n
+}
+
+type coldStartTimeoutKey struct{}
+
+// WithColdStartTimeout bounds how long a request waits for the revision to
+// scale from zero, if it has no capacity once the request arrives. Requests
+// waiting longer fail with ErrColdStartTimeout.
+func WithColdStartTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, coldStartTimeoutKey{}, timeout)
+}
+
+func coldStartTimeoutFrom(ctx context.Context) time.Duration {
+	timeout, _ := ctx.Value(coldStartTimeoutKey{}).(time.Duration)
+	return timeout
+}
+
+type coldStartKey struct{}
+
+// WithColdStart has the throttler report on *cold whether the revision had no
+// capacity once the request arrived, i.e. whether it's a cold start.
+func WithColdStart(ctx context.Context, cold *bool) context.Context {
+	return context.WithValue(ctx, coldStartKey{}, cold)
+}
+
+// ReportColdStart reports whether the request of ctx is a cold start to the
+// caller of WithColdStart, if any.
+func ReportColdStart(ctx context.Context, cold bool) {
+	if p, ok := ctx.Value(coldStartKey{}).(*bool); ok {
+		*p = cold
+	}
+}
+
+type coldStartTimeoutError struct{}
+
+func (coldStartTimeoutError) Error() string {
+	return "timed out waiting for the revision to scale from zero"
+}
+
+// Is makes the error match context.DeadlineExceeded as well.
+func (coldStartTimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
 }
 
+// ErrColdStartTimeout indicates that a request timed out waiting for the
+// revision, which had no capacity, to scale from zero. It matches
+// context.DeadlineExceeded.
+var ErrColdStartTimeout error = coldStartTimeoutError{}
+
 // revisionThrottler is used to throttle requests across the entire revision.
 // We use a breaker across the entire revision as well as individual
 // podTrackers because we need to queue requests in case no individual
@@ -128,6 +211,11 @@ type revisionThrottler struct {
 	revID                types.NamespacedName
 	containerConcurrency int
 	lbPolicy             lbPolicy
+	lbPolicyName         string
+
+	// statsCtx is the context the metrics of the revision are recorded with.
+	// Nil if they're not to be recorded.
+	statsCtx context.Context
 
 	// These are used in slicing to infer which pods to assign
 	// to this activator.
@@ -146,6 +234,13 @@ type revisionThrottler struct {
 	// This is a breaker for the revision as a whole.
 	breaker breaker
 
+	// retryBudget bounds the retries of requests for the revision, e.g. when
+	// no destination was available after passing the breaker. Nil if unbounded.
+	retryBudget *queue.RetryBudget
+
+	// outlier ejects the pods failing requests from load balancing.
+	outlier *outlierDetector
+
 	// This will be non-empty when we're able to use pod addressing.
 	podTrackers []*podTracker
 
@@ -161,6 +256,10 @@ type revisionThrottler struct {
 	// request path. This is: trackers, clusterIPDest.
 	mux sync.RWMutex
 
+	// updateMux serializes the updates of the throttler state, which happen
+	// on the updates of the revision and the activators and on ejections.
+	updateMux sync.Mutex
+
 	logger *zap.SugaredLogger
 }
 
@@ -172,19 +271,20 @@ func newRevisionThrottler(revID types.NamespacedName,
 	var (
 		revBreaker breaker
 		lbp        lbPolicy
+		lbpName    string
 	)
 	switch {
 	case containerConcurrency == 0:
 		revBreaker = newInfiniteBreaker(logger)
-		lbp = randomChoice2Policy
+		lbp, lbpName = randomChoice2Policy, randomChoice2PolicyName
 	case containerConcurrency <= 3:
 		// For very low CC values use first available pod.
 		revBreaker = queue.NewBreaker(breakerParams)
-		lbp = firstAvailableLBPolicy
+		lbp, lbpName = firstAvailableLBPolicy, firstAvailablePolicyName
 	default:
 		// Otherwise RR.
 		revBreaker = queue.NewBreaker(breakerParams)
-		lbp = newRoundRobinPolicy()
+		lbp, lbpName = newRoundRobinPolicy(), roundRobinPolicyName
 	}
 	return &revisionThrottler{
 		revID:                revID,
@@ -194,34 +294,79 @@ func newRevisionThrottler(revID types.NamespacedName,
 		protocol:             proto,
 		activatorIndex:       *atomic.NewInt32(-1), // Start with unknown.
 		lbPolicy:             lbp,
+		lbPolicyName:         lbpName,
+		outlier:              newOutlierDetector(),
 	}
 }
 
 func noop() {}
 
 // Returns a dest that at the moment of choosing had an open slot
-// for request.
-func (rt *revisionThrottler) acquireDest(ctx context.Context) (func(), *podTracker) {
+// for request, other than those in failed. The returned bool is false
+// if no other dest is left.
+func (rt *revisionThrottler) acquireDest(ctx context.Context, failed sets.String) (func(), *podTracker, bool) {
 	rt.mux.RLock()
 	defer rt.mux.RUnlock()
 
 	if rt.clusterIPTracker != nil {
-		return noop, rt.clusterIPTracker
+		if failed.Has(rt.clusterIPTracker.dest) {
+			return noop, nil, false
+		}
+		return noop, rt.clusterIPTracker, true
 	}
-	return rt.lbPolicy(ctx, rt.assignedTrackers)
+	policy := rt.lbPolicy
+	// Streams stick to the pod of their key, if hashed consistently.
+	if isStream(ctx) && rt.lbPolicyName != consistentHashPolicyName {
+		policy = leastStreamsPolicy
+	}
+	if failed.Len() == 0 && rt.outlier.ejected.Load() == 0 {
+		cb, tracker := policy(ctx, rt.assignedTrackers)
+		return cb, tracker, true
+	}
+	alternates := availableTrackers(rt.assignedTrackers, failed)
+	if len(alternates) == 0 {
+		return noop, nil, false
+	}
+	cb, tracker := policy(ctx, alternates)
+	return cb, tracker, true
 }
 
 func (rt *revisionThrottler) try(ctx context.Context, function func(string) error) error {
-	var ret error
+	var (
+		ret error
+		// failed holds the dests the request failed on retryably, so that it's
+		// retried on others.
+		failed sets.String
+	)
 
 	// Retrying infinitely as long as we receive no dest. Outer semaphore and inner
 	// pod capacity are not changed atomically, hence they can race each other. We
 	// "reenqueue" requests should that happen.
+	if max := maxQueueLengthFrom(ctx); max > 0 && rt.breaker.QueueDepth() >= max {
+		return queue.ErrRequestQueueFull
+	}
+	cold := rt.breaker.Capacity() == 0
+	ReportColdStart(ctx, cold)
+	if timeout := coldStartTimeoutFrom(ctx); timeout > 0 && cold {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	rt.retryBudget.Request()
+	stream := isStream(ctx)
 	reenqueue := true
-	for reenqueue {
+	for first := true; reenqueue; first = false {
+		if !first && !rt.retryBudget.AllowRetry() {
+			return ErrRetryBudgetExhausted
+		}
 		reenqueue = false
+		noneLeft := false
 		if err := rt.breaker.Maybe(ctx, func() {
-			cb, tracker := rt.acquireDest(ctx)
+			cb, tracker, ok := rt.acquireDest(ctx, failed)
+			if !ok {
+				noneLeft = true
+				return
+			}
 			if tracker == nil {
 				// This can happen if individual requests raced each other or if pod
 				// capacity was decreased after passing the outer semaphore.
@@ -229,15 +374,85 @@ func (rt *revisionThrottler) try(ctx context.Context, function func(string) erro
 				return
 			}
 			defer cb()
+			if stream {
+				rt.recordStreams(tracker, tracker.streams.Inc())
+				defer func() { rt.recordStreams(tracker, tracker.streams.Dec()) }()
+			} else {
+				tracker.inFlight.Inc()
+				defer tracker.inFlight.Dec()
+				rt.recordInflightSkew()
+			}
 			// We already reserved a guaranteed spot. So just execute the passed functor.
-			ret = function(tracker.dest)
+			ret = rt.recordResult(tracker, function(tracker.dest))
+			var retryErr *RetryableError
+			if errors.As(ret, &retryErr) {
+				if failed == nil {
+					failed = sets.NewString()
+				}
+				failed.Insert(tracker.dest)
+				reenqueue = true
+				rt.recordProxyRetry()
+			}
 		}); err != nil {
+			if cold && errors.Is(err, context.DeadlineExceeded) && rt.breaker.Capacity() == 0 {
+				return ErrColdStartTimeout
+			}
 			return err
 		}
+		if noneLeft {
+			// There's no other dest to retry the request on.
+			return ret
+		}
 	}
 	return ret
 }
 
+// recordProxyRetry records that a request is retried on another dest.
+func (rt *revisionThrottler) recordProxyRetry() {
+	if rt.statsCtx != nil {
+		pkgmetrics.Record(rt.statsCtx, proxyRetryCountM.M(1))
+	}
+}
+
+// recordStreams records the number of streams to the dest of the tracker.
+func (rt *revisionThrottler) recordStreams(t *podTracker, streams int32) {
+	if rt.statsCtx == nil {
+		return
+	}
+	ctx, err := tag.New(rt.statsCtx, tag.Upsert(podAddressKey, t.dest))
+	if err != nil {
+		return
+	}
+	pkgmetrics.Record(ctx, podStreamCountM.M(int64(streams)))
+}
+
+// recordInflightSkew records the difference between the most and the least
+// requests in flight to the pods assigned to this activator, which shows how
+// evenly the load balancing policy spreads the requests.
+func (rt *revisionThrottler) recordInflightSkew() {
+	if rt.statsCtx == nil {
+		return
+	}
+	rt.mux.RLock()
+	// Requests through the private clusterIP can't be told apart by pod.
+	if rt.clusterIPTracker != nil || len(rt.assignedTrackers) == 0 {
+		rt.mux.RUnlock()
+		return
+	}
+	least, most := int32(math.MaxInt32), int32(0)
+	for _, t := range rt.assignedTrackers {
+		n := t.inFlight.Load()
+		if n < least {
+			least = n
+		}
+		if n > most {
+			most = n
+		}
+	}
+	rt.mux.RUnlock()
+	pkgmetrics.Record(rt.statsCtx, podInflightSkewM.M(int64(most-least)))
+}
+
 func (rt *revisionThrottler) calculateCapacity(size, activatorCount int) int {
 	targetCapacity := rt.containerConcurrency * size
 
@@ -269,15 +484,15 @@ func (rt *revisionThrottler) resetTrackers() {
 
 // updateCapacity updates the capacity of the throttler and recomputes
 // the assigned trackers to the Activator instance.
-// Currently updateCapacity is ensured to be invoked from a single go routine
-// and this does not synchronize
+// updateCapacity must be invoked with updateMux held, and this does not
+// synchronize otherwise.
 func (rt *revisionThrottler) updateCapacity(backendCount int) {
 	// We have to make assignments on each updateCapacity, since if number
 	// of activators changes, then we need to rebalance the assignedTrackers.
 	ac, ai := int(rt.numActivators.Load()), int(rt.activatorIndex.Load())
 	numTrackers := func() int {
 		// We do not have to process the `podTrackers` under lock, since
-		// updateCapacity is guaranteed to be executed by a single goroutine.
+		// updateCapacity is guaranteed to be executed under updateMux.
 		// But `assignedTrackers` is being read by the serving thread, so the
 		// actual assignment has to be done under lock.
 
@@ -307,7 +522,8 @@ func (rt *revisionThrottler) updateCapacity(backendCount int) {
 	if numTrackers > 0 {
 		// Capacity is computed based off of number of trackers,
 		// when using pod direct routing.
-		capacity = rt.calculateCapacity(len(rt.podTrackers), ac)
+		// The ejected pods get no requests.
+		capacity = rt.calculateCapacity(len(rt.podTrackers)-int(rt.outlier.ejected.Load()), ac)
 	} else {
 		// Capacity is computed off of number of ready backends,
 		// when we are using clusterIP routing.
@@ -405,6 +621,8 @@ func assignSlice(trackers []*podTracker, selfIndex, numActivators, cc int) []*po
 // This function will never be called in parallel but `try` can be called in parallel to this so we need
 // to lock on updating concurrency / trackers
 func (rt *revisionThrottler) handleUpdate(update revisionDestsUpdate) {
+	rt.updateMux.Lock()
+	defer rt.updateMux.Unlock()
 	rt.logger.Debugw("Handling update",
 		zap.String("ClusterIP", update.ClusterIPDest), zap.Object("dests", logging.StringSet(update.Dests)))
 
@@ -424,6 +642,7 @@ func (rt *revisionThrottler) handleUpdate(update revisionDestsUpdate) {
 		// a new one.
 		for newDest := range update.Dests {
 			tracker, ok := trackersMap[newDest]
+			delete(trackersMap, newDest)
 			if !ok {
 				if rt.containerConcurrency == 0 {
 					tracker = newPodTracker(newDest, nil)
@@ -437,11 +656,17 @@ func (rt *revisionThrottler) handleUpdate(update revisionDestsUpdate) {
 			}
 			trackers = append(trackers, tracker)
 		}
+		for _, tracker := range trackersMap {
+			rt.forget(tracker)
+		}
 
 		rt.updateThrottlerState(len(update.Dests), trackers, nil /*clusterIP*/)
 		return
 	}
 
+	for _, tracker := range rt.podTrackers {
+		rt.forget(tracker)
+	}
 	rt.updateThrottlerState(len(update.Dests), nil /*trackers*/, newPodTracker(update.ClusterIPDest, nil))
 }
 
@@ -454,6 +679,12 @@ type Throttler struct {
 	ipAddress               string // The IP address of this activator.
 	logger                  *zap.SugaredLogger
 	epsUpdateCh             chan *corev1.Endpoints
+
+	// probeMux guards the transport that the ejected pods are probed over,
+	// which is set by Run, and whether to probe them via the passthrough lb.
+	probeMux         sync.RWMutex
+	probeTransport   http.RoundTripper
+	usePassthroughLb bool
 }
 
 // NewThrottler creates a new Throttler
@@ -492,6 +723,9 @@ func NewThrottler(ctx context.Context, ipAddr string) *Throttler {
 
 // Run starts the throttler and blocks until the context is done.
 func (t *Throttler) Run(ctx context.Context, probeTransport http.RoundTripper, usePassthroughLb bool) {
+	t.probeMux.Lock()
+	t.probeTransport, t.usePassthroughLb = probeTransport, usePassthroughLb
+	t.probeMux.Unlock()
 	rbm := newRevisionBackendsManager(ctx, probeTransport, usePassthroughLb)
 	// Update channel is closed when ctx is done.
 	t.run(rbm.updates())
@@ -521,6 +755,18 @@ func (t *Throttler) Try(ctx context.Context, revID types.NamespacedName, functio
 	return rt.try(ctx, function)
 }
 
+// QueueDepth returns the number of requests for the revision waiting for
+// capacity, 0 if the throttler doesn't know the revision.
+func (t *Throttler) QueueDepth(revID types.NamespacedName) int {
+	t.revisionThrottlersMutex.RLock()
+	rt, ok := t.revisionThrottlers[revID]
+	t.revisionThrottlersMutex.RUnlock()
+	if !ok {
+		return 0
+	}
+	return rt.breaker.QueueDepth()
+}
+
 func (t *Throttler) getOrCreateRevisionThrottler(revID types.NamespacedName) (*revisionThrottler, error) {
 	// First, see if we can succeed with just an RLock. This is in the request path so optimizing
 	// for this case is important
@@ -547,6 +793,19 @@ func (t *Throttler) getOrCreateRevisionThrottler(revID types.NamespacedName) (*r
 			queue.BreakerParams{QueueDepth: breakerQueueDepth, MaxConcurrency: revisionMaxConcurrency},
 			t.logger,
 		)
+		revThrottler.retryBudget = retryBudgetFromAnnotations(rev.Annotations, clock.RealClock{})
+		revThrottler.outlier.probe = func(dest string) bool { return t.probe(revID, dest) }
+		if lbp, name := lbPolicyFromAnnotations(rev.Annotations); lbp != nil {
+			revThrottler.lbPolicy, revThrottler.lbPolicyName = lbp, name
+		}
+		if ctx, err := tag.New(metrics.RevisionContext(rev.Namespace, rev.Labels[serving.ServiceLabelKey],
+			rev.Labels[serving.ConfigurationLabelKey], rev.Name),
+			tag.Upsert(lbPolicyKey, revThrottler.lbPolicyName)); err != nil {
+			t.logger.Errorw("Failed to create the metrics context of the revision throttler",
+				zap.Error(err), zap.String(logkey.Key, revID.String()))
+		} else {
+			revThrottler.statsCtx = ctx
+		}
 		t.revisionThrottlers[revID] = revThrottler
 	}
 	return revThrottler, nil
@@ -576,9 +835,31 @@ func (t *Throttler) revisionDeleted(obj interface{}) {
 
 	t.revisionThrottlersMutex.Lock()
 	defer t.revisionThrottlersMutex.Unlock()
+	if rt, ok := t.revisionThrottlers[revID]; ok {
+		rt.outlier.stop()
+	}
 	delete(t.revisionThrottlers, revID)
 }
 
+// probe returns whether the pod of the revision at dest passes the probe,
+// readmitting it after an ejection. Pods are readmitted without probing until
+// Run is called.
+func (t *Throttler) probe(revID types.NamespacedName, dest string) bool {
+	t.probeMux.RLock()
+	transport, usePassthroughLb := t.probeTransport, t.usePassthroughLb
+	t.probeMux.RUnlock()
+	if transport == nil {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	ok, _, err := probeDest(ctx, transport, revID, dest, usePassthroughLb)
+	if err != nil {
+		t.logger.Debugw("Failed to probe pod "+dest, zap.Error(err), zap.String(logkey.Key, revID.String()))
+	}
+	return ok
+}
+
 func (t *Throttler) handleUpdate(update revisionDestsUpdate) {
 	if rt, err := t.getOrCreateRevisionThrottler(update.Rev); err != nil {
 		if k8serrors.IsNotFound(err) {
@@ -613,7 +894,8 @@ func (t *Throttler) handlePubEpsUpdate(eps *corev1.Endpoints) {
 }
 
 func (rt *revisionThrottler) handlePubEpsUpdate(eps *corev1.Endpoints, selfIP string) {
-	// NB: this is guaranteed to be executed on a single thread.
+	rt.updateMux.Lock()
+	defer rt.updateMux.Unlock()
 	epSet := healthyAddresses(eps, rt.protocol)
 	if !epSet.Has(selfIP) {
 		// No need to do anything, this activator is not in path.
@@ -697,6 +979,9 @@ type infiniteBreaker struct {
 	// immediately or wait for capacity to appear.
 	concurrency atomic.Int32
 
+	// pending counts the requests waiting for downstream capacity.
+	pending atomic.Int32
+
 	logger *zap.SugaredLogger
 }
 
@@ -755,15 +1040,23 @@ func (ib *infiniteBreaker) Maybe(ctx context.Context, thunk func()) error {
 	ib.mu.RLock()
 	ch = ib.broadcast
 	ib.mu.RUnlock()
+	ib.pending.Inc()
 	select {
 	case <-ch:
 		// Scaled up.
+		ib.pending.Dec()
 		thunk()
 		return nil
 	case <-ctx.Done():
+		ib.pending.Dec()
 		ib.logger.Info("Context is closed: ", ctx.Err())
 		return ctx.Err()
 	}
 }
 
 func (ib *infiniteBreaker) Reserve(context.Context) (func(), bool) { return noop, true }
+
+// QueueDepth returns the number of requests waiting for downstream capacity.
+func (ib *infiniteBreaker) QueueDepth() int {
+	return int(ib.pending.Load())
+}
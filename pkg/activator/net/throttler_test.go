@@ -19,6 +19,7 @@ package net
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math"
 	"strconv"
 	"sync"
@@ -38,6 +39,8 @@ import (
 	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
 	fakeendpointsinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/endpoints/fake"
 	. "knative.dev/pkg/logging/testing"
+	"knative.dev/pkg/metrics/metricstest"
+	_ "knative.dev/pkg/metrics/testing"
 	rtesting "knative.dev/pkg/reconciler/testing"
 	"knative.dev/serving/pkg/apis/serving"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
@@ -69,6 +72,7 @@ func TestThrottlerUpdateCapacity(t *testing.T) {
 		logger:               logger,
 		breaker:              queue.NewBreaker(testBreakerParams),
 		containerConcurrency: 10,
+		outlier:              newOutlierDetector(),
 	}
 
 	rt.updateCapacity(1)
@@ -207,6 +211,129 @@ func makeTrackers(num, cc int) []*podTracker {
 	return x
 }
 
+// resetThrottlerMetrics drops the metrics recorded by other tests, before and
+// after the test.
+func resetThrottlerMetrics(t *testing.T) {
+	reset := func() {
+		metricstest.Unregister(podInflightSkewM.Name(), podStreamCountM.Name(), proxyRetryCountM.Name(), podEjectionCountM.Name())
+		register()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestThrottlerLBPolicyAnnotation(t *testing.T) {
+	resetThrottlerMetrics(t)
+	ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+	servfake := fakeservingclient.Get(ctx)
+	revisions := fakerevisioninformer.Get(ctx)
+	waitInformers, err := rtesting.RunAndSyncInformers(ctx, revisions.Informer())
+	if err != nil {
+		t.Fatal("Failed to start informers:", err)
+	}
+	defer func() {
+		cancel()
+		waitInformers()
+	}()
+
+	revID := types.NamespacedName{Namespace: testNamespace, Name: testRevision}
+	rev := revision(revID, pkgnet.ProtocolHTTP1, 0)
+	rev.Annotations = map[string]string{
+		serving.ActivatorLoadBalancingPolicyAnnotation: leastInflightPolicyName,
+	}
+	servfake.ServingV1().Revisions(rev.Namespace).Create(ctx, rev, metav1.CreateOptions{})
+	revisions.Informer().GetIndexer().Add(rev)
+
+	throttler := newTestThrottler(ctx)
+	throttler.handleUpdate(revisionDestsUpdate{
+		Rev:   revID,
+		Dests: sets.NewString("128.0.0.1:1234", "128.0.0.2:1234"),
+	})
+	rt, err := throttler.getOrCreateRevisionThrottler(revID)
+	if err != nil {
+		t.Fatal("RevisionThrottler can't be found:", err)
+	}
+	if got, want := rt.lbPolicyName, leastInflightPolicyName; got != want {
+		t.Errorf("lbPolicyName = %q, want: %q", got, want)
+	}
+
+	// The busy pod is skipped by the policy, leaving a skew of 3-1.
+	busy := rt.assignedTrackers[0]
+	busy.inFlight.Store(3)
+	busy.increaseWeight()
+	if err := throttler.Try(ctx, revID, func(dest string) error {
+		if dest == busy.dest {
+			t.Errorf("Request was proxied to the busy pod %s", dest)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Try() = %v, want no error", err)
+	}
+	metricstest.AssertMetric(t, metricstest.IntMetric("pod_inflight_skew", 2, map[string]string{
+		"lb_policy": leastInflightPolicyName,
+	}))
+}
+
+func TestThrottlerStreams(t *testing.T) {
+	resetThrottlerMetrics(t)
+	ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
+	servfake := fakeservingclient.Get(ctx)
+	revisions := fakerevisioninformer.Get(ctx)
+	waitInformers, err := rtesting.RunAndSyncInformers(ctx, revisions.Informer())
+	if err != nil {
+		t.Fatal("Failed to start informers:", err)
+	}
+	defer func() {
+		cancel()
+		waitInformers()
+	}()
+
+	revID := types.NamespacedName{Namespace: testNamespace, Name: testRevision}
+	rev := revision(revID, pkgnet.ProtocolHTTP1, 0)
+	servfake.ServingV1().Revisions(rev.Namespace).Create(ctx, rev, metav1.CreateOptions{})
+	revisions.Informer().GetIndexer().Add(rev)
+
+	throttler := newTestThrottler(ctx)
+	throttler.handleUpdate(revisionDestsUpdate{
+		Rev:   revID,
+		Dests: sets.NewString("128.0.0.1:1234", "128.0.0.2:1234"),
+	})
+	rt, err := throttler.getOrCreateRevisionThrottler(revID)
+	if err != nil {
+		t.Fatal("RevisionThrottler can't be found:", err)
+	}
+
+	// The stream goes to the pod with the least streams, even though it has
+	// the most other requests in flight.
+	busy, streaming := rt.assignedTrackers[0], rt.assignedTrackers[1]
+	busy.increaseWeight()
+	busy.increaseWeight()
+	streaming.streams.Store(1)
+	if err := throttler.Try(WithStream(ctx), revID, func(dest string) error {
+		if dest != busy.dest {
+			t.Errorf("Stream was proxied to %s, want: %s", dest, busy.dest)
+		}
+		if got, want := busy.streams.Load(), int32(1); got != want {
+			t.Errorf("streams = %d, want: %d", got, want)
+		}
+		if got := busy.inFlight.Load(); got != 0 {
+			t.Errorf("inFlight = %d, want the stream not counted", got)
+		}
+		metricstest.AssertMetric(t, metricstest.IntMetric("pod_stream_count", 1, map[string]string{
+			"pod_address": busy.dest,
+		}))
+		return nil
+	}); err != nil {
+		t.Fatalf("Try() = %v, want no error", err)
+	}
+	if got := busy.streams.Load(); got != 0 {
+		t.Errorf("streams = %d, want: 0 once the stream finished", got)
+	}
+	metricstest.AssertMetric(t, metricstest.IntMetric("pod_stream_count", 0, map[string]string{
+		"pod_address": busy.dest,
+	}))
+}
+
 func TestThrottlerErrorNoRevision(t *testing.T) {
 	ctx, cancel, _ := rtesting.SetupFakeContextWithCancel(t)
 	servfake := fakeservingclient.Get(ctx)
@@ -917,6 +1044,80 @@ func TestInfiniteBreaker(t *testing.T) {
 	}
 }
 
+func TestRevisionThrottlerMaxQueueLength(t *testing.T) {
+	for _, cc := range []int{0, 10} {
+		t.Run(fmt.Sprint("cc=", cc), func(t *testing.T) {
+			rt := newRevisionThrottler(types.NamespacedName{Namespace: "a", Name: "b"}, cc,
+				pkgnet.ServicePortNameHTTP1, queue.BreakerParams{QueueDepth: 10, MaxConcurrency: revisionMaxConcurrency}, TestLogger(t))
+
+			// A request waits for a pod to show up.
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go rt.try(ctx, func(string) error { return nil })
+			if err := wait.PollImmediate(10*time.Millisecond, 3*time.Second, func() (bool, error) {
+				return rt.breaker.QueueDepth() == 1, nil
+			}); err != nil {
+				t.Fatal("The request didn't wait for a pod")
+			}
+
+			if err := rt.try(WithMaxQueueLength(ctx, 1), func(string) error { return nil }); !errors.Is(err, queue.ErrRequestQueueFull) {
+				t.Errorf("try() = %v, want: %v", err, queue.ErrRequestQueueFull)
+			}
+			tryCtx, tryCancel := context.WithTimeout(WithMaxQueueLength(ctx, 2), 10*time.Millisecond)
+			defer tryCancel()
+			if err := rt.try(tryCtx, func(string) error { return nil }); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("try() = %v, want: %v", err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
+
+func TestThrottlerQueueDepth(t *testing.T) {
+	revID := types.NamespacedName{Namespace: "a", Name: "b"}
+	rt := newRevisionThrottler(revID, 10, pkgnet.ServicePortNameHTTP1,
+		queue.BreakerParams{QueueDepth: 10, MaxConcurrency: revisionMaxConcurrency}, TestLogger(t))
+	throttler := &Throttler{revisionThrottlers: map[types.NamespacedName]*revisionThrottler{revID: rt}}
+
+	if got := throttler.QueueDepth(types.NamespacedName{Namespace: "a", Name: "unknown"}); got != 0 {
+		t.Errorf("QueueDepth(unknown) = %d, want: 0", got)
+	}
+
+	// The requests wait for a pod to show up.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		go rt.try(ctx, func(string) error { return nil })
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 3*time.Second, func() (bool, error) {
+		return throttler.QueueDepth(revID) == 2, nil
+	}); err != nil {
+		t.Errorf("QueueDepth() = %d, want: 2", throttler.QueueDepth(revID))
+	}
+}
+
+func TestRevisionThrottlerColdStartTimeout(t *testing.T) {
+	for _, cc := range []int{0, 10} {
+		t.Run(fmt.Sprint("cc=", cc), func(t *testing.T) {
+			rt := newRevisionThrottler(types.NamespacedName{Namespace: "a", Name: "b"}, cc,
+				pkgnet.ServicePortNameHTTP1, queue.BreakerParams{QueueDepth: 10, MaxConcurrency: revisionMaxConcurrency}, TestLogger(t))
+
+			var cold bool
+			ctx := WithColdStart(context.Background(), &cold)
+			ctx = WithColdStartTimeout(ctx, 10*time.Millisecond)
+			err := rt.try(ctx, func(string) error { return nil })
+			if !cold {
+				t.Error("The request wasn't reported as a cold start")
+			}
+			if !errors.Is(err, ErrColdStartTimeout) {
+				t.Errorf("try() = %v, want: %v", err, ErrColdStartTimeout)
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("try() = %v, want it to match %v", err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
+
 func TestInferIndex(t *testing.T) {
 	const myIP = "10.10.10.3"
 	tests := []struct {
@@ -21,10 +21,12 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/apis"
 	"knative.dev/serving/pkg/autoscaler/config/autoscalerconfig"
 )
@@ -53,12 +55,27 @@ func getIntGE0(m map[string]string, k string) (int32, *apis.FieldError) {
 func ValidateAnnotations(ctx context.Context, config *autoscalerconfig.Config, anns map[string]string) *apis.FieldError {
 	return validateClass(anns).
 		Also(validateMinMaxScale(config, anns)).
+		Also(validateScaleSchedule(config, anns)).
 		Also(validateFloats(anns)).
 		Also(validateWindow(anns)).
 		Also(validateLastPodRetention(anns)).
+		Also(validateEnableScaleToZero(anns)).
+		Also(validateMinScaleUnavailablePolicy(anns)).
 		Also(validateScaleDownDelay(anns)).
+		Also(validateMaxScaleDownPerMinute(anns)).
+		Also(validatePredictionPeriod(config, anns)).
+		Also(validateConcurrencyStatePauseDelay(anns)).
+		Also(validateKeepWarmPeriod(anns)).
+		Also(validateScaleToZeroExclusionPeriod(anns)).
+		Also(validatePanicExitThreshold(config, anns)).
+		Also(validatePanicCooldown(anns)).
+		Also(validateActivatorSubsetSize(anns)).
 		Also(validateMetric(anns)).
+		Also(validateCustomMetric(anns)).
+		Also(validateExternalMetric(anns)).
+		Also(validateCombinedMetrics(anns)).
 		Also(validateAlgorithm(anns)).
+		Also(validateScalingAlgorithm(anns)).
 		Also(validateInitialScale(config, anns))
 }
 
@@ -87,6 +104,22 @@ func validateAlgorithm(annotations map[string]string) *apis.FieldError {
 	return nil
 }
 
+func validateScalingAlgorithm(annotations map[string]string) *apis.FieldError {
+	// Not a KPA? Don't validate, custom autoscalers might have custom values.
+	if c, ok := annotations[ClassAnnotationKey]; ok && c != KPA {
+		return nil
+	}
+	if a, ok := annotations[ScalingAlgorithmAnnotationKey]; ok {
+		switch a {
+		case ScalingAlgorithmWindow:
+			return nil
+		default:
+			return apis.ErrInvalidValue(a, ScalingAlgorithmAnnotationKey)
+		}
+	}
+	return nil
+}
+
 func validateFloats(annotations map[string]string) (errs *apis.FieldError) {
 	if v, ok := annotations[PanicWindowPercentageAnnotationKey]; ok {
 		if fv, err := strconv.ParseFloat(v, 64); err != nil {
@@ -143,6 +176,118 @@ func validateScaleDownDelay(annotations map[string]string) *apis.FieldError {
 	return errs
 }
 
+func validateMaxScaleDownPerMinute(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[MaxScaleDownPerMinuteAnnotationKey]; ok {
+		if _, err := ParseScaleDownLimit(v); err != nil {
+			fe := apis.ErrInvalidValue(v, MaxScaleDownPerMinuteAnnotationKey)
+			fe.Details = err.Error()
+			return fe
+		}
+	}
+	return nil
+}
+
+func validatePredictionPeriod(config *autoscalerconfig.Config, annotations map[string]string) *apis.FieldError {
+	if w, ok := annotations[PredictionPeriodAnnotationKey]; ok {
+		d, err := time.ParseDuration(w)
+		switch {
+		case err != nil:
+			return apis.ErrInvalidValue(w, PredictionPeriodAnnotationKey)
+		case d == 0:
+			return nil
+		case d < 0 || d > PredictionPeriodMax:
+			return apis.ErrOutOfBoundsValue(w, time.Duration(0), PredictionPeriodMax, PredictionPeriodAnnotationKey)
+		case d.Round(time.Minute) != d:
+			return apis.ErrGeneric("must be specified with at most minute precision", PredictionPeriodAnnotationKey)
+		case config.PredictionLookahead >= d:
+			return apis.ErrGeneric(fmt.Sprintf("must be longer than the prediction-lookahead of %v", config.PredictionLookahead),
+				PredictionPeriodAnnotationKey)
+		}
+	}
+	return nil
+}
+
+func validateConcurrencyStatePauseDelay(annotations map[string]string) *apis.FieldError {
+	if w, ok := annotations[ConcurrencyStatePauseDelayAnnotationKey]; ok {
+		if d, err := time.ParseDuration(w); err != nil {
+			return apis.ErrInvalidValue(w, ConcurrencyStatePauseDelayAnnotationKey)
+		} else if d < 0 || d > WindowMax {
+			return apis.ErrOutOfBoundsValue(w, time.Duration(0), WindowMax, ConcurrencyStatePauseDelayAnnotationKey)
+		} else if d.Round(time.Millisecond) != d {
+			return apis.ErrGeneric("must be specified with at most millisecond precision", ConcurrencyStatePauseDelayAnnotationKey)
+		}
+	}
+	return nil
+}
+
+func validateActivatorSubsetSize(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[ActivatorSubsetSizeAnnotationKey]; ok {
+		if n, err := strconv.ParseInt(v, 10, 32); err != nil {
+			return apis.ErrInvalidValue(v, ActivatorSubsetSizeAnnotationKey)
+		} else if n < 1 {
+			return apis.ErrOutOfBoundsValue(v, 1, math.MaxInt32, ActivatorSubsetSizeAnnotationKey)
+		}
+	}
+	return nil
+}
+
+func validateKeepWarmPeriod(annotations map[string]string) *apis.FieldError {
+	if w, ok := annotations[KeepWarmPeriodAnnotationKey]; ok {
+		if d, err := time.ParseDuration(w); err != nil {
+			return apis.ErrInvalidValue(w, KeepWarmPeriodAnnotationKey)
+		} else if d < 0 || d > KeepWarmPeriodMax {
+			return apis.ErrOutOfBoundsValue(w, time.Duration(0), KeepWarmPeriodMax, KeepWarmPeriodAnnotationKey)
+		}
+	}
+	return nil
+}
+
+func validateScaleToZeroExclusionPeriod(annotations map[string]string) *apis.FieldError {
+	if w, ok := annotations[ScaleToZeroExclusionPeriodAnnotationKey]; ok {
+		if d, err := time.ParseDuration(w); err != nil {
+			return apis.ErrInvalidValue(w, ScaleToZeroExclusionPeriodAnnotationKey)
+		} else if d < 0 || d > ScaleToZeroExclusionPeriodMax {
+			return apis.ErrOutOfBoundsValue(w, time.Duration(0), ScaleToZeroExclusionPeriodMax, ScaleToZeroExclusionPeriodAnnotationKey)
+		}
+	}
+	return nil
+}
+
+func validatePanicExitThreshold(config *autoscalerconfig.Config, annotations map[string]string) *apis.FieldError {
+	v, ok := annotations[PanicExitThresholdPercentageAnnotationKey]
+	if !ok {
+		return nil
+	}
+	fv, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return apis.ErrInvalidValue(v, PanicExitThresholdPercentageAnnotationKey)
+	}
+	// Panic mode must be left below the level it's entered at.
+	panicThreshold := config.PanicThresholdPercentage
+	if pv, ok := annotations[PanicThresholdPercentageAnnotationKey]; ok {
+		if panicThreshold, err = strconv.ParseFloat(pv, 64); err != nil {
+			// Reported by validateFloats.
+			return nil
+		}
+	}
+	if fv < PanicExitThresholdPercentageMin || fv > panicThreshold {
+		return apis.ErrOutOfBoundsValue(v, PanicExitThresholdPercentageMin, panicThreshold,
+			PanicExitThresholdPercentageAnnotationKey)
+	}
+	return nil
+}
+
+func validatePanicCooldown(annotations map[string]string) *apis.FieldError {
+	if w, ok := annotations[PanicCooldownAnnotationKey]; ok {
+		if d, err := time.ParseDuration(w); err != nil {
+			return apis.ErrInvalidValue(w, PanicCooldownAnnotationKey)
+		} else if d < PanicCooldownMin || d > WindowMax {
+			return apis.ErrOutOfBoundsValue(w, PanicCooldownMin, WindowMax, PanicCooldownAnnotationKey)
+		}
+	}
+	return nil
+}
+
 func validateLastPodRetention(annotations map[string]string) *apis.FieldError {
 	if w, ok := annotations[ScaleToZeroPodRetentionPeriodKey]; ok {
 		if d, err := time.ParseDuration(w); err != nil {
@@ -156,9 +301,28 @@ func validateLastPodRetention(annotations map[string]string) *apis.FieldError {
 	return nil
 }
 
+func validateEnableScaleToZero(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[EnableScaleToZeroAnnotationKey]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return apis.ErrInvalidValue(v, EnableScaleToZeroAnnotationKey)
+		}
+	}
+	return nil
+}
+
+func validateMinScaleUnavailablePolicy(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[MinScaleUnavailablePolicyAnnotationKey]; ok {
+		if v != MinScaleUnavailablePolicyHold && v != MinScaleUnavailablePolicyBestEffort {
+			return apis.ErrInvalidValue(v, MinScaleUnavailablePolicyAnnotationKey)
+		}
+	}
+	return nil
+}
+
 func validateWindow(annotations map[string]string) *apis.FieldError {
 	if w, ok := annotations[WindowAnnotationKey]; ok {
-		if annotations[ClassAnnotationKey] == HPA && (annotations[MetricAnnotationKey] == CPU || annotations[MetricAnnotationKey] == Memory) {
+		if annotations[ClassAnnotationKey] == HPA && (annotations[MetricAnnotationKey] == CPU ||
+			annotations[MetricAnnotationKey] == Memory || annotations[MetricAnnotationKey] == External) {
 			return apis.ErrInvalidKeyName(WindowAnnotationKey, apis.CurrentField, fmt.Sprintf("%s for %s %s", HPA,
 				MetricAnnotationKey, annotations[MetricAnnotationKey]))
 		}
@@ -193,6 +357,29 @@ func validateMinMaxScale(config *autoscalerconfig.Config, annotations map[string
 	return errs
 }
 
+func validateScaleSchedule(config *autoscalerconfig.Config, annotations map[string]string) *apis.FieldError {
+	v, ok := annotations[ScaleScheduleAnnotationKey]
+	if !ok {
+		return nil
+	}
+	sched, err := ParseScaleSchedule(v)
+	if err != nil {
+		fe := apis.ErrInvalidValue(v, ScaleScheduleAnnotationKey)
+		fe.Details = err.Error()
+		return fe
+	}
+	if config.MaxScaleLimit == 0 {
+		return nil
+	}
+	var errs *apis.FieldError
+	for _, w := range sched {
+		if w.Max != nil && (*w.Max == 0 || *w.Max > config.MaxScaleLimit) {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(*w.Max, 1, config.MaxScaleLimit, ScaleScheduleAnnotationKey))
+		}
+	}
+	return errs
+}
+
 func validateMaxScaleWithinLimit(maxScale, maxScaleLimit int32) (errs *apis.FieldError) {
 	if maxScaleLimit == 0 {
 		return nil
@@ -221,12 +408,12 @@ func validateMetric(annotations map[string]string) *apis.FieldError {
 		switch classValue {
 		case KPA:
 			switch metric {
-			case Concurrency, RPS:
+			case Concurrency, RPS, Custom, GPU:
 				return nil
 			}
 		case HPA:
 			switch metric {
-			case CPU, Memory:
+			case CPU, Memory, External:
 				return nil
 			}
 		default:
@@ -238,6 +425,155 @@ func validateMetric(annotations map[string]string) *apis.FieldError {
 	return nil
 }
 
+// customMetricNameRegexp matches the valid Prometheus metric names.
+var customMetricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+func validateCustomMetric(annotations map[string]string) *apis.FieldError {
+	var errs *apis.FieldError
+	custom := annotations[MetricAnnotationKey] == Custom || combinesMetric(annotations, Custom)
+	// The gpu metric is scraped the same way, from defaults the annotations
+	// may override.
+	gpu := annotations[MetricAnnotationKey] == GPU
+	for _, k := range []string{CustomMetricNameAnnotationKey, CustomMetricPortAnnotationKey, CustomMetricPathAnnotationKey} {
+		_, ok := annotations[k]
+		if ok && !custom && !gpu {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("only allowed with the %s or %s metric", Custom, GPU), k))
+		} else if !ok && custom && k != CustomMetricPathAnnotationKey {
+			errs = errs.Also(apis.ErrMissingField(k))
+		}
+	}
+	if _, ok := annotations[TargetAnnotationKey]; annotations[MetricAnnotationKey] == Custom && !ok {
+		errs = errs.Also(apis.ErrMissingField(TargetAnnotationKey))
+	}
+	if gpu && custom {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("the %s metric can't be combined with the %s metric", Custom, GPU),
+			CombinedMetricsAnnotationKey))
+	}
+	if v, ok := annotations[TargetAnnotationKey]; ok && gpu {
+		if fv, err := strconv.ParseFloat(v, 64); err == nil && fv > GPUUtilizationMax {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(v, TargetMin, GPUUtilizationMax, TargetAnnotationKey))
+		}
+	}
+
+	if name, ok := annotations[CustomMetricNameAnnotationKey]; ok && !customMetricNameRegexp.MatchString(name) {
+		errs = errs.Also(apis.ErrInvalidValue(name, CustomMetricNameAnnotationKey))
+	}
+	if v, ok := annotations[CustomMetricPortAnnotationKey]; ok {
+		if port, err := strconv.Atoi(v); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, CustomMetricPortAnnotationKey))
+		} else if port < 1 || port > 65535 {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(v, 1, 65535, CustomMetricPortAnnotationKey))
+		}
+	}
+	if path, ok := annotations[CustomMetricPathAnnotationKey]; ok && !strings.HasPrefix(path, "/") {
+		errs = errs.Also(apis.ErrInvalidValue(path, CustomMetricPathAnnotationKey))
+	}
+	return errs
+}
+
+func validateExternalMetric(annotations map[string]string) *apis.FieldError {
+	var errs *apis.FieldError
+	external := annotations[MetricAnnotationKey] == External
+	for _, k := range []string{ExternalMetricNameAnnotationKey, ExternalMetricSelectorAnnotationKey} {
+		_, ok := annotations[k]
+		if ok && !external {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("only allowed with the %s metric", External), k))
+		} else if !ok && external && k == ExternalMetricNameAnnotationKey {
+			errs = errs.Also(apis.ErrMissingField(k))
+		}
+	}
+	if _, ok := annotations[TargetAnnotationKey]; external && !ok {
+		errs = errs.Also(apis.ErrMissingField(TargetAnnotationKey))
+	}
+
+	if name, ok := annotations[ExternalMetricNameAnnotationKey]; ok && strings.TrimSpace(name) == "" {
+		errs = errs.Also(apis.ErrInvalidValue(name, ExternalMetricNameAnnotationKey))
+	}
+	if sel, ok := annotations[ExternalMetricSelectorAnnotationKey]; ok {
+		if _, err := metav1.ParseToLabelSelector(sel); err != nil {
+			fe := apis.ErrInvalidValue(sel, ExternalMetricSelectorAnnotationKey)
+			fe.Details = err.Error()
+			errs = errs.Also(fe)
+		}
+	}
+	return errs
+}
+
+// combinesMetric returns whether the metric is one of the valid
+// CombinedMetricsAnnotationKey annotation.
+func combinesMetric(annotations map[string]string, metric string) bool {
+	values, err := ParseMetricValues(annotations[CombinedMetricsAnnotationKey])
+	if err != nil {
+		return false
+	}
+	for _, v := range values {
+		if v.Metric == metric {
+			return true
+		}
+	}
+	return false
+}
+
+func validateCombinedMetrics(annotations map[string]string) *apis.FieldError {
+	v, ok := annotations[CombinedMetricsAnnotationKey]
+	if !ok {
+		for _, k := range []string{MetricCombinationAnnotationKey, MetricWeightsAnnotationKey} {
+			if _, ok := annotations[k]; ok {
+				return apis.ErrGeneric("only allowed with "+CombinedMetricsAnnotationKey, k)
+			}
+		}
+		return nil
+	}
+	if c, ok := annotations[ClassAnnotationKey]; ok && c != KPA {
+		return apis.ErrGeneric("only allowed with "+ClassAnnotationKey+": "+KPA, CombinedMetricsAnnotationKey)
+	}
+
+	var errs *apis.FieldError
+	metric := annotations[MetricAnnotationKey]
+	if metric == "" {
+		metric = Concurrency
+	}
+	metrics := map[string]bool{metric: true}
+	combined, err := ParseMetricValues(v)
+	if err != nil {
+		fe := apis.ErrInvalidValue(v, CombinedMetricsAnnotationKey)
+		fe.Details = err.Error()
+		errs = errs.Also(fe)
+	}
+	for _, m := range combined {
+		if m.Metric == metric {
+			errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%q is already the %s", metric, MetricAnnotationKey),
+				CombinedMetricsAnnotationKey))
+		}
+		metrics[m.Metric] = true
+	}
+
+	combination := annotations[MetricCombinationAnnotationKey]
+	switch combination {
+	case "", MetricCombinationMax, MetricCombinationMin, MetricCombinationWeighted:
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(combination, MetricCombinationAnnotationKey))
+	}
+	if w, ok := annotations[MetricWeightsAnnotationKey]; ok {
+		if combination != MetricCombinationWeighted {
+			return errs.Also(apis.ErrGeneric(fmt.Sprintf("only allowed with %s: %s",
+				MetricCombinationAnnotationKey, MetricCombinationWeighted), MetricWeightsAnnotationKey))
+		}
+		weights, err := ParseMetricValues(w)
+		if err != nil {
+			fe := apis.ErrInvalidValue(w, MetricWeightsAnnotationKey)
+			fe.Details = err.Error()
+			return errs.Also(fe)
+		}
+		for _, m := range weights {
+			if !metrics[m.Metric] {
+				errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("%q is not scaled on", m.Metric), MetricWeightsAnnotationKey))
+			}
+		}
+	}
+	return errs
+}
+
 func validateInitialScale(config *autoscalerconfig.Config, annotations map[string]string) *apis.FieldError {
 	if initialScale, ok := annotations[InitialScaleAnnotationKey]; ok {
 		initScaleInt, err := strconv.Atoi(initialScale)
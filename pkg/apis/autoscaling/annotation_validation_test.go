@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
@@ -280,6 +281,318 @@ func TestValidateAnnotations(t *testing.T) {
 		name:        "invalid last pod scaledown timeout",
 		annotations: map[string]string{ScaleToZeroPodRetentionPeriodKey: "twenty-two-minutes-and-five-seconds"},
 		expectErr:   "invalid value: twenty-two-minutes-and-five-seconds: " + ScaleToZeroPodRetentionPeriodKey,
+	}, {
+		name:        "valid keep warm period",
+		annotations: map[string]string{KeepWarmPeriodAnnotationKey: "2h"},
+	}, {
+		name:        "too long keep warm period",
+		annotations: map[string]string{KeepWarmPeriodAnnotationKey: "25h"},
+		expectErr:   "expected 0s <= 25h <= 24h0m0s: " + KeepWarmPeriodAnnotationKey,
+	}, {
+		name:        "invalid keep warm period",
+		annotations: map[string]string{KeepWarmPeriodAnnotationKey: "forever"},
+		expectErr:   "invalid value: forever: " + KeepWarmPeriodAnnotationKey,
+	}, {
+		name:        "valid scale to zero exclusion period",
+		annotations: map[string]string{ScaleToZeroExclusionPeriodAnnotationKey: "15m"},
+	}, {
+		name:        "too long scale to zero exclusion period",
+		annotations: map[string]string{ScaleToZeroExclusionPeriodAnnotationKey: "25h"},
+		expectErr:   "expected 0s <= 25h <= 24h0m0s: " + ScaleToZeroExclusionPeriodAnnotationKey,
+	}, {
+		name:        "negative scale to zero exclusion period",
+		annotations: map[string]string{ScaleToZeroExclusionPeriodAnnotationKey: "-1m"},
+		expectErr:   "expected 0s <= -1m <= 24h0m0s: " + ScaleToZeroExclusionPeriodAnnotationKey,
+	}, {
+		name:        "invalid scale to zero exclusion period",
+		annotations: map[string]string{ScaleToZeroExclusionPeriodAnnotationKey: "a while"},
+		expectErr:   "invalid value: a while: " + ScaleToZeroExclusionPeriodAnnotationKey,
+	}, {
+		name: "valid panic exit threshold",
+		annotations: map[string]string{
+			PanicThresholdPercentageAnnotationKey:     "300",
+			PanicExitThresholdPercentageAnnotationKey: "250",
+		},
+	}, {
+		name:        "panic exit threshold above the default panic threshold",
+		annotations: map[string]string{PanicExitThresholdPercentageAnnotationKey: "250"},
+		configMutator: func(config *autoscalerconfig.Config) {
+			config.PanicThresholdPercentage = 200
+		},
+		expectErr: "expected 100 <= 250 <= 200: " + PanicExitThresholdPercentageAnnotationKey,
+	}, {
+		name:        "panic exit threshold below the target",
+		annotations: map[string]string{PanicExitThresholdPercentageAnnotationKey: "90"},
+		configMutator: func(config *autoscalerconfig.Config) {
+			config.PanicThresholdPercentage = 200
+		},
+		expectErr: "expected 100 <= 90 <= 200: " + PanicExitThresholdPercentageAnnotationKey,
+	}, {
+		name:        "invalid panic exit threshold",
+		annotations: map[string]string{PanicExitThresholdPercentageAnnotationKey: "half"},
+		expectErr:   "invalid value: half: " + PanicExitThresholdPercentageAnnotationKey,
+	}, {
+		name:        "valid panic cooldown",
+		annotations: map[string]string{PanicCooldownAnnotationKey: "2m"},
+	}, {
+		name:        "too short panic cooldown",
+		annotations: map[string]string{PanicCooldownAnnotationKey: "100ms"},
+		expectErr:   "expected 1s <= 100ms <= 1h0m0s: " + PanicCooldownAnnotationKey,
+	}, {
+		name:        "valid enable scale to zero",
+		annotations: map[string]string{EnableScaleToZeroAnnotationKey: "false"},
+	}, {
+		name:        "invalid enable scale to zero",
+		annotations: map[string]string{EnableScaleToZeroAnnotationKey: "sometimes"},
+		expectErr:   "invalid value: sometimes: " + EnableScaleToZeroAnnotationKey,
+	}, {
+		name:        "valid min scale unavailable policy",
+		annotations: map[string]string{MinScaleUnavailablePolicyAnnotationKey: MinScaleUnavailablePolicyBestEffort},
+	}, {
+		name:        "invalid min scale unavailable policy",
+		annotations: map[string]string{MinScaleUnavailablePolicyAnnotationKey: "Wait"},
+		expectErr:   "invalid value: Wait: " + MinScaleUnavailablePolicyAnnotationKey,
+	}, {
+		name:        "valid concurrency state pause delay",
+		annotations: map[string]string{ConcurrencyStatePauseDelayAnnotationKey: "1500ms"},
+	}, {
+		name:        "invalid concurrency state pause delay",
+		annotations: map[string]string{ConcurrencyStatePauseDelayAnnotationKey: "soon"},
+		expectErr:   "invalid value: soon: " + ConcurrencyStatePauseDelayAnnotationKey,
+	}, {
+		name:        "negative concurrency state pause delay",
+		annotations: map[string]string{ConcurrencyStatePauseDelayAnnotationKey: "-1s"},
+		expectErr:   "expected 0s <= -1s <= 1h0m0s: " + ConcurrencyStatePauseDelayAnnotationKey,
+	}, {
+		name:        "concurrency state pause delay too precise",
+		annotations: map[string]string{ConcurrencyStatePauseDelayAnnotationKey: "1.5ms"},
+		expectErr:   "must be specified with at most millisecond precision: " + ConcurrencyStatePauseDelayAnnotationKey,
+	}, {
+		name:        "valid scale schedule",
+		annotations: map[string]string{ScaleScheduleAnnotationKey: "0 8 * * 1-5 min=5; 0 20 * * * min=0 max=10"},
+	}, {
+		name:        "invalid scale schedule",
+		annotations: map[string]string{ScaleScheduleAnnotationKey: "0 25 * * * min=5"},
+		expectErr:   "invalid value: 0 25 * * * min=5: " + ScaleScheduleAnnotationKey + "\n" + `"0 25 * * * min=5": "25" is out of the range 0-23`,
+	}, {
+		name:          "scale schedule max over the limit",
+		annotations:   map[string]string{ScaleScheduleAnnotationKey: "0 8 * * * max=20; 0 20 * * * max=0"},
+		configMutator: func(config *autoscalerconfig.Config) { config.MaxScaleLimit = 10 },
+		expectErr:     "expected 1 <= 0 <= 10: " + ScaleScheduleAnnotationKey + "\nexpected 1 <= 20 <= 10: " + ScaleScheduleAnnotationKey,
+	}, {
+		name:        "valid activator subset size",
+		annotations: map[string]string{ActivatorSubsetSizeAnnotationKey: "5"},
+	}, {
+		name:        "invalid activator subset size",
+		annotations: map[string]string{ActivatorSubsetSizeAnnotationKey: "many"},
+		expectErr:   "invalid value: many: " + ActivatorSubsetSizeAnnotationKey,
+	}, {
+		name:        "zero activator subset size",
+		annotations: map[string]string{ActivatorSubsetSizeAnnotationKey: "0"},
+		expectErr:   "expected 1 <= 0 <= 2147483647: " + ActivatorSubsetSizeAnnotationKey,
+	}, {
+		name:        "valid scaling algorithm",
+		annotations: map[string]string{ScalingAlgorithmAnnotationKey: ScalingAlgorithmWindow},
+	}, {
+		name:        "invalid scaling algorithm",
+		annotations: map[string]string{ScalingAlgorithmAnnotationKey: "magic"},
+		expectErr:   "invalid value: magic: " + ScalingAlgorithmAnnotationKey,
+	}, {
+		name: "scaling algorithm of another class",
+		annotations: map[string]string{
+			ClassAnnotationKey:            "other.autoscaler.io",
+			ScalingAlgorithmAnnotationKey: "magic",
+		},
+	}, {
+		name:        "valid max scale down per minute",
+		annotations: map[string]string{MaxScaleDownPerMinuteAnnotationKey: "5"},
+	}, {
+		name:        "valid max scale down percentage per minute",
+		annotations: map[string]string{MaxScaleDownPerMinuteAnnotationKey: "10%"},
+	}, {
+		name:        "invalid max scale down per minute",
+		annotations: map[string]string{MaxScaleDownPerMinuteAnnotationKey: "0"},
+		expectErr:   "invalid value: 0: " + MaxScaleDownPerMinuteAnnotationKey + "\n" + `"0": want a positive number of pods or a percentage`,
+	}, {
+		name:        "valid prediction period",
+		annotations: map[string]string{PredictionPeriodAnnotationKey: "24h"},
+	}, {
+		name:        "prediction disabled",
+		annotations: map[string]string{PredictionPeriodAnnotationKey: "0s"},
+	}, {
+		name:        "invalid prediction period",
+		annotations: map[string]string{PredictionPeriodAnnotationKey: "daily"},
+		expectErr:   "invalid value: daily: " + PredictionPeriodAnnotationKey,
+	}, {
+		name:        "prediction period too long",
+		annotations: map[string]string{PredictionPeriodAnnotationKey: "169h"},
+		expectErr:   "expected 0s <= 169h <= 168h0m0s: " + PredictionPeriodAnnotationKey,
+	}, {
+		name:        "prediction period too precise",
+		annotations: map[string]string{PredictionPeriodAnnotationKey: "1h30s"},
+		expectErr:   "must be specified with at most minute precision: " + PredictionPeriodAnnotationKey,
+	}, {
+		name:        "prediction period within the lookahead",
+		annotations: map[string]string{PredictionPeriodAnnotationKey: "10m"},
+		configMutator: func(config *autoscalerconfig.Config) {
+			config.PredictionLookahead = 10 * time.Minute
+		},
+		expectErr: "must be longer than the prediction-lookahead of 10m0s: " + PredictionPeriodAnnotationKey,
+	}, {
+		name: "valid custom metric",
+		annotations: map[string]string{
+			MetricAnnotationKey:           Custom,
+			CustomMetricNameAnnotationKey: "jobs_in_flight",
+			CustomMetricPortAnnotationKey: "9090",
+			CustomMetricPathAnnotationKey: "/stats/metrics",
+			TargetAnnotationKey:           "5",
+		},
+	}, {
+		name:        "custom metric without its annotations",
+		annotations: map[string]string{MetricAnnotationKey: Custom},
+		expectErr: "missing field(s): " + CustomMetricNameAnnotationKey + ", " +
+			CustomMetricPortAnnotationKey + ", " + TargetAnnotationKey,
+	}, {
+		name: "invalid custom metric",
+		annotations: map[string]string{
+			MetricAnnotationKey:           Custom,
+			CustomMetricNameAnnotationKey: "jobs-in-flight",
+			CustomMetricPortAnnotationKey: "65536",
+			CustomMetricPathAnnotationKey: "metrics",
+			TargetAnnotationKey:           "5",
+		},
+		expectErr: "expected 1 <= 65536 <= 65535: " + CustomMetricPortAnnotationKey + "\n" +
+			"invalid value: jobs-in-flight: " + CustomMetricNameAnnotationKey + "\n" +
+			"invalid value: metrics: " + CustomMetricPathAnnotationKey,
+	}, {
+		name: "custom metric annotations without the custom metric",
+		annotations: map[string]string{
+			CustomMetricNameAnnotationKey: "jobs_in_flight",
+		},
+		expectErr: "only allowed with the custom or gpu metric: " + CustomMetricNameAnnotationKey,
+	}, {
+		name: "valid external metric",
+		annotations: map[string]string{
+			ClassAnnotationKey:                  HPA,
+			MetricAnnotationKey:                 External,
+			ExternalMetricNameAnnotationKey:     "kafka_consumergroup_lag",
+			ExternalMetricSelectorAnnotationKey: "topic=orders,consumergroup=knative",
+			TargetAnnotationKey:                 "100",
+		},
+	}, {
+		name: "external metric without its annotations",
+		annotations: map[string]string{
+			ClassAnnotationKey:  HPA,
+			MetricAnnotationKey: External,
+		},
+		expectErr: "missing field(s): " + ExternalMetricNameAnnotationKey + ", " + TargetAnnotationKey,
+	}, {
+		name: "invalid external metric selector",
+		annotations: map[string]string{
+			ClassAnnotationKey:                  HPA,
+			MetricAnnotationKey:                 External,
+			ExternalMetricNameAnnotationKey:     "kafka_consumergroup_lag",
+			ExternalMetricSelectorAnnotationKey: "topic in orders",
+			TargetAnnotationKey:                 "100",
+		},
+		expectErr: "invalid value: topic in orders: " + ExternalMetricSelectorAnnotationKey + "\n" +
+			`couldn't parse the selector string "topic in orders": unable to parse requirement: found 'orders' expected: '('`,
+	}, {
+		name: "external metric with KPA",
+		annotations: map[string]string{
+			MetricAnnotationKey:             External,
+			ExternalMetricNameAnnotationKey: "kafka_consumergroup_lag",
+			TargetAnnotationKey:             "100",
+		},
+		expectErr: "invalid value: external: " + MetricAnnotationKey,
+	}, {
+		name:        "external metric annotations without the external metric",
+		annotations: map[string]string{ExternalMetricNameAnnotationKey: "kafka_consumergroup_lag"},
+		expectErr:   "only allowed with the external metric: " + ExternalMetricNameAnnotationKey,
+	}, {
+		name:        "gpu metric",
+		annotations: map[string]string{MetricAnnotationKey: GPU},
+	}, {
+		name: "gpu metric from another exporter",
+		annotations: map[string]string{
+			MetricAnnotationKey:           GPU,
+			CustomMetricNameAnnotationKey: "nvml_gpu_utilization",
+			CustomMetricPortAnnotationKey: "9090",
+			TargetAnnotationKey:           "80",
+		},
+	}, {
+		name: "gpu metric target above 100%",
+		annotations: map[string]string{
+			MetricAnnotationKey: GPU,
+			TargetAnnotationKey: "150",
+		},
+		expectErr: "expected 0.01 <= 150 <= 100: " + TargetAnnotationKey,
+	}, {
+		name: "gpu metric combined with the custom metric",
+		annotations: map[string]string{
+			MetricAnnotationKey:           GPU,
+			CombinedMetricsAnnotationKey:  "custom=5",
+			CustomMetricNameAnnotationKey: "jobs_in_flight",
+			CustomMetricPortAnnotationKey: "9090",
+		},
+		expectErr: "the custom metric can't be combined with the gpu metric: " + CombinedMetricsAnnotationKey,
+	}, {
+		name: "custom metric combined",
+		annotations: map[string]string{
+			CombinedMetricsAnnotationKey:  "rps=150, custom=5",
+			CustomMetricNameAnnotationKey: "jobs_in_flight",
+			CustomMetricPortAnnotationKey: "9090",
+		},
+	}, {
+		name: "valid weighted combination",
+		annotations: map[string]string{
+			MetricAnnotationKey:            RPS,
+			CombinedMetricsAnnotationKey:   "concurrency=10",
+			MetricCombinationAnnotationKey: MetricCombinationWeighted,
+			MetricWeightsAnnotationKey:     "rps=3, concurrency=1",
+		},
+	}, {
+		name:        "invalid combined metrics",
+		annotations: map[string]string{CombinedMetricsAnnotationKey: "rps=150, cpu=80"},
+		expectErr:   "invalid value: rps=150, cpu=80: " + CombinedMetricsAnnotationKey + "\n" + `"cpu=80": unknown metric "cpu"`,
+	}, {
+		name:        "combined metric is the metric",
+		annotations: map[string]string{CombinedMetricsAnnotationKey: "concurrency=10"},
+		expectErr:   `"concurrency" is already the ` + MetricAnnotationKey + ": " + CombinedMetricsAnnotationKey,
+	}, {
+		name: "combined metrics with HPA",
+		annotations: map[string]string{
+			ClassAnnotationKey:           HPA,
+			MetricAnnotationKey:          CPU,
+			CombinedMetricsAnnotationKey: "rps=150",
+		},
+		expectErr: "only allowed with " + ClassAnnotationKey + ": " + KPA + ": " + CombinedMetricsAnnotationKey,
+	}, {
+		name: "invalid metric combination",
+		annotations: map[string]string{
+			CombinedMetricsAnnotationKey:   "rps=150",
+			MetricCombinationAnnotationKey: "avg",
+		},
+		expectErr: "invalid value: avg: " + MetricCombinationAnnotationKey,
+	}, {
+		name:        "metric combination without combined metrics",
+		annotations: map[string]string{MetricCombinationAnnotationKey: MetricCombinationMin},
+		expectErr:   "only allowed with " + CombinedMetricsAnnotationKey + ": " + MetricCombinationAnnotationKey,
+	}, {
+		name: "weights without the weighted combination",
+		annotations: map[string]string{
+			CombinedMetricsAnnotationKey: "rps=150",
+			MetricWeightsAnnotationKey:   "rps=2",
+		},
+		expectErr: "only allowed with " + MetricCombinationAnnotationKey + ": weighted: " + MetricWeightsAnnotationKey,
+	}, {
+		name: "weight of a metric not scaled on",
+		annotations: map[string]string{
+			CombinedMetricsAnnotationKey:   "rps=150",
+			MetricCombinationAnnotationKey: MetricCombinationWeighted,
+			MetricWeightsAnnotationKey:     "custom=2",
+		},
+		expectErr: `"custom" is not scaled on: ` + MetricWeightsAnnotationKey,
 	}, {
 		name:        "valid 0 scale down delay",
 		annotations: map[string]string{ScaleDownDelayAnnotationKey: "0"},
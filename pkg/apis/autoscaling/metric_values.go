@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaling
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MetricValue is a metric paired with a value, a target or a weight.
+type MetricValue struct {
+	Metric string
+	Value  float64
+}
+
+// ParseMetricValues parses the value of the CombinedMetricsAnnotationKey and
+// MetricWeightsAnnotationKey annotations, metric=value pairs separated by
+// commas, e.g. "rps=150, custom=5", in the order they're given.
+func ParseMetricValues(s string) ([]MetricValue, error) {
+	var (
+		values []MetricValue
+		seen   = make(map[string]bool)
+	)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%q: want metric=value", pair)
+		}
+		metric := strings.TrimSpace(kv[0])
+		switch metric {
+		case Concurrency, RPS, Custom:
+		default:
+			return nil, fmt.Errorf("%q: unknown metric %q", pair, metric)
+		}
+		if seen[metric] {
+			return nil, fmt.Errorf("%q: metric %q is given twice", pair, metric)
+		}
+		seen[metric] = true
+		v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil || v <= 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, fmt.Errorf("%q: invalid value %q", pair, kv[1])
+		}
+		values = append(values, MetricValue{Metric: metric, Value: v})
+	}
+	return values, nil
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaling
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseMetricValues(t *testing.T) {
+	got, err := ParseMetricValues(" rps = 150,custom=2.5, ")
+	if err != nil {
+		t.Fatal("ParseMetricValues() =", err)
+	}
+	if want := []MetricValue{{RPS, 150}, {Custom, 2.5}}; !cmp.Equal(got, want) {
+		t.Errorf("ParseMetricValues() (-want, +got): %s", cmp.Diff(want, got))
+	}
+
+	for _, s := range []string{
+		"rps",
+		"rps=",
+		"rps=0",
+		"rps=-1",
+		"rps=NaN",
+		"rps=+Inf",
+		"cpu=80",
+		"rps=1, rps=2",
+	} {
+		if _, err := ParseMetricValues(s); err == nil {
+			t.Errorf("ParseMetricValues(%q) = nil, want an error", s)
+		}
+	}
+}
@@ -45,14 +45,95 @@ const (
 	// the PodAutoscaler should provision. For example,
 	//   autoscaling.knative.dev/maxScale: "10"
 	MaxScaleAnnotationKey = GroupName + "/maxScale"
+	// ScaleScheduleAnnotationKey is the annotation to specify windows, starting
+	// at the times given by cron expressions in UTC, during which the minimum
+	// and/or maximum scale take the values of the window rather than those of
+	// the minScale and maxScale annotations. For example,
+	//   autoscaling.knative.dev/scaleSchedule: "0 8 * * 1-5 min=5; 0 20 * * * min=0"
+	ScaleScheduleAnnotationKey = GroupName + "/scaleSchedule"
 
 	// InitialScaleAnnotationKey is the annotation to specify the initial scale of
 	// a revision when a service is initially deployed. This number can be set to 0 iff
 	// allow-zero-initial-scale of config-autoscaler is true.
 	InitialScaleAnnotationKey = GroupName + "/initialScale"
 
+	// MinScaleUnavailablePolicyAnnotationKey is the annotation to specify what
+	// happens if the cluster lacks the capacity for the minimum scale of a revision,
+	// i.e. pods of the revision can't be scheduled. For example,
+	//   autoscaling.knative.dev/minScaleUnavailablePolicy: BestEffort
+	MinScaleUnavailablePolicyAnnotationKey = GroupName + "/minScaleUnavailablePolicy"
+	// MinScaleUnavailablePolicyHold is the MinScaleUnavailablePolicyAnnotationKey
+	// value, and the default, that holds the revision not ready until its minimum
+	// scale is satisfied.
+	MinScaleUnavailablePolicyHold = "Hold"
+	// MinScaleUnavailablePolicyBestEffort is the MinScaleUnavailablePolicyAnnotationKey
+	// value that lets the revision proceed with the pods that could be scheduled,
+	// if any, while reporting the lack of capacity in its status.
+	MinScaleUnavailablePolicyBestEffort = "BestEffort"
+
 	// ScaleDownDelayAnnotationKey is the annotation to specify a scale down delay.
 	ScaleDownDelayAnnotationKey = GroupName + "/scaleDownDelay"
+	// MaxScaleDownPerMinuteAnnotationKey is the annotation to limit how much
+	// the KPA scales a revision down by per minute, either a number of pods or
+	// a percentage of its pods, for revisions with pods expensive to bring back.
+	// For example,
+	//   autoscaling.knative.dev/maxScaleDownPerMinute: "5"
+	//   autoscaling.knative.dev/maxScaleDownPerMinute: "10%"
+	MaxScaleDownPerMinuteAnnotationKey = GroupName + "/maxScaleDownPerMinute"
+
+	// PredictionPeriodAnnotationKey is the annotation to specify the period of
+	// the load pattern the autoscaler learns to scale a revision ahead of its
+	// load, overriding the prediction-period of the autoscaler config.
+	// "0s" disables the prediction. For example,
+	//   autoscaling.knative.dev/predictionPeriod: "24h"
+	PredictionPeriodAnnotationKey = GroupName + "/predictionPeriod"
+	// PredictionPeriodMax is the longest period of the load pattern, a week,
+	// to bound the history kept for it.
+	PredictionPeriodMax = 7 * 24 * time.Hour
+
+	// ActivatorSubsetSizeAnnotationKey is the annotation to pin the number of
+	// activators put into the routing path of a revision, rather than deriving
+	// it from the capacity to cover. For example,
+	//   autoscaling.knative.dev/activatorSubsetSize: "5"
+	ActivatorSubsetSizeAnnotationKey = GroupName + "/activatorSubsetSize"
+
+	// ConcurrencyStatePauseDelayAnnotationKey is the annotation to specify how
+	// long the queue-proxy waits after the last request of a pod completed before
+	// it pauses the user container through the concurrency state endpoint.
+	// A request arriving in the meantime cancels the pause. For example,
+	//   autoscaling.knative.dev/concurrencyStatePauseDelay: "500ms"
+	ConcurrencyStatePauseDelayAnnotationKey = GroupName + "/concurrencyStatePauseDelay"
+
+	// KeepWarmPeriodAnnotationKey is the annotation to specify how long the
+	// last pod of a revision is kept, paused by the queue-proxy, once the
+	// revision would have been scaled to zero, so that it resumes within
+	// milliseconds rather than starting cold. It only applies if the
+	// concurrency state endpoint pausing the idle pods is configured. For example,
+	//   autoscaling.knative.dev/keepWarmPeriod: "30m"
+	KeepWarmPeriodAnnotationKey = GroupName + "/keepWarmPeriod"
+	// KeepWarmPeriodMax is the longest the last pod is kept warm.
+	KeepWarmPeriodMax = 24 * time.Hour
+
+	// ScaleToZeroExclusionPeriodAnnotationKey is the annotation to specify how
+	// long a revision isn't scaled to zero after it first became ready,
+	// whatever its traffic, so that a freshly deployed revision waiting for
+	// delayed traffic, e.g. DNS propagation or queue consumers, isn't started
+	// cold. For example,
+	//   autoscaling.knative.dev/scaleToZeroExclusionPeriod: "15m"
+	ScaleToZeroExclusionPeriodAnnotationKey = GroupName + "/scaleToZeroExclusionPeriod"
+	// ScaleToZeroExclusionPeriodMax is the longest a revision is kept from
+	// scaling to zero after it first became ready.
+	ScaleToZeroExclusionPeriodMax = 24 * time.Hour
+
+	// ScalingAlgorithmAnnotationKey is the annotation to specify the algorithm
+	// the KPA computes the scale of a revision with, among those built into the
+	// autoscaler. For example,
+	//   autoscaling.knative.dev/scalingAlgorithm: window
+	ScalingAlgorithmAnnotationKey = GroupName + "/scalingAlgorithm"
+	// ScalingAlgorithmWindow is the ScalingAlgorithmAnnotationKey value, and the
+	// default, to scale on the metrics averaged over the stable and panic
+	// windows.
+	ScalingAlgorithmWindow = "window"
 
 	// MetricAnnotationKey is the annotation to specify what metric the PodAutoscaler
 	// should be scaled on. For example,
@@ -66,6 +147,93 @@ const (
 	Memory = "memory"
 	// RPS is the requests per second reaching the Pod.
 	RPS = "rps"
+	// Custom is a metric the user container exposes itself, e.g. the depth of
+	// its work queue, as given by the CustomMetric annotations below. The
+	// target is the value of the metric to maintain per Pod.
+	Custom = "custom"
+	// GPU is the utilization percentage of the GPUs of the Pod, averaged over
+	// its GPUs, as exposed by a DCGM exporter sidecar. The metric, port and
+	// path it's scraped from may be changed with the CustomMetric annotations
+	// below, e.g. to scrape an NVML based exporter instead. The target is the
+	// utilization percentage to maintain per Pod, the target utilization of
+	// GPUUtilizationMax if it's not set. For example,
+	//   autoscaling.knative.dev/metric: gpu
+	//   autoscaling.knative.dev/target: "80"
+	GPU = "gpu"
+	// GPUMetricNameDefault is the DCGM field of the GPU utilization, scraped
+	// for the gpu metric by default.
+	GPUMetricNameDefault = "DCGM_FI_DEV_GPU_UTIL"
+	// GPUMetricPortDefault is the port the DCGM exporter serves its metrics
+	// on by default.
+	GPUMetricPortDefault = "9400"
+	// GPUUtilizationMax is the maximum utilization percentage of the GPUs of
+	// a Pod.
+	GPUUtilizationMax = 100
+
+	// CustomMetricNameAnnotationKey is the annotation to specify the name of
+	// the metric to scale on for the custom metric. The user container must
+	// expose it in the Prometheus text format, the values of all of its series
+	// are summed up. For example,
+	//   autoscaling.knative.dev/metric: custom
+	//   autoscaling.knative.dev/customMetricName: jobs_in_flight
+	//   autoscaling.knative.dev/customMetricPort: "9090"
+	//   autoscaling.knative.dev/target: "5"   # target 5 jobs in flight per pod
+	CustomMetricNameAnnotationKey = GroupName + "/customMetricName"
+	// CustomMetricPortAnnotationKey is the annotation to specify the port of
+	// the user container the custom metric is scraped from.
+	CustomMetricPortAnnotationKey = GroupName + "/customMetricPort"
+	// CustomMetricPathAnnotationKey is the annotation to specify the path the
+	// custom metric is scraped from, /metrics if it's not set.
+	CustomMetricPathAnnotationKey = GroupName + "/customMetricPath"
+	// CustomMetricPathDefault is the default path the custom metric is scraped
+	// from.
+	CustomMetricPathDefault = "/metrics"
+
+	// External is a metric of a system outside of the cluster, e.g. the lag
+	// of a Kafka consumer group or the backlog of a Pub/Sub subscription, as
+	// served by the external metrics API and given by the ExternalMetric
+	// annotations below. Only the hpa.autoscaling.knative.dev class supports
+	// it. The target is the value of the metric to maintain per Pod.
+	External = "external"
+	// ExternalMetricNameAnnotationKey is the annotation to specify the name of
+	// the external metric to scale on. For example,
+	//   autoscaling.knative.dev/class: hpa.autoscaling.knative.dev
+	//   autoscaling.knative.dev/metric: external
+	//   autoscaling.knative.dev/externalMetricName: kafka_consumergroup_lag
+	//   autoscaling.knative.dev/externalMetricSelector: topic=orders
+	//   autoscaling.knative.dev/target: "100"   # target a lag of 100 per pod
+	ExternalMetricNameAnnotationKey = GroupName + "/externalMetricName"
+	// ExternalMetricSelectorAnnotationKey is the annotation to specify the
+	// label selector narrowing down the series of the external metric, e.g.
+	// "topic=orders,consumergroup=knative".
+	ExternalMetricSelectorAnnotationKey = GroupName + "/externalMetricSelector"
+
+	// CombinedMetricsAnnotationKey is the annotation to specify metrics, each
+	// with its target, to scale on besides the one of MetricAnnotationKey. The
+	// scale recommended for each metric is combined as given by
+	// MetricCombinationAnnotationKey. For example,
+	//   autoscaling.knative.dev/metric: concurrency
+	//   autoscaling.knative.dev/combinedMetrics: "rps=150, custom=5"
+	CombinedMetricsAnnotationKey = GroupName + "/combinedMetrics"
+	// MetricCombinationAnnotationKey is the annotation to specify how the
+	// scales recommended for the metrics are combined.
+	MetricCombinationAnnotationKey = GroupName + "/metricCombination"
+	// MetricCombinationMax is the MetricCombinationAnnotationKey value to
+	// scale to the highest of the recommended scales. This is the default.
+	MetricCombinationMax = "max"
+	// MetricCombinationMin is the MetricCombinationAnnotationKey value to
+	// scale to the lowest of the recommended scales.
+	MetricCombinationMin = "min"
+	// MetricCombinationWeighted is the MetricCombinationAnnotationKey value to
+	// scale to the average of the recommended scales, weighted by
+	// MetricWeightsAnnotationKey.
+	MetricCombinationWeighted = "weighted"
+	// MetricWeightsAnnotationKey is the annotation to specify the weights of
+	// the metrics for the weighted combination, 1 for the metrics left out.
+	// For example,
+	//   autoscaling.knative.dev/metricCombination: weighted
+	//   autoscaling.knative.dev/metricWeights: "concurrency=3, rps=1"
+	MetricWeightsAnnotationKey = GroupName + "/metricWeights"
 
 	// TargetAnnotationKey is the annotation to specify what metric value the
 	// PodAutoscaler should attempt to maintain. For example,
@@ -88,6 +256,13 @@ const (
 	// scale-to-zero-pod-retention-period global setting.
 	ScaleToZeroPodRetentionPeriodKey = GroupName + "/scaleToZeroPodRetentionPeriod"
 
+	// EnableScaleToZeroAnnotationKey is the annotation to specify whether
+	// the revision is allowed to scale to zero. For example,
+	//   autoscaling.knative.dev/enableScaleToZero: "false"
+	// This is the per-revision setting compliment to the
+	// enable-scale-to-zero global setting and takes precedence over it.
+	EnableScaleToZeroAnnotationKey = GroupName + "/enableScaleToZero"
+
 	// MetricAggregationAlgorithmKey is the annotation that can be used for selection
 	// of the algorithm to use for averaging metric data in the Autoscaler.
 	// Since autoscalers are a pluggable concept, this field is only validated
@@ -199,4 +374,30 @@ const (
 	// PanicThresholdPercentageMax is the counterpart to the PanicThresholdPercentageMin
 	// but bounding from above.
 	PanicThresholdPercentageMax = 1000.0
+
+	// PanicExitThresholdPercentageAnnotationKey is the annotation to specify
+	// the level below which panic mode is left, as a percentage of the metric
+	// target like the panic threshold. A level lower than the panic threshold
+	// keeps the autoscaler from flapping in and out of panic mode when the
+	// load oscillates around the panic threshold. Panic mode is left below
+	// the panic threshold if it's not set. For example,
+	//   autoscaling.knative.dev/panicThresholdPercentage: "200.0"
+	//   autoscaling.knative.dev/panicExitThresholdPercentage: "150.0"
+	// Only the kpa.autoscaling.knative.dev class autoscaler supports
+	// the panicExitThresholdPercentage annotation.
+	PanicExitThresholdPercentageAnnotationKey = GroupName + "/panicExitThresholdPercentage"
+	// PanicExitThresholdPercentageMin is the minimum allowable panic exit
+	// threshold percentage. Below the metric target the revision, which
+	// isn't scaled down in panic mode, would never leave it.
+	PanicExitThresholdPercentageMin = 100.0
+
+	// PanicCooldownAnnotationKey is the annotation to specify how long the
+	// level must have stayed below the panic exit threshold for panic mode to
+	// be left, the stable window if it's not set. For example,
+	//   autoscaling.knative.dev/panicCooldown: "2m"
+	// Only the kpa.autoscaling.knative.dev class autoscaler supports
+	// the panicCooldown annotation.
+	PanicCooldownAnnotationKey = GroupName + "/panicCooldown"
+	// PanicCooldownMin is the minimum allowable panic cool-down.
+	PanicCooldownMin = time.Second
 )
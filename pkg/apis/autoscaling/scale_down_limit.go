@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaling
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ScaleDownLimit is how much a revision may be scaled down by per minute,
+// either a number of pods or a percentage of its pods. The zero value
+// doesn't limit scaling down.
+type ScaleDownLimit struct {
+	Value   float64
+	Percent bool
+}
+
+// ParseScaleDownLimit parses the value of the
+// MaxScaleDownPerMinuteAnnotationKey annotation, a positive number of pods,
+// e.g. "5", or a percentage of the pods, e.g. "10%".
+func ParseScaleDownLimit(s string) (ScaleDownLimit, error) {
+	s = strings.TrimSpace(s)
+	num := strings.TrimSuffix(s, "%")
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v <= 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return ScaleDownLimit{}, fmt.Errorf("%q: want a positive number of pods or a percentage", s)
+	}
+	percent := num != s
+	if percent && v > 100 {
+		return ScaleDownLimit{}, fmt.Errorf("%q: percentage must be at most 100%%", s)
+	}
+	return ScaleDownLimit{Value: v, Percent: percent}, nil
+}
+
+// PodsPerMinute returns the number of pods a revision with the given number
+// of pods may be scaled down by per minute. A percentage allows at least one
+// pod per minute, so that small revisions still scale down.
+func (l ScaleDownLimit) PodsPerMinute(pods float64) float64 {
+	if !l.Percent {
+		return l.Value
+	}
+	return math.Max(1, pods*l.Value/100)
+}
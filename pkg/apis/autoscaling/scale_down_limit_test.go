@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaling
+
+import "testing"
+
+func TestParseScaleDownLimit(t *testing.T) {
+	for s, want := range map[string]ScaleDownLimit{
+		"5":     {Value: 5},
+		" 2.5 ": {Value: 2.5},
+		"10%":   {Value: 10, Percent: true},
+		"100%":  {Value: 100, Percent: true},
+	} {
+		if got, err := ParseScaleDownLimit(s); err != nil || got != want {
+			t.Errorf("ParseScaleDownLimit(%q) = %v, %v, want: %v", s, got, err, want)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"%",
+		"0",
+		"-1",
+		"0%",
+		"101%",
+		"NaN",
+		"+Inf",
+		"5 pods",
+	} {
+		if _, err := ParseScaleDownLimit(s); err == nil {
+			t.Errorf("ParseScaleDownLimit(%q) = nil, want an error", s)
+		}
+	}
+}
+
+func TestScaleDownLimitPodsPerMinute(t *testing.T) {
+	for _, tc := range []struct {
+		limit ScaleDownLimit
+		pods  float64
+		want  float64
+	}{{
+		limit: ScaleDownLimit{Value: 5},
+		pods:  50,
+		want:  5,
+	}, {
+		limit: ScaleDownLimit{Value: 10, Percent: true},
+		pods:  50,
+		want:  5,
+	}, {
+		limit: ScaleDownLimit{Value: 10, Percent: true},
+		pods:  3,
+		want:  1,
+	}} {
+		if got := tc.limit.PodsPerMinute(tc.pods); got != tc.want {
+			t.Errorf("%v.PodsPerMinute(%v) = %v, want: %v", tc.limit, tc.pods, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaling
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduleSearchDays bounds how many days the start of a window is searched
+// for, long enough to find those starting on a leap day.
+const scheduleSearchDays = 5 * 366
+
+// ScaleSchedule is the parsed value of the ScaleScheduleAnnotationKey
+// annotation: windows starting at the times given by cron expressions, each
+// setting the scale bounds until the next one starts.
+type ScaleSchedule []ScaleWindow
+
+// ScaleWindow is a window of a ScaleSchedule.
+type ScaleWindow struct {
+	start cronSchedule
+
+	// Min and Max are the scale bounds in effect during the window, or nil
+	// if the window keeps the bound of the annotations.
+	Min, Max *int32
+}
+
+// ParseScaleSchedule parses the value of the ScaleScheduleAnnotationKey
+// annotation, windows separated by semicolons, each a cron expression of
+// five fields in UTC followed by min=N and/or max=N, e.g.
+// "0 8 * * 1-5 min=5; 0 20 * * * min=0".
+func ParseScaleSchedule(s string) (ScaleSchedule, error) {
+	var sched ScaleSchedule
+	for _, entry := range strings.Split(s, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("%q: want a cron expression followed by min=N and/or max=N", entry)
+		}
+		start, err := parseCron(fields[:5])
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", entry, err)
+		}
+		w := ScaleWindow{start: start}
+		for _, bound := range fields[5:] {
+			kv := strings.SplitN(bound, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("%q: want min=N or max=N, got %q", entry, bound)
+			}
+			n, err := strconv.ParseInt(kv[1], 10, 32)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%q: invalid scale %q", entry, kv[1])
+			}
+			v := int32(n)
+			switch {
+			case kv[0] == "min" && w.Min == nil:
+				w.Min = &v
+			case kv[0] == "max" && w.Max == nil:
+				w.Max = &v
+			default:
+				return nil, fmt.Errorf("%q: unexpected %q", entry, bound)
+			}
+		}
+		if w.Min != nil && w.Max != nil && *w.Max != 0 && *w.Max < *w.Min {
+			return nil, fmt.Errorf("%q: max=%d is less than min=%d", entry, *w.Max, *w.Min)
+		}
+		sched = append(sched, w)
+	}
+	if len(sched) == 0 {
+		return nil, errors.New("the schedule has no windows")
+	}
+	return sched, nil
+}
+
+// Bounds returns the window in effect at t, i.e. the one that started last,
+// or false if none started yet.
+func (s ScaleSchedule) Bounds(t time.Time) (ScaleWindow, bool) {
+	var (
+		window ScaleWindow
+		latest time.Time
+		found  bool
+	)
+	for _, w := range s {
+		// The later of the windows starting at once wins.
+		if start, ok := w.start.prev(t); ok && !start.Before(latest) {
+			window, latest, found = w, start, true
+		}
+	}
+	return window, found
+}
+
+// Next returns the time after t the next window starts, or false if none
+// does.
+func (s ScaleSchedule) Next(t time.Time) (time.Time, bool) {
+	var (
+		earliest time.Time
+		found    bool
+	)
+	for _, w := range s {
+		if start, ok := w.start.next(t); ok && (!found || start.Before(earliest)) {
+			earliest, found = start, true
+		}
+	}
+	return earliest, found
+}
+
+// cronSchedule is a cron expression of five fields: minute, hour, day of
+// month, month and day of week. Every field is a set of values, as a bitmask.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// anyDOM and anyDOW are set if the day of month and the day of week are
+	// "*". A day restricted by both matches if either matches, as in cron.
+	anyDOM, anyDOW bool
+}
+
+// cronRanges are the ranges of the fields of a cron expression. Sunday is
+// both 0 and 7.
+var cronRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+func parseCron(fields []string) (cronSchedule, error) {
+	var bits [5]uint64
+	for i, f := range fields {
+		b, err := parseCronField(f, cronRanges[i][0], cronRanges[i][1])
+		if err != nil {
+			return cronSchedule{}, err
+		}
+		bits[i] = b
+	}
+	dow := bits[4]
+	if dow&(1<<7) != 0 {
+		dow |= 1
+	}
+	return cronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    dow,
+		anyDOM: fields[2] == "*",
+		anyDOW: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps,
+// e.g. "*/15" or "1-5,0".
+func parseCronField(f string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(f, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rng, step = part[:i], n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				// As in "5/15", every 15 starting at 5.
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of the range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c cronSchedule) matchesDay(day time.Time) bool {
+	if c.month&(1<<uint(day.Month())) == 0 {
+		return false
+	}
+	dom, dow := c.dom&(1<<uint(day.Day())) != 0, c.dow&(1<<uint(day.Weekday())) != 0
+	if c.anyDOM || c.anyDOW {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (c cronSchedule) matchesMinute(m int) bool {
+	return c.hour&(1<<uint(m/60)) != 0 && c.minute&(1<<uint(m%60)) != 0
+}
+
+// prev returns the last time at or before t the schedule fires.
+func (c cronSchedule) prev(t time.Time) (time.Time, bool) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	last := t.Hour()*60 + t.Minute()
+	for i := 0; i < scheduleSearchDays; i++ {
+		if c.matchesDay(day) {
+			for m := last; m >= 0; m-- {
+				if c.matchesMinute(m) {
+					return day.Add(time.Duration(m) * time.Minute), true
+				}
+			}
+		}
+		day, last = day.AddDate(0, 0, -1), 24*60-1
+	}
+	return time.Time{}, false
+}
+
+// next returns the first time after t the schedule fires.
+func (c cronSchedule) next(t time.Time) (time.Time, bool) {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	first := t.Hour()*60 + t.Minute()
+	for i := 0; i < scheduleSearchDays; i++ {
+		if c.matchesDay(day) {
+			for m := first; m < 24*60; m++ {
+				if c.matchesMinute(m) {
+					return day.Add(time.Duration(m) * time.Minute), true
+				}
+			}
+		}
+		day, first = day.AddDate(0, 0, 1), 0
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package autoscaling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"knative.dev/pkg/ptr"
+)
+
+func TestParseScaleScheduleErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		" ; ",
+		"0 8 * * *",
+		"0 8 * * * 5",
+		"0 8 * * * min=-1",
+		"0 8 * * * min=many",
+		"0 8 * * * min=1 min=2",
+		"0 8 * * * replicas=2",
+		"0 8 * * * min=5 max=2",
+		"60 8 * * * min=1",
+		"0 8 0 * * min=1",
+		"0 8 * 13 * min=1",
+		"0 8 * * 8 min=1",
+		"0 8-6 * * * min=1",
+		"*/0 8 * * * min=1",
+		"0 8 * * mon min=1",
+		"0 8 * * * min=1; 0 20 * *",
+	} {
+		if _, err := ParseScaleSchedule(s); err == nil {
+			t.Errorf("ParseScaleSchedule(%q) = nil, want an error", s)
+		}
+	}
+}
+
+func TestScaleScheduleBounds(t *testing.T) {
+	// Monday.
+	monday := time.Date(2021, time.October, 4, 0, 0, 0, 0, time.UTC)
+	sched, err := ParseScaleSchedule("0 8 * * 1-5 min=5; 0 20 * * * min=0 max=3")
+	if err != nil {
+		t.Fatal("ParseScaleSchedule() =", err)
+	}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		wantMin  *int32
+		wantMax  *int32
+		wantNext time.Time
+	}{{
+		name:     "early on Monday, after Sunday's evening window",
+		at:       monday.Add(3 * time.Hour),
+		wantMin:  ptr.Int32(0),
+		wantMax:  ptr.Int32(3),
+		wantNext: monday.Add(8 * time.Hour),
+	}, {
+		name:     "when the window starts",
+		at:       monday.Add(8 * time.Hour),
+		wantMin:  ptr.Int32(5),
+		wantNext: monday.Add(20 * time.Hour),
+	}, {
+		name:     "Monday afternoon",
+		at:       monday.Add(15*time.Hour + 30*time.Second),
+		wantMin:  ptr.Int32(5),
+		wantNext: monday.Add(20 * time.Hour),
+	}, {
+		name:     "Friday night",
+		at:       monday.AddDate(0, 0, 4).Add(21 * time.Hour),
+		wantMin:  ptr.Int32(0),
+		wantMax:  ptr.Int32(3),
+		wantNext: monday.AddDate(0, 0, 5).Add(20 * time.Hour),
+	}, {
+		name:     "Saturday morning, no weekday window",
+		at:       monday.AddDate(0, 0, 5).Add(9 * time.Hour),
+		wantMin:  ptr.Int32(0),
+		wantMax:  ptr.Int32(3),
+		wantNext: monday.AddDate(0, 0, 5).Add(20 * time.Hour),
+	}, {
+		name:     "in another time zone",
+		at:       monday.Add(8 * time.Hour).In(time.FixedZone("UTC+2", 2*60*60)),
+		wantMin:  ptr.Int32(5),
+		wantNext: monday.Add(20 * time.Hour),
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w, ok := sched.Bounds(tc.at)
+			if !ok {
+				t.Fatal("Bounds() = false, want a window in effect")
+			}
+			if got, want := [2]*int32{w.Min, w.Max}, [2]*int32{tc.wantMin, tc.wantMax}; !cmp.Equal(got, want) {
+				t.Errorf("Bounds() (-want, +got): %s", cmp.Diff(want, got))
+			}
+			if got, ok := sched.Next(tc.at); !ok || !got.Equal(tc.wantNext) {
+				t.Errorf("Next() = %v, want: %v", got, tc.wantNext)
+			}
+		})
+	}
+}
+
+func TestCronSchedule(t *testing.T) {
+	at := time.Date(2021, time.October, 4, 10, 17, 0, 0, time.UTC)
+	tests := []struct {
+		cron     string
+		wantPrev time.Time
+		wantNext time.Time
+	}{{
+		cron:     "*/15 * * * *",
+		wantPrev: time.Date(2021, time.October, 4, 10, 15, 0, 0, time.UTC),
+		wantNext: time.Date(2021, time.October, 4, 10, 30, 0, 0, time.UTC),
+	}, {
+		cron:     "5/30 9-17 * * *",
+		wantPrev: time.Date(2021, time.October, 4, 10, 5, 0, 0, time.UTC),
+		wantNext: time.Date(2021, time.October, 4, 10, 35, 0, 0, time.UTC),
+	}, {
+		cron:     "0 0 1 1 *",
+		wantPrev: time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC),
+		wantNext: time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}, {
+		cron:     "0 12 29 2 *",
+		wantPrev: time.Date(2020, time.February, 29, 12, 0, 0, 0, time.UTC),
+		wantNext: time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC),
+	}, {
+		// Sunday, as 7.
+		cron:     "30 6 * * 7",
+		wantPrev: time.Date(2021, time.October, 3, 6, 30, 0, 0, time.UTC),
+		wantNext: time.Date(2021, time.October, 10, 6, 30, 0, 0, time.UTC),
+	}, {
+		// The 15th or a Sunday, as both are restricted.
+		cron:     "0 0 15 * 0",
+		wantPrev: time.Date(2021, time.October, 3, 0, 0, 0, 0, time.UTC),
+		wantNext: time.Date(2021, time.October, 10, 0, 0, 0, 0, time.UTC),
+	}, {
+		cron:     "17 10 * * *",
+		wantPrev: at,
+		wantNext: at.AddDate(0, 0, 1),
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.cron, func(t *testing.T) {
+			sched, err := ParseScaleSchedule(tc.cron + " min=1")
+			if err != nil {
+				t.Fatal("ParseScaleSchedule() =", err)
+			}
+			c := sched[0].start
+			if got, ok := c.prev(at); !ok || !got.Equal(tc.wantPrev) {
+				t.Errorf("prev() = %v, want: %v", got, tc.wantPrev)
+			}
+			if got, ok := c.next(at); !ok || !got.Equal(tc.wantNext) {
+				t.Errorf("next() = %v, want: %v", got, tc.wantNext)
+			}
+		})
+	}
+
+	// A schedule that never fires.
+	sched, err := ParseScaleSchedule("0 0 31 2 * min=1")
+	if err != nil {
+		t.Fatal("ParseScaleSchedule() =", err)
+	}
+	if _, ok := sched.Bounds(at); ok {
+		t.Error("Bounds() = true for a schedule that never fires")
+	}
+	if _, ok := sched.Next(at); ok {
+		t.Error("Next() = true for a schedule that never fires")
+	}
+}
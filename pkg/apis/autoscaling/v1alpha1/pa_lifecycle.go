@@ -22,6 +22,7 @@ import (
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"knative.dev/pkg/apis"
@@ -81,22 +82,57 @@ func (pa *PodAutoscaler) annotationFloat64(key string) (float64, bool) {
 
 // ScaleBounds returns scale bounds annotations values as a tuple:
 // `(min, max int32)`. The value of 0 for any of min or max means the bound is
-// not set.
+// not set. The window of the scale schedule in effect, if any, takes
+// precedence over the annotations.
 // Note: min will be ignored if the PA is not reachable
 func (pa *PodAutoscaler) ScaleBounds(asConfig *autoscalerconfig.Config) (int32, int32) {
+	return pa.scaleBoundsAt(asConfig, time.Now())
+}
+
+func (pa *PodAutoscaler) scaleBoundsAt(asConfig *autoscalerconfig.Config, now time.Time) (int32, int32) {
+	var window autoscaling.ScaleWindow
+	if sched, ok := pa.ScaleSchedule(); ok {
+		window, _ = sched.Bounds(now)
+	}
+
 	var min int32
 	if pa.Spec.Reachability != ReachabilityUnreachable {
 		min, _ = pa.annotationInt32(autoscaling.MinScaleAnnotationKey)
+		if window.Min != nil {
+			min = *window.Min
+		}
 	}
 
 	max := asConfig.MaxScale
 	if paMax, ok := pa.annotationInt32(autoscaling.MaxScaleAnnotationKey); ok {
 		max = paMax
 	}
+	if window.Max != nil {
+		max = *window.Max
+	}
 
 	return min, max
 }
 
+// ScaleSchedule returns the parsed scaleSchedule annotation value, or false if
+// not present, or invalid.
+func (pa *PodAutoscaler) ScaleSchedule() (autoscaling.ScaleSchedule, bool) {
+	if s, ok := pa.Annotations[autoscaling.ScaleScheduleAnnotationKey]; ok {
+		sched, err := autoscaling.ParseScaleSchedule(s)
+		return sched, err == nil
+	}
+	return nil, false
+}
+
+// NextScaleBoundsChange returns when the next window of the scale schedule
+// starts, changing the scale bounds, or false if there's none.
+func (pa *PodAutoscaler) NextScaleBoundsChange(now time.Time) (time.Time, bool) {
+	if sched, ok := pa.ScaleSchedule(); ok {
+		return sched.Next(now)
+	}
+	return time.Time{}, false
+}
+
 // Target returns the target annotation value or false if not present, or invalid.
 func (pa *PodAutoscaler) Target() (float64, bool) {
 	return pa.annotationFloat64(autoscaling.TargetAnnotationKey)
@@ -132,6 +168,30 @@ func (pa *PodAutoscaler) ScaleToZeroPodRetention() (time.Duration, bool) {
 	return pa.annotationDuration(autoscaling.ScaleToZeroPodRetentionPeriodKey)
 }
 
+// KeepWarmPeriod returns the keepWarmPeriod annotation value, or false if not
+// present.
+func (pa *PodAutoscaler) KeepWarmPeriod() (time.Duration, bool) {
+	// The value is validated in the webhook.
+	return pa.annotationDuration(autoscaling.KeepWarmPeriodAnnotationKey)
+}
+
+// ScaleToZeroExclusionPeriod returns the scaleToZeroExclusionPeriod annotation
+// value, or false if not present.
+func (pa *PodAutoscaler) ScaleToZeroExclusionPeriod() (time.Duration, bool) {
+	// The value is validated in the webhook.
+	return pa.annotationDuration(autoscaling.ScaleToZeroExclusionPeriodAnnotationKey)
+}
+
+// EnableScaleToZero returns whether the enableScaleToZero annotation allows
+// scaling to zero, or false if not present.
+func (pa *PodAutoscaler) EnableScaleToZero() (enabled bool, ok bool) {
+	if s, ok := pa.Annotations[autoscaling.EnableScaleToZeroAnnotationKey]; ok {
+		b, err := strconv.ParseBool(s)
+		return b, err == nil
+	}
+	return false, false
+}
+
 // Window returns the window annotation value, or false if not present.
 func (pa *PodAutoscaler) Window() (time.Duration, bool) {
 	// The value is validated in the webhook.
@@ -144,6 +204,68 @@ func (pa *PodAutoscaler) ScaleDownDelay() (time.Duration, bool) {
 	return pa.annotationDuration(autoscaling.ScaleDownDelayAnnotationKey)
 }
 
+// MaxScaleDownPerMinute returns the limit of scaling down per minute, or false
+// if not present or invalid.
+func (pa *PodAutoscaler) MaxScaleDownPerMinute() (autoscaling.ScaleDownLimit, bool) {
+	if s, ok := pa.Annotations[autoscaling.MaxScaleDownPerMinuteAnnotationKey]; ok {
+		limit, err := autoscaling.ParseScaleDownLimit(s)
+		return limit, err == nil
+	}
+	return autoscaling.ScaleDownLimit{}, false
+}
+
+// PredictionPeriod returns the prediction period annotation value, or false if not present.
+func (pa *PodAutoscaler) PredictionPeriod() (time.Duration, bool) {
+	// The value is validated in the webhook.
+	return pa.annotationDuration(autoscaling.PredictionPeriodAnnotationKey)
+}
+
+func (pa *PodAutoscaler) annotationMetricValues(key string) ([]autoscaling.MetricValue, bool) {
+	if s, ok := pa.Annotations[key]; ok {
+		values, err := autoscaling.ParseMetricValues(s)
+		return values, err == nil
+	}
+	return nil, false
+}
+
+// CombinedMetrics returns the metrics and their targets the revision is
+// scaled on besides its Metric, or false if not present or invalid.
+func (pa *PodAutoscaler) CombinedMetrics() ([]autoscaling.MetricValue, bool) {
+	return pa.annotationMetricValues(autoscaling.CombinedMetricsAnnotationKey)
+}
+
+// ScalingAlgorithm returns the algorithm the revision is scaled with,
+// defaulting to the window one.
+func (pa *PodAutoscaler) ScalingAlgorithm() string {
+	// The value is validated in the webhook.
+	if a, ok := pa.Annotations[autoscaling.ScalingAlgorithmAnnotationKey]; ok {
+		return a
+	}
+	return autoscaling.ScalingAlgorithmWindow
+}
+
+// MetricCombination returns how the pod counts of the metrics are combined,
+// defaulting to the max of them.
+func (pa *PodAutoscaler) MetricCombination() string {
+	// The value is validated in the webhook.
+	if c, ok := pa.Annotations[autoscaling.MetricCombinationAnnotationKey]; ok {
+		return c
+	}
+	return autoscaling.MetricCombinationMax
+}
+
+// MetricWeight returns the weight of the metric in the weighted combination,
+// defaulting to 1.
+func (pa *PodAutoscaler) MetricWeight(metric string) float64 {
+	weights, _ := pa.annotationMetricValues(autoscaling.MetricWeightsAnnotationKey)
+	for _, w := range weights {
+		if w.Metric == metric {
+			return w.Value
+		}
+	}
+	return 1
+}
+
 // PanicWindowPercentage returns the panic window annotation value, or false if not present.
 func (pa *PodAutoscaler) PanicWindowPercentage() (percentage float64, ok bool) {
 	// The value is validated in the webhook.
@@ -156,12 +278,54 @@ func (pa *PodAutoscaler) PanicThresholdPercentage() (percentage float64, ok bool
 	return pa.annotationFloat64(autoscaling.PanicThresholdPercentageAnnotationKey)
 }
 
+// ExternalMetricSelector returns the label selector of the external metric
+// annotation, or false if not present or invalid.
+func (pa *PodAutoscaler) ExternalMetricSelector() (*metav1.LabelSelector, bool) {
+	if s, ok := pa.Annotations[autoscaling.ExternalMetricSelectorAnnotationKey]; ok {
+		sel, err := metav1.ParseToLabelSelector(s)
+		return sel, err == nil
+	}
+	return nil, false
+}
+
+// PanicExitThresholdPercentage returns the panic exit threshold annotation
+// value, or false if not present.
+func (pa *PodAutoscaler) PanicExitThresholdPercentage() (percentage float64, ok bool) {
+	// The value is validated in the webhook.
+	return pa.annotationFloat64(autoscaling.PanicExitThresholdPercentageAnnotationKey)
+}
+
+// PanicCooldown returns the panic cool-down annotation value, or false if not
+// present.
+func (pa *PodAutoscaler) PanicCooldown() (time.Duration, bool) {
+	// The value is validated in the webhook.
+	return pa.annotationDuration(autoscaling.PanicCooldownAnnotationKey)
+}
+
 // InitialScale returns the initial scale on the revision if present, or false if not present.
 func (pa *PodAutoscaler) InitialScale() (int32, bool) {
 	// The value is validated in the webhook.
 	return pa.annotationInt32(autoscaling.InitialScaleAnnotationKey)
 }
 
+// ActivatorSubsetSize returns the number of activators pinned for the revision
+// if present, or false if not present.
+func (pa *PodAutoscaler) ActivatorSubsetSize() (int32, bool) {
+	// The value is validated in the webhook.
+	return pa.annotationInt32(autoscaling.ActivatorSubsetSizeAnnotationKey)
+}
+
+// MinScaleUnavailablePolicy returns the policy for when the minimum scale
+// can't be satisfied for lack of capacity, MinScaleUnavailablePolicyHold
+// unless specified otherwise.
+func (pa *PodAutoscaler) MinScaleUnavailablePolicy() string {
+	// The value is validated in the webhook.
+	if p, ok := pa.Annotations[autoscaling.MinScaleUnavailablePolicyAnnotationKey]; ok {
+		return p
+	}
+	return autoscaling.MinScaleUnavailablePolicyHold
+}
+
 // IsReady returns true if the Status condition PodAutoscalerConditionReady
 // is true and the latest spec has been observed.
 func (pa *PodAutoscaler) IsReady() bool {
@@ -223,6 +387,12 @@ func (pas *PodAutoscalerStatus) MarkActive() {
 	podCondSet.Manage(pas).MarkTrue(PodAutoscalerConditionActive)
 }
 
+// MarkActiveWithReason marks the PA as active, with a reason for a caveat of
+// its activity.
+func (pas *PodAutoscalerStatus) MarkActiveWithReason(reason, message string) {
+	podCondSet.Manage(pas).MarkTrueWithReason(PodAutoscalerConditionActive, reason, message)
+}
+
 // MarkActivating marks the PA as activating.
 func (pas *PodAutoscalerStatus) MarkActivating(reason, message string) {
 	podCondSet.Manage(pas).MarkUnknown(PodAutoscalerConditionActive, reason, message)
@@ -257,6 +427,16 @@ func (pas *PodAutoscalerStatus) ActiveFor(now time.Time) time.Duration {
 	return pas.inStatusFor(corev1.ConditionTrue, now)
 }
 
+// ScaleTargetInitializedFor returns the time since the PA's scale target was
+// first initialized, or -1 if it wasn't yet.
+func (pas *PodAutoscalerStatus) ScaleTargetInitializedFor(now time.Time) time.Duration {
+	cond := pas.GetCondition(PodAutoscalerConditionScaleTargetInitialized)
+	if !cond.IsTrue() {
+		return -1
+	}
+	return now.Sub(cond.LastTransitionTime.Inner.Time)
+}
+
 // CanFailActivation checks whether the pod autoscaler has been activating
 // for at least the specified idle period.
 func (pas *PodAutoscalerStatus) CanFailActivation(now time.Time, idlePeriod time.Duration) bool {
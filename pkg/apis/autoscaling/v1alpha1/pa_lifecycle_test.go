@@ -20,6 +20,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -243,6 +244,51 @@ func TestActiveFor(t *testing.T) {
 	}
 }
 
+func TestScaleTargetInitializedFor(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name   string
+		status PodAutoscalerStatus
+		result time.Duration
+	}{{
+		name:   "empty status",
+		status: PodAutoscalerStatus{},
+		result: -1,
+	}, {
+		name: "not initialized",
+		status: PodAutoscalerStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{{
+					Type:               PodAutoscalerConditionScaleTargetInitialized,
+					Status:             corev1.ConditionUnknown,
+					LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(now.Add(-time.Minute))},
+				}},
+			},
+		},
+		result: -1,
+	}, {
+		name: "initialized",
+		status: PodAutoscalerStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{{
+					Type:               PodAutoscalerConditionScaleTargetInitialized,
+					Status:             corev1.ConditionTrue,
+					LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(now.Add(-time.Minute))},
+				}},
+			},
+		},
+		result: time.Minute,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := tc.status.ScaleTargetInitializedFor(now), tc.result; got != want {
+				t.Errorf("ScaleTargetInitializedFor = %v, want: %v", got, want)
+			}
+		})
+	}
+}
+
 func TestCanFailActivation(t *testing.T) {
 	now := time.Now()
 	cases := []struct {
@@ -537,6 +583,7 @@ func TestScaleBounds(t *testing.T) {
 		name         string
 		min          string
 		max          string
+		schedule     string
 		config       autoscalerconfig.Config
 		reachability ReachabilityType
 		wantMin      int32
@@ -596,8 +643,44 @@ func TestScaleBounds(t *testing.T) {
 		max:     "sandwich",
 		wantMin: 0,
 		wantMax: 0,
+	}, {
+		name:     "schedule",
+		min:      "1",
+		max:      "100",
+		schedule: "0 8 * * * min=5 max=50; 0 20 * * * min=0",
+		wantMin:  5,
+		wantMax:  50,
+	}, {
+		name:     "schedule, the window started yesterday",
+		min:      "1",
+		max:      "100",
+		schedule: "0 20 * * * min=5; 0 13 * * * min=0",
+		wantMin:  5,
+		wantMax:  100,
+	}, {
+		name:     "schedule, only max",
+		min:      "1",
+		schedule: "0 8 * * * max=3",
+		wantMin:  1,
+		wantMax:  3,
+	}, {
+		name:         "schedule, unreachable",
+		schedule:     "0 8 * * * min=5 max=50",
+		reachability: ReachabilityUnreachable,
+		wantMin:      0,
+		wantMax:      50,
+	}, {
+		name:     "schedule, malformed",
+		min:      "1",
+		max:      "100",
+		schedule: "0 8 * * * min=five",
+		wantMin:  1,
+		wantMax:  100,
 	}}
 
+	// Monday noon.
+	now := time.Date(2021, time.October, 4, 12, 0, 0, 0, time.UTC)
+
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			pa := pa(map[string]string{})
@@ -607,9 +690,12 @@ func TestScaleBounds(t *testing.T) {
 			if tc.max != "" {
 				pa.Annotations[autoscaling.MaxScaleAnnotationKey] = tc.max
 			}
+			if tc.schedule != "" {
+				pa.Annotations[autoscaling.ScaleScheduleAnnotationKey] = tc.schedule
+			}
 			pa.Spec.Reachability = tc.reachability
 
-			min, max := pa.ScaleBounds(&tc.config)
+			min, max := pa.scaleBoundsAt(&tc.config, now)
 
 			if min != tc.wantMin {
 				t.Errorf("got min: %v wanted: %v", min, tc.wantMin)
@@ -621,6 +707,19 @@ func TestScaleBounds(t *testing.T) {
 	}
 }
 
+func TestNextScaleBoundsChange(t *testing.T) {
+	now := time.Date(2021, time.October, 4, 12, 0, 0, 0, time.UTC)
+	if _, ok := pa(map[string]string{}).NextScaleBoundsChange(now); ok {
+		t.Error("NextScaleBoundsChange() = true without a schedule")
+	}
+	pa := pa(map[string]string{
+		autoscaling.ScaleScheduleAnnotationKey: "0 8 * * 1-5 min=5; 0 20 * * * min=0",
+	})
+	if got, ok := pa.NextScaleBoundsChange(now); !ok || !got.Equal(now.Add(8*time.Hour)) {
+		t.Errorf("NextScaleBoundsChange() = %v, want: %v", got, now.Add(8*time.Hour))
+	}
+}
+
 func TestMarkResourceNotOwned(t *testing.T) {
 	pa := pa(map[string]string{})
 	pa.Status.MarkResourceNotOwned("doesn't", "matter")
@@ -774,6 +873,155 @@ func TestScaleDownDelayAnnotation(t *testing.T) {
 	}
 }
 
+func TestPredictionPeriodAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		pa         *PodAutoscaler
+		wantPeriod time.Duration
+		wantOK     bool
+	}{{
+		name: "not present",
+		pa:   pa(map[string]string{}),
+	}, {
+		name: "present",
+		pa: pa(map[string]string{
+			autoscaling.PredictionPeriodAnnotationKey: "168h",
+		}),
+		wantPeriod: 7 * 24 * time.Hour,
+		wantOK:     true,
+	}, {
+		name: "disabled",
+		pa: pa(map[string]string{
+			autoscaling.PredictionPeriodAnnotationKey: "0s",
+		}),
+		wantOK: true,
+	}, {
+		name: "invalid",
+		pa: pa(map[string]string{
+			autoscaling.PredictionPeriodAnnotationKey: "weekly",
+		}),
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotPeriod, gotOK := tc.pa.PredictionPeriod()
+			if gotPeriod != tc.wantPeriod {
+				t.Errorf("PredictionPeriod = %v, want: %v", gotPeriod, tc.wantPeriod)
+			}
+			if gotOK != tc.wantOK {
+				t.Errorf("OK = %v, want: %v", gotOK, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestScalingAlgorithm(t *testing.T) {
+	if got, want := pa(map[string]string{}).ScalingAlgorithm(), autoscaling.ScalingAlgorithmWindow; got != want {
+		t.Errorf("ScalingAlgorithm() = %q, want: %q", got, want)
+	}
+	if got, want := pa(map[string]string{
+		autoscaling.ScalingAlgorithmAnnotationKey: "pid",
+	}).ScalingAlgorithm(), "pid"; got != want {
+		t.Errorf("ScalingAlgorithm() = %q, want: %q", got, want)
+	}
+}
+
+func TestMaxScaleDownPerMinuteAnnotation(t *testing.T) {
+	cases := []struct {
+		name      string
+		pa        *PodAutoscaler
+		wantLimit autoscaling.ScaleDownLimit
+		wantOK    bool
+	}{{
+		name: "not present",
+		pa:   pa(map[string]string{}),
+	}, {
+		name: "pods",
+		pa: pa(map[string]string{
+			autoscaling.MaxScaleDownPerMinuteAnnotationKey: "5",
+		}),
+		wantLimit: autoscaling.ScaleDownLimit{Value: 5},
+		wantOK:    true,
+	}, {
+		name: "percentage",
+		pa: pa(map[string]string{
+			autoscaling.MaxScaleDownPerMinuteAnnotationKey: "10%",
+		}),
+		wantLimit: autoscaling.ScaleDownLimit{Value: 10, Percent: true},
+		wantOK:    true,
+	}, {
+		name: "invalid",
+		pa: pa(map[string]string{
+			autoscaling.MaxScaleDownPerMinuteAnnotationKey: "slowly",
+		}),
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotLimit, gotOK := tc.pa.MaxScaleDownPerMinute()
+			if gotLimit != tc.wantLimit {
+				t.Errorf("MaxScaleDownPerMinute = %v, want: %v", gotLimit, tc.wantLimit)
+			}
+			if gotOK != tc.wantOK {
+				t.Errorf("OK = %v, want: %v", gotOK, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestCombinedMetricsAnnotations(t *testing.T) {
+	cases := []struct {
+		name            string
+		pa              *PodAutoscaler
+		wantMetrics     []autoscaling.MetricValue
+		wantOK          bool
+		wantCombination string
+		wantRPSWeight   float64
+	}{{
+		name:            "not present",
+		pa:              pa(map[string]string{}),
+		wantCombination: autoscaling.MetricCombinationMax,
+		wantRPSWeight:   1,
+	}, {
+		name: "present",
+		pa: pa(map[string]string{
+			autoscaling.CombinedMetricsAnnotationKey:   "rps=150, custom=5",
+			autoscaling.MetricCombinationAnnotationKey: autoscaling.MetricCombinationWeighted,
+			autoscaling.MetricWeightsAnnotationKey:     "rps=3",
+		}),
+		wantMetrics:     []autoscaling.MetricValue{{Metric: "rps", Value: 150}, {Metric: "custom", Value: 5}},
+		wantOK:          true,
+		wantCombination: autoscaling.MetricCombinationWeighted,
+		wantRPSWeight:   3,
+	}, {
+		name: "invalid",
+		pa: pa(map[string]string{
+			autoscaling.CombinedMetricsAnnotationKey: "rps",
+			autoscaling.MetricWeightsAnnotationKey:   "rps=heavy",
+		}),
+		wantCombination: autoscaling.MetricCombinationMax,
+		wantRPSWeight:   1,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotMetrics, gotOK := tc.pa.CombinedMetrics()
+			if !cmp.Equal(gotMetrics, tc.wantMetrics) {
+				t.Error("CombinedMetrics (-want, +got):", cmp.Diff(tc.wantMetrics, gotMetrics))
+			}
+			if gotOK != tc.wantOK {
+				t.Errorf("OK = %v, want: %v", gotOK, tc.wantOK)
+			}
+			if got := tc.pa.MetricCombination(); got != tc.wantCombination {
+				t.Errorf("MetricCombination = %q, want: %q", got, tc.wantCombination)
+			}
+			if got := tc.pa.MetricWeight(autoscaling.RPS); got != tc.wantRPSWeight {
+				t.Errorf("MetricWeight = %v, want: %v", got, tc.wantRPSWeight)
+			}
+		})
+	}
+}
+
 func TestWindowAnnotation(t *testing.T) {
 	cases := []struct {
 		name       string
@@ -1097,6 +1345,30 @@ func TestPodAutoscalerGetGroupVersionKind(t *testing.T) {
 	}
 }
 
+func TestKeepWarmPeriod(t *testing.T) {
+	if got, ok := pa(map[string]string{}).KeepWarmPeriod(); ok {
+		t.Errorf("KeepWarmPeriod() = %v, want not present", got)
+	}
+	got, ok := pa(map[string]string{
+		autoscaling.KeepWarmPeriodAnnotationKey: "2h30m",
+	}).KeepWarmPeriod()
+	if want := 150 * time.Minute; !ok || got != want {
+		t.Errorf("KeepWarmPeriod() = %v, %v, want: %v", got, ok, want)
+	}
+}
+
+func TestScaleToZeroExclusionPeriod(t *testing.T) {
+	if got, ok := pa(map[string]string{}).ScaleToZeroExclusionPeriod(); ok {
+		t.Errorf("ScaleToZeroExclusionPeriod() = %v, want not present", got)
+	}
+	got, ok := pa(map[string]string{
+		autoscaling.ScaleToZeroExclusionPeriodAnnotationKey: "15m",
+	}).ScaleToZeroExclusionPeriod()
+	if want := 15 * time.Minute; !ok || got != want {
+		t.Errorf("ScaleToZeroExclusionPeriod() = %v, %v, want: %v", got, ok, want)
+	}
+}
+
 func TestScaleToZeroPodRetention(t *testing.T) {
 	cases := []struct {
 		name   string
@@ -1143,6 +1415,48 @@ func TestScaleToZeroPodRetention(t *testing.T) {
 	}
 }
 
+func TestEnableScaleToZero(t *testing.T) {
+	cases := []struct {
+		name        string
+		pa          *PodAutoscaler
+		wantEnabled bool
+		wantOK      bool
+	}{{
+		name: "not present",
+		pa:   pa(map[string]string{}),
+	}, {
+		name: "enabled",
+		pa: pa(map[string]string{
+			autoscaling.EnableScaleToZeroAnnotationKey: "true",
+		}),
+		wantEnabled: true,
+		wantOK:      true,
+	}, {
+		name: "disabled",
+		pa: pa(map[string]string{
+			autoscaling.EnableScaleToZeroAnnotationKey: "false",
+		}),
+		wantOK: true,
+	}, {
+		name: "invalid",
+		pa: pa(map[string]string{
+			autoscaling.EnableScaleToZeroAnnotationKey: "nope",
+		}),
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, gotOK := tc.pa.EnableScaleToZero()
+			if got != tc.wantEnabled {
+				t.Errorf("EnableScaleToZero = %v, want: %v", got, tc.wantEnabled)
+			}
+			if gotOK != tc.wantOK {
+				t.Errorf("OK = %v, want: %v", gotOK, tc.wantOK)
+			}
+		})
+	}
+}
+
 func TestInitialScale(t *testing.T) {
 	cases := []struct {
 		name   string
@@ -1177,6 +1491,37 @@ func TestInitialScale(t *testing.T) {
 	}
 }
 
+func TestActivatorSubsetSize(t *testing.T) {
+	cases := []struct {
+		name   string
+		pa     *PodAutoscaler
+		want   int32
+		wantOK bool
+	}{{
+		name: "not present",
+		pa:   pa(map[string]string{}),
+	}, {
+		name: "present",
+		pa: pa(map[string]string{
+			autoscaling.ActivatorSubsetSizeAnnotationKey: "7",
+		}),
+		want:   7,
+		wantOK: true,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, gotOK := tc.pa.ActivatorSubsetSize()
+			if got != tc.want {
+				t.Errorf("ActivatorSubsetSize = %v, want: %v", got, tc.want)
+			}
+			if gotOK != tc.wantOK {
+				t.Errorf("OK = %v, want: %v", gotOK, tc.wantOK)
+			}
+		})
+	}
+}
+
 func TestIsScaleTargetInitialized(t *testing.T) {
 	p := PodAutoscaler{}
 	if got, want := p.Status.IsScaleTargetInitialized(), false; got != want {
@@ -95,6 +95,16 @@ type PodAutoscalerSpec struct {
 
 	// The application-layer protocol. Matches `ProtocolType` inferred from the revision spec.
 	ProtocolType net.ProtocolType `json:"protocolType"`
+
+	// Hibernated scales the `ScaleTargetRef` to zero, whatever its traffic.
+	// +optional
+	Hibernated bool `json:"hibernated,omitempty"`
+
+	// StartupWindowSeconds is the time the startup probe of the pods of the
+	// `ScaleTargetRef` allows them to take to start up, which extends the
+	// time given to their activation.
+	// +optional
+	StartupWindowSeconds int64 `json:"startupWindowSeconds,omitempty"`
 }
 
 const (
@@ -127,6 +137,26 @@ type PodAutoscalerStatus struct {
 
 	// ActualScale shows the actual number of replicas for the revision.
 	ActualScale *int32 `json:"actualScale,omitempty"`
+
+	// MetricDesiredScales shows the number of replicas each of the metrics
+	// recommends, if the revision is scaled on a combination of metrics.
+	// +optional
+	MetricDesiredScales []MetricDesiredScale `json:"metricDesiredScales,omitempty"`
+
+	// PanicStartTime is when the autoscaler entered panic mode for the
+	// revision, unset when it isn't panicking.
+	// +optional
+	PanicStartTime *metav1.Time `json:"panicStartTime,omitempty"`
+}
+
+// MetricDesiredScale is the number of replicas one of the metrics a revision
+// is scaled on recommends.
+type MetricDesiredScale struct {
+	// Metric is the metric, i.e. concurrency, rps or custom.
+	Metric string `json:"metric"`
+
+	// DesiredScale is the number of replicas the metric recommends.
+	DesiredScale int32 `json:"desiredScale"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
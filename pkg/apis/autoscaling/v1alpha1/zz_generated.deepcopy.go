@@ -53,6 +53,22 @@ func (in *Metric) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricDesiredScale) DeepCopyInto(out *MetricDesiredScale) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricDesiredScale.
+func (in *MetricDesiredScale) DeepCopy() *MetricDesiredScale {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricDesiredScale)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetricList) DeepCopyInto(out *MetricList) {
 	*out = *in
@@ -211,6 +227,15 @@ func (in *PodAutoscalerStatus) DeepCopyInto(out *PodAutoscalerStatus) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.MetricDesiredScales != nil {
+		in, out := &in.MetricDesiredScales, &out.MetricDesiredScales
+		*out = make([]MetricDesiredScale, len(*in))
+		copy(*out, *in)
+	}
+	if in.PanicStartTime != nil {
+		in, out := &in.PanicStartTime, &out.PanicStartTime
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
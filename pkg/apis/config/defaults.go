@@ -59,6 +59,12 @@ const (
 	// DefaultAllowContainerConcurrencyZero is whether, by default,
 	// containerConcurrency can be set to zero (i.e. unbounded) by users.
 	DefaultAllowContainerConcurrencyZero = true
+
+	// NamespaceDefaultsLabelKey is the label of the ConfigMaps of a namespace
+	// overriding the revision timeout, container concurrency, user container
+	// name, resource and queue-proxy resource defaults of config-defaults for
+	// the resources of the namespace.
+	NamespaceDefaultsLabelKey = "serving.knative.dev/namespace-defaults"
 )
 
 var (
@@ -107,49 +113,84 @@ func NewDefaultsConfigFromMap(data map[string]string) (*Defaults, error) {
 	nc := defaultDefaultsConfig()
 
 	if err := cm.Parse(data,
-		cm.AsString("container-name-template", &nc.UserContainerNameTemplate),
-
 		cm.AsBool("allow-container-concurrency-zero", &nc.AllowContainerConcurrencyZero),
 		asTriState("enable-service-links", &nc.EnableServiceLinks, nil),
 
-		cm.AsInt64("revision-timeout-seconds", &nc.RevisionTimeoutSeconds),
-		cm.AsInt64("max-revision-timeout-seconds", &nc.MaxRevisionTimeoutSeconds),
-		cm.AsInt64("container-concurrency", &nc.ContainerConcurrency),
 		cm.AsInt64("container-concurrency-max-limit", &nc.ContainerConcurrencyMaxLimit),
-
-		cm.AsQuantity("revision-cpu-request", &nc.RevisionCPURequest),
-		cm.AsQuantity("revision-memory-request", &nc.RevisionMemoryRequest),
-		cm.AsQuantity("revision-ephemeral-storage-request", &nc.RevisionEphemeralStorageRequest),
-		cm.AsQuantity("revision-cpu-limit", &nc.RevisionCPULimit),
-		cm.AsQuantity("revision-memory-limit", &nc.RevisionMemoryLimit),
-		cm.AsQuantity("revision-ephemeral-storage-limit", &nc.RevisionEphemeralStorageLimit),
 	); err != nil {
 		return nil, err
 	}
-
-	if nc.RevisionTimeoutSeconds > nc.MaxRevisionTimeoutSeconds {
-		return nil, fmt.Errorf("revision-timeout-seconds (%d) cannot be greater than max-revision-timeout-seconds (%d)", nc.RevisionTimeoutSeconds, nc.MaxRevisionTimeoutSeconds)
-	}
 	if nc.ContainerConcurrencyMaxLimit < 1 {
 		return nil, apis.ErrOutOfBoundsValue(
 			nc.ContainerConcurrencyMaxLimit, 1, math.MaxInt32, "container-concurrency-max-limit")
 	}
-	if nc.ContainerConcurrency < 0 || nc.ContainerConcurrency > nc.ContainerConcurrencyMaxLimit {
-		return nil, apis.ErrOutOfBoundsValue(
-			nc.ContainerConcurrency, 0, nc.ContainerConcurrencyMaxLimit, "container-concurrency")
+
+	if err := nc.parseNamespaced(data); err != nil {
+		return nil, err
 	}
+	return nc, nil
+}
 
-	tmpl, err := template.New("user-container").Parse(nc.UserContainerNameTemplate)
-	if err != nil {
+// WithNamespaceOverrides returns a copy of the defaults overridden by the data
+// of the namespace defaults ConfigMaps of a namespace. Only the revision
+// timeout, container concurrency, user container name, resource and
+// queue-proxy resource defaults may be overridden, and the max revision
+// timeout of a namespace may only be lowered.
+func (d *Defaults) WithNamespaceOverrides(data map[string]string) (*Defaults, error) {
+	nc := d.DeepCopy()
+	if err := nc.parseNamespaced(data); err != nil {
 		return nil, err
 	}
+	if nc.MaxRevisionTimeoutSeconds > d.MaxRevisionTimeoutSeconds {
+		return nil, fmt.Errorf("max-revision-timeout-seconds (%d) cannot be greater than the cluster-wide max-revision-timeout-seconds (%d)",
+			nc.MaxRevisionTimeoutSeconds, d.MaxRevisionTimeoutSeconds)
+	}
+	return nc, nil
+}
+
+// parseNamespaced parses the defaults which may be overridden by namespace
+// from data into d.
+func (d *Defaults) parseNamespaced(data map[string]string) error {
+	if err := cm.Parse(data,
+		cm.AsString("container-name-template", &d.UserContainerNameTemplate),
+
+		cm.AsInt64("revision-timeout-seconds", &d.RevisionTimeoutSeconds),
+		cm.AsInt64("max-revision-timeout-seconds", &d.MaxRevisionTimeoutSeconds),
+		cm.AsInt64("container-concurrency", &d.ContainerConcurrency),
+
+		cm.AsQuantity("revision-cpu-request", &d.RevisionCPURequest),
+		cm.AsQuantity("revision-memory-request", &d.RevisionMemoryRequest),
+		cm.AsQuantity("revision-ephemeral-storage-request", &d.RevisionEphemeralStorageRequest),
+		cm.AsQuantity("revision-cpu-limit", &d.RevisionCPULimit),
+		cm.AsQuantity("revision-memory-limit", &d.RevisionMemoryLimit),
+		cm.AsQuantity("revision-ephemeral-storage-limit", &d.RevisionEphemeralStorageLimit),
+
+		cm.AsFloat64("queue-sidecar-resource-percentage", &d.QueueSidecarResourcePercentage),
+	); err != nil {
+		return err
+	}
+
+	if d.RevisionTimeoutSeconds > d.MaxRevisionTimeoutSeconds {
+		return fmt.Errorf("revision-timeout-seconds (%d) cannot be greater than max-revision-timeout-seconds (%d)", d.RevisionTimeoutSeconds, d.MaxRevisionTimeoutSeconds)
+	}
+	if p := d.QueueSidecarResourcePercentage; p != 0 && (p < 0.1 || p > 100) {
+		return apis.ErrOutOfBoundsValue(p, 0.1, 100.0, "queue-sidecar-resource-percentage")
+	}
+	if d.ContainerConcurrency < 0 || d.ContainerConcurrency > d.ContainerConcurrencyMaxLimit {
+		return apis.ErrOutOfBoundsValue(
+			d.ContainerConcurrency, 0, d.ContainerConcurrencyMaxLimit, "container-concurrency")
+	}
+
+	tmpl, err := template.New("user-container").Parse(d.UserContainerNameTemplate)
+	if err != nil {
+		return err
+	}
 	// Check that the template properly applies to ObjectMeta.
 	if err := tmpl.Execute(io.Discard, metav1.ObjectMeta{}); err != nil {
-		return nil, fmt.Errorf("error executing template: %w", err)
+		return fmt.Errorf("error executing template: %w", err)
 	}
-	templateCache.Add(nc.UserContainerNameTemplate, tmpl)
-
-	return nc, nil
+	templateCache.Add(d.UserContainerNameTemplate, tmpl)
+	return nil
 }
 
 // NewDefaultsConfigFromConfigMap creates a Defaults from the supplied configMap.
@@ -186,6 +227,11 @@ type Defaults struct {
 	RevisionMemoryLimit             *resource.Quantity
 	RevisionEphemeralStorageRequest *resource.Quantity
 	RevisionEphemeralStorageLimit   *resource.Quantity
+
+	// QueueSidecarResourcePercentage is the default percentage of the
+	// resources of the user container given to the queue-proxy, zero to
+	// leave the queue-proxy resources to config-deployment.
+	QueueSidecarResourcePercentage float64
 }
 
 // UserContainerName returns the name of the user container based on the context.
@@ -50,6 +50,7 @@ func TestDefaultsConfigurationFromFile(t *testing.T) {
 	got.RevisionCPULimit, got.RevisionCPURequest = nil, nil
 	got.RevisionMemoryLimit, got.RevisionMemoryRequest = nil, nil
 	got.RevisionEphemeralStorageLimit, got.RevisionEphemeralStorageRequest = nil, nil
+	got.QueueSidecarResourcePercentage = 0
 	want := defaultDefaultsConfig()
 	if diff := cmp.Diff(want, got); diff != "" {
 		t.Error("Example does not represent default config: diff(-want,+got)\n", diff)
@@ -168,6 +169,12 @@ func TestDefaultsConfiguration(t *testing.T) {
 			"container-concurrency":           "11",
 			"container-concurrency-max-limit": "10",
 		},
+	}, {
+		name:    "queue-sidecar-resource-percentage out of bounds",
+		wantErr: true,
+		data: map[string]string{
+			"queue-sidecar-resource-percentage": "200",
+		},
 	}, {
 		name:    "container-concurrency-max-limit is invalid",
 		wantErr: true,
@@ -352,6 +352,24 @@ func TestFeaturesConfiguration(t *testing.T) {
 		data: map[string]string{
 			"kubernetes.podspec-volumes-emptydir": "Enabled",
 		},
+	}, {
+		name:    "kubernetes.podspec-persistent-volume-claim Enabled",
+		wantErr: false,
+		wantFeatures: defaultWith(&Features{
+			PodSpecPersistentVolumeClaim: Enabled,
+		}),
+		data: map[string]string{
+			"kubernetes.podspec-persistent-volume-claim": "Enabled",
+		},
+	}, {
+		name:    "kubernetes.podspec-persistent-volume-write Enabled",
+		wantErr: false,
+		wantFeatures: defaultWith(&Features{
+			PodSpecPersistentVolumeWrite: Enabled,
+		}),
+		data: map[string]string{
+			"kubernetes.podspec-persistent-volume-write": "Enabled",
+		},
 	}, {
 		name:    "kubernetes.podspec-priorityclassname Allowed",
 		wantErr: false,
@@ -379,6 +397,24 @@ func TestFeaturesConfiguration(t *testing.T) {
 		data: map[string]string{
 			"kubernetes.podspec-priorityclassname": "Disabled",
 		},
+	}, {
+		name:    "kubernetes.podspec-topologyspreadconstraints Enabled",
+		wantErr: false,
+		wantFeatures: defaultWith(&Features{
+			PodSpecTopologySpread: Enabled,
+		}),
+		data: map[string]string{
+			"kubernetes.podspec-topologyspreadconstraints": "Enabled",
+		},
+	}, {
+		name:    "kubernetes.podspec-topologyspreadconstraints Allowed",
+		wantErr: false,
+		wantFeatures: defaultWith(&Features{
+			PodSpecTopologySpread: Allowed,
+		}),
+		data: map[string]string{
+			"kubernetes.podspec-topologyspreadconstraints": "Allowed",
+		},
 	}}
 
 	for _, tt := range configTests {
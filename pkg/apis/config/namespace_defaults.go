@@ -0,0 +1,58 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"knative.dev/pkg/logging"
+)
+
+type namespaceDefaultsKey struct{}
+
+// NamespaceDefaultsFunc returns the data of the namespace defaults ConfigMaps
+// of the namespace, nil if it has none.
+type NamespaceDefaultsFunc func(namespace string) map[string]string
+
+// WithNamespaceDefaults attaches the NamespaceDefaultsFunc looking up the
+// namespace defaults to the context.
+func WithNamespaceDefaults(ctx context.Context, f NamespaceDefaultsFunc) context.Context {
+	return context.WithValue(ctx, namespaceDefaultsKey{}, f)
+}
+
+// ForNamespace returns the context with the Defaults of its Config overridden
+// by the namespace defaults of the namespace, if any. Invalid namespace
+// defaults are ignored.
+func ForNamespace(ctx context.Context, namespace string) context.Context {
+	f, ok := ctx.Value(namespaceDefaultsKey{}).(NamespaceDefaultsFunc)
+	if !ok || namespace == "" {
+		return ctx
+	}
+	data := f(namespace)
+	if len(data) == 0 {
+		return ctx
+	}
+	cfg := *FromContextOrDefaults(ctx)
+	defaults, err := cfg.Defaults.WithNamespaceOverrides(data)
+	if err != nil {
+		logging.FromContext(ctx).Warnw("Ignoring the invalid defaults of namespace "+namespace, zap.Error(err))
+		return ctx
+	}
+	cfg.Defaults = defaults
+	return ToContext(ctx, &cfg)
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func TestForNamespace(t *testing.T) {
+	namespaceDefaults := func(namespace string) map[string]string {
+		switch namespace {
+		case "tenant":
+			return map[string]string{
+				"revision-timeout-seconds":          "60",
+				"revision-memory-limit":             "1Gi",
+				"queue-sidecar-resource-percentage": "20",
+				"max-revision-timeout-seconds":      "300",
+				"container-concurrency":             "10",
+				"container-name-template":           "{{.Name}}-app",
+				// Not overridable by namespace.
+				"container-concurrency-max-limit": "5",
+			}
+		case "invalid":
+			return map[string]string{"revision-timeout-seconds": "6000"}
+		case "raised":
+			return map[string]string{"max-revision-timeout-seconds": "6000"}
+		}
+		return nil
+	}
+	ctx := WithNamespaceDefaults(logtesting.TestContextWithLogger(t), namespaceDefaults)
+
+	got := FromContextOrDefaults(ForNamespace(ctx, "tenant")).Defaults
+	if got.RevisionTimeoutSeconds != 60 {
+		t.Errorf("RevisionTimeoutSeconds = %d, want: 60", got.RevisionTimeoutSeconds)
+	}
+	if want := resource.MustParse("1Gi"); got.RevisionMemoryLimit == nil || !got.RevisionMemoryLimit.Equal(want) {
+		t.Errorf("RevisionMemoryLimit = %v, want: %v", got.RevisionMemoryLimit, want)
+	}
+	if got.QueueSidecarResourcePercentage != 20 {
+		t.Errorf("QueueSidecarResourcePercentage = %v, want: 20", got.QueueSidecarResourcePercentage)
+	}
+	if got.MaxRevisionTimeoutSeconds != 300 {
+		t.Errorf("MaxRevisionTimeoutSeconds = %d, want: 300", got.MaxRevisionTimeoutSeconds)
+	}
+	if got.ContainerConcurrency != 10 {
+		t.Errorf("ContainerConcurrency = %d, want: 10", got.ContainerConcurrency)
+	}
+	if got.ContainerConcurrencyMaxLimit != DefaultMaxRevisionContainerConcurrency {
+		t.Errorf("ContainerConcurrencyMaxLimit = %d, want: %d", got.ContainerConcurrencyMaxLimit, DefaultMaxRevisionContainerConcurrency)
+	}
+	if got, want := got.UserContainerName(context.Background()), "-app"; got != want {
+		t.Errorf("UserContainerName = %q, want: %q", got, want)
+	}
+	if def := FromContextOrDefaults(ctx).Defaults; def.RevisionTimeoutSeconds != DefaultRevisionTimeoutSeconds {
+		t.Errorf("RevisionTimeoutSeconds of the cluster = %d, want: %d", def.RevisionTimeoutSeconds, DefaultRevisionTimeoutSeconds)
+	}
+
+	for _, namespace := range []string{"other", "invalid", "raised"} {
+		got := FromContextOrDefaults(ForNamespace(ctx, namespace)).Defaults
+		if got.RevisionTimeoutSeconds != DefaultRevisionTimeoutSeconds {
+			t.Errorf("RevisionTimeoutSeconds in namespace %s = %d, want: %d", namespace, got.RevisionTimeoutSeconds, DefaultRevisionTimeoutSeconds)
+		}
+		if got.MaxRevisionTimeoutSeconds != DefaultMaxRevisionTimeoutSeconds {
+			t.Errorf("MaxRevisionTimeoutSeconds in namespace %s = %d, want: %d", namespace, got.MaxRevisionTimeoutSeconds, DefaultMaxRevisionTimeoutSeconds)
+		}
+	}
+
+	if got := ForNamespace(context.Background(), "tenant"); got != context.Background() {
+		t.Error("ForNamespace() without namespace defaults changed the context")
+	}
+}
@@ -63,7 +63,10 @@ var (
 	reservedPorts = sets.NewInt32(
 		networking.BackendHTTPPort,
 		networking.BackendHTTP2Port,
+		networking.BackendHTTPSPort,
 		networking.QueueAdminPort,
+		networking.DebugPort,
+		networking.QueueLocalAdminPort,
 		networking.AutoscalingQueueMetricsPort,
 		networking.UserQueueMetricsPort,
 		profiling.ProfilingPort)
@@ -91,6 +94,10 @@ func ValidateVolumes(ctx context.Context, vs []corev1.Volume, mountedVolumes set
 			errs = errs.Also((&apis.FieldError{Message: fmt.Sprintf("EmptyDir volume support is off, "+
 				"but found EmptyDir volume %s", volume.Name)}).ViaIndex(i))
 		}
+		if volume.PersistentVolumeClaim != nil && features.PodSpecPersistentVolumeClaim != config.Enabled {
+			errs = errs.Also((&apis.FieldError{Message: fmt.Sprintf("Persistent volume claim support is off, "+
+				"but found persistent volume claim %s", volume.Name)}).ViaIndex(i))
+		}
 		if _, ok := volumes[volume.Name]; ok {
 			errs = errs.Also((&apis.FieldError{
 				Message: fmt.Sprintf("duplicate volume name %q", volume.Name),
@@ -142,12 +149,19 @@ func validateVolume(ctx context.Context, volume corev1.Volume) *apis.FieldError
 		specified = append(specified, "emptyDir")
 		errs = errs.Also(validateEmptyDirFields(vs.EmptyDir).ViaField("emptyDir"))
 	}
+	cfg := config.FromContextOrDefaults(ctx)
+	if vs.PersistentVolumeClaim != nil {
+		specified = append(specified, "persistentVolumeClaim")
+		errs = errs.Also(validatePersistentVolumeClaim(vs.PersistentVolumeClaim, cfg.Features).ViaField("persistentVolumeClaim"))
+	}
 	if len(specified) == 0 {
 		fieldPaths := []string{"secret", "configMap", "projected"}
-		cfg := config.FromContextOrDefaults(ctx)
 		if cfg.Features.PodSpecVolumesEmptyDir == config.Enabled {
 			fieldPaths = append(fieldPaths, "emptyDir")
 		}
+		if cfg.Features.PodSpecPersistentVolumeClaim == config.Enabled {
+			fieldPaths = append(fieldPaths, "persistentVolumeClaim")
+		}
 		errs = errs.Also(apis.ErrMissingOneOf(fieldPaths...))
 	} else if len(specified) > 1 {
 		errs = errs.Also(apis.ErrMultipleOneOf(specified...))
@@ -225,6 +239,23 @@ func validateKeyToPath(k2p corev1.KeyToPath) *apis.FieldError {
 	return errs
 }
 
+// validatePersistentVolumeClaim validates the claim of a volume, which may
+// only be mounted read-write if allowed by the features.
+func validatePersistentVolumeClaim(pvc *corev1.PersistentVolumeClaimVolumeSource, features *config.Features) *apis.FieldError {
+	var errs *apis.FieldError
+	if pvc.ClaimName == "" {
+		errs = errs.Also(apis.ErrMissingField("claimName"))
+	}
+	if !pvc.ReadOnly && features.PodSpecPersistentVolumeWrite != config.Enabled {
+		errs = errs.Also(&apis.FieldError{
+			Message: fmt.Sprintf("Persistent volume write support is off, "+
+				"but found persistent volume claim %s that is not read-only", pvc.ClaimName),
+			Paths: []string{"readOnly"},
+		})
+	}
+	return errs
+}
+
 func validateEmptyDirFields(dir *corev1.EmptyDirVolumeSource) *apis.FieldError {
 	var errs *apis.FieldError
 	if dir.Medium != "" && dir.Medium != "Memory" {
@@ -315,6 +346,9 @@ func ValidatePodSpec(ctx context.Context, ps corev1.PodSpec) *apis.FieldError {
 	errs := apis.CheckDisallowedFields(ps, *PodSpecMask(ctx, &ps))
 
 	errs = errs.Also(ValidatePodSecurityContext(ctx, ps.SecurityContext).ViaField("securityContext"))
+	for i, c := range ps.TopologySpreadConstraints {
+		errs = errs.Also(validateTopologySpreadConstraint(c).ViaFieldIndex("topologySpreadConstraints", i))
+	}
 
 	volumes, err := ValidateVolumes(ctx, ps.Volumes, AllMountedVolumes(ps.Containers))
 	if err != nil {
@@ -338,6 +372,28 @@ func ValidatePodSpec(ctx context.Context, ps corev1.PodSpec) *apis.FieldError {
 	return errs
 }
 
+// validateTopologySpreadConstraint validates the fields of a pod topology
+// spread constraint the API server would otherwise reject when creating the
+// Deployment of the Revision.
+func validateTopologySpreadConstraint(c corev1.TopologySpreadConstraint) (errs *apis.FieldError) {
+	if c.MaxSkew <= 0 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(c.MaxSkew, 1, math.MaxInt32, "maxSkew"))
+	}
+	if c.TopologyKey == "" {
+		errs = errs.Also(apis.ErrMissingField("topologyKey"))
+	} else if el := validation.IsQualifiedName(c.TopologyKey); len(el) > 0 {
+		errs = errs.Also(apis.ErrInvalidValue(c.TopologyKey, "topologyKey", el...))
+	}
+	switch c.WhenUnsatisfiable {
+	case corev1.DoNotSchedule, corev1.ScheduleAnyway:
+	case "":
+		errs = errs.Also(apis.ErrMissingField("whenUnsatisfiable"))
+	default:
+		errs = errs.Also(apis.ErrInvalidValue(c.WhenUnsatisfiable, "whenUnsatisfiable"))
+	}
+	return errs
+}
+
 func validateContainers(ctx context.Context, containers []corev1.Container, volumes map[string]corev1.Volume) (errs *apis.FieldError) {
 	features := config.FromContextOrDefaults(ctx).Features
 	if features.MultiContainer != config.Enabled {
@@ -395,6 +451,10 @@ func validateSidecarContainer(ctx context.Context, container corev1.Container, v
 		errs = errs.Also(apis.CheckDisallowedFields(*container.ReadinessProbe,
 			*ProbeMask(&corev1.Probe{})).ViaField("readinessProbe"))
 	}
+	if container.StartupProbe != nil {
+		errs = errs.Also(apis.CheckDisallowedFields(*container.StartupProbe,
+			*ProbeMask(&corev1.Probe{})).ViaField("startupProbe"))
+	}
 	return errs.Also(validate(ctx, container, volumes))
 }
 
@@ -406,6 +466,8 @@ func ValidateContainer(ctx context.Context, container corev1.Container, volumes
 	errs = errs.Also(validateProbe(container.LivenessProbe).ViaField("livenessProbe"))
 	// Readiness Probes
 	errs = errs.Also(validateReadinessProbe(container.ReadinessProbe).ViaField("readinessProbe"))
+	// Startup Probes
+	errs = errs.Also(validateStartupProbe(container.StartupProbe).ViaField("startupProbe"))
 	return errs.Also(validate(ctx, container, volumes))
 }
 
@@ -538,7 +600,9 @@ func validateVolumeMounts(mounts []corev1.VolumeMount, volumes map[string]corev1
 		}
 		seenMountPath.Insert(filepath.Clean(vm.MountPath))
 
-		if volumes[vm.Name].EmptyDir == nil && !vm.ReadOnly {
+		// Only the volumes validated as writable may be mounted read-write.
+		if v := volumes[vm.Name]; !vm.ReadOnly && v.EmptyDir == nil &&
+			(v.PersistentVolumeClaim == nil || v.PersistentVolumeClaim.ReadOnly) {
 			errs = errs.Also(apis.ErrMissingField("readOnly").ViaIndex(i))
 		}
 	}
@@ -632,6 +696,29 @@ func validateReadinessProbe(p *corev1.Probe) *apis.FieldError {
 	return errs
 }
 
+func validateStartupProbe(p *corev1.Probe) *apis.FieldError {
+	if p == nil {
+		return nil
+	}
+
+	errs := validateProbe(p)
+
+	if p.InitialDelaySeconds < 0 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(p.InitialDelaySeconds, 0, math.MaxInt32, "initialDelaySeconds"))
+	}
+	if p.PeriodSeconds < 0 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(p.PeriodSeconds, 0, math.MaxInt32, "periodSeconds"))
+	}
+	if p.FailureThreshold < 0 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(p.FailureThreshold, 0, math.MaxInt32, "failureThreshold"))
+	}
+	// Like Kubernetes, a startup probe succeeds at its first success.
+	if p.SuccessThreshold > 1 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(p.SuccessThreshold, 0, 1, "successThreshold"))
+	}
+	return errs
+}
+
 func validateProbe(p *corev1.Probe) *apis.FieldError {
 	if p == nil {
 		return nil
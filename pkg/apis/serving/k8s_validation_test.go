@@ -103,6 +103,20 @@ func withPodSpecVolumesEmptyDirEnabled() configOption {
 	}
 }
 
+func withPodSpecPersistentVolumeClaimEnabled() configOption {
+	return func(cfg *config.Config) *config.Config {
+		cfg.Features.PodSpecPersistentVolumeClaim = config.Enabled
+		return cfg
+	}
+}
+
+func withPodSpecPersistentVolumeWriteEnabled() configOption {
+	return func(cfg *config.Config) *config.Config {
+		cfg.Features.PodSpecPersistentVolumeWrite = config.Enabled
+		return cfg
+	}
+}
+
 func withPodSpecPriorityClassNameEnabled() configOption {
 	return func(cfg *config.Config) *config.Config {
 		cfg.Features.PodSpecPriorityClassName = config.Enabled
@@ -110,6 +124,13 @@ func withPodSpecPriorityClassNameEnabled() configOption {
 	}
 }
 
+func withPodSpecTopologySpreadEnabled() configOption {
+	return func(cfg *config.Config) *config.Config {
+		cfg.Features.PodSpecTopologySpread = config.Enabled
+		return cfg
+	}
+}
+
 func TestPodSpecValidation(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -279,6 +300,33 @@ func TestPodSpecValidation(t *testing.T) {
 			ServiceAccountName: "foo@bar.baz",
 		},
 		want: apis.ErrInvalidValue("foo@bar.baz", "serviceAccountName"),
+	}, {
+		name: "valid topology spread constraint",
+		ps: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Image: "busybox",
+			}},
+			TopologySpreadConstraints: []corev1.TopologySpreadConstraint{{
+				MaxSkew:           1,
+				TopologyKey:       "topology.kubernetes.io/zone",
+				WhenUnsatisfiable: corev1.DoNotSchedule,
+			}},
+		},
+		cfgOpts: []configOption{withPodSpecTopologySpreadEnabled()},
+	}, {
+		name: "invalid topology spread constraint",
+		ps: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Image: "busybox",
+			}},
+			TopologySpreadConstraints: []corev1.TopologySpreadConstraint{{
+				WhenUnsatisfiable: "Sometimes",
+			}},
+		},
+		cfgOpts: []configOption{withPodSpecTopologySpreadEnabled()},
+		want: apis.ErrOutOfBoundsValue(0, 1, math.MaxInt32, "topologySpreadConstraints[0].maxSkew").Also(
+			apis.ErrMissingField("topologySpreadConstraints[0].topologyKey"),
+			apis.ErrInvalidValue("Sometimes", "topologySpreadConstraints[0].whenUnsatisfiable")),
 	}}
 
 	for _, test := range tests {
@@ -694,6 +742,20 @@ func TestPodSpecFeatureValidation(t *testing.T) {
 			Paths:   []string{"priorityClassName"},
 		},
 		cfgOpts: []configOption{withPodSpecPriorityClassNameEnabled()},
+	}, {
+		name: "TopologySpreadConstraints",
+		featureSpec: corev1.PodSpec{
+			TopologySpreadConstraints: []corev1.TopologySpreadConstraint{{
+				MaxSkew:           1,
+				TopologyKey:       "topology.kubernetes.io/zone",
+				WhenUnsatisfiable: corev1.ScheduleAnyway,
+			}},
+		},
+		err: &apis.FieldError{
+			Message: "must not set the field(s)",
+			Paths:   []string{"topologySpreadConstraints"},
+		},
+		cfgOpts: []configOption{withPodSpecTopologySpreadEnabled()},
 	}}
 
 	featureTests := []struct {
@@ -1047,6 +1109,24 @@ func TestContainerValidation(t *testing.T) {
 			}},
 		},
 		want: apis.ErrInvalidValue(8022, "ports.containerPort"),
+	}, {
+		name: "port conflicts with queue proxy debug",
+		c: corev1.Container{
+			Image: "foo",
+			Ports: []corev1.ContainerPort{{
+				ContainerPort: 8009,
+			}},
+		},
+		want: apis.ErrInvalidValue(8009, "ports.containerPort"),
+	}, {
+		name: "port conflicts with queue proxy local admin",
+		c: corev1.Container{
+			Image: "foo",
+			Ports: []corev1.ContainerPort{{
+				ContainerPort: 8023,
+			}},
+		},
+		want: apis.ErrInvalidValue(8023, "ports.containerPort"),
 	}, {
 		name: "port conflicts with queue proxy",
 		c: corev1.Container{
@@ -1065,6 +1145,15 @@ func TestContainerValidation(t *testing.T) {
 			}},
 		},
 		want: apis.ErrInvalidValue(8012, "ports.containerPort"),
+	}, {
+		name: "port conflicts with queue proxy https",
+		c: corev1.Container{
+			Image: "foo",
+			Ports: []corev1.ContainerPort{{
+				ContainerPort: 8112,
+			}},
+		},
+		want: apis.ErrInvalidValue(8112, "ports.containerPort"),
 	}, {
 		name: "port conflicts with queue proxy metrics",
 		c: corev1.Container{
@@ -1194,6 +1283,46 @@ func TestContainerValidation(t *testing.T) {
 				},
 			},
 		},
+	}, {
+		name: "persistent volume claim has rw access",
+		c: corev1.Container{
+			Image: "foo",
+			VolumeMounts: []corev1.VolumeMount{{
+				MountPath: "/mount/path",
+				Name:      "the-name",
+			}},
+		},
+		volumes: map[string]corev1.Volume{
+			"the-name": {
+				Name: "the-name",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: "shared",
+					},
+				},
+			},
+		},
+	}, {
+		name: "read-only persistent volume claim has rw access",
+		c: corev1.Container{
+			Image: "foo",
+			VolumeMounts: []corev1.VolumeMount{{
+				MountPath: "/mount/path",
+				Name:      "the-name",
+			}},
+		},
+		volumes: map[string]corev1.Volume{
+			"the-name": {
+				Name: "the-name",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: "weights",
+						ReadOnly:  true,
+					},
+				},
+			},
+		},
+		want: apis.ErrMissingField("volumeMounts[0].readOnly"),
 	}, {
 		name: "has lifecycle",
 		c: corev1.Container{
@@ -1272,6 +1401,33 @@ func TestContainerValidation(t *testing.T) {
 			},
 		},
 		want: nil,
+	}, {
+		name: "valid with startup probe",
+		c: corev1.Container{
+			Image: "foo",
+			StartupProbe: &corev1.Probe{
+				PeriodSeconds:    10,
+				FailureThreshold: 30,
+				Handler: corev1.Handler{
+					HTTPGet: &corev1.HTTPGetAction{
+						Path: "/started",
+					},
+				},
+			},
+		},
+		want: nil,
+	}, {
+		name: "invalid startup probe success threshold",
+		c: corev1.Container{
+			Image: "foo",
+			StartupProbe: &corev1.Probe{
+				SuccessThreshold: 2,
+				Handler: corev1.Handler{
+					TCPSocket: &corev1.TCPSocketAction{},
+				},
+			},
+		},
+		want: apis.ErrOutOfBoundsValue(2, 0, 1, "startupProbe.successThreshold"),
 	}, {
 		name: "invalid with no handler",
 		c: corev1.Container{
@@ -1671,6 +1827,75 @@ func TestVolumeValidation(t *testing.T) {
 		},
 		want:    apis.ErrInvalidValue(-1, "emptyDir.sizeLimit"),
 		cfgOpts: []configOption{withPodSpecVolumesEmptyDirEnabled()},
+	}, {
+		name: "read-only persistentVolumeClaim volume",
+		v: corev1.Volume{
+			Name: "foo",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: "weights",
+					ReadOnly:  true,
+				},
+			},
+		},
+		cfgOpts: []configOption{withPodSpecPersistentVolumeClaimEnabled()},
+	}, {
+		name: "persistentVolumeClaim volume disabled",
+		v: corev1.Volume{
+			Name: "foo",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: "weights",
+					ReadOnly:  true,
+				},
+			},
+		},
+		want: apis.ErrDisallowedFields("persistentVolumeClaim"),
+	}, {
+		name: "read-write persistentVolumeClaim volume",
+		v: corev1.Volume{
+			Name: "foo",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: "shared",
+				},
+			},
+		},
+		cfgOpts: []configOption{withPodSpecPersistentVolumeClaimEnabled(), withPodSpecPersistentVolumeWriteEnabled()},
+	}, {
+		name: "read-write persistentVolumeClaim volume without write support",
+		v: corev1.Volume{
+			Name: "foo",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: "shared",
+				},
+			},
+		},
+		want: &apis.FieldError{
+			Message: "Persistent volume write support is off, but found persistent volume claim shared that is not read-only",
+			Paths:   []string{"persistentVolumeClaim.readOnly"},
+		},
+		cfgOpts: []configOption{withPodSpecPersistentVolumeClaimEnabled()},
+	}, {
+		name: "persistentVolumeClaim volume without claim",
+		v: corev1.Volume{
+			Name: "foo",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ReadOnly: true,
+				},
+			},
+		},
+		want:    apis.ErrMissingField("persistentVolumeClaim.claimName"),
+		cfgOpts: []configOption{withPodSpecPersistentVolumeClaimEnabled()},
+	}, {
+		name: "no volume source with persistentVolumeClaim support",
+		v: corev1.Volume{
+			Name: "foo",
+		},
+		want:    apis.ErrMissingOneOf("secret", "configMap", "projected", "persistentVolumeClaim"),
+		cfgOpts: []configOption{withPodSpecPersistentVolumeClaimEnabled()},
 	}, {
 		name: "no volume source",
 		v: corev1.Volume{
@@ -17,13 +17,20 @@ limitations under the License.
 package serving
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"golang.org/x/net/http/httpguts"
 	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"knative.dev/pkg/apis"
 	"knative.dev/serving/pkg/apis/autoscaling"
@@ -75,6 +82,215 @@ func ValidateRolloutDurationAnnotation(annos map[string]string) (errs *apis.Fiel
 	return errs
 }
 
+// ValidateRolloutDuration validates the rollout duration of the spec of
+// service or route objects, which must be positive at second precision.
+func ValidateRolloutDuration(d time.Duration) *apis.FieldError {
+	switch {
+	case d.Round(time.Second) != d:
+		return &apis.FieldError{
+			Message: fmt.Sprintf("rolloutDuration=%v is not at second precision", d),
+			Paths:   []string{apis.CurrentField},
+		}
+	case d < 0:
+		return &apis.FieldError{
+			Message: fmt.Sprintf("rolloutDuration=%v must be positive", d),
+			Paths:   []string{apis.CurrentField},
+		}
+	}
+	return nil
+}
+
+// ValidateRolloutGuardAnnotations validates the annotations guarding the
+// rollout against error rate and latency. These annotations can be set on
+// either service or route objects.
+func ValidateRolloutGuardAnnotations(annos map[string]string) (errs *apis.FieldError) {
+	if v := annos[RolloutMaxErrorRateKey]; v != "" {
+		if r, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, RolloutMaxErrorRateKey))
+		} else if r <= 0 || r > 1 {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(v, "0 (exclusive)", 1, RolloutMaxErrorRateKey))
+		}
+	}
+	if v := annos[RolloutMaxLatencyKey]; v != "" {
+		if d, err := time.ParseDuration(v); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, RolloutMaxLatencyKey))
+		} else if d <= 0 {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("rolloutMaxLatency=%s must be positive", v),
+				Paths:   []string{RolloutMaxLatencyKey},
+			})
+		}
+	}
+	return errs
+}
+
+// ValidateReadinessGateAnnotations validates the readiness gate timeout and
+// action annotations. These annotations can be set on service, route and
+// revision objects.
+func ValidateReadinessGateAnnotations(annos map[string]string) (errs *apis.FieldError) {
+	if v := annos[ReadinessGateTimeoutKey]; v != "" {
+		if d, err := time.ParseDuration(v); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, ReadinessGateTimeoutKey))
+		} else if d <= 0 {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("readinessGateTimeout=%s must be positive", v),
+				Paths:   []string{ReadinessGateTimeoutKey},
+			})
+		}
+	}
+	if v, ok := annos[ReadinessGateTimeoutActionKey]; ok {
+		if v != ReadinessGateTimeoutActionFail && v != ReadinessGateTimeoutActionProceed {
+			errs = errs.Also(apis.ErrInvalidValue(v, ReadinessGateTimeoutActionKey))
+		}
+	}
+	return errs
+}
+
+// ValidateCertificateIssuerAnnotation validates the certificate issuer
+// annotation, which must name a ClusterIssuer. This annotation can be set on
+// service, route and domain mapping objects.
+func ValidateCertificateIssuerAnnotation(annos map[string]string) *apis.FieldError {
+	if v, ok := annos[CertificateIssuerKey]; ok {
+		if msgs := validation.IsDNS1123Subdomain(v); len(msgs) > 0 {
+			return apis.ErrInvalidValue(v, CertificateIssuerKey, strings.Join(msgs, "; "))
+		}
+	}
+	return nil
+}
+
+// ValidateCORSAnnotations validates the CORS annotations, which need the
+// allowed origins to be set. These annotations can be set on service, route
+// and domain mapping objects.
+func ValidateCORSAnnotations(annos map[string]string) (errs *apis.FieldError) {
+	origins, ok := annos[CORSAllowOriginsKey]
+	if !ok {
+		for _, key := range []string{CORSAllowMethodsKey, CORSAllowHeadersKey, CORSMaxAgeKey} {
+			if _, ok := annos[key]; ok {
+				errs = errs.Also(&apis.FieldError{
+					Message: fmt.Sprintf("%s requires %s to be set", key, CORSAllowOriginsKey),
+					Paths:   []string{key},
+				})
+			}
+		}
+		return errs
+	}
+	for _, o := range strings.Split(origins, ",") {
+		if o = strings.TrimSpace(o); o == "*" {
+			continue
+		}
+		if u, err := url.Parse(o); err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+			u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+			errs = errs.Also(apis.ErrInvalidValue(o, CORSAllowOriginsKey,
+				"origins must be * or a scheme and a host, e.g. https://example.com"))
+		}
+	}
+	if v, ok := annos[CORSAllowMethodsKey]; ok {
+		for _, m := range strings.Split(v, ",") {
+			if m = strings.TrimSpace(m); !httpguts.ValidHeaderFieldName(m) {
+				errs = errs.Also(apis.ErrInvalidValue(m, CORSAllowMethodsKey))
+			}
+		}
+	}
+	if v, ok := annos[CORSAllowHeadersKey]; ok {
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "*" && !httpguts.ValidHeaderFieldName(h) {
+				errs = errs.Also(apis.ErrInvalidValue(h, CORSAllowHeadersKey))
+			}
+		}
+	}
+	if v, ok := annos[CORSMaxAgeKey]; ok {
+		if d, err := time.ParseDuration(v); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, CORSMaxAgeKey))
+		} else if d < 0 || d.Truncate(time.Second) != d {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("corsMaxAge=%s must be non-negative and have at most a second precision", v),
+				Paths:   []string{CORSMaxAgeKey},
+			})
+		}
+	}
+	return errs
+}
+
+// ValidateMirrorAnnotations validates the traffic mirroring annotations, the
+// percentage needing the shadow Revision to be set. These annotations can be
+// set on service and route objects.
+func ValidateMirrorAnnotations(annos map[string]string) (errs *apis.FieldError) {
+	revision, ok := annos[MirrorRevisionKey]
+	if !ok {
+		if _, ok := annos[MirrorPercentKey]; ok {
+			return &apis.FieldError{
+				Message: fmt.Sprintf("%s requires %s to be set", MirrorPercentKey, MirrorRevisionKey),
+				Paths:   []string{MirrorPercentKey},
+			}
+		}
+		return nil
+	}
+	if msgs := validation.IsDNS1035Label(revision); len(msgs) > 0 {
+		errs = errs.Also(apis.ErrInvalidValue(revision, MirrorRevisionKey, strings.Join(msgs, "; ")))
+	}
+	if v, ok := annos[MirrorPercentKey]; ok {
+		if p, err := strconv.ParseFloat(v, 64); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, MirrorPercentKey))
+		} else if p <= 0 || p > 100 {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(v, "0 (exclusive)", 100, MirrorPercentKey))
+		}
+	}
+	return errs
+}
+
+// responseHeaderRules mirrors the rules the queue-proxy applies to the
+// headers of the responses, as declared by the
+// QueueSideCarResponseHeadersAnnotation and the TagResponseHeadersKey
+// annotations.
+type responseHeaderRules struct {
+	Set    map[string]string `json:"set,omitempty"`
+	Add    map[string]string `json:"add,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// ValidateResponseHeaderRules validates JSON serialized response header
+// rules, which must not have unknown fields.
+func ValidateResponseHeaderRules(rules []byte) (errs *apis.FieldError) {
+	var r responseHeaderRules
+	dec := json.NewDecoder(bytes.NewReader(rules))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		return apis.ErrInvalidValue(string(rules), apis.CurrentField, err.Error())
+	}
+	names := make([]string, 0, len(r.Set)+len(r.Add)+len(r.Remove))
+	for name := range r.Set {
+		names = append(names, name)
+	}
+	for name := range r.Add {
+		names = append(names, name)
+	}
+	names = append(names, r.Remove...)
+	sort.Strings(names)
+	for _, name := range names {
+		if len(validation.IsHTTPHeaderName(name)) > 0 {
+			errs = errs.Also(apis.ErrInvalidKeyName(name, apis.CurrentField))
+		}
+	}
+	return errs
+}
+
+// ValidateTagResponseHeadersAnnotation validates the response header rules
+// by traffic tag. This annotation can be set on service and route objects.
+func ValidateTagResponseHeadersAnnotation(annos map[string]string) (errs *apis.FieldError) {
+	v, ok := annos[TagResponseHeadersKey]
+	if !ok {
+		return nil
+	}
+	var rules map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(v), &rules); err != nil {
+		return apis.ErrInvalidValue(v, TagResponseHeadersKey, err.Error())
+	}
+	for tag, r := range rules {
+		errs = errs.Also(ValidateResponseHeaderRules(r).ViaKey(tag))
+	}
+	return errs.ViaKey(TagResponseHeadersKey)
+}
+
 // ValidateHasNoAutoscalingAnnotation validates that the respective entity does not have
 // annotations from the autoscaling group. It's to be used to validate Service and
 // Configuration.
@@ -478,6 +478,49 @@ func TestAnnotationUpdate(t *testing.T) {
 	}
 }
 
+func TestValidateReadinessGateAnnotations(t *testing.T) {
+	tests := []struct {
+		name  string
+		annos map[string]string
+		want  string
+	}{{
+		name: "empty",
+	}, {
+		name: "valid",
+		annos: map[string]string{
+			ReadinessGateTimeoutKey:       "5m",
+			ReadinessGateTimeoutActionKey: ReadinessGateTimeoutActionProceed,
+		},
+	}, {
+		name:  "timeout only",
+		annos: map[string]string{ReadinessGateTimeoutKey: "90s"},
+	}, {
+		name:  "not a valid duration",
+		annos: map[string]string{ReadinessGateTimeoutKey: "five minutes"},
+		want:  "invalid value: five minutes: serving.knative.dev/readinessGateTimeout",
+	}, {
+		name:  "zero",
+		annos: map[string]string{ReadinessGateTimeoutKey: "0s"},
+		want:  "readinessGateTimeout=0s must be positive: serving.knative.dev/readinessGateTimeout",
+	}, {
+		name: "invalid action",
+		annos: map[string]string{
+			ReadinessGateTimeoutKey:       "5m",
+			ReadinessGateTimeoutActionKey: "Retry",
+		},
+		want: "invalid value: Retry: serving.knative.dev/readinessGateTimeoutAction",
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateReadinessGateAnnotations(tc.annos)
+			if got, want := err.Error(), tc.want; got != want {
+				t.Errorf("APIErr mismatch, diff(-want,+got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
 func TestValidateRolloutDurationAnnotation(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -520,3 +563,226 @@ func TestValidateRolloutDurationAnnotation(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateRolloutGuardAnnotations(t *testing.T) {
+	tests := []struct {
+		name  string
+		annos map[string]string
+		want  string
+	}{{
+		name: "empty",
+	}, {
+		name: "valid",
+		annos: map[string]string{
+			RolloutMaxErrorRateKey: "0.05",
+			RolloutMaxLatencyKey:   "500ms",
+		},
+	}, {
+		name:  "error rate not a number",
+		annos: map[string]string{RolloutMaxErrorRateKey: "5%"},
+		want:  "invalid value: 5%: serving.knative.dev/rolloutMaxErrorRate",
+	}, {
+		name:  "error rate zero",
+		annos: map[string]string{RolloutMaxErrorRateKey: "0"},
+		want:  "expected 0 (exclusive) <= 0 <= 1: serving.knative.dev/rolloutMaxErrorRate",
+	}, {
+		name:  "error rate above one",
+		annos: map[string]string{RolloutMaxErrorRateKey: "1.5"},
+		want:  "expected 0 (exclusive) <= 1.5 <= 1: serving.knative.dev/rolloutMaxErrorRate",
+	}, {
+		name:  "latency not a duration",
+		annos: map[string]string{RolloutMaxLatencyKey: "fast"},
+		want:  "invalid value: fast: serving.knative.dev/rolloutMaxLatency",
+	}, {
+		name:  "latency negative",
+		annos: map[string]string{RolloutMaxLatencyKey: "-1s"},
+		want:  "rolloutMaxLatency=-1s must be positive: serving.knative.dev/rolloutMaxLatency",
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateRolloutGuardAnnotations(tc.annos)
+			if got, want := err.Error(), tc.want; got != want {
+				t.Errorf("APIErr mismatch, diff(-want,+got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestValidateCertificateIssuerAnnotation(t *testing.T) {
+	tests := []struct {
+		name  string
+		annos map[string]string
+		want  string
+	}{{
+		name: "empty",
+	}, {
+		name:  "valid",
+		annos: map[string]string{CertificateIssuerKey: "letsencrypt-prod"},
+	}, {
+		name:  "not a resource name",
+		annos: map[string]string{CertificateIssuerKey: "Internal CA"},
+		want:  "invalid value: Internal CA: serving.knative.dev/certificateIssuer",
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateCertificateIssuerAnnotation(tc.annos)
+			if got := err.Error(); !strings.HasPrefix(got, tc.want) {
+				t.Errorf("ValidateCertificateIssuerAnnotation() = %q, want prefix: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateCORSAnnotations(t *testing.T) {
+	tests := []struct {
+		name  string
+		annos map[string]string
+		want  string
+	}{{
+		name: "empty",
+	}, {
+		name: "valid",
+		annos: map[string]string{
+			CORSAllowOriginsKey: "https://example.com, http://localhost:8080",
+			CORSAllowMethodsKey: "GET, POST, DELETE",
+			CORSAllowHeadersKey: "Content-Type, Authorization",
+			CORSMaxAgeKey:       "10m",
+		},
+	}, {
+		name: "all origins and headers",
+		annos: map[string]string{
+			CORSAllowOriginsKey: "*",
+			CORSAllowHeadersKey: "*",
+		},
+	}, {
+		name:  "no origins",
+		annos: map[string]string{CORSMaxAgeKey: "10m"},
+		want:  "serving.knative.dev/corsMaxAge requires serving.knative.dev/corsAllowOrigins to be set",
+	}, {
+		name:  "origin with a path",
+		annos: map[string]string{CORSAllowOriginsKey: "https://example.com/app"},
+		want:  "invalid value: https://example.com/app: serving.knative.dev/corsAllowOrigins",
+	}, {
+		name:  "origin without a scheme",
+		annos: map[string]string{CORSAllowOriginsKey: "example.com"},
+		want:  "invalid value: example.com: serving.knative.dev/corsAllowOrigins",
+	}, {
+		name: "invalid header",
+		annos: map[string]string{
+			CORSAllowOriginsKey: "*",
+			CORSAllowHeadersKey: "X Custom",
+		},
+		want: "invalid value: X Custom: serving.knative.dev/corsAllowHeaders",
+	}, {
+		name: "sub-second max age",
+		annos: map[string]string{
+			CORSAllowOriginsKey: "*",
+			CORSMaxAgeKey:       "1500ms",
+		},
+		want: "corsMaxAge=1500ms must be non-negative and have at most a second precision",
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateCORSAnnotations(tc.annos)
+			if got := err.Error(); !strings.HasPrefix(got, tc.want) {
+				t.Errorf("ValidateCORSAnnotations() = %q, want prefix: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateMirrorAnnotations(t *testing.T) {
+	tests := []struct {
+		name  string
+		annos map[string]string
+		want  string
+	}{{
+		name: "empty",
+	}, {
+		name:  "all requests",
+		annos: map[string]string{MirrorRevisionKey: "shadow-00001"},
+	}, {
+		name: "share of the requests",
+		annos: map[string]string{
+			MirrorRevisionKey: "shadow-00001",
+			MirrorPercentKey:  "12.5",
+		},
+	}, {
+		name:  "no revision",
+		annos: map[string]string{MirrorPercentKey: "10"},
+		want:  "serving.knative.dev/mirrorPercent requires serving.knative.dev/mirrorRevision to be set",
+	}, {
+		name:  "invalid revision",
+		annos: map[string]string{MirrorRevisionKey: "Shadow"},
+		want:  "invalid value: Shadow: serving.knative.dev/mirrorRevision",
+	}, {
+		name: "invalid percent",
+		annos: map[string]string{
+			MirrorRevisionKey: "shadow-00001",
+			MirrorPercentKey:  "ten",
+		},
+		want: "invalid value: ten: serving.knative.dev/mirrorPercent",
+	}, {
+		name: "zero percent",
+		annos: map[string]string{
+			MirrorRevisionKey: "shadow-00001",
+			MirrorPercentKey:  "0",
+		},
+		want: "expected 0 (exclusive) <= 0 <= 100: serving.knative.dev/mirrorPercent",
+	}, {
+		name: "too large percent",
+		annos: map[string]string{
+			MirrorRevisionKey: "shadow-00001",
+			MirrorPercentKey:  "101",
+		},
+		want: "expected 0 (exclusive) <= 101 <= 100: serving.knative.dev/mirrorPercent",
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateMirrorAnnotations(tc.annos)
+			if got := err.Error(); !strings.HasPrefix(got, tc.want) {
+				t.Errorf("ValidateMirrorAnnotations() = %q, want prefix: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateTagResponseHeadersAnnotation(t *testing.T) {
+	tests := []struct {
+		name  string
+		annos map[string]string
+		want  string
+	}{{
+		name: "empty",
+	}, {
+		name: "valid",
+		annos: map[string]string{
+			TagResponseHeadersKey: `{"staging": {"set": {"X-Robots-Tag": "noindex"}, "remove": ["Server"]}}`,
+		},
+	}, {
+		name:  "not json",
+		annos: map[string]string{TagResponseHeadersKey: "staging"},
+		want:  "invalid value: staging: serving.knative.dev/tagResponseHeaders",
+	}, {
+		name:  "unknown field",
+		annos: map[string]string{TagResponseHeadersKey: `{"staging": {"sett": {"X-Robots-Tag": "noindex"}}}`},
+		want:  `invalid value: {"sett": {"X-Robots-Tag": "noindex"}}: [serving.knative.dev/tagResponseHeaders][staging]`,
+	}, {
+		name:  "invalid header",
+		annos: map[string]string{TagResponseHeadersKey: `{"staging": {"add": {"X Robots": "noindex"}}}`},
+		want:  "invalid key name \"X Robots\": [serving.knative.dev/tagResponseHeaders][staging]",
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateTagResponseHeadersAnnotation(tc.annos)
+			if got := err.Error(); !strings.HasPrefix(got, tc.want) {
+				t.Errorf("ValidateTagResponseHeadersAnnotation() = %q, want prefix: %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -57,6 +57,98 @@ const (
 	// The value can be specified with at most with a second precision.
 	RolloutDurationKey = GroupName + "/rolloutDuration"
 
+	// RolloutMaxErrorRateKey is an annotation attached to a Route to guard the
+	// gradual rollout of the latest revision: once the share of its requests
+	// failing with a 5xx response exceeds the value, a number in (0, 1], the
+	// traffic is rolled back to the previous revision.
+	RolloutMaxErrorRateKey = GroupName + "/rolloutMaxErrorRate"
+
+	// RolloutMaxLatencyKey is an annotation attached to a Route to guard the
+	// gradual rollout of the latest revision: once the average latency of its
+	// requests exceeds the value, a positive Golang time.Duration serialized
+	// to string, the traffic is rolled back to the previous revision.
+	RolloutMaxLatencyKey = GroupName + "/rolloutMaxLatency"
+
+	// CertificateIssuerKey is an annotation attached to a Route or a
+	// DomainMapping, or to a Service to propagate to its Route, naming the
+	// ClusterIssuer the auto-TLS Certificates of its hosts are issued by.
+	// It's propagated to the Certificates, as is the certificate class
+	// annotation, for the certificate class implementation to honor. If
+	// missing, the issuer configured for the certificate class is used.
+	CertificateIssuerKey = GroupName + "/certificateIssuer"
+
+	// CORSAllowOriginsKey is an annotation attached to a Route or a
+	// DomainMapping, or to a Service to propagate to its Route, listing the
+	// comma separated origins, e.g. https://example.com, allowed to make
+	// cross-origin requests to it, or "*" for all of them. The CORS
+	// annotations are propagated to the Ingress, for the ingress
+	// implementations that support CORS to enforce them, and otherwise the
+	// queue-proxy of the revisions enforces them.
+	CORSAllowOriginsKey = GroupName + "/corsAllowOrigins"
+
+	// CORSAllowMethodsKey is an annotation attached along with the
+	// CORSAllowOriginsKey, listing the comma separated methods the allowed
+	// origins may use. If missing, the CORS-safelisted methods are allowed.
+	CORSAllowMethodsKey = GroupName + "/corsAllowMethods"
+
+	// CORSAllowHeadersKey is an annotation attached along with the
+	// CORSAllowOriginsKey, listing the comma separated request headers the
+	// allowed origins may send, or "*" for all of them.
+	CORSAllowHeadersKey = GroupName + "/corsAllowHeaders"
+
+	// CORSMaxAgeKey is an annotation attached along with the
+	// CORSAllowOriginsKey, setting how long the browsers may cache the
+	// responses to the preflight requests. The value must be a valid
+	// non-negative Golang time.Duration value serialized to string, with at
+	// most a second precision.
+	CORSMaxAgeKey = GroupName + "/corsMaxAge"
+
+	// TagResponseHeadersKey is an annotation attached to a Route, or to a
+	// Service to propagate to its Route, overriding the response headers the
+	// Revisions declare with QueueSideCarResponseHeadersAnnotation for the
+	// requests through a traffic tag, as a JSON object of such rules by tag,
+	// e.g. {"staging": {"set": {"X-Robots-Tag": "noindex"}}}. The rules of the
+	// tag are applied after the ones of the Revision.
+	TagResponseHeadersKey = GroupName + "/tagResponseHeaders"
+
+	// MirrorRevisionKey is an annotation attached to a Route, or to a Service
+	// to propagate to its Route, naming a "shadow" Revision of its namespace
+	// that a share of the requests to the Revisions of the Route, the ones to
+	// the shadow Revision aside, are mirrored to. The responses of the shadow
+	// Revision are discarded. The mirrored requests carry the
+	// Knative-Serving-Mirrored header, by which the request metrics of the
+	// shadow Revision tell them apart, while they still count towards its
+	// autoscaling, for it to keep up with them. The mirroring is done by the
+	// queue-proxy of the Revisions of the Route.
+	MirrorRevisionKey = GroupName + "/mirrorRevision"
+
+	// MirrorPercentKey is an annotation attached along with the
+	// MirrorRevisionKey, the percentage of the requests mirrored, a number in
+	// (0, 100]. If missing, all of them are mirrored.
+	MirrorPercentKey = GroupName + "/mirrorPercent"
+
+	// ReadinessGateTimeoutKey is an annotation attached to a Route, or to a
+	// Revision to override the Route's, to bound how long traffic waits for a
+	// referenced Revision that is not yet ready. The value must be a valid
+	// positive Golang time.Duration value serialized to string. If missing,
+	// traffic waits until the Revision becomes ready or fails.
+	ReadinessGateTimeoutKey = GroupName + "/readinessGateTimeout"
+
+	// ReadinessGateTimeoutActionKey is an annotation attached to a Route or a
+	// Revision to determine what happens once the ReadinessGateTimeoutKey
+	// elapses: ReadinessGateTimeoutActionFail, the default, fails the Route
+	// and ReadinessGateTimeoutActionProceed routes traffic to the Revision
+	// even though it's not ready.
+	ReadinessGateTimeoutActionKey = GroupName + "/readinessGateTimeoutAction"
+
+	// ReadinessGateTimeoutActionFail is the ReadinessGateTimeoutActionKey
+	// value that fails the Route once the readiness gate timed out.
+	ReadinessGateTimeoutActionFail = "Fail"
+
+	// ReadinessGateTimeoutActionProceed is the ReadinessGateTimeoutActionKey
+	// value that routes traffic to the Revision once the readiness gate timed out.
+	ReadinessGateTimeoutActionProceed = "Proceed"
+
 	// RoutingStateLabelKey is the label attached to a Revision indicating
 	// its state in relation to serving a Route.
 	RoutingStateLabelKey = GroupName + "/routingState"
@@ -69,6 +161,11 @@ const (
 	// by a Route to indicate which namespace the Route was created in.
 	RouteNamespaceLabelKey = GroupName + "/routeNamespace"
 
+	// ExternalRouteLabelKey is the label key attached to the ExternalName
+	// Services created for the external traffic targets of a Route, indicating
+	// which Route they were created for.
+	ExternalRouteLabelKey = GroupName + "/externalRoute"
+
 	// RevisionLabelKey is the label key attached to k8s resources to indicate
 	// which Revision triggered their creation.
 	RevisionLabelKey = GroupName + "/revision"
@@ -115,6 +212,249 @@ const (
 	// It has to be in [0.1,100]
 	QueueSideCarResourcePercentageAnnotation = "queue.sidecar." + GroupName + "/resourcePercentage"
 
+	// QueueSideCarMaxRequestRateAnnotation is the maximum number of requests per second
+	// the queue-proxy of each pod admits to the user container. Requests beyond the rate
+	// are rejected with a 429. It has to be greater than 0.
+	QueueSideCarMaxRequestRateAnnotation = "queue.sidecar." + GroupName + "/maxRequestRate"
+	// QueueSideCarRequestRateBurstAnnotation is the number of requests the queue-proxy
+	// admits in a burst beyond QueueSideCarMaxRequestRateAnnotation. It has to be at least 1.
+	QueueSideCarRequestRateBurstAnnotation = "queue.sidecar." + GroupName + "/requestRateBurst"
+
+	// QueueSideCarMaxClientRequestRateAnnotation is the maximum number of requests per
+	// second the queue-proxy of each pod admits of each client. Requests beyond the rate
+	// are rejected with a 429. It has to be greater than 0.
+	QueueSideCarMaxClientRequestRateAnnotation = "queue.sidecar." + GroupName + "/maxClientRequestRate"
+	// QueueSideCarClientRequestRateBurstAnnotation is the number of requests of each
+	// client the queue-proxy admits in a burst beyond
+	// QueueSideCarMaxClientRequestRateAnnotation. It has to be at least 1.
+	QueueSideCarClientRequestRateBurstAnnotation = "queue.sidecar." + GroupName + "/clientRequestRateBurst"
+	// QueueSideCarClientRateLimitKeyAnnotation is how the clients of
+	// QueueSideCarMaxClientRequestRateAnnotation are identified, either "ip", the
+	// default, for the first hop of their X-Forwarded-For chain or their address, or
+	// "header:" followed by the name of a header carrying their identity.
+	QueueSideCarClientRateLimitKeyAnnotation = "queue.sidecar." + GroupName + "/clientRateLimitKey"
+
+	// QueueSideCarMaxRequestBodyBytesAnnotation is the maximum size in bytes of the
+	// request bodies the queue-proxy passes on to the user container. Requests with
+	// larger bodies are rejected with a 413. It has to be at least 1.
+	QueueSideCarMaxRequestBodyBytesAnnotation = "queue.sidecar." + GroupName + "/maxRequestBodyBytes"
+
+	// QueueSideCarMaxUpstreamConnectionsAnnotation is the maximum number of connections
+	// the queue-proxy of each pod has open to the user container at once. Requests
+	// beyond wait for a connection to be free. It has to be at least 1.
+	QueueSideCarMaxUpstreamConnectionsAnnotation = "queue.sidecar." + GroupName + "/maxUpstreamConnections"
+	// QueueSideCarMaxResponseBytesPerSecondAnnotation is the bandwidth in bytes per second
+	// the queue-proxy of each pod reads the responses of the user container at, all of
+	// them together. It has to be at least 1.
+	QueueSideCarMaxResponseBytesPerSecondAnnotation = "queue.sidecar." + GroupName + "/maxResponseBytesPerSecond"
+
+	// QueueSideCarStreamModeAnnotation defines how the queue-proxy accounts for
+	// WebSocket connections and server-sent event streams. With "track" the
+	// established streams are reported separately, with "release" they're
+	// additionally released from the container concurrency once established, so
+	// they don't take capacity from the other requests nor count towards scaling.
+	QueueSideCarStreamModeAnnotation = "queue.sidecar." + GroupName + "/streamMode"
+	// QueueSideCarStreamDrainSettleTimeAnnotation is the time, e.g. "10s", the
+	// WebSocket connections and server-sent event streams get to finish once the
+	// queue-proxy drains, before they're closed. WebSocket clients are sent a close
+	// frame as the drain starts. It has to be greater than 0. Streams are cut as the
+	// pod terminates if unset.
+	QueueSideCarStreamDrainSettleTimeAnnotation = "queue.sidecar." + GroupName + "/streamDrainSettleTime"
+	// QueueSideCarStreamDrainGoAwayAnnotation makes the queue-proxy send the HTTP/2
+	// connections a GOAWAY frame once it drains, if "true".
+	QueueSideCarStreamDrainGoAwayAnnotation = "queue.sidecar." + GroupName + "/streamDrainGoAway"
+	// QueueSideCarDrainSignalAnnotation is how the queue-proxy tells the user container
+	// it drains, as a JSON object, e.g. {"path": "/draining", "header": true,
+	// "timeoutSeconds": 120}. The path, if any, is POSTed to as the drain starts, and
+	// with header the requests forwarded while draining carry the Knative-Draining
+	// header. The user container is only terminated once the streaming responses in
+	// flight completed, or after timeoutSeconds, the drain timeout of the queue-proxy
+	// by default and bound by max-revision-timeout-seconds.
+	QueueSideCarDrainSignalAnnotation = "queue.sidecar." + GroupName + "/drainSignal"
+
+	// QueueSideCarReadinessProbeGRPCServiceAnnotation makes the queue-proxy probe
+	// the readiness of the user container by the gRPC Health Checking Protocol,
+	// checking the service named by its value, or the server as a whole if empty.
+	// It applies to TCP socket readiness probes, the default, as the probe spec has
+	// no gRPC action yet.
+	QueueSideCarReadinessProbeGRPCServiceAnnotation = "queue.sidecar." + GroupName + "/readinessProbeGRPCService"
+	// QueueSideCarReadinessModeAnnotation is how the queue-proxy tells whether the
+	// user container is ready. With "probe", the default, every readiness check
+	// probes it. With "passive" it's only probed until the probe first succeeds,
+	// after which the outcomes of the requests tell: once failureThreshold requests
+	// in a row failed to reach the container, it's probed again until it passes.
+	// It's meant for containers that can't expose a dedicated health endpoint.
+	QueueSideCarReadinessModeAnnotation = "queue.sidecar." + GroupName + "/readinessMode"
+	// QueueSideCarWarmUpAnnotation is a call the queue-proxy makes to the user container
+	// once it's ready, the pod only being reported ready once the call succeeded, as a
+	// JSON object, e.g. {"path": "/warm-up", "status": 200, "timeoutSeconds": 300,
+	// "payload": "{\"model\": \"large\"}"}. The call is a POST with the payload if
+	// there's one, a GET otherwise. It's expected to respond with status, 200 by default,
+	// within timeoutSeconds, a minute by default and bound by max-revision-timeout-seconds.
+	// It's made once per pod, and again until it succeeds.
+	QueueSideCarWarmUpAnnotation = "queue.sidecar." + GroupName + "/warmUp"
+
+	// QueueSideCarUnixSocketAnnotation makes the queue-proxy talk to the user
+	// container over a Unix domain socket on a shared emptyDir volume, instead of
+	// over TCP on the user port. The user container is told the path of the socket
+	// to listen on by the K_UNIX_SOCKET environment variable. It's "true" or "false".
+	QueueSideCarUnixSocketAnnotation = "queue.sidecar." + GroupName + "/unixSocket"
+
+	// QueueSideCarAsyncAnnotation makes the queue-proxy run the requests with the
+	// "Prefer: respond-async" header in the background, if "true". They're answered
+	// with a 202 and a tracking ID right away, and their response is POSTed to the
+	// URL of their Knative-Async-Callback header, which is the only reliable way to
+	// get it. The requests are queued by the pod that accepted them only, on an
+	// emptyDir volume: they survive the restarts of the queue-proxy, but not the
+	// deletion of the pod, which runs the queued requests for no longer than its
+	// termination grace period. The ones left are lost, without a callback. The
+	// status API at the Location of the 202 only knows the requests accepted by the
+	// pod serving it, so polling it through a Route with more than one pod may be
+	// answered with a 404.
+	QueueSideCarAsyncAnnotation = "queue.sidecar." + GroupName + "/async"
+
+	// QueueSideCarConfigMapAnnotation is the name of a ConfigMap in the namespace of
+	// the Revision mounted into the queue-proxy, whose "loglevel", "timeout",
+	// "metrics-backend", "drain-timeout" and "concurrency-state-endpoint" override the
+	// queue-proxy's settings. Updates of the ConfigMap apply to the running pods.
+	QueueSideCarConfigMapAnnotation = "queue.sidecar." + GroupName + "/configMap"
+
+	// QueueSideCarRequestPriorityHeaderAnnotation is the name of the request header
+	// carrying the priority, from 0 to 9, by which the queue-proxy admits requests
+	// waiting for capacity. Higher priorities are admitted first.
+	QueueSideCarRequestPriorityHeaderAnnotation = "queue.sidecar." + GroupName + "/requestPriorityHeader"
+	// QueueSideCarRequestPriorityRulesAnnotation assigns priorities to requests by the
+	// prefix of their path, as a JSON object, e.g. {"/checkout": 9, "/batch": 0}.
+	// The longest matching prefix wins, the priority header takes precedence.
+	QueueSideCarRequestPriorityRulesAnnotation = "queue.sidecar." + GroupName + "/requestPriorityRules"
+
+	// QueueSideCarRequestWeightHeaderAnnotation is the name of the request header
+	// carrying the weight of a request, i.e. the number of concurrency slots it takes
+	// in the queue-proxy, up to the container concurrency. It has to be at least 1.
+	QueueSideCarRequestWeightHeaderAnnotation = "queue.sidecar." + GroupName + "/requestWeightHeader"
+	// QueueSideCarRequestWeightRulesAnnotation assigns weights to requests by the
+	// prefix of their path, as a JSON object, e.g. {"/batch": 4}.
+	// The longest matching prefix wins, the weight header takes precedence.
+	QueueSideCarRequestWeightRulesAnnotation = "queue.sidecar." + GroupName + "/requestWeightRules"
+
+	// QueueSideCarResponseHeadersAnnotation declares the static headers the queue-proxy
+	// injects into every response of the Revision, as a JSON object of the headers to
+	// "set", replacing the ones of the application, to "add" to them and to "remove",
+	// e.g. {"set": {"Strict-Transport-Security": "max-age=31536000"}, "remove": ["Server"]}.
+	// Removals are applied first, then the headers set and finally the ones added.
+	QueueSideCarResponseHeadersAnnotation = "queue.sidecar." + GroupName + "/responseHeaders"
+
+	// QueueSideCarAdaptiveConcurrencyTargetLatencyAnnotation is the latency, e.g. "200ms",
+	// the queue-proxy keeps requests to the user container near by adjusting the number of
+	// requests it admits concurrently, up to the container concurrency. It has no effect
+	// with unlimited container concurrency and has to be greater than 0.
+	QueueSideCarAdaptiveConcurrencyTargetLatencyAnnotation = "queue.sidecar." + GroupName + "/adaptiveConcurrencyTargetLatency"
+
+	// QueueSideCarTimeoutRulesAnnotation overrides the timeoutSeconds of the requests
+	// matching a path prefix, optionally preceded by a method, as a JSON object of
+	// timeouts in seconds, e.g. {"/batch": 600, "POST /upload": 60}. The longest
+	// matching prefix wins, and a rule with a method over one without for the same
+	// prefix. Timeouts are bound by max-revision-timeout-seconds like timeoutSeconds.
+	QueueSideCarTimeoutRulesAnnotation = "queue.sidecar." + GroupName + "/timeoutRules"
+
+	// QueueSideCarRetryAttemptsAnnotation is the maximum number of attempts, from 1 to 10,
+	// the queue-proxy makes of a request to the user container, if it fails to connect to
+	// it, gets one of QueueSideCarRetryStatusCodesAnnotation or times out after
+	// QueueSideCarRetryPerTryTimeoutAnnotation.
+	QueueSideCarRetryAttemptsAnnotation = "queue.sidecar." + GroupName + "/retryAttempts"
+	// QueueSideCarRetryMethodsAnnotation is the comma separated list of the methods of
+	// the requests the queue-proxy retries, e.g. "GET,PUT". The idempotent methods by default.
+	QueueSideCarRetryMethodsAnnotation = "queue.sidecar." + GroupName + "/retryMethods"
+	// QueueSideCarRetryStatusCodesAnnotation is the comma separated list of the response
+	// codes, from 400 to 599, the queue-proxy retries, e.g. "502,503".
+	QueueSideCarRetryStatusCodesAnnotation = "queue.sidecar." + GroupName + "/retryStatusCodes"
+	// QueueSideCarRetryPerTryTimeoutAnnotation is the time, e.g. "2s", each attempt of a
+	// request has to get the response headers. It has to be greater than 0.
+	QueueSideCarRetryPerTryTimeoutAnnotation = "queue.sidecar." + GroupName + "/retryPerTryTimeout"
+	// QueueSideCarRetryBudgetAnnotation is the maximum fraction of a pod's requests the
+	// queue-proxy may retry. It has to be in (0,1], retries are unbounded if unset.
+	QueueSideCarRetryBudgetAnnotation = "queue.sidecar." + GroupName + "/retryBudget"
+
+	// ActivatorRetryBudgetAnnotation is the maximum fraction of a Revision's requests
+	// through the activator that may be retried, e.g. when their destination went away
+	// while they were waiting for capacity. Retries beyond the budget fail with a 503.
+	// It has to be in [0,1].
+	ActivatorRetryBudgetAnnotation = GroupName + "/activatorRetryBudget"
+
+	// ActivatorLoadBalancingPolicyAnnotation is the policy the activator balances a
+	// Revision's requests across its pods by: one of "random-choice-2",
+	// "least-inflight", "round-robin", "first-available" or "consistent-hash". The
+	// default depends on the container concurrency of the Revision.
+	ActivatorLoadBalancingPolicyAnnotation = GroupName + "/activatorLoadBalancingPolicy"
+
+	// ActivatorLoadBalancingHashHeaderAnnotation is the request header whose value
+	// the "consistent-hash" load balancing policy maps requests to pods by. It's
+	// required by that policy, and only valid with it.
+	ActivatorLoadBalancingHashHeaderAnnotation = GroupName + "/activatorLoadBalancingHashHeader"
+
+	// ActivatorRequestBufferMaxBytesAnnotation is the maximum size in bytes of the
+	// body of a Revision's request the activator buffers while the request waits
+	// for a pod. Beyond the memory buffer of the activator, the body spills to its
+	// disk buffer, if it has one. It has to be at least 1.
+	ActivatorRequestBufferMaxBytesAnnotation = GroupName + "/activatorRequestBufferMaxBytes"
+
+	// ActivatorRequestBufferMaxAgeAnnotation is the longest a Revision's request
+	// whose body the activator buffers may wait for a pod, e.g. "30s". Requests
+	// waiting longer are rejected with a 503, freeing their buffer.
+	ActivatorRequestBufferMaxAgeAnnotation = GroupName + "/activatorRequestBufferMaxAge"
+
+	// ActivatorMaxQueueLengthAnnotation is the maximum number of a Revision's
+	// requests waiting for a pod in each activator. Requests beyond it are shed.
+	// It has to be at least 1.
+	ActivatorMaxQueueLengthAnnotation = GroupName + "/activatorMaxQueueLength"
+	// ActivatorMaxQueueWaitAnnotation is the longest a Revision's request may
+	// wait for a pod in the activator, e.g. "10s", before it's shed.
+	ActivatorMaxQueueWaitAnnotation = GroupName + "/activatorMaxQueueWait"
+	// ActivatorShedResponseBodyAnnotation is the body of the 503 the activator
+	// responds with to a Revision's requests it sheds, instead of its generic
+	// error, e.g. {"error": "overloaded"}.
+	ActivatorShedResponseBodyAnnotation = GroupName + "/activatorShedResponseBody"
+	// ActivatorShedResponseHeadersAnnotation are the headers of the 503 the
+	// activator responds with to a Revision's requests it sheds, as a JSON object,
+	// e.g. {"Content-Type": "application/json", "Retry-After": "5"}.
+	ActivatorShedResponseHeadersAnnotation = GroupName + "/activatorShedResponseHeaders"
+
+	// ActivatorColdStartTimeoutAnnotation is the longest a Revision's request may
+	// wait in the activator for the Revision to scale from zero, e.g. "2m".
+	// Requests timing out doing so, with or without it, are responded to with a
+	// 504 and the K-Cold-Start-Timeout header.
+	ActivatorColdStartTimeoutAnnotation = GroupName + "/activatorColdStartTimeout"
+
+	// ActivatorPrewarmPathAnnotation is the path each activator sends warm-up
+	// requests for to every new pod of a Revision, before the pod gets any
+	// requests through it, e.g. "/warmup".
+	ActivatorPrewarmPathAnnotation = GroupName + "/activatorPrewarmPath"
+	// ActivatorPrewarmHeadersAnnotation are the headers of the warm-up requests,
+	// as a JSON object, e.g. {"X-Warmup": "true"}. It's only valid with
+	// ActivatorPrewarmPathAnnotation.
+	ActivatorPrewarmHeadersAnnotation = GroupName + "/activatorPrewarmHeaders"
+	// ActivatorPrewarmCountAnnotation is the number of warm-up requests sent to
+	// each new pod, one after the other, 1 by default. It has to be in [1,100],
+	// and is only valid with ActivatorPrewarmPathAnnotation.
+	ActivatorPrewarmCountAnnotation = GroupName + "/activatorPrewarmCount"
+
+	// ContainerStartupOrderAnnotation is the comma separated list of the
+	// containers of a Revision in the order they are started in. Each of them
+	// but the last must be probed by the queue-proxy, which holds the start of
+	// the next one until it's ready. The containers not listed start after them.
+	ContainerStartupOrderAnnotation = GroupName + "/containerStartupOrder"
+	// ContainerShutdownOrderAnnotation is the comma separated list of the
+	// containers of a Revision in the order they are stopped in, once the pod
+	// is drained. Each of them but the last must be probed by the queue-proxy,
+	// which holds the stop of the next one until it no longer responds.
+	ContainerShutdownOrderAnnotation = GroupName + "/containerShutdownOrder"
+
+	// TracingSampleRateAnnotation is the fraction, between 0 and 1, of the
+	// requests to a Revision whose traces are sampled by the activator and the
+	// queue-proxy, in place of the sample-rate of config-tracing. The traces
+	// sampled upstream stay sampled.
+	TracingSampleRateAnnotation = GroupName + "/tracingSampleRate"
+
 	// VisibilityClusterLocal is the label value for VisibilityLabelKey
 	// that will result to the Route/KService getting a cluster local
 	// domain suffix.
@@ -20,12 +20,13 @@ import (
 	"context"
 
 	"knative.dev/pkg/apis"
+	"knative.dev/serving/pkg/apis/config"
 	"knative.dev/serving/pkg/apis/serving"
 )
 
 // SetDefaults implements apis.Defaultable
 func (c *Configuration) SetDefaults(ctx context.Context) {
-	ctx = apis.WithinParent(ctx, c.ObjectMeta)
+	ctx = apis.WithinParent(config.ForNamespace(ctx, c.Namespace), c.ObjectMeta)
 	c.Spec.SetDefaults(apis.WithinSpec(ctx))
 	if c.GetOwnerReferences() == nil {
 		if apis.IsInUpdate(ctx) {
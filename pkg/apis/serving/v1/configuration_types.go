@@ -91,6 +91,12 @@ type ConfigurationStatusFields struct {
 	// Configuration. It might not be ready yet, for that use LatestReadyRevisionName.
 	// +optional
 	LatestCreatedRevisionName string `json:"latestCreatedRevisionName,omitempty"`
+
+	// PreservedRevisionNames holds the names of the Revisions stamped out from
+	// this Configuration that are exempt from garbage collection, as pinned
+	// by the serving.knative.dev/no-gc annotation.
+	// +optional
+	PreservedRevisionNames []string `json:"preservedRevisionNames,omitempty"`
 }
 
 // ConfigurationStatus communicates the observed state of the Configuration (from the controller).
@@ -21,11 +21,13 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/apis"
+	"knative.dev/serving/pkg/apis/config"
 	"knative.dev/serving/pkg/apis/serving"
 )
 
 // Validate makes sure that Configuration is properly configured.
 func (c *Configuration) Validate(ctx context.Context) (errs *apis.FieldError) {
+	ctx = config.ForNamespace(ctx, c.Namespace)
 	// If we are in a status sub resource update, the metadata and spec cannot change.
 	// So, to avoid rejecting controller status updates due to validations that may
 	// have changed (i.e. due to config-defaults changes), we elide the metadata and
@@ -22,11 +22,13 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"knative.dev/pkg/apis"
 	"knative.dev/pkg/kmeta"
 	"knative.dev/pkg/ptr"
 	"knative.dev/serving/pkg/apis/config"
+	"knative.dev/serving/pkg/apis/serving"
 )
 
 // SetDefaults implements apis.Defaultable
@@ -36,14 +38,54 @@ func (r *Revision) SetDefaults(ctx context.Context) {
 	if apis.IsInUpdate(ctx) {
 		return
 	}
+	ctx = config.ForNamespace(ctx, r.Namespace)
+	setQueueSidecarDefaults(ctx, &r.ObjectMeta)
+	setScalingDefaults(&r.ObjectMeta, &r.Spec)
 	r.Spec.SetDefaults(apis.WithinSpec(ctx))
 }
 
 // SetDefaults implements apis.Defaultable
 func (rts *RevisionTemplateSpec) SetDefaults(ctx context.Context) {
+	setQueueSidecarDefaults(ctx, &rts.ObjectMeta)
+	setScalingDefaults(&rts.ObjectMeta, &rts.Spec)
 	rts.Spec.SetDefaults(apis.WithinSpec(ctx))
 }
 
+// setQueueSidecarDefaults defaults the queue-proxy resources of the revision
+// with the given metadata.
+func setQueueSidecarDefaults(ctx context.Context, meta *metav1.ObjectMeta) {
+	p := config.FromContextOrDefaults(ctx).Defaults.QueueSidecarResourcePercentage
+	if p == 0 {
+		return
+	}
+	if _, ok := meta.Annotations[serving.QueueSideCarResourcePercentageAnnotation]; ok {
+		return
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = make(map[string]string, 1)
+	}
+	meta.Annotations[serving.QueueSideCarResourcePercentageAnnotation] = strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// setScalingDefaults sets the autoscaling annotations of the revision with
+// the given metadata from the scaling block of its spec, for the autoscaler
+// to pick them up. The annotations already set are kept, validation
+// rejecting those conflicting with the scaling block.
+func setScalingDefaults(meta *metav1.ObjectMeta, rs *RevisionSpec) {
+	if rs.Scaling == nil {
+		return
+	}
+	for k, v := range rs.Scaling.Annotations() {
+		if _, ok := meta.Annotations[k]; ok {
+			continue
+		}
+		if meta.Annotations == nil {
+			meta.Annotations = make(map[string]string, 5)
+		}
+		meta.Annotations[k] = v
+	}
+}
+
 // SetDefaults implements apis.Defaultable
 func (rs *RevisionSpec) SetDefaults(ctx context.Context) {
 	cfg := config.FromContextOrDefaults(ctx)
@@ -19,6 +19,7 @@ package v1
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -30,7 +31,9 @@ import (
 	"knative.dev/pkg/apis"
 	logtesting "knative.dev/pkg/logging/testing"
 	"knative.dev/pkg/ptr"
+	"knative.dev/serving/pkg/apis/autoscaling"
 	"knative.dev/serving/pkg/apis/config"
+	"knative.dev/serving/pkg/apis/serving"
 	autoscalerconfig "knative.dev/serving/pkg/autoscaler/config"
 )
 
@@ -631,6 +634,91 @@ func TestRevisionDefaulting(t *testing.T) {
 			ctx = apis.WithinUpdate(ctx, "fake")
 			return ctx
 		},
+	}, {
+		name: "namespace defaults",
+		in: &Revision{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "tenant",
+			},
+			Spec: RevisionSpec{
+				PodSpec: corev1.PodSpec{
+					Containers: []corev1.Container{{}},
+				},
+			},
+		},
+		want: &Revision{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "tenant",
+				Annotations: map[string]string{
+					serving.QueueSideCarResourcePercentageAnnotation: "12.5",
+				},
+			},
+			Spec: RevisionSpec{
+				PodSpec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name: config.DefaultUserContainerName,
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU: resource.MustParse("250m"),
+							},
+							Limits: corev1.ResourceList{},
+						},
+						ReadinessProbe: defaultProbe,
+					}},
+				},
+				TimeoutSeconds:       ptr.Int64(60),
+				ContainerConcurrency: ptr.Int64(config.DefaultContainerConcurrency),
+			},
+		},
+		wc: func(ctx context.Context) context.Context {
+			return config.WithNamespaceDefaults(ctx, func(namespace string) map[string]string {
+				if namespace != "tenant" {
+					return nil
+				}
+				return map[string]string{
+					"revision-timeout-seconds":          "60",
+					"revision-cpu-request":              "250m",
+					"queue-sidecar-resource-percentage": "12.5",
+				}
+			})
+		},
+	}, {
+		name: "scaling block",
+		in: &Revision{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					autoscaling.MaxScaleAnnotationKey: "10",
+				},
+			},
+			Spec: RevisionSpec{
+				Scaling: &ScalingSpec{
+					MinScale:       ptr.Int32(1),
+					MaxScale:       ptr.Int32(10),
+					Target:         ptr.Float64(2.5),
+					ScaleDownDelay: &metav1.Duration{Duration: time.Minute},
+				},
+			},
+		},
+		want: &Revision{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					autoscaling.MinScaleAnnotationKey:       "1",
+					autoscaling.MaxScaleAnnotationKey:       "10",
+					autoscaling.TargetAnnotationKey:         "2.5",
+					autoscaling.ScaleDownDelayAnnotationKey: "1m0s",
+				},
+			},
+			Spec: RevisionSpec{
+				TimeoutSeconds:       ptr.Int64(config.DefaultRevisionTimeoutSeconds),
+				ContainerConcurrency: ptr.Int64(config.DefaultContainerConcurrency),
+				Scaling: &ScalingSpec{
+					MinScale:       ptr.Int32(1),
+					MaxScale:       ptr.Int32(10),
+					Target:         ptr.Float64(2.5),
+					ScaleDownDelay: &metav1.Duration{Duration: time.Minute},
+				},
+			},
+		},
 	}}
 
 	for _, test := range tests {
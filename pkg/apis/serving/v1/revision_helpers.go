@@ -17,11 +17,15 @@ limitations under the License.
 package v1
 
 import (
+	"strconv"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	net "knative.dev/networking/pkg/apis/networking"
 	"knative.dev/pkg/kmeta"
+	"knative.dev/serving/pkg/apis/autoscaling"
 	"knative.dev/serving/pkg/apis/serving"
 )
 
@@ -86,6 +90,93 @@ func (rs *RevisionSpec) GetContainer() *corev1.Container {
 	return &corev1.Container{}
 }
 
+// StartupWindow returns the time the startupProbe of the serving container
+// allows it to take to start up, or 0 if it has none. The Kubernetes defaults
+// apply to the unset fields of the probe.
+func (rs *RevisionSpec) StartupWindow() time.Duration {
+	p := rs.GetContainer().StartupProbe
+	if p == nil {
+		return 0
+	}
+	period, failures := p.PeriodSeconds, p.FailureThreshold
+	if period == 0 {
+		period = 10
+	}
+	if failures == 0 {
+		failures = 3
+	}
+	return time.Duration(p.InitialDelaySeconds+period*failures) * time.Second
+}
+
+// ContainerOrder returns the names of the containers listed by the container
+// order annotation key, serving.ContainerStartupOrderAnnotation or
+// serving.ContainerShutdownOrderAnnotation, of the annotations.
+func ContainerOrder(annotations map[string]string, key string) []string {
+	v := annotations[key]
+	if v == "" {
+		return nil
+	}
+	names := strings.Split(v, ",")
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+	return names
+}
+
+// Annotations returns the autoscaling annotations equivalent to the fields
+// set in the scaling block.
+func (s *ScalingSpec) Annotations() map[string]string {
+	anns := make(map[string]string, 5)
+	if s.MinScale != nil {
+		anns[autoscaling.MinScaleAnnotationKey] = strconv.Itoa(int(*s.MinScale))
+	}
+	if s.MaxScale != nil {
+		anns[autoscaling.MaxScaleAnnotationKey] = strconv.Itoa(int(*s.MaxScale))
+	}
+	if s.Target != nil {
+		anns[autoscaling.TargetAnnotationKey] = strconv.FormatFloat(*s.Target, 'f', -1, 64)
+	}
+	if s.Metric != "" {
+		anns[autoscaling.MetricAnnotationKey] = s.Metric
+	}
+	if s.ScaleDownDelay != nil {
+		anns[autoscaling.ScaleDownDelayAnnotationKey] = s.ScaleDownDelay.Duration.String()
+	}
+	return anns
+}
+
+// ScalingFromAnnotations returns the scaling block equivalent to the
+// autoscaling annotations, or nil if there are none. The annotations whose
+// value doesn't parse are left out.
+func ScalingFromAnnotations(annotations map[string]string) *ScalingSpec {
+	s := &ScalingSpec{}
+	set := false
+	if v, err := strconv.ParseInt(annotations[autoscaling.MinScaleAnnotationKey], 10, 32); err == nil {
+		s.MinScale, set = int32Ptr(v), true
+	}
+	if v, err := strconv.ParseInt(annotations[autoscaling.MaxScaleAnnotationKey], 10, 32); err == nil {
+		s.MaxScale, set = int32Ptr(v), true
+	}
+	if v, err := strconv.ParseFloat(annotations[autoscaling.TargetAnnotationKey], 64); err == nil {
+		s.Target, set = &v, true
+	}
+	if v := annotations[autoscaling.MetricAnnotationKey]; v != "" {
+		s.Metric, set = v, true
+	}
+	if v, err := time.ParseDuration(annotations[autoscaling.ScaleDownDelayAnnotationKey]); err == nil {
+		s.ScaleDownDelay, set = &metav1.Duration{Duration: v}, true
+	}
+	if !set {
+		return nil
+	}
+	return s
+}
+
+func int32Ptr(v int64) *int32 {
+	i := int32(v)
+	return &i
+}
+
 // SetRoutingState sets the routingState label on this Revision and updates the
 // routingStateModified annotation.
 func (r *Revision) SetRoutingState(state RoutingState, tm time.Time) {
@@ -128,6 +219,12 @@ func (r *Revision) GetRoutingStateModified() time.Time {
 	return parsed
 }
 
+// IsPreserved returns whether the revision is exempt from garbage collection,
+// as pinned by the RevisionPreservedAnnotationKey annotation.
+func (r *Revision) IsPreserved() bool {
+	return strings.EqualFold(r.Annotations[serving.RevisionPreservedAnnotationKey], "true")
+}
+
 // IsReachable returns whether or not the revision can be reached by a route.
 func (r *Revision) IsReachable() bool {
 	return RoutingState(r.Labels[serving.RoutingStateLabelKey]) == RoutingStateActive
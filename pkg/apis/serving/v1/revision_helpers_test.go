@@ -26,6 +26,8 @@ import (
 
 	net "knative.dev/networking/pkg/apis/networking"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/ptr"
+	"knative.dev/serving/pkg/apis/autoscaling"
 	"knative.dev/serving/pkg/apis/serving"
 )
 
@@ -258,6 +260,40 @@ func TestGetContainer(t *testing.T) {
 	}
 }
 
+func TestStartupWindow(t *testing.T) {
+	tests := []struct {
+		name  string
+		probe *corev1.Probe
+		want  time.Duration
+	}{{
+		name: "no startup probe",
+	}, {
+		name:  "kubernetes defaults",
+		probe: &corev1.Probe{},
+		want:  30 * time.Second,
+	}, {
+		name: "custom",
+		probe: &corev1.Probe{
+			InitialDelaySeconds: 20,
+			PeriodSeconds:       5,
+			FailureThreshold:    60,
+		},
+		want: 320 * time.Second,
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rs := &RevisionSpec{
+				PodSpec: corev1.PodSpec{
+					Containers: []corev1.Container{{StartupProbe: tc.probe}},
+				},
+			}
+			if got := rs.StartupWindow(); got != tc.want {
+				t.Errorf("StartupWindow() = %v, want: %v", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestSetRoutingState(t *testing.T) {
 	rev := &Revision{}
 	empty := time.Time{}
@@ -300,3 +336,34 @@ func TestSetRoutingState(t *testing.T) {
 		t.Error("Expected default value for unparsable annotationm but got:", got)
 	}
 }
+
+func TestScalingAnnotations(t *testing.T) {
+	scaling := &ScalingSpec{
+		MinScale:       ptr.Int32(1),
+		MaxScale:       ptr.Int32(0),
+		Target:         ptr.Float64(0.5),
+		Metric:         autoscaling.RPS,
+		ScaleDownDelay: &metav1.Duration{Duration: 90 * time.Second},
+	}
+	want := map[string]string{
+		autoscaling.MinScaleAnnotationKey:       "1",
+		autoscaling.MaxScaleAnnotationKey:       "0",
+		autoscaling.TargetAnnotationKey:         "0.5",
+		autoscaling.MetricAnnotationKey:         autoscaling.RPS,
+		autoscaling.ScaleDownDelayAnnotationKey: "1m30s",
+	}
+	anns := scaling.Annotations()
+	if !equality.Semantic.DeepEqual(anns, want) {
+		t.Errorf("Annotations() = %v, want: %v", anns, want)
+	}
+	if got := ScalingFromAnnotations(anns); !equality.Semantic.DeepEqual(got, scaling) {
+		t.Errorf("ScalingFromAnnotations() = %+v, want: %+v", got, scaling)
+	}
+
+	if got := ScalingFromAnnotations(map[string]string{
+		autoscaling.MinScaleAnnotationKey: "not a number",
+		autoscaling.WindowAnnotationKey:   "1m",
+	}); got != nil {
+		t.Errorf("ScalingFromAnnotations() = %+v, want: nil", got)
+	}
+}
@@ -106,6 +106,12 @@ func (rs *RevisionStatus) MarkActiveTrue() {
 	revisionCondSet.Manage(rs).MarkTrue(RevisionConditionActive)
 }
 
+// MarkActiveTrueWithReason marks Active status on revision as True with a
+// reason for a caveat of its activity
+func (rs *RevisionStatus) MarkActiveTrueWithReason(reason, message string) {
+	revisionCondSet.Manage(rs).MarkTrueWithReason(RevisionConditionActive, reason, message)
+}
+
 // MarkActiveFalse marks Active status on revision as False
 func (rs *RevisionStatus) MarkActiveFalse(reason, message string) {
 	revisionCondSet.Manage(rs).MarkFalse(RevisionConditionActive, reason, message)
@@ -221,7 +227,13 @@ func (rs *RevisionStatus) PropagateAutoscalerStatus(ps *autoscalingv1alpha1.PodA
 		}
 		rs.MarkActiveFalse(cond.Reason, cond.Message)
 	case corev1.ConditionTrue:
-		rs.MarkActiveTrue()
+		// The PA may be active with a caveat, e.g. short of capacity, which
+		// is reflected as well.
+		if active := ps.GetCondition(autoscalingv1alpha1.PodAutoscalerConditionActive); active != nil && active.Reason != "" {
+			rs.MarkActiveTrueWithReason(active.Reason, active.Message)
+		} else {
+			rs.MarkActiveTrue()
+		}
 
 		// Precondition for PA being active is SKS being active and
 		// that implies that |service.endpoints| > 0.
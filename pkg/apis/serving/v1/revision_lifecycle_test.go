@@ -582,6 +582,34 @@ func TestPropagateAutoscalerStatus(t *testing.T) {
 	apistest.CheckConditionSucceeded(r, RevisionConditionResourcesAvailable, t)
 }
 
+func TestPropagateAutoscalerStatusCapacityUnavailable(t *testing.T) {
+	r := &RevisionStatus{}
+	r.InitializeConditions()
+
+	// PodAutoscaler proceeds short of capacity, making us active with its reason.
+	r.PropagateAutoscalerStatus(&autoscalingv1alpha1.PodAutoscalerStatus{
+		Status: duckv1.Status{
+			Conditions: duckv1.Conditions{{
+				Type:   autoscalingv1alpha1.PodAutoscalerConditionReady,
+				Status: corev1.ConditionTrue,
+			}, {
+				Type:    autoscalingv1alpha1.PodAutoscalerConditionActive,
+				Status:  corev1.ConditionTrue,
+				Reason:  "CapacityUnavailable",
+				Message: "1 of the 3 required pods are ready, 2 pods can't be scheduled.",
+			}, {
+				Type:   autoscalingv1alpha1.PodAutoscalerConditionScaleTargetInitialized,
+				Status: corev1.ConditionTrue,
+			}},
+		},
+	})
+	apistest.CheckConditionSucceeded(r, RevisionConditionActive, t)
+	apistest.CheckConditionSucceeded(r, RevisionConditionReady, t)
+	if got, want := r.GetCondition(RevisionConditionActive).Reason, "CapacityUnavailable"; got != want {
+		t.Errorf("Active reason = %q, want: %q", got, want)
+	}
+}
+
 func TestPAResAvailableNoOverride(t *testing.T) {
 	r := &RevisionStatus{}
 	r.InitializeConditions()
@@ -88,6 +88,43 @@ type RevisionSpec struct {
 	// (send network traffic). If unspecified, a system default will be provided.
 	// +optional
 	TimeoutSeconds *int64 `json:"timeoutSeconds,omitempty"`
+
+	// Scaling configures the autoscaling of the Revision. Its fields take the
+	// place of the autoscaling.knative.dev annotations of the same name.
+	// +optional
+	Scaling *ScalingSpec `json:"scaling,omitempty"`
+
+	// Hibernated scales the Revision to zero and rejects the requests routed to
+	// it, whatever its traffic, until it's unset again. Unlike the other fields,
+	// it can be updated on an existing Revision, but not set in a template.
+	// +optional
+	Hibernated bool `json:"hibernated,omitempty"`
+}
+
+// ScalingSpec holds the autoscaling knobs of a Revision.
+type ScalingSpec struct {
+	// MinScale is the minimum number of replicas of the Revision.
+	// +optional
+	MinScale *int32 `json:"minScale,omitempty"`
+
+	// MaxScale is the maximum number of replicas of the Revision, zero
+	// meaning no limit.
+	// +optional
+	MaxScale *int32 `json:"maxScale,omitempty"`
+
+	// Target is the value of the metric the autoscaler aims for per replica.
+	// +optional
+	Target *float64 `json:"target,omitempty"`
+
+	// Metric is the metric the autoscaler scales the Revision on,
+	// e.g. concurrency or rps.
+	// +optional
+	Metric string `json:"metric,omitempty"`
+
+	// ScaleDownDelay is how long the autoscaler waits at a higher scale
+	// before scaling the Revision down.
+	// +optional
+	ScaleDownDelay *metav1.Duration `json:"scaleDownDelay,omitempty"`
 }
 
 const (
@@ -18,11 +18,20 @@ package v1
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	utilvalidation "k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/apis"
 	"knative.dev/pkg/kmp"
 	"knative.dev/serving/pkg/apis/autoscaling"
@@ -32,13 +41,17 @@ import (
 
 // Validate ensures Revision is properly configured.
 func (r *Revision) Validate(ctx context.Context) *apis.FieldError {
+	ctx = config.ForNamespace(ctx, r.Namespace)
 	errs := serving.ValidateObjectMetadata(ctx, r.GetObjectMeta(), true).Also(
 		r.ValidateLabels().ViaField("labels")).ViaField("metadata")
 	errs = errs.Also(r.Status.Validate(apis.WithinStatus(ctx)).ViaField("status"))
 
 	if apis.IsInUpdate(ctx) {
 		original := apis.GetBaseline(ctx).(*Revision)
-		if diff, err := kmp.ShortDiff(original.Spec, r.Spec); err != nil {
+		// Hibernated is the one field that can be updated.
+		originalSpec, spec := original.Spec, r.Spec
+		originalSpec.Hibernated, spec.Hibernated = false, false
+		if diff, err := kmp.ShortDiff(originalSpec, spec); err != nil {
 			return &apis.FieldError{
 				Message: "Failed to diff Revision",
 				Paths:   []string{"spec"},
@@ -68,6 +81,39 @@ func (rts *RevisionTemplateSpec) Validate(ctx context.Context) *apis.FieldError
 	// it follows the requirements on the name.
 	errs = errs.Also(validateRevisionName(ctx, rts.Name, rts.GenerateName))
 	errs = errs.Also(validateQueueSidecarAnnotation(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateRequestRateAnnotations(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateClientRequestRateAnnotations(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateRetryBudgetAnnotation(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateLoadBalancingAnnotations(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateRequestBufferAnnotations(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateShedAnnotations(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validatePrewarmAnnotations(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateMaxRequestBodyBytesAnnotation(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateUpstreamLimitAnnotations(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateStreamModeAnnotation(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateStreamDrainAnnotations(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateReadinessProbeGRPCAnnotation(rts).ViaField("metadata.annotations"))
+	errs = errs.Also(validateReadinessModeAnnotation(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateWarmUpAnnotation(ctx, rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateDrainSignalAnnotation(ctx, rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateUnixSocketAnnotation(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateAsyncAnnotation(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateQueueConfigMapAnnotation(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateRequestPriorityAnnotations(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateRequestWeightAnnotations(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateResponseHeadersAnnotation(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateAdaptiveConcurrencyAnnotation(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateRetryAnnotations(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateTimeoutRulesAnnotation(ctx, rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateTracingSampleRateAnnotation(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(serving.ValidateReadinessGateAnnotations(rts.Annotations).ViaField("metadata.annotations"))
+	errs = errs.Also(validateContainerOrderAnnotation(rts, serving.ContainerStartupOrderAnnotation).ViaField("metadata.annotations"))
+	errs = errs.Also(validateContainerOrderAnnotation(rts, serving.ContainerShutdownOrderAnnotation).ViaField("metadata.annotations"))
+	errs = errs.Also(validateScalingAnnotations(rts.Annotations, rts.Spec.Scaling).ViaField("spec.scaling"))
+	if rts.Spec.Hibernated {
+		// A template stamps out new Revisions, only existing ones are hibernated.
+		errs = errs.Also(apis.ErrDisallowedFields("spec.hibernated"))
+	}
 	return errs
 }
 
@@ -113,6 +159,68 @@ func (rs *RevisionSpec) Validate(ctx context.Context) *apis.FieldError {
 		errs = errs.Also(serving.ValidateContainerConcurrency(ctx, rs.ContainerConcurrency).ViaField("containerConcurrency"))
 	}
 
+	if rs.Scaling != nil {
+		errs = errs.Also(rs.Scaling.Validate(ctx).ViaField("scaling"))
+	}
+
+	return errs
+}
+
+// Validate implements apis.Validatable
+func (s *ScalingSpec) Validate(context.Context) (errs *apis.FieldError) {
+	if s.MinScale != nil && *s.MinScale < 0 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(*s.MinScale, 0, math.MaxInt32, "minScale"))
+	}
+	if s.MaxScale != nil && *s.MaxScale < 0 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(*s.MaxScale, 0, math.MaxInt32, "maxScale"))
+	}
+	if s.MinScale != nil && s.MaxScale != nil && *s.MaxScale != 0 && *s.MinScale > *s.MaxScale {
+		errs = errs.Also(&apis.FieldError{
+			Message: fmt.Sprintf("maxScale=%d is less than minScale=%d", *s.MaxScale, *s.MinScale),
+			Paths:   []string{"maxScale", "minScale"},
+		})
+	}
+	if s.Target != nil && *s.Target < autoscaling.TargetMin {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("target %g should be at least %g", *s.Target, autoscaling.TargetMin), "target"))
+	}
+	if s.ScaleDownDelay != nil {
+		if d := s.ScaleDownDelay.Duration; d < 0 || d > autoscaling.WindowMax {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(d, 0*time.Second, autoscaling.WindowMax, "scaleDownDelay"))
+		} else if d.Round(time.Second) != d {
+			errs = errs.Also(apis.ErrGeneric("must be specified with at most second precision", "scaleDownDelay"))
+		}
+	}
+	return errs
+}
+
+// validateScalingAnnotations rejects the autoscaling annotations conflicting
+// with the fields of the scaling block.
+func validateScalingAnnotations(annotations map[string]string, s *ScalingSpec) (errs *apis.FieldError) {
+	if s == nil {
+		return nil
+	}
+	a := ScalingFromAnnotations(annotations)
+	if a == nil {
+		return nil
+	}
+	conflict := func(field, key string) {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf("conflicts with the %s annotation %q", key, annotations[key]), field))
+	}
+	if s.MinScale != nil && a.MinScale != nil && *s.MinScale != *a.MinScale {
+		conflict("minScale", autoscaling.MinScaleAnnotationKey)
+	}
+	if s.MaxScale != nil && a.MaxScale != nil && *s.MaxScale != *a.MaxScale {
+		conflict("maxScale", autoscaling.MaxScaleAnnotationKey)
+	}
+	if s.Target != nil && a.Target != nil && *s.Target != *a.Target {
+		conflict("target", autoscaling.TargetAnnotationKey)
+	}
+	if s.Metric != "" && a.Metric != "" && s.Metric != a.Metric {
+		conflict("metric", autoscaling.MetricAnnotationKey)
+	}
+	if s.ScaleDownDelay != nil && a.ScaleDownDelay != nil && s.ScaleDownDelay.Duration != a.ScaleDownDelay.Duration {
+		conflict("scaleDownDelay", autoscaling.ScaleDownDelayAnnotationKey)
+	}
 	return errs
 }
 
@@ -197,3 +305,640 @@ func validateQueueSidecarAnnotation(annotations map[string]string) *apis.FieldEr
 	}
 	return nil
 }
+
+// validateRequestRateAnnotations validates QueueSideCarMaxRequestRateAnnotation and
+// QueueSideCarRequestRateBurstAnnotation
+func validateRequestRateAnnotations(annotations map[string]string) *apis.FieldError {
+	var errs *apis.FieldError
+	if v, ok := annotations[serving.QueueSideCarMaxRequestRateAnnotation]; ok {
+		if value, err := strconv.ParseFloat(v, 64); err != nil || value <= 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.QueueSideCarMaxRequestRateAnnotation))
+		}
+	}
+	if v, ok := annotations[serving.QueueSideCarRequestRateBurstAnnotation]; ok {
+		if _, ok := annotations[serving.QueueSideCarMaxRequestRateAnnotation]; !ok {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("requires %s to be set", serving.QueueSideCarMaxRequestRateAnnotation),
+				Paths:   []string{fmt.Sprintf("[%s]", serving.QueueSideCarRequestRateBurstAnnotation)},
+			})
+		}
+		if value, err := strconv.Atoi(v); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.QueueSideCarRequestRateBurstAnnotation))
+		} else if value < 1 {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(value, 1, math.MaxInt32, apis.CurrentField).
+				ViaKey(serving.QueueSideCarRequestRateBurstAnnotation))
+		}
+	}
+	return errs
+}
+
+// validateClientRequestRateAnnotations validates
+// QueueSideCarMaxClientRequestRateAnnotation,
+// QueueSideCarClientRequestRateBurstAnnotation and
+// QueueSideCarClientRateLimitKeyAnnotation
+func validateClientRequestRateAnnotations(annotations map[string]string) *apis.FieldError {
+	var errs *apis.FieldError
+	rate, hasRate := annotations[serving.QueueSideCarMaxClientRequestRateAnnotation]
+	if hasRate {
+		if value, err := strconv.ParseFloat(rate, 64); err != nil || value <= 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+			errs = errs.Also(apis.ErrInvalidValue(rate, apis.CurrentField).
+				ViaKey(serving.QueueSideCarMaxClientRequestRateAnnotation))
+		}
+	}
+	if v, ok := annotations[serving.QueueSideCarClientRequestRateBurstAnnotation]; ok {
+		if !hasRate {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("requires %s to be set", serving.QueueSideCarMaxClientRequestRateAnnotation),
+				Paths:   []string{fmt.Sprintf("[%s]", serving.QueueSideCarClientRequestRateBurstAnnotation)},
+			})
+		}
+		if value, err := strconv.Atoi(v); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.QueueSideCarClientRequestRateBurstAnnotation))
+		} else if value < 1 {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(value, 1, math.MaxInt32, apis.CurrentField).
+				ViaKey(serving.QueueSideCarClientRequestRateBurstAnnotation))
+		}
+	}
+	if v, ok := annotations[serving.QueueSideCarClientRateLimitKeyAnnotation]; ok {
+		if !hasRate {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("requires %s to be set", serving.QueueSideCarMaxClientRequestRateAnnotation),
+				Paths:   []string{fmt.Sprintf("[%s]", serving.QueueSideCarClientRateLimitKeyAnnotation)},
+			})
+		}
+		header := strings.TrimPrefix(v, "header:")
+		if v != "ip" && (header == v || len(utilvalidation.IsHTTPHeaderName(header)) > 0) {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.QueueSideCarClientRateLimitKeyAnnotation))
+		}
+	}
+	return errs
+}
+
+// validateMaxRequestBodyBytesAnnotation validates
+// QueueSideCarMaxRequestBodyBytesAnnotation
+func validateMaxRequestBodyBytesAnnotation(annotations map[string]string) *apis.FieldError {
+	v, ok := annotations[serving.QueueSideCarMaxRequestBodyBytesAnnotation]
+	if !ok {
+		return nil
+	}
+	value, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return apis.ErrInvalidValue(v, apis.CurrentField).
+			ViaKey(serving.QueueSideCarMaxRequestBodyBytesAnnotation)
+	}
+	if value < 1 {
+		return apis.ErrOutOfBoundsValue(value, 1, math.MaxInt64, apis.CurrentField).
+			ViaKey(serving.QueueSideCarMaxRequestBodyBytesAnnotation)
+	}
+	return nil
+}
+
+// validateTracingSampleRateAnnotation validates TracingSampleRateAnnotation
+func validateTracingSampleRateAnnotation(annotations map[string]string) *apis.FieldError {
+	v, ok := annotations[serving.TracingSampleRateAnnotation]
+	if !ok {
+		return nil
+	}
+	rate, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return apis.ErrInvalidValue(v, apis.CurrentField).ViaKey(serving.TracingSampleRateAnnotation)
+	}
+	if rate < 0 || rate > 1 {
+		return apis.ErrOutOfBoundsValue(rate, 0, 1, apis.CurrentField).ViaKey(serving.TracingSampleRateAnnotation)
+	}
+	return nil
+}
+
+// validateUpstreamLimitAnnotations validates
+// QueueSideCarMaxUpstreamConnectionsAnnotation and
+// QueueSideCarMaxResponseBytesPerSecondAnnotation
+func validateUpstreamLimitAnnotations(annotations map[string]string) (errs *apis.FieldError) {
+	for _, key := range []string{
+		serving.QueueSideCarMaxUpstreamConnectionsAnnotation,
+		serving.QueueSideCarMaxResponseBytesPerSecondAnnotation,
+	} {
+		v, ok := annotations[key]
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).ViaKey(key))
+		} else if value < 1 {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(value, 1, math.MaxInt32, apis.CurrentField).ViaKey(key))
+		}
+	}
+	return errs
+}
+
+// validateStreamModeAnnotation validates QueueSideCarStreamModeAnnotation
+func validateStreamModeAnnotation(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[serving.QueueSideCarStreamModeAnnotation]; ok && v != "track" && v != "release" {
+		return apis.ErrInvalidValue(v, apis.CurrentField).ViaKey(serving.QueueSideCarStreamModeAnnotation)
+	}
+	return nil
+}
+
+// validateStreamDrainAnnotations validates
+// QueueSideCarStreamDrainSettleTimeAnnotation and
+// QueueSideCarStreamDrainGoAwayAnnotation
+func validateStreamDrainAnnotations(annotations map[string]string) *apis.FieldError {
+	var errs *apis.FieldError
+	if v, ok := annotations[serving.QueueSideCarStreamDrainSettleTimeAnnotation]; ok {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.QueueSideCarStreamDrainSettleTimeAnnotation))
+		}
+	}
+	if v, ok := annotations[serving.QueueSideCarStreamDrainGoAwayAnnotation]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.QueueSideCarStreamDrainGoAwayAnnotation))
+		}
+	}
+	return errs
+}
+
+// validateReadinessProbeGRPCAnnotation validates
+// QueueSideCarReadinessProbeGRPCServiceAnnotation, which requires the readiness
+// probe of the serving container to be a TCP socket probe.
+func validateReadinessProbeGRPCAnnotation(rts *RevisionTemplateSpec) *apis.FieldError {
+	if _, ok := rts.Annotations[serving.QueueSideCarReadinessProbeGRPCServiceAnnotation]; !ok {
+		return nil
+	}
+	if p := rts.Spec.GetContainer().ReadinessProbe; p != nil && (p.HTTPGet != nil || p.Exec != nil) {
+		return &apis.FieldError{
+			Message: "gRPC readiness probes require a tcpSocket readiness probe",
+			Paths:   []string{serving.QueueSideCarReadinessProbeGRPCServiceAnnotation},
+		}
+	}
+	return nil
+}
+
+// validateReadinessModeAnnotation validates QueueSideCarReadinessModeAnnotation
+func validateReadinessModeAnnotation(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[serving.QueueSideCarReadinessModeAnnotation]; ok && v != "probe" && v != "passive" {
+		return apis.ErrInvalidValue(v, apis.CurrentField).ViaKey(serving.QueueSideCarReadinessModeAnnotation)
+	}
+	return nil
+}
+
+// validateContainerOrderAnnotation validates ContainerStartupOrderAnnotation
+// or ContainerShutdownOrderAnnotation, given by key
+func validateContainerOrderAnnotation(rts *RevisionTemplateSpec, key string) (errs *apis.FieldError) {
+	names := ContainerOrder(rts.Annotations, key)
+	if len(names) == 0 {
+		return nil
+	}
+	containers := make(map[string]*corev1.Container, len(rts.Spec.Containers))
+	for i := range rts.Spec.Containers {
+		containers[rts.Spec.Containers[i].Name] = &rts.Spec.Containers[i]
+	}
+	servingContainer := rts.Spec.GetContainer()
+	seen := make(sets.String, len(names))
+	for i, name := range names {
+		c, ok := containers[name]
+		switch {
+		case !ok:
+			errs = errs.Also(apis.ErrInvalidValue(name, apis.CurrentField).ViaKey(key))
+			continue
+		case seen.Has(name):
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("container %q is listed more than once", name),
+				Paths:   []string{key},
+			})
+			continue
+		}
+		seen.Insert(name)
+		// The queue-proxy must be able to tell whether the container is
+		// up for the next one to wait on it.
+		if i < len(names)-1 && c != servingContainer && !probedByQueue(c) {
+			errs = errs.Also(&apis.FieldError{
+				Message: fmt.Sprintf("container %q requires an httpGet or tcpSocket readiness probe with a port number to be followed by another", name),
+				Paths:   []string{key},
+			})
+		}
+	}
+	return errs
+}
+
+// probedByQueue returns whether the readiness probe of the sidecar is
+// executed by the queue-proxy.
+func probedByQueue(sidecar *corev1.Container) bool {
+	p := sidecar.ReadinessProbe
+	if p == nil {
+		return false
+	}
+	var port intstr.IntOrString
+	switch {
+	case p.HTTPGet != nil:
+		port = p.HTTPGet.Port
+	case p.TCPSocket != nil:
+		port = p.TCPSocket.Port
+	default:
+		return false
+	}
+	return port.Type == intstr.Int && port.IntVal > 0
+}
+
+// validateUnixSocketAnnotation validates QueueSideCarUnixSocketAnnotation
+func validateUnixSocketAnnotation(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[serving.QueueSideCarUnixSocketAnnotation]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return apis.ErrInvalidValue(v, apis.CurrentField).ViaKey(serving.QueueSideCarUnixSocketAnnotation)
+		}
+	}
+	return nil
+}
+
+// validateAsyncAnnotation validates QueueSideCarAsyncAnnotation
+func validateAsyncAnnotation(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[serving.QueueSideCarAsyncAnnotation]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return apis.ErrInvalidValue(v, apis.CurrentField).ViaKey(serving.QueueSideCarAsyncAnnotation)
+		}
+	}
+	return nil
+}
+
+// validateQueueConfigMapAnnotation validates QueueSideCarConfigMapAnnotation
+func validateQueueConfigMapAnnotation(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[serving.QueueSideCarConfigMapAnnotation]; ok {
+		if msgs := utilvalidation.IsDNS1123Subdomain(v); len(msgs) > 0 {
+			return apis.ErrInvalidValue(v, apis.CurrentField, strings.Join(msgs, ", ")).
+				ViaKey(serving.QueueSideCarConfigMapAnnotation)
+		}
+	}
+	return nil
+}
+
+// validateRequestPriorityAnnotations validates
+// QueueSideCarRequestPriorityHeaderAnnotation and
+// QueueSideCarRequestPriorityRulesAnnotation
+func validateRequestPriorityAnnotations(annotations map[string]string) *apis.FieldError {
+	return validateRequestRulesAnnotations(annotations, serving.QueueSideCarRequestPriorityHeaderAnnotation,
+		serving.QueueSideCarRequestPriorityRulesAnnotation, 0, 9)
+}
+
+// validateRequestWeightAnnotations validates
+// QueueSideCarRequestWeightHeaderAnnotation and
+// QueueSideCarRequestWeightRulesAnnotation
+func validateRequestWeightAnnotations(annotations map[string]string) *apis.FieldError {
+	return validateRequestRulesAnnotations(annotations, serving.QueueSideCarRequestWeightHeaderAnnotation,
+		serving.QueueSideCarRequestWeightRulesAnnotation, 1, math.MaxInt32)
+}
+
+// validateRequestRulesAnnotations validates the annotations of the header
+// carrying a value for requests and of the JSON serialized values by path
+// prefix, which have to be in [min, max].
+func validateRequestRulesAnnotations(annotations map[string]string, headerKey, rulesKey string, min, max int) *apis.FieldError {
+	var errs *apis.FieldError
+	if v, ok := annotations[headerKey]; ok {
+		if len(utilvalidation.IsHTTPHeaderName(v)) > 0 {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).ViaKey(headerKey))
+		}
+	}
+	if v, ok := annotations[rulesKey]; ok {
+		var rules map[string]int
+		if err := json.Unmarshal([]byte(v), &rules); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).ViaKey(rulesKey))
+		}
+		for prefix, value := range rules {
+			if value < min || value > max {
+				errs = errs.Also(apis.ErrOutOfBoundsValue(value, min, max, apis.CurrentField).
+					ViaKey(prefix).ViaKey(rulesKey))
+			}
+		}
+	}
+	return errs
+}
+
+// validateResponseHeadersAnnotation validates QueueSideCarResponseHeadersAnnotation
+func validateResponseHeadersAnnotation(annotations map[string]string) *apis.FieldError {
+	if v, ok := annotations[serving.QueueSideCarResponseHeadersAnnotation]; ok {
+		return serving.ValidateResponseHeaderRules([]byte(v)).ViaKey(serving.QueueSideCarResponseHeadersAnnotation)
+	}
+	return nil
+}
+
+// validateAdaptiveConcurrencyAnnotation validates
+// QueueSideCarAdaptiveConcurrencyTargetLatencyAnnotation
+func validateAdaptiveConcurrencyAnnotation(annotations map[string]string) *apis.FieldError {
+	v, ok := annotations[serving.QueueSideCarAdaptiveConcurrencyTargetLatencyAnnotation]
+	if !ok {
+		return nil
+	}
+	if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+		return apis.ErrInvalidValue(v, apis.CurrentField).
+			ViaKey(serving.QueueSideCarAdaptiveConcurrencyTargetLatencyAnnotation)
+	}
+	return nil
+}
+
+// retryMethods are the methods QueueSideCarRetryMethodsAnnotation may list.
+var retryMethods = sets.NewString(
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions, http.MethodTrace,
+)
+
+// validateRetryAnnotations validates QueueSideCarRetryAttemptsAnnotation,
+// QueueSideCarRetryMethodsAnnotation, QueueSideCarRetryStatusCodesAnnotation,
+// QueueSideCarRetryPerTryTimeoutAnnotation and QueueSideCarRetryBudgetAnnotation
+func validateRetryAnnotations(annotations map[string]string) *apis.FieldError {
+	var errs *apis.FieldError
+	if v, ok := annotations[serving.QueueSideCarRetryAttemptsAnnotation]; ok {
+		if value, err := strconv.Atoi(v); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.QueueSideCarRetryAttemptsAnnotation))
+		} else if value < 1 || value > 10 {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(value, 1, 10, apis.CurrentField).
+				ViaKey(serving.QueueSideCarRetryAttemptsAnnotation))
+		}
+	}
+	if v, ok := annotations[serving.QueueSideCarRetryMethodsAnnotation]; ok {
+		for _, method := range strings.Split(v, ",") {
+			if !retryMethods.Has(method) {
+				errs = errs.Also(apis.ErrInvalidValue(method, apis.CurrentField).
+					ViaKey(serving.QueueSideCarRetryMethodsAnnotation))
+			}
+		}
+	}
+	if v, ok := annotations[serving.QueueSideCarRetryStatusCodesAnnotation]; ok {
+		for _, code := range strings.Split(v, ",") {
+			if value, err := strconv.Atoi(code); err != nil {
+				errs = errs.Also(apis.ErrInvalidValue(code, apis.CurrentField).
+					ViaKey(serving.QueueSideCarRetryStatusCodesAnnotation))
+			} else if value < 400 || value > 599 {
+				errs = errs.Also(apis.ErrOutOfBoundsValue(value, 400, 599, apis.CurrentField).
+					ViaKey(serving.QueueSideCarRetryStatusCodesAnnotation))
+			}
+		}
+	}
+	if v, ok := annotations[serving.QueueSideCarRetryPerTryTimeoutAnnotation]; ok {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.QueueSideCarRetryPerTryTimeoutAnnotation))
+		}
+	}
+	if v, ok := annotations[serving.QueueSideCarRetryBudgetAnnotation]; ok {
+		if value, err := strconv.ParseFloat(v, 64); err != nil || value <= 0 {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.QueueSideCarRetryBudgetAnnotation))
+		} else if value > 1 {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(value, 0.0, 1.0, apis.CurrentField).
+				ViaKey(serving.QueueSideCarRetryBudgetAnnotation))
+		}
+	}
+	return errs
+}
+
+// validateTimeoutRulesAnnotation validates QueueSideCarTimeoutRulesAnnotation
+func validateTimeoutRulesAnnotation(ctx context.Context, annotations map[string]string) *apis.FieldError {
+	v, ok := annotations[serving.QueueSideCarTimeoutRulesAnnotation]
+	if !ok {
+		return nil
+	}
+	var rules map[string]int64
+	if err := json.Unmarshal([]byte(v), &rules); err != nil {
+		return apis.ErrInvalidValue(v, apis.CurrentField).ViaKey(serving.QueueSideCarTimeoutRulesAnnotation)
+	}
+	max := config.FromContextOrDefaults(ctx).Defaults.MaxRevisionTimeoutSeconds
+	var errs *apis.FieldError
+	for rule, seconds := range rules {
+		// The path prefix may be preceded by a method and a space.
+		prefix := rule
+		if i := strings.IndexByte(rule, ' '); i > 0 {
+			prefix = rule[i+1:]
+		}
+		if !strings.HasPrefix(prefix, "/") || strings.Contains(prefix, " ") {
+			errs = errs.Also(apis.ErrInvalidKeyName(rule, apis.CurrentField).
+				ViaKey(serving.QueueSideCarTimeoutRulesAnnotation))
+		} else if seconds < 1 || seconds > max {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(seconds, 1, max, apis.CurrentField).
+				ViaKey(rule).ViaKey(serving.QueueSideCarTimeoutRulesAnnotation))
+		}
+	}
+	return errs
+}
+
+// validateWarmUpAnnotation validates QueueSideCarWarmUpAnnotation
+func validateWarmUpAnnotation(ctx context.Context, annotations map[string]string) *apis.FieldError {
+	v, ok := annotations[serving.QueueSideCarWarmUpAnnotation]
+	if !ok {
+		return nil
+	}
+	var hook struct {
+		Path           string `json:"path"`
+		Status         int    `json:"status"`
+		TimeoutSeconds int64  `json:"timeoutSeconds"`
+	}
+	if err := json.Unmarshal([]byte(v), &hook); err != nil {
+		return apis.ErrInvalidValue(v, apis.CurrentField).ViaKey(serving.QueueSideCarWarmUpAnnotation)
+	}
+	var errs *apis.FieldError
+	if !strings.HasPrefix(hook.Path, "/") {
+		errs = errs.Also(apis.ErrInvalidValue(hook.Path, "path").ViaKey(serving.QueueSideCarWarmUpAnnotation))
+	}
+	if hook.Status != 0 && (hook.Status < 100 || hook.Status > 599) {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(hook.Status, 100, 599, "status").
+			ViaKey(serving.QueueSideCarWarmUpAnnotation))
+	}
+	max := config.FromContextOrDefaults(ctx).Defaults.MaxRevisionTimeoutSeconds
+	if hook.TimeoutSeconds < 0 || hook.TimeoutSeconds > max {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(hook.TimeoutSeconds, 0, max, "timeoutSeconds").
+			ViaKey(serving.QueueSideCarWarmUpAnnotation))
+	}
+	return errs
+}
+
+// validateDrainSignalAnnotation validates QueueSideCarDrainSignalAnnotation
+func validateDrainSignalAnnotation(ctx context.Context, annotations map[string]string) *apis.FieldError {
+	v, ok := annotations[serving.QueueSideCarDrainSignalAnnotation]
+	if !ok {
+		return nil
+	}
+	var signal struct {
+		Path           string `json:"path"`
+		Header         bool   `json:"header"`
+		TimeoutSeconds int64  `json:"timeoutSeconds"`
+	}
+	if err := json.Unmarshal([]byte(v), &signal); err != nil {
+		return apis.ErrInvalidValue(v, apis.CurrentField).ViaKey(serving.QueueSideCarDrainSignalAnnotation)
+	}
+	var errs *apis.FieldError
+	if signal.Path == "" && !signal.Header {
+		errs = errs.Also(apis.ErrMissingOneOf("path", "header").ViaKey(serving.QueueSideCarDrainSignalAnnotation))
+	} else if signal.Path != "" && !strings.HasPrefix(signal.Path, "/") {
+		errs = errs.Also(apis.ErrInvalidValue(signal.Path, "path").ViaKey(serving.QueueSideCarDrainSignalAnnotation))
+	}
+	max := config.FromContextOrDefaults(ctx).Defaults.MaxRevisionTimeoutSeconds
+	if signal.TimeoutSeconds < 0 || signal.TimeoutSeconds > max {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(signal.TimeoutSeconds, 0, max, "timeoutSeconds").
+			ViaKey(serving.QueueSideCarDrainSignalAnnotation))
+	}
+	return errs
+}
+
+// validateRetryBudgetAnnotation validates ActivatorRetryBudgetAnnotation
+func validateRetryBudgetAnnotation(annotations map[string]string) *apis.FieldError {
+	v, ok := annotations[serving.ActivatorRetryBudgetAnnotation]
+	if !ok {
+		return nil
+	}
+	value, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(value) {
+		return apis.ErrInvalidValue(v, apis.CurrentField).
+			ViaKey(serving.ActivatorRetryBudgetAnnotation)
+	}
+	if value < 0 || value > 1 {
+		return apis.ErrOutOfBoundsValue(value, 0.0, 1.0, apis.CurrentField).
+			ViaKey(serving.ActivatorRetryBudgetAnnotation)
+	}
+	return nil
+}
+
+// loadBalancingPolicies are the valid values of
+// ActivatorLoadBalancingPolicyAnnotation.
+var loadBalancingPolicies = sets.NewString(
+	"random-choice-2", "least-inflight", "round-robin", "first-available", "consistent-hash")
+
+// validateLoadBalancingAnnotations validates ActivatorLoadBalancingPolicyAnnotation
+// and ActivatorLoadBalancingHashHeaderAnnotation
+func validateLoadBalancingAnnotations(annotations map[string]string) *apis.FieldError {
+	policy, hasPolicy := annotations[serving.ActivatorLoadBalancingPolicyAnnotation]
+	header, hasHeader := annotations[serving.ActivatorLoadBalancingHashHeaderAnnotation]
+	var errs *apis.FieldError
+	if hasPolicy && !loadBalancingPolicies.Has(policy) {
+		errs = errs.Also(apis.ErrInvalidValue(policy, apis.CurrentField).
+			ViaKey(serving.ActivatorLoadBalancingPolicyAnnotation))
+	}
+	switch {
+	case policy == "consistent-hash" && !hasHeader:
+		errs = errs.Also(apis.ErrMissingField(serving.ActivatorLoadBalancingHashHeaderAnnotation))
+	case policy != "consistent-hash" && hasHeader:
+		errs = errs.Also(&apis.FieldError{
+			Message: "only valid with the consistent-hash load balancing policy",
+			Paths:   []string{serving.ActivatorLoadBalancingHashHeaderAnnotation},
+		})
+	case hasHeader && header == "":
+		errs = errs.Also(apis.ErrInvalidValue(header, apis.CurrentField).
+			ViaKey(serving.ActivatorLoadBalancingHashHeaderAnnotation))
+	}
+	return errs
+}
+
+// validateRequestBufferAnnotations validates ActivatorRequestBufferMaxBytesAnnotation
+// and ActivatorRequestBufferMaxAgeAnnotation
+func validateRequestBufferAnnotations(annotations map[string]string) *apis.FieldError {
+	var errs *apis.FieldError
+	if v, ok := annotations[serving.ActivatorRequestBufferMaxBytesAnnotation]; ok {
+		if value, err := strconv.ParseInt(v, 10, 64); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.ActivatorRequestBufferMaxBytesAnnotation))
+		} else if value < 1 {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(value, 1, math.MaxInt64, apis.CurrentField).
+				ViaKey(serving.ActivatorRequestBufferMaxBytesAnnotation))
+		}
+	}
+	if v, ok := annotations[serving.ActivatorRequestBufferMaxAgeAnnotation]; ok {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.ActivatorRequestBufferMaxAgeAnnotation))
+		}
+	}
+	return errs
+}
+
+// validateShedAnnotations validates ActivatorMaxQueueLengthAnnotation,
+// ActivatorMaxQueueWaitAnnotation, ActivatorColdStartTimeoutAnnotation and
+// ActivatorShedResponseHeadersAnnotation
+func validateShedAnnotations(annotations map[string]string) *apis.FieldError {
+	var errs *apis.FieldError
+	if v, ok := annotations[serving.ActivatorMaxQueueLengthAnnotation]; ok {
+		if value, err := strconv.Atoi(v); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.ActivatorMaxQueueLengthAnnotation))
+		} else if value < 1 {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(value, 1, math.MaxInt32, apis.CurrentField).
+				ViaKey(serving.ActivatorMaxQueueLengthAnnotation))
+		}
+	}
+	if v, ok := annotations[serving.ActivatorMaxQueueWaitAnnotation]; ok {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.ActivatorMaxQueueWaitAnnotation))
+		}
+	}
+	if v, ok := annotations[serving.ActivatorColdStartTimeoutAnnotation]; ok {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.ActivatorColdStartTimeoutAnnotation))
+		}
+	}
+	if v, ok := annotations[serving.ActivatorShedResponseHeadersAnnotation]; ok {
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(v), &headers); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.ActivatorShedResponseHeadersAnnotation))
+		}
+		for name := range headers {
+			if len(utilvalidation.IsHTTPHeaderName(name)) > 0 {
+				errs = errs.Also(apis.ErrInvalidKeyName(name, apis.CurrentField).
+					ViaKey(serving.ActivatorShedResponseHeadersAnnotation))
+			}
+		}
+	}
+	return errs
+}
+
+// validatePrewarmAnnotations validates ActivatorPrewarmPathAnnotation,
+// ActivatorPrewarmHeadersAnnotation and ActivatorPrewarmCountAnnotation
+func validatePrewarmAnnotations(annotations map[string]string) *apis.FieldError {
+	path, hasPath := annotations[serving.ActivatorPrewarmPathAnnotation]
+	var errs *apis.FieldError
+	if hasPath {
+		if u, err := url.ParseRequestURI(path); err != nil || u.Host != "" || !strings.HasPrefix(path, "/") {
+			errs = errs.Also(apis.ErrInvalidValue(path, apis.CurrentField).
+				ViaKey(serving.ActivatorPrewarmPathAnnotation))
+		}
+	}
+	if v, ok := annotations[serving.ActivatorPrewarmHeadersAnnotation]; ok {
+		var headers map[string]string
+		if err := json.Unmarshal([]byte(v), &headers); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.ActivatorPrewarmHeadersAnnotation))
+		}
+		for name := range headers {
+			if len(utilvalidation.IsHTTPHeaderName(name)) > 0 {
+				errs = errs.Also(apis.ErrInvalidKeyName(name, apis.CurrentField).
+					ViaKey(serving.ActivatorPrewarmHeadersAnnotation))
+			}
+		}
+		if !hasPath {
+			errs = errs.Also(&apis.FieldError{
+				Message: "only valid with " + serving.ActivatorPrewarmPathAnnotation,
+				Paths:   []string{serving.ActivatorPrewarmHeadersAnnotation},
+			})
+		}
+	}
+	if v, ok := annotations[serving.ActivatorPrewarmCountAnnotation]; ok {
+		if value, err := strconv.Atoi(v); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(v, apis.CurrentField).
+				ViaKey(serving.ActivatorPrewarmCountAnnotation))
+		} else if value < 1 || value > 100 {
+			errs = errs.Also(apis.ErrOutOfBoundsValue(value, 1, 100, apis.CurrentField).
+				ViaKey(serving.ActivatorPrewarmCountAnnotation))
+		}
+		if !hasPath {
+			errs = errs.Also(&apis.FieldError{
+				Message: "only valid with " + serving.ActivatorPrewarmPathAnnotation,
+				Paths:   []string{serving.ActivatorPrewarmCountAnnotation},
+			})
+		}
+	}
+	return errs
+}
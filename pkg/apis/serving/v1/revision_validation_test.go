@@ -19,8 +19,10 @@ package v1
 import (
 	"context"
 	"fmt"
+	"math"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
@@ -35,6 +37,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func TestRevisionValidation(t *testing.T) {
@@ -709,6 +712,34 @@ func TestImmutableFields(t *testing.T) {
 	+: "foobar"
 `,
 		},
+	}, {
+		name: "good (hibernated)",
+		new: &Revision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: RevisionSpec{
+				PodSpec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Image: "busybox",
+					}},
+				},
+				Hibernated: true,
+			},
+		},
+		old: &Revision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: RevisionSpec{
+				PodSpec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Image: "busybox",
+					}},
+				},
+			},
+		},
+		want: nil,
 	}, {
 		name: "bad (multiple changes)",
 		new: &Revision{
@@ -781,6 +812,19 @@ func TestRevisionTemplateSpecValidation(t *testing.T) {
 			},
 		},
 		want: nil,
+	}, {
+		name: "hibernated",
+		rts: &RevisionTemplateSpec{
+			Spec: RevisionSpec{
+				PodSpec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Image: "helloworld",
+					}},
+				},
+				Hibernated: true,
+			},
+		},
+		want: apis.ErrDisallowedFields("spec.hibernated"),
 	}, {
 		name: "empty spec",
 		rts:  &RevisionTemplateSpec{},
@@ -1054,25 +1098,70 @@ func TestValidateQueueSidecarAnnotation(t *testing.T) {
 	}
 }
 
-func TestValidateTimeoutSecond(t *testing.T) {
+func TestValidateRequestRateAnnotations(t *testing.T) {
 	cases := []struct {
-		name      string
-		timeout   *int64
-		expectErr *apis.FieldError
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
 	}{{
-		name:    "exceed max timeout",
-		timeout: ptr.Int64(6000),
-		expectErr: apis.ErrOutOfBoundsValue(
-			6000, 0, config.DefaultMaxRevisionTimeoutSeconds,
-			"timeoutSeconds"),
+		name: "valid rate",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxRequestRateAnnotation: "0.5",
+		},
 	}, {
-		name:    "valid timeout value",
-		timeout: ptr.Int64(100),
+		name: "valid rate and burst",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxRequestRateAnnotation:   "100",
+			serving.QueueSideCarRequestRateBurstAnnotation: "10",
+		},
+	}, {
+		name: "zero rate",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxRequestRateAnnotation: "0",
+		},
+		expectErr: apis.ErrInvalidValue("0", apis.CurrentField).ViaKey(serving.QueueSideCarMaxRequestRateAnnotation),
+	}, {
+		name: "invalid rate",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxRequestRateAnnotation: "fast",
+		},
+		expectErr: apis.ErrInvalidValue("fast", apis.CurrentField).ViaKey(serving.QueueSideCarMaxRequestRateAnnotation),
+	}, {
+		name: "NaN rate",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxRequestRateAnnotation: "NaN",
+		},
+		expectErr: apis.ErrInvalidValue("NaN", apis.CurrentField).ViaKey(serving.QueueSideCarMaxRequestRateAnnotation),
+	}, {
+		name: "infinite rate",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxRequestRateAnnotation: "+Inf",
+		},
+		expectErr: apis.ErrInvalidValue("+Inf", apis.CurrentField).ViaKey(serving.QueueSideCarMaxRequestRateAnnotation),
+	}, {
+		name: "burst too small",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxRequestRateAnnotation:   "100",
+			serving.QueueSideCarRequestRateBurstAnnotation: "0",
+		},
+		expectErr: &apis.FieldError{
+			Message: "expected 1 <= 0 <= 2147483647",
+			Paths:   []string{fmt.Sprintf("[%s]", serving.QueueSideCarRequestRateBurstAnnotation)},
+		},
+	}, {
+		name: "burst without rate",
+		annotation: map[string]string{
+			serving.QueueSideCarRequestRateBurstAnnotation: "10",
+		},
+		expectErr: &apis.FieldError{
+			Message: fmt.Sprintf("requires %s to be set", serving.QueueSideCarMaxRequestRateAnnotation),
+			Paths:   []string{fmt.Sprintf("[%s]", serving.QueueSideCarRequestRateBurstAnnotation)},
+		},
 	}}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			err := validateTimeoutSeconds(context.Background(), *c.timeout)
+			err := validateRequestRateAnnotations(c.annotation)
 			if got, want := err.Error(), c.expectErr.Error(); got != want {
 				t.Errorf("Got: %q want: %q", got, want)
 			}
@@ -1080,53 +1169,1208 @@ func TestValidateTimeoutSecond(t *testing.T) {
 	}
 }
 
-func TestValidateRevisionName(t *testing.T) {
+func TestValidateClientRequestRateAnnotations(t *testing.T) {
 	cases := []struct {
-		name            string
-		revName         string
-		revGenerateName string
-		objectMeta      metav1.ObjectMeta
-		expectErr       *apis.FieldError
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
 	}{{
-		name:            "invalid revision generateName - dots",
-		revGenerateName: "foo.bar",
-		expectErr: apis.ErrInvalidValue("not a DNS 1035 label prefix: [a DNS-1035 label must consist of lower case alphanumeric characters or '-', start with an alphabetic character, and end with an alphanumeric character (e.g. 'my-name',  or 'abc-123', regex used for validation is '[a-z]([-a-z0-9]*[a-z0-9])?')]",
-			"metadata.generateName"),
+		name:       "no annotations",
+		annotation: map[string]string{},
 	}, {
-		name:    "invalid revision name - dots",
-		revName: "foo.bar",
-		expectErr: apis.ErrInvalidValue("not a DNS 1035 label: [a DNS-1035 label must consist of lower case alphanumeric characters or '-', start with an alphabetic character, and end with an alphanumeric character (e.g. 'my-name',  or 'abc-123', regex used for validation is '[a-z]([-a-z0-9]*[a-z0-9])?')]",
-			"metadata.name"),
+		name: "rate, burst and header key",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxClientRequestRateAnnotation:   "2.5",
+			serving.QueueSideCarClientRequestRateBurstAnnotation: "10",
+			serving.QueueSideCarClientRateLimitKeyAnnotation:     "header:X-Tenant-Id",
+		},
 	}, {
-		name: "invalid name (not prefixed)",
-		objectMeta: metav1.ObjectMeta{
-			Name: "bar",
+		name: "ip key",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxClientRequestRateAnnotation: "1",
+			serving.QueueSideCarClientRateLimitKeyAnnotation:   "ip",
 		},
-		revName: "foo",
-		expectErr: apis.ErrInvalidValue(`"foo" must have prefix "bar-"`,
-			"metadata.name"),
 	}, {
-		name: "invalid name (with generateName)",
-		objectMeta: metav1.ObjectMeta{
-			GenerateName: "foo-bar-",
+		name: "invalid rate",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxClientRequestRateAnnotation: "0",
 		},
-		revName:   "foo-bar-foo",
-		expectErr: apis.ErrDisallowedFields("metadata.name"),
+		expectErr: apis.ErrInvalidValue("0", apis.CurrentField).ViaKey(serving.QueueSideCarMaxClientRequestRateAnnotation),
 	}, {
-		name: "valid name",
-		objectMeta: metav1.ObjectMeta{
-			Name: "valid",
+		name: "NaN rate",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxClientRequestRateAnnotation: "nan",
 		},
-		revName: "valid-name",
+		expectErr: apis.ErrInvalidValue("nan", apis.CurrentField).ViaKey(serving.QueueSideCarMaxClientRequestRateAnnotation),
+	}, {
+		name: "infinite rate",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxClientRequestRateAnnotation: "Infinity",
+		},
+		expectErr: apis.ErrInvalidValue("Infinity", apis.CurrentField).ViaKey(serving.QueueSideCarMaxClientRequestRateAnnotation),
+	}, {
+		name: "burst too small",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxClientRequestRateAnnotation:   "1",
+			serving.QueueSideCarClientRequestRateBurstAnnotation: "0",
+		},
+		expectErr: apis.ErrOutOfBoundsValue(0, 1, math.MaxInt32, apis.CurrentField).
+			ViaKey(serving.QueueSideCarClientRequestRateBurstAnnotation),
+	}, {
+		name: "key without rate",
+		annotation: map[string]string{
+			serving.QueueSideCarClientRateLimitKeyAnnotation: "ip",
+		},
+		expectErr: &apis.FieldError{
+			Message: fmt.Sprintf("requires %s to be set", serving.QueueSideCarMaxClientRequestRateAnnotation),
+			Paths:   []string{fmt.Sprintf("[%s]", serving.QueueSideCarClientRateLimitKeyAnnotation)},
+		},
+	}, {
+		name: "invalid key",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxClientRequestRateAnnotation: "1",
+			serving.QueueSideCarClientRateLimitKeyAnnotation:   "X-Tenant-Id",
+		},
+		expectErr: apis.ErrInvalidValue("X-Tenant-Id", apis.CurrentField).ViaKey(serving.QueueSideCarClientRateLimitKeyAnnotation),
+	}, {
+		name: "invalid header",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxClientRequestRateAnnotation: "1",
+			serving.QueueSideCarClientRateLimitKeyAnnotation:   "header:X Tenant",
+		},
+		expectErr: apis.ErrInvalidValue("header:X Tenant", apis.CurrentField).ViaKey(serving.QueueSideCarClientRateLimitKeyAnnotation),
 	}}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			ctx := apis.WithinParent(context.Background(), c.objectMeta)
-			err := validateRevisionName(ctx, c.revName, c.revGenerateName)
+			if got, want := validateClientRequestRateAnnotations(c.annotation).Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateRequestPriorityAnnotations(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name: "valid header and rules",
+		annotation: map[string]string{
+			serving.QueueSideCarRequestPriorityHeaderAnnotation: "X-Priority",
+			serving.QueueSideCarRequestPriorityRulesAnnotation:  `{"/checkout": 9, "/batch": 0}`,
+		},
+	}, {
+		name: "invalid header",
+		annotation: map[string]string{
+			serving.QueueSideCarRequestPriorityHeaderAnnotation: "X Priority",
+		},
+		expectErr: apis.ErrInvalidValue("X Priority", apis.CurrentField).ViaKey(serving.QueueSideCarRequestPriorityHeaderAnnotation),
+	}, {
+		name: "invalid rules",
+		annotation: map[string]string{
+			serving.QueueSideCarRequestPriorityRulesAnnotation: "/checkout=9",
+		},
+		expectErr: apis.ErrInvalidValue("/checkout=9", apis.CurrentField).ViaKey(serving.QueueSideCarRequestPriorityRulesAnnotation),
+	}, {
+		name: "priority out of bounds",
+		annotation: map[string]string{
+			serving.QueueSideCarRequestPriorityRulesAnnotation: `{"/checkout": 10}`,
+		},
+		expectErr: apis.ErrOutOfBoundsValue(10, 0, 9, apis.CurrentField).
+			ViaKey("/checkout").ViaKey(serving.QueueSideCarRequestPriorityRulesAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateRequestPriorityAnnotations(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateRequestWeightAnnotations(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name: "valid header and rules",
+		annotation: map[string]string{
+			serving.QueueSideCarRequestWeightHeaderAnnotation: "X-Weight",
+			serving.QueueSideCarRequestWeightRulesAnnotation:  `{"/batch": 4}`,
+		},
+	}, {
+		name: "invalid header",
+		annotation: map[string]string{
+			serving.QueueSideCarRequestWeightHeaderAnnotation: "X:Weight",
+		},
+		expectErr: apis.ErrInvalidValue("X:Weight", apis.CurrentField).ViaKey(serving.QueueSideCarRequestWeightHeaderAnnotation),
+	}, {
+		name: "weight too small",
+		annotation: map[string]string{
+			serving.QueueSideCarRequestWeightRulesAnnotation: `{"/batch": 0}`,
+		},
+		expectErr: apis.ErrOutOfBoundsValue(0, 1, math.MaxInt32, apis.CurrentField).
+			ViaKey("/batch").ViaKey(serving.QueueSideCarRequestWeightRulesAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateRequestWeightAnnotations(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateAdaptiveConcurrencyAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name:       "valid target latency",
+		annotation: map[string]string{serving.QueueSideCarAdaptiveConcurrencyTargetLatencyAnnotation: "200ms"},
+	}, {
+		name:       "invalid target latency",
+		annotation: map[string]string{serving.QueueSideCarAdaptiveConcurrencyTargetLatencyAnnotation: "fast"},
+		expectErr: apis.ErrInvalidValue("fast", apis.CurrentField).
+			ViaKey(serving.QueueSideCarAdaptiveConcurrencyTargetLatencyAnnotation),
+	}, {
+		name:       "zero target latency",
+		annotation: map[string]string{serving.QueueSideCarAdaptiveConcurrencyTargetLatencyAnnotation: "0s"},
+		expectErr: apis.ErrInvalidValue("0s", apis.CurrentField).
+			ViaKey(serving.QueueSideCarAdaptiveConcurrencyTargetLatencyAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateAdaptiveConcurrencyAnnotation(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateRetryAnnotations(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name: "valid retry policy",
+		annotation: map[string]string{
+			serving.QueueSideCarRetryAttemptsAnnotation:      "3",
+			serving.QueueSideCarRetryMethodsAnnotation:       "GET,PUT",
+			serving.QueueSideCarRetryStatusCodesAnnotation:   "502,503",
+			serving.QueueSideCarRetryPerTryTimeoutAnnotation: "2s",
+			serving.QueueSideCarRetryBudgetAnnotation:        "0.2",
+		},
+	}, {
+		name:       "too many attempts",
+		annotation: map[string]string{serving.QueueSideCarRetryAttemptsAnnotation: "11"},
+		expectErr: apis.ErrOutOfBoundsValue(11, 1, 10, apis.CurrentField).
+			ViaKey(serving.QueueSideCarRetryAttemptsAnnotation),
+	}, {
+		name:       "invalid method",
+		annotation: map[string]string{serving.QueueSideCarRetryMethodsAnnotation: "GET,FETCH"},
+		expectErr: apis.ErrInvalidValue("FETCH", apis.CurrentField).
+			ViaKey(serving.QueueSideCarRetryMethodsAnnotation),
+	}, {
+		name:       "successful status code",
+		annotation: map[string]string{serving.QueueSideCarRetryStatusCodesAnnotation: "200"},
+		expectErr: apis.ErrOutOfBoundsValue(200, 400, 599, apis.CurrentField).
+			ViaKey(serving.QueueSideCarRetryStatusCodesAnnotation),
+	}, {
+		name:       "invalid per-try timeout",
+		annotation: map[string]string{serving.QueueSideCarRetryPerTryTimeoutAnnotation: "-1s"},
+		expectErr: apis.ErrInvalidValue("-1s", apis.CurrentField).
+			ViaKey(serving.QueueSideCarRetryPerTryTimeoutAnnotation),
+	}, {
+		name:       "budget out of bounds",
+		annotation: map[string]string{serving.QueueSideCarRetryBudgetAnnotation: "1.5"},
+		expectErr: apis.ErrOutOfBoundsValue(1.5, 0.0, 1.0, apis.CurrentField).
+			ViaKey(serving.QueueSideCarRetryBudgetAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateRetryAnnotations(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateMaxRequestBodyBytesAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name:       "valid limit",
+		annotation: map[string]string{serving.QueueSideCarMaxRequestBodyBytesAnnotation: "1048576"},
+	}, {
+		name:       "invalid limit",
+		annotation: map[string]string{serving.QueueSideCarMaxRequestBodyBytesAnnotation: "1Mi"},
+		expectErr: apis.ErrInvalidValue("1Mi", apis.CurrentField).
+			ViaKey(serving.QueueSideCarMaxRequestBodyBytesAnnotation),
+	}, {
+		name:       "zero limit",
+		annotation: map[string]string{serving.QueueSideCarMaxRequestBodyBytesAnnotation: "0"},
+		expectErr: apis.ErrOutOfBoundsValue(0, 1, math.MaxInt64, apis.CurrentField).
+			ViaKey(serving.QueueSideCarMaxRequestBodyBytesAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateMaxRequestBodyBytesAnnotation(c.annotation)
 			if got, want := err.Error(), c.expectErr.Error(); got != want {
 				t.Errorf("Got: %q want: %q", got, want)
 			}
 		})
 	}
 }
+
+func TestValidateTracingSampleRateAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name:       "valid rate",
+		annotation: map[string]string{serving.TracingSampleRateAnnotation: "0.25"},
+	}, {
+		name:       "invalid rate",
+		annotation: map[string]string{serving.TracingSampleRateAnnotation: "25%"},
+		expectErr: apis.ErrInvalidValue("25%", apis.CurrentField).
+			ViaKey(serving.TracingSampleRateAnnotation),
+	}, {
+		name:       "rate out of bounds",
+		annotation: map[string]string{serving.TracingSampleRateAnnotation: "1.5"},
+		expectErr: apis.ErrOutOfBoundsValue(1.5, 0, 1, apis.CurrentField).
+			ViaKey(serving.TracingSampleRateAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateTracingSampleRateAnnotation(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateStreamModeAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name:       "track",
+		annotation: map[string]string{serving.QueueSideCarStreamModeAnnotation: "track"},
+	}, {
+		name:       "release",
+		annotation: map[string]string{serving.QueueSideCarStreamModeAnnotation: "release"},
+	}, {
+		name:       "unknown mode",
+		annotation: map[string]string{serving.QueueSideCarStreamModeAnnotation: "drop"},
+		expectErr:  apis.ErrInvalidValue("drop", apis.CurrentField).ViaKey(serving.QueueSideCarStreamModeAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateStreamModeAnnotation(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateStreamDrainAnnotations(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name: "valid",
+		annotation: map[string]string{
+			serving.QueueSideCarStreamDrainSettleTimeAnnotation: "10s",
+			serving.QueueSideCarStreamDrainGoAwayAnnotation:     "true",
+		},
+	}, {
+		name:       "invalid settle time",
+		annotation: map[string]string{serving.QueueSideCarStreamDrainSettleTimeAnnotation: "10"},
+		expectErr:  apis.ErrInvalidValue("10", apis.CurrentField).ViaKey(serving.QueueSideCarStreamDrainSettleTimeAnnotation),
+	}, {
+		name:       "zero settle time",
+		annotation: map[string]string{serving.QueueSideCarStreamDrainSettleTimeAnnotation: "0s"},
+		expectErr:  apis.ErrInvalidValue("0s", apis.CurrentField).ViaKey(serving.QueueSideCarStreamDrainSettleTimeAnnotation),
+	}, {
+		name:       "invalid go away",
+		annotation: map[string]string{serving.QueueSideCarStreamDrainGoAwayAnnotation: "always"},
+		expectErr:  apis.ErrInvalidValue("always", apis.CurrentField).ViaKey(serving.QueueSideCarStreamDrainGoAwayAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateStreamDrainAnnotations(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateReadinessProbeGRPCAnnotation(t *testing.T) {
+	grpcAnnotation := map[string]string{serving.QueueSideCarReadinessProbeGRPCServiceAnnotation: ""}
+	cases := []struct {
+		name      string
+		rts       *RevisionTemplateSpec
+		expectErr *apis.FieldError
+	}{{
+		name: "default probe",
+		rts: &RevisionTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Annotations: grpcAnnotation},
+			Spec: RevisionSpec{PodSpec: corev1.PodSpec{
+				Containers: []corev1.Container{{Image: "busybox"}},
+			}},
+		},
+	}, {
+		name: "tcp socket probe",
+		rts: &RevisionTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Annotations: grpcAnnotation},
+			Spec: RevisionSpec{PodSpec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Image: "busybox",
+					ReadinessProbe: &corev1.Probe{Handler: corev1.Handler{
+						TCPSocket: &corev1.TCPSocketAction{},
+					}},
+				}},
+			}},
+		},
+	}, {
+		name: "http probe",
+		rts: &RevisionTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Annotations: grpcAnnotation},
+			Spec: RevisionSpec{PodSpec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Image: "busybox",
+					ReadinessProbe: &corev1.Probe{Handler: corev1.Handler{
+						HTTPGet: &corev1.HTTPGetAction{},
+					}},
+				}},
+			}},
+		},
+		expectErr: &apis.FieldError{
+			Message: "gRPC readiness probes require a tcpSocket readiness probe",
+			Paths:   []string{serving.QueueSideCarReadinessProbeGRPCServiceAnnotation},
+		},
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateReadinessProbeGRPCAnnotation(c.rts)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateContainerOrderAnnotation(t *testing.T) {
+	spec := RevisionSpec{PodSpec: corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name:  "user-container",
+			Image: "busybox",
+			Ports: []corev1.ContainerPort{{ContainerPort: 8080}},
+		}, {
+			Name:  "cache",
+			Image: "redis",
+			ReadinessProbe: &corev1.Probe{Handler: corev1.Handler{
+				TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(6379)},
+			}},
+		}, {
+			Name:  "logger",
+			Image: "fluentd",
+		}},
+	}}
+	cases := []struct {
+		name      string
+		order     string
+		expectErr *apis.FieldError
+	}{{
+		name:  "sidecars first",
+		order: "cache,user-container,logger",
+	}, {
+		name:  "unprobed sidecar last",
+		order: "user-container, logger",
+	}, {
+		name:      "unknown container",
+		order:     "cache,proxy",
+		expectErr: apis.ErrInvalidValue("proxy", apis.CurrentField).ViaKey(serving.ContainerStartupOrderAnnotation),
+	}, {
+		name:  "duplicate container",
+		order: "cache,user-container,cache",
+		expectErr: &apis.FieldError{
+			Message: `container "cache" is listed more than once`,
+			Paths:   []string{serving.ContainerStartupOrderAnnotation},
+		},
+	}, {
+		name:  "unprobed sidecar followed by another",
+		order: "logger,user-container",
+		expectErr: &apis.FieldError{
+			Message: `container "logger" requires an httpGet or tcpSocket readiness probe with a port number to be followed by another`,
+			Paths:   []string{serving.ContainerStartupOrderAnnotation},
+		},
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rts := &RevisionTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+					serving.ContainerStartupOrderAnnotation: c.order,
+				}},
+				Spec: spec,
+			}
+			err := validateContainerOrderAnnotation(rts, serving.ContainerStartupOrderAnnotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateUnixSocketAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name:       "enabled",
+		annotation: map[string]string{serving.QueueSideCarUnixSocketAnnotation: "true"},
+	}, {
+		name:       "disabled",
+		annotation: map[string]string{serving.QueueSideCarUnixSocketAnnotation: "false"},
+	}, {
+		name:       "invalid",
+		annotation: map[string]string{serving.QueueSideCarUnixSocketAnnotation: "uds"},
+		expectErr:  apis.ErrInvalidValue("uds", apis.CurrentField).ViaKey(serving.QueueSideCarUnixSocketAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateUnixSocketAnnotation(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateAsyncAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name:       "enabled",
+		annotation: map[string]string{serving.QueueSideCarAsyncAnnotation: "true"},
+	}, {
+		name:       "invalid",
+		annotation: map[string]string{serving.QueueSideCarAsyncAnnotation: "yes please"},
+		expectErr:  apis.ErrInvalidValue("yes please", apis.CurrentField).ViaKey(serving.QueueSideCarAsyncAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateAsyncAnnotation(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateUpstreamLimitAnnotations(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name: "valid",
+		annotation: map[string]string{
+			serving.QueueSideCarMaxUpstreamConnectionsAnnotation:    "100",
+			serving.QueueSideCarMaxResponseBytesPerSecondAnnotation: "10485760",
+		},
+	}, {
+		name:       "invalid connections",
+		annotation: map[string]string{serving.QueueSideCarMaxUpstreamConnectionsAnnotation: "many"},
+		expectErr:  apis.ErrInvalidValue("many", apis.CurrentField).ViaKey(serving.QueueSideCarMaxUpstreamConnectionsAnnotation),
+	}, {
+		name:       "zero bandwidth",
+		annotation: map[string]string{serving.QueueSideCarMaxResponseBytesPerSecondAnnotation: "0"},
+		expectErr: apis.ErrOutOfBoundsValue(0, 1, math.MaxInt32, apis.CurrentField).
+			ViaKey(serving.QueueSideCarMaxResponseBytesPerSecondAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateUpstreamLimitAnnotations(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateReadinessModeAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name:       "probe",
+		annotation: map[string]string{serving.QueueSideCarReadinessModeAnnotation: "probe"},
+	}, {
+		name:       "passive",
+		annotation: map[string]string{serving.QueueSideCarReadinessModeAnnotation: "passive"},
+	}, {
+		name:       "invalid",
+		annotation: map[string]string{serving.QueueSideCarReadinessModeAnnotation: "never"},
+		expectErr:  apis.ErrInvalidValue("never", apis.CurrentField).ViaKey(serving.QueueSideCarReadinessModeAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateReadinessModeAnnotation(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateQueueConfigMapAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name:       "valid",
+		annotation: map[string]string{serving.QueueSideCarConfigMapAnnotation: "queue-config"},
+	}, {
+		name:       "invalid",
+		annotation: map[string]string{serving.QueueSideCarConfigMapAnnotation: "Queue_Config"},
+		expectErr: apis.ErrInvalidValue("Queue_Config", apis.CurrentField,
+			"a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')").
+			ViaKey(serving.QueueSideCarConfigMapAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateQueueConfigMapAnnotation(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateResponseHeadersAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name:       "valid",
+		annotation: map[string]string{serving.QueueSideCarResponseHeadersAnnotation: `{"set": {"Cache-Control": "no-store"}}`},
+	}, {
+		name:       "invalid header",
+		annotation: map[string]string{serving.QueueSideCarResponseHeadersAnnotation: `{"remove": ["Bad Header"]}`},
+		expectErr:  apis.ErrInvalidKeyName("Bad Header", apis.CurrentField).ViaKey(serving.QueueSideCarResponseHeadersAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateResponseHeadersAnnotation(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateTimeoutSecond(t *testing.T) {
+	cases := []struct {
+		name      string
+		timeout   *int64
+		expectErr *apis.FieldError
+	}{{
+		name:    "exceed max timeout",
+		timeout: ptr.Int64(6000),
+		expectErr: apis.ErrOutOfBoundsValue(
+			6000, 0, config.DefaultMaxRevisionTimeoutSeconds,
+			"timeoutSeconds"),
+	}, {
+		name:    "valid timeout value",
+		timeout: ptr.Int64(100),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateTimeoutSeconds(context.Background(), *c.timeout)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRevisionValidationNamespaceMaxTimeout(t *testing.T) {
+	ctx := config.WithNamespaceDefaults(logtesting.TestContextWithLogger(t), func(namespace string) map[string]string {
+		if namespace == "tenant" {
+			return map[string]string{"max-revision-timeout-seconds": "300"}
+		}
+		return nil
+	})
+	rev := func(namespace string) *Revision {
+		return &Revision{
+			ObjectMeta: metav1.ObjectMeta{Name: "rev", Namespace: namespace},
+			Spec: RevisionSpec{
+				PodSpec: corev1.PodSpec{
+					Containers: []corev1.Container{{Image: "busybox"}},
+				},
+				TimeoutSeconds: ptr.Int64(400),
+			},
+		}
+	}
+
+	if err := rev("other").Validate(ctx); err != nil {
+		t.Error("Validate() in the namespace without defaults =", err)
+	}
+	want := apis.ErrOutOfBoundsValue(400, 0, 300, "timeoutSeconds").ViaField("spec")
+	if got := rev("tenant").Validate(ctx); got == nil || got.Error() != want.Error() {
+		t.Errorf("Validate() in the namespace with a lower max timeout = %v, want: %v", got, want)
+	}
+}
+
+func TestValidateRevisionName(t *testing.T) {
+	cases := []struct {
+		name            string
+		revName         string
+		revGenerateName string
+		objectMeta      metav1.ObjectMeta
+		expectErr       *apis.FieldError
+	}{{
+		name:            "invalid revision generateName - dots",
+		revGenerateName: "foo.bar",
+		expectErr: apis.ErrInvalidValue("not a DNS 1035 label prefix: [a DNS-1035 label must consist of lower case alphanumeric characters or '-', start with an alphabetic character, and end with an alphanumeric character (e.g. 'my-name',  or 'abc-123', regex used for validation is '[a-z]([-a-z0-9]*[a-z0-9])?')]",
+			"metadata.generateName"),
+	}, {
+		name:    "invalid revision name - dots",
+		revName: "foo.bar",
+		expectErr: apis.ErrInvalidValue("not a DNS 1035 label: [a DNS-1035 label must consist of lower case alphanumeric characters or '-', start with an alphabetic character, and end with an alphanumeric character (e.g. 'my-name',  or 'abc-123', regex used for validation is '[a-z]([-a-z0-9]*[a-z0-9])?')]",
+			"metadata.name"),
+	}, {
+		name: "invalid name (not prefixed)",
+		objectMeta: metav1.ObjectMeta{
+			Name: "bar",
+		},
+		revName: "foo",
+		expectErr: apis.ErrInvalidValue(`"foo" must have prefix "bar-"`,
+			"metadata.name"),
+	}, {
+		name: "invalid name (with generateName)",
+		objectMeta: metav1.ObjectMeta{
+			GenerateName: "foo-bar-",
+		},
+		revName:   "foo-bar-foo",
+		expectErr: apis.ErrDisallowedFields("metadata.name"),
+	}, {
+		name: "valid name",
+		objectMeta: metav1.ObjectMeta{
+			Name: "valid",
+		},
+		revName: "valid-name",
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := apis.WithinParent(context.Background(), c.objectMeta)
+			err := validateRevisionName(ctx, c.revName, c.revGenerateName)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateRetryBudgetAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name: "not set",
+	}, {
+		name:       "valid budget",
+		annotation: map[string]string{serving.ActivatorRetryBudgetAnnotation: "0.2"},
+	}, {
+		name:       "no retries",
+		annotation: map[string]string{serving.ActivatorRetryBudgetAnnotation: "0"},
+	}, {
+		name:       "invalid budget",
+		annotation: map[string]string{serving.ActivatorRetryBudgetAnnotation: "some"},
+		expectErr:  apis.ErrInvalidValue("some", apis.CurrentField).ViaKey(serving.ActivatorRetryBudgetAnnotation),
+	}, {
+		name:       "NaN budget",
+		annotation: map[string]string{serving.ActivatorRetryBudgetAnnotation: "NaN"},
+		expectErr:  apis.ErrInvalidValue("NaN", apis.CurrentField).ViaKey(serving.ActivatorRetryBudgetAnnotation),
+	}, {
+		name:       "budget too large",
+		annotation: map[string]string{serving.ActivatorRetryBudgetAnnotation: "1.5"},
+		expectErr: apis.ErrOutOfBoundsValue(1.5, 0.0, 1.0, apis.CurrentField).
+			ViaKey(serving.ActivatorRetryBudgetAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateRetryBudgetAnnotation(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateLoadBalancingAnnotations(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name: "not set",
+	}, {
+		name:       "valid policy",
+		annotation: map[string]string{serving.ActivatorLoadBalancingPolicyAnnotation: "least-inflight"},
+	}, {
+		name: "consistent hashing",
+		annotation: map[string]string{
+			serving.ActivatorLoadBalancingPolicyAnnotation:     "consistent-hash",
+			serving.ActivatorLoadBalancingHashHeaderAnnotation: "X-Session-Id",
+		},
+	}, {
+		name:       "invalid policy",
+		annotation: map[string]string{serving.ActivatorLoadBalancingPolicyAnnotation: "random"},
+		expectErr: apis.ErrInvalidValue("random", apis.CurrentField).
+			ViaKey(serving.ActivatorLoadBalancingPolicyAnnotation),
+	}, {
+		name:       "consistent hashing without header",
+		annotation: map[string]string{serving.ActivatorLoadBalancingPolicyAnnotation: "consistent-hash"},
+		expectErr:  apis.ErrMissingField(serving.ActivatorLoadBalancingHashHeaderAnnotation),
+	}, {
+		name: "consistent hashing with empty header",
+		annotation: map[string]string{
+			serving.ActivatorLoadBalancingPolicyAnnotation:     "consistent-hash",
+			serving.ActivatorLoadBalancingHashHeaderAnnotation: "",
+		},
+		expectErr: apis.ErrInvalidValue("", apis.CurrentField).
+			ViaKey(serving.ActivatorLoadBalancingHashHeaderAnnotation),
+	}, {
+		name:       "header without consistent hashing",
+		annotation: map[string]string{serving.ActivatorLoadBalancingHashHeaderAnnotation: "X-Session-Id"},
+		expectErr: &apis.FieldError{
+			Message: "only valid with the consistent-hash load balancing policy",
+			Paths:   []string{serving.ActivatorLoadBalancingHashHeaderAnnotation},
+		},
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateLoadBalancingAnnotations(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateRequestBufferAnnotations(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name: "not set",
+	}, {
+		name: "valid",
+		annotation: map[string]string{
+			serving.ActivatorRequestBufferMaxBytesAnnotation: "104857600",
+			serving.ActivatorRequestBufferMaxAgeAnnotation:   "30s",
+		},
+	}, {
+		name:       "invalid max bytes",
+		annotation: map[string]string{serving.ActivatorRequestBufferMaxBytesAnnotation: "100Mi"},
+		expectErr: apis.ErrInvalidValue("100Mi", apis.CurrentField).
+			ViaKey(serving.ActivatorRequestBufferMaxBytesAnnotation),
+	}, {
+		name:       "zero max bytes",
+		annotation: map[string]string{serving.ActivatorRequestBufferMaxBytesAnnotation: "0"},
+		expectErr: apis.ErrOutOfBoundsValue(0, 1, math.MaxInt64, apis.CurrentField).
+			ViaKey(serving.ActivatorRequestBufferMaxBytesAnnotation),
+	}, {
+		name:       "invalid max age",
+		annotation: map[string]string{serving.ActivatorRequestBufferMaxAgeAnnotation: "30"},
+		expectErr: apis.ErrInvalidValue("30", apis.CurrentField).
+			ViaKey(serving.ActivatorRequestBufferMaxAgeAnnotation),
+	}, {
+		name:       "negative max age",
+		annotation: map[string]string{serving.ActivatorRequestBufferMaxAgeAnnotation: "-1s"},
+		expectErr: apis.ErrInvalidValue("-1s", apis.CurrentField).
+			ViaKey(serving.ActivatorRequestBufferMaxAgeAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateRequestBufferAnnotations(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateShedAnnotations(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name: "not set",
+	}, {
+		name: "valid",
+		annotation: map[string]string{
+			serving.ActivatorMaxQueueLengthAnnotation:      "100",
+			serving.ActivatorMaxQueueWaitAnnotation:        "10s",
+			serving.ActivatorColdStartTimeoutAnnotation:    "2m",
+			serving.ActivatorShedResponseBodyAnnotation:    `{"error": "overloaded"}`,
+			serving.ActivatorShedResponseHeadersAnnotation: `{"Content-Type": "application/json", "Retry-After": "5"}`,
+		},
+	}, {
+		name:       "invalid max queue length",
+		annotation: map[string]string{serving.ActivatorMaxQueueLengthAnnotation: "many"},
+		expectErr: apis.ErrInvalidValue("many", apis.CurrentField).
+			ViaKey(serving.ActivatorMaxQueueLengthAnnotation),
+	}, {
+		name:       "zero max queue length",
+		annotation: map[string]string{serving.ActivatorMaxQueueLengthAnnotation: "0"},
+		expectErr: apis.ErrOutOfBoundsValue(0, 1, math.MaxInt32, apis.CurrentField).
+			ViaKey(serving.ActivatorMaxQueueLengthAnnotation),
+	}, {
+		name:       "invalid max queue wait",
+		annotation: map[string]string{serving.ActivatorMaxQueueWaitAnnotation: "0s"},
+		expectErr: apis.ErrInvalidValue("0s", apis.CurrentField).
+			ViaKey(serving.ActivatorMaxQueueWaitAnnotation),
+	}, {
+		name:       "invalid cold start timeout",
+		annotation: map[string]string{serving.ActivatorColdStartTimeoutAnnotation: "2"},
+		expectErr: apis.ErrInvalidValue("2", apis.CurrentField).
+			ViaKey(serving.ActivatorColdStartTimeoutAnnotation),
+	}, {
+		name:       "invalid headers",
+		annotation: map[string]string{serving.ActivatorShedResponseHeadersAnnotation: "Retry-After: 5"},
+		expectErr: apis.ErrInvalidValue("Retry-After: 5", apis.CurrentField).
+			ViaKey(serving.ActivatorShedResponseHeadersAnnotation),
+	}, {
+		name:       "invalid header name",
+		annotation: map[string]string{serving.ActivatorShedResponseHeadersAnnotation: `{"Retry After": "5"}`},
+		expectErr: apis.ErrInvalidKeyName("Retry After", apis.CurrentField).
+			ViaKey(serving.ActivatorShedResponseHeadersAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateShedAnnotations(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidatePrewarmAnnotations(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name: "not set",
+	}, {
+		name: "valid",
+		annotation: map[string]string{
+			serving.ActivatorPrewarmPathAnnotation:    "/warmup?model=large",
+			serving.ActivatorPrewarmHeadersAnnotation: `{"X-Warmup": "true"}`,
+			serving.ActivatorPrewarmCountAnnotation:   "3",
+		},
+	}, {
+		name:       "relative path",
+		annotation: map[string]string{serving.ActivatorPrewarmPathAnnotation: "warmup"},
+		expectErr: apis.ErrInvalidValue("warmup", apis.CurrentField).
+			ViaKey(serving.ActivatorPrewarmPathAnnotation),
+	}, {
+		name:       "URL",
+		annotation: map[string]string{serving.ActivatorPrewarmPathAnnotation: "http://example.com/warmup"},
+		expectErr: apis.ErrInvalidValue("http://example.com/warmup", apis.CurrentField).
+			ViaKey(serving.ActivatorPrewarmPathAnnotation),
+	}, {
+		name: "invalid header name",
+		annotation: map[string]string{
+			serving.ActivatorPrewarmPathAnnotation:    "/warmup",
+			serving.ActivatorPrewarmHeadersAnnotation: `{"X Warmup": "true"}`,
+		},
+		expectErr: apis.ErrInvalidKeyName("X Warmup", apis.CurrentField).
+			ViaKey(serving.ActivatorPrewarmHeadersAnnotation),
+	}, {
+		name: "count out of bounds",
+		annotation: map[string]string{
+			serving.ActivatorPrewarmPathAnnotation:  "/warmup",
+			serving.ActivatorPrewarmCountAnnotation: "101",
+		},
+		expectErr: apis.ErrOutOfBoundsValue(101, 1, 100, apis.CurrentField).
+			ViaKey(serving.ActivatorPrewarmCountAnnotation),
+	}, {
+		name:       "count without path",
+		annotation: map[string]string{serving.ActivatorPrewarmCountAnnotation: "2"},
+		expectErr: &apis.FieldError{
+			Message: "only valid with " + serving.ActivatorPrewarmPathAnnotation,
+			Paths:   []string{serving.ActivatorPrewarmCountAnnotation},
+		},
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validatePrewarmAnnotations(c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateTimeoutRulesAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name: "valid rules",
+		annotation: map[string]string{
+			serving.QueueSideCarTimeoutRulesAnnotation: `{"/batch": 600, "POST /upload": 60}`,
+		},
+	}, {
+		name: "invalid rules",
+		annotation: map[string]string{
+			serving.QueueSideCarTimeoutRulesAnnotation: "/batch=600",
+		},
+		expectErr: apis.ErrInvalidValue("/batch=600", apis.CurrentField).ViaKey(serving.QueueSideCarTimeoutRulesAnnotation),
+	}, {
+		name: "no path prefix",
+		annotation: map[string]string{
+			serving.QueueSideCarTimeoutRulesAnnotation: `{"POST batch": 600}`,
+		},
+		expectErr: apis.ErrInvalidKeyName("POST batch", apis.CurrentField).ViaKey(serving.QueueSideCarTimeoutRulesAnnotation),
+	}, {
+		name: "timeout out of bounds",
+		annotation: map[string]string{
+			serving.QueueSideCarTimeoutRulesAnnotation: `{"/batch": 601}`,
+		},
+		expectErr: apis.ErrOutOfBoundsValue(601, 1, 600, apis.CurrentField).
+			ViaKey("/batch").ViaKey(serving.QueueSideCarTimeoutRulesAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateTimeoutRulesAnnotation(context.Background(), c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateWarmUpAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name: "valid hook",
+		annotation: map[string]string{
+			serving.QueueSideCarWarmUpAnnotation: `{"path": "/warm-up", "status": 204, "timeoutSeconds": 300, "payload": "{}"}`,
+		},
+	}, {
+		name: "invalid hook",
+		annotation: map[string]string{
+			serving.QueueSideCarWarmUpAnnotation: "/warm-up",
+		},
+		expectErr: apis.ErrInvalidValue("/warm-up", apis.CurrentField).ViaKey(serving.QueueSideCarWarmUpAnnotation),
+	}, {
+		name: "relative path",
+		annotation: map[string]string{
+			serving.QueueSideCarWarmUpAnnotation: `{"path": "warm-up"}`,
+		},
+		expectErr: apis.ErrInvalidValue("warm-up", "path").ViaKey(serving.QueueSideCarWarmUpAnnotation),
+	}, {
+		name: "status and timeout out of bounds",
+		annotation: map[string]string{
+			serving.QueueSideCarWarmUpAnnotation: `{"path": "/warm-up", "status": 42, "timeoutSeconds": 601}`,
+		},
+		expectErr: apis.ErrOutOfBoundsValue(42, 100, 599, "status").
+			Also(apis.ErrOutOfBoundsValue(601, 0, 600, "timeoutSeconds")).
+			ViaKey(serving.QueueSideCarWarmUpAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateWarmUpAnnotation(context.Background(), c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateDrainSignalAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		annotation map[string]string
+		expectErr  *apis.FieldError
+	}{{
+		name: "valid signal",
+		annotation: map[string]string{
+			serving.QueueSideCarDrainSignalAnnotation: `{"path": "/draining", "header": true, "timeoutSeconds": 120}`,
+		},
+	}, {
+		name: "header only",
+		annotation: map[string]string{
+			serving.QueueSideCarDrainSignalAnnotation: `{"header": true}`,
+		},
+	}, {
+		name: "invalid signal",
+		annotation: map[string]string{
+			serving.QueueSideCarDrainSignalAnnotation: "/draining",
+		},
+		expectErr: apis.ErrInvalidValue("/draining", apis.CurrentField).ViaKey(serving.QueueSideCarDrainSignalAnnotation),
+	}, {
+		name: "no signal",
+		annotation: map[string]string{
+			serving.QueueSideCarDrainSignalAnnotation: `{"timeoutSeconds": 120}`,
+		},
+		expectErr: apis.ErrMissingOneOf("path", "header").ViaKey(serving.QueueSideCarDrainSignalAnnotation),
+	}, {
+		name: "relative path and timeout out of bounds",
+		annotation: map[string]string{
+			serving.QueueSideCarDrainSignalAnnotation: `{"path": "draining", "timeoutSeconds": 601}`,
+		},
+		expectErr: apis.ErrInvalidValue("draining", "path").
+			Also(apis.ErrOutOfBoundsValue(601, 0, 600, "timeoutSeconds")).
+			ViaKey(serving.QueueSideCarDrainSignalAnnotation),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateDrainSignalAnnotation(context.Background(), c.annotation)
+			if got, want := err.Error(), c.expectErr.Error(); got != want {
+				t.Errorf("Got: %q want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestScalingValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		scaling     *ScalingSpec
+		annotations map[string]string
+		want        *apis.FieldError
+	}{{
+		name: "valid",
+		scaling: &ScalingSpec{
+			MinScale:       ptr.Int32(1),
+			MaxScale:       ptr.Int32(3),
+			Target:         ptr.Float64(10),
+			Metric:         autoscaling.RPS,
+			ScaleDownDelay: &metav1.Duration{Duration: time.Minute},
+		},
+	}, {
+		name:    "negative min scale",
+		scaling: &ScalingSpec{MinScale: ptr.Int32(-1)},
+		want:    apis.ErrOutOfBoundsValue(-1, 0, math.MaxInt32, "spec.scaling.minScale"),
+	}, {
+		name:    "max scale less than min scale",
+		scaling: &ScalingSpec{MinScale: ptr.Int32(3), MaxScale: ptr.Int32(2)},
+		want: &apis.FieldError{
+			Message: "maxScale=2 is less than minScale=3",
+			Paths:   []string{"spec.scaling.maxScale", "spec.scaling.minScale"},
+		},
+	}, {
+		name:    "target too low",
+		scaling: &ScalingSpec{Target: ptr.Float64(0.001)},
+		want:    apis.ErrGeneric("target 0.001 should be at least 0.01", "spec.scaling.target"),
+	}, {
+		name:    "scale down delay too long",
+		scaling: &ScalingSpec{ScaleDownDelay: &metav1.Duration{Duration: 2 * time.Hour}},
+		want:    apis.ErrOutOfBoundsValue(2*time.Hour, 0*time.Second, autoscaling.WindowMax, "spec.scaling.scaleDownDelay"),
+	}, {
+		name:    "scale down delay too precise",
+		scaling: &ScalingSpec{ScaleDownDelay: &metav1.Duration{Duration: 1500 * time.Millisecond}},
+		want:    apis.ErrGeneric("must be specified with at most second precision", "spec.scaling.scaleDownDelay"),
+	}, {
+		name:    "matching annotations",
+		scaling: &ScalingSpec{MinScale: ptr.Int32(1), Target: ptr.Float64(2)},
+		annotations: map[string]string{
+			autoscaling.MinScaleAnnotationKey: "1",
+			autoscaling.TargetAnnotationKey:   "2.0",
+		},
+	}, {
+		name:    "conflicting annotations",
+		scaling: &ScalingSpec{MinScale: ptr.Int32(1), Metric: autoscaling.Concurrency},
+		annotations: map[string]string{
+			autoscaling.MinScaleAnnotationKey: "2",
+			autoscaling.MetricAnnotationKey:   autoscaling.Concurrency,
+		},
+		want: apis.ErrGeneric(`conflicts with the autoscaling.knative.dev/minScale annotation "2"`, "spec.scaling.minScale"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rts := &RevisionTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: test.annotations,
+				},
+				Spec: RevisionSpec{
+					PodSpec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Image: "helloworld",
+						}},
+					},
+					Scaling: test.scaling,
+				},
+			}
+			got := rts.Validate(context.Background())
+			if got, want := got.Error(), test.want.Error(); got != want {
+				t.Errorf("Validate() = %s, want: %s", got, want)
+			}
+		})
+	}
+}
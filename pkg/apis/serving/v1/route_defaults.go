@@ -53,7 +53,7 @@ func (rs *RouteSpec) SetDefaults(ctx context.Context) {
 // SetDefaults implements apis.Defaultable
 func (tt *TrafficTarget) SetDefaults(ctx context.Context) {
 	if tt.LatestRevision == nil {
-		tt.LatestRevision = ptr.Bool(tt.RevisionName == "")
+		tt.LatestRevision = ptr.Bool(tt.RevisionName == "" && tt.External == nil)
 	}
 	// Despite the fact that we have the field percent
 	// as required, historically we were lenient about checking this.
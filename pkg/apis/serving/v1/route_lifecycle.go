@@ -18,6 +18,7 @@ package v1
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -60,10 +61,13 @@ func (r *Route) IsFailed() bool {
 		rs.GetCondition(RouteConditionReady).IsFalse()
 }
 
-// RolloutDuration returns the rollout duration specified as an
-// annotation.
+// RolloutDuration returns the rollout duration specified in the spec,
+// or else as an annotation.
 // 0 is returned if missing or cannot be parsed.
 func (r *Route) RolloutDuration() time.Duration {
+	if r.Spec.RolloutDuration != nil {
+		return r.Spec.RolloutDuration.Duration
+	}
 	if v, ok := r.Annotations[serving.RolloutDurationKey]; ok && v != "" {
 		// WH should've declined all the invalid values for this annotation.
 		if d, err := time.ParseDuration(v); err == nil {
@@ -73,6 +77,20 @@ func (r *Route) RolloutDuration() time.Duration {
 	return 0
 }
 
+// RolloutGuard returns the maximum error rate and average latency of the
+// revisions rolled out, specified as annotations.
+// 0 is returned for each if missing or cannot be parsed.
+func (r *Route) RolloutGuard() (maxErrorRate float64, maxLatency time.Duration) {
+	// WH should've declined all the invalid values for these annotations.
+	if v := r.Annotations[serving.RolloutMaxErrorRateKey]; v != "" {
+		maxErrorRate, _ = strconv.ParseFloat(v, 64)
+	}
+	if v := r.Annotations[serving.RolloutMaxLatencyKey]; v != "" {
+		maxLatency, _ = time.ParseDuration(v)
+	}
+	return maxErrorRate, maxLatency
+}
+
 // InitializeConditions sets the initial values to the conditions.
 func (rs *RouteStatus) InitializeConditions() {
 	routeCondSet.Manage(rs).InitializeConditions()
@@ -149,6 +167,15 @@ func (rs *RouteStatus) MarkRevisionFailed(name string) {
 		"Revision %q failed to become ready.", name)
 }
 
+// MarkRevisionReadinessGateTimeout marks the RouteConditionAllTrafficAssigned
+// condition to indicate the Revision didn't become ready within the readiness
+// gate timeout.
+func (rs *RouteStatus) MarkRevisionReadinessGateTimeout(name string, timeout time.Duration) {
+	routeCondSet.Manage(rs).MarkFalse(RouteConditionAllTrafficAssigned,
+		"ReadinessGateTimeout",
+		"Revision %q did not become ready within %v.", name, timeout)
+}
+
 // MarkMissingTrafficTarget marks the RouteConditionAllTrafficAssigned
 // condition to indicate a reference traffic target was not found.
 func (rs *RouteStatus) MarkMissingTrafficTarget(kind, name string) {
@@ -157,6 +184,20 @@ func (rs *RouteStatus) MarkMissingTrafficTarget(kind, name string) {
 		"%s %q referenced in traffic not found.", kind, name)
 }
 
+// MarkRolloutHealthy marks the RouteConditionRolloutHealthy condition to
+// indicate that no rollout has been rolled back.
+func (rs *RouteStatus) MarkRolloutHealthy() {
+	routeCondSet.Manage(rs).MarkTrue(RouteConditionRolloutHealthy)
+}
+
+// MarkRolloutRolledBack marks the RouteConditionRolloutHealthy condition to
+// indicate that the rollout of the Revision was rolled back, and why.
+func (rs *RouteStatus) MarkRolloutRolledBack(name, reason string) {
+	routeCondSet.Manage(rs).MarkFalse(RouteConditionRolloutHealthy,
+		"RolledBack",
+		"The rollout of Revision %q was rolled back: %s.", name, reason)
+}
+
 // MarkCertificateProvisionFailed marks the
 // RouteConditionCertificateProvisioned condition to indicate that the
 // Certificate provisioning failed.
@@ -345,6 +345,22 @@ func TestTargetRevisionFailedToBeReadyFlow(t *testing.T) {
 	apistest.CheckConditionFailed(r, RouteConditionReady, t)
 }
 
+func TestReadinessGateTimeout(t *testing.T) {
+	r := &RouteStatus{}
+	r.InitializeConditions()
+	apistest.CheckConditionOngoing(r, RouteConditionAllTrafficAssigned, t)
+	apistest.CheckConditionOngoing(r, RouteConditionIngressReady, t)
+	apistest.CheckConditionOngoing(r, RouteConditionReady, t)
+
+	r.MarkRevisionReadinessGateTimeout("slow-to-start", 5*time.Minute)
+	apistest.CheckConditionFailed(r, RouteConditionAllTrafficAssigned, t)
+	apistest.CheckConditionOngoing(r, RouteConditionIngressReady, t)
+	apistest.CheckConditionFailed(r, RouteConditionReady, t)
+	if got, want := r.GetCondition(RouteConditionAllTrafficAssigned).Reason, "ReadinessGateTimeout"; got != want {
+		t.Errorf("Reason = %q, want: %q", got, want)
+	}
+}
+
 func TestIngressFailureRecovery(t *testing.T) {
 	r := &RouteStatus{}
 	r.InitializeConditions()
@@ -499,6 +515,36 @@ func TestMarkInRollout(t *testing.T) {
 	apistest.CheckConditionOngoing(r, RouteConditionIngressReady, t)
 }
 
+func TestRolloutRolledBack(t *testing.T) {
+	r := &RouteStatus{}
+	r.InitializeConditions()
+	r.MarkRolloutHealthy()
+	apistest.CheckConditionSucceeded(r, RouteConditionRolloutHealthy, t)
+
+	r.MarkRolloutRolledBack("foo-00002", "error rate 50.00% exceeds 5.00%")
+	apistest.CheckConditionFailed(r, RouteConditionRolloutHealthy, t)
+	if got, want := r.GetCondition(RouteConditionRolloutHealthy).Reason, "RolledBack"; got != want {
+		t.Errorf("RolloutHealthy reason = %q, want: %q", got, want)
+	}
+	// The rollback doesn't affect the readiness of the route.
+	apistest.CheckConditionOngoing(r, RouteConditionReady, t)
+}
+
+func TestRolloutGuard(t *testing.T) {
+	r := &Route{}
+	if rate, latency := r.RolloutGuard(); rate != 0 || latency != 0 {
+		t.Errorf("RolloutGuard = %v, %v, want: 0, 0", rate, latency)
+	}
+
+	r.Annotations = map[string]string{
+		serving.RolloutMaxErrorRateKey: "0.05",
+		serving.RolloutMaxLatencyKey:   "250ms",
+	}
+	if rate, latency := r.RolloutGuard(); rate != 0.05 || latency != 250*time.Millisecond {
+		t.Errorf("RolloutGuard = %v, %v, want: 0.05, 250ms", rate, latency)
+	}
+}
+
 func TestRolloutDuration(t *testing.T) {
 	tests := []struct {
 		name string
@@ -531,4 +577,18 @@ func TestRolloutDuration(t *testing.T) {
 			}
 		})
 	}
+
+	r := &Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				serving.RolloutDurationKey: "2m",
+			},
+		},
+		Spec: RouteSpec{
+			RolloutDuration: &metav1.Duration{Duration: 5 * time.Minute},
+		},
+	}
+	if got, want := r.RolloutDuration(), 5*time.Minute; got != want {
+		t.Errorf("RolloutDuration = %v, want: %v (from the spec)", got, want)
+	}
 }
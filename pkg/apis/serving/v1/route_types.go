@@ -103,6 +103,18 @@ type TrafficTarget struct {
 	// +optional
 	Percent *int64 `json:"percent,omitempty"`
 
+	// Match optionally routes all the requests matching its conditions to
+	// this target, ahead of the percentage based routing.
+	// +optional
+	Match *TrafficMatch `json:"match,omitempty"`
+
+	// External is a backend outside of Knative to send this portion of
+	// traffic to, e.g. to migrate the traffic of a legacy Deployment
+	// gradually. This is mutually exclusive with RevisionName and
+	// ConfigurationName.
+	// +optional
+	External *ExternalTarget `json:"external,omitempty"`
+
 	// URL displays the URL for accessing named traffic targets. URL is displayed in
 	// status, and is disallowed on spec. URL must contain a scheme (e.g. http://) and
 	// a hostname, but may not contain anything else (e.g. basic auth, url path, etc.)
@@ -110,12 +122,57 @@ type TrafficTarget struct {
 	URL *apis.URL `json:"url,omitempty"`
 }
 
+// ExternalTarget is a backend outside of Knative that traffic is routed to.
+type ExternalTarget struct {
+	// ServiceName is the name of a Kubernetes Service in the namespace of the
+	// Route, which may be of the ExternalName type. This is mutually exclusive
+	// with URL.
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// Port is the port of the Service, 80 by default.
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// URL is an http URL of a backend outside of the cluster, with only a host
+	// and optionally a port, e.g. http://legacy.example.com:8080. It's routed to
+	// through an ExternalName Service created for the Route, with the Host
+	// header of the requests rewritten to its host, so it can't share the
+	// traffic with other targets. This is mutually exclusive with ServiceName.
+	// +optional
+	URL *apis.URL `json:"url,omitempty"`
+}
+
+// TrafficMatch holds the conditions a request must all meet to be routed to
+// a traffic target.
+type TrafficMatch struct {
+	// Headers are the names of the request headers with their exact values.
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Cookies are the names of the request cookies with their values. They're
+	// rejected until the Ingress supports matching cookies, as it matches the
+	// headers exactly.
+	// +optional
+	Cookies map[string]string `json:"cookies,omitempty"`
+
+	// PathPrefix is the prefix of the request paths, e.g. "/v2/".
+	// +optional
+	PathPrefix string `json:"pathPrefix,omitempty"`
+}
+
 // RouteSpec holds the desired state of the Route (from the client).
 type RouteSpec struct {
 	// Traffic specifies how to distribute traffic over a collection of
 	// revisions and configurations.
 	// +optional
 	Traffic []TrafficTarget `json:"traffic,omitempty"`
+
+	// RolloutDuration is the duration over which the traffic is gradually
+	// shifted to the latest revisions of the configurations. It takes
+	// precedence over the rolloutDuration annotation.
+	// +optional
+	RolloutDuration *metav1.Duration `json:"rolloutDuration,omitempty"`
 }
 
 const (
@@ -135,6 +192,11 @@ const (
 	// RouteConditionCertificateProvisioned is set to False when the
 	// Knative Certificates fail to be provisioned for the Route.
 	RouteConditionCertificateProvisioned apis.ConditionType = "CertificateProvisioned"
+
+	// RouteConditionRolloutHealthy is set to False when the rollout of the
+	// latest revision was rolled back for breaching the thresholds of the
+	// rollout guard annotations. It doesn't affect the readiness of the Route.
+	RouteConditionRolloutHealthy apis.ConditionType = "RolloutHealthy"
 )
 
 // IsRouteCondition returns true if the ConditionType is a route condition type
@@ -144,7 +206,8 @@ func IsRouteCondition(t apis.ConditionType) bool {
 		RouteConditionReady,
 		RouteConditionAllTrafficAssigned,
 		RouteConditionIngressReady,
-		RouteConditionCertificateProvisioned:
+		RouteConditionCertificateProvisioned,
+		RouteConditionRolloutHealthy:
 		return true
 	}
 	return false
@@ -169,6 +232,49 @@ type RouteStatusFields struct {
 	// LatestReadyRevisionName that we last observed.
 	// +optional
 	Traffic []TrafficTarget `json:"traffic,omitempty"`
+
+	// Rollouts holds the progress of the gradual rollouts of the traffic
+	// to the latest revisions of the configurations, while in progress.
+	// +optional
+	Rollouts []RolloutStatus `json:"rollouts,omitempty"`
+}
+
+// RolloutStatus holds the progress of the gradual rollout of the traffic of a
+// configuration to its latest revision.
+type RolloutStatus struct {
+	// ConfigurationName is the name of the configuration rolled out.
+	ConfigurationName string `json:"configurationName"`
+
+	// Tag is the tag of the traffic target rolled out, if any.
+	// +optional
+	Tag string `json:"tag,omitempty"`
+
+	// RevisionName is the name of the revision the traffic is shifted to.
+	RevisionName string `json:"revisionName"`
+
+	// Percent is the percentage of the traffic of the route shifted to the
+	// revision so far.
+	Percent int64 `json:"percent"`
+
+	// TargetPercent is the percentage of the traffic of the route the
+	// revision receives once the rollout completes.
+	TargetPercent int64 `json:"targetPercent"`
+
+	// Step is the number of steps of the rollout taken so far.
+	// +optional
+	Step int64 `json:"step,omitempty"`
+
+	// Steps is the total number of steps of the rollout, once known.
+	// +optional
+	Steps int64 `json:"steps,omitempty"`
+
+	// NextStepTime is when the next step of the rollout is taken.
+	// +optional
+	NextStepTime *metav1.Time `json:"nextStepTime,omitempty"`
+
+	// EstimatedCompletionTime is when the rollout is expected to complete.
+	// +optional
+	EstimatedCompletionTime *metav1.Time `json:"estimatedCompletionTime,omitempty"`
 }
 
 // RouteStatus communicates the observed state of the Route (from the controller).
@@ -19,10 +19,14 @@ package v1
 import (
 	"context"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/util/validation"
 	network "knative.dev/networking/pkg"
 	"knative.dev/pkg/apis"
+	pkgnetwork "knative.dev/pkg/network"
 	"knative.dev/serving/pkg/apis/serving"
 )
 
@@ -32,6 +36,18 @@ func (r *Route) Validate(ctx context.Context) *apis.FieldError {
 		r.validateLabels().ViaField("labels"))
 	errs = errs.Also(serving.ValidateRolloutDurationAnnotation(
 		r.GetAnnotations()).ViaField("annotations"))
+	errs = errs.Also(serving.ValidateReadinessGateAnnotations(
+		r.GetAnnotations()).ViaField("annotations"))
+	errs = errs.Also(serving.ValidateRolloutGuardAnnotations(
+		r.GetAnnotations()).ViaField("annotations"))
+	errs = errs.Also(serving.ValidateCertificateIssuerAnnotation(
+		r.GetAnnotations()).ViaField("annotations"))
+	errs = errs.Also(serving.ValidateCORSAnnotations(
+		r.GetAnnotations()).ViaField("annotations"))
+	errs = errs.Also(serving.ValidateTagResponseHeadersAnnotation(
+		r.GetAnnotations()).ViaField("annotations"))
+	errs = errs.Also(serving.ValidateMirrorAnnotations(
+		r.GetAnnotations()).ViaField("annotations"))
 	errs = errs.ViaField("metadata")
 	errs = errs.Also(r.Spec.Validate(apis.WithinSpec(ctx)).ViaField("spec"))
 
@@ -53,12 +69,15 @@ func validateTrafficList(ctx context.Context, traffic []TrafficTarget) *apis.Fie
 	// Track the targets of named TrafficTarget entries (to detect duplicates).
 	trafficMap := make(map[string]int)
 
-	sum := int64(0)
+	sum, routed := int64(0), 0
 	for i, tt := range traffic {
 		errs = errs.Also(tt.Validate(ctx).ViaIndex(i))
 
 		if tt.Percent != nil {
 			sum += *tt.Percent
+			if *tt.Percent > 0 {
+				routed++
+			}
 		}
 
 		if tt.Tag == "" {
@@ -84,6 +103,15 @@ func validateTrafficList(ctx context.Context, traffic []TrafficTarget) *apis.Fie
 		}
 	}
 
+	// The Host header of the requests is rewritten to the host of an external
+	// URL, which the Ingress only does for a path routed to it alone.
+	for i, tt := range traffic {
+		if routed > 1 && tt.External != nil && tt.External.URL != nil && tt.Percent != nil && *tt.Percent > 0 {
+			errs = errs.Also(apis.ErrGeneric("an external URL target can't share the traffic with other targets",
+				"external.url").ViaIndex(i))
+		}
+	}
+
 	if sum != 100 {
 		errs = errs.Also(&apis.FieldError{
 			Message: fmt.Sprintf("Traffic targets sum to %d, want 100", sum),
@@ -95,7 +123,11 @@ func validateTrafficList(ctx context.Context, traffic []TrafficTarget) *apis.Fie
 
 // Validate implements apis.Validatable
 func (rs *RouteSpec) Validate(ctx context.Context) *apis.FieldError {
-	return validateTrafficList(ctx, rs.Traffic).ViaField("traffic")
+	errs := validateTrafficList(ctx, rs.Traffic).ViaField("traffic")
+	if rs.RolloutDuration != nil {
+		errs = errs.Also(serving.ValidateRolloutDuration(rs.RolloutDuration.Duration).ViaField("rolloutDuration"))
+	}
+	return errs
 }
 
 // Validate verifies that TrafficTarget is properly configured.
@@ -103,13 +135,109 @@ func (tt *TrafficTarget) Validate(ctx context.Context) *apis.FieldError {
 	errs := tt.validateLatestRevision(ctx)
 	errs = tt.validateRevisionAndConfiguration(ctx, errs)
 	errs = tt.validateTrafficPercentage(errs)
+	errs = errs.Also(tt.Match.Validate().ViaField("match"))
+	errs = errs.Also(tt.External.Validate(ctx).ViaField("external"))
 	return tt.validateURL(ctx, errs)
 }
 
+// Validate makes sure an external traffic target references one backend.
+func (et *ExternalTarget) Validate(ctx context.Context) *apis.FieldError {
+	if et == nil {
+		return nil
+	}
+	var errs *apis.FieldError
+	switch {
+	case et.ServiceName != "" && et.URL != nil:
+		errs = errs.Also(apis.ErrMultipleOneOf("serviceName", "url"))
+	case et.ServiceName != "":
+		if msgs := validation.IsDNS1035Label(et.ServiceName); len(msgs) > 0 {
+			errs = errs.Also(apis.ErrInvalidValue(et.ServiceName, "serviceName", msgs...))
+		}
+	case et.URL != nil:
+		u := et.URL.URL()
+		if u.Scheme != "http" || u.Hostname() == "" || u.User != nil || strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+			errs = errs.Also(apis.ErrInvalidValue(u.String(), "url"))
+		} else if port := u.Port(); port != "" {
+			if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+				errs = errs.Also(apis.ErrInvalidValue(u.String(), "url"))
+			}
+		}
+		errs = errs.Also(validateExternalHost(u.Hostname()))
+		if et.Port != 0 {
+			errs = errs.Also(apis.ErrDisallowedFields("port"))
+		}
+	default:
+		errs = errs.Also(apis.ErrMissingOneOf("serviceName", "url"))
+	}
+	if et.Port < 0 || et.Port > 65535 {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(et.Port, 1, 65535, "port"))
+	}
+	return errs
+}
+
+// validateExternalHost makes sure the host of an external URL is outside of
+// the cluster, as it's routed to through an ExternalName Service the ingress
+// would otherwise resolve to any Service, of any namespace. The Services of
+// the namespace are routed to by name.
+func validateExternalHost(host string) *apis.FieldError {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return apis.ErrGeneric(fmt.Sprintf("invalid host %q: must be a domain name", host), "url")
+	}
+	if msgs := validation.IsDNS1123Subdomain(host); len(msgs) > 0 {
+		return apis.ErrInvalidValue(host, "url", msgs...)
+	}
+	// The names relative to the search domains of the pods, like
+	// "<service>.<namespace>.svc", are rejected as well.
+	clusterLocalDomain := pkgnetwork.GetClusterDomainName()
+	if !strings.Contains(host, ".") || strings.HasSuffix(host, ".svc") || strings.HasSuffix(host, ".pod") ||
+		host == clusterLocalDomain || strings.HasSuffix(host, "."+clusterLocalDomain) {
+		return apis.ErrGeneric(fmt.Sprintf("invalid host %q: must be a fully qualified domain name outside of the cluster, "+
+			"use serviceName for the Services of the namespace", host), "url")
+	}
+	return nil
+}
+
+// Validate makes sure the match conditions of a traffic target are valid.
+func (tm *TrafficMatch) Validate() *apis.FieldError {
+	if tm == nil {
+		return nil
+	}
+	var errs *apis.FieldError
+	// The Ingress matches the headers exactly, and the Cookie header of the
+	// browsers has more cookies than those matched, in any order.
+	if len(tm.Cookies) > 0 {
+		errs = apis.ErrDisallowedFields("cookies")
+		errs.Details = "matching cookies isn't supported by the Ingress"
+	}
+	if len(tm.Headers) == 0 && tm.PathPrefix == "" {
+		return errs.Also(apis.ErrMissingOneOf("headers", "pathPrefix"))
+	}
+
+	if tm.PathPrefix != "" && (!strings.HasPrefix(tm.PathPrefix, "/") || strings.ContainsAny(tm.PathPrefix, "?#*")) {
+		errs = errs.Also(apis.ErrInvalidValue(tm.PathPrefix, "pathPrefix"))
+	}
+	for name := range tm.Headers {
+		if el := validation.IsHTTPHeaderName(name); len(el) > 0 {
+			errs = errs.Also(apis.ErrInvalidKeyName(name, "headers", el...))
+		}
+	}
+	return errs
+}
+
 func (tt *TrafficTarget) validateRevisionAndConfiguration(ctx context.Context, errs *apis.FieldError) *apis.FieldError {
 	// We only validate the sense of latestRevision in the context of a Spec,
 	// and only when it is specified.
 	switch {
+	// An external target references neither a revision nor a configuration.
+	case tt.External != nil:
+		if tt.RevisionName != "" {
+			errs = errs.Also(apis.ErrMultipleOneOf("external", "revisionName"))
+		}
+		if tt.ConfigurationName != "" {
+			errs = errs.Also(apis.ErrMultipleOneOf("external", "configurationName"))
+		}
+
 	// When we have a default configurationName, we don't
 	// allow one to be specified.
 	case HasDefaultConfigurationName(ctx) && tt.ConfigurationName != "":
@@ -162,6 +290,12 @@ func (tt *TrafficTarget) validateTrafficPercentage(errs *apis.FieldError) *apis.
 func (tt *TrafficTarget) validateLatestRevision(ctx context.Context) *apis.FieldError {
 	if apis.IsInSpec(ctx) && tt.LatestRevision != nil {
 		lr := *tt.LatestRevision
+		if tt.External != nil {
+			if lr {
+				return apis.ErrGeneric("may not set external when latestRevision is true", "latestRevision")
+			}
+			return nil
+		}
 		pinned := tt.RevisionName != ""
 		if pinned == lr {
 			// The senses for whether to pin to a particular revision or
@@ -20,6 +20,7 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -42,6 +43,67 @@ func TestTrafficTargetValidation(t *testing.T) {
 			Percent:      ptr.Int64(12),
 		},
 		wc: apis.WithinSpec,
+	}, {
+		name: "valid with match",
+		tt: &TrafficTarget{
+			RevisionName: "bar",
+			Match: &TrafficMatch{
+				Headers: map[string]string{"X-Tester": "true"},
+			},
+		},
+		wc: apis.WithinSpec,
+	}, {
+		name: "invalid match cookies",
+		tt: &TrafficTarget{
+			RevisionName: "bar",
+			Match: &TrafficMatch{
+				Headers: map[string]string{"X-Tester": "true"},
+				Cookies: map[string]string{"canary": "yes"},
+			},
+		},
+		wc: apis.WithinSpec,
+		want: &apis.FieldError{
+			Message: "must not set the field(s)",
+			Paths:   []string{"match.cookies"},
+			Details: "matching cookies isn't supported by the Ingress",
+		},
+	}, {
+		name: "invalid empty match",
+		tt: &TrafficTarget{
+			RevisionName: "bar",
+			Match:        &TrafficMatch{},
+		},
+		wc:   apis.WithinSpec,
+		want: apis.ErrMissingOneOf("match.headers", "match.pathPrefix"),
+	}, {
+		name: "valid match path prefix",
+		tt: &TrafficTarget{
+			RevisionName: "bar",
+			Match:        &TrafficMatch{PathPrefix: "/v2/"},
+		},
+		wc: apis.WithinSpec,
+	}, {
+		name: "invalid match path prefix",
+		tt: &TrafficTarget{
+			RevisionName: "bar",
+			Match:        &TrafficMatch{PathPrefix: "v2/*"},
+		},
+		wc:   apis.WithinSpec,
+		want: apis.ErrInvalidValue("v2/*", "match.pathPrefix"),
+	}, {
+		name: "invalid match with only cookies",
+		tt: &TrafficTarget{
+			RevisionName: "bar",
+			Match: &TrafficMatch{
+				Cookies: map[string]string{"canary": "yes"},
+			},
+		},
+		wc: apis.WithinSpec,
+		want: (&apis.FieldError{
+			Message: "must not set the field(s)",
+			Paths:   []string{"match.cookies"},
+			Details: "matching cookies isn't supported by the Ingress",
+		}).Also(apis.ErrMissingOneOf("match.headers", "match.pathPrefix")),
 	}, {
 		name: "valid with revisionName and name (spec)",
 		tt: &TrafficTarget{
@@ -255,6 +317,103 @@ func TestTrafficTargetValidation(t *testing.T) {
 		},
 		wc:   apis.WithinSpec,
 		want: apis.ErrDisallowedFields("url"),
+	}, {
+		name: "valid external service",
+		tt: &TrafficTarget{
+			Percent:  ptr.Int64(10),
+			External: &ExternalTarget{ServiceName: "legacy", Port: 8080},
+		},
+		wc: apis.WithinSpec,
+	}, {
+		name: "valid external url",
+		tt: &TrafficTarget{
+			LatestRevision: ptr.Bool(false),
+			Percent:        ptr.Int64(10),
+			External: &ExternalTarget{
+				URL: &apis.URL{Scheme: "http", Host: "legacy.example.com:8080"},
+			},
+		},
+		wc: apis.WithinSpec,
+	}, {
+		name: "valid external (status)",
+		tt: &TrafficTarget{
+			Percent:  ptr.Int64(10),
+			External: &ExternalTarget{ServiceName: "legacy"},
+		},
+		wc: apis.WithinStatus,
+	}, {
+		name: "invalid external with revisionName",
+		tt: &TrafficTarget{
+			RevisionName: "bar",
+			External:     &ExternalTarget{ServiceName: "legacy"},
+		},
+		wc:   apis.WithinSpec,
+		want: apis.ErrMultipleOneOf("external", "revisionName"),
+	}, {
+		name: "invalid external with latestRevision",
+		tt: &TrafficTarget{
+			LatestRevision: ptr.Bool(true),
+			External:       &ExternalTarget{ServiceName: "legacy"},
+		},
+		wc:   apis.WithinSpec,
+		want: apis.ErrGeneric("may not set external when latestRevision is true", "latestRevision"),
+	}, {
+		name: "invalid empty external",
+		tt: &TrafficTarget{
+			External: &ExternalTarget{},
+		},
+		wc:   apis.WithinSpec,
+		want: apis.ErrMissingOneOf("external.serviceName", "external.url"),
+	}, {
+		name: "invalid external url",
+		tt: &TrafficTarget{
+			External: &ExternalTarget{
+				URL:  &apis.URL{Scheme: "https", Host: "legacy.example.com", Path: "/api"},
+				Port: 443,
+			},
+		},
+		wc: apis.WithinSpec,
+		want: apis.ErrInvalidValue("https://legacy.example.com/api", "external.url").Also(
+			apis.ErrDisallowedFields("external.port")),
+	}, {
+		name: "invalid external url in the cluster",
+		tt: &TrafficTarget{
+			External: &ExternalTarget{
+				URL: &apis.URL{Scheme: "http", Host: "secrets.other-ns.svc.cluster.local"},
+			},
+		},
+		wc: apis.WithinSpec,
+		want: apis.ErrGeneric(`invalid host "secrets.other-ns.svc.cluster.local": must be a fully qualified domain name outside of the cluster, `+
+			"use serviceName for the Services of the namespace", "external.url"),
+	}, {
+		name: "invalid external url relative to the search domains",
+		tt: &TrafficTarget{
+			External: &ExternalTarget{
+				URL: &apis.URL{Scheme: "http", Host: "secrets.other-ns.svc:8080"},
+			},
+		},
+		wc: apis.WithinSpec,
+		want: apis.ErrGeneric(`invalid host "secrets.other-ns.svc": must be a fully qualified domain name outside of the cluster, `+
+			"use serviceName for the Services of the namespace", "external.url"),
+	}, {
+		name: "invalid external url single label",
+		tt: &TrafficTarget{
+			External: &ExternalTarget{
+				URL: &apis.URL{Scheme: "http", Host: "legacy"},
+			},
+		},
+		wc: apis.WithinSpec,
+		want: apis.ErrGeneric(`invalid host "legacy": must be a fully qualified domain name outside of the cluster, `+
+			"use serviceName for the Services of the namespace", "external.url"),
+	}, {
+		name: "invalid external url IP",
+		tt: &TrafficTarget{
+			External: &ExternalTarget{
+				URL: &apis.URL{Scheme: "http", Host: "169.254.169.254"},
+			},
+		},
+		wc:   apis.WithinSpec,
+		want: apis.ErrGeneric(`invalid host "169.254.169.254": must be a domain name`, "external.url"),
 	}}
 
 	for _, test := range tests {
@@ -338,6 +497,38 @@ func TestRouteValidation(t *testing.T) {
 				}},
 			},
 		},
+	}, {
+		name: "valid rollout duration",
+		r: &Route{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: RouteSpec{
+				Traffic: []TrafficTarget{{
+					RevisionName: "foo",
+					Percent:      ptr.Int64(100),
+				}},
+				RolloutDuration: &metav1.Duration{Duration: 3 * time.Minute},
+			},
+		},
+	}, {
+		name: "invalid rollout duration",
+		r: &Route{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: RouteSpec{
+				Traffic: []TrafficTarget{{
+					RevisionName: "foo",
+					Percent:      ptr.Int64(100),
+				}},
+				RolloutDuration: &metav1.Duration{Duration: 1500 * time.Millisecond},
+			},
+		},
+		want: &apis.FieldError{
+			Message: "rolloutDuration=1.5s is not at second precision",
+			Paths:   []string{"spec.rolloutDuration"},
+		},
 	}, {
 		name: "invalid traffic entry (missing oneof)",
 		r: &Route{
@@ -455,6 +646,45 @@ func TestRouteValidation(t *testing.T) {
 			Message: "invalid value: not a DNS 1035 label: [a DNS-1035 label must consist of lower case alphanumeric characters or '-', start with an alphabetic character, and end with an alphanumeric character (e.g. 'my-name',  or 'abc-123', regex used for validation is '[a-z]([-a-z0-9]*[a-z0-9])?')]",
 			Paths:   []string{"spec.traffic.tag[0]"},
 		},
+	}, {
+		name: "valid external url with its own tag",
+		r: &Route{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: RouteSpec{
+				Traffic: []TrafficTarget{{
+					RevisionName: "bar",
+					Percent:      ptr.Int64(100),
+				}, {
+					Tag:     "legacy",
+					Percent: ptr.Int64(0),
+					External: &ExternalTarget{
+						URL: &apis.URL{Scheme: "http", Host: "legacy.example.com"},
+					},
+				}},
+			},
+		},
+	}, {
+		name: "invalid external url sharing the traffic",
+		r: &Route{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+			},
+			Spec: RouteSpec{
+				Traffic: []TrafficTarget{{
+					RevisionName: "bar",
+					Percent:      ptr.Int64(90),
+				}, {
+					Percent: ptr.Int64(10),
+					External: &ExternalTarget{
+						URL: &apis.URL{Scheme: "http", Host: "legacy.example.com"},
+					},
+				}},
+			},
+		},
+		want: apis.ErrGeneric("an external URL target can't share the traffic with other targets",
+			"spec.traffic[1].external.url"),
 	}}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -747,6 +977,19 @@ func TestRouteAnnotationUpdate(t *testing.T) {
 			Spec: getRouteSpec("new"),
 		},
 		wantErr: apis.ErrInvalidValue("three hours and seventeen seconds", serving.RolloutDurationKey).ViaField("metadata.annotations"),
+	}, {
+		name: "readiness gate validation, fail",
+		this: &Route{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "valid",
+				Annotations: map[string]string{
+					serving.ReadinessGateTimeoutKey:       "5m",
+					serving.ReadinessGateTimeoutActionKey: "Wait",
+				},
+			},
+			Spec: getRouteSpec("new"),
+		},
+		wantErr: apis.ErrInvalidValue("Wait", serving.ReadinessGateTimeoutActionKey).ViaField("metadata.annotations"),
 	}, {
 		name: "no validation for lastModifier annotation even after update without spec changes as route owned by service",
 		this: &Route{
@@ -20,12 +20,13 @@ import (
 	"context"
 
 	"knative.dev/pkg/apis"
+	"knative.dev/serving/pkg/apis/config"
 	"knative.dev/serving/pkg/apis/serving"
 )
 
 // SetDefaults implements apis.Defaultable
 func (s *Service) SetDefaults(ctx context.Context) {
-	ctx = apis.WithinParent(ctx, s.ObjectMeta)
+	ctx = apis.WithinParent(config.ForNamespace(ctx, s.Namespace), s.ObjectMeta)
 	s.Spec.SetDefaults(apis.WithinSpec(ctx))
 
 	if apis.IsInUpdate(ctx) {
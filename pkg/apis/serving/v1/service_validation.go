@@ -21,11 +21,13 @@ import (
 
 	network "knative.dev/networking/pkg"
 	"knative.dev/pkg/apis"
+	"knative.dev/serving/pkg/apis/config"
 	"knative.dev/serving/pkg/apis/serving"
 )
 
 // Validate makes sure that Service is properly configured.
 func (s *Service) Validate(ctx context.Context) (errs *apis.FieldError) {
+	ctx = config.ForNamespace(ctx, s.Namespace)
 	// If we are in a status sub resource update, the metadata and spec cannot change.
 	// So, to avoid rejecting controller status updates due to validations that may
 	// have changed (i.e. due to config-defaults changes), we elide the metadata and
@@ -35,6 +37,18 @@ func (s *Service) Validate(ctx context.Context) (errs *apis.FieldError) {
 		errs = errs.Also(s.validateLabels().ViaField("labels"))
 		errs = errs.Also(serving.ValidateRolloutDurationAnnotation(
 			s.GetAnnotations()).ViaField("annotations"))
+		errs = errs.Also(serving.ValidateReadinessGateAnnotations(
+			s.GetAnnotations()).ViaField("annotations"))
+		errs = errs.Also(serving.ValidateRolloutGuardAnnotations(
+			s.GetAnnotations()).ViaField("annotations"))
+		errs = errs.Also(serving.ValidateCertificateIssuerAnnotation(
+			s.GetAnnotations()).ViaField("annotations"))
+		errs = errs.Also(serving.ValidateCORSAnnotations(
+			s.GetAnnotations()).ViaField("annotations"))
+		errs = errs.Also(serving.ValidateTagResponseHeadersAnnotation(
+			s.GetAnnotations()).ViaField("annotations"))
+		errs = errs.Also(serving.ValidateMirrorAnnotations(
+			s.GetAnnotations()).ViaField("annotations"))
 		errs = errs.ViaField("metadata")
 
 		ctx = apis.WithinParent(ctx, s.ObjectMeta)
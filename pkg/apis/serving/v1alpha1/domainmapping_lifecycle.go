@@ -40,6 +40,24 @@ func (dm *DomainMapping) GetGroupVersionKind() schema.GroupVersionKind {
 	return SchemeGroupVersion.WithKind("DomainMapping")
 }
 
+// Domain returns the domain of the DomainMapping, its spec.host or else its
+// name.
+func (dm *DomainMapping) Domain() string {
+	if dm.Spec.Host != "" {
+		return dm.Spec.Host
+	}
+	return dm.Name
+}
+
+// Host returns the host mapped by the DomainMapping, its domain or, when it
+// is a wildcard mapping, all the subdomains of its domain.
+func (dm *DomainMapping) Host() string {
+	if dm.Spec.Wildcard {
+		return "*." + dm.Domain()
+	}
+	return dm.Domain()
+}
+
 // IsReady returns true if the DomainMapping is ready.
 func (dms *DomainMappingStatus) IsReady() bool {
 	return domainMappingCondSet.Manage(dms).IsHappy()
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	netv1alpha1 "knative.dev/networking/pkg/apis/networking/v1alpha1"
 	"knative.dev/pkg/apis"
@@ -335,3 +336,14 @@ func TestDomainMappingIsReady(t *testing.T) {
 		})
 	}
 }
+
+func TestDomainMappingHost(t *testing.T) {
+	dm := &DomainMapping{ObjectMeta: metav1.ObjectMeta{Name: "mapping.com"}}
+	if got, want := dm.Host(), "mapping.com"; got != want {
+		t.Errorf("Host() = %q, want: %q", got, want)
+	}
+	dm.Spec.Wildcard = true
+	if got, want := dm.Host(), "*.mapping.com"; got != want {
+		t.Errorf("Host() = %q, want: %q (wildcard)", got, want)
+	}
+}
@@ -92,6 +92,24 @@ type DomainMappingSpec struct {
 	// TLS allows the DomainMapping to terminate TLS traffic with an existing secret.
 	// +optional
 	TLS *SecretTLS `json:"tls,omitempty"`
+
+	// Wildcard maps all the subdomains of the name, i.e. `*.{name}`, rather
+	// than the name itself. With auto-TLS this requests a wildcard
+	// certificate, which needs a certificate class solving DNS-01 challenges.
+	// +optional
+	Wildcard bool `json:"wildcard,omitempty"`
+
+	// Host is the host the Ref is mapped on, defaulting to the name of the
+	// DomainMapping. Setting it lets several DomainMappings of a namespace
+	// mount different paths of the same host.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Path mounts the Ref under this path prefix of the host, e.g. `/api`,
+	// rather than at its root. The paths of the DomainMappings of a host
+	// must not overlap.
+	// +optional
+	Path string `json:"path,omitempty"`
 }
 
 // DomainMappingStatus describes the current state of the DomainMapping.
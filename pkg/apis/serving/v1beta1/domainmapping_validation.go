@@ -44,16 +44,9 @@ func (dm *DomainMapping) validateMetadata(ctx context.Context) (errs *apis.Field
 		errs = errs.Also(apis.ErrDisallowedFields("generateName"))
 	}
 
-	err := validation.IsFullyQualifiedDomainName(field.NewPath("name"), dm.Name)
-	if err != nil {
-		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf(
-			"invalid name %q: %s", dm.Name, err.ToAggregate()), "name"))
-	}
-
-	clusterLocalDomain := network.GetClusterDomainName()
-	if strings.HasSuffix(dm.Name, "."+clusterLocalDomain) {
-		errs = errs.Also(apis.ErrGeneric(
-			fmt.Sprintf("invalid name %q: must not be a subdomain of cluster local domain %q", dm.Name, clusterLocalDomain), "name"))
+	// The name is the domain, unless the spec has a host.
+	if dm.Spec.Host == "" {
+		errs = errs.Also(validateDomain(dm.Name, "name"))
 	}
 
 	if apis.IsInUpdate(ctx) {
@@ -67,7 +60,32 @@ func (dm *DomainMapping) validateMetadata(ctx context.Context) (errs *apis.Field
 	return errs
 }
 
+// validateDomain makes sure the domain is a fully qualified domain name
+// outside of the cluster local domain.
+func validateDomain(domain, fieldName string) (errs *apis.FieldError) {
+	err := validation.IsFullyQualifiedDomainName(field.NewPath(fieldName), domain)
+	if err != nil {
+		errs = errs.Also(apis.ErrGeneric(fmt.Sprintf(
+			"invalid %s %q: %s", fieldName, domain, err.ToAggregate()), fieldName))
+	}
+
+	clusterLocalDomain := network.GetClusterDomainName()
+	if strings.HasSuffix(domain, "."+clusterLocalDomain) {
+		errs = errs.Also(apis.ErrGeneric(
+			fmt.Sprintf("invalid %s %q: must not be a subdomain of cluster local domain %q", fieldName, domain, clusterLocalDomain), fieldName))
+	}
+	return errs
+}
+
 // Validate makes sure the DomainMappingSpec is properly configured.
 func (spec *DomainMappingSpec) Validate(ctx context.Context) *apis.FieldError {
-	return spec.Ref.Validate(ctx).ViaField("ref")
+	errs := spec.Ref.Validate(ctx).ViaField("ref")
+	if spec.Host != "" {
+		errs = errs.Also(validateDomain(spec.Host, "host"))
+	}
+	if p := spec.Path; p != "" && (!strings.HasPrefix(p, "/") || strings.HasSuffix(p, "/") ||
+		strings.ContainsAny(p, "?#*")) {
+		errs = errs.Also(apis.ErrInvalidValue(p, "path"))
+	}
+	return errs
 }
@@ -67,6 +67,44 @@ func TestDomainMappingValidation(t *testing.T) {
 				},
 			},
 		},
+	}, {
+		name: "valid host and path",
+		dm: &DomainMapping{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "api",
+				Namespace: "ns",
+			},
+			Spec: DomainMappingSpec{
+				Ref: duckv1.KReference{
+					Name:       "api",
+					APIVersion: "serving.knative.dev/v1",
+					Kind:       "Service",
+					Namespace:  "ns",
+				},
+				Host: "example.com",
+				Path: "/api",
+			},
+		},
+	}, {
+		name: "invalid host and path",
+		want: apis.ErrGeneric("invalid host \"invalid\": host: Invalid value: \"invalid\": should be a domain with at least two segments separated by dots", "spec.host").Also(
+			apis.ErrInvalidValue("api/", "spec.path")),
+		dm: &DomainMapping{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "api.example.com",
+				Namespace: "ns",
+			},
+			Spec: DomainMappingSpec{
+				Ref: duckv1.KReference{
+					Name:       "api",
+					APIVersion: "serving.knative.dev/v1",
+					Kind:       "Service",
+					Namespace:  "ns",
+				},
+				Host: "invalid",
+				Path: "api/",
+			},
+		},
 	}, {
 		name: "ref in wrong namespace",
 		want: &apis.FieldError{
@@ -42,6 +42,16 @@ type Config struct {
 	// the number of activators per revision.
 	ActivatorCapacity float64
 
+	// ActivatorQueueThreshold is the number of the requests queued in the
+	// activators for a revision that warrant one more pod on top of the ready
+	// ones, regardless of the observed concurrency. 0 disables it.
+	ActivatorQueueThreshold float64
+
+	// ActivatorFallbackErrorRatio is the share of the requests of a revision
+	// failing on its pods, over the panic window, above which the activator
+	// is put back in their path to buffer and retry them. 0 disables it.
+	ActivatorFallbackErrorRatio float64
+
 	// AllowZeroInitialScale indicates whether InitialScale and
 	// autoscaling.internal.knative.dev/initialScale are allowed to be set to 0.
 	AllowZeroInitialScale bool
@@ -58,6 +68,11 @@ type Config struct {
 	// annotation value for a revision.
 	MaxScaleLimit int32
 
+	// SkipDeciderForFixedScale indicates whether the revisions pinned at a
+	// fixed scale, i.e. with equal min and max scale, are scaled to it without
+	// a decider, so that no metrics are collected for them.
+	SkipDeciderForFixedScale bool
+
 	// General autoscaler algorithm configuration.
 	MaxScaleUpRate           float64
 	MaxScaleDownRate         float64
@@ -65,6 +80,23 @@ type Config struct {
 	PanicWindowPercentage    float64
 	PanicThresholdPercentage float64
 
+	// StepChangeThreshold is the ratio of the load observed over the panic
+	// window to the load observed over the stable window, at or above which
+	// the autoscaler treats the load as a step change and scales on the panic
+	// window instead, until the stable window catches up. 0 disables it.
+	StepChangeThreshold float64
+
+	// PredictionPeriod is the period of the load pattern that the autoscaler
+	// learns to pre-scale revisions ahead of the load it predicts, e.g. 24h to
+	// expect the load seen at the same time the day before. 0 disables it.
+	PredictionPeriod time.Duration
+	// PredictionSeasons is the number of past periods whose load is averaged
+	// to predict the load.
+	PredictionSeasons int
+	// PredictionLookahead is how far ahead of the predicted load the
+	// autoscaler scales for it.
+	PredictionLookahead time.Duration
+
 	// ScaleToZeroGracePeriod is the time we will wait for networking to
 	// propagate before scaling down. We may wait less than this if it is safe to
 	// do so, for example if the Activator has already been in the path for
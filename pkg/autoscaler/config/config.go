@@ -57,6 +57,9 @@ func defaultConfig() *autoscalerconfig.Config {
 		ScaleToZeroGracePeriod:        30 * time.Second,
 		ScaleToZeroPodRetentionPeriod: 0 * time.Second,
 		ScaleDownDelay:                0 * time.Second,
+		PredictionPeriod:              0 * time.Second,
+		PredictionSeasons:             1,
+		PredictionLookahead:           5 * time.Minute,
 		PodAutoscalerClass:            autoscaling.KPA,
 		AllowZeroInitialScale:         false,
 		InitialScale:                  1,
@@ -74,6 +77,7 @@ func NewConfigFromMap(data map[string]string) (*autoscalerconfig.Config, error)
 
 		cm.AsBool("enable-scale-to-zero", &lc.EnableScaleToZero),
 		cm.AsBool("allow-zero-initial-scale", &lc.AllowZeroInitialScale),
+		cm.AsBool("skip-decider-for-fixed-scale", &lc.SkipDeciderForFixedScale),
 
 		cm.AsFloat64("max-scale-up-rate", &lc.MaxScaleUpRate),
 		cm.AsFloat64("max-scale-down-rate", &lc.MaxScaleDownRate),
@@ -83,16 +87,22 @@ func NewConfigFromMap(data map[string]string) (*autoscalerconfig.Config, error)
 		cm.AsFloat64("target-burst-capacity", &lc.TargetBurstCapacity),
 		cm.AsFloat64("panic-window-percentage", &lc.PanicWindowPercentage),
 		cm.AsFloat64("activator-capacity", &lc.ActivatorCapacity),
+		cm.AsFloat64("activator-queue-threshold", &lc.ActivatorQueueThreshold),
+		cm.AsFloat64("activator-fallback-error-ratio", &lc.ActivatorFallbackErrorRatio),
 		cm.AsFloat64("panic-threshold-percentage", &lc.PanicThresholdPercentage),
+		cm.AsFloat64("step-change-threshold", &lc.StepChangeThreshold),
 
 		cm.AsInt32("initial-scale", &lc.InitialScale),
 		cm.AsInt32("max-scale", &lc.MaxScale),
 		cm.AsInt32("max-scale-limit", &lc.MaxScaleLimit),
+		cm.AsInt("prediction-seasons", &lc.PredictionSeasons),
 
 		cm.AsDuration("stable-window", &lc.StableWindow),
 		cm.AsDuration("scale-down-delay", &lc.ScaleDownDelay),
 		cm.AsDuration("scale-to-zero-grace-period", &lc.ScaleToZeroGracePeriod),
 		cm.AsDuration("scale-to-zero-pod-retention-period", &lc.ScaleToZeroPodRetentionPeriod),
+		cm.AsDuration("prediction-period", &lc.PredictionPeriod),
+		cm.AsDuration("prediction-lookahead", &lc.PredictionLookahead),
 	); err != nil {
 		return nil, fmt.Errorf("failed to parse data: %w", err)
 	}
@@ -145,6 +155,14 @@ func validate(lc *autoscalerconfig.Config) (*autoscalerconfig.Config, error) {
 		return nil, fmt.Errorf("activator-capacity = %v, must be at least 1", lc.ActivatorCapacity)
 	}
 
+	if lc.ActivatorQueueThreshold < 0 {
+		return nil, fmt.Errorf("activator-queue-threshold cannot be negative, was: %v", lc.ActivatorQueueThreshold)
+	}
+
+	if lc.ActivatorFallbackErrorRatio < 0 || lc.ActivatorFallbackErrorRatio > 1 {
+		return nil, fmt.Errorf("activator-fallback-error-ratio = %v, must be in [0, 1] range", lc.ActivatorFallbackErrorRatio)
+	}
+
 	if lc.MaxScaleUpRate <= 1.0 {
 		return nil, fmt.Errorf("max-scale-up-rate = %v, must be greater than 1.0", lc.MaxScaleUpRate)
 	}
@@ -172,6 +190,27 @@ func validate(lc *autoscalerconfig.Config) (*autoscalerconfig.Config, error) {
 
 	}
 
+	if lc.StepChangeThreshold != 0 && lc.StepChangeThreshold <= 1.0 {
+		return nil, fmt.Errorf("step-change-threshold = %v, must be 0 (disabled) or greater than 1.0", lc.StepChangeThreshold)
+	}
+
+	if lc.PredictionPeriod < 0 {
+		return nil, fmt.Errorf("prediction-period cannot be negative, was: %v", lc.PredictionPeriod)
+	}
+
+	if lc.PredictionPeriod > 0 {
+		if lc.PredictionPeriod.Round(time.Minute) != lc.PredictionPeriod {
+			return nil, fmt.Errorf("prediction-period = %v, must be specified with at most minute precision", lc.PredictionPeriod)
+		}
+		if lc.PredictionSeasons < 1 {
+			return nil, fmt.Errorf("prediction-seasons = %v, must be at least 1", lc.PredictionSeasons)
+		}
+		if lc.PredictionLookahead <= 0 || lc.PredictionLookahead >= lc.PredictionPeriod {
+			return nil, fmt.Errorf("prediction-lookahead = %v, must be in (0, prediction-period(%v)) range",
+				lc.PredictionLookahead, lc.PredictionPeriod)
+		}
+	}
+
 	if lc.InitialScale < 0 || (lc.InitialScale == 0 && !lc.AllowZeroInitialScale) {
 		return nil, fmt.Errorf("initial-scale = %v, must be at least 0 (or at least 1 when allow-zero-initial-scale is false)", lc.InitialScale)
 	}
@@ -247,6 +247,38 @@ func TestNewConfig(t *testing.T) {
 			"activator-capacity": "0.95",
 		},
 		wantErr: true,
+	}, {
+		name: "activator-queue-threshold negative",
+		input: map[string]string{
+			"activator-queue-threshold": "-1",
+		},
+		wantErr: true,
+	}, {
+		name: "with activator queue threshold",
+		input: map[string]string{
+			"activator-queue-threshold": "10",
+		},
+		want: func() *autoscalerconfig.Config {
+			c := defaultConfig()
+			c.ActivatorQueueThreshold = 10
+			return c
+		}(),
+	}, {
+		name: "activator-fallback-error-ratio over 1",
+		input: map[string]string{
+			"activator-fallback-error-ratio": "1.5",
+		},
+		wantErr: true,
+	}, {
+		name: "with activator fallback error ratio",
+		input: map[string]string{
+			"activator-fallback-error-ratio": "0.5",
+		},
+		want: func() *autoscalerconfig.Config {
+			c := defaultConfig()
+			c.ActivatorFallbackErrorRatio = 0.5
+			return c
+		}(),
 	}, {
 		name: "panic window percentage too small",
 		input: map[string]string{
@@ -261,6 +293,62 @@ func TestNewConfig(t *testing.T) {
 			"panic-window-percentage": "110",
 		},
 		wantErr: true,
+	}, {
+		name: "step change threshold too small",
+		input: map[string]string{
+			"step-change-threshold": "1",
+		},
+		wantErr: true,
+	}, {
+		name: "with valid step change threshold",
+		input: map[string]string{
+			"step-change-threshold": "5.5",
+		},
+		want: func() *autoscalerconfig.Config {
+			c := defaultConfig()
+			c.StepChangeThreshold = 5.5
+			return c
+		}(),
+	}, {
+		name: "with prediction",
+		input: map[string]string{
+			"prediction-period":    "24h",
+			"prediction-seasons":   "7",
+			"prediction-lookahead": "10m",
+		},
+		want: func() *autoscalerconfig.Config {
+			c := defaultConfig()
+			c.PredictionPeriod = 24 * time.Hour
+			c.PredictionSeasons = 7
+			c.PredictionLookahead = 10 * time.Minute
+			return c
+		}(),
+	}, {
+		name: "prediction period negative",
+		input: map[string]string{
+			"prediction-period": "-1h",
+		},
+		wantErr: true,
+	}, {
+		name: "prediction period not whole minutes",
+		input: map[string]string{
+			"prediction-period": "1h30s",
+		},
+		wantErr: true,
+	}, {
+		name: "prediction seasons too few",
+		input: map[string]string{
+			"prediction-period":  "24h",
+			"prediction-seasons": "0",
+		},
+		wantErr: true,
+	}, {
+		name: "prediction lookahead not less than period",
+		input: map[string]string{
+			"prediction-period":    "1h",
+			"prediction-lookahead": "1h",
+		},
+		wantErr: true,
 	}, {
 		name: "TU*CC < 0.01",
 		input: map[string]string{
@@ -308,6 +396,16 @@ func TestNewConfig(t *testing.T) {
 			c.InitialScale = 0
 			return c
 		}(),
+	}, {
+		name: "with the decider skipped for fixed scale",
+		input: map[string]string{
+			"skip-decider-for-fixed-scale": "true",
+		},
+		want: func() *autoscalerconfig.Config {
+			c := defaultConfig()
+			c.SkipDeciderForFixedScale = true
+			return c
+		}(),
 	}, {
 		name: "with non-parseable allow-zero-initial-scale",
 		input: map[string]string{
@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package historystore persists the load histories of the autoscaler's
+// metric collector.
+package historystore
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/kmeta"
+	autoscalingv1alpha1 "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
+	"knative.dev/serving/pkg/autoscaler/metrics"
+)
+
+// historyDataKey is the key of the ConfigMap binary data holding the history.
+const historyDataKey = "history"
+
+// configMapStore persists the history of each metric to a ConfigMap
+// owned by the metric, so that it's deleted along with it.
+type configMapStore struct {
+	ctx    context.Context
+	client kubernetes.Interface
+}
+
+var _ metrics.HistoryStore = (*configMapStore)(nil)
+
+// NewConfigMapStore creates a metrics.HistoryStore which persists the history
+// of each metric to a ConfigMap next to it.
+func NewConfigMapStore(ctx context.Context, client kubernetes.Interface) metrics.HistoryStore {
+	return &configMapStore{
+		ctx:    ctx,
+		client: client,
+	}
+}
+
+// historyConfigMapName returns the name of the ConfigMap holding the history
+// of the metric.
+func historyConfigMapName(metric *autoscalingv1alpha1.Metric) string {
+	return kmeta.ChildName(metric.Name, "-load-history")
+}
+
+// Load implements metrics.HistoryStore.
+func (s *configMapStore) Load(metric *autoscalingv1alpha1.Metric) ([]byte, error) {
+	cm, err := s.client.CoreV1().ConfigMaps(metric.Namespace).Get(s.ctx, historyConfigMapName(metric), metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return cm.BinaryData[historyDataKey], nil
+}
+
+// Save implements metrics.HistoryStore.
+func (s *configMapStore) Save(metric *autoscalingv1alpha1.Metric, data []byte) error {
+	configMaps := s.client.CoreV1().ConfigMaps(metric.Namespace)
+	cm, err := configMaps.Get(s.ctx, historyConfigMapName(metric), metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		_, err = configMaps.Create(s.ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            historyConfigMapName(metric),
+				Namespace:       metric.Namespace,
+				Labels:          metric.Labels,
+				OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(metric)},
+			},
+			BinaryData: map[string][]byte{historyDataKey: data},
+		}, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	cm = cm.DeepCopy()
+	cm.BinaryData = map[string][]byte{historyDataKey: data}
+	_, err = configMaps.Update(s.ctx, cm, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package historystore
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakek8s "k8s.io/client-go/kubernetes/fake"
+	autoscalingv1alpha1 "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
+)
+
+func TestConfigMapHistoryStore(t *testing.T) {
+	ctx := context.Background()
+	client := fakek8s.NewSimpleClientset()
+	store := NewConfigMapStore(ctx, client)
+	metric := &autoscalingv1alpha1.Metric{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-namespace",
+			Name:      "test-name",
+			UID:       "metric-uid",
+		},
+	}
+
+	if got, err := store.Load(metric); err != nil || got != nil {
+		t.Fatalf("Load() = %v, %v, want no history", got, err)
+	}
+
+	for _, data := range [][]byte{[]byte("created"), []byte("updated")} {
+		if err := store.Save(metric, data); err != nil {
+			t.Fatal("Save() =", err)
+		}
+		if got, err := store.Load(metric); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("Load() = %q, %v, want: %q", got, err, data)
+		}
+	}
+
+	cm, err := client.CoreV1().ConfigMaps(metric.Namespace).Get(ctx, "test-name-load-history", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("Failed to get the ConfigMap:", err)
+	}
+	if refs := cm.OwnerReferences; len(refs) != 1 || refs[0].UID != metric.UID {
+		t.Errorf("OwnerReferences = %v, want the metric", refs)
+	}
+}
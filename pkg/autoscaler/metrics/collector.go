@@ -18,6 +18,7 @@ package metrics
 
 import (
 	"errors"
+	"math"
 	"sync"
 	"time"
 
@@ -35,6 +36,10 @@ const (
 	// scrapeTickInterval is the interval of time between triggering StatsScraper.Scrape()
 	// to get metrics across all pods of a revision.
 	scrapeTickInterval = time.Second
+
+	// historySaveInterval is the interval of time between persisting the
+	// load history of a revision, if it's kept.
+	historySaveInterval = 5 * time.Minute
 )
 
 var (
@@ -80,14 +85,34 @@ type MetricClient interface {
 	// StableAndPanicRPS returns both the stable and the panic RPS
 	// for the given replica as of the given time.
 	StableAndPanicRPS(key types.NamespacedName, now time.Time) (float64, float64, error)
+
+	// StableAndPanicCustom returns both the stable and the panic value of the
+	// custom metric for the given replica as of the given time.
+	StableAndPanicCustom(key types.NamespacedName, now time.Time) (float64, float64, error)
+
+	// ActivatorQueueDepth returns the number of requests for the given
+	// replica queued in the activators, averaged over the panic window.
+	ActivatorQueueDepth(key types.NamespacedName, now time.Time) (float64, error)
+
+	// ErrorRatio returns the share of the requests for the given replica
+	// that failed on its pods, over the panic window.
+	ErrorRatio(key types.NamespacedName, now time.Time) (float64, error)
+
+	// History returns the load history of the given replica for a load
+	// pattern repeating every period, averaged over the given seasons.
+	// The history is replaced by an empty one if it was kept for another
+	// period or number of seasons.
+	History(key types.NamespacedName, period time.Duration, seasons int) (*History, error)
 }
 
 // MetricCollector manages collection of metrics for many entities.
 type MetricCollector struct {
 	logger *zap.SugaredLogger
 
-	statsScraperFactory StatsScraperFactory
-	clock               clock.Clock
+	statsScraperFactory        StatsScraperFactory
+	customMetricScraperFactory CustomMetricScraperFactory
+	clock                      clock.Clock
+	historyStore               HistoryStore
 
 	collectionsMutex sync.RWMutex
 	collections      map[types.NamespacedName]*collection
@@ -109,6 +134,20 @@ func NewMetricCollector(statsScraperFactory StatsScraperFactory, logger *zap.Sug
 	}
 }
 
+// PersistHistory persists the load histories to the given store, and
+// restores them from it when collections are created. It must be called
+// before any metric is collected.
+func (c *MetricCollector) PersistHistory(store HistoryStore) {
+	c.historyStore = store
+}
+
+// ScrapeCustomMetrics scrapes the custom metrics of the metrics scaled on one
+// with the scrapers the given factory creates. It must be called before any
+// metric is collected.
+func (c *MetricCollector) ScrapeCustomMetrics(factory CustomMetricScraperFactory) {
+	c.customMetricScraperFactory = factory
+}
+
 // CreateOrUpdate either creates a collection for the given metric or update it, should
 // it already exist.
 func (c *MetricCollector) CreateOrUpdate(metric *autoscalingv1alpha1.Metric) error {
@@ -121,6 +160,12 @@ func (c *MetricCollector) CreateOrUpdate(metric *autoscalingv1alpha1.Metric) err
 	if err != nil {
 		return err
 	}
+	var customScraper CustomMetricScraper
+	if c.customMetricScraperFactory != nil {
+		if customScraper, err = c.customMetricScraperFactory(metric, logger); err != nil {
+			return err
+		}
+	}
 	key := types.NamespacedName{Namespace: metric.Namespace, Name: metric.Name}
 
 	c.collectionsMutex.Lock()
@@ -128,12 +173,12 @@ func (c *MetricCollector) CreateOrUpdate(metric *autoscalingv1alpha1.Metric) err
 
 	collection, exists := c.collections[key]
 	if exists {
-		collection.updateScraper(scraper)
+		collection.updateScraper(scraper, customScraper)
 		collection.updateMetric(metric)
 		return collection.lastError()
 	}
 
-	c.collections[key] = newCollection(metric, scraper, c.clock, c.Inform, logger)
+	c.collections[key] = newCollection(metric, scraper, customScraper, c.clock, c.historyStore, c.Inform, logger)
 	return nil
 }
 
@@ -218,6 +263,70 @@ func (c *MetricCollector) StableAndPanicRPS(key types.NamespacedName, now time.T
 		nil
 }
 
+// StableAndPanicCustom returns both the stable and the panic value of the
+// custom metric.
+// It may truncate metric buckets as a side-effect.
+func (c *MetricCollector) StableAndPanicCustom(key types.NamespacedName, now time.Time) (float64, float64, error) {
+	c.collectionsMutex.RLock()
+	defer c.collectionsMutex.RUnlock()
+
+	collection, exists := c.collections[key]
+	if !exists {
+		return 0, 0, ErrNotCollecting
+	}
+
+	if collection.customBuckets.IsEmpty(now) {
+		return 0, 0, ErrNoData
+	}
+	return collection.customBuckets.WindowAverage(now),
+		collection.customPanicBuckets.WindowAverage(now),
+		nil
+}
+
+// ActivatorQueueDepth returns the number of requests queued in the activators,
+// averaged over the panic window.
+// It may truncate metric buckets as a side-effect.
+func (c *MetricCollector) ActivatorQueueDepth(key types.NamespacedName, now time.Time) (float64, error) {
+	c.collectionsMutex.RLock()
+	defer c.collectionsMutex.RUnlock()
+
+	collection, exists := c.collections[key]
+	if !exists {
+		return 0, ErrNotCollecting
+	}
+	return collection.activatorQueueBuckets.WindowAverage(now), nil
+}
+
+// ErrorRatio returns the share of the requests that failed on the pods, i.e.
+// the errors per second over the requests per second, over the panic window.
+// It may truncate metric buckets as a side-effect.
+func (c *MetricCollector) ErrorRatio(key types.NamespacedName, now time.Time) (float64, error) {
+	c.collectionsMutex.RLock()
+	defer c.collectionsMutex.RUnlock()
+
+	collection, exists := c.collections[key]
+	if !exists {
+		return 0, ErrNotCollecting
+	}
+	rps := collection.rpsPanicBuckets.WindowAverage(now)
+	if rps <= 0 {
+		return 0, nil
+	}
+	return math.Min(collection.errorPanicBuckets.WindowAverage(now)/rps, 1), nil
+}
+
+// History returns the load history of the given replica.
+func (c *MetricCollector) History(key types.NamespacedName, period time.Duration, seasons int) (*History, error) {
+	c.collectionsMutex.RLock()
+	defer c.collectionsMutex.RUnlock()
+
+	collection, exists := c.collections[key]
+	if !exists {
+		return nil, ErrNotCollecting
+	}
+	return collection.getHistory(period, seasons), nil
+}
+
 type (
 	// windowAverager is the client side abstraction for various bucket types.
 	windowAverager interface {
@@ -239,31 +348,42 @@ type (
 		concurrencyPanicBuckets windowAverager
 		rpsBuckets              windowAverager
 		rpsPanicBuckets         windowAverager
+		customBuckets           windowAverager
+		customPanicBuckets      windowAverager
+		activatorQueueBuckets   windowAverager
+		errorPanicBuckets       windowAverager
+
+		// history is the load history, created once asked for and restored
+		// from the historyStore, if there's one.
+		history      *History
+		historyStore HistoryStore
 
 		// Fields relevant for metric scraping specifically.
-		scraper StatsScraper
-		lastErr error
-		grp     sync.WaitGroup
-		stopCh  chan struct{}
+		scraper       StatsScraper
+		customScraper CustomMetricScraper
+		lastErr       error
+		grp           sync.WaitGroup
+		stopCh        chan struct{}
 	}
 )
 
-func (c *collection) updateScraper(ss StatsScraper) {
+func (c *collection) updateScraper(ss StatsScraper, cs CustomMetricScraper) {
 	c.mux.Lock()
 	defer c.mux.Unlock()
 	c.scraper = ss
+	c.customScraper = cs
 }
 
-func (c *collection) getScraper() StatsScraper {
+func (c *collection) getScraper() (StatsScraper, CustomMetricScraper) {
 	c.mux.RLock()
 	defer c.mux.RUnlock()
-	return c.scraper
+	return c.scraper, c.customScraper
 }
 
-// newCollection creates a new collection, which uses the given scraper to
-// collect stats every scrapeTickInterval.
-func newCollection(metric *autoscalingv1alpha1.Metric, scraper StatsScraper, clock clock.Clock,
-	callback func(types.NamespacedName), logger *zap.SugaredLogger) *collection {
+// newCollection creates a new collection, which uses the given scrapers to
+// collect stats and the custom metric, if any, every scrapeTickInterval.
+func newCollection(metric *autoscalingv1alpha1.Metric, scraper StatsScraper, customScraper CustomMetricScraper,
+	clock clock.Clock, historyStore HistoryStore, callback func(types.NamespacedName), logger *zap.SugaredLogger) *collection {
 	// Pick the constructor to use to build the buckets.
 	// NB: this relies on the fact that aggregation algorithm is set on annotation of revision
 	// and as such is immutable.
@@ -286,7 +406,17 @@ func newCollection(metric *autoscalingv1alpha1.Metric, scraper StatsScraper, clo
 			metric.Spec.StableWindow, config.BucketSize),
 		rpsPanicBuckets: bucketCtor(
 			metric.Spec.PanicWindow, config.BucketSize),
-		scraper: scraper,
+		customBuckets: bucketCtor(
+			metric.Spec.StableWindow, config.BucketSize),
+		customPanicBuckets: bucketCtor(
+			metric.Spec.PanicWindow, config.BucketSize),
+		activatorQueueBuckets: bucketCtor(
+			metric.Spec.PanicWindow, config.BucketSize),
+		errorPanicBuckets: bucketCtor(
+			metric.Spec.PanicWindow, config.BucketSize),
+		scraper:       scraper,
+		customScraper: customScraper,
+		historyStore:  historyStore,
 
 		stopCh: make(chan struct{}),
 	}
@@ -298,14 +428,27 @@ func newCollection(metric *autoscalingv1alpha1.Metric, scraper StatsScraper, clo
 	go func() {
 		defer c.grp.Done()
 
+		// The history is restored in the background, as the collection is
+		// created with the collector locked.
+		if err := c.restoreHistory(); err != nil {
+			logger.Warnw("Failed to restore the load history", zap.Error(err))
+		}
+
 		scrapeTicker := clock.NewTicker(scrapeTickInterval)
 		defer scrapeTicker.Stop()
+		lastSaved := clock.Now()
 		for {
 			select {
 			case <-c.stopCh:
 				return
 			case <-scrapeTicker.C():
-				scraper := c.getScraper()
+				if now := clock.Now(); now.Sub(lastSaved) >= historySaveInterval {
+					lastSaved = now
+					if err := c.saveHistory(); err != nil {
+						logger.Warnw("Failed to persist the load history", zap.Error(err))
+					}
+				}
+				scraper, customScraper := c.getScraper()
 				if scraper == nil {
 					// Don't scrape empty target service.
 					if c.updateLastError(nil) {
@@ -314,10 +457,22 @@ func newCollection(metric *autoscalingv1alpha1.Metric, scraper StatsScraper, clo
 					continue
 				}
 
-				stat, err := scraper.Scrape(c.currentMetric().Spec.StableWindow)
+				window := c.currentMetric().Spec.StableWindow
+				stat, err := scraper.Scrape(window)
 				if err != nil {
 					logger.Errorw("Failed to scrape metrics", zap.Error(err))
 				}
+				if customScraper != nil {
+					value, cerr := customScraper.Scrape(window)
+					if cerr != nil {
+						logger.Errorw("Failed to scrape the custom metric", zap.Error(cerr))
+						if err == nil {
+							err = cerr
+						}
+					} else {
+						c.recordCustom(clock.Now(), value)
+					}
+				}
 				if c.updateLastError(err) {
 					callback(key)
 				}
@@ -347,6 +502,10 @@ func (c *collection) updateMetric(metric *autoscalingv1alpha1.Metric) {
 	c.concurrencyPanicBuckets.ResizeWindow(metric.Spec.PanicWindow)
 	c.rpsBuckets.ResizeWindow(metric.Spec.StableWindow)
 	c.rpsPanicBuckets.ResizeWindow(metric.Spec.PanicWindow)
+	c.customBuckets.ResizeWindow(metric.Spec.StableWindow)
+	c.customPanicBuckets.ResizeWindow(metric.Spec.PanicWindow)
+	c.activatorQueueBuckets.ResizeWindow(metric.Spec.PanicWindow)
+	c.errorPanicBuckets.ResizeWindow(metric.Spec.PanicWindow)
 }
 
 // currentMetric safely returns the current metric stored in the collection.
@@ -377,6 +536,58 @@ func (c *collection) lastError() error {
 	return c.lastErr
 }
 
+// getHistory returns the load history for the given period and seasons,
+// replacing the one kept if it doesn't fit them.
+func (c *collection) getHistory(period time.Duration, seasons int) *History {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.history == nil || !c.history.fits(period, seasons) {
+		c.history = NewHistory(period, seasons)
+	}
+	return c.history
+}
+
+// restoreHistory restores the load history from the historyStore, if there's
+// one, unless a history was asked for already.
+func (c *collection) restoreHistory() error {
+	if c.historyStore == nil {
+		return nil
+	}
+	data, err := c.historyStore.Load(c.currentMetric())
+	if err != nil || data == nil {
+		return err
+	}
+	h, err := decodeHistory(data)
+	if err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.history == nil {
+		c.history = h
+	}
+	return nil
+}
+
+// saveHistory persists the load history to the historyStore, if there's one
+// to persist.
+func (c *collection) saveHistory() error {
+	c.mux.RLock()
+	h, metric := c.history, c.metric
+	c.mux.RUnlock()
+
+	if c.historyStore == nil || h == nil {
+		return nil
+	}
+	data, err := h.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return c.historyStore.Save(metric, data)
+}
+
 // record adds a stat to the current collection.
 func (c *collection) record(now time.Time, stat Stat) {
 	// Proxied requests have been counted at the activator. Subtract
@@ -387,6 +598,14 @@ func (c *collection) record(now time.Time, stat Stat) {
 	rps := stat.RequestCount - stat.ProxiedRequestCount
 	c.rpsBuckets.Record(now, rps)
 	c.rpsPanicBuckets.Record(now, rps)
+	c.activatorQueueBuckets.Record(now, stat.ActivatorQueueDepth)
+	c.errorPanicBuckets.Record(now, stat.ErrorCount)
+}
+
+// recordCustom adds a value of the custom metric to the current collection.
+func (c *collection) recordCustom(now time.Time, value float64) {
+	c.customBuckets.Record(now, value)
+	c.customPanicBuckets.Record(now, value)
 }
 
 // add adds the stats from `src` to `dst`.
@@ -395,6 +614,7 @@ func (dst *Stat) add(src Stat) {
 	dst.AverageProxiedConcurrentRequests += src.AverageProxiedConcurrentRequests
 	dst.RequestCount += src.RequestCount
 	dst.ProxiedRequestCount += src.ProxiedRequestCount
+	dst.ErrorCount += src.ErrorCount
 }
 
 // average reduces the aggregate stat from `sample` pods to an averaged one over
@@ -413,4 +633,5 @@ func (dst *Stat) average(sample, total float64) {
 	dst.AverageProxiedConcurrentRequests = dst.AverageProxiedConcurrentRequests / sample * total
 	dst.RequestCount = dst.RequestCount / sample * total
 	dst.ProxiedRequestCount = dst.ProxiedRequestCount / sample * total
+	dst.ErrorCount = dst.ErrorCount / sample * total
 }
@@ -19,6 +19,7 @@ package metrics
 import (
 	"errors"
 	"math"
+	"sync"
 	"testing"
 	"time"
 
@@ -313,6 +314,74 @@ func TestMetricCollectorScraper(t *testing.T) {
 	}
 }
 
+func TestMetricCollectorCustomMetric(t *testing.T) {
+	logger := TestLogger(t)
+
+	mtp := &fake.ManualTickProvider{
+		Channel: make(chan time.Time),
+	}
+	now := time.Now()
+	fc := fake.Clock{
+		FakeClock: clock.NewFakeClock(now),
+		TP:        mtp,
+	}
+	metricKey := types.NamespacedName{Namespace: defaultNamespace, Name: defaultName}
+	const (
+		reportCustom = 7
+		wantCustom   = 3 * 7 // In 3 seconds we'll scrape 3 times.
+	)
+	scraper := &testScraper{
+		s: func() (Stat, error) {
+			return Stat{PodName: "testPod", AverageConcurrentRequests: 1}, nil
+		},
+	}
+	customScraper := &testCustomScraper{
+		s: func() (float64, error) {
+			return reportCustom, nil
+		},
+	}
+
+	coll := NewMetricCollector(scraperFactory(scraper, nil), logger)
+	coll.ScrapeCustomMetrics(func(*autoscalingv1alpha1.Metric, *zap.SugaredLogger) (CustomMetricScraper, error) {
+		return customScraper, nil
+	})
+	coll.clock = fc
+	coll.CreateOrUpdate(&defaultMetric)
+
+	if _, _, err := coll.StableAndPanicCustom(metricKey, now); !errors.Is(err, ErrNoData) {
+		t.Errorf("StableAndPanicCustom() = %v, want: %v", err, ErrNoData)
+	}
+
+	for i := 0; i < 3; i++ {
+		mtp.Channel <- now
+	}
+	var gotCustom, panicCustom float64
+	if err := wait.PollImmediate(10*time.Millisecond, 2*time.Second, func() (bool, error) {
+		gotCustom, panicCustom, _ = coll.StableAndPanicCustom(metricKey, now)
+		return gotCustom == wantCustom && panicCustom == wantCustom, nil
+	}); err != nil {
+		t.Fatalf("StableAndPanicCustom() = %v, %v, want: %v, %v", gotCustom, panicCustom, wantCustom, wantCustom)
+	}
+
+	// Errors scraping the custom metric are surfaced as errors of the
+	// collection.
+	customErr := errors.New("custom metric unavailable")
+	customScraper.setFunc(func() (float64, error) {
+		return 0, customErr
+	})
+	mtp.Channel <- now
+	if err := wait.PollImmediate(10*time.Millisecond, 2*time.Second, func() (bool, error) {
+		return errors.Is(coll.CreateOrUpdate(&defaultMetric), customErr), nil
+	}); err != nil {
+		t.Error("CreateOrUpdate() never returned the error of the custom metric scraper")
+	}
+
+	coll.Delete(defaultNamespace, defaultName)
+	if _, _, err := coll.StableAndPanicCustom(metricKey, now); !errors.Is(err, ErrNotCollecting) {
+		t.Errorf("StableAndPanicCustom() = %v, want: %v", err, ErrNotCollecting)
+	}
+}
+
 func TestMetricCollectorNoScraper(t *testing.T) {
 	logger := TestLogger(t)
 
@@ -492,6 +561,67 @@ func TestMetricCollectorRecord(t *testing.T) {
 	}
 }
 
+func TestMetricCollectorActivatorQueueDepth(t *testing.T) {
+	now := time.Now()
+	metricKey := types.NamespacedName{Namespace: defaultNamespace, Name: defaultName}
+	coll := NewMetricCollector(scraperFactory(&testScraper{
+		s: func() (Stat, error) {
+			return emptyStat, nil
+		},
+	}, nil), TestLogger(t))
+	coll.clock = fake.Clock{
+		FakeClock: clock.NewFakeClock(now),
+		TP:        &fake.ManualTickProvider{Channel: make(chan time.Time)},
+	}
+
+	if _, err := coll.ActivatorQueueDepth(metricKey, now); !errors.Is(err, ErrNotCollecting) {
+		t.Errorf("ActivatorQueueDepth() = %v, want: %v", err, ErrNotCollecting)
+	}
+
+	coll.CreateOrUpdate(&defaultMetric)
+	if got, err := coll.ActivatorQueueDepth(metricKey, now); err != nil || got != 0 {
+		t.Errorf("ActivatorQueueDepth() = %v, %v, want: 0, nil", got, err)
+	}
+
+	// The requests queued in both of the activators add up.
+	coll.Record(metricKey, now, Stat{PodName: "activator-1", AverageConcurrentRequests: 5, ActivatorQueueDepth: 4})
+	coll.Record(metricKey, now, Stat{PodName: "activator-2", AverageConcurrentRequests: 5, ActivatorQueueDepth: 2})
+	if got, err := coll.ActivatorQueueDepth(metricKey, now); err != nil || got != 6 {
+		t.Errorf("ActivatorQueueDepth() = %v, %v, want: 6, nil", got, err)
+	}
+}
+
+func TestMetricCollectorErrorRatio(t *testing.T) {
+	now := time.Now()
+	metricKey := types.NamespacedName{Namespace: defaultNamespace, Name: defaultName}
+	coll := NewMetricCollector(scraperFactory(&testScraper{
+		s: func() (Stat, error) {
+			return emptyStat, nil
+		},
+	}, nil), TestLogger(t))
+	coll.clock = fake.Clock{
+		FakeClock: clock.NewFakeClock(now),
+		TP:        &fake.ManualTickProvider{Channel: make(chan time.Time)},
+	}
+
+	if _, err := coll.ErrorRatio(metricKey, now); !errors.Is(err, ErrNotCollecting) {
+		t.Errorf("ErrorRatio() = %v, want: %v", err, ErrNotCollecting)
+	}
+
+	coll.CreateOrUpdate(&defaultMetric)
+	if got, err := coll.ErrorRatio(metricKey, now); err != nil || got != 0 {
+		t.Errorf("ErrorRatio() = %v, %v, want: 0, nil", got, err)
+	}
+
+	// Half of the requests fail on the pods, the ones proxied by the
+	// activator being counted there.
+	coll.Record(metricKey, now, Stat{PodName: "activator", RequestCount: 10})
+	coll.Record(metricKey, now, Stat{PodName: scraperPodName, RequestCount: 30, ProxiedRequestCount: 10, ErrorCount: 15})
+	if got, err := coll.ErrorRatio(metricKey, now); err != nil || got != 0.5 {
+		t.Errorf("ErrorRatio() = %v, %v, want: 0.5, nil", got, err)
+	}
+}
+
 func TestDoubleWatch(t *testing.T) {
 	defer func() {
 		if x := recover(); x == nil {
@@ -592,6 +722,95 @@ func TestMetricCollectorError(t *testing.T) {
 	}
 }
 
+func TestMetricCollectorHistory(t *testing.T) {
+	const period = time.Hour
+	mtp := &fake.ManualTickProvider{
+		Channel: make(chan time.Time),
+	}
+	now := time.Unix(0, 0).Add(1000 * period)
+	fc := fake.Clock{
+		FakeClock: clock.NewFakeClock(now),
+		TP:        mtp,
+	}
+	metricKey := types.NamespacedName{Namespace: defaultNamespace, Name: defaultName}
+
+	persisted := NewHistory(period, 1)
+	persisted.Record(now.Add(-period), 42)
+	data, err := persisted.MarshalBinary()
+	if err != nil {
+		t.Fatal("MarshalBinary() =", err)
+	}
+	store := &testHistoryStore{data: map[string][]byte{defaultName: data}}
+
+	coll := NewMetricCollector(scraperFactory(nil, nil), TestLogger(t))
+	coll.clock = fc
+	coll.PersistHistory(store)
+	if _, err := coll.History(metricKey, period, 1); !errors.Is(err, ErrNotCollecting) {
+		t.Errorf("History() = %v, want: %v", err, ErrNotCollecting)
+	}
+	coll.CreateOrUpdate(&defaultMetric)
+	defer coll.Delete(defaultNamespace, defaultName)
+
+	// The persisted history is restored in the background.
+	if err := wait.PollImmediate(10*time.Millisecond, 2*time.Second, func() (bool, error) {
+		c := coll.collections[metricKey]
+		c.mux.RLock()
+		defer c.mux.RUnlock()
+		return c.history != nil, nil
+	}); err != nil {
+		t.Fatal("The history wasn't restored")
+	}
+	h, err := coll.History(metricKey, period, 1)
+	if err != nil {
+		t.Fatal("History() =", err)
+	}
+	if got, ok := h.Predict(now, 0); !ok || got != 42 {
+		t.Errorf("Predict() = %v, %v, want the persisted load: 42, true", got, ok)
+	}
+	// The same history is returned, unless asked for another period.
+	if got, _ := coll.History(metricKey, period, 1); got != h {
+		t.Error("History() replaced the history of the same period")
+	}
+	h, _ = coll.History(metricKey, 2*period, 1)
+	if got, ok := h.Predict(now, 0); ok {
+		t.Errorf("Predict() = %v after a change of the period, want no prediction", got)
+	}
+
+	// The history is persisted every historySaveInterval.
+	h.Record(now, 7)
+	fc.SetTime(now.Add(historySaveInterval))
+	mtp.Channel <- now.Add(historySaveInterval)
+	if err := wait.PollImmediate(10*time.Millisecond, 2*time.Second, func() (bool, error) {
+		saved, err := decodeHistory(store.get(defaultName))
+		return err == nil && saved.fits(2*period, 1), nil
+	}); err != nil {
+		t.Error("The history wasn't persisted")
+	}
+}
+
+// testHistoryStore keeps the histories in memory, by metric name.
+type testHistoryStore struct {
+	mux  sync.Mutex
+	data map[string][]byte
+}
+
+func (s *testHistoryStore) Load(metric *autoscalingv1alpha1.Metric) ([]byte, error) {
+	return s.get(metric.Name), nil
+}
+
+func (s *testHistoryStore) Save(metric *autoscalingv1alpha1.Metric, data []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.data[metric.Name] = data
+	return nil
+}
+
+func (s *testHistoryStore) get(name string) []byte {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.data[name]
+}
+
 func scraperFactory(scraper StatsScraper, err error) StatsScraperFactory {
 	return func(*autoscalingv1alpha1.Metric, *zap.SugaredLogger) (StatsScraper, error) {
 		return scraper, err
@@ -607,6 +826,23 @@ func (s *testScraper) Scrape(time.Duration) (Stat, error) {
 	return s.s()
 }
 
+type testCustomScraper struct {
+	mux sync.Mutex
+	s   func() (float64, error)
+}
+
+func (s *testCustomScraper) setFunc(f func() (float64, error)) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.s = f
+}
+
+func (s *testCustomScraper) Scrape(time.Duration) (float64, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.s()
+}
+
 func TestMetricCollectorAggregate(t *testing.T) {
 	m := defaultMetric
 	m.Spec.StableWindow = 6 * time.Second
@@ -617,6 +853,8 @@ func TestMetricCollectorAggregate(t *testing.T) {
 		concurrencyPanicBuckets: aggregation.NewTimedFloat64Buckets(m.Spec.PanicWindow, config.BucketSize),
 		rpsBuckets:              aggregation.NewTimedFloat64Buckets(m.Spec.StableWindow, config.BucketSize),
 		rpsPanicBuckets:         aggregation.NewTimedFloat64Buckets(m.Spec.PanicWindow, config.BucketSize),
+		activatorQueueBuckets:   aggregation.NewTimedFloat64Buckets(m.Spec.PanicWindow, config.BucketSize),
+		errorPanicBuckets:       aggregation.NewTimedFloat64Buckets(m.Spec.PanicWindow, config.BucketSize),
 	}
 	now := time.Now()
 	for i := time.Duration(0); i < 10; i++ {
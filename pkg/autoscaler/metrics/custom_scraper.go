@@ -0,0 +1,211 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"knative.dev/serving/pkg/apis/autoscaling"
+	autoscalingv1alpha1 "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
+	"knative.dev/serving/pkg/resources"
+)
+
+// errMetricNotFound is returned by the custom metric scraper when a pod does
+// not expose the metric.
+var errMetricNotFound = errors.New("the metric was not found")
+
+// CustomMetricScraper defines the interface for collecting the custom metric
+// the user containers of a Revision expose.
+type CustomMetricScraper interface {
+	// Scrape returns the total of the metric over the pods of the Revision.
+	// The duration is used to cutoff young pods, whose values might skew
+	// lower.
+	Scrape(time.Duration) (float64, error)
+}
+
+// CustomMetricScraperFactory creates a CustomMetricScraper for a given Metric.
+type CustomMetricScraperFactory func(*autoscalingv1alpha1.Metric, *zap.SugaredLogger) (CustomMetricScraper, error)
+
+// customMetricScraper scrapes the custom metric from the user containers
+// directly, by sampling the pods of the Revision.
+type customMetricScraper struct {
+	client *http.Client
+
+	name string
+	port string
+	path string
+	// average is whether the series of the metric of a pod are averaged
+	// rather than summed up, like the utilization of each of its GPUs.
+	average bool
+
+	podAccessor resources.PodAccessor
+	logger      *zap.SugaredLogger
+}
+
+// NewCustomMetricScraper creates a new CustomMetricScraper for the custom
+// metric configured by the annotations of the given Metric.
+func NewCustomMetricScraper(metric *autoscalingv1alpha1.Metric, podAccessor resources.PodAccessor,
+	logger *zap.SugaredLogger) CustomMetricScraper {
+	return newCustomMetricScraperWithClient(metric, podAccessor, client, logger)
+}
+
+func newCustomMetricScraperWithClient(metric *autoscalingv1alpha1.Metric, podAccessor resources.PodAccessor,
+	client *http.Client, logger *zap.SugaredLogger) *customMetricScraper {
+	s := &customMetricScraper{
+		client:      client,
+		path:        autoscaling.CustomMetricPathDefault,
+		podAccessor: podAccessor,
+		logger:      logger,
+	}
+	if metric.Annotations[autoscaling.MetricAnnotationKey] == autoscaling.GPU {
+		// The gpu metric is scraped from the DCGM exporter sidecar by default.
+		s.name = autoscaling.GPUMetricNameDefault
+		s.port = autoscaling.GPUMetricPortDefault
+		s.average = true
+	}
+	if n, ok := metric.Annotations[autoscaling.CustomMetricNameAnnotationKey]; ok {
+		s.name = n
+	}
+	if p, ok := metric.Annotations[autoscaling.CustomMetricPortAnnotationKey]; ok {
+		s.port = p
+	}
+	if p, ok := metric.Annotations[autoscaling.CustomMetricPathAnnotationKey]; ok {
+		s.path = p
+	}
+	return s
+}
+
+// Scrape scrapes a sample of the pods and extrapolates the total of the
+// metric from their average. The pods are scraped directly, as the metric
+// isn't exposed by the queue-proxy.
+func (s *customMetricScraper) Scrape(window time.Duration) (float64, error) {
+	pods, youngPods, err := s.podAccessor.PodIPsSplitByAge(window, time.Now())
+	if err != nil {
+		s.logger.Infow("Error querying pods by age", zap.Error(err))
+		return 0, err
+	}
+	total := len(pods) + len(youngPods)
+	if total == 0 {
+		return 0, nil
+	}
+
+	sampleSize := int(populationMeanSampleSize(float64(total)))
+	results := make(chan float64, sampleSize)
+
+	// Prefer the old pods, picking the ones to scrape at random, and fall back
+	// to the young ones.
+	rand.Shuffle(len(pods), func(i, j int) {
+		pods[i], pods[j] = pods[j], pods[i]
+	})
+	rand.Shuffle(len(youngPods), func(i, j int) {
+		youngPods[i], youngPods[j] = youngPods[j], youngPods[i]
+	})
+	pods = append(pods, youngPods...)
+
+	grp, egCtx := errgroup.WithContext(context.Background())
+	idx := atomic.NewInt32(-1)
+	for i := 0; i < sampleSize; i++ {
+		grp.Go(func() error {
+			for {
+				myIdx := int(idx.Inc())
+				if myIdx >= len(pods) {
+					return errPodsExhausted
+				}
+				value, err := s.scrapePod(egCtx, pods[myIdx])
+				if err == nil {
+					results <- value
+					return nil
+				}
+				s.logger.Infow("Failed scraping the custom metric of pod "+pods[myIdx], zap.Error(err))
+			}
+		})
+	}
+	if err := grp.Wait(); err != nil {
+		return 0, fmt.Errorf("unsuccessful scrape of the custom metric, sampleSize=%d: %w", sampleSize, err)
+	}
+	close(results)
+
+	var sum float64
+	for value := range results {
+		sum += value
+	}
+	return sum / float64(sampleSize) * float64(total), nil
+}
+
+// scrapePod returns the value of the metric exposed by the pod at ip, summed
+// or averaged over all of its series.
+func (s *customMetricScraper) scrapePod(ctx context.Context, ip string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+net.JoinHostPort(ip, s.port)+s.path, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", string(expfmt.FmtText))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return 0, fmt.Errorf("GET request for URL %q returned HTTP status %v", req.URL.String(), resp.StatusCode)
+	}
+
+	dec := expfmt.NewDecoder(resp.Body, expfmt.ResponseFormat(resp.Header))
+	for {
+		var mf dto.MetricFamily
+		if err := dec.Decode(&mf); err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, fmt.Errorf("%w: %s", errMetricNotFound, s.name)
+			}
+			return 0, fmt.Errorf("decoding the metrics failed: %w", err)
+		}
+		if mf.GetName() == s.name {
+			sum := sumMetricFamily(&mf)
+			if s.average && len(mf.GetMetric()) > 0 {
+				return sum / float64(len(mf.GetMetric())), nil
+			}
+			return sum, nil
+		}
+	}
+}
+
+// sumMetricFamily returns the sum of the values of the series of a gauge or
+// untyped metric.
+func sumMetricFamily(mf *dto.MetricFamily) float64 {
+	var sum float64
+	for _, m := range mf.GetMetric() {
+		switch {
+		case m.Gauge != nil:
+			sum += m.GetGauge().GetValue()
+		case m.Untyped != nil:
+			sum += m.GetUntyped().GetValue()
+		}
+	}
+	return sum
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakepodsinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/pod/fake"
+	logtesting "knative.dev/pkg/logging/testing"
+	pkgnetwork "knative.dev/pkg/network"
+	"knative.dev/serving/pkg/apis/autoscaling"
+	"knative.dev/serving/pkg/resources"
+
+	. "knative.dev/pkg/reconciler/testing"
+)
+
+func TestNewCustomMetricScraper(t *testing.T) {
+	metric := testMetric()
+	metric.Annotations = map[string]string{
+		autoscaling.MetricAnnotationKey:           autoscaling.Custom,
+		autoscaling.CustomMetricNameAnnotationKey: "jobs_in_flight",
+		autoscaling.CustomMetricPortAnnotationKey: "9090",
+	}
+	got, ok := NewCustomMetricScraper(metric, resources.PodAccessor{}, logtesting.TestLogger(t)).(*customMetricScraper)
+	if !ok {
+		t.Fatalf("NewCustomMetricScraper() = %T, want a *customMetricScraper", got)
+	}
+	if got.name != "jobs_in_flight" || got.port != "9090" || got.path != autoscaling.CustomMetricPathDefault {
+		t.Errorf("NewCustomMetricScraper() = {%s %s %s}, want: {jobs_in_flight 9090 %s}",
+			got.name, got.port, got.path, autoscaling.CustomMetricPathDefault)
+	}
+}
+
+func TestCustomMetricScraper(t *testing.T) {
+	// The metrics of the pods, by pod IP.
+	bodies := map[string]string{
+		"1.2.3.4:9090": "# TYPE jobs_in_flight gauge\njobs_in_flight{queue=\"a\"} 2\njobs_in_flight{queue=\"b\"} 1\nother 100\n",
+		"1.2.3.5:9090": "# TYPE jobs_in_flight gauge\njobs_in_flight 5\n",
+		"1.2.3.6:9090": "jobs_in_flight 4\n",
+	}
+
+	tests := []struct {
+		name    string
+		pods    int
+		bodies  map[string]string
+		want    float64
+		wantErr bool
+	}{{
+		name:   "all pods",
+		pods:   3,
+		bodies: bodies,
+		want:   3 + 5 + 4,
+	}, {
+		name: "no pods",
+	}, {
+		name: "metric not exposed",
+		pods: 1,
+		bodies: map[string]string{
+			"1.2.3.4:9090": "other 100\n",
+		},
+		wantErr: true,
+	}, {
+		name: "not enough pods answering",
+		pods: 3,
+		bodies: map[string]string{
+			"1.2.3.4:9090": bodies["1.2.3.4:9090"],
+		},
+		wantErr: true,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel, _ := SetupFakeContextWithCancel(t)
+			defer cancel()
+			makePods(ctx, "", tc.pods, metav1.NewTime(time.Now().Add(-time.Hour)))
+
+			client := &http.Client{
+				Transport: pkgnetwork.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					if got, want := req.URL.Path, "/stats"; got != want {
+						t.Errorf("Path = %q, want: %q", got, want)
+					}
+					if got, want := req.Header.Get("Accept"), string(expfmt.FmtText); got != want {
+						t.Errorf("Accept = %q, want: %q", got, want)
+					}
+					body, ok := tc.bodies[req.URL.Host]
+					if !ok {
+						return nil, errors.New("connection refused")
+					}
+					rec := httptest.NewRecorder()
+					rec.WriteString(body)
+					return rec.Result(), nil
+				}),
+			}
+
+			metric := testMetric()
+			metric.Annotations = map[string]string{
+				autoscaling.MetricAnnotationKey:           autoscaling.Custom,
+				autoscaling.CustomMetricNameAnnotationKey: "jobs_in_flight",
+				autoscaling.CustomMetricPortAnnotationKey: "9090",
+				autoscaling.CustomMetricPathAnnotationKey: "/stats",
+			}
+			accessor := resources.NewPodAccessor(fakepodsinformer.Get(ctx).Lister(), testNamespace, testRevision)
+			s := newCustomMetricScraperWithClient(metric, accessor, client, logtesting.TestLogger(t))
+
+			got, err := s.Scrape(time.Minute)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Scrape() = %v, wantErr: %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Scrape() = %v, want: %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGPUMetricScraper(t *testing.T) {
+	// The GPU utilization of the pods, as exposed by the DCGM exporter, by pod
+	// IP. The first pod has two GPUs.
+	bodies := map[string]string{
+		"1.2.3.4:9400": "# TYPE DCGM_FI_DEV_GPU_UTIL gauge\nDCGM_FI_DEV_GPU_UTIL{gpu=\"0\"} 80\nDCGM_FI_DEV_GPU_UTIL{gpu=\"1\"} 60\n",
+		"1.2.3.5:9400": "# TYPE DCGM_FI_DEV_GPU_UTIL gauge\nDCGM_FI_DEV_GPU_UTIL{gpu=\"0\"} 90\n",
+	}
+
+	ctx, cancel, _ := SetupFakeContextWithCancel(t)
+	defer cancel()
+	makePods(ctx, "", 2, metav1.NewTime(time.Now().Add(-time.Hour)))
+
+	client := &http.Client{
+		Transport: pkgnetwork.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if got, want := req.URL.Path, autoscaling.CustomMetricPathDefault; got != want {
+				t.Errorf("Path = %q, want: %q", got, want)
+			}
+			body, ok := bodies[req.URL.Host]
+			if !ok {
+				return nil, errors.New("connection refused")
+			}
+			rec := httptest.NewRecorder()
+			rec.WriteString(body)
+			return rec.Result(), nil
+		}),
+	}
+
+	metric := testMetric()
+	metric.Annotations = map[string]string{autoscaling.MetricAnnotationKey: autoscaling.GPU}
+	accessor := resources.NewPodAccessor(fakepodsinformer.Get(ctx).Lister(), testNamespace, testRevision)
+	s := newCustomMetricScraperWithClient(metric, accessor, client, logtesting.TestLogger(t))
+
+	got, err := s.Scrape(time.Minute)
+	if err != nil {
+		t.Fatal("Scrape() =", err)
+	}
+	// The utilization is averaged over the GPUs of each pod.
+	if want := 70.0 + 90; got != want {
+		t.Errorf("Scrape() = %v, want: %v", got, want)
+	}
+}
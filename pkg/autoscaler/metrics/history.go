@@ -0,0 +1,207 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math"
+	"sync"
+	"time"
+
+	autoscalingv1alpha1 "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
+)
+
+// historyGranularity is the resolution of the load history.
+const historyGranularity = time.Minute
+
+// HistoryStore persists the load history of the metrics across restarts of
+// the autoscaler.
+type HistoryStore interface {
+	// Load returns the history persisted for the metric, or nil if there's
+	// none.
+	Load(metric *autoscalingv1alpha1.Metric) ([]byte, error)
+	// Save persists the history of the metric.
+	Save(metric *autoscalingv1alpha1.Metric, data []byte) error
+}
+
+// History is the load history of a revision, which predicts the load at a
+// point in time to be the average of the loads observed at the same point of
+// the past seasons, e.g. at the same time on the previous days for a period
+// of 24h. For a single season that's the load at the same time yesterday.
+type History struct {
+	// mux guards the slots, as the history is recorded to by the decider and
+	// persisted by the collection.
+	mux     sync.RWMutex
+	period  time.Duration
+	seasons int
+	// slots hold the history of each historyGranularity of the period.
+	slots []historySlot
+}
+
+// historySlot holds the highest load observed in a slot of the period for
+// each of the tracked seasons, indexed by season modulo the tracked seasons.
+type historySlot struct {
+	loads []float64
+	// season is the season each of the loads was observed in, 0 if none was.
+	season []int64
+}
+
+// NewHistory creates a History for a load pattern that repeats every period,
+// averaging over the given number of past seasons.
+func NewHistory(period time.Duration, seasons int) *History {
+	slots := make([]historySlot, int(math.Ceil(float64(period)/float64(historyGranularity))))
+	for i := range slots {
+		slots[i] = historySlot{
+			loads:  make([]float64, seasons),
+			season: make([]int64, seasons),
+		}
+	}
+	return &History{
+		period:  period,
+		seasons: seasons,
+		slots:   slots,
+	}
+}
+
+// fits returns whether the history tracks the given period and seasons.
+func (h *History) fits(period time.Duration, seasons int) bool {
+	return h.period == period && h.seasons == seasons
+}
+
+// locate returns the season of t, counting from 1, and its slot.
+func (h *History) locate(t time.Time) (int64, *historySlot) {
+	nanos := t.UnixNano()
+	return nanos/int64(h.period) + 1, &h.slots[nanos%int64(h.period)/int64(historyGranularity)]
+}
+
+// Record records the load observed at the given time.
+func (h *History) Record(now time.Time, value float64) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	season, slot := h.locate(now)
+	i := season % int64(h.seasons)
+	if slot.season[i] != season {
+		slot.season[i] = season
+		slot.loads[i] = value
+	} else if value > slot.loads[i] {
+		slot.loads[i] = value
+	}
+}
+
+// Predict returns the highest load expected between now and now+ahead,
+// and whether there's any history to predict it from.
+func (h *History) Predict(now time.Time, ahead time.Duration) (float64, bool) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	var (
+		max   float64
+		found bool
+	)
+	for t := now; !t.After(now.Add(ahead)); t = t.Add(historyGranularity) {
+		if load, ok := h.predictAt(t); ok && (!found || load > max) {
+			max, found = load, true
+		}
+	}
+	return max, found
+}
+
+// predictAt returns the average of the loads observed in the slot of t in the
+// seasons before the one of t.
+func (h *History) predictAt(t time.Time) (float64, bool) {
+	season, slot := h.locate(t)
+	var (
+		sum float64
+		n   int
+	)
+	for i, s := range slot.season {
+		if s != 0 && s < season && s >= season-int64(h.seasons) {
+			sum += slot.loads[i]
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The history is encoded
+// as its period and seasons followed by the slots, gzipped as most slots of
+// revisions without traffic around the clock are empty.
+func (h *History) MarshalBinary() ([]byte, error) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := binary.Write(zw, binary.LittleEndian, []int64{int64(h.period), int64(h.seasons)}); err != nil {
+		return nil, err
+	}
+	for _, slot := range h.slots {
+		if err := binary.Write(zw, binary.LittleEndian, slot.season); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(zw, binary.LittleEndian, slot.loads); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeHistory decodes a History encoded by MarshalBinary.
+func decodeHistory(data []byte) (*History, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(raw)
+
+	header := make([]int64, 2)
+	if err := binary.Read(r, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+	period, seasons := time.Duration(header[0]), header[1]
+	// Each slot takes a season and a load of 8 bytes for every season.
+	if period < historyGranularity || seasons < 1 || seasons > int64(r.Len()) ||
+		float64(r.Len()) != math.Ceil(float64(period)/float64(historyGranularity))*float64(seasons)*16 {
+		return nil, errors.New("the history is malformed")
+	}
+
+	h := NewHistory(period, int(seasons))
+	for _, slot := range h.slots {
+		if err := binary.Read(r, binary.LittleEndian, slot.season); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, slot.loads); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	const period = time.Hour
+	// Start of a season, so the minutes below are minutes of the period.
+	start := time.Unix(0, 0).Add(1000 * period)
+	at := func(season int, minute int) time.Time {
+		return start.Add(time.Duration(season)*period + time.Duration(minute)*time.Minute)
+	}
+
+	p := NewHistory(period, 2)
+	if got, ok := p.Predict(at(0, 0), 10*time.Minute); ok {
+		t.Errorf("Predict() without history = %v, want no prediction", got)
+	}
+
+	// The highest value of a slot is kept.
+	p.Record(at(0, 30), 10)
+	p.Record(at(0, 30).Add(30*time.Second), 20)
+	p.Record(at(0, 30).Add(40*time.Second), 5)
+	p.Record(at(0, 35), 50)
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		ahead  time.Duration
+		want   float64
+		wantOK bool
+	}{{
+		name:  "same season",
+		now:   at(0, 25),
+		ahead: 10 * time.Minute,
+	}, {
+		name:  "before the history",
+		now:   at(1, 20),
+		ahead: 9 * time.Minute,
+	}, {
+		name:   "slot ahead",
+		now:    at(1, 25),
+		ahead:  5 * time.Minute,
+		want:   20,
+		wantOK: true,
+	}, {
+		name:   "highest slot ahead",
+		now:    at(1, 25),
+		ahead:  10 * time.Minute,
+		want:   50,
+		wantOK: true,
+	}, {
+		name:   "two seasons ago",
+		now:    at(2, 30),
+		want:   20,
+		wantOK: true,
+	}, {
+		name: "too long ago",
+		now:  at(3, 30),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := p.Predict(test.now, test.ahead)
+			if ok != test.wantOK || got != test.want {
+				t.Errorf("Predict() = %v, %v, want: %v, %v", got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+
+	// The seasons are averaged, once there are more of them.
+	p.Record(at(1, 30), 40)
+	if got, ok := p.Predict(at(2, 30), 0); !ok || got != 30 {
+		t.Errorf("Predict() = %v, %v, want the average: 30, true", got, ok)
+	}
+	// The oldest season is replaced by the latest.
+	p.Record(at(2, 30), 100)
+	if got, ok := p.Predict(at(3, 30), 0); !ok || got != 70 {
+		t.Errorf("Predict() = %v, %v, want the average of the last seasons: 70, true", got, ok)
+	}
+}
+
+func TestHistoryWrapsAround(t *testing.T) {
+	const period = time.Hour
+	start := time.Unix(0, 0).Add(1000 * period)
+
+	p := NewHistory(period, 1)
+	p.Record(start.Add(2*time.Minute), 42)
+
+	// Ahead of the end of the period comes the start of the next one.
+	if got, ok := p.Predict(start.Add(period-time.Minute), 5*time.Minute); !ok || got != 42 {
+		t.Errorf("Predict() = %v, %v, want: 42, true", got, ok)
+	}
+}
+
+func TestHistoryMarshal(t *testing.T) {
+	const period = time.Hour
+	start := time.Unix(0, 0).Add(1000 * period)
+
+	h := NewHistory(period, 2)
+	h.Record(start.Add(2*time.Minute), 42)
+	h.Record(start.Add(period+2*time.Minute), 21)
+	data, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal("MarshalBinary() =", err)
+	}
+
+	got, err := decodeHistory(data)
+	if err != nil {
+		t.Fatal("decodeHistory() =", err)
+	}
+	if !got.fits(period, 2) {
+		t.Errorf("Decoded period, seasons = %v, %d, want: %v, 2", got.period, got.seasons, period)
+	}
+	if load, ok := got.Predict(start.Add(2*period+2*time.Minute), 0); !ok || load != 31.5 {
+		t.Errorf("Predict() = %v, %v, want: 31.5, true", load, ok)
+	}
+
+	if _, err := decodeHistory(data[:len(data)/2]); err == nil {
+		t.Error("decodeHistory() = nil for a truncated history, want an error")
+	}
+	if _, err := decodeHistory([]byte("not a history")); err == nil {
+		t.Error("decodeHistory() = nil for garbage, want an error")
+	}
+}
@@ -18,6 +18,7 @@ package metrics
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
@@ -52,6 +53,9 @@ func newHTTPScrapeClient(httpClient *http.Client) *httpScrapeClient {
 
 func (c *httpScrapeClient) Do(req *http.Request) (Stat, error) {
 	req.Header.Add("Accept", network.ProtoAcceptContent)
+	// Setting this explicitly disables the transparent decompression of the
+	// transport, so we handle both compressed and uncompressed stats below.
+	req.Header.Add("Accept-Encoding", "gzip")
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return emptyStat, err
@@ -66,6 +70,14 @@ func (c *httpScrapeClient) Do(req *http.Request) (Stat, error) {
 	if resp.Header.Get("Content-Type") != network.ProtoAcceptContent {
 		return emptyStat, errUnsupportedMetricType
 	}
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return emptyStat, fmt.Errorf("reading compressed body failed: %w", err)
+		}
+		defer gz.Close()
+		return statFromProto(gz)
+	}
 	return statFromProto(resp.Body)
 }
 
@@ -18,6 +18,7 @@ package metrics
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
@@ -67,6 +68,46 @@ func TestHTTPScrapeClientScrapeHappyCaseWithOptionals(t *testing.T) {
 	}
 }
 
+func TestHTTPScrapeClientScrapeCompressed(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprint("compressed=", compress), func(t *testing.T) {
+			resp := makeProtoResponse(http.StatusOK, stat, network.ProtoAcceptContent)
+			if compress {
+				resp = makeCompressedProtoResponse(http.StatusOK, stat, network.ProtoAcceptContent)
+			}
+			hClient := newTestHTTPClient(resp, nil)
+			sClient := newHTTPScrapeClient(hClient)
+			req, err := http.NewRequest(http.MethodGet, testURL, nil)
+			if err != nil {
+				t.Fatalf("Failed to create a request: %v", err)
+			}
+			got, err := sClient.Do(req)
+			if err != nil {
+				t.Fatalf("Scrape = %v, want no error", err)
+			}
+			if !cmp.Equal(got, stat) {
+				t.Errorf("Scraped stat mismatch; diff(-want,+got):\n%s", cmp.Diff(stat, got))
+			}
+			if got, want := req.Header.Get("Accept-Encoding"), "gzip"; got != want {
+				t.Errorf("Accept-Encoding = %q, want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestHTTPScrapeClientScrapeCorruptCompressed(t *testing.T) {
+	resp := makeProtoResponse(http.StatusOK, stat, network.ProtoAcceptContent)
+	resp.Header.Set("Content-Encoding", "gzip")
+	sClient := newHTTPScrapeClient(newTestHTTPClient(resp, nil))
+	req, err := http.NewRequest(http.MethodGet, testURL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create a request: %v", err)
+	}
+	if _, err := sClient.Do(req); err == nil {
+		t.Error("Got no error for a corrupt compressed body")
+	}
+}
+
 func TestHTTPScrapeClientScrapeProtoErrorCases(t *testing.T) {
 	testCases := []struct {
 		name            string
@@ -130,6 +171,22 @@ func makeProtoResponse(statusCode int, stat Stat, contentType string) *http.Resp
 	return res
 }
 
+func makeCompressedProtoResponse(statusCode int, stat Stat, contentType string) *http.Response {
+	buffer, _ := stat.Marshal()
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	gw.Write(buffer)
+	gw.Close()
+	res := &http.Response{
+		StatusCode: statusCode,
+		Body:       io.NopCloser(&compressed),
+	}
+	res.Header = http.Header{}
+	res.Header.Set("Content-Type", contentType)
+	res.Header.Set("Content-Encoding", "gzip")
+	return res
+}
+
 type fakeRoundTripper struct {
 	response      *http.Response
 	responseError error
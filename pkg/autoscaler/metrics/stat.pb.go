@@ -20,9 +20,14 @@ limitations under the License.
 package metrics
 
 import (
+	context "context"
 	encoding_binary "encoding/binary"
 	fmt "fmt"
 	proto "github.com/gogo/protobuf/proto"
+	types "github.com/gogo/protobuf/types"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
 	io "io"
 	math "math"
 	math_bits "math/bits"
@@ -57,6 +62,13 @@ type Stat struct {
 	// Time/date that the stat was generated in seconds since
 	// 1970-01-01 00:00:00.000 UTC.
 	Timestamp int64 `protobuf:"varint,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Number of requests for the revision queued at the activator, waiting
+	// for capacity. Only set by activators.
+	ActivatorQueueDepth float64 `protobuf:"fixed64,8,opt,name=activator_queue_depth,json=activatorQueueDepth,proto3" json:"activator_queue_depth,omitempty"`
+	// Number of requests that failed with a 5xx status, including those that
+	// couldn't reach the user container, since last Stat (approximately
+	// errors per second). Only set by queue-proxies.
+	ErrorCount float64 `protobuf:"fixed64,9,opt,name=error_count,json=errorCount,proto3" json:"error_count,omitempty"`
 }
 
 func (m *Stat) Reset()         { *m = Stat{} }
@@ -141,6 +153,20 @@ func (m *Stat) GetTimestamp() int64 {
 	return 0
 }
 
+func (m *Stat) GetActivatorQueueDepth() float64 {
+	if m != nil {
+		return m.ActivatorQueueDepth
+	}
+	return 0
+}
+
+func (m *Stat) GetErrorCount() float64 {
+	if m != nil {
+		return m.ErrorCount
+	}
+	return 0
+}
+
 // WireStatMessage is a copy of the StatMessage Golang type, exploding the fields of
 // `types.NamespacedName` to make it compatible with protobufs.
 type WireStatMessage struct {
@@ -261,30 +287,152 @@ func init() {
 func init() { proto.RegisterFile("pkg/autoscaler/metrics/stat.proto", fileDescriptor_cf216df9f6fff44c) }
 
 var fileDescriptor_cf216df9f6fff44c = []byte{
-	// 359 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x92, 0x4f, 0x4b, 0xc3, 0x30,
-	0x18, 0xc6, 0x97, 0xad, 0xee, 0x4f, 0xe6, 0x54, 0x22, 0x42, 0x86, 0x52, 0xba, 0x0d, 0xa1, 0xa7,
-	0x0d, 0xa6, 0x67, 0x0f, 0xee, 0xe2, 0x65, 0x22, 0x15, 0xf1, 0x58, 0x62, 0xf6, 0x3a, 0x8a, 0xb6,
-	0x89, 0x49, 0x2a, 0x7e, 0x0c, 0x3f, 0x96, 0x27, 0xd9, 0xd1, 0xa3, 0x6c, 0x5f, 0x44, 0x1a, 0xb3,
-	0x4d, 0xc7, 0x4e, 0x0d, 0xcf, 0xfb, 0x7b, 0x9e, 0x17, 0x9e, 0xb7, 0xb8, 0x23, 0x9f, 0xa6, 0x03,
-	0x96, 0x1b, 0xa1, 0x39, 0x7b, 0x06, 0x35, 0x48, 0xc1, 0xa8, 0x84, 0xeb, 0x81, 0x36, 0xcc, 0xf4,
-	0xa5, 0x12, 0x46, 0x90, 0x9a, 0xd3, 0xba, 0x9f, 0x65, 0xec, 0xdd, 0x1a, 0x66, 0x48, 0x1b, 0xd7,
-	0xa5, 0x98, 0xc4, 0x19, 0x4b, 0x81, 0xa2, 0x00, 0x85, 0x8d, 0xa8, 0x26, 0xc5, 0xe4, 0x9a, 0xa5,
-	0x40, 0x2e, 0xf0, 0x31, 0x7b, 0x05, 0xc5, 0xa6, 0x10, 0x73, 0x91, 0xf1, 0x5c, 0x29, 0xc8, 0x4c,
-	0xac, 0xe0, 0x25, 0x07, 0x6d, 0x34, 0x2d, 0x07, 0x28, 0x44, 0x51, 0xdb, 0x21, 0xa3, 0x15, 0x11,
-	0x39, 0x80, 0x8c, 0x71, 0x6f, 0xe9, 0x97, 0x4a, 0xbc, 0x25, 0x30, 0xd9, 0x9a, 0x53, 0xb1, 0x39,
-	0x81, 0x43, 0x6f, 0x7e, 0xc9, 0x2d, 0x71, 0x3d, 0xdc, 0x72, 0x9e, 0x98, 0x8b, 0x3c, 0x33, 0xd4,
-	0xb3, 0xc6, 0x5d, 0x27, 0x8e, 0x0a, 0x8d, 0x0c, 0xf1, 0xd1, 0x72, 0xd7, 0x7f, 0x78, 0xc7, 0xc2,
-	0x87, 0x6e, 0x18, 0xfd, 0xf5, 0x9c, 0xe2, 0x3d, 0xa9, 0x04, 0x07, 0xad, 0xe3, 0x5c, 0x9a, 0x24,
-	0x05, 0x5a, 0xb5, 0x70, 0xcb, 0xa9, 0x77, 0x56, 0x24, 0x27, 0xb8, 0x51, 0x7c, 0xb5, 0x61, 0xa9,
-	0xa4, 0xb5, 0x00, 0x85, 0x95, 0x68, 0x2d, 0x74, 0x1f, 0xf1, 0xfe, 0x7d, 0xa2, 0xa0, 0xe8, 0x74,
-	0x0c, 0x5a, 0xb3, 0xa9, 0x35, 0x14, 0xb5, 0x6a, 0xc9, 0xf8, 0xb2, 0xdb, 0xb5, 0x40, 0x08, 0xf6,
-	0x6c, 0xe9, 0x65, 0x3b, 0xb0, 0x6f, 0xd2, 0xc1, 0x5e, 0x71, 0x2c, 0x5b, 0x49, 0x73, 0xd8, 0xea,
-	0xbb, 0x6b, 0xf5, 0x8b, 0xd4, 0xc8, 0x8e, 0xba, 0x57, 0xf8, 0x60, 0x63, 0x8f, 0x26, 0xe7, 0xb8,
-	0x9e, 0xba, 0x37, 0x45, 0x41, 0x25, 0x6c, 0x0e, 0xe9, 0xca, 0xba, 0x01, 0x47, 0x2b, 0xf2, 0x92,
-	0x7e, 0xcc, 0x7d, 0x34, 0x9b, 0xfb, 0xe8, 0x7b, 0xee, 0xa3, 0xf7, 0x85, 0x5f, 0x9a, 0x2d, 0xfc,
-	0xd2, 0xd7, 0xc2, 0x2f, 0x3d, 0x54, 0xed, 0xcf, 0x72, 0xf6, 0x13, 0x00, 0x00, 0xff, 0xff, 0x6d,
-	0xc3, 0xa5, 0x6a, 0x51, 0x02, 0x00, 0x00,
+	// 460 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x92, 0xcf, 0x6e, 0x13, 0x31,
+	0x10, 0xc6, 0xe3, 0x26, 0xe4, 0xcf, 0x84, 0x00, 0x32, 0x02, 0x39, 0x2d, 0x5a, 0xb6, 0xa9, 0x90,
+	0xf6, 0xb4, 0x8b, 0x02, 0x67, 0x90, 0x28, 0x48, 0x5c, 0x5a, 0xc1, 0x56, 0x88, 0xe3, 0xca, 0x75,
+	0xa6, 0xcb, 0x8a, 0x6e, 0xec, 0xda, 0xde, 0x08, 0xde, 0x82, 0x77, 0xe0, 0x65, 0x38, 0xf6, 0xc8,
+	0x11, 0x25, 0x2f, 0x82, 0xec, 0x38, 0x29, 0x54, 0xed, 0x69, 0xad, 0xef, 0xfb, 0x7d, 0x33, 0xa3,
+	0x9d, 0x81, 0x7d, 0xf5, 0xb5, 0xcc, 0x78, 0x63, 0xa5, 0x11, 0xfc, 0x1c, 0x75, 0x56, 0xa3, 0xd5,
+	0x95, 0x30, 0x99, 0xb1, 0xdc, 0xa6, 0x4a, 0x4b, 0x2b, 0x69, 0x2f, 0x68, 0xbb, 0x7b, 0xa5, 0x94,
+	0xe5, 0x39, 0x66, 0x5e, 0x3e, 0x6d, 0xce, 0x32, 0xac, 0x95, 0xfd, 0xbe, 0xa6, 0x26, 0x3f, 0xdb,
+	0xd0, 0x39, 0xb1, 0xdc, 0xd2, 0x31, 0xf4, 0x95, 0x9c, 0x15, 0x73, 0x5e, 0x23, 0x23, 0x31, 0x49,
+	0x06, 0x79, 0x4f, 0xc9, 0xd9, 0x31, 0xaf, 0x91, 0xbe, 0x82, 0x3d, 0xbe, 0x40, 0xcd, 0x4b, 0x2c,
+	0x84, 0x9c, 0x8b, 0x46, 0x6b, 0x9c, 0xdb, 0x42, 0xe3, 0x45, 0x83, 0xc6, 0x1a, 0xb6, 0x13, 0x93,
+	0x84, 0xe4, 0xe3, 0x80, 0x1c, 0x6e, 0x89, 0x3c, 0x00, 0xf4, 0x08, 0x0e, 0x36, 0x79, 0xa5, 0xe5,
+	0xb7, 0x0a, 0x67, 0x37, 0xd6, 0x69, 0xfb, 0x3a, 0x71, 0x40, 0x3f, 0xac, 0xc9, 0x1b, 0xca, 0x1d,
+	0xc0, 0x28, 0x64, 0x0a, 0x21, 0x9b, 0xb9, 0x65, 0x1d, 0x1f, 0xbc, 0x1b, 0xc4, 0x43, 0xa7, 0xd1,
+	0x29, 0x3c, 0xda, 0xf4, 0xfa, 0x1f, 0xbe, 0xe3, 0xe1, 0x87, 0xc1, 0xcc, 0xff, 0xcd, 0x3c, 0x83,
+	0x7b, 0x4a, 0x4b, 0x81, 0xc6, 0x14, 0x8d, 0xb2, 0x55, 0x8d, 0xac, 0xeb, 0xe1, 0x51, 0x50, 0x3f,
+	0x79, 0x91, 0x3e, 0x81, 0x81, 0xfb, 0x1a, 0xcb, 0x6b, 0xc5, 0x7a, 0x31, 0x49, 0xda, 0xf9, 0x95,
+	0xe0, 0x1a, 0x73, 0x61, 0xab, 0x05, 0xb7, 0x52, 0x17, 0x17, 0x0d, 0x36, 0x58, 0xcc, 0x50, 0xd9,
+	0x2f, 0xac, 0xbf, 0x6e, 0xbc, 0x35, 0x3f, 0x3a, 0xef, 0xad, 0xb3, 0xe8, 0x53, 0x18, 0xa2, 0xd6,
+	0x52, 0x87, 0x11, 0x07, 0x9e, 0x04, 0x2f, 0xf9, 0xc9, 0x26, 0x67, 0x70, 0xff, 0x73, 0xa5, 0xd1,
+	0x2d, 0xea, 0x08, 0x8d, 0xe1, 0xa5, 0x9f, 0xc2, 0xed, 0xca, 0x28, 0x2e, 0x36, 0x0b, 0xbb, 0x12,
+	0x28, 0x85, 0x8e, 0xdf, 0xe4, 0x8e, 0x37, 0xfc, 0x9b, 0xee, 0x43, 0xc7, 0x9d, 0x87, 0xff, 0xcf,
+	0xc3, 0xe9, 0x28, 0x0d, 0xf7, 0x91, 0xba, 0xaa, 0xb9, 0xb7, 0x26, 0xef, 0xe1, 0xc1, 0xb5, 0x3e,
+	0x86, 0xbe, 0x84, 0x7e, 0x1d, 0xde, 0x8c, 0xc4, 0xed, 0x64, 0x38, 0x65, 0xdb, 0xe8, 0x35, 0x38,
+	0xdf, 0x92, 0xd3, 0x63, 0x18, 0x3a, 0xe3, 0x04, 0xf5, 0xa2, 0x12, 0x48, 0x5f, 0x43, 0x37, 0x47,
+	0x25, 0xb5, 0xa5, 0xe3, 0xdb, 0xc2, 0x66, 0xf7, 0x71, 0xba, 0xbe, 0xd4, 0x74, 0x73, 0xa9, 0xe9,
+	0x3b, 0x77, 0xa9, 0x09, 0x79, 0x4e, 0xde, 0xb0, 0x5f, 0xcb, 0x88, 0x5c, 0x2e, 0x23, 0xf2, 0x67,
+	0x19, 0x91, 0x1f, 0xab, 0xa8, 0x75, 0xb9, 0x8a, 0x5a, 0xbf, 0x57, 0x51, 0xeb, 0xb4, 0xeb, 0xe9,
+	0x17, 0x7f, 0x07, 0x00, 0x6d, 0xbc, 0xc5, 0xfe, 0x13, 0x03, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// StatServiceClient is the client API for StatService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type StatServiceClient interface {
+	// Report streams stats to the autoscaler, which acknowledges every
+	// message once it accepted its stats.
+	Report(ctx context.Context, opts ...grpc.CallOption) (StatService_ReportClient, error)
+}
+
+type statServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewStatServiceClient(cc *grpc.ClientConn) StatServiceClient {
+	return &statServiceClient{cc}
+}
+
+func (c *statServiceClient) Report(ctx context.Context, opts ...grpc.CallOption) (StatService_ReportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_StatService_serviceDesc.Streams[0], "/metrics.StatService/Report", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &statServiceReportClient{stream}
+	return x, nil
+}
+
+type StatService_ReportClient interface {
+	Send(*WireStatMessages) error
+	Recv() (*types.Empty, error)
+	grpc.ClientStream
+}
+
+type statServiceReportClient struct {
+	grpc.ClientStream
+}
+
+func (x *statServiceReportClient) Send(m *WireStatMessages) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *statServiceReportClient) Recv() (*types.Empty, error) {
+	m := new(types.Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// StatServiceServer is the server API for StatService service.
+type StatServiceServer interface {
+	// Report streams stats to the autoscaler, which acknowledges every
+	// message once it accepted its stats.
+	Report(StatService_ReportServer) error
+}
+
+// UnimplementedStatServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedStatServiceServer struct {
+}
+
+func (*UnimplementedStatServiceServer) Report(srv StatService_ReportServer) error {
+	return status.Errorf(codes.Unimplemented, "method Report not implemented")
+}
+
+func RegisterStatServiceServer(s *grpc.Server, srv StatServiceServer) {
+	s.RegisterService(&_StatService_serviceDesc, srv)
+}
+
+func _StatService_Report_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StatServiceServer).Report(&statServiceReportServer{stream})
+}
+
+type StatService_ReportServer interface {
+	Send(*types.Empty) error
+	Recv() (*WireStatMessages, error)
+	grpc.ServerStream
+}
+
+type statServiceReportServer struct {
+	grpc.ServerStream
+}
+
+func (x *statServiceReportServer) Send(m *types.Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *statServiceReportServer) Recv() (*WireStatMessages, error) {
+	m := new(WireStatMessages)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _StatService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "metrics.StatService",
+	HandlerType: (*StatServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Report",
+			Handler:       _StatService_Report_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "pkg/autoscaler/metrics/stat.proto",
 }
 
 func (m *Stat) Marshal() (dAtA []byte, err error) {
@@ -307,6 +455,18 @@ func (m *Stat) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.ErrorCount != 0 {
+		i -= 8
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.ErrorCount))))
+		i--
+		dAtA[i] = 0x49
+	}
+	if m.ActivatorQueueDepth != 0 {
+		i -= 8
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.ActivatorQueueDepth))))
+		i--
+		dAtA[i] = 0x41
+	}
 	if m.Timestamp != 0 {
 		i = encodeVarintStat(dAtA, i, uint64(m.Timestamp))
 		i--
@@ -477,6 +637,12 @@ func (m *Stat) Size() (n int) {
 	if m.Timestamp != 0 {
 		n += 1 + sovStat(uint64(m.Timestamp))
 	}
+	if m.ActivatorQueueDepth != 0 {
+		n += 9
+	}
+	if m.ErrorCount != 0 {
+		n += 9
+	}
 	return n
 }
 
@@ -657,6 +823,28 @@ func (m *Stat) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 8:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ActivatorQueueDepth", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.ActivatorQueueDepth = float64(math.Float64frombits(v))
+		case 9:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ErrorCount", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.ErrorCount = float64(math.Float64frombits(v))
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStat(dAtA[iNdEx:])
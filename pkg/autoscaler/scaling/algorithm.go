@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaling
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"knative.dev/serving/pkg/apis/autoscaling"
+	"knative.dev/serving/pkg/autoscaler/metrics"
+	"knative.dev/serving/pkg/resources"
+)
+
+// AlgorithmFactory creates a UniScaler scaling a revision with an algorithm,
+// on the metrics of the metricClient.
+type AlgorithmFactory func(
+	reporterCtx context.Context,
+	namespace, revision string,
+	metricClient metrics.MetricClient,
+	podCounter resources.EndpointsCounter,
+	deciderSpec *DeciderSpec) UniScaler
+
+var (
+	algorithmsMux sync.RWMutex
+	algorithms    = map[string]AlgorithmFactory{}
+)
+
+func init() {
+	RegisterAlgorithm(autoscaling.ScalingAlgorithmWindow, New)
+}
+
+// RegisterAlgorithm registers the factory of the scaling algorithm with the
+// given name, to be selected by the ScalingAlgorithmAnnotationKey annotation.
+// It panics if an algorithm was already registered with the name.
+func RegisterAlgorithm(name string, factory AlgorithmFactory) {
+	algorithmsMux.Lock()
+	defer algorithmsMux.Unlock()
+	if _, ok := algorithms[name]; ok {
+		panic(fmt.Sprintf("scaling algorithm %q is already registered", name))
+	}
+	algorithms[name] = factory
+}
+
+// NewForAlgorithm creates a UniScaler scaling with the algorithm of the
+// DeciderSpec, the sliding window averaging one if none is given.
+func NewForAlgorithm(
+	reporterCtx context.Context,
+	namespace, revision string,
+	metricClient metrics.MetricClient,
+	podCounter resources.EndpointsCounter,
+	deciderSpec *DeciderSpec) (UniScaler, error) {
+	name := deciderSpec.Algorithm
+	if name == "" {
+		name = autoscaling.ScalingAlgorithmWindow
+	}
+
+	algorithmsMux.RLock()
+	factory, ok := algorithms[name]
+	algorithmsMux.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown scaling algorithm %q", name)
+	}
+	return factory(reporterCtx, namespace, revision, metricClient, podCounter, deciderSpec), nil
+}
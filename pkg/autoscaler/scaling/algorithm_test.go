@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaling
+
+import (
+	"context"
+	"testing"
+
+	"knative.dev/serving/pkg/apis/autoscaling"
+	"knative.dev/serving/pkg/autoscaler/metrics"
+	"knative.dev/serving/pkg/resources"
+)
+
+func TestNewForAlgorithm(t *testing.T) {
+	fake := &fakeUniScaler{}
+	RegisterAlgorithm("test-algorithm", func(context.Context, string, string, metrics.MetricClient,
+		resources.EndpointsCounter, *DeciderSpec) UniScaler {
+		return fake
+	})
+	defer func() {
+		algorithmsMux.Lock()
+		defer algorithmsMux.Unlock()
+		delete(algorithms, "test-algorithm")
+	}()
+
+	ctx := context.Background()
+	pc := &fakePodCounter{}
+	for _, algorithm := range []string{"", autoscaling.ScalingAlgorithmWindow} {
+		scaler, err := NewForAlgorithm(ctx, testNamespace, testRevision, &metricClient{}, pc,
+			&DeciderSpec{Algorithm: algorithm})
+		if err != nil {
+			t.Fatalf("NewForAlgorithm(%q) = %v", algorithm, err)
+		}
+		if _, ok := scaler.(*autoscaler); !ok {
+			t.Errorf("NewForAlgorithm(%q) = %T, want the window autoscaler", algorithm, scaler)
+		}
+	}
+
+	scaler, err := NewForAlgorithm(ctx, testNamespace, testRevision, &metricClient{}, pc,
+		&DeciderSpec{Algorithm: "test-algorithm"})
+	if err != nil {
+		t.Fatal("NewForAlgorithm(test-algorithm) =", err)
+	}
+	if scaler != fake {
+		t.Errorf("NewForAlgorithm(test-algorithm) = %v, want the registered one", scaler)
+	}
+
+	if _, err := NewForAlgorithm(ctx, testNamespace, testRevision, &metricClient{}, pc,
+		&DeciderSpec{Algorithm: "unknown"}); err == nil {
+		t.Error("NewForAlgorithm(unknown) = nil, want an error")
+	}
+}
+
+func TestRegisterAlgorithmTwice(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Registering an algorithm twice didn't panic")
+		}
+	}()
+	RegisterAlgorithm(autoscaling.ScalingAlgorithmWindow, New)
+}
@@ -49,17 +49,30 @@ type autoscaler struct {
 	podCounter   podCounter
 	reporterCtx  context.Context
 
-	// State in panic mode.
-	panicTime    time.Time
-	maxPanicPods int32
+	// State in panic mode. The panicTime is the last time the load was over
+	// the panic exit threshold, the panicStartTime when panic mode was
+	// entered.
+	panicTime      time.Time
+	panicStartTime time.Time
+	maxPanicPods   int32
 
 	// delayWindow is used to defer scale-down decisions until a time
 	// window has passed at the reduced concurrency.
 	delayWindow *max.TimeWindow
 
+	// State of the scale-down limit: the pods that may still be scaled down
+	// by, and the pod count decided on last, and when.
+	scaleDownBudget   float64
+	limitedPodCount   int32
+	limitedDecisionAt time.Time
+
 	// specMux guards the current DeciderSpec.
 	specMux     sync.RWMutex
 	deciderSpec *DeciderSpec
+
+	// explanationMux guards the explanation of the last scale decision.
+	explanationMux sync.RWMutex
+	explanation    *Explanation
 }
 
 // New creates a new instance of default autoscaler implementation.
@@ -119,11 +132,34 @@ func newAutoscaler(
 
 		delayWindow: delayWindow,
 
-		panicTime:    pt,
-		maxPanicPods: int32(curC),
+		panicTime:      pt,
+		panicStartTime: pt,
+		maxPanicPods:   int32(curC),
 	}
 }
 
+// Restore implements Restorer. The restored scale is kept like the one the
+// autoscaler starts panicking with, and recorded in the delay window so that
+// scaling down is still delayed.
+func (a *autoscaler) Restore(desiredScale int32, now time.Time) {
+	if desiredScale > 1 && desiredScale > a.maxPanicPods {
+		if a.panicTime.IsZero() {
+			a.panicTime = now
+			a.panicStartTime = now
+			pkgmetrics.Record(a.reporterCtx, panicM.M(1))
+		}
+		a.maxPanicPods = desiredScale
+	}
+	if a.delayWindow != nil {
+		a.delayWindow.Record(now, desiredScale)
+	}
+}
+
+// PanicStartTime implements PanicReporter.
+func (a *autoscaler) PanicStartTime() time.Time {
+	return a.panicStartTime
+}
+
 // Update reconfigures the UniScaler according to the DeciderSpec.
 func (a *autoscaler) Update(deciderSpec *DeciderSpec) {
 	a.specMux.Lock()
@@ -138,6 +174,12 @@ func (a *autoscaler) Update(deciderSpec *DeciderSpec) {
 // Scale is not thread safe in regards to panic state, but it's thread safe in
 // regards to acquiring the decider spec.
 func (a *autoscaler) Scale(logger *zap.SugaredLogger, now time.Time) ScaleResult {
+	// The latency is measured in wall-clock time, as now may be simulated.
+	start := time.Now()
+	defer func() {
+		pkgmetrics.Record(a.reporterCtx, decisionLatencyM.M(float64(time.Since(start))/float64(time.Millisecond)))
+	}()
+
 	desugared := logger.Desugar()
 	debugEnabled := desugared.Core().Enabled(zapcore.DebugLevel)
 
@@ -153,16 +195,7 @@ func (a *autoscaler) Scale(logger *zap.SugaredLogger, now time.Time) ScaleResult
 
 	metricKey := types.NamespacedName{Namespace: a.namespace, Name: a.revision}
 
-	metricName := spec.ScalingMetric
-	var observedStableValue, observedPanicValue float64
-	switch spec.ScalingMetric {
-	case autoscaling.RPS:
-		observedStableValue, observedPanicValue, err = a.metricClient.StableAndPanicRPS(metricKey, now)
-	default:
-		metricName = autoscaling.Concurrency // concurrency is used by default
-		observedStableValue, observedPanicValue, err = a.metricClient.StableAndPanicConcurrency(metricKey, now)
-	}
-
+	observedStableValue, observedPanicValue, metricName, err := a.observe(spec.ScalingMetric, metricKey, now, originalReadyPodsCount)
 	if err != nil {
 		if errors.Is(err, metrics.ErrNoData) {
 			logger.Debug("No data to scale on yet")
@@ -172,6 +205,45 @@ func (a *autoscaler) Scale(logger *zap.SugaredLogger, now time.Time) ScaleResult
 		return invalidSR
 	}
 
+	// A large step change in load takes the whole stable window to be reflected
+	// by the stable value. Scale on the panic value instead, which
+	// converges faster, until the stable value caught up with it.
+	stableScalingValue := observedStableValue
+	if spec.StepChangeThreshold > 0 && observedPanicValue > observedStableValue &&
+		observedPanicValue >= spec.StepChangeThreshold*observedStableValue {
+		if debugEnabled {
+			desugared.Debug(fmt.Sprintf("Step change detected: stable = %0.3f; panic = %0.3f, scaling on the panic value",
+				observedStableValue, observedPanicValue))
+		}
+		stableScalingValue = observedPanicValue
+	}
+
+	// Scale ahead of a load that's predicted from the history to exceed the
+	// observed one. The prediction is never scaled down to.
+	// The history is kept by the metric collector, so that it outlives the
+	// autoscaler.
+	predictedValue := -1.
+	if spec.PredictionPeriod > 0 {
+		if history, err := a.metricClient.History(metricKey, spec.PredictionPeriod, spec.PredictionSeasons); err != nil {
+			logger.Errorw("Failed to obtain the load history", zap.Error(err))
+		} else {
+			// The prediction for now, to be compared with the observed value.
+			// It's taken before recording now, which replaces the load of
+			// the oldest season.
+			if predicted, ok := history.Predict(now, 0); ok {
+				predictedValue = predicted
+			}
+			if predicted, ok := history.Predict(now, spec.PredictionLookahead); ok && predicted > stableScalingValue {
+				if debugEnabled {
+					desugared.Debug(fmt.Sprintf("Predicted value %0.3f within %v exceeds the observed %0.3f, scaling on the prediction",
+						predicted, spec.PredictionLookahead, stableScalingValue))
+				}
+				stableScalingValue = predicted
+			}
+			history.Record(now, observedStableValue)
+		}
+	}
+
 	// Make sure we don't get stuck with the same number of pods, if the scale up rate
 	// is too conservative and MaxScaleUp*RPC==RPC, so this permits us to grow at least by a single
 	// pod if we need to scale up.
@@ -184,7 +256,7 @@ func (a *autoscaler) Scale(logger *zap.SugaredLogger, now time.Time) ScaleResult
 		maxScaleDown = math.Floor(readyPodsCount / spec.MaxScaleDownRate)
 	}
 
-	dspc := math.Ceil(observedStableValue / spec.TargetValue)
+	dspc := math.Ceil(stableScalingValue / spec.TargetValue)
 	dppc := math.Ceil(observedPanicValue / spec.TargetValue)
 	if debugEnabled {
 		desugared.Debug(
@@ -194,24 +266,63 @@ func (a *autoscaler) Scale(logger *zap.SugaredLogger, now time.Time) ScaleResult
 				dspc, dppc, originalReadyPodsCount, maxScaleUp, maxScaleDown))
 	}
 
+	// Combine the pod counts recommended by the other metrics scaled on.
+	var metricPodCounts []MetricPodCount
+	if len(spec.CombinedMetrics) > 0 {
+		dspc, dppc, metricPodCounts = a.combineMetrics(logger, spec, metricKey, now, originalReadyPodsCount,
+			MetricPodCount{Metric: metricName, DesiredPodCount: int32(dspc)}, dppc)
+	}
+
+	// Requests queueing in the activators mean the ready pods can't take the
+	// load, whatever the concurrency observed on them. Add a pod per
+	// ActivatorQueueThreshold requests queued over the panic window.
+	var activatorQueueDepth float64
+	if spec.ActivatorQueueThreshold > 0 {
+		activatorQueueDepth, err = a.metricClient.ActivatorQueueDepth(metricKey, now)
+		if err != nil {
+			logger.Errorw("Failed to obtain the activator queue depth", zap.Error(err))
+		} else if activatorQueueDepth >= spec.ActivatorQueueThreshold {
+			queuePodCount := float64(originalReadyPodsCount) + math.Ceil(activatorQueueDepth/spec.ActivatorQueueThreshold)
+			if debugEnabled {
+				desugared.Debug(fmt.Sprintf("Activator queue depth = %0.3f, threshold = %0.3f, desired PodCount at least %0.0f",
+					activatorQueueDepth, spec.ActivatorQueueThreshold, queuePodCount))
+			}
+			dspc = math.Max(dspc, queuePodCount)
+			dppc = math.Max(dppc, queuePodCount)
+		}
+	}
+
 	// We want to keep desired pod count in the  [maxScaleDown, maxScaleUp] range.
 	desiredStablePodCount := int32(math.Min(math.Max(dspc, maxScaleDown), maxScaleUp))
 	desiredPanicPodCount := int32(math.Min(math.Max(dppc, maxScaleDown), maxScaleUp))
 
 	isOverPanicThreshold := dppc/readyPodsCount >= spec.PanicThreshold
+	// Panic mode is only left below the exit threshold, if it's lower, so
+	// that load oscillating around the panic threshold doesn't flap it.
+	isOverPanicExitThreshold := isOverPanicThreshold
+	if spec.PanicExitThreshold > 0 {
+		isOverPanicExitThreshold = dppc/readyPodsCount >= spec.PanicExitThreshold
+	}
+	panicCooldown := spec.StableWindow
+	if spec.PanicCooldown > 0 {
+		panicCooldown = spec.PanicCooldown
+	}
 
 	if a.panicTime.IsZero() && isOverPanicThreshold {
 		// Begin panicking when we cross the threshold in the panic window.
 		logger.Info("PANICKING.")
 		a.panicTime = now
+		a.panicStartTime = now
 		pkgmetrics.Record(a.reporterCtx, panicM.M(1))
-	} else if isOverPanicThreshold {
-		// If we're still over panic threshold right now — extend the panic window.
+	} else if !a.panicTime.IsZero() && isOverPanicExitThreshold {
+		// If we're still over panic exit threshold right now — extend the panic window.
 		a.panicTime = now
-	} else if !a.panicTime.IsZero() && !isOverPanicThreshold && a.panicTime.Add(spec.StableWindow).Before(now) {
-		// Stop panicking after the surge has made its way into the stable metric.
+	} else if !a.panicTime.IsZero() && a.panicTime.Add(panicCooldown).Before(now) {
+		// Stop panicking after the surge has made its way into the stable metric,
+		// or the cool-down passed.
 		logger.Info("Un-panicking.")
 		a.panicTime = time.Time{}
+		a.panicStartTime = time.Time{}
 		a.maxPanicPods = 0
 		pkgmetrics.Record(a.reporterCtx, panicM.M(0))
 	}
@@ -256,6 +367,17 @@ func (a *autoscaler) Scale(logger *zap.SugaredLogger, now time.Time) ScaleResult
 		}
 	}
 
+	// Limit how fast the revision is scaled down, if asked to, so that pods
+	// expensive to bring back aren't slashed after a dip in the load.
+	if limit := spec.MaxScaleDownPerMinute; limit.Value > 0 {
+		if limitedPodCount := a.limitScaleDown(limit, now, originalReadyPodsCount, desiredPodCount); limitedPodCount != desiredPodCount {
+			if debugEnabled {
+				desugared.Debug(fmt.Sprintf("Limiting scale down to %d, wanted %d", limitedPodCount, desiredPodCount))
+			}
+			desiredPodCount = limitedPodCount
+		}
+	}
+
 	// Compute excess burst capacity
 	//
 	// the excess burst capacity is based on panic value, since we don't want to
@@ -272,6 +394,21 @@ func (a *autoscaler) Scale(logger *zap.SugaredLogger, now time.Time) ScaleResult
 		excessBCF = math.Floor(totCap - spec.TargetBurstCapacity - observedPanicValue)
 	}
 
+	// The activator buffers and retries the requests failing on the pods,
+	// e.g. as they crash, so it's put back in their path, whatever the
+	// headroom, while ActivatorFallbackErrorRatio of them fail.
+	var errorRatio float64
+	if spec.ActivatorFallbackErrorRatio > 0 {
+		errorRatio, err = a.metricClient.ErrorRatio(metricKey, now)
+		if err != nil {
+			logger.Errorw("Failed to obtain the error ratio", zap.Error(err))
+		} else if errorRatio >= spec.ActivatorFallbackErrorRatio && excessBCF >= 0 {
+			logger.Infof("Error ratio = %0.3f, threshold = %0.3f, putting the activator in the request path",
+				errorRatio, spec.ActivatorFallbackErrorRatio)
+			excessBCF = -1
+		}
+	}
+
 	if debugEnabled {
 		desugared.Debug(fmt.Sprintf("PodCount=%d Total1PodCapacity=%0.3f ObsStableValue=%0.3f ObsPanicValue=%0.3f TargetBC=%0.3f ExcessBC=%0.3f",
 			originalReadyPodsCount, spec.TotalValue, observedStableValue,
@@ -287,6 +424,20 @@ func (a *autoscaler) Scale(logger *zap.SugaredLogger, now time.Time) ScaleResult
 			panicRPSM.M(observedStableValue),
 			targetRPSM.M(spec.TargetValue),
 		)
+		if predictedValue >= 0 {
+			pkgmetrics.Record(a.reporterCtx, predictedRPSM.M(predictedValue))
+		}
+	case autoscaling.Custom, autoscaling.GPU:
+		pkgmetrics.RecordBatch(a.reporterCtx,
+			excessBurstCapacityM.M(excessBCF),
+			desiredPodCountM.M(int64(desiredPodCount)),
+			stableCustomM.M(observedStableValue),
+			panicCustomM.M(observedPanicValue),
+			targetCustomM.M(spec.TargetValue),
+		)
+		if predictedValue >= 0 {
+			pkgmetrics.Record(a.reporterCtx, predictedCustomM.M(predictedValue))
+		}
 	default:
 		pkgmetrics.RecordBatch(a.reporterCtx,
 			excessBurstCapacityM.M(excessBCF),
@@ -295,13 +446,171 @@ func (a *autoscaler) Scale(logger *zap.SugaredLogger, now time.Time) ScaleResult
 			panicRequestConcurrencyM.M(observedPanicValue),
 			targetRequestConcurrencyM.M(spec.TargetValue),
 		)
+		if predictedValue >= 0 {
+			pkgmetrics.Record(a.reporterCtx, predictedRequestConcurrencyM.M(predictedValue))
+		}
 	}
 
+	explanation := &Explanation{
+		Time:                  now,
+		Metric:                metricName,
+		ObservedStableValue:   observedStableValue,
+		ObservedPanicValue:    observedPanicValue,
+		ActivatorQueueDepth:   activatorQueueDepth,
+		ErrorRatio:            errorRatio,
+		TargetValue:           spec.TargetValue,
+		TotalValue:            spec.TotalValue,
+		TargetBurstCapacity:   spec.TargetBurstCapacity,
+		ExcessBurstCapacity:   int32(excessBCF),
+		ReadyPodCount:         originalReadyPodsCount,
+		DesiredStablePodCount: desiredStablePodCount,
+		DesiredPanicPodCount:  desiredPanicPodCount,
+		MaxScaleUp:            int32(maxScaleUp),
+		MaxScaleDown:          int32(maxScaleDown),
+		MetricPodCounts:       metricPodCounts,
+		DesiredPodCount:       desiredPodCount,
+	}
+	if predictedValue >= 0 {
+		explanation.PredictedValue = &predictedValue
+	}
+	if !a.panicTime.IsZero() {
+		panicTime := a.panicTime
+		explanation.Panicking = true
+		explanation.PanicTime = &panicTime
+		explanation.MaxPanicPods = a.maxPanicPods
+	}
+	a.explanationMux.Lock()
+	a.explanation = explanation
+	a.explanationMux.Unlock()
+
 	return ScaleResult{
 		DesiredPodCount:     desiredPodCount,
 		ExcessBurstCapacity: int32(excessBCF),
 		ScaleValid:          true,
+		MetricPodCounts:     metricPodCounts,
+	}
+}
+
+// observe returns the stable and panic values of the metric, and the metric
+// they're the values of.
+func (a *autoscaler) observe(metric string, key types.NamespacedName, now time.Time,
+	readyPodsCount int) (float64, float64, string, error) {
+	switch metric {
+	case autoscaling.RPS:
+		stableValue, panicValue, err := a.metricClient.StableAndPanicRPS(key, now)
+		return stableValue, panicValue, metric, err
+	case autoscaling.Custom, autoscaling.GPU:
+		// There are no pods to expose the custom metric when scaled to zero,
+		// the revision is scaled from zero on the concurrency reported by the
+		// activator instead. The gpu metric is scraped as a custom one.
+		if readyPodsCount > 0 {
+			stableValue, panicValue, err := a.metricClient.StableAndPanicCustom(key, now)
+			return stableValue, panicValue, metric, err
+		}
 	}
+	// Concurrency is used by default.
+	stableValue, panicValue, err := a.metricClient.StableAndPanicConcurrency(key, now)
+	return stableValue, panicValue, autoscaling.Concurrency, err
+}
+
+// combineMetrics combines the stable pod count recommended by the
+// ScalingMetric and its panic pod count with those of the CombinedMetrics, as
+// given by the MetricCombination. It returns the combined pod counts and the
+// stable pod count recommended by each of the metrics.
+func (a *autoscaler) combineMetrics(logger *zap.SugaredLogger, spec *DeciderSpec, key types.NamespacedName,
+	now time.Time, readyPodsCount int, scalingMetric MetricPodCount, dppc float64) (float64, float64, []MetricPodCount) {
+	podCounts := []MetricPodCount{scalingMetric}
+	stablePodCounts := []float64{float64(scalingMetric.DesiredPodCount)}
+	panicPodCounts := []float64{dppc}
+	weights := []float64{spec.ScalingMetricWeight}
+	for _, m := range spec.CombinedMetrics {
+		observedStable, observedPanic, metricName, err := a.observe(m.Metric, key, now, readyPodsCount)
+		if err != nil {
+			// The metric is left out until there is data to scale on.
+			logger.Debugw("No data to scale on yet for combined metric "+m.Metric, zap.Error(err))
+			continue
+		}
+		if metricName != m.Metric {
+			// The custom metric is not observed without ready pods to
+			// scrape it from.
+			continue
+		}
+		mspc, mppc := math.Ceil(observedStable/m.TargetValue), math.Ceil(observedPanic/m.TargetValue)
+		logger.Debugf("For combined metric %s observed values: stable = %0.3f; panic = %0.3f; target = %0.3f "+
+			"Desired StablePodCount = %0.0f, PanicPodCount = %0.0f",
+			metricName, observedStable, observedPanic, m.TargetValue, mspc, mppc)
+		podCounts = append(podCounts, MetricPodCount{Metric: m.Metric, DesiredPodCount: int32(mspc)})
+		stablePodCounts = append(stablePodCounts, mspc)
+		panicPodCounts = append(panicPodCounts, mppc)
+		weights = append(weights, m.Weight)
+	}
+
+	dspc, dppc := combinePodCounts(spec.MetricCombination, stablePodCounts, weights),
+		combinePodCounts(spec.MetricCombination, panicPodCounts, weights)
+	logger.Debugf("Combined the pod counts %v by %s: StablePodCount = %0.0f, PanicPodCount = %0.0f",
+		podCounts, spec.MetricCombination, dspc, dppc)
+	return dspc, dppc, podCounts
+}
+
+// combinePodCounts combines the pod counts the metrics recommend, as given by
+// the combination.
+func combinePodCounts(combination string, podCounts, weights []float64) float64 {
+	ret := podCounts[0]
+	switch combination {
+	case autoscaling.MetricCombinationMin:
+		for _, pc := range podCounts[1:] {
+			ret = math.Min(ret, pc)
+		}
+	case autoscaling.MetricCombinationWeighted:
+		var sum, total float64
+		for i, pc := range podCounts {
+			sum += pc * weights[i]
+			total += weights[i]
+		}
+		if total > 0 {
+			ret = math.Ceil(sum / total)
+		}
+	default:
+		for _, pc := range podCounts[1:] {
+			ret = math.Max(ret, pc)
+		}
+	}
+	return ret
+}
+
+// limitScaleDown returns the desired pod count, limited to scaling down from
+// the pod count decided on last by as many pods as the limit allows. The pods
+// to scale down by accrue over time, up to a minute's worth or a single pod,
+// whichever is more, so that limits below a pod per minute still scale down.
+func (a *autoscaler) limitScaleDown(limit autoscaling.ScaleDownLimit, now time.Time,
+	readyPodsCount int, desiredPodCount int32) int32 {
+	from := a.limitedPodCount
+	if a.limitedDecisionAt.IsZero() {
+		from = int32(readyPodsCount)
+	}
+	perMinute := limit.PodsPerMinute(float64(from))
+	if a.limitedDecisionAt.IsZero() {
+		a.scaleDownBudget = perMinute
+	} else {
+		a.scaleDownBudget = math.Min(math.Max(perMinute, 1),
+			a.scaleDownBudget+perMinute*now.Sub(a.limitedDecisionAt).Minutes())
+	}
+
+	if maxDown := int32(math.Floor(a.scaleDownBudget)); from-desiredPodCount > maxDown {
+		desiredPodCount = from - maxDown
+	}
+	if desiredPodCount < from {
+		a.scaleDownBudget -= float64(from - desiredPodCount)
+	}
+	a.limitedPodCount, a.limitedDecisionAt = desiredPodCount, now
+	return desiredPodCount
+}
+
+// Explain returns the explanation of the last scale decision.
+func (a *autoscaler) Explain() *Explanation {
+	a.explanationMux.RLock()
+	defer a.explanationMux.RUnlock()
+	return a.explanation
 }
 
 func (a *autoscaler) currentSpec() *DeciderSpec {
@@ -25,6 +25,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"go.opencensus.io/resource"
+	"go.uber.org/zap"
 
 	"k8s.io/apimachinery/pkg/types"
 
@@ -32,6 +33,7 @@ import (
 	servingmetrics "knative.dev/serving/pkg/metrics"
 
 	logtesting "knative.dev/pkg/logging/testing"
+	"knative.dev/serving/pkg/apis/autoscaling"
 	"knative.dev/serving/pkg/autoscaler/metrics"
 	"knative.dev/serving/pkg/resources"
 
@@ -165,7 +167,7 @@ func TestAutoscalerNoDataNoAutoscale(t *testing.T) {
 	}
 
 	a := newTestAutoscalerNoPC(10, 100, metrics)
-	expectScale(t, a, time.Now(), ScaleResult{0, 0, false})
+	expectScale(t, a, time.Now(), ScaleResult{0, 0, false, nil})
 }
 
 func expectedEBC(totCap, targetBC, recordedConcurrency, numPods float64) int32 {
@@ -190,7 +192,7 @@ func TestAutoscalerMetrics(t *testing.T) {
 	// Non-panic created autoscaler.
 	metricstest.AssertMetric(t, metricstest.IntMetric(panicM.Name(), 0, nil).WithResource(wantResource))
 	ebc := expectedEBC(10, 100, 50, 1)
-	expectScale(t, a, time.Now(), ScaleResult{5, ebc, true})
+	expectScale(t, a, time.Now(), ScaleResult{5, ebc, true, nil})
 	spec := a.currentSpec()
 
 	wantMetrics := []metricstest.Metric{
@@ -204,15 +206,33 @@ func TestAutoscalerMetrics(t *testing.T) {
 	metricstest.AssertMetric(t, wantMetrics...)
 }
 
+func TestAutoscalerDecisionLatency(t *testing.T) {
+	defer reset()
+
+	metrics := &metricClient{StableConcurrency: 50.0, PanicConcurrency: 50.0}
+	a := newTestAutoscalerNoPC(10, 100, metrics)
+	metricstest.AssertNoMetric(t, decisionLatencyM.Name())
+
+	expectScale(t, a, time.Now(), ScaleResult{5, expectedEBC(10, 100, 50, 1), true, nil})
+	metricstest.AssertMetric(t, metricstest.DistributionCountOnlyMetric(decisionLatencyM.Name(), 1, nil).WithResource(wantResource))
+
+	// Decisions without data to scale on take time too.
+	metrics.ErrF = func(types.NamespacedName, time.Time) error {
+		return errors.New("no metrics")
+	}
+	expectScale(t, a, time.Now(), ScaleResult{0, 0, false, nil})
+	metricstest.AssertMetric(t, metricstest.DistributionCountOnlyMetric(decisionLatencyM.Name(), 2, nil).WithResource(wantResource))
+}
+
 func TestAutoscalerMetricsWithRPS(t *testing.T) {
 	defer reset()
 	metrics := &metricClient{PanicRPS: 99.0, StableRPS: 100}
 	a, _ := newTestAutoscalerWithScalingMetric(10, 100, metrics, "rps", false /*startInPanic*/)
 	ebc := expectedEBC(10, 100, 99, 1)
-	expectScale(t, a, time.Now(), ScaleResult{10, ebc, true})
+	expectScale(t, a, time.Now(), ScaleResult{10, ebc, true, nil})
 	spec := a.currentSpec()
 
-	expectScale(t, a, time.Now().Add(61*time.Second), ScaleResult{10, ebc, true})
+	expectScale(t, a, time.Now().Add(61*time.Second), ScaleResult{10, ebc, true, nil})
 	wantMetrics := []metricstest.Metric{
 		metricstest.FloatMetric(stableRPSM.Name(), 100, nil).WithResource(wantResource),
 		metricstest.FloatMetric(panicRPSM.Name(), 100, nil).WithResource(wantResource),
@@ -227,20 +247,298 @@ func TestAutoscalerMetricsWithRPS(t *testing.T) {
 func TestAutoscalerStableModeIncreaseWithConcurrencyDefault(t *testing.T) {
 	metrics := &metricClient{StableConcurrency: 50.0, PanicConcurrency: 10}
 	a := newTestAutoscalerNoPC(10, 101, metrics)
-	expectScale(t, a, time.Now(), ScaleResult{5, expectedEBC(10, 101, 10, 1), true})
+	expectScale(t, a, time.Now(), ScaleResult{5, expectedEBC(10, 101, 10, 1), true, nil})
 
 	metrics.StableConcurrency = 100
-	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 101, 10, 1), true})
+	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 101, 10, 1), true, nil})
 }
 
 func TestAutoscalerStableModeIncreaseWithRPS(t *testing.T) {
 	metrics := &metricClient{StableRPS: 50.0, PanicRPS: 50}
 	a, _ := newTestAutoscalerWithScalingMetric(10, 101, metrics, "rps", false /*startInPanic*/)
-	expectScale(t, a, time.Now(), ScaleResult{5, expectedEBC(10, 101, 50, 1), true})
+	expectScale(t, a, time.Now(), ScaleResult{5, expectedEBC(10, 101, 50, 1), true, nil})
 
 	metrics.StableRPS = 100
 	metrics.PanicRPS = 99
-	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 101, 99, 1), true})
+	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 101, 99, 1), true, nil})
+}
+
+func TestAutoscalerMetricsWithCustom(t *testing.T) {
+	defer reset()
+	metrics := &metricClient{StableCustom: 50, PanicCustom: 40, StableConcurrency: 100}
+	a, _ := newTestAutoscalerWithScalingMetric(10, 101, metrics, "custom", false /*startInPanic*/)
+	ebc := expectedEBC(10, 101, 40, 1)
+	expectScale(t, a, time.Now(), ScaleResult{5, ebc, true, nil})
+
+	wantMetrics := []metricstest.Metric{
+		metricstest.FloatMetric(stableCustomM.Name(), 50, nil).WithResource(wantResource),
+		metricstest.FloatMetric(panicCustomM.Name(), 40, nil).WithResource(wantResource),
+		metricstest.IntMetric(desiredPodCountM.Name(), 5, nil).WithResource(wantResource),
+		metricstest.FloatMetric(targetCustomM.Name(), a.currentSpec().TargetValue, nil).WithResource(wantResource),
+		metricstest.FloatMetric(excessBurstCapacityM.Name(), float64(ebc), nil).WithResource(wantResource),
+	}
+	metricstest.AssertMetric(t, wantMetrics...)
+}
+
+func TestAutoscalerMetricsWithGPU(t *testing.T) {
+	defer reset()
+	// Two pods worth of GPU utilization at the 70% target.
+	metrics := &metricClient{StableCustom: 140, PanicCustom: 140, StableConcurrency: 1}
+	a, _ := newTestAutoscalerWithScalingMetric(70, 100, metrics, autoscaling.GPU, false /*startInPanic*/)
+	ebc := expectedEBC(70, 100, 140, 1)
+	expectScale(t, a, time.Now(), ScaleResult{2, ebc, true, nil})
+
+	wantMetrics := []metricstest.Metric{
+		metricstest.FloatMetric(stableCustomM.Name(), 140, nil).WithResource(wantResource),
+		metricstest.FloatMetric(panicCustomM.Name(), 140, nil).WithResource(wantResource),
+		metricstest.IntMetric(desiredPodCountM.Name(), 2, nil).WithResource(wantResource),
+		metricstest.FloatMetric(targetCustomM.Name(), 70, nil).WithResource(wantResource),
+		metricstest.FloatMetric(excessBurstCapacityM.Name(), float64(ebc), nil).WithResource(wantResource),
+	}
+	metricstest.AssertMetric(t, wantMetrics...)
+}
+
+func TestAutoscalerCustomFromZero(t *testing.T) {
+	metrics := &metricClient{StableConcurrency: 1, PanicConcurrency: 1}
+	a, pc := newTestAutoscalerWithScalingMetric(10, 101, metrics, "custom", false /*startInPanic*/)
+	pc.readyCount = 0
+
+	// Scaled from zero on the concurrency, as there are no pods to expose
+	// the custom metric.
+	expectScale(t, a, time.Now(), ScaleResult{1, expectedEBC(10, 101, 1, 0), true, nil})
+
+	// And on the custom metric once there are.
+	pc.readyCount = 1
+	expectScale(t, a, time.Now(), ScaleResult{0, expectedEBC(10, 101, 0, 1), true, nil})
+}
+
+func TestAutoscalerCombinedMetrics(t *testing.T) {
+	// 5 pods for the concurrency, 8 for the RPS and 3 for the custom metric.
+	metrics := &metricClient{
+		StableConcurrency: 50,
+		PanicConcurrency:  50,
+		StableRPS:         40,
+		PanicRPS:          40,
+		StableCustom:      6,
+		PanicCustom:       6,
+	}
+	combined := []MetricTarget{{
+		Metric:      autoscaling.RPS,
+		TargetValue: 5,
+		Weight:      2,
+	}, {
+		Metric:      autoscaling.Custom,
+		TargetValue: 2,
+		Weight:      1,
+	}}
+	allPodCounts := []MetricPodCount{{
+		Metric:          autoscaling.Concurrency,
+		DesiredPodCount: 5,
+	}, {
+		Metric:          autoscaling.RPS,
+		DesiredPodCount: 8,
+	}, {
+		Metric:          autoscaling.Custom,
+		DesiredPodCount: 3,
+	}}
+
+	tests := []struct {
+		name          string
+		combination   string
+		readyPods     int
+		wantScale     int32
+		wantPodCounts []MetricPodCount
+	}{{
+		name:          "max",
+		combination:   autoscaling.MetricCombinationMax,
+		readyPods:     5,
+		wantScale:     8,
+		wantPodCounts: allPodCounts,
+	}, {
+		name:          "min",
+		combination:   autoscaling.MetricCombinationMin,
+		readyPods:     5,
+		wantScale:     3,
+		wantPodCounts: allPodCounts,
+	}, {
+		name:        "weighted",
+		combination: autoscaling.MetricCombinationWeighted,
+		readyPods:   5,
+		// (5*1 + 8*2 + 3*1) / 4.
+		wantScale:     6,
+		wantPodCounts: allPodCounts,
+	}, {
+		name:        "no pods to scrape the custom metric from",
+		combination: autoscaling.MetricCombinationMax,
+		wantScale:   8,
+		wantPodCounts: []MetricPodCount{{
+			Metric:          autoscaling.Concurrency,
+			DesiredPodCount: 5,
+		}, {
+			Metric:          autoscaling.RPS,
+			DesiredPodCount: 8,
+		}},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a, pc := newTestAutoscaler(10, 101, metrics)
+			pc.readyCount = tc.readyPods
+			spec := *a.currentSpec()
+			spec.CombinedMetrics = combined
+			spec.MetricCombination = tc.combination
+			spec.ScalingMetricWeight = 1
+			a.Update(&spec)
+
+			got := a.Scale(logtesting.TestLogger(t), time.Now())
+			if got.DesiredPodCount != tc.wantScale {
+				t.Errorf("DesiredPodCount = %d, want: %d", got.DesiredPodCount, tc.wantScale)
+			}
+			if !cmp.Equal(got.MetricPodCounts, tc.wantPodCounts) {
+				t.Error("MetricPodCounts mismatch (-want,+got):\n", cmp.Diff(tc.wantPodCounts, got.MetricPodCounts))
+			}
+		})
+	}
+}
+
+func TestAutoscalerActivatorQueue(t *testing.T) {
+	tests := []struct {
+		name       string
+		threshold  float64
+		queueDepth float64
+		wantScale  int32
+	}{{
+		name:       "disabled",
+		queueDepth: 100,
+		wantScale:  5,
+	}, {
+		name:      "nothing queued",
+		threshold: 10,
+		wantScale: 5,
+	}, {
+		name:       "queued below the threshold",
+		threshold:  10,
+		queueDepth: 9,
+		wantScale:  5,
+	}, {
+		name:       "queued above the threshold",
+		threshold:  10,
+		queueDepth: 25,
+		wantScale:  5 + 3,
+	}, {
+		name:       "queue exceeds the concurrency",
+		threshold:  10,
+		queueDepth: 100,
+		wantScale:  5 + 10,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// 5 pods for the concurrency observed on the pods.
+			metrics := &metricClient{StableConcurrency: 50, PanicConcurrency: 50, ActivatorQueue: tc.queueDepth}
+			a, pc := newTestAutoscaler(10, 101, metrics)
+			pc.readyCount = 5
+			spec := *a.currentSpec()
+			spec.ActivatorQueueThreshold = tc.threshold
+			a.Update(&spec)
+
+			if got := a.Scale(logtesting.TestLogger(t), time.Now()).DesiredPodCount; got != tc.wantScale {
+				t.Errorf("DesiredPodCount = %d, want: %d", got, tc.wantScale)
+			}
+		})
+	}
+}
+
+func TestAutoscalerActivatorFallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		threshold  float64
+		errorRatio float64
+		wantEBC    int32
+	}{{
+		name:       "disabled",
+		errorRatio: 1,
+		wantEBC:    6,
+	}, {
+		name:      "no errors",
+		threshold: 0.5,
+		wantEBC:   6,
+	}, {
+		name:       "errors below the threshold",
+		threshold:  0.5,
+		errorRatio: 0.2,
+		wantEBC:    6,
+	}, {
+		name:       "errors above the threshold",
+		threshold:  0.5,
+		errorRatio: 0.8,
+		wantEBC:    -1,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// 5 pods with a headroom of 6 requests over the burst capacity.
+			metrics := &metricClient{StableConcurrency: 50, PanicConcurrency: 50, FailureRatio: tc.errorRatio}
+			a, pc := newTestAutoscaler(10, 10, metrics)
+			pc.readyCount = 5
+			spec := *a.currentSpec()
+			spec.ActivatorFallbackErrorRatio = tc.threshold
+			a.Update(&spec)
+
+			got := a.Scale(logtesting.TestLogger(t), time.Now())
+			if got.ExcessBurstCapacity != tc.wantEBC {
+				t.Errorf("ExcessBurstCapacity = %d, want: %d", got.ExcessBurstCapacity, tc.wantEBC)
+			}
+			if got.DesiredPodCount != 5 {
+				t.Errorf("DesiredPodCount = %d, want: 5", got.DesiredPodCount)
+			}
+		})
+	}
+}
+
+func TestAutoscalerMaxScaleDownPerMinute(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit autoscaling.ScaleDownLimit
+		// The desired scale after each of the ticks, a tick every 20s.
+		wantScales []int32
+	}{{
+		name:       "no limit",
+		wantScales: []int32{5, 5, 5, 5},
+	}, {
+		name:       "pods",
+		limit:      autoscaling.ScaleDownLimit{Value: 6},
+		wantScales: []int32{44, 42, 40, 38},
+	}, {
+		name:       "fraction of a pod",
+		limit:      autoscaling.ScaleDownLimit{Value: 0.4},
+		wantScales: []int32{50, 50, 50, 50, 50, 49},
+	}, {
+		name:       "percentage",
+		limit:      autoscaling.ScaleDownLimit{Value: 10, Percent: true},
+		wantScales: []int32{45, 44, 43, 41},
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// 5 pods for the observed concurrency.
+			metrics := &metricClient{StableConcurrency: 50, PanicConcurrency: 50}
+			a, pc := newTestAutoscaler(10, 101, metrics)
+			pc.readyCount = 50
+			spec := *a.currentSpec()
+			spec.MaxScaleDownRate = 100
+			spec.MaxScaleDownPerMinute = tc.limit
+			a.Update(&spec)
+
+			now := time.Now()
+			for i, want := range tc.wantScales {
+				if got := a.Scale(logtesting.TestLogger(t), now).DesiredPodCount; got != want {
+					t.Errorf("Tick %d: DesiredPodCount = %d, want: %d", i, got, want)
+				}
+				now = now.Add(20 * time.Second)
+			}
+		})
+	}
 }
 
 func TestAutoscalerUnpanicAfterSlowIncrease(t *testing.T) {
@@ -251,7 +549,7 @@ func TestAutoscalerUnpanicAfterSlowIncrease(t *testing.T) {
 
 	start := time.Now()
 	tm := start
-	expectScale(t, a, tm, ScaleResult{25, expectedEBC(1, 98, 25, 10), true})
+	expectScale(t, a, tm, ScaleResult{25, expectedEBC(1, 98, 25, 10), true, nil})
 	if a.panicTime != tm {
 		t.Errorf("PanicTime = %v, want: %v", a.panicTime, tm)
 	}
@@ -262,7 +560,7 @@ func TestAutoscalerUnpanicAfterSlowIncrease(t *testing.T) {
 	metrics.SetStableAndPanicConcurrency(30, 41)
 	tm = tm.Add(stableWindow / 2)
 
-	expectScale(t, a, tm, ScaleResult{41, expectedEBC(1, 98, 41, 40), true})
+	expectScale(t, a, tm, ScaleResult{41, expectedEBC(1, 98, 41, 40), true, nil})
 	if a.panicTime != start {
 		t.Error("Panic Time should not have moved")
 	}
@@ -272,7 +570,7 @@ func TestAutoscalerUnpanicAfterSlowIncrease(t *testing.T) {
 	metrics.SetStableAndPanicConcurrency(50, 56)
 	tm = tm.Add(stableWindow/2 + tickInterval)
 
-	expectScale(t, a, tm, ScaleResult{50 /* no longer in panic*/, expectedEBC(1, 98, 56, 55), true})
+	expectScale(t, a, tm, ScaleResult{50 /* no longer in panic*/, expectedEBC(1, 98, 56, 55), true, nil})
 	if !a.panicTime.IsZero() {
 		t.Errorf("PanicTime = %v, want: 0", a.panicTime)
 	}
@@ -286,7 +584,7 @@ func TestAutoscalerExtendPanicWindow(t *testing.T) {
 
 	start := time.Now()
 	tm := start
-	expectScale(t, a, tm, ScaleResult{25, expectedEBC(1, 98, 25, 10), true})
+	expectScale(t, a, tm, ScaleResult{25, expectedEBC(1, 98, 25, 10), true, nil})
 	if a.panicTime != tm {
 		t.Errorf("PanicTime = %v, want: %v", a.panicTime, tm)
 	}
@@ -296,7 +594,7 @@ func TestAutoscalerExtendPanicWindow(t *testing.T) {
 	metrics.SetStableAndPanicConcurrency(30, 80)
 	tm = tm.Add(stableWindow / 2)
 
-	expectScale(t, a, tm, ScaleResult{80, expectedEBC(1, 98, 80, 40), true})
+	expectScale(t, a, tm, ScaleResult{80, expectedEBC(1, 98, 80, 40), true, nil})
 	if a.panicTime != tm {
 		t.Errorf("PanicTime = %v, want: %v", a.panicTime, tm)
 	}
@@ -306,19 +604,19 @@ func TestAutoscalerStableModeDecrease(t *testing.T) {
 	metrics := &metricClient{StableConcurrency: 100.0, PanicConcurrency: 100}
 	a, pc := newTestAutoscaler(10, 98, metrics)
 	pc.readyCount = 8
-	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 98, 100, 8), true})
+	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 98, 100, 8), true, nil})
 
 	metrics.SetStableAndPanicConcurrency(50, 50)
-	expectScale(t, a, time.Now(), ScaleResult{5, expectedEBC(10, 98, 50, 8), true})
+	expectScale(t, a, time.Now(), ScaleResult{5, expectedEBC(10, 98, 50, 8), true, nil})
 }
 
 func TestAutoscalerStableModeNoTrafficScaleToZero(t *testing.T) {
 	metrics := &metricClient{StableConcurrency: 1, PanicConcurrency: 0}
 	a := newTestAutoscalerNoPC(10, 75, metrics)
-	expectScale(t, a, time.Now(), ScaleResult{1, expectedEBC(10, 75, 0, 1), true})
+	expectScale(t, a, time.Now(), ScaleResult{1, expectedEBC(10, 75, 0, 1), true, nil})
 
 	metrics.StableConcurrency = 0.0
-	expectScale(t, a, time.Now(), ScaleResult{0, expectedEBC(10, 75, 0, 1), true})
+	expectScale(t, a, time.Now(), ScaleResult{0, expectedEBC(10, 75, 0, 1), true, nil})
 }
 
 // QPS is increasing exponentially. Each scaling event bring concurrency
@@ -327,12 +625,12 @@ func TestAutoscalerStableModeNoTrafficScaleToZero(t *testing.T) {
 func TestAutoscalerPanicModeExponentialTrackAndStabilize(t *testing.T) {
 	metrics := &metricClient{StableConcurrency: 6, PanicConcurrency: 6}
 	a, pc := newTestAutoscaler(1, 101, metrics)
-	expectScale(t, a, time.Now(), ScaleResult{6, expectedEBC(1, 101, 6, 1), true})
+	expectScale(t, a, time.Now(), ScaleResult{6, expectedEBC(1, 101, 6, 1), true, nil})
 
 	tm := time.Now()
 	pc.readyCount = 6
 	metrics.SetStableAndPanicConcurrency(36, 36)
-	expectScale(t, a, tm, ScaleResult{36, expectedEBC(1, 101, 36, 6), true})
+	expectScale(t, a, tm, ScaleResult{36, expectedEBC(1, 101, 36, 6), true, nil})
 	if got, want := a.panicTime, tm; got != tm {
 		t.Errorf("PanicTime = %v, want: %v", got, want)
 	}
@@ -340,21 +638,21 @@ func TestAutoscalerPanicModeExponentialTrackAndStabilize(t *testing.T) {
 	pc.readyCount = 36
 	metrics.SetStableAndPanicConcurrency(216, 216)
 	tm = tm.Add(time.Second)
-	expectScale(t, a, tm, ScaleResult{216, expectedEBC(1, 101, 216, 36), true})
+	expectScale(t, a, tm, ScaleResult{216, expectedEBC(1, 101, 216, 36), true, nil})
 	if got, want := a.panicTime, tm; got != tm {
 		t.Errorf("PanicTime = %v, want: %v", got, want)
 	}
 
 	pc.readyCount = 216
 	metrics.SetStableAndPanicConcurrency(1296, 1296)
-	expectScale(t, a, tm, ScaleResult{1296, expectedEBC(1, 101, 1296, 216), true})
+	expectScale(t, a, tm, ScaleResult{1296, expectedEBC(1, 101, 1296, 216), true, nil})
 	if got, want := a.panicTime, tm; got != tm {
 		t.Errorf("PanicTime = %v, want: %v", got, want)
 	}
 
 	pc.readyCount = 1296
 	tm = tm.Add(time.Second)
-	expectScale(t, a, tm, ScaleResult{1296, expectedEBC(1, 101, 1296, 1296), true})
+	expectScale(t, a, tm, ScaleResult{1296, expectedEBC(1, 101, 1296, 1296), true, nil})
 }
 
 func TestAutoscalerScale(t *testing.T) {
@@ -468,7 +766,7 @@ func TestAutoscalerScale(t *testing.T) {
 			if test.prepFunc != nil {
 				test.prepFunc(test.as)
 			}
-			expectScale(tt, test.as, time.Now(), ScaleResult{test.wantScale, test.wantEBC, !test.wantInvalid})
+			expectScale(tt, test.as, time.Now(), ScaleResult{test.wantScale, test.wantEBC, !test.wantInvalid, nil})
 		})
 	}
 }
@@ -476,19 +774,52 @@ func TestAutoscalerScale(t *testing.T) {
 func TestAutoscalerPanicThenUnPanicScaleDown(t *testing.T) {
 	metrics := &metricClient{StableConcurrency: 100, PanicConcurrency: 100}
 	a, pc := newTestAutoscaler(10, 93, metrics)
-	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 93, 100, 1), true})
+	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 93, 100, 1), true, nil})
 	pc.readyCount = 10
 
 	panicTime := time.Now()
 	metrics.PanicConcurrency = 1000
-	expectScale(t, a, panicTime, ScaleResult{100, expectedEBC(10, 93, 1000, 10), true})
+	expectScale(t, a, panicTime, ScaleResult{100, expectedEBC(10, 93, 1000, 10), true, nil})
 
 	// Traffic dropped off, scale stays as we're still in panic.
 	metrics.SetStableAndPanicConcurrency(1, 1)
-	expectScale(t, a, panicTime.Add(30*time.Second), ScaleResult{100, expectedEBC(10, 93, 1, 10), true})
+	expectScale(t, a, panicTime.Add(30*time.Second), ScaleResult{100, expectedEBC(10, 93, 1, 10), true, nil})
 
 	// Scale down after the StableWindow
-	expectScale(t, a, panicTime.Add(61*time.Second), ScaleResult{1, expectedEBC(10, 93, 1, 10), true})
+	expectScale(t, a, panicTime.Add(61*time.Second), ScaleResult{1, expectedEBC(10, 93, 1, 10), true, nil})
+}
+
+func TestAutoscalerPanicExitHysteresis(t *testing.T) {
+	metrics := &metricClient{StableConcurrency: 10, PanicConcurrency: 10}
+	a, pc := newTestAutoscaler(10, 93, metrics)
+	a.deciderSpec.PanicExitThreshold = 1.2
+	a.deciderSpec.PanicCooldown = 20 * time.Second
+	expectScale(t, a, time.Now(), ScaleResult{1, expectedEBC(10, 93, 10, 1), true, nil})
+	pc.readyCount = 10
+
+	panicTime := time.Now()
+	metrics.PanicConcurrency = 1000
+	expectScale(t, a, panicTime, ScaleResult{100, expectedEBC(10, 93, 1000, 10), true, nil})
+	if got := a.PanicStartTime(); !got.Equal(panicTime) {
+		t.Errorf("PanicStartTime() = %v, want: %v", got, panicTime)
+	}
+
+	// Below the panic threshold, but over the exit one, so panic mode is
+	// extended past the StableWindow.
+	metrics.SetStableAndPanicConcurrency(150, 150)
+	expectScale(t, a, panicTime.Add(50*time.Second), ScaleResult{100, expectedEBC(10, 93, 150, 10), true, nil})
+	expectScale(t, a, panicTime.Add(61*time.Second), ScaleResult{100, expectedEBC(10, 93, 150, 10), true, nil})
+
+	// Below the exit threshold, panic mode is left after the cool-down.
+	metrics.SetStableAndPanicConcurrency(50, 50)
+	expectScale(t, a, panicTime.Add(80*time.Second), ScaleResult{100, expectedEBC(10, 93, 50, 10), true, nil})
+	if got := a.PanicStartTime(); !got.Equal(panicTime) {
+		t.Errorf("PanicStartTime() = %v, want: %v", got, panicTime)
+	}
+	expectScale(t, a, panicTime.Add(82*time.Second), ScaleResult{5, expectedEBC(10, 93, 50, 10), true, nil})
+	if got := a.PanicStartTime(); !got.IsZero() {
+		t.Errorf("PanicStartTime() = %v, want the zero time", got)
+	}
 }
 
 func TestAutoscalerRateLimitScaleUp(t *testing.T) {
@@ -496,11 +827,11 @@ func TestAutoscalerRateLimitScaleUp(t *testing.T) {
 	a, pc := newTestAutoscaler(10, 61, metrics)
 
 	// Need 100 pods but only scale x10
-	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 61, 1001, 1), true})
+	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 61, 1001, 1), true, nil})
 
 	pc.readyCount = 10
 	// Scale x10 again
-	expectScale(t, a, time.Now(), ScaleResult{100, expectedEBC(10, 61, 1001, 10), true})
+	expectScale(t, a, time.Now(), ScaleResult{100, expectedEBC(10, 61, 1001, 10), true, nil})
 }
 
 func TestAutoscalerRateLimitScaleDown(t *testing.T) {
@@ -509,11 +840,11 @@ func TestAutoscalerRateLimitScaleDown(t *testing.T) {
 
 	// Need 1 pods but can only scale down ten times, to 10.
 	pc.readyCount = 100
-	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 61, 1, 100), true})
+	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 61, 1, 100), true, nil})
 
 	pc.readyCount = 10
 	// Scale ÷10 again.
-	expectScale(t, a, time.Now(), ScaleResult{1, expectedEBC(10, 61, 1, 10), true})
+	expectScale(t, a, time.Now(), ScaleResult{1, expectedEBC(10, 61, 1, 10), true, nil})
 }
 
 func TestCantCountPods(t *testing.T) {
@@ -532,13 +863,13 @@ func TestAutoscalerUseOnePodAsMinimumIfEndpointsNotFound(t *testing.T) {
 	pc.readyCount = 0
 	// 2*10 as the rate limited if we can get the actual pods number.
 	// 1*10 as the rate limited since no read pods are there from K8S API.
-	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 81, 888, 0), true})
+	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 81, 888, 0), true, nil})
 }
 
 func TestAutoscalerUpdateTarget(t *testing.T) {
 	metrics := &metricClient{StableConcurrency: 100, PanicConcurrency: 101}
 	a, pc := newTestAutoscaler(10, 77, metrics)
-	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 77, 101, 1), true})
+	expectScale(t, a, time.Now(), ScaleResult{10, expectedEBC(10, 77, 101, 1), true, nil})
 
 	pc.readyCount = 10
 	a.Update(&DeciderSpec{
@@ -551,7 +882,7 @@ func TestAutoscalerUpdateTarget(t *testing.T) {
 		MaxScaleUpRate:      10,
 		StableWindow:        stableWindow,
 	})
-	expectScale(t, a, time.Now(), ScaleResult{100, expectedEBC(1, 71, 101, 10), true})
+	expectScale(t, a, time.Now(), ScaleResult{100, expectedEBC(1, 71, 101, 10), true, nil})
 }
 
 // For table tests and tests that don't care about changing scale.
@@ -610,6 +941,50 @@ func expectScale(t *testing.T, a UniScaler, now time.Time, want ScaleResult) {
 	}
 }
 
+func TestAutoscalerRestore(t *testing.T) {
+	metrics := &metricClient{StableConcurrency: 10, PanicConcurrency: 10}
+	a, pc := newTestAutoscaler(10, 0, metrics)
+	pc.readyCount = 10
+	now := time.Now()
+	a.Restore(15, now)
+
+	// The restored scale is kept until the stable window passed.
+	expectScale(t, a, now.Add(time.Second), ScaleResult{
+		DesiredPodCount:     15,
+		ExcessBurstCapacity: 0,
+		ScaleValid:          true,
+	})
+	expectScale(t, a, now.Add(stableWindow+time.Second), ScaleResult{
+		DesiredPodCount:     1,
+		ExcessBurstCapacity: 0,
+		ScaleValid:          true,
+	})
+}
+
+func TestAutoscalerRestoreScaleDownDelay(t *testing.T) {
+	metrics := &metricClient{}
+	spec := &DeciderSpec{
+		TargetValue:      10,
+		MaxScaleDownRate: 10,
+		MaxScaleUpRate:   10,
+		PanicThreshold:   100,
+		ScaleDownDelay:   5 * time.Minute,
+	}
+	as := New(context.Background(), testNamespace, testRevision, metrics, &fakePodCounter{}, spec)
+	now := time.Time{}
+	as.(Restorer).Restore(4, now)
+
+	// Scaling down is delayed from the restored scale.
+	expectScale(t, as, now.Add(5*time.Minute-2*time.Second), ScaleResult{
+		ScaleValid:      true,
+		DesiredPodCount: 4,
+	})
+	expectScale(t, as, now.Add(5*time.Minute+2*time.Second), ScaleResult{
+		ScaleValid:      true,
+		DesiredPodCount: 0,
+	})
+}
+
 func TestStartInPanicMode(t *testing.T) {
 	metrics := &staticMetricClient
 	deciderSpec := &DeciderSpec{
@@ -670,7 +1045,11 @@ func reset() {
 		panicRequestConcurrencyM.Name(),
 		targetRequestConcurrencyM.Name(),
 		stableRPSM.Name(), panicRPSM.Name(),
-		targetRPSM.Name(), panicM.Name())
+		targetRPSM.Name(), panicM.Name(),
+		predictedRequestConcurrencyM.Name(), predictedRPSM.Name(),
+		stableCustomM.Name(), panicCustomM.Name(),
+		predictedCustomM.Name(), targetCustomM.Name(),
+		decisionLatencyM.Name())
 	register()
 }
 
@@ -688,7 +1067,15 @@ type metricClient struct {
 	PanicConcurrency  float64
 	StableRPS         float64
 	PanicRPS          float64
+	StableCustom      float64
+	PanicCustom       float64
+	ActivatorQueue    float64
+	FailureRatio      float64
 	ErrF              func(key types.NamespacedName, now time.Time) error
+
+	history        *metrics.History
+	historyPeriod  time.Duration
+	historySeasons int
 }
 
 // SetStableAndPanicConcurrency sets the stable and panic concurrencies.
@@ -716,6 +1103,46 @@ func (mc *metricClient) StableAndPanicRPS(key types.NamespacedName, now time.Tim
 	return mc.StableRPS, mc.PanicRPS, err
 }
 
+// StableAndPanicCustom returns stable/panic custom metric values stored in
+// the object and the result of Errf as the error.
+func (mc *metricClient) StableAndPanicCustom(key types.NamespacedName, now time.Time) (float64, float64, error) {
+	var err error
+	if mc.ErrF != nil {
+		err = mc.ErrF(key, now)
+	}
+	return mc.StableCustom, mc.PanicCustom, err
+}
+
+// ActivatorQueueDepth returns the activator queue depth stored in the object
+// and the result of Errf as the error.
+func (mc *metricClient) ActivatorQueueDepth(key types.NamespacedName, now time.Time) (float64, error) {
+	var err error
+	if mc.ErrF != nil {
+		err = mc.ErrF(key, now)
+	}
+	return mc.ActivatorQueue, err
+}
+
+// ErrorRatio returns the error ratio stored in the object and the result
+// of Errf as the error.
+func (mc *metricClient) ErrorRatio(key types.NamespacedName, now time.Time) (float64, error) {
+	var err error
+	if mc.ErrF != nil {
+		err = mc.ErrF(key, now)
+	}
+	return mc.FailureRatio, err
+}
+
+// History returns the history stored in the object, replacing it as the
+// collector does if it doesn't fit.
+func (mc *metricClient) History(key types.NamespacedName, period time.Duration, seasons int) (*metrics.History, error) {
+	if mc.history == nil || mc.historyPeriod != period || mc.historySeasons != seasons {
+		mc.history = metrics.NewHistory(period, seasons)
+		mc.historyPeriod, mc.historySeasons = period, seasons
+	}
+	return mc.history, nil
+}
+
 func BenchmarkAutoscaler(b *testing.B) {
 	metrics := &metricClient{StableConcurrency: 50.0, PanicConcurrency: 10}
 	a := newTestAutoscalerNoPC(10, 101, metrics)
@@ -725,3 +1152,215 @@ func BenchmarkAutoscaler(b *testing.B) {
 		a.Scale(logtesting.TestLogger(b), now)
 	}
 }
+
+func TestAutoscalerStepChange(t *testing.T) {
+	const (
+		stableSecs = 60
+		panicSecs  = 6
+		wantPods   = 10
+	)
+	// timeToScale returns the seconds it takes to scale to wantPods for the
+	// given load (concurrency per second since the load started to change).
+	timeToScale := func(load func(sec int) float64, stepChangeThreshold float64) int {
+		avg := func(now, window int) float64 {
+			sum := 0.
+			for s := now - window + 1; s <= now; s++ {
+				sum += load(s)
+			}
+			return sum / float64(window)
+		}
+		metrics := &metricClient{}
+		spec := &DeciderSpec{
+			TargetValue:         10,
+			MaxScaleUpRate:      1000,
+			MaxScaleDownRate:    10,
+			PanicThreshold:      100, // Never panic.
+			StepChangeThreshold: stepChangeThreshold,
+			StableWindow:        stableSecs * time.Second,
+		}
+		as := newAutoscaler(context.Background(), testNamespace, testRevision, metrics,
+			&fakePodCounter{readyCount: 1}, spec, nil)
+		start := time.Now()
+		for sec := 0; sec <= 10*stableSecs; sec += int(tickInterval.Seconds()) {
+			metrics.SetStableAndPanicConcurrency(avg(sec, stableSecs), avg(sec, panicSecs))
+			if as.Scale(logtesting.TestLogger(t), start.Add(time.Duration(sec)*time.Second)).DesiredPodCount >= wantPods {
+				return sec
+			}
+		}
+		t.Fatalf("Never scaled to %d pods", wantPods)
+		return 0
+	}
+
+	step := func(sec int) float64 {
+		if sec <= 0 {
+			return 10
+		}
+		return 100
+	}
+	ramp := func(sec int) float64 {
+		switch {
+		case sec <= 0:
+			return 10
+		case sec >= stableSecs:
+			return 100
+		default:
+			return 10 + 90*float64(sec)/stableSecs
+		}
+	}
+
+	stepDetected := timeToScale(step, 3)
+	stepUndetected := timeToScale(step, 0)
+	rampDetected := timeToScale(ramp, 3)
+	rampUndetected := timeToScale(ramp, 0)
+
+	if stepDetected > panicSecs {
+		t.Errorf("Scaling on a step change took %ds, want at most %ds", stepDetected, panicSecs)
+	}
+	if stepDetected >= stepUndetected {
+		t.Errorf("Scaling on a detected step change took %ds, want less than the %ds without detection", stepDetected, stepUndetected)
+	}
+	if stepDetected >= rampDetected {
+		t.Errorf("Scaling on a step change took %ds, want less than the %ds of a gradual ramp", stepDetected, rampDetected)
+	}
+	// A gradual ramp is not a step change and scales as before.
+	if rampDetected != rampUndetected {
+		t.Errorf("Scaling on a gradual ramp took %ds, want: %ds as without detection", rampDetected, rampUndetected)
+	}
+}
+
+func TestAutoscalerPredictive(t *testing.T) {
+	defer reset()
+	const (
+		period    = time.Hour
+		lookahead = 5 * time.Minute
+		peakStart = 30 * time.Minute
+		peakEnd   = 40 * time.Minute
+	)
+	// The load repeats every period, with a peak of 100 in between a
+	// baseline of 10.
+	load := func(at time.Duration) float64 {
+		if at%period >= peakStart && at%period < peakEnd {
+			return 100
+		}
+		return 10
+	}
+
+	// podsAt returns the pods desired at each minute of the given period.
+	// A period has too many ticks to log them all.
+	logger := zap.NewNop().Sugar()
+	podsAt := func(as *autoscaler, metrics *metricClient, start time.Time, season int) map[time.Duration]int32 {
+		pods := make(map[time.Duration]int32)
+		for at := time.Duration(season) * period; at < time.Duration(season+1)*period; at += tickInterval {
+			metrics.SetStableAndPanicConcurrency(load(at), load(at))
+			sr := as.Scale(logger, start.Add(at))
+			if at%time.Minute == 0 {
+				pods[at%period] = sr.DesiredPodCount
+			}
+		}
+		return pods
+	}
+	spec := &DeciderSpec{
+		TargetValue:         10,
+		MaxScaleUpRate:      1000,
+		MaxScaleDownRate:    1000,
+		PanicThreshold:      100, // Never panic.
+		StableWindow:        time.Minute,
+		PredictionPeriod:    period,
+		PredictionSeasons:   1,
+		PredictionLookahead: lookahead,
+		Reachable:           true,
+	}
+	start := time.Unix(0, 0).Add(1000 * period)
+
+	metrics := &metricClient{}
+	as := newAutoscaler(context.Background(), testNamespace, testRevision, metrics,
+		&fakePodCounter{readyCount: 1}, spec, nil)
+
+	// Without history, the autoscaler only reacts to the peak.
+	learning := podsAt(as, metrics, start, 0)
+	if got := learning[peakStart-time.Minute]; got != 1 {
+		t.Errorf("Pods a minute before the first peak = %d, want: 1", got)
+	}
+	if got := learning[peakStart]; got != 10 {
+		t.Errorf("Pods at the first peak = %d, want: 10", got)
+	}
+
+	// Once the peak was learned, the capacity is raised ahead of it, and not
+	// any earlier.
+	predicted := podsAt(as, metrics, start, 1)
+	if got := predicted[peakStart-lookahead-2*time.Minute]; got != 1 {
+		t.Errorf("Pods well before the lookahead = %d, want: 1", got)
+	}
+	for at := peakStart - lookahead; at < peakEnd; at += time.Minute {
+		if got := predicted[at]; got != 10 {
+			t.Errorf("Pods at minute %v = %d, want: 10 ahead of and during the peak", at.Minutes(), got)
+		}
+	}
+	if got := predicted[peakEnd+time.Minute]; got != 1 {
+		t.Errorf("Pods after the peak = %d, want: 1", got)
+	}
+
+	// The history goes with a change of the period.
+	as.Update(&DeciderSpec{
+		TargetValue:         10,
+		MaxScaleUpRate:      1000,
+		MaxScaleDownRate:    1000,
+		PanicThreshold:      100,
+		StableWindow:        time.Minute,
+		PredictionPeriod:    2 * period,
+		PredictionSeasons:   1,
+		PredictionLookahead: lookahead,
+		Reachable:           true,
+	})
+	metrics.SetStableAndPanicConcurrency(10, 10)
+	if got := as.Scale(logtesting.TestLogger(t), start.Add(2*period+peakStart-lookahead)).DesiredPodCount; got != 1 {
+		t.Errorf("Pods ahead of the peak after a period change = %d, want: 1", got)
+	}
+}
+
+func TestAutoscalerPredictiveHistoryOutlivesAutoscaler(t *testing.T) {
+	defer reset()
+	const period = time.Hour
+	spec := &DeciderSpec{
+		TargetValue:         10,
+		MaxScaleUpRate:      1000,
+		MaxScaleDownRate:    1000,
+		PanicThreshold:      100,
+		StableWindow:        time.Minute,
+		PredictionPeriod:    period,
+		PredictionSeasons:   1,
+		PredictionLookahead: 5 * time.Minute,
+		Reachable:           true,
+	}
+	start := time.Unix(0, 0).Add(1000 * period)
+	metrics := &metricClient{}
+	ctx := servingmetrics.RevisionContext(testNamespace, "testSvc", "testConfig", testRevision)
+	as := newAutoscaler(ctx, testNamespace, testRevision, metrics,
+		&fakePodCounter{readyCount: 1}, spec, nil)
+	metrics.SetStableAndPanicConcurrency(100, 100)
+	as.Scale(logtesting.TestLogger(t), start.Add(30*time.Minute))
+
+	// A new autoscaler, e.g. after a restart, predicts from the history kept
+	// by the metric client.
+	as = newAutoscaler(ctx, testNamespace, testRevision, metrics,
+		&fakePodCounter{readyCount: 1}, spec, nil)
+	metrics.SetStableAndPanicConcurrency(10, 10)
+	if got := as.Scale(logtesting.TestLogger(t), start.Add(period+28*time.Minute)).DesiredPodCount; got != 10 {
+		t.Errorf("Pods ahead of the recorded peak = %d, want: 10", got)
+	}
+	// The prediction for now is reported next to the observed value.
+	as.Scale(logtesting.TestLogger(t), start.Add(period+30*time.Minute))
+	metricstest.AssertMetric(t,
+		metricstest.FloatMetric(stableRequestConcurrencyM.Name(), 10, nil).WithResource(wantResource),
+		metricstest.FloatMetric(predictedRequestConcurrencyM.Name(), 100, nil).WithResource(wantResource))
+
+	// Nothing is recorded with prediction disabled.
+	disabled := *spec
+	disabled.PredictionPeriod = 0
+	as.Update(&disabled)
+	metrics.history = nil
+	as.Scale(logtesting.TestLogger(t), start.Add(period+30*time.Minute))
+	if metrics.history != nil {
+		t.Error("The history was asked for with prediction disabled")
+	}
+}
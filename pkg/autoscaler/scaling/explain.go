@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaling
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	autoscalingv1alpha1 "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
+)
+
+// ExplainPath is the path the explanations of the scale decisions are
+// served at, followed by the namespace and the name of the revision.
+const ExplainPath = "/debug/scaling/"
+
+// ErrNoExplanation is returned when no scale decision was made for the
+// revision yet.
+var ErrNoExplanation = errors.New("no scale decision was made yet")
+
+// Explanation holds the inputs and the outcome of a scale decision.
+type Explanation struct {
+	// Time is the time the decision was made at.
+	Time time.Time `json:"time"`
+	// Metric is the metric the observed values are the values of.
+	Metric string `json:"metric"`
+	// ObservedStableValue and ObservedPanicValue are the values of the
+	// metric observed over the stable and the panic window.
+	ObservedStableValue float64 `json:"observedStableValue"`
+	ObservedPanicValue  float64 `json:"observedPanicValue"`
+	// PredictedValue is the value predicted from the load history, if the
+	// load is predicted.
+	PredictedValue *float64 `json:"predictedValue,omitempty"`
+	// ActivatorQueueDepth is the number of requests queued in the
+	// activators, if they are scaled on.
+	ActivatorQueueDepth float64 `json:"activatorQueueDepth,omitempty"`
+	// ErrorRatio is the share of the requests failing on the pods, if the
+	// activator falls back into their path on it.
+	ErrorRatio float64 `json:"errorRatio,omitempty"`
+	// TargetValue and TotalValue are the per pod target and total values
+	// of the metric.
+	TargetValue float64 `json:"targetValue"`
+	TotalValue  float64 `json:"totalValue"`
+	// TargetBurstCapacity is the burst capacity to maintain and
+	// ExcessBurstCapacity the headroom left over it.
+	TargetBurstCapacity float64 `json:"targetBurstCapacity"`
+	ExcessBurstCapacity int32   `json:"excessBurstCapacity"`
+	// ReadyPodCount is the number of ready pods the decision was made on.
+	ReadyPodCount int `json:"readyPodCount"`
+	// DesiredStablePodCount and DesiredPanicPodCount are the pod counts
+	// desired over the stable and the panic window, within MaxScaleDown
+	// and MaxScaleUp.
+	DesiredStablePodCount int32 `json:"desiredStablePodCount"`
+	DesiredPanicPodCount  int32 `json:"desiredPanicPodCount"`
+	MaxScaleUp            int32 `json:"maxScaleUp"`
+	MaxScaleDown          int32 `json:"maxScaleDown"`
+	// MetricPodCounts are the pod counts each of the metrics recommends, if
+	// the revision is scaled on a combination of metrics.
+	MetricPodCounts []MetricPodCount `json:"metricPodCounts,omitempty"`
+	// Panicking tells whether the autoscaler is in panic mode, since
+	// PanicTime, not scaling below MaxPanicPods.
+	Panicking    bool       `json:"panicking"`
+	PanicTime    *time.Time `json:"panicTime,omitempty"`
+	MaxPanicPods int32      `json:"maxPanicPods,omitempty"`
+	// DesiredPodCount is the resulting desired scale.
+	DesiredPodCount int32 `json:"desiredPodCount"`
+}
+
+// Explainer is implemented by the UniScalers able to explain their last
+// scale decision.
+type Explainer interface {
+	// Explain returns the explanation of the last scale decision, nil if
+	// no decision was made yet.
+	Explain() *Explanation
+}
+
+// Explain returns the explanation of the last scale decision made for the
+// given revision.
+func (m *MultiScaler) Explain(namespace, name string) (*Explanation, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	m.scalersMutex.RLock()
+	defer m.scalersMutex.RUnlock()
+	scaler, exists := m.scalers[key]
+	if !exists {
+		// This GroupResource is a lie, but unfortunately this interface requires one.
+		return nil, apierrors.NewNotFound(autoscalingv1alpha1.Resource("Deciders"), key.String())
+	}
+	explainer, ok := scaler.scaler.(Explainer)
+	if !ok {
+		return nil, ErrNoExplanation
+	}
+	explanation := explainer.Explain()
+	if explanation == nil {
+		return nil, ErrNoExplanation
+	}
+	return explanation, nil
+}
+
+// NewExplainHandler returns a handler serving the explanations of the last
+// scale decisions of the MultiScaler as JSON, at ExplainPath followed by
+// namespace/revision. The requests are first authorized by authorize, for
+// the revision asked for.
+func NewExplainHandler(m *MultiScaler, authorize func(*http.Request, types.NamespacedName) error,
+	logger *zap.SugaredLogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, ExplainPath), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			http.Error(w, "expected "+ExplainPath+"{namespace}/{revision}", http.StatusNotFound)
+			return
+		}
+		key := types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+
+		if err := authorize(r, key); err != nil {
+			logger.Infow("Unauthorized request to explain the scale decision of "+key.String(), zap.Error(err))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		explanation, err := m.Explain(key.Namespace, key.Name)
+		switch {
+		case apierrors.IsNotFound(err):
+			http.Error(w, "revision is not scaled by this autoscaler", http.StatusNotFound)
+			return
+		case errors.Is(err, ErrNoExplanation):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(explanation); err != nil {
+			logger.Errorw("Failed to write the explanation of the scale decision of "+key.String(), zap.Error(err))
+		}
+	})
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/types"
+
+	. "knative.dev/pkg/logging/testing"
+)
+
+// fakeExplainer is a fakeUniScaler explaining its decisions.
+type fakeExplainer struct {
+	fakeUniScaler
+	explanation *Explanation
+}
+
+func (e *fakeExplainer) Explain() *Explanation {
+	return e.explanation
+}
+
+func TestExplainHandler(t *testing.T) {
+	explanation := &Explanation{
+		Time:                time.Unix(1000, 0).UTC(),
+		Metric:              "concurrency",
+		ObservedStableValue: 10,
+		ObservedPanicValue:  20,
+		TargetValue:         1,
+		DesiredPodCount:     20,
+		Panicking:           true,
+	}
+	explainer := &fakeExplainer{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ms := NewMultiScaler(ctx.Done(), func(*Decider) (UniScaler, error) {
+		return explainer, nil
+	}, TestLogger(t))
+	if _, err := ms.Create(ctx, newDecider()); err != nil {
+		t.Fatal("Create() =", err)
+	}
+
+	authorize := func(r *http.Request, key types.NamespacedName) error {
+		if r.Header.Get("Authorization") != "Bearer good" {
+			return errors.New("bad token")
+		}
+		if key.Namespace != "a-ns" {
+			return errors.New("not allowed")
+		}
+		return nil
+	}
+	handler := NewExplainHandler(ms, authorize, TestLogger(t))
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		token       string
+		explanation *Explanation
+		wantCode    int
+	}{{
+		name:        "explained",
+		path:        "/debug/scaling/a-ns/a-rev",
+		token:       "good",
+		explanation: explanation,
+		wantCode:    http.StatusOK,
+	}, {
+		name:     "no decision yet",
+		path:     "/debug/scaling/a-ns/a-rev",
+		token:    "good",
+		wantCode: http.StatusNotFound,
+	}, {
+		name:        "unknown revision",
+		path:        "/debug/scaling/a-ns/another-rev",
+		token:       "good",
+		explanation: explanation,
+		wantCode:    http.StatusNotFound,
+	}, {
+		name:        "bad path",
+		path:        "/debug/scaling/a-ns",
+		token:       "good",
+		explanation: explanation,
+		wantCode:    http.StatusNotFound,
+	}, {
+		name:        "bad method",
+		method:      http.MethodPost,
+		path:        "/debug/scaling/a-ns/a-rev",
+		token:       "good",
+		explanation: explanation,
+		wantCode:    http.StatusMethodNotAllowed,
+	}, {
+		name:        "bad token",
+		path:        "/debug/scaling/a-ns/a-rev",
+		token:       "bad",
+		explanation: explanation,
+		wantCode:    http.StatusUnauthorized,
+	}, {
+		name:        "not allowed",
+		path:        "/debug/scaling/another-ns/a-rev",
+		token:       "good",
+		explanation: explanation,
+		wantCode:    http.StatusUnauthorized,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			explainer.explanation = tc.explanation
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, tc.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("StatusCode = %d, want: %d", rec.Code, tc.wantCode)
+			}
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+			got := &Explanation{}
+			if err := json.NewDecoder(rec.Body).Decode(got); err != nil {
+				t.Fatal("Failed to decode the explanation:", err)
+			}
+			if !cmp.Equal(got, tc.explanation) {
+				t.Error("Explanation mismatch (-want +got):", cmp.Diff(tc.explanation, got))
+			}
+		})
+	}
+}
+
+func TestAutoscalerExplain(t *testing.T) {
+	metrics := &metricClient{StableConcurrency: 50, PanicConcurrency: 50}
+	a, pc := newTestAutoscaler(10, 101, metrics)
+	pc.readyCount = 2
+
+	if got := a.Explain(); got != nil {
+		t.Errorf("Explain() = %v, want: nil", got)
+	}
+
+	now := time.Now()
+	a.Scale(TestLogger(t), now)
+	want := &Explanation{
+		Time:                  now,
+		Metric:                "concurrency",
+		ObservedStableValue:   50,
+		ObservedPanicValue:    50,
+		TargetValue:           10,
+		TotalValue:            10 / targetUtilization,
+		TargetBurstCapacity:   101,
+		ExcessBurstCapacity:   -125,
+		ReadyPodCount:         2,
+		DesiredStablePodCount: 5,
+		DesiredPanicPodCount:  5,
+		MaxScaleUp:            20,
+		MaxScaleDown:          0,
+		Panicking:             true,
+		PanicTime:             &now,
+		MaxPanicPods:          5,
+		DesiredPodCount:       5,
+	}
+	if got := a.Explain(); !cmp.Equal(got, want) {
+		t.Error("Explain() mismatch (-want +got):", cmp.Diff(want, got))
+	}
+}
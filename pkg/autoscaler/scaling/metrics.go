@@ -40,6 +40,10 @@ var (
 		"panic_request_concurrency",
 		"Average of requests count per observed pod over the panic window",
 		stats.UnitDimensionless)
+	predictedRequestConcurrencyM = stats.Float64(
+		"predicted_request_concurrency",
+		"Average of requests count per observed pod predicted from the load history",
+		stats.UnitDimensionless)
 	targetRequestConcurrencyM = stats.Float64(
 		"target_concurrency_per_pod",
 		"The desired number of concurrent requests for each pod",
@@ -52,14 +56,38 @@ var (
 		"panic_requests_per_second",
 		"Average requests-per-second per observed pod over the panic window",
 		stats.UnitDimensionless)
+	predictedRPSM = stats.Float64(
+		"predicted_requests_per_second",
+		"Average requests-per-second per observed pod predicted from the load history",
+		stats.UnitDimensionless)
 	targetRPSM = stats.Float64(
 		"target_requests_per_second",
 		"The desired requests-per-second for each pod",
 		stats.UnitDimensionless)
+	stableCustomM = stats.Float64(
+		"stable_custom_metric",
+		"Average of the custom metric per observed pod over the stable window",
+		stats.UnitDimensionless)
+	panicCustomM = stats.Float64(
+		"panic_custom_metric",
+		"Average of the custom metric per observed pod over the panic window",
+		stats.UnitDimensionless)
+	predictedCustomM = stats.Float64(
+		"predicted_custom_metric",
+		"Average of the custom metric per observed pod predicted from the load history",
+		stats.UnitDimensionless)
+	targetCustomM = stats.Float64(
+		"target_custom_metric_per_pod",
+		"The desired value of the custom metric for each pod",
+		stats.UnitDimensionless)
 	panicM = stats.Int64(
 		"panic_mode",
 		"1 if autoscaler is in panic mode, 0 otherwise",
 		stats.UnitDimensionless)
+	decisionLatencyM = stats.Float64(
+		"scale_decision_latency",
+		"Time to compute a scaling decision in milliseconds",
+		stats.UnitMilliseconds)
 )
 
 func init() {
@@ -91,6 +119,11 @@ func register() {
 			Measure:     panicRequestConcurrencyM,
 			Aggregation: view.LastValue(),
 		},
+		&view.View{
+			Description: "Average of requests count predicted from the load history",
+			Measure:     predictedRequestConcurrencyM,
+			Aggregation: view.LastValue(),
+		},
 		&view.View{
 			Description: "The desired number of concurrent requests for each pod",
 			Measure:     targetRequestConcurrencyM,
@@ -111,11 +144,41 @@ func register() {
 			Measure:     panicRPSM,
 			Aggregation: view.LastValue(),
 		},
+		&view.View{
+			Description: "Average requests-per-second predicted from the load history",
+			Measure:     predictedRPSM,
+			Aggregation: view.LastValue(),
+		},
 		&view.View{
 			Description: "The desired requests-per-second for each pod",
 			Measure:     targetRPSM,
 			Aggregation: view.LastValue(),
 		},
+		&view.View{
+			Description: "Average of the custom metric over the stable window",
+			Measure:     stableCustomM,
+			Aggregation: view.LastValue(),
+		},
+		&view.View{
+			Description: "Average of the custom metric over the panic window",
+			Measure:     panicCustomM,
+			Aggregation: view.LastValue(),
+		},
+		&view.View{
+			Description: "Average of the custom metric predicted from the load history",
+			Measure:     predictedCustomM,
+			Aggregation: view.LastValue(),
+		},
+		&view.View{
+			Description: "The desired value of the custom metric for each pod",
+			Measure:     targetCustomM,
+			Aggregation: view.LastValue(),
+		},
+		&view.View{
+			Description: "The time to compute a scaling decision in milliseconds",
+			Measure:     decisionLatencyM,
+			Aggregation: view.Distribution(pkgmetrics.Buckets125(0.01, 10000)...),
+		},
 	); err != nil {
 		panic(err)
 	}
@@ -23,10 +23,12 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"knative.dev/pkg/logging/logkey"
+	"knative.dev/serving/pkg/apis/autoscaling"
 	autoscalingv1alpha1 "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
 	"knative.dev/serving/pkg/autoscaler/metrics"
 )
@@ -45,7 +47,10 @@ type Decider struct {
 }
 
 // DeciderSpec is the parameters by which the Revision should be scaled.
+// +k8s:deepcopy-gen=true
 type DeciderSpec struct {
+	// Algorithm is the name of the algorithm the revision is scaled with.
+	Algorithm        string
 	MaxScaleUpRate   float64
 	MaxScaleDownRate float64
 	// The metric used for scaling, i.e. concurrency, rps.
@@ -65,6 +70,38 @@ type DeciderSpec struct {
 	// pods. I.e. if this is 2, panic mode will be entered if the observed metric
 	// is twice as high as the current population can handle.
 	PanicThreshold float64
+	// PanicExitThreshold is the threshold below which panic mode is left, as a
+	// factor like the PanicThreshold. 0 leaves it below the PanicThreshold.
+	PanicExitThreshold float64
+	// PanicCooldown is how long the load must have stayed below the
+	// PanicExitThreshold for panic mode to be left. 0 waits for the
+	// StableWindow.
+	PanicCooldown time.Duration
+	// StepChangeThreshold is the factor by which the load observed over the
+	// panic window must exceed the load observed over the stable window to be
+	// considered a step change. Step changes are scaled on the panic window
+	// value, rather than waiting for the stable window to catch up.
+	// 0 disables step change detection.
+	StepChangeThreshold float64
+	// MaxScaleDownPerMinute limits how much the revision is scaled down by
+	// per minute. The zero value doesn't limit it.
+	MaxScaleDownPerMinute autoscaling.ScaleDownLimit
+	// ActivatorQueueThreshold is the number of requests queued in the
+	// activators that warrant one more pod on top of the ready ones.
+	// 0 disables it.
+	ActivatorQueueThreshold float64
+	// ActivatorFallbackErrorRatio is the share of the requests failing on
+	// the pods above which the activator is put in the request path.
+	// 0 disables it.
+	ActivatorFallbackErrorRatio float64
+	// PredictionPeriod is the period of the load pattern learned to scale
+	// ahead of the predicted load. 0 disables prediction.
+	PredictionPeriod time.Duration
+	// PredictionSeasons is the number of past periods the load is predicted
+	// from.
+	PredictionSeasons int
+	// PredictionLookahead is how far ahead of the predicted load to scale.
+	PredictionLookahead time.Duration
 	// StableWindow is needed to determine when to exit panic mode.
 	StableWindow time.Duration
 	// ScaleDownDelay is the time that must pass at reduced concurrency before a
@@ -76,9 +113,36 @@ type DeciderSpec struct {
 	InitialScale int32
 	// Reachable describes whether the revision is referenced by any route.
 	Reachable bool
+	// CombinedMetrics are the metrics the revision is scaled on besides the
+	// ScalingMetric. The pod counts recommended by each of the metrics are
+	// combined as given by MetricCombination.
+	CombinedMetrics []MetricTarget
+	// MetricCombination is how the pod counts recommended by the metrics are
+	// combined, i.e. max, min or weighted.
+	MetricCombination string
+	// ScalingMetricWeight is the weight of the ScalingMetric in the weighted
+	// combination.
+	ScalingMetricWeight float64
+}
+
+// MetricTarget is one of the CombinedMetrics of a DeciderSpec.
+type MetricTarget struct {
+	// Metric is the metric, i.e. concurrency, rps or custom.
+	Metric string
+	// TargetValue is the value of the metric per pod to maintain.
+	TargetValue float64
+	// Weight is the weight of the metric in the weighted combination.
+	Weight float64
+}
+
+// MetricPodCount is the pod count one of the metrics recommends.
+type MetricPodCount struct {
+	Metric          string `json:"metric"`
+	DesiredPodCount int32  `json:"desiredPodCount"`
 }
 
 // DeciderStatus is the current scale recommendation.
+// +k8s:deepcopy-gen=true
 type DeciderStatus struct {
 	// DesiredScale is the target number of instances that autoscaler
 	// this revision needs.
@@ -90,6 +154,14 @@ type DeciderStatus struct {
 	// If this number is negative: Activator will be threaded in
 	// the request path by the PodAutoscaler controller.
 	ExcessBurstCapacity int32
+
+	// MetricPodCounts are the pod counts each of the metrics recommends, if
+	// the revision is scaled on a combination of metrics.
+	MetricPodCounts []MetricPodCount
+
+	// PanicStartTime is when the autoscaler entered panic mode, the zero time
+	// when it isn't panicking.
+	PanicStartTime time.Time
 }
 
 // ScaleResult holds the scale result of the UniScaler evaluation cycle.
@@ -102,6 +174,9 @@ type ScaleResult struct {
 	// ScaleValid specifies whether this scale result is valid, i.e. whether
 	// Autoscaler had all the necessary information to compute a suggestion.
 	ScaleValid bool
+	// MetricPodCounts are the pod counts each of the metrics recommends, if
+	// the revision is scaled on a combination of metrics.
+	MetricPodCounts []MetricPodCount
 }
 
 var invalidSR = ScaleResult{
@@ -117,6 +192,22 @@ type UniScaler interface {
 	Update(*DeciderSpec)
 }
 
+// Restorer is implemented by the UniScalers able to pick up from the scale
+// last desired for a revision, e.g. by an autoscaler before it restarted.
+type Restorer interface {
+	// Restore keeps at least the given scale until the UniScaler collected
+	// enough metrics to decide on its own.
+	Restore(desiredScale int32, now time.Time)
+}
+
+// PanicReporter is implemented by the UniScalers scaling in panic mode on
+// surges of the load.
+type PanicReporter interface {
+	// PanicStartTime returns when the UniScaler entered panic mode, the zero
+	// time when it isn't panicking.
+	PanicStartTime() time.Time
+}
+
 // UniScalerFactory creates a UniScaler for a given PA using the given dynamic configuration.
 type UniScalerFactory func(*Decider) (UniScaler, error)
 
@@ -163,9 +254,25 @@ func (sr *scalerRunner) updateLatestScale(sRes ScaleResult) bool {
 
 	// Update with the latest calculation anyway.
 	sr.decider.Status.ExcessBurstCapacity = sRes.ExcessBurstCapacity
+
+	// The PodAutoscaler surfaces the pod counts of the metrics.
+	if !equality.Semantic.DeepEqual(sr.decider.Status.MetricPodCounts, sRes.MetricPodCounts) {
+		sr.decider.Status.MetricPodCounts = sRes.MetricPodCounts
+		ret = true
+	}
 	return ret
 }
 
+func (sr *scalerRunner) updatePanicStartTime(t time.Time) bool {
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+	if sr.decider.Status.PanicStartTime.Equal(t) {
+		return false
+	}
+	sr.decider.Status.PanicStartTime = t
+	return true
+}
+
 // MultiScaler maintains a collection of UniScalers.
 type MultiScaler struct {
 	scalersMutex sync.RWMutex
@@ -311,7 +418,13 @@ func (m *MultiScaler) createScaler(decider *Decider, key types.NamespacedName) (
 		pokeCh:  make(chan struct{}),
 		logger:  m.logger.With(zap.String(logkey.Key, key.String())),
 	}
-	d.Status.DesiredScale = -1
+	// A Decider created with a desired scale restores it, rather than
+	// being unknown until the first decision.
+	if restorer, ok := scaler.(Restorer); ok && d.Status.DesiredScale > 0 {
+		restorer.Restore(d.Status.DesiredScale, time.Now())
+	} else {
+		d.Status.DesiredScale = -1
+	}
 	switch tbc := d.Spec.TargetBurstCapacity; tbc {
 	case -1, 0:
 		d.Status.ExcessBurstCapacity = int32(tbc)
@@ -332,7 +445,12 @@ func (m *MultiScaler) tickScaler(scaler UniScaler, runner *scalerRunner, metricK
 		return
 	}
 
-	if runner.updateLatestScale(sr) {
+	changed := runner.updateLatestScale(sr)
+	// The PodAutoscaler surfaces whether the revision is panicking.
+	if p, ok := scaler.(PanicReporter); ok {
+		changed = runner.updatePanicStartTime(p.PanicStartTime()) || changed
+	}
+	if changed {
 		m.Inform(metricKey)
 	}
 }
@@ -24,6 +24,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/types"
 
@@ -310,7 +311,7 @@ func TestMultiScalerScaleFromZero(t *testing.T) {
 	metricKey := types.NamespacedName{Namespace: decider.Namespace, Name: decider.Name}
 	if scaler, exists := ms.scalers[metricKey]; !exists {
 		t.Error("Failed to get scaler for metric", metricKey)
-	} else if !scaler.updateLatestScale(ScaleResult{0, 10, true}) {
+	} else if !scaler.updateLatestScale(ScaleResult{0, 10, true, nil}) {
 		t.Error("Failed to set scale for metric to 0")
 	}
 
@@ -328,6 +329,38 @@ func TestMultiScalerScaleFromZero(t *testing.T) {
 	ms.Delete(ctx, decider.Namespace, decider.Name)
 }
 
+// fakeRestorer is a fakeUniScaler restoring the scale it's created with.
+type fakeRestorer struct {
+	fakeUniScaler
+	restored int32
+}
+
+func (r *fakeRestorer) Restore(desiredScale int32, _ time.Time) {
+	r.restored = desiredScale
+}
+
+func TestMultiScalerCreateRestores(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	restorer := &fakeRestorer{}
+	ms := NewMultiScaler(ctx.Done(), func(*Decider) (UniScaler, error) {
+		return restorer, nil
+	}, TestLogger(t))
+
+	decider := newDecider()
+	decider.Status.DesiredScale = 7
+	got, err := ms.Create(ctx, decider)
+	if err != nil {
+		t.Fatal("Create() =", err)
+	}
+	if got.Status.DesiredScale != 7 {
+		t.Errorf("DesiredScale = %d, want: 7", got.Status.DesiredScale)
+	}
+	if restorer.restored != 7 {
+		t.Errorf("Restored scale = %d, want: 7", restorer.restored)
+	}
+}
+
 func TestMultiScalerUpdate(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -387,7 +420,7 @@ func (u *fakeUniScaler) Scale(*zap.SugaredLogger, time.Time) ScaleResult {
 	u.mutex.Lock()
 	defer u.mutex.Unlock()
 	u.scaleCount++
-	return ScaleResult{u.replicas, u.surplus, u.scaled}
+	return ScaleResult{u.replicas, u.surplus, u.scaled, nil}
 }
 
 func (u *fakeUniScaler) setScaleResult(replicas, surplus int32, scaled bool) {
@@ -430,3 +463,37 @@ func TestSameSign(t *testing.T) {
 		}
 	}
 }
+
+func TestUpdateLatestScaleMetricPodCounts(t *testing.T) {
+	sr := &scalerRunner{decider: newDecider()}
+	podCounts := []MetricPodCount{{Metric: "concurrency", DesiredPodCount: 5}, {Metric: "rps", DesiredPodCount: 8}}
+	if !sr.updateLatestScale(ScaleResult{0, 0, true, podCounts}) {
+		t.Error("updateLatestScale() = false for new metric pod counts")
+	}
+	if got := sr.safeDecider().Status.MetricPodCounts; !cmp.Equal(got, podCounts) {
+		t.Error("MetricPodCounts mismatch (-want,+got):\n", cmp.Diff(podCounts, got))
+	}
+	if sr.updateLatestScale(ScaleResult{0, 0, true, []MetricPodCount{{Metric: "concurrency", DesiredPodCount: 5}, {Metric: "rps", DesiredPodCount: 8}}}) {
+		t.Error("updateLatestScale() = true for the same metric pod counts")
+	}
+	if !sr.updateLatestScale(ScaleResult{0, 0, true, nil}) {
+		t.Error("updateLatestScale() = false for dropping the metric pod counts")
+	}
+}
+
+func TestUpdatePanicStartTime(t *testing.T) {
+	sr := &scalerRunner{decider: newDecider()}
+	panicTime := time.Now()
+	if !sr.updatePanicStartTime(panicTime) {
+		t.Error("updatePanicStartTime() = false for entering panic mode")
+	}
+	if got := sr.safeDecider().Status.PanicStartTime; !got.Equal(panicTime) {
+		t.Errorf("PanicStartTime = %v, want: %v", got, panicTime)
+	}
+	if sr.updatePanicStartTime(panicTime) {
+		t.Error("updatePanicStartTime() = true for the same panic start time")
+	}
+	if !sr.updatePanicStartTime(time.Time{}) {
+		t.Error("updatePanicStartTime() = false for leaving panic mode")
+	}
+}
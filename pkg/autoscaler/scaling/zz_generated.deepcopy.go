@@ -24,8 +24,8 @@ package scaling
 func (in *Decider) DeepCopyInto(out *Decider) {
 	*out = *in
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -38,3 +38,45 @@ func (in *Decider) DeepCopy() *Decider {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeciderSpec) DeepCopyInto(out *DeciderSpec) {
+	*out = *in
+	if in.CombinedMetrics != nil {
+		in, out := &in.CombinedMetrics, &out.CombinedMetrics
+		*out = make([]MetricTarget, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeciderSpec.
+func (in *DeciderSpec) DeepCopy() *DeciderSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DeciderSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeciderStatus) DeepCopyInto(out *DeciderStatus) {
+	*out = *in
+	if in.MetricPodCounts != nil {
+		in, out := &in.MetricPodCounts, &out.MetricPodCounts
+		*out = make([]MetricPodCount, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeciderStatus.
+func (in *DeciderStatus) DeepCopy() *DeciderStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DeciderStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -18,12 +18,16 @@ package statforwarder
 
 import (
 	"context"
+	"fmt"
+	"net/url"
 
 	"go.uber.org/zap"
 	"knative.dev/pkg/leaderelection"
 )
 
 // StatefulSetBasedProcessor configured "processors" for each of the statefulset ordinals.
+// The stats of the buckets owned by the other ordinals are forwarded to their pods,
+// addressed through the governing service of the StatefulSet.
 func StatefulSetBasedProcessor(ctx context.Context, f *Forwarder, accept statProcessor) error {
 	id, bs, err := leaderelection.NewStatefulSetBucketAndSet(len(f.bs.BucketList()))
 	if err != nil {
@@ -37,8 +41,22 @@ func StatefulSetBasedProcessor(ctx context.Context, f *Forwarder, accept statPro
 				accept: accept,
 			})
 		} else {
-			f.setProcessor(n, newForwardProcessor(f.logger.With(zap.String("bucket", n)), n, "unused", n))
+			addr, err := statefulSetPodAddress(n)
+			if err != nil {
+				return err
+			}
+			f.setProcessor(n, newForwardProcessor(f.logger.With(zap.String("bucket", n)), n, "unused", addr))
 		}
 	}
 	return nil
 }
+
+// statefulSetPodAddress returns the WebSocket address of the autoscaler pod
+// owning the given bucket, whose name is the URL of the pod.
+func statefulSetPodAddress(bkt string) (string, error) {
+	u, err := url.Parse(bkt)
+	if err != nil || u.Hostname() == "" {
+		return "", fmt.Errorf("bucket %q isn't the URL of a StatefulSet pod", bkt)
+	}
+	return fmt.Sprintf("ws://%s:%d", u.Hostname(), autoscalerPort), nil
+}
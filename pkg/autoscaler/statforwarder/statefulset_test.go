@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statforwarder
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"knative.dev/pkg/leaderelection"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/system"
+
+	. "knative.dev/pkg/logging/testing"
+)
+
+func TestStatefulSetBasedProcessor(t *testing.T) {
+	os.Setenv("STATEFUL_CONTROLLER_ORDINAL", "autoscaler-1")
+	os.Setenv("STATEFUL_SERVICE_NAME", "autoscaler-sharded")
+	defer os.Unsetenv("STATEFUL_CONTROLLER_ORDINAL")
+	defer os.Unsetenv("STATEFUL_SERVICE_NAME")
+
+	own, bs, err := leaderelection.NewStatefulSetBucketAndSet(3)
+	must(t, err)
+
+	ctx := logging.WithLogger(context.Background(), TestLogger(t))
+	f := New(ctx, bs)
+	defer f.Cancel()
+	must(t, StatefulSetBasedProcessor(ctx, f, noOp))
+
+	if !f.IsBucketOwner(own.Name()) {
+		t.Errorf("IsBucketOwner(%s) = false, want true", own.Name())
+	}
+	for _, bkt := range bs.BucketList() {
+		if bkt == own.Name() {
+			continue
+		}
+		if f.IsBucketOwner(bkt) {
+			t.Errorf("IsBucketOwner(%s) = true, want false", bkt)
+		}
+	}
+
+	p, ok := f.getProcessor(bs.BucketList()[0]).(*remoteProcessor)
+	if !ok {
+		t.Fatalf("Processor of %s = %T, want a remoteProcessor", bs.BucketList()[0], p)
+	}
+	want := []string{"ws://autoscaler-0.autoscaler-sharded." + system.Namespace() + ".svc.cluster.local:8080"}
+	if !cmp.Equal(p.addrs, want) {
+		t.Error("Forwarding addresses mismatch (-want +got):", cmp.Diff(want, p.addrs))
+	}
+}
+
+func TestStatefulSetPodAddress(t *testing.T) {
+	got, err := statefulSetPodAddress("http://autoscaler-2.autoscaler-sharded.knative-serving.svc.cluster.local:80")
+	if err != nil {
+		t.Fatal("statefulSetPodAddress() =", err)
+	}
+	if want := "ws://autoscaler-2.autoscaler-sharded.knative-serving.svc.cluster.local:8080"; got != want {
+		t.Errorf("statefulSetPodAddress() = %s, want: %s", got, want)
+	}
+
+	if _, err := statefulSetPodAddress("as-bucket-00-of-02"); err == nil {
+		t.Error("statefulSetPodAddress(as-bucket-00-of-02) = nil, want an error")
+	}
+}
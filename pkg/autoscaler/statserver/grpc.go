@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statserver
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	gogotypes "github.com/gogo/protobuf/types"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"knative.dev/serving/pkg/autoscaler/metrics"
+)
+
+const (
+	// grpcKeepaliveTime is how often the server pings idle clients, and the
+	// least time between the pings of the clients.
+	grpcKeepaliveTime = 30 * time.Second
+	// grpcKeepaliveTimeout is how long a ping may go unanswered before the
+	// connection is closed.
+	grpcKeepaliveTimeout = 10 * time.Second
+)
+
+// GRPCServer receives autoscaler statistics over gRPC streams and sends them
+// to a channel. Every message is acknowledged once its stats were sent, so
+// that clients are held back by a busy autoscaler through flow control.
+type GRPCServer struct {
+	addr       string
+	grpcSrv    *grpc.Server
+	servingCh  chan struct{}
+	statsCh    chan<- metrics.StatMessage
+	isBktOwner func(bktName string) bool
+	logger     *zap.SugaredLogger
+}
+
+var _ metrics.StatServiceServer = (*GRPCServer)(nil)
+
+// NewGRPC creates a GRPCServer which will receive autoscaler statistics and
+// forward them to statsCh until Shutdown is called.
+func NewGRPC(statsServerAddr string, statsCh chan<- metrics.StatMessage, logger *zap.SugaredLogger, isBktOwner func(bktName string) bool) *GRPCServer {
+	s := &GRPCServer{
+		addr:       statsServerAddr,
+		servingCh:  make(chan struct{}),
+		statsCh:    statsCh,
+		isBktOwner: isBktOwner,
+		logger:     logger.Named("stats-grpc-server").With("address", statsServerAddr),
+	}
+	s.grpcSrv = grpc.NewServer(
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    grpcKeepaliveTime,
+			Timeout: grpcKeepaliveTimeout,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             grpcKeepaliveTime,
+			PermitWithoutStream: true,
+		}),
+	)
+	metrics.RegisterStatServiceServer(s.grpcSrv, s)
+	return s
+}
+
+// ListenAndServe listens on the address s.addr and handles incoming streams.
+// It blocks until the server fails or Shutdown is called.
+// It returns an error or, if Shutdown was called, nil.
+func (s *GRPCServer) ListenAndServe() error {
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+	return s.serve(listener)
+}
+
+func (s *GRPCServer) listen() (net.Listener, error) {
+	s.logger.Info("Starting")
+	return net.Listen("tcp", s.addr)
+}
+
+func (s *GRPCServer) serve(l net.Listener) error {
+	close(s.servingCh)
+	if err := s.grpcSrv.Serve(l); !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// Report implements metrics.StatServiceServer.
+func (s *GRPCServer) Report(stream metrics.StatService_ReportServer) error {
+	if s.isBktOwner != nil {
+		if md, ok := metadata.FromIncomingContext(stream.Context()); ok && len(md[":authority"]) > 0 {
+			host := md[":authority"][0]
+			// It won't affect streams via Autoscaler service (used by Activator) or IP address.
+			if isBucketHost(host) {
+				bkt := strings.SplitN(host, ".", 2)[0]
+				if !s.isBktOwner(bkt) {
+					s.logger.Warn("Rejecting stream because not the owner of the bucket ", bkt)
+					return status.Error(codes.Unavailable, "not the owner of the bucket "+bkt)
+				}
+			}
+		}
+	}
+
+	for {
+		wsms, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			s.logger.Debug("Stream closed by the client")
+			return nil
+		}
+		if err != nil {
+			s.logger.Debugw("Stream exiting on error", zap.Error(err))
+			return err
+		}
+
+		for _, wsm := range wsms.Messages {
+			if wsm.Stat == nil {
+				// To allow for future protobuf schema changes.
+				continue
+			}
+
+			sm := wsm.ToStatMessage()
+			s.logger.Debugf("Received stat message: %+v", sm)
+			s.statsCh <- sm
+		}
+		if err := stream.Send(&gogotypes.Empty{}); err != nil {
+			return err
+		}
+	}
+}
+
+// Shutdown stops the server, giving the streams the given timeout period to
+// finish before they're cut, and then returns. Clients reconnect right away.
+func (s *GRPCServer) Shutdown(timeout time.Duration) {
+	<-s.servingCh
+	s.logger.Info("Shutting down")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.grpcSrv.GracefulStop()
+	}()
+	select {
+	case <-done:
+		s.logger.Info("Shutdown complete")
+	case <-time.After(timeout):
+		s.logger.Warn("Shutdown timed out")
+		s.grpcSrv.Stop()
+		<-done
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"knative.dev/serving/pkg/autoscaler/metrics"
+)
+
+// startGRPCServer starts a GRPCServer and returns a client connected to it.
+func startGRPCServer(t *testing.T, statsCh chan<- metrics.StatMessage, f func(bkt string) bool, opts ...grpc.DialOption) metrics.StatServiceClient {
+	t.Helper()
+	server := NewGRPC(testAddress, statsCh, zap.NewNop().Sugar(), f)
+	listener, err := server.listen()
+	if err != nil {
+		t.Fatal("listen() =", err)
+	}
+	go server.serve(listener)
+	t.Cleanup(func() { server.Shutdown(time.Second) })
+
+	conn, err := grpc.Dial(listener.Addr().String(), append(opts, grpc.WithInsecure())...)
+	if err != nil {
+		t.Fatal("Dial() =", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return metrics.NewStatServiceClient(conn)
+}
+
+func TestGRPCServerReport(t *testing.T) {
+	statsCh := make(chan metrics.StatMessage)
+	client := startGRPCServer(t, statsCh, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Report(ctx)
+	if err != nil {
+		t.Fatal("Report() =", err)
+	}
+	wsms := metrics.ToWireStatMessages(both)
+	if err := stream.Send(&wsms); err != nil {
+		t.Fatal("Send() =", err)
+	}
+
+	got := make([]metrics.StatMessage, 0, len(both))
+	for range both {
+		got = append(got, <-statsCh)
+	}
+	if !cmp.Equal(both, got) {
+		t.Error("StatMessage mismatch: diff (-got, +want)", cmp.Diff(got, both))
+	}
+	// The message is acknowledged once its stats were accepted.
+	if _, err := stream.Recv(); err != nil {
+		t.Error("Recv() =", err)
+	}
+	stream.CloseSend()
+}
+
+func TestGRPCServerNotOwnerForBucketHost(t *testing.T) {
+	// Override the function to mock a bucket host.
+	isBucketHost = alwaysTrue
+
+	statsCh := make(chan metrics.StatMessage)
+	client := startGRPCServer(t, statsCh, alwaysFalse, grpc.WithAuthority("autoscaler-bucket-00-of-01.knative-serving"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Report(ctx)
+	if err != nil {
+		t.Fatal("Report() =", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("Recv() = %v, want code %v", err, codes.Unavailable)
+	}
+}
@@ -17,13 +17,16 @@ limitations under the License.
 package deployment
 
 import (
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"path"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
 
 	cm "knative.dev/pkg/configmap"
 )
@@ -52,6 +55,23 @@ const (
 	// (e.g. ko.local) where tags should not be resolved to digests.
 	registriesSkippingTagResolvingKey = "registriesSkippingTagResolving"
 
+	// imagesSkippingTagResolvingKey is the config map key for the glob patterns
+	// of the repositories (e.g. registry.internal/base/*) whose tags should not
+	// be resolved to digests.
+	imagesSkippingTagResolvingKey = "imagesSkippingTagResolving"
+
+	// digestResolutionRegistriesKey is the config map key for the per-registry
+	// configuration of the digest resolution.
+	digestResolutionRegistriesKey = "digestResolutionRegistries"
+
+	// digestCacheTTLKey is the key to configure how long resolved digests are
+	// cached for.
+	digestCacheTTLKey = "digestCacheTTL"
+
+	// injectionKey is the config map key for the sidecars, env vars and
+	// volumes injected into the pods of the revisions.
+	injectionKey = "injection"
+
 	// queueSidecar resource request keys.
 	queueSidecarCPURequestKey              = "queueSidecarCPURequest"
 	queueSidecarMemoryRequestKey           = "queueSidecarMemoryRequest"
@@ -64,6 +84,24 @@ const (
 
 	// concurrencyStateEndpointKey is the key to configure the endpoint Queue Proxy will call when traffic drops to / increases from zero.
 	concurrencyStateEndpointKey = "concurrencyStateEndpoint"
+
+	// concurrencyStateFailFastKey is the key to make Queue Proxy reject requests once it failed to resume the user container.
+	concurrencyStateFailFastKey = "concurrencyStateFailFast"
+
+	// concurrencyStateProbeInterpositionKey is the key to make Queue Proxy answer the kubelet probes of the paused user container.
+	concurrencyStateProbeInterpositionKey = "concurrencyStateProbeInterposition"
+
+	// queueSidecarPushStatsKey is the key to make Queue Proxy push its stats to the autoscaler rather than being scraped.
+	queueSidecarPushStatsKey = "queueSidecarPushStats"
+
+	// queueSidecarDebugKey is the key to make Queue Proxy serve the pprof profiles and the Go runtime metrics on localhost.
+	queueSidecarDebugKey = "queueSidecarDebug"
+
+	// queueSidecarAutoGomaxprocsKey is the key to make Queue Proxy set GOMAXPROCS to its CPU quota.
+	queueSidecarAutoGomaxprocsKey = "queueSidecarAutoGomaxprocs"
+
+	// asyncCallbackAllowedHostsKey is the key to the hosts the responses of the asynchronous requests may be POSTed to.
+	asyncCallbackAllowedHostsKey = "asyncCallbackAllowedHosts"
 )
 
 var (
@@ -92,6 +130,10 @@ func NewConfigFromMap(configMap map[string]string) (*Config, error) {
 		cm.AsDuration(ProgressDeadlineKey, &nc.ProgressDeadline),
 		cm.AsDuration(digestResolutionTimeoutKey, &nc.DigestResolutionTimeout),
 		cm.AsStringSet(registriesSkippingTagResolvingKey, &nc.RegistriesSkippingTagResolving),
+		cm.AsStringSet(imagesSkippingTagResolvingKey, &nc.ImagesSkippingTagResolving),
+		asRegistries(digestResolutionRegistriesKey, &nc.DigestResolutionRegistries),
+		cm.AsDuration(digestCacheTTLKey, &nc.DigestCacheTTL),
+		asInjection(injectionKey, &nc.Injection),
 
 		cm.AsQuantity(queueSidecarCPURequestKey, &nc.QueueSidecarCPURequest),
 		cm.AsQuantity(queueSidecarMemoryRequestKey, &nc.QueueSidecarMemoryRequest),
@@ -101,6 +143,12 @@ func NewConfigFromMap(configMap map[string]string) (*Config, error) {
 		cm.AsQuantity(queueSidecarEphemeralStorageLimitKey, &nc.QueueSidecarEphemeralStorageLimit),
 
 		cm.AsString(concurrencyStateEndpointKey, &nc.ConcurrencyStateEndpoint),
+		cm.AsBool(concurrencyStateFailFastKey, &nc.ConcurrencyStateFailFast),
+		cm.AsBool(concurrencyStateProbeInterpositionKey, &nc.ConcurrencyStateProbeInterposition),
+		cm.AsBool(queueSidecarPushStatsKey, &nc.QueueSidecarPushStats),
+		cm.AsBool(queueSidecarDebugKey, &nc.QueueSidecarDebug),
+		cm.AsBool(queueSidecarAutoGomaxprocsKey, &nc.QueueSidecarAutoGomaxprocs),
+		cm.AsStringSet(asyncCallbackAllowedHostsKey, &nc.AsyncCallbackAllowedHosts),
 	); err != nil {
 		return nil, err
 	}
@@ -121,6 +169,16 @@ func NewConfigFromMap(configMap map[string]string) (*Config, error) {
 		return nil, fmt.Errorf("digestResolutionTimeout cannot be a non-positive duration, was %v", nc.DigestResolutionTimeout)
 	}
 
+	for _, pattern := range nc.ImagesSkippingTagResolving.List() {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q in imagesSkippingTagResolving: %w", pattern, err)
+		}
+	}
+
+	if nc.DigestCacheTTL < 0 {
+		return nil, fmt.Errorf("digestCacheTTL cannot be a negative duration, was %v", nc.DigestCacheTTL)
+	}
+
 	return nc, nil
 }
 
@@ -138,6 +196,22 @@ type Config struct {
 	// Repositories for which tag to digest resolving should be skipped.
 	RegistriesSkippingTagResolving sets.String
 
+	// ImagesSkippingTagResolving are the glob patterns of the repositories,
+	// including their registry, for which tag to digest resolving should be skipped.
+	ImagesSkippingTagResolving sets.String
+
+	// DigestResolutionRegistries is the configuration of the digest resolution
+	// of the images of each registry.
+	DigestResolutionRegistries map[string]RegistryConfig
+
+	// DigestCacheTTL is how long resolved digests are reused for, by all the
+	// revisions using the same image. Zero disables the cache.
+	DigestCacheTTL time.Duration
+
+	// Injection declares the sidecars, env vars and volumes added to the pods
+	// of the revisions of the selected namespaces.
+	Injection *Injection
+
 	// DigestResolutionTimeout is the maximum time allowed for image digest resolution.
 	DigestResolutionTimeout time.Duration
 
@@ -167,4 +241,179 @@ type Config struct {
 
 	// ConcurrencyStateEndpoint is the endpoint Queue Proxy will call when traffic drops to / increases from zero.
 	ConcurrencyStateEndpoint string
+
+	// ConcurrencyStateFailFast makes Queue Proxy reject requests with a 503 once it failed to resume the user container,
+	// report not ready and record an event about the pod, rather than keep requests waiting until they time out.
+	ConcurrencyStateFailFast bool
+
+	// ConcurrencyStateProbeInterposition makes Queue Proxy answer the kubelet probes routed through it on behalf of
+	// the user container while it's paused, with the outcome of their last run, rather than resume it for them.
+	ConcurrencyStateProbeInterposition bool
+
+	// QueueSidecarPushStats makes Queue Proxy push its stats to the autoscaler over gRPC every second, rather than
+	// the autoscaler scraping a sample of the pods of the revision through its metrics service.
+	QueueSidecarPushStats bool
+
+	// QueueSidecarDebug makes Queue Proxy serve the pprof profiles and the Go runtime metrics on the localhost-only
+	// debug port, to profile the data path in production.
+	QueueSidecarDebug bool
+
+	// QueueSidecarAutoGomaxprocs makes Queue Proxy set GOMAXPROCS to the CPU quota of its container, unless GOMAXPROCS
+	// is set explicitly.
+	QueueSidecarAutoGomaxprocs bool
+
+	// AsyncCallbackAllowedHosts are the hosts, or "*.domain" patterns matching their subdomains, the responses of the
+	// asynchronous requests may be POSTed to. No asynchronous request is accepted if empty.
+	AsyncCallbackAllowedHosts sets.String
+}
+
+// RegistryConfig is the configuration of the digest resolution of the images
+// of a registry.
+type RegistryConfig struct {
+	// Timeout overrides DigestResolutionTimeout for the images of the registry.
+	Timeout time.Duration
+
+	// CABundle are the PEM encoded certificates trusted for the registry, in
+	// addition to the system and cluster ones.
+	CABundle string
+
+	// PullSecret is the name of a secret of the system namespace whose
+	// credentials are used for the registry, in addition to the image pull
+	// secrets of the revision.
+	PullSecret string
+}
+
+// SkipsTagResolving returns whether the tags of the repository (e.g. foo/bar)
+// of the registry should not be resolved to digests.
+func (c *Config) SkipsTagResolving(registry, repository string) bool {
+	if c.RegistriesSkippingTagResolving.Has(registry) {
+		return true
+	}
+	for pattern := range c.ImagesSkippingTagResolving {
+		if ok, _ := path.Match(pattern, registry+"/"+repository); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// DigestResolutionTimeoutFor returns the maximum time allowed for the digest
+// resolution of the images of the registry.
+func (c *Config) DigestResolutionTimeoutFor(registry string) time.Duration {
+	if rc, ok := c.DigestResolutionRegistries[registry]; ok && rc.Timeout > 0 {
+		return rc.Timeout
+	}
+	return c.DigestResolutionTimeout
+}
+
+// asRegistries parses the YAML map of the registry configurations at key,
+// such as:
+//
+//	registry.internal:
+//	  timeout: 30s
+//	  caBundle: |
+//	    -----BEGIN CERTIFICATE-----
+//	    ...
+//	  pullSecret: registry-internal-creds
+func asRegistries(key string, target *map[string]RegistryConfig) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		var registries map[string]struct {
+			Timeout    string `json:"timeout"`
+			CABundle   string `json:"caBundle"`
+			PullSecret string `json:"pullSecret"`
+		}
+		if err := yaml.Unmarshal([]byte(raw), &registries); err != nil {
+			return fmt.Errorf("failed to parse %q: %w", key, err)
+		}
+		*target = make(map[string]RegistryConfig, len(registries))
+		for registry, r := range registries {
+			rc := RegistryConfig{
+				CABundle:   r.CABundle,
+				PullSecret: r.PullSecret,
+			}
+			if r.Timeout != "" {
+				timeout, err := time.ParseDuration(r.Timeout)
+				if err != nil {
+					return fmt.Errorf("failed to parse the timeout of registry %q in %q: %w", registry, key, err)
+				}
+				if timeout <= 0 {
+					return fmt.Errorf("the timeout of registry %q in %q cannot be a non-positive duration, was %v", registry, key, timeout)
+				}
+				rc.Timeout = timeout
+			}
+			if rc.CABundle != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(rc.CABundle)) {
+				return fmt.Errorf("the caBundle of registry %q in %q contains no PEM encoded certificate", registry, key)
+			}
+			(*target)[registry] = rc
+		}
+		return nil
+	}
+}
+
+// Injection declares what is added to the pods of the revisions, without
+// changing the revisions themselves.
+type Injection struct {
+	// Namespaces are the glob patterns of the namespaces whose revisions get
+	// the injection, all of them if empty.
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Containers are appended to the containers of the pods.
+	Containers []corev1.Container `json:"containers,omitempty"`
+
+	// Env is appended to the env of the user containers.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// VolumeMounts are appended to the volume mounts of the user containers.
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// Volumes are appended to the volumes of the pods.
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+}
+
+// AppliesTo returns whether the revisions of the namespace get the injection.
+func (i *Injection) AppliesTo(namespace string) bool {
+	if len(i.Namespaces) == 0 {
+		return true
+	}
+	for _, pattern := range i.Namespaces {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// asInjection parses the YAML injection at key.
+func asInjection(key string, target **Injection) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		injection := &Injection{}
+		if err := yaml.UnmarshalStrict([]byte(raw), injection); err != nil {
+			return fmt.Errorf("failed to parse %q: %w", key, err)
+		}
+		for _, pattern := range injection.Namespaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid namespace pattern %q in %q: %w", pattern, key, err)
+			}
+		}
+		for _, c := range injection.Containers {
+			if c.Name == "" || c.Image == "" {
+				return fmt.Errorf("the containers in %q must have a name and an image", key)
+			}
+		}
+		for _, v := range injection.Volumes {
+			if v.Name == "" {
+				return fmt.Errorf("the volumes in %q must have a name", key)
+			}
+		}
+		*target = injection
+		return nil
+	}
 }
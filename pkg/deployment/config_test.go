@@ -68,12 +68,53 @@ func TestControllerConfigurationFromFile(t *testing.T) {
 		got.QueueSidecarCPULimit = nil
 		got.QueueSidecarMemoryRequest, got.QueueSidecarMemoryLimit = nil, nil
 		got.QueueSidecarEphemeralStorageRequest, got.QueueSidecarEphemeralStorageLimit = nil, nil
+		got.ImagesSkippingTagResolving, got.DigestResolutionRegistries = nil, nil
+		got.Injection = nil
+		got.AsyncCallbackAllowedHosts = nil
 		if !cmp.Equal(got, want) {
 			t.Error("Example stanza does not match default, diff(-want,+got):", cmp.Diff(want, got))
 		}
 	}
 }
 
+func TestSkipsTagResolving(t *testing.T) {
+	cfg := &Config{
+		RegistriesSkippingTagResolving: sets.NewString("ko.local"),
+		ImagesSkippingTagResolving:     sets.NewString("registry.internal/base/*"),
+	}
+	for _, tc := range []struct {
+		registry, repository string
+		want                 bool
+	}{
+		{"ko.local", "foo", true},
+		{"registry.internal", "base/ubuntu", true},
+		{"registry.internal", "base/ubuntu/nested", false},
+		{"registry.internal", "apps/ubuntu", false},
+		{"index.docker.io", "library/ubuntu", false},
+	} {
+		if got := cfg.SkipsTagResolving(tc.registry, tc.repository); got != tc.want {
+			t.Errorf("SkipsTagResolving(%s, %s) = %v, want: %v", tc.registry, tc.repository, got, tc.want)
+		}
+	}
+}
+
+func TestInjectionAppliesTo(t *testing.T) {
+	all := &Injection{}
+	if !all.AppliesTo("foo") {
+		t.Error("AppliesTo(foo) = false without namespaces, want true")
+	}
+	some := &Injection{Namespaces: []string{"team-*", "default"}}
+	for ns, want := range map[string]bool{
+		"team-a":  true,
+		"default": true,
+		"foo":     false,
+	} {
+		if got := some.AppliesTo(ns); got != want {
+			t.Errorf("AppliesTo(%s) = %v, want: %v", ns, got, want)
+		}
+	}
+}
+
 func TestControllerConfiguration(t *testing.T) {
 	configTests := []struct {
 		name       string
@@ -194,6 +235,93 @@ func TestControllerConfiguration(t *testing.T) {
 			QueueSidecarImageKey: defaultSidecarImage,
 			ProgressDeadlineKey:  "1982ms",
 		},
+	}, {
+		name: "controller configuration with image patterns and registries",
+		wantConfig: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("kind.local", "ko.local", "dev.local"),
+			ImagesSkippingTagResolving:     sets.NewString("registry.internal/base/*"),
+			DigestResolutionRegistries: map[string]RegistryConfig{
+				"registry.internal": {Timeout: time.Minute, PullSecret: "creds"},
+				"registry.other":    {},
+			},
+			DigestResolutionTimeout: digestResolutionTimeoutDefault,
+			DigestCacheTTL:          5 * time.Minute,
+			QueueSidecarImage:       defaultSidecarImage,
+			QueueSidecarCPURequest:  &QueueSidecarCPURequestDefault,
+			ProgressDeadline:        ProgressDeadlineDefault,
+		},
+		data: map[string]string{
+			QueueSidecarImageKey:          defaultSidecarImage,
+			imagesSkippingTagResolvingKey: "registry.internal/base/*",
+			digestResolutionRegistriesKey: "registry.internal:\n  timeout: 1m\n  pullSecret: creds\nregistry.other: {}\n",
+			digestCacheTTLKey:             "5m",
+		},
+	}, {
+		name: "controller configuration with injection",
+		wantConfig: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("kind.local", "ko.local", "dev.local"),
+			DigestResolutionTimeout:        digestResolutionTimeoutDefault,
+			QueueSidecarImage:              defaultSidecarImage,
+			QueueSidecarCPURequest:         &QueueSidecarCPURequestDefault,
+			ProgressDeadline:               ProgressDeadlineDefault,
+			Injection: &Injection{
+				Namespaces: []string{"team-*"},
+				Containers: []corev1.Container{{
+					Name:  "agent",
+					Image: "agent-image",
+				}},
+				Env: []corev1.EnvVar{{
+					Name:  "AGENT_ENABLED",
+					Value: "true",
+				}},
+			},
+		},
+		data: map[string]string{
+			QueueSidecarImageKey: defaultSidecarImage,
+			injectionKey:         "namespaces: [team-*]\ncontainers:\n- name: agent\n  image: agent-image\nenv:\n- name: AGENT_ENABLED\n  value: \"true\"\n",
+		},
+	}, {
+		name:    "controller configuration invalid injection",
+		wantErr: true,
+		data: map[string]string{
+			QueueSidecarImageKey: defaultSidecarImage,
+			injectionKey:         "sidecars: []\n",
+		},
+	}, {
+		name:    "controller configuration injected container without image",
+		wantErr: true,
+		data: map[string]string{
+			QueueSidecarImageKey: defaultSidecarImage,
+			injectionKey:         "containers:\n- name: agent\n",
+		},
+	}, {
+		name:    "controller configuration invalid image pattern",
+		wantErr: true,
+		data: map[string]string{
+			QueueSidecarImageKey:          defaultSidecarImage,
+			imagesSkippingTagResolvingKey: "registry.internal/[",
+		},
+	}, {
+		name:    "controller configuration invalid registry timeout",
+		wantErr: true,
+		data: map[string]string{
+			QueueSidecarImageKey:          defaultSidecarImage,
+			digestResolutionRegistriesKey: "registry.internal:\n  timeout: -1s\n",
+		},
+	}, {
+		name:    "controller configuration invalid registry CA bundle",
+		wantErr: true,
+		data: map[string]string{
+			QueueSidecarImageKey:          defaultSidecarImage,
+			digestResolutionRegistriesKey: "registry.internal:\n  caBundle: not a cert\n",
+		},
+	}, {
+		name:    "controller configuration invalid digest cache TTL",
+		wantErr: true,
+		data: map[string]string{
+			QueueSidecarImageKey: defaultSidecarImage,
+			digestCacheTTLKey:    "-1m",
+		},
 	}, {
 		name: "controller configuration with concurrency state endpoint",
 		wantConfig: &Config{
@@ -208,6 +336,94 @@ func TestControllerConfiguration(t *testing.T) {
 			QueueSidecarImageKey:        defaultSidecarImage,
 			concurrencyStateEndpointKey: "freeze-proxy",
 		},
+	}, {
+		name: "controller configuration with concurrency state fail fast",
+		wantConfig: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("kind.local", "ko.local", "dev.local"),
+			DigestResolutionTimeout:        digestResolutionTimeoutDefault,
+			QueueSidecarImage:              defaultSidecarImage,
+			QueueSidecarCPURequest:         &QueueSidecarCPURequestDefault,
+			ProgressDeadline:               ProgressDeadlineDefault,
+			ConcurrencyStateEndpoint:       "freeze-proxy",
+			ConcurrencyStateFailFast:       true,
+		},
+		data: map[string]string{
+			QueueSidecarImageKey:        defaultSidecarImage,
+			concurrencyStateEndpointKey: "freeze-proxy",
+			concurrencyStateFailFastKey: "true",
+		},
+	}, {
+		name: "controller configuration with concurrency state probe interposition",
+		wantConfig: &Config{
+			RegistriesSkippingTagResolving:     sets.NewString("kind.local", "ko.local", "dev.local"),
+			DigestResolutionTimeout:            digestResolutionTimeoutDefault,
+			QueueSidecarImage:                  defaultSidecarImage,
+			QueueSidecarCPURequest:             &QueueSidecarCPURequestDefault,
+			ProgressDeadline:                   ProgressDeadlineDefault,
+			ConcurrencyStateEndpoint:           "freeze-proxy",
+			ConcurrencyStateProbeInterposition: true,
+		},
+		data: map[string]string{
+			QueueSidecarImageKey:                  defaultSidecarImage,
+			concurrencyStateEndpointKey:           "freeze-proxy",
+			concurrencyStateProbeInterpositionKey: "true",
+		},
+	}, {
+		name: "controller configuration with stats pushed by queue-proxy",
+		wantConfig: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("kind.local", "ko.local", "dev.local"),
+			DigestResolutionTimeout:        digestResolutionTimeoutDefault,
+			QueueSidecarImage:              defaultSidecarImage,
+			QueueSidecarCPURequest:         &QueueSidecarCPURequestDefault,
+			ProgressDeadline:               ProgressDeadlineDefault,
+			QueueSidecarPushStats:          true,
+		},
+		data: map[string]string{
+			QueueSidecarImageKey:     defaultSidecarImage,
+			queueSidecarPushStatsKey: "true",
+		},
+	}, {
+		name: "controller configuration with the queue-proxy debug server",
+		wantConfig: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("kind.local", "ko.local", "dev.local"),
+			DigestResolutionTimeout:        digestResolutionTimeoutDefault,
+			QueueSidecarImage:              defaultSidecarImage,
+			QueueSidecarCPURequest:         &QueueSidecarCPURequestDefault,
+			ProgressDeadline:               ProgressDeadlineDefault,
+			QueueSidecarDebug:              true,
+		},
+		data: map[string]string{
+			QueueSidecarImageKey: defaultSidecarImage,
+			queueSidecarDebugKey: "true",
+		},
+	}, {
+		name: "controller configuration with GOMAXPROCS set to the CPU quota",
+		wantConfig: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("kind.local", "ko.local", "dev.local"),
+			DigestResolutionTimeout:        digestResolutionTimeoutDefault,
+			QueueSidecarImage:              defaultSidecarImage,
+			QueueSidecarCPURequest:         &QueueSidecarCPURequestDefault,
+			ProgressDeadline:               ProgressDeadlineDefault,
+			QueueSidecarAutoGomaxprocs:     true,
+		},
+		data: map[string]string{
+			QueueSidecarImageKey:          defaultSidecarImage,
+			queueSidecarAutoGomaxprocsKey: "true",
+		},
+	}, {
+		name: "controller configuration with asynchronous callback hosts",
+		wantConfig: &Config{
+			RegistriesSkippingTagResolving: sets.NewString("kind.local", "ko.local", "dev.local"),
+			DigestResolutionTimeout:        digestResolutionTimeoutDefault,
+			QueueSidecarImage:              defaultSidecarImage,
+			QueueSidecarCPURequest:         &QueueSidecarCPURequestDefault,
+			ProgressDeadline:               ProgressDeadlineDefault,
+			AsyncCallbackAllowedHosts:      sets.NewString("hooks.example.com", "*.callbacks.example.com"),
+		},
+		data: map[string]string{
+			QueueSidecarImageKey:         defaultSidecarImage,
+			asyncCallbackAllowedHostsKey: "hooks.example.com, *.callbacks.example.com",
+		},
 	}}
 
 	for _, tt := range configTests {
@@ -36,6 +36,11 @@ const (
 	Disabled = -1
 
 	disabled = "disabled"
+
+	// NamespaceOverridePrefix is the prefix of the namespace annotations
+	// overriding the settings of the same name for the revisions in the
+	// namespace, e.g. "gc.serving.knative.dev/min-non-active-revisions".
+	NamespaceOverridePrefix = "gc.serving.knative.dev/"
 )
 
 // Config defines the tunable parameters for Garbage Collection.
@@ -69,35 +74,62 @@ func defaultConfig() *Config {
 func NewConfigFromConfigMapFunc(ctx context.Context) func(configMap *corev1.ConfigMap) (*Config, error) {
 	return func(configMap *corev1.ConfigMap) (*Config, error) {
 		c := defaultConfig()
-
-		var retainCreate, retainActive, max string
-		if err := cm.Parse(configMap.Data,
-			cm.AsString("retain-since-create-time", &retainCreate),
-			cm.AsString("retain-since-last-active-time", &retainActive),
-			cm.AsInt64("min-non-active-revisions", &c.MinNonActiveRevisions),
-			cm.AsString("max-non-active-revisions", &max),
-		); err != nil {
-			return nil, fmt.Errorf("failed to parse data: %w", err)
+		if err := c.parse(configMap.Data); err != nil {
+			return nil, err
 		}
+		return c, nil
+	}
+}
 
-		// validate V2 settings
-		if err := parseDisabledOrDuration(retainCreate, &c.RetainSinceCreateTime); err != nil {
-			return nil, fmt.Errorf("failed to parse retain-since-create-time: %w", err)
-		}
-		if err := parseDisabledOrDuration(retainActive, &c.RetainSinceLastActiveTime); err != nil {
-			return nil, fmt.Errorf("failed to parse retain-since-last-active-time: %w", err)
-		}
-		if err := parseDisabledOrInt64(max, &c.MaxNonActiveRevisions); err != nil {
-			return nil, fmt.Errorf("failed to parse max-non-active-revisions: %w", err)
-		}
-		if c.MinNonActiveRevisions < 0 {
-			return nil, fmt.Errorf("min-non-active-revisions must be non-negative, was: %d", c.MinNonActiveRevisions)
-		}
-		if c.MaxNonActiveRevisions >= 0 && c.MinNonActiveRevisions > c.MaxNonActiveRevisions {
-			return nil, fmt.Errorf("min-non-active-revisions(%d) must be <= max-non-active-revisions(%d)", c.MinNonActiveRevisions, c.MaxNonActiveRevisions)
+// WithNamespaceOverrides returns the config with the settings overridden by
+// the NamespaceOverridePrefix annotations of a namespace.
+func (c *Config) WithNamespaceOverrides(annotations map[string]string) (*Config, error) {
+	data := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		if key := strings.TrimPrefix(k, NamespaceOverridePrefix); key != k {
+			data[key] = v
 		}
+	}
+	if len(data) == 0 {
 		return c, nil
 	}
+
+	out := c.DeepCopy()
+	if err := out.parse(data); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// parse overrides the settings of the config with the ones in data.
+func (c *Config) parse(data map[string]string) error {
+	var retainCreate, retainActive, max string
+	if err := cm.Parse(data,
+		cm.AsString("retain-since-create-time", &retainCreate),
+		cm.AsString("retain-since-last-active-time", &retainActive),
+		cm.AsInt64("min-non-active-revisions", &c.MinNonActiveRevisions),
+		cm.AsString("max-non-active-revisions", &max),
+	); err != nil {
+		return fmt.Errorf("failed to parse data: %w", err)
+	}
+
+	// validate V2 settings
+	if err := parseDisabledOrDuration(retainCreate, &c.RetainSinceCreateTime); err != nil {
+		return fmt.Errorf("failed to parse retain-since-create-time: %w", err)
+	}
+	if err := parseDisabledOrDuration(retainActive, &c.RetainSinceLastActiveTime); err != nil {
+		return fmt.Errorf("failed to parse retain-since-last-active-time: %w", err)
+	}
+	if err := parseDisabledOrInt64(max, &c.MaxNonActiveRevisions); err != nil {
+		return fmt.Errorf("failed to parse max-non-active-revisions: %w", err)
+	}
+	if c.MinNonActiveRevisions < 0 {
+		return fmt.Errorf("min-non-active-revisions must be non-negative, was: %d", c.MinNonActiveRevisions)
+	}
+	if c.MaxNonActiveRevisions >= 0 && c.MinNonActiveRevisions > c.MaxNonActiveRevisions {
+		return fmt.Errorf("min-non-active-revisions(%d) must be <= max-non-active-revisions(%d)", c.MinNonActiveRevisions, c.MaxNonActiveRevisions)
+	}
+	return nil
 }
 
 func parseDisabledOrInt64(val string, toSet *int64) error {
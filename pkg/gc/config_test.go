@@ -145,3 +145,35 @@ func TestOurConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestWithNamespaceOverrides(t *testing.T) {
+	base := defaultConfig()
+
+	got, err := base.WithNamespaceOverrides(map[string]string{"unrelated": "annotation"})
+	if err != nil || got != base {
+		t.Errorf("WithNamespaceOverrides(no overrides) = %v, %v, want the config itself", got, err)
+	}
+
+	got, err = base.WithNamespaceOverrides(map[string]string{
+		NamespaceOverridePrefix + "retain-since-create-time": "disabled",
+		NamespaceOverridePrefix + "min-non-active-revisions": "2",
+	})
+	if err != nil {
+		t.Fatal("WithNamespaceOverrides() =", err)
+	}
+	want := defaultConfig()
+	want.RetainSinceCreateTime = Disabled
+	want.MinNonActiveRevisions = 2
+	if !cmp.Equal(got, want) {
+		t.Error("WithNamespaceOverrides (-want, +got):", cmp.Diff(want, got))
+	}
+	if !cmp.Equal(base, defaultConfig()) {
+		t.Error("WithNamespaceOverrides() mutated the config")
+	}
+
+	if _, err := base.WithNamespaceOverrides(map[string]string{
+		NamespaceOverridePrefix + "min-non-active-revisions": "2000",
+	}); err == nil {
+		t.Error("WithNamespaceOverrides(min > max) = nil, want an error")
+	}
+}
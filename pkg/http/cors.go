@@ -0,0 +1,166 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"knative.dev/serving/pkg/apis/serving"
+)
+
+const (
+	// CORSHeaderName is the header the Ingress of a Route appends to the
+	// requests of its revisions, carrying the CORS policy of the Route for
+	// the queue-proxy to enforce.
+	CORSHeaderName = "Knative-Serving-CORS"
+
+	// DomainMappingCORSHeaderName is the header the Ingress of a
+	// DomainMapping appends to its requests, carrying the CORS policy of the
+	// DomainMapping. It takes precedence over the one of the Route.
+	DomainMappingCORSHeaderName = "Knative-Serving-Domain-CORS"
+)
+
+// CORSPolicy is the CORS policy of a Route or a DomainMapping.
+type CORSPolicy struct {
+	AllowOrigins []string `json:"origins"`
+	AllowMethods []string `json:"methods,omitempty"`
+	AllowHeaders []string `json:"headers,omitempty"`
+	// MaxAgeSeconds is how long the browsers may cache the preflight
+	// responses, the browser's default if zero.
+	MaxAgeSeconds int64 `json:"maxAge,omitempty"`
+}
+
+// CORSPolicyFromAnnotations returns the CORS policy of the validated
+// annotations of a Route or a DomainMapping, nil if they allow no origins.
+func CORSPolicyFromAnnotations(annos map[string]string) *CORSPolicy {
+	origins := splitList(annos[serving.CORSAllowOriginsKey])
+	if len(origins) == 0 {
+		return nil
+	}
+	p := &CORSPolicy{
+		AllowOrigins: origins,
+		AllowMethods: splitList(annos[serving.CORSAllowMethodsKey]),
+		AllowHeaders: splitList(annos[serving.CORSAllowHeadersKey]),
+	}
+	if d, err := time.ParseDuration(annos[serving.CORSMaxAgeKey]); err == nil {
+		p.MaxAgeSeconds = int64(d / time.Second)
+	}
+	return p
+}
+
+func splitList(s string) []string {
+	var ret []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// String serializes the policy as the value of the CORS headers.
+func (p *CORSPolicy) String() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+// ParseCORSPolicy parses the value of the CORS headers.
+func ParseCORSPolicy(s string) (*CORSPolicy, error) {
+	p := &CORSPolicy{}
+	if err := json.Unmarshal([]byte(s), p); err != nil {
+		return nil, fmt.Errorf("failed to parse CORS policy: %w", err)
+	}
+	return p, nil
+}
+
+func (p *CORSPolicy) allowsOrigin(origin string) bool {
+	for _, o := range p.AllowOrigins {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *CORSPolicy) allowsAll(list []string) bool {
+	return len(list) == 1 && list[0] == "*"
+}
+
+// NewCORSHandler enforces the CORS policy the requests carry in the
+// DomainMappingCORSHeaderName or the CORSHeaderName header, for the ingress
+// implementations that don't enforce it themselves. The preflight requests
+// are answered right away, the responses to the other cross-origin requests
+// of the allowed origins get the Access-Control-Allow-Origin header. The
+// policy headers are removed, so they aren't passed on to the application.
+func NewCORSHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := r.Header.Get(DomainMappingCORSHeaderName)
+		if policy == "" {
+			policy = r.Header.Get(CORSHeaderName)
+		}
+		r.Header.Del(DomainMappingCORSHeaderName)
+		r.Header.Del(CORSHeaderName)
+		origin := r.Header.Get("Origin")
+		if policy == "" || origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p, err := ParseCORSPolicy(policy)
+		if err != nil {
+			// The header is set by the Ingress, so this is not expected.
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		allowed := p.allowsOrigin(origin)
+		if allowed {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The preflight requests are answered here, whether the origin is
+		// allowed or not, and the browser enforces the policy.
+		if allowed {
+			methods := p.AllowMethods
+			if len(methods) == 0 {
+				methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+			}
+			h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if p.allowsAll(p.AllowHeaders) {
+				if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+					h.Set("Access-Control-Allow-Headers", reqHeaders)
+				}
+			} else if len(p.AllowHeaders) > 0 {
+				h.Set("Access-Control-Allow-Headers", strings.Join(p.AllowHeaders, ", "))
+			}
+			if p.MaxAgeSeconds > 0 {
+				h.Set("Access-Control-Max-Age", strconv.FormatInt(p.MaxAgeSeconds, 10))
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
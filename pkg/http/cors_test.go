@@ -0,0 +1,188 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"knative.dev/serving/pkg/apis/serving"
+)
+
+func TestCORSPolicyFromAnnotations(t *testing.T) {
+	if p := CORSPolicyFromAnnotations(map[string]string{serving.CORSMaxAgeKey: "1m"}); p != nil {
+		t.Errorf("CORSPolicyFromAnnotations() = %v, want nil without origins", p)
+	}
+
+	p := CORSPolicyFromAnnotations(map[string]string{
+		serving.CORSAllowOriginsKey: "https://example.com, http://localhost:8080",
+		serving.CORSAllowMethodsKey: "GET,PUT",
+		serving.CORSMaxAgeKey:       "10m",
+	})
+	want := &CORSPolicy{
+		AllowOrigins:  []string{"https://example.com", "http://localhost:8080"},
+		AllowMethods:  []string{"GET", "PUT"},
+		MaxAgeSeconds: 600,
+	}
+	if !cmp.Equal(p, want) {
+		t.Errorf("CORSPolicyFromAnnotations() = (-want, +got): %s", cmp.Diff(want, p))
+	}
+	got, err := ParseCORSPolicy(p.String())
+	if err != nil {
+		t.Fatal("ParseCORSPolicy() =", err)
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("ParseCORSPolicy() = (-want, +got): %s", cmp.Diff(want, got))
+	}
+}
+
+func TestCORSHandler(t *testing.T) {
+	routePolicy := (&CORSPolicy{
+		AllowOrigins:  []string{"https://example.com"},
+		AllowHeaders:  []string{"Content-Type"},
+		MaxAgeSeconds: 60,
+	}).String()
+	domainPolicy := (&CORSPolicy{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{"PUT"},
+		AllowHeaders: []string{"*"},
+	}).String()
+
+	tests := []struct {
+		name         string
+		method       string
+		headers      map[string]string
+		wantCode     int
+		wantHeaders  map[string]string
+		wantUpstream bool
+	}{{
+		name:         "no policy",
+		method:       http.MethodGet,
+		headers:      map[string]string{"Origin": "https://example.com"},
+		wantCode:     http.StatusOK,
+		wantHeaders:  map[string]string{"Access-Control-Allow-Origin": ""},
+		wantUpstream: true,
+	}, {
+		name:   "same origin",
+		method: http.MethodGet,
+		headers: map[string]string{
+			CORSHeaderName: routePolicy,
+		},
+		wantCode:     http.StatusOK,
+		wantHeaders:  map[string]string{"Access-Control-Allow-Origin": "", "Vary": ""},
+		wantUpstream: true,
+	}, {
+		name:   "allowed origin",
+		method: http.MethodGet,
+		headers: map[string]string{
+			CORSHeaderName: routePolicy,
+			"Origin":       "https://example.com",
+		},
+		wantCode: http.StatusOK,
+		wantHeaders: map[string]string{
+			"Access-Control-Allow-Origin": "https://example.com",
+			"Vary":                        "Origin",
+		},
+		wantUpstream: true,
+	}, {
+		name:   "other origin",
+		method: http.MethodGet,
+		headers: map[string]string{
+			CORSHeaderName: routePolicy,
+			"Origin":       "https://evil.example.com",
+		},
+		wantCode:     http.StatusOK,
+		wantHeaders:  map[string]string{"Access-Control-Allow-Origin": ""},
+		wantUpstream: true,
+	}, {
+		name:   "preflight",
+		method: http.MethodOptions,
+		headers: map[string]string{
+			CORSHeaderName:                  routePolicy,
+			"Origin":                        "https://example.com",
+			"Access-Control-Request-Method": "POST",
+		},
+		wantCode: http.StatusNoContent,
+		wantHeaders: map[string]string{
+			"Access-Control-Allow-Origin":  "https://example.com",
+			"Access-Control-Allow-Methods": "GET, HEAD, POST",
+			"Access-Control-Allow-Headers": "Content-Type",
+			"Access-Control-Max-Age":       "60",
+		},
+	}, {
+		name:   "preflight of other origin",
+		method: http.MethodOptions,
+		headers: map[string]string{
+			CORSHeaderName:                  routePolicy,
+			"Origin":                        "https://evil.example.com",
+			"Access-Control-Request-Method": "POST",
+		},
+		wantCode: http.StatusNoContent,
+		wantHeaders: map[string]string{
+			"Access-Control-Allow-Origin":  "",
+			"Access-Control-Allow-Methods": "",
+		},
+	}, {
+		name:   "domain mapping policy takes precedence",
+		method: http.MethodOptions,
+		headers: map[string]string{
+			CORSHeaderName:                   routePolicy,
+			DomainMappingCORSHeaderName:      domainPolicy,
+			"Origin":                         "https://evil.example.com",
+			"Access-Control-Request-Method":  "PUT",
+			"Access-Control-Request-Headers": "X-Custom",
+		},
+		wantCode: http.StatusNoContent,
+		wantHeaders: map[string]string{
+			"Access-Control-Allow-Origin":  "https://evil.example.com",
+			"Access-Control-Allow-Methods": "PUT",
+			"Access-Control-Allow-Headers": "X-Custom",
+			"Access-Control-Max-Age":       "",
+		},
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			upstream := false
+			h := NewCORSHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstream = true
+				if r.Header.Get(CORSHeaderName) != "" || r.Header.Get(DomainMappingCORSHeaderName) != "" {
+					t.Error("The CORS policy was passed on")
+				}
+			}))
+			req := httptest.NewRequest(tc.method, "http://example.com", nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantCode {
+				t.Errorf("Code = %d, want: %d", rec.Code, tc.wantCode)
+			}
+			if upstream != tc.wantUpstream {
+				t.Errorf("Passed on = %v, want: %v", upstream, tc.wantUpstream)
+			}
+			for k, want := range tc.wantHeaders {
+				if got := rec.Header().Get(k); got != want {
+					t.Errorf("%s = %q, want: %q", k, got, want)
+				}
+			}
+		})
+	}
+}
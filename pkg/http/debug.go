@@ -0,0 +1,100 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime/metrics"
+	"strconv"
+)
+
+// RuntimeMetricsPath is the path of the debug server the Go runtime metrics
+// are served at, as JSON.
+const RuntimeMetricsPath = "/debug/runtime-metrics"
+
+// NewDebugHandler returns the handler of the pprof profiles, under
+// /debug/pprof/, and of the Go runtime metrics, at RuntimeMetricsPath.
+func NewDebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc(RuntimeMetricsPath, serveRuntimeMetrics)
+	return mux
+}
+
+// NewDebugServer returns the server of the NewDebugHandler, listening on
+// localhost only, as the profiles expose the internals of the process and are
+// expensive to collect. They're reached with kubectl port-forward.
+func NewDebugServer(port int) *http.Server {
+	return &http.Server{
+		Addr:    net.JoinHostPort("127.0.0.1", strconv.Itoa(port)),
+		Handler: NewDebugHandler(),
+	}
+}
+
+// runtimeHistogram is the JSON form of a metrics.Float64Histogram.
+type runtimeHistogram struct {
+	Counts  []uint64  `json:"counts"`
+	Buckets []float64 `json:"buckets"`
+}
+
+func serveRuntimeMetrics(w http.ResponseWriter, _ *http.Request) {
+	descs := metrics.All()
+	samples := make([]metrics.Sample, len(descs))
+	for i := range descs {
+		samples[i].Name = descs[i].Name
+	}
+	metrics.Read(samples)
+
+	values := make(map[string]interface{}, len(samples))
+	for _, s := range samples {
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			values[s.Name] = s.Value.Uint64()
+		case metrics.KindFloat64:
+			values[s.Name] = s.Value.Float64()
+		case metrics.KindFloat64Histogram:
+			h := s.Value.Float64Histogram()
+			values[s.Name] = runtimeHistogram{Counts: h.Counts, Buckets: finiteBuckets(h.Buckets)}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(values)
+}
+
+// finiteBuckets replaces the infinite boundaries of the first and the last
+// buckets, which JSON can't encode, with the largest finite values.
+func finiteBuckets(buckets []float64) []float64 {
+	ret := make([]float64, len(buckets))
+	for i, b := range buckets {
+		switch {
+		case b > math.MaxFloat64:
+			b = math.MaxFloat64
+		case b < -math.MaxFloat64:
+			b = -math.MaxFloat64
+		}
+		ret[i] = b
+	}
+	return ret
+}
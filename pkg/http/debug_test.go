@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugHandler(t *testing.T) {
+	h := NewDebugHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Goroutine profile code = %d, want: %d", rec.Code, http.StatusOK)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, RuntimeMetricsPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Runtime metrics code = %d, want: %d", rec.Code, http.StatusOK)
+	}
+	var got map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal("Failed to parse the runtime metrics:", err)
+	}
+	for _, name := range []string{"/sched/goroutines:goroutines", "/gc/pauses:seconds"} {
+		if _, ok := got[name]; !ok {
+			t.Errorf("Runtime metric %s is missing", name)
+		}
+	}
+}
+
+func TestDebugServerListensOnLocalhost(t *testing.T) {
+	if got, want := NewDebugServer(8009).Addr, "127.0.0.1:8009"; got != want {
+		t.Errorf("Addr = %s, want: %s", got, want)
+	}
+}
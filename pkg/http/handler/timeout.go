@@ -30,11 +30,14 @@ import (
 
 type timeoutHandler struct {
 	handler          http.Handler
-	firstByteTimeout time.Duration
+	firstByteTimeout TimeoutFunc
 	idleTimeout      time.Duration
 	body             string
 }
 
+// TimeoutFunc returns the timeout of a request.
+type TimeoutFunc func(r *http.Request) time.Duration
+
 // NewTimeoutHandler returns a Handler that runs `h` with the
 // given timeout in which the first byte of the response must be written,
 // and with the given idle timeout
@@ -52,6 +55,12 @@ type timeoutHandler struct {
 //
 // The implementation is largely inspired by http.TimeoutHandler.
 func NewTimeoutHandler(h http.Handler, msg string, firstByteTimeout time.Duration, idleTimeout time.Duration) http.Handler {
+	return NewTimeoutFuncHandler(h, msg, func(*http.Request) time.Duration { return firstByteTimeout }, idleTimeout)
+}
+
+// NewTimeoutFuncHandler is like NewTimeoutHandler, but with a first byte
+// timeout depending on the request, as returned by firstByteTimeout.
+func NewTimeoutFuncHandler(h http.Handler, msg string, firstByteTimeout TimeoutFunc, idleTimeout time.Duration) http.Handler {
 	return &timeoutHandler{
 		handler:          h,
 		body:             msg,
@@ -75,10 +84,10 @@ func (h *timeoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				done <- p
 			}
 		}()
-		h.handler.ServeHTTP(tw, r.WithContext(ctx))
+		h.handler.ServeHTTP(tw, r.WithContext(context.WithValue(ctx, timeoutWriterKey{}, tw)))
 	}()
 
-	firstByteTimeout := getTimer(h.firstByteTimeout)
+	firstByteTimeout := getTimer(h.firstByteTimeout(r))
 	var firstByteTimeoutDrained bool
 	defer func() {
 		putTimer(firstByteTimeout, firstByteTimeoutDrained)
@@ -181,6 +190,15 @@ func (tw *timeoutWriter) WriteHeader(code int) {
 	tw.w.WriteHeader(code)
 }
 
+// markStarted records that the response started, without a write.
+func (tw *timeoutWriter) markStarted() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.timedOut {
+		tw.lastWriteTime = time.Now()
+	}
+}
+
 // tryFirstByteTimeoutAndWriteError writes an error to the responsewriter if
 // nothing has been written to the writer before. Returns whether
 // an error was written or not.
@@ -225,6 +243,18 @@ func (tw *timeoutWriter) timeoutAndWriteError(msg string) {
 	tw.timedOut = true
 }
 
+type timeoutWriterKey struct{}
+
+// ResponseStarted tells the timeout handler running the request of ctx, if
+// any, that the response started, so the first byte timeout doesn't fire even
+// though nothing was written yet. It's meant for the handlers holding back the
+// response header until the first byte of the body.
+func ResponseStarted(ctx context.Context) {
+	if tw, ok := ctx.Value(timeoutWriterKey{}).(*timeoutWriter); ok {
+		tw.markStarted()
+	}
+}
+
 var timerPool sync.Pool
 
 func getTimer(timeout time.Duration) *time.Timer {
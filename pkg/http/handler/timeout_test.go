@@ -307,6 +307,29 @@ func TestIdleTimeoutHandler(t *testing.T) {
 
 }
 
+func TestTimeoutFuncHandler(t *testing.T) {
+	h := NewTimeoutFuncHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}), "request timeout", func(r *http.Request) time.Duration {
+		if r.URL.Path == "/slow" {
+			return time.Minute
+		}
+		return 10 * time.Millisecond
+	}, 0 /*idleTimeout*/)
+
+	for path, want := range map[string]int{
+		"/slow": http.StatusOK,
+		"/fast": http.StatusGatewayTimeout,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil))
+		if rec.Code != want {
+			t.Errorf("Status of %s = %d, want %d", path, rec.Code, want)
+		}
+	}
+}
+
 func BenchmarkTimeoutHandler(b *testing.B) {
 	writes := [][]byte{[]byte("this"), []byte("is"), []byte("a"), []byte("test")}
 	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -17,6 +17,7 @@ limitations under the License.
 package http
 
 import (
+	"io"
 	"net/http"
 	"net/http/httputil"
 
@@ -53,6 +54,40 @@ func NewHeaderPruningReverseProxy(target, hostOverride string, headersToRemove [
 			for _, h := range headersToRemove {
 				req.Header.Del(h)
 			}
+
+			if b, ok := req.Body.(*trailerForwardingBody); ok {
+				b.to = req.Trailer
+			}
 		},
 	}
 }
+
+// ForwardRequestTrailers makes the proxies returned by NewHeaderPruningReverseProxy
+// forward the trailers of r to the target. httputil.ReverseProxy only copies the
+// trailers before the body is sent, which is before their values are known.
+// This is a no-op if r doesn't declare any trailers.
+func ForwardRequestTrailers(r *http.Request) {
+	if len(r.Trailer) == 0 || r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	r.Body = &trailerForwardingBody{ReadCloser: r.Body, from: r.Trailer}
+}
+
+// trailerForwardingBody copies the trailers of the incoming request to the
+// trailers of the outgoing request once the body has been read completely,
+// at which point the incoming trailers are populated and the outgoing ones
+// are yet to be written.
+type trailerForwardingBody struct {
+	io.ReadCloser
+	from, to http.Header
+}
+
+func (b *trailerForwardingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF && b.to != nil {
+		for k, v := range b.from {
+			b.to[k] = v
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
+	"go.opencensus.io/trace"
+	"golang.org/x/net/http/httpguts"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/websocket"
+)
+
+const (
+	// RequestIDHeaderKey is the config-network key of the header carrying
+	// the ID of a request. Request IDs are disabled if it's set to "".
+	RequestIDHeaderKey = "request-id-header"
+	// RequestIDGeneratorKey is the config-network key of how IDs are
+	// generated for the requests arriving without one.
+	RequestIDGeneratorKey = "request-id-generator"
+
+	// DefaultRequestIDHeader is the default header carrying request IDs.
+	DefaultRequestIDHeader = "X-Request-Id"
+)
+
+// RequestIDGenerator is how IDs are generated for requests without one.
+type RequestIDGenerator string
+
+const (
+	// RequestIDGeneratorUUIDv7 generates time ordered UUIDs, version 7, so
+	// the IDs sort by the time the requests arrived. It's the default.
+	RequestIDGeneratorUUIDv7 RequestIDGenerator = "uuidv7"
+	// RequestIDGeneratorTraceID reuses the trace ID of the request, so logs
+	// can be joined with traces by the request ID alone. Requests without a
+	// trace ID get a UUIDv7.
+	RequestIDGeneratorTraceID RequestIDGenerator = "trace-id"
+)
+
+// ParseRequestIDGenerator parses the request ID generator, defaulting to
+// RequestIDGeneratorUUIDv7.
+func ParseRequestIDGenerator(s string) (RequestIDGenerator, error) {
+	switch g := RequestIDGenerator(s); g {
+	case "":
+		return RequestIDGeneratorUUIDv7, nil
+	case RequestIDGeneratorUUIDv7, RequestIDGeneratorTraceID:
+		return g, nil
+	default:
+		return "", fmt.Errorf("unknown request ID generator %q", s)
+	}
+}
+
+// RequestIDConfig contains the request ID settings of config-network, which
+// aren't known to knative.dev/networking.
+type RequestIDConfig struct {
+	// Header is the header carrying the request ID, request IDs are disabled
+	// if empty.
+	Header    string
+	Generator RequestIDGenerator
+}
+
+// DeepCopy returns a copy of the RequestIDConfig.
+func (c *RequestIDConfig) DeepCopy() *RequestIDConfig {
+	cp := *c
+	return &cp
+}
+
+// NewRequestIDConfigFromMap creates a RequestIDConfig from the data of the
+// config-network ConfigMap. Unlike other keys, an empty header isn't replaced
+// by the default, as it disables request IDs.
+func NewRequestIDConfigFromMap(data map[string]string) (*RequestIDConfig, error) {
+	c := &RequestIDConfig{Header: DefaultRequestIDHeader}
+	if h, ok := data[RequestIDHeaderKey]; ok {
+		c.Header = h
+	}
+	if c.Header != "" && !httpguts.ValidHeaderFieldName(c.Header) {
+		return nil, fmt.Errorf("%s = %q is not a valid header name", RequestIDHeaderKey, c.Header)
+	}
+	g, err := ParseRequestIDGenerator(data[RequestIDGeneratorKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", RequestIDGeneratorKey, err)
+	}
+	c.Generator = g
+	return c, nil
+}
+
+// NewRequestIDConfigFromConfigMap creates a RequestIDConfig from the
+// config-network ConfigMap.
+func NewRequestIDConfigFromConfigMap(configMap *corev1.ConfigMap) (*RequestIDConfig, error) {
+	return NewRequestIDConfigFromMap(configMap.Data)
+}
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the request of ctx, empty if it has
+// none, as set by the RequestIDHandler.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestIDHandler makes sure every request carries an ID in header,
+// generating one for those that arrive without. The ID is passed on with the
+// request, added to its span if it's traced, made available by
+// RequestIDFromContext and returned in the response, replacing the one of the
+// backend, if any. If header is empty, next is returned unchanged.
+func NewRequestIDHandler(next http.Handler, header string, generator RequestIDGenerator) http.Handler {
+	if header == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(header)
+		if id == "" {
+			if generator == RequestIDGeneratorTraceID {
+				id = TraceID(r)
+			}
+			if id == "" {
+				id = newUUIDv7(time.Now())
+			}
+			r.Header.Set(header, id)
+		}
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		if span := trace.FromContext(ctx); span != nil {
+			span.AddAttributes(trace.StringAttribute("http.request_id", id))
+		}
+		rw := &requestIDWriter{writer: w, header: header, id: id}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+		// Responses without a body are written once the handler is done.
+		if !rw.wroteHeader {
+			w.Header().Set(header, id)
+		}
+	})
+}
+
+// TraceID returns the trace ID of the request's span, or the one it was sent
+// with if it isn't traced here, or "" if it has none.
+func TraceID(r *http.Request) string {
+	if sc, ok := SpanContext(r); ok {
+		return sc.TraceID.String()
+	}
+	return ""
+}
+
+// SpanContext returns the context of the request's span, or the one it was
+// sent with if it isn't traced here, or false if it has none.
+func SpanContext(r *http.Request) (trace.SpanContext, bool) {
+	if span := trace.FromContext(r.Context()); span != nil {
+		return span.SpanContext(), true
+	}
+	if sc, ok := (&b3.HTTPFormat{}).SpanContextFromRequest(r); ok {
+		return sc, true
+	}
+	return (&tracecontext.HTTPFormat{}).SpanContextFromRequest(r)
+}
+
+// newUUIDv7 returns a random UUID, version 7, of the given time, as
+// specified by RFC 9562.
+func newUUIDv7(now time.Time) string {
+	var u [16]byte
+	rand.Read(u[6:])
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(now.UnixNano()/int64(time.Millisecond)))
+	copy(u[:6], ms[2:])
+	u[6] = 0x70 | u[6]&0x0f // version 7
+	u[8] = 0x80 | u[8]&0x3f // variant 10
+
+	var s [36]byte
+	hex.Encode(s[0:8], u[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], u[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], u[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], u[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], u[10:])
+	return string(s[:])
+}
+
+var (
+	_ http.Flusher  = (*requestIDWriter)(nil)
+	_ http.Hijacker = (*requestIDWriter)(nil)
+)
+
+// requestIDWriter sets the request ID header of the response right before
+// it's written, so it replaces the one of a proxied response rather than
+// being added to it.
+type requestIDWriter struct {
+	writer      http.ResponseWriter
+	header, id  string
+	wroteHeader bool
+}
+
+// Header returns the header map that will be sent by WriteHeader.
+func (w *requestIDWriter) Header() http.Header {
+	return w.writer.Header()
+}
+
+// WriteHeader sets the request ID and sends the headers with the provided
+// status code.
+func (w *requestIDWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.writer.Header().Set(w.header, w.id)
+	}
+	w.writer.WriteHeader(code)
+}
+
+// Write writes the data to the connection as part of an HTTP reply.
+func (w *requestIDWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.writer.Write(p)
+}
+
+// Flush flushes the buffer to the client.
+func (w *requestIDWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack calls Hijack() on the wrapped http.ResponseWriter if it implements
+// http.Hijacker interface, which is required for net/http/httputil/reverseproxy
+// to handle connection upgrade/switching protocol.  Otherwise returns an error.
+func (w *requestIDWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return websocket.HijackIfPossible(w.writer)
+}
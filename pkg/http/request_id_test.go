@@ -0,0 +1,175 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	network "knative.dev/networking/pkg"
+	. "knative.dev/pkg/configmap/testing"
+)
+
+const testRequestIDHeader = "X-Test-Request-Id"
+
+var uuidv7Regexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// serveRequestID serves req by a RequestIDHandler, returning the ID seen by
+// the backend in the header and the context, and the response.
+func serveRequestID(req *http.Request, generator RequestIDGenerator, backend http.HandlerFunc) (string, string, *httptest.ResponseRecorder) {
+	var header, ctx string
+	h := NewRequestIDHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header, ctx = r.Header.Get(testRequestIDHeader), RequestIDFromContext(r.Context())
+		backend(w, r)
+	}), testRequestIDHeader, generator)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return header, ctx, rec
+}
+
+func TestRequestIDHandlerGenerates(t *testing.T) {
+	header, ctx, rec := serveRequestID(httptest.NewRequest(http.MethodGet, "/", nil), RequestIDGeneratorUUIDv7,
+		func(w http.ResponseWriter, r *http.Request) {})
+	if !uuidv7Regexp.MatchString(header) {
+		t.Errorf("Request ID = %q, want a UUIDv7", header)
+	}
+	if ctx != header {
+		t.Errorf("RequestIDFromContext() = %q, want %q", ctx, header)
+	}
+	if got := rec.Header().Get(testRequestIDHeader); got != header {
+		t.Errorf("Response request ID = %q, want %q", got, header)
+	}
+}
+
+func TestRequestIDHandlerPropagates(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(testRequestIDHeader, "incoming")
+	// The backend returning another ID, e.g. the one it was sent by a proxy,
+	// doesn't end up with two IDs in the response.
+	header, ctx, rec := serveRequestID(req, RequestIDGeneratorUUIDv7, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(testRequestIDHeader, "backend")
+		w.Write([]byte("hello"))
+	})
+	if header != "incoming" || ctx != "incoming" {
+		t.Errorf("Request ID = %q, RequestIDFromContext() = %q, want both %q", header, ctx, "incoming")
+	}
+	if got, want := rec.Header().Values(testRequestIDHeader), []string{"incoming"}; !cmp.Equal(got, want) {
+		t.Errorf("Response request IDs = %q, want %q", got, want)
+	}
+}
+
+func TestRequestIDHandlerTraceID(t *testing.T) {
+	const traceID = "463ac35c9f6413ad48485a3953bb6124"
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-B3-Traceid", traceID)
+	req.Header.Set("X-B3-Spanid", "a2fb4a1d1a96d312")
+	if header, _, _ := serveRequestID(req, RequestIDGeneratorTraceID, func(w http.ResponseWriter, r *http.Request) {}); header != traceID {
+		t.Errorf("Request ID = %q, want the trace ID %q", header, traceID)
+	}
+
+	// Requests without a trace ID fall back to UUIDv7s.
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	if header, _, _ := serveRequestID(req, RequestIDGeneratorTraceID, func(w http.ResponseWriter, r *http.Request) {}); !uuidv7Regexp.MatchString(header) {
+		t.Errorf("Request ID = %q, want a UUIDv7", header)
+	}
+}
+
+func TestRequestIDHandlerDisabled(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := NewRequestIDHandler(next, "", RequestIDGeneratorUUIDv7)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get(DefaultRequestIDHeader); got != "" {
+		t.Errorf("Response request ID = %q, want none", got)
+	}
+}
+
+func TestNewUUIDv7(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	a, b := newUUIDv7(now), newUUIDv7(now.Add(time.Millisecond))
+	if !uuidv7Regexp.MatchString(a) {
+		t.Errorf("newUUIDv7() = %q, want a UUIDv7", a)
+	}
+	if a[:8] != "018bcfe5" {
+		t.Errorf("newUUIDv7() = %q, want it to start with the time in milliseconds, 018bcfe5", a)
+	}
+	if a >= b {
+		t.Errorf("newUUIDv7() = %q, then %q a millisecond later, want them ordered", a, b)
+	}
+}
+
+func TestRequestIDConfigFromFile(t *testing.T) {
+	cm, example := ConfigMapsFromTestFile(t, network.ConfigName)
+	if _, err := NewRequestIDConfigFromMap(cm.Data); err != nil {
+		t.Error("NewRequestIDConfigFromMap(actual) =", err)
+	}
+	got, err := NewRequestIDConfigFromMap(example.Data)
+	if err != nil {
+		t.Fatal("NewRequestIDConfigFromMap(example) =", err)
+	}
+	if want := (&RequestIDConfig{Header: DefaultRequestIDHeader, Generator: RequestIDGeneratorUUIDv7}); !cmp.Equal(got, want) {
+		t.Error("Example stanza does not match default, diff(-want,+got):", cmp.Diff(want, got))
+	}
+}
+
+func TestNewRequestIDConfigFromMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    *RequestIDConfig
+		wantErr bool
+	}{{
+		name: "defaults",
+		want: &RequestIDConfig{Header: DefaultRequestIDHeader, Generator: RequestIDGeneratorUUIDv7},
+	}, {
+		name: "custom",
+		data: map[string]string{
+			RequestIDHeaderKey:    "X-Correlation-Id",
+			RequestIDGeneratorKey: "trace-id",
+		},
+		want: &RequestIDConfig{Header: "X-Correlation-Id", Generator: RequestIDGeneratorTraceID},
+	}, {
+		name: "disabled",
+		data: map[string]string{RequestIDHeaderKey: ""},
+		want: &RequestIDConfig{Generator: RequestIDGeneratorUUIDv7},
+	}, {
+		name:    "invalid header",
+		data:    map[string]string{RequestIDHeaderKey: "X Request Id"},
+		wantErr: true,
+	}, {
+		name:    "invalid generator",
+		data:    map[string]string{RequestIDGeneratorKey: "uuidv4"},
+		wantErr: true,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewRequestIDConfigFromMap(tc.data)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("NewRequestIDConfigFromMap() = %v, wantErr: %v", err, tc.wantErr)
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Error("Config differs (-want, +got):", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
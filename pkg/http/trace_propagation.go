@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+	ocpropagation "go.opencensus.io/trace/propagation"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/tracing/propagation/tracecontextb3"
+)
+
+// TracePropagationKey is the config-tracing key of the formats the trace
+// context is passed on in.
+const TracePropagationKey = "propagation"
+
+// TracePropagation is the formats the trace context is passed on in. The
+// incoming requests are read in either format, preferring W3C Trace Context.
+type TracePropagation string
+
+const (
+	// TracePropagationTraceContextB3 passes on the trace context in both the
+	// W3C traceparent and tracestate headers and the B3 headers. It's the
+	// default.
+	TracePropagationTraceContextB3 TracePropagation = "tracecontext-b3"
+	// TracePropagationTraceContext passes on the W3C headers only.
+	TracePropagationTraceContext TracePropagation = "tracecontext"
+	// TracePropagationB3 passes on the B3 headers only.
+	TracePropagationB3 TracePropagation = "b3"
+)
+
+// ParseTracePropagation parses the trace propagation, defaulting to
+// TracePropagationTraceContextB3.
+func ParseTracePropagation(s string) (TracePropagation, error) {
+	switch p := TracePropagation(s); p {
+	case "":
+		return TracePropagationTraceContextB3, nil
+	case TracePropagationTraceContextB3, TracePropagationTraceContext, TracePropagationB3:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown trace propagation %q", s)
+	}
+}
+
+// HTTPFormat returns the format reading and writing the trace context.
+func (p TracePropagation) HTTPFormat() ocpropagation.HTTPFormat {
+	switch p {
+	case TracePropagationTraceContext:
+		return tracecontextb3.TraceContextEgress
+	case TracePropagationB3:
+		return tracecontextb3.B3Egress
+	default:
+		return tracecontextb3.TraceContextB3Egress
+	}
+}
+
+// TracePropagationConfig contains the propagation settings of config-tracing,
+// which aren't known to knative.dev/pkg/tracing.
+type TracePropagationConfig struct {
+	Propagation TracePropagation
+}
+
+// DeepCopy returns a copy of the TracePropagationConfig.
+func (c *TracePropagationConfig) DeepCopy() *TracePropagationConfig {
+	cp := *c
+	return &cp
+}
+
+// NewTracePropagationConfigFromConfigMap creates a TracePropagationConfig from
+// the config-tracing ConfigMap.
+func NewTracePropagationConfigFromConfigMap(configMap *corev1.ConfigMap) (*TracePropagationConfig, error) {
+	p, err := ParseTracePropagation(configMap.Data[TracePropagationKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", TracePropagationKey, err)
+	}
+	return &TracePropagationConfig{Propagation: p}, nil
+}
+
+// ParseTraceSampleRate parses a sample rate, between 0 and 1.
+func ParseTraceSampleRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("sample rate %v is not between 0 and 1", rate)
+	}
+	return rate, nil
+}
+
+// NewTracingHandler creates spans for the requests, continuing the trace of
+// the context they carry in the format of propagation. The sampler returned
+// by sampler for a request, if not nil, replaces the one of config-tracing;
+// the traces sampled upstream stay sampled either way.
+func NewTracingHandler(next http.Handler, propagation ocpropagation.HTTPFormat, sampler func(*http.Request) trace.Sampler) http.Handler {
+	return &ochttp.Handler{
+		Handler:     next,
+		Propagation: propagation,
+		GetStartOptions: func(r *http.Request) trace.StartOptions {
+			if sampler == nil {
+				return trace.StartOptions{}
+			}
+			return trace.StartOptions{Sampler: sampler(r)}
+		},
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opencensus.io/trace"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNewTracePropagationConfigFromConfigMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    TracePropagation
+		wantErr bool
+	}{{
+		name: "default",
+		want: TracePropagationTraceContextB3,
+	}, {
+		name: "w3c only",
+		data: map[string]string{TracePropagationKey: "tracecontext"},
+		want: TracePropagationTraceContext,
+	}, {
+		name: "b3 only",
+		data: map[string]string{TracePropagationKey: "b3"},
+		want: TracePropagationB3,
+	}, {
+		name:    "unknown",
+		data:    map[string]string{TracePropagationKey: "jaeger"},
+		wantErr: true,
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewTracePropagationConfigFromConfigMap(&corev1.ConfigMap{Data: tc.data})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("NewTracePropagationConfigFromConfigMap() = %v, wantErr: %v", err, tc.wantErr)
+			}
+			if err == nil && c.Propagation != tc.want {
+				t.Errorf("Propagation = %q, want: %q", c.Propagation, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseTraceSampleRate(t *testing.T) {
+	for s, wantErr := range map[string]bool{"0": false, "0.25": false, "1": false, "-0.1": true, "2": true, "half": true} {
+		if _, err := ParseTraceSampleRate(s); (err != nil) != wantErr {
+			t.Errorf("ParseTraceSampleRate(%q) = %v, wantErr: %v", s, err, wantErr)
+		}
+	}
+}
+
+func TestTracingHandler(t *testing.T) {
+	const traceparent = "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-00"
+	for _, rate := range []float64{0, 1} {
+		var sc trace.SpanContext
+		h := NewTracingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sc = trace.FromContext(r.Context()).SpanContext()
+		}), TracePropagationTraceContext.HTTPFormat(), func(*http.Request) trace.Sampler {
+			return trace.ProbabilitySampler(rate)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("traceparent", traceparent)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if got, want := sc.TraceID.String(), "0102030405060708090a0b0c0d0e0f10"; got != want {
+			t.Errorf("TraceID = %s, want: %s", got, want)
+		}
+		if got, want := sc.IsSampled(), rate == 1; got != want {
+			t.Errorf("Sample rate %v: sampled = %v, want: %v", rate, got, want)
+		}
+	}
+}
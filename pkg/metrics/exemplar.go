@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/trace"
+)
+
+// TraceIDExemplarLabel is the label of the trace ID in the exemplars of the
+// Prometheus histograms, as expected by Grafana to link them to the traces.
+const TraceIDExemplarLabel = "trace_id"
+
+// SampledSpanContext returns the span context of the span of ctx, if there
+// is one and it's sampled, i.e. its trace can be looked up.
+func SampledSpanContext(ctx context.Context) (trace.SpanContext, bool) {
+	span := trace.FromContext(ctx)
+	if span == nil || !span.SpanContext().IsSampled() {
+		return trace.SpanContext{}, false
+	}
+	return span.SpanContext(), true
+}
+
+// ExemplarOptions returns the options recording the span context of sc as
+// the exemplar of the measurements, none if it's not sampled. The
+// distributions keep the exemplar of the last measurement of each bucket.
+func ExemplarOptions(sc trace.SpanContext) []stats.Options {
+	if !sc.IsSampled() {
+		return nil
+	}
+	return []stats.Options{stats.WithAttachments(metricdata.Attachments{
+		metricdata.AttachmentKeySpanContext: sc,
+	})}
+}
@@ -61,6 +61,16 @@ const (
 	// LabelResponseTimeout is the label timeout.
 	LabelResponseTimeout = metricskey.LabelResponseTimeout
 
+	// LabelHop is the label for the cluster-internal hop a request took, e.g.
+	// from the ingress to the activator.
+	LabelHop = "hop"
+
+	// The LabelHop values of the hops from the ingress to the activator and
+	// to the queue-proxy, and from the activator to the queue-proxy.
+	HopIngressActivator = "ingress-activator"
+	HopIngressQueue     = "ingress-queue-proxy"
+	HopActivatorQueue   = "activator-queue-proxy"
+
 	// ValueUnknown is the default value if the field is unknown, e.g. project will be unknown if Knative
 	// is not running on GKE.
 	ValueUnknown = metricskey.ValueUnknown
@@ -77,4 +87,6 @@ var (
 	ResponseCodeKey      = tag.MustNewKey(LabelResponseCode)
 	ResponseCodeClassKey = tag.MustNewKey(LabelResponseCodeClass)
 	RouteTagKey          = tag.MustNewKey(LabelRouteTag)
+	RouteKey             = tag.MustNewKey(LabelRouteName)
+	HopKey               = tag.MustNewKey(LabelHop)
 )
@@ -148,6 +148,15 @@ func AugmentWithResponseAndRouteTag(baseCtx context.Context, responseCode int, r
 	return ctx
 }
 
+// AugmentWithRoute augments the given context with route and route-tag specific tags.
+func AugmentWithRoute(baseCtx context.Context, route, routeTag string) context.Context {
+	ctx, _ := tag.New(
+		baseCtx,
+		tag.Upsert(RouteKey, valueOrUnknown(route)),
+		tag.Upsert(RouteTagKey, routeTag))
+	return ctx
+}
+
 // responseCodeClass converts response code to a string of response code class.
 // e.g. The response code class is "5xx" for response code 503.
 func responseCodeClass(responseCode int) string {
@@ -45,7 +45,7 @@ func register(t *testing.T) func() {
 			Description: "Number of pods autoscaler wants to allocate",
 			Measure:     testM,
 			Aggregation: view.LastValue(),
-			TagKeys:     []tag.Key{ResponseCodeKey, ResponseCodeClassKey, PodKey, ContainerKey},
+			TagKeys:     []tag.Key{ResponseCodeKey, ResponseCodeClassKey, PodKey, ContainerKey, RouteKey, RouteTagKey},
 		}); err != nil {
 		t.Fatal("Failed to register view:", err)
 	}
@@ -213,6 +213,39 @@ func TestContexts(t *testing.T) {
 				LabelRevisionName:      "testrev",
 			},
 		},
+	}, {
+		name: "pod revision context augmented with route",
+		ctx: mustCtx(t, func() (context.Context, error) {
+			ctx, err := PodRevisionContext("testpod", "testcontainer", "testns", "testsvc", "testcfg", "testrev")
+			return AugmentWithRoute(ctx, "testroute", "testtag"), err
+		}),
+		wantTags: map[string]string{
+			LabelPodName:       "testpod",
+			LabelContainerName: "testcontainer",
+			LabelRouteName:     "testroute",
+			LabelRouteTag:      "testtag",
+		},
+		wantResource: &resource.Resource{
+			Type: "knative_revision",
+			Labels: map[string]string{
+				LabelNamespaceName:     "testns",
+				LabelServiceName:       "testsvc",
+				LabelConfigurationName: "testcfg",
+				LabelRevisionName:      "testrev",
+			},
+		},
+	}, {
+		name: "pod context augmented with unknown route",
+		ctx: mustCtx(t, func() (context.Context, error) {
+			ctx, err := PodContext("testpod", "testcontainer")
+			return AugmentWithRoute(ctx, "", "DISABLED"), err
+		}),
+		wantTags: map[string]string{
+			LabelPodName:       "testpod",
+			LabelContainerName: "testcontainer",
+			LabelRouteName:     ValueUnknown,
+			LabelRouteTag:      "DISABLED",
+		},
 	}}
 
 	for _, test := range tests {
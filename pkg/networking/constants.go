@@ -26,10 +26,37 @@ const (
 	// BackendHTTP2Port is the backend, i.e. `targetPort` that we setup for HTTP/2 services.
 	BackendHTTP2Port = 8013
 
+	// BackendHTTPSPort is the backend port over which the queue-proxy serves
+	// the activator with mutual TLS, if enabled, or the activator and the
+	// ingress with TLS once internal encryption is enabled. The activator
+	// serves the ingress with TLS over it too.
+	BackendHTTPSPort = 8112
+
+	// BackendHTTPSPortName is the name of the port over which the queue-proxy
+	// serves the activator with mutual TLS.
+	BackendHTTPSPortName = "queue-https"
+
+	// ServiceHTTPSPort is the port of the public services of the revisions
+	// the ingress talks TLS to, once internal encryption is enabled.
+	ServiceHTTPSPort = 443
+
+	// ServicePortNameHTTPS is the name of the ServiceHTTPSPort.
+	ServicePortNameHTTPS = "https"
+
 	// QueueAdminPort specifies the port number for
 	// health check and lifecycle hooks for queue-proxy.
 	QueueAdminPort = 8022
 
+	// DebugPort is the localhost-only port over which the queue-proxy and
+	// the activator serve the pprof profiles and the Go runtime metrics, if
+	// enabled.
+	DebugPort = 8009
+
+	// QueueLocalAdminPort is the localhost-only port over which the
+	// queue-proxy serves the admin endpoints changing how it proxies, for the
+	// other containers of the pod only.
+	QueueLocalAdminPort = 8023
+
 	// AutoscalingQueueMetricsPort specifies the port number for metrics emitted
 	// by queue-proxy for autoscaler.
 	AutoscalingQueueMetricsPort = 9090
@@ -38,6 +65,10 @@ const (
 	// by queue-proxy for end user.
 	UserQueueMetricsPort = 9091
 
+	// AutoscalerStatGRPCPort is the port of the autoscaler service over which
+	// stats are streamed to the autoscaler with gRPC.
+	AutoscalerStatGRPCPort = 8081
+
 	// ActivatorServiceName is the name of the activator Kubernetes service.
 	ActivatorServiceName = "activator-service"
 
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networking
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	cm "knative.dev/pkg/configmap"
+)
+
+const (
+	// ActivatorMTLSKey is the config-network key enabling mutual TLS for the
+	// traffic between the activator and the queue-proxies. The queue-proxies
+	// of Revisions deployed before it was enabled don't serve mutual TLS, so
+	// those need to be redeployed.
+	ActivatorMTLSKey = "activator-mtls"
+
+	// InternalEncryptionKey is the config-network key enabling TLS for all the
+	// cluster-internal hops: from the ingress to the activator and to the
+	// queue-proxies, and from the activator to the queue-proxies. The ingress
+	// implementation reads it too, to talk TLS to the backends. As the
+	// ingress has no client certificate, the queue-proxies then don't require
+	// the one of the activator, even if ActivatorMTLSKey is set.
+	InternalEncryptionKey = "internal-encryption"
+
+	// ServingCertsMountPath is the directory the certificate of a revision,
+	// its key and the CA bundle are mounted to in the queue-proxy.
+	ServingCertsMountPath = "/var/lib/knative/serving-certs"
+
+	// The files of the certificate and its key, and of the CA bundle peers are
+	// verified with, within the directory of a certificate secret.
+	CertificateFile = "tls.crt"
+	KeyFile         = "tls.key"
+	CAFile          = "ca.crt"
+)
+
+// InternalTLSConfig contains the settings of config-network for the TLS
+// between the system components and the queue-proxies, which aren't known to
+// knative.dev/networking.
+type InternalTLSConfig struct {
+	// ActivatorMTLS makes the activator and the queue-proxies authenticate
+	// each other with the certificates issued by the serving-certs
+	// infrastructure.
+	ActivatorMTLS bool
+
+	// InternalEncryption makes the ingress, the activator and the
+	// queue-proxies talk TLS with each other, with the certificates issued by
+	// the serving-certs infrastructure.
+	InternalEncryption bool
+}
+
+// QueueTLS returns whether the queue-proxies serve TLS, to the activator
+// only or to the ingress too.
+func (c *InternalTLSConfig) QueueTLS() bool {
+	return c.ActivatorMTLS || c.InternalEncryption
+}
+
+// QueueMutualTLS returns whether the queue-proxies require the certificate
+// of the activator from their TLS clients.
+func (c *InternalTLSConfig) QueueMutualTLS() bool {
+	return c.ActivatorMTLS && !c.InternalEncryption
+}
+
+// DeepCopy returns a copy of the InternalTLSConfig.
+func (c *InternalTLSConfig) DeepCopy() *InternalTLSConfig {
+	cp := *c
+	return &cp
+}
+
+// NewInternalTLSConfigFromMap creates an InternalTLSConfig from the data of
+// the config-network ConfigMap.
+func NewInternalTLSConfigFromMap(data map[string]string) (*InternalTLSConfig, error) {
+	c := &InternalTLSConfig{}
+	if err := cm.Parse(data,
+		cm.AsBool(ActivatorMTLSKey, &c.ActivatorMTLS),
+		cm.AsBool(InternalEncryptionKey, &c.InternalEncryption),
+	); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewInternalTLSConfigFromConfigMap creates an InternalTLSConfig from the
+// config-network ConfigMap.
+func NewInternalTLSConfigFromConfigMap(configMap *corev1.ConfigMap) (*InternalTLSConfig, error) {
+	return NewInternalTLSConfigFromMap(configMap.Data)
+}
+
+// ServingCertsSecretName returns the name of the secret in the namespace of
+// the revision holding the certificate of its queue-proxies, as issued by the
+// serving-certs infrastructure.
+func ServingCertsSecretName(revision string) string {
+	return revision + "-serving-certs"
+}
+
+// RevisionCertificateSAN returns the DNS SAN the certificate of the
+// queue-proxies of the revision must have. The activator verifies it, so a
+// request can't be served by the pods of another revision.
+func RevisionCertificateSAN(namespace, revision string) string {
+	return revision + "." + namespace + ".revision.serving.knative.internal"
+}
+
+// ActivatorCertificateSAN returns the DNS SAN the certificate of the
+// activator in the system namespace must have, which the queue-proxies
+// verify.
+func ActivatorCertificateSAN(systemNamespace string) string {
+	return ActivatorServiceName + "." + systemNamespace + ".svc"
+}
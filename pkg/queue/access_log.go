@@ -0,0 +1,403 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pkghttp "knative.dev/serving/pkg/http"
+	"knative.dev/serving/pkg/logging"
+)
+
+// AccessLogFormat defines the shape of the access log entries.
+type AccessLogFormat string
+
+const (
+	// AccessLogFormatNone disables the access log.
+	AccessLogFormatNone AccessLogFormat = ""
+	// AccessLogFormatJSON writes an entry as a JSON object.
+	AccessLogFormatJSON AccessLogFormat = "json"
+	// AccessLogFormatCombined writes an entry in the Apache combined log
+	// format.
+	AccessLogFormatCombined AccessLogFormat = "combined"
+	// AccessLogFormatTemplate writes an entry by an AccessLogTemplate.
+	AccessLogFormatTemplate AccessLogFormat = "template"
+)
+
+// ParseAccessLogFormat parses an AccessLogFormat.
+func ParseAccessLogFormat(s string) (AccessLogFormat, error) {
+	switch f := AccessLogFormat(s); f {
+	case AccessLogFormatNone, AccessLogFormatJSON, AccessLogFormatCombined, AccessLogFormatTemplate:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown access log format %q", s)
+}
+
+const (
+	// AccessLogDestinationStdout writes the access log to stdout.
+	AccessLogDestinationStdout = "stdout"
+	// AccessLogDestinationFluentPrefix prefixes the path of the Unix domain
+	// socket of a local fluentd to send the access log to, e.g.
+	// "fluent:///var/run/fluent/fluent.sock".
+	AccessLogDestinationFluentPrefix = "fluent://"
+
+	// accessLogFluentTag is the tag of the events sent to fluentd.
+	accessLogFluentTag = "knative.access"
+	// accessLogWriteTimeout bounds the time writing an entry to fluentd may
+	// hold up a request.
+	accessLogWriteTimeout = time.Second
+)
+
+// FluentSocketPath returns the path of the fluentd socket of the access log
+// destination, or false if the destination isn't a fluentd socket.
+func FluentSocketPath(destination string) (string, bool) {
+	if !strings.HasPrefix(destination, AccessLogDestinationFluentPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(destination, AccessLogDestinationFluentPrefix), true
+}
+
+// ValidateAccessLogDestination checks an access log destination is either
+// stdout, the default if empty, or an absolute fluentd socket path.
+func ValidateAccessLogDestination(destination string) error {
+	if destination == "" || destination == AccessLogDestinationStdout {
+		return nil
+	}
+	path, ok := FluentSocketPath(destination)
+	if !ok {
+		return fmt.Errorf("unknown access log destination %q", destination)
+	}
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("fluent socket path %q is not absolute", path)
+	}
+	return nil
+}
+
+// accessLogEntry is what is known of a request once it's done.
+type accessLogEntry struct {
+	time     time.Time
+	request  *http.Request
+	revision *pkghttp.RequestLogRevision
+	status   int
+	bytes    int
+	latency  time.Duration
+	traceID  string
+	// requestID is the ID set by the request ID handler, if any.
+	requestID string
+}
+
+// accessLogFields are the fields available to an AccessLogTemplate.
+var accessLogFields = map[string]func(e *accessLogEntry) string{
+	"time":          func(e *accessLogEntry) string { return e.time.Format(time.RFC3339Nano) },
+	"method":        func(e *accessLogEntry) string { return e.request.Method },
+	"host":          func(e *accessLogEntry) string { return e.request.Host },
+	"path":          func(e *accessLogEntry) string { return e.request.URL.RequestURI() },
+	"protocol":      func(e *accessLogEntry) string { return e.request.Proto },
+	"remote_ip":     func(e *accessLogEntry) string { return remoteIP(e.request) },
+	"user_agent":    func(e *accessLogEntry) string { return e.request.UserAgent() },
+	"referer":       func(e *accessLogEntry) string { return e.request.Referer() },
+	"status":        func(e *accessLogEntry) string { return strconv.Itoa(e.status) },
+	"bytes":         func(e *accessLogEntry) string { return strconv.Itoa(e.bytes) },
+	"latency":       func(e *accessLogEntry) string { return formatLatency(e.latency) },
+	"namespace":     func(e *accessLogEntry) string { return e.revision.Namespace },
+	"service":       func(e *accessLogEntry) string { return e.revision.Service },
+	"configuration": func(e *accessLogEntry) string { return e.revision.Configuration },
+	"revision":      func(e *accessLogEntry) string { return e.revision.Name },
+	"pod":           func(e *accessLogEntry) string { return e.revision.PodName },
+	"trace_id":      func(e *accessLogEntry) string { return e.traceID },
+	"request_id":    func(e *accessLogEntry) string { return e.requestID },
+}
+
+// AccessLogTemplate is a line with $field placeholders, e.g.
+// "$status $bytes $latency $revision $trace_id", expanded for every request.
+// A literal $ is written as $$.
+type AccessLogTemplate struct {
+	// segments alternate between literal text, at even indices, and field
+	// names, at odd ones.
+	segments []string
+}
+
+// ParseAccessLogTemplate parses an AccessLogTemplate, failing on unknown
+// fields.
+func ParseAccessLogTemplate(s string) (*AccessLogTemplate, error) {
+	var (
+		segments []string
+		literal  strings.Builder
+	)
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			literal.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '$' {
+			literal.WriteByte('$')
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(s) && (s[j] >= 'a' && s[j] <= 'z' || s[j] == '_') {
+			j++
+		}
+		field := s[i+1 : j]
+		if _, ok := accessLogFields[field]; !ok {
+			return nil, fmt.Errorf("unknown access log field %q", field)
+		}
+		segments = append(segments, literal.String(), field)
+		literal.Reset()
+		i = j - 1
+	}
+	return &AccessLogTemplate{segments: append(segments, literal.String())}, nil
+}
+
+func (t *AccessLogTemplate) write(b *bytes.Buffer, e *accessLogEntry) {
+	for i, s := range t.segments {
+		if i%2 == 0 {
+			b.WriteString(s)
+		} else {
+			b.WriteString(accessLogFields[s](e))
+		}
+	}
+}
+
+// accessLogJSON is an access log entry in the JSON format.
+type accessLogJSON struct {
+	Time          string  `json:"time"`
+	Method        string  `json:"method"`
+	Host          string  `json:"host"`
+	Path          string  `json:"path"`
+	Protocol      string  `json:"protocol"`
+	RemoteIP      string  `json:"remoteIp"`
+	UserAgent     string  `json:"userAgent,omitempty"`
+	Referer       string  `json:"referer,omitempty"`
+	Status        int     `json:"status"`
+	Bytes         int     `json:"bytes"`
+	Latency       float64 `json:"latency"`
+	Namespace     string  `json:"namespace"`
+	Service       string  `json:"service,omitempty"`
+	Configuration string  `json:"configuration"`
+	Revision      string  `json:"revision"`
+	Pod           string  `json:"pod"`
+	TraceID       string  `json:"traceId,omitempty"`
+	RequestID     string  `json:"requestId,omitempty"`
+}
+
+func newAccessLogJSON(e *accessLogEntry) *accessLogJSON {
+	return &accessLogJSON{
+		Time:          e.time.Format(time.RFC3339Nano),
+		Method:        e.request.Method,
+		Host:          e.request.Host,
+		Path:          e.request.URL.RequestURI(),
+		Protocol:      e.request.Proto,
+		RemoteIP:      remoteIP(e.request),
+		UserAgent:     e.request.UserAgent(),
+		Referer:       e.request.Referer(),
+		Status:        e.status,
+		Bytes:         e.bytes,
+		Latency:       e.latency.Seconds(),
+		Namespace:     e.revision.Namespace,
+		Service:       e.revision.Service,
+		Configuration: e.revision.Configuration,
+		Revision:      e.revision.Name,
+		Pod:           e.revision.PodName,
+		TraceID:       e.traceID,
+		RequestID:     e.requestID,
+	}
+}
+
+// writeCombined writes the entry in the Apache combined log format.
+func writeCombined(b *bytes.Buffer, e *accessLogEntry) {
+	size := "-"
+	if e.bytes > 0 {
+		size = strconv.Itoa(e.bytes)
+	}
+	fmt.Fprintf(b, "%s - - [%s] %q %d %s %q %q",
+		remoteIP(e.request), e.time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.request.Method+" "+e.request.URL.RequestURI()+" "+e.request.Proto,
+		e.status, size, e.request.Referer(), e.request.UserAgent())
+}
+
+// AccessLogHandler writes an access log entry for every request passed on to
+// the next handler.
+type AccessLogHandler struct {
+	next     http.Handler
+	writer   io.Writer
+	format   AccessLogFormat
+	template *AccessLogTemplate
+	revision *pkghttp.RequestLogRevision
+	// fluent wraps every entry into a fluentd event.
+	fluent bool
+}
+
+// NewAccessLogHandler creates an AccessLogHandler writing entries of the format
+// to the destination. The template is only used by AccessLogFormatTemplate.
+// Entries carry the trace ID of the request's span and the request ID, so the
+// handler belongs inside the tracing middleware and the request ID handler.
+func NewAccessLogHandler(next http.Handler, format AccessLogFormat, template *AccessLogTemplate,
+	destination string, revision *pkghttp.RequestLogRevision) (*AccessLogHandler, error) {
+	if format == AccessLogFormatTemplate && template == nil {
+		return nil, fmt.Errorf("access log format %q requires a template", format)
+	}
+	if err := ValidateAccessLogDestination(destination); err != nil {
+		return nil, err
+	}
+
+	h := &AccessLogHandler{
+		next:     next,
+		format:   format,
+		template: template,
+		revision: revision,
+	}
+	if path, ok := FluentSocketPath(destination); ok {
+		h.writer = &fluentWriter{path: path}
+		h.fluent = true
+	} else {
+		h.writer = logging.NewSyncFileWriter(os.Stdout)
+	}
+	return h, nil
+}
+
+func (h *AccessLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rr := pkghttp.NewResponseRecorder(w, http.StatusOK)
+	start := time.Now()
+	defer func() {
+		e := &accessLogEntry{
+			time:      start,
+			request:   r,
+			revision:  h.revision,
+			status:    rr.ResponseCode,
+			bytes:     rr.ResponseSize,
+			latency:   time.Since(start),
+			traceID:   pkghttp.TraceID(r),
+			requestID: pkghttp.RequestIDFromContext(r.Context()),
+		}
+		// If ServeHTTP panics, log the failure and panic again.
+		if err := recover(); err != nil {
+			e.status, e.bytes = http.StatusInternalServerError, 0
+			h.write(e)
+			panic(err)
+		}
+		h.write(e)
+	}()
+	h.next.ServeHTTP(rr, r)
+}
+
+func (h *AccessLogHandler) write(e *accessLogEntry) {
+	// Expand the whole entry first, parallel writes would interleave
+	// otherwise.
+	b := bufPool.Get().(*bytes.Buffer)
+	b.Reset()
+	defer bufPool.Put(b)
+
+	if h.fluent {
+		fmt.Fprintf(b, "[%q,%d,", accessLogFluentTag, e.time.Unix())
+	}
+	if h.format == AccessLogFormatJSON {
+		// The encoder terminates the object with a newline.
+		json.NewEncoder(b).Encode(newAccessLogJSON(e))
+	} else {
+		var line bytes.Buffer
+		if h.format == AccessLogFormatCombined {
+			writeCombined(&line, e)
+		} else {
+			h.template.write(&line, e)
+		}
+		if h.fluent {
+			// fluentd takes a record rather than a line.
+			json.NewEncoder(b).Encode(map[string]string{"log": line.String()})
+		} else {
+			b.Write(line.Bytes())
+			b.WriteByte('\n')
+		}
+	}
+	if h.fluent {
+		// Close the event array in place of the trailing newline.
+		b.Truncate(b.Len() - 1)
+		b.WriteString("]\n")
+	}
+	h.writer.Write(b.Bytes())
+}
+
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// remoteIP returns the address of the client, the first X-Forwarded-For hop
+// if the request was forwarded.
+func remoteIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		return strings.TrimSpace(strings.SplitN(fwd, ",", 2)[0])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// formatLatency formats a latency in seconds.
+func formatLatency(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+// fluentWriter sends the access log to the Unix domain socket of a local
+// fluentd, dialing it on demand. Entries written while fluentd is
+// unreachable are dropped, so its absence doesn't hold up requests.
+type fluentWriter struct {
+	path string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (w *fluentWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// Redial once if the connection went stale.
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			conn, err := net.DialTimeout("unix", w.path, accessLogWriteTimeout)
+			if err != nil {
+				return 0, err
+			}
+			w.conn = conn
+		}
+		w.conn.SetWriteDeadline(time.Now().Add(accessLogWriteTimeout))
+		n, err := w.conn.Write(p)
+		if err == nil {
+			return n, nil
+		}
+		w.conn.Close()
+		w.conn = nil
+		if n > 0 {
+			// A partially written event can't be resent.
+			return n, err
+		}
+	}
+	return 0, io.ErrClosedPipe
+}
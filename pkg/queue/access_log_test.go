@@ -0,0 +1,255 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	pkghttp "knative.dev/serving/pkg/http"
+)
+
+const testTraceID = "463ac35c9f6413ad48485a3953bb6124"
+
+var testAccessLogRevision = &pkghttp.RequestLogRevision{
+	Name:          "rev",
+	Namespace:     "ns",
+	Service:       "svc",
+	Configuration: "cfg",
+	PodName:       "pod",
+	PodIP:         "10.0.0.1",
+}
+
+func accessLogRequest() *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/path?q=1", nil)
+	req.RemoteAddr = "10.1.1.1:4000"
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("X-B3-Traceid", testTraceID)
+	req.Header.Set("X-B3-Spanid", "a2fb4a1d1a96d312")
+	return req
+}
+
+func serveAccessLog(t *testing.T, format AccessLogFormat, template string) string {
+	t.Helper()
+	var tmpl *AccessLogTemplate
+	if template != "" {
+		var err error
+		if tmpl, err = ParseAccessLogTemplate(template); err != nil {
+			t.Fatal("ParseAccessLogTemplate() =", err)
+		}
+	}
+	h, err := NewAccessLogHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}), format, tmpl, AccessLogDestinationStdout, testAccessLogRevision)
+	if err != nil {
+		t.Fatal("NewAccessLogHandler() =", err)
+	}
+	var buf bytes.Buffer
+	h.writer = &buf
+
+	h.ServeHTTP(httptest.NewRecorder(), accessLogRequest())
+	return buf.String()
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	out := serveAccessLog(t, AccessLogFormatJSON, "")
+
+	var got accessLogJSON
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("Failed to decode entry %q: %v", out, err)
+	}
+	if got.Time == "" || got.Latency <= 0 {
+		t.Errorf("Time = %q, Latency = %v, want them set", got.Time, got.Latency)
+	}
+	got.Time, got.Latency = "", 0
+	want := accessLogJSON{
+		Method:        http.MethodGet,
+		Host:          "example.com",
+		Path:          "/path?q=1",
+		Protocol:      "HTTP/1.1",
+		RemoteIP:      "10.1.1.1",
+		UserAgent:     "test-agent",
+		Status:        http.StatusCreated,
+		Bytes:         len("hello"),
+		Namespace:     "ns",
+		Service:       "svc",
+		Configuration: "cfg",
+		Revision:      "rev",
+		Pod:           "pod",
+		TraceID:       testTraceID,
+	}
+	if !cmp.Equal(got, want) {
+		t.Error("Entry differs (-want, +got):", cmp.Diff(want, got))
+	}
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	out := serveAccessLog(t, AccessLogFormatCombined, "")
+
+	re := regexp.MustCompile(`^10\.1\.1\.1 - - \[[^\]]+\] "GET /path\?q=1 HTTP/1\.1" 201 5 "" "test-agent"\n$`)
+	if !re.MatchString(out) {
+		t.Errorf("Entry = %q, want to match %v", out, re)
+	}
+}
+
+func TestAccessLogTemplate(t *testing.T) {
+	out := serveAccessLog(t, AccessLogFormatTemplate, "$status $bytes $revision $trace_id $$$latency")
+
+	re := regexp.MustCompile(`^201 5 rev ` + testTraceID + ` \$[0-9.e-]+s\n$`)
+	if !re.MatchString(out) {
+		t.Errorf("Entry = %q, want to match %v", out, re)
+	}
+}
+
+func TestAccessLogRequestID(t *testing.T) {
+	tmpl := mustParseAccessLogTemplate(t, "$request_id")
+	h, err := NewAccessLogHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		AccessLogFormatTemplate, tmpl, AccessLogDestinationStdout, testAccessLogRevision)
+	if err != nil {
+		t.Fatal("NewAccessLogHandler() =", err)
+	}
+	var buf bytes.Buffer
+	h.writer = &buf
+
+	req := accessLogRequest()
+	req.Header.Set(pkghttp.DefaultRequestIDHeader, "req-1")
+	pkghttp.NewRequestIDHandler(h, pkghttp.DefaultRequestIDHeader, pkghttp.RequestIDGeneratorUUIDv7).
+		ServeHTTP(httptest.NewRecorder(), req)
+	if got, want := buf.String(), "req-1\n"; got != want {
+		t.Errorf("Entry = %q, want %q", got, want)
+	}
+}
+
+func TestAccessLogForwardedRemoteIP(t *testing.T) {
+	req := accessLogRequest()
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.2")
+	if got, want := remoteIP(req), "1.2.3.4"; got != want {
+		t.Errorf("remoteIP() = %q, want %q", got, want)
+	}
+}
+
+func TestAccessLogPanic(t *testing.T) {
+	h, err := NewAccessLogHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), AccessLogFormatTemplate, mustParseAccessLogTemplate(t, "$status"), "", testAccessLogRevision)
+	if err != nil {
+		t.Fatal("NewAccessLogHandler() =", err)
+	}
+	var buf bytes.Buffer
+	h.writer = &buf
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected the panic to be passed on")
+		}
+		if got, want := buf.String(), "500\n"; got != want {
+			t.Errorf("Entry = %q, want %q", got, want)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), accessLogRequest())
+}
+
+func TestAccessLogFluent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fluent.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	events := make(chan string, 2)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s := bufio.NewScanner(conn)
+		for s.Scan() {
+			events <- s.Text()
+		}
+	}()
+
+	h, err := NewAccessLogHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		AccessLogFormatTemplate, mustParseAccessLogTemplate(t, "$status $revision"),
+		AccessLogDestinationFluentPrefix+path, testAccessLogRevision)
+	if err != nil {
+		t.Fatal("NewAccessLogHandler() =", err)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), accessLogRequest())
+
+	var event []json.RawMessage
+	if err := json.Unmarshal([]byte(<-events), &event); err != nil || len(event) != 3 {
+		t.Fatalf("Event = %v, %v, want a [tag, time, record] array", event, err)
+	}
+	var tag string
+	json.Unmarshal(event[0], &tag)
+	if tag != accessLogFluentTag {
+		t.Errorf("Tag = %q, want %q", tag, accessLogFluentTag)
+	}
+	var record map[string]string
+	json.Unmarshal(event[2], &record)
+	if got, want := record, map[string]string{"log": "200 rev"}; !cmp.Equal(got, want) {
+		t.Errorf("Record = %v, want %v", got, want)
+	}
+}
+
+func TestParseAccessLogTemplateErrors(t *testing.T) {
+	for _, s := range []string{"$nope", "$", "$status $Status"} {
+		if _, err := ParseAccessLogTemplate(s); err == nil {
+			t.Errorf("ParseAccessLogTemplate(%q) = nil, want an error", s)
+		}
+	}
+}
+
+func TestValidateAccessLogDestination(t *testing.T) {
+	for d, valid := range map[string]bool{
+		"":                              true,
+		"stdout":                        true,
+		"fluent:///var/run/fluent.sock": true,
+		"fluent://relative.sock":        false,
+		"stderr":                        false,
+	} {
+		if err := ValidateAccessLogDestination(d); (err == nil) != valid {
+			t.Errorf("ValidateAccessLogDestination(%q) = %v, want valid: %v", d, err, valid)
+		}
+	}
+}
+
+func TestNewAccessLogHandlerRequiresTemplate(t *testing.T) {
+	if _, err := NewAccessLogHandler(http.NotFoundHandler(), AccessLogFormatTemplate, nil, "", testAccessLogRevision); err == nil {
+		t.Error("NewAccessLogHandler() = nil, want an error")
+	}
+}
+
+func mustParseAccessLogTemplate(t *testing.T, s string) *AccessLogTemplate {
+	t.Helper()
+	tmpl, err := ParseAccessLogTemplate(s)
+	if err != nil {
+		t.Fatal("ParseAccessLogTemplate() =", err)
+	}
+	return tmpl
+}
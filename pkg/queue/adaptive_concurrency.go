@@ -0,0 +1,170 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveConcurrencyInterval is how often the limit is adjusted to the
+	// latencies observed since the last adjustment.
+	adaptiveConcurrencyInterval = time.Second
+
+	// adaptiveConcurrencySmoothing is the weight of a new limit against the
+	// current one, so a single slow interval doesn't halve the limit.
+	adaptiveConcurrencySmoothing = 0.2
+
+	// minLatencyGradient bounds how far the limit shrinks in one adjustment.
+	minLatencyGradient = 0.5
+)
+
+// AdaptiveConcurrencyParams defines the parameters of an AdaptiveConcurrency.
+type AdaptiveConcurrencyParams struct {
+	// TargetLatency is the latency of the user container the limit is
+	// adjusted to keep requests at.
+	TargetLatency time.Duration
+	// MinLimit and MaxLimit bound the limit. MaxLimit is usually the container
+	// concurrency.
+	MinLimit int
+	MaxLimit int
+}
+
+// AdaptiveConcurrency adjusts the capacity of a Breaker to the latency of the
+// user container, in the manner of a gradient concurrency limiter: while the
+// average latency is below the target, the limit grows by its square root
+// each interval, and once it exceeds the target, the limit shrinks by the
+// ratio of the two. The limit only grows while the user container is using
+// at least half of it, so an idle pod doesn't drift to MaxLimit.
+type AdaptiveConcurrency struct {
+	breaker *Breaker
+	params  AdaptiveConcurrencyParams
+
+	mu    sync.Mutex
+	limit float64
+	// The latencies and the peak concurrency observed since the last
+	// adjustment.
+	latencySum  time.Duration
+	samples     int
+	inFlight    int
+	maxInFlight int
+}
+
+// NewAdaptiveConcurrency creates an AdaptiveConcurrency for the breaker,
+// starting at MaxLimit.
+func NewAdaptiveConcurrency(breaker *Breaker, params AdaptiveConcurrencyParams) *AdaptiveConcurrency {
+	if params.MinLimit < 1 {
+		params.MinLimit = 1
+	}
+	if params.MaxLimit < params.MinLimit {
+		params.MaxLimit = params.MinLimit
+	}
+	a := &AdaptiveConcurrency{
+		breaker: breaker,
+		params:  params,
+		limit:   float64(params.MaxLimit),
+	}
+	breaker.UpdateConcurrency(params.MaxLimit)
+	return a
+}
+
+// Limit returns the current concurrency limit.
+func (a *AdaptiveConcurrency) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.limit)
+}
+
+// Handler measures the latency of the requests passed to next. It belongs
+// behind the breaker, so the time requests wait for capacity isn't counted.
+func (a *AdaptiveConcurrency) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.started()
+		start := time.Now()
+		defer func() {
+			a.finished(time.Since(start))
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// started records a request passed to the user container.
+func (a *AdaptiveConcurrency) started() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.inFlight++
+	if a.inFlight > a.maxInFlight {
+		a.maxInFlight = a.inFlight
+	}
+}
+
+// finished records the latency of a request passed to the user container.
+func (a *AdaptiveConcurrency) finished(latency time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.inFlight--
+	a.latencySum += latency
+	a.samples++
+}
+
+// Run adjusts the limit every adaptiveConcurrencyInterval until ctx is done.
+func (a *AdaptiveConcurrency) Run(ctx context.Context) {
+	ticker := time.NewTicker(adaptiveConcurrencyInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.adjust()
+		}
+	}
+}
+
+// adjust updates the limit to the latencies observed since the last
+// adjustment and applies it to the breaker. Without samples, the limit is
+// kept.
+func (a *AdaptiveConcurrency) adjust() {
+	a.mu.Lock()
+	if a.samples == 0 {
+		a.mu.Unlock()
+		return
+	}
+	latency := a.latencySum / time.Duration(a.samples)
+	utilized := float64(a.maxInFlight) >= a.limit/2
+	a.latencySum, a.samples, a.maxInFlight = 0, 0, a.inFlight
+
+	next := a.limit
+	switch {
+	case latency > a.params.TargetLatency:
+		next *= math.Max(minLatencyGradient, float64(a.params.TargetLatency)/float64(latency))
+	case utilized:
+		// The square root allows for some queueing, so the limit keeps
+		// probing upwards while the latency is on target.
+		next += math.Sqrt(a.limit)
+	}
+	next = a.limit*(1-adaptiveConcurrencySmoothing) + next*adaptiveConcurrencySmoothing
+	a.limit = math.Max(float64(a.params.MinLimit), math.Min(float64(a.params.MaxLimit), next))
+	limit := int(a.limit)
+	a.mu.Unlock()
+
+	a.breaker.UpdateConcurrency(limit)
+}
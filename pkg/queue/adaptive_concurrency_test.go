@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	network "knative.dev/networking/pkg"
+)
+
+// interval simulates an adjustment interval of requests running
+// concurrently with the given latency.
+func interval(a *AdaptiveConcurrency, concurrency int, latency time.Duration) {
+	for i := 0; i < concurrency; i++ {
+		a.started()
+	}
+	for i := 0; i < concurrency; i++ {
+		a.finished(latency)
+	}
+	a.adjust()
+}
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 100, MaxConcurrency: 10, InitialCapacity: 0})
+	a := NewAdaptiveConcurrency(b, AdaptiveConcurrencyParams{TargetLatency: 100 * time.Millisecond, MinLimit: 2, MaxLimit: 10})
+	if got, want := a.Limit(), 10; got != want {
+		t.Fatalf("Initial Limit() = %d, want: %d", got, want)
+	}
+	if got, want := b.Capacity(), 10; got != want {
+		t.Fatalf("Initial Capacity() = %d, want: %d", got, want)
+	}
+
+	// Above the target latency, the limit shrinks down to MinLimit.
+	last := a.Limit()
+	interval(a, 10, 400*time.Millisecond)
+	if got := a.Limit(); got >= last {
+		t.Errorf("Limit() = %d after slow requests, want less than %d", got, last)
+	}
+	for i := 0; i < 50; i++ {
+		interval(a, 10, 400*time.Millisecond)
+	}
+	if got, want := a.Limit(), 2; got != want {
+		t.Errorf("Limit() = %d, want: %d", got, want)
+	}
+	if got, want := b.Capacity(), a.Limit(); got != want {
+		t.Errorf("Capacity() = %d, want: %d", got, want)
+	}
+
+	// Without requests, the limit is kept.
+	a.adjust()
+	if got, want := a.Limit(), 2; got != want {
+		t.Errorf("Limit() = %d without requests, want: %d", got, want)
+	}
+
+	// Below the target latency, the limit grows back up to MaxLimit.
+	for i := 0; i < 50; i++ {
+		interval(a, a.Limit(), 10*time.Millisecond)
+	}
+	if got, want := a.Limit(), 10; got != want {
+		t.Errorf("Limit() = %d, want: %d", got, want)
+	}
+	if got, want := b.Capacity(), 10; got != want {
+		t.Errorf("Capacity() = %d, want: %d", got, want)
+	}
+
+	// It doesn't grow while most of the limit is unused.
+	interval(a, 10, 400*time.Millisecond)
+	last = a.Limit()
+	interval(a, 1, 10*time.Millisecond)
+	if got := a.Limit(); got != last {
+		t.Errorf("Limit() = %d with spare capacity, want: %d", got, last)
+	}
+}
+
+func TestAdaptiveConcurrencyHandler(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 4, InitialCapacity: 4})
+	a := NewAdaptiveConcurrency(b, AdaptiveConcurrencyParams{TargetLatency: time.Millisecond, MaxLimit: 4})
+	h := a.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}))
+	for i := 0; i < 3; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	}
+
+	a.adjust()
+	if got, want := a.Limit(), 3; got != want {
+		t.Errorf("Limit() = %d, want: %d", got, want)
+	}
+}
+
+func TestPrometheusStatsReporterAdaptiveConcurrency(t *testing.T) {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, 10 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	b := NewBreaker(BreakerParams{QueueDepth: 100, MaxConcurrency: 10, InitialCapacity: 10})
+	a := NewAdaptiveConcurrency(b, AdaptiveConcurrencyParams{TargetLatency: 100 * time.Millisecond, MaxLimit: 10})
+	reporter.ObserveAdaptiveConcurrency(a)
+	interval(a, 10, time.Second)
+	reporter.Report(network.RequestStatsReport{})
+
+	m := dto.Metric{}
+	if err := adaptiveConcurrencyLimitGV.With(prometheus.Labels{
+		destinationNsLabel:     namespace,
+		destinationConfigLabel: config,
+		destinationRevLabel:    revision,
+		destinationPodLabel:    pod,
+	}).Write(&m); err != nil {
+		t.Fatal("Gauge.Write() error =", err)
+	}
+	if got, want := m.Gauge.GetValue(), float64(a.Limit()); got != want {
+		t.Errorf("Limit gauge = %v, want: %v", got, want)
+	}
+}
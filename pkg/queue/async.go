@@ -0,0 +1,531 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	network "knative.dev/networking/pkg"
+)
+
+const (
+	// AsyncPreference is the preference of the Prefer header of the requests
+	// to run asynchronously.
+	AsyncPreference = "respond-async"
+
+	// AsyncCallbackHeaderName is the header of an asynchronous request holding
+	// the URL its response is POSTed to.
+	AsyncCallbackHeaderName = "Knative-Async-Callback"
+
+	// AsyncRequestIDHeaderName is the header holding the tracking ID of an
+	// asynchronous request, in the 202 response to it and in its callback.
+	AsyncRequestIDHeaderName = "Knative-Async-Request-Id"
+
+	// AsyncStatusHeaderName is the header of the callback of an asynchronous
+	// request holding the status code of its response.
+	AsyncStatusHeaderName = "Knative-Async-Status"
+
+	// AsyncStatusPath is the path prefix of the status API of the asynchronous
+	// requests, followed by their tracking ID. Each queue-proxy only knows the
+	// status of the requests it accepted.
+	AsyncStatusPath = "/.knative/async/"
+
+	// asyncMaxBodyBytes is the maximum size of the body of an asynchronous
+	// request, and of its response.
+	asyncMaxBodyBytes = 10 << 20
+
+	// asyncMaxQueued is the maximum number of asynchronous requests queued.
+	asyncMaxQueued = 1000
+
+	// asyncDeliveryAttempts is the number of times the delivery of a response
+	// to its callback is attempted.
+	asyncDeliveryAttempts = 5
+
+	// asyncStatusRetention is how long the status of a finished asynchronous
+	// request can be polled for.
+	asyncStatusRetention = time.Hour
+
+	asyncFileSuffix = ".json"
+)
+
+// AsyncState is the state of an asynchronous request.
+type AsyncState string
+
+const (
+	// AsyncStateQueued is the state of the requests waiting to be run.
+	AsyncStateQueued AsyncState = "queued"
+	// AsyncStateRunning is the state of the requests being run or delivered.
+	AsyncStateRunning AsyncState = "running"
+	// AsyncStateDelivered is the state of the requests whose response was
+	// delivered to their callback.
+	AsyncStateDelivered AsyncState = "delivered"
+	// AsyncStateFailed is the state of the requests whose response couldn't
+	// be delivered to their callback.
+	AsyncStateFailed AsyncState = "failed"
+)
+
+// AsyncStatus is the status of an asynchronous request, as returned by the
+// status API.
+type AsyncStatus struct {
+	ID    string     `json:"id"`
+	State AsyncState `json:"state"`
+	// StatusCode is the status code of the response, once run.
+	StatusCode int `json:"statusCode,omitempty"`
+	// Error is why the response couldn't be delivered.
+	Error string `json:"error,omitempty"`
+
+	finished time.Time
+}
+
+// asyncRequest is an asynchronous request, as stored until its response is
+// delivered.
+type asyncRequest struct {
+	ID       string      `json:"id"`
+	Method   string      `json:"method"`
+	URI      string      `json:"uri"`
+	Host     string      `json:"host"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body,omitempty"`
+	Callback string      `json:"callback"`
+}
+
+// AsyncRequests runs the requests preferring to respond asynchronously in the
+// background, answering them with a 202 and a tracking ID right away, and
+// POSTs their response to the callback URL they carry, if its host is allowed.
+// The requests are stored in a directory until their response is delivered,
+// so the ones of a restarted queue-proxy are run once it's back, as long as
+// the directory outlives it, e.g. an emptyDir volume. Nothing outlives the
+// pod though: the queued requests count towards its concurrency, so it isn't
+// scaled down while they wait, and Drain runs them before it terminates, but
+// the ones left when it's killed are lost. Likewise, the status of a request
+// is only known to the AsyncRequests that accepted it.
+type AsyncRequests struct {
+	dir          string
+	client       *http.Client
+	allowedHosts []string
+	stats        *RequestStats
+	logger       *zap.SugaredLogger
+	queue        chan *asyncRequest
+	// stop is closed by Drain, done once the workers drained the queue.
+	stop, done chan struct{}
+	stopOnce   sync.Once
+
+	mu       sync.Mutex
+	draining bool
+	statuses map[string]*AsyncStatus
+}
+
+// NewAsyncRequests creates AsyncRequests storing the requests in dir, and
+// queues the requests left in it. The responses are only delivered to the
+// callbacks on allowedHosts, which are host names, or patterns like
+// "*.example.com" matching their subdomains. The queued requests are reported
+// to stats, if not nil, as in flight.
+func NewAsyncRequests(dir string, client *http.Client, allowedHosts []string, stats *RequestStats, logger *zap.SugaredLogger) (*AsyncRequests, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	a := &AsyncRequests{
+		dir:          dir,
+		client:       client,
+		allowedHosts: allowedHosts,
+		stats:        stats,
+		logger:       logger,
+		queue:        make(chan *asyncRequest, asyncMaxQueued),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+		statuses:     make(map[string]*AsyncStatus),
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+asyncFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		req := &asyncRequest{}
+		if err := json.Unmarshal(data, req); err != nil {
+			logger.Errorw("Dropping unreadable asynchronous request "+f, zap.Error(err))
+			os.Remove(f)
+			continue
+		}
+		if !a.enqueue(req) {
+			logger.Warn("Dropping the asynchronous requests over the queue size")
+			break
+		}
+	}
+	return a, nil
+}
+
+// Run runs the queued requests against next with the given number of
+// workers, until Drain is called and the queue is empty.
+func (a *AsyncRequests) Run(next http.Handler, workers int) {
+	defer close(a.done)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case req := <-a.queue:
+					a.dequeued(next, req)
+				case <-a.stop:
+					select {
+					case req := <-a.queue:
+						a.dequeued(next, req)
+					default:
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Drain stops queueing requests, and waits for the queued ones to be run and
+// their responses to be delivered, after which Run returns.
+func (a *AsyncRequests) Drain() {
+	a.stopOnce.Do(func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.draining = true
+		close(a.stop)
+	})
+	<-a.done
+}
+
+// dequeued runs a request taken off the queue, which is running rather than
+// queued from then on.
+func (a *AsyncRequests) dequeued(next http.Handler, req *asyncRequest) {
+	a.reportEvent(network.ReqOut)
+	a.run(context.Background(), next, req)
+}
+
+// reportEvent reports a request entering or leaving the queue to the stats.
+func (a *AsyncRequests) reportEvent(t network.ReqEventType) {
+	if a.stats != nil {
+		a.stats.HandleEvent(network.ReqEvent{Time: time.Now(), Type: t})
+	}
+}
+
+// callbackAllowed returns whether the responses may be delivered to the host.
+func (a *AsyncRequests) callbackAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range a.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler returns a handler queuing the requests preferring to respond
+// asynchronously and serving the status API, and passing the other requests
+// on to next.
+func (a *AsyncRequests) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, AsyncStatusPath) && r.Method == http.MethodGet {
+			a.serveStatus(w, strings.TrimPrefix(r.URL.Path, AsyncStatusPath))
+			return
+		}
+		if !prefersAsync(r.Header) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		callback := r.Header.Get(AsyncCallbackHeaderName)
+		if u, err := url.Parse(callback); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, fmt.Sprintf("the %s header must be an absolute http(s) URL", AsyncCallbackHeaderName), http.StatusBadRequest)
+			return
+		} else if !a.callbackAllowed(u.Hostname()) {
+			http.Error(w, fmt.Sprintf("the host of the %s header isn't allowed", AsyncCallbackHeaderName), http.StatusForbidden)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, asyncMaxBodyBytes+1))
+		if err != nil {
+			http.Error(w, "failed to read the request body", http.StatusBadRequest)
+			return
+		}
+		if len(body) > asyncMaxBodyBytes {
+			http.Error(w, "request body too large for an asynchronous request", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		header := r.Header.Clone()
+		header.Del("Prefer")
+		header.Del(AsyncCallbackHeaderName)
+		req := &asyncRequest{
+			ID:       newAsyncID(),
+			Method:   r.Method,
+			URI:      r.URL.RequestURI(),
+			Host:     r.Host,
+			Header:   header,
+			Body:     body,
+			Callback: callback,
+		}
+		if err := a.store(req); err != nil {
+			a.logger.Errorw("Failed to store asynchronous request", zap.Error(err))
+			http.Error(w, "failed to store the asynchronous request", http.StatusInternalServerError)
+			return
+		}
+		if !a.enqueue(req) {
+			a.remove(req)
+			http.Error(w, "too many asynchronous requests queued, or draining", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set(AsyncRequestIDHeaderName, req.ID)
+		w.Header().Set("Location", AsyncStatusPath+req.ID)
+		w.Header().Set("Preference-Applied", AsyncPreference)
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// Status returns the status of the asynchronous request with the given
+// tracking ID, if it is known.
+func (a *AsyncRequests) Status(id string) (AsyncStatus, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.statuses[id]
+	if !ok {
+		return AsyncStatus{}, false
+	}
+	return *s, true
+}
+
+func (a *AsyncRequests) serveStatus(w http.ResponseWriter, id string) {
+	s, ok := a.Status(id)
+	if !ok {
+		// The request might have been accepted by another pod.
+		http.Error(w, "unknown asynchronous request, or accepted by another pod", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// enqueue queues the request, unless the queue is full.
+func (a *AsyncRequests) enqueue(req *asyncRequest) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.draining {
+		return false
+	}
+	select {
+	case a.queue <- req:
+		a.statuses[req.ID] = &AsyncStatus{ID: req.ID, State: AsyncStateQueued}
+		a.reportEvent(network.ReqIn)
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *AsyncRequests) setStatus(id string, f func(*AsyncStatus)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	for id, s := range a.statuses {
+		if !s.finished.IsZero() && now.Sub(s.finished) > asyncStatusRetention {
+			delete(a.statuses, id)
+		}
+	}
+	if s, ok := a.statuses[id]; ok {
+		f(s)
+	}
+}
+
+// run runs the request against next and delivers its response.
+func (a *AsyncRequests) run(ctx context.Context, next http.Handler, req *asyncRequest) {
+	a.setStatus(req.ID, func(s *AsyncStatus) { s.State = AsyncStateRunning })
+
+	r, err := http.NewRequestWithContext(ctx, req.Method, req.URI, bytes.NewReader(req.Body))
+	if err != nil {
+		a.finish(req, 0, err)
+		return
+	}
+	r.Header = req.Header
+	r.Host = req.Host
+	r.RequestURI = req.URI
+	r.RemoteAddr = "127.0.0.1:0"
+	resp := newBufferedResponse()
+	next.ServeHTTP(resp, r)
+
+	a.setStatus(req.ID, func(s *AsyncStatus) { s.StatusCode = resp.status })
+	a.finish(req, resp.status, a.deliver(ctx, req, resp))
+}
+
+// deliver POSTs the response to the callback of the request, retrying with
+// an exponential backoff.
+func (a *AsyncRequests) deliver(ctx context.Context, req *asyncRequest, resp *bufferedResponse) error {
+	if resp.overflow {
+		return errors.New("response body too large for an asynchronous request")
+	}
+	// The allowed hosts might have changed since a reloaded request was queued.
+	if u, err := url.Parse(req.Callback); err != nil || !a.callbackAllowed(u.Hostname()) {
+		return fmt.Errorf("the host of callback %q isn't allowed", req.Callback)
+	}
+	backoff := time.Second
+	var err error
+	for attempt := 1; attempt <= asyncDeliveryAttempts; attempt++ {
+		if err = a.post(ctx, req, resp); err == nil {
+			return nil
+		}
+		a.logger.Warnw(fmt.Sprintf("Failed to deliver the response of asynchronous request %s (attempt %d)", req.ID, attempt), zap.Error(err))
+		if attempt == asyncDeliveryAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+func (a *AsyncRequests) post(ctx context.Context, req *asyncRequest, resp *bufferedResponse) error {
+	cb, err := http.NewRequestWithContext(ctx, http.MethodPost, req.Callback, bytes.NewReader(resp.body.Bytes()))
+	if err != nil {
+		return err
+	}
+	for k, v := range resp.header {
+		cb.Header[k] = v
+	}
+	cb.Header.Set(AsyncRequestIDHeaderName, req.ID)
+	cb.Header.Set(AsyncStatusHeaderName, strconv.Itoa(resp.status))
+	res, err := a.client.Do(cb)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("callback returned HTTP status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// finish records the outcome of the request and forgets it.
+func (a *AsyncRequests) finish(req *asyncRequest, status int, err error) {
+	a.setStatus(req.ID, func(s *AsyncStatus) {
+		s.State, s.StatusCode, s.finished = AsyncStateDelivered, status, time.Now()
+		if err != nil {
+			s.State, s.Error = AsyncStateFailed, err.Error()
+		}
+	})
+	if err != nil {
+		a.logger.Errorw("Failed to run asynchronous request "+req.ID, zap.Error(err))
+	}
+	a.remove(req)
+}
+
+func (a *AsyncRequests) path(req *asyncRequest) string {
+	return filepath.Join(a.dir, req.ID+asyncFileSuffix)
+}
+
+// store writes the request to its file, atomically.
+func (a *AsyncRequests) store(req *asyncRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	tmp := a.path(req) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.path(req))
+}
+
+func (a *AsyncRequests) remove(req *asyncRequest) {
+	if err := os.Remove(a.path(req)); err != nil && !os.IsNotExist(err) {
+		a.logger.Errorw("Failed to remove asynchronous request "+req.ID, zap.Error(err))
+	}
+}
+
+// prefersAsync returns whether the Prefer header holds the respond-async
+// preference.
+func prefersAsync(h http.Header) bool {
+	for _, v := range h.Values("Prefer") {
+		for _, p := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(strings.SplitN(p, ";", 2)[0]), AsyncPreference) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newAsyncID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// bufferedResponse is a ResponseWriter keeping the response in memory, up to
+// asyncMaxBodyBytes.
+type bufferedResponse struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header)}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	if b.body.Len()+len(p) > asyncMaxBodyBytes {
+		b.overflow = true
+		return len(p), nil
+	}
+	return b.body.Write(p)
+}
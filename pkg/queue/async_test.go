@@ -0,0 +1,294 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "knative.dev/pkg/logging/testing"
+)
+
+type callback struct {
+	id, status, body, contentType string
+}
+
+// newCallbackServer starts a server acting as the callback of the
+// asynchronous requests, which reports the responses it receives on the
+// returned channel.
+func newCallbackServer(t *testing.T) (string, chan callback) {
+	cbs := make(chan callback, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		cbs <- callback{
+			id:          r.Header.Get(AsyncRequestIDHeaderName),
+			status:      r.Header.Get(AsyncStatusHeaderName),
+			body:        string(body),
+			contentType: r.Header.Get("Content-Type"),
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, cbs
+}
+
+func TestAsyncRequests(t *testing.T) {
+	callbackURL, cbs := newCallbackServer(t)
+	a, err := NewAsyncRequests(t.TempDir(), http.DefaultClient, []string{"127.0.0.1"}, nil, TestLogger(t))
+	if err != nil {
+		t.Fatal("NewAsyncRequests() =", err)
+	}
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Prefer") != "" || r.Header.Get(AsyncCallbackHeaderName) != "" {
+			t.Error("The asynchronous request was passed on with its Prefer and callback headers")
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, r.Method+" "+r.URL.Path+" "+string(body))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/jobs", strings.NewReader("payload"))
+	req.Header.Set("Prefer", "wait=10, respond-async")
+	req.Header.Set(AsyncCallbackHeaderName, callbackURL)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got, want := rec.Code, http.StatusAccepted; got != want {
+		t.Fatalf("Code = %d, want: %d", got, want)
+	}
+	id := rec.Header().Get(AsyncRequestIDHeaderName)
+	if id == "" {
+		t.Fatal("No tracking ID in the response")
+	}
+	if got, want := rec.Header().Get("Location"), AsyncStatusPath+id; got != want {
+		t.Errorf("Location = %q, want: %q", got, want)
+	}
+	if status, _ := a.Status(id); status.State != AsyncStateQueued {
+		t.Errorf("State = %q, want: %q", status.State, AsyncStateQueued)
+	}
+
+	go a.Run(h, 1)
+	defer a.Drain()
+
+	select {
+	case got := <-cbs:
+		want := callback{id: id, status: "201", body: "POST /jobs payload", contentType: "text/plain"}
+		if got != want {
+			t.Errorf("Callback = %+v, want: %+v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The response wasn't delivered to the callback")
+	}
+
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if status, _ := a.Status(id); status.State == AsyncStateDelivered {
+			break
+		}
+	}
+	var status AsyncStatus
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com"+AsyncStatusPath+id, nil))
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatal("Failed to decode the status:", err)
+	}
+	if want := (AsyncStatus{ID: id, State: AsyncStateDelivered, StatusCode: http.StatusCreated}); status != want {
+		t.Errorf("Status = %+v, want: %+v", status, want)
+	}
+	if files, _ := filepath.Glob(filepath.Join(a.dir, "*")); len(files) != 0 {
+		t.Errorf("Files = %v, want the delivered request removed", files)
+	}
+}
+
+func TestAsyncRequestsRejected(t *testing.T) {
+	a, err := NewAsyncRequests(t.TempDir(), http.DefaultClient, []string{"*.example.com"}, nil, TestLogger(t))
+	if err != nil {
+		t.Fatal("NewAsyncRequests() =", err)
+	}
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "sync")
+	}))
+
+	tests := []struct {
+		name     string
+		prefer   string
+		callback string
+		path     string
+		body     string
+		want     int
+	}{{
+		name: "synchronous",
+		path: "/",
+		want: http.StatusOK,
+	}, {
+		name:     "other preference",
+		prefer:   "return=minimal",
+		callback: "http://callback.example.com",
+		path:     "/",
+		want:     http.StatusOK,
+	}, {
+		name:   "no callback",
+		prefer: AsyncPreference,
+		path:   "/",
+		want:   http.StatusBadRequest,
+	}, {
+		name:     "relative callback",
+		prefer:   AsyncPreference,
+		callback: "/callback",
+		path:     "/",
+		want:     http.StatusBadRequest,
+	}, {
+		name:     "callback host not allowed",
+		prefer:   AsyncPreference,
+		callback: "http://10.0.0.1/callback",
+		path:     "/",
+		want:     http.StatusForbidden,
+	}, {
+		name:     "cluster-local callback",
+		prefer:   AsyncPreference,
+		callback: "http://metadata.kube-system.svc.cluster.local",
+		path:     "/",
+		want:     http.StatusForbidden,
+	}, {
+		name:     "body too large",
+		prefer:   AsyncPreference,
+		callback: "http://callback.example.com",
+		path:     "/",
+		body:     strings.Repeat("x", asyncMaxBodyBytes+1),
+		want:     http.StatusRequestEntityTooLarge,
+	}, {
+		name: "unknown status",
+		path: AsyncStatusPath + "unknown",
+		want: http.StatusNotFound,
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://example.com"+tc.path, strings.NewReader(tc.body))
+			if strings.HasPrefix(tc.path, AsyncStatusPath) {
+				req.Method = http.MethodGet
+			}
+			if tc.prefer != "" {
+				req.Header.Set("Prefer", tc.prefer)
+			}
+			if tc.callback != "" {
+				req.Header.Set(AsyncCallbackHeaderName, tc.callback)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Errorf("Code = %d, want: %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
+
+func TestAsyncRequestsReloaded(t *testing.T) {
+	dir := t.TempDir()
+	req := &asyncRequest{ID: "left", Method: http.MethodGet, URI: "/", Callback: "http://callback.example.com"}
+	data, _ := json.Marshal(req)
+	if err := os.WriteFile(filepath.Join(dir, "left.json"), data, 0o600); err != nil {
+		t.Fatal("WriteFile() =", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "garbage.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal("WriteFile() =", err)
+	}
+
+	a, err := NewAsyncRequests(dir, http.DefaultClient, []string{"callback.example.com"}, nil, TestLogger(t))
+	if err != nil {
+		t.Fatal("NewAsyncRequests() =", err)
+	}
+	if status, ok := a.Status("left"); !ok || status.State != AsyncStateQueued {
+		t.Errorf("Status = %+v, want the stored request queued", status)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "garbage.json")); !os.IsNotExist(err) {
+		t.Error("The unreadable request wasn't removed")
+	}
+}
+
+func TestAsyncRequestsDrained(t *testing.T) {
+	callbackURL, cbs := newCallbackServer(t)
+	stats := NewRequestStats(time.Now())
+	a, err := NewAsyncRequests(t.TempDir(), http.DefaultClient, []string{"127.0.0.1"}, stats, TestLogger(t))
+	if err != nil {
+		t.Fatal("NewAsyncRequests() =", err)
+	}
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "done")
+	}))
+	asyncRequest := func() int {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/jobs", nil)
+		req.Header.Set("Prefer", AsyncPreference)
+		req.Header.Set(AsyncCallbackHeaderName, callbackURL)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if got, want := asyncRequest(), http.StatusAccepted; got != want {
+			t.Fatalf("Code = %d, want: %d", got, want)
+		}
+	}
+	now := time.Now()
+	stats.Report(now)
+	if got, want := stats.Report(now.Add(time.Second)).AverageConcurrency, 2.; got != want {
+		t.Errorf("AverageConcurrency = %v with the requests queued, want: %v", got, want)
+	}
+
+	go a.Run(h, 1)
+	a.Drain()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-cbs:
+		default:
+			t.Fatal("The queued responses weren't delivered by the time Drain returned")
+		}
+	}
+	now = time.Now()
+	stats.Report(now)
+	if got, want := stats.Report(now.Add(time.Second)).AverageConcurrency, 0.; got != want {
+		t.Errorf("AverageConcurrency = %v once drained, want: %v", got, want)
+	}
+	if got, want := asyncRequest(), http.StatusServiceUnavailable; got != want {
+		t.Errorf("Code = %d once drained, want: %d", got, want)
+	}
+}
+
+func TestPrefersAsync(t *testing.T) {
+	tests := []struct {
+		prefer []string
+		want   bool
+	}{
+		{nil, false},
+		{[]string{"respond-async"}, true},
+		{[]string{"Respond-Async; foo=bar"}, true},
+		{[]string{"return=minimal", "wait=5, respond-async"}, true},
+		{[]string{"respond-asynchronously"}, false},
+	}
+	for _, tc := range tests {
+		h := http.Header{"Prefer": tc.prefer}
+		if got := prefersAsync(h); got != tc.want {
+			t.Errorf("prefersAsync(%v) = %v, want: %v", tc.prefer, got, tc.want)
+		}
+	}
+}
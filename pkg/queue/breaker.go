@@ -21,6 +21,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sync"
+	"time"
 
 	"go.uber.org/atomic"
 )
@@ -30,6 +32,18 @@ var (
 	ErrRequestQueueFull = errors.New("pending request queue full")
 )
 
+// breakerEventBuffer is the number of events buffered per subscriber before
+// the oldest ones are dropped.
+const breakerEventBuffer = 16
+
+// queueWaitEWMAWeight is the weight of a request in the moving average of the
+// time the requests wait for a slot. The average follows the last few dozen
+// requests.
+const queueWaitEWMAWeight = 0.05
+
+// minQueueWait is the average queue wait, in seconds, below which it's 0.
+const minQueueWait = 1e-6
+
 // MaxBreakerCapacity is the largest valid value for the MaxConcurrency value of BreakerParams.
 // This is limited by the maximum size of a chan struct{} in the current implementation.
 const MaxBreakerCapacity = math.MaxInt32
@@ -39,6 +53,42 @@ type BreakerParams struct {
 	QueueDepth      int
 	MaxConcurrency  int
 	InitialCapacity int
+	// Priorities is the number of priorities, from 0 to Priorities-1, by
+	// which requests waiting for capacity are admitted, as attached to their
+	// context by WithRequestPriority. With one priority or none, they're
+	// admitted in no particular order.
+	Priorities int
+	// StarvationLimit is the number of requests of higher priority a waiting
+	// request is passed by at most, before it's admitted regardless of its
+	// priority. 0 disables the starvation protection.
+	StarvationLimit int
+	// MaxRequestWeight is the largest number of slots a single request takes,
+	// as attached to its context by WithRequestWeight. Up to 1, every request
+	// takes one slot. A request never takes more than the capacity, so it's
+	// admitted once the breaker is otherwise idle.
+	MaxRequestWeight int
+}
+
+// BreakerEventType is the type of a BreakerEvent.
+type BreakerEventType int
+
+const (
+	// BreakerSaturated is emitted when the breaker's queue becomes full.
+	BreakerSaturated BreakerEventType = iota
+	// BreakerIdle is emitted when a saturated breaker drained to no requests
+	// in flight.
+	BreakerIdle
+	// BreakerCapacityUpdated is emitted when the capacity of the breaker
+	// changed.
+	BreakerCapacityUpdated
+)
+
+// BreakerEvent is a change in the state of a Breaker, as observed by its
+// subscribers.
+type BreakerEvent struct {
+	Type     BreakerEventType
+	InFlight int
+	Capacity int
 }
 
 // Breaker is a component that enforces a concurrency limit on the
@@ -46,13 +96,40 @@ type BreakerParams struct {
 // executions in excess of the concurrency limit. Function call attempts
 // beyond the limit of the queue are failed immediately.
 type Breaker struct {
+	// inFlight counts the admitted requests, both the pending and the
+	// executing ones.
 	inFlight   atomic.Int64
 	totalSlots int64
 	sem        *semaphore
+	// gate orders the requests waiting for the semaphore by their priority.
+	// It's nil if the breaker has no priorities.
+	gate *priorityGate
+	// maxWeight caps the number of slots a request takes.
+	maxWeight int
+	// pending counts the admitted requests waiting for the semaphore. It's
+	// kept separately, rather than derived from inFlight and the semaphore,
+	// which change independently and can't be read at once.
+	pending atomic.Int64
 
 	// release is the callback function returned to callers by Reserve to
 	// allow the reservation made by Reserve to be released.
 	release func()
+
+	// saturated is set when the queue filled up and reset once the breaker
+	// drained, so that events are only emitted on transitions.
+	saturated atomic.Bool
+	// saturationEpisodes counts the transitions into saturation.
+	saturationEpisodes atomic.Uint64
+	// saturatedSince is the time in Unix nanoseconds since which the queue has
+	// been full, or 0 if it has room. Unlike saturated, it's reset as soon as
+	// a request leaves the full queue.
+	saturatedSince atomic.Int64
+	// queueWait is the exponentially weighted moving average of the time the
+	// admitted requests waited for a slot, in seconds.
+	queueWait atomic.Float64
+
+	mu          sync.Mutex
+	subscribers map[chan BreakerEvent]struct{}
 }
 
 // NewBreaker creates a Breaker with the desired queue depth,
@@ -68,14 +145,22 @@ func NewBreaker(params BreakerParams) *Breaker {
 		panic(fmt.Sprintf("Initial capacity must be between 0 and max concurrency. Got %v.", params.InitialCapacity))
 	}
 
+	if params.StarvationLimit < 0 {
+		panic(fmt.Sprintf("Starvation limit must be 0 or greater. Got %v.", params.StarvationLimit))
+	}
+
 	b := &Breaker{
 		totalSlots: int64(params.QueueDepth + params.MaxConcurrency),
 		sem:        newSemaphore(params.MaxConcurrency, params.InitialCapacity),
+		maxWeight:  params.MaxRequestWeight,
+	}
+	if params.Priorities > 1 {
+		b.gate = newPriorityGate(b.sem, params.Priorities, params.StarvationLimit)
 	}
 
 	// Allocating the closure returned by Reserve here avoids an allocation in Reserve.
 	b.release = func() {
-		b.sem.release()
+		b.releaseSlots(1)
 		b.releasePending()
 	}
 
@@ -104,6 +189,13 @@ func (b *Breaker) tryAcquirePending() bool {
 			return false
 		}
 		if b.inFlight.CAS(cur, cur+1) {
+			if cur+1 == b.totalSlots {
+				b.updateSaturatedSince()
+				if b.saturated.CAS(false, true) {
+					b.saturationEpisodes.Inc()
+					b.publish(BreakerSaturated)
+				}
+			}
 			return true
 		}
 	}
@@ -111,11 +203,65 @@ func (b *Breaker) tryAcquirePending() bool {
 
 // releasePending releases a slot on the pending "queue".
 func (b *Breaker) releasePending() {
-	b.inFlight.Dec()
+	inFlight := b.inFlight.Dec()
+	if inFlight == b.totalSlots-1 {
+		b.updateSaturatedSince()
+	}
+	if inFlight == 0 && b.saturated.Load() && b.saturated.CAS(true, false) {
+		b.publish(BreakerIdle)
+	}
+}
+
+// updateSaturatedSince updates saturatedSince after the queue filled up or
+// got room again. Racing updates are settled by the number of requests in
+// flight, which the last of them observes.
+func (b *Breaker) updateSaturatedSince() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch full := b.inFlight.Load() == b.totalSlots; {
+	case full && b.saturatedSince.Load() == 0:
+		b.saturatedSince.Store(time.Now().UnixNano())
+	case !full:
+		b.saturatedSince.Store(0)
+	}
+}
+
+// RequestWeight returns the number of slots a request with the given context
+// takes, i.e. its weight attached by WithRequestWeight capped at
+// MaxRequestWeight.
+func (b *Breaker) RequestWeight(ctx context.Context) int {
+	if b.maxWeight <= 1 {
+		return 1
+	}
+	w := requestWeight(ctx)
+	if w > b.maxWeight {
+		return b.maxWeight
+	}
+	return w
+}
+
+// acquireSlots waits for capacity for a request of the given weight in the
+// semaphore, in the order of the request's priority if the breaker has
+// priorities, and returns the number of slots acquired.
+func (b *Breaker) acquireSlots(ctx context.Context, weight uint64) (uint64, error) {
+	if b.gate != nil {
+		return b.gate.acquire(ctx, requestPriority(ctx), weight)
+	}
+	return b.sem.acquireN(ctx, weight)
+}
+
+// releaseSlots releases n slots in the semaphore.
+func (b *Breaker) releaseSlots(n uint64) {
+	if b.gate != nil {
+		b.gate.release(n)
+		return
+	}
+	b.sem.releaseN(n)
 }
 
 // Reserve reserves an execution slot in the breaker, to permit
-// richer semantics in the caller.
+// richer semantics in the caller. As it doesn't wait, priorities don't apply,
+// and the reservation is for a single slot, regardless of the request weight.
 // The caller on success must execute the callback when done with work.
 func (b *Breaker) Reserve(ctx context.Context) (func(), bool) {
 	if !b.tryAcquirePending() {
@@ -139,17 +285,37 @@ func (b *Breaker) Maybe(ctx context.Context, thunk func()) error {
 		return ErrRequestQueueFull
 	}
 
-	defer b.releasePending()
-
-	// Wait for capacity in the active queue.
-	if err := b.sem.acquire(ctx); err != nil {
-		return err
+	// Wait for capacity in the active queue, unless there's some right away.
+	// With priorities, the gate decides whether there is.
+	weight := uint64(b.RequestWeight(ctx))
+	var acquired uint64
+	if b.gate == nil {
+		acquired = b.sem.tryAcquireN(weight)
+	}
+	if acquired == 0 {
+		b.pending.Inc()
+		queued := time.Now()
+		var err error
+		acquired, err = b.acquireSlots(ctx, weight)
+		b.pending.Dec()
+		if err != nil {
+			b.releasePending()
+			return err
+		}
+		b.recordQueueWait(time.Since(queued).Seconds())
+	} else {
+		b.recordQueueWait(0)
 	}
-	// Defer releasing capacity in the active.
+	// Defer releasing capacity in the active and the pending queue.
 	// It's safe to ignore the error returned by release since we
 	// make sure the semaphore is only manipulated here and acquire
-	// + release calls are equally paired.
-	defer b.sem.release()
+	// + release calls are equally paired. A stream released by Streams
+	// gives its capacity back once it's established.
+	if s := streamOf(ctx); s != nil {
+		defer s.releaseWith(func() { b.releaseAcquired(acquired) })()
+	} else {
+		defer b.releaseAcquired(acquired)
+	}
 
 	// Do the thing.
 	thunk()
@@ -157,14 +323,146 @@ func (b *Breaker) Maybe(ctx context.Context, thunk func()) error {
 	return nil
 }
 
+// releaseAcquired releases the slots a request acquired and its place in the
+// pending queue.
+func (b *Breaker) releaseAcquired(acquired uint64) {
+	b.releaseSlots(acquired)
+	b.releasePending()
+}
+
+// recordQueueWait adds the time a request waited for a slot, in seconds, to
+// the moving average.
+func (b *Breaker) recordQueueWait(wait float64) {
+	for {
+		cur := b.queueWait.Load()
+		// Without queueing, the average stays at 0 without contending for it.
+		if cur == 0 && wait == 0 {
+			return
+		}
+		next := cur + queueWaitEWMAWeight*(wait-cur)
+		// Let the average decay to 0 rather than approach it forever.
+		if next < minQueueWait {
+			next = 0
+		}
+		if b.queueWait.CAS(cur, next) {
+			return
+		}
+	}
+}
+
+// QueueWait returns the exponentially weighted moving average of the time the
+// requests admitted by this breaker waited for a slot, counting those that
+// didn't wait.
+func (b *Breaker) QueueWait() time.Duration {
+	return time.Duration(b.queueWait.Load() * float64(time.Second))
+}
+
 // InFlight returns the number of requests currently in flight in this breaker.
 func (b *Breaker) InFlight() int {
 	return int(b.inFlight.Load())
 }
 
+// QueueDepth returns the number of requests in this breaker waiting for a
+// slot to become free. It's the same as PendingRequests.
+func (b *Breaker) QueueDepth() int {
+	return b.PendingRequests()
+}
+
+// PendingRequests returns the number of requests in this breaker waiting for
+// a slot to become free.
+func (b *Breaker) PendingRequests() int {
+	return int(b.pending.Load())
+}
+
+// PendingRequestsByPriority returns the number of requests in this breaker
+// waiting for a slot to become free per priority, or nil if the breaker has
+// no priorities.
+func (b *Breaker) PendingRequestsByPriority() []int {
+	if b.gate == nil {
+		return nil
+	}
+	return b.gate.pendingByPriority()
+}
+
+// SaturationEpisodes returns the number of times the breaker's queue filled
+// up. A sustained saturation counts once, no matter how many requests are
+// rejected, until the breaker drained to no requests in flight.
+func (b *Breaker) SaturationEpisodes() uint64 {
+	return b.saturationEpisodes.Load()
+}
+
+// SaturatedSince returns the time at which the breaker's queue filled up, if
+// it has been full ever since. Any request leaving the full queue ends the
+// saturation, unlike the episodes counted by SaturationEpisodes, which only
+// end once the breaker drained to no requests in flight.
+func (b *Breaker) SaturatedSince() (time.Time, bool) {
+	if since := b.saturatedSince.Load(); since != 0 {
+		return time.Unix(0, since), true
+	}
+	return time.Time{}, false
+}
+
 // UpdateConcurrency updates the maximum number of in-flight requests.
+// The update is atomic with respect to concurrent Maybe and Reserve calls:
+// requests already admitted keep running, waiting requests are woken up if
+// capacity grows and new requests observe either the old or the new capacity.
+// The size is clamped to [0, MaxConcurrency].
 func (b *Breaker) UpdateConcurrency(size int) {
-	b.sem.updateCapacity(size)
+	if b.sem.updateCapacity(size) {
+		if b.gate != nil {
+			b.gate.dispatch()
+		}
+		b.publish(BreakerCapacityUpdated)
+	}
+}
+
+// Subscribe returns a channel emitting the saturation, idle and capacity
+// events of the breaker and a function to cancel the subscription, which
+// closes the channel. Events are never waited on: if a subscriber falls
+// behind, its oldest events are dropped.
+func (b *Breaker) Subscribe() (<-chan BreakerEvent, func()) {
+	ch := make(chan BreakerEvent, breakerEventBuffer)
+	b.mu.Lock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan BreakerEvent]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers, ch)
+			close(ch)
+		})
+	}
+}
+
+// publish sends an event of the given type to all subscribers.
+func (b *Breaker) publish(typ BreakerEventType) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.subscribers) == 0 {
+		return
+	}
+	ev := BreakerEvent{Type: typ, InFlight: b.InFlight(), Capacity: b.Capacity()}
+	for ch := range b.subscribers {
+		for sent := false; !sent; {
+			select {
+			case ch <- ev:
+				sent = true
+			default:
+				// The subscriber is behind, make room by dropping its
+				// oldest event.
+				select {
+				case <-ch:
+				default:
+				}
+			}
+		}
+	}
 }
 
 // Capacity returns the number of allowed in-flight requests on this breaker.
@@ -197,57 +495,77 @@ type semaphore struct {
 
 // tryAcquire receives a token from the semaphore if there is one otherwise returns false.
 func (s *semaphore) tryAcquire() bool {
+	return s.tryAcquireN(1) > 0
+}
+
+// tryAcquireN receives n tokens from the semaphore if there are that many,
+// and returns how many it received, 0 if none. If the capacity is lower than
+// n, all of it is received once it's free, so heavy requests still make
+// progress.
+func (s *semaphore) tryAcquireN(n uint64) uint64 {
 	for {
 		old := s.state.Load()
 		capacity, in := unpack(old)
-		if in >= capacity {
-			return false
+		want := min(n, capacity)
+		if want == 0 || in+want > capacity {
+			return 0
 		}
-		in++
-		if s.state.CAS(old, pack(capacity, in)) {
-			return true
+		if s.state.CAS(old, pack(capacity, in+want)) {
+			return want
 		}
 	}
 }
 
 // acquire acquires capacity from the semaphore.
 func (s *semaphore) acquire(ctx context.Context) error {
+	_, err := s.acquireN(ctx, 1)
+	return err
+}
+
+// acquireN acquires n tokens, or all of the capacity if it's lower than n,
+// from the semaphore and returns how many it acquired.
+func (s *semaphore) acquireN(ctx context.Context, n uint64) (uint64, error) {
 	for {
 		old := s.state.Load()
 		capacity, in := unpack(old)
+		want := min(n, capacity)
 
-		if in >= capacity {
+		if want == 0 || in+want > capacity {
 			select {
 			case <-ctx.Done():
-				return ctx.Err()
+				return 0, ctx.Err()
 			case <-s.queue:
 			}
 			// Force reload state.
 			continue
 		}
 
-		in++
-		if s.state.CAS(old, pack(capacity, in)) {
-			return nil
+		if s.state.CAS(old, pack(capacity, in+want)) {
+			return want, nil
 		}
 	}
 }
 
 // release releases capacity in the semaphore.
+func (s *semaphore) release() {
+	s.releaseN(1)
+}
+
+// releaseN releases n tokens in the semaphore.
 // If the semaphore capacity was reduced in between and as a result inFlight is greater
 // than capacity, we don't wake up goroutines as they'd not get any capacity anyway.
-func (s *semaphore) release() {
+func (s *semaphore) releaseN(n uint64) {
 	for {
 		old := s.state.Load()
 		capacity, in := unpack(old)
 
-		if in == 0 {
+		if in < n {
 			panic("release and acquire are not paired")
 		}
 
-		in--
+		in -= n
 		if s.state.CAS(old, pack(capacity, in)) {
-			if in < capacity {
+			for i := uint64(0); i < n && in+i < capacity; i++ {
 				select {
 				case s.queue <- struct{}{}:
 				default:
@@ -263,8 +581,23 @@ func (s *semaphore) release() {
 	}
 }
 
-// updateCapacity updates the capacity of the semaphore to the desired size.
-func (s *semaphore) updateCapacity(size int) {
+func min(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// updateCapacity updates the capacity of the semaphore to the desired size
+// and returns whether it changed.
+// Sizes outside of [0, maxCapacity] are clamped, as they'd otherwise corrupt
+// the packed state.
+func (s *semaphore) updateCapacity(size int) bool {
+	if size < 0 {
+		size = 0
+	} else if size > cap(s.queue) {
+		size = cap(s.queue)
+	}
 	s64 := uint64(size)
 	for {
 		old := s.state.Load()
@@ -272,7 +605,7 @@ func (s *semaphore) updateCapacity(size int) {
 
 		if capacity == s64 {
 			// Nothing to do, exit early.
-			return
+			return false
 		}
 
 		if s.state.CAS(old, pack(s64, in)) {
@@ -285,7 +618,7 @@ func (s *semaphore) updateCapacity(size int) {
 					}
 				}
 			}
-			return
+			return true
 		}
 	}
 }
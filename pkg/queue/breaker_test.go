@@ -19,8 +19,14 @@ package queue
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/atomic"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 const (
@@ -147,6 +153,63 @@ func TestBreakerQueueing(t *testing.T) {
 	reqs.processSuccessfully(t)
 }
 
+func TestBreakerQueueDepth(t *testing.T) {
+	params := BreakerParams{QueueDepth: 2, MaxConcurrency: 1, InitialCapacity: 1}
+	b := NewBreaker(params)
+	reqs := newRequestor(b)
+
+	// One request is running, the other two are waiting for its slot.
+	reqs.request()
+	reqs.request()
+	reqs.request()
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return b.QueueDepth() == 2, nil
+	}); err != nil {
+		t.Errorf("QueueDepth() = %d, want: 2", b.QueueDepth())
+	}
+
+	reqs.processSuccessfully(t)
+	reqs.processSuccessfully(t)
+	reqs.processSuccessfully(t)
+	if got := b.QueueDepth(); got != 0 {
+		t.Errorf("QueueDepth() = %d, want: 0", got)
+	}
+}
+
+func TestBreakerQueueWait(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 0})
+	if got := b.QueueWait(); got != 0 {
+		t.Errorf("QueueWait() = %v, want: 0", got)
+	}
+
+	// The request waits for the capacity.
+	done := make(chan struct{})
+	go func() {
+		b.Maybe(context.Background(), func() {})
+		close(done)
+	}()
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return b.QueueDepth() == 1, nil
+	}); err != nil {
+		t.Fatalf("QueueDepth() = %d, want: 1", b.QueueDepth())
+	}
+	time.Sleep(50 * time.Millisecond)
+	b.UpdateConcurrency(1)
+	<-done
+	waited := b.QueueWait()
+	if min := time.Duration(queueWaitEWMAWeight * float64(50*time.Millisecond)); waited < min {
+		t.Errorf("QueueWait() = %v, want at least: %v", waited, min)
+	}
+
+	// The requests admitted right away make the average decay.
+	for i := 0; i < 10; i++ {
+		b.Maybe(context.Background(), func() {})
+	}
+	if got := b.QueueWait(); got >= waited {
+		t.Errorf("QueueWait() = %v, want less than: %v", got, waited)
+	}
+}
+
 func TestBreakerNoOverload(t *testing.T) {
 	params := BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1}
 	b := NewBreaker(params) // Breaker capacity = 2
@@ -221,9 +284,332 @@ func TestBreakerUpdateConcurrency(t *testing.T) {
 		t.Errorf("Capacity() = %d, want: %d", got, want)
 	}
 
+	// Out of range sizes are clamped.
+	b.UpdateConcurrency(-1)
+	if got, want := b.Capacity(), 0; got != want {
+		t.Errorf("Capacity() = %d, want: %d", got, want)
+	}
+	b.UpdateConcurrency(2)
+	if got, want := b.Capacity(), 1; got != want {
+		t.Errorf("Capacity() = %d, want: %d", got, want)
+	}
+}
+
+func TestBreakerConcurrentUpdateConcurrency(t *testing.T) {
+	const (
+		maxConcurrency = 5
+		requests       = 500
+	)
+	// The queue is deep enough to fit all requests, so none of them must be rejected.
+	b := NewBreaker(BreakerParams{QueueDepth: requests, MaxConcurrency: maxConcurrency, InitialCapacity: 1})
+
+	var active, maxActive atomic.Int64
+	thunk := func() {
+		cur := active.Inc()
+		for {
+			old := maxActive.Load()
+			if cur <= old || maxActive.CAS(old, cur) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Microsecond)
+		active.Dec()
+	}
+
+	stop := make(chan struct{})
+	resized := make(chan struct{})
+	go func() {
+		defer close(resized)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				b.UpdateConcurrency(i % (maxConcurrency + 1))
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	wg.Add(requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			defer wg.Done()
+			if err := b.Maybe(context.Background(), thunk); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	// Let the resizing race with the requests for a bit, then open up the
+	// breaker to let the remaining requests drain.
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	<-resized
+	b.UpdateConcurrency(maxConcurrency)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error("Maybe() =", err)
+	}
+	if got := maxActive.Load(); got > maxConcurrency {
+		t.Errorf("Max concurrently admitted requests = %d, want <= %d", got, maxConcurrency)
+	}
+	if got := b.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d, want: 0", got)
+	}
+	if _, in := unpack(b.sem.state.Load()); in != 0 {
+		t.Errorf("Semaphore in-flight = %d, want: 0", in)
+	}
 }
 
 // Test empty semaphore, token cannot be acquired
+func TestBreakerStress(t *testing.T) {
+	const (
+		capacity = 10
+		requests = 5000
+	)
+	for _, resize := range []bool{false, true} {
+		t.Run(fmt.Sprint("resize=", resize), func(t *testing.T) {
+			// The queue is deep enough to fit all requests, so none of them must be rejected.
+			b := NewBreaker(BreakerParams{QueueDepth: requests, MaxConcurrency: capacity, InitialCapacity: capacity})
+
+			var executing atomic.Int64
+			thunk := func(slow bool) func() {
+				return func() {
+					if cur := executing.Inc(); cur > capacity {
+						t.Errorf("Executing requests = %d, want <= %d", cur, capacity)
+					}
+					if slow {
+						time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+					}
+					executing.Dec()
+				}
+			}
+
+			// Check the accessors for consistency while the requests are running
+			// and, optionally, the capacity is changing.
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; ; i++ {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					if resize {
+						b.UpdateConcurrency(1 + i%capacity)
+					}
+					if got := b.PendingRequests(); got < 0 || got > requests {
+						t.Errorf("PendingRequests() = %d, want in [0, %d]", got, requests)
+					}
+					if got := b.InFlight(); got < 0 || got > requests+capacity {
+						t.Errorf("InFlight() = %d, want in [0, %d]", got, requests+capacity)
+					}
+					if got := b.Capacity(); got < 1 || got > capacity {
+						t.Errorf("Capacity() = %d, want in [1, %d]", got, capacity)
+					}
+				}
+			}()
+
+			var wg sync.WaitGroup
+			wg.Add(requests)
+			for i := 0; i < requests; i++ {
+				go func(slow bool) {
+					defer wg.Done()
+					if err := b.Maybe(context.Background(), thunk(slow)); err != nil {
+						t.Error("Maybe() =", err)
+					}
+				}(rand.Intn(2) == 0)
+			}
+			wg.Wait()
+			close(stop)
+			<-done
+
+			if got := executing.Load(); got != 0 {
+				t.Errorf("Executing requests = %d, want: 0", got)
+			}
+			if got := b.InFlight(); got != 0 {
+				t.Errorf("InFlight() = %d, want: 0", got)
+			}
+			if got := b.PendingRequests(); got != 0 {
+				t.Errorf("PendingRequests() = %d, want: 0", got)
+			}
+			if _, in := unpack(b.sem.state.Load()); in != 0 {
+				t.Errorf("Semaphore in-flight = %d, want: 0", in)
+			}
+		})
+	}
+}
+
+func TestBreakerSubscribe(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 2, InitialCapacity: 1}) // Breaker capacity = 3
+	events, cancel := b.Subscribe()
+	defer cancel()
+	others, cancelOthers := b.Subscribe()
+
+	first, ok := b.Reserve(context.Background())
+	if !ok {
+		t.Fatal("Reserve failed")
+	}
+	b.UpdateConcurrency(2)
+	second, ok := b.Reserve(context.Background())
+	if !ok {
+		t.Fatal("Reserve failed")
+	}
+	// The third request fills the queue, but is rejected for lack of capacity.
+	if _, ok := b.Reserve(context.Background()); ok {
+		t.Fatal("Reserve was an unexpected success")
+	}
+	// Overshooting and updating to the same capacity don't emit events.
+	b.Reserve(context.Background())
+	b.UpdateConcurrency(2)
+	second()
+
+	// Unsubscribed channels are closed and get no more events.
+	cancelOthers()
+	cancelOthers()
+	first()
+	var n int
+	for range others {
+		n++
+	}
+	if got, want := n, 2; got != want {
+		t.Errorf("Events of the cancelled subscription = %d, want: %d", got, want)
+	}
+
+	want := []BreakerEvent{
+		{Type: BreakerCapacityUpdated, InFlight: 1, Capacity: 2},
+		{Type: BreakerSaturated, InFlight: 3, Capacity: 2},
+		{Type: BreakerIdle, InFlight: 0, Capacity: 2},
+	}
+	got := make([]BreakerEvent, 0, len(want))
+	for len(got) < len(want) {
+		select {
+		case ev := <-events:
+			got = append(got, ev)
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for events, got: %v", got)
+		}
+	}
+	if !cmp.Equal(got, want) {
+		t.Error("Events mismatch (-want,+got):", cmp.Diff(want, got))
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if got, want := len(b.subscribers), 1; got != want {
+		t.Errorf("Subscribers = %d, want: %d", got, want)
+	}
+}
+
+func TestBreakerSubscribeSlowConsumer(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1000, InitialCapacity: 0})
+	events, cancel := b.Subscribe()
+	defer cancel()
+
+	// Nobody consumes the events, this must not block.
+	for i := 1; i <= 1000; i++ {
+		b.UpdateConcurrency(i)
+	}
+
+	// Only the latest events are kept.
+	var last BreakerEvent
+	for i := 0; i < breakerEventBuffer; i++ {
+		last = <-events
+	}
+	if got, want := last.Capacity, 1000; got != want {
+		t.Errorf("Capacity = %d, want: %d", got, want)
+	}
+	select {
+	case ev := <-events:
+		t.Error("Unexpected event:", ev)
+	default:
+	}
+}
+
+func TestBreakerSaturationEpisodes(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1}) // Breaker capacity = 2
+	release, ok := b.Reserve(context.Background())
+	if !ok {
+		t.Fatal("Reserve failed")
+	}
+
+	// A sustained saturation rejects many requests but is a single episode.
+	for i := 0; i < 100; i++ {
+		if _, ok := b.Reserve(context.Background()); ok {
+			t.Fatal("Reserve was an unexpected success")
+		}
+	}
+	if got, want := b.SaturationEpisodes(), uint64(1); got != want {
+		t.Errorf("SaturationEpisodes() = %d, want: %d", got, want)
+	}
+
+	// Once drained, the next saturation is a new episode.
+	release()
+	if got, want := b.SaturationEpisodes(), uint64(1); got != want {
+		t.Errorf("SaturationEpisodes() after drain = %d, want: %d", got, want)
+	}
+	release, _ = b.Reserve(context.Background())
+	defer release()
+	b.Reserve(context.Background())
+	if got, want := b.SaturationEpisodes(), uint64(2); got != want {
+		t.Errorf("SaturationEpisodes() after resaturating = %d, want: %d", got, want)
+	}
+}
+
+func TestBreakerSaturatedSince(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1}) // Breaker capacity = 2
+	reqs := newRequestor(b)
+	if _, saturated := b.SaturatedSince(); saturated {
+		t.Error("New breaker is saturated")
+	}
+	fill := func() {
+		t.Helper()
+		if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+			return b.InFlight() == 2, nil
+		}); err != nil {
+			t.Fatalf("InFlight() = %d, want: 2", b.InFlight())
+		}
+	}
+
+	// One request is running, the other one fills the queue.
+	before := time.Now()
+	reqs.request()
+	reqs.request()
+	fill()
+	since, saturated := b.SaturatedSince()
+	if !saturated || since.Before(before) || since.After(time.Now()) {
+		t.Errorf("SaturatedSince() = %v, %v, want saturated since the requests", since, saturated)
+	}
+
+	// A request leaving the full queue ends the saturation, but not the
+	// episode, which lasts until the breaker drained.
+	reqs.processSuccessfully(t)
+	if _, saturated := b.SaturatedSince(); saturated {
+		t.Error("Partially drained breaker is saturated")
+	}
+	before = time.Now()
+	reqs.request()
+	fill()
+	if got, saturated := b.SaturatedSince(); !saturated || got.Before(before) {
+		t.Errorf("SaturatedSince() = %v, %v, want saturated since the last request", got, saturated)
+	}
+	if got, want := b.SaturationEpisodes(), uint64(1); got != want {
+		t.Errorf("SaturationEpisodes() = %d, want: %d", got, want)
+	}
+
+	reqs.processSuccessfully(t)
+	reqs.processSuccessfully(t)
+	if _, saturated := b.SaturatedSince(); saturated {
+		t.Error("Drained breaker is saturated")
+	}
+}
+
 func TestSemaphoreAcquireHasNoCapacity(t *testing.T) {
 	gotChan := make(chan struct{}, 1)
 
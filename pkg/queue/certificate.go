@@ -0,0 +1,232 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CertificateReloader serves the TLS certificate stored in a cert/key file
+// pair and reloads it when the files change on disk, e.g. when a mounted
+// secret is rotated, so that no restart is needed to pick up the new one.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+	// caFile is the bundle of CAs peers are verified with, for mutual TLS.
+	caFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	certPEM []byte
+	keyPEM  []byte
+	caPEM   []byte
+	cas     *x509.CertPool
+
+	// onReload are called once a changed certificate was loaded by Run.
+	onReload []func()
+}
+
+// NewCertificateReloader creates a CertificateReloader that initially serves
+// the certificate in the given files.
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	cr := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if _, err := cr.reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// NewMutualCertificateReloader creates a CertificateReloader for mutual TLS,
+// which also reloads the bundle of CAs in caFile peers are verified with.
+func NewMutualCertificateReloader(certFile, keyFile, caFile string) (*CertificateReloader, error) {
+	cr := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+	if _, err := cr.reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// OnReload registers f to be called once Run loaded a changed certificate,
+// e.g. to close the connections established with the previous one.
+func (cr *CertificateReloader) OnReload(f func()) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.onReload = append(cr.onReload, f)
+}
+
+// GetCertificate returns the most recently loaded certificate. It's supposed
+// to be used as tls.Config.GetCertificate.
+func (cr *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+// TLSConfig returns a tls.Config serving the most recently loaded certificate.
+func (cr *CertificateReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.GetCertificate,
+	}
+}
+
+// ServerMutualTLSConfig returns a tls.Config serving the most recently loaded
+// certificate to clients presenting a certificate with the DNS SAN clientSAN,
+// issued by the most recently loaded CAs.
+func (cr *CertificateReloader) ServerMutualTLSConfig(clientSAN string) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.GetCertificate,
+		// The client certificate is verified by VerifyConnection, so that
+		// the CAs can be rotated.
+		ClientAuth: tls.RequireAnyClientCert,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return cr.verifyPeer(cs.PeerCertificates, clientSAN, x509.ExtKeyUsageClientAuth)
+		},
+	}
+}
+
+// ClientMutualTLSConfig returns a tls.Config presenting the most recently
+// loaded certificate to servers with a certificate with the DNS SAN
+// serverName, issued by the most recently loaded CAs.
+func (cr *CertificateReloader) ClientMutualTLSConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cr.GetCertificate(nil)
+		},
+		// The server certificate is verified by VerifyConnection, so that the
+		// CAs can be rotated.
+		InsecureSkipVerify: true, // nolint:gosec // Verified below.
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return cr.verifyPeer(cs.PeerCertificates, serverName, x509.ExtKeyUsageServerAuth)
+		},
+	}
+}
+
+// verifyPeer verifies that the certificate chain of the peer was issued by
+// the CAs, for the usage, and that the certificate has the DNS SAN san.
+func (cr *CertificateReloader) verifyPeer(certs []*x509.Certificate, san string, usage x509.ExtKeyUsage) error {
+	if len(certs) == 0 {
+		return errors.New("peer presented no certificate")
+	}
+	cr.mu.RLock()
+	cas := cr.cas
+	cr.mu.RUnlock()
+	if cas == nil {
+		return errors.New("no CAs to verify the peer with")
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       san,
+		Roots:         cas,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	})
+	return err
+}
+
+// Run checks the files for changes every interval until ctx is done.
+// If the changed files can't be loaded, the previous certificate keeps
+// being served.
+func (cr *CertificateReloader) Run(ctx context.Context, interval time.Duration, logger *zap.SugaredLogger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if reloaded, err := cr.reload(); err != nil {
+				logger.Errorw("Failed to reload TLS certificate", zap.Error(err))
+			} else if reloaded {
+				logger.Info("Reloaded TLS certificate")
+				cr.mu.RLock()
+				onReload := cr.onReload
+				cr.mu.RUnlock()
+				for _, f := range onReload {
+					f()
+				}
+			}
+		}
+	}
+}
+
+// reload loads the certificate if the content of the files changed and
+// returns whether it did.
+func (cr *CertificateReloader) reload() (bool, error) {
+	certPEM, err := os.ReadFile(cr.certFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+	keyPEM, err := os.ReadFile(cr.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read key file: %w", err)
+	}
+	var caPEM []byte
+	if cr.caFile != "" {
+		if caPEM, err = os.ReadFile(cr.caFile); err != nil {
+			return false, fmt.Errorf("failed to read CA file: %w", err)
+		}
+	}
+
+	cr.mu.RLock()
+	unchanged := bytes.Equal(certPEM, cr.certPEM) && bytes.Equal(keyPEM, cr.keyPEM) && bytes.Equal(caPEM, cr.caPEM)
+	cr.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	var cas *x509.CertPool
+	if cr.caFile != "" {
+		cas = x509.NewCertPool()
+		if !cas.AppendCertsFromPEM(caPEM) {
+			return false, fmt.Errorf("no certificates found in CA file %s", cr.caFile)
+		}
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.cert, cr.certPEM, cr.keyPEM = &cert, certPEM, keyPEM
+	cr.cas, cr.caPEM = cas, caPEM
+	return true, nil
+}
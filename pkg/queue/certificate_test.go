@@ -0,0 +1,237 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func TestCertificateReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeSelfSignedCert(t, certFile, keyFile, 1)
+
+	cr, err := NewCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal("NewCertificateReloader() =", err)
+	}
+	reloaded := make(chan struct{}, 1)
+	cr.OnReload(func() {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cr.Run(ctx, 10*time.Millisecond, logtesting.TestLogger(t))
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = cr.TLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		// Send SNI, otherwise the server falls back to the httptest certificate.
+		TLSClientConfig: &tls.Config{ServerName: "localhost", InsecureSkipVerify: true}, // nolint:gosec // Self-signed test cert.
+		// Force a new handshake for every request.
+		DisableKeepAlives: true,
+	}}
+	servedSerial := func() int64 {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal("Get() =", err)
+		}
+		defer resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if got, want := servedSerial(), int64(1); got != want {
+		t.Errorf("Serial = %d, want: %d", got, want)
+	}
+
+	// Rotate the certificate on disk.
+	writeSelfSignedCert(t, certFile, keyFile, 2)
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return servedSerial() == 2, nil
+	}); err != nil {
+		t.Fatal("New certificate was never served:", err)
+	}
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Error("The reload hook wasn't called")
+	}
+
+	// A broken certificate doesn't replace the current one.
+	if err := os.WriteFile(certFile, []byte("garbage"), 0600); err != nil {
+		t.Fatal("WriteFile() =", err)
+	}
+	if _, err := cr.reload(); err == nil {
+		t.Error("reload() succeeded for a broken certificate, want error")
+	}
+	if got, want := servedSerial(), int64(2); got != want {
+		t.Errorf("Serial = %d, want: %d", got, want)
+	}
+}
+
+func TestNewCertificateReloaderMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewCertificateReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")); err == nil {
+		t.Error("NewCertificateReloader() succeeded for missing files, want error")
+	}
+}
+
+func writeSelfSignedCert(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("GenerateKey() =", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "queue-proxy"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal("CreateCertificate() =", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal("MarshalECPrivateKey() =", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal("WriteFile() =", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal("WriteFile() =", err)
+	}
+}
+
+func TestMutualCertificateReloader(t *testing.T) {
+	const serverSAN, clientSAN = "rev.ns.revision.serving.knative.internal", "activator-service.knative-serving.svc"
+	serverDir, clientDir := t.TempDir(), t.TempDir()
+	ca := newTestCA(t)
+	ca.issue(t, serverDir, serverSAN, x509.ExtKeyUsageServerAuth)
+	ca.issue(t, clientDir, clientSAN, x509.ExtKeyUsageClientAuth)
+
+	newReloader := func(dir string) *CertificateReloader {
+		cr, err := NewMutualCertificateReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt"))
+		if err != nil {
+			t.Fatal("NewMutualCertificateReloader() =", err)
+		}
+		return cr
+	}
+	serverCerts, clientCerts := newReloader(serverDir), newReloader(clientDir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = serverCerts.ServerMutualTLSConfig(clientSAN)
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(cfg *tls.Config) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	if err := get(clientCerts.ClientMutualTLSConfig(serverSAN)); err != nil {
+		t.Error("Get() =", err)
+	}
+	if err := get(clientCerts.ClientMutualTLSConfig("other.ns.revision.serving.knative.internal")); err == nil {
+		t.Error("Get() succeeded for a server of another revision, want error")
+	}
+	if err := get(&tls.Config{InsecureSkipVerify: true}); err == nil { // nolint:gosec // Testing the client certificate.
+		t.Error("Get() succeeded without a client certificate, want error")
+	}
+
+	// A client certificate issued by another CA is rejected.
+	otherDir := t.TempDir()
+	other := newTestCA(t)
+	other.issue(t, otherDir, clientSAN, x509.ExtKeyUsageClientAuth)
+	if err := get(newReloader(otherDir).ClientMutualTLSConfig(serverSAN)); err == nil {
+		t.Error("Get() succeeded with a client certificate of another CA, want error")
+	}
+
+	// Until the CA of the server is rotated to it.
+	other.issue(t, serverDir, serverSAN, x509.ExtKeyUsageServerAuth)
+	if reloaded, err := serverCerts.reload(); err != nil || !reloaded {
+		t.Fatalf("reload() = %v, %v, want reloaded", reloaded, err)
+	}
+	if err := get(newReloader(otherDir).ClientMutualTLSConfig(serverSAN)); err != nil {
+		t.Error("Get() with a client certificate of the rotated CA =", err)
+	}
+	if err := get(clientCerts.ClientMutualTLSConfig(serverSAN)); err == nil {
+		t.Error("Get() succeeded with a client certificate of the previous CA, want error")
+	}
+}
+
+// issue writes a certificate with the SAN issued by the CA, its key and the
+// CA to dir, as they're mounted from a secret.
+func (ca *testCA) issue(t *testing.T, dir, san string, usage x509.ExtKeyUsage) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("GenerateKey() =", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: san},
+		DNSNames:     []string{san},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal("CreateCertificate() =", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal("MarshalECPrivateKey() =", err)
+	}
+	writePEM(t, filepath.Join(dir, "tls.crt"), "CERTIFICATE", der)
+	writePEM(t, filepath.Join(dir, "tls.key"), "EC PRIVATE KEY", keyDER)
+	writePEM(t, filepath.Join(dir, "ca.crt"), "CERTIFICATE", ca.cert.Raw)
+}
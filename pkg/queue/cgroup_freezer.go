@@ -0,0 +1,146 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// freezerPollInterval is how often the freezer is checked while waiting for
+// the cgroup to reach the requested state.
+const freezerPollInterval = 10 * time.Millisecond
+
+const (
+	// cgroup v2 freezes through cgroup.freeze and reports the state in
+	// cgroup.events.
+	cgroupV2FreezeFile = "cgroup.freeze"
+	cgroupV2EventsFile = "cgroup.events"
+	// The cgroup v1 freezer controller does both through freezer.state.
+	cgroupV1StateFile = "freezer.state"
+)
+
+// ConcurrencyStateBackend selects how the user container is paused and
+// resumed.
+type ConcurrencyStateBackend string
+
+const (
+	// ConcurrencyStateBackendEndpoint calls a ConcurrencyEndpoint.
+	ConcurrencyStateBackendEndpoint ConcurrencyStateBackend = "endpoint"
+	// ConcurrencyStateBackendFreezer freezes the cgroup with a CgroupFreezer.
+	ConcurrencyStateBackendFreezer ConcurrencyStateBackend = "freezer"
+)
+
+// CgroupFreezer pauses and resumes the user container by freezing its
+// cgroup directly, as an alternative to a ConcurrencyEndpoint for clusters
+// without a freezer daemon. It needs write access to the cgroup of the user
+// container, which must not contain queue-proxy itself.
+type CgroupFreezer struct {
+	path string
+	v2   bool
+}
+
+// NewCgroupFreezer creates a CgroupFreezer for the cgroup at path, i.e. its
+// directory in the cgroup v2 hierarchy or in the cgroup v1 freezer hierarchy.
+func NewCgroupFreezer(path string) (*CgroupFreezer, error) {
+	if _, err := os.Stat(filepath.Join(path, cgroupV2FreezeFile)); err == nil {
+		return &CgroupFreezer{path: path, v2: true}, nil
+	}
+	if _, err := os.Stat(filepath.Join(path, cgroupV1StateFile)); err == nil {
+		return &CgroupFreezer{path: path}, nil
+	}
+	return nil, fmt.Errorf("no cgroup freezer found at %s", path)
+}
+
+// Pause freezes the cgroup and waits until all of its processes are frozen.
+func (f *CgroupFreezer) Pause(ctx context.Context) error {
+	if f.v2 {
+		return f.set(ctx, cgroupV2FreezeFile, "1", f.frozenV2(true))
+	}
+	return f.set(ctx, cgroupV1StateFile, "FROZEN", f.stateV1("FROZEN"))
+}
+
+// Resume thaws the cgroup and waits until it's thawed.
+func (f *CgroupFreezer) Resume(ctx context.Context) error {
+	if f.v2 {
+		return f.set(ctx, cgroupV2FreezeFile, "0", f.frozenV2(false))
+	}
+	return f.set(ctx, cgroupV1StateFile, "THAWED", f.stateV1("THAWED"))
+}
+
+// set writes value to file and polls until done reports the cgroup reached
+// the requested state, or ctx is done. Freezing is asynchronous in both
+// cgroup versions.
+func (f *CgroupFreezer) set(ctx context.Context, file, value string, done func() (bool, error)) error {
+	if err := os.WriteFile(filepath.Join(f.path, file), []byte(value), 0); err != nil {
+		return fmt.Errorf("failed to write %q to the cgroup freezer: %w", value, err)
+	}
+	ticker := time.NewTicker(freezerPollInterval)
+	defer ticker.Stop()
+	for {
+		ok, err := done()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("cgroup freezer didn't reach the requested state: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// frozenV2 checks the frozen key of cgroup.events against want.
+func (f *CgroupFreezer) frozenV2(want bool) func() (bool, error) {
+	wantValue := "0"
+	if want {
+		wantValue = "1"
+	}
+	return func() (bool, error) {
+		b, err := os.ReadFile(filepath.Join(f.path, cgroupV2EventsFile))
+		if err != nil {
+			return false, fmt.Errorf("failed to read the cgroup events: %w", err)
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(b))
+		for scanner.Scan() {
+			if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == "frozen" {
+				return fields[1] == wantValue, nil
+			}
+		}
+		return false, fmt.Errorf("no frozen state in %s", cgroupV2EventsFile)
+	}
+}
+
+// stateV1 checks freezer.state against want.
+func (f *CgroupFreezer) stateV1(want string) func() (bool, error) {
+	return func() (bool, error) {
+		b, err := os.ReadFile(filepath.Join(f.path, cgroupV1StateFile))
+		if err != nil {
+			return false, fmt.Errorf("failed to read the cgroup freezer state: %w", err)
+		}
+		return strings.TrimSpace(string(b)) == want, nil
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCgroupFile(t *testing.T, dir, file, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0600); err != nil {
+		t.Fatal("Failed to write cgroup file:", err)
+	}
+}
+
+func readCgroupFile(t *testing.T, dir, file string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		t.Fatal("Failed to read cgroup file:", err)
+	}
+	return string(b)
+}
+
+func TestCgroupFreezerV1(t *testing.T) {
+	dir := t.TempDir()
+	writeCgroupFile(t, dir, cgroupV1StateFile, "THAWED\n")
+	f, err := NewCgroupFreezer(dir)
+	if err != nil {
+		t.Fatal("NewCgroupFreezer() =", err)
+	}
+
+	if err := f.Pause(context.Background()); err != nil {
+		t.Fatal("Pause() =", err)
+	}
+	if got, want := readCgroupFile(t, dir, cgroupV1StateFile), "FROZEN"; got != want {
+		t.Errorf("State = %q, want: %q", got, want)
+	}
+	if err := f.Resume(context.Background()); err != nil {
+		t.Fatal("Resume() =", err)
+	}
+	if got, want := readCgroupFile(t, dir, cgroupV1StateFile), "THAWED"; got != want {
+		t.Errorf("State = %q, want: %q", got, want)
+	}
+}
+
+func TestCgroupFreezerV2(t *testing.T) {
+	dir := t.TempDir()
+	writeCgroupFile(t, dir, cgroupV2FreezeFile, "0\n")
+	writeCgroupFile(t, dir, cgroupV2EventsFile, "populated 1\nfrozen 0\n")
+	f, err := NewCgroupFreezer(dir)
+	if err != nil {
+		t.Fatal("NewCgroupFreezer() =", err)
+	}
+
+	// The kernel reports the cgroup frozen a bit after it's asked to freeze.
+	// The events are replaced atomically, so they're never read half written.
+	writeCgroupFile(t, dir, "events.new", "populated 1\nfrozen 1\n")
+	go func() {
+		time.Sleep(3 * freezerPollInterval)
+		if err := os.Rename(filepath.Join(dir, "events.new"), filepath.Join(dir, cgroupV2EventsFile)); err != nil {
+			t.Error("Failed to update cgroup events:", err)
+		}
+	}()
+	if err := f.Pause(context.Background()); err != nil {
+		t.Fatal("Pause() =", err)
+	}
+	if got, want := readCgroupFile(t, dir, cgroupV2FreezeFile), "1"; got != want {
+		t.Errorf("cgroup.freeze = %q, want: %q", got, want)
+	}
+
+	// The cgroup never thaws, Resume gives up along with its context.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*freezerPollInterval)
+	defer cancel()
+	if err := f.Resume(ctx); err == nil {
+		t.Error("Resume() succeeded for a frozen cgroup, want error")
+	}
+	if got, want := readCgroupFile(t, dir, cgroupV2FreezeFile), "0"; got != want {
+		t.Errorf("cgroup.freeze = %q, want: %q", got, want)
+	}
+}
+
+func TestCgroupFreezerMissing(t *testing.T) {
+	if _, err := NewCgroupFreezer(t.TempDir()); err == nil {
+		t.Error("NewCgroupFreezer() succeeded without a freezer, want error")
+	}
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	pkghttp "knative.dev/serving/pkg/http"
+)
+
+// circuitBreakerBuckets is the number of buckets the rolling window of the
+// CircuitBreaker is split into.
+const circuitBreakerBuckets = 10
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// CircuitBreakerParams defines the parameters of the CircuitBreaker.
+type CircuitBreakerParams struct {
+	// FailureRatio is the ratio of failed requests in (0, 1] over Window
+	// that trips the circuit.
+	FailureRatio float64
+	// Window is the rolling window the failure ratio is computed over.
+	Window time.Duration
+	// MinRequests is the number of requests that must have been seen in
+	// Window before the circuit can trip.
+	MinRequests int
+	// Cooldown is how long the circuit stays open before a trial request
+	// is let through.
+	Cooldown time.Duration
+}
+
+type circuitBucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// CircuitBreaker fast-fails requests once the user container returned too
+// many errors over a rolling window, giving it room to recover. Failures are
+// 5xx responses, which includes the 502 returned by the proxy if the user
+// container can't be dialed. After Cooldown a single trial request is let
+// through: if it succeeds the circuit closes again, otherwise it reopens.
+// This is distinct from the concurrency Breaker.
+//
+// Handler belongs behind the concurrency Breaker, so only the outcome of
+// requests passed to the user container is counted, while AdmissionHandler
+// belongs in front of it, so requests are rejected before they're queued.
+type CircuitBreaker struct {
+	params    CircuitBreakerParams
+	bucketLen time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    circuitState
+	openedAt time.Time
+	buckets  [circuitBreakerBuckets]circuitBucket
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker with the given parameters.
+func NewCircuitBreaker(params CircuitBreakerParams) *CircuitBreaker {
+	if params.MinRequests < 1 {
+		params.MinRequests = 1
+	}
+	bucketLen := params.Window / circuitBreakerBuckets
+	if bucketLen <= 0 {
+		bucketLen = 1
+	}
+	return &CircuitBreaker{
+		params:    params,
+		bucketLen: bucketLen,
+		now:       time.Now,
+	}
+}
+
+// circuitAdmission is a request admitted by AdmissionHandler.
+type circuitAdmission struct {
+	trial bool
+	// reached is set once the request reaches Handler.
+	reached bool
+}
+
+type circuitAdmissionKey struct{}
+
+// AdmissionHandler wraps next, fast-failing requests with a 503 while the
+// circuit is open, before they're queued for capacity. next is expected to
+// pass the admitted requests on to Handler, which records their outcome.
+func (cb *CircuitBreaker) AdmissionHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, trial := cb.allow()
+		if !allowed {
+			cb.reject(w)
+			return
+		}
+
+		a := &circuitAdmission{trial: trial}
+		if trial {
+			defer func() {
+				// The trial request failed before it got to the user container,
+				// e.g. as the queue was full. Let the next request try again.
+				if !a.reached {
+					cb.abandonTrial()
+				}
+			}()
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), circuitAdmissionKey{}, a)))
+	})
+}
+
+// Handler wraps next, fast-failing requests with a 503 while the circuit is
+// open and counting the responses of next as successes or failures. Requests
+// admitted by AdmissionHandler are still rejected if the circuit opened
+// while they were queued.
+func (cb *CircuitBreaker) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var allowed, trial bool
+		if a, ok := r.Context().Value(circuitAdmissionKey{}).(*circuitAdmission); ok {
+			a.reached = true
+			allowed, trial = a.trial || cb.closed(), a.trial
+		} else {
+			allowed, trial = cb.allow()
+		}
+		if !allowed {
+			cb.reject(w)
+			return
+		}
+
+		rr := pkghttp.NewResponseRecorder(w, http.StatusOK)
+		defer func() {
+			// A panicking request, e.g. http.ErrAbortHandler, is a failure too.
+			if err := recover(); err != nil {
+				cb.record(false, trial)
+				panic(err)
+			}
+			cb.record(rr.ResponseCode < http.StatusInternalServerError, trial)
+		}()
+		next.ServeHTTP(rr, r)
+	})
+}
+
+// reject responds with a 503, telling the client to retry once the cooldown
+// of the circuit has passed.
+func (cb *CircuitBreaker) reject(w http.ResponseWriter) {
+	cb.mu.Lock()
+	retryAfter := cb.openedAt.Add(cb.params.Cooldown).Sub(cb.now())
+	cb.mu.Unlock()
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+	http.Error(w, "circuit breaker open", http.StatusServiceUnavailable)
+}
+
+// closed returns whether the circuit is closed.
+func (cb *CircuitBreaker) closed() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state == circuitClosed
+}
+
+// abandonTrial reopens a half-open circuit whose trial request never got to
+// the user container. As the cooldown has passed, the next request is let
+// through as the trial.
+func (cb *CircuitBreaker) abandonTrial() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == circuitHalfOpen {
+		cb.state = circuitOpen
+	}
+}
+
+// allow returns whether a request may be passed on to the user container and
+// whether it's the trial request of a half-open circuit.
+func (cb *CircuitBreaker) allow() (allowed, trial bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if cb.now().Sub(cb.openedAt) < cb.params.Cooldown {
+			return false, false
+		}
+		// Let exactly one trial request through.
+		cb.state = circuitHalfOpen
+		return true, true
+	case circuitHalfOpen:
+		// The trial request is still running.
+		return false, false
+	default:
+		return true, false
+	}
+}
+
+// record records the outcome of a request that was allowed by allow.
+func (cb *CircuitBreaker) record(success, trial bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.now()
+	if trial {
+		if success {
+			cb.state = circuitClosed
+			cb.buckets = [circuitBreakerBuckets]circuitBucket{}
+		} else {
+			cb.state, cb.openedAt = circuitOpen, now
+		}
+		return
+	}
+	if cb.state != circuitClosed {
+		// A request admitted before the circuit opened.
+		return
+	}
+
+	b := cb.bucket(now)
+	if success {
+		b.successes++
+	} else {
+		b.failures++
+	}
+
+	var successes, failures int
+	for i := range cb.buckets {
+		if now.Sub(cb.buckets[i].start) < cb.params.Window {
+			successes += cb.buckets[i].successes
+			failures += cb.buckets[i].failures
+		}
+	}
+	total := successes + failures
+	if total >= cb.params.MinRequests && float64(failures)/float64(total) >= cb.params.FailureRatio {
+		cb.state, cb.openedAt = circuitOpen, now
+	}
+}
+
+// bucket returns the bucket for the given time, resetting it if it holds
+// data of a previous window.
+func (cb *CircuitBreaker) bucket(now time.Time) *circuitBucket {
+	start := now.Truncate(cb.bucketLen)
+	b := &cb.buckets[(now.UnixNano()/int64(cb.bucketLen))%circuitBreakerBuckets]
+	if !b.start.Equal(start) {
+		*b = circuitBucket{start: start}
+	}
+	return b
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var (
+		now    = time.Now()
+		status atomic.Int32
+		calls  atomic.Int32
+	)
+	status.Store(http.StatusOK)
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Inc()
+		w.WriteHeader(int(status.Load()))
+	})
+
+	cb := NewCircuitBreaker(CircuitBreakerParams{
+		FailureRatio: 0.5,
+		Window:       10 * time.Second,
+		MinRequests:  4,
+		Cooldown:     5 * time.Second,
+	})
+	cb.now = func() time.Time { return now }
+	breaker := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 10})
+	h := ProxyHandler(breaker, NewRequestStats(now), false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, cb.Handler(backend))
+
+	request := func() int {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, targetURI, nil))
+		return rec.Code
+	}
+	expect := func(want int) {
+		t.Helper()
+		if got := request(); got != want {
+			t.Errorf("Code = %d, want: %d", got, want)
+		}
+	}
+
+	// Client errors don't count as failures.
+	status.Store(http.StatusNotFound)
+	for i := 0; i < 10; i++ {
+		expect(http.StatusNotFound)
+	}
+
+	// Failures that fell out of the window don't count either.
+	status.Store(http.StatusInternalServerError)
+	for i := 0; i < 9; i++ {
+		expect(http.StatusInternalServerError)
+	}
+	now = now.Add(11 * time.Second)
+	status.Store(http.StatusOK)
+	for i := 0; i < 3; i++ {
+		expect(http.StatusOK)
+	}
+
+	// The backend starts failing, 3 failures over 6 requests trip the circuit.
+	status.Store(http.StatusBadGateway)
+	for i := 0; i < 3; i++ {
+		expect(http.StatusBadGateway)
+	}
+	calls.Store(0)
+	expect(http.StatusServiceUnavailable)
+	if got := calls.Load(); got != 0 {
+		t.Errorf("Backend calls while open = %d, want: 0", got)
+	}
+
+	// Once the cooldown passed, a failing trial request reopens the circuit.
+	now = now.Add(5 * time.Second)
+	expect(http.StatusBadGateway)
+	expect(http.StatusServiceUnavailable)
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Backend calls = %d, want: 1", got)
+	}
+
+	// The backend recovered, the trial request closes the circuit.
+	status.Store(http.StatusOK)
+	now = now.Add(5 * time.Second)
+	for i := 0; i < 5; i++ {
+		expect(http.StatusOK)
+	}
+}
+
+func TestCircuitBreakerHalfOpenSingleTrial(t *testing.T) {
+	now := time.Now()
+	cb := NewCircuitBreaker(CircuitBreakerParams{
+		FailureRatio: 1,
+		Window:       time.Second,
+		Cooldown:     time.Second,
+	})
+	cb.now = func() time.Time { return now }
+
+	fail := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	cb.Handler(fail).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, targetURI, nil))
+
+	now = now.Add(time.Second)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	block := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cb.Handler(block).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, targetURI, nil))
+	}()
+	<-started
+
+	// While the trial request is running, everything else is rejected.
+	rec := httptest.NewRecorder()
+	cb.Handler(block).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, targetURI, nil))
+	if got, want := rec.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+
+	close(release)
+	<-done
+	rec = httptest.NewRecorder()
+	cb.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, targetURI, nil))
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+}
+
+func TestCircuitBreakerRetryAfter(t *testing.T) {
+	now := time.Now()
+	cb := NewCircuitBreaker(CircuitBreakerParams{
+		FailureRatio: 1,
+		Window:       time.Second,
+		Cooldown:     5 * time.Second,
+	})
+	cb.now = func() time.Time { return now }
+	fail := cb.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	fail.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, targetURI, nil))
+
+	now = now.Add(2 * time.Second)
+	rec := httptest.NewRecorder()
+	fail.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, targetURI, nil))
+	if got, want := rec.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+	if got, want := rec.Header().Get("Retry-After"), "3"; got != want {
+		t.Errorf("Retry-After = %q, want: %q", got, want)
+	}
+}
+
+func TestCircuitBreakerAdmission(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Inc()
+		<-release
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	cb := NewCircuitBreaker(CircuitBreakerParams{
+		FailureRatio: 1,
+		Window:       time.Minute,
+		Cooldown:     time.Minute,
+	})
+	breaker := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 1, InitialCapacity: 1})
+	proxy := ProxyHandler(breaker, NewRequestStats(time.Now()), false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, cb.Handler(backend))
+	h := cb.AdmissionHandler(proxy)
+
+	codes := make(chan int, 2)
+	request := func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, targetURI, nil))
+		codes <- rec.Code
+	}
+	go request()
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return calls.Load() == 1, nil
+	}); err != nil {
+		t.Fatal("Request didn't reach the backend")
+	}
+	go request()
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return breaker.PendingRequests() == 1, nil
+	}); err != nil {
+		t.Fatalf("PendingRequests() = %d, want: 1", breaker.PendingRequests())
+	}
+
+	// The failure trips the circuit, which rejects the queued request too.
+	close(release)
+	if got, want := <-codes, http.StatusInternalServerError; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+	if got, want := <-codes, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Code of the queued request = %d, want: %d", got, want)
+	}
+
+	// Further requests aren't queued at all.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, targetURI, nil))
+	if got, want := rec.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+	if got, want := calls.Load(), int32(1); got != want {
+		t.Errorf("Backend calls = %d, want: %d", got, want)
+	}
+}
+
+func TestCircuitBreakerAdmissionAbandonedTrial(t *testing.T) {
+	now := time.Now()
+	cb := NewCircuitBreaker(CircuitBreakerParams{
+		FailureRatio: 1,
+		Window:       time.Second,
+		Cooldown:     time.Second,
+	})
+	cb.now = func() time.Time { return now }
+	cb.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, targetURI, nil))
+
+	// The trial request times out waiting for capacity.
+	now = now.Add(time.Second)
+	breaker := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 1, InitialCapacity: 0})
+	backend := cb.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	h := cb.AdmissionHandler(ProxyHandler(breaker, NewRequestStats(now), false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, backend))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, targetURI, nil).WithContext(ctx))
+	if got, want := rec.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+
+	// The next request gets to be the trial and closes the circuit.
+	breaker.UpdateConcurrency(1)
+	for i := 0; i < 2; i++ {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, targetURI, nil))
+		if got, want := rec.Code, http.StatusOK; got != want {
+			t.Errorf("Code = %d, want: %d", got, want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"golang.org/x/net/http/httpguts"
+
+	network "knative.dev/networking/pkg"
+	pkgmetrics "knative.dev/pkg/metrics"
+	"knative.dev/serving/pkg/metrics"
+)
+
+const (
+	// ClientRateLimitKeyIP identifies clients by their IP, the first hop of
+	// the X-Forwarded-For chain if the request was forwarded.
+	ClientRateLimitKeyIP = "ip"
+	// ClientRateLimitKeyHeaderPrefix identifies clients by the value of the
+	// header following the prefix, e.g. "header:X-Tenant-Id".
+	ClientRateLimitKeyHeaderPrefix = "header:"
+
+	// clientRateLimitSweepInterval is the least interval at which the buckets
+	// of clients that have gone quiet are dropped.
+	clientRateLimitSweepInterval = time.Minute
+)
+
+var clientRateLimitedRequestCountM = stats.Int64(
+	"client_rate_limited_request_count",
+	"The number of requests rejected by the queue-proxy per client rate limiter",
+	stats.UnitDimensionless)
+
+// ClientKeyFunc returns the identity of the client of a request.
+type ClientKeyFunc func(r *http.Request) string
+
+// ParseClientRateLimitKey parses how the clients of requests are identified,
+// either ClientRateLimitKeyIP, the default, or ClientRateLimitKeyHeaderPrefix
+// followed by the name of a header.
+func ParseClientRateLimitKey(s string) (ClientKeyFunc, error) {
+	if s == "" || s == ClientRateLimitKeyIP {
+		return remoteIP, nil
+	}
+	if !strings.HasPrefix(s, ClientRateLimitKeyHeaderPrefix) {
+		return nil, fmt.Errorf("client rate limit key %q is neither %q nor %q followed by a header",
+			s, ClientRateLimitKeyIP, ClientRateLimitKeyHeaderPrefix)
+	}
+	header := strings.TrimPrefix(s, ClientRateLimitKeyHeaderPrefix)
+	if !httpguts.ValidHeaderFieldName(header) {
+		return nil, fmt.Errorf("client rate limit key %q has an invalid header name", s)
+	}
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}, nil
+}
+
+// tokenBucket holds up to burst tokens, refilled at rps tokens per second.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take takes a token from the bucket refilled until now if it has one,
+// returning whether it had and the tokens left.
+func (b *tokenBucket) take(now time.Time, rps float64, burst int) (bool, float64) {
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rps)
+	b.last = now
+	if b.tokens < 1 {
+		return false, b.tokens
+	}
+	b.tokens--
+	return true, b.tokens
+}
+
+type clientRateLimitHandler struct {
+	next  http.Handler
+	key   ClientKeyFunc
+	rps   float64
+	burst int
+	// fill is the time it takes an empty bucket to fill up. Buckets not
+	// taken from for that long are full, so they're dropped.
+	fill     time.Duration
+	statsCtx context.Context
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// NewClientRateLimitHandler creates an http.Handler that admits at most rps
+// requests per second of each client to next, allowing bursts of up to burst
+// requests. Clients are identified by key; requests without an identity share
+// a single limit. Responses carry the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers of the client's limit, and requests in excess of it
+// are rejected with a 429 and a Retry-After header. A rate of zero or less
+// disables the limit and next is returned unchanged.
+func NewClientRateLimitHandler(next http.Handler, rps float64, burst int, key ClientKeyFunc,
+	ns, service, config, rev, pod string) (http.Handler, error) {
+	if rps <= 0 {
+		return next, nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	if err := pkgmetrics.RegisterResourceView(&view.View{
+		Description: "The number of requests rejected by the queue-proxy per client rate limiter",
+		Measure:     clientRateLimitedRequestCountM,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey},
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx, err := metrics.PodRevisionContext(pod, "queue-proxy", ns, service, config, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clientRateLimitHandler{
+		next:      next,
+		key:       key,
+		rps:       rps,
+		burst:     burst,
+		fill:      time.Duration(float64(burst) / rps * float64(time.Second)),
+		statsCtx:  ctx,
+		buckets:   map[string]*tokenBucket{},
+		lastSweep: time.Now(),
+	}, nil
+}
+
+func (h *clientRateLimitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if network.IsKubeletProbe(r) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	ok, remaining := h.take(h.key(r), time.Now())
+	header := w.Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(h.burst))
+	header.Set("RateLimit-Remaining", strconv.Itoa(int(remaining)))
+	header.Set("RateLimit-Reset", strconv.Itoa(h.secondsUntil(float64(h.burst)-remaining)))
+	if !ok {
+		pkgmetrics.Record(h.statsCtx, clientRateLimitedRequestCountM.M(1))
+		header.Set("Retry-After", strconv.Itoa(h.secondsUntil(1-remaining)))
+		http.Error(w, "client request rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+// take takes a token from the bucket of the client.
+func (h *clientRateLimitHandler) take(client string, now time.Time) (bool, float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if now.Sub(h.lastSweep) >= clientRateLimitSweepInterval {
+		h.lastSweep = now
+		for c, b := range h.buckets {
+			if now.Sub(b.last) >= h.fill {
+				delete(h.buckets, c)
+			}
+		}
+	}
+
+	b, ok := h.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: float64(h.burst), last: now}
+		h.buckets[client] = b
+	}
+	return b.take(now, h.rps, h.burst)
+}
+
+// secondsUntil returns the whole seconds, at least one, it takes to refill
+// the given tokens, or 0 if there are none to refill.
+func (h *clientRateLimitHandler) secondsUntil(tokens float64) int {
+	if tokens <= 0 {
+		return 0
+	}
+	return retryAfterSeconds(time.Duration(tokens / h.rps * float64(time.Second)))
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	network "knative.dev/networking/pkg"
+	"knative.dev/pkg/metrics/metricstest"
+	_ "knative.dev/pkg/metrics/testing"
+	"knative.dev/serving/pkg/metrics"
+)
+
+func TestClientRateLimitHandlerDisabled(t *testing.T) {
+	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h, err := NewClientRateLimitHandler(baseHandler, 0 /*rps*/, 10 /*burst*/, remoteIP, "ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("Failed to create handler:", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, targetURI, nil))
+		if got, want := rec.Code, http.StatusOK; got != want {
+			t.Fatalf("Code = %d, want: %d", got, want)
+		}
+		if got := rec.Header().Get("RateLimit-Limit"); got != "" {
+			t.Fatalf("RateLimit-Limit = %q, want none", got)
+		}
+	}
+}
+
+func TestClientRateLimitHandler(t *testing.T) {
+	t.Cleanup(func() { metricstest.Unregister(clientRateLimitedRequestCountM.Name()) })
+
+	key, err := ParseClientRateLimitKey("header:X-Tenant")
+	if err != nil {
+		t.Fatal("ParseClientRateLimitKey() =", err)
+	}
+	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	// A rate of one request per second makes it impossible for a token to be
+	// refilled while the requests below are being sent.
+	h, err := NewClientRateLimitHandler(baseHandler, 1 /*rps*/, 2 /*burst*/, key, "ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("Failed to create handler:", err)
+	}
+
+	serve := func(tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, targetURI, nil)
+		req.Header.Set("X-Tenant", tenant)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		tenant                   string
+		wantCode                 int
+		wantRemaining, wantReset string
+	}{
+		{"a", http.StatusOK, "1", "1"},
+		{"a", http.StatusOK, "0", "2"},
+		{"a", http.StatusTooManyRequests, "0", "2"},
+		// Other clients have limits of their own.
+		{"b", http.StatusOK, "1", "1"},
+		{"a", http.StatusTooManyRequests, "0", "2"},
+	}
+	for i, tc := range tests {
+		rec := serve(tc.tenant)
+		if got := rec.Code; got != tc.wantCode {
+			t.Errorf("#%d: Code = %d, want: %d", i, got, tc.wantCode)
+		}
+		if got := rec.Header().Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("#%d: RateLimit-Limit = %q, want: 2", i, got)
+		}
+		if got := rec.Header().Get("RateLimit-Remaining"); got != tc.wantRemaining {
+			t.Errorf("#%d: RateLimit-Remaining = %q, want: %s", i, got, tc.wantRemaining)
+		}
+		if got := rec.Header().Get("RateLimit-Reset"); got != tc.wantReset {
+			t.Errorf("#%d: RateLimit-Reset = %q, want: %s", i, got, tc.wantReset)
+		}
+		if got, want := rec.Header().Get("Retry-After") != "", tc.wantCode == http.StatusTooManyRequests; got != want {
+			t.Errorf("#%d: Retry-After = %q, want it set: %v", i, rec.Header().Get("Retry-After"), want)
+		}
+	}
+
+	metricstest.AssertMetric(t, metricstest.IntMetric("client_rate_limited_request_count", 2, map[string]string{
+		metrics.LabelPodName:       "pod",
+		metrics.LabelContainerName: "queue-proxy",
+	}))
+
+	// Kubelet probes are never rate limited.
+	req := httptest.NewRequest(http.MethodGet, targetURI, nil)
+	req.Header.Set("X-Tenant", "a")
+	req.Header.Set(network.KubeletProbeHeaderName, "1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("Probe code = %d, want: %d", got, want)
+	}
+}
+
+func TestClientRateLimitHandlerForwardedFor(t *testing.T) {
+	t.Cleanup(func() { metricstest.Unregister(clientRateLimitedRequestCountM.Name()) })
+
+	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h, err := NewClientRateLimitHandler(baseHandler, 1 /*rps*/, 1 /*burst*/, remoteIP, "ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("Failed to create handler:", err)
+	}
+
+	serve := func(forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, targetURI, nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Forwarded requests are limited by the client, not the proxy.
+	for _, fwd := range []string{"1.2.3.4, 10.0.0.2", "5.6.7.8, 10.0.0.2"} {
+		if got, want := serve(fwd), http.StatusOK; got != want {
+			t.Errorf("Code for %s = %d, want: %d", fwd, got, want)
+		}
+	}
+	if got, want := serve("1.2.3.4, 10.0.0.3"), http.StatusTooManyRequests; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+}
+
+func TestClientRateLimitHandlerSweep(t *testing.T) {
+	h := &clientRateLimitHandler{
+		rps:       10,
+		burst:     2,
+		fill:      200 * time.Millisecond,
+		buckets:   map[string]*tokenBucket{},
+		lastSweep: time.Now(),
+	}
+	now := time.Now()
+	h.take("a", now)
+	h.take("b", now.Add(clientRateLimitSweepInterval-time.Millisecond))
+
+	// Once swept, only the buckets taken from within the fill time are kept.
+	h.take("c", now.Add(clientRateLimitSweepInterval))
+	if _, ok := h.buckets["a"]; ok {
+		t.Error("The bucket of a wasn't dropped")
+	}
+	for _, c := range []string{"b", "c"} {
+		if _, ok := h.buckets[c]; !ok {
+			t.Errorf("The bucket of %s was dropped", c)
+		}
+	}
+}
+
+func TestParseClientRateLimitKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, targetURI, nil)
+	req.RemoteAddr = "10.1.1.1:4000"
+	req.Header.Set("X-Tenant", "tenant")
+
+	for s, want := range map[string]string{
+		"":                "10.1.1.1",
+		"ip":              "10.1.1.1",
+		"header:X-Tenant": "tenant",
+		"header:X-Other":  "",
+	} {
+		key, err := ParseClientRateLimitKey(s)
+		if err != nil {
+			t.Errorf("ParseClientRateLimitKey(%q) = %v", s, err)
+			continue
+		}
+		if got := key(req); got != want {
+			t.Errorf("Key of %q = %q, want %q", s, got, want)
+		}
+	}
+	for _, s := range []string{"tenant", "header:", "header:X Tenant"} {
+		if _, err := ParseClientRateLimitKey(s); err == nil {
+			t.Errorf("ParseClientRateLimitKey(%q) = nil, want an error", s)
+		}
+	}
+}
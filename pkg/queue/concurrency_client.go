@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// concurrencyEndpointTimeout is the timeout of the whole request to the
+// concurrency state endpoint.
+const concurrencyEndpointTimeout = 10 * time.Second
+
+// ConcurrencyEndpointTLS holds the files used for mTLS with the concurrency
+// state endpoint, typically mounted from a projected volume.
+type ConcurrencyEndpointTLS struct {
+	// CertPath and KeyPath are the client certificate and its key presented
+	// to the endpoint. No certificate is presented if they're empty.
+	CertPath string
+	KeyPath  string
+	// CAPath is the bundle of CAs the endpoint is verified with. The system
+	// roots are used if it's empty.
+	CAPath string
+}
+
+func (t ConcurrencyEndpointTLS) paths() []string {
+	var paths []string
+	for _, p := range []string{t.CertPath, t.KeyPath, t.CAPath} {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// version identifies the current content of the files, by their
+// modification times and sizes. Projected volumes swap the files atomically,
+// so a rotation always changes the version.
+func (t ConcurrencyEndpointTLS) version() (string, error) {
+	var b strings.Builder
+	for _, p := range t.paths() {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat %s: %w", p, err)
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", p, fi.ModTime().UnixNano(), fi.Size())
+	}
+	return b.String(), nil
+}
+
+// config loads the files into a tls.Config, nil if there are none.
+func (t ConcurrencyEndpointTLS) config() (*tls.Config, error) {
+	if len(t.paths()) == 0 {
+		return nil, nil
+	}
+	if (t.CertPath == "") != (t.KeyPath == "") {
+		return nil, errors.New("client certificate and key must be set together")
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.CertPath != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertPath, t.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if t.CAPath != "" {
+		pem, err := os.ReadFile(t.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", t.CAPath)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// reloadingClient hands out the http.Client for the concurrency state
+// endpoint, rebuilding its transport whenever the TLS files changed, so
+// rotated certificates are picked up without a restart.
+type reloadingClient struct {
+	files ConcurrencyEndpointTLS
+
+	mux       sync.Mutex
+	client    *http.Client
+	transport *http.Transport
+	version   string
+}
+
+func newReloadingClient(files ConcurrencyEndpointTLS) *reloadingClient {
+	return &reloadingClient{files: files}
+}
+
+// get returns the client for the current content of the TLS files.
+func (r *reloadingClient) get() (*http.Client, error) {
+	version, err := r.files.version()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.client != nil && version == r.version {
+		return r.client, nil
+	}
+	cfg, err := r.files.config()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	if r.transport != nil {
+		// Connections authenticated with the old credentials aren't reused.
+		r.transport.CloseIdleConnections()
+	}
+	r.client = &http.Client{Transport: transport, Timeout: concurrencyEndpointTimeout}
+	r.transport, r.version = transport, version
+	return r.client, nil
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// testCA signs client certificates for the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate CA key:", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal("Failed to create CA certificate:", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal("Failed to parse CA certificate:", err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// writeClientCert writes a client certificate for name signed by the CA and
+// its key to the given paths.
+func (ca *testCA) writeClientCert(t *testing.T, name, certPath, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate key:", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal("Failed to create certificate:", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal("Failed to marshal key:", err)
+	}
+	writePEM(t, certPath, "CERTIFICATE", der)
+	writePEM(t, keyPath, "EC PRIVATE KEY", keyDER)
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal("Failed to write PEM:", err)
+	}
+}
+
+func TestConcurrencyEndpointMTLSRotation(t *testing.T) {
+	ca := newTestCA(t)
+	var client atomic.String
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client.Store(r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	files := ConcurrencyEndpointTLS{
+		CertPath: filepath.Join(dir, "tls.crt"),
+		KeyPath:  filepath.Join(dir, "tls.key"),
+		CAPath:   filepath.Join(dir, "ca.crt"),
+	}
+	writePEM(t, files.CAPath, "CERTIFICATE", server.Certificate().Raw)
+	ca.writeClientCert(t, "client-1", files.CertPath, files.KeyPath)
+
+	c := NewConcurrencyEndpoint(server.URL, "", files)
+	if err := c.Pause(context.Background()); err != nil {
+		t.Fatal("Pause() =", err)
+	}
+	if got, want := client.Load(), "client-1"; got != want {
+		t.Errorf("Client = %q, want: %q", got, want)
+	}
+
+	// The certificate is rotated, the transport is rebuilt with it. Bump the
+	// modification times, so the rotation is seen on coarse filesystem clocks.
+	ca.writeClientCert(t, "client-2", files.CertPath, files.KeyPath)
+	later := time.Now().Add(time.Minute)
+	for _, p := range []string{files.CertPath, files.KeyPath} {
+		if err := os.Chtimes(p, later, later); err != nil {
+			t.Fatal("Chtimes() =", err)
+		}
+	}
+	if err := c.Resume(context.Background()); err != nil {
+		t.Fatal("Resume() =", err)
+	}
+	if got, want := client.Load(), "client-2"; got != want {
+		t.Errorf("Client = %q, want rotated %q", got, want)
+	}
+}
+
+func TestConcurrencyEndpointMTLSWithoutCertificate(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	caPath := filepath.Join(t.TempDir(), "ca.crt")
+	writePEM(t, caPath, "CERTIFICATE", server.Certificate().Raw)
+	if err := NewConcurrencyEndpoint(server.URL, "", ConcurrencyEndpointTLS{CAPath: caPath}).Pause(context.Background()); err == nil {
+		t.Error("Pause() succeeded without a client certificate, want error")
+	}
+}
+
+func TestConcurrencyEndpointTLSInvalid(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal("Failed to write CA bundle:", err)
+	}
+
+	tests := []struct {
+		name  string
+		files ConcurrencyEndpointTLS
+	}{{
+		name:  "missing file",
+		files: ConcurrencyEndpointTLS{CAPath: filepath.Join(dir, "missing")},
+	}, {
+		name:  "certificate without key",
+		files: ConcurrencyEndpointTLS{CertPath: notPEM},
+	}, {
+		name:  "invalid CA bundle",
+		files: ConcurrencyEndpointTLS{CAPath: notPEM},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := newReloadingClient(test.files).get(); err == nil {
+				t.Error("get() succeeded, want error")
+			}
+		})
+	}
+}
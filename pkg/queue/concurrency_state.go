@@ -17,9 +17,30 @@ limitations under the License.
 package queue
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
+	network "knative.dev/networking/pkg"
+	pkghttp "knative.dev/serving/pkg/http"
+)
+
+const (
+	// tokenRefreshInterval is how long a token read from disk is used before
+	// it's read again. A rejected token is re-read right away.
+	tokenRefreshInterval = time.Minute
+
+	// maxInterposedProbes bounds the number of probe paths PausedProbeHandler
+	// keeps the outcome of, a pod having a few probes only.
+	maxInterposedProbes = 16
 )
 
 // ConcurrencyStateHandler tracks the in flight requests for the pod. When the requests
@@ -27,7 +48,12 @@ import (
 // runs the `resume` function. If either of `pause` or `resume` are not passed, it runs
 // the respective local function(s). The local functions are the expected behavior; the
 // function parameters are enabled primarily for testing purposes.
-func ConcurrencyStateHandler(logger *zap.SugaredLogger, h http.Handler, pause, resume func()) http.HandlerFunc {
+//
+// If `pauseDelay` is set, `pause` only runs once the requests stayed at zero
+// for that long, so that bursty traffic doesn't pause and resume the container
+// over and over. A request arriving in the meantime cancels the pause, so the
+// container isn't resumed either.
+func ConcurrencyStateHandler(logger *zap.SugaredLogger, h http.Handler, pause, resume func(), pauseDelay time.Duration) http.HandlerFunc {
 	logger.Info("Concurrency state tracking enabled")
 
 	if pause == nil {
@@ -38,17 +64,18 @@ func ConcurrencyStateHandler(logger *zap.SugaredLogger, h http.Handler, pause, r
 		resume = func() {}
 	}
 
-	type req struct {
-		w http.ResponseWriter
-		r *http.Request
-
-		done chan struct{}
-	}
-
-	reqCh := make(chan req)
+	// Requests send a channel to be told on once they're admitted, i.e. the
+	// container is resumed, and serve themselves. The channels are recycled,
+	// so tracking a request doesn't allocate.
+	reqCh := make(chan chan struct{})
 	doneCh := make(chan struct{})
 	go func() {
 		inFlight := 0
+		// pauseTimer is set while a delayed pause is pending.
+		var (
+			pauseTimer *time.Timer
+			pauseCh    <-chan time.Time
+		)
 
 		// This loop is entirely synchronous, so there's no cleverness needed in
 		// ensuring open and close dont run at the same time etc. Only the
@@ -58,30 +85,287 @@ func ConcurrencyStateHandler(logger *zap.SugaredLogger, h http.Handler, pause, r
 			case <-doneCh:
 				inFlight--
 				if inFlight == 0 {
-					logger.Info("Requests dropped to zero ...")
-					pause()
+					if pauseDelay <= 0 {
+						logger.Info("Requests dropped to zero ...")
+						pause()
+					} else {
+						logger.Infof("Requests dropped to zero, pausing in %v ...", pauseDelay)
+						pauseTimer = time.NewTimer(pauseDelay)
+						pauseCh = pauseTimer.C
+					}
 				}
 
-			case r := <-reqCh:
+			case <-pauseCh:
+				pauseTimer, pauseCh = nil, nil
+				logger.Info("Requests stayed at zero ...")
+				pause()
+
+			case admitted := <-reqCh:
 				inFlight++
 				if inFlight == 1 {
-					logger.Info("Requests increased from zero ...")
-					resume()
+					if pauseTimer != nil {
+						logger.Info("Requests increased from zero before pausing ...")
+						pauseTimer.Stop()
+						pauseTimer, pauseCh = nil, nil
+					} else {
+						logger.Info("Requests increased from zero ...")
+						resume()
+					}
 				}
-
-				go func(r req) {
-					h.ServeHTTP(r.w, r.r)
-					close(r.done) // Return from ServeHTTP
-					doneCh <- struct{}{}
-				}(r)
+				admitted <- struct{}{}
 			}
 		}
 	}()
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		done := make(chan struct{})
-		reqCh <- req{w, r, done}
-		// Block till we've processed the request
-		<-done
+		admitted := admittedChanPool.Get().(chan struct{})
+		reqCh <- admitted
+		// Block till the container is resumed, if it was paused.
+		<-admitted
+		admittedChanPool.Put(admitted)
+
+		defer func() { doneCh <- struct{}{} }()
+		h.ServeHTTP(w, r)
+	}
+}
+
+// admittedChanPool recycles the channels ConcurrencyStateHandler admits
+// requests by. They're buffered, so the tracking loop never waits on a
+// request.
+var admittedChanPool = sync.Pool{
+	New: func() interface{} {
+		return make(chan struct{}, 1)
+	},
+}
+
+// ResumeFailedHeaderName is the header of the responses to the requests
+// rejected as the user container couldn't be resumed.
+const ResumeFailedHeaderName = "Knative-Serving-Resume-Failed"
+
+// ResumeFailFastHandler rejects requests with a 503 once failed is set, i.e.
+// the user container couldn't be resumed, rather than having them wait on the
+// paused container until they time out. Within ConcurrencyStateHandler it
+// rejects the requests admitted by the failed resume, outside of it those
+// arriving later, which would otherwise retry resuming the container.
+func ResumeFailFastHandler(failed *atomic.Bool, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if failed.Load() {
+			w.Header().Set(ResumeFailedHeaderName, "true")
+			http.Error(w, "failed to resume the user container", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// PausedProbeHandler answers the kubelet probes of the user container while
+// paused is set, as a paused container can't answer them and failing its
+// liveness probe would have it restarted. They're answered with the outcome
+// of the last probe of the same path that reached the container, or as
+// passed if there was none, the container running until it was paused. Once
+// the container is resumed, the probes reach it again.
+func PausedProbeHandler(paused *atomic.Bool, next http.Handler) http.HandlerFunc {
+	var (
+		mu sync.RWMutex
+		// passed is whether the last probe of each path passed.
+		passed = make(map[string]bool, 2)
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !network.IsKubeletProbe(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if paused.Load() {
+			mu.RLock()
+			ok, known := passed[r.URL.Path]
+			mu.RUnlock()
+			if known && !ok {
+				http.Error(w, "the paused user container failed its last probe", http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		rr := pkghttp.NewResponseRecorder(w, http.StatusOK)
+		next.ServeHTTP(rr, r)
+		// Like for the kubelet, any 2xx or 3xx status passes.
+		ok := rr.ResponseCode >= http.StatusOK && rr.ResponseCode < http.StatusBadRequest
+		mu.Lock()
+		defer mu.Unlock()
+		if _, known := passed[r.URL.Path]; known || len(passed) < maxInterposedProbes {
+			passed[r.URL.Path] = ok
+		}
+	}
+}
+
+// HookRetryPolicy governs how failing pause and resume hooks are retried.
+type HookRetryPolicy struct {
+	// Attempts is the maximum number of attempts, at least 1.
+	Attempts int
+	// Backoff is the delay before the first retry, which doubles with each
+	// further retry up to MaxBackoff, unless that's 0.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout bounds each attempt, unless it's 0.
+	Timeout time.Duration
+}
+
+// Wrap returns a hook for ConcurrencyStateHandler that calls hook until it
+// succeeds, as allowed by the policy. If none of the attempts succeed, failed
+// is called with the last error, so that the pod can stop taking traffic
+// rather than serving into a container in an unknown state.
+// As ConcurrencyStateHandler runs the hooks synchronously, requests wait for
+// the retries to finish.
+func (p HookRetryPolicy) Wrap(logger *zap.SugaredLogger, name string, hook func(context.Context) error, failed func(error)) func() {
+	return func() {
+		backoff := p.Backoff
+		for attempt := 1; ; attempt++ {
+			err := p.try(hook)
+			if err == nil {
+				return
+			}
+			if attempt >= p.Attempts {
+				logger.Errorw(fmt.Sprintf("Failed to %s the user container after %d attempts", name, attempt), zap.Error(err))
+				if failed != nil {
+					failed(err)
+				}
+				return
+			}
+			logger.Warnw(fmt.Sprintf("Failed to %s the user container, retrying in %v", name, backoff), zap.Error(err))
+			time.Sleep(backoff)
+			if backoff *= 2; p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+				backoff = p.MaxBackoff
+			}
+		}
+	}
+}
+
+func (p HookRetryPolicy) try(hook func(context.Context) error) error {
+	ctx := context.Background()
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	return hook(ctx)
+}
+
+// ConcurrencyEndpoint sends pause and resume requests to the concurrency state
+// endpoint. If a token path is given, requests carry the token read from that
+// file as bearer token. The token is re-read periodically and whenever the
+// endpoint rejects it, so that rotated tokens are picked up without a restart.
+// Likewise, the TLS files are checked for changes before each request.
+type ConcurrencyEndpoint struct {
+	endpoint  atomic.String
+	tokenPath string
+	client    *reloadingClient
+	now       func() time.Time
+
+	mux      sync.Mutex
+	token    string
+	tokenAge time.Time
+}
+
+// NewConcurrencyEndpoint creates a ConcurrencyEndpoint for the given endpoint
+// and token path, using mTLS with the given files. An empty token path disables
+// authentication with a token.
+func NewConcurrencyEndpoint(endpoint, tokenPath string, tlsFiles ConcurrencyEndpointTLS) *ConcurrencyEndpoint {
+	c := &ConcurrencyEndpoint{
+		tokenPath: tokenPath,
+		client:    newReloadingClient(tlsFiles),
+		now:       time.Now,
+	}
+	c.endpoint.Store(endpoint)
+	return c
+}
+
+// SetEndpoint changes the endpoint the subsequent requests are sent to.
+func (c *ConcurrencyEndpoint) SetEndpoint(endpoint string) {
+	c.endpoint.Store(endpoint)
+}
+
+// Pause asks the endpoint to pause the user container.
+func (c *ConcurrencyEndpoint) Pause(ctx context.Context) error {
+	return c.request(ctx, "pause")
+}
+
+// Resume asks the endpoint to resume the user container.
+func (c *ConcurrencyEndpoint) Resume(ctx context.Context) error {
+	return c.request(ctx, "resume")
+}
+
+func (c *ConcurrencyEndpoint) request(ctx context.Context, action string) error {
+	endpoint := c.endpoint.Load()
+	token, err := c.getToken(false /*force*/)
+	if err != nil {
+		return err
+	}
+	code, err := c.send(ctx, endpoint, action, token)
+	if err != nil {
+		return err
+	}
+	if code == http.StatusUnauthorized && c.tokenPath != "" {
+		// The token might have been rotated since it was last read, retry once
+		// with a fresh one.
+		if token, err = c.getToken(true /*force*/); err != nil {
+			return err
+		}
+		if code, err = c.send(ctx, endpoint, action, token); err != nil {
+			return err
+		}
+	}
+	if code != http.StatusOK {
+		return fmt.Errorf("%s request to %s failed with status %d", action, endpoint, code)
+	}
+	return nil
+}
+
+func (c *ConcurrencyEndpoint) send(ctx context.Context, endpoint, action, token string) (int, error) {
+	body, err := json.Marshal(struct {
+		Action string `json:"action"`
+	}{Action: action})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s request: %w", action, err)
+	}
+	req.Header.Set(contentTypeHeader, "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client, err := c.client.get()
+	if err != nil {
+		return 0, fmt.Errorf("failed to set up the client for %s: %w", endpoint, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%s request to %s failed: %w", action, endpoint, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// getToken returns the cached token, re-reading it from disk if it's too old
+// or force is set.
+func (c *ConcurrencyEndpoint) getToken(force bool) (string, error) {
+	if c.tokenPath == "" {
+		return "", nil
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	now := c.now()
+	if !force && c.token != "" && now.Sub(c.tokenAge) < tokenRefreshInterval {
+		return c.token, nil
+	}
+	b, err := os.ReadFile(c.tokenPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token: %w", err)
 	}
+	c.token, c.tokenAge = strings.TrimSpace(string(b)), now
+	return c.token, nil
 }
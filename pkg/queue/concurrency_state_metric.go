@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	pkgmetrics "knative.dev/pkg/metrics"
+	"knative.dev/serving/pkg/metrics"
+)
+
+var (
+	concurrencyStateCallCountM = stats.Int64(
+		"concurrency_state_call_count",
+		"The number of pause and resume calls to the concurrency state endpoint",
+		stats.UnitDimensionless)
+	concurrencyStateFailureCountM = stats.Int64(
+		"concurrency_state_failure_count",
+		"The number of failed pause and resume calls to the concurrency state endpoint",
+		stats.UnitDimensionless)
+	concurrencyStateLatencyInMsecM = stats.Float64(
+		"concurrency_state_latencies",
+		"The time pause and resume calls to the concurrency state endpoint take in millisecond",
+		stats.UnitMilliseconds)
+
+	// concurrencyStateActionKey tags the measures with "pause" or "resume".
+	concurrencyStateActionKey = tag.MustNewKey("action")
+)
+
+// ConcurrencyStateMetrics records the calls of the pause and resume hooks of
+// ConcurrencyStateHandler with OpenCensus.
+type ConcurrencyStateMetrics struct {
+	statsCtx context.Context
+}
+
+// NewConcurrencyStateMetrics creates ConcurrencyStateMetrics for the given
+// revision and pod.
+func NewConcurrencyStateMetrics(ns, service, config, rev, pod string) (*ConcurrencyStateMetrics, error) {
+	keys := []tag.Key{metrics.PodKey, metrics.ContainerKey, concurrencyStateActionKey}
+	if err := pkgmetrics.RegisterResourceView(&view.View{
+		Description: "The number of pause and resume calls to the concurrency state endpoint",
+		Measure:     concurrencyStateCallCountM,
+		Aggregation: view.Count(),
+		TagKeys:     keys,
+	}, &view.View{
+		Description: "The number of failed pause and resume calls to the concurrency state endpoint",
+		Measure:     concurrencyStateFailureCountM,
+		Aggregation: view.Count(),
+		TagKeys:     keys,
+	}, &view.View{
+		Description: "The time pause and resume calls to the concurrency state endpoint take in millisecond",
+		Measure:     concurrencyStateLatencyInMsecM,
+		Aggregation: defaultLatencyDistribution,
+		TagKeys:     keys,
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx, err := metrics.PodRevisionContext(pod, "queue-proxy", ns, service, config, rev)
+	if err != nil {
+		return nil, err
+	}
+	return &ConcurrencyStateMetrics{statsCtx: ctx}, nil
+}
+
+// Hook returns a hook that records the calls of the given hook for action,
+// i.e. "pause" or "resume". Wrapped by HookRetryPolicy, every attempt counts
+// as a call.
+func (m *ConcurrencyStateMetrics) Hook(action string, hook func(context.Context) error) func(context.Context) error {
+	ctx, err := tag.New(m.statsCtx, tag.Upsert(concurrencyStateActionKey, action))
+	if err != nil {
+		// The action is a valid tag value, this is not expected to happen.
+		ctx = m.statsCtx
+	}
+	return func(hookCtx context.Context) error {
+		start := time.Now()
+		err := hook(hookCtx)
+		latency := float64(time.Since(start)) / float64(time.Millisecond)
+		if err != nil {
+			pkgmetrics.RecordBatch(ctx, concurrencyStateCallCountM.M(1),
+				concurrencyStateFailureCountM.M(1), concurrencyStateLatencyInMsecM.M(latency))
+		} else {
+			pkgmetrics.RecordBatch(ctx, concurrencyStateCallCountM.M(1),
+				concurrencyStateLatencyInMsecM.M(latency))
+		}
+		return err
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opencensus.io/resource"
+	"knative.dev/pkg/metrics/metricstest"
+	_ "knative.dev/pkg/metrics/testing"
+	"knative.dev/serving/pkg/metrics"
+)
+
+func TestConcurrencyStateMetrics(t *testing.T) {
+	t.Cleanup(func() {
+		metricstest.Unregister(concurrencyStateCallCountM.Name(),
+			concurrencyStateFailureCountM.Name(), concurrencyStateLatencyInMsecM.Name())
+	})
+	m, err := NewConcurrencyStateMetrics("ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("NewConcurrencyStateMetrics() =", err)
+	}
+
+	fail := true
+	resume := m.Hook("resume", func(context.Context) error {
+		if fail {
+			return errors.New("resume failed")
+		}
+		return nil
+	})
+	if err := resume(context.Background()); err == nil {
+		t.Error("resume() = nil, want the error of the wrapped hook")
+	}
+	fail = false
+	if err := resume(context.Background()); err != nil {
+		t.Error("resume() =", err)
+	}
+
+	wantTags := map[string]string{
+		metrics.LabelPodName:       "pod",
+		metrics.LabelContainerName: "queue-proxy",
+		"action":                   "resume",
+	}
+	wantResource := &resource.Resource{
+		Type: "knative_revision",
+		Labels: map[string]string{
+			metrics.LabelNamespaceName:     "ns",
+			metrics.LabelRevisionName:      "rev",
+			metrics.LabelServiceName:       "svc",
+			metrics.LabelConfigurationName: "cfg",
+		},
+	}
+	metricstest.AssertMetric(t,
+		metricstest.IntMetric("concurrency_state_call_count", 2, wantTags).WithResource(wantResource),
+		metricstest.IntMetric("concurrency_state_failure_count", 1, wantTags).WithResource(wantResource),
+		metricstest.DistributionCountOnlyMetric("concurrency_state_latencies", 2, wantTags).WithResource(wantResource))
+}
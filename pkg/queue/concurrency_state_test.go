@@ -17,12 +17,20 @@ limitations under the License.
 package queue
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	"go.uber.org/atomic"
+	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/util/wait"
 	pkglogging "knative.dev/pkg/logging"
 	ltesting "knative.dev/pkg/logging/testing"
@@ -36,7 +44,7 @@ func TestConcurrencyStateHandler(t *testing.T) {
 
 	handler := func(w http.ResponseWriter, r *http.Request) {}
 	logger := ltesting.TestLogger(t)
-	h := ConcurrencyStateHandler(logger, http.HandlerFunc(handler), func() { paused.Inc() }, func() { resumed.Inc() })
+	h := ConcurrencyStateHandler(logger, http.HandlerFunc(handler), func() { paused.Inc() }, func() { resumed.Inc() }, 0 /*pauseDelay*/)
 
 	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://target", nil))
 	if got, want := pollFor(paused, 1), int64(1); got != want {
@@ -69,7 +77,7 @@ func TestConcurrencyStateHandlerParallelSubsumed(t *testing.T) {
 		}
 	}
 	logger := ltesting.TestLogger(t)
-	h := ConcurrencyStateHandler(logger, http.HandlerFunc(handler), func() { paused.Inc() }, func() { resumed.Inc() })
+	h := ConcurrencyStateHandler(logger, http.HandlerFunc(handler), func() { paused.Inc() }, func() { resumed.Inc() }, 0 /*pauseDelay*/)
 
 	go func() {
 		defer func() { req1 <- struct{}{} }()
@@ -109,7 +117,7 @@ func TestConcurrencyStateHandlerParallelOverlapping(t *testing.T) {
 		}
 	}
 	logger := ltesting.TestLogger(t)
-	h := ConcurrencyStateHandler(logger, http.HandlerFunc(handler), func() { paused.Inc() }, func() { resumed.Inc() })
+	h := ConcurrencyStateHandler(logger, http.HandlerFunc(handler), func() { paused.Inc() }, func() { resumed.Inc() }, 0 /*pauseDelay*/)
 
 	go func() {
 		defer func() { req1 <- struct{}{} }()
@@ -140,14 +148,63 @@ func TestConcurrencyStateHandlerParallelOverlapping(t *testing.T) {
 	}
 }
 
+func TestConcurrencyStateHandlerPauseDelay(t *testing.T) {
+	paused := atomic.NewInt64(0)
+	resumed := atomic.NewInt64(0)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	logger := ltesting.TestLogger(t)
+	h := ConcurrencyStateHandler(logger, http.HandlerFunc(handler), func() { paused.Inc() }, func() { resumed.Inc() }, 10*time.Millisecond)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://target", nil))
+	if got, want := pollFor(resumed, 1), int64(1); got != want {
+		t.Errorf("Resume was called %d times, want %d times", got, want)
+	}
+	// The pause comes once the delay passed.
+	if got, want := pollFor(paused, 1), int64(1); got != want {
+		t.Errorf("Pause was called %d times, want %d times", got, want)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://target", nil))
+	if got, want := pollFor(resumed, 2), int64(2); got != want {
+		t.Errorf("Resume was called %d times, want %d times", got, want)
+	}
+	if got, want := pollFor(paused, 2), int64(2); got != want {
+		t.Errorf("Pause was called %d times, want %d times", got, want)
+	}
+}
+
+func TestConcurrencyStateHandlerPauseDelayCanceled(t *testing.T) {
+	paused := atomic.NewInt64(0)
+	resumed := atomic.NewInt64(0)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	// The handler logs the pending pause after the test is done, as nothing
+	// tells when the last request was counted out.
+	logger := zap.NewNop().Sugar()
+	h := ConcurrencyStateHandler(logger, http.HandlerFunc(handler), func() { paused.Inc() }, func() { resumed.Inc() }, time.Hour)
+
+	// The requests arrive within the delay, so the container is neither paused
+	// nor resumed in between.
+	for i := 0; i < 5; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://target", nil))
+	}
+	if got, want := resumed.Load(), int64(1); got != want {
+		t.Errorf("Resume was called %d times, want %d times", got, want)
+	}
+	if got, want := paused.Load(), int64(0); got != want {
+		t.Errorf("Pause was called %d times, want %d times", got, want)
+	}
+}
+
 func BenchmarkConcurrencyStateProxyHandler(b *testing.B) {
 	logger, _ := pkglogging.NewLogger("", "error")
 	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
-	stats := network.NewRequestStats(time.Now())
+	stats := NewRequestStats(time.Now())
 
 	promStatReporter, err := NewPrometheusStatsReporter(
 		"ns", "testksvc", "testksvc",
-		"pod", reportingPeriod)
+		"pod", reportingPeriod, 0 /*containerConcurrency*/)
 	if err != nil {
 		b.Fatal("Failed to create stats reporter:", err)
 	}
@@ -186,7 +243,7 @@ func BenchmarkConcurrencyStateProxyHandler(b *testing.B) {
 			}
 		}()
 
-		h := ConcurrencyStateHandler(logger, ProxyHandler(tc.breaker, stats, true /*tracingEnabled*/, baseHandler), nil, nil)
+		h := ConcurrencyStateHandler(logger, ProxyHandler(tc.breaker, stats, true /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, baseHandler), nil, nil, 0 /*pauseDelay*/)
 		b.Run("sequential-"+tc.label, func(b *testing.B) {
 			resp := httptest.NewRecorder()
 			for j := 0; j < b.N; j++ {
@@ -194,18 +251,39 @@ func BenchmarkConcurrencyStateProxyHandler(b *testing.B) {
 			}
 		})
 		b.Run("parallel-"+tc.label, func(b *testing.B) {
+			var (
+				mu        sync.Mutex
+				latencies = make([]time.Duration, 0, b.N)
+			)
 			b.RunParallel(func(pb *testing.PB) {
 				resp := httptest.NewRecorder()
+				var local []time.Duration
 				for pb.Next() {
+					start := time.Now()
 					h(resp, req)
+					local = append(local, time.Since(start))
 				}
+				mu.Lock()
+				latencies = append(latencies, local...)
+				mu.Unlock()
 			})
+			reportP99(b, latencies)
 		})
 
 		reportTicker.Stop()
 	}
 }
 
+// reportP99 reports the 99th percentile of the latencies, which the mean of
+// ns/op hides.
+func reportP99(b *testing.B, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns/op")
+}
+
 func pollFor(val *atomic.Int64, want int64) int64 {
 	var lastVal int64
 	wait.PollImmediate(1*time.Millisecond, 1*time.Second, func() (bool, error) {
@@ -214,3 +292,301 @@ func pollFor(val *atomic.Int64, want int64) int64 {
 	})
 	return lastVal
 }
+
+func TestConcurrencyEndpointTokenRotation(t *testing.T) {
+	var (
+		valid    atomic.String
+		requests atomic.Int64
+		actions  = make(chan string, 10)
+	)
+	valid.Store("old-token")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+		if r.Header.Get("Authorization") != "Bearer "+valid.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Action string `json:"action"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		actions <- body.Action
+	}))
+	defer server.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	writeToken := func(token string) {
+		if err := os.WriteFile(tokenPath, []byte(token+"\n"), 0600); err != nil {
+			t.Fatal("Failed to write token:", err)
+		}
+	}
+	writeToken("old-token")
+
+	c := NewConcurrencyEndpoint(server.URL, tokenPath, ConcurrencyEndpointTLS{})
+	if err := c.Pause(context.Background()); err != nil {
+		t.Fatal("Pause() =", err)
+	}
+	if got, want := <-actions, "pause"; got != want {
+		t.Errorf("Action = %q, want: %q", got, want)
+	}
+
+	// The token is rotated, the cached stale one is rejected once and the
+	// request is retried with the rotated one.
+	writeToken("new-token")
+	valid.Store("new-token")
+	requests.Store(0)
+	if err := c.Resume(context.Background()); err != nil {
+		t.Fatal("Resume() =", err)
+	}
+	if got, want := <-actions, "resume"; got != want {
+		t.Errorf("Action = %q, want: %q", got, want)
+	}
+	if got, want := requests.Load(), int64(2); got != want {
+		t.Errorf("Requests = %d, want: %d", got, want)
+	}
+
+	// The endpoint rejects the token on disk as well, there's only one retry.
+	valid.Store("newer-token")
+	requests.Store(0)
+	if err := c.Pause(context.Background()); err == nil {
+		t.Error("Pause() succeeded with a rejected token, want error")
+	}
+	if got, want := requests.Load(), int64(2); got != want {
+		t.Errorf("Requests = %d, want: %d", got, want)
+	}
+}
+
+func TestConcurrencyEndpointTokenRefreshInterval(t *testing.T) {
+	var gotToken atomic.String
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotToken.Store(r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("token-1"), 0600); err != nil {
+		t.Fatal("Failed to write token:", err)
+	}
+	now := time.Now()
+	c := NewConcurrencyEndpoint(server.URL, tokenPath, ConcurrencyEndpointTLS{})
+	c.now = func() time.Time { return now }
+	if err := c.Pause(context.Background()); err != nil {
+		t.Fatal("Pause() =", err)
+	}
+
+	if err := os.WriteFile(tokenPath, []byte("token-2"), 0600); err != nil {
+		t.Fatal("Failed to write token:", err)
+	}
+	if err := c.Resume(context.Background()); err != nil {
+		t.Fatal("Resume() =", err)
+	}
+	if got, want := gotToken.Load(), "Bearer token-1"; got != want {
+		t.Errorf("Authorization = %q, want cached %q", got, want)
+	}
+
+	now = now.Add(tokenRefreshInterval)
+	if err := c.Pause(context.Background()); err != nil {
+		t.Fatal("Pause() =", err)
+	}
+	if got, want := gotToken.Load(), "Bearer token-2"; got != want {
+		t.Errorf("Authorization = %q, want refreshed %q", got, want)
+	}
+}
+
+func TestConcurrencyEndpointNoToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("Authorization = %q, want none", r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	if err := NewConcurrencyEndpoint(server.URL, "", ConcurrencyEndpointTLS{}).Pause(context.Background()); err == nil {
+		t.Error("Pause() succeeded for a failing endpoint, want error")
+	}
+}
+
+func TestResumeFailFastHandler(t *testing.T) {
+	failed := atomic.NewBool(false)
+	resumed, served := atomic.NewInt64(0), atomic.NewInt64(0)
+
+	handler := func(w http.ResponseWriter, r *http.Request) { served.Inc() }
+	logger := ltesting.TestLogger(t)
+	h := ResumeFailFastHandler(failed, ConcurrencyStateHandler(logger,
+		ResumeFailFastHandler(failed, http.HandlerFunc(handler)),
+		nil /*pause*/, func() {
+			resumed.Inc()
+			failed.Store(true)
+		}, 0 /*pauseDelay*/))
+
+	for i := 1; i <= 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "http://target", nil))
+		if got, want := rec.Code, http.StatusServiceUnavailable; got != want {
+			t.Errorf("#%d: Code = %d, want: %d", i, got, want)
+		}
+		if got := rec.Header().Get(ResumeFailedHeaderName); got != "true" {
+			t.Errorf("#%d: %s = %q, want: true", i, ResumeFailedHeaderName, got)
+		}
+	}
+	// The request admitted by the failed resume is rejected within, those
+	// after it without resuming again.
+	if got := resumed.Load(); got != 1 {
+		t.Errorf("Resume was called %d times, want once", got)
+	}
+	if got := served.Load(); got != 0 {
+		t.Errorf("Served %d requests, want none", got)
+	}
+}
+
+func TestPausedProbeHandler(t *testing.T) {
+	paused := atomic.NewBool(false)
+	// The liveness probe passes, the startup probe fails.
+	served := atomic.NewInt64(0)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		served.Inc()
+		if r.URL.Path == "/startup" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+	h := PausedProbeHandler(paused, http.HandlerFunc(handler))
+
+	probe := func(path string) int {
+		req := httptest.NewRequest(http.MethodGet, "http://target"+path, nil)
+		req.Header.Set(network.KubeletProbeHeaderName, "queue")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if got, want := probe("/healthz"), http.StatusOK; got != want {
+		t.Errorf("Liveness probe code = %d, want: %d", got, want)
+	}
+	if got, want := probe("/startup"), http.StatusInternalServerError; got != want {
+		t.Errorf("Startup probe code = %d, want: %d", got, want)
+	}
+
+	paused.Store(true)
+	for path, want := range map[string]int{
+		"/healthz": http.StatusOK,
+		"/startup": http.StatusServiceUnavailable,
+		// Not probed before the pause.
+		"/other": http.StatusOK,
+	} {
+		if got := probe(path); got != want {
+			t.Errorf("Probe of %s while paused code = %d, want: %d", path, got, want)
+		}
+	}
+	if got, want := served.Load(), int64(2); got != want {
+		t.Errorf("Served %d probes, want: %d", got, want)
+	}
+	// Other requests still reach the container, e.g. to resume it.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://target/healthz", nil))
+	if got, want := served.Load(), int64(3); got != want {
+		t.Errorf("Served %d requests, want: %d", got, want)
+	}
+
+	paused.Store(false)
+	if got, want := probe("/healthz"), http.StatusOK; got != want {
+		t.Errorf("Liveness probe code after resume = %d, want: %d", got, want)
+	}
+	if got, want := served.Load(), int64(4); got != want {
+		t.Errorf("Served %d requests, want: %d", got, want)
+	}
+}
+
+func TestHookRetryPolicy(t *testing.T) {
+	errFailed := errors.New("failed")
+	policy := HookRetryPolicy{
+		Attempts:   3,
+		Backoff:    5 * time.Millisecond,
+		MaxBackoff: 8 * time.Millisecond,
+		Timeout:    10 * time.Millisecond,
+	}
+	logger := ltesting.TestLogger(t)
+
+	tests := []struct {
+		name       string
+		failures   int
+		wantCalls  int
+		wantFailed bool
+	}{{
+		name:      "success",
+		wantCalls: 1,
+	}, {
+		name:      "success on retry",
+		failures:  2,
+		wantCalls: 3,
+	}, {
+		name:       "all attempts fail",
+		failures:   5,
+		wantCalls:  3,
+		wantFailed: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			var failedErr error
+			hook := policy.Wrap(logger, "pause", func(context.Context) error {
+				calls++
+				if calls <= test.failures {
+					return errFailed
+				}
+				return nil
+			}, func(err error) {
+				failedErr = err
+			})
+
+			start := time.Now()
+			hook()
+			if calls != test.wantCalls {
+				t.Errorf("Calls = %d, want: %d", calls, test.wantCalls)
+			}
+			if got := failedErr != nil; got != test.wantFailed {
+				t.Errorf("Failed = %v (%v), want: %v", got, failedErr, test.wantFailed)
+			}
+			if test.wantFailed && !errors.Is(failedErr, errFailed) {
+				t.Errorf("Failed with %v, want: %v", failedErr, errFailed)
+			}
+			// The backoff doubles from 5ms, capped at 8ms.
+			var wantBackoff time.Duration
+			for i, backoff := 1, policy.Backoff; i < test.wantCalls; i++ {
+				wantBackoff += backoff
+				if backoff *= 2; backoff > policy.MaxBackoff {
+					backoff = policy.MaxBackoff
+				}
+			}
+			if got := time.Since(start); got < wantBackoff {
+				t.Errorf("Retries took %v, want at least the backoff of %v", got, wantBackoff)
+			}
+		})
+	}
+}
+
+func TestHookRetryPolicyTimeout(t *testing.T) {
+	policy := HookRetryPolicy{Attempts: 2, Timeout: 10 * time.Millisecond}
+
+	calls := 0
+	var failedErr error
+	hook := policy.Wrap(ltesting.TestLogger(t), "resume", func(ctx context.Context) error {
+		calls++
+		<-ctx.Done()
+		return ctx.Err()
+	}, func(err error) {
+		failedErr = err
+	})
+
+	hook()
+	if calls != 2 {
+		t.Errorf("Calls = %d, want: 2", calls)
+	}
+	if !errors.Is(failedErr, context.DeadlineExceeded) {
+		t.Errorf("Failed with %v, want: %v", failedErr, context.DeadlineExceeded)
+	}
+}
@@ -26,4 +26,50 @@ const (
 	// Main usage is to delay the termination of user-container until all
 	// accepted requests have been processed.
 	RequestQueueDrainPath = "/wait-for-drain"
+
+	// WaitForStartupPath specifies the path prefix on the admin port to wait
+	// until the container named by the rest of the path is ready. It's called
+	// by the PostStart hooks ordering the startup of the containers.
+	WaitForStartupPath = "/wait-for-startup/"
+
+	// WaitForExitPath specifies the path prefix on the admin port to wait
+	// until the proxy server is shut down and then until the container named
+	// by the rest of the path no longer responds. It's called by the PreStop
+	// hooks ordering the shutdown of the containers.
+	WaitForExitPath = "/wait-for-exit/"
+
+	// DrainPath specifies the path on the localhost-only admin port to put the
+	// queue-proxy into lame duck mode, in which it rejects new requests but
+	// completes the ones in flight, ahead of its shutdown.
+	DrainPath = "/drain"
+
+	// RequestsDebugPath specifies the path on the admin port to dump the
+	// requests currently in flight, if enabled.
+	RequestsDebugPath = "/debug/requests"
+
+	// ReadinessDebugPath specifies the path on the admin port to get the
+	// results of the last readiness probes of the containers of the pod.
+	ReadinessDebugPath = "/debug/readiness"
+
+	// BackendWeightsPath specifies the path on the localhost-only admin port
+	// to get and update the weights of the user container backends, if
+	// configured.
+	BackendWeightsPath = "/backend-weights"
+
+	// InfoPath specifies the path on the admin port to get the runtime
+	// information of the queue-proxy, like its CPU quota and GOMAXPROCS.
+	InfoPath = "/info"
+
+	// StatsPath specifies the path on the admin port to get a snapshot of
+	// the stats of the queue-proxy as JSON.
+	StatsPath = "/stats"
+
+	// SaturationHealthPath specifies the path on the admin port to check
+	// whether the breaker has been saturated for too long.
+	SaturationHealthPath = "/healthz/saturation"
+
+	// QueueStatePath specifies the path on the admin port to get the queue
+	// depth, the requests in flight and the average queue wait of the
+	// breaker as JSON.
+	QueueStatePath = "/queue-state"
 )
@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// CgroupRoot is where the cgroup filesystem of the container is mounted.
+const CgroupRoot = "/sys/fs/cgroup"
+
+// ReadCPUQuota reads the CPU quota of the container, in cores, from the
+// cgroup filesystem mounted at root. Both cgroup v2 and v1 are supported.
+// A quota of 0 means that no quota is set.
+func ReadCPUQuota(root string) (float64, error) {
+	// cgroup v2: "<quota> <period>", where quota may be "max".
+	if b, err := os.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		fields := strings.Fields(string(b))
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("malformed cpu.max: %q", b)
+		}
+		if fields[0] == "max" {
+			return 0, nil
+		}
+		return cpuQuota(fields[0], fields[1])
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+
+	// cgroup v1: the quota is -1 if unset.
+	quota, err := os.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	period, err := os.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, err
+	}
+	return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func cpuQuota(quota, period string) (float64, error) {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed CPU quota: %w", err)
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed CPU period: %w", err)
+	}
+	if q <= 0 || p <= 0 {
+		return 0, nil
+	}
+	return float64(q) / float64(p), nil
+}
+
+// SetMaxProcs sets GOMAXPROCS to the given CPU quota, rounded down, but to at
+// least 1. Nothing is changed if there is no quota. The effective GOMAXPROCS
+// is returned.
+func SetMaxProcs(quota float64) int {
+	if quota <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	procs := int(math.Floor(quota))
+	if procs < 1 {
+		procs = 1
+	}
+	runtime.GOMAXPROCS(procs)
+	return procs
+}
+
+// Info is the JSON representation of the queue-proxy's runtime information.
+type Info struct {
+	// CPUQuota is the CPU quota of the container in cores, omitted if unset.
+	CPUQuota   float64 `json:"cpuQuota,omitempty"`
+	GOMAXPROCS int     `json:"gomaxprocs"`
+}
+
+// NewInfoHandler creates a handler serving the queue-proxy's runtime
+// information, given its CPU quota, as JSON.
+func NewInfoHandler(cpuQuota float64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Info{
+			CPUQuota:   cpuQuota,
+			GOMAXPROCS: runtime.GOMAXPROCS(0),
+		})
+	})
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestReadCPUQuota(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    float64
+		wantErr bool
+	}{{
+		name: "no cgroup",
+	}, {
+		name:  "cgroup v2",
+		files: map[string]string{"cpu.max": "150000 100000\n"},
+		want:  1.5,
+	}, {
+		name:  "cgroup v2 unlimited",
+		files: map[string]string{"cpu.max": "max 100000\n"},
+	}, {
+		name:    "cgroup v2 malformed",
+		files:   map[string]string{"cpu.max": "garbage\n"},
+		wantErr: true,
+	}, {
+		name: "cgroup v1",
+		files: map[string]string{
+			"cpu/cpu.cfs_quota_us":  "50000\n",
+			"cpu/cpu.cfs_period_us": "100000\n",
+		},
+		want: 0.5,
+	}, {
+		name: "cgroup v1 unlimited",
+		files: map[string]string{
+			"cpu/cpu.cfs_quota_us":  "-1\n",
+			"cpu/cpu.cfs_period_us": "100000\n",
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range test.files {
+				path := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal("MkdirAll() =", err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal("WriteFile() =", err)
+				}
+			}
+
+			got, err := ReadCPUQuota(root)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ReadCPUQuota() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("ReadCPUQuota() = %v, want: %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSetMaxProcs(t *testing.T) {
+	prev := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(prev)
+
+	for _, test := range []struct {
+		quota float64
+		want  int
+	}{
+		{quota: 0.5, want: 1},
+		{quota: 2, want: 2},
+		{quota: 3.7, want: 3},
+		{quota: 0, want: 3}, // No quota, unchanged.
+	} {
+		if got := SetMaxProcs(test.quota); got != test.want {
+			t.Errorf("SetMaxProcs(%v) = %d, want: %d", test.quota, got, test.want)
+		}
+		if got := runtime.GOMAXPROCS(0); got != test.want {
+			t.Errorf("GOMAXPROCS after SetMaxProcs(%v) = %d, want: %d", test.quota, got, test.want)
+		}
+	}
+}
+
+func TestInfoHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewInfoHandler(1.5).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost"+InfoPath, nil))
+
+	var got Info
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal("Failed to decode info:", err)
+	}
+	if want := (Info{CPUQuota: 1.5, GOMAXPROCS: runtime.GOMAXPROCS(0)}); got != want {
+		t.Errorf("Info = %+v, want: %+v", got, want)
+	}
+}
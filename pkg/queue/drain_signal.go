@@ -0,0 +1,214 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.uber.org/atomic"
+
+	network "knative.dev/networking/pkg"
+	pkgmetrics "knative.dev/pkg/metrics"
+	"knative.dev/serving/pkg/metrics"
+)
+
+const (
+	// DrainingHeaderName is the header set to "true" on the requests forwarded
+	// to the user container once the queue-proxy drains, if the drain signal
+	// asks for it.
+	DrainingHeaderName = "Knative-Draining"
+
+	// DrainSignalUserAgent is the user-agent header value set in the call
+	// notifying the user container of the drain.
+	DrainSignalUserAgent = "Knative-Queue-Proxy-Drain"
+
+	// drainSignalCallTimeout is the time the call notifying the user
+	// container of the drain may take.
+	drainSignalCallTimeout = 5 * time.Second
+	// drainSignalPollInterval is the interval at which Wait checks whether
+	// the streaming responses completed.
+	drainSignalPollInterval = 100 * time.Millisecond
+)
+
+// DrainSignal is the JSON representation of how the user container is told
+// the queue-proxy drains.
+type DrainSignal struct {
+	// Path is the path of the user container POSTed to as the drain starts,
+	// e.g. "/draining". No call is made if empty.
+	Path string `json:"path,omitempty"`
+	// Header makes the queue-proxy set the DrainingHeaderName header on the
+	// requests it forwards while draining.
+	Header bool `json:"header,omitempty"`
+	// TimeoutSeconds is the longest the termination of the user container is
+	// delayed for the streaming responses in flight to complete, the drain
+	// timeout of the queue-proxy by default.
+	TimeoutSeconds int64 `json:"timeoutSeconds,omitempty"`
+}
+
+// ParseDrainSignal parses the JSON encoded DrainSignal.
+func ParseDrainSignal(s string) (*DrainSignal, error) {
+	d := &DrainSignal{}
+	if err := json.Unmarshal([]byte(s), d); err != nil {
+		return nil, fmt.Errorf("failed to parse the drain signal: %w", err)
+	}
+	if d.Path != "" && !strings.HasPrefix(d.Path, "/") {
+		return nil, fmt.Errorf("drain signal path %q must start with /", d.Path)
+	}
+	if d.Path == "" && !d.Header {
+		return nil, errors.New("drain signal has neither a path nor the header set")
+	}
+	if d.TimeoutSeconds < 0 {
+		return nil, fmt.Errorf("drain signal timeout %ds must not be negative", d.TimeoutSeconds)
+	}
+	return d, nil
+}
+
+var (
+	drainInFlightStreamsM = stats.Int64(
+		"drain_in_flight_streams",
+		"The number of streaming responses in flight while the queue-proxy drains",
+		stats.UnitDimensionless)
+	drainWaitTimeInMsecM = stats.Float64(
+		"drain_wait_time",
+		"The time the queue-proxy waited for the streaming responses to complete while draining in millisecond",
+		stats.UnitMilliseconds)
+)
+
+// DrainNotifier notifies the user container that the queue-proxy drains,
+// and holds off its termination until the streaming responses in flight,
+// i.e. WebSocket connections and server-sent event streams, completed.
+type DrainNotifier struct {
+	signal   DrainSignal
+	target   string
+	client   *http.Client
+	statsCtx context.Context
+
+	draining atomic.Bool
+	streams  atomic.Int64
+}
+
+// NewDrainNotifier creates a DrainNotifier calling the user container at
+// target, e.g. "http://127.0.0.1:8080", through transport.
+func NewDrainNotifier(signal *DrainSignal, target string, transport http.RoundTripper,
+	ns, service, config, rev, pod string) (*DrainNotifier, error) {
+	if err := pkgmetrics.RegisterResourceView(&view.View{
+		Description: "The number of streaming responses in flight while the queue-proxy drains",
+		Measure:     drainInFlightStreamsM,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey},
+	}, &view.View{
+		Description: "The time the queue-proxy waited for the streaming responses to complete while draining in millisecond",
+		Measure:     drainWaitTimeInMsecM,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey},
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx, err := metrics.PodRevisionContext(pod, "queue-proxy", ns, service, config, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DrainNotifier{
+		signal:   *signal,
+		target:   target,
+		client:   &http.Client{Transport: transport},
+		statsCtx: ctx,
+	}, nil
+}
+
+// Handler tracks the streaming responses passing through it and, once
+// draining, sets the DrainingHeaderName header on the requests if the signal
+// asks for it.
+func (n *DrainNotifier) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.signal.Header && n.draining.Load() {
+			r.Header.Set(DrainingHeaderName, "true")
+		}
+		if !mayStream(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		n.streams.Inc()
+		defer n.streams.Dec()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Notify marks the drain as started and makes the call to the user
+// container, if the signal has a path, returning why it failed, if it did.
+func (n *DrainNotifier) Notify() error {
+	n.draining.Store(true)
+	if n.signal.Path == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainSignalCallTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.target+n.signal.Path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create the drain signal request: %w", err)
+	}
+	req.Header.Set(network.UserAgentKey, DrainSignalUserAgent)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("drain signal call to %s failed: %w", n.signal.Path, err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("drain signal call to %s returned status %d", n.signal.Path, resp.StatusCode)
+	}
+	return nil
+}
+
+// Wait waits for the streaming responses in flight to complete, up to the
+// timeout of the signal, or drainTimeout if it has none. The streams left are
+// recorded as the drain progresses. It returns the number of streams still in
+// flight as it gave up.
+func (n *DrainNotifier) Wait(drainTimeout time.Duration) int {
+	timeout := drainTimeout
+	if n.signal.TimeoutSeconds > 0 {
+		timeout = time.Duration(n.signal.TimeoutSeconds) * time.Second
+	}
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	left := n.streams.Load()
+	for {
+		pkgmetrics.Record(n.statsCtx, drainInFlightStreamsM.M(left))
+		if left == 0 || !time.Now().Before(deadline) {
+			break
+		}
+		time.Sleep(drainSignalPollInterval)
+		left = n.streams.Load()
+	}
+	pkgmetrics.Record(n.statsCtx, drainWaitTimeInMsecM.M(float64(time.Since(start))/float64(time.Millisecond)))
+	return int(left)
+}
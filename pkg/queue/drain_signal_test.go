@@ -0,0 +1,171 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	network "knative.dev/networking/pkg"
+	"knative.dev/pkg/metrics/metricstest"
+	_ "knative.dev/pkg/metrics/testing"
+	"knative.dev/serving/pkg/metrics"
+)
+
+func TestParseDrainSignal(t *testing.T) {
+	tests := []struct {
+		name    string
+		signal  string
+		want    *DrainSignal
+		wantErr bool
+	}{{
+		name:   "path",
+		signal: `{"path": "/draining"}`,
+		want:   &DrainSignal{Path: "/draining"},
+	}, {
+		name:   "all",
+		signal: `{"path": "/draining", "header": true, "timeoutSeconds": 120}`,
+		want:   &DrainSignal{Path: "/draining", Header: true, TimeoutSeconds: 120},
+	}, {
+		name:    "not JSON",
+		signal:  "/draining",
+		wantErr: true,
+	}, {
+		name:    "relative path",
+		signal:  `{"path": "draining"}`,
+		wantErr: true,
+	}, {
+		name:    "no signal",
+		signal:  `{"timeoutSeconds": 120}`,
+		wantErr: true,
+	}, {
+		name:    "negative timeout",
+		signal:  `{"header": true, "timeoutSeconds": -1}`,
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseDrainSignal(test.signal)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseDrainSignal() = %v, want error: %v", err, test.wantErr)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Error("ParseDrainSignal (-want, +got):", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestDrainNotifier(t *testing.T) {
+	t.Cleanup(func() {
+		metricstest.Unregister(drainInFlightStreamsM.Name(), drainWaitTimeInMsecM.Name())
+	})
+
+	notified := make(chan *http.Request, 1)
+	headers := make(chan string, 10)
+	finishStream := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/draining":
+			notified <- r
+		case mayStream(r):
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-finishStream
+		default:
+			headers <- r.Header.Get(DrainingHeaderName)
+		}
+	}))
+	t.Cleanup(backend.Close)
+	backendURL, _ := url.Parse(backend.URL)
+
+	n, err := NewDrainNotifier(&DrainSignal{Path: "/draining", Header: true}, backend.URL, http.DefaultTransport,
+		"ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("NewDrainNotifier() =", err)
+	}
+	server := httptest.NewServer(n.Handler(httputil.NewSingleHostReverseProxy(backendURL)))
+	t.Cleanup(server.Close)
+
+	if _, err := http.Get(server.URL); err != nil {
+		t.Fatal("Get() =", err)
+	}
+	if got := <-headers; got != "" {
+		t.Errorf("%s = %q before the drain, want none", DrainingHeaderName, got)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Do() =", err)
+	}
+	defer resp.Body.Close()
+
+	if err := n.Notify(); err != nil {
+		t.Fatal("Notify() =", err)
+	}
+	if r := <-notified; r.Method != http.MethodPost || r.Header.Get(network.UserAgentKey) != DrainSignalUserAgent {
+		t.Errorf("Drain signal call = %s by %q, want: POST by %q", r.Method, r.Header.Get(network.UserAgentKey), DrainSignalUserAgent)
+	}
+	if _, err := http.Get(server.URL); err != nil {
+		t.Fatal("Get() =", err)
+	}
+	if got, want := <-headers, "true"; got != want {
+		t.Errorf("%s = %q while draining, want: %q", DrainingHeaderName, got, want)
+	}
+
+	tags := map[string]string{
+		metrics.LabelPodName:       "pod",
+		metrics.LabelContainerName: "queue-proxy",
+	}
+	if got, want := n.Wait(10*time.Millisecond), 1; got != want {
+		t.Errorf("Wait() = %d with the stream in flight, want: %d", got, want)
+	}
+	metricstest.AssertMetric(t, metricstest.IntMetric("drain_in_flight_streams", 1, tags))
+
+	close(finishStream)
+	if got, want := n.Wait(5*time.Second), 0; got != want {
+		t.Errorf("Wait() = %d once the stream completed, want: %d", got, want)
+	}
+	metricstest.AssertMetric(t, metricstest.IntMetric("drain_in_flight_streams", 0, tags))
+	metricstest.AssertMetricExists(t, "drain_wait_time")
+}
+
+func TestDrainNotifierFailedCall(t *testing.T) {
+	t.Cleanup(func() {
+		metricstest.Unregister(drainInFlightStreamsM.Name(), drainWaitTimeInMsecM.Name())
+	})
+	backend := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(backend.Close)
+
+	n, err := NewDrainNotifier(&DrainSignal{Path: "/draining"}, backend.URL, http.DefaultTransport,
+		"ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("NewDrainNotifier() =", err)
+	}
+	if err := n.Notify(); err == nil {
+		t.Error("Notify() = nil for a call failing with a 404")
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ForwardedPolicy governs how the forwarded chain of incoming requests, i.e.
+// their `forwarded` and `x-forwarded-for` headers, is treated before the
+// queue-proxy appends its own hop.
+type ForwardedPolicy string
+
+const (
+	// ForwardedAppend trusts the whole incoming chain and appends to it.
+	ForwardedAppend ForwardedPolicy = "append"
+	// ForwardedReplace trusts none of the incoming chain and replaces it, so
+	// the chain starts with the peer of the queue-proxy.
+	ForwardedReplace ForwardedPolicy = "replace"
+	// ForwardedTrustHops only trusts the hops added by a given number of
+	// proxies in front of the queue-proxy and drops the ones before them.
+	ForwardedTrustHops ForwardedPolicy = "trust-hops"
+)
+
+// NewForwardedChainHandler creates an http.Handler that applies the given
+// policy to the forwarded chain of the requests passed to next. The
+// trustedHops are the number of proxies trusted by ForwardedTrustHops.
+// An empty policy is ForwardedAppend, for which next is returned unchanged.
+func NewForwardedChainHandler(next http.Handler, policy ForwardedPolicy, trustedHops int) (http.Handler, error) {
+	switch policy {
+	case "", ForwardedAppend:
+		return next, nil
+	case ForwardedReplace:
+		trustedHops = 0
+	case ForwardedTrustHops:
+		if trustedHops < 0 {
+			return nil, fmt.Errorf("trusted hops must not be negative, was: %d", trustedHops)
+		}
+	default:
+		return nil, fmt.Errorf("unknown forwarded policy %q", policy)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trimForwardedChain(r.Header, "X-Forwarded-For", trustedHops)
+		trimForwardedChain(r.Header, "Forwarded", trustedHops)
+		next.ServeHTTP(w, r)
+	}), nil
+}
+
+// trimForwardedChain keeps the last hops nodes of the comma separated chain
+// in the given header, which may span multiple lines.
+func trimForwardedChain(h http.Header, key string, hops int) {
+	lines := h.Values(key)
+	if len(lines) == 0 {
+		return
+	}
+	var nodes []string
+	for _, line := range lines {
+		nodes = append(nodes, splitForwardedNodes(line)...)
+	}
+	if len(nodes) <= hops {
+		return
+	}
+	if hops == 0 {
+		h.Del(key)
+		return
+	}
+	h.Set(key, strings.Join(nodes[len(nodes)-hops:], ", "))
+}
+
+// splitForwardedNodes splits a chain at the commas outside of quoted strings,
+// which `forwarded` values may use.
+func splitForwardedNodes(chain string) []string {
+	var nodes []string
+	start, quoted := 0, false
+	for i := 0; i < len(chain); i++ {
+		switch chain[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				nodes = appendNode(nodes, chain[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return appendNode(nodes, chain[start:])
+}
+
+func appendNode(nodes []string, node string) []string {
+	if node = strings.TrimSpace(node); node != "" {
+		return append(nodes, node)
+	}
+	return nodes
+}
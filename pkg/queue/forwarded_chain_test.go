@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestForwardedChainHandler(t *testing.T) {
+	incoming := http.Header{
+		"X-Forwarded-For": {"1.1.1.1, 2.2.2.2", "3.3.3.3"},
+		"Forwarded":       {`for=1.1.1.1;host="a,b", for=2.2.2.2`, "for=3.3.3.3;proto=https"},
+	}
+
+	tests := []struct {
+		name        string
+		policy      ForwardedPolicy
+		trustedHops int
+		want        http.Header
+	}{{
+		name: "append by default",
+		want: incoming,
+	}, {
+		name:   "append",
+		policy: ForwardedAppend,
+		want:   incoming,
+	}, {
+		name:   "replace",
+		policy: ForwardedReplace,
+		want:   http.Header{},
+	}, {
+		name:        "trust one hop",
+		policy:      ForwardedTrustHops,
+		trustedHops: 1,
+		want: http.Header{
+			"X-Forwarded-For": {"3.3.3.3"},
+			"Forwarded":       {"for=3.3.3.3;proto=https"},
+		},
+	}, {
+		name:        "trust two hops",
+		policy:      ForwardedTrustHops,
+		trustedHops: 2,
+		want: http.Header{
+			"X-Forwarded-For": {"2.2.2.2, 3.3.3.3"},
+			"Forwarded":       {"for=2.2.2.2, for=3.3.3.3;proto=https"},
+		},
+	}, {
+		name:        "trust more hops than there are",
+		policy:      ForwardedTrustHops,
+		trustedHops: 5,
+		want:        incoming,
+	}, {
+		name:   "trust no hops",
+		policy: ForwardedTrustHops,
+		want:   http.Header{},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got http.Header
+			h, err := NewForwardedChainHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header
+			}), test.policy, test.trustedHops)
+			if err != nil {
+				t.Fatal("NewForwardedChainHandler() =", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req.Header = incoming.Clone()
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if !cmp.Equal(got, test.want) {
+				t.Error("Headers mismatch (-want,+got):", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestForwardedChainHandlerProxied(t *testing.T) {
+	var got string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Forwarded-For")
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal("Failed to parse backend URL:", err)
+	}
+
+	// The proxy appends the peer, i.e. the trusted proxy, to the chain.
+	h, err := NewForwardedChainHandler(httputil.NewSingleHostReverseProxy(backendURL), ForwardedTrustHops, 1)
+	if err != nil {
+		t.Fatal("NewForwardedChainHandler() =", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "6.6.6.6, 1.1.1.1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if want := "1.1.1.1, 10.0.0.1"; got != want {
+		t.Errorf("X-Forwarded-For = %q, want: %q", got, want)
+	}
+}
+
+func TestNewForwardedChainHandlerInvalid(t *testing.T) {
+	if _, err := NewForwardedChainHandler(http.NotFoundHandler(), "prepend", 0); err == nil {
+		t.Error("Unknown policy succeeded, want error")
+	}
+	if _, err := NewForwardedChainHandler(http.NotFoundHandler(), ForwardedTrustHops, -1); err == nil {
+		t.Error("Negative trusted hops succeeded, want error")
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	pkgnet "knative.dev/pkg/network"
+)
+
+// ProtocolH2C is the protocol of user containers speaking h2c, i.e. HTTP/2
+// without TLS, as named by the port of their Revision.
+const ProtocolH2C = "h2c"
+
+const (
+	// h2cReadIdleTimeout is how long a connection to the user container may
+	// be silent before it's checked with a ping, so requests aren't stuck on
+	// a connection the container dropped, e.g. as it restarted.
+	h2cReadIdleTimeout = 30 * time.Second
+	// h2cPingTimeout is how long the ping may take before the connection is
+	// closed.
+	h2cPingTimeout = 5 * time.Second
+)
+
+// NewH2CTransport creates a transport speaking h2c to the user container for
+// all requests, regardless of the protocol they were received with, so gRPC
+// trailers, flow control and bidirectional streams survive end to end rather
+// than being downgraded to HTTP/1.1. Connections are made by dial, over TCP
+// with pkgnet.DialWithBackOff if it's nil. Like pkgnet.NewProxyAutoTransport,
+// it doesn't ask for compressed responses.
+func NewH2CTransport(dial func(ctx context.Context, network, addr string) (net.Conn, error)) *http2.Transport {
+	if dial == nil {
+		dial = pkgnet.DialWithBackOff
+	}
+	return &http2.Transport{
+		AllowHTTP:          true,
+		DisableCompression: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(context.Background(), network, addr)
+		},
+		ReadIdleTimeout: h2cReadIdleTimeout,
+		PingTimeout:     h2cPingTimeout,
+	}
+}
+
+// PreserveTrailers is a ReverseProxy.ModifyResponse hook keeping the trailers
+// of the user container's response for HTTP/1.1 clients. HTTP/2 responses may
+// announce trailers and still carry a Content-Length, which would be passed on
+// and keep the response from being chunked, the only way HTTP/1.1 can carry
+// trailers.
+func PreserveTrailers(resp *http.Response) error {
+	if len(resp.Trailer) > 0 && resp.ContentLength >= 0 {
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	pkghttp "knative.dev/serving/pkg/http"
+)
+
+// h2cBackend is a user container speaking h2c only, like a gRPC server. It
+// answers with the protocol it got the request with and the request trailer,
+// and sets a trailer of its own.
+func h2cBackend(t *testing.T, l net.Listener) {
+	server := &http.Server{Handler: h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			http.Error(w, "h2c only", http.StatusHTTPVersionNotSupported)
+			return
+		}
+		io.Copy(ioutil.Discard, r.Body)
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte(r.Proto + " " + r.Trailer.Get("Client-Trailer")))
+		w.Header().Set("Grpc-Status", "0")
+	}), &http2.Server{})}
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+}
+
+func TestH2CTransportEndToEnd(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	h2cBackend(t, l)
+
+	transport := NewH2CTransport(nil /*dial*/)
+	defer transport.CloseIdleConnections()
+	proxy := pkghttp.NewHeaderPruningReverseProxy(l.Addr().String(), pkghttp.NoHostOverride, nil)
+	proxy.Transport = transport
+	proxy.ModifyResponse = PreserveTrailers
+	breaker := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 10})
+	stats := NewRequestStats(time.Now())
+	server := httptest.NewServer(ProxyHandler(breaker, stats, false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, proxy))
+	defer server.Close()
+
+	// The client speaks HTTP/1.1 with a chunked body carrying a trailer.
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("hello"))
+		pw.Close()
+	}()
+	req, err := http.NewRequest(http.MethodPost, server.URL, pr)
+	if err != nil {
+		t.Fatal("NewRequest() =", err)
+	}
+	req.Trailer = http.Header{"Client-Trailer": []string{"sent"}}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Do() =", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal("ReadAll() =", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode = %d, want %d, body: %s", resp.StatusCode, http.StatusOK, body)
+	}
+	if got, want := string(body), "HTTP/2.0 sent"; got != want {
+		t.Errorf("Body = %q, want %q", got, want)
+	}
+	if got, want := resp.Trailer.Get("Grpc-Status"), "0"; got != want {
+		t.Errorf("Grpc-Status trailer = %q, want %q", got, want)
+	}
+}
+
+func TestH2CTransportUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	h2cBackend(t, l)
+
+	transport := NewH2CTransport(UnixSocketDialer(path))
+	defer transport.CloseIdleConnections()
+	// An HTTP/1.1 request is sent as HTTP/2 nonetheless.
+	req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:8080/", nil).WithContext(context.Background())
+	req.RequestURI = ""
+	req.URL = &url.URL{Scheme: "http", Host: "127.0.0.1:8080", Path: "/"}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal("RoundTrip() =", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal("ReadAll() =", err)
+	}
+	if got, want := string(body), "HTTP/2.0 "; !strings.HasPrefix(got, want) {
+		t.Errorf("Body = %q, want prefix %q", got, want)
+	}
+}
+
+func TestPreserveTrailers(t *testing.T) {
+	resp := &http.Response{
+		Header:        http.Header{"Content-Length": []string{"5"}},
+		ContentLength: 5,
+	}
+	PreserveTrailers(resp)
+	if got, want := resp.ContentLength, int64(5); got != want {
+		t.Errorf("ContentLength without trailers = %d, want %d", got, want)
+	}
+
+	resp.Trailer = http.Header{"Grpc-Status": nil}
+	PreserveTrailers(resp)
+	if got, want := resp.ContentLength, int64(-1); got != want {
+		t.Errorf("ContentLength with trailers = %d, want %d", got, want)
+	}
+	if got := resp.Header.Get("Content-Length"); got != "" {
+		t.Errorf("Content-Length header = %q, want it removed", got)
+	}
+}
@@ -17,20 +17,39 @@ limitations under the License.
 package queue
 
 import (
+	"bufio"
 	"context"
 	"errors"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"go.opencensus.io/trace"
+	"go.uber.org/atomic"
 	network "knative.dev/networking/pkg"
+	"knative.dev/pkg/websocket"
 	"knative.dev/serving/pkg/activator"
+	pkghttp "knative.dev/serving/pkg/http"
+	"knative.dev/serving/pkg/http/handler"
 )
 
 // ProxyHandler sends requests to the `next` handler at a rate controlled by
-// the passed `breaker`, while recording stats to `stats`.
-func ProxyHandler(breaker *Breaker, stats *network.RequestStats, tracingEnabled bool, next http.Handler) http.HandlerFunc {
+// the passed `breaker`, while recording stats to `stats`. Request trailers are
+// forwarded if `next` is a proxy created by pkghttp.NewHeaderPruningReverseProxy.
+// Matching requests are also sent to the optional `mirror`. Requests with an
+// ambiguous framing are rejected, see checkFraming. If `timingHeaders` is set,
+// the responses tell the time spent queueing and in the backend, see
+// QueueWaitHeader and BackendDurationHeader.
+func ProxyHandler(breaker *Breaker, stats *RequestStats, tracingEnabled, timingHeaders bool, mirror *RequestMirror, next http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkFraming(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		if network.IsKubeletProbe(r) {
 			next.ServeHTTP(w, r)
 			return
@@ -42,26 +61,51 @@ func ProxyHandler(breaker *Breaker, stats *network.RequestStats, tracingEnabled
 			defer proxySpan.End()
 		}
 
-		// Metrics for autoscaling.
+		// Metrics for autoscaling. A request taking several slots of the
+		// breaker counts as that many requests. A stream released by Streams
+		// stops counting once it's established.
 		in, out := network.ReqIn, network.ReqOut
 		if activator.Name == network.KnativeProxyHeader(r) {
 			in, out = network.ProxiedIn, network.ProxiedOut
 		}
-		stats.HandleEvent(network.ReqEvent{Time: time.Now(), Type: in})
-		defer func() {
-			stats.HandleEvent(network.ReqEvent{Time: time.Now(), Type: out})
-		}()
+		weight := 1
+		if breaker != nil {
+			weight = breaker.RequestWeight(r.Context())
+		}
+		arrived := time.Now()
+		handleEvents(stats, network.ReqEvent{Time: arrived, Type: in}, weight)
+		if s := streamOf(r.Context()); s != nil {
+			defer s.releaseWith(func() {
+				handleEvents(stats, network.ReqEvent{Time: time.Now(), Type: out}, weight)
+			})()
+		} else {
+			defer handleEventNow(stats, out, weight)
+		}
 		network.RewriteHostOut(r)
+		// Mirroring rewraps the body, so it goes first for the proxy to find
+		// the body forwarding the trailers.
+		mirror.Mirror(r)
+		pkghttp.ForwardRequestTrailers(r)
+
+		var timing *requestTiming
+		if timingHeaders {
+			timing = &requestTiming{arrived: arrived}
+		}
 
 		// Enforce queuing and concurrency limits.
 		if breaker != nil {
 			var waitSpan *trace.Span
 			if tracingEnabled {
 				_, waitSpan = trace.StartSpan(r.Context(), "queue_wait")
+				// Building the attribute allocates, which unsampled spans
+				// don't need to.
+				if waitSpan.IsRecordingEvents() {
+					waitSpan.AddAttributes(trace.Int64Attribute("queue.depth", int64(breaker.QueueDepth())))
+				}
 			}
 			if err := breaker.Maybe(r.Context(), func() {
 				waitSpan.End()
-				next.ServeHTTP(w, r)
+				serveBackend(w, r, tracingEnabled, timing, next)
 			}); err != nil {
 				waitSpan.End()
 				if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrRequestQueueFull) {
@@ -72,7 +116,223 @@ func ProxyHandler(breaker *Breaker, stats *network.RequestStats, tracingEnabled
 				}
 			}
 		} else {
-			next.ServeHTTP(w, r)
+			serveBackend(w, r, tracingEnabled, timing, next)
 		}
 	}
 }
+
+// handleEvents records the event weight times, once per breaker slot the
+// request takes.
+func handleEvents(stats *RequestStats, event network.ReqEvent, weight int) {
+	stats.handleEvents(event, int64(weight))
+}
+
+// handleEventNow records an event of the type at the current time, weight
+// times. Unlike a closure, deferring it doesn't allocate.
+func handleEventNow(stats *RequestStats, typ network.ReqEventType, weight int) {
+	handleEvents(stats, network.ReqEvent{Time: time.Now(), Type: typ}, weight)
+}
+
+// serveBackend passes the request on to the backend, in a span of its own if
+// tracing is enabled, to tell the time spent in the backend from queueing.
+//
+// If the backend breaks off its response, which the reverse proxy signals by
+// panicking with http.ErrAbortHandler, the client gets a clean 502 if nothing
+// was sent to it yet. Otherwise the panic is propagated, which makes the
+// server close the connection rather than leaving the client with a
+// truncated response that looks complete.
+func serveBackend(w http.ResponseWriter, r *http.Request, tracingEnabled bool, timing *requestTiming, next http.Handler) {
+	if tracingEnabled {
+		backendCtx, backendSpan := trace.StartSpan(r.Context(), "queue_backend")
+		defer backendSpan.End()
+		r = r.WithContext(backendCtx)
+	}
+
+	if timing != nil {
+		timing.dispatched = time.Now()
+	}
+	bw := backendResponseWriterPool.Get().(*backendResponseWriter)
+	*bw = backendResponseWriter{ResponseWriter: w, ctx: r.Context(), timing: timing}
+	defer func() {
+		// The reverse proxy is done with the writer once it returned, even
+		// if the connection was hijacked.
+		*bw = backendResponseWriter{}
+		backendResponseWriterPool.Put(bw)
+	}()
+	defer func() {
+		if err := recover(); err != nil {
+			if err != http.ErrAbortHandler || !bw.discard() {
+				panic(err)
+			}
+			for k := range w.Header() {
+				delete(w.Header(), k)
+			}
+			http.Error(w, errBackendAborted.Error(), http.StatusBadGateway)
+		}
+	}()
+	next.ServeHTTP(bw, r)
+	bw.done.Store(true)
+	bw.send()
+}
+
+const (
+	// QueueWaitHeader is the response header telling the milliseconds a
+	// request waited for the breaker.
+	QueueWaitHeader = "X-Queue-Wait-Ms"
+	// BackendDurationHeader is the response header telling the milliseconds
+	// the backend took until it sent its response header. It's omitted for
+	// streamed responses, which the backend is still busy with by then.
+	BackendDurationHeader = "X-Backend-Duration-Ms"
+)
+
+// requestTiming records when a request arrived and when it was passed on to
+// the backend, to set the timing headers of its response.
+type requestTiming struct {
+	arrived    time.Time
+	dispatched time.Time
+}
+
+// setHeaders sets the timing headers in h. The backend is considered done if
+// it returned already or if it knows the length of its response, otherwise
+// it's streaming.
+func (t *requestTiming) setHeaders(h http.Header, done bool) {
+	h.Set(QueueWaitHeader, strconv.FormatInt(t.dispatched.Sub(t.arrived).Milliseconds(), 10))
+	if done || h.Get("Content-Length") != "" {
+		h.Set(BackendDurationHeader, strconv.FormatInt(time.Since(t.dispatched).Milliseconds(), 10))
+	}
+}
+
+var errBackendAborted = errors.New("backend aborted the response")
+
+var (
+	errAmbiguousFraming         = errors.New("request has both a Content-Length and a Transfer-Encoding")
+	errConflictingContentLength = errors.New("request has conflicting Content-Length values")
+	errInvalidContentLength     = errors.New("request has an invalid Content-Length")
+)
+
+// checkFraming returns an error if the body of r isn't framed by exactly one
+// of Content-Length or Transfer-Encoding, or if it has Content-Length values
+// that disagree. Proxies and user containers might frame such a request
+// differently, which allows smuggling requests past the queue-proxy.
+// The server of the queue-proxy already drops some of these, but the check is
+// cheap and doesn't rely on how exactly the request was parsed.
+func checkFraming(r *http.Request) error {
+	lengths := r.Header.Values("Content-Length")
+	if len(lengths) == 0 {
+		return nil
+	}
+	if len(r.TransferEncoding) > 0 || r.Header.Get("Transfer-Encoding") != "" {
+		return errAmbiguousFraming
+	}
+	if len(lengths) == 1 && !strings.Contains(lengths[0], ",") {
+		return nil
+	}
+	// Repeated values are tolerated as long as they're all the same.
+	var first string
+	for _, line := range lengths {
+		for _, v := range strings.Split(line, ",") {
+			v = strings.TrimSpace(v)
+			if _, err := strconv.ParseUint(v, 10, 63); err != nil {
+				return errInvalidContentLength
+			}
+			if first == "" {
+				first = v
+			} else if v != first {
+				return errConflictingContentLength
+			}
+		}
+	}
+	return nil
+}
+
+// backendResponseWriter holds back the response header of the backend until
+// the first byte of the body is written or flushed, so that a backend failing
+// before that can still be answered with an error instead. The timeout
+// handler is told the response started as the header is held back, so a
+// backend sending its header before a slow body doesn't time out.
+type backendResponseWriter struct {
+	http.ResponseWriter
+
+	// ctx is the context of the request, to tell the timeout handler the
+	// response started once the header is held back.
+	ctx  context.Context
+	code int
+	// timing is set if the response is to tell its timing.
+	timing *requestTiming
+	// done is set once the backend returned.
+	done atomic.Bool
+	// sent is set once the response header was passed on or discarded.
+	// Flushes may come from the timer of the reverse proxy.
+	sent atomic.Bool
+}
+
+var (
+	_ http.Flusher  = (*backendResponseWriter)(nil)
+	_ http.Hijacker = (*backendResponseWriter)(nil)
+)
+
+// backendResponseWriterPool recycles the backendResponseWriters, of which
+// every request needs one.
+var backendResponseWriterPool = sync.Pool{
+	New: func() interface{} {
+		return new(backendResponseWriter)
+	},
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *backendResponseWriter) WriteHeader(code int) {
+	// Informational responses aren't final, so aren't held back.
+	if code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.code == 0 {
+		w.code = code
+		handler.ResponseStarted(w.ctx)
+	}
+}
+
+// Write implements http.ResponseWriter.
+func (w *backendResponseWriter) Write(p []byte) (int, error) {
+	w.send()
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher.
+func (w *backendResponseWriter) Flush() {
+	w.send()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, for the reverse proxy to handle protocol
+// upgrades.
+func (w *backendResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.sent.Store(true)
+	return websocket.HijackIfPossible(w.ResponseWriter)
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for http.ResponseController.
+func (w *backendResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// send passes the held back response header on, if it wasn't yet.
+func (w *backendResponseWriter) send() {
+	if !w.sent.CAS(false, true) {
+		return
+	}
+	if w.timing != nil {
+		w.timing.setHeaders(w.Header(), w.done.Load())
+	}
+	if w.code != 0 {
+		w.ResponseWriter.WriteHeader(w.code)
+	}
+}
+
+// discard drops the held back response header and returns whether nothing
+// was sent to the client yet.
+func (w *backendResponseWriter) discard() bool {
+	return w.sent.CAS(false, true)
+}
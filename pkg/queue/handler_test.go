@@ -18,18 +18,28 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"go.opencensus.io/trace"
 	"go.uber.org/atomic"
+	"k8s.io/apimachinery/pkg/util/wait"
 	network "knative.dev/networking/pkg"
 	"knative.dev/serving/pkg/activator"
+	pkghttp "knative.dev/serving/pkg/http"
+	"knative.dev/serving/pkg/http/handler"
 )
 
 const (
@@ -47,8 +57,8 @@ func TestHandlerBreakerQueueFull(t *testing.T) {
 	breaker := NewBreaker(BreakerParams{
 		QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1,
 	})
-	stats := network.NewRequestStats(time.Now())
-	h := ProxyHandler(breaker, stats, false /*tracingEnabled*/, blockHandler)
+	stats := NewRequestStats(time.Now())
+	h := ProxyHandler(breaker, stats, false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, blockHandler)
 
 	req := httptest.NewRequest(http.MethodGet, "http://localhost:8081/time", nil)
 	resps := make(chan *httptest.ResponseRecorder)
@@ -81,6 +91,346 @@ func TestHandlerBreakerQueueFull(t *testing.T) {
 	}
 }
 
+func TestHandlerTimingHeaders(t *testing.T) {
+	const hold = 50 * time.Millisecond
+	started, release := make(chan struct{}, 3), make(chan struct{})
+	blockHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		if r.Header.Get("X-Stream") != "" {
+			w.Write([]byte("first"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("second"))
+		}
+	})
+	breaker := NewBreaker(BreakerParams{
+		QueueDepth: 10, MaxConcurrency: 1, InitialCapacity: 1,
+	})
+	stats := NewRequestStats(time.Now())
+	h := ProxyHandler(breaker, stats, false /*tracingEnabled*/, true /*timingHeaders*/, nil /*mirror*/, blockHandler)
+
+	// The first request takes the only slot of the breaker and the others
+	// queue behind it until it's released.
+	send := func(stream bool) chan *http.Response {
+		resp := make(chan *http.Response, 1)
+		go func() {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			if stream {
+				req.Header.Set("X-Stream", "true")
+			}
+			rec := httptest.NewRecorder()
+			h(rec, req)
+			resp <- rec.Result()
+		}()
+		return resp
+	}
+	first := send(false)
+	<-started
+	queued, streamed := send(false), send(true)
+	if err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+		return breaker.PendingRequests() == 2, nil
+	}); err != nil {
+		t.Fatal("Requests never queued:", err)
+	}
+	time.Sleep(hold)
+	close(release)
+
+	millis := func(t *testing.T, h http.Header, key string) time.Duration {
+		t.Helper()
+		ms, err := strconv.ParseInt(h.Get(key), 10, 64)
+		if err != nil {
+			t.Fatalf("%s = %q, want milliseconds: %v", key, h.Get(key), err)
+		}
+		return time.Duration(ms) * time.Millisecond
+	}
+
+	r := <-first
+	if got := millis(t, r.Header, QueueWaitHeader); got >= hold {
+		t.Errorf("First request waited %v, want less than %v", got, hold)
+	}
+	if got := millis(t, r.Header, BackendDurationHeader); got < hold {
+		t.Errorf("First request spent %v in the backend, want at least %v", got, hold)
+	}
+
+	// The queued requests are served one after the other, in any order.
+	for _, resp := range []chan *http.Response{queued, streamed} {
+		r := <-resp
+		if got := millis(t, r.Header, QueueWaitHeader); got < hold {
+			t.Errorf("Queued request waited %v, want at least %v", got, hold)
+		}
+		if resp == streamed {
+			if got := r.Header.Get(BackendDurationHeader); got != "" {
+				t.Errorf("%s = %q for a streamed response, want unset", BackendDurationHeader, got)
+			}
+		} else if got := millis(t, r.Header, BackendDurationHeader); got >= hold {
+			t.Errorf("Queued request spent %v in the backend, want less than %v", got, hold)
+		}
+	}
+}
+
+func TestHandlerTimingHeadersDisabled(t *testing.T) {
+	breaker := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 1, InitialCapacity: 1})
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := ProxyHandler(breaker, NewRequestStats(time.Now()), false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, backend)
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	for _, key := range []string{QueueWaitHeader, BackendDurationHeader} {
+		if got := rec.Result().Header.Get(key); got != "" {
+			t.Errorf("%s = %q, want unset", key, got)
+		}
+	}
+}
+
+func TestHandlerHeaderBeforeFirstByteTimeout(t *testing.T) {
+	const firstByteTimeout = 50 * time.Millisecond
+	// The backend answers with its header right away, and only sends its body
+	// after the first byte timeout.
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		time.Sleep(3 * firstByteTimeout)
+		io.WriteString(w, "done")
+	})
+	h := handler.NewTimeoutHandler(ProxyHandler(nil, NewRequestStats(time.Now()), false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, backend),
+		"timeout", firstByteTimeout, 0 /*idleTimeout*/)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	if got, want := rec.Code, http.StatusAccepted; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+	if got, want := rec.Body.String(), "done"; got != want {
+		t.Errorf("Body = %q, want: %q", got, want)
+	}
+}
+
+func TestHandlerTrailers(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Write([]byte("body"))
+		// Echo the request trailer and add an undeclared one.
+		w.Header().Set("X-Checksum", r.Trailer.Get("X-Request-Checksum"))
+		w.Header().Set(http.TrailerPrefix+"X-Undeclared", "surprise")
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal("Failed to parse backend URL:", err)
+	}
+
+	proxy := pkghttp.NewHeaderPruningReverseProxy(backendURL.Host, pkghttp.NoHostOverride, nil)
+	breaker := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 10})
+	stats := NewRequestStats(time.Now())
+	server := httptest.NewServer(ProxyHandler(breaker, stats, false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, proxy))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, io.NopCloser(strings.NewReader("payload")))
+	if err != nil {
+		t.Fatal("Failed to create request:", err)
+	}
+	// The trailer is sent after the (chunked) body.
+	req.Trailer = http.Header{"X-Request-Checksum": []string{"abc"}}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Request failed:", err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatal("Failed to read body:", err)
+	}
+
+	if got, want := resp.Trailer.Get("X-Checksum"), "abc"; got != want {
+		t.Errorf("X-Checksum trailer = %q, want: %q", got, want)
+	}
+	if got, want := resp.Trailer.Get("X-Undeclared"), "surprise"; got != want {
+		t.Errorf("X-Undeclared trailer = %q, want: %q", got, want)
+	}
+}
+
+func TestHandlerBackendAbort(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantCode int
+		wantBody string
+	}{{
+		name:     "before the body",
+		response: "HTTP/1.1 200 OK\r\nContent-Length: 10\r\nX-Backend: yes\r\n\r\n",
+		wantCode: http.StatusBadGateway,
+		wantBody: errBackendAborted.Error() + "\n",
+	}, {
+		name:     "mid body",
+		response: "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n",
+		wantCode: http.StatusOK,
+		wantBody: "hello",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The backend crashes, leaving its response incomplete.
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, buf, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Error("Hijack() =", err)
+					return
+				}
+				buf.WriteString(test.response)
+				buf.Flush()
+				conn.Close()
+			}))
+			defer backend.Close()
+			backendURL, err := url.Parse(backend.URL)
+			if err != nil {
+				t.Fatal("Failed to parse backend URL:", err)
+			}
+
+			proxy := pkghttp.NewHeaderPruningReverseProxy(backendURL.Host, pkghttp.NoHostOverride, nil)
+			proxy.ErrorLog = log.New(io.Discard, "", 0)
+			breaker := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 10})
+			server := httptest.NewUnstartedServer(ProxyHandler(breaker, NewRequestStats(time.Now()), false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, proxy))
+			server.Config.ErrorLog = log.New(io.Discard, "", 0)
+			server.Start()
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			if err != nil {
+				t.Fatal("Request failed:", err)
+			}
+			defer resp.Body.Close()
+			if got, want := resp.StatusCode, test.wantCode; got != want {
+				t.Errorf("StatusCode = %d, want: %d", got, want)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if got, want := string(body), test.wantBody; got != want {
+				t.Errorf("Body = %q, want: %q", got, want)
+			}
+
+			if test.wantCode == http.StatusBadGateway {
+				// A clean response, without the headers of the backend.
+				if err != nil {
+					t.Error("Failed to read body:", err)
+				}
+				if got := resp.Header.Get("X-Backend"); got != "" {
+					t.Errorf("X-Backend = %q, want it dropped", got)
+				}
+			} else if !errors.Is(err, io.ErrUnexpectedEOF) {
+				// The truncated response must not look complete.
+				t.Errorf("Reading the body = %v, want: %v", err, io.ErrUnexpectedEOF)
+			}
+		})
+	}
+}
+
+func TestHandlerRequestFraming(t *testing.T) {
+	tests := []struct {
+		name             string
+		contentLength    []string
+		transferEncoding []string // As parsed by the server.
+		teHeader         string   // As left in the header.
+		wantCode         int
+	}{{
+		name:     "no body",
+		wantCode: http.StatusOK,
+	}, {
+		name:          "content length",
+		contentLength: []string{"5"},
+		wantCode:      http.StatusOK,
+	}, {
+		name:             "chunked",
+		transferEncoding: []string{"chunked"},
+		wantCode:         http.StatusOK,
+	}, {
+		name:          "repeated equal content lengths",
+		contentLength: []string{"5", "5, 5"},
+		wantCode:      http.StatusOK,
+	}, {
+		name:             "content length and chunked",
+		contentLength:    []string{"5"},
+		transferEncoding: []string{"chunked"},
+		wantCode:         http.StatusBadRequest,
+	}, {
+		name:          "content length and transfer encoding header",
+		contentLength: []string{"5"},
+		teHeader:      "chunked",
+		wantCode:      http.StatusBadRequest,
+	}, {
+		name:          "conflicting content lengths",
+		contentLength: []string{"5", "6"},
+		wantCode:      http.StatusBadRequest,
+	}, {
+		name:          "conflicting content lengths in one header",
+		contentLength: []string{"5, 6"},
+		wantCode:      http.StatusBadRequest,
+	}, {
+		name:          "invalid repeated content length",
+		contentLength: []string{"5", "five"},
+		wantCode:      http.StatusBadRequest,
+	}, {
+		name:          "empty repeated content length",
+		contentLength: []string{"5,"},
+		wantCode:      http.StatusBadRequest,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var proxied bool
+			backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				proxied = true
+			})
+			h := ProxyHandler(nil, NewRequestStats(time.Now()), false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, backend)
+
+			req := httptest.NewRequest(http.MethodPost, "http://example.com", strings.NewReader("hello"))
+			req.Header["Content-Length"] = test.contentLength
+			req.TransferEncoding = test.transferEncoding
+			if test.teHeader != "" {
+				req.Header.Set("Transfer-Encoding", test.teHeader)
+			}
+			rec := httptest.NewRecorder()
+			h(rec, req)
+
+			if got := rec.Code; got != test.wantCode {
+				t.Errorf("Code = %d, want: %d", got, test.wantCode)
+			}
+			if want := test.wantCode == http.StatusOK; proxied != want {
+				t.Errorf("Proxied = %v, want: %v", proxied, want)
+			}
+		})
+	}
+}
+
+func TestRequestStatsSpanningWindows(t *testing.T) {
+	// The queue-proxy reports its stats in fixed windows, so a long request
+	// must contribute to each window it spans by the time it spent in it.
+	start := time.Now()
+	stats := NewRequestStats(start)
+	stats.HandleEvent(network.ReqEvent{Time: start.Add(750 * time.Millisecond), Type: network.ReqIn})
+
+	first := stats.Report(start.Add(time.Second))
+	stats.HandleEvent(network.ReqEvent{Time: start.Add(1500 * time.Millisecond), Type: network.ReqOut})
+	second := stats.Report(start.Add(2 * time.Second))
+	third := stats.Report(start.Add(3 * time.Second))
+
+	for _, test := range []struct {
+		name   string
+		report network.RequestStatsReport
+		want   float64
+	}{
+		{name: "first window", report: first, want: 0.25},
+		{name: "second window", report: second, want: 0.5},
+		{name: "after the request", report: third, want: 0},
+	} {
+		if got := test.report.AverageConcurrency; got != test.want {
+			t.Errorf("%s: AverageConcurrency = %v, want: %v", test.name, got, test.want)
+		}
+	}
+	// The request is counted in the window it arrived in only.
+	if first.RequestCount != 1 || second.RequestCount != 0 {
+		t.Errorf("RequestCount = %v and %v, want: 1 and 0", first.RequestCount, second.RequestCount)
+	}
+}
+
 func TestHandlerBreakerTimeout(t *testing.T) {
 	// This test sends a request which will take a long time to complete.
 	// Then another one with a very short context timeout.
@@ -95,8 +445,8 @@ func TestHandlerBreakerTimeout(t *testing.T) {
 	breaker := NewBreaker(BreakerParams{
 		QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1,
 	})
-	stats := network.NewRequestStats(time.Now())
-	h := ProxyHandler(breaker, stats, false /*tracingEnabled*/, blockHandler)
+	stats := NewRequestStats(time.Now())
+	h := ProxyHandler(breaker, stats, false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, blockHandler)
 
 	go func() {
 		h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost:8081/time", nil))
@@ -153,8 +503,8 @@ func TestHandlerReqEvent(t *testing.T) {
 			defer server.Close()
 			proxy := httputil.NewSingleHostReverseProxy(serverURL)
 
-			stats := network.NewRequestStats(time.Now())
-			h := ProxyHandler(br, stats, true /*tracingEnabled*/, proxy)
+			stats := NewRequestStats(time.Now())
+			h := ProxyHandler(br, stats, true /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, proxy)
 
 			writer := httptest.NewRecorder()
 			req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
@@ -173,6 +523,114 @@ func TestHandlerReqEvent(t *testing.T) {
 	}
 }
 
+// spanRecorder is a trace.Exporter recording the spans in memory.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *trace.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func TestHandlerTracingSpans(t *testing.T) {
+	recorder := &spanRecorder{}
+	trace.RegisterExporter(recorder)
+	defer trace.UnregisterExporter(recorder)
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+	defer trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+
+	const hold = 100 * time.Millisecond
+	entered := make(chan struct{}, 3)
+	breaker := NewBreaker(BreakerParams{QueueDepth: 2, MaxConcurrency: 1, InitialCapacity: 1})
+	backend := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		entered <- struct{}{}
+		time.Sleep(hold)
+	})
+	h := ProxyHandler(breaker, NewRequestStats(time.Now()), true /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, backend)
+
+	var wg sync.WaitGroup
+	serve := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost:8081/time", nil))
+		}()
+	}
+	// Saturate the breaker, so that the second and third request are queued
+	// behind the first one.
+	serve()
+	<-entered
+	serve()
+	if err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+		return breaker.QueueDepth() == 1, nil
+	}); err != nil {
+		t.Fatal("Second request was never queued")
+	}
+	serve()
+	wg.Wait()
+
+	type requestSpans struct {
+		proxy, wait, backend *trace.SpanData
+	}
+	byTrace := map[trace.TraceID]*requestSpans{}
+	for _, s := range recorder.spans {
+		rs, ok := byTrace[s.TraceID]
+		if !ok {
+			rs = &requestSpans{}
+			byTrace[s.TraceID] = rs
+		}
+		switch s.Name {
+		case "queue_proxy":
+			rs.proxy = s
+		case "queue_wait":
+			rs.wait = s
+		case "queue_backend":
+			rs.backend = s
+		}
+	}
+	if len(byTrace) != 3 {
+		t.Fatalf("Got spans of %d requests, want: 3", len(byTrace))
+	}
+
+	requests := make([]*requestSpans, 0, len(byTrace))
+	for _, rs := range byTrace {
+		if rs.proxy == nil || rs.wait == nil || rs.backend == nil {
+			t.Fatalf("Missing spans of a request: %+v", rs)
+		}
+		requests = append(requests, rs)
+	}
+	// The breaker serializes the requests in the backend.
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].backend.StartTime.Before(requests[j].backend.StartTime)
+	})
+
+	// Only the third request had another one queued ahead of it.
+	wantDepths := []int64{0, 0, 1}
+	for i, rs := range requests {
+		if rs.wait.ParentSpanID != rs.proxy.SpanID || rs.backend.ParentSpanID != rs.proxy.SpanID {
+			t.Errorf("Request %d: wait and backend spans aren't children of the proxy span", i)
+		}
+		if rs.backend.StartTime.Before(rs.wait.EndTime) {
+			t.Errorf("Request %d: backend span started before the wait span ended", i)
+		}
+		if got := rs.backend.EndTime.Sub(rs.backend.StartTime); got < hold {
+			t.Errorf("Request %d: backend span took %v, want: >= %v", i, got, hold)
+		}
+		if got, want := rs.wait.Attributes["queue.depth"], wantDepths[i]; got != want {
+			t.Errorf("Request %d: queue.depth = %v, want: %v", i, got, want)
+		}
+	}
+	if got := requests[1].wait.EndTime.Sub(requests[1].wait.StartTime); got < hold/2 {
+		t.Errorf("Queued request waited %v, want: >= %v", got, hold/2)
+	}
+	if got := requests[2].wait.EndTime.Sub(requests[2].wait.StartTime); got < hold {
+		t.Errorf("Queued request waited %v, want: >= %v", got, hold)
+	}
+}
+
 func TestIgnoreProbe(t *testing.T) {
 	// Verifies that probes don't queue.
 	resp := make(chan struct{})
@@ -214,8 +672,8 @@ func TestIgnoreProbe(t *testing.T) {
 
 	// Ensure no more than 1 request can be queued. So we'll send 3.
 	breaker := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1})
-	stats := network.NewRequestStats(time.Now())
-	h := ProxyHandler(breaker, stats, false /*tracingEnabled*/, proxy)
+	stats := NewRequestStats(time.Now())
+	h := ProxyHandler(breaker, stats, false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, proxy)
 
 	req := httptest.NewRequest(http.MethodPost, "http://prob.in", nil)
 	req.Header.Set(network.KubeletProbeHeaderName, "1") // Mark it a probe.
@@ -231,13 +689,28 @@ func TestIgnoreProbe(t *testing.T) {
 	}
 }
 
+func TestProxyHandlerAllocations(t *testing.T) {
+	stats := NewRequestStats(time.Now())
+	breaker := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 10})
+	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := ProxyHandler(breaker, stats, false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, baseHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	resp := httptest.NewRecorder()
+	// Without tracing, passing a request through the breaker to the backend
+	// shouldn't allocate at all.
+	if allocs := testing.AllocsPerRun(100, func() { h(resp, req) }); allocs != 0 {
+		t.Errorf("ProxyHandler allocations = %v, want 0", allocs)
+	}
+}
+
 func BenchmarkProxyHandler(b *testing.B) {
 	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
-	stats := network.NewRequestStats(time.Now())
+	stats := NewRequestStats(time.Now())
 
 	promStatReporter, err := NewPrometheusStatsReporter(
 		"ns", "testksvc", "testksvc",
-		"pod", reportingPeriod)
+		"pod", reportingPeriod, 0 /*containerConcurrency*/)
 	if err != nil {
 		b.Fatal("Failed to create stats reporter:", err)
 	}
@@ -276,7 +749,7 @@ func BenchmarkProxyHandler(b *testing.B) {
 			}
 		}()
 
-		h := ProxyHandler(tc.breaker, stats, true /*tracingEnabled*/, baseHandler)
+		h := ProxyHandler(tc.breaker, stats, true /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, baseHandler)
 		b.Run("sequential-"+tc.label, func(b *testing.B) {
 			resp := httptest.NewRecorder()
 			for j := 0; j < b.N; j++ {
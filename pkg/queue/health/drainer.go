@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"net/http"
+
+	"go.uber.org/atomic"
+)
+
+// drainingMessage is the body of the responses to requests rejected while
+// draining.
+const drainingMessage = "queue-proxy is draining, not accepting new requests"
+
+// Drainer puts the queue-proxy into a lame duck mode ahead of its shutdown,
+// in which it fails its readiness probes and rejects new requests, while the
+// requests already in flight complete.
+type Drainer struct {
+	state    *State
+	draining atomic.Bool
+}
+
+// NewDrainer creates a Drainer failing the probes handled with state.
+func NewDrainer(state *State) *Drainer {
+	return &Drainer{state: state}
+}
+
+// Drain makes the probes fail, so Kubernetes stops routing to the pod, and
+// rejects new requests from now on. Calling it more than once is a no-op.
+func (d *Drainer) Drain() {
+	if d.draining.CAS(false, true) {
+		d.state.shutdown()
+	}
+}
+
+// Draining returns whether Drain was called.
+func (d *Drainer) Draining() bool {
+	return d.draining.Load()
+}
+
+// Handler creates an http.Handler that rejects the requests it receives with
+// a 503 once draining, and passes them on to next otherwise. Requests that
+// were passed on before keep running. The probes need to be handled before
+// this handler, to report the draining.
+func (d *Drainer) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.Draining() {
+			// Move the client off this connection as well.
+			w.Header().Set("Connection", "close")
+			http.Error(w, drainingMessage, http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServeHTTP drains on POST requests, e.g. from a container of the pod ahead
+// of its termination. It must only be reachable from within the pod, as a
+// drained pod never serves again.
+func (d *Drainer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	d.Drain()
+}
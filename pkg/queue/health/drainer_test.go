@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"knative.dev/pkg/network"
+	"knative.dev/serving/pkg/queue"
+)
+
+func TestDrainer(t *testing.T) {
+	state := NewState()
+	drainer := NewDrainer(state)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		w.Write([]byte("done"))
+	})
+	h := ProbeHandler(state, func() bool { return true }, false /*tracingEnabled*/, drainer.Handler(backend))
+
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	probe := http.Header{network.ProbeHeaderName: {queue.Name}}
+
+	if rec := serve("/", probe); rec.Code != http.StatusOK {
+		t.Errorf("Probe StatusCode = %d, want: %d", rec.Code, http.StatusOK)
+	}
+
+	// A request in flight when draining starts.
+	slow := make(chan *httptest.ResponseRecorder)
+	go func() { slow <- serve("/slow", nil) }()
+	<-started
+
+	drainer.Drain()
+	drainer.Drain()
+
+	rec := serve("/", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("New request StatusCode = %d, want: %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if got := rec.Body.String(); !strings.Contains(got, "draining") {
+		t.Errorf("New request body = %q, want the reason", got)
+	}
+	if got, want := rec.Header().Get("Connection"), "close"; got != want {
+		t.Errorf("Connection = %q, want: %q", got, want)
+	}
+	if rec := serve("/", probe); rec.Code != http.StatusGone {
+		t.Errorf("Probe StatusCode = %d, want: %d", rec.Code, http.StatusGone)
+	}
+
+	close(release)
+	select {
+	case rec := <-slow:
+		if rec.Code != http.StatusOK || rec.Body.String() != "done" {
+			t.Errorf("Request in flight = %d, %q, want it completed", rec.Code, rec.Body.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Request in flight didn't complete")
+	}
+}
+
+func TestDrainerServeHTTP(t *testing.T) {
+	drainer := NewDrainer(NewState())
+
+	rec := httptest.NewRecorder()
+	drainer.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost"+queue.DrainPath, nil))
+	if rec.Code != http.StatusMethodNotAllowed || drainer.Draining() {
+		t.Errorf("GET = %d, draining: %v, want: %d and not draining", rec.Code, drainer.Draining(), http.StatusMethodNotAllowed)
+	}
+
+	rec = httptest.NewRecorder()
+	drainer.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://localhost"+queue.DrainPath, nil))
+	if rec.Code != http.StatusOK || !drainer.Draining() {
+		t.Errorf("POST = %d, draining: %v, want: %d and draining", rec.Code, drainer.Draining(), http.StatusOK)
+	}
+}
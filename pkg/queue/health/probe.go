@@ -17,6 +17,8 @@ limitations under the License.
 package health
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -24,9 +26,13 @@ import (
 	"net/url"
 	"time"
 
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
 	corev1 "k8s.io/api/core/v1"
 	network "knative.dev/networking/pkg"
 	pkgnet "knative.dev/pkg/network"
+	"knative.dev/serving/pkg/queue"
 )
 
 // HTTPProbeConfigOptions holds the HTTP probe config options
@@ -36,19 +42,38 @@ type HTTPProbeConfigOptions struct {
 	KubeMajor     string
 	KubeMinor     string
 	MaxProtoMajor int
+	// UnixSocket is the path of the Unix domain socket to send the request
+	// over, instead of connecting to the host and port of the action.
+	UnixSocket string
 }
 
 // TCPProbeConfigOptions holds the TCP probe config options
 type TCPProbeConfigOptions struct {
 	SocketTimeout time.Duration
 	Address       string
+	// Network is the network of the address, "tcp" if empty.
+	Network string
 }
 
-// TCPProbe checks that a TCP socket to the address can be opened.
+// GRPCProbeConfigOptions holds the gRPC probe config options
+type GRPCProbeConfigOptions struct {
+	Timeout time.Duration
+	Address string
+	// Service is the name of the service checked, the server as a whole if
+	// empty.
+	Service string
+}
+
+// TCPProbe checks that a TCP socket, or a socket of the network of the
+// config, to the address can be opened.
 // Did not reuse k8s.io/kubernetes/pkg/probe/tcp to not create a dependency
 // on klog.
 func TCPProbe(config TCPProbeConfigOptions) error {
-	conn, err := net.DialTimeout("tcp", config.Address, config.SocketTimeout)
+	netw := config.Network
+	if netw == "" {
+		netw = "tcp"
+	}
+	conn, err := net.DialTimeout(netw, config.Address, config.SocketTimeout)
 	if err != nil {
 		return err
 	}
@@ -56,10 +81,37 @@ func TCPProbe(config TCPProbeConfigOptions) error {
 	return nil
 }
 
+// GRPCProbe checks that the server at the address reports the service as
+// serving by the gRPC Health Checking Protocol.
+func GRPCProbe(config GRPCProbeConfigOptions) error {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, config.Address, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return fmt.Errorf("failed to connect to the gRPC server: %w", err)
+	}
+	defer conn.Close()
+
+	res, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{
+		Service: config.Service,
+	})
+	if err != nil {
+		return fmt.Errorf("gRPC health check failed: %w", err)
+	}
+	if res.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("gRPC probe did not respond Serving, got status: %s", res.Status)
+	}
+	return nil
+}
+
 // Returns a transport that uses HTTP/2 if it's known to be supported, and otherwise
 // spoofs the request & response versions to HTTP/1.1.
 func autoDowngradingTransport(opt HTTPProbeConfigOptions) http.RoundTripper {
 	t := pkgnet.NewProberTransport()
+	if opt.UnixSocket != "" {
+		t = unixSocketProberTransport(opt.UnixSocket)
+	}
 	return pkgnet.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
 		// If the user-container can handle HTTP2, we pass through the request as-is.
 		// We have to set r.ProtoMajor to 2, since auto transport relies solely on it
@@ -91,6 +143,27 @@ var transport = func() *http.Transport {
 	return t
 }()
 
+// unixSocketProberTransport returns a transport like pkgnet.NewProberTransport
+// that connects to the Unix domain socket at path.
+func unixSocketProberTransport(path string) http.RoundTripper {
+	dial := queue.UnixSocketDialer(path)
+	v1 := http.DefaultTransport.(*http.Transport).Clone()
+	v1.DialContext = dial
+	v1.DisableKeepAlives = true
+	v2 := &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(context.Background(), network, addr)
+		},
+	}
+	return pkgnet.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.ProtoMajor == 2 {
+			return v2.RoundTrip(r)
+		}
+		return v1.RoundTrip(r)
+	})
+}
+
 func getURL(config HTTPProbeConfigOptions) url.URL {
 	return url.URL{
 		Scheme: string(config.Scheme),
@@ -103,8 +176,15 @@ func getURL(config HTTPProbeConfigOptions) url.URL {
 // connection can be understood by the address.
 // Returns: the highest known proto version supported (0 if not ready or error)
 func http2UpgradeProbe(config HTTPProbeConfigOptions) (int, error) {
+	t := http.RoundTripper(transport)
+	if config.UnixSocket != "" {
+		unixTransport := transport.Clone()
+		unixTransport.DialContext = queue.UnixSocketDialer(config.UnixSocket)
+		unixTransport.DisableKeepAlives = true
+		t = unixTransport
+	}
 	httpClient := &http.Client{
-		Transport: transport,
+		Transport: t,
 		Timeout:   config.Timeout,
 	}
 	url := getURL(config)
@@ -17,9 +17,11 @@ limitations under the License.
 package health
 
 import (
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -28,6 +30,9 @@ import (
 	"go.uber.org/atomic"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	network "knative.dev/networking/pkg"
@@ -53,6 +58,113 @@ func TestTCPProbe(t *testing.T) {
 	}
 }
 
+// newUnixSocketServer serves handler on a Unix domain socket and returns its
+// path.
+func newUnixSocketServer(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "user.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	server := &http.Server{Handler: h2c.NewHandler(handler, &http2.Server{})}
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+	return path
+}
+
+func TestUnixSocketTCPProbe(t *testing.T) {
+	path := newUnixSocketServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	config := TCPProbeConfigOptions{
+		Network:       "unix",
+		Address:       path,
+		SocketTimeout: time.Second,
+	}
+	if err := TCPProbe(config); err != nil {
+		t.Error("Probe failed with:", err)
+	}
+
+	config.Address = filepath.Join(filepath.Dir(path), "missing.sock")
+	if err := TCPProbe(config); err == nil {
+		t.Error("Expected probe to fail but it didn't")
+	}
+}
+
+func TestUnixSocketHTTPProbe(t *testing.T) {
+	var gotPath string
+	path := newUnixSocketServer(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+	})
+
+	for _, maxProto := range []int{0, 1, 2} {
+		gotPath = ""
+		config := HTTPProbeConfigOptions{
+			Timeout: time.Second,
+			HTTPGetAction: &corev1.HTTPGetAction{
+				Host:   "127.0.0.1",
+				Port:   intstr.FromInt(8080),
+				Path:   "/healthz",
+				Scheme: corev1.URISchemeHTTP,
+			},
+			MaxProtoMajor: maxProto,
+			UnixSocket:    path,
+		}
+		if err := HTTPProbe(config); err != nil {
+			t.Errorf("Probe with MaxProtoMajor %d failed with: %v", maxProto, err)
+		}
+		if maxProto != 0 && gotPath != "/healthz" {
+			t.Errorf("Path = %q, want: /healthz", gotPath)
+		}
+	}
+}
+
+func TestGRPCProbe(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	server := grpc.NewServer()
+	healthServer := grpchealth.NewServer()
+	healthServer.SetServingStatus("up", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("down", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	tests := []struct {
+		service string
+		wantErr bool
+	}{{
+		// The server as a whole is serving by default.
+		service: "",
+	}, {
+		service: "up",
+	}, {
+		service: "down",
+		wantErr: true,
+	}, {
+		service: "unknown",
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		err := GRPCProbe(GRPCProbeConfigOptions{
+			Address: lis.Addr().String(),
+			Service: test.service,
+			Timeout: time.Second,
+		})
+		if got := err != nil; got != test.wantErr {
+			t.Errorf("GRPCProbe(%q) = %v, want error: %v", test.service, err, test.wantErr)
+		}
+	}
+
+	// Stop the server so probing fails afterwards
+	server.Stop()
+	if err := GRPCProbe(GRPCProbeConfigOptions{Address: lis.Addr().String(), Timeout: 100 * time.Millisecond}); err == nil {
+		t.Error("Expected probe to fail but it didn't")
+	}
+}
+
 func TestHTTPProbeSuccess(t *testing.T) {
 	var (
 		gotHeader        corev1.HTTPHeader
@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+const (
+	// InFlightStateQueued is the state of a request waiting for a Breaker slot.
+	InFlightStateQueued = "queued"
+	// InFlightStateRunning is the state of a request being handled by the backend.
+	InFlightStateRunning = "running"
+)
+
+// InFlightRequest is the JSON representation of a request currently being
+// handled by the queue-proxy.
+type InFlightRequest struct {
+	ID             uint64    `json:"id"`
+	Method         string    `json:"method"`
+	Path           string    `json:"path"`
+	StartTime      time.Time `json:"startTime"`
+	ElapsedSeconds float64   `json:"elapsedSeconds"`
+	State          string    `json:"state"`
+}
+
+type inFlightRequest struct {
+	id      uint64
+	method  string
+	path    string
+	start   time.Time
+	running atomic.Bool
+}
+
+type inFlightKey struct{}
+
+// InFlightRequests keeps track of the requests currently being handled by the
+// queue-proxy, so that they can be dumped for debugging purposes.
+type InFlightRequests struct {
+	nextID   atomic.Uint64
+	requests sync.Map // uint64 -> *inFlightRequest
+}
+
+// NewInFlightRequests creates a new InFlightRequests tracker.
+func NewInFlightRequests() *InFlightRequests {
+	return &InFlightRequests{}
+}
+
+// TrackHandler registers every request passing through it as queued until it
+// leaves the handler. It's supposed to wrap the ProxyHandler.
+func (t *InFlightRequests) TrackHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &inFlightRequest{
+			id:     t.nextID.Inc(),
+			method: r.Method,
+			path:   r.URL.Path,
+			start:  time.Now(),
+		}
+		t.requests.Store(req.id, req)
+		defer t.requests.Delete(req.id)
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), inFlightKey{}, req)))
+	})
+}
+
+// RunningHandler marks requests tracked by TrackHandler as running in the
+// backend. It's supposed to be wrapped by the ProxyHandler, so that it's only
+// invoked once the request was admitted by the Breaker.
+func (t *InFlightRequests) RunningHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if req, ok := r.Context().Value(inFlightKey{}).(*inFlightRequest); ok {
+			req.running.Store(true)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Snapshot returns the requests currently in flight, oldest first.
+func (t *InFlightRequests) Snapshot() []InFlightRequest {
+	now := time.Now()
+	ret := []InFlightRequest{}
+	t.requests.Range(func(_, v interface{}) bool {
+		req := v.(*inFlightRequest)
+		state := InFlightStateQueued
+		if req.running.Load() {
+			state = InFlightStateRunning
+		}
+		ret = append(ret, InFlightRequest{
+			ID:             req.id,
+			Method:         req.method,
+			Path:           req.path,
+			StartTime:      req.start,
+			ElapsedSeconds: now.Sub(req.start).Seconds(),
+			State:          state,
+		})
+		return true
+	})
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ID < ret[j].ID
+	})
+	return ret
+}
+
+// ServeHTTP serves the snapshot of in-flight requests as JSON.
+func (t *InFlightRequests) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set(contentTypeHeader, "application/json")
+	json.NewEncoder(w).Encode(t.Snapshot())
+}
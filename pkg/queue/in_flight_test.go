@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestInFlightRequests(t *testing.T) {
+	seen := make(chan struct{})
+	resp := make(chan struct{})
+	blockHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen <- struct{}{}
+		<-resp
+	})
+
+	tracker := NewInFlightRequests()
+	breaker := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 1, InitialCapacity: 1})
+	stats := NewRequestStats(time.Now())
+	h := tracker.TrackHandler(ProxyHandler(breaker, stats, false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, tracker.RunningHandler(blockHandler)))
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://localhost/slow", nil))
+		done <- struct{}{}
+	}()
+	// Wait for the first request to be running, so that the second one is queued.
+	<-seen
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "http://localhost/slower", nil))
+		done <- struct{}{}
+	}()
+
+	// Wait for the second request to be tracked.
+	if err := wait.PollImmediate(5*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return len(tracker.Snapshot()) == 2, nil
+	}); err != nil {
+		t.Fatal("Timed out waiting for 2 requests to be in flight")
+	}
+
+	rec := httptest.NewRecorder()
+	tracker.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost"+RequestsDebugPath, nil))
+	if got, want := rec.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("Content-Type = %q, want: %q", got, want)
+	}
+	var got []InFlightRequest
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal("Failed to unmarshal dump:", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("len(dump) = %d, want: 2", len(got))
+	}
+
+	want := []struct{ method, path, state string }{
+		{http.MethodGet, "/slow", InFlightStateRunning},
+		{http.MethodPost, "/slower", InFlightStateQueued},
+	}
+	for i, w := range want {
+		if got[i].Method != w.method || got[i].Path != w.path || got[i].State != w.state {
+			t.Errorf("dump[%d] = %+v, want method %s, path %s, state %s", i, got[i], w.method, w.path, w.state)
+		}
+		if got[i].StartTime.IsZero() || got[i].ElapsedSeconds < 0 {
+			t.Errorf("dump[%d] has invalid timing: %+v", i, got[i])
+		}
+	}
+
+	// Let both requests finish.
+	resp <- struct{}{}
+	<-seen
+	resp <- struct{}{}
+	<-done
+	<-done
+
+	if got := tracker.Snapshot(); len(got) != 0 {
+		t.Errorf("Snapshot after completion = %v, want empty", got)
+	}
+}
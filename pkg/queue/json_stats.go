@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/json"
+	"net/http"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// JSONStatsSchemaVersion is the version of the JSONStats schema. It's bumped
+// on every incompatible change of the schema.
+const JSONStatsSchemaVersion = 1
+
+// JSONStats is a point-in-time snapshot of the stats of a
+// PrometheusStatsReporter, for consumers that don't speak Prometheus.
+type JSONStats struct {
+	SchemaVersion int `json:"schemaVersion"`
+
+	RequestsPerSecond                float64 `json:"requestsPerSecond"`
+	ProxiedRequestsPerSecond         float64 `json:"proxiedRequestsPerSecond"`
+	AverageConcurrentRequests        float64 `json:"averageConcurrentRequests"`
+	AverageProxiedConcurrentRequests float64 `json:"averageProxiedConcurrentRequests"`
+	ProcessUptimeSeconds             float64 `json:"processUptimeSeconds"`
+
+	// ConcurrencyUtilization is omitted if the container concurrency is
+	// unlimited and QueueDepth if there is no breaker.
+	ConcurrencyUtilization *float64 `json:"concurrencyUtilization,omitempty"`
+	QueueDepth             *float64 `json:"queueDepth,omitempty"`
+
+	// RequestCount is the total number of requests received, which are also
+	// broken down by method and response code class, e.g. "2xx".
+	RequestCount                uint64            `json:"requestCount"`
+	RequestsByMethod            map[string]uint64 `json:"requestsByMethod"`
+	RequestsByResponseCodeClass map[string]uint64 `json:"requestsByResponseCodeClass"`
+	// RequestsByBackend is omitted if no backends are configured.
+	RequestsByBackend map[string]uint64 `json:"requestsByBackend,omitempty"`
+}
+
+// JSONStats returns a snapshot of the stats. It's read from the same metrics
+// that are served in Prometheus format, so the two never disagree.
+func (r *PrometheusStatsReporter) JSONStats() (*JSONStats, error) {
+	families, err := r.gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &JSONStats{
+		SchemaVersion:               JSONStatsSchemaVersion,
+		RequestsByMethod:            map[string]uint64{},
+		RequestsByResponseCodeClass: map[string]uint64{},
+	}
+	gauges := map[string]*float64{
+		"queue_requests_per_second":                 &stats.RequestsPerSecond,
+		"queue_proxied_operations_per_second":       &stats.ProxiedRequestsPerSecond,
+		"queue_average_concurrent_requests":         &stats.AverageConcurrentRequests,
+		"queue_average_proxied_concurrent_requests": &stats.AverageProxiedConcurrentRequests,
+		"process_uptime":                            &stats.ProcessUptimeSeconds,
+	}
+	for _, family := range families {
+		for _, m := range family.Metric {
+			labels := labelValues(m)
+			if !r.owns(labels) {
+				continue
+			}
+			switch name := family.GetName(); name {
+			case "queue_concurrency_utilization":
+				if r.concurrencyUtilization != nil {
+					stats.ConcurrencyUtilization = floatPtr(m.GetGauge().GetValue())
+				}
+			case "queue_depth":
+				if r.breaker.Load() != nil {
+					stats.QueueDepth = floatPtr(m.GetGauge().GetValue())
+				}
+			case "queue_requests_by_method_total":
+				count := uint64(m.GetCounter().GetValue())
+				stats.RequestCount += count
+				stats.RequestsByMethod[labels[requestMethodLabel]] += count
+				stats.RequestsByResponseCodeClass[labels[responseCodeClassLabel]] += count
+			case "queue_requests_by_backend_total":
+				if stats.RequestsByBackend == nil {
+					stats.RequestsByBackend = map[string]uint64{}
+				}
+				stats.RequestsByBackend[labels[backendLabel]] += uint64(m.GetCounter().GetValue())
+			default:
+				if v, ok := gauges[name]; ok {
+					*v = m.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	return stats, nil
+}
+
+// ServeJSON serves a snapshot of the stats as JSON over HTTP.
+func (r *PrometheusStatsReporter) ServeJSON(w http.ResponseWriter, req *http.Request) {
+	stats, err := r.JSONStats()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// owns returns whether a metric with the given labels was recorded by this
+// reporter, as the metric vectors are shared by all reporters in the process.
+func (r *PrometheusStatsReporter) owns(labels map[string]string) bool {
+	for k, v := range r.labels {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func labelValues(m *dto.Metric) map[string]string {
+	labels := make(map[string]string, len(m.Label))
+	for _, l := range m.Label {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
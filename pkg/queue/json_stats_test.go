@@ -0,0 +1,84 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	network "knative.dev/networking/pkg"
+)
+
+func TestPrometheusStatsReporterServeJSON(t *testing.T) {
+	// A pod of its own, as the metric vectors are shared with the other tests
+	// and repeated runs.
+	pod := "json-stats-pod-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, 10 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	reporter.ObserveBreaker(NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1}))
+
+	h := reporter.RequestMethodHandler(reporter.BackendRequestHandler("8080",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		})))
+	for _, method := range []string{http.MethodGet, http.MethodGet, http.MethodPost} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "http://example.com", nil))
+	}
+	reporter.Report(network.RequestStatsReport{
+		AverageConcurrency: 5,
+		RequestCount:       3,
+	})
+
+	rec := httptest.NewRecorder()
+	reporter.ServeJSON(rec, httptest.NewRequest(http.MethodGet, "http://localhost"+StatsPath, nil))
+	if got, want := rec.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("Content-Type = %q, want: %q", got, want)
+	}
+
+	var got JSONStats
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal("Failed to decode stats:", err)
+	}
+	utilization, queueDepth := 0.5, 0.
+	want := JSONStats{
+		SchemaVersion:             JSONStatsSchemaVersion,
+		RequestsPerSecond:         3,
+		AverageConcurrentRequests: 5,
+		ConcurrencyUtilization:    &utilization,
+		QueueDepth:                &queueDepth,
+		RequestCount:              3,
+		RequestsByMethod:          map[string]uint64{http.MethodGet: 2, http.MethodPost: 1},
+		RequestsByResponseCodeClass: map[string]uint64{
+			"2xx": 2,
+			"5xx": 1,
+		},
+		RequestsByBackend: map[string]uint64{"8080": 3},
+	}
+	if !cmp.Equal(got, want, cmpopts.IgnoreFields(JSONStats{}, "ProcessUptimeSeconds")) {
+		t.Error("Stats mismatch (-want,+got):", cmp.Diff(want, got, cmpopts.IgnoreFields(JSONStats{}, "ProcessUptimeSeconds")))
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+	pkgnet "knative.dev/pkg/network"
+	"knative.dev/serving/pkg/activator"
+)
+
+const (
+	// mirrorMaxBodyBytes is the largest request body that is mirrored.
+	// Requests with larger bodies are only served by the primary backend.
+	mirrorMaxBodyBytes = 1 << 20
+
+	// mirrorMaxInFlight is the maximum number of concurrently mirrored
+	// requests. Requests beyond are not mirrored.
+	mirrorMaxInFlight = 16
+
+	// mirrorTimeout bounds how long a mirrored request may take.
+	mirrorTimeout = 10 * time.Second
+
+	// MirrorHeaderName is the header the Ingress of a Route mirroring its
+	// traffic appends to the requests to its Revisions, carrying the JSON
+	// serialized MirrorPolicy.
+	MirrorHeaderName = "Knative-Serving-Mirror"
+
+	// MirroredHeaderName tags the requests mirrored to a shadow Revision,
+	// for its metrics to tell them apart from its own traffic.
+	MirroredHeaderName = "Knative-Serving-Mirrored"
+)
+
+// MirrorPolicy is the share of the traffic of a Route that is mirrored to a
+// shadow Revision of its namespace.
+type MirrorPolicy struct {
+	// Revision is the name of the shadow Revision.
+	Revision string `json:"revision"`
+	// Percent is the percentage of the requests mirrored, in (0, 100].
+	Percent float64 `json:"percent"`
+}
+
+// ParseMirrorPolicy parses the JSON serialized MirrorPolicy.
+func ParseMirrorPolicy(s string) (*MirrorPolicy, error) {
+	p := &MirrorPolicy{}
+	if err := json.Unmarshal([]byte(s), p); err != nil {
+		return nil, fmt.Errorf("failed to parse mirror policy: %w", err)
+	}
+	if p.Revision == "" {
+		return nil, fmt.Errorf("mirror policy %q has no revision", s)
+	}
+	return p, nil
+}
+
+// String returns the JSON serialization of the policy.
+func (p *MirrorPolicy) String() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+// RequestMirror duplicates the requests carrying a given header value to a
+// secondary target, e.g. a debug sink, without affecting the response of the
+// primary backend. Mirrored requests are sent fire-and-forget and their
+// responses, as well as any failure, are discarded.
+type RequestMirror struct {
+	mirrorSender
+	target *url.URL
+	header string
+	value  string
+}
+
+// mirrorSender sends the copies of requests, up to mirrorMaxInFlight at once.
+type mirrorSender struct {
+	transport http.RoundTripper
+	logger    *zap.SugaredLogger
+
+	inFlight chan struct{}
+}
+
+func newMirrorSender(transport http.RoundTripper, logger *zap.SugaredLogger) mirrorSender {
+	return mirrorSender{
+		transport: transport,
+		logger:    logger,
+		inFlight:  make(chan struct{}, mirrorMaxInFlight),
+	}
+}
+
+// NewRequestMirror creates a RequestMirror sending the requests whose header
+// has the given value to target via transport.
+func NewRequestMirror(target *url.URL, header, value string, transport http.RoundTripper, logger *zap.SugaredLogger) *RequestMirror {
+	return &RequestMirror{
+		mirrorSender: newMirrorSender(transport, logger),
+		target:       target,
+		header:       header,
+		value:        value,
+	}
+}
+
+// Mirror sends a copy of r to the target if r carries the mirror header.
+// The body of matching requests is buffered, so both the primary backend and
+// the mirror get all of it. Requests not carrying the header are left alone.
+func (m *RequestMirror) Mirror(r *http.Request) {
+	if m == nil || r.Header.Get(m.header) != m.value {
+		return
+	}
+	m.send(r, m.target, nil /*prepare*/)
+}
+
+// send sends a copy of r to target, calling prepare with it first if set.
+// The body of r is buffered, so both r and the copy get all of it.
+func (m *mirrorSender) send(r *http.Request, target *url.URL, prepare func(*http.Request)) {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var buf bytes.Buffer
+		n, err := io.CopyN(&buf, r.Body, mirrorMaxBodyBytes+1)
+		if (err != nil && err != io.EOF) || n > mirrorMaxBodyBytes {
+			// Hand the primary backend what was read and leave the rest of the
+			// body to it, but don't mirror.
+			r.Body = readCloser{io.MultiReader(&buf, r.Body), r.Body}
+			return
+		}
+		body = buf.Bytes()
+		r.Body = readCloser{bytes.NewReader(body), r.Body}
+	}
+
+	select {
+	case m.inFlight <- struct{}{}:
+	default:
+		m.logger.Debug("Too many mirrored requests in flight, not mirroring")
+		return
+	}
+
+	// The mirrored request must outlive r, so it gets a context of its own.
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	mr := r.Clone(ctx)
+	mr.URL.Scheme, mr.URL.Host = target.Scheme, target.Host
+	mr.RequestURI = ""
+	mr.Body = nil
+	if body != nil {
+		mr.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if prepare != nil {
+		prepare(mr)
+	}
+
+	go func() {
+		defer func() { <-m.inFlight }()
+		defer cancel()
+		resp, err := m.transport.RoundTrip(mr)
+		if err != nil {
+			m.logger.Debugw("Failed to mirror request", zap.Error(err))
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+}
+
+// ShadowMirror mirrors a share of the requests to a shadow Revision, as told
+// by the MirrorPolicy the Ingress of the Route appends to them, for the
+// ingress implementations that can't mirror traffic themselves. The copies
+// are sent to the Kubernetes Service of the shadow Revision, tagged with the
+// MirroredHeaderName header, and their responses are discarded.
+type ShadowMirror struct {
+	mirrorSender
+	namespace string
+	// serviceHost returns the host of the Service of the named Revision.
+	serviceHost func(revision string) string
+	// percent returns a random number in [0, 100).
+	percent func() float64
+}
+
+// NewShadowMirror creates a ShadowMirror sending the mirrored requests to the
+// Revisions of namespace via transport.
+func NewShadowMirror(namespace string, transport http.RoundTripper, logger *zap.SugaredLogger) *ShadowMirror {
+	return &ShadowMirror{
+		mirrorSender: newMirrorSender(transport, logger),
+		namespace:    namespace,
+		serviceHost: func(revision string) string {
+			return pkgnet.GetServiceHostname(revision, namespace)
+		},
+		percent: func() float64 {
+			return rand.Float64() * 100
+		},
+	}
+}
+
+// Handler mirrors the requests carrying the MirrorHeaderName header as its
+// MirrorPolicy says before passing them on to next. The header is removed
+// from the requests.
+func (m *ShadowMirror) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get(MirrorHeaderName); v != "" {
+			r.Header.Del(MirrorHeaderName)
+			// The header is set by the Ingress, so it's not expected to be invalid.
+			if policy, err := ParseMirrorPolicy(v); err == nil && m.percent() < policy.Percent {
+				target := &url.URL{Scheme: "http", Host: m.serviceHost(policy.Revision)}
+				m.send(r, target, func(mr *http.Request) {
+					mr.Host = target.Host
+					mr.Header.Set(activator.RevisionHeaderName, policy.Revision)
+					mr.Header.Set(activator.RevisionHeaderNamespace, m.namespace)
+					mr.Header.Set(MirroredHeaderName, "true")
+				})
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readCloser reads from one reader and closes another one.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,300 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	. "knative.dev/pkg/logging/testing"
+	pkghttp "knative.dev/serving/pkg/http"
+)
+
+type mirroredRequest struct {
+	method, path, host, body string
+}
+
+// newMirrorTarget starts a server acting as the mirror, which reports the
+// requests it receives on the returned channel.
+func newMirrorTarget(t *testing.T) (*url.URL, chan mirroredRequest) {
+	reqs := make(chan mirroredRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs <- mirroredRequest{method: r.Method, path: r.URL.Path, host: r.Host, body: string(body)}
+		http.Error(w, "ignored", http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal("Failed to parse mirror URL:", err)
+	}
+	return target, reqs
+}
+
+// primaryHandler echoes the body of the request.
+var primaryHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, "primary: ")
+	io.Copy(w, r.Body)
+})
+
+func TestRequestMirror(t *testing.T) {
+	target, reqs := newMirrorTarget(t)
+	mirror := NewRequestMirror(target, "X-Debug-Capture", "1", http.DefaultTransport, TestLogger(t))
+	h := ProxyHandler(nil, NewRequestStats(time.Now()), false /*tracingEnabled*/, false /*timingHeaders*/, mirror, primaryHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/path", strings.NewReader("payload"))
+	req.Header.Set("X-Debug-Capture", "1")
+	rec := httptest.NewRecorder()
+	h(rec, req)
+
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+	if got, want := rec.Body.String(), "primary: payload"; got != want {
+		t.Errorf("Body = %q, want: %q", got, want)
+	}
+	select {
+	case got := <-reqs:
+		want := mirroredRequest{method: http.MethodPost, path: "/path", host: "example.com", body: "payload"}
+		if got != want {
+			t.Errorf("Mirrored request = %+v, want: %+v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the mirrored request")
+	}
+}
+
+func TestRequestMirrorTrailers(t *testing.T) {
+	target, reqs := newMirrorTarget(t)
+	mirror := NewRequestMirror(target, "X-Debug-Capture", "1", http.DefaultTransport, TestLogger(t))
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Write([]byte("body"))
+		w.Header().Set("X-Checksum", r.Trailer.Get("X-Request-Checksum"))
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal("Failed to parse backend URL:", err)
+	}
+	proxy := pkghttp.NewHeaderPruningReverseProxy(backendURL.Host, pkghttp.NoHostOverride, nil)
+	server := httptest.NewServer(ProxyHandler(nil, NewRequestStats(time.Now()), false /*tracingEnabled*/, false /*timingHeaders*/, mirror, proxy))
+	defer server.Close()
+
+	// The body of the second request is too large to be mirrored, so the
+	// primary backend reads the rest of it, and its trailers, after the
+	// request was passed on.
+	for _, body := range []string{"payload", strings.Repeat("x", 2*mirrorMaxBodyBytes)} {
+		req, err := http.NewRequest(http.MethodPost, server.URL, io.NopCloser(strings.NewReader(body)))
+		if err != nil {
+			t.Fatal("Failed to create request:", err)
+		}
+		req.Header.Set("X-Debug-Capture", "1")
+		req.Trailer = http.Header{"X-Request-Checksum": []string{"abc"}}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal("Request failed:", err)
+		}
+		if _, err := io.ReadAll(resp.Body); err != nil {
+			t.Fatal("Failed to read body:", err)
+		}
+		resp.Body.Close()
+
+		if got, want := resp.Trailer.Get("X-Checksum"), "abc"; got != want {
+			t.Errorf("X-Checksum trailer with a body of %d bytes = %q, want: %q", len(body), got, want)
+		}
+	}
+	select {
+	case got := <-reqs:
+		if got.body != "payload" {
+			t.Errorf("Mirrored body = %q, want: %q", got.body, "payload")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the mirrored request")
+	}
+}
+
+func TestRequestMirrorNotMatching(t *testing.T) {
+	target, reqs := newMirrorTarget(t)
+	mirror := NewRequestMirror(target, "X-Debug-Capture", "1", http.DefaultTransport, TestLogger(t))
+
+	for _, value := range []string{"", "0"} {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com", strings.NewReader("payload"))
+		if value != "" {
+			req.Header.Set("X-Debug-Capture", value)
+		}
+		body := req.Body
+		mirror.Mirror(req)
+		if req.Body != body {
+			t.Errorf("Body of request with header %q was replaced", value)
+		}
+	}
+	select {
+	case got := <-reqs:
+		t.Errorf("Not matching request was mirrored: %+v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRequestMirrorLargeBody(t *testing.T) {
+	target, reqs := newMirrorTarget(t)
+	mirror := NewRequestMirror(target, "X-Debug-Capture", "1", http.DefaultTransport, TestLogger(t))
+	h := ProxyHandler(nil, NewRequestStats(time.Now()), false /*tracingEnabled*/, false /*timingHeaders*/, mirror, primaryHandler)
+
+	payload := strings.Repeat("a", mirrorMaxBodyBytes+1)
+	req := httptest.NewRequest(http.MethodPost, "http://example.com", strings.NewReader(payload))
+	req.Header.Set("X-Debug-Capture", "1")
+	rec := httptest.NewRecorder()
+	h(rec, req)
+
+	// The primary backend still gets all of the body.
+	if got, want := rec.Body.String(), "primary: "+payload; got != want {
+		t.Errorf("Body has length %d, want: %d", len(got), len(want))
+	}
+	select {
+	case <-reqs:
+		t.Error("Request with too large body was mirrored")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRequestMirrorFailure(t *testing.T) {
+	target, _ := newMirrorTarget(t)
+	// Nothing listens at the port of the mirror once it's closed.
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	target.Host = server.Listener.Addr().String()
+	mirror := NewRequestMirror(target, "X-Debug-Capture", "1", http.DefaultTransport, TestLogger(t))
+	h := ProxyHandler(nil, NewRequestStats(time.Now()), false /*tracingEnabled*/, false /*timingHeaders*/, mirror, primaryHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com", strings.NewReader("payload"))
+	req.Header.Set("X-Debug-Capture", "1")
+	rec := httptest.NewRecorder()
+	h(rec, req)
+
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+	if got, want := rec.Body.String(), "primary: payload"; got != want {
+		t.Errorf("Body = %q, want: %q", got, want)
+	}
+	// Wait for the mirrored request to fail, so it doesn't log after the test.
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return len(mirror.inFlight) == 0, nil
+	}); err != nil {
+		t.Fatal("Mirrored request didn't finish:", err)
+	}
+}
+
+func TestShadowMirror(t *testing.T) {
+	headers := make(chan http.Header, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Header.Set("Body", string(body))
+		r.Header.Set("Host", r.Host)
+		headers <- r.Header
+	}))
+	t.Cleanup(server.Close)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal("Failed to parse mirror URL:", err)
+	}
+
+	mirror := NewShadowMirror("ns", http.DefaultTransport, TestLogger(t))
+	var shadowRevision string
+	mirror.serviceHost = func(revision string) string {
+		shadowRevision = revision
+		return target.Host
+	}
+	mirror.percent = func() float64 { return 30 }
+	var primaryHeader http.Header
+	h := mirror.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHeader = r.Header
+		primaryHandler(w, r)
+	}))
+
+	tests := []struct {
+		name     string
+		policy   string
+		mirrored bool
+	}{{
+		name:     "mirrored",
+		policy:   (&MirrorPolicy{Revision: "shadow", Percent: 50}).String(),
+		mirrored: true,
+	}, {
+		name:   "not sampled",
+		policy: (&MirrorPolicy{Revision: "shadow", Percent: 20}).String(),
+	}, {
+		name:   "invalid policy",
+		policy: `{"percent": 100}`,
+	}, {
+		name: "no policy",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shadowRevision = ""
+			req := httptest.NewRequest(http.MethodPost, "http://example.com/path", strings.NewReader("payload"))
+			if test.policy != "" {
+				req.Header.Set(MirrorHeaderName, test.policy)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if got, want := rec.Body.String(), "primary: payload"; got != want {
+				t.Errorf("Body = %q, want: %q", got, want)
+			}
+			if got := primaryHeader.Get(MirrorHeaderName); got != "" {
+				t.Errorf("Primary got the %s header %q", MirrorHeaderName, got)
+			}
+			if !test.mirrored {
+				select {
+				case got := <-headers:
+					t.Errorf("Request was mirrored with headers %v", got)
+				case <-time.After(100 * time.Millisecond):
+				}
+				return
+			}
+			select {
+			case got := <-headers:
+				for name, want := range map[string]string{
+					"Body":                      "payload",
+					"Host":                      target.Host,
+					"Knative-Serving-Revision":  "shadow",
+					"Knative-Serving-Namespace": "ns",
+					MirroredHeaderName:          "true",
+					MirrorHeaderName:            "",
+				} {
+					if got := got.Get(name); got != want {
+						t.Errorf("Mirrored request header %s = %q, want: %q", name, got, want)
+					}
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for the mirrored request")
+			}
+			if got, want := shadowRevision, "shadow"; got != want {
+				t.Errorf("Mirrored to the Service of %q, want: %q", got, want)
+			}
+		})
+	}
+}
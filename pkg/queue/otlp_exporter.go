@@ -0,0 +1,409 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// OTLPProtocol is the protocol metrics are exported by to an OpenTelemetry
+// collector, named like by the OTEL_EXPORTER_OTLP_PROTOCOL of the SDKs.
+type OTLPProtocol string
+
+const (
+	// OTLPProtocolGRPC exports metrics by OTLP over gRPC, the default.
+	OTLPProtocolGRPC OTLPProtocol = "grpc"
+	// OTLPProtocolHTTP exports metrics by OTLP over HTTP, in protobuf.
+	OTLPProtocolHTTP OTLPProtocol = "http/protobuf"
+)
+
+const (
+	// otlpGRPCMethod is the method of the OTLP metrics service.
+	otlpGRPCMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+	// otlpHTTPPath is the path of the OTLP metrics service, appended to
+	// endpoints without a path.
+	otlpHTTPPath = "/v1/metrics"
+	// otlpGRPCPort is the port gRPC endpoints without one are dialed at.
+	otlpGRPCPort = "4317"
+	// otlpExportTimeout is the time an export may take.
+	otlpExportTimeout = 10 * time.Second
+	// otlpScopeName is the name of the instrumentation scope of the metrics.
+	otlpScopeName = "knative.dev/serving/pkg/queue"
+	// otlpCumulative is the CUMULATIVE aggregation temporality, as the
+	// Prometheus metrics are.
+	otlpCumulative = 2
+)
+
+// ParseOTLPProtocol parses the OTLP protocol, defaulting to gRPC.
+func ParseOTLPProtocol(s string) (OTLPProtocol, error) {
+	switch p := OTLPProtocol(s); p {
+	case "":
+		return OTLPProtocolGRPC, nil
+	case OTLPProtocolGRPC, OTLPProtocolHTTP:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown OTLP protocol %q", s)
+	}
+}
+
+// ValidateOTLPEndpoint returns an error if endpoint isn't the http or https
+// URL of a collector. With gRPC, http means the connection isn't secured.
+func ValidateOTLPEndpoint(endpoint string) error {
+	_, err := parseOTLPEndpoint(endpoint)
+	return err
+}
+
+func parseOTLPEndpoint(endpoint string) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("OTLP endpoint %q is not an http or https URL", endpoint)
+	}
+	return u, nil
+}
+
+// OTLPResource returns the attributes of the OTLP resource of the metrics of
+// a queue-proxy, by the OpenTelemetry semantic conventions where they exist.
+// The service is omitted if empty.
+func OTLPResource(namespace, service, configuration, revision, pod string) map[string]string {
+	attrs := map[string]string{
+		"service.name":               "queue-proxy",
+		"k8s.namespace.name":         namespace,
+		"k8s.pod.name":               pod,
+		"knative.configuration.name": configuration,
+		"knative.revision.name":      revision,
+	}
+	if service != "" {
+		attrs["knative.service.name"] = service
+	}
+	return attrs
+}
+
+// OTLPExporter periodically pushes the metrics of a PrometheusStatsReporter to
+// an OpenTelemetry collector, for clusters not scraping every queue-proxy.
+type OTLPExporter struct {
+	reporter *PrometheusStatsReporter
+	// resource is the encoded OTLP resource.
+	resource []byte
+	send     func(ctx context.Context, body []byte) error
+	close    func() error
+	logger   *zap.SugaredLogger
+}
+
+// NewOTLPExporter creates an exporter of the metrics of the reporter to the
+// collector at endpoint, by protocol, with the given resource attributes. The
+// reporter's own labels are left to the resource.
+func (r *PrometheusStatsReporter) NewOTLPExporter(endpoint string, protocol OTLPProtocol, resource map[string]string,
+	logger *zap.SugaredLogger) (*OTLPExporter, error) {
+	send, closeFn, err := newOTLPSender(endpoint, protocol, otlpGRPCMethod, otlpHTTPPath)
+	if err != nil {
+		return nil, err
+	}
+	return &OTLPExporter{
+		reporter: r,
+		resource: appendAttributes(nil, 1, resource),
+		send:     send,
+		close:    closeFn,
+		logger:   logger,
+	}, nil
+}
+
+// newOTLPSender returns the function sending the encoded requests of an OTLP
+// service to the collector at endpoint, by protocol, with the given gRPC
+// method or HTTP path, and the function closing its connection.
+func newOTLPSender(endpoint string, protocol OTLPProtocol, grpcMethod, httpPath string) (
+	func(ctx context.Context, body []byte) error, func() error, error) {
+	u, err := parseOTLPEndpoint(endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch protocol {
+	case OTLPProtocolHTTP:
+		if u.Path == "" || u.Path == "/" {
+			u.Path = httpPath
+		}
+		return otlpHTTPSender(u.String()), func() error { return nil }, nil
+	case OTLPProtocolGRPC:
+		opt := grpc.WithInsecure()
+		if u.Scheme == "https" {
+			opt = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12}))
+		}
+		target := u.Host
+		if u.Port() == "" {
+			target = net.JoinHostPort(u.Hostname(), otlpGRPCPort)
+		}
+		// The connection is made lazily and re-established as needed.
+		conn, err := grpc.Dial(target, opt)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to dial OTLP endpoint: %w", err)
+		}
+		send := func(ctx context.Context, body []byte) error {
+			var resp []byte
+			return conn.Invoke(ctx, grpcMethod, body, &resp, grpc.ForceCodec(rawCodec{}))
+		}
+		return send, conn.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown OTLP protocol %q", protocol)
+	}
+}
+
+// Run exports the metrics every interval until ctx is done, and a last time
+// then, so the final counts aren't lost as the pod goes away.
+func (e *OTLPExporter) Run(ctx context.Context, interval time.Duration) {
+	defer e.close()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.exportAndLog(context.Background())
+		case <-ctx.Done():
+			e.exportAndLog(context.Background())
+			return
+		}
+	}
+}
+
+func (e *OTLPExporter) exportAndLog(ctx context.Context) {
+	if err := e.Export(ctx); err != nil {
+		e.logger.Errorw("Failed to export metrics by OTLP", zap.Error(err))
+	}
+}
+
+// Export exports the current metrics once.
+func (e *OTLPExporter) Export(ctx context.Context) error {
+	families, err := e.reporter.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, otlpExportTimeout)
+	defer cancel()
+	return e.send(ctx, e.encode(families, time.Now()))
+}
+
+// encode encodes the families as an ExportMetricsServiceRequest.
+func (e *OTLPExporter) encode(families []*dto.MetricFamily, now time.Time) []byte {
+	start := uint64(e.reporter.startTime.UnixNano())
+	ts := uint64(now.UnixNano())
+
+	// ScopeMetrics.scope, with InstrumentationScope.name
+	scopeMetrics := appendMessage(nil, 1, appendString(nil, 1, otlpScopeName))
+	for _, family := range families {
+		if metric := e.encodeMetric(family, start, ts); metric != nil {
+			// ScopeMetrics.metrics
+			scopeMetrics = appendMessage(scopeMetrics, 2, metric)
+		}
+	}
+
+	// ResourceMetrics.resource and .scope_metrics
+	resourceMetrics := appendMessage(nil, 1, e.resource)
+	resourceMetrics = appendMessage(resourceMetrics, 2, scopeMetrics)
+	// ExportMetricsServiceRequest.resource_metrics
+	return appendMessage(nil, 1, resourceMetrics)
+}
+
+// encodeMetric encodes the metrics of the family recorded by the reporter as
+// a Metric, or returns nil if there are none.
+func (e *OTLPExporter) encodeMetric(family *dto.MetricFamily, start, ts uint64) []byte {
+	var points []byte
+	for _, m := range family.Metric {
+		labels := labelValues(m)
+		if !e.reporter.owns(labels) {
+			continue
+		}
+		for k := range e.reporter.labels {
+			delete(labels, k)
+		}
+
+		switch family.GetType() {
+		case dto.MetricType_GAUGE:
+			// Gauge.data_points
+			points = appendMessage(points, 1, numberDataPoint(labels, 0, ts, m.GetGauge().GetValue()))
+		case dto.MetricType_COUNTER:
+			// Sum.data_points
+			points = appendMessage(points, 1, numberDataPoint(labels, start, ts, m.GetCounter().GetValue()))
+		case dto.MetricType_HISTOGRAM:
+			// Histogram.data_points
+			points = appendMessage(points, 1, histogramDataPoint(labels, start, ts, m.GetHistogram()))
+		}
+	}
+	if points == nil {
+		return nil
+	}
+
+	// Metric.name and .description
+	metric := appendString(nil, 1, family.GetName())
+	metric = appendString(metric, 2, family.GetHelp())
+	switch family.GetType() {
+	case dto.MetricType_GAUGE:
+		// Metric.gauge
+		metric = appendMessage(metric, 5, points)
+	case dto.MetricType_COUNTER:
+		// Sum.aggregation_temporality and .is_monotonic
+		points = appendVarint(points, 2, otlpCumulative)
+		points = appendVarint(points, 3, 1)
+		// Metric.sum
+		metric = appendMessage(metric, 7, points)
+	case dto.MetricType_HISTOGRAM:
+		// Histogram.aggregation_temporality
+		points = appendVarint(points, 2, otlpCumulative)
+		// Metric.histogram
+		metric = appendMessage(metric, 9, points)
+	}
+	return metric
+}
+
+// numberDataPoint encodes a NumberDataPoint. Gauges have no start time.
+func numberDataPoint(labels map[string]string, start, ts uint64, value float64) []byte {
+	p := appendAttributes(nil, 7, labels)
+	if start != 0 {
+		p = appendFixed64(p, 2, start)
+	}
+	p = appendFixed64(p, 3, ts)
+	// NumberDataPoint.as_double
+	return appendFixed64(p, 4, math.Float64bits(value))
+}
+
+// histogramDataPoint encodes a HistogramDataPoint, turning the cumulative
+// Prometheus bucket counts into the per bucket counts of OTLP, with the
+// overflow bucket last.
+func histogramDataPoint(labels map[string]string, start, ts uint64, h *dto.Histogram) []byte {
+	var counts, bounds []byte
+	var previous uint64
+	for _, b := range h.Bucket {
+		if math.IsInf(b.GetUpperBound(), +1) {
+			continue
+		}
+		counts = protowire.AppendFixed64(counts, b.GetCumulativeCount()-previous)
+		bounds = protowire.AppendFixed64(bounds, math.Float64bits(b.GetUpperBound()))
+		previous = b.GetCumulativeCount()
+	}
+	counts = protowire.AppendFixed64(counts, h.GetSampleCount()-previous)
+
+	p := appendAttributes(nil, 9, labels)
+	p = appendFixed64(p, 2, start)
+	p = appendFixed64(p, 3, ts)
+	// HistogramDataPoint.count, .sum, .bucket_counts and .explicit_bounds
+	p = appendFixed64(p, 4, h.GetSampleCount())
+	p = appendFixed64(p, 5, math.Float64bits(h.GetSampleSum()))
+	p = appendMessage(p, 6, counts)
+	return appendMessage(p, 7, bounds)
+}
+
+// appendAttributes appends the attributes as KeyValues with string values as
+// field num, sorted by key for a stable encoding.
+func appendAttributes(b []byte, num protowire.Number, attrs map[string]string) []byte {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		// AnyValue.string_value
+		b = appendKeyValue(b, num, k, appendString(nil, 1, attrs[k]))
+	}
+	return b
+}
+
+// appendKeyValue appends the KeyValue of the key and the encoded AnyValue as
+// field num.
+func appendKeyValue(b []byte, num protowire.Number, key string, value []byte) []byte {
+	// KeyValue.key and .value
+	kv := appendString(nil, 1, key)
+	kv = appendMessage(kv, 2, value)
+	return appendMessage(b, num, kv)
+}
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// otlpHTTPSender posts the requests to url.
+func otlpHTTPSender(url string) func(ctx context.Context, body []byte) error {
+	client := &http.Client{}
+	return func(ctx context.Context, body []byte) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(ioutil.Discard, resp.Body)
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("OTLP endpoint responded with %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+// rawCodec passes the already encoded requests through to gRPC, so the OTLP
+// messages don't need generated code. It's named "proto" as that's what the
+// messages are.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return v.([]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*(v.(*[]byte)) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
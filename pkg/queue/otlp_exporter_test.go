@@ -0,0 +1,248 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+	network "knative.dev/networking/pkg"
+	pkglogging "knative.dev/pkg/logging/testing"
+)
+
+const otlpPod = "otlp-pod"
+
+// otlpFields decodes the length delimited fields of a message by number.
+func otlpFields(t *testing.T, b []byte) map[protowire.Number][][]byte {
+	t.Helper()
+	fields := map[protowire.Number][][]byte{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatal("Failed to decode tag:", protowire.ParseError(n))
+		}
+		b = b[n:]
+		if typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				t.Fatal("Failed to decode field:", protowire.ParseError(n))
+			}
+			fields[num] = append(fields[num], v)
+			b = b[n:]
+			continue
+		}
+		if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+			t.Fatal("Failed to decode field:", protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+	return fields
+}
+
+// otlpAttributes decodes the string KeyValues in field num of a message.
+func otlpAttributes(t *testing.T, fields map[protowire.Number][][]byte, num protowire.Number) map[string]string {
+	attrs := map[string]string{}
+	for _, kv := range fields[num] {
+		kvFields := otlpFields(t, kv)
+		attrs[string(kvFields[1][0])] = string(otlpFields(t, kvFields[2][0])[1][0])
+	}
+	return attrs
+}
+
+// otlpMetrics decodes an ExportMetricsServiceRequest into its resource
+// attributes and its metrics by name and data type field.
+func otlpMetrics(t *testing.T, body []byte) (map[string]string, map[string]protowire.Number, map[string][]byte) {
+	t.Helper()
+	resourceMetrics := otlpFields(t, otlpFields(t, body)[1][0])
+	resource := otlpAttributes(t, otlpFields(t, resourceMetrics[1][0]), 1)
+	types := map[string]protowire.Number{}
+	data := map[string][]byte{}
+	for _, metric := range otlpFields(t, resourceMetrics[2][0])[2] {
+		fields := otlpFields(t, metric)
+		name := string(fields[1][0])
+		for _, num := range []protowire.Number{5, 7, 9} {
+			if v, ok := fields[num]; ok {
+				types[name] = num
+				data[name] = v[0]
+			}
+		}
+	}
+	return resource, types, data
+}
+
+func newOTLPTestReporter(t *testing.T) *PrometheusStatsReporter {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, otlpPod, time.Second, 0 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	reporter.Report(network.RequestStatsReport{RequestCount: 3})
+	h := reporter.RequestMethodHandler(reporter.ResponseTimingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	return reporter
+}
+
+func TestOTLPExporterHTTP(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	reporter := newOTLPTestReporter(t)
+	resource := OTLPResource(namespace, "svc", config, revision, otlpPod)
+	e, err := reporter.NewOTLPExporter(server.URL, OTLPProtocolHTTP, resource, pkglogging.TestLogger(t))
+	if err != nil {
+		t.Fatal("NewOTLPExporter() =", err)
+	}
+	if err := e.Export(context.Background()); err != nil {
+		t.Fatal("Export() =", err)
+	}
+
+	gotResource, types, data := otlpMetrics(t, <-bodies)
+	want := map[string]string{
+		"service.name":               "queue-proxy",
+		"k8s.namespace.name":         namespace,
+		"k8s.pod.name":               otlpPod,
+		"knative.configuration.name": config,
+		"knative.revision.name":      revision,
+		"knative.service.name":       "svc",
+	}
+	if !cmp.Equal(gotResource, want) {
+		t.Error("Resource differs (-want, +got):", cmp.Diff(want, gotResource))
+	}
+	for name, want := range map[string]protowire.Number{
+		"queue_requests_per_second":                 5,
+		"queue_requests_by_method_total":            7,
+		"queue_response_time_to_first_byte_seconds": 9,
+	} {
+		if got := types[name]; got != want {
+			t.Errorf("Data field of %s = %d, want %d", name, got, want)
+		}
+	}
+
+	// The reporter's labels are left to the resource.
+	point := otlpFields(t, otlpFields(t, data["queue_requests_by_method_total"])[1][0])
+	if got, want := otlpAttributes(t, point, 7), map[string]string{
+		requestMethodLabel:     http.MethodGet,
+		responseCodeClassLabel: "2xx",
+	}; !cmp.Equal(got, want) {
+		t.Error("Data point attributes differ (-want, +got):", cmp.Diff(want, got))
+	}
+}
+
+func TestOTLPExporterHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	e, err := newOTLPTestReporter(t).NewOTLPExporter(server.URL, OTLPProtocolHTTP, nil, pkglogging.TestLogger(t))
+	if err != nil {
+		t.Fatal("NewOTLPExporter() =", err)
+	}
+	if err := e.Export(context.Background()); err == nil {
+		t.Error("Export() = nil, want an error")
+	}
+}
+
+func TestOTLPExporterGRPC(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	bodies := make(chan []byte, 1)
+	server := grpc.NewServer(grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+			if method, _ := grpc.MethodFromServerStream(stream); method != otlpGRPCMethod {
+				t.Errorf("Method = %q, want %q", method, otlpGRPCMethod)
+			}
+			var body []byte
+			if err := stream.RecvMsg(&body); err != nil {
+				return err
+			}
+			bodies <- body
+			return stream.SendMsg([]byte{})
+		}))
+	go server.Serve(l)
+	defer server.Stop()
+
+	reporter := newOTLPTestReporter(t)
+	e, err := reporter.NewOTLPExporter("http://"+l.Addr().String(), OTLPProtocolGRPC,
+		OTLPResource(namespace, "", config, revision, otlpPod), pkglogging.TestLogger(t))
+	if err != nil {
+		t.Fatal("NewOTLPExporter() =", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx, time.Hour)
+		close(done)
+	}()
+	// Metrics are exported a last time once done.
+	cancel()
+	<-done
+
+	resource, types, _ := otlpMetrics(t, <-bodies)
+	if got, want := resource["k8s.pod.name"], otlpPod; got != want {
+		t.Errorf("k8s.pod.name = %q, want %q", got, want)
+	}
+	if _, ok := resource["knative.service.name"]; ok {
+		t.Error("knative.service.name is set, want it omitted without a service")
+	}
+	if _, ok := types["queue_requests_per_second"]; !ok {
+		t.Error("queue_requests_per_second wasn't exported")
+	}
+}
+
+func TestOTLPConfigValidation(t *testing.T) {
+	for s, valid := range map[string]bool{
+		"":              true,
+		"grpc":          true,
+		"http/protobuf": true,
+		"http/json":     false,
+	} {
+		if _, err := ParseOTLPProtocol(s); (err == nil) != valid {
+			t.Errorf("ParseOTLPProtocol(%q) = %v, want valid: %v", s, err, valid)
+		}
+	}
+	for endpoint, valid := range map[string]bool{
+		"http://collector:4317":  true,
+		"https://collector:4318": true,
+		"collector:4317":         false,
+		"grpc://collector":       false,
+		"http://":                false,
+	} {
+		if err := ValidateOTLPEndpoint(endpoint); (err == nil) != valid {
+			t.Errorf("ValidateOTLPEndpoint(%q) = %v, want valid: %v", endpoint, err, valid)
+		}
+	}
+}
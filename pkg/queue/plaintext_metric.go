@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"net/http"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	network "knative.dev/networking/pkg"
+	pkgmetrics "knative.dev/pkg/metrics"
+	"knative.dev/serving/pkg/activator"
+	"knative.dev/serving/pkg/metrics"
+)
+
+var plaintextRequestCountM = stats.Int64(
+	"plaintext_request_count",
+	"The number of requests that reached queue-proxy in plain text although internal encryption is enabled",
+	stats.UnitDimensionless)
+
+type plaintextMetricsHandler struct {
+	next     http.Handler
+	statsCtx context.Context
+}
+
+// NewPlaintextMetricsHandler creates an http.Handler counting the requests
+// that fell back to plain text on their hop to the queue-proxy, for when
+// internal encryption is enabled.
+func NewPlaintextMetricsHandler(next http.Handler, ns, service, config, rev, pod string) (http.Handler, error) {
+	if err := pkgmetrics.RegisterResourceView(&view.View{
+		Description: "The number of requests that reached queue-proxy in plain text although internal encryption is enabled",
+		Measure:     plaintextRequestCountM,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, metrics.HopKey},
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx, err := metrics.PodRevisionContext(pod, "queue-proxy", ns, service, config, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	return &plaintextMetricsHandler{
+		next:     next,
+		statsCtx: ctx,
+	}, nil
+}
+
+func (h *plaintextMetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.TLS == nil && !network.IsProbe(r) {
+		hop := metrics.HopIngressQueue
+		if r.Header.Get(network.ProxyHeaderName) == activator.Name {
+			hop = metrics.HopActivatorQueue
+		}
+		ctx, _ := tag.New(h.statsCtx, tag.Upsert(metrics.HopKey, hop))
+		pkgmetrics.Record(ctx, plaintextRequestCountM.M(1))
+	}
+	h.next.ServeHTTP(w, r)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opencensus.io/resource"
+	network "knative.dev/networking/pkg"
+	"knative.dev/pkg/metrics/metricstest"
+	_ "knative.dev/pkg/metrics/testing"
+	"knative.dev/serving/pkg/activator"
+	"knative.dev/serving/pkg/metrics"
+)
+
+func TestPlaintextMetricsHandler(t *testing.T) {
+	t.Cleanup(func() { metricstest.Unregister(plaintextRequestCountM.Name()) })
+	handler, err := NewPlaintextMetricsHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		"ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("NewPlaintextMetricsHandler() =", err)
+	}
+
+	serve := func(modify func(*http.Request)) {
+		req := httptest.NewRequest(http.MethodGet, targetURI, nil)
+		modify(req)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	serve(func(*http.Request) {})
+	serve(func(r *http.Request) { r.Header.Set(network.ProxyHeaderName, activator.Name) })
+	serve(func(r *http.Request) { r.Header.Set(network.ProxyHeaderName, activator.Name) })
+	// Neither TLS requests nor probes are counted.
+	serve(func(r *http.Request) { r.TLS = &tls.ConnectionState{} })
+	serve(func(r *http.Request) { r.Header.Set(network.ProbeHeaderName, "probe") })
+
+	tags := func(hop string) map[string]string {
+		return map[string]string{
+			metrics.LabelPodName:       "pod",
+			metrics.LabelContainerName: "queue-proxy",
+			metrics.LabelHop:           hop,
+		}
+	}
+	want := metricstest.IntMetric("plaintext_request_count", 1, tags(metrics.HopIngressQueue)).WithResource(&resource.Resource{
+		Type: "knative_revision",
+		Labels: map[string]string{
+			metrics.LabelNamespaceName:     "ns",
+			metrics.LabelRevisionName:      "rev",
+			metrics.LabelServiceName:       "svc",
+			metrics.LabelConfigurationName: "cfg",
+		},
+	})
+	want.Values = append(want.Values,
+		metricstest.IntMetric("plaintext_request_count", 2, tags(metrics.HopActivatorQueue)).Values...)
+	metricstest.AssertMetric(t, want)
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// ResumeFailedReason is the reason of the event recorded once the user
+	// container couldn't be resumed.
+	ResumeFailedReason = "ResumeFailed"
+
+	// serviceAccountDir is where the credentials of the service account of
+	// the pod are mounted.
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// PodEvents records Kubernetes events about the pod of the queue-proxy, as
+// the service account of the pod, which has to be allowed to create events.
+// The API server is talked to directly, as client-go is too heavy a
+// dependency for the queue-proxy.
+type PodEvents struct {
+	apiServer string
+	tokenPath string
+	tls       ConcurrencyEndpointTLS
+
+	namespace, pod string
+	uid            types.UID
+}
+
+// NewInClusterPodEvents creates PodEvents recording events about the pod
+// through the API server of the cluster.
+func NewInClusterPodEvents(namespace, pod string, uid types.UID) (*PodEvents, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+	}
+	return &PodEvents{
+		apiServer: "https://" + net.JoinHostPort(host, port),
+		tokenPath: serviceAccountDir + "/token",
+		tls:       ConcurrencyEndpointTLS{CAPath: serviceAccountDir + "/ca.crt"},
+		namespace: namespace,
+		pod:       pod,
+		uid:       uid,
+	}, nil
+}
+
+// Warning records a warning event about the pod.
+func (e *PodEvents) Warning(ctx context.Context, reason, message string) error {
+	now := metav1.NewTime(time.Now())
+	body, err := json.Marshal(&corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: e.pod + ".",
+			Namespace:    e.namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Namespace:  e.namespace,
+			Name:       e.pod,
+			UID:        e.uid,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           corev1.EventTypeWarning,
+		Source:         corev1.EventSource{Component: "queue-proxy"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	})
+	if err != nil {
+		return err
+	}
+
+	url := e.apiServer + "/api/v1/namespaces/" + e.namespace + "/events"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create event request: %w", err)
+	}
+	req.Header.Set(contentTypeHeader, "application/json")
+	token, err := os.ReadFile(e.tokenPath)
+	if err != nil {
+		return fmt.Errorf("failed to read token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	cfg, err := e.tls.config()
+	if err != nil {
+		return err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	defer transport.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return fmt.Errorf("event request to %s failed: %w", url, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("event request to %s failed with status %d", url, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPodEventsWarning(t *testing.T) {
+	var got corev1.Event
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/namespaces/ns/events" {
+			t.Errorf("Got %s %s, want POST /api/v1/namespaces/ns/events", r.Method, r.URL.Path)
+		}
+		if got, want := r.Header.Get("Authorization"), "Bearer token"; got != want {
+			t.Errorf("Authorization = %q, want: %q", got, want)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error("Failed to decode the event:", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	tokenPath, caPath := filepath.Join(dir, "token"), filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(tokenPath, []byte("token\n"), 0600); err != nil {
+		t.Fatal("Failed to write the token:", err)
+	}
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caPath, ca, 0600); err != nil {
+		t.Fatal("Failed to write the CA:", err)
+	}
+
+	e := &PodEvents{
+		apiServer: server.URL,
+		tokenPath: tokenPath,
+		tls:       ConcurrencyEndpointTLS{CAPath: caPath},
+		namespace: "ns",
+		pod:       "pod",
+		uid:       "uid",
+	}
+	if err := e.Warning(context.Background(), ResumeFailedReason, "frozen"); err != nil {
+		t.Fatal("Warning() =", err)
+	}
+
+	if got.Namespace != "ns" || got.GenerateName != "pod." {
+		t.Errorf("Event is %s/%s*, want ns/pod.*", got.Namespace, got.GenerateName)
+	}
+	if want := (corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: "ns", Name: "pod", UID: "uid"}); got.InvolvedObject != want {
+		t.Errorf("InvolvedObject = %+v, want: %+v", got.InvolvedObject, want)
+	}
+	if got.Type != corev1.EventTypeWarning || got.Reason != ResumeFailedReason || got.Message != "frozen" {
+		t.Errorf("Got a %s event %s: %s, want a Warning event %s: frozen", got.Type, got.Reason, got.Message, ResumeFailedReason)
+	}
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	if err := e.Warning(context.Background(), ResumeFailedReason, "frozen"); err == nil {
+		t.Error("Warning() = nil, want an error when forbidden")
+	}
+}
+
+func TestNewInClusterPodEvents(t *testing.T) {
+	os.Setenv("KUBERNETES_SERVICE_HOST", "fd00::1")
+	defer os.Unsetenv("KUBERNETES_SERVICE_HOST")
+	os.Setenv("KUBERNETES_SERVICE_PORT", "443")
+	defer os.Unsetenv("KUBERNETES_SERVICE_PORT")
+	e, err := NewInClusterPodEvents("ns", "pod", "uid")
+	if err != nil {
+		t.Fatal("NewInClusterPodEvents() =", err)
+	}
+	if got, want := e.apiServer, "https://[fd00::1]:443"; got != want {
+		t.Errorf("API server = %s, want: %s", got, want)
+	}
+
+	os.Unsetenv("KUBERNETES_SERVICE_HOST")
+	if _, err := NewInClusterPodEvents("ns", "pod", "uid"); err == nil {
+		t.Error("NewInClusterPodEvents() = nil, want an error outside of a cluster")
+	}
+}
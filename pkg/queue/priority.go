@@ -0,0 +1,210 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// MaxRequestPriority is the highest priority of a request. Priorities range
+// from 0 to MaxRequestPriority, higher ones are admitted first.
+const MaxRequestPriority = 9
+
+// RequestPriorities is the number of priority levels of requests.
+const RequestPriorities = MaxRequestPriority + 1
+
+type priorityKey struct{}
+
+// WithRequestPriority attaches the priority a Breaker admits the request by
+// to the context.
+func WithRequestPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// requestPriority returns the priority attached to the context, 0 if none is.
+func requestPriority(ctx context.Context) int {
+	p, _ := ctx.Value(priorityKey{}).(int)
+	return p
+}
+
+// Prioritizer derives the priority of requests from a header carrying it or
+// from rules matching their path.
+type Prioritizer struct {
+	rules *requestRules
+}
+
+// NewPrioritizer creates a Prioritizer reading the priority from header, if
+// set, and otherwise from rules, the JSON serialized priorities by path
+// prefix, e.g. {"/checkout": 9, "/batch": 0}. Requests matching neither get
+// priority 0.
+func NewPrioritizer(header, rules string) (*Prioritizer, error) {
+	r, err := newRequestRules(header, rules, 0 /*min*/, MaxRequestPriority)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse priority rules: %w", err)
+	}
+	return &Prioritizer{rules: r}, nil
+}
+
+// Priority returns the priority of the request. Header values outside of
+// [0, MaxRequestPriority] are clamped, invalid ones are ignored.
+func (p *Prioritizer) Priority(r *http.Request) int {
+	return p.rules.value(r)
+}
+
+// Handler attaches the priority of each request to its context.
+func (p *Prioritizer) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithRequestPriority(r.Context(), p.Priority(r))))
+	})
+}
+
+// priorityWaiter is a request waiting for capacity in a priorityGate.
+type priorityWaiter struct {
+	priority int
+	weight   uint64
+	// passed counts the requests of higher priority admitted ahead of it.
+	passed int
+	ready  chan struct{}
+	// acquired is the number of slots the waiter was admitted with, 0 as
+	// long as it's waiting.
+	acquired uint64
+}
+
+// priorityGate hands out the capacity of a semaphore to the waiting requests
+// by their priority, the earlier ones first within a priority. A request
+// that has been passed by starvationLimit requests of higher priority is
+// admitted next, so low priorities still make progress under sustained load.
+type priorityGate struct {
+	sem             *semaphore
+	starvationLimit int
+
+	mu sync.Mutex
+	// waiters are kept in the order of their arrival.
+	waiters []*priorityWaiter
+	pending []int
+}
+
+func newPriorityGate(sem *semaphore, priorities, starvationLimit int) *priorityGate {
+	return &priorityGate{
+		sem:             sem,
+		starvationLimit: starvationLimit,
+		pending:         make([]int, priorities),
+	}
+}
+
+// acquire acquires capacity from the semaphore for a request of the given
+// priority and weight, waiting behind the requests of higher priority, and
+// returns the number of slots acquired.
+func (g *priorityGate) acquire(ctx context.Context, priority int, weight uint64) (uint64, error) {
+	if priority < 0 {
+		priority = 0
+	} else if priority >= len(g.pending) {
+		priority = len(g.pending) - 1
+	}
+
+	g.mu.Lock()
+	// Only take capacity right away if nobody's waiting for it already.
+	if len(g.waiters) == 0 {
+		if acquired := g.sem.tryAcquireN(weight); acquired > 0 {
+			g.mu.Unlock()
+			return acquired, nil
+		}
+	}
+	w := &priorityWaiter{priority: priority, weight: weight, ready: make(chan struct{})}
+	g.waiters = append(g.waiters, w)
+	g.pending[priority]++
+	g.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return w.acquired, nil
+	case <-ctx.Done():
+	}
+
+	g.mu.Lock()
+	if w.acquired > 0 {
+		// Raced with being admitted, hand the capacity on.
+		g.mu.Unlock()
+		g.release(w.acquired)
+		return 0, ctx.Err()
+	}
+	for i, o := range g.waiters {
+		if o == w {
+			g.waiters = append(g.waiters[:i], g.waiters[i+1:]...)
+			break
+		}
+	}
+	g.pending[priority]--
+	g.mu.Unlock()
+	// The waiter might have held back lighter ones behind it.
+	g.dispatch()
+	return 0, ctx.Err()
+}
+
+// release releases n slots and admits the next waiting requests.
+func (g *priorityGate) release(n uint64) {
+	g.sem.releaseN(n)
+	g.dispatch()
+}
+
+// dispatch admits waiting requests for as long as there's capacity. A heavy
+// request next in line holds back the ones behind it until there's enough
+// capacity for it, so it isn't starved by lighter ones.
+func (g *priorityGate) dispatch() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for len(g.waiters) > 0 {
+		i := g.next()
+		w := g.waiters[i]
+		if w.acquired = g.sem.tryAcquireN(w.weight); w.acquired == 0 {
+			return
+		}
+		for _, o := range g.waiters[:i] {
+			if o.priority < w.priority {
+				o.passed++
+			}
+		}
+		g.waiters = append(g.waiters[:i], g.waiters[i+1:]...)
+		g.pending[w.priority]--
+		close(w.ready)
+	}
+}
+
+// next returns the index of the waiter to admit next: the earliest starved
+// one if any, otherwise the earliest one of the highest priority.
+func (g *priorityGate) next() int {
+	best := 0
+	for i, w := range g.waiters {
+		if g.starvationLimit > 0 && w.passed >= g.starvationLimit {
+			return i
+		}
+		if w.priority > g.waiters[best].priority {
+			best = i
+		}
+	}
+	return best
+}
+
+// pendingByPriority returns the number of waiting requests per priority.
+func (g *priorityGate) pendingByPriority() []int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]int(nil), g.pending...)
+}
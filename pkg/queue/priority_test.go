@@ -0,0 +1,224 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestPrioritizer(t *testing.T) {
+	p, err := NewPrioritizer("X-Priority", `{"/checkout": 9, "/checkout/batch": 1, "/api": 5}`)
+	if err != nil {
+		t.Fatal("NewPrioritizer() =", err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		want   int
+	}{{
+		name: "no match",
+		path: "/",
+	}, {
+		name: "prefix",
+		path: "/api/v1",
+		want: 5,
+	}, {
+		name: "longest prefix",
+		path: "/checkout/batch/1",
+		want: 1,
+	}, {
+		name:   "header over rules",
+		path:   "/checkout",
+		header: "2",
+		want:   2,
+	}, {
+		name:   "header clamped",
+		path:   "/",
+		header: "42",
+		want:   MaxRequestPriority,
+	}, {
+		name:   "negative header clamped",
+		path:   "/api",
+		header: "-1",
+	}, {
+		name:   "invalid header",
+		path:   "/api",
+		header: "high",
+		want:   5,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+test.path, nil)
+			if test.header != "" {
+				req.Header.Set("X-Priority", test.header)
+			}
+			var got int
+			p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = requestPriority(r.Context())
+			})).ServeHTTP(httptest.NewRecorder(), req)
+			if got != test.want {
+				t.Errorf("Priority = %d, want: %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewPrioritizerInvalid(t *testing.T) {
+	if _, err := NewPrioritizer("", "/checkout=9"); err == nil {
+		t.Error("Invalid rules succeeded, want error")
+	}
+	if _, err := NewPrioritizer("", `{"/checkout": 10}`); err == nil {
+		t.Error("Priority out of bounds succeeded, want error")
+	}
+}
+
+// admissions queues requests with priorities in a breaker and records the
+// order they're admitted in.
+type admissions struct {
+	breaker *Breaker
+	wg      sync.WaitGroup
+
+	mu    sync.Mutex
+	order []int
+}
+
+// request queues a request of the given priority and waits until it's
+// waiting for capacity.
+func (a *admissions) request(t *testing.T, ctx context.Context, priority int) <-chan error {
+	t.Helper()
+	want := a.breaker.PendingRequests() + 1
+	errCh := make(chan error, 1)
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		errCh <- a.breaker.Maybe(WithRequestPriority(ctx, priority), func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.order = append(a.order, priority)
+		})
+	}()
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return a.breaker.PendingRequests() == want, nil
+	}); err != nil {
+		t.Fatalf("PendingRequests() = %d, want: %d", a.breaker.PendingRequests(), want)
+	}
+	return errCh
+}
+
+// block occupies the capacity of the breaker until the returned function is
+// called.
+func (a *admissions) block(t *testing.T) func() {
+	t.Helper()
+	release := make(chan struct{})
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		a.breaker.Maybe(context.Background(), func() { <-release })
+	}()
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return a.breaker.InFlight() == 1 && a.breaker.PendingRequests() == 0, nil
+	}); err != nil {
+		t.Fatal("Blocking request didn't get capacity")
+	}
+	return func() { close(release) }
+}
+
+func (a *admissions) assertOrder(t *testing.T, want ...int) {
+	t.Helper()
+	a.wg.Wait()
+	if !cmp.Equal(a.order, want) {
+		t.Error("Admission order mismatch (-want,+got):", cmp.Diff(want, a.order))
+	}
+}
+
+func TestBreakerPriorities(t *testing.T) {
+	a := &admissions{breaker: NewBreaker(BreakerParams{
+		QueueDepth: 10, MaxConcurrency: 1, InitialCapacity: 1, Priorities: RequestPriorities,
+	})}
+	release := a.block(t)
+	a.request(t, context.Background(), 1)
+	a.request(t, context.Background(), 5)
+	a.request(t, context.Background(), 3)
+	a.request(t, context.Background(), 5)
+	if got, want := a.breaker.PendingRequestsByPriority(), []int{0, 1, 0, 1, 0, 2, 0, 0, 0, 0}; !cmp.Equal(got, want) {
+		t.Error("PendingRequestsByPriority() mismatch (-want,+got):", cmp.Diff(want, got))
+	}
+
+	release()
+	a.assertOrder(t, 5, 5, 3, 1)
+	if got, want := a.breaker.PendingRequestsByPriority(), make([]int, RequestPriorities); !cmp.Equal(got, want) {
+		t.Error("PendingRequestsByPriority() mismatch (-want,+got):", cmp.Diff(want, got))
+	}
+}
+
+func TestBreakerPrioritiesStarvation(t *testing.T) {
+	a := &admissions{breaker: NewBreaker(BreakerParams{
+		QueueDepth: 10, MaxConcurrency: 1, InitialCapacity: 1, Priorities: RequestPriorities, StarvationLimit: 2,
+	})}
+	release := a.block(t)
+	a.request(t, context.Background(), 0)
+	for i := 0; i < 4; i++ {
+		a.request(t, context.Background(), 9)
+	}
+
+	// The low priority request is passed by two requests at most.
+	release()
+	a.assertOrder(t, 9, 9, 0, 9, 9)
+}
+
+func TestBreakerPrioritiesCancel(t *testing.T) {
+	a := &admissions{breaker: NewBreaker(BreakerParams{
+		QueueDepth: 10, MaxConcurrency: 1, InitialCapacity: 1, Priorities: RequestPriorities,
+	})}
+	release := a.block(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := a.request(t, ctx, 9)
+	a.request(t, context.Background(), 1)
+
+	cancel()
+	if err := <-canceled; err != context.Canceled {
+		t.Errorf("Maybe() = %v, want: %v", err, context.Canceled)
+	}
+	if got, want := a.breaker.PendingRequestsByPriority()[9], 0; got != want {
+		t.Errorf("Pending requests of priority 9 = %d, want: %d", got, want)
+	}
+
+	release()
+	a.assertOrder(t, 1)
+}
+
+func TestBreakerPrioritiesUpdateConcurrency(t *testing.T) {
+	a := &admissions{breaker: NewBreaker(BreakerParams{
+		QueueDepth: 10, MaxConcurrency: 1, InitialCapacity: 0, Priorities: RequestPriorities,
+	})}
+	a.request(t, context.Background(), 3)
+	a.request(t, context.Background(), 7)
+
+	a.breaker.UpdateConcurrency(1)
+	a.assertOrder(t, 7, 3)
+}
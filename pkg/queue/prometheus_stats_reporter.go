@@ -17,14 +17,28 @@ limitations under the License.
 package queue
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"strconv"
 	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/atomic"
+	"k8s.io/apimachinery/pkg/util/sets"
 	network "knative.dev/networking/pkg"
+	pkgmetrics "knative.dev/pkg/metrics"
+	pkgnet "knative.dev/pkg/network"
+	"knative.dev/pkg/websocket"
+	pkghttp "knative.dev/serving/pkg/http"
+	"knative.dev/serving/pkg/metrics"
 )
 
 const (
@@ -32,6 +46,25 @@ const (
 	destinationConfigLabel = "destination_configuration"
 	destinationRevLabel    = "destination_revision"
 	destinationPodLabel    = "destination_pod"
+	requestMethodLabel     = "request_method"
+	responseCodeClassLabel = "response_code_class"
+	backendLabel           = "backend"
+	connectionLabel        = "connection"
+	actionLabel            = "action"
+	priorityLabel          = "priority"
+	protocolLabel          = "protocol"
+
+	// requestIDExemplarLabel is the label of the request ID in exemplars.
+	requestIDExemplarLabel = "request_id"
+
+	// connectionReused and connectionNew are the values of the connection
+	// label for upstream connections that were reused and freshly dialed.
+	connectionReused = "reused"
+	connectionNew    = "new"
+
+	// otherMethod is the label value all non-standard request methods are
+	// folded into, to bound the cardinality of the method label.
+	otherMethod = "other"
 )
 
 var (
@@ -58,6 +91,132 @@ var (
 	processUptimeGV = newGV(
 		"process_uptime",
 		"The number of seconds that the process has been up")
+	concurrencyUtilizationGV = newGV(
+		"queue_concurrency_utilization",
+		"Ratio of the average concurrency to the container concurrency of this pod")
+	queueDepthGV = newGV(
+		"queue_depth",
+		"Number of requests waiting for a slot in the breaker of this pod")
+	inFlightRequestsGV = newGV(
+		"queue_in_flight_requests",
+		"Number of requests admitted by the breaker of this pod, both waiting and executing")
+	queueWaitGV = newGV(
+		"queue_wait_time_ewma_seconds",
+		"Moving average of the time the requests waited for a slot in the breaker of this pod")
+	adaptiveConcurrencyLimitGV = newGV(
+		"queue_adaptive_concurrency_limit",
+		"Concurrency limit of this pod adjusted to the latency of the user container")
+
+	queueDepthByPriorityGV = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "queue_depth_by_priority",
+			Help: "Number of requests waiting for a slot in the breaker of this pod per priority",
+		},
+		append([]string{priorityLabel}, metricLabelNames...),
+	)
+
+	activeStreamsGV = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "queue_active_streams",
+			Help: "Number of established WebSocket connections and server-sent event streams of this pod per protocol",
+		},
+		append([]string{protocolLabel}, metricLabelNames...),
+	)
+
+	requestMethodCountCV = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "queue_requests_by_method_total",
+			Help: "Number of requests received by this pod per HTTP method and response code class",
+		},
+		append([]string{requestMethodLabel, responseCodeClassLabel}, metricLabelNames...),
+	)
+
+	backendRequestCountCV = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "queue_requests_by_backend_total",
+			Help: "Number of requests proxied by this pod per backend",
+		},
+		append([]string{backendLabel}, metricLabelNames...),
+	)
+
+	upstreamConnectionCountCV = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "queue_upstream_connections_total",
+			Help: "Number of requests proxied by this pod over a reused or a new connection to the user container",
+		},
+		append([]string{connectionLabel}, metricLabelNames...),
+	)
+
+	saturationEpisodesCV = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "queue_saturation_episodes_total",
+			Help: "Number of times the breaker queue of this pod filled up",
+		},
+		metricLabelNames,
+	)
+
+	concurrencyStateCallCountCV = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "queue_concurrency_state_calls_total",
+			Help: "Number of pause and resume calls to the concurrency state endpoint",
+		},
+		append([]string{actionLabel}, metricLabelNames...),
+	)
+	concurrencyStateFailureCountCV = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "queue_concurrency_state_failures_total",
+			Help: "Number of failed pause and resume calls to the concurrency state endpoint",
+		},
+		append([]string{actionLabel}, metricLabelNames...),
+	)
+	concurrencyStateLatencyHV = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "queue_concurrency_state_latency_seconds",
+			Help:    "Time pause and resume calls to the concurrency state endpoint take",
+			Buckets: prometheus.DefBuckets,
+		},
+		append([]string{actionLabel}, metricLabelNames...),
+	)
+
+	timeToFirstByteHV = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "queue_response_time_to_first_byte_seconds",
+			Help:    "Time from receiving a request to writing the first byte of its response body",
+			Buckets: prometheus.DefBuckets,
+		},
+		metricLabelNames,
+	)
+	responseStreamingDurationHV = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "queue_response_streaming_duration_seconds",
+			Help: "Time from writing the first byte of a response body to finishing the response",
+			// Streamed responses, e.g. of LLM workloads, take minutes.
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 16),
+		},
+		metricLabelNames,
+	)
+	requestBytesHV = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "queue_request_body_bytes",
+			Help:    "Size of the request bodies received by this pod",
+			Buckets: prometheus.ExponentialBuckets(64, 4, 10),
+		},
+		metricLabelNames,
+	)
+	responseBytesHV = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "queue_response_body_bytes",
+			Help:    "Size of the response bodies sent by this pod",
+			Buckets: prometheus.ExponentialBuckets(64, 4, 10),
+		},
+		metricLabelNames,
+	)
+
+	standardMethods = sets.NewString(
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodConnect,
+		http.MethodOptions, http.MethodTrace,
+	)
 )
 
 func newGV(n, h string) *prometheus.GaugeVec {
@@ -70,6 +229,8 @@ func newGV(n, h string) *prometheus.GaugeVec {
 // PrometheusStatsReporter structure represents a prometheus stats reporter.
 type PrometheusStatsReporter struct {
 	handler   http.Handler
+	gatherer  prometheus.Gatherer
+	labels    prometheus.Labels
 	startTime time.Time
 
 	// RequestsPerSecond and ProxiedRequestsPerSecond need to be divided by the
@@ -81,10 +242,57 @@ type PrometheusStatsReporter struct {
 	averageConcurrentRequests        prometheus.Gauge
 	averageProxiedConcurrentRequests prometheus.Gauge
 	processUptime                    prometheus.Gauge
+	requestMethodCount               *prometheus.CounterVec
+	backendRequestCount              *prometheus.CounterVec
+	reusedConnectionCount            prometheus.Counter
+	newConnectionCount               prometheus.Counter
+	timeToFirstByte                  prometheus.Observer
+	responseStreamingDuration        prometheus.Observer
+	requestBytes                     prometheus.Observer
+	responseBytes                    prometheus.Observer
+
+	// concurrencyUtilization is nil if the container concurrency is
+	// unlimited, as there's no capacity to relate the concurrency to.
+	concurrencyUtilization prometheus.Gauge
+	containerConcurrency   float64
+
+	// breaker holds a *breakerSource once a breaker is observed.
+	breaker atomic.Value
+	// adaptiveConcurrency holds an *adaptiveConcurrencySource once an
+	// AdaptiveConcurrency is observed.
+	adaptiveConcurrency atomic.Value
+	// streams holds a *streamsSource once Streams are observed.
+	streams atomic.Value
+}
+
+type streamsSource struct {
+	streams *Streams
+	active  *prometheus.GaugeVec
+}
+
+type adaptiveConcurrencySource struct {
+	limiter *AdaptiveConcurrency
+	limit   prometheus.Gauge
+}
+
+type breakerSource struct {
+	breaker            *Breaker
+	queueDepth         prometheus.Gauge
+	inFlight           prometheus.Gauge
+	queueWait          prometheus.Gauge
+	saturationEpisodes prometheus.Counter
+	// queueDepthByPriority is only reported for breakers with priorities.
+	queueDepthByPriority *prometheus.GaugeVec
+
+	// episodes is the number of saturation episodes reported so far. It's
+	// only accessed by Report.
+	episodes uint64
 }
 
 // NewPrometheusStatsReporter creates a reporter that collects and reports queue metrics.
-func NewPrometheusStatsReporter(namespace, config, revision, pod string, reportingPeriod time.Duration) (*PrometheusStatsReporter, error) {
+// A containerConcurrency of 0 means unlimited concurrency, in which case no
+// utilization is reported.
+func NewPrometheusStatsReporter(namespace, config, revision, pod string, reportingPeriod time.Duration, containerConcurrency int) (*PrometheusStatsReporter, error) {
 	if namespace == "" {
 		return nil, errors.New("namespace must not be empty")
 	}
@@ -102,11 +310,24 @@ func NewPrometheusStatsReporter(namespace, config, revision, pod string, reporti
 	for _, gv := range []*prometheus.GaugeVec{
 		requestsPerSecondGV, proxiedRequestsPerSecondGV,
 		averageConcurrentRequestsGV, averageProxiedConcurrentRequestsGV,
-		processUptimeGV} {
+		processUptimeGV, concurrencyUtilizationGV, queueDepthGV, queueDepthByPriorityGV, inFlightRequestsGV, queueWaitGV,
+		adaptiveConcurrencyLimitGV, activeStreamsGV} {
 		if err := registry.Register(gv); err != nil {
 			return nil, fmt.Errorf("register metric failed: %w", err)
 		}
 	}
+	for _, cv := range []*prometheus.CounterVec{requestMethodCountCV, backendRequestCountCV, upstreamConnectionCountCV, saturationEpisodesCV,
+		concurrencyStateCallCountCV, concurrencyStateFailureCountCV} {
+		if err := registry.Register(cv); err != nil {
+			return nil, fmt.Errorf("register metric failed: %w", err)
+		}
+	}
+	for _, hv := range []*prometheus.HistogramVec{concurrencyStateLatencyHV,
+		timeToFirstByteHV, responseStreamingDurationHV, requestBytesHV, responseBytesHV} {
+		if err := registry.Register(hv); err != nil {
+			return nil, fmt.Errorf("register metric failed: %w", err)
+		}
+	}
 
 	labels := prometheus.Labels{
 		destinationNsLabel:     namespace,
@@ -115,8 +336,12 @@ func NewPrometheusStatsReporter(namespace, config, revision, pod string, reporti
 		destinationPodLabel:    pod,
 	}
 
-	return &PrometheusStatsReporter{
-		handler:   promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+	// Exemplars are only exposed in the OpenMetrics format.
+	opts := promhttp.HandlerOpts{EnableOpenMetrics: true}
+	r := &PrometheusStatsReporter{
+		handler:   promhttp.HandlerFor(registry, opts),
+		gatherer:  registry,
+		labels:    labels,
 		startTime: time.Now(),
 
 		reportingPeriodSeconds: reportingPeriod.Seconds(),
@@ -126,7 +351,20 @@ func NewPrometheusStatsReporter(namespace, config, revision, pod string, reporti
 		averageConcurrentRequests:        averageConcurrentRequestsGV.With(labels),
 		averageProxiedConcurrentRequests: averageProxiedConcurrentRequestsGV.With(labels),
 		processUptime:                    processUptimeGV.With(labels),
-	}, nil
+		requestMethodCount:               requestMethodCountCV.MustCurryWith(labels),
+		backendRequestCount:              backendRequestCountCV.MustCurryWith(labels),
+		reusedConnectionCount:            upstreamConnectionCountCV.MustCurryWith(labels).WithLabelValues(connectionReused),
+		newConnectionCount:               upstreamConnectionCountCV.MustCurryWith(labels).WithLabelValues(connectionNew),
+		timeToFirstByte:                  timeToFirstByteHV.With(labels),
+		responseStreamingDuration:        responseStreamingDurationHV.With(labels),
+		requestBytes:                     requestBytesHV.With(labels),
+		responseBytes:                    responseBytesHV.With(labels),
+	}
+	if containerConcurrency > 0 {
+		r.concurrencyUtilization = concurrencyUtilizationGV.With(labels)
+		r.containerConcurrency = float64(containerConcurrency)
+	}
+	return r, nil
 }
 
 // Report captures request metrics.
@@ -137,6 +375,247 @@ func (r *PrometheusStatsReporter) Report(stats network.RequestStatsReport) {
 	r.averageConcurrentRequests.Set(stats.AverageConcurrency)
 	r.averageProxiedConcurrentRequests.Set(stats.AverageProxiedConcurrency)
 	r.processUptime.Set(time.Since(r.startTime).Seconds())
+	if r.concurrencyUtilization != nil {
+		r.concurrencyUtilization.Set(stats.AverageConcurrency / r.containerConcurrency)
+	}
+	if bs, ok := r.breaker.Load().(*breakerSource); ok {
+		bs.queueDepth.Set(float64(bs.breaker.QueueDepth()))
+		bs.inFlight.Set(float64(bs.breaker.InFlight()))
+		bs.queueWait.Set(bs.breaker.QueueWait().Seconds())
+		for priority, pending := range bs.breaker.PendingRequestsByPriority() {
+			bs.queueDepthByPriority.WithLabelValues(strconv.Itoa(priority)).Set(float64(pending))
+		}
+		episodes := bs.breaker.SaturationEpisodes()
+		bs.saturationEpisodes.Add(float64(episodes - bs.episodes))
+		bs.episodes = episodes
+	}
+	if as, ok := r.adaptiveConcurrency.Load().(*adaptiveConcurrencySource); ok {
+		as.limit.Set(float64(as.limiter.Limit()))
+	}
+	if ss, ok := r.streams.Load().(*streamsSource); ok {
+		for _, protocol := range streamProtocols {
+			ss.active.WithLabelValues(protocol).Set(float64(ss.streams.Active(protocol)))
+		}
+	}
+}
+
+// ObserveBreaker makes the reporter report the queue depth, the requests in
+// flight, the average queue wait and the saturation episodes of the given
+// breaker.
+func (r *PrometheusStatsReporter) ObserveBreaker(b *Breaker) {
+	r.breaker.Store(&breakerSource{
+		breaker:              b,
+		queueDepth:           queueDepthGV.With(r.labels),
+		inFlight:             inFlightRequestsGV.With(r.labels),
+		queueWait:            queueWaitGV.With(r.labels),
+		saturationEpisodes:   saturationEpisodesCV.With(r.labels),
+		queueDepthByPriority: queueDepthByPriorityGV.MustCurryWith(r.labels),
+	})
+}
+
+// ObserveAdaptiveConcurrency makes the reporter report the current limit of
+// the given AdaptiveConcurrency.
+func (r *PrometheusStatsReporter) ObserveAdaptiveConcurrency(a *AdaptiveConcurrency) {
+	r.adaptiveConcurrency.Store(&adaptiveConcurrencySource{
+		limiter: a,
+		limit:   adaptiveConcurrencyLimitGV.With(r.labels),
+	})
+}
+
+// ObserveStreams makes the reporter report the established streams of the
+// given Streams per protocol.
+func (r *PrometheusStatsReporter) ObserveStreams(s *Streams) {
+	r.streams.Store(&streamsSource{
+		streams: s,
+		active:  activeStreamsGV.MustCurryWith(r.labels),
+	})
+}
+
+// RequestMethodHandler counts the requests passing through it by their HTTP
+// method and the class of their response code, e.g. "5xx". Non-standard
+// methods are counted as "other".
+func (r *PrometheusStatsReporter) RequestMethodHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		method := req.Method
+		if !standardMethods.Has(method) {
+			method = otherMethod
+		}
+
+		rr := pkghttp.NewResponseRecorder(w, http.StatusOK)
+		defer func() {
+			// If ServeHTTP panics, recover, count the failure and panic again.
+			if err := recover(); err != nil {
+				r.requestMethodCount.WithLabelValues(method, pkgmetrics.ResponseCodeClass(http.StatusInternalServerError)).Inc()
+				panic(err)
+			}
+			r.requestMethodCount.WithLabelValues(method, pkgmetrics.ResponseCodeClass(rr.ResponseCode)).Inc()
+		}()
+		next.ServeHTTP(rr, req)
+	})
+}
+
+// BackendRequestHandler counts the requests passed to next as requests of
+// the given backend.
+func (r *PrometheusStatsReporter) BackendRequestHandler(backend string, next http.Handler) http.Handler {
+	counter := r.backendRequestCount.WithLabelValues(backend)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		counter.Inc()
+		next.ServeHTTP(w, req)
+	})
+}
+
+// ConnectionTransport counts whether the requests sent through next got a
+// reused connection or a freshly dialed one.
+func (r *PrometheusStatsReporter) ConnectionTransport(next http.RoundTripper) http.RoundTripper {
+	gotConn := func(info httptrace.GotConnInfo) {
+		if info.Reused {
+			r.reusedConnectionCount.Inc()
+		} else {
+			r.newConnectionCount.Inc()
+		}
+	}
+	return pkgnet.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		// The trace must not be shared, as it's composed with the traces
+		// already in the context.
+		trace := &httptrace.ClientTrace{GotConn: gotConn}
+		return next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	})
+}
+
+// ConcurrencyStateHook returns a hook that counts the calls and failures of
+// the given hook for action, i.e. "pause" or "resume", and observes their
+// latency. Wrapped by HookRetryPolicy, every attempt counts as a call.
+func (r *PrometheusStatsReporter) ConcurrencyStateHook(action string, hook func(context.Context) error) func(context.Context) error {
+	calls := concurrencyStateCallCountCV.MustCurryWith(r.labels).WithLabelValues(action)
+	failures := concurrencyStateFailureCountCV.MustCurryWith(r.labels).WithLabelValues(action)
+	latency := concurrencyStateLatencyHV.MustCurryWith(r.labels).WithLabelValues(action)
+	return func(ctx context.Context) error {
+		start := time.Now()
+		err := hook(ctx)
+		latency.Observe(time.Since(start).Seconds())
+		calls.Inc()
+		if err != nil {
+			failures.Inc()
+		}
+		return err
+	}
+}
+
+// ResponseTimingHandler observes the time to the first byte of the responses
+// passing through it, the time it takes to stream the rest of them and the
+// sizes of the request and response bodies, so slow-to-start responses can be
+// told from slow-to-finish ones. Responses without a body count their whole
+// latency as the time to the first byte. Upgraded connections aren't observed.
+func (r *PrometheusStatsReporter) ResponseTimingHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body *countingBody
+		if req.Body != nil && req.Body != http.NoBody {
+			body = &countingBody{ReadCloser: req.Body}
+			req.Body = body
+		}
+		tw := &timingResponseWriter{ResponseWriter: w, start: time.Now()}
+		defer func() {
+			if tw.hijacked {
+				return
+			}
+			end := time.Now()
+			exemplar := exemplarLabels(req.Context())
+			if tw.firstByte.IsZero() {
+				observeWithExemplar(r.timeToFirstByte, end.Sub(tw.start).Seconds(), exemplar)
+			} else {
+				observeWithExemplar(r.timeToFirstByte, tw.firstByte.Sub(tw.start).Seconds(), exemplar)
+				observeWithExemplar(r.responseStreamingDuration, end.Sub(tw.firstByte).Seconds(), exemplar)
+			}
+			if body != nil {
+				r.requestBytes.Observe(float64(body.n.Load()))
+			} else {
+				r.requestBytes.Observe(0)
+			}
+			r.responseBytes.Observe(float64(tw.bytes))
+		}()
+		next.ServeHTTP(tw, req)
+	})
+}
+
+// exemplarLabels returns the labels of the exemplar of the request of ctx:
+// the ID of its trace, if it's sampled, so the observations link to the
+// trace, and its request ID, if it fits the runes allowed for the labels of
+// exemplars along with the trace ID.
+func exemplarLabels(ctx context.Context) prometheus.Labels {
+	labels := make(prometheus.Labels, 2)
+	runes := 0
+	if sc, ok := metrics.SampledSpanContext(ctx); ok {
+		labels[metrics.TraceIDExemplarLabel] = sc.TraceID.String()
+		runes = len(metrics.TraceIDExemplarLabel) + 2*len(sc.TraceID)
+	}
+	if id := pkghttp.RequestIDFromContext(ctx); id != "" && utf8.ValidString(id) &&
+		runes+len(requestIDExemplarLabel)+utf8.RuneCountInString(id) <= prometheus.ExemplarMaxRunes {
+		labels[requestIDExemplarLabel] = id
+	}
+	return labels
+}
+
+// observeWithExemplar observes v, with the given exemplar if it has labels.
+func observeWithExemplar(o prometheus.Observer, v float64, exemplar prometheus.Labels) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && len(exemplar) > 0 {
+		eo.ObserveWithExemplar(v, exemplar)
+		return
+	}
+	o.Observe(v)
+}
+
+// countingBody counts the bytes read of a request body. It may be read by the
+// transport while the handler finishes, hence the atomic.
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+// timingResponseWriter records when the first byte of a response body is
+// written and how many are.
+type timingResponseWriter struct {
+	http.ResponseWriter
+	start     time.Time
+	firstByte time.Time
+	bytes     int
+	hijacked  bool
+}
+
+var (
+	_ http.Flusher  = (*timingResponseWriter)(nil)
+	_ http.Hijacker = (*timingResponseWriter)(nil)
+)
+
+// Write implements http.ResponseWriter.
+func (w *timingResponseWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 && w.firstByte.IsZero() {
+		w.firstByte = time.Now()
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += n
+	return n, err
+}
+
+// Flush implements http.Flusher.
+func (w *timingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (w *timingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := websocket.HijackIfPossible(w.ResponseWriter)
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
 }
 
 // ServeHTTP serves the stats in prometheus format over HTTP.
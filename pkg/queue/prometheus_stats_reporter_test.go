@@ -17,16 +17,25 @@ limitations under the License.
 package queue
 
 import (
+	"context"
 	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/trace"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	network "knative.dev/networking/pkg"
 	"knative.dev/serving/pkg/autoscaler/metrics"
+	pkghttp "knative.dev/serving/pkg/http"
 
 	dto "github.com/prometheus/client_model/go"
 )
@@ -146,7 +155,7 @@ func TestNewPrometheusStatsReporterNegative(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			if _, err := NewPrometheusStatsReporter(test.namespace, test.config, test.revision, test.pod, 1*time.Second); err.Error() != test.result.Error() {
+			if _, err := NewPrometheusStatsReporter(test.namespace, test.config, test.revision, test.pod, 1*time.Second, 0 /*containerConcurrency*/); err.Error() != test.result.Error() {
 				t.Errorf("Got error msg from NewPrometheusStatsReporter(): '%+v', wanted '%+v'", err, test.errorMsg)
 			}
 		})
@@ -156,7 +165,7 @@ func TestNewPrometheusStatsReporterNegative(t *testing.T) {
 func TestPrometheusStatsReporterReport(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, test.reportingPeriod)
+			reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, test.reportingPeriod, 0 /*containerConcurrency*/)
 			if err != nil {
 				t.Errorf("Something went wrong with creating a reporter, '%v'.", err)
 			}
@@ -180,6 +189,297 @@ func TestPrometheusStatsReporterReport(t *testing.T) {
 	}
 }
 
+func TestPrometheusStatsReporterRequestMethods(t *testing.T) {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, 0 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	labels := prometheus.Labels{
+		destinationNsLabel:     namespace,
+		destinationConfigLabel: config,
+		destinationRevLabel:    revision,
+		destinationPodLabel:    pod,
+	}
+	before := map[string]float64{}
+	for _, m := range []string{http.MethodGet, http.MethodPost, otherMethod, "PURGE"} {
+		before[m] = getCounterData(t, requestMethodCountCV, m, "2xx", labels)
+	}
+
+	h := reporter.RequestMethodHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for _, m := range []string{http.MethodGet, http.MethodGet, http.MethodPost, "PURGE", "BREW"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(m, "http://example.com", nil))
+	}
+
+	for m, want := range map[string]float64{
+		http.MethodGet:  2,
+		http.MethodPost: 1,
+		otherMethod:     2,
+		"PURGE":         0,
+	} {
+		if got := getCounterData(t, requestMethodCountCV, m, "2xx", labels) - before[m]; got != want {
+			t.Errorf("Count for method %s = %v, want: %v", m, got, want)
+		}
+	}
+}
+
+func TestPrometheusStatsReporterResponseCodeClasses(t *testing.T) {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, 0 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	labels := prometheus.Labels{
+		destinationNsLabel:     namespace,
+		destinationConfigLabel: config,
+		destinationRevLabel:    revision,
+		destinationPodLabel:    pod,
+	}
+	classes := []string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+	before := map[string]float64{}
+	for _, c := range classes {
+		before[c] = getCounterData(t, requestMethodCountCV, http.MethodPut, c, labels)
+	}
+
+	h := reporter.RequestMethodHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/default":
+			w.Write([]byte("no explicit status means 200"))
+		case "/panic":
+			panic(http.ErrAbortHandler)
+		default:
+			code, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+			w.WriteHeader(code)
+		}
+	}))
+	serve := func(path string) {
+		defer func() { recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "http://example.com"+path, nil))
+	}
+	for _, path := range []string{"/default", "/204", "/301", "/304", "/404", "/500", "/503", "/panic"} {
+		serve(path)
+	}
+
+	for c, want := range map[string]float64{
+		"1xx": 0,
+		"2xx": 2,
+		"3xx": 2,
+		"4xx": 1,
+		"5xx": 3,
+	} {
+		if got := getCounterData(t, requestMethodCountCV, http.MethodPut, c, labels) - before[c]; got != want {
+			t.Errorf("Count for class %s = %v, want: %v", c, got, want)
+		}
+	}
+}
+
+func TestPrometheusStatsReporterBackendRequests(t *testing.T) {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, 0 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	labels := prometheus.Labels{
+		destinationNsLabel:     namespace,
+		destinationConfigLabel: config,
+		destinationRevLabel:    revision,
+		destinationPodLabel:    pod,
+	}
+	count := func(backend string) float64 {
+		c, err := backendRequestCountCV.MustCurryWith(labels).GetMetricWithLabelValues(backend)
+		if err != nil {
+			t.Fatal("GetMetricWithLabelValues() =", err)
+		}
+		m := dto.Metric{}
+		if err := c.Write(&m); err != nil {
+			t.Fatal("Counter.Write() error =", err)
+		}
+		return m.Counter.GetValue()
+	}
+	beforeBlue, beforeGreen := count("blue"), count("green")
+
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	blue, green := reporter.BackendRequestHandler("blue", next), reporter.BackendRequestHandler("green", next)
+	for i := 0; i < 3; i++ {
+		blue.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	}
+	green.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+
+	if got, want := count("blue")-beforeBlue, 3.0; got != want {
+		t.Errorf("Count for blue = %v, want: %v", got, want)
+	}
+	if got, want := count("green")-beforeGreen, 1.0; got != want {
+		t.Errorf("Count for green = %v, want: %v", got, want)
+	}
+}
+
+func TestPrometheusStatsReporterConnectionTransport(t *testing.T) {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, 0 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	labels := prometheus.Labels{
+		destinationNsLabel:     namespace,
+		destinationConfigLabel: config,
+		destinationRevLabel:    revision,
+		destinationPodLabel:    pod,
+	}
+	count := func(connection string) float64 {
+		m := dto.Metric{}
+		if err := upstreamConnectionCountCV.MustCurryWith(labels).WithLabelValues(connection).Write(&m); err != nil {
+			t.Fatal("Counter.Write() error =", err)
+		}
+		return m.Counter.GetValue()
+	}
+	beforeReused, beforeNew := count(connectionReused), count(connectionNew)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+	defer server.Close()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: reporter.ConnectionTransport(transport)}
+	request := func() {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal("Get() =", err)
+		}
+		// Consume the body, so the connection is put back for reuse.
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	// The first request dials, the following ones reuse its connection.
+	for i := 0; i < 3; i++ {
+		request()
+	}
+	// Without an idle connection, the next request dials again.
+	transport.CloseIdleConnections()
+	request()
+
+	if got, want := count(connectionReused)-beforeReused, 2.0; got != want {
+		t.Errorf("Reused connections = %v, want: %v", got, want)
+	}
+	if got, want := count(connectionNew)-beforeNew, 2.0; got != want {
+		t.Errorf("New connections = %v, want: %v", got, want)
+	}
+}
+
+func TestPrometheusStatsReporterSaturationEpisodes(t *testing.T) {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, 1 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	breaker := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1})
+	reporter.ObserveBreaker(breaker)
+	count := func() float64 {
+		m := dto.Metric{}
+		if err := saturationEpisodesCV.With(prometheus.Labels{
+			destinationNsLabel:     namespace,
+			destinationConfigLabel: config,
+			destinationRevLabel:    revision,
+			destinationPodLabel:    pod,
+		}).Write(&m); err != nil {
+			t.Fatal("Counter.Write() error =", err)
+		}
+		return m.Counter.GetValue()
+	}
+	before := count()
+
+	release, _ := breaker.Reserve(context.Background())
+	defer release()
+	for i := 0; i < 10; i++ {
+		breaker.Reserve(context.Background())
+		reporter.Report(network.RequestStatsReport{})
+	}
+	if got, want := count()-before, 1.0; got != want {
+		t.Errorf("Saturation episodes = %v, want: %v", got, want)
+	}
+
+	m := dto.Metric{}
+	if err := inFlightRequestsGV.With(prometheus.Labels{
+		destinationNsLabel:     namespace,
+		destinationConfigLabel: config,
+		destinationRevLabel:    revision,
+		destinationPodLabel:    pod,
+	}).Write(&m); err != nil {
+		t.Fatal("Gauge.Write() error =", err)
+	}
+	if got, want := m.Gauge.GetValue(), float64(breaker.InFlight()); got != want {
+		t.Errorf("In-flight requests = %v, want: %v", got, want)
+	}
+}
+
+func TestPrometheusStatsReporterConcurrencyUtilization(t *testing.T) {
+	tests := []struct {
+		name                 string
+		containerConcurrency int
+		averageConcurrency   float64
+		want                 float64
+	}{{
+		name:                 "idle",
+		containerConcurrency: 10,
+		want:                 0,
+	}, {
+		name:                 "half utilized",
+		containerConcurrency: 10,
+		averageConcurrency:   5,
+		want:                 0.5,
+	}, {
+		name:                 "fully utilized",
+		containerConcurrency: 1,
+		averageConcurrency:   1,
+		want:                 1,
+	}, {
+		name:                 "queueing",
+		containerConcurrency: 4,
+		averageConcurrency:   6,
+		want:                 1.5,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, test.containerConcurrency)
+			if err != nil {
+				t.Fatal("NewPrometheusStatsReporter() =", err)
+			}
+			reporter.Report(network.RequestStatsReport{AverageConcurrency: test.averageConcurrency})
+			if got := getData(t, concurrencyUtilizationGV); got != test.want {
+				t.Errorf("Utilization = %v, want: %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPrometheusStatsReporterConcurrencyUtilizationUnlimited(t *testing.T) {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, "unlimited-pod", time.Second, 0 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	reporter.Report(network.RequestStatsReport{AverageConcurrency: 3})
+
+	rec := httptest.NewRecorder()
+	reporter.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	if strings.Contains(rec.Body.String(), `queue_concurrency_utilization{destination_configuration="helloworld-go",destination_namespace="default",destination_pod="unlimited-pod"`) {
+		t.Error("Utilization reported for unlimited container concurrency")
+	}
+}
+
+func getCounterData(t *testing.T, cv *prometheus.CounterVec, method, codeClass string, labels prometheus.Labels) float64 {
+	t.Helper()
+	l := prometheus.Labels{requestMethodLabel: method, responseCodeClassLabel: codeClass}
+	for k, v := range labels {
+		l[k] = v
+	}
+	c, err := cv.GetMetricWith(l)
+	if err != nil {
+		t.Fatal("CounterVec.GetMetricWith() error =", err)
+	}
+	m := dto.Metric{}
+	if err := c.Write(&m); err != nil {
+		t.Fatal("Counter.Write() error =", err)
+	}
+	return m.Counter.GetValue()
+}
+
 func getData(t *testing.T, gv *prometheus.GaugeVec) float64 {
 	t.Helper()
 	g, err := gv.GetMetricWith(prometheus.Labels{
@@ -197,3 +497,230 @@ func getData(t *testing.T, gv *prometheus.GaugeVec) float64 {
 	}
 	return m.Gauge.GetValue()
 }
+
+func TestPrometheusStatsReporterQueueDepthByPriority(t *testing.T) {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, 1 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	breaker := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 1, InitialCapacity: 0, Priorities: RequestPriorities})
+	reporter.ObserveBreaker(breaker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, priority := range []int{2, 7, 7} {
+		go breaker.Maybe(WithRequestPriority(ctx, priority), func() {})
+	}
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return breaker.PendingRequests() == 3, nil
+	}); err != nil {
+		t.Fatalf("PendingRequests() = %d, want: 3", breaker.PendingRequests())
+	}
+	reporter.Report(network.RequestStatsReport{})
+
+	depth := func(priority string) float64 {
+		m := dto.Metric{}
+		if err := queueDepthByPriorityGV.With(prometheus.Labels{
+			priorityLabel:          priority,
+			destinationNsLabel:     namespace,
+			destinationConfigLabel: config,
+			destinationRevLabel:    revision,
+			destinationPodLabel:    pod,
+		}).Write(&m); err != nil {
+			t.Fatal("Gauge.Write() error =", err)
+		}
+		return m.Gauge.GetValue()
+	}
+	for priority, want := range map[string]float64{"0": 0, "2": 1, "7": 2} {
+		if got := depth(priority); got != want {
+			t.Errorf("Queue depth of priority %s = %v, want: %v", priority, got, want)
+		}
+	}
+}
+
+func TestPrometheusStatsReporterConcurrencyStateHook(t *testing.T) {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, 0 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	labels := prometheus.Labels{
+		destinationNsLabel:     namespace,
+		destinationConfigLabel: config,
+		destinationRevLabel:    revision,
+		destinationPodLabel:    pod,
+	}
+	count := func(cv *prometheus.CounterVec) float64 {
+		m := dto.Metric{}
+		if err := cv.MustCurryWith(labels).WithLabelValues("pause").Write(&m); err != nil {
+			t.Fatal("Counter.Write() error =", err)
+		}
+		return m.Counter.GetValue()
+	}
+	observations := func() uint64 {
+		m := dto.Metric{}
+		if err := concurrencyStateLatencyHV.MustCurryWith(labels).WithLabelValues("pause").(prometheus.Histogram).Write(&m); err != nil {
+			t.Fatal("Histogram.Write() error =", err)
+		}
+		return m.Histogram.GetSampleCount()
+	}
+	beforeCalls, beforeFailures, beforeObservations := count(concurrencyStateCallCountCV), count(concurrencyStateFailureCountCV), observations()
+
+	fail := true
+	hook := reporter.ConcurrencyStateHook("pause", func(context.Context) error {
+		if fail {
+			return errors.New("pause failed")
+		}
+		return nil
+	})
+	if err := hook(context.Background()); err == nil {
+		t.Error("hook() = nil, want the error of the wrapped hook")
+	}
+	fail = false
+	if err := hook(context.Background()); err != nil {
+		t.Error("hook() =", err)
+	}
+
+	if got, want := count(concurrencyStateCallCountCV)-beforeCalls, 2.0; got != want {
+		t.Errorf("Calls = %v, want: %v", got, want)
+	}
+	if got, want := count(concurrencyStateFailureCountCV)-beforeFailures, 1.0; got != want {
+		t.Errorf("Failures = %v, want: %v", got, want)
+	}
+	if got, want := observations()-beforeObservations, uint64(2); got != want {
+		t.Errorf("Latency observations = %v, want: %v", got, want)
+	}
+}
+
+func TestPrometheusStatsReporterResponseTiming(t *testing.T) {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, 0 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	labels := prometheus.Labels{
+		destinationNsLabel:     namespace,
+		destinationConfigLabel: config,
+		destinationRevLabel:    revision,
+		destinationPodLabel:    pod,
+	}
+	histogram := func(hv *prometheus.HistogramVec) *dto.Histogram {
+		m := dto.Metric{}
+		if err := hv.With(labels).(prometheus.Histogram).Write(&m); err != nil {
+			t.Fatal("Histogram.Write() error =", err)
+		}
+		return m.Histogram
+	}
+	before := map[*prometheus.HistogramVec]*dto.Histogram{}
+	for _, hv := range []*prometheus.HistogramVec{timeToFirstByteHV, responseStreamingDurationHV, requestBytesHV, responseBytesHV} {
+		before[hv] = histogram(hv)
+	}
+
+	const delay = 50 * time.Millisecond
+	h := reporter.ResponseTimingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("first"))
+		time.Sleep(delay)
+		w.Write([]byte("second"))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("request")))
+
+	delta := func(hv *prometheus.HistogramVec) (uint64, float64) {
+		got := histogram(hv)
+		return got.GetSampleCount() - before[hv].GetSampleCount(), got.GetSampleSum() - before[hv].GetSampleSum()
+	}
+	if count, sum := delta(timeToFirstByteHV); count != 1 || sum >= delay.Seconds() {
+		t.Errorf("Time to first byte = %d observations summing to %v, want one below %v", count, sum, delay)
+	}
+	if count, sum := delta(responseStreamingDurationHV); count != 1 || sum < delay.Seconds() {
+		t.Errorf("Streaming duration = %d observations summing to %v, want one of at least %v", count, sum, delay)
+	}
+	if count, sum := delta(requestBytesHV); count != 1 || sum != float64(len("request")) {
+		t.Errorf("Request bytes = %d observations summing to %v, want one of %d", count, sum, len("request"))
+	}
+	if count, sum := delta(responseBytesHV); count != 1 || sum != float64(len("firstsecond")) {
+		t.Errorf("Response bytes = %d observations summing to %v, want one of %d", count, sum, len("firstsecond"))
+	}
+
+	// Responses without a body only count towards the time to first byte.
+	for hv := range before {
+		before[hv] = histogram(hv)
+	}
+	h = reporter.ResponseTimingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if count, _ := delta(timeToFirstByteHV); count != 1 {
+		t.Errorf("Time to first byte observations = %d, want 1", count)
+	}
+	if count, _ := delta(responseStreamingDurationHV); count != 0 {
+		t.Errorf("Streaming duration observations = %d, want 0", count)
+	}
+}
+
+func TestPrometheusStatsReporterRequestIDExemplars(t *testing.T) {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, 0 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	h := pkghttp.NewRequestIDHandler(reporter.ResponseTimingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})), pkghttp.DefaultRequestIDHeader, pkghttp.RequestIDGeneratorUUIDv7)
+
+	const id = "exemplar-request"
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(pkghttp.DefaultRequestIDHeader, id)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	m := dto.Metric{}
+	if err := reporter.timeToFirstByte.(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatal("Histogram.Write() error =", err)
+	}
+	found := false
+	for _, b := range m.Histogram.Bucket {
+		for _, l := range b.GetExemplar().GetLabel() {
+			found = found || (l.GetName() == requestIDExemplarLabel && l.GetValue() == id)
+		}
+	}
+	if !found {
+		t.Errorf("No bucket has an exemplar with %s=%q", requestIDExemplarLabel, id)
+	}
+
+	// The metrics are exposed with their exemplars to OpenMetrics scrapers.
+	scrape := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	scrape.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	rec := httptest.NewRecorder()
+	reporter.ServeHTTP(rec, scrape)
+	if !strings.Contains(rec.Body.String(), `request_id="`+id+`"`) {
+		t.Error("The request ID exemplar isn't exposed")
+	}
+}
+
+func TestPrometheusStatsReporterTraceExemplars(t *testing.T) {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, 0 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	h := reporter.ResponseTimingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+
+	for _, sampler := range []trace.Sampler{trace.NeverSample(), trace.AlwaysSample()} {
+		ctx, span := trace.StartSpan(context.Background(), "request", trace.WithSampler(sampler))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+		span.End()
+
+		m := dto.Metric{}
+		if err := reporter.timeToFirstByte.(prometheus.Histogram).Write(&m); err != nil {
+			t.Fatal("Histogram.Write() error =", err)
+		}
+		traceID := span.SpanContext().TraceID.String()
+		found := false
+		for _, b := range m.Histogram.Bucket {
+			for _, l := range b.GetExemplar().GetLabel() {
+				found = found || (l.GetName() == "trace_id" && l.GetValue() == traceID)
+			}
+		}
+		if sampled := span.SpanContext().IsSampled(); found != sampled {
+			t.Errorf("Sampled = %v: found an exemplar with the trace ID = %v", sampled, found)
+		}
+	}
+}
@@ -17,7 +17,11 @@ limitations under the License.
 package queue
 
 import (
+	"bytes"
+	"compress/gzip"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.uber.org/atomic"
@@ -26,10 +30,15 @@ import (
 
 	network "knative.dev/networking/pkg"
 	"knative.dev/serving/pkg/autoscaler/metrics"
+	pkghttp "knative.dev/serving/pkg/http"
 )
 
 const (
-	contentTypeHeader = "Content-Type"
+	contentTypeHeader     = "Content-Type"
+	contentLengthHeader   = "Content-Length"
+	contentEncodingHeader = "Content-Encoding"
+	acceptEncodingHeader  = "Accept-Encoding"
+	gzipEncoding          = "gzip"
 )
 
 // ProtobufStatsReporter structure represents a protobuf stats reporter.
@@ -38,16 +47,26 @@ type ProtobufStatsReporter struct {
 	stat      atomic.Value
 	podName   string
 
+	// compress enables gzip compression of the served stat for clients
+	// that accept it.
+	compress bool
+
+	// errors is the number of requests that failed since the last Report.
+	errors atomic.Int64
+
 	// RequestCount and ProxiedRequestCount need to be divided by the reporting period
 	// they were collected over to get a "per-second" value.
 	reportingPeriodSeconds float64
 }
 
 // NewProtobufStatsReporter creates a reporter that collects and reports queue metrics.
-func NewProtobufStatsReporter(pod string, reportingPeriod time.Duration) *ProtobufStatsReporter {
+// If compress is true, the stat is gzip-compressed for clients that send a
+// matching Accept-Encoding header.
+func NewProtobufStatsReporter(pod string, reportingPeriod time.Duration, compress bool) *ProtobufStatsReporter {
 	r := &ProtobufStatsReporter{
 		startTime: time.Now(),
 		podName:   pod,
+		compress:  compress,
 
 		reportingPeriodSeconds: reportingPeriod.Seconds(),
 	}
@@ -71,12 +90,38 @@ func (r *ProtobufStatsReporter) Report(stats network.RequestStatsReport) {
 		ProxiedRequestCount:              stats.ProxiedRequestCount / r.reportingPeriodSeconds,
 		AverageConcurrentRequests:        stats.AverageConcurrency,
 		AverageProxiedConcurrentRequests: stats.AverageProxiedConcurrency,
+		ErrorCount:                       float64(r.errors.Swap(0)) / r.reportingPeriodSeconds,
+	})
+}
+
+// ErrorHandler counts the requests passing through it that fail with a 5xx
+// status, including the ones that couldn't reach the user container, for the
+// autoscaler to put the activator back in the path of a failing revision.
+func (r *ProtobufStatsReporter) ErrorHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rr := pkghttp.NewResponseRecorder(w, http.StatusOK)
+		defer func() {
+			// If ServeHTTP panics, recover, count the failure and panic again.
+			if err := recover(); err != nil {
+				r.errors.Inc()
+				panic(err)
+			}
+			if rr.ResponseCode >= http.StatusInternalServerError {
+				r.errors.Inc()
+			}
+		}()
+		next.ServeHTTP(rr, req)
 	})
 }
 
+// Stat returns the stat last reported, e.g. to push it to the autoscaler.
+func (r *ProtobufStatsReporter) Stat() metrics.Stat {
+	return r.stat.Load().(metrics.Stat)
+}
+
 // ServeHTTP serves the stats in protobuf format over HTTP.
-func (r *ProtobufStatsReporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
-	data := r.stat.Load().(metrics.Stat)
+func (r *ProtobufStatsReporter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	data := r.Stat()
 	buffer, err := proto.Marshal(&data)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -84,5 +129,19 @@ func (r *ProtobufStatsReporter) ServeHTTP(w http.ResponseWriter, _ *http.Request
 	}
 
 	w.Header().Set(contentTypeHeader, network.ProtoAcceptContent)
+	if r.compress && req != nil && strings.Contains(req.Header.Get(acceptEncodingHeader), gzipEncoding) {
+		var compressed bytes.Buffer
+		gw := gzip.NewWriter(&compressed)
+		gw.Write(buffer)
+		gw.Close()
+		buffer = compressed.Bytes()
+		w.Header().Set(contentEncodingHeader, gzipEncoding)
+	}
+
+	// HEAD requests get the same headers as a GET, but no body.
+	w.Header().Set(contentLengthHeader, strconv.Itoa(len(buffer)))
+	if req != nil && req.Method == http.MethodHead {
+		return
+	}
 	w.Write(buffer)
 }
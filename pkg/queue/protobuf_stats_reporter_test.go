@@ -17,6 +17,7 @@ limitations under the License.
 package queue
 
 import (
+	"compress/gzip"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -25,13 +26,14 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 
+	network "knative.dev/networking/pkg"
 	"knative.dev/serving/pkg/autoscaler/metrics"
 )
 
 func TestProtobufStatsReporterReport(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			reporter := NewProtobufStatsReporter(pod, test.reportingPeriod)
+			reporter := NewProtobufStatsReporter(pod, test.reportingPeriod, false /*compress*/)
 			// Make the value slightly more interesting, rather than microseconds.
 			reporter.startTime = reporter.startTime.Add(-5 * time.Second)
 			reporter.Report(test.report)
@@ -43,12 +45,40 @@ func TestProtobufStatsReporterReport(t *testing.T) {
 			if gotUptime := got.ProcessUptime; gotUptime < 5.0 || gotUptime > 6.0 {
 				t.Errorf("Got %v for process uptime, wanted 5.0 <= x < 6.0", gotUptime)
 			}
+			if pushed := reporter.Stat(); !cmp.Equal(got, pushed) {
+				t.Errorf("Stat() mismatch; diff(-scraped,+pushed):\n%s", cmp.Diff(got, pushed))
+			}
 		})
 	}
 }
 
+func TestProtobufStatsReporterErrors(t *testing.T) {
+	r := NewProtobufStatsReporter(pod, 2*time.Second, false /*compress*/)
+	h := r.ErrorHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/reject":
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	for _, path := range []string{"/fail", "/fail", "/fail", "/fail", "/reject", "/"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	r.Report(network.RequestStatsReport{RequestCount: 6})
+	if got, want := r.Stat().ErrorCount, 2.; got != want {
+		t.Errorf("ErrorCount = %v, want: %v", got, want)
+	}
+	// The errors are counted anew for every report.
+	r.Report(network.RequestStatsReport{})
+	if got := r.Stat().ErrorCount; got != 0 {
+		t.Errorf("ErrorCount = %v, want: 0", got)
+	}
+}
+
 func TestInitialProtobufStateValid(t *testing.T) {
-	r := NewProtobufStatsReporter(pod, 1*time.Second)
+	r := NewProtobufStatsReporter(pod, 1*time.Second, false /*compress*/)
 	emptyStat := metrics.Stat{
 		PodName: pod,
 	}
@@ -82,3 +112,107 @@ func scrapeProtobufStat(t *testing.T, r *ProtobufStatsReporter) metrics.Stat {
 
 	return stat
 }
+
+func TestProtobufStatsReporterCompression(t *testing.T) {
+	report := network.RequestStatsReport{
+		AverageConcurrency:        3,
+		AverageProxiedConcurrency: 2,
+		RequestCount:              39,
+		ProxiedRequestCount:       15,
+	}
+
+	tests := []struct {
+		name           string
+		compress       bool
+		acceptEncoding string
+		wantEncoding   string
+	}{{
+		name: "compression disabled",
+	}, {
+		name:           "compression disabled, client accepts gzip",
+		acceptEncoding: "gzip",
+	}, {
+		name:     "compression enabled, client doesn't accept gzip",
+		compress: true,
+	}, {
+		name:           "compression enabled, client accepts gzip",
+		compress:       true,
+		acceptEncoding: "gzip",
+		wantEncoding:   "gzip",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := NewProtobufStatsReporter(pod, 1*time.Second, test.compress)
+			r.Report(report)
+
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/metrics", nil)
+			if test.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			result := w.Result()
+			if got := result.Header.Get("Content-Encoding"); got != test.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want: %q", got, test.wantEncoding)
+			}
+
+			body := io.Reader(result.Body)
+			if test.wantEncoding == "gzip" {
+				gz, err := gzip.NewReader(result.Body)
+				if err != nil {
+					t.Fatal("Failed to create gzip reader:", err)
+				}
+				body = gz
+			}
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal("Expected Read to succeed, got", err)
+			}
+			var got metrics.Stat
+			if err := got.Unmarshal(b); err != nil {
+				t.Fatal("Expected Unmarshal to succeed, got", err)
+			}
+
+			want := metrics.Stat{
+				PodName:                          pod,
+				AverageConcurrentRequests:        3,
+				AverageProxiedConcurrentRequests: 2,
+				RequestCount:                     39,
+				ProxiedRequestCount:              15,
+			}
+			if !cmp.Equal(want, got, ignoreStatFields) {
+				t.Errorf("Scraped stat mismatch; diff(-want,+got):\n%s", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestProtobufStatsReporterHead(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		r := NewProtobufStatsReporter(pod, 1*time.Second, compress)
+		r.Report(network.RequestStatsReport{AverageConcurrency: 3, RequestCount: 39})
+
+		serve := func(method string) *http.Response {
+			req := httptest.NewRequest(method, "http://localhost/metrics", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Result()
+		}
+		get, head := serve(http.MethodGet), serve(http.MethodHead)
+
+		for _, h := range []string{"Content-Type", "Content-Encoding", "Content-Length"} {
+			if got, want := head.Header.Get(h), get.Header.Get(h); got != want {
+				t.Errorf("compress=%v: HEAD %s = %q, want: %q", compress, h, got, want)
+			}
+		}
+		if head.Header.Get("Content-Length") == "0" {
+			t.Errorf("compress=%v: HEAD Content-Length = 0, want the GET body length", compress)
+		}
+		if b, _ := io.ReadAll(head.Body); len(b) != 0 {
+			t.Errorf("compress=%v: HEAD body has %d bytes, want: 0", compress, len(b))
+		}
+	}
+}
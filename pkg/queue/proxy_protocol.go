@@ -0,0 +1,217 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// proxyV1MaxLength is the maximum length of a v1 header, including the
+	// trailing CRLF.
+	proxyV1MaxLength = 107
+
+	proxyV2HeaderLength = 16
+)
+
+// proxyV2Signature starts every v2 header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// NewProxyProtocolListener wraps l to expect a PROXY protocol header, in
+// either the v1 text or the v2 binary format, at the start of each accepted
+// connection. The source address of the header becomes the RemoteAddr of the
+// connection, and with it the RemoteAddr of the requests served over it.
+//
+// The header is read on the first Read or RemoteAddr call on the connection,
+// so a slow client doesn't hold up the accept loop, and must arrive within
+// timeout. Connections without a valid header are closed.
+func NewProxyProtocolListener(l net.Listener, timeout time.Duration) net.Listener {
+	return &proxyProtocolListener{Listener: l, timeout: timeout}
+}
+
+type proxyProtocolListener struct {
+	net.Listener
+	timeout time.Duration
+}
+
+// Accept implements net.Listener.
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyProtocolConn{
+		Conn:    conn,
+		r:       bufio.NewReader(conn),
+		timeout: l.timeout,
+	}, nil
+}
+
+type proxyProtocolConn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+// readHeader reads the PROXY protocol header once and closes the connection
+// if it's invalid.
+func (c *proxyProtocolConn) readHeader() error {
+	c.once.Do(func() {
+		if c.err = c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); c.err != nil {
+			c.Conn.Close()
+			return
+		}
+		c.remoteAddr, c.err = readProxyHeader(c.r)
+		if c.err == nil {
+			c.err = c.Conn.SetReadDeadline(time.Time{})
+		}
+		if c.err != nil {
+			c.Conn.Close()
+		}
+	})
+	return c.err
+}
+
+// Read implements net.Conn.
+func (c *proxyProtocolConn) Read(p []byte) (int, error) {
+	if err := c.readHeader(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// RemoteAddr implements net.Conn. It's the source address of the PROXY
+// protocol header, unless the header doesn't carry one.
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	if c.readHeader() == nil && c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader reads a v1 or v2 PROXY protocol header from r and returns
+// its source address, which is nil if the header doesn't carry one, e.g. for
+// health checks of the load balancer.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	sig, err := r.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PROXY protocol header: %w", err)
+	}
+	switch {
+	case bytes.Equal(sig, proxyV2Signature):
+		return readProxyV2Header(r)
+	case bytes.HasPrefix(sig, []byte("PROXY ")):
+		return readProxyV1Header(r)
+	default:
+		return nil, errors.New("missing PROXY protocol header")
+	}
+}
+
+// readProxyV1Header reads a header like "PROXY TCP4 <src> <dst> <src port>
+// <dst port>\r\n".
+func readProxyV1Header(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) == proxyV1MaxLength {
+			return nil, errors.New("PROXY protocol v1 header too long")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PROXY protocol v1 header: %w", err)
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		// The rest of the line is to be ignored.
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("malformed PROXY protocol v1 header: %q", line)
+	}
+	ip := net.ParseIP(fields[2])
+	if ip == nil || (ip.To4() != nil) != (fields[1] == "TCP4") {
+		return nil, fmt.Errorf("invalid source address in PROXY protocol v1 header: %q", fields[2])
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source port in PROXY protocol v1 header: %w", err)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyV2Header reads a binary header, see section 2.2 of
+// https://www.haproxy.org/download/2.4/doc/proxy-protocol.txt.
+func readProxyV2Header(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, proxyV2HeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read PROXY protocol v2 header: %w", err)
+	}
+	if version := header[12] >> 4; version != 2 {
+		return nil, fmt.Errorf("unsupported PROXY protocol version %d", version)
+	}
+	// The addresses are followed by optional TLVs, which are skipped.
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("failed to read PROXY protocol v2 addresses: %w", err)
+	}
+
+	switch command := header[12] & 0xF; command {
+	case 0x0: // LOCAL, e.g. health checks of the load balancer.
+		return nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, fmt.Errorf("unsupported PROXY protocol v2 command %d", command)
+	}
+
+	var ipLen int
+	switch family := header[13] >> 4; family {
+	case 0x1: // AF_INET
+		ipLen = net.IPv4len
+	case 0x2: // AF_INET6
+		ipLen = net.IPv6len
+	default: // AF_UNSPEC and AF_UNIX carry no IP address.
+		return nil, nil
+	}
+	// Source and destination address, then source and destination port.
+	if len(payload) < 2*ipLen+4 {
+		return nil, errors.New("PROXY protocol v2 addresses truncated")
+	}
+	ip := net.IP(payload[:ipLen])
+	port := binary.BigEndian.Uint16(payload[2*ipLen:])
+	switch header[13] & 0xF {
+	case 0x2: // SOCK_DGRAM
+		return &net.UDPAddr{IP: ip, Port: int(port)}, nil
+	default:
+		return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// proxyV2Header builds a binary header with the given command, family and
+// transport byte and addresses.
+func proxyV2Header(command, family byte, addrs []byte) []byte {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:], uint16(len(addrs)))
+	return append(header, addrs...)
+}
+
+func proxyV2Addrs(src, dst net.IP, srcPort, dstPort uint16) []byte {
+	addrs := append(append([]byte{}, src...), dst...)
+	addrs = append(addrs, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(addrs[len(addrs)-4:], srcPort)
+	binary.BigEndian.PutUint16(addrs[len(addrs)-2:], dstPort)
+	return addrs
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	v4Addrs := proxyV2Addrs(net.ParseIP("192.0.2.1").To4(), net.ParseIP("198.51.100.1").To4(), 56324, 443)
+	v6Addrs := proxyV2Addrs(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), 56324, 443)
+
+	tests := []struct {
+		name    string
+		header  []byte
+		want    string // Empty if no address is carried.
+		wantErr bool
+	}{{
+		name:   "v1 TCP4",
+		header: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"),
+		want:   "192.0.2.1:56324",
+	}, {
+		name:   "v1 TCP6",
+		header: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"),
+		want:   "[2001:db8::1]:56324",
+	}, {
+		name:   "v1 UNKNOWN",
+		header: []byte("PROXY UNKNOWN ignored stuff\r\n"),
+	}, {
+		name:    "v1 family mismatch",
+		header:  []byte("PROXY TCP4 2001:db8::1 2001:db8::2 56324 443\r\n"),
+		wantErr: true,
+	}, {
+		name:    "v1 bad port",
+		header:  []byte("PROXY TCP4 192.0.2.1 198.51.100.1 70000 443\r\n"),
+		wantErr: true,
+	}, {
+		name:    "v1 missing fields",
+		header:  []byte("PROXY TCP4 192.0.2.1\r\n"),
+		wantErr: true,
+	}, {
+		name:    "v1 without CRLF",
+		header:  []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443" + strings.Repeat(" ", 100)),
+		wantErr: true,
+	}, {
+		name:   "v2 TCP over IPv4",
+		header: proxyV2Header(0x1, 0x11, v4Addrs),
+		want:   "192.0.2.1:56324",
+	}, {
+		name:   "v2 TCP over IPv6",
+		header: proxyV2Header(0x1, 0x21, v6Addrs),
+		want:   "[2001:db8::1]:56324",
+	}, {
+		name:   "v2 with TLVs",
+		header: proxyV2Header(0x1, 0x11, append(v4Addrs, 0x04, 0, 1, 0xff)),
+		want:   "192.0.2.1:56324",
+	}, {
+		name:   "v2 LOCAL",
+		header: proxyV2Header(0x0, 0x00, nil),
+	}, {
+		name:   "v2 AF_UNSPEC",
+		header: proxyV2Header(0x1, 0x00, nil),
+	}, {
+		name:    "v2 truncated addresses",
+		header:  proxyV2Header(0x1, 0x21, v4Addrs),
+		wantErr: true,
+	}, {
+		name:    "v2 bad version",
+		header:  append(append([]byte{}, proxyV2Signature...), 0x11, 0x11, 0, 0),
+		wantErr: true,
+	}, {
+		name:    "no header",
+		header:  []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"),
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The request following the header must be left unread.
+			r := bufio.NewReader(io.MultiReader(bytes.NewReader(test.header), strings.NewReader("rest")))
+			addr, err := readProxyHeader(r)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("readProxyHeader() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			var got string
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != test.want {
+				t.Errorf("Address = %q, want: %q", got, test.want)
+			}
+			if rest, _ := io.ReadAll(r); string(rest) != "rest" {
+				t.Errorf("Remainder = %q, want: %q", rest, "rest")
+			}
+		})
+	}
+}
+
+func TestProxyProtocolListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.RemoteAddr)
+	})}
+	go server.Serve(NewProxyProtocolListener(l, time.Second))
+	defer server.Close()
+
+	// request sends an HTTP request prefixed by header and returns the
+	// address the server saw, or an error if the connection was rejected.
+	request := func(header []byte) (string, error) {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal("Dial() =", err)
+		}
+		defer conn.Close()
+		conn.Write(append(header, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"...))
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	v1 := []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n")
+	if got, err := request(v1); err != nil || got != "192.0.2.1:56324" {
+		t.Errorf("v1: RemoteAddr = %q, %v, want: %q", got, err, "192.0.2.1:56324")
+	}
+	v2 := proxyV2Header(0x1, 0x21, proxyV2Addrs(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), 56324, 443))
+	if got, err := request(v2); err != nil || got != "[2001:db8::1]:56324" {
+		t.Errorf("v2: RemoteAddr = %q, %v, want: %q", got, err, "[2001:db8::1]:56324")
+	}
+	// Without an address, the one of the connection is used.
+	if got, err := request([]byte("PROXY UNKNOWN\r\n")); err != nil || !strings.HasPrefix(got, "127.0.0.1:") {
+		t.Errorf("UNKNOWN: RemoteAddr = %q, %v, want the connection's", got, err)
+	}
+	if got, err := request(nil); err == nil {
+		t.Errorf("Request without header succeeded with RemoteAddr %q, want rejected", got)
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// QueueState is the JSON representation of the state of the breaker, for
+// the load balancers that pick the pods by how busy they are.
+type QueueState struct {
+	// QueueDepth is the number of requests waiting for a slot.
+	QueueDepth int `json:"queueDepth"`
+	// InFlight is the number of requests admitted, both the waiting and the
+	// executing ones.
+	InFlight int `json:"inFlight"`
+	// Capacity is the number of requests executed at once.
+	Capacity int `json:"capacity"`
+	// QueueWaitSeconds is the moving average of the time the requests waited
+	// for a slot, the wait a request arriving now can expect.
+	QueueWaitSeconds float64 `json:"queueWaitSeconds"`
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() QueueState {
+	return QueueState{
+		QueueDepth:       b.QueueDepth(),
+		InFlight:         b.InFlight(),
+		Capacity:         b.Capacity(),
+		QueueWaitSeconds: b.QueueWait().Seconds(),
+	}
+}
+
+// NewQueueStateHandler creates a handler serving the state of the breaker as
+// JSON. It's cheap enough to be polled by every client of the pod.
+func NewQueueStateHandler(b *Breaker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(b.State())
+	})
+}
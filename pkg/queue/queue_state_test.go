@@ -0,0 +1,41 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueueStateHandler(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 2, InitialCapacity: 2})
+	release, _ := b.Reserve(context.Background())
+	defer release()
+
+	rec := httptest.NewRecorder()
+	NewQueueStateHandler(b).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, QueueStatePath, nil))
+	var got QueueState
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal("Failed to decode the queue state:", err)
+	}
+	if want := (QueueState{InFlight: 1, Capacity: 2}); got != want {
+		t.Errorf("Queue state = %+v, want: %+v", got, want)
+	}
+}
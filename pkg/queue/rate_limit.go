@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"golang.org/x/time/rate"
+
+	network "knative.dev/networking/pkg"
+	pkgmetrics "knative.dev/pkg/metrics"
+	"knative.dev/serving/pkg/metrics"
+)
+
+var rateLimitedRequestCountM = stats.Int64(
+	"rate_limited_request_count",
+	"The number of requests rejected by the queue-proxy rate limiter",
+	stats.UnitDimensionless)
+
+type rateLimitHandler struct {
+	next     http.Handler
+	limiter  *rate.Limiter
+	statsCtx context.Context
+}
+
+// NewRateLimitHandler creates an http.Handler that admits at most rps requests
+// per second to next, allowing bursts of up to burst requests. Requests in excess
+// of that rate are rejected with a 429 and a Retry-After header. The limit is
+// enforced independently of the Breaker, so the two compose. A rate of zero or
+// less disables rate limiting and next is returned unchanged.
+func NewRateLimitHandler(next http.Handler, rps float64, burst int,
+	ns, service, config, rev, pod string) (http.Handler, error) {
+	if rps <= 0 {
+		return next, nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	if err := pkgmetrics.RegisterResourceView(&view.View{
+		Description: "The number of requests rejected by the queue-proxy rate limiter",
+		Measure:     rateLimitedRequestCountM,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey},
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx, err := metrics.PodRevisionContext(pod, "queue-proxy", ns, service, config, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rateLimitHandler{
+		next:     next,
+		limiter:  rate.NewLimiter(rate.Limit(rps), burst),
+		statsCtx: ctx,
+	}, nil
+}
+
+func (h *rateLimitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if network.IsKubeletProbe(r) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	now := time.Now()
+	res := h.limiter.ReserveN(now, 1)
+	if delay := res.DelayFrom(now); delay > 0 {
+		// We don't wait for the token, so give it back for the next request.
+		res.CancelAt(now)
+		pkgmetrics.Record(h.statsCtx, rateLimitedRequestCountM.M(1))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(delay)))
+		http.Error(w, "request rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+// retryAfterSeconds rounds the given delay up to whole seconds, which is the
+// granularity of the Retry-After header.
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Max(1, math.Ceil(d.Seconds())))
+}
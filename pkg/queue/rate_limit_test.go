@@ -0,0 +1,125 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	network "knative.dev/networking/pkg"
+	"knative.dev/pkg/metrics/metricstest"
+	_ "knative.dev/pkg/metrics/testing"
+	"knative.dev/serving/pkg/metrics"
+)
+
+func TestRateLimitHandlerDisabled(t *testing.T) {
+	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h, err := NewRateLimitHandler(baseHandler, 0 /*rps*/, 10 /*burst*/, "ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("Failed to create handler:", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, targetURI, nil))
+		if got, want := rec.Code, http.StatusOK; got != want {
+			t.Fatalf("Code = %d, want: %d", got, want)
+		}
+	}
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	t.Cleanup(func() { metricstest.Unregister(rateLimitedRequestCountM.Name()) })
+
+	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	// A rate of one request per second makes it impossible for a token to be
+	// refilled while the requests below are being sent.
+	h, err := NewRateLimitHandler(baseHandler, 1 /*rps*/, 3 /*burst*/, "ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("Failed to create handler:", err)
+	}
+
+	codes := map[int]int{}
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, targetURI, nil))
+		codes[rec.Code]++
+
+		if rec.Code == http.StatusTooManyRequests {
+			ra, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+			if err != nil {
+				t.Fatal("Failed to parse Retry-After:", err)
+			}
+			if ra < 1 {
+				t.Errorf("Retry-After = %d, want >= 1", ra)
+			}
+		}
+	}
+
+	if got, want := codes[http.StatusOK], 3; got != want {
+		t.Errorf("#200 = %d, want: %d", got, want)
+	}
+	if got, want := codes[http.StatusTooManyRequests], 7; got != want {
+		t.Errorf("#429 = %d, want: %d", got, want)
+	}
+
+	metricstest.AssertMetric(t, metricstest.IntMetric("rate_limited_request_count", 7, map[string]string{
+		metrics.LabelPodName:       "pod",
+		metrics.LabelContainerName: "queue-proxy",
+	}))
+
+	// Kubelet probes are never rate limited.
+	req := httptest.NewRequest(http.MethodGet, targetURI, nil)
+	req.Header.Set(network.KubeletProbeHeaderName, "1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("Probe code = %d, want: %d", got, want)
+	}
+}
+
+func TestRateLimitHandlerRefill(t *testing.T) {
+	t.Cleanup(func() { metricstest.Unregister(rateLimitedRequestCountM.Name()) })
+
+	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h, err := NewRateLimitHandler(baseHandler, 10 /*rps*/, 1 /*burst*/, "ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("Failed to create handler:", err)
+	}
+
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, targetURI, nil))
+		return rec.Code
+	}
+
+	if got, want := serve(), http.StatusOK; got != want {
+		t.Fatalf("Code = %d, want: %d", got, want)
+	}
+	if got, want := serve(), http.StatusTooManyRequests; got != want {
+		t.Fatalf("Code = %d, want: %d", got, want)
+	}
+
+	// After waiting for more than 1/rps we must be admitted again.
+	time.Sleep(150 * time.Millisecond)
+	if got, want := serve(), http.StatusOK; got != want {
+		t.Fatalf("Code after refill = %d, want: %d", got, want)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package readiness
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// waitPollInterval is the interval between the probes of a container waited
+// on by WaitReady or WaitGone.
+const waitPollInterval = 100 * time.Millisecond
+
+// ContainerResult is the outcome of the last readiness probe of a container.
+type ContainerResult struct {
+	Ready bool `json:"ready"`
+	// Error is why the probe failed, if it did.
+	Error string `json:"error,omitempty"`
+	// Time is when the probe finished, zero if the container wasn't probed
+	// yet.
+	Time time.Time `json:"time"`
+}
+
+// AggregateProbe probes the readiness of the containers of a pod, the serving
+// container and the sidecars probed by the queue-proxy, and is ready only if
+// all of them are.
+type AggregateProbe struct {
+	probes map[string]*Probe
+
+	mu      sync.RWMutex
+	results map[string]ContainerResult
+}
+
+// NewAggregateProbe returns an AggregateProbe of the probes by container name.
+func NewAggregateProbe(probes map[string]*Probe) *AggregateProbe {
+	results := make(map[string]ContainerResult, len(probes))
+	for name := range probes {
+		results[name] = ContainerResult{Error: "not probed yet"}
+	}
+	return &AggregateProbe{
+		probes:  probes,
+		results: results,
+	}
+}
+
+// checked is the outcome of the probe of the named container.
+type checked struct {
+	name string
+	err  error
+}
+
+// ProbeContainer executes the probes of all the containers at once, returning
+// whether all of them succeeded.
+func (a *AggregateProbe) ProbeContainer() bool {
+	results := make(chan checked, len(a.probes))
+	for name, p := range a.probes {
+		name, p := name, p
+		go func() {
+			results <- checked{name: name, err: p.Check()}
+		}()
+	}
+
+	ready := true
+	for range a.probes {
+		res := <-results
+		r := ContainerResult{Ready: res.err == nil, Time: time.Now()}
+		if res.err != nil {
+			r.Error = res.err.Error()
+			ready = false
+		}
+		a.mu.Lock()
+		a.results[res.name] = r
+		a.mu.Unlock()
+	}
+	return ready
+}
+
+// ObserveRequest reports the outcome of a request proxied to the named
+// container to its probe, see Probe.ObserveRequest.
+func (a *AggregateProbe) ObserveRequest(name string, err error) {
+	if p, ok := a.probes[name]; ok {
+		p.ObserveRequest(err)
+	}
+}
+
+// Results returns the outcome of the last probe of each container by name.
+func (a *AggregateProbe) Results() map[string]ContainerResult {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	results := make(map[string]ContainerResult, len(a.results))
+	for name, r := range a.results {
+		results[name] = r
+	}
+	return results
+}
+
+// WaitReady blocks until the probe of the named container succeeds or ctx is
+// done. It returns right away for containers without a probe.
+func (a *AggregateProbe) WaitReady(ctx context.Context, name string) error {
+	return a.wait(ctx, name, true)
+}
+
+// WaitGone blocks until the probe of the named container fails or ctx is
+// done. It returns right away for containers without a probe.
+func (a *AggregateProbe) WaitGone(ctx context.Context, name string) error {
+	return a.wait(ctx, name, false)
+}
+
+func (a *AggregateProbe) wait(ctx context.Context, name string, ready bool) error {
+	p, ok := a.probes[name]
+	if !ok {
+		return nil
+	}
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	for (p.Check() == nil) != ready {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// ServeHTTP responds with the Results as JSON, without probing.
+func (a *AggregateProbe) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.Results())
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package readiness
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func newAggregateTestProbe(t *testing.T, ready *atomic.Bool) *Probe {
+	tsURL := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	p := NewProbe(&corev1.Probe{
+		PeriodSeconds:    1,
+		TimeoutSeconds:   1,
+		SuccessThreshold: 1,
+		FailureThreshold: 1,
+		Handler: corev1.Handler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Scheme: "http",
+				Host:   tsURL.Hostname(),
+				Port:   intstr.FromString(tsURL.Port()),
+			},
+		},
+	})
+	p.out = ioutil.Discard
+	return p
+}
+
+func TestAggregateProbe(t *testing.T) {
+	userReady, sidecarReady := atomic.NewBool(true), atomic.NewBool(false)
+	a := NewAggregateProbe(map[string]*Probe{
+		"user-container": newAggregateTestProbe(t, userReady),
+		"sidecar":        newAggregateTestProbe(t, sidecarReady),
+	})
+
+	for name, r := range a.Results() {
+		if r.Ready || !r.Time.IsZero() {
+			t.Errorf("Result of %s = %+v before probing, want not ready", name, r)
+		}
+	}
+
+	if a.ProbeContainer() {
+		t.Error("ProbeContainer() = true, want false with a sidecar not ready")
+	}
+	results := a.Results()
+	if r := results["user-container"]; !r.Ready || r.Error != "" || r.Time.IsZero() {
+		t.Errorf("Result of user-container = %+v, want ready", r)
+	}
+	if r := results["sidecar"]; r.Ready || r.Error == "" {
+		t.Errorf("Result of sidecar = %+v, want not ready with an error", r)
+	}
+
+	sidecarReady.Store(true)
+	if !a.ProbeContainer() {
+		t.Error("ProbeContainer() = false, want true with all containers ready")
+	}
+
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/readiness", nil))
+	var got map[string]ContainerResult
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal("Failed to decode the results:", err)
+	}
+	for _, name := range []string{"user-container", "sidecar"} {
+		if !got[name].Ready {
+			t.Errorf("Served result of %s = %+v, want ready", name, got[name])
+		}
+	}
+}
+
+func TestAggregateProbeWait(t *testing.T) {
+	sidecarReady := atomic.NewBool(false)
+	a := NewAggregateProbe(map[string]*Probe{
+		"sidecar": newAggregateTestProbe(t, sidecarReady),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := a.WaitReady(ctx, "sidecar"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitReady() = %v, want a deadline exceeded with the sidecar not ready", err)
+	}
+
+	time.AfterFunc(200*time.Millisecond, func() { sidecarReady.Store(true) })
+	if err := a.WaitReady(context.Background(), "sidecar"); err != nil {
+		t.Error("WaitReady() =", err)
+	}
+
+	time.AfterFunc(200*time.Millisecond, func() { sidecarReady.Store(false) })
+	if err := a.WaitGone(context.Background(), "sidecar"); err != nil {
+		t.Error("WaitGone() =", err)
+	}
+
+	if err := a.WaitReady(context.Background(), "unprobed"); err != nil {
+		t.Error("WaitReady() of a container without a probe =", err)
+	}
+}
@@ -17,12 +17,14 @@ limitations under the License.
 package readiness
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"sync"
 	"time"
 
+	"go.uber.org/atomic"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"knative.dev/serving/pkg/queue/health"
@@ -34,6 +36,10 @@ const (
 	// advantage of the full window
 	PollTimeout   = 10 * time.Second
 	retryInterval = 50 * time.Millisecond
+	// defaultFailureThreshold is the number of requests in a row that have to
+	// fail for a passive probe to probe again, if the probe doesn't say, as
+	// Kubernetes defaults it.
+	defaultFailureThreshold = 3
 )
 
 // Probe wraps a corev1.Probe along with a count of consecutive, successful probes
@@ -43,6 +49,20 @@ type Probe struct {
 	pollTimeout     time.Duration // To make tests not run for 10 seconds.
 	out             io.Writer     // To make tests not log errors in good cases.
 	autoDetectHTTP2 bool          // Feature gate to enable HTTP2 auto-detection.
+	// grpc is set if the TCPSocket action is made by the gRPC Health Checking
+	// Protocol, checking grpcService.
+	grpc        bool
+	grpcService string
+	// unixSocket is the path of the Unix domain socket the probes connect to
+	// instead of the host and port of their action, if set.
+	unixSocket string
+	// passive is set if the container is only probed until the probe first
+	// succeeds, the outcomes of the requests telling whether it's ready
+	// after. established is set while they do, failures being the number of
+	// requests in a row that failed to reach the container.
+	passive     bool
+	established atomic.Bool
+	failures    atomic.Int32
 
 	// Barrier sync to ensure only one probe is happening at the same time.
 	// When a probe is active `gv` will be non-nil.
@@ -51,10 +71,10 @@ type Probe struct {
 	gv *gateValue
 }
 
-// gateValue is a write-once boolean impl.
+// gateValue is a write-once error impl.
 type gateValue struct {
 	broadcast chan struct{}
-	result    bool
+	result    error
 }
 
 // newGV returns a gateValue which is ready to write.
@@ -66,13 +86,13 @@ func newGV() *gateValue {
 
 // only `writer` must call `write` to set the value.
 // `write` will panic if called more than once.
-func (gv *gateValue) write(val bool) {
+func (gv *gateValue) write(val error) {
 	gv.result = val
 	close(gv.broadcast)
 }
 
 // `read` can be called multiple times.
-func (gv *gateValue) read() bool {
+func (gv *gateValue) read() error {
 	<-gv.broadcast
 	return gv.result
 }
@@ -97,6 +117,55 @@ func NewProbeWithHTTP2AutoDetection(v1p *corev1.Probe) *Probe {
 	}
 }
 
+// NewGRPCProbe returns a pointer to a new Probe that checks the port of its
+// TCPSocket action by the gRPC Health Checking Protocol, for the given service
+// or the server as a whole if empty.
+func NewGRPCProbe(v1p *corev1.Probe, service string) *Probe {
+	return &Probe{
+		Probe:       v1p,
+		pollTimeout: PollTimeout,
+		out:         os.Stderr,
+		grpc:        true,
+		grpcService: service,
+	}
+}
+
+// UseUnixSocket makes the probe connect to the user container over the Unix
+// domain socket at path instead of the host and port of its action. It must
+// be called before probing.
+func (p *Probe) UseUnixSocket(path string) {
+	p.unixSocket = path
+}
+
+// UsePassiveChecks makes the probe only probe the container until the probe
+// first succeeds. The container is then taken to be ready until
+// FailureThreshold requests in a row failed to reach it, as reported to
+// ObserveRequest, after which it's probed again until the probe passes. It
+// must be called before probing.
+func (p *Probe) UsePassiveChecks() {
+	p.passive = true
+}
+
+// ObserveRequest reports the outcome of a request proxied to the container,
+// err being why it failed to reach the container, if it did. It's a no-op
+// unless the probe uses passive checks.
+func (p *Probe) ObserveRequest(err error) {
+	if !p.passive {
+		return
+	}
+	if err == nil {
+		p.failures.Store(0)
+		return
+	}
+	threshold := p.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	if failures := p.failures.Inc(); failures >= threshold && p.established.CAS(true, false) {
+		fmt.Fprintf(p.out, "%d requests in a row failed, probing again: %v\n", failures, err)
+	}
+}
+
 // shouldProbeAggressively indicates whether the Knative probe with aggressive retries should be used.
 func (p *Probe) shouldProbeAggressively() bool {
 	return p.PeriodSeconds == 0
@@ -104,6 +173,16 @@ func (p *Probe) shouldProbeAggressively() bool {
 
 // ProbeContainer executes the defined Probe against the user-container
 func (p *Probe) ProbeContainer() bool {
+	return p.Check() == nil
+}
+
+// Check executes the defined Probe against the user-container, returning why
+// it failed, if it did.
+func (p *Probe) Check() error {
+	if p.passive && p.established.Load() {
+		return nil
+	}
+
 	gv, writer := func() (*gateValue, bool) {
 		p.mu.Lock()
 		defer p.mu.Unlock()
@@ -117,6 +196,10 @@ func (p *Probe) ProbeContainer() bool {
 
 	if writer {
 		res := p.probeContainerImpl()
+		if p.passive && res == nil {
+			p.failures.Store(0)
+			p.established.Store(true)
+		}
 		gv.write(res)
 		p.mu.Lock()
 		defer p.mu.Unlock()
@@ -126,32 +209,29 @@ func (p *Probe) ProbeContainer() bool {
 	return gv.read()
 }
 
-func (p *Probe) probeContainerImpl() bool {
+func (p *Probe) probeContainerImpl() error {
 	var err error
 
 	switch {
 	case p.HTTPGet != nil:
 		err = p.httpProbe()
+	case p.TCPSocket != nil && p.grpc:
+		err = p.grpcProbe()
 	case p.TCPSocket != nil:
 		err = p.tcpProbe()
 	case p.Exec != nil:
 		// Should never be reachable. Exec probes to be translated to
 		// TCP probes when container is built.
-		// Using Fprintf for a concise error message in the event log.
-		fmt.Fprintln(p.out, "exec probe not supported")
-		return false
+		err = errors.New("exec probe not supported")
 	default:
-		// Using Fprintf for a concise error message in the event log.
-		fmt.Fprintln(p.out, "no probe found")
-		return false
+		err = errors.New("no probe found")
 	}
 
 	if err != nil {
 		// Using Fprintf for a concise error message in the event log.
 		fmt.Fprintln(p.out, err.Error())
-		return false
 	}
-	return true
+	return err
 }
 
 func (p *Probe) doProbe(probe func(time.Duration) error) error {
@@ -194,6 +274,9 @@ func (p *Probe) tcpProbe() error {
 	config := health.TCPProbeConfigOptions{
 		Address: p.TCPSocket.Host + ":" + p.TCPSocket.Port.String(),
 	}
+	if p.unixSocket != "" {
+		config.Network, config.Address = "unix", p.unixSocket
+	}
 
 	return p.doProbe(func(to time.Duration) error {
 		config.SocketTimeout = to
@@ -208,6 +291,7 @@ func (p *Probe) httpProbe() error {
 	config := health.HTTPProbeConfigOptions{
 		HTTPGetAction: p.HTTPGet,
 		MaxProtoMajor: 1,
+		UnixSocket:    p.unixSocket,
 	}
 	if p.autoDetectHTTP2 {
 		// A value of 0 indicates that the prober should find out which version is
@@ -220,3 +304,21 @@ func (p *Probe) httpProbe() error {
 		return health.HTTPProbe(config)
 	})
 }
+
+// grpcProbe function executes gRPC probe once if its standard probe
+// otherwise gRPC probe polls condition function which returns true
+// if the probe count is greater than success threshold and false if gRPC probe fails
+func (p *Probe) grpcProbe() error {
+	config := health.GRPCProbeConfigOptions{
+		Address: p.TCPSocket.Host + ":" + p.TCPSocket.Port.String(),
+		Service: p.grpcService,
+	}
+	if p.unixSocket != "" {
+		config.Address = "unix://" + p.unixSocket
+	}
+
+	return p.doProbe(func(to time.Duration) error {
+		config.Timeout = to
+		return health.GRPCProbe(config)
+	})
+}
@@ -19,6 +19,7 @@ package readiness
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
 )
@@ -44,3 +45,29 @@ func EncodeProbe(rp *corev1.Probe) (string, error) {
 	}
 	return string(probeJSON), nil
 }
+
+// DecodeProbes takes json serialised *corev1.Probes by container name and
+// returns them or an error.
+func DecodeProbes(jsonProbes string) (map[string]*corev1.Probe, error) {
+	probes := map[string]*corev1.Probe{}
+	if err := json.Unmarshal([]byte(jsonProbes), &probes); err != nil {
+		return nil, err
+	}
+	return probes, nil
+}
+
+// EncodeProbes takes *corev1.Probe objects by container name and returns
+// their marshalled JSON string and an error.
+func EncodeProbes(probes map[string]*corev1.Probe) (string, error) {
+	for name, p := range probes {
+		if p == nil {
+			return "", fmt.Errorf("cannot encode nil probe of %q", name)
+		}
+	}
+
+	probesJSON, err := json.Marshal(probes)
+	if err != nil {
+		return "", err
+	}
+	return string(probesJSON), nil
+}
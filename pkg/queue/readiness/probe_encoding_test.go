@@ -97,3 +97,39 @@ func TestEncodeNilProbe(t *testing.T) {
 		t.Error("Expected empty probe string; got", jsonProbe)
 	}
 }
+
+func TestEncodeDecodeProbes(t *testing.T) {
+	probes := map[string]*corev1.Probe{
+		"sidecar": {
+			Handler: corev1.Handler{
+				TCPSocket: &corev1.TCPSocketAction{
+					Host: "127.0.0.1",
+					Port: intstr.FromInt(9090),
+				},
+			},
+		},
+	}
+
+	jsonProbes, err := EncodeProbes(probes)
+	if err != nil {
+		t.Fatal("EncodeProbes() =", err)
+	}
+	if want := `{"sidecar":{"tcpSocket":{"port":9090,"host":"127.0.0.1"}}}`; jsonProbes != want {
+		t.Errorf("EncodeProbes() = %s, want %s", jsonProbes, want)
+	}
+
+	got, err := DecodeProbes(jsonProbes)
+	if err != nil {
+		t.Fatal("DecodeProbes() =", err)
+	}
+	if diff := cmp.Diff(probes, got); diff != "" {
+		t.Error("Probes differ (-want, +got):", diff)
+	}
+
+	if _, err := EncodeProbes(map[string]*corev1.Probe{"sidecar": nil}); err == nil {
+		t.Error("EncodeProbes() = nil, want an error encoding a nil probe")
+	}
+	if _, err := DecodeProbes(`["sidecar"]`); err == nil {
+		t.Error("DecodeProbes() = nil, want an error")
+	}
+}
@@ -24,12 +24,16 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"go.uber.org/atomic"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/google/go-cmp/cmp"
 	corev1 "k8s.io/api/core/v1"
@@ -197,6 +201,146 @@ func TestTCPSuccess(t *testing.T) {
 	}
 }
 
+func TestUnixSocketProbe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+
+	for _, handler := range []corev1.Handler{{
+		TCPSocket: &corev1.TCPSocketAction{
+			Host: "127.0.0.1",
+			Port: intstr.FromInt(12345),
+		},
+	}, {
+		HTTPGet: &corev1.HTTPGetAction{
+			Host:   "127.0.0.1",
+			Port:   intstr.FromInt(12345),
+			Scheme: corev1.URISchemeHTTP,
+		},
+	}} {
+		pb := NewProbe(&corev1.Probe{
+			PeriodSeconds:    1,
+			TimeoutSeconds:   2,
+			SuccessThreshold: 1,
+			FailureThreshold: 1,
+			Handler:          handler,
+		})
+		// Nothing listens on the port, but on the socket.
+		pb.UseUnixSocket(path)
+		if !pb.ProbeContainer() {
+			t.Error("Probe report failure. Expected success.")
+		}
+	}
+}
+
+func TestPassiveProbe(t *testing.T) {
+	var healthy, probed atomic.Bool
+	tsURL := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		probed.Store(true)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	pb := NewProbe(&corev1.Probe{
+		PeriodSeconds:    1,
+		TimeoutSeconds:   2,
+		SuccessThreshold: 1,
+		FailureThreshold: 2,
+		Handler: corev1.Handler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Host:   tsURL.Hostname(),
+				Port:   intstr.FromString(tsURL.Port()),
+				Scheme: corev1.URISchemeHTTP,
+			},
+		},
+	})
+	pb.UsePassiveChecks()
+	pb.out = &bytes.Buffer{}
+
+	if pb.ProbeContainer() {
+		t.Error("Probe reported success before the container passed the probe")
+	}
+	healthy.Store(true)
+	if !pb.ProbeContainer() {
+		t.Error("Probe reported failure. Expected success.")
+	}
+
+	// Once established, the container is no longer probed.
+	healthy.Store(false)
+	probed.Store(false)
+	requestErr := errors.New("connection refused")
+	pb.ObserveRequest(requestErr)
+	pb.ObserveRequest(nil)
+	pb.ObserveRequest(requestErr)
+	if !pb.ProbeContainer() {
+		t.Error("Probe reported failure before FailureThreshold requests in a row failed")
+	}
+	if probed.Load() {
+		t.Error("Container was probed while the requests succeeded")
+	}
+
+	// Then it's probed again until it passes.
+	pb.ObserveRequest(requestErr)
+	if pb.ProbeContainer() {
+		t.Error("Probe reported success after FailureThreshold requests in a row failed")
+	}
+	if !probed.Load() {
+		t.Error("Container wasn't probed after the requests failed")
+	}
+	healthy.Store(true)
+	if !pb.ProbeContainer() {
+		t.Error("Probe reported failure. Expected success.")
+	}
+}
+
+func TestGRPCProbe(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	server := grpc.NewServer()
+	healthServer := grpchealth.NewServer()
+	healthServer.SetServingStatus("down", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	probe := func(service string) *Probe {
+		return NewGRPCProbe(&corev1.Probe{
+			PeriodSeconds:    1,
+			TimeoutSeconds:   2,
+			SuccessThreshold: 1,
+			FailureThreshold: 1,
+			Handler: corev1.Handler{
+				TCPSocket: &corev1.TCPSocketAction{
+					Host: "127.0.0.1",
+					Port: intstr.FromString(port),
+				},
+			},
+		}, service)
+	}
+
+	if !probe("").ProbeContainer() {
+		t.Error("Probe report failure. Expected success.")
+	}
+	pb := probe("down")
+	var buf bytes.Buffer
+	pb.out = &buf
+	if pb.ProbeContainer() {
+		t.Error("Reported success for a service not serving")
+	}
+	if !strings.Contains(buf.String(), "NOT_SERVING") {
+		t.Errorf("Probe output = %q, want the status", buf.String())
+	}
+}
+
 func TestHTTPFailureToConnect(t *testing.T) {
 	pb := NewProbe(&corev1.Probe{
 		PeriodSeconds:    1,
@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.uber.org/atomic"
+
+	pkgmetrics "knative.dev/pkg/metrics"
+	"knative.dev/pkg/websocket"
+	"knative.dev/serving/pkg/metrics"
+)
+
+var requestBodyTooLargeCountM = stats.Int64(
+	"request_body_too_large_count",
+	"The number of requests rejected by the queue-proxy as their body was too large",
+	stats.UnitDimensionless)
+
+var errRequestBodyTooLarge = errors.New("request body too large")
+
+type requestBodyLimitHandler struct {
+	next     http.Handler
+	limit    int64
+	statsCtx context.Context
+}
+
+// NewRequestBodyLimitHandler creates an http.Handler that rejects requests
+// with a body of more than limit bytes with a 413. Requests announcing a
+// larger Content-Length are rejected right away, streamed bodies are counted
+// as they are read and cut off once they exceed the limit, in which case the
+// user container sees the request fail. A limit of zero or less disables the
+// check and next is returned unchanged.
+func NewRequestBodyLimitHandler(next http.Handler, limit int64,
+	ns, service, config, rev, pod string) (http.Handler, error) {
+	if limit <= 0 {
+		return next, nil
+	}
+
+	if err := pkgmetrics.RegisterResourceView(&view.View{
+		Description: "The number of requests rejected by the queue-proxy as their body was too large",
+		Measure:     requestBodyTooLargeCountM,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey},
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx, err := metrics.PodRevisionContext(pod, "queue-proxy", ns, service, config, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	return &requestBodyLimitHandler{
+		next:     next,
+		limit:    limit,
+		statsCtx: ctx,
+	}, nil
+}
+
+func (h *requestBodyLimitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > h.limit {
+		pkgmetrics.Record(h.statsCtx, requestBodyTooLargeCountM.M(1))
+		rejectRequestBody(w)
+		return
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	body := &limitedBody{ReadCloser: r.Body, remaining: h.limit}
+	r.Body = body
+	h.next.ServeHTTP(&bodyLimitResponseWriter{ResponseWriter: w, body: body}, r)
+	if body.exceeded.Load() {
+		pkgmetrics.Record(h.statsCtx, requestBodyTooLargeCountM.M(1))
+	}
+}
+
+// rejectRequestBody responds with a 413. The connection is closed, as the
+// rest of the body isn't read.
+func rejectRequestBody(w http.ResponseWriter) {
+	w.Header().Set("Connection", "close")
+	http.Error(w, errRequestBodyTooLarge.Error(), http.StatusRequestEntityTooLarge)
+}
+
+// limitedBody is a request body failing with errRequestBodyTooLarge once
+// more than remaining bytes are read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	// exceeded is set once the body exceeded the limit. It's read by the
+	// response writer, while the body may be read by the transport.
+	exceeded atomic.Bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded.Load() {
+		return 0, errRequestBodyTooLarge
+	}
+	// Read a byte beyond the limit, to tell a body of exactly the limit from
+	// a larger one.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		b.exceeded.Store(true)
+		return n, errRequestBodyTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// bodyLimitResponseWriter replaces the response to a request whose body
+// exceeded the limit with a 413, unless the response has been started
+// already. The handlers reading the body typically fail the request with a
+// 400 or 502 in that case.
+type bodyLimitResponseWriter struct {
+	http.ResponseWriter
+	body *limitedBody
+
+	wroteHeader bool
+	// rejected is set if the response was replaced, writes are discarded.
+	rejected bool
+}
+
+var (
+	_ http.Flusher  = (*bodyLimitResponseWriter)(nil)
+	_ http.Hijacker = (*bodyLimitResponseWriter)(nil)
+)
+
+// WriteHeader implements http.ResponseWriter.
+func (w *bodyLimitResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	// Informational responses aren't final.
+	if code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader = true
+	if w.body.exceeded.Load() {
+		w.rejected = true
+		for k := range w.Header() {
+			delete(w.Header(), k)
+		}
+		rejectRequestBody(w.ResponseWriter)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *bodyLimitResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.rejected {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher.
+func (w *bodyLimitResponseWriter) Flush() {
+	if w.rejected {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (w *bodyLimitResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return websocket.HijackIfPossible(w.ResponseWriter)
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"knative.dev/pkg/metrics/metricstest"
+	_ "knative.dev/pkg/metrics/testing"
+	"knative.dev/serving/pkg/metrics"
+)
+
+func TestRequestBodyLimitHandlerDisabled(t *testing.T) {
+	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h, err := NewRequestBodyLimitHandler(baseHandler, 0 /*limit*/, "ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("Failed to create handler:", err)
+	}
+	if _, ok := h.(http.HandlerFunc); !ok {
+		t.Errorf("Handler = %T, want the handler passed in", h)
+	}
+}
+
+func TestRequestBodyLimitHandler(t *testing.T) {
+	t.Cleanup(func() { metricstest.Unregister(requestBodyTooLargeCountM.Name()) })
+
+	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail like the proxy does if the body can't be read.
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write(b)
+	})
+	h, err := NewRequestBodyLimitHandler(baseHandler, 5 /*limit*/, "ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("Failed to create handler:", err)
+	}
+
+	tests := []struct {
+		name          string
+		body          io.Reader
+		contentLength int64
+		wantCode      int
+		wantBody      string
+	}{{
+		name:          "no body",
+		contentLength: 0,
+		wantCode:      http.StatusOK,
+	}, {
+		name:          "below the limit",
+		body:          strings.NewReader("abc"),
+		contentLength: 3,
+		wantCode:      http.StatusOK,
+		wantBody:      "abc",
+	}, {
+		name:          "at the limit, streamed",
+		body:          strings.NewReader("abcde"),
+		contentLength: -1,
+		wantCode:      http.StatusOK,
+		wantBody:      "abcde",
+	}, {
+		name:          "content length above the limit",
+		body:          strings.NewReader("abcdef"),
+		contentLength: 6,
+		wantCode:      http.StatusRequestEntityTooLarge,
+		wantBody:      "request body too large\n",
+	}, {
+		name:          "streamed above the limit",
+		body:          strings.NewReader("abcdefgh"),
+		contentLength: -1,
+		wantCode:      http.StatusRequestEntityTooLarge,
+		wantBody:      "request body too large\n",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, targetURI, test.body)
+			req.ContentLength = test.contentLength
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if got, want := rec.Code, test.wantCode; got != want {
+				t.Errorf("Code = %d, want: %d", got, want)
+			}
+			if got, want := rec.Body.String(), test.wantBody; got != want {
+				t.Errorf("Body = %q, want: %q", got, want)
+			}
+		})
+	}
+
+	metricstest.AssertMetric(t, metricstest.IntMetric("request_body_too_large_count", 2, map[string]string{
+		metrics.LabelPodName:       "pod",
+		metrics.LabelContainerName: "queue-proxy",
+	}))
+}
+
+func TestRequestBodyLimitHandlerResponseStarted(t *testing.T) {
+	t.Cleanup(func() { metricstest.Unregister(requestBodyTooLargeCountM.Name()) })
+
+	// A response started before the body exceeded the limit is kept.
+	baseHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		if _, err := ioutil.ReadAll(r.Body); err != errRequestBodyTooLarge {
+			t.Errorf("ReadAll() = %v, want: %v", err, errRequestBodyTooLarge)
+		}
+	})
+	h, err := NewRequestBodyLimitHandler(baseHandler, 5 /*limit*/, "ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("Failed to create handler:", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, targetURI, strings.NewReader("abcdefgh"))
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusAccepted; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+}
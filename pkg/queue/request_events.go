@@ -0,0 +1,268 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"google.golang.org/protobuf/encoding/protowire"
+	pkghttp "knative.dev/serving/pkg/http"
+)
+
+const (
+	// otlpLogsGRPCMethod is the method of the OTLP logs service.
+	otlpLogsGRPCMethod = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+	// otlpLogsHTTPPath is the path of the OTLP logs service, appended to
+	// endpoints without a path.
+	otlpLogsHTTPPath = "/v1/logs"
+
+	// requestEventsMaxBatch is the most events exported at once. A full
+	// batch is exported right away.
+	requestEventsMaxBatch = 512
+	// requestEventsMaxPending bounds the events kept while the collector is
+	// slow or unreachable. The events beyond are dropped.
+	requestEventsMaxPending = 8 * requestEventsMaxBatch
+
+	// The SeverityNumbers of the events of the successful, the client error
+	// and the server error responses.
+	otlpSeverityInfo  = 9
+	otlpSeverityWarn  = 13
+	otlpSeverityError = 17
+)
+
+// requestEvent is what is exported of a request once it's done.
+type requestEvent struct {
+	time    time.Time
+	method  string
+	path    string
+	status  int
+	latency time.Duration
+	// span is the context of the request's span, if it has one.
+	span      trace.SpanContext
+	hasSpan   bool
+	coldStart bool
+}
+
+// RequestEventExporter ships a structured event per request to an
+// OpenTelemetry collector as OTLP logs, as an alternative to collecting the
+// access log from stdout. The events are batched, and rate limited so a
+// traffic spike doesn't swamp the collector: those over the limit are
+// dropped and counted.
+type RequestEventExporter struct {
+	// resource is the encoded OTLP resource.
+	resource []byte
+	send     func(ctx context.Context, body []byte) error
+	close    func() error
+	limiter  *rate.Limiter
+	logger   *zap.SugaredLogger
+
+	// served is whether the pod responded to a request already. The requests
+	// arriving before are cold starts.
+	served atomic.Bool
+
+	mu      sync.Mutex
+	pending []requestEvent
+	// dropped is the number of events dropped since the last export.
+	dropped int
+	// full is signaled once a batch is pending.
+	full chan struct{}
+}
+
+// NewRequestEventExporter creates an exporter of the request events to the
+// collector at endpoint, by protocol, with the given resource attributes,
+// which exports up to maxEventsPerSecond events, with bursts of a batch.
+func NewRequestEventExporter(endpoint string, protocol OTLPProtocol, resource map[string]string,
+	maxEventsPerSecond float64, logger *zap.SugaredLogger) (*RequestEventExporter, error) {
+	send, closeFn, err := newOTLPSender(endpoint, protocol, otlpLogsGRPCMethod, otlpLogsHTTPPath)
+	if err != nil {
+		return nil, err
+	}
+	return &RequestEventExporter{
+		resource: appendAttributes(nil, 1, resource),
+		send:     send,
+		close:    closeFn,
+		limiter:  rate.NewLimiter(rate.Limit(maxEventsPerSecond), requestEventsMaxBatch),
+		logger:   logger,
+		full:     make(chan struct{}, 1),
+	}, nil
+}
+
+// Handler returns a handler recording an event for every request passed on
+// to next. The events carry the trace ID of the request's span, so the
+// handler belongs inside the tracing middleware.
+func (e *RequestEventExporter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rr := pkghttp.NewResponseRecorder(w, http.StatusOK)
+		start := time.Now()
+		coldStart := !e.served.Load()
+		defer func() {
+			ev := requestEvent{
+				time:      start,
+				method:    r.Method,
+				path:      r.URL.Path,
+				status:    rr.ResponseCode,
+				latency:   time.Since(start),
+				coldStart: coldStart,
+			}
+			ev.span, ev.hasSpan = pkghttp.SpanContext(r)
+			e.served.Store(true)
+			// If ServeHTTP panics, record the failure and panic again.
+			if err := recover(); err != nil {
+				ev.status = http.StatusInternalServerError
+				e.record(ev)
+				panic(err)
+			}
+			e.record(ev)
+		}()
+		next.ServeHTTP(rr, r)
+	})
+}
+
+func (e *RequestEventExporter) record(ev requestEvent) {
+	allowed := e.limiter.Allow()
+
+	e.mu.Lock()
+	if !allowed || len(e.pending) >= requestEventsMaxPending {
+		e.dropped++
+		e.mu.Unlock()
+		return
+	}
+	e.pending = append(e.pending, ev)
+	full := len(e.pending) >= requestEventsMaxBatch
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run exports the pending events every interval, or as soon as a batch is
+// pending, until ctx is done, and a last time then, so the last events
+// aren't lost as the pod goes away.
+func (e *RequestEventExporter) Run(ctx context.Context, interval time.Duration) {
+	defer e.close()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.full:
+		case <-ctx.Done():
+			e.exportAndLog(context.Background())
+			return
+		}
+		e.exportAndLog(context.Background())
+	}
+}
+
+func (e *RequestEventExporter) exportAndLog(ctx context.Context) {
+	if err := e.Export(ctx); err != nil {
+		e.logger.Errorw("Failed to export request events by OTLP", zap.Error(err))
+	}
+}
+
+// Export exports the pending events, a batch at a time. The events of a
+// batch that failed to export are dropped.
+func (e *RequestEventExporter) Export(ctx context.Context) error {
+	e.mu.Lock()
+	events, dropped := e.pending, e.dropped
+	e.pending, e.dropped = nil, 0
+	e.mu.Unlock()
+
+	if dropped > 0 {
+		e.logger.Warnf("Dropped %d request events over the rate limit or while the collector was behind", dropped)
+	}
+	for len(events) > 0 {
+		n := len(events)
+		if n > requestEventsMaxBatch {
+			n = requestEventsMaxBatch
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, otlpExportTimeout)
+		err := e.send(sendCtx, e.encode(events[:n], time.Now()))
+		cancel()
+		if err != nil {
+			return err
+		}
+		events = events[n:]
+	}
+	return nil
+}
+
+// encode encodes the events as an ExportLogsServiceRequest.
+func (e *RequestEventExporter) encode(events []requestEvent, now time.Time) []byte {
+	observed := uint64(now.UnixNano())
+
+	// ScopeLogs.scope, with InstrumentationScope.name
+	scopeLogs := appendMessage(nil, 1, appendString(nil, 1, otlpScopeName))
+	for i := range events {
+		// ScopeLogs.log_records
+		scopeLogs = appendMessage(scopeLogs, 2, logRecord(&events[i], observed))
+	}
+
+	// ResourceLogs.resource and .scope_logs
+	resourceLogs := appendMessage(nil, 1, e.resource)
+	resourceLogs = appendMessage(resourceLogs, 2, scopeLogs)
+	// ExportLogsServiceRequest.resource_logs
+	return appendMessage(nil, 1, resourceLogs)
+}
+
+// logRecord encodes the event as a LogRecord, with the attributes named by
+// the OpenTelemetry semantic conventions for HTTP.
+func logRecord(ev *requestEvent, observed uint64) []byte {
+	severity, severityText := uint64(otlpSeverityInfo), "INFO"
+	switch {
+	case ev.status >= 500:
+		severity, severityText = otlpSeverityError, "ERROR"
+	case ev.status >= 400:
+		severity, severityText = otlpSeverityWarn, "WARN"
+	}
+
+	// LogRecord.time_unix_nano, .severity_number and .severity_text
+	r := appendFixed64(nil, 1, uint64(ev.time.UnixNano()))
+	r = appendVarint(r, 2, severity)
+	r = appendString(r, 3, severityText)
+	// LogRecord.body, with AnyValue.string_value
+	r = appendMessage(r, 5, appendString(nil, 1, ev.method+" "+ev.path+" "+strconv.Itoa(ev.status)))
+	// LogRecord.attributes, with AnyValue.string_value, .bool_value,
+	// .int_value and .double_value
+	r = appendKeyValue(r, 6, "http.request.method", appendString(nil, 1, ev.method))
+	r = appendKeyValue(r, 6, "url.path", appendString(nil, 1, ev.path))
+	r = appendKeyValue(r, 6, "http.response.status_code", appendVarint(nil, 3, uint64(ev.status)))
+	r = appendKeyValue(r, 6, "http.server.request.duration", appendFixed64(nil, 4, math.Float64bits(ev.latency.Seconds())))
+	r = appendKeyValue(r, 6, "knative.cold_start", appendVarint(nil, 2, protowire.EncodeBool(ev.coldStart)))
+	if ev.hasSpan {
+		// LogRecord.flags, .trace_id and .span_id
+		r = protowire.AppendTag(r, 8, protowire.Fixed32Type)
+		r = protowire.AppendFixed32(r, uint32(ev.span.TraceOptions))
+		r = appendMessage(r, 9, ev.span.TraceID[:])
+		r = appendMessage(r, 10, ev.span.SpanID[:])
+	}
+	// LogRecord.observed_time_unix_nano
+	return appendFixed64(r, 11, observed)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protowire"
+	pkglogging "knative.dev/pkg/logging/testing"
+)
+
+// otlpLogRecords decodes an ExportLogsServiceRequest into its resource
+// attributes and its log records' fields.
+func otlpLogRecords(t *testing.T, body []byte) (map[string]string, []map[protowire.Number][][]byte) {
+	t.Helper()
+	resourceLogs := otlpFields(t, otlpFields(t, body)[1][0])
+	resource := otlpAttributes(t, otlpFields(t, resourceLogs[1][0]), 1)
+	var records []map[protowire.Number][][]byte
+	for _, r := range otlpFields(t, resourceLogs[2][0])[2] {
+		records = append(records, otlpFields(t, r))
+	}
+	return resource, records
+}
+
+// otlpAnyValues decodes the KeyValues in field num of a message into the
+// encoded AnyValues by key.
+func otlpAnyValues(t *testing.T, fields map[protowire.Number][][]byte, num protowire.Number) map[string][]byte {
+	values := map[string][]byte{}
+	for _, kv := range fields[num] {
+		kvFields := otlpFields(t, kv)
+		values[string(kvFields[1][0])] = kvFields[2][0]
+	}
+	return values
+}
+
+func newRequestEventTestServer(t *testing.T) (*httptest.Server, chan []byte) {
+	bodies := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- body
+	}))
+	t.Cleanup(server.Close)
+	return server, bodies
+}
+
+func TestRequestEventExporter(t *testing.T) {
+	server, bodies := newRequestEventTestServer(t)
+	resource := OTLPResource(namespace, "svc", config, revision, otlpPod)
+	e, err := NewRequestEventExporter(server.URL, OTLPProtocolHTTP, resource, 100, pkglogging.TestLogger(t))
+	if err != nil {
+		t.Fatal("NewRequestEventExporter() =", err)
+	}
+
+	h := e.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/hello?name=world", nil)
+	req.Header.Set("traceparent", "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if err := e.Export(context.Background()); err != nil {
+		t.Fatal("Export() =", err)
+	}
+	gotResource, records := otlpLogRecords(t, <-bodies)
+	if got, want := gotResource["knative.revision.name"], revision; got != want {
+		t.Errorf("Revision = %q, want: %q", got, want)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Got %d log records, want 2", len(records))
+	}
+
+	first, second := records[0], records[1]
+	if got, want := string(otlpFields(t, first[5][0])[1][0]), "POST /hello 200"; got != want {
+		t.Errorf("Body = %q, want: %q", got, want)
+	}
+	if got, want := first[9], [][]byte{{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}}; !cmp.Equal(got, want) {
+		t.Errorf("TraceID = %v, want: %v", got, want)
+	}
+	if got, want := string(second[3][0]), "WARN"; got != want {
+		t.Errorf("Severity = %q, want: %q", got, want)
+	}
+	if _, ok := second[9]; ok {
+		t.Error("Got a trace ID without a trace context")
+	}
+
+	attrs := otlpAnyValues(t, first, 6)
+	if got, want := string(otlpFields(t, attrs["url.path"])[1][0]), "/hello"; got != want {
+		t.Errorf("url.path = %q, want: %q", got, want)
+	}
+	if _, ok := attrs["http.server.request.duration"]; !ok {
+		t.Error("No http.server.request.duration attribute")
+	}
+	status, _ := protowire.ConsumeVarint(attrs["http.response.status_code"][1:])
+	if status != http.StatusOK {
+		t.Errorf("http.response.status_code = %d, want: %d", status, http.StatusOK)
+	}
+	// Only the first request is a cold start.
+	for i, want := range []uint64{1, 0} {
+		v, _ := protowire.ConsumeVarint(otlpAnyValues(t, records[i], 6)["knative.cold_start"][1:])
+		if v != want {
+			t.Errorf("Request %d: knative.cold_start = %d, want: %d", i, v, want)
+		}
+	}
+}
+
+func TestRequestEventExporterRateLimit(t *testing.T) {
+	server, bodies := newRequestEventTestServer(t)
+	e, err := NewRequestEventExporter(server.URL, OTLPProtocolHTTP, nil, 1, pkglogging.TestLogger(t))
+	if err != nil {
+		t.Fatal("NewRequestEventExporter() =", err)
+	}
+	h := e.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	// The burst is a batch; the events beyond are dropped.
+	for i := 0; i < requestEventsMaxBatch+10; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if got := e.dropped; got < 9 {
+		t.Errorf("Dropped %d events, want at least 9", got)
+	}
+
+	// A full batch is exported right away.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx, time.Hour)
+		close(done)
+	}()
+	_, records := otlpLogRecords(t, <-bodies)
+	cancel()
+	<-done
+	if len(records) > requestEventsMaxBatch {
+		t.Errorf("Got %d log records, want at most %d", len(records), requestEventsMaxBatch)
+	}
+}
@@ -117,18 +117,21 @@ func (h *requestMetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		// If ServeHTTP panics, recover, record the failure and panic again.
 		err := recover()
 		latency := time.Since(startTime)
+		// The latencies of the sampled requests link to their traces.
+		sc, _ := metrics.SampledSpanContext(r.Context())
+		exemplar := metrics.ExemplarOptions(sc)
 		routeTag := GetRouteTagNameFromRequest(r)
 		if err != nil {
 			ctx := metrics.AugmentWithResponseAndRouteTag(h.statsCtx,
 				http.StatusInternalServerError, routeTag)
-			pkgmetrics.RecordBatch(ctx, requestCountM.M(1),
-				responseTimeInMsecM.M(float64(latency.Milliseconds())))
+			pkgmetrics.Record(ctx, requestCountM.M(1))
+			pkgmetrics.Record(ctx, responseTimeInMsecM.M(float64(latency.Milliseconds())), exemplar...)
 			panic(err)
 		}
 		ctx := metrics.AugmentWithResponseAndRouteTag(h.statsCtx,
 			rr.ResponseCode, routeTag)
-		pkgmetrics.RecordBatch(ctx, requestCountM.M(1),
-			responseTimeInMsecM.M(float64(latency.Milliseconds())))
+		pkgmetrics.Record(ctx, requestCountM.M(1))
+		pkgmetrics.Record(ctx, responseTimeInMsecM.M(float64(latency.Milliseconds())), exemplar...)
 	}()
 
 	h.next.ServeHTTP(rr, r)
@@ -185,16 +188,18 @@ func (h *appRequestMetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Requ
 		// If ServeHTTP panics, recover, record the failure and panic again.
 		err := recover()
 		latency := time.Since(startTime)
+		sc, _ := metrics.SampledSpanContext(r.Context())
+		exemplar := metrics.ExemplarOptions(sc)
 		if err != nil {
 			ctx := metrics.AugmentWithResponse(h.statsCtx, http.StatusInternalServerError)
-			pkgmetrics.RecordBatch(ctx, appRequestCountM.M(1),
-				appResponseTimeInMsecM.M(float64(latency.Milliseconds())))
+			pkgmetrics.Record(ctx, appRequestCountM.M(1))
+			pkgmetrics.Record(ctx, appResponseTimeInMsecM.M(float64(latency.Milliseconds())), exemplar...)
 			panic(err)
 		}
 
 		ctx := metrics.AugmentWithResponse(h.statsCtx, rr.ResponseCode)
-		pkgmetrics.RecordBatch(ctx, appRequestCountM.M(1),
-			appResponseTimeInMsecM.M(float64(latency.Milliseconds())))
+		pkgmetrics.Record(ctx, appRequestCountM.M(1))
+		pkgmetrics.Record(ctx, appResponseTimeInMsecM.M(float64(latency.Milliseconds())), exemplar...)
 	}()
 	h.next.ServeHTTP(rr, r)
 }
@@ -203,10 +208,16 @@ const (
 	defaultTagName   = "DEFAULT"
 	undefinedTagName = "UNDEFINED"
 	disabledTagName  = "DISABLED"
+	mirroredTagName  = "MIRRORED"
 )
 
-// GetRouteTagNameFromRequest extracts the value of the tag header from http.Request
+// GetRouteTagNameFromRequest extracts the value of the tag header from http.Request.
+// The requests mirrored to a shadow Revision are tagged as such instead, so
+// they're told apart from its own traffic.
 func GetRouteTagNameFromRequest(r *http.Request) string {
+	if r.Header.Get(MirroredHeaderName) != "" {
+		return mirroredTagName
+	}
 	name := r.Header.Get(network.TagHeaderName)
 	isDefaultRoute := r.Header.Get(network.DefaultRouteHeaderName)
 
@@ -134,6 +134,14 @@ func TestRequestMetricsHandlerWithEnablingTagOnRequestMetrics(t *testing.T) {
 	handler.ServeHTTP(resp, req)
 	wantTags["route_tag"] = "test-tag"
 	metricstest.AssertMetric(t, metricstest.IntMetric("request_count", 1, wantTags).WithResource(wantResource))
+
+	// Testing for mirrored requests
+	reset()
+	handler, _ = NewRequestMetricsHandler(baseHandler, "ns", "svc", "cfg", "rev", "pod")
+	req.Header.Set(MirroredHeaderName, "true")
+	handler.ServeHTTP(resp, req)
+	wantTags["route_tag"] = mirroredTagName
+	metricstest.AssertMetric(t, metricstest.IntMetric("request_count", 1, wantTags).WithResource(wantResource))
 }
 
 func reset() {
@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pathRule assigns a value to the requests whose path starts with prefix.
+type pathRule struct {
+	prefix string
+	value  int
+}
+
+// requestRules derive a value in [min, max] for requests, e.g. their
+// priority, from a header carrying it or from rules matching their path.
+// Requests matching neither get min.
+type requestRules struct {
+	header   string
+	min, max int
+	// rules are sorted by descending length of prefix, so the longest
+	// matching prefix is found first.
+	rules []pathRule
+}
+
+// newRequestRules creates requestRules reading the value from header, if set,
+// and otherwise from rules, the JSON serialized values by path prefix.
+func newRequestRules(header, rules string, min, max int) (*requestRules, error) {
+	r := &requestRules{header: header, min: min, max: max}
+	if rules == "" {
+		return r, nil
+	}
+	var values map[string]int
+	if err := json.Unmarshal([]byte(rules), &values); err != nil {
+		return nil, err
+	}
+	for prefix, value := range values {
+		if value < min || value > max {
+			return nil, fmt.Errorf("value %d of %q is not in [%d, %d]", value, prefix, min, max)
+		}
+		r.rules = append(r.rules, pathRule{prefix: prefix, value: value})
+	}
+	sort.Slice(r.rules, func(i, j int) bool {
+		return len(r.rules[i].prefix) > len(r.rules[j].prefix)
+	})
+	return r, nil
+}
+
+// value returns the value of the request. Header values outside of
+// [min, max] are clamped, invalid ones are ignored.
+func (rr *requestRules) value(r *http.Request) int {
+	if rr.header != "" {
+		if v, err := strconv.Atoi(r.Header.Get(rr.header)); err == nil {
+			switch {
+			case v < rr.min:
+				return rr.min
+			case v > rr.max:
+				return rr.max
+			default:
+				return v
+			}
+		}
+	}
+	for _, rule := range rr.rules {
+		if strings.HasPrefix(r.URL.Path, rule.prefix) {
+			return rule.value
+		}
+	}
+	return rr.min
+}
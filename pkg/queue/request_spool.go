@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// spoolFilePattern is the pattern of the temporary files request bodies are
+// spooled to.
+const spoolFilePattern = "request-body-*"
+
+// NewRequestSpoolHandler creates a handler that reads request bodies in full
+// before passing the request on to next, for user containers that can't deal
+// with streamed uploads. Bodies of up to threshold bytes are buffered in memory,
+// larger ones are spooled to a temporary file in dir, so memory usage stays flat.
+// The file is removed once the request is done. An empty dir means the default
+// directory for temporary files. A threshold of zero or less disables spooling
+// and next is returned unchanged.
+func NewRequestSpoolHandler(next http.Handler, threshold int64, dir string, logger *zap.SugaredLogger) http.Handler {
+	if threshold <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body := &ctxReader{ctx: r.Context(), r: r.Body}
+		var buf bytes.Buffer
+		n, err := io.CopyN(&buf, body, threshold+1)
+		if err != nil && err != io.EOF {
+			failSpool(w, r, logger, err)
+			return
+		}
+		if n <= threshold {
+			data := buf.Bytes()
+			r.Body = io.NopCloser(bytes.NewReader(data))
+			r.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(data)), nil
+			}
+			r.ContentLength, r.TransferEncoding = n, nil
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		f, err := os.CreateTemp(dir, spoolFilePattern)
+		if err != nil {
+			logger.Errorw("Failed to create request spool file", zap.Error(err))
+			http.Error(w, "failed to spool request body", http.StatusInternalServerError)
+			return
+		}
+		defer func() {
+			f.Close()
+			if err := os.Remove(f.Name()); err != nil {
+				logger.Errorw("Failed to remove request spool file", zap.Error(err))
+			}
+		}()
+
+		size, err := io.Copy(f, io.MultiReader(&buf, body))
+		if err != nil {
+			failSpool(w, r, logger, err)
+			return
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			logger.Errorw("Failed to rewind request spool file", zap.Error(err))
+			http.Error(w, "failed to spool request body", http.StatusInternalServerError)
+			return
+		}
+		// The file is closed by the deferred cleanup, not by the consumer.
+		// Replays of the body, e.g. for retries, read the file independently.
+		r.Body = io.NopCloser(f)
+		r.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(io.NewSectionReader(f, 0, size)), nil
+		}
+		r.ContentLength, r.TransferEncoding = size, nil
+		next.ServeHTTP(w, r)
+	})
+}
+
+// failSpool responds to a request whose body couldn't be read. Nothing is
+// written if the request was cancelled, as nobody is waiting for the response.
+func failSpool(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, err error) {
+	if r.Context().Err() != nil {
+		return
+	}
+	logger.Debugw("Failed to read request body", zap.Error(err))
+	http.Error(w, "failed to read request body", http.StatusBadRequest)
+}
+
+// ctxReader is an io.Reader that fails once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func TestRequestSpoolHandler(t *testing.T) {
+	const threshold = 16
+	tests := []struct {
+		name        string
+		body        string
+		wantSpooled bool
+	}{{
+		name: "small body in memory",
+		body: "tiny",
+	}, {
+		name: "body at the threshold in memory",
+		body: strings.Repeat("a", threshold),
+	}, {
+		name:        "large body spooled",
+		body:        strings.Repeat("large body ", 1000),
+		wantSpooled: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			h := NewRequestSpoolHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got, want := r.ContentLength, int64(len(test.body)); got != want {
+					t.Errorf("ContentLength = %d, want: %d", got, want)
+				}
+				if got := spoolFiles(t, dir); got != 0 && !test.wantSpooled {
+					t.Errorf("Spool files = %d, want: 0", got)
+				} else if got != 1 && test.wantSpooled {
+					t.Errorf("Spool files = %d, want: 1", got)
+				}
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Error("ReadAll() =", err)
+				}
+				if string(b) != test.body {
+					t.Errorf("Body = %q, want: %q", b, test.body)
+				}
+
+				// The body can be replayed, e.g. for retries.
+				replay, err := r.GetBody()
+				if err != nil {
+					t.Fatal("GetBody() =", err)
+				}
+				if b, err = io.ReadAll(replay); err != nil {
+					t.Error("ReadAll() =", err)
+				}
+				if string(b) != test.body {
+					t.Errorf("Replayed body = %q, want: %q", b, test.body)
+				}
+			}), threshold, dir, logtesting.TestLogger(t))
+
+			req := httptest.NewRequest(http.MethodPost, targetURI, io.NopCloser(strings.NewReader(test.body)))
+			req.ContentLength = -1
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if got, want := rec.Code, http.StatusOK; got != want {
+				t.Errorf("Code = %d, want: %d", got, want)
+			}
+			if got := spoolFiles(t, dir); got != 0 {
+				t.Errorf("Spool files after the request = %d, want: 0", got)
+			}
+		})
+	}
+}
+
+func TestRequestSpoolHandlerCancelled(t *testing.T) {
+	dir := t.TempDir()
+	var called bool
+	h := NewRequestSpoolHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called = true
+	}), 16, dir, logtesting.TestLogger(t))
+
+	body, bodyWriter := io.Pipe()
+	defer bodyWriter.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, targetURI, body).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(rec, req)
+	}()
+
+	// Exceed the threshold, so the body is being spooled to disk.
+	chunk := []byte(strings.Repeat("a", 32))
+	bodyWriter.Write(chunk)
+	for spoolFiles(t, dir) == 0 {
+		bodyWriter.Write(chunk)
+	}
+
+	cancel()
+	// Unblock a read that started before the cancellation.
+	go bodyWriter.Write(chunk)
+	<-done
+
+	if called {
+		t.Error("Handler was called for a cancelled request")
+	}
+	if got := spoolFiles(t, dir); got != 0 {
+		t.Errorf("Spool files after the request = %d, want: 0", got)
+	}
+}
+
+func spoolFiles(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal("ReadDir() =", err)
+	}
+	return len(entries)
+}
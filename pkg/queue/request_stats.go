@@ -0,0 +1,177 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	network "knative.dev/networking/pkg"
+)
+
+// requestStatsShards is the number of shards the request events are spread
+// over, so concurrent requests rarely update the same counters.
+const (
+	requestStatsShardBits = 5
+	requestStatsShards    = 1 << requestStatsShardBits
+)
+
+// RequestStats collects the same statistics about the requests as
+// network.RequestStats, without taking a lock per request event, which every
+// request does twice. The events are added up by atomics in the shards of the
+// current reporting window, which are only folded into the stats as the
+// window is reported.
+type RequestStats struct {
+	// current is the *statsWindow the events are added to.
+	current atomic.Value
+
+	// mux serializes the reports. The fields below are only used by them.
+	mux sync.Mutex
+	// concurrency and proxiedConcurrency are the concurrencies as of the
+	// start of the current window.
+	concurrency, proxiedConcurrency int64
+}
+
+// statsWindow adds up the request events of a reporting window. The times of
+// the events are kept as offsets from the start of the window, so the sums
+// stay small, and their area, i.e. the time the requests spent in the window,
+// is computed at the end of the window:
+//
+//	area = concurrency at the end * length of the window - sum of the offsets
+//
+// with the offsets of the finished requests negated. The sums being linear,
+// each event can go to any shard.
+type statsWindow struct {
+	start  int64
+	shards [requestStatsShards]statsShard
+}
+
+type statsShard struct {
+	// writers is the number of events being added. The window is only
+	// reported once they're done.
+	writers atomic.Int64
+
+	// The number of requests that arrived and finished in the window.
+	in, out, proxiedIn, proxiedOut atomic.Int64
+	// The sums of the offsets of the events, in nanoseconds.
+	offsets, proxiedOffsets atomic.Int64
+
+	// Pads the shard to two cache lines, which are fetched together.
+	_ [128 - 7*8]byte
+}
+
+// NewRequestStats builds a RequestStats instance, started at the given time.
+func NewRequestStats(startedAt time.Time) *RequestStats {
+	s := &RequestStats{}
+	s.current.Store(&statsWindow{start: startedAt.UnixNano()})
+	return s
+}
+
+// HandleEvent handles an incoming or outgoing request event and updates
+// the state accordingly.
+func (s *RequestStats) HandleEvent(event network.ReqEvent) {
+	s.handleEvents(event, 1)
+}
+
+// handleEvents handles n identical request events at once.
+func (s *RequestStats) handleEvents(event network.ReqEvent, n int64) {
+	t := event.Time.UnixNano()
+	w, shard := s.acquire(t)
+
+	// Like network.RequestStats, the events from before the window are
+	// taken to happen as it started.
+	offset := int64(0)
+	if t > w.start {
+		offset = n * (t - w.start)
+	}
+	switch event.Type {
+	case network.ProxiedIn:
+		shard.proxiedIn.Add(n)
+		shard.proxiedOffsets.Add(offset)
+		fallthrough
+	case network.ReqIn:
+		shard.in.Add(n)
+		shard.offsets.Add(offset)
+	case network.ProxiedOut:
+		shard.proxiedOut.Add(n)
+		shard.proxiedOffsets.Sub(offset)
+		fallthrough
+	case network.ReqOut:
+		shard.out.Add(n)
+		shard.offsets.Sub(offset)
+	}
+	shard.writers.Dec()
+}
+
+// acquire returns the current window and the shard of an event at t,
+// registered as being written to. The shard has to be released by
+// decrementing its writers.
+func (s *RequestStats) acquire(t int64) (*statsWindow, *statsShard) {
+	// The nanoseconds of the event are as good as random, hashed to pick a
+	// shard even if the clock is coarse.
+	i := (uint64(t) * 0x9e3779b97f4a7c15) >> (64 - requestStatsShardBits)
+	for {
+		w := s.current.Load().(*statsWindow)
+		shard := &w.shards[i]
+		shard.writers.Inc()
+		// If the window was reported meanwhile, the report might have missed
+		// the event, which belongs to the next window then.
+		if s.current.Load().(*statsWindow) == w {
+			return w, shard
+		}
+		shard.writers.Dec()
+	}
+}
+
+// Report returns a RequestStatsReport relative to the given time. The state
+// will be reset for another reporting cycle afterwards.
+func (s *RequestStats) Report(now time.Time) network.RequestStatsReport {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	w := s.current.Load().(*statsWindow)
+	s.current.Store(&statsWindow{start: now.UnixNano()})
+
+	var requests, proxiedRequests, offsets, proxiedOffsets int64
+	for i := range w.shards {
+		shard := &w.shards[i]
+		// Wait for the events being added to the shard, which only takes a
+		// few atomic operations.
+		for shard.writers.Load() > 0 {
+			runtime.Gosched()
+		}
+		requests += shard.in.Load()
+		proxiedRequests += shard.proxiedIn.Load()
+		s.concurrency += shard.in.Load() - shard.out.Load()
+		s.proxiedConcurrency += shard.proxiedIn.Load() - shard.proxiedOut.Load()
+		offsets += shard.offsets.Load()
+		proxiedOffsets += shard.proxiedOffsets.Load()
+	}
+
+	report := network.RequestStatsReport{
+		RequestCount:        float64(requests),
+		ProxiedRequestCount: float64(proxiedRequests),
+	}
+	// Like network.RequestStats, the windows going back in time are ignored.
+	if length := now.UnixNano() - w.start; length > 0 {
+		report.AverageConcurrency = float64(s.concurrency*length-offsets) / float64(length)
+		report.AverageProxiedConcurrency = float64(s.proxiedConcurrency*length-proxiedOffsets) / float64(length)
+	}
+	return report
+}
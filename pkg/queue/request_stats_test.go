@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	network "knative.dev/networking/pkg"
+)
+
+func TestRequestStatsMatchesNetwork(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time {
+		return start.Add(time.Duration(ms) * time.Millisecond)
+	}
+	events := [][]network.ReqEvent{{
+		{Time: at(100), Type: network.ReqIn},
+		{Time: at(200), Type: network.ProxiedIn},
+		{Time: at(300), Type: network.ReqIn},
+		{Time: at(600), Type: network.ReqOut},
+	}, {
+		// An event handled late, from before the window.
+		{Time: at(900), Type: network.ProxiedOut},
+		{Time: at(1250), Type: network.ProxiedIn},
+	}, {}, {
+		{Time: at(3100), Type: network.ReqOut},
+		{Time: at(3400), Type: network.ProxiedOut},
+		{Time: at(3500), Type: network.ReqIn},
+		{Time: at(3700), Type: network.ReqOut},
+	}}
+
+	want := network.NewRequestStats(start)
+	got := NewRequestStats(start)
+	for i, window := range events {
+		for _, e := range window {
+			want.HandleEvent(e)
+			got.HandleEvent(e)
+		}
+		now := at(1000 * (i + 1))
+		if got, want := got.Report(now), want.Report(now); !cmp.Equal(got, want, cmpopts.EquateApprox(0, 1e-9)) {
+			t.Errorf("Window %d: Report = %+v, want: %+v", i, got, want)
+		}
+	}
+}
+
+func TestRequestStatsConcurrent(t *testing.T) {
+	const goroutines, requests = 10, 1000
+	start := time.Now()
+	stats := NewRequestStats(start)
+
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < requests; j++ {
+				stats.HandleEvent(network.ReqEvent{Time: time.Now(), Type: network.ProxiedIn})
+				stats.HandleEvent(network.ReqEvent{Time: time.Now(), Type: network.ProxiedOut})
+			}
+		}()
+	}
+	// Report while the events are handled, none may be lost.
+	var count, proxiedCount float64
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for reporting := true; reporting; {
+		select {
+		case <-done:
+			reporting = false
+		case <-time.After(time.Millisecond):
+		}
+		report := stats.Report(time.Now())
+		count += report.RequestCount
+		proxiedCount += report.ProxiedRequestCount
+	}
+
+	if count != goroutines*requests || proxiedCount != goroutines*requests {
+		t.Errorf("Counted %v requests and %v proxied ones, want: %d", count, proxiedCount, goroutines*requests)
+	}
+	// All the requests are done.
+	report := stats.Report(time.Now().Add(time.Second))
+	if math.Abs(report.AverageConcurrency) > 1e-9 || math.Abs(report.AverageProxiedConcurrency) > 1e-9 {
+		t.Errorf("Concurrency after the requests = %v and %v, want: 0", report.AverageConcurrency, report.AverageProxiedConcurrency)
+	}
+}
+
+// requestStats is implemented by both RequestStats and network.RequestStats.
+type requestStats interface {
+	HandleEvent(network.ReqEvent)
+	Report(time.Time) network.RequestStatsReport
+}
+
+func BenchmarkRequestStats(b *testing.B) {
+	for _, impl := range []struct {
+		name  string
+		stats func() requestStats
+	}{{
+		name:  "network",
+		stats: func() requestStats { return network.NewRequestStats(time.Now()) },
+	}, {
+		name:  "lock-free",
+		stats: func() requestStats { return NewRequestStats(time.Now()) },
+	}} {
+		b.Run(impl.name+"-sequential", func(b *testing.B) {
+			stats := impl.stats()
+			now := time.Now()
+			for i := 0; i < b.N; i++ {
+				// Reading the clock would dominate, the events just need
+				// distinct times.
+				t := now.Add(time.Duration(i))
+				stats.HandleEvent(network.ReqEvent{Time: t, Type: network.ReqIn})
+				stats.HandleEvent(network.ReqEvent{Time: t, Type: network.ReqOut})
+			}
+		})
+
+		b.Run(impl.name+"-parallel", func(b *testing.B) {
+			stats := impl.stats()
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				ticker := time.NewTicker(time.Millisecond)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-done:
+						return
+					case now := <-ticker.C:
+						stats.Report(now)
+					}
+				}
+			}()
+			b.RunParallel(func(pb *testing.PB) {
+				t := time.Now()
+				for pb.Next() {
+					t = t.Add(time.Nanosecond)
+					stats.HandleEvent(network.ReqEvent{Time: t, Type: network.ReqIn})
+					stats.HandleEvent(network.ReqEvent{Time: t, Type: network.ReqOut})
+				}
+			})
+		})
+	}
+}
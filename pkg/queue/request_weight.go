@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+)
+
+type weightKey struct{}
+
+// WithRequestWeight attaches the number of slots the request takes in a
+// Breaker to the context.
+func WithRequestWeight(ctx context.Context, weight int) context.Context {
+	return context.WithValue(ctx, weightKey{}, weight)
+}
+
+// requestWeight returns the weight attached to the context, 1 if none is.
+func requestWeight(ctx context.Context) int {
+	if w, ok := ctx.Value(weightKey{}).(int); ok && w > 1 {
+		return w
+	}
+	return 1
+}
+
+// Weigher derives the weight of requests, i.e. the number of concurrency
+// slots they take, from a header carrying it or from rules matching their
+// path.
+type Weigher struct {
+	rules *requestRules
+}
+
+// NewWeigher creates a Weigher reading the weight from header, if set, and
+// otherwise from rules, the JSON serialized weights by path prefix, e.g.
+// {"/batch": 4}. Requests matching neither weigh 1.
+func NewWeigher(header, rules string) (*Weigher, error) {
+	r, err := newRequestRules(header, rules, 1 /*min*/, math.MaxInt32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse weight rules: %w", err)
+	}
+	return &Weigher{rules: r}, nil
+}
+
+// Weight returns the weight of the request. Header values below 1 are
+// raised to 1, invalid ones are ignored.
+func (w *Weigher) Weight(r *http.Request) int {
+	return w.rules.value(r)
+}
+
+// Handler attaches the weight of each request to its context.
+func (w *Weigher) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(rw, r.WithContext(WithRequestWeight(r.Context(), w.Weight(r))))
+	})
+}
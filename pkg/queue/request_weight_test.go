@@ -0,0 +1,236 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestWeigher(t *testing.T) {
+	w, err := NewWeigher("X-Weight", `{"/batch": 4}`)
+	if err != nil {
+		t.Fatal("NewWeigher() =", err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		want   int
+	}{{
+		name: "default",
+		path: "/",
+		want: 1,
+	}, {
+		name: "rule",
+		path: "/batch/1",
+		want: 4,
+	}, {
+		name:   "header",
+		path:   "/batch",
+		header: "8",
+		want:   8,
+	}, {
+		name:   "header raised to 1",
+		path:   "/",
+		header: "0",
+		want:   1,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+test.path, nil)
+			if test.header != "" {
+				req.Header.Set("X-Weight", test.header)
+			}
+			var got int
+			w.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = requestWeight(r.Context())
+			})).ServeHTTP(httptest.NewRecorder(), req)
+			if got != test.want {
+				t.Errorf("Weight = %d, want: %d", got, test.want)
+			}
+		})
+	}
+
+	if _, err := NewWeigher("", `{"/batch": 0}`); err == nil {
+		t.Error("Weight below 1 succeeded, want error")
+	}
+}
+
+// slotsInUse returns the number of slots of the breaker's semaphore in use.
+func slotsInUse(b *Breaker) uint64 {
+	_, in := unpack(b.sem.state.Load())
+	return in
+}
+
+// weighted runs a request of the given weight in the breaker until the
+// returned function is called.
+func weighted(t *testing.T, b *Breaker, weight int) func() {
+	t.Helper()
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := b.Maybe(WithRequestWeight(context.Background(), weight), func() { <-release }); err != nil {
+			t.Error("Maybe() =", err)
+		}
+	}()
+	return func() {
+		close(release)
+		<-done
+	}
+}
+
+func pollSlotsInUse(t *testing.T, b *Breaker, want uint64) {
+	t.Helper()
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return slotsInUse(b) == want, nil
+	}); err != nil {
+		t.Fatalf("Slots in use = %d, want: %d", slotsInUse(b), want)
+	}
+}
+
+func TestBreakerRequestWeight(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 4, InitialCapacity: 4, MaxRequestWeight: 4})
+
+	releaseHeavy := weighted(t, b, 3)
+	pollSlotsInUse(t, b, 3)
+	releaseLight := weighted(t, b, 1)
+	pollSlotsInUse(t, b, 4)
+
+	// There's no room for another two slots until the heavy request is done.
+	releaseMedium := weighted(t, b, 2)
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return b.PendingRequests() == 1, nil
+	}); err != nil {
+		t.Fatalf("PendingRequests() = %d, want: 1", b.PendingRequests())
+	}
+	releaseHeavy()
+	pollSlotsInUse(t, b, 3)
+
+	releaseLight()
+	releaseMedium()
+	pollSlotsInUse(t, b, 0)
+}
+
+func TestBreakerRequestWeightCapped(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 4, InitialCapacity: 4, MaxRequestWeight: 4})
+	if got, want := b.RequestWeight(WithRequestWeight(context.Background(), 10)), 4; got != want {
+		t.Errorf("RequestWeight() = %d, want: %d", got, want)
+	}
+
+	// A request heavier than the capacity takes all of it.
+	b.UpdateConcurrency(2)
+	release := weighted(t, b, 4)
+	pollSlotsInUse(t, b, 2)
+	release()
+	pollSlotsInUse(t, b, 0)
+
+	// Without a maximum weight, weights are ignored.
+	b = NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 4, InitialCapacity: 4})
+	if got, want := b.RequestWeight(WithRequestWeight(context.Background(), 3)), 1; got != want {
+		t.Errorf("RequestWeight() = %d, want: %d", got, want)
+	}
+}
+
+func TestBreakerRequestWeightPriorities(t *testing.T) {
+	b := NewBreaker(BreakerParams{
+		QueueDepth: 10, MaxConcurrency: 2, InitialCapacity: 2, Priorities: RequestPriorities, MaxRequestWeight: 2,
+	})
+	releaseLight := weighted(t, b, 1)
+	pollSlotsInUse(t, b, 1)
+
+	// The heavy request next in line holds back the light one behind it.
+	ctx := WithRequestPriority(WithRequestWeight(context.Background(), 2), 9)
+	heavy := make(chan struct{})
+	go b.Maybe(ctx, func() { close(heavy) })
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return b.PendingRequests() == 1, nil
+	}); err != nil {
+		t.Fatalf("PendingRequests() = %d, want: 1", b.PendingRequests())
+	}
+	light := make(chan struct{})
+	go b.Maybe(context.Background(), func() { close(light) })
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return b.PendingRequests() == 2, nil
+	}); err != nil {
+		t.Fatalf("PendingRequests() = %d, want: 2", b.PendingRequests())
+	}
+
+	releaseLight()
+	<-heavy
+	<-light
+}
+
+func TestBreakerRequestWeightPrioritiesCancel(t *testing.T) {
+	b := NewBreaker(BreakerParams{
+		QueueDepth: 10, MaxConcurrency: 2, InitialCapacity: 2, Priorities: RequestPriorities, MaxRequestWeight: 2,
+	})
+	releaseLight := weighted(t, b, 1)
+	defer releaseLight()
+	pollSlotsInUse(t, b, 1)
+
+	ctx, cancel := context.WithCancel(WithRequestPriority(WithRequestWeight(context.Background(), 2), 9))
+	canceled := make(chan error, 1)
+	go func() { canceled <- b.Maybe(ctx, func() {}) }()
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return b.PendingRequests() == 1, nil
+	}); err != nil {
+		t.Fatalf("PendingRequests() = %d, want: 1", b.PendingRequests())
+	}
+	light := make(chan struct{})
+	go b.Maybe(context.Background(), func() { close(light) })
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return b.PendingRequests() == 2, nil
+	}); err != nil {
+		t.Fatalf("PendingRequests() = %d, want: 2", b.PendingRequests())
+	}
+
+	// The light request behind the canceled heavy one takes the free slot,
+	// without waiting for another request to complete.
+	cancel()
+	if err := <-canceled; err != context.Canceled {
+		t.Errorf("Maybe() = %v, want: %v", err, context.Canceled)
+	}
+	select {
+	case <-light:
+	case <-time.After(semAcquireTimeout):
+		t.Fatal("The light request wasn't admitted once the heavy one ahead of it was canceled")
+	}
+}
+
+func TestProxyHandlerRequestWeight(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 4, InitialCapacity: 4, MaxRequestWeight: 4})
+	stats := NewRequestStats(time.Now())
+	h := ProxyHandler(b, stats, false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req = req.WithContext(WithRequestWeight(req.Context(), 3))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	// A request weighing three counts as three requests.
+	if got, want := stats.Report(time.Now()).RequestCount, 3.0; got != want {
+		t.Errorf("RequestCount = %v, want: %v", got, want)
+	}
+}
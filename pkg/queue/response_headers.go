@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+
+	"knative.dev/pkg/websocket"
+)
+
+// ResponseHeaderRulesHeaderName is the header the Ingress of a Route appends
+// to the requests through a traffic tag with response header rules, carrying
+// the JSON serialized HeaderRules of the tag, which are applied after the
+// ones of the Revision.
+const ResponseHeaderRulesHeaderName = "Knative-Serving-Response-Headers"
+
+// HeaderRules are the rules applied to every response on its way back to
+// the client. Remove is applied first, then Set overrides the values set by
+// the backend and finally Add appends to them.
+type HeaderRules struct {
+	Set    map[string]string `json:"set,omitempty"`
+	Add    map[string]string `json:"add,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// ParseHeaderRules parses the JSON serialized HeaderRules.
+// An empty string results in nil rules.
+func ParseHeaderRules(s string) (*HeaderRules, error) {
+	if s == "" {
+		return nil, nil
+	}
+	rules := &HeaderRules{}
+	if err := json.Unmarshal([]byte(s), rules); err != nil {
+		return nil, fmt.Errorf("failed to parse header rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (hr *HeaderRules) empty() bool {
+	return hr == nil || (len(hr.Set) == 0 && len(hr.Add) == 0 && len(hr.Remove) == 0)
+}
+
+func (hr *HeaderRules) apply(h http.Header) {
+	for _, k := range hr.Remove {
+		h.Del(k)
+	}
+	for k, v := range hr.Set {
+		h.Set(k, v)
+	}
+	for k, v := range hr.Add {
+		h.Add(k, v)
+	}
+}
+
+// ResponseHeadersHandler applies the given rules to the headers of every
+// response, including the ones generated by the queue-proxy itself, right
+// before they're written. The rules the request carries in the
+// ResponseHeaderRulesHeaderName header are applied next, and the header is
+// removed from the request.
+func ResponseHeadersHandler(rules *HeaderRules, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		all := make([]*HeaderRules, 0, 2)
+		if !rules.empty() {
+			all = append(all, rules)
+		}
+		if v := r.Header.Get(ResponseHeaderRulesHeaderName); v != "" {
+			r.Header.Del(ResponseHeaderRulesHeaderName)
+			// The header is set by the Ingress, so it's not expected to be invalid.
+			if tagRules, err := ParseHeaderRules(v); err == nil && !tagRules.empty() {
+				all = append(all, tagRules)
+			}
+		}
+		if len(all) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rw := &headerRulesWriter{writer: w, rules: all}
+		next.ServeHTTP(rw, r)
+		// Responses without a body are written once the handler is done.
+		if !rw.wroteHeader {
+			rw.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+var (
+	_ http.Flusher  = (*headerRulesWriter)(nil)
+	_ http.Hijacker = (*headerRulesWriter)(nil)
+)
+
+type headerRulesWriter struct {
+	writer      http.ResponseWriter
+	rules       []*HeaderRules
+	wroteHeader bool
+}
+
+// Header returns the header map that will be sent by WriteHeader.
+func (w *headerRulesWriter) Header() http.Header {
+	return w.writer.Header()
+}
+
+// WriteHeader applies the rules and sends the headers with the provided status code.
+func (w *headerRulesWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		for _, rules := range w.rules {
+			rules.apply(w.writer.Header())
+		}
+	}
+	w.writer.WriteHeader(code)
+}
+
+// Write writes the data to the connection as part of an HTTP reply.
+func (w *headerRulesWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.writer.Write(p)
+}
+
+// Flush flushes the buffer to the client.
+func (w *headerRulesWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.writer.(http.Flusher).Flush()
+}
+
+// Hijack calls Hijack() on the wrapped http.ResponseWriter if it implements
+// http.Hijacker interface, which is required for net/http/httputil/reverseproxy
+// to handle connection upgrade/switching protocol.  Otherwise returns an error.
+func (w *headerRulesWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	// The connection is taken over, so there's no response to write anymore.
+	w.wroteHeader = true
+	return websocket.HijackIfPossible(w.writer)
+}
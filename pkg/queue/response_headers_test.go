@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseHeaderRules(t *testing.T) {
+	if rules, err := ParseHeaderRules(""); err != nil || rules != nil {
+		t.Errorf("ParseHeaderRules(\"\") = %v, %v, want nil, nil", rules, err)
+	}
+	if _, err := ParseHeaderRules("{not json"); err == nil {
+		t.Error("ParseHeaderRules(invalid) succeeded, want error")
+	}
+
+	got, err := ParseHeaderRules(`{"set":{"Server":"knative"},"add":{"Vary":"Origin"},"remove":["X-Powered-By"]}`)
+	if err != nil {
+		t.Fatal("ParseHeaderRules() =", err)
+	}
+	want := &HeaderRules{
+		Set:    map[string]string{"Server": "knative"},
+		Add:    map[string]string{"Vary": "Origin"},
+		Remove: []string{"X-Powered-By"},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error("ParseHeaderRules() diff(-want,+got):\n", cmp.Diff(want, got))
+	}
+}
+
+func TestResponseHeadersHandler(t *testing.T) {
+	rules := &HeaderRules{
+		Set:    map[string]string{"Server": "knative"},
+		Add:    map[string]string{"X-Content-Type-Options": "nosniff", "Vary": "Origin"},
+		Remove: []string{"X-Powered-By"},
+	}
+
+	tests := []struct {
+		name    string
+		backend http.HandlerFunc
+	}{{
+		name: "explicit WriteHeader",
+		backend: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Server", "my-app")
+			w.Header().Set("X-Powered-By", "magic")
+			w.Header().Set("Vary", "Accept")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("hello"))
+		},
+	}, {
+		name: "implicit WriteHeader",
+		backend: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Server", "my-app")
+			w.Header().Set("X-Powered-By", "magic")
+			w.Header().Set("Vary", "Accept")
+			w.Write([]byte("hello"))
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ResponseHeadersHandler(rules, test.backend).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, targetURI, nil))
+
+			if got, want := rec.Header().Get("Server"), "knative"; got != want {
+				t.Errorf("Server = %q, want: %q", got, want)
+			}
+			if got, want := rec.Header().Get("X-Content-Type-Options"), "nosniff"; got != want {
+				t.Errorf("X-Content-Type-Options = %q, want: %q", got, want)
+			}
+			if got, want := rec.Header().Values("Vary"), []string{"Accept", "Origin"}; !cmp.Equal(got, want) {
+				t.Errorf("Vary = %q, want: %q", got, want)
+			}
+			if got := rec.Header().Get("X-Powered-By"); got != "" {
+				t.Errorf("X-Powered-By = %q, want it removed", got)
+			}
+			if got, want := rec.Body.String(), "hello"; got != want {
+				t.Errorf("Body = %q, want: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestResponseHeadersHandlerTagRules(t *testing.T) {
+	rules := &HeaderRules{Set: map[string]string{"Cache-Control": "max-age=60", "Server": "knative"}}
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(ResponseHeaderRulesHeaderName); got != "" {
+			t.Errorf("The tag rules were passed on: %q", got)
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+	})
+
+	for _, rules := range []*HeaderRules{rules, nil} {
+		req := httptest.NewRequest(http.MethodGet, targetURI, nil)
+		req.Header.Set(ResponseHeaderRulesHeaderName, `{"set":{"Cache-Control":"no-store"},"remove":["Server"]}`)
+		rec := httptest.NewRecorder()
+		ResponseHeadersHandler(rules, backend).ServeHTTP(rec, req)
+
+		if got, want := rec.Header().Get("Cache-Control"), "no-store"; got != want {
+			t.Errorf("Cache-Control = %q, want: %q", got, want)
+		}
+		if got := rec.Header().Get("Server"); got != "" {
+			t.Errorf("Server = %q, want it removed", got)
+		}
+	}
+}
+
+func TestResponseHeadersHandlerBreakerError(t *testing.T) {
+	rules := &HeaderRules{
+		Set: map[string]string{"X-Content-Type-Options": "nosniff"},
+	}
+
+	resp := make(chan struct{})
+	blockHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-resp
+	})
+	breaker := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1})
+	stats := NewRequestStats(time.Now())
+	h := ResponseHeadersHandler(rules, ProxyHandler(breaker, stats, false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, blockHandler))
+
+	resps := make(chan *httptest.ResponseRecorder)
+	for i := 0; i < 3; i++ {
+		go func() {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, targetURI, nil))
+			resps <- rec
+		}()
+	}
+
+	// One of the three requests is rejected by the breaker and it's the first
+	// we see since the others are still held by the resp channel.
+	failure := <-resps
+	if got, want := failure.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+	if got, want := failure.Header().Get("X-Content-Type-Options"), "nosniff"; got != want {
+		t.Errorf("X-Content-Type-Options = %q, want: %q", got, want)
+	}
+
+	close(resp)
+	<-resps
+	<-resps
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// retryBackoff is the delay before the first retry, doubling with every
+	// further one.
+	retryBackoff = 25 * time.Millisecond
+	// maxRetryBackoff caps the delay between retries.
+	maxRetryBackoff = time.Second
+)
+
+// errPerTryTimeout is the error of an attempt that timed out.
+var errPerTryTimeout = errors.New("per-try timeout exceeded")
+
+// DefaultRetryMethods are the idempotent methods retried if a RetryPolicy
+// names none.
+var DefaultRetryMethods = sets.NewString(
+	http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+	http.MethodPut, http.MethodDelete,
+)
+
+// RetryPolicy defines which requests to the user container are retried.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts of a request, including the
+	// first one.
+	Attempts int
+	// Methods are the methods of the requests that are retried, the
+	// DefaultRetryMethods if empty.
+	Methods sets.String
+	// StatusCodes are the response codes that are retried, besides failures
+	// to connect to the user container and to read its response.
+	StatusCodes sets.Int
+	// PerTryTimeout bounds the time to the response headers of each attempt,
+	// if greater than 0.
+	PerTryTimeout time.Duration
+	// Budget bounds the retries to a fraction of the requests, nil if
+	// unbounded.
+	Budget *RetryBudget
+}
+
+// retryTransport is an http.RoundTripper retrying requests by a RetryPolicy.
+type retryTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+}
+
+// NewRetryTransport creates a transport that passes requests on to next,
+// retrying them by the policy, e.g. as the user container reset the
+// connection while it was starting up or paused for garbage collection.
+// Requests with a body are only retried if they can replay it, i.e. have
+// GetBody set, as the request spool does.
+func NewRetryTransport(next http.RoundTripper, policy RetryPolicy) http.RoundTripper {
+	if len(policy.Methods) == 0 {
+		policy.Methods = DefaultRetryMethods
+	}
+	return &retryTransport{next: next, policy: policy}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.policy.Budget.Request()
+	if !t.retryable(req) {
+		return t.try(req)
+	}
+
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		resp, err := t.try(req)
+		if attempt >= t.policy.Attempts || !t.shouldRetry(req, resp, err) || !t.policy.Budget.AllowRetry() {
+			return resp, err
+		}
+		if resp != nil {
+			// Drain a bit of the body so the connection can be reused.
+			io.CopyN(io.Discard, resp.Body, 4096)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryable returns whether the request may be retried at all.
+func (t *retryTransport) retryable(req *http.Request) bool {
+	if t.policy.Attempts <= 1 || !t.policy.Methods.Has(req.Method) {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// shouldRetry returns whether an attempt failed in a way that's retried.
+func (t *retryTransport) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		// The client went away or the request timed out as a whole.
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return t.policy.StatusCodes.Has(resp.StatusCode)
+}
+
+// try makes a single attempt of the request, which times out after
+// PerTryTimeout if the response headers take longer.
+func (t *retryTransport) try(req *http.Request) (*http.Response, error) {
+	if t.policy.PerTryTimeout <= 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(t.policy.PerTryTimeout, cancel)
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if !timer.Stop() {
+		cancel()
+		if err == nil {
+			resp.Body.Close()
+		}
+		return nil, errPerTryTimeout
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		// The body of an upgraded connection must stay an io.ReadWriteCloser.
+		// The attempt ends along with the request.
+		return resp, nil
+	}
+	// The attempt lives on until its response is read.
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody cancels the context of the attempt it's the response body of,
+// once it's closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+const (
+	// retryBudgetWindow is the sliding window over which the retries are
+	// compared to the requests.
+	retryBudgetWindow = 10 * time.Second
+	// retryBudgetGranularity is the size of each bucket of the window.
+	retryBudgetGranularity = time.Second
+)
+
+// RetryBudget bounds retries to a fraction of the requests over a sliding
+// window, so that retries can't storm a struggling revision. A nil
+// RetryBudget permits all retries.
+type RetryBudget struct {
+	ratio float64
+	clock clock.PassiveClock
+
+	mux     sync.Mutex
+	buckets []retryBudgetBucket
+}
+
+// retryBudgetBucket counts the requests and retries during a granule of the
+// window, denoted by its index since the epoch.
+type retryBudgetBucket struct {
+	granule  int64
+	requests int
+	retries  int
+}
+
+// NewRetryBudget creates a RetryBudget permitting retries up to ratio times
+// the number of requests.
+func NewRetryBudget(ratio float64, clock clock.PassiveClock) *RetryBudget {
+	return &RetryBudget{
+		ratio:   ratio,
+		clock:   clock,
+		buckets: make([]retryBudgetBucket, retryBudgetWindow/retryBudgetGranularity),
+	}
+}
+
+// bucketLocked returns the bucket of the current granule, resetting it if
+// it's left over from a previous window.
+func (b *RetryBudget) bucketLocked() *retryBudgetBucket {
+	granule := b.clock.Now().UnixNano() / int64(retryBudgetGranularity)
+	bucket := &b.buckets[granule%int64(len(b.buckets))]
+	if bucket.granule != granule {
+		*bucket = retryBudgetBucket{granule: granule}
+	}
+	return bucket
+}
+
+// Request records a request.
+func (b *RetryBudget) Request() {
+	if b == nil {
+		return
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.bucketLocked().requests++
+}
+
+// AllowRetry records a retry and returns true if it stays within the budget.
+// Otherwise it returns false and the retry must not happen.
+func (b *RetryBudget) AllowRetry() bool {
+	if b == nil {
+		return true
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	current := b.bucketLocked()
+	var requests, retries int
+	for _, bucket := range b.buckets {
+		if current.granule-bucket.granule < int64(len(b.buckets)) {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+	if float64(retries+1) > b.ratio*float64(requests) {
+		return false
+	}
+	current.retries++
+	return true
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestRetryBudget(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	b := NewRetryBudget(0.2, fakeClock)
+
+	if b.AllowRetry() {
+		t.Error("Retry without requests was allowed")
+	}
+	for i := 0; i < 10; i++ {
+		b.Request()
+	}
+	for i := 0; i < 2; i++ {
+		if !b.AllowRetry() {
+			t.Errorf("Retry %d within the budget was denied", i)
+		}
+	}
+	if b.AllowRetry() {
+		t.Error("Retry beyond the budget was allowed")
+	}
+
+	// More requests allow more retries.
+	fakeClock.Step(retryBudgetGranularity)
+	for i := 0; i < 5; i++ {
+		b.Request()
+	}
+	if !b.AllowRetry() {
+		t.Error("Retry within the budget was denied")
+	}
+	if b.AllowRetry() {
+		t.Error("Retry beyond the budget was allowed")
+	}
+
+	// Once the first requests and retries left the window, only the later
+	// requests and retries count.
+	fakeClock.Step(retryBudgetWindow - retryBudgetGranularity)
+	if b.AllowRetry() {
+		t.Error("Retry beyond the budget of the window was allowed")
+	}
+
+	// And eventually the window is empty.
+	fakeClock.Step(retryBudgetWindow)
+	b.Request()
+	if b.AllowRetry() {
+		t.Error("Retry beyond the budget of the window was allowed")
+	}
+	for i := 0; i < 4; i++ {
+		b.Request()
+	}
+	if !b.AllowRetry() {
+		t.Error("Retry within the budget of the window was denied")
+	}
+}
+
+func TestRetryBudgetNil(t *testing.T) {
+	var b *RetryBudget
+	b.Request()
+	if !b.AllowRetry() {
+		t.Error("Retry without a budget was denied")
+	}
+}
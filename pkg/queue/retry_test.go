@@ -0,0 +1,189 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
+	pkgnet "knative.dev/pkg/network"
+)
+
+// flakyTransport fails the first failures attempts with err, or responds
+// with status if err is nil, and then responds with a 200.
+type flakyTransport struct {
+	failures int
+	err      error
+	status   int
+
+	attempts int
+	bodies   []string
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.attempts++
+	if req.Body != nil {
+		b, _ := io.ReadAll(req.Body)
+		f.bodies = append(f.bodies, string(b))
+	}
+	status := http.StatusOK
+	if f.attempts <= f.failures {
+		if f.err != nil {
+			return nil, f.err
+		}
+		status = f.status
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func TestRetryTransport(t *testing.T) {
+	errReset := errors.New("connection reset by peer")
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		transport    *flakyTransport
+		method       string
+		wantAttempts int
+		wantStatus   int
+		wantErr      bool
+	}{{
+		name:         "connection errors",
+		policy:       RetryPolicy{Attempts: 3},
+		transport:    &flakyTransport{failures: 2, err: errReset},
+		wantAttempts: 3,
+		wantStatus:   http.StatusOK,
+	}, {
+		name:         "attempts exhausted",
+		policy:       RetryPolicy{Attempts: 2},
+		transport:    &flakyTransport{failures: 2, err: errReset},
+		wantAttempts: 2,
+		wantErr:      true,
+	}, {
+		name:         "retried status code",
+		policy:       RetryPolicy{Attempts: 3, StatusCodes: sets.NewInt(http.StatusServiceUnavailable)},
+		transport:    &flakyTransport{failures: 1, status: http.StatusServiceUnavailable},
+		wantAttempts: 2,
+		wantStatus:   http.StatusOK,
+	}, {
+		name:         "other status code",
+		policy:       RetryPolicy{Attempts: 3, StatusCodes: sets.NewInt(http.StatusServiceUnavailable)},
+		transport:    &flakyTransport{failures: 1, status: http.StatusInternalServerError},
+		wantAttempts: 1,
+		wantStatus:   http.StatusInternalServerError,
+	}, {
+		name:         "non-idempotent method",
+		policy:       RetryPolicy{Attempts: 3},
+		transport:    &flakyTransport{failures: 1, err: errReset},
+		method:       http.MethodPost,
+		wantAttempts: 1,
+		wantErr:      true,
+	}, {
+		name:         "configured method",
+		policy:       RetryPolicy{Attempts: 3, Methods: sets.NewString(http.MethodPost)},
+		transport:    &flakyTransport{failures: 1, err: errReset},
+		method:       http.MethodPost,
+		wantAttempts: 2,
+		wantStatus:   http.StatusOK,
+	}, {
+		name:         "budget exhausted",
+		policy:       RetryPolicy{Attempts: 3, Budget: NewRetryBudget(0.5, clock.RealClock{})},
+		transport:    &flakyTransport{failures: 2, err: errReset},
+		wantAttempts: 1,
+		wantErr:      true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			resp, err := NewRetryTransport(test.transport, test.policy).RoundTrip(httptest.NewRequest(method, targetURI, nil))
+			if (err != nil) != test.wantErr {
+				t.Errorf("RoundTrip() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if err == nil && resp.StatusCode != test.wantStatus {
+				t.Errorf("StatusCode = %d, want: %d", resp.StatusCode, test.wantStatus)
+			}
+			if got := test.transport.attempts; got != test.wantAttempts {
+				t.Errorf("Attempts = %d, want: %d", got, test.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryTransportBody(t *testing.T) {
+	const body = "request body"
+	transport := &flakyTransport{failures: 1, err: errors.New("connection reset by peer")}
+	rt := NewRetryTransport(transport, RetryPolicy{Attempts: 3})
+
+	// Without GetBody, the body can't be replayed.
+	req := httptest.NewRequest(http.MethodPut, targetURI, io.NopCloser(strings.NewReader(body)))
+	if _, err := rt.RoundTrip(req); err == nil {
+		t.Error("RoundTrip() succeeded without replaying the body, want error")
+	}
+
+	transport.attempts, transport.bodies = 0, nil
+	req = httptest.NewRequest(http.MethodPut, targetURI, nil)
+	req.Body = io.NopCloser(strings.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader([]byte(body))), nil
+	}
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatal("RoundTrip() =", err)
+	}
+	if got, want := transport.bodies, []string{body, body}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Bodies = %q, want: %q", got, want)
+	}
+}
+
+func TestRetryTransportPerTryTimeout(t *testing.T) {
+	var attempts int
+	transport := pkgnet.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts == 1 {
+			// The first attempt hangs until it times out.
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	rt := NewRetryTransport(transport, RetryPolicy{Attempts: 2, PerTryTimeout: 10 * time.Millisecond})
+	resp, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, targetURI, nil))
+	if err != nil {
+		t.Fatal("RoundTrip() =", err)
+	}
+	resp.Body.Close()
+	if got, want := attempts, 2; got != want {
+		t.Errorf("Attempts = %d, want: %d", got, want)
+	}
+
+	attempts = 0
+	rt = NewRetryTransport(transport, RetryPolicy{Attempts: 1, PerTryTimeout: 10 * time.Millisecond})
+	if _, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, targetURI, nil)); !errors.Is(err, errPerTryTimeout) {
+		t.Errorf("RoundTrip() = %v, want: %v", err, errPerTryTimeout)
+	}
+}
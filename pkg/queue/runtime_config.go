@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	cm "knative.dev/pkg/configmap"
+)
+
+// The keys of the ConfigMap the queue-proxy reloads its runtime settings from.
+const (
+	// RuntimeConfigLogLevelKey is the key of the log level, e.g. "debug".
+	RuntimeConfigLogLevelKey = "loglevel"
+	// RuntimeConfigTimeoutKey is the key of the time to first byte timeout of
+	// the requests, which the timeout rules still override.
+	RuntimeConfigTimeoutKey = "timeout"
+	// RuntimeConfigMetricsBackendKey is the key of the backend the request
+	// metrics are exported to, e.g. "prometheus".
+	RuntimeConfigMetricsBackendKey = "metrics-backend"
+	// RuntimeConfigDrainTimeoutKey is the key of the time the queue-proxy
+	// keeps serving once told to shut down, for the pod's removal to
+	// propagate to the load balancers.
+	RuntimeConfigDrainTimeoutKey = "drain-timeout"
+	// RuntimeConfigConcurrencyStateEndpointKey is the key of the URL the
+	// pause and resume requests are sent to.
+	RuntimeConfigConcurrencyStateEndpointKey = "concurrency-state-endpoint"
+)
+
+// RuntimeConfig is the subset of the settings of the queue-proxy that can be
+// changed while it runs, by updating the ConfigMap mounted into the pod. The
+// settings left out, i.e. zero, keep the values of the environment.
+type RuntimeConfig struct {
+	LogLevel                 string
+	Timeout                  time.Duration
+	MetricsBackend           string
+	DrainTimeout             time.Duration
+	ConcurrencyStateEndpoint string
+}
+
+// NewRuntimeConfigFromMap creates a RuntimeConfig from the data of the
+// ConfigMap.
+func NewRuntimeConfigFromMap(data map[string]string) (*RuntimeConfig, error) {
+	c := &RuntimeConfig{}
+	if err := cm.Parse(data,
+		cm.AsString(RuntimeConfigLogLevelKey, &c.LogLevel),
+		cm.AsDuration(RuntimeConfigTimeoutKey, &c.Timeout),
+		cm.AsString(RuntimeConfigMetricsBackendKey, &c.MetricsBackend),
+		cm.AsDuration(RuntimeConfigDrainTimeoutKey, &c.DrainTimeout),
+		cm.AsString(RuntimeConfigConcurrencyStateEndpointKey, &c.ConcurrencyStateEndpoint),
+	); err != nil {
+		return nil, fmt.Errorf("failed to parse data: %w", err)
+	}
+
+	if c.LogLevel != "" {
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", RuntimeConfigLogLevelKey, err)
+		}
+	}
+	if c.Timeout < 0 {
+		return nil, fmt.Errorf("%s = %v, must not be negative", RuntimeConfigTimeoutKey, c.Timeout)
+	}
+	if c.DrainTimeout < 0 {
+		return nil, fmt.Errorf("%s = %v, must not be negative", RuntimeConfigDrainTimeoutKey, c.DrainTimeout)
+	}
+	if c.ConcurrencyStateEndpoint != "" {
+		if u, err := url.Parse(c.ConcurrencyStateEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid %s %q: must be an absolute URL", RuntimeConfigConcurrencyStateEndpointKey, c.ConcurrencyStateEndpoint)
+		}
+	}
+	return c, nil
+}
+
+// RuntimeConfigWatcher reloads the RuntimeConfig from the ConfigMap mounted
+// at a directory, as the kubelet updates it.
+type RuntimeConfigWatcher struct {
+	dir      string
+	onChange func(*RuntimeConfig)
+	current  RuntimeConfig
+}
+
+// NewRuntimeConfigWatcher creates a RuntimeConfigWatcher of the ConfigMap
+// mounted at dir, calling onChange with the RuntimeConfig once it's loaded
+// and every time it changes.
+func NewRuntimeConfigWatcher(dir string, onChange func(*RuntimeConfig)) *RuntimeConfigWatcher {
+	return &RuntimeConfigWatcher{
+		dir:      dir,
+		onChange: onChange,
+	}
+}
+
+// Run loads the RuntimeConfig, then checks it for changes every interval
+// until ctx is done. If the ConfigMap is invalid, the previous RuntimeConfig
+// stays in effect.
+func (w *RuntimeConfigWatcher) Run(ctx context.Context, interval time.Duration, logger *zap.SugaredLogger) {
+	if _, err := w.reload(true /*force*/); err != nil {
+		logger.Errorw("Failed to load the runtime configuration", zap.Error(err))
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if reloaded, err := w.reload(false /*force*/); err != nil {
+				logger.Errorw("Failed to reload the runtime configuration", zap.Error(err))
+			} else if reloaded {
+				logger.Infof("Reloaded the runtime configuration: %+v", w.current)
+			}
+		}
+	}
+}
+
+// reload loads the RuntimeConfig and calls onChange if it changed, or if
+// forced, and returns whether it did.
+func (w *RuntimeConfigWatcher) reload(force bool) (bool, error) {
+	data, err := cm.Load(w.dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", w.dir, err)
+	}
+	c, err := NewRuntimeConfigFromMap(data)
+	if err != nil {
+		return false, err
+	}
+	if !force && *c == w.current {
+		return false, nil
+	}
+	w.current = *c
+	w.onChange(c)
+	return true, nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	pkglogging "knative.dev/pkg/logging/testing"
+)
+
+func TestNewRuntimeConfigFromMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    *RuntimeConfig
+		wantErr bool
+	}{{
+		name: "empty",
+		data: map[string]string{},
+		want: &RuntimeConfig{},
+	}, {
+		name: "all",
+		data: map[string]string{
+			RuntimeConfigLogLevelKey:                 "debug",
+			RuntimeConfigTimeoutKey:                  "30s",
+			RuntimeConfigMetricsBackendKey:           "prometheus",
+			RuntimeConfigDrainTimeoutKey:             "10s",
+			RuntimeConfigConcurrencyStateEndpointKey: "http://localhost:9696",
+		},
+		want: &RuntimeConfig{
+			LogLevel:                 "debug",
+			Timeout:                  30 * time.Second,
+			MetricsBackend:           "prometheus",
+			DrainTimeout:             10 * time.Second,
+			ConcurrencyStateEndpoint: "http://localhost:9696",
+		},
+	}, {
+		name:    "invalid log level",
+		data:    map[string]string{RuntimeConfigLogLevelKey: "chatty"},
+		wantErr: true,
+	}, {
+		name:    "invalid timeout",
+		data:    map[string]string{RuntimeConfigTimeoutKey: "soon"},
+		wantErr: true,
+	}, {
+		name:    "negative drain timeout",
+		data:    map[string]string{RuntimeConfigDrainTimeoutKey: "-1s"},
+		wantErr: true,
+	}, {
+		name:    "relative concurrency state endpoint",
+		data:    map[string]string{RuntimeConfigConcurrencyStateEndpointKey: "/state"},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewRuntimeConfigFromMap(test.data)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewRuntimeConfigFromMap() = %v, want error: %v", err, test.wantErr)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Error("NewRuntimeConfigFromMap (-want, +got):", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestRuntimeConfigWatcher(t *testing.T) {
+	dir := t.TempDir()
+	write := func(key, value string) {
+		if err := os.WriteFile(filepath.Join(dir, key), []byte(value), 0o644); err != nil {
+			t.Fatal("WriteFile() =", err)
+		}
+	}
+	write(RuntimeConfigLogLevelKey, "info")
+
+	changes := make(chan RuntimeConfig, 10)
+	w := NewRuntimeConfigWatcher(dir, func(c *RuntimeConfig) {
+		changes <- *c
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx, 10*time.Millisecond, pkglogging.TestLogger(t))
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	if got, want := <-changes, (RuntimeConfig{LogLevel: "info"}); got != want {
+		t.Errorf("Loaded %+v, want: %+v", got, want)
+	}
+
+	// An invalid config keeps the previous one.
+	write(RuntimeConfigTimeoutKey, "soon")
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case c := <-changes:
+		t.Errorf("Reloaded %+v, want the invalid config ignored", c)
+	default:
+	}
+
+	write(RuntimeConfigTimeoutKey, "5s")
+	if got, want := <-changes, (RuntimeConfig{LogLevel: "info", Timeout: 5 * time.Second}); got != want {
+		t.Errorf("Reloaded %+v, want: %+v", got, want)
+	}
+}
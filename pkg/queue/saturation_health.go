@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// SaturationHealth tells a pod that is overloaded, as its Breaker has been
+// saturated for longer than a threshold, from a pod that merely handles a
+// burst of requests.
+type SaturationHealth struct {
+	breaker   *Breaker
+	threshold time.Duration
+	clock     clock.PassiveClock
+}
+
+// NewSaturationHealth creates a SaturationHealth reporting unhealthy once the
+// breaker has been saturated for threshold.
+func NewSaturationHealth(breaker *Breaker, threshold time.Duration) *SaturationHealth {
+	return &SaturationHealth{
+		breaker:   breaker,
+		threshold: threshold,
+		clock:     clock.RealClock{},
+	}
+}
+
+// Healthy returns false if the breaker's queue has been full for the
+// threshold, without a request leaving it in between.
+func (h *SaturationHealth) Healthy() bool {
+	since, saturated := h.breaker.SaturatedSince()
+	return !saturated || h.clock.Since(since) < h.threshold
+}
+
+// ServeHTTP responds with 200 if healthy and 503 otherwise.
+func (h *SaturationHealth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.Healthy() {
+		since, _ := h.breaker.SaturatedSince()
+		http.Error(w, fmt.Sprint("saturated since ", since.UTC().Format(time.RFC3339)), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("healthy"))
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestSaturationHealth(t *testing.T) {
+	const threshold = 10 * time.Second
+	breaker := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1}) // Breaker capacity = 2
+	fakeClock := clock.NewFakePassiveClock(time.Now())
+	health := NewSaturationHealth(breaker, threshold)
+	health.clock = fakeClock
+
+	expectCode := func(want int) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		health.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost"+SaturationHealthPath, nil))
+		if rec.Code != want {
+			t.Errorf("StatusCode = %d, want: %d, body: %q", rec.Code, want, rec.Body.String())
+		}
+	}
+	reqs := newRequestor(breaker)
+	saturate := func() {
+		t.Helper()
+		// One request is running, the other one fills the queue.
+		for i := breaker.InFlight(); i < 2; i++ {
+			reqs.request()
+		}
+		if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+			return breaker.InFlight() == 2, nil
+		}); err != nil {
+			t.Fatalf("InFlight() = %d, want: 2", breaker.InFlight())
+		}
+		// The breaker takes the time of saturation from the real clock.
+		fakeClock.SetTime(time.Now())
+	}
+	expectCode(http.StatusOK)
+
+	// A brief burst.
+	saturate()
+	fakeClock.SetTime(fakeClock.Now().Add(threshold / 2))
+	expectCode(http.StatusOK)
+	reqs.processSuccessfully(t)
+	reqs.processSuccessfully(t)
+	fakeClock.SetTime(fakeClock.Now().Add(threshold))
+	expectCode(http.StatusOK)
+
+	// A momentary saturation, followed by steady traffic below capacity.
+	saturate()
+	fakeClock.SetTime(fakeClock.Now().Add(threshold / 2))
+	reqs.processSuccessfully(t)
+	fakeClock.SetTime(fakeClock.Now().Add(threshold))
+	expectCode(http.StatusOK)
+
+	// A sustained overload.
+	saturate()
+	fakeClock.SetTime(fakeClock.Now().Add(threshold / 2))
+	expectCode(http.StatusOK)
+	fakeClock.SetTime(fakeClock.Now().Add(threshold))
+	expectCode(http.StatusServiceUnavailable)
+
+	// Making room in the queue recovers.
+	reqs.processSuccessfully(t)
+	expectCode(http.StatusOK)
+	reqs.processSuccessfully(t)
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	pkgmetrics "knative.dev/pkg/metrics"
+	"knative.dev/pkg/websocket"
+	"knative.dev/serving/pkg/metrics"
+)
+
+// streamDrainPollInterval is the interval at which Drain checks whether the
+// streams finished.
+const streamDrainPollInterval = 50 * time.Millisecond
+
+// goingAwayCloseFrame is a WebSocket close frame with the 1001 (going away)
+// status code, as sent by a server, i.e. unmasked.
+var goingAwayCloseFrame = []byte{0x88, 0x02, 0x03, 0xe9}
+
+var forceClosedStreamCountM = stats.Int64(
+	"force_closed_stream_count",
+	"The number of streams the queue-proxy closed as they didn't finish while draining",
+	stats.UnitDimensionless)
+
+// StreamDrainer lets the streaming connections, i.e. WebSocket connections and
+// server-sent event streams, finish gracefully once the queue-proxy drains,
+// rather than having them cut once the pod terminates.
+type StreamDrainer struct {
+	statsCtx context.Context
+
+	mu       sync.Mutex
+	draining bool
+	streams  map[*drainStream]struct{}
+}
+
+// NewStreamDrainer creates a StreamDrainer.
+func NewStreamDrainer(ns, service, config, rev, pod string) (*StreamDrainer, error) {
+	if err := pkgmetrics.RegisterResourceView(&view.View{
+		Description: "The number of streams the queue-proxy closed as they didn't finish while draining",
+		Measure:     forceClosedStreamCountM,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey},
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx, err := metrics.PodRevisionContext(pod, "queue-proxy", ns, service, config, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamDrainer{
+		statsCtx: ctx,
+		streams:  map[*drainStream]struct{}{},
+	}, nil
+}
+
+// Handler tracks the streams passing through it, so they can be drained.
+func (d *StreamDrainer) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !mayStream(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		s := &drainStream{cancel: cancel}
+		d.add(s)
+		defer d.remove(s)
+		next.ServeHTTP(&drainResponseWriter{ResponseWriter: w, drainer: d, stream: s}, r.WithContext(ctx))
+	})
+}
+
+// Drain sends the established WebSocket connections a close frame and waits up
+// to settle for the streams to finish, before closing those that didn't. It
+// returns the number of streams it closed. Streams started after Drain was
+// called are sent a close frame once established.
+func (d *StreamDrainer) Drain(settle time.Duration) int {
+	d.mu.Lock()
+	d.draining = true
+	for s := range d.streams {
+		s.goAway()
+	}
+	d.mu.Unlock()
+
+	deadline := time.Now().Add(settle)
+	for d.active() > 0 && time.Now().Before(deadline) {
+		time.Sleep(streamDrainPollInterval)
+	}
+
+	d.mu.Lock()
+	closed := len(d.streams)
+	for s := range d.streams {
+		s.close()
+	}
+	d.mu.Unlock()
+	if closed > 0 {
+		pkgmetrics.Record(d.statsCtx, forceClosedStreamCountM.M(int64(closed)))
+	}
+	return closed
+}
+
+// active returns the number of streams in flight.
+func (d *StreamDrainer) active() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.streams)
+}
+
+func (d *StreamDrainer) add(s *drainStream) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.streams[s] = struct{}{}
+}
+
+func (d *StreamDrainer) remove(s *drainStream) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.streams, s)
+}
+
+// hijacked sets the connection of an established WebSocket stream, which is
+// sent a close frame right away if draining already.
+func (d *StreamDrainer) hijacked(s *drainStream, c *drainConn) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s.conn = c
+	if d.draining {
+		s.goAway()
+	}
+}
+
+// drainStream is a stream in flight. Its fields are guarded by the mutex of
+// the StreamDrainer.
+type drainStream struct {
+	cancel context.CancelFunc
+	// conn is set once the stream is established as a WebSocket connection.
+	conn *drainConn
+}
+
+// goAway asks the client of a WebSocket connection to close it. There's
+// nothing to ask the clients of other streams.
+func (s *drainStream) goAway() {
+	if s.conn != nil {
+		s.conn.goAway()
+	}
+}
+
+// close ends the stream, and closes its connection if it's a WebSocket one.
+func (s *drainStream) close() {
+	s.cancel()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// drainResponseWriter hands out the hijacked connections of WebSocket streams
+// wrapped, so they can be sent a close frame.
+type drainResponseWriter struct {
+	http.ResponseWriter
+	drainer *StreamDrainer
+	stream  *drainStream
+}
+
+var (
+	_ http.Flusher  = (*drainResponseWriter)(nil)
+	_ http.Hijacker = (*drainResponseWriter)(nil)
+)
+
+// Flush implements http.Flusher.
+func (w *drainResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker. The reverse proxy hijacks the connection
+// once the backend accepted the upgrade and writes the upgrade response
+// through the returned bufio.ReadWriter, bypassing the wrapped connection, so
+// all that's written to it are the frames of the backend.
+func (w *drainResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := websocket.HijackIfPossible(w.ResponseWriter)
+	if err != nil {
+		return conn, rw, err
+	}
+	c := &drainConn{Conn: conn}
+	w.drainer.hijacked(w.stream, c)
+	return c, rw, nil
+}
+
+// drainConn is the hijacked connection of a WebSocket client. It follows the
+// frames written to it, so a close frame can be slipped in between two of
+// them.
+type drainConn struct {
+	net.Conn
+
+	mu sync.Mutex
+	// header holds the header of the frame being written, until it's complete.
+	header []byte
+	// payload is the number of payload bytes of the frame being written left.
+	payload uint64
+	// closing is set once the client is to be sent a close frame, closed once
+	// it was.
+	closing, closed bool
+}
+
+// Write implements net.Conn. Once the client was sent a close frame, it must
+// not be sent any other frame, so the remaining frames of the backend are
+// dropped.
+func (c *drainConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return len(p), nil
+	}
+	n, err := c.Conn.Write(p)
+	c.track(p[:n])
+	if err == nil && c.closing {
+		err = c.sendClose()
+	}
+	return n, err
+}
+
+// goAway sends the client a close frame once the frame being written, if
+// any, is complete.
+func (c *drainConn) goAway() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closing = true
+	c.sendClose()
+}
+
+// sendClose sends the client a close frame if it's in between two frames.
+func (c *drainConn) sendClose() error {
+	if c.closed || len(c.header) > 0 || c.payload > 0 {
+		return nil
+	}
+	c.closed = true
+	_, err := c.Conn.Write(goingAwayCloseFrame)
+	return err
+}
+
+// track follows the frames by the bytes written of them.
+func (c *drainConn) track(p []byte) {
+	for len(p) > 0 {
+		if c.payload > 0 {
+			n := uint64(len(p))
+			if n > c.payload {
+				n = c.payload
+			}
+			c.payload -= n
+			p = p[n:]
+			continue
+		}
+		c.header = append(c.header, p[0])
+		p = p[1:]
+		if payload, ok := framePayloadLength(c.header); ok {
+			c.payload = payload
+			c.header = c.header[:0]
+		}
+	}
+}
+
+// framePayloadLength returns the payload length of the WebSocket frame with
+// the header, and whether the header is complete.
+func framePayloadLength(header []byte) (uint64, bool) {
+	if len(header) < 2 {
+		return 0, false
+	}
+	n := 2
+	if header[1]&0x80 != 0 {
+		// The header ends with a masking key.
+		n += 4
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		n += 2
+	case 127:
+		n += 8
+	}
+	if len(header) < n {
+		return 0, false
+	}
+	switch length {
+	case 126:
+		length = uint64(binary.BigEndian.Uint16(header[2:]))
+	case 127:
+		length = binary.BigEndian.Uint64(header[2:])
+	}
+	return length, true
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"knative.dev/pkg/metrics/metricstest"
+	_ "knative.dev/pkg/metrics/testing"
+	"knative.dev/serving/pkg/metrics"
+)
+
+// newWebSocketBackend returns the URL of a server sending WebSocket messages
+// until the client closes the connection.
+func newWebSocketBackend(t *testing.T) *url.URL {
+	var upgrader websocket.Upgrader
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		go func() {
+			// Long enough for the frames to have an extended payload length.
+			msg := []byte(strings.Repeat("a", 200))
+			for conn.WriteMessage(websocket.TextMessage, msg) == nil {
+				time.Sleep(time.Millisecond)
+			}
+		}()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(backend.Close)
+	u, _ := url.Parse(backend.URL)
+	return u
+}
+
+func newTestStreamDrainer(t *testing.T) *StreamDrainer {
+	t.Cleanup(func() { metricstest.Unregister(forceClosedStreamCountM.Name()) })
+	d, err := NewStreamDrainer("ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("NewStreamDrainer() =", err)
+	}
+	return d
+}
+
+func TestStreamDrainerWebSocket(t *testing.T) {
+	d := newTestStreamDrainer(t)
+	proxy := httputil.NewSingleHostReverseProxy(newWebSocketBackend(t))
+	server := httptest.NewServer(d.Handler(proxy))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal("Dial() =", err)
+	}
+	defer conn.Close()
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatal("ReadMessage() =", err)
+	}
+
+	closed := make(chan int)
+	go func() {
+		closed <- d.Drain(10 * time.Second)
+	}()
+
+	// The messages in flight are received intact before the close frame.
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+				t.Fatalf("ReadMessage() = %v, want a close error with code %d", err, websocket.CloseGoingAway)
+			}
+			break
+		}
+		if len(msg) != 200 {
+			t.Fatalf("Got a message of %d bytes, want: 200", len(msg))
+		}
+	}
+	// Replying to the close frame lets the backend close the connection.
+	select {
+	case got := <-closed:
+		if got != 0 {
+			t.Errorf("Drain() = %d, want: 0", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Drain() didn't return once the connection was closed")
+	}
+}
+
+func TestStreamDrainerForceClose(t *testing.T) {
+	d := newTestStreamDrainer(t)
+	sseBackend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer sseBackend.Close()
+	sseURL, _ := url.Parse(sseBackend.URL)
+	sse := httputil.NewSingleHostReverseProxy(sseURL)
+	ws := httputil.NewSingleHostReverseProxy(newWebSocketBackend(t))
+	server := httptest.NewServer(d.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			ws.ServeHTTP(w, r)
+			return
+		}
+		sse.ServeHTTP(w, r)
+	})))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Do() =", err)
+	}
+	defer resp.Body.Close()
+
+	// A client ignoring the close frame.
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal("Dial() =", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"))
+	br := bufio.NewReader(conn)
+	wsResp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal("ReadResponse() =", err)
+	}
+	if got, want := wsResp.StatusCode, http.StatusSwitchingProtocols; got != want {
+		t.Fatalf("StatusCode = %d, want: %d", got, want)
+	}
+
+	if got, want := d.Drain(100*time.Millisecond), 2; got != want {
+		t.Errorf("Drain() = %d, want: %d", got, want)
+	}
+	// The close frame is the last one the client is sent.
+	frames, _ := ioutil.ReadAll(br)
+	if !bytes.HasSuffix(frames, goingAwayCloseFrame) {
+		t.Errorf("Frames end with %x, want the close frame %x", frames[len(frames)-4:], goingAwayCloseFrame)
+	}
+	if _, err := ioutil.ReadAll(resp.Body); err == nil {
+		t.Error("The event stream finished, want it cut")
+	}
+	metricstest.AssertMetric(t, metricstest.IntMetric("force_closed_stream_count", 2, map[string]string{
+		metrics.LabelPodName:       "pod",
+		metrics.LabelContainerName: "queue-proxy",
+	}))
+}
+
+func TestFramePayloadLength(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      []byte
+		wantLength  uint64
+		wantPartial bool
+	}{{
+		name:        "incomplete",
+		header:      []byte{0x81},
+		wantPartial: true,
+	}, {
+		name:       "short",
+		header:     []byte{0x81, 0x05},
+		wantLength: 5,
+	}, {
+		name:   "masked",
+		header: []byte{0x81, 0x85, 1, 2, 3, 4},
+		// The masking key is part of the header.
+		wantLength: 5,
+	}, {
+		name:        "masked incomplete",
+		header:      []byte{0x81, 0x85, 1, 2},
+		wantPartial: true,
+	}, {
+		name:       "extended",
+		header:     []byte{0x82, 126, 0x01, 0x00},
+		wantLength: 256,
+	}, {
+		name:        "extended incomplete",
+		header:      []byte{0x82, 126, 0x01},
+		wantPartial: true,
+	}, {
+		name:       "long",
+		header:     []byte{0x82, 127, 0, 0, 0, 0, 0, 0x01, 0x00, 0x00},
+		wantLength: 65536,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			length, ok := framePayloadLength(tc.header)
+			if ok == tc.wantPartial {
+				t.Fatalf("framePayloadLength() complete = %v, want: %v", ok, !tc.wantPartial)
+			}
+			if length != tc.wantLength {
+				t.Errorf("framePayloadLength() = %d, want: %d", length, tc.wantLength)
+			}
+		})
+	}
+}
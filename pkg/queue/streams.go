@@ -0,0 +1,255 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.uber.org/atomic"
+	"knative.dev/pkg/websocket"
+)
+
+// StreamMode defines how long-lived streaming connections are accounted for.
+type StreamMode string
+
+const (
+	// StreamModeNone accounts for streams like for any other request.
+	StreamModeNone StreamMode = ""
+	// StreamModeTrack counts the established streams separately, while they
+	// keep holding their concurrency slot.
+	StreamModeTrack StreamMode = "track"
+	// StreamModeRelease counts the established streams separately and
+	// releases their concurrency slot once the handshake completed, so they
+	// neither take capacity of the breaker nor count as concurrency for the
+	// autoscaler.
+	StreamModeRelease StreamMode = "release"
+)
+
+// ParseStreamMode parses a StreamMode.
+func ParseStreamMode(s string) (StreamMode, error) {
+	switch m := StreamMode(s); m {
+	case StreamModeNone, StreamModeTrack, StreamModeRelease:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown stream mode %q", s)
+}
+
+// The protocols of streams.
+const (
+	StreamProtocolWebSocket = "websocket"
+	StreamProtocolSSE       = "sse"
+)
+
+// streamProtocols are the protocols streams are counted by.
+var streamProtocols = []string{StreamProtocolWebSocket, StreamProtocolSSE}
+
+// Streams tracks the streaming connections, i.e. WebSocket connections and
+// server-sent event streams, from the completion of their handshake until
+// they're closed.
+type Streams struct {
+	release   bool
+	websocket atomic.Int64
+	sse       atomic.Int64
+}
+
+// NewStreams creates a Streams accounting for streams by the mode, which
+// must not be StreamModeNone.
+func NewStreams(mode StreamMode) *Streams {
+	return &Streams{release: mode == StreamModeRelease}
+}
+
+// Active returns the number of established streams of the protocol.
+func (s *Streams) Active(protocol string) int {
+	switch protocol {
+	case StreamProtocolWebSocket:
+		return int(s.websocket.Load())
+	case StreamProtocolSSE:
+		return int(s.sse.Load())
+	}
+	return 0
+}
+
+// Handler tracks the streams passing through it. It belongs outside of the
+// ProxyHandler, which releases the concurrency slot of a stream once it's
+// established, if the streams are released.
+func (s *Streams) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !mayStream(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		st := &stream{}
+		if s.release {
+			r = r.WithContext(context.WithValue(r.Context(), streamKey{}, st))
+		}
+		sw := &streamResponseWriter{ResponseWriter: w, streams: s, stream: st}
+		defer sw.closed()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// counter returns the counter of the established streams of the protocol.
+func (s *Streams) counter(protocol string) *atomic.Int64 {
+	if protocol == StreamProtocolWebSocket {
+		return &s.websocket
+	}
+	return &s.sse
+}
+
+// mayStream returns whether the request asks for a WebSocket connection or a
+// server-sent event stream.
+func mayStream(r *http.Request) bool {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+type streamKey struct{}
+
+// stream runs the functions releasing the resources of a request once it's
+// established as a stream.
+type stream struct {
+	mu          sync.Mutex
+	established bool
+	releases    []func()
+}
+
+// onEstablished registers f to be run once the stream is established.
+func (s *stream) onEstablished(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.established {
+		f()
+		return
+	}
+	s.releases = append(s.releases, f)
+}
+
+// establish runs the registered functions.
+func (s *stream) establish() {
+	s.mu.Lock()
+	s.established = true
+	releases := s.releases
+	s.releases = nil
+	s.mu.Unlock()
+	for _, f := range releases {
+		f()
+	}
+}
+
+// streamOf returns the stream the request of ctx is released with once it's
+// established, nil if it isn't a stream to be released. Callers only set up
+// the release for streams, as it allocates, which plain requests shouldn't.
+func streamOf(ctx context.Context) *stream {
+	s, _ := ctx.Value(streamKey{}).(*stream)
+	return s
+}
+
+// releaseWith returns a function running f once, which is also run once the
+// stream is established, e.g. to release its concurrency slot early.
+func (s *stream) releaseWith(f func()) func() {
+	var once sync.Once
+	release := func() { once.Do(f) }
+	s.onEstablished(release)
+	return release
+}
+
+// streamResponseWriter notices a stream being established by a successful
+// upgrade or an event stream response.
+type streamResponseWriter struct {
+	http.ResponseWriter
+	streams *Streams
+	stream  *stream
+
+	wroteHeader bool
+	// protocol is set once the stream is established.
+	protocol string
+}
+
+var (
+	_ http.Flusher  = (*streamResponseWriter)(nil)
+	_ http.Hijacker = (*streamResponseWriter)(nil)
+)
+
+// WriteHeader implements http.ResponseWriter.
+func (w *streamResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader && code >= http.StatusOK {
+		w.wroteHeader = true
+		if code == http.StatusOK && isEventStream(w.Header()) {
+			w.established(StreamProtocolSSE)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *streamResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher.
+func (w *streamResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker. The reverse proxy hijacks the connection
+// once the backend accepted the upgrade.
+func (w *streamResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := websocket.HijackIfPossible(w.ResponseWriter)
+	if err == nil {
+		w.established(StreamProtocolWebSocket)
+	}
+	return conn, rw, err
+}
+
+// established counts the stream as established and releases its resources.
+func (w *streamResponseWriter) established(protocol string) {
+	if w.protocol != "" {
+		return
+	}
+	w.protocol = protocol
+	w.streams.counter(protocol).Inc()
+	w.stream.establish()
+}
+
+// closed stops counting the stream once the request is done.
+func (w *streamResponseWriter) closed() {
+	if w.protocol != "" {
+		w.streams.counter(w.protocol).Dec()
+	}
+}
+
+// isEventStream returns whether the header is that of a server-sent event
+// stream.
+func isEventStream(h http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && mediaType == "text/event-stream"
+}
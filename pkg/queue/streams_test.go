@@ -0,0 +1,215 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/util/wait"
+	network "knative.dev/networking/pkg"
+)
+
+func TestParseStreamMode(t *testing.T) {
+	for _, s := range []string{"", "track", "release"} {
+		if got, err := ParseStreamMode(s); err != nil || string(got) != s {
+			t.Errorf("ParseStreamMode(%q) = %q, %v", s, got, err)
+		}
+	}
+	if _, err := ParseStreamMode("drop"); err == nil {
+		t.Error("ParseStreamMode(drop) succeeded, want error")
+	}
+}
+
+// eventStream serves a server-sent event stream until done is closed, if the
+// request asks for one.
+func eventStream(done chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !mayStream(r) {
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.Write([]byte("data: hello\n\n"))
+		w.(http.Flusher).Flush()
+		<-done
+	})
+}
+
+func sseRequest() *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	return req
+}
+
+func pollActiveStreams(t *testing.T, s *Streams, protocol string, want int) {
+	t.Helper()
+	if err := wait.PollImmediate(time.Millisecond, semAcquireTimeout, func() (bool, error) {
+		return s.Active(protocol) == want, nil
+	}); err != nil {
+		t.Fatalf("Active(%s) = %d, want: %d", protocol, s.Active(protocol), want)
+	}
+}
+
+func TestStreamsReleaseSSE(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1})
+	stats := NewRequestStats(time.Now())
+	streams := NewStreams(StreamModeRelease)
+	done := make(chan struct{})
+	h := streams.Handler(ProxyHandler(b, stats, false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, eventStream(done)))
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		h.ServeHTTP(httptest.NewRecorder(), sseRequest())
+	}()
+	pollActiveStreams(t, streams, StreamProtocolSSE, 1)
+
+	// The established stream neither holds its slot nor counts as in flight.
+	if got := b.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d, want: 0", got)
+	}
+	if got := stats.Report(time.Now()).AverageConcurrency; got >= 1 {
+		t.Errorf("AverageConcurrency = %v, want < 1", got)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+
+	close(done)
+	<-served
+	if got := streams.Active(StreamProtocolSSE); got != 0 {
+		t.Errorf("Active(sse) = %d, want: 0", got)
+	}
+	if got := b.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d, want: 0", got)
+	}
+}
+
+func TestStreamsTrackSSE(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1})
+	stats := NewRequestStats(time.Now())
+	streams := NewStreams(StreamModeTrack)
+	done := make(chan struct{})
+	h := streams.Handler(ProxyHandler(b, stats, false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, eventStream(done)))
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		h.ServeHTTP(httptest.NewRecorder(), sseRequest())
+	}()
+	pollActiveStreams(t, streams, StreamProtocolSSE, 1)
+
+	// The stream keeps its slot.
+	if got := b.InFlight(); got != 1 {
+		t.Errorf("InFlight() = %d, want: 1", got)
+	}
+	close(done)
+	<-served
+	pollActiveStreams(t, streams, StreamProtocolSSE, 0)
+}
+
+func TestStreamsNotAStream(t *testing.T) {
+	streams := NewStreams(StreamModeRelease)
+	h := streams.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A plain response to an event stream request isn't a stream.
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("nope"))
+		if got := streams.Active(StreamProtocolSSE); got != 0 {
+			t.Errorf("Active(sse) = %d, want: 0", got)
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), sseRequest())
+}
+
+func TestStreamsReleaseWebSocket(t *testing.T) {
+	b := NewBreaker(BreakerParams{QueueDepth: 1, MaxConcurrency: 1, InitialCapacity: 1})
+	stats := NewRequestStats(time.Now())
+	streams := NewStreams(StreamModeRelease)
+	done := make(chan struct{})
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error("Hijack() =", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		<-done
+	})
+	server := httptest.NewServer(streams.Handler(ProxyHandler(b, stats, false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, backend)))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal("Dial() =", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal("ReadResponse() =", err)
+	}
+	if got, want := resp.StatusCode, http.StatusSwitchingProtocols; got != want {
+		t.Fatalf("StatusCode = %d, want: %d", got, want)
+	}
+
+	pollActiveStreams(t, streams, StreamProtocolWebSocket, 1)
+	if got := b.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d, want: 0", got)
+	}
+	close(done)
+	pollActiveStreams(t, streams, StreamProtocolWebSocket, 0)
+}
+
+func TestPrometheusStatsReporterStreams(t *testing.T) {
+	reporter, err := NewPrometheusStatsReporter(namespace, config, revision, pod, time.Second, 1 /*containerConcurrency*/)
+	if err != nil {
+		t.Fatal("NewPrometheusStatsReporter() =", err)
+	}
+	streams := NewStreams(StreamModeTrack)
+	reporter.ObserveStreams(streams)
+	streams.counter(StreamProtocolSSE).Add(2)
+	reporter.Report(network.RequestStatsReport{})
+
+	active := func(protocol string) float64 {
+		m := dto.Metric{}
+		if err := activeStreamsGV.With(prometheus.Labels{
+			protocolLabel:          protocol,
+			destinationNsLabel:     namespace,
+			destinationConfigLabel: config,
+			destinationRevLabel:    revision,
+			destinationPodLabel:    pod,
+		}).Write(&m); err != nil {
+			t.Fatal("Gauge.Write() error =", err)
+		}
+		return m.Gauge.GetValue()
+	}
+	for protocol, want := range map[string]float64{StreamProtocolSSE: 2, StreamProtocolWebSocket: 0} {
+		if got := active(protocol); got != want {
+			t.Errorf("Active streams of %s = %v, want: %v", protocol, got, want)
+		}
+	}
+}
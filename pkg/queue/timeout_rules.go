@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timeoutRule assigns timeout to the requests whose path starts with prefix
+// and, if set, whose method is method.
+type timeoutRule struct {
+	method  string
+	prefix  string
+	timeout time.Duration
+}
+
+// TimeoutRules override the time to first byte timeout of the requests
+// matching a method and a path prefix.
+type TimeoutRules struct {
+	// rules are sorted by descending length of prefix, and rules with a
+	// method first for the same prefix, so the most specific matching rule
+	// is found first.
+	rules []timeoutRule
+}
+
+// ParseTimeoutRules parses the JSON serialized timeouts in seconds by path
+// prefix, optionally preceded by a method and a space, e.g.
+// {"/batch": 600, "POST /upload": 60}.
+func ParseTimeoutRules(s string) (*TimeoutRules, error) {
+	t := &TimeoutRules{}
+	if s == "" {
+		return t, nil
+	}
+	var timeouts map[string]int64
+	if err := json.Unmarshal([]byte(s), &timeouts); err != nil {
+		return nil, err
+	}
+	for key, seconds := range timeouts {
+		method, prefix, err := ParseTimeoutRuleKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if seconds < 1 {
+			return nil, fmt.Errorf("timeout %d of %q is not positive", seconds, key)
+		}
+		t.rules = append(t.rules, timeoutRule{
+			method:  method,
+			prefix:  prefix,
+			timeout: time.Duration(seconds) * time.Second,
+		})
+	}
+	sort.Slice(t.rules, func(i, j int) bool {
+		if li, lj := len(t.rules[i].prefix), len(t.rules[j].prefix); li != lj {
+			return li > lj
+		}
+		return t.rules[i].method > t.rules[j].method
+	})
+	return t, nil
+}
+
+// ParseTimeoutRuleKey splits the key of a timeout rule into its method, empty
+// if the rule is for all methods, and its path prefix.
+func ParseTimeoutRuleKey(key string) (method, prefix string, err error) {
+	prefix = key
+	if i := strings.IndexByte(key, ' '); i >= 0 {
+		method, prefix = key[:i], key[i+1:]
+		if method == "" {
+			return "", "", fmt.Errorf("rule %q has an empty method", key)
+		}
+	}
+	if !strings.HasPrefix(prefix, "/") || strings.Contains(prefix, " ") {
+		return "", "", fmt.Errorf("rule %q doesn't have a path prefix starting with /", key)
+	}
+	return method, prefix, nil
+}
+
+// Timeout returns the timeout of the most specific rule matching the request,
+// or def if none does.
+func (t *TimeoutRules) Timeout(r *http.Request, def time.Duration) time.Duration {
+	for _, rule := range t.rules {
+		if (rule.method == "" || rule.method == r.Method) && strings.HasPrefix(r.URL.Path, rule.prefix) {
+			return rule.timeout
+		}
+	}
+	return def
+}
+
+// Max returns the longest timeout of the rules, or 0 if there are none.
+func (t *TimeoutRules) Max() time.Duration {
+	var max time.Duration
+	for _, rule := range t.rules {
+		if rule.timeout > max {
+			max = rule.timeout
+		}
+	}
+	return max
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutRules(t *testing.T) {
+	rules, err := ParseTimeoutRules(`{"/batch": 600, "POST /batch": 900, "/batch/quick": 5, "GET /": 20}`)
+	if err != nil {
+		t.Fatal("ParseTimeoutRules() =", err)
+	}
+	const def = 10 * time.Second
+
+	tests := []struct {
+		method, path string
+		want         time.Duration
+	}{
+		{http.MethodPut, "/batch/job", 600 * time.Second},
+		{http.MethodPost, "/batch/job", 900 * time.Second},
+		{http.MethodPost, "/batch/quick/job", 5 * time.Second},
+		{http.MethodGet, "/index.html", 20 * time.Second},
+		{http.MethodPut, "/index.html", def},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(tc.method, "http://example.com"+tc.path, nil)
+		if got := rules.Timeout(r, def); got != tc.want {
+			t.Errorf("Timeout(%s %s) = %v, want %v", tc.method, tc.path, got, tc.want)
+		}
+	}
+	if got, want := rules.Max(), 900*time.Second; got != want {
+		t.Errorf("Max() = %v, want %v", got, want)
+	}
+}
+
+func TestTimeoutRulesEmpty(t *testing.T) {
+	rules, err := ParseTimeoutRules("")
+	if err != nil {
+		t.Fatal("ParseTimeoutRules() =", err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	if got, want := rules.Timeout(r, time.Second), time.Second; got != want {
+		t.Errorf("Timeout() = %v, want %v", got, want)
+	}
+	if got := rules.Max(); got != 0 {
+		t.Errorf("Max() = %v, want 0", got)
+	}
+}
+
+func TestParseTimeoutRulesErrors(t *testing.T) {
+	for _, s := range []string{
+		`[600]`,
+		`{"/batch": 0}`,
+		`{"batch": 600}`,
+		`{" /batch": 600}`,
+		`{"POST batch": 600}`,
+		`{"POST /batch job": 600}`,
+	} {
+		if _, err := ParseTimeoutRules(s); err == nil {
+			t.Errorf("ParseTimeoutRules(%s) = nil, want an error", s)
+		}
+	}
+}
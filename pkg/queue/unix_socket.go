@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+	pkgnet "knative.dev/pkg/network"
+)
+
+// UnixSocketTransport is an http.RoundTripper sending all requests to the user
+// container over the Unix domain socket it listens on, regardless of their
+// host. Like pkgnet.NewProxyAutoTransport, it uses HTTP or H2C based on the
+// request's HTTP version and doesn't ask for compressed responses.
+type UnixSocketTransport struct {
+	v1 *http.Transport
+	v2 *http2.Transport
+}
+
+var _ http.RoundTripper = (*UnixSocketTransport)(nil)
+
+// NewUnixSocketTransport creates a UnixSocketTransport to the socket at path,
+// keeping up to maxIdle idle connections.
+func NewUnixSocketTransport(path string, maxIdle int) *UnixSocketTransport {
+	dial := UnixSocketDialer(path)
+
+	v1 := http.DefaultTransport.(*http.Transport).Clone()
+	v1.DialContext = dial
+	v1.MaxIdleConns = maxIdle
+	v1.MaxIdleConnsPerHost = maxIdle
+	v1.ForceAttemptHTTP2 = false
+	v1.DisableCompression = true
+
+	return &UnixSocketTransport{
+		v1: v1,
+		v2: &http2.Transport{
+			AllowHTTP:          true,
+			DisableCompression: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(context.Background(), network, addr)
+			},
+		},
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *UnixSocketTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.ProtoMajor == 2 {
+		return t.v2.RoundTrip(r)
+	}
+	return t.v1.RoundTrip(r)
+}
+
+// CloseIdleConnections closes the idle connections to the user container.
+func (t *UnixSocketTransport) CloseIdleConnections() {
+	t.v1.CloseIdleConnections()
+	t.v2.CloseIdleConnections()
+}
+
+// UnixSocketDialer returns a dial function connecting to the Unix domain
+// socket at path, whatever network and address it's asked to dial, retrying
+// with pkgnet.DialWithBackOff if connecting times out.
+func UnixSocketDialer(path string) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return pkgnet.DialWithBackOff(ctx, "unix", path)
+	}
+}
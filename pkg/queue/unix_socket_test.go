@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestUnixSocketTransport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	server := &http.Server{Handler: h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), &http2.Server{})}
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+
+	transport := NewUnixSocketTransport(path, 10 /*maxIdle*/)
+	defer transport.CloseIdleConnections()
+	for protoMajor, want := range map[int]string{1: "HTTP/1.1", 2: "HTTP/2.0"} {
+		// The host is ignored, the request goes to the socket.
+		req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:8080/", nil)
+		if err != nil {
+			t.Fatal("NewRequest() =", err)
+		}
+		req.ProtoMajor = protoMajor
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip(HTTP/%d) = %v", protoMajor, err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal("ReadAll() =", err)
+		}
+		if got := string(body); got != want {
+			t.Errorf("Proto = %q, want: %q", got, want)
+		}
+	}
+}
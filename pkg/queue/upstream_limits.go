@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// maxBandwidthBurst is the most bytes of the responses read at once when
+// their bandwidth is limited, so that it's shared fairly among them.
+const maxBandwidthBurst = 32 << 10
+
+// upstreamLimitTransport limits the connections to the user container in use
+// at once and the bandwidth of its responses.
+type upstreamLimitTransport struct {
+	next http.RoundTripper
+	// conns holds a slot per connection in use, nil if unlimited.
+	conns chan struct{}
+	// bandwidth is shared by all responses, nil if unlimited.
+	bandwidth *rate.Limiter
+}
+
+// NewUpstreamLimitTransport limits the requests made via next to maxConns at
+// once, i.e. the connections to the user container in use, the others waiting
+// for one to be free. The bodies of all the responses are read at most at
+// bytesPerSecond together. A limit of zero or less disables it, and next is
+// returned unchanged if both are.
+func NewUpstreamLimitTransport(next http.RoundTripper, maxConns int, bytesPerSecond int64) http.RoundTripper {
+	if maxConns <= 0 && bytesPerSecond <= 0 {
+		return next
+	}
+	t := &upstreamLimitTransport{next: next}
+	if maxConns > 0 {
+		t.conns = make(chan struct{}, maxConns)
+	}
+	if bytesPerSecond > 0 {
+		burst := maxBandwidthBurst
+		if bytesPerSecond < maxBandwidthBurst {
+			burst = int(bytesPerSecond)
+		}
+		t.bandwidth = rate.NewLimiter(rate.Limit(bytesPerSecond), burst)
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *upstreamLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	release := func() {}
+	if t.conns != nil {
+		select {
+		case t.conns <- struct{}{}:
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+		var once sync.Once
+		release = func() { once.Do(func() { <-t.conns }) }
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	// The connection is in use until the body is closed.
+	body := &upstreamBody{
+		ReadCloser: resp.Body,
+		ctx:        r.Context(),
+		bandwidth:  t.bandwidth,
+		release:    release,
+	}
+	if rwc, ok := resp.Body.(io.ReadWriteCloser); ok {
+		// The body of an upgraded connection has to stay writable for the
+		// proxy, which copies both ways.
+		resp.Body = &upstreamUpgradeBody{upstreamBody: body, writer: rwc}
+	} else {
+		resp.Body = body
+	}
+	return resp, nil
+}
+
+// upstreamBody reads a response body within the bandwidth, if limited, and
+// releases its connection once closed.
+type upstreamBody struct {
+	io.ReadCloser
+	ctx       context.Context
+	bandwidth *rate.Limiter
+	release   func()
+}
+
+func (b *upstreamBody) Read(p []byte) (int, error) {
+	if b.bandwidth == nil {
+		return b.ReadCloser.Read(p)
+	}
+	if burst := b.bandwidth.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := b.bandwidth.WaitN(b.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (b *upstreamBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+type upstreamUpgradeBody struct {
+	*upstreamBody
+	writer io.Writer
+}
+
+func (b *upstreamUpgradeBody) Write(p []byte) (int, error) {
+	return b.writer.Write(p)
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bodyTransport responds with body.
+type bodyTransport struct {
+	body string
+}
+
+func (t *bodyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(t.body)),
+	}, nil
+}
+
+func TestUpstreamLimitTransportUnlimited(t *testing.T) {
+	next := &bodyTransport{}
+	if got := NewUpstreamLimitTransport(next, 0, 0); got != next {
+		t.Errorf("NewUpstreamLimitTransport() = %v, want the transport unchanged", got)
+	}
+}
+
+func TestUpstreamLimitTransportConnections(t *testing.T) {
+	next := &bodyTransport{body: "ok"}
+	transport := NewUpstreamLimitTransport(next, 2, 0)
+
+	var bodies []io.Closer
+	for i := 0; i < 2; i++ {
+		resp, err := transport.RoundTrip(httptest.NewRequest(http.MethodGet, "http://target", nil))
+		if err != nil {
+			t.Fatal("RoundTrip() =", err)
+		}
+		bodies = append(bodies, resp.Body)
+	}
+
+	// A third request waits for a connection to be free.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "http://target", nil).WithContext(ctx)
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RoundTrip() = %v, want: %v", err, context.DeadlineExceeded)
+	}
+
+	done := make(chan error)
+	go func() {
+		resp, err := transport.RoundTrip(httptest.NewRequest(http.MethodGet, "http://target", nil))
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatal("RoundTrip() returned before a connection was free:", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// Closing a body twice only frees its connection once.
+	bodies[0].Close()
+	bodies[0].Close()
+	if err := <-done; err != nil {
+		t.Error("RoundTrip() =", err)
+	}
+	bodies[1].Close()
+	if got := len(transport.(*upstreamLimitTransport).conns); got != 0 {
+		t.Errorf("%d connections in use, want none", got)
+	}
+}
+
+func TestUpstreamLimitTransportBandwidth(t *testing.T) {
+	const bytesPerSecond = 10000
+	body := strings.Repeat("a", bytesPerSecond*3/2)
+	transport := NewUpstreamLimitTransport(&bodyTransport{body: body}, 0, bytesPerSecond)
+
+	start := time.Now()
+	resp, err := transport.RoundTrip(httptest.NewRequest(http.MethodGet, "http://target", nil))
+	if err != nil {
+		t.Fatal("RoundTrip() =", err)
+	}
+	defer resp.Body.Close()
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal("ReadAll() =", err)
+	}
+	if string(got) != body {
+		t.Errorf("Read %d bytes, want: %d", len(got), len(body))
+	}
+	// A second's worth of bytes is read right away, the rest within half a
+	// second.
+	if elapsed, want := time.Since(start), 400*time.Millisecond; elapsed < want {
+		t.Errorf("Read the body in %v, want at least %v", elapsed, want)
+	}
+}
+
+// upgradeTransport responds with an upgraded connection.
+type upgradeTransport struct{}
+
+type nopReadWriteCloser struct {
+	io.Reader
+	io.Writer
+}
+
+func (nopReadWriteCloser) Close() error { return nil }
+
+func (upgradeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusSwitchingProtocols,
+		Body:       nopReadWriteCloser{strings.NewReader(""), io.Discard},
+	}, nil
+}
+
+func TestUpstreamLimitTransportUpgrade(t *testing.T) {
+	transport := NewUpstreamLimitTransport(upgradeTransport{}, 1, 1000)
+	resp, err := transport.RoundTrip(httptest.NewRequest(http.MethodGet, "http://target", nil))
+	if err != nil {
+		t.Fatal("RoundTrip() =", err)
+	}
+	if _, ok := resp.Body.(io.ReadWriteCloser); !ok {
+		t.Errorf("Body %T of the upgraded connection isn't writable", resp.Body)
+	}
+	resp.Body.Close()
+	if got := len(transport.(*upstreamLimitTransport).conns); got != 0 {
+		t.Errorf("%d connections in use, want none", got)
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.uber.org/atomic"
+
+	network "knative.dev/networking/pkg"
+	pkgmetrics "knative.dev/pkg/metrics"
+	"knative.dev/serving/pkg/metrics"
+)
+
+const (
+	// WarmUpFailedReason is the reason of the event recorded once the
+	// warm-up call to the user container failed.
+	WarmUpFailedReason = "WarmUpFailed"
+
+	// WarmUpUserAgent is the user-agent header value set in the warm-up
+	// calls to the user container.
+	WarmUpUserAgent = "Knative-Queue-Proxy-Warm-Up"
+
+	// defaultWarmUpTimeout is the timeout of the warm-up call if the hook
+	// doesn't say.
+	defaultWarmUpTimeout = time.Minute
+	// warmUpRetryDelay is the time after a failed warm-up call before the
+	// next one, so the failures aren't recorded at the rate of the probes.
+	warmUpRetryDelay = 10 * time.Second
+)
+
+// WarmUpHook is the JSON representation of the call made to the user
+// container once it's ready, before the pod is.
+type WarmUpHook struct {
+	// Path is the path called, e.g. "/warm-up".
+	Path string `json:"path"`
+	// Status is the status the call has to respond with, 200 by default.
+	Status int `json:"status,omitempty"`
+	// TimeoutSeconds is the time the call may take, a minute by default.
+	TimeoutSeconds int64 `json:"timeoutSeconds,omitempty"`
+	// Payload is the body of the call, made with POST if set, with GET
+	// otherwise.
+	Payload string `json:"payload,omitempty"`
+}
+
+// ParseWarmUpHook parses the JSON encoded WarmUpHook, with the defaults
+// applied.
+func ParseWarmUpHook(s string) (*WarmUpHook, error) {
+	h := &WarmUpHook{}
+	if err := json.Unmarshal([]byte(s), h); err != nil {
+		return nil, fmt.Errorf("failed to parse the warm-up hook: %w", err)
+	}
+	if !strings.HasPrefix(h.Path, "/") {
+		return nil, fmt.Errorf("warm-up path %q must start with /", h.Path)
+	}
+	if h.Status == 0 {
+		h.Status = http.StatusOK
+	} else if h.Status < 100 || h.Status > 599 {
+		return nil, fmt.Errorf("warm-up status %d is not an HTTP status", h.Status)
+	}
+	if h.TimeoutSeconds < 0 {
+		return nil, fmt.Errorf("warm-up timeout %ds must not be negative", h.TimeoutSeconds)
+	}
+	return h, nil
+}
+
+// timeout returns the time the call may take.
+func (h *WarmUpHook) timeout() time.Duration {
+	if h.TimeoutSeconds == 0 {
+		return defaultWarmUpTimeout
+	}
+	return time.Duration(h.TimeoutSeconds) * time.Second
+}
+
+// WarmUp calls the WarmUpHook of the user container once the container is
+// ready, so caches and models are loaded before requests are routed to the
+// pod. The call is made once per pod, and again after a failure until it
+// succeeds.
+type WarmUp struct {
+	hook   WarmUpHook
+	target string
+	client *http.Client
+	// report is called with the time each call took and why it failed, if
+	// it did.
+	report     func(time.Duration, error)
+	retryDelay time.Duration
+
+	// done is set once a call succeeded. running is set while a call is
+	// made, and no call is made before retryAt, in Unix nanoseconds.
+	done    atomic.Bool
+	running atomic.Bool
+	retryAt atomic.Int64
+}
+
+// NewWarmUp creates a WarmUp calling the hook on the user container at
+// target, e.g. "http://127.0.0.1:8080", through transport. report, if not
+// nil, is called after every call.
+func NewWarmUp(hook *WarmUpHook, target string, transport http.RoundTripper, report func(time.Duration, error)) *WarmUp {
+	return &WarmUp{
+		hook:       *hook,
+		target:     target,
+		client:     &http.Client{Transport: transport},
+		report:     report,
+		retryDelay: warmUpRetryDelay,
+	}
+}
+
+// Prober wraps the prober of the user container to only be ready once the
+// warm-up call succeeded. The call is started in the background the first
+// time prober is, and the pod reported not ready until it's done.
+func (w *WarmUp) Prober(prober func() bool) func() bool {
+	return func() bool {
+		if !prober() {
+			return false
+		}
+		if w.done.Load() {
+			return true
+		}
+		if time.Now().UnixNano() >= w.retryAt.Load() && w.running.CAS(false, true) {
+			go w.run()
+		}
+		return false
+	}
+}
+
+func (w *WarmUp) run() {
+	defer w.running.Store(false)
+	start := time.Now()
+	err := w.call()
+	if w.report != nil {
+		w.report(time.Since(start), err)
+	}
+	if err != nil {
+		w.retryAt.Store(time.Now().Add(w.retryDelay).UnixNano())
+		return
+	}
+	w.done.Store(true)
+}
+
+// call makes the warm-up call, returning why it failed, if it did.
+func (w *WarmUp) call() error {
+	ctx, cancel := context.WithTimeout(context.Background(), w.hook.timeout())
+	defer cancel()
+
+	method, body := http.MethodGet, io.Reader(nil)
+	if w.hook.Payload != "" {
+		method, body = http.MethodPost, strings.NewReader(w.hook.Payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, w.target+w.hook.Path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create the warm-up request: %w", err)
+	}
+	req.Header.Set(network.UserAgentKey, WarmUpUserAgent)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("warm-up call to %s timed out after %v", w.hook.Path, w.hook.timeout())
+		}
+		return fmt.Errorf("warm-up call to %s failed: %w", w.hook.Path, err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != w.hook.Status {
+		return fmt.Errorf("warm-up call to %s returned status %d, want: %d", w.hook.Path, resp.StatusCode, w.hook.Status)
+	}
+	return nil
+}
+
+var (
+	warmUpLatencyInMsecM = stats.Float64(
+		"warm_up_latencies",
+		"The time the warm-up calls to the user container take in millisecond",
+		stats.UnitMilliseconds)
+
+	// warmUpResultKey tags the measure with "success" or "failure".
+	warmUpResultKey = tag.MustNewKey("result")
+)
+
+// WarmUpMetrics records the warm-up calls with OpenCensus.
+type WarmUpMetrics struct {
+	statsCtx context.Context
+}
+
+// NewWarmUpMetrics creates WarmUpMetrics for the given revision and pod.
+func NewWarmUpMetrics(ns, service, config, rev, pod string) (*WarmUpMetrics, error) {
+	if err := pkgmetrics.RegisterResourceView(&view.View{
+		Description: "The time the warm-up calls to the user container take in millisecond",
+		Measure:     warmUpLatencyInMsecM,
+		Aggregation: defaultLatencyDistribution,
+		TagKeys:     []tag.Key{metrics.PodKey, metrics.ContainerKey, warmUpResultKey},
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx, err := metrics.PodRevisionContext(pod, "queue-proxy", ns, service, config, rev)
+	if err != nil {
+		return nil, err
+	}
+	return &WarmUpMetrics{statsCtx: ctx}, nil
+}
+
+// Record records a warm-up call that took latency and failed with err, if
+// not nil.
+func (m *WarmUpMetrics) Record(latency time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	ctx, tagErr := tag.New(m.statsCtx, tag.Upsert(warmUpResultKey, result))
+	if tagErr != nil {
+		// The result is a valid tag value, this is not expected to happen.
+		ctx = m.statsCtx
+	}
+	pkgmetrics.Record(ctx, warmUpLatencyInMsecM.M(float64(latency)/float64(time.Millisecond)))
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/resource"
+	"go.uber.org/atomic"
+	network "knative.dev/networking/pkg"
+	"knative.dev/pkg/metrics/metricstest"
+	_ "knative.dev/pkg/metrics/testing"
+	"knative.dev/serving/pkg/metrics"
+)
+
+func TestParseWarmUpHook(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    string
+		want    *WarmUpHook
+		wantErr bool
+	}{{
+		name: "defaults",
+		hook: `{"path": "/warm-up"}`,
+		want: &WarmUpHook{Path: "/warm-up", Status: http.StatusOK},
+	}, {
+		name: "all",
+		hook: `{"path": "/load", "status": 204, "timeoutSeconds": 300, "payload": "{\"model\":\"big\"}"}`,
+		want: &WarmUpHook{Path: "/load", Status: http.StatusNoContent, TimeoutSeconds: 300, Payload: `{"model":"big"}`},
+	}, {
+		name:    "not JSON",
+		hook:    "/warm-up",
+		wantErr: true,
+	}, {
+		name:    "relative path",
+		hook:    `{"path": "warm-up"}`,
+		wantErr: true,
+	}, {
+		name:    "invalid status",
+		hook:    `{"path": "/warm-up", "status": 42}`,
+		wantErr: true,
+	}, {
+		name:    "negative timeout",
+		hook:    `{"path": "/warm-up", "timeoutSeconds": -1}`,
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseWarmUpHook(test.hook)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseWarmUpHook() = %v, want error: %v", err, test.wantErr)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Error("ParseWarmUpHook (-want, +got):", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	status := atomic.NewInt32(http.StatusServiceUnavailable)
+	calls := make(chan *http.Request, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls <- r
+		if string(body) != "load" {
+			t.Errorf("Warm-up payload = %q, want: load", body)
+		}
+		w.WriteHeader(int(status.Load()))
+	}))
+	t.Cleanup(server.Close)
+
+	reports := make(chan error, 100)
+	w := NewWarmUp(&WarmUpHook{Path: "/warm-up", Status: http.StatusOK, Payload: "load"}, server.URL,
+		http.DefaultTransport, func(_ time.Duration, err error) {
+			reports <- err
+		})
+	w.retryDelay = 0
+
+	ready := atomic.NewBool(false)
+	prober := w.Prober(ready.Load)
+
+	if prober() {
+		t.Error("prober() = true while the container isn't ready")
+	}
+	select {
+	case <-calls:
+		t.Fatal("Warm-up called before the container is ready")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The container is ready, but the warm-up fails.
+	ready.Store(true)
+	if prober() {
+		t.Error("prober() = true before the warm-up")
+	}
+	r := <-calls
+	if r.Method != http.MethodPost || r.URL.Path != "/warm-up" || r.Header.Get(network.UserAgentKey) != WarmUpUserAgent {
+		t.Errorf("Warm-up call = %s %s by %q, want: POST /warm-up by %q", r.Method, r.URL.Path, r.Header.Get(network.UserAgentKey), WarmUpUserAgent)
+	}
+	if err := <-reports; err == nil {
+		t.Error("Reported success of a call failing with a 503")
+	}
+	if prober() {
+		t.Error("prober() = true after the warm-up failed")
+	}
+
+	// The next warm-up succeeds.
+	status.Store(http.StatusOK)
+	for deadline := time.Now().Add(5 * time.Second); !prober(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("prober() = false after the warm-up succeeded")
+		}
+	}
+	for len(reports) > 1 {
+		<-reports
+	}
+	if err := <-reports; err != nil {
+		t.Error("Reported failure of the last warm-up:", err)
+	}
+	for len(calls) > 0 {
+		<-calls
+	}
+	prober()
+	select {
+	case <-calls:
+		t.Error("Warm-up called again after it succeeded")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ready.Store(false)
+	if prober() {
+		t.Error("prober() = true while the container isn't ready")
+	}
+}
+
+func TestWarmUpMetrics(t *testing.T) {
+	t.Cleanup(func() {
+		metricstest.Unregister(warmUpLatencyInMsecM.Name())
+	})
+	m, err := NewWarmUpMetrics("ns", "svc", "cfg", "rev", "pod")
+	if err != nil {
+		t.Fatal("NewWarmUpMetrics() =", err)
+	}
+	m.Record(time.Second, errors.New("warm-up failed"))
+	m.Record(2*time.Second, nil)
+
+	wantResource := &resource.Resource{
+		Type: "knative_revision",
+		Labels: map[string]string{
+			metrics.LabelNamespaceName:     "ns",
+			metrics.LabelRevisionName:      "rev",
+			metrics.LabelServiceName:       "svc",
+			metrics.LabelConfigurationName: "cfg",
+		},
+	}
+	tags := func(result string) map[string]string {
+		return map[string]string{
+			metrics.LabelPodName:       "pod",
+			metrics.LabelContainerName: "queue-proxy",
+			"result":                   result,
+		}
+	}
+	want := metricstest.DistributionCountOnlyMetric("warm_up_latencies", 1, tags("failure")).WithResource(wantResource)
+	want.Values = append(want.Values, metricstest.DistributionCountOnlyMetric("warm_up_latencies", 1, tags("success")).Values...)
+	metricstest.AssertMetric(t, want)
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/atomic"
+)
+
+// BackendWeight is the weight of a named backend.
+type BackendWeight struct {
+	Name   string
+	Weight int
+}
+
+// ParseBackendWeights parses a comma separated list of name=weight pairs,
+// e.g. "8080=90,8081=10".
+func ParseBackendWeights(s string) ([]BackendWeight, error) {
+	var weights []BackendWeight
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid backend weight %q, want name=weight", pair)
+		}
+		name := kv[0]
+		w, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight for backend %q: %w", name, err)
+		}
+		weights = append(weights, BackendWeight{Name: name, Weight: w})
+	}
+	return weights, nil
+}
+
+// WeightedBackends picks one of a fixed set of backends for every request at
+// random, proportionally to their weights. The weights can be updated at
+// runtime, e.g. to gradually shift traffic from one backend to another.
+type WeightedBackends struct {
+	names []string
+	intn  func(int) int
+
+	// weights holds a *backendWeights, which is swapped as a whole on
+	// updates. Updates are serialized by mu.
+	mu      sync.Mutex
+	weights atomic.Value
+}
+
+type backendWeights struct {
+	weights []int // Same order as names.
+	total   int
+}
+
+// NewWeightedBackends creates a WeightedBackends for the given backends.
+func NewWeightedBackends(weights []BackendWeight) (*WeightedBackends, error) {
+	if len(weights) == 0 {
+		return nil, errors.New("at least one backend is required")
+	}
+	b := &WeightedBackends{
+		names: make([]string, 0, len(weights)),
+		intn:  rand.Intn,
+	}
+	initial := make(map[string]int, len(weights))
+	for _, w := range weights {
+		if _, ok := initial[w.Name]; ok {
+			return nil, fmt.Errorf("duplicate backend %q", w.Name)
+		}
+		b.names = append(b.names, w.Name)
+		initial[w.Name] = w.Weight
+	}
+	if err := b.SetWeights(initial); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Names returns the names of the backends.
+func (b *WeightedBackends) Names() []string {
+	return b.names
+}
+
+// Weights returns the current weights of the backends.
+func (b *WeightedBackends) Weights() map[string]int {
+	current := b.weights.Load().(*backendWeights)
+	weights := make(map[string]int, len(b.names))
+	for i, name := range b.names {
+		weights[name] = current.weights[i]
+	}
+	return weights
+}
+
+// SetWeights atomically updates the weights of the given backends. Backends
+// that are not given keep their weight. Weights must not be negative and at
+// least one backend must have a positive weight.
+func (b *WeightedBackends) SetWeights(weights map[string]int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	next := &backendWeights{weights: make([]int, len(b.names))}
+	if current, ok := b.weights.Load().(*backendWeights); ok {
+		copy(next.weights, current.weights)
+	}
+
+	index := make(map[string]int, len(b.names))
+	for i, name := range b.names {
+		index[name] = i
+	}
+	for name, w := range weights {
+		i, ok := index[name]
+		if !ok {
+			return fmt.Errorf("unknown backend %q", name)
+		}
+		if w < 0 {
+			return fmt.Errorf("weight of backend %q must not be negative, was: %d", name, w)
+		}
+		next.weights[i] = w
+	}
+	for _, w := range next.weights {
+		next.total += w
+	}
+	if next.total == 0 {
+		return errors.New("at least one backend must have a positive weight")
+	}
+
+	b.weights.Store(next)
+	return nil
+}
+
+// pick returns the index of a backend chosen by weighted random selection.
+// Backends with a weight of 0 are never chosen.
+func (b *WeightedBackends) pick() int {
+	current := b.weights.Load().(*backendWeights)
+	n := b.intn(current.total)
+	for i, w := range current.weights {
+		if n < w {
+			return i
+		}
+		n -= w
+	}
+	// Unreachable, as n < total.
+	return len(current.weights) - 1
+}
+
+// Handler routes every request to the handler of the picked backend. The
+// handlers are given by backend name.
+func (b *WeightedBackends) Handler(handlers map[string]http.Handler) (http.Handler, error) {
+	ordered := make([]http.Handler, len(b.names))
+	for i, name := range b.names {
+		h, ok := handlers[name]
+		if !ok {
+			return nil, fmt.Errorf("no handler for backend %q", name)
+		}
+		ordered[i] = h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ordered[b.pick()].ServeHTTP(w, r)
+	}), nil
+}
+
+// ServeHTTP serves the weights of the backends as a JSON object on GET and
+// updates them from such an object on PUT.
+func (b *WeightedBackends) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var weights map[string]int
+		if err := json.NewDecoder(r.Body).Decode(&weights); err != nil {
+			http.Error(w, "failed to decode weights: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := b.SetWeights(weights); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(b.Weights())
+}
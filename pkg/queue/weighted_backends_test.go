@@ -0,0 +1,166 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseBackendWeights(t *testing.T) {
+	got, err := ParseBackendWeights("8080=90, 8081=10")
+	if err != nil {
+		t.Fatal("ParseBackendWeights() =", err)
+	}
+	want := []BackendWeight{{Name: "8080", Weight: 90}, {Name: "8081", Weight: 10}}
+	if !cmp.Equal(got, want) {
+		t.Error("Weights mismatch (-want,+got):", cmp.Diff(want, got))
+	}
+
+	for _, s := range []string{"8080", "=1", "8080=a", "8080=1,"} {
+		if _, err := ParseBackendWeights(s); err == nil {
+			t.Errorf("ParseBackendWeights(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestNewWeightedBackendsInvalid(t *testing.T) {
+	for name, weights := range map[string][]BackendWeight{
+		"no backends": nil,
+		"duplicate":   {{Name: "a", Weight: 1}, {Name: "a", Weight: 1}},
+		"negative":    {{Name: "a", Weight: 1}, {Name: "b", Weight: -1}},
+		"all zero":    {{Name: "a"}, {Name: "b"}},
+		"single zero": {{Name: "a"}},
+	} {
+		if _, err := NewWeightedBackends(weights); err == nil {
+			t.Errorf("%s: NewWeightedBackends() succeeded, want error", name)
+		}
+	}
+}
+
+func TestWeightedBackendsSplit(t *testing.T) {
+	const requests = 10000
+	backends, err := NewWeightedBackends([]BackendWeight{
+		{Name: "blue", Weight: 80},
+		{Name: "green", Weight: 20},
+		{Name: "canary", Weight: 0},
+	})
+	if err != nil {
+		t.Fatal("NewWeightedBackends() =", err)
+	}
+
+	counts := map[string]int{}
+	backend := func(name string) http.Handler {
+		return http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			counts[name]++
+		})
+	}
+	h, err := backends.Handler(map[string]http.Handler{
+		"blue":   backend("blue"),
+		"green":  backend("green"),
+		"canary": backend("canary"),
+	})
+	if err != nil {
+		t.Fatal("Handler() =", err)
+	}
+	breaker := NewBreaker(BreakerParams{QueueDepth: 10, MaxConcurrency: 10, InitialCapacity: 10})
+	proxy := ProxyHandler(breaker, NewRequestStats(time.Now()), false /*tracingEnabled*/, false /*timingHeaders*/, nil /*mirror*/, h)
+
+	serve := func() {
+		for i := 0; i < requests; i++ {
+			proxy(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, targetURI, nil))
+		}
+	}
+	expectSplit := func(want map[string]float64) {
+		t.Helper()
+		for name, ratio := range want {
+			got := float64(counts[name]) / requests
+			if ratio == 0 && counts[name] != 0 {
+				t.Errorf("Backend %s got %d requests, want: 0", name, counts[name])
+			} else if math.Abs(got-ratio) > 0.03 {
+				t.Errorf("Backend %s got %.3f of the requests, want: %.3f", name, got, ratio)
+			}
+		}
+	}
+
+	serve()
+	expectSplit(map[string]float64{"blue": 0.8, "green": 0.2, "canary": 0})
+
+	// Ramp the traffic over to green.
+	if err := backends.SetWeights(map[string]int{"blue": 0, "green": 1}); err != nil {
+		t.Fatal("SetWeights() =", err)
+	}
+	counts = map[string]int{}
+	serve()
+	expectSplit(map[string]float64{"blue": 0, "green": 1, "canary": 0})
+}
+
+func TestWeightedBackendsMissingHandler(t *testing.T) {
+	backends, err := NewWeightedBackends([]BackendWeight{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}})
+	if err != nil {
+		t.Fatal("NewWeightedBackends() =", err)
+	}
+	if _, err := backends.Handler(map[string]http.Handler{"a": http.NotFoundHandler()}); err == nil {
+		t.Error("Handler() succeeded with a missing backend handler, want error")
+	}
+}
+
+func TestWeightedBackendsServeHTTP(t *testing.T) {
+	backends, err := NewWeightedBackends([]BackendWeight{{Name: "8080", Weight: 90}, {Name: "8081", Weight: 10}})
+	if err != nil {
+		t.Fatal("NewWeightedBackends() =", err)
+	}
+
+	serve := func(method, body string) (int, map[string]int) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		backends.ServeHTTP(rec, httptest.NewRequest(method, "http://localhost"+BackendWeightsPath, strings.NewReader(body)))
+		var weights map[string]int
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&weights); err != nil {
+				t.Fatal("Failed to decode weights:", err)
+			}
+		}
+		return rec.Code, weights
+	}
+
+	if code, got := serve(http.MethodGet, ""); code != http.StatusOK || !cmp.Equal(got, map[string]int{"8080": 90, "8081": 10}) {
+		t.Errorf("GET = %d, %v", code, got)
+	}
+	if code, got := serve(http.MethodPut, `{"8081": 50}`); code != http.StatusOK || !cmp.Equal(got, map[string]int{"8080": 90, "8081": 50}) {
+		t.Errorf("PUT = %d, %v", code, got)
+	}
+	for _, body := range []string{`{"8082": 1}`, `{"8080": -1}`, `{"8080": 0, "8081": 0}`, `garbage`} {
+		if code, _ := serve(http.MethodPut, body); code != http.StatusBadRequest {
+			t.Errorf("PUT %s = %d, want: %d", body, code, http.StatusBadRequest)
+		}
+	}
+	if code, _ := serve(http.MethodPost, ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want: %d", code, http.StatusMethodNotAllowed)
+	}
+	// Failed updates don't change the weights.
+	if _, got := serve(http.MethodGet, ""); !cmp.Equal(got, map[string]int{"8080": 90, "8081": 50}) {
+		t.Errorf("GET = %v", got)
+	}
+}
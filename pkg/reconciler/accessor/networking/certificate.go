@@ -24,11 +24,13 @@ import (
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/networking/pkg/apis/networking"
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
 	clientset "knative.dev/networking/pkg/client/clientset/versioned"
 	listers "knative.dev/networking/pkg/client/listers/networking/v1alpha1"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/kmeta"
+	"knative.dev/serving/pkg/apis/serving"
 	kaccessor "knative.dev/serving/pkg/reconciler/accessor"
 )
 
@@ -63,10 +65,11 @@ func ReconcileCertificate(ctx context.Context, owner kmeta.Accessor, desired *v1
 		return nil, kaccessor.NewAccessorError(
 			fmt.Errorf("owner: %s with Type %T does not own Certificate: %q", owner.GetName(), owner, cert.Name),
 			kaccessor.NotOwnResource)
-	} else if !equality.Semantic.DeepEqual(cert.Spec, desired.Spec) {
+	} else if !equality.Semantic.DeepEqual(cert.Spec, desired.Spec) || !issuanceAnnotationsEqual(cert, desired) {
 		// Don't modify the informers copy
 		existing := cert.DeepCopy()
 		existing.Spec = desired.Spec
+		existing.Annotations = withIssuanceAnnotations(existing.Annotations, desired.Annotations)
 		cert, err = certAccessor.GetNetworkingClient().NetworkingV1alpha1().Certificates(existing.Namespace).Update(ctx, existing, metav1.UpdateOptions{})
 		if err != nil {
 			recorder.Eventf(owner, corev1.EventTypeWarning, "UpdateFailed",
@@ -78,3 +81,35 @@ func ReconcileCertificate(ctx context.Context, owner kmeta.Accessor, desired *v1
 	}
 	return cert, nil
 }
+
+// issuanceAnnotations are the annotations of a Certificate selecting how it's
+// issued, so a change to them has the Certificate reissued.
+var issuanceAnnotations = []string{
+	networking.CertificateClassAnnotationKey,
+	serving.CertificateIssuerKey,
+}
+
+func issuanceAnnotationsEqual(cert, desired *v1alpha1.Certificate) bool {
+	for _, key := range issuanceAnnotations {
+		if cert.Annotations[key] != desired.Annotations[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// withIssuanceAnnotations returns the annotations with the issuance
+// annotations replaced by the desired ones.
+func withIssuanceAnnotations(annos, desired map[string]string) map[string]string {
+	if annos == nil {
+		annos = make(map[string]string, len(issuanceAnnotations))
+	}
+	for _, key := range issuanceAnnotations {
+		if v, ok := desired[key]; ok {
+			annos[key] = v
+		} else {
+			delete(annos, key)
+		}
+	}
+	return annos
+}
@@ -28,12 +28,14 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 
+	"knative.dev/networking/pkg/apis/networking"
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
 	netclientset "knative.dev/networking/pkg/client/clientset/versioned"
 	fakenetworkingclient "knative.dev/networking/pkg/client/injection/client/fake"
 	fakecertinformer "knative.dev/networking/pkg/client/injection/informers/networking/v1alpha1/certificate/fake"
 	listers "knative.dev/networking/pkg/client/listers/networking/v1alpha1"
 	"knative.dev/pkg/ptr"
+	"knative.dev/serving/pkg/apis/serving"
 
 	. "knative.dev/pkg/reconciler/testing"
 )
@@ -138,6 +140,30 @@ func TestReconcileCertificateUpdate(t *testing.T) {
 	}
 }
 
+func TestReconcileCertificateIssuanceUpdate(t *testing.T) {
+	existing := desired.DeepCopy()
+	existing.Annotations = map[string]string{
+		networking.CertificateClassAnnotationKey: "old.class",
+		serving.CertificateIssuerKey:             "internal-ca",
+		"other":                                  "kept",
+	}
+	ctx, accessor := setup([]*v1alpha1.Certificate{existing}, t)
+
+	want := desired.DeepCopy()
+	want.Annotations = map[string]string{networking.CertificateClassAnnotationKey: "new.class"}
+	cert, err := ReconcileCertificate(ctx, ownerObj, want, accessor)
+	if err != nil {
+		t.Fatal("ReconcileCertificate() =", err)
+	}
+	wantAnnotations := map[string]string{
+		networking.CertificateClassAnnotationKey: "new.class",
+		"other":                                  "kept",
+	}
+	if !cmp.Equal(cert.Annotations, wantAnnotations) {
+		t.Error("Annotations (-want, +got):", cmp.Diff(wantAnnotations, cert.Annotations))
+	}
+}
+
 func setup(certs []*v1alpha1.Certificate, t *testing.T) (context.Context, *FakeAccessor) {
 	ctx, cancel, informers := SetupFakeContextWithCancel(t)
 
@@ -41,8 +41,9 @@ import (
 type Reconciler struct {
 	*areconciler.Base
 
-	kubeClient kubernetes.Interface
-	hpaLister  autoscalingv2beta1listers.HorizontalPodAutoscalerLister
+	kubeClient   kubernetes.Interface
+	hpaLister    autoscalingv2beta1listers.HorizontalPodAutoscalerLister
+	enqueueAfter func(interface{}, time.Duration)
 }
 
 // Check that our Reconciler implements pareconciler.Interface
@@ -58,6 +59,10 @@ func (c *Reconciler) ReconcileKind(ctx context.Context, pa *autoscalingv1alpha1.
 
 	// HPA-class PA delegates autoscaling to the Kubernetes Horizontal Pod Autoscaler.
 	desiredHpa := resources.MakeHPA(pa, config.FromContext(ctx).Autoscaler)
+	if next, ok := pa.NextScaleBoundsChange(time.Now()); ok {
+		// Update the HPA bounds once the next window of the schedule starts.
+		c.enqueueAfter(pa, time.Until(next))
+	}
 	hpa, err := c.hpaLister.HorizontalPodAutoscalers(pa.Namespace).Get(desiredHpa.Name)
 	if errors.IsNotFound(err) {
 		logger.Infof("Creating HPA %q", desiredHpa.Name)
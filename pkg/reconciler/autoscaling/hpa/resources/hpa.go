@@ -82,6 +82,21 @@ func MakeHPA(pa *v1alpha1.PodAutoscaler, config *autoscalerconfig.Config) *autos
 				},
 			}}
 		}
+
+	case autoscaling.External:
+		if target, ok := pa.Target(); ok {
+			external := &autoscalingv2beta1.ExternalMetricSource{
+				MetricName:         pa.Annotations[autoscaling.ExternalMetricNameAnnotationKey],
+				TargetAverageValue: resource.NewMilliQuantity(int64(math.Ceil(target*1000)), resource.DecimalSI),
+			}
+			if selector, ok := pa.ExternalMetricSelector(); ok {
+				external.MetricSelector = selector
+			}
+			hpa.Spec.Metrics = []autoscalingv2beta1.MetricSpec{{
+				Type:     autoscalingv2beta1.ExternalMetricSourceType,
+				External: external,
+			}}
+		}
 	}
 
 	return hpa
@@ -97,6 +97,28 @@ func TestMakeHPA(t *testing.T) {
 					TargetAverageUtilization: ptr.Int32(1983),
 				},
 			})),
+	}, {
+		name: "with an external metric",
+		pa: pa(WithTargetAnnotation("2.5"), WithMetricAnnotation(autoscaling.External), func(pa *v1alpha1.PodAutoscaler) {
+			pa.Annotations[autoscaling.ExternalMetricNameAnnotationKey] = "kafka_consumergroup_lag"
+			pa.Annotations[autoscaling.ExternalMetricSelectorAnnotationKey] = "topic=orders"
+		}),
+		want: hpa(
+			withAnnotationValue(autoscaling.MetricAnnotationKey, autoscaling.External),
+			withAnnotationValue(autoscaling.TargetAnnotationKey, "2.5"),
+			withAnnotationValue(autoscaling.ExternalMetricNameAnnotationKey, "kafka_consumergroup_lag"),
+			withAnnotationValue(autoscaling.ExternalMetricSelectorAnnotationKey, "topic=orders"),
+			withMetric(autoscalingv2beta1.MetricSpec{
+				Type: autoscalingv2beta1.ExternalMetricSourceType,
+				External: &autoscalingv2beta1.ExternalMetricSource{
+					MetricName: "kafka_consumergroup_lag",
+					MetricSelector: &metav1.LabelSelector{
+						MatchLabels:      map[string]string{"topic": "orders"},
+						MatchExpressions: []metav1.LabelSelectorRequirement{},
+					},
+					TargetAverageValue: resource.NewMilliQuantity(2500, resource.DecimalSI),
+				},
+			})),
 	}}
 
 	for _, tc := range cases {
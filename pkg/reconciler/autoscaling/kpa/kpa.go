@@ -30,6 +30,7 @@ import (
 	pkgmetrics "knative.dev/pkg/metrics"
 	"knative.dev/pkg/ptr"
 	pkgreconciler "knative.dev/pkg/reconciler"
+	"knative.dev/serving/pkg/apis/autoscaling"
 	autoscalingv1alpha1 "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
 	"knative.dev/serving/pkg/apis/serving"
 	"knative.dev/serving/pkg/autoscaler/scaling"
@@ -43,6 +44,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 )
@@ -51,6 +53,14 @@ const (
 	noPrivateServiceName = "No Private Service Name"
 	noTrafficReason      = "NoTraffic"
 	minActivators        = 2
+
+	// hibernatedReason is the reason of the PA being inactive once its
+	// target is hibernated.
+	hibernatedReason = "Hibernated"
+
+	// capacityUnavailableReason is the reason of the PA being held activating,
+	// or active short of its minimum scale, as not all pods could be scheduled.
+	capacityUnavailableReason = "CapacityUnavailable"
 )
 
 // podCounts keeps record of various numbers of pods
@@ -61,6 +71,8 @@ type podCounts struct {
 	notReady    int
 	pending     int
 	terminating int
+	// unschedulable are the pending pods that could not be scheduled.
+	unschedulable int
 }
 
 // Reconciler tracks PAs and right sizes the ScaleTargetRef based on the
@@ -107,13 +119,22 @@ func (c *Reconciler) ReconcileKind(ctx context.Context, pa *autoscalingv1alpha1.
 	}
 
 	pa.Status.MetricsServiceName = sks.Status.PrivateServiceName
-	decider, err := c.reconcileDecider(ctx, pa)
-	if err != nil {
-		return fmt.Errorf("error reconciling Decider: %w", err)
-	}
-
-	if err := c.ReconcileMetric(ctx, pa, resolveScrapeTarget(ctx, pa)); err != nil {
-		return fmt.Errorf("error reconciling Metric: %w", err)
+	var decider *scaling.Decider
+	if scale, ok := fixedScale(ctx, pa); ok && config.FromContext(ctx).Autoscaler.SkipDeciderForFixedScale {
+		// A revision pinned at a fixed scale isn't autoscaled, so, if opted
+		// in, neither a decider nor metric collection is kept for it.
+		c.deciders.Delete(ctx, pa.Namespace, pa.Name)
+		if err := c.DeleteMetric(ctx, pa); err != nil {
+			return fmt.Errorf("error deleting Metric: %w", err)
+		}
+		decider = fixedScaleDecider(ctx, pa, scale)
+	} else {
+		if decider, err = c.reconcileDecider(ctx, pa); err != nil {
+			return fmt.Errorf("error reconciling Decider: %w", err)
+		}
+		if err := c.ReconcileMetric(ctx, pa, resolveScrapeTarget(ctx, pa)); err != nil {
+			return fmt.Errorf("error reconciling Metric: %w", err)
+		}
 	}
 
 	// Get the appropriate current scale from the metric, and right size
@@ -130,7 +151,8 @@ func (c *Reconciler) ReconcileKind(ctx context.Context, pa *autoscalingv1alpha1.
 	//   b. want == -1 && PA is inactive (Autoscaler has no previous knowledge of
 	//			this revision, e.g. after a restart) but PA status is inactive (it was
 	//			already scaled to 0).
-	// 2. The excess burst capacity is negative.
+	// 2. The excess burst capacity is negative, which the autoscaler also
+	//    reports while the pods fail too many of the requests.
 	if want == 0 || decider.Status.ExcessBurstCapacity < 0 || want == scaleUnknown && pa.Status.IsInactive() {
 		mode = nv1alpha1.SKSOperationModeProxy
 	}
@@ -141,9 +163,13 @@ func (c *Reconciler) ReconcileKind(ctx context.Context, pa *autoscalingv1alpha1.
 	if err != nil {
 		return fmt.Errorf("error getting pod counts: %w", err)
 	}
+	unschedulable, err := podCounter.UnschedulableCount()
+	if err != nil {
+		return fmt.Errorf("error getting unschedulable pod count: %w", err)
+	}
 
 	// Determine the amount of activators to put into the routing path.
-	numActivators := computeNumActivators(ready, decider)
+	numActivators := computeNumActivators(pa, ready, decider)
 
 	logger.Infof("SKS should be in %s mode: want = %d, ebc = %d, #act's = %d PA Inactive? = %v",
 		mode, want, decider.Status.ExcessBurstCapacity, numActivators,
@@ -169,13 +195,16 @@ func (c *Reconciler) ReconcileKind(ctx context.Context, pa *autoscalingv1alpha1.
 		decider.Status.DesiredScale, decider.Status.ExcessBurstCapacity)
 
 	pc := podCounts{
-		want:        int(want),
-		ready:       ready,
-		notReady:    notReady,
-		pending:     pending,
-		terminating: terminating,
+		want:          int(want),
+		ready:         ready,
+		notReady:      notReady,
+		pending:       pending,
+		terminating:   terminating,
+		unschedulable: unschedulable,
 	}
 	logger.Infof("Observed pod counts=%#v", pc)
+	pa.Status.MetricDesiredScales = metricDesiredScales(decider)
+	pa.Status.PanicStartTime = panicStartTime(decider)
 	computeStatus(ctx, pa, pc, logger)
 	return nil
 }
@@ -190,6 +219,11 @@ func (c *Reconciler) reconcileDecider(ctx context.Context, pa *autoscalingv1alph
 	desiredDecider := resources.MakeDecider(pa, config.FromContext(ctx).Autoscaler)
 	decider, err := c.deciders.Get(ctx, desiredDecider.Namespace, desiredDecider.Name)
 	if errors.IsNotFound(err) {
+		// Restore the scale last desired for the revision, e.g. before the
+		// autoscaler restarted or took over the revision from another one.
+		if ds := pa.Status.DesiredScale; ds != nil && *ds > 0 {
+			desiredDecider.Status.DesiredScale = *ds
+		}
 		decider, err = c.deciders.Create(ctx, desiredDecider)
 		if err != nil {
 			return nil, fmt.Errorf("error creating Decider: %w", err)
@@ -209,6 +243,56 @@ func (c *Reconciler) reconcileDecider(ctx context.Context, pa *autoscalingv1alph
 	return decider, nil
 }
 
+// fixedScale returns the scale of a revision pinned at a fixed scale, i.e.
+// with equal min and max scale, and whether it is pinned.
+func fixedScale(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler) (int32, bool) {
+	min, max := pa.ScaleBounds(config.FromContext(ctx).Autoscaler)
+	return min, min > 0 && min == max
+}
+
+// fixedScaleDecider returns a decider in place of a running one for a
+// revision pinned at the given scale. As there are no metrics to compute the
+// excess burst capacity from, it's computed from the capacity of the pods
+// alone, keeping the activator in the path if they can't absorb the target
+// burst capacity.
+func fixedScaleDecider(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler, scale int32) *scaling.Decider {
+	decider := resources.MakeDecider(pa, config.FromContext(ctx).Autoscaler)
+	decider.Status.DesiredScale = scale
+	switch tbc := decider.Spec.TargetBurstCapacity; tbc {
+	case -1, 0:
+		decider.Status.ExcessBurstCapacity = int32(tbc)
+	default:
+		decider.Status.ExcessBurstCapacity = int32(math.Floor(float64(scale)*decider.Spec.TotalValue - tbc))
+	}
+	return decider
+}
+
+// metricDesiredScales returns the scales each of the metrics recommends, if
+// the revision is scaled on a combination of metrics.
+func metricDesiredScales(decider *scaling.Decider) []autoscalingv1alpha1.MetricDesiredScale {
+	if len(decider.Status.MetricPodCounts) == 0 {
+		return nil
+	}
+	scales := make([]autoscalingv1alpha1.MetricDesiredScale, 0, len(decider.Status.MetricPodCounts))
+	for _, pc := range decider.Status.MetricPodCounts {
+		scales = append(scales, autoscalingv1alpha1.MetricDesiredScale{
+			Metric:       pc.Metric,
+			DesiredScale: pc.DesiredPodCount,
+		})
+	}
+	return scales
+}
+
+// panicStartTime returns when the autoscaler entered panic mode for the
+// revision, nil if it isn't panicking.
+func panicStartTime(decider *scaling.Decider) *metav1.Time {
+	if decider.Status.PanicStartTime.IsZero() {
+		return nil
+	}
+	t := metav1.NewTime(decider.Status.PanicStartTime)
+	return &t
+}
+
 func computeStatus(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler, pc podCounts, logger *zap.SugaredLogger) {
 	pa.Status.DesiredScale, pa.Status.ActualScale = ptr.Int32(int32(pc.want)), ptr.Int32(int32(pc.ready))
 
@@ -249,24 +333,40 @@ func reportMetrics(pa *autoscalingv1alpha1.PodAutoscaler, pc podCounts) {
 //    | -1   | >= min | 0     | active     | inactive   | <-- this case technically is impossible.
 //    | -1   | >= min | >0    | activating | active     |
 //    | -1   | >= min | >0    | active     | active     |
+//
+// If got < min as pods can't be scheduled, the PA is activating with the
+// CapacityUnavailable reason. With the BestEffort minScaleUnavailablePolicy
+// and got > 0 it's active instead, with the same reason.
 func computeActiveCondition(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler, pc podCounts) {
 	minReady := activeThreshold(ctx, pa)
-	if pc.ready >= minReady && pa.Status.ServiceName != "" {
+	capacityUnavailable := pc.ready < minReady && pc.unschedulable > 0
+	bestEffort := capacityUnavailable && pc.ready > 0 &&
+		pa.MinScaleUnavailablePolicy() == autoscaling.MinScaleUnavailablePolicyBestEffort
+	if (pc.ready >= minReady || bestEffort) && pa.Status.ServiceName != "" {
 		pa.Status.MarkScaleTargetInitialized()
 	}
+	capacityMessage := fmt.Sprintf("%d of the %d required pods are ready, %d pods can't be scheduled.",
+		pc.ready, minReady, pc.unschedulable)
 
 	switch {
 	// Need to check for minReady = 0 because in the initialScale 0 case, pc.want will be -1.
 	case pc.want == 0 || minReady == 0:
-		if pa.Status.IsActivating() && minReady > 0 {
+		if pa.Spec.Hibernated {
+			pa.Status.MarkInactive(hibernatedReason, "The target is hibernated.")
+		} else if pa.Status.IsActivating() && minReady > 0 {
 			// We only ever scale to zero while activating if we fail to activate within the progress deadline.
 			pa.Status.MarkInactive("TimedOut", "The target could not be activated.")
 		} else {
 			pa.Status.MarkInactive(noTrafficReason, "The target is not receiving traffic.")
 		}
 
+	case bestEffort && (pc.want > 0 || !pa.Status.IsInactive()):
+		pa.Status.MarkActiveWithReason(capacityUnavailableReason, capacityMessage)
+
 	case pc.ready < minReady:
-		if pc.want > 0 || !pa.Status.IsInactive() {
+		if capacityUnavailable && (pc.want > 0 || !pa.Status.IsInactive()) {
+			pa.Status.MarkActivating(capacityUnavailableReason, capacityMessage)
+		} else if pc.want > 0 || !pa.Status.IsInactive() {
 			pa.Status.MarkActivating(
 				"Queued", "Requests to the target are being buffered as resources are provisioned.")
 		} else {
@@ -300,9 +400,10 @@ func activeThreshold(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler)
 
 // resolveScrapeTarget returns metric service name to be scraped based on TBC configuration
 // TBC == -1 => activator in path, don't scrape the service
+// Neither is it scraped if the queue-proxies push their stats to the autoscaler.
 func resolveScrapeTarget(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler) string {
 	tbc := resolveTBC(ctx, pa)
-	if tbc == -1 {
+	if tbc == -1 || config.FromContext(ctx).Deployment.QueueSidecarPushStats {
 		return ""
 	}
 
@@ -324,7 +425,10 @@ func intMax(a, b int32) int32 {
 	return a
 }
 
-func computeNumActivators(readyPods int, decider *scaling.Decider) int32 {
+func computeNumActivators(pa *autoscalingv1alpha1.PodAutoscaler, readyPods int, decider *scaling.Decider) int32 {
+	if n, ok := pa.ActivatorSubsetSize(); ok {
+		return n
+	}
 	if decider.Spec.TargetBurstCapacity == 0 {
 		return int32(minActivators)
 	}
@@ -122,6 +122,12 @@ func initialScaleZeroASConfig() *autoscalerconfig.Config {
 	return ac
 }
 
+func skipFixedScaleDeciderASConfig() *autoscalerconfig.Config {
+	ac, _ := asconfig.NewConfigFromMap(defaultConfigMapData())
+	ac.SkipDeciderForFixedScale = true
+	return ac
+}
+
 func defaultConfig() *config.Config {
 	ac, _ := asconfig.NewConfigFromMap(defaultConfigMapData())
 	deploymentConfig, _ := deployment.NewConfigFromMap(map[string]string{
@@ -908,6 +914,66 @@ func TestReconcile(t *testing.T) {
 		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
 			Object: activeKPAMinScale(overscale, defaultScale),
 		}},
+	}, {
+		Name: "fixed scale without opting in, decider kept",
+		// The decider keeps the activator in the path.
+		Key: key,
+		Ctx: context.WithValue(context.Background(), deciderKey{},
+			decider(testNamespace, testRevision, defaultScale /*wantScale*/, -42 /* ebc */)),
+		Objects: append([]runtime.Object{
+			kpa(testNamespace, testRevision, WithPASKSReady, WithTraffic, markScaleTargetInitialized,
+				withScales(defaultScale, defaultScale), WithReachabilityReachable,
+				withMinScale(defaultScale), withMaxScale(defaultScale), WithPAStatusService(testRevision),
+				WithPAMetricsService(privateSvc), WithObservedGeneration(1)),
+			defaultDeployment, defaultMetric,
+			sks(testNamespace, testRevision, WithDeployRef(deployName), WithProxyMode, WithSKSReady, WithNumActivators(scaledAct)),
+		}, preciseReady...),
+	}, {
+		Name: "fixed scale, decider and metric deleted",
+		// The decider's recommendation is ignored and the Metric removed.
+		Key: key,
+		Ctx: context.WithValue(context.WithValue(context.Background(), asConfigKey{}, skipFixedScaleDeciderASConfig()), deciderKey{},
+			decider(testNamespace, testRevision, 1 /*wantScale*/, -42 /* ebc */)),
+		Objects: append([]runtime.Object{
+			kpa(testNamespace, testRevision, WithPASKSReady, WithTraffic, markScaleTargetInitialized,
+				withScales(defaultScale, defaultScale), WithReachabilityReachable,
+				withMinScale(defaultScale), withMaxScale(defaultScale), withTBC(0),
+				WithPAStatusService(testRevision), WithPAMetricsService(privateSvc), WithObservedGeneration(1)),
+			defaultDeployment, defaultMetric,
+			sks(testNamespace, testRevision, WithDeployRef(deployName), WithSKSReady, WithNumActivators(minActivators)),
+		}, preciseReady...),
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: testNamespace,
+				Verb:      "delete",
+				Resource:  autoscalingv1alpha1.SchemeGroupVersion.WithResource("metrics"),
+			},
+			Name: testRevision,
+		}},
+	}, {
+		Name: "fixed scale short of the default burst capacity, activator kept in the path",
+		// 11 pods with a total of 10 each are 90 short of the TBC of 200.
+		Key: key,
+		Ctx: context.WithValue(context.Background(), asConfigKey{}, skipFixedScaleDeciderASConfig()),
+		Objects: append([]runtime.Object{
+			kpa(testNamespace, testRevision, WithPASKSReady, WithTraffic, markScaleTargetInitialized,
+				withScales(defaultScale, defaultScale), WithReachabilityReachable,
+				withMinScale(defaultScale), withMaxScale(defaultScale), WithPAStatusService(testRevision),
+				WithPAMetricsService(privateSvc), WithObservedGeneration(1)),
+			defaultDeployment, defaultMetric,
+			sks(testNamespace, testRevision, WithDeployRef(deployName), WithSKSReady, WithNumActivators(scaledAct)),
+		}, preciseReady...),
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: sks(testNamespace, testRevision, WithDeployRef(deployName), WithProxyMode, WithSKSReady, WithNumActivators(scaledAct)),
+		}},
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: testNamespace,
+				Verb:      "delete",
+				Resource:  autoscalingv1alpha1.SchemeGroupVersion.WithResource("metrics"),
+			},
+			Name: testRevision,
+		}},
 	}, {
 		Name: "scaled-to-0-no-scale-data",
 		Key:  key,
@@ -1746,6 +1812,24 @@ func withMinScale(minScale int) PodAutoscalerOption {
 	}
 }
 
+func withMaxScale(maxScale int) PodAutoscalerOption {
+	return func(pa *autoscalingv1alpha1.PodAutoscaler) {
+		pa.Annotations = kmeta.UnionMaps(
+			pa.Annotations,
+			map[string]string{autoscaling.MaxScaleAnnotationKey: strconv.Itoa(maxScale)},
+		)
+	}
+}
+
+func withTBC(tbc int) PodAutoscalerOption {
+	return func(pa *autoscalingv1alpha1.PodAutoscaler) {
+		pa.Annotations = kmeta.UnionMaps(
+			pa.Annotations,
+			map[string]string{autoscaling.TargetBurstCapacityKey: strconv.Itoa(tbc)},
+		)
+	}
+}
+
 func decider(ns, name string, desiredScale, ebc int32) *scaling.Decider {
 	return &scaling.Decider{
 		ObjectMeta: metav1.ObjectMeta{
@@ -1824,6 +1908,22 @@ func TestMetricsReporter(t *testing.T) {
 	metricstest.AssertMetric(t, wantMetrics...)
 }
 
+func TestReconcileDeciderRestoresScale(t *testing.T) {
+	pa := kpa(testNamespace, testRevision)
+	pa.Status.DesiredScale = ptr.Int32(7)
+	deciders := newTestDeciders()
+	c := &Reconciler{deciders: deciders}
+	ctx := (&testConfigStore{config: defaultConfig()}).ToContext(context.Background())
+
+	decider, err := c.reconcileDecider(ctx, pa)
+	if err != nil {
+		t.Fatal("reconcileDecider() =", err)
+	}
+	if got, want := decider.Status.DesiredScale, int32(7); got != want {
+		t.Errorf("DesiredScale = %d, want: %d", got, want)
+	}
+}
+
 func TestResolveScrapeTarget(t *testing.T) {
 	pa := kpa(testNamespace, testRevision, WithPAMetricsService("echo"))
 	tc := &testConfigStore{config: defaultConfig()}
@@ -1837,6 +1937,12 @@ func TestResolveScrapeTarget(t *testing.T) {
 		t.Errorf("reconcileMetricSN()= %s, want %s", got, want)
 	}
 
+	tc = &testConfigStore{config: defaultConfig()}
+	tc.config.Deployment.QueueSidecarPushStats = true
+	if got, want := resolveScrapeTarget(tc.ToContext(context.Background()), pa), ""; got != want {
+		t.Errorf("reconcileMetricSN()= %s, want %s", got, want)
+	}
+
 	tc = &testConfigStore{config: defaultConfig()}
 	pa.Annotations[autoscaling.TargetBurstCapacityKey] = "-1"
 	if got, want := resolveScrapeTarget(tc.ToContext(context.Background()), pa), ""; got != want {
@@ -1857,6 +1963,7 @@ func TestComputeActivatorNum(t *testing.T) {
 	cases := []struct {
 		name    string
 		pods    int
+		pa      *autoscalingv1alpha1.PodAutoscaler
 		decider *scaling.Decider
 		want    int32
 	}{{
@@ -1914,14 +2021,156 @@ func TestComputeActivatorNum(t *testing.T) {
 			},
 		},
 		want: 17,
+	}, {
+		name: "pinned",
+		pods: 15,
+		pa:   kpa(testNamespace, testRevision, WithActivatorSubsetSizeAnnotation("4")),
+		decider: &scaling.Decider{
+			Spec: scaling.DeciderSpec{
+				TotalValue:          100,
+				TargetBurstCapacity: 200,
+				ActivatorCapacity:   100,
+			},
+		},
+		want: 4,
+	}, {
+		name: "pinned below the minimum",
+		pods: 0,
+		pa:   kpa(testNamespace, testRevision, WithActivatorSubsetSizeAnnotation("1")),
+		decider: &scaling.Decider{
+			Spec: scaling.DeciderSpec{
+				TotalValue:          100,
+				TargetBurstCapacity: 0,
+				ActivatorCapacity:   100,
+			},
+		},
+		want: 1,
 	}}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			got := computeNumActivators(c.pods, c.decider)
+			pa := c.pa
+			if pa == nil {
+				pa = kpa(testNamespace, testRevision)
+			}
+			got := computeNumActivators(pa, c.pods, c.decider)
 			if got != c.want {
 				t.Errorf("computeNumActivators() = %d, want %d", got, c.want)
 			}
 		})
 	}
 }
+
+func TestComputeActiveConditionCapacityUnavailable(t *testing.T) {
+	ctx := config.ToContext(context.Background(), defaultConfig())
+	withPolicy := func(policy string) PodAutoscalerOption {
+		return func(pa *autoscalingv1alpha1.PodAutoscaler) {
+			pa.Annotations[autoscaling.MinScaleUnavailablePolicyAnnotationKey] = policy
+		}
+	}
+
+	cases := []struct {
+		name            string
+		opts            []PodAutoscalerOption
+		pc              podCounts
+		wantStatus      corev1.ConditionStatus
+		wantReason      string
+		wantInitialized bool
+	}{{
+		name:       "short of pods while they're scheduled",
+		pc:         podCounts{want: 3, ready: 1, pending: 2},
+		wantStatus: corev1.ConditionUnknown,
+		wantReason: "Queued",
+	}, {
+		name:       "unschedulable pods are held by default",
+		pc:         podCounts{want: 3, ready: 1, pending: 2, unschedulable: 2},
+		wantStatus: corev1.ConditionUnknown,
+		wantReason: capacityUnavailableReason,
+	}, {
+		name:       "unschedulable pods are held",
+		opts:       []PodAutoscalerOption{withPolicy(autoscaling.MinScaleUnavailablePolicyHold)},
+		pc:         podCounts{want: 3, ready: 1, pending: 2, unschedulable: 2},
+		wantStatus: corev1.ConditionUnknown,
+		wantReason: capacityUnavailableReason,
+	}, {
+		name:            "unschedulable pods best effort",
+		opts:            []PodAutoscalerOption{withPolicy(autoscaling.MinScaleUnavailablePolicyBestEffort)},
+		pc:              podCounts{want: 3, ready: 1, pending: 2, unschedulable: 2},
+		wantStatus:      corev1.ConditionTrue,
+		wantReason:      capacityUnavailableReason,
+		wantInitialized: true,
+	}, {
+		name:       "best effort without any ready pod",
+		opts:       []PodAutoscalerOption{withPolicy(autoscaling.MinScaleUnavailablePolicyBestEffort)},
+		pc:         podCounts{want: 3, pending: 3, unschedulable: 3},
+		wantStatus: corev1.ConditionUnknown,
+		wantReason: capacityUnavailableReason,
+	}, {
+		name:            "best effort with the minimum scale satisfied",
+		opts:            []PodAutoscalerOption{withPolicy(autoscaling.MinScaleUnavailablePolicyBestEffort)},
+		pc:              podCounts{want: 4, ready: 3, pending: 1, unschedulable: 1},
+		wantStatus:      corev1.ConditionTrue,
+		wantInitialized: true,
+	}, {
+		name: "hibernated",
+		opts: []PodAutoscalerOption{func(pa *autoscalingv1alpha1.PodAutoscaler) {
+			pa.Spec.Hibernated = true
+		}},
+		pc:              podCounts{want: 0, ready: 3},
+		wantStatus:      corev1.ConditionFalse,
+		wantReason:      hibernatedReason,
+		wantInitialized: true,
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// The minimum scale was never reached.
+			pa := kpa(testNamespace, testRevision, append([]PodAutoscalerOption{
+				withMinScale(3), WithPAStatusService(testRevision),
+			}, c.opts...)...)
+
+			computeActiveCondition(ctx, pa, c.pc)
+			cond := pa.Status.GetCondition(autoscalingv1alpha1.PodAutoscalerConditionActive)
+			if cond.Status != c.wantStatus || cond.Reason != c.wantReason {
+				t.Errorf("Active = %s/%q, want: %s/%q", cond.Status, cond.Reason, c.wantStatus, c.wantReason)
+			}
+			if got := pa.Status.IsScaleTargetInitialized(); got != c.wantInitialized {
+				t.Errorf("IsScaleTargetInitialized() = %v, want: %v", got, c.wantInitialized)
+			}
+		})
+	}
+}
+
+func TestMetricDesiredScales(t *testing.T) {
+	if got := metricDesiredScales(&scaling.Decider{}); got != nil {
+		t.Errorf("metricDesiredScales() = %v, want: nil", got)
+	}
+
+	decider := &scaling.Decider{
+		Status: scaling.DeciderStatus{
+			MetricPodCounts: []scaling.MetricPodCount{
+				{Metric: "concurrency", DesiredPodCount: 5},
+				{Metric: "rps", DesiredPodCount: 8},
+			},
+		},
+	}
+	want := []autoscalingv1alpha1.MetricDesiredScale{
+		{Metric: "concurrency", DesiredScale: 5},
+		{Metric: "rps", DesiredScale: 8},
+	}
+	if got := metricDesiredScales(decider); !cmp.Equal(got, want) {
+		t.Error("metricDesiredScales() (-want, +got):", cmp.Diff(want, got))
+	}
+}
+
+func TestPanicStartTime(t *testing.T) {
+	if got := panicStartTime(&scaling.Decider{}); got != nil {
+		t.Errorf("panicStartTime() = %v, want: nil", got)
+	}
+
+	now := time.Now()
+	decider := &scaling.Decider{Status: scaling.DeciderStatus{PanicStartTime: now}}
+	if got := panicStartTime(decider); got == nil || !got.Time.Equal(now) {
+		t.Errorf("panicStartTime() = %v, want: %v", got, now)
+	}
+}
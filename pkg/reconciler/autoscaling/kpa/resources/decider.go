@@ -66,23 +66,57 @@ func MakeDecider(pa *autoscalingv1alpha1.PodAutoscaler, config *autoscalerconfig
 		scaleDownDelay = sdd
 	}
 
-	return &scaling.Decider{
+	d := &scaling.Decider{
 		ObjectMeta: *pa.ObjectMeta.DeepCopy(),
 		Spec: scaling.DeciderSpec{
-			MaxScaleUpRate:      config.MaxScaleUpRate,
-			MaxScaleDownRate:    config.MaxScaleDownRate,
-			ScalingMetric:       pa.Metric(),
-			TargetValue:         target,
-			TotalValue:          total,
-			TargetBurstCapacity: tbc,
-			ActivatorCapacity:   config.ActivatorCapacity,
-			PanicThreshold:      panicThreshold,
-			StableWindow:        resources.StableWindow(pa, config),
-			ScaleDownDelay:      scaleDownDelay,
-			InitialScale:        GetInitialScale(config, pa),
-			Reachable:           pa.Spec.Reachability != autoscalingv1alpha1.ReachabilityUnreachable,
+			Algorithm:                   pa.ScalingAlgorithm(),
+			MaxScaleUpRate:              config.MaxScaleUpRate,
+			MaxScaleDownRate:            config.MaxScaleDownRate,
+			ScalingMetric:               pa.Metric(),
+			TargetValue:                 target,
+			TotalValue:                  total,
+			TargetBurstCapacity:         tbc,
+			ActivatorCapacity:           config.ActivatorCapacity,
+			PanicThreshold:              panicThreshold,
+			StepChangeThreshold:         config.StepChangeThreshold,
+			StableWindow:                resources.StableWindow(pa, config),
+			ScaleDownDelay:              scaleDownDelay,
+			InitialScale:                GetInitialScale(config, pa),
+			Reachable:                   pa.Spec.Reachability != autoscalingv1alpha1.ReachabilityUnreachable,
+			ActivatorQueueThreshold:     config.ActivatorQueueThreshold,
+			ActivatorFallbackErrorRatio: config.ActivatorFallbackErrorRatio,
 		},
 	}
+	if x, ok := pa.PanicExitThresholdPercentage(); ok {
+		d.Spec.PanicExitThreshold = x / 100.0
+	}
+	if x, ok := pa.PanicCooldown(); ok {
+		d.Spec.PanicCooldown = x
+	}
+	if limit, ok := pa.MaxScaleDownPerMinute(); ok {
+		d.Spec.MaxScaleDownPerMinute = limit
+	}
+	predictionPeriod := config.PredictionPeriod
+	if pp, ok := pa.PredictionPeriod(); ok {
+		predictionPeriod = pp
+	}
+	if predictionPeriod > 0 {
+		d.Spec.PredictionPeriod = predictionPeriod
+		d.Spec.PredictionSeasons = config.PredictionSeasons
+		d.Spec.PredictionLookahead = config.PredictionLookahead
+	}
+	if combined, ok := pa.CombinedMetrics(); ok {
+		for _, m := range combined {
+			d.Spec.CombinedMetrics = append(d.Spec.CombinedMetrics, scaling.MetricTarget{
+				Metric:      m.Metric,
+				TargetValue: resources.ResolveCombinedMetricTarget(pa, config, m.Metric, m.Value),
+				Weight:      pa.MetricWeight(m.Metric),
+			})
+		}
+		d.Spec.MetricCombination = pa.MetricCombination()
+		d.Spec.ScalingMetricWeight = pa.MetricWeight(pa.Metric())
+	}
+	return d
 }
 
 // GetInitialScale returns the calculated initial scale based on the autoscaler
@@ -151,6 +151,132 @@ func TestMakeDecider(t *testing.T) {
 			return &c
 		},
 		want: decider(withTarget(100.0), withPanicThreshold(2.0), withTotal(100), withScaleDownDelay(10*time.Minute), withDeciderScaleDownDelayAnnotation("10m")),
+	}, {
+		name: "with activator queue threshold",
+		pa:   pa(),
+		cfgOpt: func(c autoscalerconfig.Config) *autoscalerconfig.Config {
+			c.ActivatorQueueThreshold = 10
+			return &c
+		},
+		want: decider(withTarget(100.0), withPanicThreshold(2.0), withTotal(100), func(d *scaling.Decider) {
+			d.Spec.ActivatorQueueThreshold = 10
+		}),
+	}, {
+		name: "with activator fallback error ratio",
+		pa:   pa(),
+		cfgOpt: func(c autoscalerconfig.Config) *autoscalerconfig.Config {
+			c.ActivatorFallbackErrorRatio = 0.5
+			return &c
+		},
+		want: decider(withTarget(100.0), withPanicThreshold(2.0), withTotal(100), func(d *scaling.Decider) {
+			d.Spec.ActivatorFallbackErrorRatio = 0.5
+		}),
+	}, {
+		name: "with prediction",
+		pa:   pa(),
+		cfgOpt: func(c autoscalerconfig.Config) *autoscalerconfig.Config {
+			c.PredictionPeriod = 24 * time.Hour
+			c.PredictionSeasons = 7
+			c.PredictionLookahead = 10 * time.Minute
+			return &c
+		},
+		want: decider(withTarget(100.0), withPanicThreshold(2.0), withTotal(100), func(d *scaling.Decider) {
+			d.Spec.PredictionPeriod = 24 * time.Hour
+			d.Spec.PredictionSeasons = 7
+			d.Spec.PredictionLookahead = 10 * time.Minute
+		}),
+	}, {
+		name: "with scaling algorithm annotation",
+		pa: pa(func(pa *v1alpha1.PodAutoscaler) {
+			pa.Annotations[autoscaling.ScalingAlgorithmAnnotationKey] = "pid"
+		}),
+		want: decider(withTarget(100.0), withPanicThreshold(2.0), withTotal(100), func(d *scaling.Decider) {
+			d.Annotations[autoscaling.ScalingAlgorithmAnnotationKey] = "pid"
+			d.Spec.Algorithm = "pid"
+		}),
+	}, {
+		name: "with max scale down per minute annotation",
+		pa: pa(func(pa *v1alpha1.PodAutoscaler) {
+			pa.Annotations[autoscaling.MaxScaleDownPerMinuteAnnotationKey] = "10%"
+		}),
+		want: decider(withTarget(100.0), withPanicThreshold(2.0), withTotal(100), func(d *scaling.Decider) {
+			d.Annotations[autoscaling.MaxScaleDownPerMinuteAnnotationKey] = "10%"
+			d.Spec.MaxScaleDownPerMinute = autoscaling.ScaleDownLimit{Value: 10, Percent: true}
+		}),
+	}, {
+		name: "with panic exit threshold and cooldown annotations",
+		pa: pa(func(pa *v1alpha1.PodAutoscaler) {
+			pa.Annotations[autoscaling.PanicExitThresholdPercentageAnnotationKey] = "150"
+			pa.Annotations[autoscaling.PanicCooldownAnnotationKey] = "2m"
+		}),
+		want: decider(withTarget(100.0), withPanicThreshold(2.0), withTotal(100), func(d *scaling.Decider) {
+			d.Annotations[autoscaling.PanicExitThresholdPercentageAnnotationKey] = "150"
+			d.Annotations[autoscaling.PanicCooldownAnnotationKey] = "2m"
+			d.Spec.PanicExitThreshold = 1.5
+			d.Spec.PanicCooldown = 2 * time.Minute
+		}),
+	}, {
+		name: "with prediction period annotation",
+		pa: pa(func(pa *v1alpha1.PodAutoscaler) {
+			pa.Annotations[autoscaling.PredictionPeriodAnnotationKey] = "168h"
+		}),
+		cfgOpt: func(c autoscalerconfig.Config) *autoscalerconfig.Config {
+			c.PredictionSeasons = 2
+			c.PredictionLookahead = 10 * time.Minute
+			return &c
+		},
+		want: decider(withTarget(100.0), withPanicThreshold(2.0), withTotal(100), func(d *scaling.Decider) {
+			d.Annotations[autoscaling.PredictionPeriodAnnotationKey] = "168h"
+			d.Spec.PredictionPeriod = 168 * time.Hour
+			d.Spec.PredictionSeasons = 2
+			d.Spec.PredictionLookahead = 10 * time.Minute
+		}),
+	}, {
+		name: "prediction disabled by annotation",
+		pa: pa(func(pa *v1alpha1.PodAutoscaler) {
+			pa.Annotations[autoscaling.PredictionPeriodAnnotationKey] = "0s"
+		}),
+		cfgOpt: func(c autoscalerconfig.Config) *autoscalerconfig.Config {
+			c.PredictionPeriod = 24 * time.Hour
+			return &c
+		},
+		want: decider(withTarget(100.0), withPanicThreshold(2.0), withTotal(100), func(d *scaling.Decider) {
+			d.Annotations[autoscaling.PredictionPeriodAnnotationKey] = "0s"
+		}),
+	}, {
+		name: "with combined metrics",
+		pa: pa(func(pa *v1alpha1.PodAutoscaler) {
+			pa.Annotations[autoscaling.CombinedMetricsAnnotationKey] = "rps=150, custom=5"
+		}),
+		want: decider(withTarget(100.0), withPanicThreshold(2.0), withTotal(100), func(d *scaling.Decider) {
+			d.Annotations[autoscaling.CombinedMetricsAnnotationKey] = "rps=150, custom=5"
+			d.Spec.CombinedMetrics = []scaling.MetricTarget{
+				{Metric: autoscaling.RPS, TargetValue: 150, Weight: 1},
+				{Metric: autoscaling.Custom, TargetValue: 5, Weight: 1},
+			}
+			d.Spec.MetricCombination = autoscaling.MetricCombinationMax
+			d.Spec.ScalingMetricWeight = 1
+		}),
+	}, {
+		name: "with weighted combined metrics",
+		pa: pa(WithPAContainerConcurrency(10), WithMetricAnnotation(autoscaling.RPS), func(pa *v1alpha1.PodAutoscaler) {
+			pa.Annotations[autoscaling.CombinedMetricsAnnotationKey] = "concurrency=20"
+			pa.Annotations[autoscaling.MetricCombinationAnnotationKey] = autoscaling.MetricCombinationWeighted
+			pa.Annotations[autoscaling.MetricWeightsAnnotationKey] = "rps=3"
+		}),
+		cfgOpt: func(c autoscalerconfig.Config) *autoscalerconfig.Config {
+			c.ContainerConcurrencyTargetFraction = 0.5
+			return &c
+		},
+		want: decider(withMetric(autoscaling.RPS), withMetricAnnotation(autoscaling.RPS), withTarget(100.0), withPanicThreshold(2.0), withTotal(100), func(d *scaling.Decider) {
+			d.Annotations[autoscaling.CombinedMetricsAnnotationKey] = "concurrency=20"
+			d.Annotations[autoscaling.MetricCombinationAnnotationKey] = autoscaling.MetricCombinationWeighted
+			d.Annotations[autoscaling.MetricWeightsAnnotationKey] = "rps=3"
+			// Capped by the container concurrency.
+			d.Spec.CombinedMetrics = []scaling.MetricTarget{{Metric: autoscaling.Concurrency, TargetValue: 5, Weight: 1}}
+			d.Spec.MetricCombination = autoscaling.MetricCombinationWeighted
+			d.Spec.ScalingMetricWeight = 3
+		}),
 	}, {
 		name: "with initial scale",
 		pa: pa(func(pa *v1alpha1.PodAutoscaler) {
@@ -287,6 +413,7 @@ func decider(options ...deciderOption) *scaling.Decider {
 			},
 		},
 		Spec: scaling.DeciderSpec{
+			Algorithm:           autoscaling.ScalingAlgorithmWindow,
 			MaxScaleUpRate:      config.MaxScaleUpRate,
 			ScalingMetric:       "concurrency",
 			TargetValue:         100,
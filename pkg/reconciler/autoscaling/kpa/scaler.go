@@ -34,6 +34,7 @@ import (
 	"knative.dev/serving/pkg/activator"
 	autoscalingv1alpha1 "knative.dev/serving/pkg/apis/autoscaling/v1alpha1"
 	"knative.dev/serving/pkg/autoscaler/config/autoscalerconfig"
+	"knative.dev/serving/pkg/deployment"
 	"knative.dev/serving/pkg/reconciler/autoscaling/config"
 	kparesources "knative.dev/serving/pkg/reconciler/autoscaling/kpa/resources"
 	aresources "knative.dev/serving/pkg/reconciler/autoscaling/resources"
@@ -138,6 +139,13 @@ func activatorProbe(pa *autoscalingv1alpha1.PodAutoscaler, transport http.RoundT
 	return prober.Do(context.Background(), transport, paToProbeTarget(pa), probeOptions...)
 }
 
+func scaleToZeroEnabled(pa *autoscalingv1alpha1.PodAutoscaler, cfg *autoscalerconfig.Config) bool {
+	if enabled, ok := pa.EnableScaleToZero(); ok {
+		return enabled
+	}
+	return cfg.EnableScaleToZero
+}
+
 func lastPodRetention(pa *autoscalingv1alpha1.PodAutoscaler, cfg *autoscalerconfig.Config) time.Duration {
 	d, ok := pa.ScaleToZeroPodRetention()
 	if ok {
@@ -146,6 +154,34 @@ func lastPodRetention(pa *autoscalingv1alpha1.PodAutoscaler, cfg *autoscalerconf
 	return cfg.ScaleToZeroPodRetentionPeriod
 }
 
+// keepWarmPeriod returns how long the last pod is kept after the PA became
+// inactive, for it to be resumed rather than started cold. The queue-proxy
+// pauses it meanwhile, so it's only kept if the concurrency state endpoint is
+// configured.
+func keepWarmPeriod(pa *autoscalingv1alpha1.PodAutoscaler, cfg *deployment.Config) time.Duration {
+	if cfg.ConcurrencyStateEndpoint == "" {
+		return 0
+	}
+	d, _ := pa.KeepWarmPeriod()
+	return d
+}
+
+// scaleToZeroExclusion returns how much longer the PA isn't scaled to zero,
+// after its scale target was first initialized, or a non-positive duration if
+// it may be.
+func scaleToZeroExclusion(pa *autoscalingv1alpha1.PodAutoscaler, now time.Time) time.Duration {
+	d, ok := pa.ScaleToZeroExclusionPeriod()
+	if !ok || d == 0 {
+		return 0
+	}
+	initFor := pa.Status.ScaleTargetInitializedFor(now)
+	if initFor < 0 {
+		// Not ready yet, the activation timeout applies.
+		return 0
+	}
+	return d - initFor
+}
+
 // pre: 0 <= min <= max && 0 <= x
 func applyBounds(min, max, x int32) int32 {
 	if x < min {
@@ -171,7 +207,8 @@ func (ks *scaler) handleScaleToZero(ctx context.Context, pa *autoscalingv1alpha1
 	}
 
 	// We should only scale to zero when three of the following conditions are true:
-	//   a) enable-scale-to-zero from configmap is true
+	//   a) enable-scale-to-zero from the PA annotation, or the configmap if unset, is true,
+	//      and the scale-to-zero exclusion period since the PA first became ready is over
 	//   b) The PA has been active for at least the stable window, after which it
 	//			gets marked inactive, and
 	//   c) the PA has been backed by the Activator for at least the grace period
@@ -181,18 +218,33 @@ func (ks *scaler) handleScaleToZero(ctx context.Context, pa *autoscalingv1alpha1
 	cfgs := config.FromContext(ctx)
 	cfgAS := cfgs.Autoscaler
 
-	if !cfgAS.EnableScaleToZero {
+	if !scaleToZeroEnabled(pa, cfgAS) && !pa.Spec.Hibernated {
 		return 1, true
 	}
-	cfgD := cfgs.Deployment
-	activationTimeout := cfgD.ProgressDeadline + activationTimeoutBuffer
 
 	now := time.Now()
 	logger := logging.FromContext(ctx)
+	// A freshly deployed revision is kept at 1 for the exclusion period after
+	// it first became ready, whatever its traffic.
+	if ex := scaleToZeroExclusion(pa, now); ex > 0 && !pa.Spec.Hibernated {
+		logger.Infof("Can't scale to 0 for another %v after the revision first became ready", ex)
+		ks.enqueueCB(pa, ex)
+		return 1, true
+	}
+
+	cfgD := cfgs.Deployment
+	activationTimeout := cfgD.ProgressDeadline
+	// Slow booting pods are given the time their startup probe allows them.
+	if sw := time.Duration(pa.Spec.StartupWindowSeconds) * time.Second; sw > activationTimeout {
+		activationTimeout = sw
+	}
+	activationTimeout += activationTimeoutBuffer
+
 	switch {
 	case pa.Status.IsActivating(): // Active=Unknown
 		// If we are stuck activating for longer than our progress deadline, presume we cannot succeed and scale to 0.
-		if pa.Status.CanFailActivation(now, activationTimeout) {
+		// A hibernated target doesn't need to be activated at all.
+		if pa.Spec.Hibernated || pa.Status.CanFailActivation(now, activationTimeout) {
 			logger.Info("Activation has timed out after ", activationTimeout)
 			return desiredScale, true
 		}
@@ -203,7 +255,7 @@ func (ks *scaler) handleScaleToZero(ctx context.Context, pa *autoscalingv1alpha1
 		// but return `(0, false)` to mark PA inactive, instead.
 		sw := aresources.StableWindow(pa, cfgAS)
 		af := pa.Status.ActiveFor(now)
-		if af >= sw {
+		if af >= sw || pa.Spec.Hibernated {
 			// If SKS is in proxy mode, then there is high probability
 			// of SKS not changing its spec/status and thus not triggering
 			// a new reconciliation of PA.
@@ -239,7 +291,11 @@ func (ks *scaler) handleScaleToZero(ctx context.Context, pa *autoscalingv1alpha1
 			// And at least ScaleToZeroPodRetentionPeriod since PA became inactive.
 
 			// Most conservative check, if it passes we're good.
-			lastPodTimeout := lastPodRetention(pa, cfgAS)
+			lastPodTimeout := durationMax(lastPodRetention(pa, cfgAS), keepWarmPeriod(pa, cfgD))
+			if pa.Spec.Hibernated {
+				// The last pod isn't kept around for a hibernated target.
+				lastPodTimeout = 0
+			}
 			lastPodMaxTimeout := durationMax(cfgAS.ScaleToZeroGracePeriod, lastPodTimeout)
 			// If we have been inactive for this long, we can scale to 0!
 			if pa.Status.InactiveFor(now) >= lastPodMaxTimeout {
@@ -320,18 +376,26 @@ func (ks *scaler) scale(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscal
 	asConfig := config.FromContext(ctx).Autoscaler
 	logger := logging.FromContext(ctx)
 
-	if desiredScale < 0 && !pa.Status.IsActivating() {
+	if desiredScale < 0 && !pa.Status.IsActivating() && !pa.Spec.Hibernated {
 		logger.Debug("Metrics are not yet being collected.")
 		return desiredScale, nil
 	}
 
 	min, max := pa.ScaleBounds(asConfig)
+	if pa.Spec.Hibernated {
+		// A hibernated target is scaled to zero, whatever its traffic and bounds.
+		min, max, desiredScale = 0, 0, 0
+	}
+	if next, ok := pa.NextScaleBoundsChange(time.Now()); ok {
+		// Scale again once the next window of the schedule starts.
+		ks.enqueueCB(pa, time.Until(next))
+	}
 	initialScale := kparesources.GetInitialScale(asConfig, pa)
 	// Log reachability as quoted string, since default value is "".
 	logger.Debugf("MinScale = %d, MaxScale = %d, InitialScale = %d, DesiredScale = %d Reachable = %q",
 		min, max, initialScale, desiredScale, pa.Spec.Reachability)
 	// If initial scale has been attained, ignore the initialScale altogether.
-	if initialScale > 1 && !pa.Status.IsScaleTargetInitialized() {
+	if initialScale > 1 && !pa.Status.IsScaleTargetInitialized() && !pa.Spec.Hibernated {
 		// Ignore initial scale if minScale >= initialScale.
 		if min < initialScale {
 			logger.Debugf("Adjusting min to meet the initial scale: %d -> %d", min, initialScale)
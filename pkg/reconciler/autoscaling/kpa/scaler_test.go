@@ -194,6 +194,65 @@ func TestScaler(t *testing.T) {
 		wantReplicas: 0,
 		wantScaling:  false,
 		wantCBCount:  1,
+	}, {
+		label:         "can't scale to zero after grace period, but before keeping the paused pod warm",
+		startReplicas: 1,
+		scaleTo:       0,
+		paMutation: func(k *autoscalingv1alpha1.PodAutoscaler) {
+			paMarkInactive(k, time.Now().Add(-gracePeriod))
+			k.Annotations[autoscaling.KeepWarmPeriodAnnotationKey] = (2 * gracePeriod).String()
+		},
+		configMutator: func(c *config.Config) {
+			c.Deployment.ConcurrencyStateEndpoint = "freeze-proxy"
+		},
+		wantReplicas: 0,
+		wantScaling:  false,
+		wantCBCount:  1,
+	}, {
+		label:         "can't scale to zero during the exclusion period after first ready",
+		startReplicas: 1,
+		scaleTo:       0,
+		wantReplicas:  1,
+		wantScaling:   false,
+		paMutation: func(k *autoscalingv1alpha1.PodAutoscaler) {
+			paMarkActive(k, time.Now().Add(-stableWindow))
+			paMarkScaleTargetInitialized(k, time.Now().Add(-5*time.Minute))
+			k.Annotations[autoscaling.ScaleToZeroExclusionPeriodAnnotationKey] = "15m"
+		},
+		wantCBCount: 1,
+	}, {
+		label:         "scale to 1 during the exclusion period after first ready",
+		startReplicas: 3,
+		scaleTo:       0,
+		wantReplicas:  1,
+		wantScaling:   true,
+		paMutation: func(k *autoscalingv1alpha1.PodAutoscaler) {
+			paMarkInactive(k, time.Now().Add(-gracePeriod))
+			paMarkScaleTargetInitialized(k, time.Now().Add(-5*time.Minute))
+			k.Annotations[autoscaling.ScaleToZeroExclusionPeriodAnnotationKey] = "15m"
+		},
+		wantCBCount: 1,
+	}, {
+		label:         "scale to zero after the exclusion period after first ready",
+		startReplicas: 1,
+		scaleTo:       0,
+		wantReplicas:  0,
+		wantScaling:   true,
+		paMutation: func(k *autoscalingv1alpha1.PodAutoscaler) {
+			paMarkInactive(k, time.Now().Add(-gracePeriod))
+			paMarkScaleTargetInitialized(k, time.Now().Add(-20*time.Minute))
+			k.Annotations[autoscaling.ScaleToZeroExclusionPeriodAnnotationKey] = "15m"
+		},
+	}, {
+		label:         "scale to zero after grace period, keeping warm without pausing pods",
+		startReplicas: 1,
+		scaleTo:       0,
+		paMutation: func(k *autoscalingv1alpha1.PodAutoscaler) {
+			paMarkInactive(k, time.Now().Add(-gracePeriod))
+			k.Annotations[autoscaling.KeepWarmPeriodAnnotationKey] = (2 * gracePeriod).String()
+		},
+		wantReplicas: 0,
+		wantScaling:  true,
 	}, {
 		label:         "scale to zero after grace period, and after last pod retention",
 		startReplicas: 1,
@@ -323,6 +382,17 @@ func TestScaler(t *testing.T) {
 		paMutation: func(k *autoscalingv1alpha1.PodAutoscaler) {
 			paMarkActivating(k, time.Now().Add(-(activationTimeout + time.Second)))
 		},
+	}, {
+		label:         "waits to scale to zero while activating within the startup window",
+		startReplicas: 1,
+		scaleTo:       0,
+		wantReplicas:  -1,
+		wantScaling:   false,
+		paMutation: func(k *autoscalingv1alpha1.PodAutoscaler) {
+			k.Spec.StartupWindowSeconds = int64((2 * progressDeadline).Seconds())
+			paMarkActivating(k, time.Now().Add(-(activationTimeout + time.Second)))
+		},
+		wantCBCount: 1,
 	}, {
 		label:         "scale down to minScale before grace period",
 		startReplicas: 10,
@@ -334,6 +404,19 @@ func TestScaler(t *testing.T) {
 			paMarkInactive(k, time.Now().Add(-gracePeriod+time.Second))
 			WithReachabilityReachable(k)
 		},
+	}, {
+		label:         "scale down to the minimum of the schedule",
+		startReplicas: 10,
+		scaleTo:       0,
+		minScale:      2,
+		wantReplicas:  4,
+		wantScaling:   true,
+		wantCBCount:   1,
+		paMutation: func(k *autoscalingv1alpha1.PodAutoscaler) {
+			paMarkInactive(k, time.Now().Add(-gracePeriod))
+			WithReachabilityReachable(k)
+			WithScaleScheduleAnnotation("* * * * * min=4")(k)
+		},
 	}, {
 		label:         "scale down to minScale after grace period",
 		startReplicas: 10,
@@ -465,6 +548,32 @@ func TestScaler(t *testing.T) {
 		configMutator: func(c *config.Config) {
 			c.Autoscaler.AllowZeroInitialScale = true
 		},
+	}, {
+		label:         "hibernated deactivates right away, despite traffic",
+		startReplicas: 3,
+		scaleTo:       5,
+		minScale:      2,
+		wantReplicas:  0,
+		wantScaling:   false,
+		paMutation: func(k *autoscalingv1alpha1.PodAutoscaler) {
+			paMarkActive(k, time.Now())
+			k.Spec.Hibernated = true
+		},
+	}, {
+		label:         "hibernated scales to zero after grace period, ignoring last pod retention",
+		startReplicas: 3,
+		scaleTo:       5,
+		minScale:      2,
+		wantReplicas:  0,
+		wantScaling:   true,
+		paMutation: func(k *autoscalingv1alpha1.PodAutoscaler) {
+			paMarkInactive(k, time.Now().Add(-gracePeriod))
+			k.Spec.Hibernated = true
+		},
+		configMutator: func(c *config.Config) {
+			c.Autoscaler.EnableScaleToZero = false
+			c.Autoscaler.ScaleToZeroPodRetentionPeriod = 2 * gracePeriod
+		},
 	}}
 
 	for _, test := range tests {
@@ -626,6 +735,69 @@ func TestDisableScaleToZero(t *testing.T) {
 	}
 }
 
+func TestDisableScaleToZeroAnnotation(t *testing.T) {
+	tests := []struct {
+		label        string
+		minScale     int32
+		wantReplicas int32
+	}{{
+		label:        "enableScaleToZero == false and minScale == 0",
+		wantReplicas: 1,
+	}, {
+		label:        "enableScaleToZero == false and minScale == 3",
+		minScale:     3,
+		wantReplicas: 3,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.label, func(t *testing.T) {
+			ctx, _, _ := SetupFakeContextWithCancel(t, func(ctx context.Context) context.Context {
+				return filteredinformerfactory.WithSelectors(ctx, serving.RevisionUID)
+			})
+
+			dynamicClient := fakedynamicclient.Get(ctx)
+			gotScaling := false
+			dynamicClient.PrependReactor("patch", "deployments",
+				func(action clientgotesting.Action) (bool, runtime.Object, error) {
+					gotScaling = true
+					return true, nil, nil
+				})
+
+			revision := newRevision(ctx, t, fakeservingclient.Get(ctx), test.minScale, 0 /*maxScale*/)
+			deployment := newDeployment(ctx, t, dynamicClient, names.Deployment(revision), 10)
+			psInformerFactory := podscalable.Get(ctx)
+			revisionScaler := &scaler{
+				dynamicClient: fakedynamicclient.Get(ctx),
+				listerFactory: func(gvr schema.GroupVersionResource) (cache.GenericLister, error) {
+					_, l, err := psInformerFactory.Get(ctx, gvr)
+					return l, err
+				},
+			}
+			pa := newKPA(ctx, t, fakeservingclient.Get(ctx), revision)
+			pa.Annotations[autoscaling.EnableScaleToZeroAnnotationKey] = "false"
+			// The revision has been idle for a long time, so it would scale
+			// to zero if it was allowed to.
+			paMarkInactive(pa, time.Now().Add(-time.Hour))
+			WithReachabilityReachable(pa)
+
+			conf := defaultConfig()
+			conf.Autoscaler.EnableScaleToZero = true
+			ctx = config.ToContext(ctx, conf)
+			desiredScale, err := revisionScaler.scale(ctx, pa, nil /*sks doesn't matter in this test*/, 0)
+			if err != nil {
+				t.Fatal("Scale got an unexpected error:", err)
+			}
+			if desiredScale != test.wantReplicas {
+				t.Errorf("desiredScale = %d, wanted %d", desiredScale, test.wantReplicas)
+			}
+			if !gotScaling {
+				t.Error("want scaling, but got no scaling")
+			}
+			checkReplicas(t, dynamicClient, deployment, test.wantReplicas)
+		})
+	}
+}
+
 func newKPA(ctx context.Context, t *testing.T, servingClient clientset.Interface, revision *v1.Revision) *autoscalingv1alpha1.PodAutoscaler {
 	t.Helper()
 	pa := revisionresources.MakePA(revision)
@@ -710,6 +882,15 @@ func paMarkActive(pa *autoscalingv1alpha1.PodAutoscaler, ltt time.Time) {
 	pa.Status.Conditions[0].LastTransitionTime = apis.VolatileTime{Inner: metav1.NewTime(ltt)}
 }
 
+func paMarkScaleTargetInitialized(pa *autoscalingv1alpha1.PodAutoscaler, ltt time.Time) {
+	pa.Status.MarkScaleTargetInitialized()
+	for i := range pa.Status.Conditions {
+		if pa.Status.Conditions[i].Type == autoscalingv1alpha1.PodAutoscalerConditionScaleTargetInitialized {
+			pa.Status.Conditions[i].LastTransitionTime = apis.VolatileTime{Inner: metav1.NewTime(ltt)}
+		}
+	}
+}
+
 func paMarkInactive(pa *autoscalingv1alpha1.PodAutoscaler, ltt time.Time) {
 	pa.Status.MarkInactive("", "")
 
@@ -102,3 +102,22 @@ func (c *Base) ReconcileMetric(ctx context.Context, pa *autoscalingv1alpha1.PodA
 
 	return nil
 }
+
+// DeleteMetric deletes the metric of the given PodAutoscaler, if it exists, to
+// stop metric collection.
+func (c *Base) DeleteMetric(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler) error {
+	metric, err := c.MetricLister.Metrics(pa.Namespace).Get(pa.Name)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error fetching metric: %w", err)
+	} else if !metav1.IsControlledBy(metric, pa) {
+		pa.Status.MarkResourceNotOwned("Metric", pa.Name)
+		return fmt.Errorf("PA: %s does not own Metric: %s", pa.Name, pa.Name)
+	}
+
+	if err := c.Client.AutoscalingV1alpha1().Metrics(pa.Namespace).Delete(ctx, pa.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error deleting metric: %w", err)
+	}
+	return nil
+}
@@ -29,12 +29,13 @@ import (
 // `target` is the target value of scaling metric that we autoscaler will aim for;
 // `total` is the maximum possible value of scaling metric that is permitted on the pod.
 func ResolveMetricTarget(pa *v1alpha1.PodAutoscaler, config *autoscalerconfig.Config) (target, total float64) {
-	tu := 0.
-
 	switch pa.Metric() {
 	case autoscaling.RPS:
 		total = config.RPSTargetDefault
-		tu = config.TargetUtilization
+	case autoscaling.Custom:
+		// The target of a custom metric is required.
+	case autoscaling.GPU:
+		total = autoscaling.GPUUtilizationMax
 	default:
 		// Concurrency is used by default
 		total = float64(pa.Spec.ContainerConcurrency)
@@ -42,11 +43,15 @@ func ResolveMetricTarget(pa *v1alpha1.PodAutoscaler, config *autoscalerconfig.Co
 		if total == 0 {
 			total = config.ContainerConcurrencyTargetDefault
 		}
-		tu = config.ContainerConcurrencyTargetFraction
 	}
 
 	// Use the target provided via annotation, if applicable.
 	if annotationTarget, ok := pa.Target(); ok {
+		if pa.Metric() == autoscaling.GPU {
+			// The target of the gpu metric is the utilization percentage to
+			// aim for itself, the GPUs of a pod may be fully utilized.
+			return math.Max(autoscaling.TargetMin, annotationTarget), total
+		}
 		total = annotationTarget
 		if pa.Metric() == autoscaling.Concurrency && pa.Spec.ContainerConcurrency != 0 {
 			// We pick the smaller value between container concurrency and the annotationTarget
@@ -56,10 +61,35 @@ func ResolveMetricTarget(pa *v1alpha1.PodAutoscaler, config *autoscalerconfig.Co
 		}
 	}
 
-	if v, ok := pa.TargetUtilization(); ok {
-		tu = v
-	}
-	target = math.Max(autoscaling.TargetMin, total*tu)
+	target = math.Max(autoscaling.TargetMin, total*targetUtilization(pa, config, pa.Metric()))
 
 	return target, total
 }
+
+// ResolveCombinedMetricTarget resolves the target of one of the metrics given
+// by the combinedMetrics annotation from its value, the maximum permitted on
+// the pod, the same way ResolveMetricTarget does for the scaling metric.
+func ResolveCombinedMetricTarget(pa *v1alpha1.PodAutoscaler, config *autoscalerconfig.Config, metric string, total float64) float64 {
+	if metric == autoscaling.Concurrency && pa.Spec.ContainerConcurrency != 0 {
+		total = math.Min(total, float64(pa.Spec.ContainerConcurrency))
+	}
+	return math.Max(autoscaling.TargetMin, total*targetUtilization(pa, config, metric))
+}
+
+// targetUtilization returns the fraction of the maximum value of the metric
+// to aim for.
+func targetUtilization(pa *v1alpha1.PodAutoscaler, config *autoscalerconfig.Config, metric string) float64 {
+	if v, ok := pa.TargetUtilization(); ok {
+		return v
+	}
+	switch metric {
+	case autoscaling.RPS, autoscaling.GPU:
+		return config.TargetUtilization
+	case autoscaling.Custom:
+		// The target of a custom metric is maintained as is, as only the app
+		// knows how much of its metric a pod can take.
+		return 1
+	default:
+		return config.ContainerConcurrencyTargetFraction
+	}
+}
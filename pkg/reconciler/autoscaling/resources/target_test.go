@@ -170,6 +170,26 @@ func TestResolveMetricTarget(t *testing.T) {
 		pa:         pa(WithMetricAnnotation(autoscaling.RPS), WithTargetAnnotation("300")),
 		wantTarget: 210,
 		wantTotal:  300,
+	}, {
+		name:       "custom: with target annotation 5",
+		pa:         pa(WithMetricAnnotation(autoscaling.Custom), WithTargetAnnotation("5")),
+		wantTarget: 5,
+		wantTotal:  5,
+	}, {
+		name:       "custom: with TU annotation 80%",
+		pa:         pa(WithMetricAnnotation(autoscaling.Custom), WithTargetAnnotation("5"), WithTUAnnotation("80")),
+		wantTarget: 4,
+		wantTotal:  5,
+	}, {
+		name:       "gpu: default",
+		pa:         pa(WithMetricAnnotation(autoscaling.GPU)),
+		wantTarget: 70,
+		wantTotal:  100,
+	}, {
+		name:       "gpu: with target annotation 80",
+		pa:         pa(WithMetricAnnotation(autoscaling.GPU), WithTargetAnnotation("80")),
+		wantTarget: 80,
+		wantTotal:  100,
 	}}
 
 	for _, tc := range cases {
@@ -186,3 +206,51 @@ func TestResolveMetricTarget(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveCombinedMetricTarget(t *testing.T) {
+	cases := []struct {
+		name       string
+		pa         *v1alpha1.PodAutoscaler
+		metric     string
+		total      float64
+		wantTarget float64
+	}{{
+		name:       "rps",
+		pa:         pa(),
+		metric:     autoscaling.RPS,
+		total:      100,
+		wantTarget: 70,
+	}, {
+		name:       "rps with TU annotation 50%",
+		pa:         pa(WithTUAnnotation("50")),
+		metric:     autoscaling.RPS,
+		total:      100,
+		wantTarget: 50,
+	}, {
+		name:       "concurrency capped by container concurrency",
+		pa:         pa(WithPAContainerConcurrency(10), WithMetricAnnotation(autoscaling.RPS)),
+		metric:     autoscaling.Concurrency,
+		total:      20,
+		wantTarget: 10,
+	}, {
+		name:       "custom",
+		pa:         pa(),
+		metric:     autoscaling.Custom,
+		total:      5,
+		wantTarget: 5,
+	}, {
+		name:       "target min",
+		pa:         pa(),
+		metric:     autoscaling.RPS,
+		total:      0.001,
+		wantTarget: autoscaling.TargetMin,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ResolveCombinedMetricTarget(tc.pa, config, tc.metric, tc.total); got != tc.wantTarget {
+				t.Errorf("ResolveCombinedMetricTarget() = %v, want: %v", got, tc.wantTarget)
+			}
+		})
+	}
+}
@@ -128,6 +128,29 @@ func (c *Reconciler) ReconcileKind(ctx context.Context, config *v1.Configuration
 	if err = c.findAndSetLatestReadyRevision(ctx, config); err != nil {
 		return fmt.Errorf("failed to find and set latest ready revision: %w", err)
 	}
+	if err = c.setPreservedRevisionNames(config); err != nil {
+		return fmt.Errorf("failed to list the preserved revisions: %w", err)
+	}
+	return nil
+}
+
+// setPreservedRevisionNames surfaces the revisions of the configuration that
+// are exempt from garbage collection.
+func (c *Reconciler) setPreservedRevisionNames(config *v1.Configuration) error {
+	revs, err := c.revisionLister.Revisions(config.Namespace).List(labels.SelectorFromSet(labels.Set{
+		serving.ConfigurationLabelKey: config.Name,
+	}))
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, rev := range revs {
+		if rev.IsPreserved() {
+			names = append(names, rev.Name)
+		}
+	}
+	sort.Strings(names)
+	config.Status.PreservedRevisionNames = names
 	return nil
 }
 
@@ -246,9 +269,12 @@ func CheckNameAvailability(ctx context.Context, config *v1.Configuration, lister
 		return rev, nil
 	}
 	// We only require spec equality because the rest is immutable and the user may have
-	// annotated or labeled the Revision (beyond what the Configuration might have).
-	if !equality.Semantic.DeepEqual(config.Spec.GetTemplate().Spec, rev.Spec) {
-		diff, err := kmp.SafeDiff(config.Spec.GetTemplate().Spec, rev.Spec)
+	// annotated or labeled the Revision (beyond what the Configuration might have),
+	// or hibernated it.
+	spec := rev.Spec
+	spec.Hibernated = false
+	if !equality.Semantic.DeepEqual(config.Spec.GetTemplate().Spec, spec) {
+		diff, err := kmp.SafeDiff(config.Spec.GetTemplate().Spec, spec)
 		if err != nil {
 			logger.Errorf("Fail to Diff Revision %s spec and its Configration's spec template %v", rev.GetName(), err)
 		}
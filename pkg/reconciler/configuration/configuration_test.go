@@ -37,6 +37,7 @@ import (
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/ptr"
+	"knative.dev/serving/pkg/apis/serving"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 	servingclient "knative.dev/serving/pkg/client/injection/client/fake"
 	configreconciler "knative.dev/serving/pkg/client/injection/reconciler/serving/v1/configuration"
@@ -287,6 +288,24 @@ func TestReconcile(t *testing.T) {
 				WithCreationTimestamp(now), MarkRevisionReady, WithRevName("matching-revision")),
 		},
 		Key: "foo/matching-revision-done-idempotent",
+	}, {
+		Name: "surface the preserved revisions",
+		Ctx:  config.ToContext(context.Background(), config.FromContext(testCtx)),
+		Objects: []runtime.Object{
+			cfg("preserved-revisions", "foo", 2,
+				WithConfigObservedGen, WithLatestCreated("preserved-revisions-00002"), WithLatestReady("preserved-revisions-00002")),
+			rev("preserved-revisions", "foo", 1,
+				WithCreationTimestamp(now), MarkRevisionReady, WithRevName("preserved-revisions-00001"),
+				WithRevisionAnn(serving.RevisionPreservedAnnotationKey, "true")),
+			rev("preserved-revisions", "foo", 2,
+				WithCreationTimestamp(now), MarkRevisionReady, WithRevName("preserved-revisions-00002")),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: cfg("preserved-revisions", "foo", 2,
+				WithConfigObservedGen, WithLatestCreated("preserved-revisions-00002"), WithLatestReady("preserved-revisions-00002"),
+				WithPreservedRevisions("preserved-revisions-00001")),
+		}},
+		Key: "foo/preserved-revisions",
 	}, {
 		Name: "reconcile revision matching generation (ready: false)",
 		Ctx:  config.ToContext(context.Background(), config.FromContext(testCtx)),
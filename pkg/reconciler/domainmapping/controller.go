@@ -44,10 +44,11 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 	domainClaimInformer := domainclaiminformer.Get(ctx)
 
 	r := &Reconciler{
-		certificateLister: certificateInformer.Lister(),
-		ingressLister:     ingressInformer.Lister(),
-		domainClaimLister: domainClaimInformer.Lister(),
-		netclient:         netclient.Get(ctx),
+		certificateLister:   certificateInformer.Lister(),
+		ingressLister:       ingressInformer.Lister(),
+		domainClaimLister:   domainClaimInformer.Lister(),
+		domainMappingLister: domainmappingInformer.Lister(),
+		netclient:           netclient.Get(ctx),
 	}
 
 	impl := kindreconciler.NewImpl(ctx, r, func(impl *controller.Impl) controller.Options {
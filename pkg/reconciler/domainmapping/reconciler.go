@@ -31,6 +31,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	networkingpkg "knative.dev/networking/pkg"
 	"knative.dev/networking/pkg/apis/networking"
@@ -47,6 +48,7 @@ import (
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 	"knative.dev/serving/pkg/apis/serving/v1alpha1"
 	domainmappingreconciler "knative.dev/serving/pkg/client/injection/reconciler/serving/v1alpha1/domainmapping"
+	listers "knative.dev/serving/pkg/client/listers/serving/v1alpha1"
 	"knative.dev/serving/pkg/reconciler/domainmapping/config"
 	"knative.dev/serving/pkg/reconciler/domainmapping/resources"
 	routeresources "knative.dev/serving/pkg/reconciler/route/resources"
@@ -54,11 +56,12 @@ import (
 
 // Reconciler implements controller.Reconciler for DomainMapping resources.
 type Reconciler struct {
-	certificateLister networkinglisters.CertificateLister
-	ingressLister     networkinglisters.IngressLister
-	domainClaimLister networkinglisters.ClusterDomainClaimLister
-	netclient         netclientset.Interface
-	resolver          *resolver.URIResolver
+	certificateLister   networkinglisters.CertificateLister
+	ingressLister       networkinglisters.IngressLister
+	domainClaimLister   networkinglisters.ClusterDomainClaimLister
+	domainMappingLister listers.DomainMappingLister
+	netclient           netclientset.Interface
+	resolver            *resolver.URIResolver
 }
 
 // Check that our Reconciler implements Interface
@@ -93,8 +96,9 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, dm *v1alpha1.DomainMappi
 		dm.Status.MarkIngressNotConfigured()
 	}
 
-	// Mapped URL is the metadata.name of the DomainMapping.
-	url := &apis.URL{Scheme: config.FromContext(ctx).Network.DefaultExternalScheme, Host: dm.Name}
+	// Mapped URL is the domain of the DomainMapping, or all of its
+	// subdomains for a wildcard mapping, with its path.
+	url := &apis.URL{Scheme: config.FromContext(ctx).Network.DefaultExternalScheme, Host: dm.Host(), Path: dm.Spec.Path}
 	dm.Status.URL = url
 	dm.Status.Address = &duckv1.Addressable{URL: url}
 
@@ -158,7 +162,7 @@ func (r *Reconciler) FinalizeKind(ctx context.Context, dm *v1alpha1.DomainMappin
 		return nil
 	}
 
-	dc, err := r.domainClaimLister.Get(dm.Name)
+	dc, err := r.domainClaimLister.Get(dm.Domain())
 	if err != nil {
 		if apierrs.IsNotFound(err) {
 			// Nothing to do since the domain was never claimed.
@@ -174,7 +178,18 @@ func (r *Reconciler) FinalizeKind(ctx context.Context, dm *v1alpha1.DomainMappin
 		return nil
 	}
 
-	return r.netclient.NetworkingV1alpha1().ClusterDomainClaims().Delete(ctx, dm.Name, metav1.DeleteOptions{})
+	// Other DomainMappings of the namespace may still mount paths of the domain.
+	dms, err := r.domainMappingLister.DomainMappings(dm.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, other := range dms {
+		if other.Name != dm.Name && other.DeletionTimestamp == nil && other.Domain() == dm.Domain() {
+			return nil
+		}
+	}
+
+	return r.netclient.NetworkingV1alpha1().ClusterDomainClaims().Delete(ctx, dm.Domain(), metav1.DeleteOptions{})
 }
 
 func autoTLSEnabled(ctx context.Context, dm *v1alpha1.DomainMapping) bool {
@@ -194,7 +209,10 @@ func autoTLSEnabled(ctx context.Context, dm *v1alpha1.DomainMapping) bool {
 	return !disabledByAnnotation
 }
 
-func certClass(ctx context.Context) string {
+func certClass(ctx context.Context, dm *v1alpha1.DomainMapping) string {
+	if class := dm.Annotations[networking.CertificateClassAnnotationKey]; class != "" {
+		return class
+	}
 	return config.FromContext(ctx).Network.DefaultCertificateClass
 }
 
@@ -203,7 +221,7 @@ func (r *Reconciler) tls(ctx context.Context, dm *v1alpha1.DomainMapping) ([]net
 		dm.Status.MarkCertificateNotRequired(v1alpha1.TLSCertificateProvidedExternally)
 		dm.Status.URL.Scheme = "https"
 		return []netv1alpha1.IngressTLS{{
-			Hosts:           []string{dm.Name},
+			Hosts:           []string{dm.Host()},
 			SecretName:      dm.Spec.TLS.SecretName,
 			SecretNamespace: dm.Namespace,
 		}}, nil, nil
@@ -215,7 +233,7 @@ func (r *Reconciler) tls(ctx context.Context, dm *v1alpha1.DomainMapping) ([]net
 	}
 
 	acmeChallenges := []netv1alpha1.HTTP01Challenge{}
-	desiredCert := resources.MakeCertificate(dm, certClass(ctx))
+	desiredCert := resources.MakeCertificate(dm, certClass(ctx, dm))
 	cert, err := networkaccessor.ReconcileCertificate(ctx, dm, desiredCert, r)
 	if err != nil {
 		if kaccessor.IsNotOwned(err) {
@@ -227,7 +245,7 @@ func (r *Reconciler) tls(ctx context.Context, dm *v1alpha1.DomainMapping) ([]net
 	}
 
 	for _, dnsName := range desiredCert.Spec.DNSNames {
-		if dnsName == dm.Name {
+		if dnsName == dm.Host() {
 			dm.Status.URL.Scheme = "https"
 			break
 		}
@@ -323,7 +341,7 @@ func (r *Reconciler) resolveRef(ctx context.Context, dm *v1alpha1.DomainMapping)
 }
 
 func (r *Reconciler) reconcileDomainClaim(ctx context.Context, dm *v1alpha1.DomainMapping) error {
-	dc, err := r.domainClaimLister.Get(dm.Name)
+	dc, err := r.domainClaimLister.Get(dm.Domain())
 	if err != nil && !apierrs.IsNotFound(err) {
 		return fmt.Errorf("failed to get ClusterDomainClaim: %w", err)
 	} else if apierrs.IsNotFound(err) {
@@ -332,7 +350,7 @@ func (r *Reconciler) reconcileDomainClaim(ctx context.Context, dm *v1alpha1.Doma
 		}
 	} else if dm.Namespace != dc.Spec.Namespace {
 		dm.Status.MarkDomainClaimNotOwned()
-		return fmt.Errorf("namespace %q does not own ClusterDomainClaim for %q", dm.Namespace, dm.Name)
+		return fmt.Errorf("namespace %q does not own ClusterDomainClaim for %q", dm.Namespace, dm.Domain())
 	}
 
 	dm.Status.MarkDomainClaimed()
@@ -342,7 +360,7 @@ func (r *Reconciler) reconcileDomainClaim(ctx context.Context, dm *v1alpha1.Doma
 func (r *Reconciler) createDomainClaim(ctx context.Context, dm *v1alpha1.DomainMapping) error {
 	if !config.FromContext(ctx).Network.AutocreateClusterDomainClaims {
 		dm.Status.MarkDomainClaimNotOwned()
-		return fmt.Errorf("no ClusterDomainClaim found for domain %q", dm.Name)
+		return fmt.Errorf("no ClusterDomainClaim found for domain %q", dm.Domain())
 	}
 
 	_, err := r.netclient.NetworkingV1alpha1().ClusterDomainClaims().Create(ctx, resources.MakeDomainClaim(dm), metav1.CreateOptions{})
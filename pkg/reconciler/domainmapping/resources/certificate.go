@@ -28,9 +28,9 @@ import (
 //
 // This function delegates entirely to routeresources.MakeCertificate,
 // but we keep it here to hide the `certName`, and `dnsName` logic
-// from the caller.
+// from the caller. A wildcard DomainMapping gets a wildcard certificate.
 func MakeCertificate(dm *v1alpha1.DomainMapping, certClass string) *networkingv1alpha1.Certificate {
 	certName := kmeta.ChildName(dm.GetName(), "")
 	return routeresources.MakeCertificate(
-		dm, serving.DomainMappingUIDLabelKey, dm.Name, certName, certClass)
+		dm, serving.DomainMappingUIDLabelKey, dm.Host(), certName, certClass)
 }
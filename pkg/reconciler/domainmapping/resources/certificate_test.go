@@ -67,6 +67,37 @@ func TestMakeCertificate(t *testing.T) {
 				SecretName: "mapping.com",
 			},
 		},
+	}, {
+		name: "wildcard",
+		dm: v1alpha1.DomainMapping{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mapping.com",
+				Namespace: "the-namespace",
+			},
+			Spec: v1alpha1.DomainMappingSpec{
+				Ref: duckv1.KReference{
+					Namespace: "the-namespace",
+					Name:      "the-name",
+				},
+				Wildcard: true,
+			},
+		},
+		want: networkingv1alpha1.Certificate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "mapping.com",
+				Namespace:   "the-namespace",
+				Annotations: map[string]string{"networking.knative.dev/certificate.class": certClass},
+				Labels: map[string]string{
+					serving.DomainMappingUIDLabelKey: "mapping.com",
+				},
+			},
+			Spec: networkingv1alpha1.CertificateSpec{
+				DNSNames: []string{
+					"*.mapping.com",
+				},
+				SecretName: "mapping.com",
+			},
+		},
 	}, {
 		name: "filter last-applied",
 		dm: v1alpha1.DomainMapping{
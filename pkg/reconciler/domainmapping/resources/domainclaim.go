@@ -22,12 +22,12 @@ import (
 	"knative.dev/serving/pkg/apis/serving/v1alpha1"
 )
 
-// MakeDomainClaim creates a ClusterDomainClaim named after the given DomainMapping
-// and giving ownership of the domain name to the DomainMapping's namespace.
+// MakeDomainClaim creates a ClusterDomainClaim named after the domain of the given
+// DomainMapping and giving ownership of the domain name to the DomainMapping's namespace.
 func MakeDomainClaim(dm *v1alpha1.DomainMapping) *netv1alpha1.ClusterDomainClaim {
 	return &netv1alpha1.ClusterDomainClaim{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: dm.Name,
+			Name: dm.Domain(),
 		},
 		Spec: netv1alpha1.ClusterDomainClaimSpec{
 			Namespace: dm.Namespace,
@@ -46,3 +46,20 @@ func TestMakeDomainClaim(t *testing.T) {
 		t.Errorf("Unexpected DomainClaim (-want, +got):\n%s", cmp.Diff(want, got))
 	}
 }
+
+func TestMakeDomainClaimForHost(t *testing.T) {
+	got := MakeDomainClaim(&v1alpha1.DomainMapping{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "api",
+			Namespace: "the-namespace",
+		},
+		Spec: v1alpha1.DomainMappingSpec{
+			Host: "mapping.com",
+			Path: "/api",
+		},
+	})
+
+	if got, want := got.Name, "mapping.com"; got != want {
+		t.Errorf("DomainClaim name = %q, want: %q", got, want)
+	}
+}
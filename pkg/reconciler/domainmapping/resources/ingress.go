@@ -27,6 +27,7 @@ import (
 	"knative.dev/pkg/kmeta"
 	"knative.dev/serving/pkg/apis/serving"
 	servingv1alpha1 "knative.dev/serving/pkg/apis/serving/v1alpha1"
+	pkghttp "knative.dev/serving/pkg/http"
 	routeresources "knative.dev/serving/pkg/reconciler/route/resources"
 )
 
@@ -34,8 +35,24 @@ import (
 // always created in the same namespace as the DomainMapping, and the ingress
 // backend is always in the same namespace also (as this is required by
 // KIngress).  The created ingress will contain a RewriteHost rule to cause the
-// given hostName to be used as the host.
+// given hostName to be used as the host. A wildcard DomainMapping gets a
+// wildcard Ingress rule, whose requests don't have a single original host.
+// A DomainMapping with a path only routes the requests under its path, which
+// the Ingress can't rewrite, so they reach the target with their full path.
+// The CORS policy of the DomainMapping is passed on to the target in a
+// header, as is the original host.
 func MakeIngress(dm *servingv1alpha1.DomainMapping, backendServiceName, hostName, ingressClass string, httpOption netv1alpha1.HTTPOption, tls []netv1alpha1.IngressTLS, acmeChallenges ...netv1alpha1.HTTP01Challenge) *netv1alpha1.Ingress {
+	appendHeaders := make(map[string]string, 2)
+	if !dm.Spec.Wildcard {
+		appendHeaders[network.OriginalHostHeader] = dm.Domain()
+	}
+	if cors := pkghttp.CORSPolicyFromAnnotations(dm.GetAnnotations()); cors != nil {
+		// For the queue-proxy to enforce, if the ingress doesn't.
+		appendHeaders[pkghttp.DomainMappingCORSHeaderName] = cors.String()
+	}
+	if len(appendHeaders) == 0 {
+		appendHeaders = nil
+	}
 	return &netv1alpha1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      kmeta.ChildName(dm.GetName(), ""),
@@ -55,18 +72,17 @@ func MakeIngress(dm *servingv1alpha1.DomainMapping, backendServiceName, hostName
 			HTTPOption: httpOption,
 			TLS:        tls,
 			Rules: []netv1alpha1.IngressRule{{
-				Hosts:      []string{dm.Name},
+				Hosts:      []string{dm.Host()},
 				Visibility: netv1alpha1.IngressVisibilityExternalIP,
 				HTTP: &netv1alpha1.HTTPIngressRuleValue{
 					// The order of the paths is sensitive, always put tls challenge first
 					Paths: append(routeresources.MakeACMEIngressPaths(acmeChallenges, dm.GetName()),
 						[]netv1alpha1.HTTPIngressPath{{
+							Path:        dm.Spec.Path,
 							RewriteHost: hostName,
 							Splits: []netv1alpha1.IngressBackendSplit{{
-								Percent: 100,
-								AppendHeaders: map[string]string{
-									network.OriginalHostHeader: dm.Name,
-								},
+								Percent:       100,
+								AppendHeaders: appendHeaders,
 								IngressBackend: netv1alpha1.IngressBackend{
 									ServiceNamespace: dm.Namespace,
 									ServiceName:      backendServiceName,
@@ -122,6 +122,21 @@ func TestReconcile(t *testing.T) {
 		WantEvents: []string{
 			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", "Updated %q finalizers", "cleanup.on.aisle-three"),
 		},
+	}, {
+		Name: "finalize keeps claim of domain still mounted",
+		Key:  "default/api",
+		Objects: []runtime.Object{
+			domainMapping("default", "api", withRef("default", "target"), withHostPath("cleanup.on.aisle-three", "/api"),
+				withFinalizer, withDeletionTimestamp(&now)),
+			domainMapping("default", "web", withRef("default", "target"), withHostPath("cleanup.on.aisle-three", "/web")),
+			resources.MakeDomainClaim(domainMapping("default", "cleanup.on.aisle-three", withRef("default", "target"))),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchRemoveFinalizerAction("default", "api"),
+		},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", "Updated %q finalizers", "api"),
+		},
 	}, {
 		Name: "finalize does not clean up unowned claim",
 		Key:  "default/cleanup.on.aisle-three",
@@ -705,11 +720,12 @@ func TestReconcile(t *testing.T) {
 	table.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher) controller.Reconciler {
 		ctx = addressable.WithDuck(ctx)
 		r := &Reconciler{
-			certificateLister: listers.GetCertificateLister(),
-			ingressLister:     listers.GetIngressLister(),
-			netclient:         networkingclient.Get(ctx),
-			resolver:          resolver.NewURIResolverFromTracker(ctx, tracker.New(func(types.NamespacedName) {}, 0)),
-			domainClaimLister: listers.GetDomainClaimLister(),
+			certificateLister:   listers.GetCertificateLister(),
+			ingressLister:       listers.GetIngressLister(),
+			netclient:           networkingclient.Get(ctx),
+			resolver:            resolver.NewURIResolverFromTracker(ctx, tracker.New(func(types.NamespacedName) {}, 0)),
+			domainClaimLister:   listers.GetDomainClaimLister(),
+			domainMappingLister: listers.GetDomainMappingLister(),
 		}
 
 		cfg := &config.Config{
@@ -840,11 +856,12 @@ func TestReconcileAutocreateClaimsDisabled(t *testing.T) {
 	table.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher) controller.Reconciler {
 		ctx = addressable.WithDuck(ctx)
 		r := &Reconciler{
-			certificateLister: listers.GetCertificateLister(),
-			ingressLister:     listers.GetIngressLister(),
-			netclient:         networkingclient.Get(ctx),
-			resolver:          resolver.NewURIResolverFromTracker(ctx, tracker.New(func(types.NamespacedName) {}, 0)),
-			domainClaimLister: listers.GetDomainClaimLister(),
+			certificateLister:   listers.GetCertificateLister(),
+			ingressLister:       listers.GetIngressLister(),
+			netclient:           networkingclient.Get(ctx),
+			resolver:            resolver.NewURIResolverFromTracker(ctx, tracker.New(func(types.NamespacedName) {}, 0)),
+			domainClaimLister:   listers.GetDomainClaimLister(),
+			domainMappingLister: listers.GetDomainMappingLister(),
 		}
 
 		return domainmappingreconciler.NewReconciler(ctx, logging.FromContext(ctx),
@@ -1195,11 +1212,12 @@ func TestReconcileTLSEnabled(t *testing.T) {
 	table.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher) controller.Reconciler {
 		ctx = addressable.WithDuck(ctx)
 		r := &Reconciler{
-			certificateLister: listers.GetCertificateLister(),
-			ingressLister:     listers.GetIngressLister(),
-			domainClaimLister: listers.GetDomainClaimLister(),
-			netclient:         networkingclient.Get(ctx),
-			resolver:          resolver.NewURIResolverFromTracker(ctx, tracker.New(func(types.NamespacedName) {}, 0)),
+			certificateLister:   listers.GetCertificateLister(),
+			ingressLister:       listers.GetIngressLister(),
+			domainClaimLister:   listers.GetDomainClaimLister(),
+			domainMappingLister: listers.GetDomainMappingLister(),
+			netclient:           networkingclient.Get(ctx),
+			resolver:            resolver.NewURIResolverFromTracker(ctx, tracker.New(func(types.NamespacedName) {}, 0)),
 		}
 
 		return domainmappingreconciler.NewReconciler(ctx, logging.FromContext(ctx),
@@ -1262,11 +1280,12 @@ func TestReconcileTLSEnabledButDowngraded(t *testing.T) {
 	table.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher) controller.Reconciler {
 		ctx = addressable.WithDuck(ctx)
 		r := &Reconciler{
-			certificateLister: listers.GetCertificateLister(),
-			domainClaimLister: listers.GetDomainClaimLister(),
-			ingressLister:     listers.GetIngressLister(),
-			netclient:         networkingclient.Get(ctx),
-			resolver:          resolver.NewURIResolverFromTracker(ctx, tracker.New(func(types.NamespacedName) {}, 0)),
+			certificateLister:   listers.GetCertificateLister(),
+			domainClaimLister:   listers.GetDomainClaimLister(),
+			domainMappingLister: listers.GetDomainMappingLister(),
+			ingressLister:       listers.GetIngressLister(),
+			netclient:           networkingclient.Get(ctx),
+			resolver:            resolver.NewURIResolverFromTracker(ctx, tracker.New(func(types.NamespacedName) {}, 0)),
 		}
 
 		return domainmappingreconciler.NewReconciler(ctx, logging.FromContext(ctx),
@@ -1368,6 +1387,13 @@ func withTLSSecret(secretName string) domainMappingOption {
 	}
 }
 
+func withHostPath(host, path string) domainMappingOption {
+	return func(dm *v1alpha1.DomainMapping) {
+		dm.Spec.Host = host
+		dm.Spec.Path = path
+	}
+}
+
 func withCertificateNotRequired(dm *v1alpha1.DomainMapping) {
 	dm.Status.MarkCertificateNotRequired(v1alpha1.TLSCertificateProvidedExternally)
 }
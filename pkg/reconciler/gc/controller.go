@@ -19,7 +19,10 @@ package gc
 import (
 	"context"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
+	nsinformer "knative.dev/pkg/client/injection/kube/informers/core/v1/namespace"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
@@ -42,10 +45,12 @@ func NewController(
 	logger := logging.FromContext(ctx)
 	configurationInformer := configurationinformer.Get(ctx)
 	revisionInformer := revisioninformer.Get(ctx)
+	nsInformer := nsinformer.Get(ctx)
 
 	c := &reconciler{
-		client:         servingclient.Get(ctx),
-		revisionLister: revisionInformer.Lister(),
+		client:          servingclient.Get(ctx),
+		revisionLister:  revisionInformer.Lister(),
+		namespaceLister: nsInformer.Lister(),
 	}
 	return configreconciler.NewImpl(ctx, c, func(impl *controller.Impl) controller.Options {
 		// Since the gc controller came from the configuration controller, having event handlers
@@ -59,6 +64,15 @@ func NewController(
 			Handler:    controller.HandleAll(impl.EnqueueControllerOf),
 		})
 
+		// Recollect the configurations of a namespace when its GC overrides change.
+		nsInformer.Informer().AddEventHandler(controller.HandleAll(func(obj interface{}) {
+			if ns, ok := obj.(*corev1.Namespace); ok {
+				impl.FilteredGlobalResync(func(obj interface{}) bool {
+					return obj.(metav1.Object).GetNamespace() == ns.Name
+				}, configurationInformer.Informer())
+			}
+		}))
+
 		configsToResync := []interface{}{
 			&gcconfig.Config{},
 		}
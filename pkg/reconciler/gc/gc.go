@@ -19,7 +19,6 @@ package gc
 import (
 	"context"
 	"sort"
-	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -129,7 +128,7 @@ func isRevisionActive(rev *v1.Revision, config *v1.Configuration) bool {
 		return true // never delete latest ready, even if config is not active.
 	}
 
-	if strings.EqualFold(rev.Annotations[serving.RevisionPreservedAnnotationKey], "true") {
+	if rev.IsPreserved() {
 		return true
 	}
 	// Anything that the labeler hasn't explicitly labelled as inactive.
@@ -20,11 +20,15 @@ import (
 	"context"
 	"time"
 
+	"go.uber.org/zap"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"knative.dev/pkg/logging"
 	pkgreconciler "knative.dev/pkg/reconciler"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 	clientset "knative.dev/serving/pkg/client/clientset/versioned"
 	configreconciler "knative.dev/serving/pkg/client/injection/reconciler/serving/v1/configuration"
 	listers "knative.dev/serving/pkg/client/listers/serving/v1"
+	configns "knative.dev/serving/pkg/reconciler/gc/config"
 )
 
 // reconciler implements controller.Reconciler for garbage collected resources.
@@ -32,7 +36,8 @@ type reconciler struct {
 	client clientset.Interface
 
 	// listers index properties about resources
-	revisionLister listers.RevisionLister
+	revisionLister  listers.RevisionLister
+	namespaceLister corev1listers.NamespaceLister
 }
 
 // Check that our reconciler implements configreconciler.Interface
@@ -43,5 +48,21 @@ func (c *reconciler) ReconcileKind(ctx context.Context, config *v1.Configuration
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	ctx = c.withNamespaceOverrides(ctx, config.Namespace)
 	return collect(ctx, c.client, c.revisionLister, config)
 }
+
+// withNamespaceOverrides returns the context with the GC config overridden by
+// the annotations of the namespace.
+func (c *reconciler) withNamespaceOverrides(ctx context.Context, namespace string) context.Context {
+	ns, err := c.namespaceLister.Get(namespace)
+	if err != nil {
+		return ctx
+	}
+	cfg, err := configns.FromContext(ctx).RevisionGC.WithNamespaceOverrides(ns.Annotations)
+	if err != nil {
+		logging.FromContext(ctx).Warnw("Ignoring the invalid GC overrides of namespace "+namespace, zap.Error(err))
+		return ctx
+	}
+	return configns.ToContext(ctx, &configns.Config{RevisionGC: cfg})
+}
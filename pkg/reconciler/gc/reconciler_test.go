@@ -21,6 +21,7 @@ import (
 	"testing"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/clock"
@@ -94,12 +95,71 @@ func TestGCReconcile(t *testing.T) {
 			Name: "5554",
 		}},
 		Key: "foo/keep-two",
+	}, {
+		Name: "namespace overrides the min non-active revisions",
+		Objects: []runtime.Object{
+			&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "dev",
+					Annotations: map[string]string{
+						gc.NamespaceOverridePrefix + "min-non-active-revisions": "0",
+					},
+				},
+			},
+			cfg("keep-one", "dev", 5556,
+				WithLatestCreated("5556"),
+				WithLatestReady("5556"),
+				WithConfigObservedGen),
+			rev("keep-one", "dev", 5555, MarkRevisionReady,
+				WithRevName("5555"),
+				WithRoutingState(v1.RoutingStateReserve, fc),
+				WithRoutingStateModified(older)),
+			rev("keep-one", "dev", 5556, MarkRevisionReady,
+				WithRevName("5556"),
+				WithRoutingState(v1.RoutingStateActive, fc),
+				WithRoutingStateModified(old)),
+		},
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{
+				Namespace: "dev",
+				Verb:      "delete",
+				Resource:  v1.SchemeGroupVersion.WithResource("revisions"),
+			},
+			Name: "5555",
+		}},
+		Key: "dev/keep-one",
+	}, {
+		Name: "invalid namespace overrides are ignored",
+		Objects: []runtime.Object{
+			&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "dev",
+					Annotations: map[string]string{
+						gc.NamespaceOverridePrefix + "min-non-active-revisions": "-1",
+					},
+				},
+			},
+			cfg("keep-one", "dev", 5556,
+				WithLatestCreated("5556"),
+				WithLatestReady("5556"),
+				WithConfigObservedGen),
+			rev("keep-one", "dev", 5555, MarkRevisionReady,
+				WithRevName("5555"),
+				WithRoutingState(v1.RoutingStateReserve, fc),
+				WithRoutingStateModified(older)),
+			rev("keep-one", "dev", 5556, MarkRevisionReady,
+				WithRevName("5556"),
+				WithRoutingState(v1.RoutingStateActive, fc),
+				WithRoutingStateModified(old)),
+		},
+		Key: "dev/keep-one",
 	}}
 
 	table.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher) controller.Reconciler {
 		r := &reconciler{
-			client:         servingclient.Get(ctx),
-			revisionLister: listers.GetRevisionLister(),
+			client:          servingclient.Get(ctx),
+			revisionLister:  listers.GetRevisionLister(),
+			namespaceLister: listers.GetNamespaceLister(),
 		}
 		return configreconciler.NewReconciler(ctx, logging.FromContext(ctx),
 			servingclient.Get(ctx), listers.GetConfigurationLister(),
@@ -25,14 +25,14 @@ import (
 	"github.com/google/go-containerregistry/pkg/authn/k8schain"
 	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/util/workqueue"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	"knative.dev/serving/pkg/deployment"
 )
 
 // imageResolver is an interface used mostly to mock digestResolver for tests.
 type imageResolver interface {
-	Resolve(ctx context.Context, image string, opt k8schain.Options, registriesToSkip sets.String) (string, error)
+	Resolve(ctx context.Context, image string, opt k8schain.Options, cfg *deployment.Config) (string, error)
 }
 
 // backgroundResolver performs background downloads of image digests.
@@ -53,7 +53,7 @@ type backgroundResolver struct {
 type resolveResult struct {
 	// these fields are immutable afer creation, so can be accessed without a lock.
 	opt                k8schain.Options
-	registriesToSkip   sets.String
+	cfg                *deployment.Config
 	completionCallback func()
 	workItems          []workItem
 
@@ -144,7 +144,7 @@ func (r *backgroundResolver) Start(stop <-chan struct{}, maxInFlight int) (done
 // If this method returns `nil, nil` this implies a resolve was triggered or is
 // already in progress, so the reconciler should exit and wait for the revision
 // to be re-enqueued when the result is ready.
-func (r *backgroundResolver) Resolve(logger *zap.SugaredLogger, rev *v1.Revision, opt k8schain.Options, registriesToSkip sets.String, timeout time.Duration) ([]v1.ContainerStatus, error) {
+func (r *backgroundResolver) Resolve(logger *zap.SugaredLogger, rev *v1.Revision, opt k8schain.Options, cfg *deployment.Config) ([]v1.ContainerStatus, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -156,7 +156,7 @@ func (r *backgroundResolver) Resolve(logger *zap.SugaredLogger, rev *v1.Revision
 	result, inFlight := r.results[name]
 	if !inFlight {
 		logger.Debugf("Adding Resolve request to queue (depth: %d)", r.queue.Len())
-		r.addWorkItems(rev, name, opt, registriesToSkip, timeout)
+		r.addWorkItems(rev, name, opt, cfg)
 		return nil, nil
 	}
 
@@ -182,13 +182,13 @@ func (r *backgroundResolver) Resolve(logger *zap.SugaredLogger, rev *v1.Revision
 
 // addWorkItems adds a digest resolve item to the queue for each container in the revision.
 // This is expected to be called with the mutex locked.
-func (r *backgroundResolver) addWorkItems(rev *v1.Revision, name types.NamespacedName, opt k8schain.Options, registriesToSkip sets.String, timeout time.Duration) {
+func (r *backgroundResolver) addWorkItems(rev *v1.Revision, name types.NamespacedName, opt k8schain.Options, cfg *deployment.Config) {
 	r.results[name] = &resolveResult{
-		opt:              opt,
-		registriesToSkip: registriesToSkip,
-		statuses:         make(map[int]v1.ContainerStatus),
-		want:             len(rev.Spec.Containers),
-		workItems:        make([]workItem, len(rev.Spec.Containers)),
+		opt:       opt,
+		cfg:       cfg,
+		statuses:  make(map[int]v1.ContainerStatus),
+		want:      len(rev.Spec.Containers),
+		workItems: make([]workItem, len(rev.Spec.Containers)),
 		completionCallback: func() {
 			r.enqueue(name)
 		},
@@ -197,7 +197,7 @@ func (r *backgroundResolver) addWorkItems(rev *v1.Revision, name types.Namespace
 	for i, container := range rev.Spec.Containers {
 		item := workItem{
 			revision: name,
-			timeout:  timeout,
+			timeout:  digestResolutionTimeout(cfg, container.Image),
 			name:     container.Name,
 			image:    container.Image,
 			index:    i,
@@ -229,7 +229,7 @@ func (r *backgroundResolver) processWorkItem(item workItem) {
 	defer cancel()
 
 	r.logger.Debugf("Resolving image %q from revision %q to digest", item.image, item.revision)
-	resolvedDigest, resolveErr := r.resolver.Resolve(ctx, item.image, result.opt, result.registriesToSkip)
+	resolvedDigest, resolveErr := r.resolver.Resolve(ctx, item.image, result.opt, result.cfg)
 	r.logger.Debugf("Resolved image %q from revision %q to digest %q, %v", item.image, item.revision, resolvedDigest, resolveErr)
 
 	// lock after the resolve because we don't want to block parallel resolves,
@@ -36,6 +36,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/util/workqueue"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	"knative.dev/serving/pkg/deployment"
 )
 
 var (
@@ -52,7 +53,7 @@ func TestResolveInBackground(t *testing.T) {
 		wantError    error
 	}{{
 		name: "success",
-		resolver: func(_ context.Context, img string, _ k8schain.Options, _ sets.String) (string, error) {
+		resolver: func(_ context.Context, img string, _ k8schain.Options, _ *deployment.Config) (string, error) {
 			return img + "-digest", nil
 		},
 		wantStatuses: []v1.ContainerStatus{{
@@ -64,8 +65,8 @@ func TestResolveInBackground(t *testing.T) {
 		}},
 	}, {
 		name: "passing params",
-		resolver: func(_ context.Context, img string, opt k8schain.Options, skip sets.String) (string, error) {
-			return fmt.Sprintf("%s-%s-%s", img, opt.ServiceAccountName, skip.List()[0]), nil
+		resolver: func(_ context.Context, img string, opt k8schain.Options, cfg *deployment.Config) (string, error) {
+			return fmt.Sprintf("%s-%s-%s", img, opt.ServiceAccountName, cfg.RegistriesSkippingTagResolving.List()[0]), nil
 		},
 		wantStatuses: []v1.ContainerStatus{{
 			Name:        "first",
@@ -76,7 +77,7 @@ func TestResolveInBackground(t *testing.T) {
 		}},
 	}, {
 		name: "one slow resolve",
-		resolver: func(_ context.Context, img string, _ k8schain.Options, _ sets.String) (string, error) {
+		resolver: func(_ context.Context, img string, _ k8schain.Options, _ *deployment.Config) (string, error) {
 			if img == "first-image" {
 				// make the first resolve arrive after the second.
 				time.Sleep(50 * time.Millisecond)
@@ -92,13 +93,13 @@ func TestResolveInBackground(t *testing.T) {
 		}},
 	}, {
 		name: "resolver entirely fails",
-		resolver: func(_ context.Context, img string, _ k8schain.Options, _ sets.String) (string, error) {
+		resolver: func(_ context.Context, img string, _ k8schain.Options, _ *deployment.Config) (string, error) {
 			return img + "-digest", errDigest
 		},
 		wantError: errDigest,
 	}, {
 		name: "resolver fails one image",
-		resolver: func(_ context.Context, img string, _ k8schain.Options, _ sets.String) (string, error) {
+		resolver: func(_ context.Context, img string, _ k8schain.Options, _ *deployment.Config) (string, error) {
 			if img == "second-image" {
 				return "", errDigest
 			}
@@ -109,7 +110,7 @@ func TestResolveInBackground(t *testing.T) {
 	}, {
 		name:    "timeout",
 		timeout: ptr.Duration(10 * time.Millisecond),
-		resolver: func(ctx context.Context, img string, _ k8schain.Options, _ sets.String) (string, error) {
+		resolver: func(ctx context.Context, img string, _ k8schain.Options, _ *deployment.Config) (string, error) {
 			if img == "second-image" {
 				select {
 				case <-time.After(10 * time.Second):
@@ -149,7 +150,7 @@ func TestResolveInBackground(t *testing.T) {
 			for i := 0; i < 2; i++ {
 				t.Run(fmt.Sprint("iteration", i), func(t *testing.T) {
 					logger := logtesting.TestLogger(t)
-					statuses, err := subject.Resolve(logger, fakeRevision, k8schain.Options{ServiceAccountName: "san"}, sets.NewString("skip"), timeout)
+					statuses, err := subject.Resolve(logger, fakeRevision, k8schain.Options{ServiceAccountName: "san"}, &deployment.Config{RegistriesSkippingTagResolving: sets.NewString("skip"), DigestResolutionTimeout: timeout})
 					if err != nil || statuses != nil {
 						// Initial result should be nil, nil since we have nothing in cache.
 						t.Errorf("Resolve() = %v, %v, wanted nil, nil", statuses, err)
@@ -164,7 +165,7 @@ func TestResolveInBackground(t *testing.T) {
 						t.Fatalf("Resolver did not report ready")
 					}
 
-					statuses, err = subject.Resolve(logger, fakeRevision, k8schain.Options{}, nil, timeout)
+					statuses, err = subject.Resolve(logger, fakeRevision, k8schain.Options{}, nil)
 					if got, want := err, tt.wantError; !errors.Is(got, want) {
 						t.Errorf("Resolve() = _, %q, wanted %q", got, want)
 					}
@@ -185,7 +186,7 @@ func TestRateLimitGlobal(t *testing.T) {
 	logger := logtesting.TestLogger(t)
 
 	var resolves atomic.Int32
-	var resolver resolveFunc = func(_ context.Context, _ string, _ k8schain.Options, _ sets.String) (string, error) {
+	var resolver resolveFunc = func(_ context.Context, _ string, _ k8schain.Options, _ *deployment.Config) (string, error) {
 		resolves.Inc()
 		return "", errors.New("failed")
 	}
@@ -203,7 +204,7 @@ func TestRateLimitGlobal(t *testing.T) {
 
 	for i := 0; i < 5; i++ {
 		name := fmt.Sprint("rev", i)
-		subject.Resolve(logger, rev(name, name+"img1", name+"img2"), k8schain.Options{ServiceAccountName: "san"}, sets.NewString("skip"), 0)
+		subject.Resolve(logger, rev(name, name+"img1", name+"img2"), k8schain.Options{ServiceAccountName: "san"}, &deployment.Config{RegistriesSkippingTagResolving: sets.NewString("skip")})
 	}
 
 	// Rate limit base rate in this test is 1 second, so this should give time for at most 1 resolve.
@@ -217,7 +218,7 @@ func TestRateLimitGlobal(t *testing.T) {
 func TestRateLimitPerItem(t *testing.T) {
 	logger := logtesting.TestLogger(t)
 
-	var resolver resolveFunc = func(_ context.Context, img string, _ k8schain.Options, _ sets.String) (string, error) {
+	var resolver resolveFunc = func(_ context.Context, img string, _ k8schain.Options, _ *deployment.Config) (string, error) {
 		if img == "img1" {
 			return "", nil
 		}
@@ -245,14 +246,14 @@ func TestRateLimitPerItem(t *testing.T) {
 	for i := 0; i < 3; i++ {
 		subject.Clear(types.NamespacedName{Name: revision.Name, Namespace: revision.Namespace})
 		start := time.Now()
-		resolution, err := subject.Resolve(logger, revision, k8schain.Options{ServiceAccountName: "san"}, sets.NewString("skip"), 0)
+		resolution, err := subject.Resolve(logger, revision, k8schain.Options{ServiceAccountName: "san"}, &deployment.Config{RegistriesSkippingTagResolving: sets.NewString("skip")})
 		if err != nil || resolution != nil {
 			t.Fatalf("Expected Resolve to be nil, nil but got %v, %v", resolution, err)
 		}
 
 		<-enqueue
 
-		_, err = subject.Resolve(logger, revision, k8schain.Options{ServiceAccountName: "san"}, sets.NewString("skip"), 0)
+		_, err = subject.Resolve(logger, revision, k8schain.Options{ServiceAccountName: "san"}, &deployment.Config{RegistriesSkippingTagResolving: sets.NewString("skip")})
 		if err == nil {
 			t.Fatalf("Expected Resolve to fail")
 		}
@@ -266,7 +267,7 @@ func TestRateLimitPerItem(t *testing.T) {
 
 	t.Run("Does not affect other revisions", func(t *testing.T) {
 		start := time.Now()
-		resolution, err := subject.Resolve(logger, rev("another-revision", "img1", "img2"), k8schain.Options{ServiceAccountName: "san"}, sets.NewString("skip"), 0)
+		resolution, err := subject.Resolve(logger, rev("another-revision", "img1", "img2"), k8schain.Options{ServiceAccountName: "san"}, &deployment.Config{RegistriesSkippingTagResolving: sets.NewString("skip")})
 		if err != nil || resolution != nil {
 			t.Fatalf("Expected Resolve to be nil, nil but got %v, %v", resolution, err)
 		}
@@ -281,7 +282,7 @@ func TestRateLimitPerItem(t *testing.T) {
 		subject.Forget(types.NamespacedName{Name: revision.Name, Namespace: revision.Namespace})
 
 		start := time.Now()
-		resolution, err := subject.Resolve(logger, revision, k8schain.Options{ServiceAccountName: "san"}, sets.NewString("skip"), 0)
+		resolution, err := subject.Resolve(logger, revision, k8schain.Options{ServiceAccountName: "san"}, &deployment.Config{RegistriesSkippingTagResolving: sets.NewString("skip")})
 		if err != nil || resolution != nil {
 			t.Fatalf("Expected Resolve to be nil, nil but got %v, %v", resolution, err)
 		}
@@ -293,9 +294,9 @@ func TestRateLimitPerItem(t *testing.T) {
 	})
 }
 
-type resolveFunc func(context.Context, string, k8schain.Options, sets.String) (string, error)
+type resolveFunc func(context.Context, string, k8schain.Options, *deployment.Config) (string, error)
 
-func (r resolveFunc) Resolve(c context.Context, s string, o k8schain.Options, t sets.String) (string, error) {
+func (r resolveFunc) Resolve(c context.Context, s string, o k8schain.Options, t *deployment.Config) (string, error) {
 	return r(c, s, o, t)
 }
 
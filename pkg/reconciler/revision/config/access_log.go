@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	cm "knative.dev/pkg/configmap"
+	"knative.dev/serving/pkg/queue"
+)
+
+const (
+	// AccessLogFormatKey is the config-observability key of the format of the
+	// queue-proxy access log, which is disabled if empty.
+	AccessLogFormatKey = "logging.access-log-format"
+	// AccessLogTemplateKey is the config-observability key of the template of
+	// the access log entries, if the format is "template".
+	AccessLogTemplateKey = "logging.access-log-template"
+	// AccessLogDestinationKey is the config-observability key of where the
+	// access log is written to.
+	AccessLogDestinationKey = "logging.access-log-destination"
+)
+
+// AccessLog contains the access log settings of the queue-proxy, which are
+// part of config-observability but not known to knative.dev/pkg/metrics.
+type AccessLog struct {
+	Format      queue.AccessLogFormat
+	Template    string
+	Destination string
+}
+
+// DeepCopy returns a copy of the AccessLog.
+func (a *AccessLog) DeepCopy() *AccessLog {
+	c := *a
+	return &c
+}
+
+// NewAccessLogFromConfigMap creates an AccessLog from the config-observability
+// ConfigMap.
+func NewAccessLogFromConfigMap(configMap *corev1.ConfigMap) (*AccessLog, error) {
+	var format, template, destination string
+	if err := cm.Parse(configMap.Data,
+		cm.AsString(AccessLogFormatKey, &format),
+		cm.AsString(AccessLogTemplateKey, &template),
+		cm.AsString(AccessLogDestinationKey, &destination),
+	); err != nil {
+		return nil, fmt.Errorf("failed to parse data: %w", err)
+	}
+
+	f, err := queue.ParseAccessLogFormat(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", AccessLogFormatKey, err)
+	}
+	if f == queue.AccessLogFormatTemplate {
+		if template == "" {
+			return nil, fmt.Errorf("%s is required by the %q format", AccessLogTemplateKey, format)
+		}
+		if _, err := queue.ParseAccessLogTemplate(template); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", AccessLogTemplateKey, err)
+		}
+	}
+	if err := queue.ValidateAccessLogDestination(destination); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", AccessLogDestinationKey, err)
+	}
+
+	return &AccessLog{
+		Format:      f,
+		Template:    template,
+		Destination: destination,
+	}, nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/metrics"
+	"knative.dev/serving/pkg/queue"
+
+	. "knative.dev/pkg/configmap/testing"
+)
+
+func TestAccessLogFromFile(t *testing.T) {
+	cm, example := ConfigMapsFromTestFile(t, metrics.ConfigMapName())
+
+	if _, err := NewAccessLogFromConfigMap(cm); err != nil {
+		t.Error("NewAccessLogFromConfigMap(actual) =", err)
+	}
+
+	got, err := NewAccessLogFromConfigMap(example)
+	if err != nil {
+		t.Fatal("NewAccessLogFromConfigMap(example) =", err)
+	}
+	// The template is in the example yaml to show usage, while the access log
+	// is disabled by default.
+	got.Template = ""
+	if want := (&AccessLog{Destination: queue.AccessLogDestinationStdout}); !cmp.Equal(got, want) {
+		t.Error("Example stanza does not match default, diff(-want,+got):", cmp.Diff(want, got))
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    *AccessLog
+		wantErr bool
+	}{{
+		name: "disabled by default",
+		data: map[string]string{},
+		want: &AccessLog{},
+	}, {
+		name: "json to stdout",
+		data: map[string]string{
+			AccessLogFormatKey:      "json",
+			AccessLogDestinationKey: "stdout",
+		},
+		want: &AccessLog{
+			Format:      queue.AccessLogFormatJSON,
+			Destination: queue.AccessLogDestinationStdout,
+		},
+	}, {
+		name: "template to fluent",
+		data: map[string]string{
+			AccessLogFormatKey:      "template",
+			AccessLogTemplateKey:    "$status $latency",
+			AccessLogDestinationKey: "fluent:///var/run/fluent/fluent.sock",
+		},
+		want: &AccessLog{
+			Format:      queue.AccessLogFormatTemplate,
+			Template:    "$status $latency",
+			Destination: "fluent:///var/run/fluent/fluent.sock",
+		},
+	}, {
+		name:    "unknown format",
+		data:    map[string]string{AccessLogFormatKey: "xml"},
+		wantErr: true,
+	}, {
+		name:    "template format without template",
+		data:    map[string]string{AccessLogFormatKey: "template"},
+		wantErr: true,
+	}, {
+		name: "unknown template field",
+		data: map[string]string{
+			AccessLogFormatKey:   "template",
+			AccessLogTemplateKey: "$status $size",
+		},
+		wantErr: true,
+	}, {
+		name:    "unknown destination",
+		data:    map[string]string{AccessLogDestinationKey: "syslog"},
+		wantErr: true,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewAccessLogFromConfigMap(&corev1.ConfigMap{Data: tc.data})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("NewAccessLogFromConfigMap() = %v, wantErr: %v", err, tc.wantErr)
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Error("AccessLog differs (-want, +got):", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
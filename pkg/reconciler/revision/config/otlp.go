@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	cm "knative.dev/pkg/configmap"
+	"knative.dev/serving/pkg/queue"
+)
+
+const (
+	// OTLPEndpointKey is the config-observability key of the URL of the
+	// OpenTelemetry collector the queue-proxy exports its metrics to by OTLP,
+	// which is disabled if empty.
+	OTLPEndpointKey = "metrics.otlp-endpoint"
+	// OTLPProtocolKey is the config-observability key of the OTLP protocol,
+	// "grpc" or "http/protobuf".
+	OTLPProtocolKey = "metrics.otlp-protocol"
+	// OTLPExportIntervalKey is the config-observability key of the interval
+	// the metrics are exported at.
+	OTLPExportIntervalKey = "metrics.otlp-export-interval"
+
+	// OTLPLogsEndpointKey is the config-observability key of the URL of the
+	// OpenTelemetry collector the queue-proxy exports an event per request
+	// to as OTLP logs, which is disabled if empty.
+	OTLPLogsEndpointKey = "logging.otlp-endpoint"
+	// OTLPLogsProtocolKey is the config-observability key of the OTLP
+	// protocol of the request events.
+	OTLPLogsProtocolKey = "logging.otlp-protocol"
+	// OTLPLogsExportIntervalKey is the config-observability key of the
+	// interval the request events are exported at.
+	OTLPLogsExportIntervalKey = "logging.otlp-export-interval"
+	// OTLPLogsMaxEventsPerSecondKey is the config-observability key of the
+	// most request events a queue-proxy exports per second; the others are
+	// dropped.
+	OTLPLogsMaxEventsPerSecondKey = "logging.otlp-max-events-per-second"
+
+	defaultOTLPExportInterval         = time.Minute
+	defaultOTLPLogsExportInterval     = 5 * time.Second
+	defaultOTLPLogsMaxEventsPerSecond = 100
+)
+
+// OTLP contains the settings of the OTLP metrics and request event exporters
+// of the queue-proxy, which are part of config-observability but not known to
+// knative.dev/pkg/metrics.
+type OTLP struct {
+	Endpoint       string
+	Protocol       queue.OTLPProtocol
+	ExportInterval time.Duration
+
+	LogsEndpoint           string
+	LogsProtocol           queue.OTLPProtocol
+	LogsExportInterval     time.Duration
+	LogsMaxEventsPerSecond float64
+}
+
+// DeepCopy returns a copy of the OTLP.
+func (o *OTLP) DeepCopy() *OTLP {
+	c := *o
+	return &c
+}
+
+// NewOTLPFromConfigMap creates an OTLP from the config-observability
+// ConfigMap.
+func NewOTLPFromConfigMap(configMap *corev1.ConfigMap) (*OTLP, error) {
+	var protocol, logsProtocol string
+	o := &OTLP{
+		ExportInterval:         defaultOTLPExportInterval,
+		LogsExportInterval:     defaultOTLPLogsExportInterval,
+		LogsMaxEventsPerSecond: defaultOTLPLogsMaxEventsPerSecond,
+	}
+	if err := cm.Parse(configMap.Data,
+		cm.AsString(OTLPEndpointKey, &o.Endpoint),
+		cm.AsString(OTLPProtocolKey, &protocol),
+		cm.AsDuration(OTLPExportIntervalKey, &o.ExportInterval),
+		cm.AsString(OTLPLogsEndpointKey, &o.LogsEndpoint),
+		cm.AsString(OTLPLogsProtocolKey, &logsProtocol),
+		cm.AsDuration(OTLPLogsExportIntervalKey, &o.LogsExportInterval),
+		cm.AsFloat64(OTLPLogsMaxEventsPerSecondKey, &o.LogsMaxEventsPerSecond),
+	); err != nil {
+		return nil, fmt.Errorf("failed to parse data: %w", err)
+	}
+
+	if o.Endpoint != "" {
+		if err := queue.ValidateOTLPEndpoint(o.Endpoint); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", OTLPEndpointKey, err)
+		}
+	}
+	p, err := queue.ParseOTLPProtocol(protocol)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", OTLPProtocolKey, err)
+	}
+	o.Protocol = p
+	if o.ExportInterval <= 0 {
+		return nil, fmt.Errorf("%s = %v, must be positive", OTLPExportIntervalKey, o.ExportInterval)
+	}
+
+	if o.LogsEndpoint != "" {
+		if err := queue.ValidateOTLPEndpoint(o.LogsEndpoint); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", OTLPLogsEndpointKey, err)
+		}
+	}
+	if o.LogsProtocol, err = queue.ParseOTLPProtocol(logsProtocol); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", OTLPLogsProtocolKey, err)
+	}
+	if o.LogsExportInterval <= 0 {
+		return nil, fmt.Errorf("%s = %v, must be positive", OTLPLogsExportIntervalKey, o.LogsExportInterval)
+	}
+	if o.LogsMaxEventsPerSecond <= 0 {
+		return nil, fmt.Errorf("%s = %v, must be positive", OTLPLogsMaxEventsPerSecondKey, o.LogsMaxEventsPerSecond)
+	}
+	return o, nil
+}
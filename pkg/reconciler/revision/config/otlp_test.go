@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/metrics"
+	"knative.dev/serving/pkg/queue"
+
+	. "knative.dev/pkg/configmap/testing"
+)
+
+func TestOTLPFromFile(t *testing.T) {
+	cm, example := ConfigMapsFromTestFile(t, metrics.ConfigMapName())
+
+	if _, err := NewOTLPFromConfigMap(cm); err != nil {
+		t.Error("NewOTLPFromConfigMap(actual) =", err)
+	}
+
+	got, err := NewOTLPFromConfigMap(example)
+	if err != nil {
+		t.Fatal("NewOTLPFromConfigMap(example) =", err)
+	}
+	want, _ := NewOTLPFromConfigMap(&corev1.ConfigMap{})
+	if !cmp.Equal(got, want) {
+		t.Error("Example stanza does not match default, diff(-want,+got):", cmp.Diff(want, got))
+	}
+}
+
+func TestOTLP(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    *OTLP
+		wantErr bool
+	}{{
+		name: "disabled by default",
+		data: map[string]string{},
+		want: &OTLP{
+			Protocol:       queue.OTLPProtocolGRPC,
+			ExportInterval: time.Minute,
+
+			LogsProtocol:           queue.OTLPProtocolGRPC,
+			LogsExportInterval:     5 * time.Second,
+			LogsMaxEventsPerSecond: 100,
+		},
+	}, {
+		name: "http every 10 seconds",
+		data: map[string]string{
+			OTLPEndpointKey:       "https://collector.observability:4318",
+			OTLPProtocolKey:       "http/protobuf",
+			OTLPExportIntervalKey: "10s",
+		},
+		want: &OTLP{
+			Endpoint:       "https://collector.observability:4318",
+			Protocol:       queue.OTLPProtocolHTTP,
+			ExportInterval: 10 * time.Second,
+
+			LogsProtocol:           queue.OTLPProtocolGRPC,
+			LogsExportInterval:     5 * time.Second,
+			LogsMaxEventsPerSecond: 100,
+		},
+	}, {
+		name: "request events",
+		data: map[string]string{
+			OTLPLogsEndpointKey:           "http://collector.observability:4318",
+			OTLPLogsProtocolKey:           "http/protobuf",
+			OTLPLogsExportIntervalKey:     "1s",
+			OTLPLogsMaxEventsPerSecondKey: "20.5",
+		},
+		want: &OTLP{
+			Protocol:       queue.OTLPProtocolGRPC,
+			ExportInterval: time.Minute,
+
+			LogsEndpoint:           "http://collector.observability:4318",
+			LogsProtocol:           queue.OTLPProtocolHTTP,
+			LogsExportInterval:     time.Second,
+			LogsMaxEventsPerSecond: 20.5,
+		},
+	}, {
+		name:    "endpoint without scheme",
+		data:    map[string]string{OTLPEndpointKey: "collector.observability:4317"},
+		wantErr: true,
+	}, {
+		name:    "unknown protocol",
+		data:    map[string]string{OTLPProtocolKey: "http/json"},
+		wantErr: true,
+	}, {
+		name:    "zero interval",
+		data:    map[string]string{OTLPExportIntervalKey: "0s"},
+		wantErr: true,
+	}, {
+		name:    "request events endpoint without scheme",
+		data:    map[string]string{OTLPLogsEndpointKey: "collector.observability:4317"},
+		wantErr: true,
+	}, {
+		name:    "no request events",
+		data:    map[string]string{OTLPLogsMaxEventsPerSecondKey: "0"},
+		wantErr: true,
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewOTLPFromConfigMap(&corev1.ConfigMap{Data: tc.data})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("NewOTLPFromConfigMap() = %v, wantErr: %v", err, tc.wantErr)
+			}
+			if !cmp.Equal(got, tc.want) {
+				t.Error("OTLP differs (-want, +got):", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
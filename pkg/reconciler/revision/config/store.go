@@ -26,6 +26,8 @@ import (
 	pkgtracing "knative.dev/pkg/tracing/config"
 	apiconfig "knative.dev/serving/pkg/apis/config"
 	"knative.dev/serving/pkg/deployment"
+	pkghttp "knative.dev/serving/pkg/http"
+	"knative.dev/serving/pkg/networking"
 )
 
 type cfgKey struct{}
@@ -37,7 +39,13 @@ type Config struct {
 	Logging       *logging.Config
 	Network       *network.Config
 	Observability *metrics.ObservabilityConfig
+	AccessLog     *AccessLog
+	OTLP          *OTLP
+	RequestID     *pkghttp.RequestIDConfig
+	InternalTLS   *networking.InternalTLSConfig
 	Tracing       *pkgtracing.Config
+	// TracePropagation is parsed out of config-tracing, next to Tracing.
+	TracePropagation *pkghttp.TracePropagationConfig
 }
 
 // FromContext loads the configuration from the context.
@@ -54,6 +62,18 @@ func ToContext(ctx context.Context, c *Config) context.Context {
 type Store struct {
 	*configmap.UntypedStore
 	apiStore *apiconfig.Store
+	// accessLogStore and otlpStore parse the access log and the OTLP
+	// exporter settings out of config-observability, which the UntypedStore
+	// parses as a metrics.ObservabilityConfig.
+	accessLogStore *configmap.UntypedStore
+	otlpStore      *configmap.UntypedStore
+	// requestIDStore and internalTLSStore parse the request ID and the
+	// internal TLS settings out of config-network.
+	requestIDStore   *configmap.UntypedStore
+	internalTLSStore *configmap.UntypedStore
+	// tracePropagationStore parses the trace propagation settings out of
+	// config-tracing.
+	tracePropagationStore *configmap.UntypedStore
 }
 
 // NewStore creates a new store of Configs and optionally calls functions when ConfigMaps are updated for Revisions
@@ -72,6 +92,41 @@ func NewStore(logger configmap.Logger, onAfterStore ...func(name string, value i
 			onAfterStore...,
 		),
 		apiStore: apiconfig.NewStore(logger),
+		accessLogStore: configmap.NewUntypedStore(
+			"revision-access-log",
+			logger,
+			configmap.Constructors{
+				metrics.ConfigMapName(): NewAccessLogFromConfigMap,
+			},
+		),
+		otlpStore: configmap.NewUntypedStore(
+			"revision-otlp",
+			logger,
+			configmap.Constructors{
+				metrics.ConfigMapName(): NewOTLPFromConfigMap,
+			},
+		),
+		requestIDStore: configmap.NewUntypedStore(
+			"revision-request-id",
+			logger,
+			configmap.Constructors{
+				network.ConfigName: pkghttp.NewRequestIDConfigFromConfigMap,
+			},
+		),
+		internalTLSStore: configmap.NewUntypedStore(
+			"revision-internal-tls",
+			logger,
+			configmap.Constructors{
+				network.ConfigName: networking.NewInternalTLSConfigFromConfigMap,
+			},
+		),
+		tracePropagationStore: configmap.NewUntypedStore(
+			"revision-trace-propagation",
+			logger,
+			configmap.Constructors{
+				pkgtracing.ConfigName: pkghttp.NewTracePropagationConfigFromConfigMap,
+			},
+		),
 	}
 	return store
 }
@@ -82,6 +137,11 @@ func NewStore(logger configmap.Logger, onAfterStore ...func(name string, value i
 func (s *Store) WatchConfigs(cmw configmap.Watcher) {
 	s.UntypedStore.WatchConfigs(cmw)
 	s.apiStore.WatchConfigs(cmw)
+	s.accessLogStore.WatchConfigs(cmw)
+	s.otlpStore.WatchConfigs(cmw)
+	s.requestIDStore.WatchConfigs(cmw)
+	s.internalTLSStore.WatchConfigs(cmw)
+	s.tracePropagationStore.WatchConfigs(cmw)
 }
 
 // ToContext persists the config on the context.
@@ -107,9 +167,24 @@ func (s *Store) Load() *Config {
 	if obs, ok := s.UntypedLoad(metrics.ConfigMapName()).(*metrics.ObservabilityConfig); ok {
 		cfg.Observability = obs.DeepCopy()
 	}
+	if al, ok := s.accessLogStore.UntypedLoad(metrics.ConfigMapName()).(*AccessLog); ok {
+		cfg.AccessLog = al.DeepCopy()
+	}
+	if o, ok := s.otlpStore.UntypedLoad(metrics.ConfigMapName()).(*OTLP); ok {
+		cfg.OTLP = o.DeepCopy()
+	}
+	if rid, ok := s.requestIDStore.UntypedLoad(network.ConfigName).(*pkghttp.RequestIDConfig); ok {
+		cfg.RequestID = rid.DeepCopy()
+	}
+	if tls, ok := s.internalTLSStore.UntypedLoad(network.ConfigName).(*networking.InternalTLSConfig); ok {
+		cfg.InternalTLS = tls.DeepCopy()
+	}
 	if tr, ok := s.UntypedLoad(pkgtracing.ConfigName).(*pkgtracing.Config); ok {
 		cfg.Tracing = tr.DeepCopy()
 	}
+	if tp, ok := s.tracePropagationStore.UntypedLoad(pkgtracing.ConfigName).(*pkghttp.TracePropagationConfig); ok {
+		cfg.TracePropagation = tp.DeepCopy()
+	}
 
 	return cfg
 }
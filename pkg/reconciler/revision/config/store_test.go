@@ -33,6 +33,8 @@ import (
 	apiconfig "knative.dev/serving/pkg/apis/config"
 	autoscalerconfig "knative.dev/serving/pkg/autoscaler/config"
 	"knative.dev/serving/pkg/deployment"
+	pkghttp "knative.dev/serving/pkg/http"
+	"knative.dev/serving/pkg/networking"
 
 	. "knative.dev/pkg/configmap/testing"
 )
@@ -97,6 +99,34 @@ func TestStoreLoadWithContext(t *testing.T) {
 		}
 	})
 
+	t.Run("access log", func(t *testing.T) {
+		expected, _ := NewAccessLogFromConfigMap(observabilityConfig)
+		if diff := cmp.Diff(expected, config.AccessLog); diff != "" {
+			t.Error("Unexpected access log config (-want, +got):", diff)
+		}
+	})
+
+	t.Run("otlp", func(t *testing.T) {
+		expected, _ := NewOTLPFromConfigMap(observabilityConfig)
+		if diff := cmp.Diff(expected, config.OTLP); diff != "" {
+			t.Error("Unexpected OTLP config (-want, +got):", diff)
+		}
+	})
+
+	t.Run("request id", func(t *testing.T) {
+		expected, _ := pkghttp.NewRequestIDConfigFromConfigMap(networkConfig)
+		if diff := cmp.Diff(expected, config.RequestID); diff != "" {
+			t.Error("Unexpected request ID config (-want, +got):", diff)
+		}
+	})
+
+	t.Run("internal tls", func(t *testing.T) {
+		expected, _ := networking.NewInternalTLSConfigFromConfigMap(networkConfig)
+		if diff := cmp.Diff(expected, config.InternalTLS); diff != "" {
+			t.Error("Unexpected internal TLS config (-want, +got):", diff)
+		}
+	})
+
 	t.Run("logging", func(t *testing.T) {
 		expected, _ := logging.NewConfigFromConfigMap(loggingConfig)
 		if diff := cmp.Diff(expected, config.Logging); diff != "" {
@@ -131,6 +161,23 @@ func TestStoreLoadWithContext(t *testing.T) {
 		}
 	})
 
+	t.Run("trace propagation", func(t *testing.T) {
+		expected, _ := pkghttp.NewTracePropagationConfigFromConfigMap(tracingConfig)
+		if diff := cmp.Diff(expected, config.TracePropagation); diff != "" {
+			t.Error("Unexpected trace propagation config (-want, +got):", diff)
+		}
+
+		// The example matches the default.
+		want, _ := pkghttp.NewTracePropagationConfigFromConfigMap(&corev1.ConfigMap{Data: map[string]string{}})
+		got, err := pkghttp.NewTracePropagationConfigFromConfigMap(tracingConfigExample)
+		if err != nil {
+			t.Fatal("Error parsing example trace propagation config:", err)
+		}
+		if !cmp.Equal(got, want) {
+			t.Error("Example trace propagation config does not match the default, diff(-want,+got):\n", cmp.Diff(want, got))
+		}
+	})
+
 	t.Run("defaults", func(t *testing.T) {
 		expected, _ := apiconfig.NewDefaultsConfigFromConfigMap(defaultConfig)
 		if diff := cmp.Diff(expected, config.Defaults); diff != "" {
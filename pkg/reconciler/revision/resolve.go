@@ -24,18 +24,42 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/authn/k8schain"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
-	"k8s.io/apimachinery/pkg/util/sets"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+
+	"knative.dev/pkg/system"
+	"knative.dev/serving/pkg/deployment"
 )
 
 type digestResolver struct {
 	client    kubernetes.Interface
 	transport http.RoundTripper
 	userAgent string
+
+	// certPath is the path of the certs bundle trusted, along with the CA
+	// bundle of a registry, by the transports of the registries having one.
+	certPath string
+
+	mu sync.Mutex
+	// caTransports are the transports of the registries having a CA bundle,
+	// keyed by their CA bundle.
+	caTransports map[string]http.RoundTripper
+	// digests are the digests resolved for images, shared by all the revisions.
+	digests map[string]cachedDigest
+}
+
+// cachedDigest is a digest resolved for an image, reused until it expires.
+type cachedDigest struct {
+	digest  string
+	expires time.Time
 }
 
 const (
@@ -47,8 +71,9 @@ const (
 // newResolverTransport returns an http.Transport that appends the certs bundle
 // at path to the system cert pool.
 //
-// Use this with k8sCertPath to trust the same certs as the cluster.
-func newResolverTransport(path string, maxIdleConns, maxIdleConnsPerHost int) (*http.Transport, error) {
+// Use this with k8sCertPath to trust the same certs as the cluster. The
+// caBundles are appended to the pool as well.
+func newResolverTransport(path string, maxIdleConns, maxIdleConnsPerHost int, caBundles ...string) (*http.Transport, error) {
 	pool, err := x509.SystemCertPool()
 	if err != nil {
 		pool = x509.NewCertPool()
@@ -59,6 +84,11 @@ func newResolverTransport(path string, maxIdleConns, maxIdleConnsPerHost int) (*
 	} else if ok := pool.AppendCertsFromPEM(crt); !ok {
 		return nil, errors.New("failed to append k8s cert bundle to cert pool")
 	}
+	for _, bundle := range caBundles {
+		if ok := pool.AppendCertsFromPEM([]byte(bundle)); !ok {
+			return nil, errors.New("failed to append registry CA bundle to cert pool")
+		}
+	}
 
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	transport.MaxIdleConns = maxIdleConns
@@ -71,12 +101,13 @@ func newResolverTransport(path string, maxIdleConns, maxIdleConnsPerHost int) (*
 	return transport, nil
 }
 
-// Resolve resolves the image references that use tags to digests.
+// Resolve resolves the image references that use tags to digests, using the
+// configuration of the registry of the image in cfg.
 func (r *digestResolver) Resolve(
 	ctx context.Context,
 	image string,
 	opt k8schain.Options,
-	registriesToSkip sets.String) (string, error) {
+	cfg *deployment.Config) (string, error) {
 	kc, err := k8schain.New(ctx, r.client, opt)
 	if err != nil {
 		return "", fmt.Errorf("failed to initialize authentication: %w", err)
@@ -92,13 +123,101 @@ func (r *digestResolver) Resolve(
 		return "", fmt.Errorf("failed to parse image name %q into a tag: %w", image, err)
 	}
 
-	if registriesToSkip.Has(tag.Registry.RegistryStr()) {
+	registry := tag.Registry.RegistryStr()
+	if cfg.SkipsTagResolving(registry, tag.RepositoryStr()) {
 		return "", nil
 	}
 
-	desc, err := remote.Head(tag, remote.WithContext(ctx), remote.WithTransport(r.transport), remote.WithAuthFromKeychain(kc), remote.WithUserAgent(r.userAgent))
+	if digest, ok := r.cachedDigest(image); ok {
+		return digest, nil
+	}
+
+	rc := cfg.DigestResolutionRegistries[registry]
+	transport, err := r.registryTransport(rc)
+	if err != nil {
+		return "", fmt.Errorf("failed to set up the transport of registry %q: %w", registry, err)
+	}
+	if rc.PullSecret != "" {
+		secret, err := r.client.CoreV1().Secrets(system.Namespace()).Get(ctx, rc.PullSecret, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get the pull secret of registry %q: %w", registry, err)
+		}
+		rkc, err := k8schain.NewFromPullSecrets(ctx, []corev1.Secret{*secret})
+		if err != nil {
+			return "", fmt.Errorf("failed to initialize authentication of registry %q: %w", registry, err)
+		}
+		kc = authn.NewMultiKeychain(kc, rkc)
+	}
+
+	desc, err := remote.Head(tag, remote.WithContext(ctx), remote.WithTransport(transport), remote.WithAuthFromKeychain(kc), remote.WithUserAgent(r.userAgent))
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("%s@%s", tag.Repository.String(), desc.Digest), nil
+	digest := fmt.Sprintf("%s@%s", tag.Repository.String(), desc.Digest)
+	r.cacheDigest(image, digest, cfg.DigestCacheTTL)
+	return digest, nil
+}
+
+// digestResolutionTimeout returns the maximum time allowed for the digest
+// resolution of the image, given the timeout of its registry.
+func digestResolutionTimeout(cfg *deployment.Config, image string) time.Duration {
+	ref, err := name.ParseReference(image, name.WeakValidation)
+	if err != nil {
+		return cfg.DigestResolutionTimeout
+	}
+	return cfg.DigestResolutionTimeoutFor(ref.Context().RegistryStr())
+}
+
+// registryTransport returns the transport trusting the CA bundle of the
+// registry, if it has one.
+func (r *digestResolver) registryTransport(rc deployment.RegistryConfig) (http.RoundTripper, error) {
+	if rc.CABundle == "" {
+		return r.transport, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.caTransports[rc.CABundle]; ok {
+		return t, nil
+	}
+	t, err := newResolverTransport(r.certPath, digestResolutionWorkers, digestResolutionWorkers, rc.CABundle)
+	if err != nil {
+		return nil, err
+	}
+	if r.caTransports == nil {
+		r.caTransports = make(map[string]http.RoundTripper, 1)
+	}
+	r.caTransports[rc.CABundle] = t
+	return t, nil
+}
+
+// cachedDigest returns the digest resolved for the image, if it hasn't expired.
+func (r *digestResolver) cachedDigest(image string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cd, ok := r.digests[image]
+	if !ok || !time.Now().Before(cd.expires) {
+		return "", false
+	}
+	return cd.digest, true
+}
+
+// cacheDigest remembers the digest of the image for ttl, dropping the expired
+// digests. A ttl of zero disables the cache.
+func (r *digestResolver) cacheDigest(image, digest string, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for img, cd := range r.digests {
+		if !now.Before(cd.expires) {
+			delete(r.digests, img)
+		}
+	}
+	if ttl <= 0 {
+		return
+	}
+	if r.digests == nil {
+		r.digests = make(map[string]cachedDigest, 1)
+	}
+	r.digests[image] = cachedDigest{digest: digest, expires: now.Add(ttl)}
 }
@@ -42,9 +42,13 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	fakeclient "k8s.io/client-go/kubernetes/fake"
+	"knative.dev/pkg/system"
+	"knative.dev/serving/pkg/deployment"
+
+	_ "knative.dev/pkg/system/testing"
 )
 
-var emptyRegistrySet = sets.NewString()
+var emptyConfig = &deployment.Config{}
 
 func mustDigest(t *testing.T, img v1.Image) v1.Hash {
 	h, err := img.Digest()
@@ -203,7 +207,7 @@ func TestResolve(t *testing.T) {
 		Namespace:          ns,
 		ServiceAccountName: svcacct,
 	}
-	resolvedDigest, err := dr.Resolve(context.Background(), tag.String(), opt, emptyRegistrySet)
+	resolvedDigest, err := dr.Resolve(context.Background(), tag.String(), opt, emptyConfig)
 	if err != nil {
 		t.Fatal("Resolve() =", err)
 	}
@@ -235,7 +239,7 @@ func TestResolveWithDigest(t *testing.T) {
 		Namespace:          ns,
 		ServiceAccountName: svcacct,
 	}
-	resolvedDigest, err := dr.Resolve(context.Background(), originalDigest, opt, emptyRegistrySet)
+	resolvedDigest, err := dr.Resolve(context.Background(), originalDigest, opt, emptyConfig)
 	if err != nil {
 		t.Fatal("Resolve() =", err)
 	}
@@ -265,7 +269,7 @@ func TestResolveWithBadTag(t *testing.T) {
 
 	// Invalid character.
 	invalidImage := "ubuntu%latest"
-	if resolvedDigest, err := dr.Resolve(context.Background(), invalidImage, opt, emptyRegistrySet); err == nil {
+	if resolvedDigest, err := dr.Resolve(context.Background(), invalidImage, opt, emptyConfig); err == nil {
 		t.Fatalf("Resolve() succeeded with %q, want error", resolvedDigest)
 	}
 }
@@ -303,7 +307,7 @@ func TestResolveWithPingFailure(t *testing.T) {
 		Namespace:          ns,
 		ServiceAccountName: svcacct,
 	}
-	if resolvedDigest, err := dr.Resolve(context.Background(), tag.String(), opt, emptyRegistrySet); err == nil {
+	if resolvedDigest, err := dr.Resolve(context.Background(), tag.String(), opt, emptyConfig); err == nil {
 		t.Fatalf("Resolve() = %v, want error", resolvedDigest)
 	}
 }
@@ -343,7 +347,7 @@ func TestResolveWithManifestFailure(t *testing.T) {
 		Namespace:          ns,
 		ServiceAccountName: svcacct,
 	}
-	if resolvedDigest, err := dr.Resolve(context.Background(), tag.String(), opt, emptyRegistrySet); err == nil {
+	if resolvedDigest, err := dr.Resolve(context.Background(), tag.String(), opt, emptyConfig); err == nil {
 		t.Fatalf("Resolve() = %v, want error", resolvedDigest)
 	}
 }
@@ -360,7 +364,7 @@ func TestResolveNoAccess(t *testing.T) {
 		ServiceAccountName: svcacct,
 	}
 	// If there is a failure accessing the ServiceAccount for this Pod, then we should see an error.
-	if resolvedDigest, err := dr.Resolve(context.Background(), "ubuntu:latest", opt, emptyRegistrySet); err == nil {
+	if resolvedDigest, err := dr.Resolve(context.Background(), "ubuntu:latest", opt, emptyConfig); err == nil {
 		t.Fatalf("Resolve() = %v, want error", resolvedDigest)
 	}
 }
@@ -406,7 +410,7 @@ func TestResolveTimeout(t *testing.T) {
 		ServiceAccountName: svcacct,
 	}
 
-	_, err = dr.Resolve(ctx, tag.String(), opt, emptyRegistrySet)
+	_, err = dr.Resolve(ctx, tag.String(), opt, emptyConfig)
 	if !errors.Is(err, context.DeadlineExceeded) {
 		t.Fatal("Expected Resolve() to fail via timeout, but failed with", err)
 	}
@@ -448,20 +452,111 @@ func TestResolveSkippingRegistry(t *testing.T) {
 		transport: http.DefaultTransport,
 	}
 
-	registriesToSkip := sets.NewString("localhost:5000")
+	cfg := &deployment.Config{
+		RegistriesSkippingTagResolving: sets.NewString("localhost:5000"),
+		ImagesSkippingTagResolving:     sets.NewString("registry.internal/base/*"),
+	}
 
 	opt := k8schain.Options{
 		Namespace:          ns,
 		ServiceAccountName: svcacct,
 	}
 
-	resolvedDigest, err := dr.Resolve(context.Background(), "localhost:5000/ubuntu:latest", opt, registriesToSkip)
+	for _, image := range []string{"localhost:5000/ubuntu:latest", "registry.internal/base/ubuntu:latest"} {
+		resolvedDigest, err := dr.Resolve(context.Background(), image, opt, cfg)
+		if err != nil {
+			t.Fatal("Resolve() =", err)
+		}
+
+		if got, want := resolvedDigest, ""; got != want {
+			t.Fatalf("Resolve(%s) got %q want of %q", image, got, want)
+		}
+	}
+}
+
+func TestResolveRegistryConfig(t *testing.T) {
+	const (
+		ns       = "user-project"
+		username = "foo"
+		password = "bar"
+		sname    = "registry-creds"
+		repo     = "booger/nose"
+	)
+
+	img, err := random.Image(3, 1024)
 	if err != nil {
+		t.Fatal("random.Image() =", err)
+	}
+
+	server := fakeRegistry(t, repo, username, password, "", img)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal("url.Parse() =", err)
+	}
+	tag, err := name.NewTag(fmt.Sprintf("%s/%s:latest", u.Host, repo), name.WeakValidation)
+	if err != nil {
+		t.Fatal("NewTag() =", err)
+	}
+
+	// The credentials of the registry are only in the system namespace.
+	client := fakeclient.NewSimpleClientset(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "default",
+			Namespace: ns,
+		},
+	}, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sname,
+			Namespace: system.Namespace(),
+		},
+		Type: corev1.SecretTypeDockercfg,
+		Data: map[string][]byte{
+			corev1.DockerConfigKey: []byte(
+				fmt.Sprintf(`{%q: {"username": %q, "password": %q}}`,
+					tag.RegistryStr(), username, password),
+			),
+		},
+	})
+	cfg := &deployment.Config{
+		DigestResolutionRegistries: map[string]deployment.RegistryConfig{
+			tag.RegistryStr(): {PullSecret: sname},
+		},
+		DigestCacheTTL: time.Hour,
+	}
+
+	dr := &digestResolver{client: client, transport: http.DefaultTransport}
+	opt := k8schain.Options{Namespace: ns}
+	want := fmt.Sprintf("%s@%s", tag.Repository.String(), mustDigest(t, img))
+	if got, err := dr.Resolve(context.Background(), tag.String(), opt, cfg); err != nil {
 		t.Fatal("Resolve() =", err)
+	} else if got != want {
+		t.Fatalf("Resolve() = %s, want: %s", got, want)
 	}
 
-	if got, want := resolvedDigest, ""; got != want {
-		t.Fatalf("Resolve() got %q want of %q", got, want)
+	// The digest is served from the cache once the registry is gone.
+	server.Close()
+	if got, err := dr.Resolve(context.Background(), tag.String(), opt, cfg); err != nil {
+		t.Fatal("Resolve() with a cached digest =", err)
+	} else if got != want {
+		t.Fatalf("Resolve() with a cached digest = %s, want: %s", got, want)
+	}
+}
+
+func TestDigestResolutionTimeout(t *testing.T) {
+	cfg := &deployment.Config{
+		DigestResolutionTimeout: 10 * time.Second,
+		DigestResolutionRegistries: map[string]deployment.RegistryConfig{
+			"registry.flaky": {Timeout: time.Minute},
+		},
+	}
+	for image, want := range map[string]time.Duration{
+		"registry.flaky/foo/bar:latest": time.Minute,
+		"registry.other/foo/bar:latest": 10 * time.Second,
+		"ubuntu":                        10 * time.Second,
+	} {
+		if got := digestResolutionTimeout(cfg, image); got != want {
+			t.Errorf("digestResolutionTimeout(%s) = %v, want: %v", image, got, want)
+		}
 	}
 }
 
@@ -18,13 +18,18 @@ package resources
 
 import (
 	"fmt"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"time"
 
 	network "knative.dev/networking/pkg"
 	"knative.dev/pkg/kmeta"
 	"knative.dev/pkg/ptr"
 	"knative.dev/serving/pkg/apis/autoscaling"
+	"knative.dev/serving/pkg/apis/serving"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	"knative.dev/serving/pkg/deployment"
 	"knative.dev/serving/pkg/networking"
 	"knative.dev/serving/pkg/queue"
 	"knative.dev/serving/pkg/reconciler/revision/config"
@@ -34,6 +39,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 var (
@@ -50,6 +56,30 @@ var (
 		SubPathExpr: "$(K_INTERNAL_POD_NAMESPACE)_$(K_INTERNAL_POD_NAME)_",
 	}
 
+	userSocketVolume = corev1.Volume{
+		Name: "knative-user-socket",
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	}
+
+	userSocketVolumeMount = corev1.VolumeMount{
+		Name:      userSocketVolume.Name,
+		MountPath: "/var/run/knative",
+	}
+
+	asyncVolume = corev1.Volume{
+		Name: "knative-async",
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	}
+
+	asyncVolumeMount = corev1.VolumeMount{
+		Name:      asyncVolume.Name,
+		MountPath: asyncRequestsDir,
+	}
+
 	// This PreStop hook is actually calling an endpoint on the queue-proxy
 	// because of the way PreStop hooks are called by kubelet. We use this
 	// to block the user-container from exiting before the queue-proxy is ready
@@ -64,6 +94,58 @@ var (
 	}
 )
 
+// userSocketPath is the path of the Unix domain socket the serving container
+// listens on, if enabled by serving.QueueSideCarUnixSocketAnnotation.
+const userSocketPath = "/var/run/knative/user.sock"
+
+// userSocketEnabled returns whether the queue-proxy talks to the serving
+// container over a Unix domain socket.
+func userSocketEnabled(rev *v1.Revision) bool {
+	enabled, _ := strconv.ParseBool(rev.Annotations[serving.QueueSideCarUnixSocketAnnotation])
+	return enabled
+}
+
+// asyncRequestsDir is the directory the queue-proxy keeps the asynchronous
+// requests in, if enabled by serving.QueueSideCarAsyncAnnotation.
+const asyncRequestsDir = "/var/run/knative-async"
+
+// asyncEnabled returns whether the queue-proxy runs the requests preferring
+// to respond asynchronously in the background.
+func asyncEnabled(rev *v1.Revision) bool {
+	enabled, _ := strconv.ParseBool(rev.Annotations[serving.QueueSideCarAsyncAnnotation])
+	return enabled
+}
+
+// queueConfigVolumeName is the name of the volume of the ConfigMap named by
+// serving.QueueSideCarConfigMapAnnotation.
+const queueConfigVolumeName = "knative-queue-config"
+
+// queueConfigDir is the directory the queue-proxy reloads its settings from,
+// where the ConfigMap named by serving.QueueSideCarConfigMapAnnotation is
+// mounted.
+const queueConfigDir = "/var/run/knative-queue-config"
+
+// accessLogFluentVolumeName is the name of the volume of the directory of the
+// fluentd socket the queue-proxy sends the access log to.
+const accessLogFluentVolumeName = "knative-access-log-fluent"
+
+// servingCertsVolumeName is the name of the volume of the certificate the
+// queue-proxy serves the activator with mutual TLS with.
+const servingCertsVolumeName = "knative-serving-certs"
+
+// accessLogFluentSocketDir returns the directory of the socket of the fluentd
+// on the node the access log is sent to, if it's sent to one.
+func accessLogFluentSocketDir(cfg *config.Config) (string, bool) {
+	if cfg.AccessLog == nil || cfg.AccessLog.Format == queue.AccessLogFormatNone {
+		return "", false
+	}
+	path, ok := queue.FluentSocketPath(cfg.AccessLog.Destination)
+	if !ok {
+		return "", false
+	}
+	return filepath.Dir(path), true
+}
+
 func rewriteUserProbe(p *corev1.Probe, userPort int) {
 	if p == nil {
 		return
@@ -86,6 +168,16 @@ func rewriteUserProbe(p *corev1.Probe, userPort int) {
 	}
 }
 
+// progressDeadline returns the time the Revision is given to make progress,
+// which is extended to the startup window of its startupProbe, so that slow
+// booting containers aren't failed during a legitimate startup.
+func progressDeadline(rev *v1.Revision, cfg *config.Config) time.Duration {
+	if sw := rev.Spec.StartupWindow(); sw > cfg.Deployment.ProgressDeadline {
+		return sw
+	}
+	return cfg.Deployment.ProgressDeadline
+}
+
 func makePodSpec(rev *v1.Revision, cfg *config.Config) (*corev1.PodSpec, error) {
 	queueContainer, err := makeQueueContainer(rev, cfg)
 
@@ -94,6 +186,10 @@ func makePodSpec(rev *v1.Revision, cfg *config.Config) (*corev1.PodSpec, error)
 	}
 
 	podSpec := BuildPodSpec(rev, append(BuildUserContainers(rev), *queueContainer), cfg)
+	if inj := cfg.Deployment.Injection; inj != nil && inj.AppliesTo(rev.Namespace) {
+		inject(podSpec, inj)
+	}
+	orderContainers(rev, podSpec)
 
 	if cfg.Observability.EnableVarLogCollection {
 		podSpec.Volumes = append(podSpec.Volumes, varLogVolume)
@@ -112,9 +208,210 @@ func makePodSpec(rev *v1.Revision, cfg *config.Config) (*corev1.PodSpec, error)
 		}
 	}
 
+	if userSocketEnabled(rev) {
+		podSpec.Volumes = append(podSpec.Volumes, userSocketVolume)
+
+		servingContainerName := rev.Spec.GetContainer().Name
+		for i, container := range podSpec.Containers {
+			switch container.Name {
+			case servingContainerName:
+				container.Env = append(container.Env, corev1.EnvVar{
+					Name:  "K_UNIX_SOCKET",
+					Value: userSocketPath,
+				})
+			case QueueContainerName:
+			default:
+				continue
+			}
+			container.VolumeMounts = append(container.VolumeMounts, userSocketVolumeMount)
+			podSpec.Containers[i] = container
+		}
+	}
+
+	if asyncEnabled(rev) {
+		podSpec.Volumes = append(podSpec.Volumes, asyncVolume)
+		for i := range podSpec.Containers {
+			if podSpec.Containers[i].Name == QueueContainerName {
+				podSpec.Containers[i].VolumeMounts = append(podSpec.Containers[i].VolumeMounts, asyncVolumeMount)
+			}
+		}
+	}
+
+	if name := rev.Annotations[serving.QueueSideCarConfigMapAnnotation]; name != "" {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: queueConfigVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: name},
+					// The pods start before the ConfigMap is created.
+					Optional: ptr.Bool(true),
+				},
+			},
+		})
+		for i := range podSpec.Containers {
+			if podSpec.Containers[i].Name == QueueContainerName {
+				podSpec.Containers[i].VolumeMounts = append(podSpec.Containers[i].VolumeMounts, corev1.VolumeMount{
+					Name:      queueConfigVolumeName,
+					MountPath: queueConfigDir,
+					ReadOnly:  true,
+				})
+			}
+		}
+	}
+
+	if dir, ok := accessLogFluentSocketDir(cfg); ok {
+		hostPathType := corev1.HostPathDirectory
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: accessLogFluentVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: dir,
+					Type: &hostPathType,
+				},
+			},
+		})
+		for i := range podSpec.Containers {
+			if podSpec.Containers[i].Name == QueueContainerName {
+				podSpec.Containers[i].VolumeMounts = append(podSpec.Containers[i].VolumeMounts, corev1.VolumeMount{
+					Name:      accessLogFluentVolumeName,
+					MountPath: dir,
+				})
+			}
+		}
+	}
+
+	if queueTLSEnabled(cfg) {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: servingCertsVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: networking.ServingCertsSecretName(rev.Name),
+				},
+			},
+		})
+		for i := range podSpec.Containers {
+			if podSpec.Containers[i].Name == QueueContainerName {
+				podSpec.Containers[i].VolumeMounts = append(podSpec.Containers[i].VolumeMounts, corev1.VolumeMount{
+					Name:      servingCertsVolumeName,
+					MountPath: networking.ServingCertsMountPath,
+					ReadOnly:  true,
+				})
+			}
+		}
+	}
+
 	return podSpec, nil
 }
 
+// inject adds the containers and volumes of the injection to the pod, and
+// its env vars and volume mounts to the user containers. The injected
+// containers, env vars and volumes named like ones of the revision are
+// skipped, the revision taking precedence.
+func inject(pod *corev1.PodSpec, inj *deployment.Injection) {
+	containers := make(sets.String, len(pod.Containers))
+	for i := range pod.Containers {
+		c := &pod.Containers[i]
+		containers.Insert(c.Name)
+		if c.Name == QueueContainerName {
+			continue
+		}
+		env := make(sets.String, len(c.Env))
+		for _, e := range c.Env {
+			env.Insert(e.Name)
+		}
+		for _, e := range inj.Env {
+			if !env.Has(e.Name) {
+				c.Env = append(c.Env, *e.DeepCopy())
+			}
+		}
+		mounts := make(sets.String, len(c.VolumeMounts))
+		for _, m := range c.VolumeMounts {
+			mounts.Insert(m.MountPath)
+		}
+		for _, m := range inj.VolumeMounts {
+			if !mounts.Has(m.MountPath) {
+				c.VolumeMounts = append(c.VolumeMounts, *m.DeepCopy())
+			}
+		}
+	}
+	for _, c := range inj.Containers {
+		if !containers.Has(c.Name) {
+			pod.Containers = append(pod.Containers, *c.DeepCopy())
+		}
+	}
+	volumes := make(sets.String, len(pod.Volumes))
+	for _, v := range pod.Volumes {
+		volumes.Insert(v.Name)
+	}
+	for _, v := range inj.Volumes {
+		if !volumes.Has(v.Name) {
+			pod.Volumes = append(pod.Volumes, *v.DeepCopy())
+		}
+	}
+}
+
+// orderContainers applies the container startup and shutdown orders of the
+// revision to the pod. The containers are started in the order they are
+// listed in, after the queue-proxy, and the PostStart hook of each of them
+// holds the start of the next one until the queue-proxy probes it ready.
+// Likewise the PreStop hook of each container of the shutdown order holds
+// its stop until the previous one no longer responds. A hook failing as the
+// queue-proxy isn't listening yet restarts its container, like a failed probe.
+func orderContainers(rev *v1.Revision, pod *corev1.PodSpec) {
+	if startup := v1.ContainerOrder(rev.Annotations, serving.ContainerStartupOrderAnnotation); len(startup) != 0 {
+		rank := make(map[string]int, len(startup)+1)
+		rank[QueueContainerName] = 0
+		for i, name := range startup {
+			rank[name] = i + 1
+		}
+		sort.SliceStable(pod.Containers, func(i, j int) bool {
+			ri, ok := rank[pod.Containers[i].Name]
+			if !ok {
+				return false
+			}
+			rj, ok := rank[pod.Containers[j].Name]
+			return !ok || ri < rj
+		})
+		for _, name := range startup[:len(startup)-1] {
+			lifecycle := containerLifecycle(pod, name)
+			lifecycle.PostStart = &corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Port: intstr.FromInt(networking.QueueAdminPort),
+					Path: queue.WaitForStartupPath + name,
+				},
+			}
+		}
+	}
+
+	shutdown := v1.ContainerOrder(rev.Annotations, serving.ContainerShutdownOrderAnnotation)
+	for i := 1; i < len(shutdown); i++ {
+		lifecycle := containerLifecycle(pod, shutdown[i])
+		lifecycle.PreStop = &corev1.Handler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Port: intstr.FromInt(networking.QueueAdminPort),
+				Path: queue.WaitForExitPath + shutdown[i-1],
+			},
+		}
+	}
+}
+
+// containerLifecycle returns a copy of the lifecycle of the named container
+// of the pod to be modified, set on the container.
+func containerLifecycle(pod *corev1.PodSpec, name string) *corev1.Lifecycle {
+	for i := range pod.Containers {
+		if c := &pod.Containers[i]; c.Name == name {
+			if c.Lifecycle == nil {
+				c.Lifecycle = &corev1.Lifecycle{}
+			} else {
+				c.Lifecycle = c.Lifecycle.DeepCopy()
+			}
+			return c.Lifecycle
+		}
+	}
+	// Unreachable for validated revisions.
+	return &corev1.Lifecycle{}
+}
+
 // BuildUserContainers makes an array of containers from the Revision template.
 func BuildUserContainers(rev *v1.Revision) []corev1.Container {
 	containers := make([]corev1.Container, 0, len(rev.Spec.PodSpec.Containers))
@@ -123,7 +420,7 @@ func BuildUserContainers(rev *v1.Revision) []corev1.Container {
 		if len(rev.Spec.PodSpec.Containers[i].Ports) != 0 || len(rev.Spec.PodSpec.Containers) == 1 {
 			container = makeServingContainer(*rev.Spec.PodSpec.Containers[i].DeepCopy(), rev)
 		} else {
-			container = makeContainer(*rev.Spec.PodSpec.Containers[i].DeepCopy(), rev)
+			container = makeSidecarContainer(*rev.Spec.PodSpec.Containers[i].DeepCopy(), rev)
 		}
 		// The below logic is safe because the image digests in Status.ContainerStatus will have been resolved
 		// before this method is called. We check for an empty array here because the method can also be
@@ -169,6 +466,17 @@ func makeServingContainer(servingContainer corev1.Container, rev *v1.Revision) c
 	}
 	// If the client provides probes, we should fill in the port for them.
 	rewriteUserProbe(container.LivenessProbe, int(userPort))
+	rewriteUserProbe(container.StartupProbe, int(userPort))
+	return container
+}
+
+func makeSidecarContainer(sidecar corev1.Container, rev *v1.Revision) corev1.Container {
+	container := makeContainer(sidecar, rev)
+	if probedByQueue(rev, &container) {
+		// The ReadinessProbe is executed by the queue-proxy along with the one
+		// of the serving container instead of via kubelet.
+		container.ReadinessProbe = nil
+	}
 	return container
 }
 
@@ -178,9 +486,27 @@ func BuildPodSpec(rev *v1.Revision, containers []corev1.Container, cfg *config.C
 	pod := rev.Spec.PodSpec.DeepCopy()
 	pod.Containers = containers
 	pod.TerminationGracePeriodSeconds = rev.Spec.TimeoutSeconds
+	// Give the requests allowed to take longer by the timeout rules the time
+	// to finish as the pod is drained.
+	if v, ok := rev.Annotations[serving.QueueSideCarTimeoutRulesAnnotation]; ok {
+		if rules, err := queue.ParseTimeoutRules(v); err == nil {
+			if max := int64(rules.Max().Seconds()); pod.TerminationGracePeriodSeconds == nil || max > *pod.TerminationGracePeriodSeconds {
+				pod.TerminationGracePeriodSeconds = ptr.Int64(max)
+			}
+		}
+	}
 	if cfg != nil && pod.EnableServiceLinks == nil {
 		pod.EnableServiceLinks = cfg.Defaults.EnableServiceLinks
 	}
+	// The topology spread constraints without a selector spread the pods of
+	// the revision.
+	for i := range pod.TopologySpreadConstraints {
+		if pod.TopologySpreadConstraints[i].LabelSelector == nil {
+			pod.TopologySpreadConstraints[i].LabelSelector = &metav1.LabelSelector{
+				MatchLabels: map[string]string{serving.RevisionUID: string(rev.UID)},
+			}
+		}
+	}
 	return pod
 }
 
@@ -257,7 +583,7 @@ func MakeDeployment(rev *v1.Revision, cfg *config.Config) (*appsv1.Deployment, e
 		Spec: appsv1.DeploymentSpec{
 			Replicas:                ptr.Int32(replicaCount),
 			Selector:                makeSelector(rev),
-			ProgressDeadlineSeconds: ptr.Int32(int32(cfg.Deployment.ProgressDeadline.Seconds())),
+			ProgressDeadlineSeconds: ptr.Int32(int32(progressDeadline(rev, cfg).Seconds())),
 			Strategy: appsv1.DeploymentStrategy{
 				Type: appsv1.RollingUpdateDeploymentStrategyType,
 				RollingUpdate: &appsv1.RollingUpdateDeployment{
@@ -40,8 +40,10 @@ import (
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 	"knative.dev/serving/pkg/autoscaler/config/autoscalerconfig"
 	"knative.dev/serving/pkg/deployment"
+	pkghttp "knative.dev/serving/pkg/http"
 	"knative.dev/serving/pkg/networking"
 	"knative.dev/serving/pkg/queue"
+	"knative.dev/serving/pkg/reconciler/revision/config"
 
 	_ "knative.dev/pkg/metrics/testing"
 	. "knative.dev/serving/pkg/testing/v1"
@@ -478,7 +480,12 @@ func TestMakePodSpec(t *testing.T) {
 		name string
 		rev  *v1.Revision
 		oc   metrics.ObservabilityConfig
+		al   *config.AccessLog
+		otlp *config.OTLP
+		rid  *pkghttp.RequestIDConfig
+		itls *networking.InternalTLSConfig
 		dc   *apicfg.Defaults
+		inj  *deployment.Injection
 		want *corev1.PodSpec
 	}{{
 		name: "user-defined user port, queue proxy have PORT env",
@@ -562,6 +569,101 @@ func TestMakePodSpec(t *testing.T) {
 					},
 				},
 			})),
+	}, {
+		name: "persistent volume claims passed through",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:  servingContainerName,
+				Image: "busybox",
+				VolumeMounts: []corev1.VolumeMount{{
+					Name:      "weights",
+					MountPath: "/weights",
+					ReadOnly:  true,
+				}},
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+			}}),
+			func(revision *v1.Revision) {
+				revision.Spec.Volumes = []corev1.Volume{{
+					Name: "weights",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: "model-weights",
+							ReadOnly:  true,
+						},
+					},
+				}}
+			},
+		),
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(
+					withPrependedVolumeMounts(corev1.VolumeMount{
+						Name:      "weights",
+						MountPath: "/weights",
+						ReadOnly:  true,
+					}),
+				),
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+				),
+			}, withAppendedVolumes(corev1.Volume{
+				Name: "weights",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: "model-weights",
+						ReadOnly:  true,
+					},
+				},
+			})),
+	}, {
+		name: "topology spread constraints select the revision pods",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+			}}),
+			WithContainerStatuses([]v1.ContainerStatus{{
+				ImageDigest: "busybox@sha256:deadbeef",
+			}}),
+			func(revision *v1.Revision) {
+				revision.Spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{{
+					MaxSkew:           1,
+					TopologyKey:       "topology.kubernetes.io/zone",
+					WhenUnsatisfiable: corev1.ScheduleAnyway,
+				}, {
+					MaxSkew:           1,
+					TopologyKey:       "kubernetes.io/hostname",
+					WhenUnsatisfiable: corev1.DoNotSchedule,
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "foo"},
+					},
+				}}
+			},
+		),
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(func(container *corev1.Container) {
+					container.Image = "busybox@sha256:deadbeef"
+				}),
+				queueContainer(),
+			}, func(p *corev1.PodSpec) {
+				p.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{{
+					MaxSkew:           1,
+					TopologyKey:       "topology.kubernetes.io/zone",
+					WhenUnsatisfiable: corev1.ScheduleAnyway,
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{serving.RevisionUID: "1234"},
+					},
+				}, {
+					MaxSkew:           1,
+					TopologyKey:       "kubernetes.io/hostname",
+					WhenUnsatisfiable: corev1.DoNotSchedule,
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "foo"},
+					},
+				}}
+			}),
 	}, {
 		name: "explicit true service links",
 		rev: revision("bar", "foo",
@@ -1087,6 +1189,478 @@ func TestMakePodSpec(t *testing.T) {
 			},
 			withAppendedVolumes(varLogVolume),
 		),
+	}, {
+		name: "unix socket enabled",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+				Ports:          buildContainerPorts(v1.DefaultUserPort),
+			}, {
+				Name:  sidecarContainerName,
+				Image: "ubuntu",
+			}}),
+			func(r *v1.Revision) {
+				r.Annotations = map[string]string{serving.QueueSideCarUnixSocketAnnotation: "true"}
+			},
+		),
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(
+					withEnvVar("K_UNIX_SOCKET", "/var/run/knative/user.sock"),
+					func(container *corev1.Container) {
+						container.VolumeMounts = []corev1.VolumeMount{{
+							Name:      userSocketVolume.Name,
+							MountPath: "/var/run/knative",
+						}}
+					}),
+				sidecarContainer(sidecarContainerName),
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+					withEnvVar("USER_SOCKET", "/var/run/knative/user.sock"),
+					func(container *corev1.Container) {
+						container.VolumeMounts = []corev1.VolumeMount{{
+							Name:      userSocketVolume.Name,
+							MountPath: "/var/run/knative",
+						}}
+					}),
+			},
+			withAppendedVolumes(userSocketVolume),
+		),
+	}, {
+		name: "async requests enabled",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+				Ports:          buildContainerPorts(v1.DefaultUserPort),
+			}}),
+			func(r *v1.Revision) {
+				r.Annotations = map[string]string{serving.QueueSideCarAsyncAnnotation: "true"}
+			},
+		),
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(),
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+					withEnvVar("ASYNC_REQUESTS_DIR", "/var/run/knative-async"),
+					func(container *corev1.Container) {
+						container.VolumeMounts = []corev1.VolumeMount{{
+							Name:      asyncVolume.Name,
+							MountPath: "/var/run/knative-async",
+						}}
+					}),
+			},
+			withAppendedVolumes(asyncVolume),
+		),
+	}, {
+		name: "queue config map",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+				Ports:          buildContainerPorts(v1.DefaultUserPort),
+			}}),
+			func(r *v1.Revision) {
+				r.Annotations = map[string]string{serving.QueueSideCarConfigMapAnnotation: "queue-config"}
+			},
+		),
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(),
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+					withEnvVar("QUEUE_CONFIG_DIR", "/var/run/knative-queue-config"),
+					func(container *corev1.Container) {
+						container.VolumeMounts = []corev1.VolumeMount{{
+							Name:      "knative-queue-config",
+							MountPath: "/var/run/knative-queue-config",
+							ReadOnly:  true,
+						}}
+					}),
+			},
+			withAppendedVolumes(corev1.Volume{
+				Name: "knative-queue-config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "queue-config"},
+						Optional:             ptr.Bool(true),
+					},
+				},
+			}),
+		),
+	}, {
+		name: "sidecar readiness probes",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+				Ports:          buildContainerPorts(v1.DefaultUserPort),
+			}, {
+				Name:           sidecarContainerName,
+				Image:          "ubuntu",
+				ReadinessProbe: withTCPReadinessProbe(9090),
+			}, {
+				Name:           sidecarContainerName2,
+				Image:          "alpine",
+				ReadinessProbe: withExecReadinessProbe([]string{"ready"}),
+			}}),
+		),
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(),
+				sidecarContainer(sidecarContainerName),
+				sidecarContainer(sidecarContainerName2,
+					func(container *corev1.Container) {
+						container.Image = "alpine"
+						container.ReadinessProbe = withExecReadinessProbe([]string{"ready"})
+					}),
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+					func(container *corev1.Container) {
+						container.Env = append(container.Env, corev1.EnvVar{
+							Name:  "SERVING_SIDECAR_READINESS_PROBES",
+							Value: `{"` + sidecarContainerName + `":{"tcpSocket":{"port":9090,"host":"127.0.0.1"}}}`,
+						}, corev1.EnvVar{
+							Name:  "SERVING_CONTAINER_NAME",
+							Value: servingContainerName,
+						})
+					}),
+			}),
+	}, {
+		name: "container startup and shutdown order",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+				Ports:          buildContainerPorts(v1.DefaultUserPort),
+			}, {
+				Name:           sidecarContainerName,
+				Image:          "ubuntu",
+				ReadinessProbe: withTCPReadinessProbe(9090),
+			}}),
+			func(r *v1.Revision) {
+				r.Annotations = map[string]string{
+					serving.ContainerStartupOrderAnnotation:  sidecarContainerName + "," + servingContainerName,
+					serving.ContainerShutdownOrderAnnotation: servingContainerName + "," + sidecarContainerName,
+				}
+			},
+		),
+		want: podSpec(
+			[]corev1.Container{
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+					func(container *corev1.Container) {
+						container.Env = append(container.Env, corev1.EnvVar{
+							Name:  "SERVING_SIDECAR_READINESS_PROBES",
+							Value: `{"` + sidecarContainerName + `":{"tcpSocket":{"port":9090,"host":"127.0.0.1"}}}`,
+						}, corev1.EnvVar{
+							Name:  "SERVING_CONTAINER_NAME",
+							Value: servingContainerName,
+						})
+					}),
+				sidecarContainer(sidecarContainerName,
+					func(container *corev1.Container) {
+						container.Lifecycle = &corev1.Lifecycle{
+							PostStart: &corev1.Handler{
+								HTTPGet: &corev1.HTTPGetAction{
+									Port: intstr.FromInt(networking.QueueAdminPort),
+									Path: queue.WaitForStartupPath + sidecarContainerName,
+								},
+							},
+							PreStop: &corev1.Handler{
+								HTTPGet: &corev1.HTTPGetAction{
+									Port: intstr.FromInt(networking.QueueAdminPort),
+									Path: queue.WaitForExitPath + servingContainerName,
+								},
+							},
+						}
+					}),
+				servingContainer(),
+			}),
+	}, {
+		name: "injection",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+				Env: []corev1.EnvVar{{
+					Name:  "AGENT_ADDR",
+					Value: "user-value",
+				}},
+			}}),
+		),
+		inj: &deployment.Injection{
+			Namespaces: []string{"f*"},
+			Containers: []corev1.Container{{
+				Name:  "agent",
+				Image: "agent-image",
+			}},
+			Env: []corev1.EnvVar{{
+				Name:  "AGENT_ADDR",
+				Value: "unix:///var/run/agent/agent.sock",
+			}, {
+				Name:  "AGENT_ENABLED",
+				Value: "true",
+			}},
+			VolumeMounts: []corev1.VolumeMount{{
+				Name:      "agent",
+				MountPath: "/var/run/agent",
+			}},
+			Volumes: []corev1.Volume{{
+				Name: "agent",
+				VolumeSource: corev1.VolumeSource{
+					EmptyDir: &corev1.EmptyDirVolumeSource{},
+				},
+			}},
+		},
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(func(container *corev1.Container) {
+					container.Env = append([]corev1.EnvVar{{
+						Name:  "AGENT_ADDR",
+						Value: "user-value",
+					}}, container.Env...)
+					container.Env = append(container.Env, corev1.EnvVar{
+						Name:  "AGENT_ENABLED",
+						Value: "true",
+					})
+					container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+						Name:      "agent",
+						MountPath: "/var/run/agent",
+					})
+				}),
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+				),
+				{
+					Name:  "agent",
+					Image: "agent-image",
+				},
+			},
+			withAppendedVolumes(corev1.Volume{
+				Name: "agent",
+				VolumeSource: corev1.VolumeSource{
+					EmptyDir: &corev1.EmptyDirVolumeSource{},
+				},
+			}),
+		),
+	}, {
+		name: "injection into other namespaces",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+			}}),
+		),
+		inj: &deployment.Injection{
+			Namespaces: []string{"bar"},
+			Containers: []corev1.Container{{
+				Name:  "agent",
+				Image: "agent-image",
+			}},
+		},
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(),
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+				),
+			}),
+	}, {
+		name: "access log to fluent",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+			}}),
+		),
+		al: &config.AccessLog{
+			Format:      queue.AccessLogFormatJSON,
+			Destination: "fluent:///var/run/fluent/fluent.sock",
+		},
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(),
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+					withEnvVar("SERVING_ACCESS_LOG_FORMAT", "json"),
+					withEnvVar("SERVING_ACCESS_LOG_TEMPLATE", ""),
+					withEnvVar("SERVING_ACCESS_LOG_DESTINATION", "fluent:///var/run/fluent/fluent.sock"),
+					func(container *corev1.Container) {
+						container.VolumeMounts = []corev1.VolumeMount{{
+							Name:      accessLogFluentVolumeName,
+							MountPath: "/var/run/fluent",
+						}}
+					}),
+			},
+			withAppendedVolumes(corev1.Volume{
+				Name: accessLogFluentVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{
+						Path: "/var/run/fluent",
+						Type: hostPathTypePtr(corev1.HostPathDirectory),
+					},
+				},
+			}),
+		),
+	}, {
+		name: "otlp metrics exporter",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+			}}),
+		),
+		otlp: &config.OTLP{
+			Endpoint:       "http://collector.observability:4317",
+			Protocol:       queue.OTLPProtocolGRPC,
+			ExportInterval: 30 * time.Second,
+		},
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(),
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+					withEnvVar("SERVING_OTLP_ENDPOINT", "http://collector.observability:4317"),
+					withEnvVar("SERVING_OTLP_PROTOCOL", "grpc"),
+					withEnvVar("SERVING_OTLP_EXPORT_INTERVAL", "30s"),
+				),
+			},
+		),
+	}, {
+		name: "request ids",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+			}}),
+		),
+		rid: &pkghttp.RequestIDConfig{
+			Header:    "X-Correlation-Id",
+			Generator: pkghttp.RequestIDGeneratorTraceID,
+		},
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(),
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+					withEnvVar("REQUEST_ID_HEADER", "X-Correlation-Id"),
+					withEnvVar("REQUEST_ID_GENERATOR", "trace-id"),
+				),
+			},
+		),
+	}, {
+		name: "activator mtls",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+			}}),
+		),
+		itls: &networking.InternalTLSConfig{ActivatorMTLS: true},
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(),
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+					withEnvVar("ACTIVATOR_MTLS_CERT_DIR", "/var/lib/knative/serving-certs"),
+					withEnvVar("ACTIVATOR_CERTIFICATE_SAN", "activator-service."+system.Namespace()+".svc"),
+					func(container *corev1.Container) {
+						container.Ports = append(container.Ports, corev1.ContainerPort{
+							Name:          "queue-https",
+							ContainerPort: 8112,
+						})
+						container.VolumeMounts = []corev1.VolumeMount{{
+							Name:      servingCertsVolumeName,
+							MountPath: "/var/lib/knative/serving-certs",
+							ReadOnly:  true,
+						}}
+					}),
+			},
+			withAppendedVolumes(corev1.Volume{
+				Name: servingCertsVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName: "bar-serving-certs",
+					},
+				},
+			}),
+		),
+	}, {
+		name: "internal encryption",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+			}}),
+		),
+		itls: &networking.InternalTLSConfig{ActivatorMTLS: true, InternalEncryption: true},
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(),
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+					withEnvVar("ACTIVATOR_MTLS_CERT_DIR", "/var/lib/knative/serving-certs"),
+					withEnvVar("INTERNAL_ENCRYPTION", "true"),
+					func(container *corev1.Container) {
+						container.Ports = append(container.Ports, corev1.ContainerPort{
+							Name:          "queue-https",
+							ContainerPort: 8112,
+						})
+						container.VolumeMounts = []corev1.VolumeMount{{
+							Name:      servingCertsVolumeName,
+							MountPath: "/var/lib/knative/serving-certs",
+							ReadOnly:  true,
+						}}
+					}),
+			},
+			withAppendedVolumes(corev1.Volume{
+				Name: servingCertsVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{
+						SecretName: "bar-serving-certs",
+					},
+				},
+			}),
+		),
+	}, {
+		name: "timeout rules beyond the revision timeout",
+		rev: revision("bar", "foo",
+			withContainers([]corev1.Container{{
+				Name:           servingContainerName,
+				Image:          "busybox",
+				ReadinessProbe: withTCPReadinessProbe(v1.DefaultUserPort),
+			}}),
+			func(r *v1.Revision) {
+				r.Annotations = map[string]string{serving.QueueSideCarTimeoutRulesAnnotation: `{"/batch": 600, "/quick": 5}`}
+			},
+		),
+		want: podSpec(
+			[]corev1.Container{
+				servingContainer(),
+				queueContainer(
+					withEnvVar("SERVING_READINESS_PROBE", `{"tcpSocket":{"port":8080,"host":"127.0.0.1"}}`),
+					withEnvVar("TIMEOUT_RULES", `{"/batch": 600, "/quick": 5}`),
+				),
+			},
+			func(p *corev1.PodSpec) {
+				p.TerminationGracePeriodSeconds = refInt64(600)
+			},
+		),
 	}, {
 		name: "with no readiness probe",
 		rev: revision("bar", "foo",
@@ -1114,9 +1688,14 @@ func TestMakePodSpec(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			cfg := revConfig()
 			cfg.Observability = &test.oc
+			cfg.AccessLog = test.al
+			cfg.OTLP = test.otlp
+			cfg.RequestID = test.rid
+			cfg.InternalTLS = test.itls
 			if test.dc != nil {
 				cfg.Defaults = test.dc
 			}
+			cfg.Deployment.Injection = test.inj
 			got, err := makePodSpec(test.rev, cfg)
 			if err != nil {
 				t.Fatal("makePodSpec returned error:", err)
@@ -1128,10 +1707,46 @@ func TestMakePodSpec(t *testing.T) {
 	}
 }
 
+func hostPathTypePtr(t corev1.HostPathType) *corev1.HostPathType {
+	return &t
+}
+
 var quantityComparer = cmp.Comparer(func(x, y resource.Quantity) bool {
 	return x.Cmp(y) == 0
 })
 
+func TestProgressDeadline(t *testing.T) {
+	cfg := &config.Config{Deployment: &deployment.Config{ProgressDeadline: 60 * time.Second}}
+	tests := []struct {
+		name  string
+		probe *corev1.Probe
+		want  time.Duration
+	}{{
+		name: "no startup probe",
+		want: 60 * time.Second,
+	}, {
+		name:  "startup window within the deadline",
+		probe: &corev1.Probe{PeriodSeconds: 10, FailureThreshold: 3},
+		want:  60 * time.Second,
+	}, {
+		name:  "startup window beyond the deadline",
+		probe: &corev1.Probe{PeriodSeconds: 10, FailureThreshold: 30},
+		want:  300 * time.Second,
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rev := revision("bar", "foo", withContainers([]corev1.Container{{
+				Name:         servingContainerName,
+				Image:        "ubuntu",
+				StartupProbe: tc.probe,
+			}}))
+			if got := progressDeadline(rev, cfg); got != tc.want {
+				t.Errorf("progressDeadline() = %v, want: %v", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestMakeDeployment(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -65,6 +65,8 @@ func MakePA(rev *v1.Revision) *autoscalingv1alpha1.PodAutoscaler {
 				}
 				return autoscalingv1alpha1.ReachabilityUnreachable
 			}(),
+			Hibernated:           rev.Spec.Hibernated,
+			StartupWindowSeconds: int64(rev.Spec.StartupWindow().Seconds()),
 		},
 	}
 }
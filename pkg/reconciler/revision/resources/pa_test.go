@@ -89,6 +89,55 @@ func TestMakePA(t *testing.T) {
 				Reachability: autoscalingv1alpha1.ReachabilityReachable,
 			},
 		},
+	}, {
+		name: "hibernated",
+		rev: func() *v1.Revision {
+			rev := v1.Revision{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "bar",
+					UID:       "1234",
+					Labels: map[string]string{
+						serving.RoutingStateLabelKey: "active",
+					},
+				},
+				Spec: v1.RevisionSpec{
+					Hibernated: true,
+				},
+			}
+			rev.Status.MarkActiveTrue()
+			return &rev
+		}(),
+		want: &autoscalingv1alpha1.PodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "foo",
+				Name:      "bar",
+				Labels: map[string]string{
+					serving.RevisionLabelKey: "bar",
+					serving.RevisionUID:      "1234",
+					AppLabelKey:              "bar",
+				},
+				Annotations: map[string]string{},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion:         v1.SchemeGroupVersion.String(),
+					Kind:               "Revision",
+					Name:               "bar",
+					UID:                "1234",
+					Controller:         ptr.Bool(true),
+					BlockOwnerDeletion: ptr.Bool(true),
+				}},
+			},
+			Spec: autoscalingv1alpha1.PodAutoscalerSpec{
+				ScaleTargetRef: corev1.ObjectReference{
+					APIVersion: "apps/v1",
+					Kind:       "Deployment",
+					Name:       "bar-deployment",
+				},
+				ProtocolType: networking.ProtocolHTTP1,
+				Reachability: autoscalingv1alpha1.ReachabilityReachable,
+				Hibernated:   true,
+			},
+		},
 	}, {
 		name: "name is baz (Concurrency=0, Reachable=false)",
 		rev: func() *v1.Revision {
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -29,13 +30,16 @@ import (
 	network "knative.dev/networking/pkg"
 	pkgnet "knative.dev/networking/pkg/apis/networking"
 	"knative.dev/pkg/metrics"
+	pkgnetwork "knative.dev/pkg/network"
 	"knative.dev/pkg/profiling"
 	"knative.dev/pkg/ptr"
 	"knative.dev/pkg/system"
+	"knative.dev/serving/pkg/apis/autoscaling"
 	apicfg "knative.dev/serving/pkg/apis/config"
 	"knative.dev/serving/pkg/apis/serving"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 	"knative.dev/serving/pkg/deployment"
+	pkghttp "knative.dev/serving/pkg/http"
 	"knative.dev/serving/pkg/networking"
 	"knative.dev/serving/pkg/queue"
 	"knative.dev/serving/pkg/queue/readiness"
@@ -57,6 +61,10 @@ var (
 		Name:          requestQueueHTTPPortName,
 		ContainerPort: networking.BackendHTTP2Port,
 	}
+	queueHTTPSPort = corev1.ContainerPort{
+		Name:          networking.BackendHTTPSPortName,
+		ContainerPort: networking.BackendHTTPSPort,
+	}
 	queueNonServingPorts = []corev1.ContainerPort{{
 		// Provides health checks and lifecycle hooks.
 		Name:          v1.QueueAdminPortName,
@@ -245,7 +253,7 @@ func makeQueueContainer(rev *v1.Revision, cfg *config.Config) (*corev1.Container
 		// variable for this probe to use.
 		userProbe := container.ReadinessProbe.DeepCopy()
 		applyReadinessProbeDefaultsForExec(userProbe, userPort)
-		execProbe = makeStartupExecProbe(userProbe, cfg.Deployment.ProgressDeadline)
+		execProbe = makeStartupExecProbe(userProbe, progressDeadline(rev, cfg))
 
 		var err error
 		userProbeJSON, err = readiness.EncodeProbe(userProbe)
@@ -345,7 +353,7 @@ func makeQueueContainer(rev *v1.Revision, cfg *config.Config) (*corev1.Container
 			Value: strconv.FormatBool(cfg.Tracing.Debug),
 		}, {
 			Name:  "TRACING_CONFIG_SAMPLE_RATE",
-			Value: fmt.Sprint(cfg.Tracing.SampleRate),
+			Value: traceSampleRate(rev, cfg),
 		}, {
 			Name:  "USER_PORT",
 			Value: strconv.Itoa(int(userPort)),
@@ -376,9 +384,409 @@ func makeQueueContainer(rev *v1.Revision, cfg *config.Config) (*corev1.Container
 		}},
 	}
 
+	if al := cfg.AccessLog; al != nil && al.Format != queue.AccessLogFormatNone {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "SERVING_ACCESS_LOG_FORMAT",
+			Value: string(al.Format),
+		}, corev1.EnvVar{
+			Name:  "SERVING_ACCESS_LOG_TEMPLATE",
+			Value: al.Template,
+		}, corev1.EnvVar{
+			Name:  "SERVING_ACCESS_LOG_DESTINATION",
+			Value: al.Destination,
+		})
+	}
+
+	if o := cfg.OTLP; o != nil && o.Endpoint != "" {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "SERVING_OTLP_ENDPOINT",
+			Value: o.Endpoint,
+		}, corev1.EnvVar{
+			Name:  "SERVING_OTLP_PROTOCOL",
+			Value: string(o.Protocol),
+		}, corev1.EnvVar{
+			Name:  "SERVING_OTLP_EXPORT_INTERVAL",
+			Value: o.ExportInterval.String(),
+		})
+	}
+	if o := cfg.OTLP; o != nil && o.LogsEndpoint != "" {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "SERVING_OTLP_LOGS_ENDPOINT",
+			Value: o.LogsEndpoint,
+		}, corev1.EnvVar{
+			Name:  "SERVING_OTLP_LOGS_PROTOCOL",
+			Value: string(o.LogsProtocol),
+		}, corev1.EnvVar{
+			Name:  "SERVING_OTLP_LOGS_EXPORT_INTERVAL",
+			Value: o.LogsExportInterval.String(),
+		}, corev1.EnvVar{
+			Name:  "SERVING_OTLP_LOGS_MAX_EVENTS_PER_SECOND",
+			Value: strconv.FormatFloat(o.LogsMaxEventsPerSecond, 'f', -1, 64),
+		})
+	}
+
+	if rid := cfg.RequestID; rid != nil {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "REQUEST_ID_HEADER",
+			Value: rid.Header,
+		}, corev1.EnvVar{
+			Name:  "REQUEST_ID_GENERATOR",
+			Value: string(rid.Generator),
+		})
+	}
+
+	if rate, ok := rev.Annotations[serving.QueueSideCarMaxRequestRateAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "REQUEST_RATE_LIMIT",
+			Value: rate,
+		})
+		if burst, ok := rev.Annotations[serving.QueueSideCarRequestRateBurstAnnotation]; ok {
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  "REQUEST_RATE_BURST",
+				Value: burst,
+			})
+		}
+	}
+
+	if rate, ok := rev.Annotations[serving.QueueSideCarMaxClientRequestRateAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "CLIENT_REQUEST_RATE_LIMIT",
+			Value: rate,
+		})
+		if burst, ok := rev.Annotations[serving.QueueSideCarClientRequestRateBurstAnnotation]; ok {
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  "CLIENT_REQUEST_RATE_BURST",
+				Value: burst,
+			})
+		}
+		if key, ok := rev.Annotations[serving.QueueSideCarClientRateLimitKeyAnnotation]; ok {
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  "CLIENT_RATE_LIMIT_KEY",
+				Value: key,
+			})
+		}
+	}
+
+	if tp := cfg.TracePropagation; tp != nil && tp.Propagation != pkghttp.TracePropagationTraceContextB3 {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "TRACING_CONFIG_PROPAGATION",
+			Value: string(tp.Propagation),
+		})
+	}
+
+	if limit, ok := rev.Annotations[serving.QueueSideCarMaxRequestBodyBytesAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "MAX_REQUEST_BODY_BYTES",
+			Value: limit,
+		})
+	}
+
+	if limit, ok := rev.Annotations[serving.QueueSideCarMaxUpstreamConnectionsAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "MAX_UPSTREAM_CONNECTIONS",
+			Value: limit,
+		})
+	}
+
+	if limit, ok := rev.Annotations[serving.QueueSideCarMaxResponseBytesPerSecondAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "MAX_RESPONSE_BYTES_PER_SECOND",
+			Value: limit,
+		})
+	}
+
+	if rev.GetProtocol() == pkgnet.ProtocolH2C {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "USER_PROTOCOL",
+			Value: string(pkgnet.ProtocolH2C),
+		})
+	}
+
+	if userSocketEnabled(rev) {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "USER_SOCKET",
+			Value: userSocketPath,
+		})
+	}
+
+	if asyncEnabled(rev) {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "ASYNC_REQUESTS_DIR",
+			Value: asyncRequestsDir,
+		})
+		if hosts := cfg.Deployment.AsyncCallbackAllowedHosts; hosts.Len() > 0 {
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  "ASYNC_CALLBACK_ALLOWED_HOSTS",
+				Value: strings.Join(hosts.List(), ","),
+			})
+		}
+	}
+
+	if rev.Annotations[serving.QueueSideCarConfigMapAnnotation] != "" {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "QUEUE_CONFIG_DIR",
+			Value: queueConfigDir,
+		})
+	}
+
+	if probes := sidecarReadinessProbes(rev); len(probes) > 0 {
+		probesJSON, err := readiness.EncodeProbes(probes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize sidecar readiness probes: %w", err)
+		}
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "SERVING_SIDECAR_READINESS_PROBES",
+			Value: probesJSON,
+		}, corev1.EnvVar{
+			Name:  "SERVING_CONTAINER_NAME",
+			Value: container.Name,
+		})
+	}
+
+	if service, ok := rev.Annotations[serving.QueueSideCarReadinessProbeGRPCServiceAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "SERVING_READINESS_PROBE_GRPC",
+			Value: "true",
+		}, corev1.EnvVar{
+			Name:  "SERVING_READINESS_PROBE_GRPC_SERVICE",
+			Value: service,
+		})
+	}
+
+	if rev.Annotations[serving.QueueSideCarReadinessModeAnnotation] == "passive" {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "SERVING_READINESS_PROBE_PASSIVE",
+			Value: "true",
+		})
+	}
+
+	if hook, ok := rev.Annotations[serving.QueueSideCarWarmUpAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "WARM_UP",
+			Value: hook,
+		})
+	}
+
+	if mode, ok := rev.Annotations[serving.QueueSideCarStreamModeAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "STREAM_MODE",
+			Value: mode,
+		})
+	}
+	for _, drain := range []struct{ annotation, env string }{
+		{serving.QueueSideCarStreamDrainSettleTimeAnnotation, "STREAM_DRAIN_SETTLE_TIME"},
+		{serving.QueueSideCarStreamDrainGoAwayAnnotation, "STREAM_DRAIN_GO_AWAY"},
+		{serving.QueueSideCarDrainSignalAnnotation, "DRAIN_SIGNAL"},
+	} {
+		if v, ok := rev.Annotations[drain.annotation]; ok {
+			c.Env = append(c.Env, corev1.EnvVar{Name: drain.env, Value: v})
+		}
+	}
+
+	if rules, ok := rev.Annotations[serving.QueueSideCarTimeoutRulesAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "TIMEOUT_RULES",
+			Value: rules,
+		})
+	}
+
+	if header, ok := rev.Annotations[serving.QueueSideCarRequestPriorityHeaderAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "REQUEST_PRIORITY_HEADER",
+			Value: header,
+		})
+	}
+	if rules, ok := rev.Annotations[serving.QueueSideCarRequestPriorityRulesAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "REQUEST_PRIORITY_RULES",
+			Value: rules,
+		})
+	}
+
+	if header, ok := rev.Annotations[serving.QueueSideCarRequestWeightHeaderAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "REQUEST_WEIGHT_HEADER",
+			Value: header,
+		})
+	}
+	if rules, ok := rev.Annotations[serving.QueueSideCarRequestWeightRulesAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "REQUEST_WEIGHT_RULES",
+			Value: rules,
+		})
+	}
+
+	if rules, ok := rev.Annotations[serving.QueueSideCarResponseHeadersAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "RESPONSE_HEADER_RULES",
+			Value: rules,
+		})
+	}
+
+	if latency, ok := rev.Annotations[serving.QueueSideCarAdaptiveConcurrencyTargetLatencyAnnotation]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "ADAPTIVE_CONCURRENCY_TARGET_LATENCY",
+			Value: latency,
+		})
+	}
+
+	for _, retry := range []struct{ annotation, env string }{
+		{serving.QueueSideCarRetryAttemptsAnnotation, "RETRY_ATTEMPTS"},
+		{serving.QueueSideCarRetryMethodsAnnotation, "RETRY_METHODS"},
+		{serving.QueueSideCarRetryStatusCodesAnnotation, "RETRY_STATUS_CODES"},
+		{serving.QueueSideCarRetryPerTryTimeoutAnnotation, "RETRY_PER_TRY_TIMEOUT"},
+		{serving.QueueSideCarRetryBudgetAnnotation, "RETRY_BUDGET"},
+	} {
+		if v, ok := rev.Annotations[retry.annotation]; ok {
+			c.Env = append(c.Env, corev1.EnvVar{Name: retry.env, Value: v})
+		}
+	}
+
+	if delay, ok := rev.Annotations[autoscaling.ConcurrencyStatePauseDelayAnnotationKey]; ok {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "CONCURRENCY_STATE_PAUSE_DELAY",
+			Value: delay,
+		})
+	}
+
+	if cfg.Deployment.ConcurrencyStateFailFast {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "CONCURRENCY_STATE_FAIL_FAST",
+			Value: "true",
+		}, corev1.EnvVar{
+			// The UID of the pod the event of a failed resume is recorded about.
+			Name: "SERVING_POD_UID",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: "metadata.uid",
+				},
+			},
+		})
+	}
+
+	if cfg.Deployment.ConcurrencyStateProbeInterposition {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "CONCURRENCY_STATE_PROBE_INTERPOSITION",
+			Value: "true",
+		})
+	}
+
+	if cfg.Deployment.QueueSidecarDebug {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "ENABLE_DEBUG_SERVER",
+			Value: "true",
+		})
+	}
+
+	if cfg.Deployment.QueueSidecarAutoGomaxprocs {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "AUTO_GOMAXPROCS",
+			Value: "true",
+		})
+	}
+
+	if cfg.Deployment.QueueSidecarPushStats {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "STAT_PUSH_ENDPOINT",
+			Value: autoscalerStatEndpoint(),
+		})
+	}
+
+	if queueTLSEnabled(cfg) {
+		c.Ports = append(c.Ports, queueHTTPSPort)
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:  "ACTIVATOR_MTLS_CERT_DIR",
+			Value: networking.ServingCertsMountPath,
+		})
+		if cfg.InternalTLS.QueueMutualTLS() {
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  "ACTIVATOR_CERTIFICATE_SAN",
+				Value: networking.ActivatorCertificateSAN(system.Namespace()),
+			})
+		}
+		if cfg.InternalTLS.InternalEncryption {
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  "INTERNAL_ENCRYPTION",
+				Value: "true",
+			})
+		}
+	}
+
 	return c, nil
 }
 
+// autoscalerStatEndpoint returns the address the queue-proxy pushes its stats
+// to, if enabled.
+func autoscalerStatEndpoint() string {
+	return fmt.Sprintf("%s:%d", pkgnetwork.GetServiceHostname("autoscaler", system.Namespace()),
+		networking.AutoscalerStatGRPCPort)
+}
+
+// queueTLSEnabled returns whether the queue-proxy serves TLS, to the
+// activator with mutual TLS or to the ingress too.
+func queueTLSEnabled(cfg *config.Config) bool {
+	return cfg.InternalTLS != nil && cfg.InternalTLS.QueueTLS()
+}
+
+// traceSampleRate returns the sample rate of the traces of the revision, the
+// one of config-tracing unless it's annotated with its own.
+func traceSampleRate(rev *v1.Revision, cfg *config.Config) string {
+	if rate, ok := rev.Annotations[serving.TracingSampleRateAnnotation]; ok {
+		return rate
+	}
+	return fmt.Sprint(cfg.Tracing.SampleRate)
+}
+
+// probedByQueue returns whether the readiness probe of the sidecar container is
+// executed by the queue-proxy rather than the kubelet, so the queue-proxy only
+// reports ready once all of the containers are. That's the case for HTTP and
+// TCP probes of a port number, if the serving container has a readiness probe
+// for the queue-proxy to execute too.
+func probedByQueue(rev *v1.Revision, sidecar *corev1.Container) bool {
+	p := sidecar.ReadinessProbe
+	if p == nil || rev.Spec.GetContainer().ReadinessProbe == nil {
+		return false
+	}
+	var port intstr.IntOrString
+	switch {
+	case p.HTTPGet != nil:
+		port = p.HTTPGet.Port
+	case p.TCPSocket != nil:
+		port = p.TCPSocket.Port
+	default:
+		return false
+	}
+	return port.Type == intstr.Int && port.IntVal > 0
+}
+
+// sidecarReadinessProbes returns the readiness probes of the sidecar
+// containers executed by the queue-proxy, by container name.
+func sidecarReadinessProbes(rev *v1.Revision) map[string]*corev1.Probe {
+	servingContainer := rev.Spec.GetContainer()
+	var probes map[string]*corev1.Probe
+	for i := range rev.Spec.Containers {
+		sidecar := &rev.Spec.Containers[i]
+		if sidecar.Name == servingContainer.Name || !probedByQueue(rev, sidecar) {
+			continue
+		}
+		p := sidecar.ReadinessProbe.DeepCopy()
+		var port int32
+		if p.HTTPGet != nil {
+			port = p.HTTPGet.Port.IntVal
+		} else {
+			port = p.TCPSocket.Port.IntVal
+		}
+		// The probes are executed against the sidecars over localhost, as the
+		// containers share the network of the pod.
+		applyReadinessProbeDefaultsForExec(p, port)
+		if probes == nil {
+			probes = make(map[string]*corev1.Probe)
+		}
+		probes[sidecar.Name] = p
+	}
+	return probes
+}
+
 func applyReadinessProbeDefaultsForExec(p *corev1.Probe, port int32) {
 	switch {
 	case p == nil:
@@ -31,6 +31,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	network "knative.dev/networking/pkg"
 	"knative.dev/networking/pkg/apis/networking"
@@ -40,11 +41,13 @@ import (
 	"knative.dev/pkg/ptr"
 	"knative.dev/pkg/system"
 	tracingconfig "knative.dev/pkg/tracing/config"
+	"knative.dev/serving/pkg/apis/autoscaling"
 	apicfg "knative.dev/serving/pkg/apis/config"
 	"knative.dev/serving/pkg/apis/serving"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 	"knative.dev/serving/pkg/autoscaler/config/autoscalerconfig"
 	"knative.dev/serving/pkg/deployment"
+	pkghttp "knative.dev/serving/pkg/http"
 	"knative.dev/serving/pkg/queue"
 	"knative.dev/serving/pkg/reconciler/revision/config"
 
@@ -90,6 +93,8 @@ func TestMakeQueueContainer(t *testing.T) {
 		oc   metrics.ObservabilityConfig
 		dc   deployment.Config
 		fc   apicfg.Features
+		tp   *pkghttp.TracePropagationConfig
+		otlp *config.OTLP
 		want corev1.Container
 	}{{
 		name: "autoscaler single",
@@ -119,9 +124,12 @@ func TestMakeQueueContainer(t *testing.T) {
 			c.Image = "alpine"
 			c.Ports = append(queueNonServingPorts, queueHTTP2Port)
 			c.ReadinessProbe.Handler.HTTPGet.Port.IntVal = queueHTTP2Port.ContainerPort
-			c.Env = env(map[string]string{
+			c.Env = append(env(map[string]string{
 				"USER_PORT":          "1955",
 				"QUEUE_SERVING_PORT": "8013",
+			}), corev1.EnvVar{
+				Name:  "USER_PROTOCOL",
+				Value: string(networking.ProtocolH2C),
 			})
 		}),
 	}, {
@@ -325,6 +333,444 @@ func TestMakeQueueContainer(t *testing.T) {
 				"CONCURRENCY_STATE_ENDPOINT": "freeze-proxy",
 			})
 		}),
+	}, {
+		name: "set concurrency state fail fast",
+		rev: revision("bar", "foo",
+			withContainers(containers)),
+		dc: deployment.Config{
+			ConcurrencyStateEndpoint: "freeze-proxy",
+			ConcurrencyStateFailFast: true,
+		},
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{
+				"CONCURRENCY_STATE_ENDPOINT": "freeze-proxy",
+			}), corev1.EnvVar{
+				Name:  "CONCURRENCY_STATE_FAIL_FAST",
+				Value: "true",
+			}, corev1.EnvVar{
+				Name: "SERVING_POD_UID",
+				ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{
+						FieldPath: "metadata.uid",
+					},
+				},
+			})
+		}),
+	}, {
+		name: "set concurrency state probe interposition",
+		rev: revision("bar", "foo",
+			withContainers(containers)),
+		dc: deployment.Config{
+			ConcurrencyStateEndpoint:           "freeze-proxy",
+			ConcurrencyStateProbeInterposition: true,
+		},
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{
+				"CONCURRENCY_STATE_ENDPOINT": "freeze-proxy",
+			}), corev1.EnvVar{
+				Name:  "CONCURRENCY_STATE_PROBE_INTERPOSITION",
+				Value: "true",
+			})
+		}),
+	}, {
+		name: "debug server",
+		rev: revision("bar", "foo",
+			withContainers(containers)),
+		dc: deployment.Config{
+			QueueSidecarDebug: true,
+		},
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "ENABLE_DEBUG_SERVER",
+				Value: "true",
+			})
+		}),
+	}, {
+		name: "GOMAXPROCS set to the CPU quota",
+		rev: revision("bar", "foo",
+			withContainers(containers)),
+		dc: deployment.Config{
+			QueueSidecarAutoGomaxprocs: true,
+		},
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "AUTO_GOMAXPROCS",
+				Value: "true",
+			})
+		}),
+	}, {
+		name: "async callback allowed hosts",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(r *v1.Revision) {
+				r.Annotations = map[string]string{serving.QueueSideCarAsyncAnnotation: "true"}
+			}),
+		dc: deployment.Config{
+			AsyncCallbackAllowedHosts: sets.NewString("hooks.example.com", "*.callbacks.example.com"),
+		},
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "ASYNC_REQUESTS_DIR",
+				Value: asyncRequestsDir,
+			}, corev1.EnvVar{
+				Name:  "ASYNC_CALLBACK_ALLOWED_HOSTS",
+				Value: "*.callbacks.example.com,hooks.example.com",
+			})
+		}),
+	}, {
+		name: "request events",
+		rev: revision("bar", "foo",
+			withContainers(containers)),
+		otlp: &config.OTLP{
+			LogsEndpoint:           "http://collector.observability:4318",
+			LogsProtocol:           queue.OTLPProtocolHTTP,
+			LogsExportInterval:     5 * time.Second,
+			LogsMaxEventsPerSecond: 100,
+		},
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "SERVING_OTLP_LOGS_ENDPOINT",
+				Value: "http://collector.observability:4318",
+			}, corev1.EnvVar{
+				Name:  "SERVING_OTLP_LOGS_PROTOCOL",
+				Value: "http/protobuf",
+			}, corev1.EnvVar{
+				Name:  "SERVING_OTLP_LOGS_EXPORT_INTERVAL",
+				Value: "5s",
+			}, corev1.EnvVar{
+				Name:  "SERVING_OTLP_LOGS_MAX_EVENTS_PER_SECOND",
+				Value: "100",
+			})
+		}),
+	}, {
+		name: "push stats",
+		rev: revision("bar", "foo",
+			withContainers(containers)),
+		dc: deployment.Config{
+			QueueSidecarPushStats: true,
+		},
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "STAT_PUSH_ENDPOINT",
+				Value: "autoscaler." + system.Namespace() + ".svc.cluster.local:8081",
+			})
+		}),
+	}, {
+		name: "request rate limit",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarMaxRequestRateAnnotation:   "12.5",
+					serving.QueueSideCarRequestRateBurstAnnotation: "5",
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "REQUEST_RATE_LIMIT",
+				Value: "12.5",
+			}, corev1.EnvVar{
+				Name:  "REQUEST_RATE_BURST",
+				Value: "5",
+			})
+		}),
+	}, {
+		name: "client request rate limit",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarMaxClientRequestRateAnnotation:   "2",
+					serving.QueueSideCarClientRequestRateBurstAnnotation: "4",
+					serving.QueueSideCarClientRateLimitKeyAnnotation:     "header:X-Tenant-Id",
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "CLIENT_REQUEST_RATE_LIMIT",
+				Value: "2",
+			}, corev1.EnvVar{
+				Name:  "CLIENT_REQUEST_RATE_BURST",
+				Value: "4",
+			}, corev1.EnvVar{
+				Name:  "CLIENT_RATE_LIMIT_KEY",
+				Value: "header:X-Tenant-Id",
+			})
+		}),
+	}, {
+		name: "request priority",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarRequestPriorityHeaderAnnotation: "X-Priority",
+					serving.QueueSideCarRequestPriorityRulesAnnotation:  `{"/checkout": 9}`,
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "REQUEST_PRIORITY_HEADER",
+				Value: "X-Priority",
+			}, corev1.EnvVar{
+				Name:  "REQUEST_PRIORITY_RULES",
+				Value: `{"/checkout": 9}`,
+			})
+		}),
+	}, {
+		name: "max request body bytes",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarMaxRequestBodyBytesAnnotation: "1048576",
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "MAX_REQUEST_BODY_BYTES",
+				Value: "1048576",
+			})
+		}),
+	}, {
+		name: "timeout rules",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarTimeoutRulesAnnotation: `{"/batch": 600}`,
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "TIMEOUT_RULES",
+				Value: `{"/batch": 600}`,
+			})
+		}),
+	}, {
+		name: "grpc readiness probe",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarReadinessProbeGRPCServiceAnnotation: "greeter",
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "SERVING_READINESS_PROBE_GRPC",
+				Value: "true",
+			}, corev1.EnvVar{
+				Name:  "SERVING_READINESS_PROBE_GRPC_SERVICE",
+				Value: "greeter",
+			})
+		}),
+	}, {
+		name: "upstream limits",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarMaxUpstreamConnectionsAnnotation:    "100",
+					serving.QueueSideCarMaxResponseBytesPerSecondAnnotation: "1048576",
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "MAX_UPSTREAM_CONNECTIONS",
+				Value: "100",
+			}, corev1.EnvVar{
+				Name:  "MAX_RESPONSE_BYTES_PER_SECOND",
+				Value: "1048576",
+			})
+		}),
+	}, {
+		name: "passive readiness",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarReadinessModeAnnotation: "passive",
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "SERVING_READINESS_PROBE_PASSIVE",
+				Value: "true",
+			})
+		}),
+	}, {
+		name: "warm-up hook",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarWarmUpAnnotation: `{"path": "/warm-up"}`,
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "WARM_UP",
+				Value: `{"path": "/warm-up"}`,
+			})
+		}),
+	}, {
+		name: "stream mode",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarStreamModeAnnotation: "release",
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "STREAM_MODE",
+				Value: "release",
+			})
+		}),
+	}, {
+		name: "tracing sample rate and propagation",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.TracingSampleRateAnnotation: "0.5",
+				}
+			}),
+		tp: &pkghttp.TracePropagationConfig{Propagation: pkghttp.TracePropagationTraceContext},
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{
+				"TRACING_CONFIG_SAMPLE_RATE": "0.5",
+			}), corev1.EnvVar{
+				Name:  "TRACING_CONFIG_PROPAGATION",
+				Value: "tracecontext",
+			})
+		}),
+	}, {
+		name: "stream drain",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarStreamDrainSettleTimeAnnotation: "10s",
+					serving.QueueSideCarStreamDrainGoAwayAnnotation:     "true",
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "STREAM_DRAIN_SETTLE_TIME",
+				Value: "10s",
+			}, corev1.EnvVar{
+				Name:  "STREAM_DRAIN_GO_AWAY",
+				Value: "true",
+			})
+		}),
+	}, {
+		name: "drain signal",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarDrainSignalAnnotation: `{"path": "/draining"}`,
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "DRAIN_SIGNAL",
+				Value: `{"path": "/draining"}`,
+			})
+		}),
+	}, {
+		name: "request weight",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarRequestWeightHeaderAnnotation: "X-Weight",
+					serving.QueueSideCarRequestWeightRulesAnnotation:  `{"/batch": 4}`,
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "REQUEST_WEIGHT_HEADER",
+				Value: "X-Weight",
+			}, corev1.EnvVar{
+				Name:  "REQUEST_WEIGHT_RULES",
+				Value: `{"/batch": 4}`,
+			})
+		}),
+	}, {
+		name: "response headers",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarResponseHeadersAnnotation: `{"set": {"Cache-Control": "no-store"}}`,
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "RESPONSE_HEADER_RULES",
+				Value: `{"set": {"Cache-Control": "no-store"}}`,
+			})
+		}),
+	}, {
+		name: "adaptive concurrency",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarAdaptiveConcurrencyTargetLatencyAnnotation: "200ms",
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "ADAPTIVE_CONCURRENCY_TARGET_LATENCY",
+				Value: "200ms",
+			})
+		}),
+	}, {
+		name: "retries",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					serving.QueueSideCarRetryAttemptsAnnotation:      "3",
+					serving.QueueSideCarRetryStatusCodesAnnotation:   "502,503",
+					serving.QueueSideCarRetryPerTryTimeoutAnnotation: "2s",
+					serving.QueueSideCarRetryBudgetAnnotation:        "0.2",
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "RETRY_ATTEMPTS",
+				Value: "3",
+			}, corev1.EnvVar{
+				Name:  "RETRY_STATUS_CODES",
+				Value: "502,503",
+			}, corev1.EnvVar{
+				Name:  "RETRY_PER_TRY_TIMEOUT",
+				Value: "2s",
+			}, corev1.EnvVar{
+				Name:  "RETRY_BUDGET",
+				Value: "0.2",
+			})
+		}),
+	}, {
+		name: "concurrency state pause delay",
+		rev: revision("bar", "foo",
+			withContainers(containers),
+			func(revision *v1.Revision) {
+				revision.Annotations = map[string]string{
+					autoscaling.ConcurrencyStatePauseDelayAnnotationKey: "250ms",
+				}
+			}),
+		want: queueContainer(func(c *corev1.Container) {
+			c.Env = append(env(map[string]string{}), corev1.EnvVar{
+				Name:  "CONCURRENCY_STATE_PAUSE_DELAY",
+				Value: "250ms",
+			})
+		}),
 	}, {
 		name: "HTTP2 autodetection disabled",
 		rev: revision("bar", "foo",
@@ -376,10 +822,12 @@ func TestMakeQueueContainer(t *testing.T) {
 				}
 			}
 			cfg := &config.Config{
-				Tracing:       &traceConfig,
-				Logging:       &test.lc,
-				Observability: &test.oc,
-				Deployment:    &test.dc,
+				Tracing:          &traceConfig,
+				TracePropagation: test.tp,
+				OTLP:             test.otlp,
+				Logging:          &test.lc,
+				Observability:    &test.oc,
+				Deployment:       &test.dc,
 				Config: &apicfg.Config{
 					Features: &test.fc,
 				},
@@ -24,6 +24,7 @@ import (
 	"knative.dev/pkg/logging"
 	cfgmap "knative.dev/serving/pkg/apis/config"
 	"knative.dev/serving/pkg/gc"
+	"knative.dev/serving/pkg/networking"
 )
 
 type cfgKey struct{}
@@ -35,6 +36,9 @@ type Config struct {
 	GC       *gc.Config
 	Network  *network.Config
 	Features *cfgmap.Features
+	// InternalTLS holds the settings of the encryption of the
+	// cluster-internal hops, parsed out of config-network.
+	InternalTLS *networking.InternalTLSConfig
 }
 
 // FromContext obtains a Config injected into the passed context.
@@ -67,6 +71,9 @@ func ToContext(ctx context.Context, c *Config) context.Context {
 // +k8s:deepcopy-gen=false
 type Store struct {
 	*configmap.UntypedStore
+	// internalTLSStore parses the internal TLS settings out of
+	// config-network, which the UntypedStore parses as a network.Config.
+	internalTLSStore *configmap.UntypedStore
 }
 
 // NewStore creates a configmap.UntypedStore based config store.
@@ -93,11 +100,26 @@ func NewStore(ctx context.Context, onAfterStore ...func(name string, value inter
 			},
 			onAfterStore...,
 		),
+		internalTLSStore: configmap.NewUntypedStore(
+			"route-internal-tls",
+			logger,
+			configmap.Constructors{
+				network.ConfigName: networking.NewInternalTLSConfigFromConfigMap,
+			},
+		),
 	}
 
 	return store
 }
 
+// WatchConfigs uses the provided configmap.Watcher
+// to setup watches for the config names provided in the
+// Constructors map
+func (s *Store) WatchConfigs(cmw configmap.Watcher) {
+	s.UntypedStore.WatchConfigs(cmw)
+	s.internalTLSStore.WatchConfigs(cmw)
+}
+
 // ToContext stores the configuration Store in the passed context.
 func (s *Store) ToContext(ctx context.Context) context.Context {
 	return ToContext(ctx, s.Load())
@@ -115,6 +137,9 @@ func (s *Store) Load() *Config {
 	if featureConfig := s.UntypedLoad(cfgmap.FeaturesConfigName); featureConfig != nil {
 		config.Features = featureConfig.(*cfgmap.Features).DeepCopy()
 	}
+	if tls, ok := s.internalTLSStore.UntypedLoad(network.ConfigName).(*networking.InternalTLSConfig); ok {
+		config.InternalTLS = tls.DeepCopy()
+	}
 
 	return config
 }
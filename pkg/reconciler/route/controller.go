@@ -31,6 +31,7 @@ import (
 	routeinformer "knative.dev/serving/pkg/client/injection/informers/serving/v1/route"
 	routereconciler "knative.dev/serving/pkg/client/injection/reconciler/serving/v1/route"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/tools/cache"
 	network "knative.dev/networking/pkg"
@@ -78,6 +79,7 @@ func newController(
 		ingressLister:       ingressInformer.Lister(),
 		certificateLister:   certificateInformer.Lister(),
 		clock:               clock,
+		requestStats:        scrapeRequestStats(endpointsInformer.Lister()),
 	}
 	impl := routereconciler.NewImpl(ctx, c, func(impl *controller.Impl) controller.Options {
 		configsToResync := []interface{}{
@@ -130,6 +132,14 @@ func newController(
 		),
 	))
 
+	// Track the Services referenced by the external traffic targets.
+	serviceInformer.Informer().AddEventHandler(controller.HandleAll(
+		controller.EnsureTypeMeta(
+			c.tracker.OnChanged,
+			corev1.SchemeGroupVersion.WithKind("Service"),
+		),
+	))
+
 	for _, opt := range opts {
 		opt(c)
 	}
@@ -216,6 +216,66 @@ func (c *Reconciler) reconcilePlaceholderServices(ctx context.Context, route *v1
 	return services, nil
 }
 
+// reconcileExternalServices creates the ExternalName Services routing to the
+// external URL backends of the targets, and deletes the ones of the backends
+// that aren't referenced anymore.
+func (c *Reconciler) reconcileExternalServices(ctx context.Context, route *v1.Route, targets map[string]traffic.RevisionTargets) error {
+	recorder := controller.GetEventRecorder(ctx)
+	ns := route.Namespace
+	active := make(sets.String)
+
+	// All the targets are in the default target, tagged or not.
+	for _, tt := range targets[traffic.DefaultTarget] {
+		if tt.External == nil || tt.External.URL == nil {
+			continue
+		}
+		desired := resources.MakeExternalService(route, tt.External.URL)
+		if active.Has(desired.Name) {
+			continue
+		}
+		active.Insert(desired.Name)
+
+		service, err := c.serviceLister.Services(ns).Get(desired.Name)
+		if apierrs.IsNotFound(err) {
+			if _, err := c.kubeclient.CoreV1().Services(ns).Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+				recorder.Eventf(route, corev1.EventTypeWarning, "CreationFailed",
+					"Failed to create external service %q: %v", desired.Name, err)
+				return fmt.Errorf("failed to create external service: %w", err)
+			}
+			recorder.Eventf(route, corev1.EventTypeNormal, "Created", "Created external service %q", desired.Name)
+		} else if err != nil {
+			return err
+		} else if !metav1.IsControlledBy(service, route) {
+			route.Status.MarkServiceNotOwned(desired.Name)
+			return fmt.Errorf("route: %q does not own Service: %q", route.Name, desired.Name)
+		} else if !equality.Semantic.DeepEqual(service.Spec.ExternalName, desired.Spec.ExternalName) ||
+			!equality.Semantic.DeepEqual(service.Spec.Ports, desired.Spec.Ports) {
+			// Don't modify the informers copy.
+			existing := service.DeepCopy()
+			existing.Spec.ExternalName = desired.Spec.ExternalName
+			existing.Spec.Ports = desired.Spec.Ports
+			if _, err := c.kubeclient.CoreV1().Services(ns).Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+				return fmt.Errorf("failed to update external service: %w", err)
+			}
+		}
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{serving.ExternalRouteLabelKey: route.Name})
+	existing, err := c.serviceLister.Services(ns).List(selector)
+	if err != nil {
+		return fmt.Errorf("failed to fetch existing external services: %w", err)
+	}
+	for _, service := range existing {
+		if active.Has(service.Name) || !metav1.IsControlledBy(service, route) {
+			continue
+		}
+		if err := c.kubeclient.CoreV1().Services(ns).Delete(ctx, service.Name, metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("failed to delete external service: %w", err)
+		}
+	}
+	return nil
+}
+
 func (c *Reconciler) updatePlaceholderServices(ctx context.Context, route *v1.Route, pairs []resources.ServicePair, ingress *netv1alpha1.Ingress) error {
 	logger := logging.FromContext(ctx)
 	ns := route.Namespace
@@ -365,6 +425,7 @@ func (c *Reconciler) reconcileRollout(
 	}
 
 	effectiveRO, nextStepTime := curRO.Step(ctx, prevRO, now)
+	c.guardRollout(ctx, r, effectiveRO)
 	if nextStepTime > 0 {
 		nextStepTime -= now
 		c.enqueueAfter(r, time.Duration(nextStepTime))
@@ -17,10 +17,12 @@ limitations under the License.
 package resources
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/davecgh/go-spew/spew"
@@ -36,10 +38,14 @@ import (
 	ingress "knative.dev/networking/pkg/ingress"
 	"knative.dev/pkg/kmeta"
 	"knative.dev/pkg/logging"
+	"knative.dev/pkg/ptr"
 	"knative.dev/serving/pkg/activator"
 	apicfg "knative.dev/serving/pkg/apis/config"
 	"knative.dev/serving/pkg/apis/serving"
 	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	pkghttp "knative.dev/serving/pkg/http"
+	servingnetworking "knative.dev/serving/pkg/networking"
+	"knative.dev/serving/pkg/queue"
 	"knative.dev/serving/pkg/reconciler/route/config"
 	"knative.dev/serving/pkg/reconciler/route/domains"
 	"knative.dev/serving/pkg/reconciler/route/resources/labels"
@@ -139,6 +145,7 @@ func makeIngressSpec(
 	rules := make([]netv1alpha1.IngressRule, 0, len(names))
 
 	featuresConfig := config.FromContextOrDefaults(ctx).Features
+	tagHeaderRules := tagResponseHeaderRules(r.Annotations)
 
 	for _, name := range names {
 		visibilities := []netv1alpha1.IngressVisibility{netv1alpha1.IngressVisibilityClusterLocal}
@@ -151,8 +158,14 @@ func makeIngressSpec(
 			if err != nil {
 				return netv1alpha1.IngressSpec{}, err
 			}
-			rule := makeIngressRule(domains, r.Namespace,
+			rule := makeIngressRule(domains, r.Namespace, r.Name,
 				visibility, tc.Targets[name], ro.RolloutsByTag(name))
+			if name == traffic.DefaultTarget {
+				// The requests matching the conditions of a target are routed to it
+				// ahead of the percentage based routing.
+				rule.HTTP.Paths = append(
+					makeMatchBasedRoutingIngressPaths(r.Namespace, r.Name, tc.Targets[name]), rule.HTTP.Paths...)
+			}
 			if featuresConfig.TagHeaderBasedRouting == apicfg.Enabled {
 				if rule.HTTP.Paths[0].AppendHeaders == nil {
 					rule.HTTP.Paths[0].AppendHeaders = make(map[string]string, 1)
@@ -174,7 +187,7 @@ func makeIngressSpec(
 					// Since names are sorted `DefaultTarget == ""` is the first one,
 					// so just pass the subslice.
 					rule.HTTP.Paths = append(
-						makeTagBasedRoutingIngressPaths(r.Namespace, tc, ro, names[1:]), rule.HTTP.Paths...)
+						makeTagBasedRoutingIngressPaths(r.Namespace, r.Name, tc, ro, names[1:]), rule.HTTP.Paths...)
 				} else {
 					// If a request is routed by a tag-attached hostname instead of the tag header,
 					// the request may not have the tag header "Knative-Serving-Tag",
@@ -186,6 +199,9 @@ func makeIngressSpec(
 					rule.HTTP.Paths[0].AppendHeaders[network.TagHeaderName] = name
 				}
 			}
+			if len(tagHeaderRules) > 0 {
+				appendTagResponseHeaderRules(rule.HTTP.Paths, name, tagHeaderRules)
+			}
 			// If this is a public rule, we need to configure ACME challenge paths.
 			if visibility == netv1alpha1.IngressVisibilityExternalIP {
 				rule.HTTP.Paths = append(
@@ -195,6 +211,30 @@ func makeIngressSpec(
 		}
 	}
 
+	if tlsConfig := config.FromContextOrDefaults(ctx).InternalTLS; tlsConfig != nil && tlsConfig.InternalEncryption {
+		// The ingress talks TLS to the https port of the public services.
+		forEachRevisionSplit(rules, func(split *netv1alpha1.IngressBackendSplit) {
+			split.ServicePort = intstr.FromInt(servingnetworking.ServiceHTTPSPort)
+		})
+	}
+	if cors := pkghttp.CORSPolicyFromAnnotations(r.Annotations); cors != nil {
+		// For the queue-proxy to enforce, if the ingress doesn't.
+		policy := cors.String()
+		forEachRevisionSplit(rules, func(split *netv1alpha1.IngressBackendSplit) {
+			split.AppendHeaders[pkghttp.CORSHeaderName] = policy
+		})
+	}
+	if mirror := mirrorPolicy(r.Annotations); mirror != nil {
+		// For the queue-proxy to mirror, the shadow Revision's own traffic
+		// aside.
+		policy := mirror.String()
+		forEachRevisionSplit(rules, func(split *netv1alpha1.IngressBackendSplit) {
+			if split.AppendHeaders[activator.RevisionHeaderName] != mirror.Revision {
+				split.AppendHeaders[queue.MirrorHeaderName] = policy
+			}
+		})
+	}
+
 	httpOption, err := getHTTPOption(ctx, r.Annotations)
 	if err != nil {
 		return netv1alpha1.IngressSpec{}, err
@@ -207,6 +247,83 @@ func makeIngressSpec(
 	}, nil
 }
 
+// mirrorPolicy returns the MirrorPolicy of the MirrorRevisionKey and
+// MirrorPercentKey annotations, if the traffic is mirrored.
+func mirrorPolicy(annotations map[string]string) *queue.MirrorPolicy {
+	revision, ok := annotations[serving.MirrorRevisionKey]
+	if !ok {
+		return nil
+	}
+	policy := &queue.MirrorPolicy{Revision: revision, Percent: 100}
+	if v, ok := annotations[serving.MirrorPercentKey]; ok {
+		// The annotations are validated, so it's not expected to be invalid.
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil
+		}
+		policy.Percent = p
+	}
+	return policy
+}
+
+// tagResponseHeaderRules returns the validated response header rules of the
+// TagResponseHeadersKey annotation, serialized by tag.
+func tagResponseHeaderRules(annotations map[string]string) map[string]string {
+	v, ok := annotations[serving.TagResponseHeadersKey]
+	if !ok {
+		return nil
+	}
+	var rules map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(v), &rules); err != nil {
+		return nil
+	}
+	ret := make(map[string]string, len(rules))
+	for tag, r := range rules {
+		var b bytes.Buffer
+		if err := json.Compact(&b, r); err == nil {
+			ret[tag] = b.String()
+		}
+	}
+	return ret
+}
+
+// appendTagResponseHeaderRules has the revision splits of the paths pass the
+// response header rules of their tag on to the queue-proxy. The paths of the
+// tag header based routing are matched by the tag in their header, the
+// others are routing to the target named name.
+func appendTagResponseHeaderRules(paths []netv1alpha1.HTTPIngressPath, name string, rules map[string]string) {
+	for i := range paths {
+		tag := name
+		if h, ok := paths[i].Headers[network.TagHeaderName]; ok {
+			tag = h.Exact
+		}
+		v, ok := rules[tag]
+		if !ok {
+			continue
+		}
+		for j := range paths[i].Splits {
+			if split := &paths[i].Splits[j]; split.AppendHeaders[activator.RevisionHeaderName] != "" {
+				split.AppendHeaders[queue.ResponseHeaderRulesHeaderName] = v
+			}
+		}
+	}
+}
+
+// forEachRevisionSplit calls f with the splits of the rules routing to a
+// revision, as opposed to e.g. the ACME solvers and the external services.
+func forEachRevisionSplit(rules []netv1alpha1.IngressRule, f func(*netv1alpha1.IngressBackendSplit)) {
+	for i := range rules {
+		for j := range rules[i].HTTP.Paths {
+			splits := rules[i].HTTP.Paths[j].Splits
+			for k := range splits {
+				if _, ok := splits[k].AppendHeaders[activator.RevisionHeaderName]; ok {
+					f(&splits[k])
+				}
+			}
+		}
+	}
+}
+
 func getHTTPOption(ctx context.Context, annotations map[string]string) (netv1alpha1.HTTPOption, error) {
 	if len(annotations) != 0 && annotations[networking.HTTPOptionAnnotationKey] != "" {
 		annotation := annotations[networking.HTTPOptionAnnotationKey]
@@ -290,7 +407,7 @@ func MakeACMEIngressPaths(acmeChallenges []netv1alpha1.HTTP01Challenge, domains
 	return paths
 }
 
-func makeIngressRule(domains []string, ns string,
+func makeIngressRule(domains []string, ns, route string,
 	visibility netv1alpha1.IngressVisibility,
 	targets traffic.RevisionTargets,
 	roCfgs []*traffic.ConfigurationRollout) netv1alpha1.IngressRule {
@@ -299,18 +416,18 @@ func makeIngressRule(domains []string, ns string,
 		Visibility: visibility,
 		HTTP: &netv1alpha1.HTTPIngressRuleValue{
 			Paths: []netv1alpha1.HTTPIngressPath{
-				*makeBaseIngressPath(ns, targets, roCfgs),
+				*makeBaseIngressPath(ns, route, targets, roCfgs),
 			},
 		},
 	}
 }
 
 // `names` must not include `""` — the DefaultTarget.
-func makeTagBasedRoutingIngressPaths(ns string, tc *traffic.Config, ro *traffic.Rollout, names []string) []netv1alpha1.HTTPIngressPath {
+func makeTagBasedRoutingIngressPaths(ns, route string, tc *traffic.Config, ro *traffic.Rollout, names []string) []netv1alpha1.HTTPIngressPath {
 	paths := make([]netv1alpha1.HTTPIngressPath, 0, len(names))
 
 	for _, name := range names {
-		path := makeBaseIngressPath(ns, tc.Targets[name], ro.RolloutsByTag(name))
+		path := makeBaseIngressPath(ns, route, tc.Targets[name], ro.RolloutsByTag(name))
 		path.Headers = map[string]netv1alpha1.HeaderMatch{network.TagHeaderName: {Exact: name}}
 		paths = append(paths, *path)
 	}
@@ -318,6 +435,40 @@ func makeTagBasedRoutingIngressPaths(ns string, tc *traffic.Config, ro *traffic.
 	return paths
 }
 
+// makeMatchBasedRoutingIngressPaths returns the ingress paths routing all the
+// requests matching the conditions of the targets to them.
+func makeMatchBasedRoutingIngressPaths(ns, route string, targets traffic.RevisionTargets) []netv1alpha1.HTTPIngressPath {
+	var paths []netv1alpha1.HTTPIngressPath
+	for _, t := range targets {
+		// The cookies can't be matched by the Ingress, the targets matching
+		// them, from before they were rejected, get no requests of their own.
+		if t.Match == nil || len(t.Match.Cookies) > 0 {
+			continue
+		}
+		t.Percent = ptr.Int64(100)
+		path := makeBaseIngressPath(ns, route, traffic.RevisionTargets{t}, nil /*no rollout*/)
+		path.Path = t.Match.PathPrefix
+		if len(t.Match.Headers) > 0 {
+			path.Headers = matchHeaders(t.Match)
+		}
+		paths = append(paths, *path)
+	}
+	// The longer path prefixes are more specific, so they are matched first.
+	sort.SliceStable(paths, func(i, j int) bool {
+		return len(paths[i].Path) > len(paths[j].Path)
+	})
+	return paths
+}
+
+// matchHeaders returns the header matches of the traffic match conditions.
+func matchHeaders(m *servingv1.TrafficMatch) map[string]netv1alpha1.HeaderMatch {
+	headers := make(map[string]netv1alpha1.HeaderMatch, len(m.Headers))
+	for name, value := range m.Headers {
+		headers[name] = netv1alpha1.HeaderMatch{Exact: value}
+	}
+	return headers
+}
+
 func rolloutConfig(cfgName string, ros []*traffic.ConfigurationRollout) *traffic.ConfigurationRollout {
 	idx := sort.Search(len(ros), func(i int) bool {
 		return ros[i].ConfigurationName >= cfgName
@@ -329,15 +480,31 @@ func rolloutConfig(cfgName string, ros []*traffic.ConfigurationRollout) *traffic
 	return ros[idx]
 }
 
-func makeBaseIngressPath(ns string, targets traffic.RevisionTargets,
+func makeBaseIngressPath(ns, route string, targets traffic.RevisionTargets,
 	roCfgs []*traffic.ConfigurationRollout) *netv1alpha1.HTTPIngressPath {
 	// Optimistically allocate |targets| elements.
 	splits := make([]netv1alpha1.IngressBackendSplit, 0, len(targets))
+	var rewriteHost string
 	for _, t := range targets {
 		var cfg *traffic.ConfigurationRollout
 		if *t.Percent == 0 {
 			continue
 		}
+		if t.External != nil {
+			name, port := externalBackend(route, t.External)
+			splits = append(splits, netv1alpha1.IngressBackendSplit{
+				IngressBackend: netv1alpha1.IngressBackend{
+					ServiceNamespace: ns,
+					ServiceName:      name,
+					ServicePort:      intstr.FromInt(port),
+				},
+				Percent: int(*t.Percent),
+			})
+			if t.External.URL != nil {
+				rewriteHost = t.External.URL.Host
+			}
+			continue
+		}
 
 		if t.LatestRevision != nil && *t.LatestRevision {
 			cfg = rolloutConfig(t.ConfigurationName, roCfgs)
@@ -356,6 +523,7 @@ func makeBaseIngressPath(ns string, targets traffic.RevisionTargets,
 				AppendHeaders: map[string]string{
 					activator.RevisionHeaderName:      t.TrafficTarget.RevisionName,
 					activator.RevisionHeaderNamespace: ns,
+					activator.RouteHeaderName:         route,
 				},
 			})
 		} else {
@@ -373,13 +541,20 @@ func makeBaseIngressPath(ns string, targets traffic.RevisionTargets,
 					AppendHeaders: map[string]string{
 						activator.RevisionHeaderName:      rev.RevisionName,
 						activator.RevisionHeaderNamespace: ns,
+						activator.RouteHeaderName:         route,
 					},
 				})
 			}
 		}
 	}
 
-	return &netv1alpha1.HTTPIngressPath{
+	path := &netv1alpha1.HTTPIngressPath{
 		Splits: splits,
 	}
+	// The external URL backends are sent the requests for their own host,
+	// which the Ingress only rewrites for a whole path.
+	if rewriteHost != "" && len(splits) == 1 {
+		path.RewriteHost = rewriteHost
+	}
+	return path
 }
@@ -38,7 +38,11 @@ import (
 	apicfg "knative.dev/serving/pkg/apis/config"
 	"knative.dev/serving/pkg/apis/serving"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	pkghttp "knative.dev/serving/pkg/http"
+	servingnetworking "knative.dev/serving/pkg/networking"
+	"knative.dev/serving/pkg/queue"
 	"knative.dev/serving/pkg/reconciler/route/config"
+	"knative.dev/serving/pkg/reconciler/route/resources/names"
 	"knative.dev/serving/pkg/reconciler/route/traffic"
 
 	_ "knative.dev/pkg/system/testing"
@@ -272,6 +276,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "rune-01911",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}, {
 					IngressBackend: netv1alpha1.IngressBackend{
@@ -283,6 +288,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "valhalla-01981",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}, {
 					IngressBackend: netv1alpha1.IngressBackend{
@@ -294,6 +300,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "valhalla-01982",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -315,6 +322,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "rune-01911",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}, {
 					IngressBackend: netv1alpha1.IngressBackend{
@@ -326,6 +334,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "valhalla-01981",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}, {
 					IngressBackend: netv1alpha1.IngressBackend{
@@ -337,6 +346,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "valhalla-01982",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -360,6 +370,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "thor-02018",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}, {
 					IngressBackend: netv1alpha1.IngressBackend{
@@ -371,6 +382,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "thor-02019",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}, {
 					IngressBackend: netv1alpha1.IngressBackend{
@@ -382,6 +394,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "thor-02020",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}, {
 					IngressBackend: netv1alpha1.IngressBackend{
@@ -393,6 +406,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "thor-beta",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -414,6 +428,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "thor-02018",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}, {
 					IngressBackend: netv1alpha1.IngressBackend{
@@ -425,6 +440,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "thor-02019",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}, {
 					IngressBackend: netv1alpha1.IngressBackend{
@@ -436,6 +452,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "thor-02020",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}, {
 					IngressBackend: netv1alpha1.IngressBackend{
@@ -447,6 +464,7 @@ func TestMakeIngressWithActualRollout(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "thor-beta",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -511,6 +529,7 @@ func TestMakeIngressSpecCorrectRules(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "v2",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -532,6 +551,7 @@ func TestMakeIngressSpecCorrectRules(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "v2",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -555,6 +575,7 @@ func TestMakeIngressSpecCorrectRules(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "v1",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -576,6 +597,7 @@ func TestMakeIngressSpecCorrectRules(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "v1",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -595,6 +617,115 @@ func TestMakeIngressSpecCorrectRules(t *testing.T) {
 	}
 }
 
+func TestMakeMatchBasedRoutingIngressPaths(t *testing.T) {
+	targets := traffic.RevisionTargets{{
+		TrafficTarget: v1.TrafficTarget{
+			ConfigurationName: "config",
+			RevisionName:      "v1",
+			Percent:           ptr.Int64(90),
+		},
+	}, {
+		TrafficTarget: v1.TrafficTarget{
+			ConfigurationName: "config",
+			RevisionName:      "v2",
+			Percent:           ptr.Int64(10),
+			Match: &v1.TrafficMatch{
+				Headers: map[string]string{"X-Tester": "true"},
+			},
+		},
+	}}
+
+	want := []netv1alpha1.HTTPIngressPath{{
+		Headers: map[string]netv1alpha1.HeaderMatch{
+			"X-Tester": {Exact: "true"},
+		},
+		Splits: []netv1alpha1.IngressBackendSplit{{
+			IngressBackend: netv1alpha1.IngressBackend{
+				ServiceNamespace: ns,
+				ServiceName:      "v2",
+				ServicePort:      intstr.FromInt(80),
+			},
+			Percent: 100,
+			AppendHeaders: map[string]string{
+				"Knative-Serving-Revision":  "v2",
+				"Knative-Serving-Namespace": ns,
+				"Knative-Serving-Route":     testRouteName,
+			},
+		}},
+	}}
+	got := makeMatchBasedRoutingIngressPaths(ns, testRouteName, targets)
+	if !cmp.Equal(want, got) {
+		t.Error("Unexpected paths (-want, +got):", cmp.Diff(want, got))
+	}
+	if got := *targets[1].Percent; got != 10 {
+		t.Errorf("Percent = %d, want: 10 (unchanged)", got)
+	}
+
+	r := Route(ns, testRouteName, WithURL)
+	tc := &traffic.Config{Targets: map[string]traffic.RevisionTargets{traffic.DefaultTarget: targets}}
+	ci, err := makeIngressSpec(testContext(), r, nil /*tls*/, tc, tc.BuildRollout())
+	if err != nil {
+		t.Fatal("makeIngressSpec() =", err)
+	}
+	for _, rule := range ci.Rules {
+		if paths := rule.HTTP.Paths; len(paths) != 2 || !cmp.Equal(paths[0], want[0]) {
+			t.Errorf("Paths of %v = %v, want the match path first", rule.Hosts, paths)
+		}
+	}
+}
+
+func TestMakeMatchBasedRoutingIngressPathsCookies(t *testing.T) {
+	// A browser sends more cookies than the ones matched, in any order, e.g.
+	// "session=abc; user=canary", which an exact match of the Cookie header
+	// on "user=canary" would miss, so the cookies aren't matched at all.
+	targets := traffic.RevisionTargets{{
+		TrafficTarget: v1.TrafficTarget{
+			ConfigurationName: "config",
+			RevisionName:      "v2",
+			Match: &v1.TrafficMatch{
+				Headers: map[string]string{"X-Tester": "true"},
+				Cookies: map[string]string{"user": "canary"},
+			},
+		},
+	}}
+
+	if got := makeMatchBasedRoutingIngressPaths(ns, testRouteName, targets); len(got) != 0 {
+		t.Errorf("Paths = %v, want none for a target matching cookies", got)
+	}
+}
+
+func TestMakeMatchBasedRoutingIngressPathsPathPrefix(t *testing.T) {
+	targets := traffic.RevisionTargets{{
+		TrafficTarget: v1.TrafficTarget{
+			ConfigurationName: "config",
+			RevisionName:      "v1",
+			Percent:           ptr.Int64(100),
+			Match:             &v1.TrafficMatch{PathPrefix: "/v1/"},
+		},
+	}, {
+		TrafficTarget: v1.TrafficTarget{
+			ConfigurationName: "config",
+			RevisionName:      "v2",
+			Match:             &v1.TrafficMatch{PathPrefix: "/v1/beta/"},
+		},
+	}}
+
+	got := makeMatchBasedRoutingIngressPaths(ns, testRouteName, targets)
+	if len(got) != 2 {
+		t.Fatalf("Got %d paths, want 2", len(got))
+	}
+	// The longer prefix is matched first.
+	for i, want := range []struct{ path, revision string }{{"/v1/beta/", "v2"}, {"/v1/", "v1"}} {
+		if got[i].Path != want.path || got[i].Splits[0].ServiceName != want.revision {
+			t.Errorf("Path %d = %s -> %s, want: %s -> %s", i, got[i].Path, got[i].Splits[0].ServiceName,
+				want.path, want.revision)
+		}
+		if got[i].Headers != nil {
+			t.Errorf("Path %d headers = %v, want none", i, got[i].Headers)
+		}
+	}
+}
+
 func TestMakeIngressSpecCorrectRuleVisibility(t *testing.T) {
 	cases := []struct {
 		name               string
@@ -720,6 +851,7 @@ func TestMakeIngressSpecCorrectRulesWithTagBasedRouting(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "v1",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}, {
@@ -736,6 +868,7 @@ func TestMakeIngressSpecCorrectRulesWithTagBasedRouting(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "v2",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -762,6 +895,7 @@ func TestMakeIngressSpecCorrectRulesWithTagBasedRouting(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "v1",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}, {
@@ -778,6 +912,7 @@ func TestMakeIngressSpecCorrectRulesWithTagBasedRouting(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "v2",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -804,6 +939,7 @@ func TestMakeIngressSpecCorrectRulesWithTagBasedRouting(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "v1",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -828,6 +964,7 @@ func TestMakeIngressSpecCorrectRulesWithTagBasedRouting(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "v1",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -866,7 +1003,7 @@ func TestMakeIngressRuleVanilla(t *testing.T) {
 		},
 	}
 	ro := tc.BuildRollout()
-	rule := makeIngressRule(domains, ns,
+	rule := makeIngressRule(domains, ns, testRouteName,
 		netv1alpha1.IngressVisibilityExternalIP, targets, ro.RolloutsByTag(traffic.DefaultTarget))
 	expected := netv1alpha1.IngressRule{
 		Hosts: []string{
@@ -885,6 +1022,7 @@ func TestMakeIngressRuleVanilla(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "revision-shark",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -919,7 +1057,7 @@ func TestMakeIngressRuleZeroPercentTarget(t *testing.T) {
 		},
 	}
 	ro := tc.BuildRollout()
-	rule := makeIngressRule(domains, ns,
+	rule := makeIngressRule(domains, ns, testRouteName,
 		netv1alpha1.IngressVisibilityExternalIP, targets, ro.RolloutsByTag(traffic.DefaultTarget))
 	expected := netv1alpha1.IngressRule{
 		Hosts: []string{"test.org"},
@@ -935,6 +1073,7 @@ func TestMakeIngressRuleZeroPercentTarget(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "revision-dolphin",
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}},
@@ -969,7 +1108,7 @@ func TestMakeIngressRuleTwoTargets(t *testing.T) {
 	}
 	ro := tc.BuildRollout()
 	domains := []string{"test.org"}
-	rule := makeIngressRule(domains, ns, netv1alpha1.IngressVisibilityExternalIP,
+	rule := makeIngressRule(domains, ns, testRouteName, netv1alpha1.IngressVisibilityExternalIP,
 		targets, ro.RolloutsByTag("a-tag"))
 	expected := netv1alpha1.IngressRule{
 		Hosts: []string{"test.org"},
@@ -984,6 +1123,7 @@ func TestMakeIngressRuleTwoTargets(t *testing.T) {
 					Percent: 80,
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 						"Knative-Serving-Revision":  "revision-beluga",
 					},
 				}, {
@@ -995,6 +1135,7 @@ func TestMakeIngressRuleTwoTargets(t *testing.T) {
 					Percent: 20,
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
 						"Knative-Serving-Revision":  "new-revision-narwhal",
 					},
 				}},
@@ -1008,6 +1149,245 @@ func TestMakeIngressRuleTwoTargets(t *testing.T) {
 	}
 }
 
+func TestMakeIngressRuleExternalTargets(t *testing.T) {
+	targets := []traffic.RevisionTarget{{
+		TrafficTarget: v1.TrafficTarget{
+			ConfigurationName: "config",
+			RevisionName:      "revision-beluga",
+			Percent:           ptr.Int64(70),
+		},
+	}, {
+		TrafficTarget: v1.TrafficTarget{
+			Percent:  ptr.Int64(30),
+			External: &v1.ExternalTarget{ServiceName: "legacy", Port: 8080},
+		},
+	}}
+	rule := makeIngressRule([]string{"test.org"}, ns, testRouteName, netv1alpha1.IngressVisibilityExternalIP,
+		targets, nil /*no rollout*/)
+	expected := netv1alpha1.IngressRule{
+		Hosts: []string{"test.org"},
+		HTTP: &netv1alpha1.HTTPIngressRuleValue{
+			Paths: []netv1alpha1.HTTPIngressPath{{
+				Splits: []netv1alpha1.IngressBackendSplit{{
+					IngressBackend: netv1alpha1.IngressBackend{
+						ServiceNamespace: ns,
+						ServiceName:      "revision-beluga",
+						ServicePort:      intstr.FromInt(80),
+					},
+					Percent: 70,
+					AppendHeaders: map[string]string{
+						"Knative-Serving-Namespace": ns,
+						"Knative-Serving-Route":     testRouteName,
+						"Knative-Serving-Revision":  "revision-beluga",
+					},
+				}, {
+					IngressBackend: netv1alpha1.IngressBackend{
+						ServiceNamespace: ns,
+						ServiceName:      "legacy",
+						ServicePort:      intstr.FromInt(8080),
+					},
+					Percent: 30,
+				}},
+			}},
+		},
+		Visibility: netv1alpha1.IngressVisibilityExternalIP,
+	}
+
+	if !cmp.Equal(expected, rule) {
+		t.Errorf("Unexpected rule (-want, +got):\n%s", cmp.Diff(expected, rule))
+	}
+}
+
+func TestMakeIngressRuleExternalURL(t *testing.T) {
+	targets := []traffic.RevisionTarget{{
+		TrafficTarget: v1.TrafficTarget{
+			Percent: ptr.Int64(100),
+			External: &v1.ExternalTarget{
+				URL: &apis.URL{Scheme: "http", Host: "legacy.example.com:8080"},
+			},
+		},
+	}}
+	rule := makeIngressRule([]string{"test.org"}, ns, testRouteName, netv1alpha1.IngressVisibilityExternalIP,
+		targets, nil /*no rollout*/)
+	expected := netv1alpha1.IngressRule{
+		Hosts: []string{"test.org"},
+		HTTP: &netv1alpha1.HTTPIngressRuleValue{
+			Paths: []netv1alpha1.HTTPIngressPath{{
+				// The backend is sent the requests for its own host.
+				RewriteHost: "legacy.example.com:8080",
+				Splits: []netv1alpha1.IngressBackendSplit{{
+					IngressBackend: netv1alpha1.IngressBackend{
+						ServiceNamespace: ns,
+						ServiceName:      names.ExternalService(testRouteName, "legacy.example.com:8080"),
+						ServicePort:      intstr.FromInt(8080),
+					},
+					Percent: 100,
+				}},
+			}},
+		},
+		Visibility: netv1alpha1.IngressVisibilityExternalIP,
+	}
+
+	if !cmp.Equal(expected, rule) {
+		t.Errorf("Unexpected rule (-want, +got):\n%s", cmp.Diff(expected, rule))
+	}
+}
+
+func TestMakeIngressSpecInternalEncryption(t *testing.T) {
+	targets := map[string]traffic.RevisionTargets{
+		traffic.DefaultTarget: {{
+			TrafficTarget: v1.TrafficTarget{
+				ConfigurationName: "config",
+				RevisionName:      "revision-beluga",
+				Percent:           ptr.Int64(70),
+			},
+		}, {
+			TrafficTarget: v1.TrafficTarget{
+				Percent:  ptr.Int64(30),
+				External: &v1.ExternalTarget{ServiceName: "legacy", Port: 8080},
+			},
+		}},
+	}
+	r := Route(ns, testRouteName, WithRouteUID("1234-5678"), WithURL)
+	ctx := testContext()
+	config.FromContext(ctx).InternalTLS = &servingnetworking.InternalTLSConfig{InternalEncryption: true}
+
+	tc := &traffic.Config{Targets: targets}
+	spec, err := makeIngressSpec(ctx, r, nil /*tls*/, tc, tc.BuildRollout())
+	if err != nil {
+		t.Fatal("makeIngressSpec() =", err)
+	}
+	for _, rule := range spec.Rules {
+		splits := rule.HTTP.Paths[0].Splits
+		if got, want := splits[0].ServicePort, intstr.FromInt(servingnetworking.ServiceHTTPSPort); got != want {
+			t.Errorf("Revision backend port = %v, want: %v", got, want)
+		}
+		if got, want := splits[1].ServicePort, intstr.FromInt(8080); got != want {
+			t.Errorf("External backend port = %v, want: %v", got, want)
+		}
+	}
+}
+
+func TestMakeIngressSpecCORS(t *testing.T) {
+	targets := map[string]traffic.RevisionTargets{
+		traffic.DefaultTarget: {{
+			TrafficTarget: v1.TrafficTarget{
+				ConfigurationName: "config",
+				RevisionName:      "revision-beluga",
+				Percent:           ptr.Int64(70),
+			},
+		}, {
+			TrafficTarget: v1.TrafficTarget{
+				Percent:  ptr.Int64(30),
+				External: &v1.ExternalTarget{ServiceName: "legacy", Port: 8080},
+			},
+		}},
+	}
+	r := Route(ns, testRouteName, WithRouteUID("1234-5678"), WithURL,
+		WithRouteAnnotation(map[string]string{
+			serving.CORSAllowOriginsKey: "https://example.com",
+			serving.CORSMaxAgeKey:       "1m",
+		}))
+
+	tc := &traffic.Config{Targets: targets}
+	spec, err := makeIngressSpec(testContext(), r, nil /*tls*/, tc, tc.BuildRollout())
+	if err != nil {
+		t.Fatal("makeIngressSpec() =", err)
+	}
+	want := `{"origins":["https://example.com"],"maxAge":60}`
+	for _, rule := range spec.Rules {
+		splits := rule.HTTP.Paths[0].Splits
+		if got := splits[0].AppendHeaders[pkghttp.CORSHeaderName]; got != want {
+			t.Errorf("Revision backend CORS policy = %s, want: %s", got, want)
+		}
+		if got, ok := splits[1].AppendHeaders[pkghttp.CORSHeaderName]; ok {
+			t.Errorf("External backend CORS policy = %s, want none", got)
+		}
+	}
+}
+
+func TestMakeIngressSpecMirror(t *testing.T) {
+	targets := map[string]traffic.RevisionTargets{
+		traffic.DefaultTarget: {{
+			TrafficTarget: v1.TrafficTarget{
+				ConfigurationName: "config",
+				RevisionName:      "revision-beluga",
+				Percent:           ptr.Int64(90),
+			},
+		}, {
+			TrafficTarget: v1.TrafficTarget{
+				ConfigurationName: "config",
+				RevisionName:      "revision-shadow",
+				Percent:           ptr.Int64(10),
+			},
+		}},
+	}
+	r := Route(ns, testRouteName, WithRouteUID("1234-5678"), WithURL,
+		WithRouteAnnotation(map[string]string{
+			serving.MirrorRevisionKey: "revision-shadow",
+			serving.MirrorPercentKey:  "5",
+		}))
+
+	tc := &traffic.Config{Targets: targets}
+	spec, err := makeIngressSpec(testContext(), r, nil /*tls*/, tc, tc.BuildRollout())
+	if err != nil {
+		t.Fatal("makeIngressSpec() =", err)
+	}
+	want := `{"revision":"revision-shadow","percent":5}`
+	for _, rule := range spec.Rules {
+		splits := rule.HTTP.Paths[0].Splits
+		if got := splits[0].AppendHeaders[queue.MirrorHeaderName]; got != want {
+			t.Errorf("Revision backend mirror policy = %s, want: %s", got, want)
+		}
+		// The traffic of the shadow revision itself isn't mirrored.
+		if got, ok := splits[1].AppendHeaders[queue.MirrorHeaderName]; ok {
+			t.Errorf("Shadow revision backend mirror policy = %s, want none", got)
+		}
+	}
+}
+
+func TestMakeIngressSpecTagResponseHeaders(t *testing.T) {
+	targets := map[string]traffic.RevisionTargets{
+		traffic.DefaultTarget: {{
+			TrafficTarget: v1.TrafficTarget{
+				ConfigurationName: "config",
+				RevisionName:      "v2",
+				Percent:           ptr.Int64(100),
+			},
+		}},
+		"v1": {{
+			TrafficTarget: v1.TrafficTarget{
+				ConfigurationName: "config",
+				RevisionName:      "v1",
+				Percent:           ptr.Int64(100),
+			},
+		}},
+	}
+	r := Route(ns, testRouteName, WithURL, WithRouteAnnotation(map[string]string{
+		serving.TagResponseHeadersKey: `{"v1": {"set": {"X-Robots-Tag": "noindex"}}}`,
+	}))
+	ctx := testContext()
+	config.FromContext(ctx).Features.TagHeaderBasedRouting = apicfg.Enabled
+
+	tc := &traffic.Config{Targets: targets}
+	spec, err := makeIngressSpec(ctx, r, nil /*tls*/, tc, tc.BuildRollout())
+	if err != nil {
+		t.Fatal("makeIngressSpec() =", err)
+	}
+	want := `{"set":{"X-Robots-Tag":"noindex"}}`
+	for _, rule := range spec.Rules {
+		for _, path := range rule.HTTP.Paths {
+			split := path.Splits[0]
+			got := split.AppendHeaders[queue.ResponseHeaderRulesHeaderName]
+			if rev := split.AppendHeaders["Knative-Serving-Revision"]; rev == "v1" && got != want {
+				t.Errorf("Rules of %v = %s, want: %s", rule.Hosts, got, want)
+			} else if rev == "v2" && got != "" {
+				t.Errorf("Rules of %v = %s, want none", rule.Hosts, got)
+			}
+		}
+	}
+}
+
 func TestMakeIngressWithTLS(t *testing.T) {
 	targets := map[string]traffic.RevisionTargets{}
 	ingressClass := "foo-ingress"
@@ -1168,6 +1548,7 @@ func TestMakeIngressACMEChallenges(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "v2",
 						"Knative-Serving-Namespace": "test-ns",
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}}},
@@ -1198,6 +1579,7 @@ func TestMakeIngressACMEChallenges(t *testing.T) {
 					AppendHeaders: map[string]string{
 						"Knative-Serving-Revision":  "v2",
 						"Knative-Serving-Namespace": "test-ns",
+						"Knative-Serving-Route":     testRouteName,
 					},
 				}},
 			}}},
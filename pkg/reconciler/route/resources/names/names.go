@@ -17,6 +17,9 @@ limitations under the License.
 package names
 
 import (
+	"fmt"
+	"hash/crc32"
+
 	"knative.dev/pkg/kmeta"
 	"knative.dev/pkg/network"
 )
@@ -37,6 +40,12 @@ func Ingress(route kmeta.Accessor) string {
 	return kmeta.ChildName(route.GetName(), "")
 }
 
+// ExternalService returns the name of the ExternalName Service routing the
+// traffic of the named Route to an external backend host.
+func ExternalService(route, host string) string {
+	return kmeta.ChildName(route+"-external-", fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(host))))
+}
+
 // Certificate returns the name for the Certificate
 // child resource for the given Route.
 func Certificate(route kmeta.Accessor) string {
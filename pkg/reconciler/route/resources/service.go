@@ -19,6 +19,8 @@ package resources
 import (
 	"context"
 	"errors"
+	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -27,10 +29,12 @@ import (
 	network "knative.dev/networking/pkg"
 	"knative.dev/networking/pkg/apis/networking"
 	netv1alpha1 "knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/apis"
 	"knative.dev/pkg/kmeta"
 	"knative.dev/serving/pkg/apis/serving"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 	"knative.dev/serving/pkg/reconciler/route/domains"
+	"knative.dev/serving/pkg/reconciler/route/resources/names"
 )
 
 type ServicePair struct {
@@ -158,6 +162,56 @@ func MakeK8sService(ctx context.Context, route *v1.Route, tagName string, ingres
 	return pair, nil
 }
 
+// externalBackend returns the name and the port of the Service serving the
+// external traffic target of the named Route.
+func externalBackend(route string, et *v1.ExternalTarget) (string, int) {
+	if et.URL != nil {
+		return names.ExternalService(route, et.URL.Host), externalPort(et.URL)
+	}
+	if et.Port != 0 {
+		return et.ServiceName, int(et.Port)
+	}
+	return et.ServiceName, 80
+}
+
+// MakeExternalService creates the ExternalName Service routing to the
+// external backend at the given URL. It's owned by the provided v1.Route.
+// The external name is absolute, so it's never resolved relative to the
+// search domains of the pods, i.e. to a Service of the cluster.
+func MakeExternalService(route *v1.Route, url *apis.URL) *corev1.Service {
+	port := externalPort(url)
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      names.ExternalService(route.Name, url.Host),
+			Namespace: route.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*kmeta.NewControllerRef(route),
+			},
+			Labels: map[string]string{
+				serving.ExternalRouteLabelKey: route.Name,
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:            corev1.ServiceTypeExternalName,
+			ExternalName:    strings.TrimSuffix(url.URL().Hostname(), ".") + ".",
+			SessionAffinity: corev1.ServiceAffinityNone,
+			Ports: []corev1.ServicePort{{
+				Name:       networking.ServicePortNameHTTP1,
+				Port:       int32(port),
+				TargetPort: intstr.FromInt(port),
+			}},
+		},
+	}
+}
+
+// externalPort returns the port of the external backend URL, 80 by default.
+func externalPort(url *apis.URL) int {
+	if port, err := strconv.Atoi(url.URL().Port()); err == nil {
+		return port
+	}
+	return 80
+}
+
 func makeServiceObjectMeta(hostname string, route *v1.Route) metav1.ObjectMeta {
 	svcLabels := map[string]string{
 		serving.RouteLabelKey: route.Name,
@@ -28,12 +28,14 @@ import (
 	network "knative.dev/networking/pkg"
 	"knative.dev/networking/pkg/apis/networking"
 	netv1alpha1 "knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/apis"
 	"knative.dev/pkg/kmeta"
 	pkgnet "knative.dev/pkg/network"
 	apiConfig "knative.dev/serving/pkg/apis/config"
 	"knative.dev/serving/pkg/apis/serving"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 	"knative.dev/serving/pkg/reconciler/route/config"
+	"knative.dev/serving/pkg/reconciler/route/resources/names"
 	"knative.dev/serving/pkg/reconciler/route/traffic"
 
 	. "knative.dev/serving/pkg/testing/v1"
@@ -405,6 +407,34 @@ func TestMakePlaceholderService(t *testing.T) {
 	}
 }
 
+func TestMakeExternalService(t *testing.T) {
+	route := Route("test-ns", "test-route")
+	got := MakeExternalService(route, &apis.URL{Scheme: "http", Host: "legacy.example.com:8080"})
+
+	if got, want := got.Name, names.ExternalService("test-route", "legacy.example.com:8080"); got != want {
+		t.Errorf("Name = %q, want: %q", got, want)
+	}
+	if !metav1.IsControlledBy(got, route) {
+		t.Error("The Service is not owned by the Route")
+	}
+	if diff := cmp.Diff(map[string]string{serving.ExternalRouteLabelKey: "test-route"}, got.Labels); diff != "" {
+		t.Error("Unexpected Labels (-want +got):", diff)
+	}
+	want := corev1.ServiceSpec{
+		Type:            corev1.ServiceTypeExternalName,
+		ExternalName:    "legacy.example.com.",
+		SessionAffinity: corev1.ServiceAffinityNone,
+		Ports: []corev1.ServicePort{{
+			Name:       networking.ServicePortNameHTTP1,
+			Port:       8080,
+			TargetPort: intstr.FromInt(8080),
+		}},
+	}
+	if diff := cmp.Diff(want, got.Spec); diff != "" {
+		t.Error("Unexpected ServiceSpec (-want +got):", diff)
+	}
+}
+
 func testConfig() *config.Config {
 	return &config.Config{
 		Domain: &config.Domain{
@@ -0,0 +1,204 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	"knative.dev/serving/pkg/metrics"
+	"knative.dev/serving/pkg/networking"
+	"knative.dev/serving/pkg/reconciler/route/traffic"
+	"knative.dev/serving/pkg/reconciler/serverlessservice/resources/names"
+)
+
+const (
+	// rolloutGuardMinRequests is the number of requests a revision must have
+	// served before its error rate and latency are judged.
+	rolloutGuardMinRequests = 10
+
+	// rolloutGuardMaxPods is the number of pods of a revision scraped for
+	// its request metrics.
+	rolloutGuardMaxPods = 10
+
+	requestCountMetric     = "revision_request_count"
+	requestLatenciesMetric = "revision_request_latencies"
+)
+
+var rolloutGuardClient = &http.Client{Timeout: 3 * time.Second}
+
+// requestStats are the request metrics of a revision, accumulated since its
+// pods started.
+type requestStats struct {
+	requests float64
+	errors   float64
+	// latencySum is the sum of the request latencies in milliseconds of the
+	// latencyCount requests.
+	latencySum   float64
+	latencyCount float64
+}
+
+// breach returns why the stats breach the error rate or latency threshold,
+// or an empty string if they don't. Thresholds of zero aren't checked.
+func (s requestStats) breach(maxErrorRate float64, maxLatency time.Duration) string {
+	if s.requests < rolloutGuardMinRequests {
+		return ""
+	}
+	if rate := s.errors / s.requests; maxErrorRate > 0 && rate > maxErrorRate {
+		return fmt.Sprintf("error rate %.2f%% exceeds %.2f%%", rate*100, maxErrorRate*100)
+	}
+	if maxLatency > 0 && s.latencyCount > 0 {
+		latency := time.Duration(s.latencySum / s.latencyCount * float64(time.Millisecond))
+		if latency > maxLatency {
+			return fmt.Sprintf("average latency %v exceeds %v", latency.Round(time.Millisecond), maxLatency)
+		}
+	}
+	return ""
+}
+
+// guardRollout rolls back the rollouts in progress whose newest revision
+// breaches the error rate or latency threshold of the route.
+func (c *Reconciler) guardRollout(ctx context.Context, r *v1.Route, ro *traffic.Rollout) {
+	maxErrorRate, maxLatency := r.RolloutGuard()
+	if maxErrorRate == 0 && maxLatency == 0 {
+		return
+	}
+	logger := logging.FromContext(ctx)
+	for _, cr := range ro.Configurations {
+		if len(cr.Revisions) < 2 {
+			continue
+		}
+		name := cr.Revisions[len(cr.Revisions)-1].RevisionName
+		stats, err := c.requestStats(ctx, r.Namespace, name)
+		if err != nil {
+			logger.Warnw("Failed to scrape the request metrics of Revision "+name, zap.Error(err))
+			continue
+		}
+		if reason := stats.breach(maxErrorRate, maxLatency); reason != "" {
+			logger.Infof("Rolling back the rollout of Revision %s: %s", name, reason)
+			cr.RollBack(reason)
+			controller.GetEventRecorder(ctx).Eventf(r, corev1.EventTypeWarning, "RolloutRolledBack",
+				"Rolled back the rollout of Revision %q: %s", name, reason)
+		}
+	}
+}
+
+// markRolloutHealth reflects the rollbacks of the rollout in the
+// RolloutHealthy condition of the route, if it has a rollout guard.
+func markRolloutHealth(r *v1.Route, ro *traffic.Rollout) {
+	if maxErrorRate, maxLatency := r.RolloutGuard(); maxErrorRate == 0 && maxLatency == 0 {
+		return
+	}
+	for _, cr := range ro.Configurations {
+		if cr.FailedRevisionName != "" {
+			r.Status.MarkRolloutRolledBack(cr.FailedRevisionName, cr.FailureReason)
+			return
+		}
+	}
+	r.Status.MarkRolloutHealthy()
+}
+
+// scrapeRequestStats returns the request stats of the revision, summed over
+// the ready pods backing its private service.
+func scrapeRequestStats(endpointsLister corev1listers.EndpointsLister) func(context.Context, string, string) (requestStats, error) {
+	return func(ctx context.Context, namespace, name string) (requestStats, error) {
+		var stats requestStats
+		eps, err := endpointsLister.Endpoints(namespace).Get(names.PrivateService(name))
+		if err != nil {
+			return stats, err
+		}
+		pods := 0
+		for _, ss := range eps.Subsets {
+			for _, addr := range ss.Addresses {
+				if pods == rolloutGuardMaxPods {
+					return stats, nil
+				}
+				pods++
+				if err := scrapePodRequestStats(ctx, addr.IP, &stats); err != nil {
+					return stats, err
+				}
+			}
+		}
+		return stats, nil
+	}
+}
+
+// scrapePodRequestStats adds the request stats exposed by the queue-proxy of
+// the pod at ip to stats.
+func scrapePodRequestStats(ctx context.Context, ip string, stats *requestStats) error {
+	url := "http://" + net.JoinHostPort(ip, strconv.Itoa(networking.UserQueueMetricsPort)) + "/metrics"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", string(expfmt.FmtText))
+	resp, err := rolloutGuardClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("GET request for URL %q returned HTTP status %v", url, resp.StatusCode)
+	}
+	return parseRequestStats(expfmt.NewDecoder(resp.Body, expfmt.ResponseFormat(resp.Header)), stats)
+}
+
+// parseRequestStats adds the request counts and latencies decoded by dec
+// to stats.
+func parseRequestStats(dec expfmt.Decoder, stats *requestStats) error {
+	for {
+		var mf dto.MetricFamily
+		if err := dec.Decode(&mf); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("decoding the metrics failed: %w", err)
+		}
+		switch mf.GetName() {
+		case requestCountMetric:
+			for _, m := range mf.GetMetric() {
+				v := m.GetCounter().GetValue()
+				stats.requests += v
+				for _, l := range m.GetLabel() {
+					if l.GetName() == metrics.LabelResponseCodeClass && l.GetValue() == "5xx" {
+						stats.errors += v
+					}
+				}
+			}
+		case requestLatenciesMetric:
+			for _, m := range mf.GetMetric() {
+				stats.latencySum += m.GetHistogram().GetSampleSum()
+				stats.latencyCount += float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"knative.dev/pkg/controller"
+	"knative.dev/serving/pkg/apis/serving"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	"knative.dev/serving/pkg/reconciler/route/traffic"
+
+	. "knative.dev/pkg/logging/testing"
+)
+
+func TestRequestStatsBreach(t *testing.T) {
+	tests := []struct {
+		name         string
+		stats        requestStats
+		maxErrorRate float64
+		maxLatency   time.Duration
+		want         string
+	}{{
+		name:         "too few requests",
+		stats:        requestStats{requests: 5, errors: 5},
+		maxErrorRate: 0.1,
+	}, {
+		name:         "healthy",
+		stats:        requestStats{requests: 100, errors: 1, latencySum: 5000, latencyCount: 100},
+		maxErrorRate: 0.05,
+		maxLatency:   100 * time.Millisecond,
+	}, {
+		name:         "error rate",
+		stats:        requestStats{requests: 100, errors: 10},
+		maxErrorRate: 0.05,
+		want:         "error rate 10.00% exceeds 5.00%",
+	}, {
+		name:       "latency",
+		stats:      requestStats{requests: 100, latencySum: 30000, latencyCount: 100},
+		maxLatency: 100 * time.Millisecond,
+		want:       "average latency 300ms exceeds 100ms",
+	}, {
+		name:  "no thresholds",
+		stats: requestStats{requests: 100, errors: 100, latencySum: 30000, latencyCount: 100},
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.stats.breach(tc.maxErrorRate, tc.maxLatency); got != tc.want {
+				t.Errorf("breach = %q, want: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseRequestStats(t *testing.T) {
+	const text = `# TYPE revision_request_count counter
+revision_request_count{response_code="200",response_code_class="2xx"} 90
+revision_request_count{response_code="503",response_code_class="5xx"} 10
+# TYPE revision_request_latencies histogram
+revision_request_latencies_bucket{response_code_class="2xx",le="100"} 90
+revision_request_latencies_bucket{response_code_class="2xx",le="+Inf"} 100
+revision_request_latencies_sum{response_code_class="2xx"} 2500
+revision_request_latencies_count{response_code_class="2xx"} 100
+# TYPE revision_queue_depth gauge
+revision_queue_depth 3
+`
+	var got requestStats
+	if err := parseRequestStats(expfmt.NewDecoder(strings.NewReader(text), expfmt.FmtText), &got); err != nil {
+		t.Fatal("parseRequestStats() =", err)
+	}
+	if want := (requestStats{requests: 100, errors: 10, latencySum: 2500, latencyCount: 100}); got != want {
+		t.Errorf("parseRequestStats() = %+v, want: %+v", got, want)
+	}
+}
+
+func TestGuardRollout(t *testing.T) {
+	route := &v1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "guarded",
+			Annotations: map[string]string{
+				serving.RolloutMaxErrorRateKey: "0.05",
+			},
+		},
+	}
+	ro := &traffic.Rollout{
+		Configurations: []*traffic.ConfigurationRollout{{
+			ConfigurationName: "config",
+			Percent:           100,
+			Revisions: []traffic.RevisionRollout{{
+				RevisionName: "config-00001",
+				Percent:      90,
+			}, {
+				RevisionName: "config-00002",
+				Percent:      10,
+			}},
+		}},
+	}
+	recorder := record.NewFakeRecorder(1)
+	ctx := controller.WithEventRecorder(TestContextWithLogger(t), recorder)
+	c := &Reconciler{
+		requestStats: func(_ context.Context, namespace, name string) (requestStats, error) {
+			if namespace != "default" || name != "config-00002" {
+				t.Errorf("requestStats(%s, %s), want the newest revision of the rollout", namespace, name)
+			}
+			return requestStats{requests: 20, errors: 5}, nil
+		},
+	}
+
+	c.guardRollout(ctx, route, ro)
+	cfg := ro.Configurations[0]
+	if cfg.FailedRevisionName != "config-00002" {
+		t.Errorf("FailedRevisionName = %q, want: config-00002", cfg.FailedRevisionName)
+	}
+	if len(cfg.Revisions) != 1 || cfg.Revisions[0].RevisionName != "config-00001" || cfg.Revisions[0].Percent != 100 {
+		t.Errorf("Revisions = %v, want all the traffic on config-00001", cfg.Revisions)
+	}
+	select {
+	case ev := <-recorder.Events:
+		if want := `Warning RolloutRolledBack Rolled back the rollout of Revision "config-00002": error rate 25.00% exceeds 5.00%`; ev != want {
+			t.Errorf("Event = %q, want: %q", ev, want)
+		}
+	default:
+		t.Error("No event was recorded for the rollback")
+	}
+
+	markRolloutHealth(route, ro)
+	if cond := route.Status.GetCondition(v1.RouteConditionRolloutHealthy); cond == nil || !cond.IsFalse() {
+		t.Errorf("RolloutHealthy = %v, want False", cond)
+	}
+}
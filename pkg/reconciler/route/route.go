@@ -24,6 +24,7 @@ import (
 	"strings"
 	"time"
 
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	kubelabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/clock"
@@ -73,6 +74,10 @@ type Reconciler struct {
 
 	clock        clock.PassiveClock
 	enqueueAfter func(interface{}, time.Duration)
+
+	// requestStats returns the request stats of a revision, to guard the
+	// rollouts of the routes.
+	requestStats func(ctx context.Context, namespace, name string) (requestStats, error)
 }
 
 // Check that our Reconciler implements routereconciler.Interface
@@ -132,6 +137,9 @@ func (c *Reconciler) ReconcileKind(ctx context.Context, r *v1.Route) pkgreconcil
 	if err != nil {
 		return err
 	}
+	if err := c.reconcileExternalServices(ctx, r, traffic.Targets); err != nil {
+		return err
+	}
 
 	tls, acmeChallenges, err := c.tls(ctx, r.Status.URL.Host, r, traffic)
 	if err != nil {
@@ -144,6 +152,8 @@ func (c *Reconciler) ReconcileKind(ctx context.Context, r *v1.Route) pkgreconcil
 	}
 
 	roInProgress := !effectiveRO.Done()
+	r.Status.Rollouts = effectiveRO.Status()
+	markRolloutHealth(r, effectiveRO)
 	if ingress.GetObjectMeta().GetGeneration() != ingress.Status.ObservedGeneration {
 		r.Status.MarkIngressNotConfigured()
 	} else if !roInProgress {
@@ -270,7 +280,7 @@ func (c *Reconciler) tls(ctx context.Context, host string, r *v1.Route, traffic
 // mark AllTrafficAssigned = False, with a message referring to one of the missing target.
 func (c *Reconciler) configureTraffic(ctx context.Context, r *v1.Route) (*traffic.Config, error) {
 	logger := logging.FromContext(ctx)
-	t, trafficErr := traffic.BuildTrafficConfiguration(c.configurationLister, c.revisionLister, r)
+	t, trafficErr := traffic.BuildTrafficConfigurationAt(c.configurationLister, c.revisionLister, r, c.clock.Now())
 	if t == nil {
 		return nil, trafficErr
 	}
@@ -323,11 +333,23 @@ func (c *Reconciler) configureTraffic(ctx context.Context, r *v1.Route) (*traffi
 	if badTarget != nil && isTargetError {
 		logger.Info("Marking bad traffic target: ", badTarget)
 		badTarget.MarkBadTrafficTarget(&r.Status)
+		if t.ReadinessGateRequeue > 0 && !badTarget.IsFailure() {
+			// Check the readiness gate again once it times out.
+			c.enqueueAfter(r, t.ReadinessGateRequeue)
+		}
 
 		// Traffic targets aren't ready, no need to configure Route.
 		return nil, nil
 	}
 
+	if name, err := c.missingExternalService(r, t); err != nil {
+		return nil, err
+	} else if name != "" {
+		logger.Info("Marking missing external traffic target: ", name)
+		r.Status.MarkMissingTrafficTarget("Service", name)
+		return nil, nil
+	}
+
 	logger.Info("All referred targets are routable, marking AllTrafficAssigned with traffic information.")
 
 	// Pass empty rollout here. We'll recompute this if there is a rollout in progress.
@@ -341,6 +363,32 @@ func (c *Reconciler) configureTraffic(ctx context.Context, r *v1.Route) (*traffi
 	return t, nil
 }
 
+// missingExternalService returns the name of the first Kubernetes Service
+// referenced by an external traffic target that doesn't exist, tracking it so
+// that the Route is reconciled again once it's created.
+func (c *Reconciler) missingExternalService(r *v1.Route, t *traffic.Config) (string, error) {
+	for _, tt := range t.Targets[traffic.DefaultTarget] {
+		if tt.External == nil || tt.External.ServiceName == "" {
+			continue
+		}
+		name := tt.External.ServiceName
+		if err := c.tracker.TrackReference(tracker.Reference{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Namespace:  r.Namespace,
+			Name:       name,
+		}, r); err != nil {
+			return "", err
+		}
+		if _, err := c.serviceLister.Services(r.Namespace).Get(name); apierrs.IsNotFound(err) {
+			return name, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
 func (c *Reconciler) updateRouteStatusURL(ctx context.Context, route *v1.Route, visibility map[string]netv1alpha1.IngressVisibility) error {
 	isClusterLocal := visibility[traffic.DefaultTarget] == netv1alpha1.IngressVisibilityClusterLocal
 
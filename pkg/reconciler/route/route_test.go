@@ -236,6 +236,7 @@ func TestCreateRouteForOneReserveRevision(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  "test-rev",
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -257,6 +258,7 @@ func TestCreateRouteForOneReserveRevision(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  "test-rev",
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -357,6 +359,7 @@ func TestCreateRouteWithMultipleTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}, {
 						IngressBackend: v1alpha1.IngressBackend{
@@ -368,6 +371,7 @@ func TestCreateRouteWithMultipleTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -389,6 +393,7 @@ func TestCreateRouteWithMultipleTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}, {
 						IngressBackend: v1alpha1.IngressBackend{
@@ -400,6 +405,7 @@ func TestCreateRouteWithMultipleTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -475,6 +481,7 @@ func TestCreateRouteWithOneTargetReserve(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}, {
 						IngressBackend: v1alpha1.IngressBackend{
@@ -486,6 +493,7 @@ func TestCreateRouteWithOneTargetReserve(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -507,6 +515,7 @@ func TestCreateRouteWithOneTargetReserve(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}, {
 						IngressBackend: v1alpha1.IngressBackend{
@@ -518,6 +527,7 @@ func TestCreateRouteWithOneTargetReserve(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -607,6 +617,7 @@ func TestCreateRouteWithDuplicateTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}, {
 						IngressBackend: v1alpha1.IngressBackend{
@@ -618,6 +629,7 @@ func TestCreateRouteWithDuplicateTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -639,6 +651,7 @@ func TestCreateRouteWithDuplicateTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}, {
 						IngressBackend: v1alpha1.IngressBackend{
@@ -650,6 +663,7 @@ func TestCreateRouteWithDuplicateTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -673,6 +687,7 @@ func TestCreateRouteWithDuplicateTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -694,6 +709,7 @@ func TestCreateRouteWithDuplicateTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -717,6 +733,7 @@ func TestCreateRouteWithDuplicateTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -738,6 +755,7 @@ func TestCreateRouteWithDuplicateTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -813,6 +831,7 @@ func TestCreateRouteWithNamedTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}, {
 						IngressBackend: v1alpha1.IngressBackend{
@@ -824,6 +843,7 @@ func TestCreateRouteWithNamedTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -843,6 +863,7 @@ func TestCreateRouteWithNamedTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}, {
 						IngressBackend: v1alpha1.IngressBackend{
@@ -854,6 +875,7 @@ func TestCreateRouteWithNamedTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -877,6 +899,7 @@ func TestCreateRouteWithNamedTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -896,6 +919,7 @@ func TestCreateRouteWithNamedTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -919,6 +943,7 @@ func TestCreateRouteWithNamedTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -938,6 +963,7 @@ func TestCreateRouteWithNamedTargets(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -1027,6 +1053,7 @@ func TestCreateRouteWithNamedTargetsAndTagBasedRouting(t *testing.T) {
 							Percent: 100,
 							AppendHeaders: map[string]string{
 								"Knative-Serving-Namespace": "test",
+								"Knative-Serving-Route":     "test-route",
 								"Knative-Serving-Revision":  "p-deadbeef",
 							},
 						},
@@ -1047,6 +1074,7 @@ func TestCreateRouteWithNamedTargetsAndTagBasedRouting(t *testing.T) {
 							Percent: 100,
 							AppendHeaders: map[string]string{
 								"Knative-Serving-Namespace": "test",
+								"Knative-Serving-Route":     "test-route",
 								"Knative-Serving-Revision":  "test-rev",
 							},
 						},
@@ -1065,6 +1093,7 @@ func TestCreateRouteWithNamedTargetsAndTagBasedRouting(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}, {
 						IngressBackend: v1alpha1.IngressBackend{
@@ -1076,6 +1105,7 @@ func TestCreateRouteWithNamedTargetsAndTagBasedRouting(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -1100,6 +1130,7 @@ func TestCreateRouteWithNamedTargetsAndTagBasedRouting(t *testing.T) {
 							Percent: 100,
 							AppendHeaders: map[string]string{
 								"Knative-Serving-Namespace": "test",
+								"Knative-Serving-Route":     "test-route",
 								"Knative-Serving-Revision":  "p-deadbeef",
 							},
 						},
@@ -1120,6 +1151,7 @@ func TestCreateRouteWithNamedTargetsAndTagBasedRouting(t *testing.T) {
 							Percent: 100,
 							AppendHeaders: map[string]string{
 								"Knative-Serving-Namespace": "test",
+								"Knative-Serving-Route":     "test-route",
 								"Knative-Serving-Revision":  "test-rev",
 							},
 						},
@@ -1138,6 +1170,7 @@ func TestCreateRouteWithNamedTargetsAndTagBasedRouting(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}, {
 						IngressBackend: v1alpha1.IngressBackend{
@@ -1149,6 +1182,7 @@ func TestCreateRouteWithNamedTargetsAndTagBasedRouting(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 				}},
@@ -1172,6 +1206,7 @@ func TestCreateRouteWithNamedTargetsAndTagBasedRouting(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 					AppendHeaders: map[string]string{
@@ -1196,6 +1231,7 @@ func TestCreateRouteWithNamedTargetsAndTagBasedRouting(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  cfgrev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 					AppendHeaders: map[string]string{
@@ -1222,6 +1258,7 @@ func TestCreateRouteWithNamedTargetsAndTagBasedRouting(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 					AppendHeaders: map[string]string{
@@ -1244,6 +1281,7 @@ func TestCreateRouteWithNamedTargetsAndTagBasedRouting(t *testing.T) {
 						AppendHeaders: map[string]string{
 							"Knative-Serving-Revision":  rev.Name,
 							"Knative-Serving-Namespace": testNamespace,
+							"Knative-Serving-Route":     "test-route",
 						},
 					}},
 					AppendHeaders: map[string]string{
@@ -486,7 +486,16 @@ func TestReconcile(t *testing.T) {
 						RevisionName:   "config-00001",
 						Percent:        ptr.Int64(1),
 						LatestRevision: ptr.Bool(true),
-					})),
+					}), WithStatusRollouts(v1.RolloutStatus{
+					ConfigurationName: "config",
+					RevisionName:      "config-00001",
+					Percent:           1,
+					TargetPercent:     100,
+					Steps:             33, // 99% in steps of 3%.
+					NextStepTime:      &metav1.Time{Time: fakeCurTime.Add(3 * time.Second)},
+					// 32 more steps of 3s after the next one.
+					EstimatedCompletionTime: &metav1.Time{Time: fakeCurTime.Add(99 * time.Second)},
+				})),
 		}},
 		WantEvents: []string{
 			Eventf(corev1.EventTypeNormal, "Created", "Created placeholder service %q", "becomes-ready"),
@@ -933,7 +942,12 @@ func TestReconcile(t *testing.T) {
 						RevisionName:   "config-00002",
 						Percent:        ptr.Int64(1),
 						LatestRevision: ptr.Bool(true),
-					})),
+					}), WithStatusRollouts(v1.RolloutStatus{
+					ConfigurationName: "config",
+					RevisionName:      "config-00002",
+					Percent:           1,
+					TargetPercent:     100,
+				})),
 		}},
 		Key: "default/new-latest-ready",
 	}, {
@@ -18,6 +18,7 @@ package traffic
 
 import (
 	"fmt"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
@@ -108,6 +109,28 @@ func (e *unreadyRevisionError) IsFailure() bool {
 	return e.isFailure
 }
 
+type readinessGateTimeoutError struct {
+	name    string        // Name of the Revision that isn't ready.
+	timeout time.Duration // The readiness gate timeout of the Revision.
+}
+
+var _ TargetError = (*readinessGateTimeoutError)(nil)
+
+// Error implements error.
+func (e *readinessGateTimeoutError) Error() string {
+	return fmt.Sprintf("Revision %q not ready within readiness gate timeout %v", e.name, e.timeout)
+}
+
+// MarkBadTrafficTarget implements TargetError.
+func (e *readinessGateTimeoutError) MarkBadTrafficTarget(rs *v1.RouteStatus) {
+	rs.MarkRevisionReadinessGateTimeout(e.name, e.timeout)
+}
+
+// IsFailure implements TargetError.
+func (e *readinessGateTimeoutError) IsFailure() bool {
+	return true
+}
+
 // errUnreadyConfiguration returns a TargetError for a Configuration that is not ready.
 func errUnreadyConfiguration(config *v1.Configuration) TargetError {
 	status := corev1.ConditionUnknown
@@ -132,6 +155,15 @@ func errUnreadyRevision(rev *v1.Revision) TargetError {
 	}
 }
 
+// errReadinessGateTimeout returns a TargetError for a Revision that didn't
+// become ready within its readiness gate timeout.
+func errReadinessGateTimeout(rev *v1.Revision, timeout time.Duration) TargetError {
+	return &readinessGateTimeoutError{
+		name:    rev.Name,
+		timeout: timeout,
+	}
+}
+
 // errMissingConfiguration returns a TargetError for a Configuration what does not exist.
 func errMissingConfiguration(name string) TargetError {
 	return &missingTargetError{
@@ -28,7 +28,9 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
 )
 
 // Rollout encapsulates the current rollout state of the system.
@@ -64,6 +66,12 @@ type ConfigurationRollout struct {
 
 	// StepParams describes rollout params for the configuration.
 	StepParams RolloutParams `json:"stepParams"`
+
+	// FailedRevisionName is the revision whose rollout was rolled back, for
+	// FailureReason. Its rollout isn't restarted until a newer revision is
+	// rolled out.
+	FailedRevisionName string `json:"failedRevisionName,omitempty"`
+	FailureReason      string `json:"failureReason,omitempty"`
 }
 
 // RolloutParams contains the timing and sizing parameters for the
@@ -122,6 +130,65 @@ func (cur *Rollout) Done() bool {
 	return true
 }
 
+// Status returns the progress of the configuration rollouts in progress.
+func (cur *Rollout) Status() []v1.RolloutStatus {
+	var ret []v1.RolloutStatus
+	for _, c := range cur.Configurations {
+		if c.done() {
+			continue
+		}
+		// The newest revision is the one being rolled out.
+		rev := c.Revisions[len(c.Revisions)-1]
+		rs := v1.RolloutStatus{
+			ConfigurationName: c.ConfigurationName,
+			Tag:               c.Tag,
+			RevisionName:      rev.RevisionName,
+			Percent:           int64(rev.Percent),
+			TargetPercent:     int64(c.Percent),
+		}
+		// The step parameters are only computed once the ingress is ready with
+		// the first 1% of the traffic moved to the revision.
+		if sp := c.StepParams; sp.StepSize > 0 {
+			rs.Step = int64(ceilDiv(rev.Percent-1, sp.StepSize))
+			rs.Steps = int64(ceilDiv(c.Percent-1, sp.StepSize))
+			if sp.NextStepTime > 0 {
+				next := time.Unix(0, sp.NextStepTime)
+				remaining := rs.Steps - rs.Step - 1
+				if remaining < 0 {
+					remaining = 0
+				}
+				rs.NextStepTime = &metav1.Time{Time: next}
+				rs.EstimatedCompletionTime = &metav1.Time{
+					Time: next.Add(time.Duration(remaining * sp.StepDuration)),
+				}
+			}
+		}
+		ret = append(ret, rs)
+	}
+	return ret
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+// RollBack reverts the rollout of the newest revision of the configuration,
+// routing all of its traffic back to the previous revision, and records the
+// revision as failed for the reason.
+func (cur *ConfigurationRollout) RollBack(reason string) {
+	n := len(cur.Revisions)
+	if n < 2 {
+		return
+	}
+	cur.FailedRevisionName = cur.Revisions[n-1].RevisionName
+	cur.FailureReason = reason
+	cur.Revisions = []RevisionRollout{{
+		RevisionName: cur.Revisions[n-2].RevisionName,
+		Percent:      cur.Percent,
+	}}
+	cur.StepParams = RolloutParams{}
+}
+
 // done returns true if there is no active rollout going on
 // for the configuration.
 func (cur *ConfigurationRollout) done() bool {
@@ -377,6 +444,15 @@ func stepConfig(goal, prev *ConfigurationRollout, nowTS int64, logger *zap.Sugar
 	if len(prev.Revisions) > 0 {
 		adjustPercentage(goal.Percent, prev, logger)
 	}
+	// The rollout of the desired revision was rolled back, so keep routing
+	// the traffic to the previous revision until a newer one is rolled out.
+	if len(prev.Revisions) > 0 && goal.Revisions[0].RevisionName == prev.FailedRevisionName {
+		logger.Debug("Rollout was rolled back for config: ", goal.ConfigurationName)
+		ret.Revisions = prev.Revisions
+		ret.FailedRevisionName = prev.FailedRevisionName
+		ret.FailureReason = prev.FailureReason
+		return ret
+	}
 	// goal will always have just one revision in the list – the current desired revision.
 	// If it matches the last revision of the previous rollout state (or there were no revisions)
 	// then no new rollout has begun for this configuration.
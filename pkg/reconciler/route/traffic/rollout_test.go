@@ -28,6 +28,8 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
 
 	. "knative.dev/pkg/logging/testing"
 )
@@ -1718,3 +1720,137 @@ func TestGetByTag(t *testing.T) {
 		t.Errorf(`RolloutsByTag("nyaful") mismatch: diff(-want,+got):\n%s`, cmp.Diff(want, got))
 	}
 }
+
+func TestRolloutStatus(t *testing.T) {
+	next := time.Unix(1600000000, 0)
+	ro := &Rollout{
+		Configurations: []*ConfigurationRollout{{
+			ConfigurationName: "done",
+			Percent:           100,
+			Revisions: []RevisionRollout{{
+				RevisionName: "done-1",
+				Percent:      100,
+			}},
+		}, {
+			ConfigurationName: "starting",
+			Percent:           50,
+			Revisions: []RevisionRollout{{
+				RevisionName: "starting-1",
+				Percent:      49,
+			}, {
+				RevisionName: "starting-2",
+				Percent:      1,
+			}},
+			StepParams: RolloutParams{
+				StartTime: next.UnixNano(),
+			},
+		}, {
+			ConfigurationName: "stepping",
+			Tag:               "canary",
+			Percent:           100,
+			Revisions: []RevisionRollout{{
+				RevisionName: "stepping-1",
+				Percent:      74,
+			}, {
+				RevisionName: "stepping-2",
+				Percent:      26,
+			}},
+			StepParams: RolloutParams{
+				StartTime:    next.Add(-2 * time.Minute).UnixNano(),
+				NextStepTime: next.UnixNano(),
+				StepDuration: int64(time.Minute),
+				StepSize:     25,
+			},
+		}},
+	}
+
+	want := []v1.RolloutStatus{{
+		ConfigurationName: "starting",
+		RevisionName:      "starting-2",
+		Percent:           1,
+		TargetPercent:     50,
+	}, {
+		ConfigurationName:       "stepping",
+		Tag:                     "canary",
+		RevisionName:            "stepping-2",
+		Percent:                 26,
+		TargetPercent:           100,
+		Step:                    1,
+		Steps:                   4,
+		NextStepTime:            &metav1.Time{Time: next},
+		EstimatedCompletionTime: &metav1.Time{Time: next.Add(2 * time.Minute)},
+	}}
+	if got := ro.Status(); !cmp.Equal(got, want) {
+		t.Error("Status mismatch (-want, +got):", cmp.Diff(want, got))
+	}
+
+	if got := (&Rollout{}).Status(); got != nil {
+		t.Errorf("Status() = %v, want nil without rollouts", got)
+	}
+}
+
+func TestRollBack(t *testing.T) {
+	const now = 2020
+	ctx := TestContextWithLogger(t)
+	prev := &Rollout{
+		Configurations: []*ConfigurationRollout{{
+			ConfigurationName: "keith",
+			Percent:           100,
+			Revisions: []RevisionRollout{{
+				RevisionName: "keith-1",
+				Percent:      80,
+			}, {
+				RevisionName: "keith-2",
+				Percent:      20,
+			}},
+			StepParams: RolloutParams{
+				StartTime:    2004,
+				NextStepTime: 2030,
+				StepDuration: 10,
+				StepSize:     20,
+			},
+		}},
+	}
+	prev.Configurations[0].RollBack("error rate 50.00% exceeds 5.00%")
+	want := &ConfigurationRollout{
+		ConfigurationName: "keith",
+		Percent:           100,
+		Revisions: []RevisionRollout{{
+			RevisionName: "keith-1",
+			Percent:      100,
+		}},
+		FailedRevisionName: "keith-2",
+		FailureReason:      "error rate 50.00% exceeds 5.00%",
+	}
+	if got := prev.Configurations[0]; !cmp.Equal(got, want) {
+		t.Error("RollBack mismatch (-want, +got):", cmp.Diff(want, got))
+	}
+
+	// The failed revision is still the desired one, so the rollback is kept.
+	cur := &Rollout{
+		Configurations: []*ConfigurationRollout{{
+			ConfigurationName: "keith",
+			Percent:           100,
+			Revisions: []RevisionRollout{{
+				RevisionName: "keith-2",
+				Percent:      100,
+			}},
+		}},
+	}
+	got, _ := cur.Step(ctx, prev, now)
+	if !cmp.Equal(got.Configurations[0], want) {
+		t.Error("Step mismatch (-want, +got):", cmp.Diff(want, got.Configurations[0]))
+	}
+
+	// A newer revision is rolled out from the last good one.
+	cur.Configurations[0].Revisions[0].RevisionName = "keith-3"
+	got, _ = cur.Step(ctx, prev, now)
+	gotCfg := got.Configurations[0]
+	if gotCfg.FailedRevisionName != "" {
+		t.Errorf("FailedRevisionName = %q, want empty", gotCfg.FailedRevisionName)
+	}
+	if len(gotCfg.Revisions) != 2 || gotCfg.Revisions[0].RevisionName != "keith-1" ||
+		gotCfg.Revisions[1].RevisionName != "keith-3" {
+		t.Errorf("Revisions = %v, want a rollout from keith-1 to keith-3", gotCfg.Revisions)
+	}
+}
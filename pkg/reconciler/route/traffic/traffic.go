@@ -19,6 +19,8 @@ package traffic
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
@@ -45,6 +47,18 @@ type RevisionTarget struct {
 	Protocol net.ProtocolType
 }
 
+// backendKey returns the key identifying the backend of the target, the
+// Revision name for Knative targets.
+func (rt *RevisionTarget) backendKey() string {
+	if et := rt.External; et != nil {
+		if et.URL != nil {
+			return "external:" + et.URL.String()
+		}
+		return fmt.Sprintf("external:%s:%d", et.ServiceName, et.Port)
+	}
+	return rt.RevisionName
+}
+
 // RevisionTargets is a collection of revision targets.
 type RevisionTargets []RevisionTarget
 
@@ -71,6 +85,11 @@ type Config struct {
 	// MissingTargets are references to Configurations or Revisions
 	// that are missing
 	MissingTargets []corev1.ObjectReference
+
+	// ReadinessGateRequeue is the time until the earliest readiness gate
+	// timeout of the referred Revisions that are not ready expires, or 0 if
+	// there is none.
+	ReadinessGateRequeue time.Duration
 }
 
 // BuildTrafficConfiguration consolidates and flattens the Route.Spec.Traffic to the Revision-level. It also provides a
@@ -80,7 +99,14 @@ type Config struct {
 // In the case that some target is missing, an error of type TargetError will be returned.
 func BuildTrafficConfiguration(configLister listers.ConfigurationLister, revLister listers.RevisionLister,
 	r *v1.Route) (*Config, error) {
-	return newBuilder(configLister, revLister, r).build()
+	return BuildTrafficConfigurationAt(configLister, revLister, r, time.Now())
+}
+
+// BuildTrafficConfigurationAt is like BuildTrafficConfiguration, but evaluates
+// the readiness gate timeouts of Revisions that are not ready at the given time.
+func BuildTrafficConfigurationAt(configLister listers.ConfigurationLister, revLister listers.RevisionLister,
+	r *v1.Route, now time.Time) (*Config, error) {
+	return newBuilder(configLister, revLister, r, now).build()
 }
 
 func rolloutConfig(cfgName string, ros []*ConfigurationRollout) *ConfigurationRollout {
@@ -137,6 +163,7 @@ func (cfg *Config) targetToStatus(ctx context.Context, r *v1.Route, tt *Revision
 			RevisionName:   rr.RevisionName,
 			Percent:        ptr.Int64(int64(rr.Percent)),
 			LatestRevision: tt.LatestRevision,
+			External:       tt.External,
 		}
 
 		if tt.Tag != "" {
@@ -185,6 +212,7 @@ type configBuilder struct {
 	configLister listers.ConfigurationNamespaceLister
 	revLister    listers.RevisionNamespaceLister
 	route        *v1.Route
+	now          time.Time
 
 	// targets is a grouping of traffic targets serving the same origin.
 	targets map[string]RevisionTargets
@@ -203,15 +231,20 @@ type configBuilder struct {
 
 	// TargetError are deferred until we got a complete list of all referred targets.
 	deferredTargetErr TargetError
+
+	// readinessGateRequeue is the time until the earliest pending readiness
+	// gate timeout expires.
+	readinessGateRequeue time.Duration
 }
 
 func newBuilder(
 	configLister listers.ConfigurationLister, revLister listers.RevisionLister,
-	r *v1.Route) *configBuilder {
+	r *v1.Route, now time.Time) *configBuilder {
 	return &configBuilder{
 		configLister:    configLister.Configurations(r.Namespace),
 		revLister:       revLister.Revisions(r.Namespace),
 		route:           r,
+		now:             now,
 		targets:         make(map[string]RevisionTargets, 1),
 		revisionTargets: make(RevisionTargets, 0, len(r.Spec.Traffic)),
 
@@ -315,7 +348,9 @@ func (cb *configBuilder) deferTargetError(err TargetError) {
 }
 
 func (cb *configBuilder) addTrafficTarget(tt *v1.TrafficTarget) (err error) {
-	if tt.RevisionName != "" {
+	if tt.External != nil {
+		cb.addExternalTarget(tt)
+	} else if tt.RevisionName != "" {
 		err = cb.addRevisionTarget(tt)
 	} else if tt.ConfigurationName != "" {
 		err = cb.addConfigurationTarget(tt)
@@ -370,13 +405,28 @@ func (cb *configBuilder) addConfigurationTarget(tt *v1.TrafficTarget) error {
 	return nil
 }
 
+// addExternalTarget adds a target routing to a backend outside of Knative,
+// which is always reached over HTTP/1.
+func (cb *configBuilder) addExternalTarget(tt *v1.TrafficTarget) {
+	ntt := tt.DeepCopy()
+	if ntt.LatestRevision == nil {
+		ntt.LatestRevision = ptr.Bool(false)
+	}
+	cb.addFlattenedTarget(RevisionTarget{
+		TrafficTarget: *ntt,
+		Protocol:      net.ProtocolHTTP1,
+	})
+}
+
 func (cb *configBuilder) addRevisionTarget(tt *v1.TrafficTarget) error {
 	rev, err := cb.getRevision(tt.RevisionName)
 	if err != nil {
 		return err
 	}
 	if !rev.IsReady() {
-		return errUnreadyRevision(rev)
+		if err := cb.readinessGate(rev); err != nil {
+			return err
+		}
 	}
 	ntt := tt.DeepCopy()
 	target := RevisionTarget{
@@ -393,11 +443,52 @@ func (cb *configBuilder) addRevisionTarget(tt *v1.TrafficTarget) error {
 	return nil
 }
 
+// readinessGate returns the TargetError for a Revision that is not ready, unless
+// its readiness gate timed out and traffic is configured to proceed to it anyway.
+// Until the timeout expires, the Revision is waited for as usual.
+func (cb *configBuilder) readinessGate(rev *v1.Revision) error {
+	targetErr := errUnreadyRevision(rev)
+	timeout, action := readinessGateConfig(cb.route, rev)
+	if timeout <= 0 || targetErr.IsFailure() {
+		return targetErr
+	}
+
+	// The Revision has been waited for since it last transitioned to not ready.
+	since := rev.CreationTimestamp.Time
+	if c := rev.Status.GetCondition(v1.RevisionConditionReady); c != nil && !c.LastTransitionTime.Inner.IsZero() {
+		since = c.LastTransitionTime.Inner.Time
+	}
+	if remaining := since.Add(timeout).Sub(cb.now); remaining > 0 {
+		if cb.readinessGateRequeue == 0 || remaining < cb.readinessGateRequeue {
+			cb.readinessGateRequeue = remaining
+		}
+		return targetErr
+	}
+	if action == serving.ReadinessGateTimeoutActionProceed {
+		return nil
+	}
+	return errReadinessGateTimeout(rev, timeout)
+}
+
+// readinessGateConfig returns the readiness gate timeout and action of the
+// Revision, which override the ones of the Route.
+func readinessGateConfig(r *v1.Route, rev *v1.Revision) (time.Duration, string) {
+	annotation := func(key string) string {
+		if v := rev.Annotations[key]; v != "" {
+			return v
+		}
+		return r.Annotations[key]
+	}
+	// WH should've declined all the invalid values for these annotations.
+	timeout, _ := time.ParseDuration(annotation(serving.ReadinessGateTimeoutKey))
+	return timeout, annotation(serving.ReadinessGateTimeoutActionKey)
+}
+
 // This find the exact revision+tag pair and if so, just adds the percentages.
 // This expects single digit lists, so just does an O(N) search.
 func mergeIfNecessary(rts RevisionTargets, rt RevisionTarget) RevisionTargets {
 	for i := range rts {
-		if rts[i].Tag == rt.Tag && rts[i].RevisionName == rt.RevisionName &&
+		if rts[i].Tag == rt.Tag && rts[i].backendKey() == rt.backendKey() &&
 			*rt.LatestRevision == *rts[i].LatestRevision {
 			rts[i].Percent = ptr.Int64(*rts[i].Percent + *rt.Percent)
 			return rts
@@ -430,6 +521,8 @@ func (cb *configBuilder) build() (*Config, error) {
 		Configurations:  cb.configurations,
 		Revisions:       cb.revisions,
 		MissingTargets:  cb.missingTargets,
+
+		ReadinessGateRequeue: cb.readinessGateRequeue,
 	}, cb.deferredTargetErr
 }
 
@@ -445,7 +538,7 @@ func consolidate(targets RevisionTargets) RevisionTargets {
 	byName := make(map[string]RevisionTarget)
 	var names []string
 	for _, tt := range targets {
-		name := tt.TrafficTarget.RevisionName
+		name := tt.backendKey()
 		cur, ok := byName[name]
 		if !ok {
 			byName[name] = tt
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/google/go-cmp/cmp"
@@ -31,6 +32,7 @@ import (
 
 	network "knative.dev/networking/pkg"
 	net "knative.dev/networking/pkg/apis/networking"
+	"knative.dev/pkg/apis"
 	"knative.dev/pkg/ptr"
 	"knative.dev/serving/pkg/apis/serving"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
@@ -998,6 +1000,79 @@ func TestBuildTrafficConfigurationTwoFixedRevisionsFromTwoConfigurations(t *test
 	}
 }
 
+// Splitting traffic between a fixed revision and an external backend.
+func TestBuildTrafficConfigurationExternal(t *testing.T) {
+	external := &v1.ExternalTarget{
+		URL: &apis.URL{Scheme: "http", Host: "legacy.example.com:8080"},
+	}
+	expectedTargets := []RevisionTarget{{
+		TrafficTarget: v1.TrafficTarget{
+			ConfigurationName: goodConfig.Name,
+			RevisionName:      goodOldRev.Name,
+			Percent:           ptr.Int64(60),
+			LatestRevision:    ptr.Bool(false),
+		},
+		Protocol: net.ProtocolHTTP1,
+	}, {
+		TrafficTarget: v1.TrafficTarget{
+			Percent:        ptr.Int64(40),
+			LatestRevision: ptr.Bool(false),
+			External:       external,
+		},
+		Protocol: net.ProtocolHTTP1,
+	}}
+	expected := &Config{
+		Targets: map[string]RevisionTargets{
+			DefaultTarget: expectedTargets,
+		},
+		revisionTargets: expectedTargets,
+		Configurations: map[string]*v1.Configuration{
+			goodConfig.Name: goodConfig,
+		},
+		Revisions: map[string]*v1.Revision{
+			goodOldRev.Name: goodOldRev,
+		},
+	}
+	route := testRouteWithTrafficTargets(WithSpecTraffic(v1.TrafficTarget{
+		RevisionName: goodOldRev.Name,
+		Percent:      ptr.Int64(60),
+	}, v1.TrafficTarget{
+		Percent:  ptr.Int64(30),
+		External: external,
+	}, v1.TrafficTarget{
+		Percent:  ptr.Int64(10),
+		External: external,
+	}))
+	tc, err := BuildTrafficConfiguration(configLister, revLister, route)
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if got, want := tc, expected; !cmp.Equal(want, got, cmpOpts...) {
+		t.Fatalf("Unexpected traffic diff (-want +got):\n%s", cmp.Diff(want, got, cmpOpts...))
+	}
+
+	if got, want := tc.BuildRollout(), (&Rollout{}); !cmp.Equal(got, want) {
+		t.Errorf("Rollout mismatch, diff(-want,+got):\n%s", cmp.Diff(want, got))
+	}
+
+	targets, err := tc.GetRevisionTrafficTargets(getContext(), route, &Rollout{})
+	if err != nil {
+		t.Fatal("Unexpected error:", err)
+	}
+	wantStatus := []v1.TrafficTarget{{
+		RevisionName:   goodOldRev.Name,
+		Percent:        ptr.Int64(60),
+		LatestRevision: ptr.Bool(false),
+	}, {
+		Percent:        ptr.Int64(40),
+		LatestRevision: ptr.Bool(false),
+		External:       external,
+	}}
+	if !cmp.Equal(wantStatus, targets) {
+		t.Errorf("Unexpected status traffic diff (-want +got):\n%s", cmp.Diff(wantStatus, targets))
+	}
+}
+
 // One fixed, two named targets for newer stuffs.
 func TestBuildTrafficConfigurationPreliminary(t *testing.T) {
 	expected := &Config{
@@ -1157,6 +1232,125 @@ func TestBuildTrafficConfigurationNotRoutableRevision(t *testing.T) {
 	}
 }
 
+func TestBuildTrafficConfigurationReadinessGate(t *testing.T) {
+	created := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	gatedRev := func(annos map[string]string) *v1.Revision {
+		rev := unreadyRev.DeepCopy()
+		rev.CreationTimestamp = metav1.NewTime(created)
+		rev.Annotations = annos
+		return rev
+	}
+	tts := v1.TrafficTarget{
+		RevisionName: unreadyRev.Name,
+		Percent:      ptr.Int64(100),
+	}
+
+	tests := []struct {
+		name        string
+		routeAnnos  map[string]string
+		rev         *v1.Revision
+		now         time.Time
+		wantErr     TargetError
+		wantRequeue time.Duration
+		wantRouted  bool
+	}{{
+		name: "no timeout",
+		rev:  gatedRev(nil),
+		now:  created.Add(time.Hour),
+		// Waits forever.
+		wantErr: errUnreadyRevision(unreadyRev),
+	}, {
+		name:        "before timeout",
+		routeAnnos:  map[string]string{serving.ReadinessGateTimeoutKey: "5m"},
+		rev:         gatedRev(nil),
+		now:         created.Add(3 * time.Minute),
+		wantErr:     errUnreadyRevision(unreadyRev),
+		wantRequeue: 2 * time.Minute,
+	}, {
+		name:       "timed out, fail by default",
+		routeAnnos: map[string]string{serving.ReadinessGateTimeoutKey: "5m"},
+		rev:        gatedRev(nil),
+		now:        created.Add(5 * time.Minute),
+		wantErr:    errReadinessGateTimeout(unreadyRev, 5*time.Minute),
+	}, {
+		name: "timed out, fail",
+		routeAnnos: map[string]string{
+			serving.ReadinessGateTimeoutKey:       "5m",
+			serving.ReadinessGateTimeoutActionKey: serving.ReadinessGateTimeoutActionFail,
+		},
+		rev:     gatedRev(nil),
+		now:     created.Add(6 * time.Minute),
+		wantErr: errReadinessGateTimeout(unreadyRev, 5*time.Minute),
+	}, {
+		name: "timed out, proceed",
+		routeAnnos: map[string]string{
+			serving.ReadinessGateTimeoutKey:       "5m",
+			serving.ReadinessGateTimeoutActionKey: serving.ReadinessGateTimeoutActionProceed,
+		},
+		rev:        gatedRev(nil),
+		now:        created.Add(6 * time.Minute),
+		wantRouted: true,
+	}, {
+		name: "revision overrides route",
+		routeAnnos: map[string]string{
+			serving.ReadinessGateTimeoutKey:       "5m",
+			serving.ReadinessGateTimeoutActionKey: serving.ReadinessGateTimeoutActionFail,
+		},
+		rev: gatedRev(map[string]string{
+			serving.ReadinessGateTimeoutKey:       "10m",
+			serving.ReadinessGateTimeoutActionKey: serving.ReadinessGateTimeoutActionProceed,
+		}),
+		now:         created.Add(6 * time.Minute),
+		wantErr:     errUnreadyRevision(unreadyRev),
+		wantRequeue: 4 * time.Minute,
+	}, {
+		name:       "since last transition",
+		routeAnnos: map[string]string{serving.ReadinessGateTimeoutKey: "5m"},
+		rev: func() *v1.Revision {
+			rev := gatedRev(nil)
+			rev.Status.SetConditions(apis.Conditions{{
+				Type:               v1.RevisionConditionReady,
+				Status:             corev1.ConditionUnknown,
+				LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(created.Add(4 * time.Minute))},
+			}})
+			return rev
+		}(),
+		now:         created.Add(6 * time.Minute),
+		wantErr:     errUnreadyRevision(unreadyRev),
+		wantRequeue: 3 * time.Minute,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			revInformer := informers.NewSharedInformerFactory(fakeclientset.NewSimpleClientset(), 0).Serving().V1().Revisions()
+			revInformer.Informer().GetIndexer().Add(test.rev)
+
+			route := testRouteWithTrafficTargets(WithSpecTraffic(tts))
+			route.Annotations = test.routeAnnos
+			tc, err := BuildTrafficConfigurationAt(configLister, revInformer.Lister(), route, test.now)
+			if test.wantErr == nil {
+				if err != nil {
+					t.Fatal("BuildTrafficConfigurationAt() =", err)
+				}
+			} else {
+				var targetErr TargetError
+				if !errors.As(err, &targetErr) || err.Error() != test.wantErr.Error() {
+					t.Fatalf("BuildTrafficConfigurationAt() = %v, want: %v", err, test.wantErr)
+				}
+				if got, want := targetErr.IsFailure(), test.wantErr.IsFailure(); got != want {
+					t.Errorf("IsFailure() = %v, want: %v", got, want)
+				}
+			}
+			if got := tc.ReadinessGateRequeue; got != test.wantRequeue {
+				t.Errorf("ReadinessGateRequeue = %v, want: %v", got, test.wantRequeue)
+			}
+			if got := len(tc.Targets[DefaultTarget]) == 1; got != test.wantRouted {
+				t.Errorf("Routed = %v, want: %v, targets: %v", got, test.wantRouted, tc.Targets)
+			}
+		})
+	}
+}
+
 func TestBuildTrafficConfigurationReadyNotReadyConfig(t *testing.T) {
 	expected := &Config{
 		Targets: map[string]RevisionTargets{
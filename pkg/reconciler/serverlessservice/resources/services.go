@@ -58,6 +58,13 @@ func MakePublicService(sks *v1alpha1.ServerlessService) *corev1.Service {
 				Protocol:   corev1.ProtocolTCP,
 				Port:       int32(pkgnet.ServicePort(sks.Spec.ProtocolType)),
 				TargetPort: targetPort(sks),
+			}, {
+				// The port the ingress talks TLS to the activator or the
+				// queue-proxy on, when the internal encryption is enabled.
+				Name:       networking.ServicePortNameHTTPS,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       networking.ServiceHTTPSPort,
+				TargetPort: intstr.FromInt(networking.BackendHTTPSPort),
 			}},
 		},
 	}
@@ -83,7 +90,8 @@ func MakePublicEndpoints(sks *v1alpha1.ServerlessService, src *corev1.Endpoints)
 }
 
 // FilterSubsetPorts makes a copy of the ep.Subsets, filtering out ports
-// that are not serving (e.g. 8012 for HTTP).
+// that are not serving (e.g. 8012 for HTTP). The TLS port is kept too,
+// renamed to match the https port of the public service.
 func FilterSubsetPorts(sks *v1alpha1.ServerlessService, subsets []corev1.EndpointSubset) []corev1.EndpointSubset {
 	targetPort := targetPort(sks).IntVal
 	return filterSubsetPorts(targetPort, subsets)
@@ -99,13 +107,31 @@ func filterSubsetPorts(targetPort int32, subsets []corev1.EndpointSubset) []core
 	ret := make([]corev1.EndpointSubset, len(subsets))
 	for i, sss := range subsets {
 		sst := sss.DeepCopy()
-		// Find the port we care about and remove all others.
-		for j, p := range sst.Ports {
-			if p.Port == targetPort {
-				sst.Ports = sst.Ports[j : j+1]
-				break
+		// Find the ports we care about and remove all others.
+		var target, tls *corev1.EndpointPort
+		for j := range sst.Ports {
+			switch p := &sst.Ports[j]; p.Port {
+			case targetPort:
+				if target == nil {
+					target = p
+				}
+			case networking.BackendHTTPSPort:
+				if tls == nil {
+					tls = p
+				}
 			}
 		}
+		ports := make([]corev1.EndpointPort, 0, 2)
+		if target != nil {
+			ports = append(ports, *target)
+		}
+		if tls != nil {
+			tls.Name = networking.ServicePortNameHTTPS
+			ports = append(ports, *tls)
+		}
+		if len(ports) > 0 {
+			sst.Ports = ports
+		}
 		ret[i] = *sst
 	}
 	return ret
@@ -164,6 +190,13 @@ func MakePrivateService(sks *v1alpha1.ServerlessService, selector map[string]str
 				Protocol:   corev1.ProtocolTCP,
 				Port:       targetPort(sks).IntVal,
 				TargetPort: targetPort(sks),
+			}, {
+				// The port the activator talks to the queue-proxy on with
+				// mutual TLS, when enabled.
+				Name:       networking.BackendHTTPSPortName,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       networking.BackendHTTPSPort,
+				TargetPort: intstr.FromInt(networking.BackendHTTPSPort),
 			}},
 			Selector: selector,
 		},
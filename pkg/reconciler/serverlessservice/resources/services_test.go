@@ -115,6 +115,14 @@ func svc(t networking.ServiceType, mods ...func(*corev1.Service)) *corev1.Servic
 			}},
 		},
 	}
+	if t == networking.ServiceTypePublic {
+		base.Spec.Ports = append(base.Spec.Ports, corev1.ServicePort{
+			Name:       networking.ServicePortNameHTTPS,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       networking.ServiceHTTPSPort,
+			TargetPort: intstr.FromInt(networking.BackendHTTPSPort),
+		})
+	}
 	for _, mod := range mods {
 		mod(base)
 	}
@@ -149,6 +157,11 @@ func privateSvcMod(s *corev1.Service) {
 			Protocol:   corev1.ProtocolTCP,
 			Port:       networking.BackendHTTPPort,
 			TargetPort: intstr.FromInt(networking.BackendHTTPPort),
+		}, {
+			Name:       networking.BackendHTTPSPortName,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       networking.BackendHTTPSPort,
+			TargetPort: intstr.FromInt(networking.BackendHTTPSPort),
 		}}...)
 }
 
@@ -176,12 +189,12 @@ func TestMakePublicService(t *testing.T) {
 			s.Spec.ProtocolType = pkgnet.ProtocolH2C
 		}),
 		want: svc(networking.ServiceTypePublic, func(s *corev1.Service) {
-			s.Spec.Ports = []corev1.ServicePort{{
+			s.Spec.Ports[0] = corev1.ServicePort{
 				Name:       pkgnet.ServicePortNameH2C,
 				Protocol:   corev1.ProtocolTCP,
 				Port:       pkgnet.ServiceHTTP2Port,
 				TargetPort: intstr.FromInt(networking.BackendHTTP2Port),
-			}}
+			}
 			s.Annotations = map[string]string{"cherub": "rock"}
 			s.OwnerReferences[0].UID = "1988"
 		}),
@@ -191,12 +204,12 @@ func TestMakePublicService(t *testing.T) {
 			s.Spec.ProtocolType = pkgnet.ProtocolH2C
 		}),
 		want: svc(networking.ServiceTypePublic, func(s *corev1.Service) {
-			s.Spec.Ports = []corev1.ServicePort{{
+			s.Spec.Ports[0] = corev1.ServicePort{
 				Name:       pkgnet.ServicePortNameH2C,
 				Protocol:   corev1.ProtocolTCP,
 				Port:       pkgnet.ServiceHTTP2Port,
 				TargetPort: intstr.FromInt(networking.BackendHTTP2Port),
-			}}
+			}
 		}),
 	}, {
 		name: "HTTP2 - proxy",
@@ -206,12 +219,12 @@ func TestMakePublicService(t *testing.T) {
 			s.Labels["infinite"] = "sadness"
 		}),
 		want: svc(networking.ServiceTypePublic, func(s *corev1.Service) {
-			s.Spec.Ports = []corev1.ServicePort{{
+			s.Spec.Ports[0] = corev1.ServicePort{
 				Name:       pkgnet.ServicePortNameH2C,
 				Protocol:   corev1.ProtocolTCP,
 				Port:       pkgnet.ServiceHTTP2Port,
 				TargetPort: intstr.FromInt(networking.BackendHTTP2Port),
-			}}
+			}
 			s.Labels["infinite"] = "sadness"
 		}),
 	}}
@@ -367,6 +380,35 @@ func TestFilterSubsetPorts(t *testing.T) {
 				Protocol: "TCP",
 			}},
 		}},
+	}, {
+		name: "keep the TLS port",
+		port: 8012,
+		subsets: []corev1.EndpointSubset{{
+			Ports: []corev1.EndpointPort{{
+				Name:     networking.BackendHTTPSPortName,
+				Port:     networking.BackendHTTPSPort,
+				Protocol: "TCP",
+			}, {
+				Name:     "http",
+				Port:     9090,
+				Protocol: "TCP",
+			}, {
+				Name:     "http",
+				Port:     8012,
+				Protocol: "TCP",
+			}},
+		}},
+		want: []corev1.EndpointSubset{{
+			Ports: []corev1.EndpointPort{{
+				Name:     "http",
+				Port:     8012,
+				Protocol: "TCP",
+			}, {
+				Name:     networking.ServicePortNameHTTPS,
+				Port:     networking.BackendHTTPSPort,
+				Protocol: "TCP",
+			}},
+		}},
 	}}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -43,7 +43,10 @@ func MakeConfigurationFromExisting(service *v1.Service, existing *v1.Configurati
 	anns := kmeta.FilterMap(service.GetAnnotations(), func(key string) bool {
 		return key == corev1.LastAppliedConfigAnnotation ||
 			// Configs & Revisions don't use rollout information, it is only for routes.
-			key == serving.RolloutDurationKey
+			key == serving.RolloutDurationKey ||
+			key == serving.RolloutMaxErrorRateKey || key == serving.RolloutMaxLatencyKey ||
+			// The readiness gate of the Service applies to its Route.
+			key == serving.ReadinessGateTimeoutKey || key == serving.ReadinessGateTimeoutActionKey
 	})
 
 	routeName := names.Route(service)
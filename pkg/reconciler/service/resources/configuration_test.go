@@ -32,7 +32,11 @@ func TestConfigurationSpec(t *testing.T) {
 	s := createService()
 	s.Annotations = kmeta.UnionMaps(s.Annotations,
 		map[string]string{
-			serving.RolloutDurationKey: "2021s",
+			serving.RolloutDurationKey:            "2021s",
+			serving.ReadinessGateTimeoutKey:       "5m",
+			serving.ReadinessGateTimeoutActionKey: serving.ReadinessGateTimeoutActionProceed,
+			serving.RolloutMaxErrorRateKey:        "0.05",
+			serving.RolloutMaxLatencyKey:          "500ms",
 		},
 	)
 
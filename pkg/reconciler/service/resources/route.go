@@ -49,7 +49,7 @@ func MakeRoute(service *v1.Service) *v1.Route {
 	// Fill in any missing ConfigurationName fields when translating
 	// from Service to Route.
 	for idx := range c.Spec.Traffic {
-		if c.Spec.Traffic[idx].RevisionName == "" {
+		if c.Spec.Traffic[idx].RevisionName == "" && c.Spec.Traffic[idx].External == nil {
 			c.Spec.Traffic[idx].ConfigurationName = names.Configuration(service)
 		}
 	}
@@ -306,8 +306,11 @@ func CheckNameAvailability(config *v1.Configuration, lister listers.RevisionList
 		return nil
 	}
 	// We only require spec equality because the rest is immutable and the user may have
-	// annotated or labeled the Revision (beyond what the Configuration might have).
-	if !equality.Semantic.DeepEqual(config.Spec.GetTemplate().Spec, rev.Spec) {
+	// annotated or labeled the Revision (beyond what the Configuration might have),
+	// or hibernated it.
+	spec := rev.Spec
+	spec.Hibernated = false
+	if !equality.Semantic.DeepEqual(config.Spec.GetTemplate().Spec, spec) {
 		return errConflict
 	}
 	return nil
@@ -86,6 +86,14 @@ func (pa PodAccessor) NotReadyCount() (int, error) {
 	return nr, err
 }
 
+// UnschedulableCount returns the number of pending pods for the revision that
+// the scheduler failed to find a node for.
+func (pa PodAccessor) UnschedulableCount() (int, error) {
+	var count int
+	err := pa.ProcessPods(func(*corev1.Pod) { count++ }, podUnschedulable)
+	return count, err
+}
+
 // PodFilter provides a way to filter pods for a revision.
 // Returning true, means that pod should be kept.
 type PodFilter func(p *corev1.Pod) bool
@@ -124,6 +132,19 @@ func podRunning(p *corev1.Pod) bool {
 	return p.Status.Phase == corev1.PodRunning && p.DeletionTimestamp == nil
 }
 
+// podUnschedulable checks whether the pod is pending as it can't be scheduled.
+func podUnschedulable(p *corev1.Pod) bool {
+	if p.Status.Phase != corev1.PodPending {
+		return false
+	}
+	for _, cond := range p.Status.Conditions {
+		if cond.Type == corev1.PodScheduled {
+			return cond.Status == corev1.ConditionFalse && cond.Reason == corev1.PodReasonUnschedulable
+		}
+	}
+	return false
+}
+
 // podReady checks whether pod's Ready status is True.
 func podReady(p *corev1.Pod) bool {
 	for _, cond := range p.Status.Conditions {
@@ -286,6 +286,39 @@ func TestPendingTerminatingCounts(t *testing.T) {
 	}
 }
 
+func TestUnschedulableCount(t *testing.T) {
+	kubeClient := fakek8s.NewSimpleClientset()
+	podsClient := kubeinformers.NewSharedInformerFactory(kubeClient, 0).Core().V1().Pods()
+	scheduled := func(status corev1.ConditionStatus, reason string) podOption {
+		return func(p *corev1.Pod) {
+			p.Status.Conditions = []corev1.PodCondition{{
+				Type:   corev1.PodScheduled,
+				Status: status,
+				Reason: reason,
+			}}
+		}
+	}
+	for _, p := range []*corev1.Pod{
+		pod("running", makeReady),
+		pod("pending", withPhase(corev1.PodPending)),
+		pod("scheduled", withPhase(corev1.PodPending), scheduled(corev1.ConditionTrue, "")),
+		pod("unschedulable-1", withPhase(corev1.PodPending), scheduled(corev1.ConditionFalse, corev1.PodReasonUnschedulable)),
+		pod("unschedulable-2", withPhase(corev1.PodPending), scheduled(corev1.ConditionFalse, corev1.PodReasonUnschedulable)),
+		pod("gated", withPhase(corev1.PodPending), scheduled(corev1.ConditionFalse, "SchedulingGated")),
+	} {
+		podsClient.Informer().GetIndexer().Add(p)
+	}
+
+	podCounter := NewPodAccessor(podsClient.Lister(), testNamespace, testRevision)
+	got, err := podCounter.UnschedulableCount()
+	if err != nil {
+		t.Fatal("UnschedulableCount() =", err)
+	}
+	if want := 2; got != want {
+		t.Errorf("UnschedulableCount() = %d, want: %d", got, want)
+	}
+}
+
 type podOption func(p *corev1.Pod)
 
 func pod(name string, pos ...podOption) *corev1.Pod {
@@ -184,6 +184,13 @@ func WithWindowAnnotation(window string) PodAutoscalerOption {
 	return withAnnotationValue(autoscaling.WindowAnnotationKey, window)
 }
 
+// WithActivatorSubsetSizeAnnotation returns a PodAutoscalerOption which sets
+// the PodAutoscaler autoscaling.knative.dev/activatorSubsetSize annotation to
+// the provided value.
+func WithActivatorSubsetSizeAnnotation(size string) PodAutoscalerOption {
+	return withAnnotationValue(autoscaling.ActivatorSubsetSizeAnnotationKey, size)
+}
+
 // WithPanicThresholdPercentageAnnotation returns a PodAutoscalerOption
 // which sets the PodAutoscaler
 // autoscaling.knative.dev/panicThresholdPercentage annotation to the
@@ -228,6 +235,13 @@ func WithLowerScaleBound(i int) PodAutoscalerOption {
 	return withAnnotationValue(autoscaling.MinScaleAnnotationKey, strconv.Itoa(i))
 }
 
+// WithScaleScheduleAnnotation returns a PodAutoscalerOption which sets the
+// PodAutoscaler autoscaling.knative.dev/scaleSchedule annotation to the
+// provided value.
+func WithScaleScheduleAnnotation(schedule string) PodAutoscalerOption {
+	return withAnnotationValue(autoscaling.ScaleScheduleAnnotationKey, schedule)
+}
+
 // K8sServiceOption enables further configuration of the Kubernetes Service.
 type K8sServiceOption func(*corev1.Service)
 
@@ -78,6 +78,13 @@ func WithLatestReady(name string) ConfigOption {
 	}
 }
 
+// WithPreservedRevisions sets the .status.preservedRevisionNames.
+func WithPreservedRevisions(names ...string) ConfigOption {
+	return func(cfg *v1.Configuration) {
+		cfg.Status.PreservedRevisionNames = names
+	}
+}
+
 // MarkRevisionCreationFailed calls .Status.MarkRevisionCreationFailed.
 func MarkRevisionCreationFailed(msg string) ConfigOption {
 	return func(cfg *v1.Configuration) {
@@ -89,6 +89,13 @@ func WithRevTarget(revision string) RouteOption {
 	})
 }
 
+// WithStatusRollouts sets the Route's status rollouts to the specified ones.
+func WithStatusRollouts(rollouts ...v1.RolloutStatus) RouteOption {
+	return func(r *v1.Route) {
+		r.Status.Rollouts = rollouts
+	}
+}
+
 // WithStatusTraffic sets the Route's status traffic block to the specified traffic targets.
 func WithStatusTraffic(traffic ...v1.TrafficTarget) RouteOption {
 	ctx := apis.WithinStatus(context.Background())
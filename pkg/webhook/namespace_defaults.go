@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/labels"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	apisconfig "knative.dev/serving/pkg/apis/config"
+)
+
+// NamespaceDefaults returns the NamespaceDefaultsFunc merging the data of the
+// namespace defaults ConfigMaps listed by lister, in the order of their names.
+func NamespaceDefaults(lister corev1listers.ConfigMapLister) apisconfig.NamespaceDefaultsFunc {
+	return func(namespace string) map[string]string {
+		cms, err := lister.ConfigMaps(namespace).List(labels.Everything())
+		if err != nil || len(cms) == 0 {
+			return nil
+		}
+		sort.Slice(cms, func(i, j int) bool {
+			return cms[i].Name < cms[j].Name
+		})
+		data := make(map[string]string, len(cms[0].Data))
+		for _, cm := range cms {
+			if _, ok := cm.Labels[apisconfig.NamespaceDefaultsLabelKey]; !ok {
+				continue
+			}
+			for k, v := range cm.Data {
+				data[k] = v
+			}
+		}
+		return data
+	}
+}
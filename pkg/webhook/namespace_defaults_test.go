@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	apisconfig "knative.dev/serving/pkg/apis/config"
+)
+
+func TestNamespaceDefaults(t *testing.T) {
+	configMap := func(namespace, name string, labeled bool, data map[string]string) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      name,
+			},
+			Data: data,
+		}
+		if labeled {
+			cm.Labels = map[string]string{apisconfig.NamespaceDefaultsLabelKey: ""}
+		}
+		return cm
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, cm := range []*corev1.ConfigMap{
+		configMap("tenant", "b-defaults", true, map[string]string{"revision-timeout-seconds": "60"}),
+		configMap("tenant", "a-defaults", true, map[string]string{
+			"revision-timeout-seconds": "30",
+			"revision-cpu-request":     "100m",
+		}),
+		configMap("tenant", "unrelated", false, map[string]string{"revision-cpu-request": "1"}),
+		configMap("other", "defaults", true, map[string]string{"revision-cpu-limit": "1"}),
+	} {
+		indexer.Add(cm)
+	}
+	f := NamespaceDefaults(corev1listers.NewConfigMapLister(indexer))
+
+	want := map[string]string{
+		"revision-timeout-seconds": "60",
+		"revision-cpu-request":     "100m",
+	}
+	if got := f("tenant"); !cmp.Equal(got, want) {
+		t.Error("NamespaceDefaults(tenant) (-want, +got):", cmp.Diff(want, got))
+	}
+	if got := f("empty"); got != nil {
+		t.Errorf("NamespaceDefaults(empty) = %v, want: nil", got)
+	}
+}
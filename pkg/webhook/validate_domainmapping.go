@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/serving/pkg/apis/serving/v1alpha1"
+	servingclient "knative.dev/serving/pkg/client/injection/client"
+)
+
+// ValidateDomainMapping runs extra validation on DomainMapping resources,
+// making sure that their path doesn't overlap the path of another
+// DomainMapping of the same host.
+func ValidateDomainMapping(ctx context.Context, uns *unstructured.Unstructured) error {
+	dm := &v1alpha1.DomainMapping{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(uns.UnstructuredContent(), dm); err != nil {
+		return fmt.Errorf("could not decode the DomainMapping: %w", err)
+	}
+
+	dms, err := servingclient.Get(ctx).ServingV1alpha1().DomainMappings(dm.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("could not list the DomainMappings: %w", err)
+	}
+	for i := range dms.Items {
+		other := &dms.Items[i]
+		if other.Name == dm.Name || other.Host() != dm.Host() {
+			continue
+		}
+		if pathsOverlap(other.Spec.Path, dm.Spec.Path) {
+			return fmt.Errorf("path %q of host %q overlaps path %q of DomainMapping %q",
+				dm.Spec.Path, dm.Host(), other.Spec.Path, other.Name)
+		}
+	}
+	return nil
+}
+
+// pathsOverlap returns whether one of the path prefixes contains the other,
+// the empty one containing all the paths.
+func pathsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"knative.dev/serving/pkg/apis/serving/v1alpha1"
+	fakeservingclient "knative.dev/serving/pkg/client/injection/client/fake"
+)
+
+func TestValidateDomainMapping(t *testing.T) {
+	mount := func(name, host, path string) *v1alpha1.DomainMapping {
+		return &v1alpha1.DomainMapping{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "foo",
+			},
+			Spec: v1alpha1.DomainMappingSpec{
+				Host: host,
+				Path: path,
+			},
+		}
+	}
+	ctx, _ := fakeservingclient.With(context.Background(),
+		mount("api", "example.com", "/api"),
+		mount("web", "example.com", "/web"),
+	)
+
+	tests := []struct {
+		name    string
+		dm      *v1alpha1.DomainMapping
+		wantErr bool
+	}{{
+		name: "update of a mount",
+		dm:   mount("api", "example.com", "/api/v2"),
+	}, {
+		name: "distinct path",
+		dm:   mount("docs", "example.com", "/docs"),
+	}, {
+		name: "common prefix is not a path segment",
+		dm:   mount("apis", "example.com", "/apis"),
+	}, {
+		name: "other host",
+		dm:   mount("api2", "example.org", "/api"),
+	}, {
+		name:    "same path",
+		dm:      mount("api2", "example.com", "/api"),
+		wantErr: true,
+	}, {
+		name:    "nested path",
+		dm:      mount("api2", "example.com", "/api/v2"),
+		wantErr: true,
+	}, {
+		name:    "root of the host",
+		dm:      mount("example.com", "", ""),
+		wantErr: true,
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(tc.dm)
+			if err != nil {
+				t.Fatal("ToUnstructured() =", err)
+			}
+			err = ValidateDomainMapping(ctx, &unstructured.Unstructured{Object: content})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ValidateDomainMapping() = %v, wantErr: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
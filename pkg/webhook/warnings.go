@@ -0,0 +1,193 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/controller"
+	pkgreconciler "knative.dev/pkg/reconciler"
+	"knative.dev/pkg/webhook"
+	"knative.dev/serving/pkg/apis/autoscaling"
+	"knative.dev/serving/pkg/apis/serving"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// supersededAnnotations are the deprecated annotations of the revision
+// templates, with the fields taking their place.
+var supersededAnnotations = map[string]string{
+	autoscaling.MinScaleAnnotationKey:       "spec.scaling.minScale",
+	autoscaling.MaxScaleAnnotationKey:       "spec.scaling.maxScale",
+	autoscaling.TargetAnnotationKey:         "spec.scaling.target",
+	autoscaling.MetricAnnotationKey:         "spec.scaling.metric",
+	autoscaling.ScaleDownDelayAnnotationKey: "spec.scaling.scaleDownDelay",
+}
+
+// admissionReconciler is the admission controller of a controller.Impl.
+type admissionReconciler interface {
+	webhook.AdmissionController
+	controller.Reconciler
+	pkgreconciler.LeaderAware
+}
+
+// warningAdmissionController adds the admission warnings of the resources to
+// the responses of the admission controller it wraps.
+type warningAdmissionController struct {
+	webhook.StatelessAdmissionImpl
+	admissionReconciler
+}
+
+// WithWarnings decorates the admission controller of the given controller so
+// that the requests it allows are answered with the admission warnings of
+// their resource, which kubectl and the client libraries show to the users.
+func WithWarnings(impl *controller.Impl) *controller.Impl {
+	if ac, ok := impl.Reconciler.(admissionReconciler); ok {
+		impl.Reconciler = &warningAdmissionController{admissionReconciler: ac}
+	}
+	return impl
+}
+
+// Admit implements webhook.AdmissionController.
+func (ac *warningAdmissionController) Admit(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	resp := ac.admissionReconciler.Admit(ctx, req)
+	if resp == nil || !resp.Allowed || len(req.Object.Raw) == 0 ||
+		req.Kind.Group != serving.GroupName || req.Kind.Version != v1.SchemeGroupVersion.Version {
+		return resp
+	}
+	var obj interface{}
+	switch req.Kind.Kind {
+	case "Service":
+		obj = &v1.Service{}
+	case "Configuration":
+		obj = &v1.Configuration{}
+	case "Revision":
+		obj = &v1.Revision{}
+	case "Route":
+		obj = &v1.Route{}
+	default:
+		return resp
+	}
+	if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+		// The validation has decoded it already, so this is not expected.
+		return resp
+	}
+	resp.Warnings = append(resp.Warnings, Warnings(obj)...)
+	return resp
+}
+
+// Warnings returns the non-fatal advice on the deprecated and risky settings
+// of a serving resource, which is valid nonetheless.
+func Warnings(obj interface{}) []string {
+	switch o := obj.(type) {
+	case *v1.Service:
+		warnings := templateWarnings("spec.template", &o.Spec.Template)
+		return append(warnings, trafficWarnings("spec.traffic", o.Spec.Traffic, &o.Spec.Template)...)
+	case *v1.Configuration:
+		return templateWarnings("spec.template", &o.Spec.Template)
+	case *v1.Revision:
+		return revisionSpecWarnings("spec", &o.Spec)
+	case *v1.Route:
+		var warnings []string
+		if _, ok := o.Annotations[serving.RolloutDurationKey]; ok {
+			warnings = append(warnings, fmt.Sprintf("metadata.annotations: %s is deprecated, use spec.rolloutDuration instead",
+				serving.RolloutDurationKey))
+		}
+		return append(warnings, trafficWarnings("spec.traffic", o.Spec.Traffic, nil)...)
+	}
+	return nil
+}
+
+func templateWarnings(path string, tmpl *v1.RevisionTemplateSpec) []string {
+	var warnings []string
+	keys := make([]string, 0, len(supersededAnnotations))
+	for key := range supersededAnnotations {
+		if _, ok := tmpl.Annotations[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		warnings = append(warnings, fmt.Sprintf("%s.metadata.annotations: %s is deprecated, use %s.%s instead",
+			path, key, path, supersededAnnotations[key]))
+	}
+	return append(warnings, revisionSpecWarnings(path+".spec", &tmpl.Spec)...)
+}
+
+func revisionSpecWarnings(path string, rs *v1.RevisionSpec) []string {
+	var warnings []string
+	for i := range rs.Containers {
+		c := &rs.Containers[i]
+		cpath := fmt.Sprintf("%s.containers[%d]", path, i)
+		if _, ok := c.Resources.Requests[corev1.ResourceCPU]; !ok {
+			warnings = append(warnings, cpath+".resources.requests: no cpu request is set, "+
+				"so the pods may be starved of cpu and can't be autoscaled on cpu")
+		}
+		if _, ok := c.Resources.Requests[corev1.ResourceMemory]; !ok {
+			warnings = append(warnings, cpath+".resources.requests: no memory request is set, "+
+				"so the pods may be evicted first under memory pressure")
+		}
+		warnings = append(warnings, probeWarnings(cpath+".readinessProbe", c.ReadinessProbe)...)
+		warnings = append(warnings, probeWarnings(cpath+".livenessProbe", c.LivenessProbe)...)
+		if c.ReadinessProbe != nil && c.ReadinessProbe.PeriodSeconds > 0 && len(c.Ports) > 0 {
+			warnings = append(warnings, cpath+".readinessProbe.periodSeconds: a non-zero period "+
+				"opts out of the aggressive probing during startup, which slows down cold starts")
+		}
+		if c.LivenessProbe != nil && c.StartupProbe == nil && c.LivenessProbe.InitialDelaySeconds == 0 {
+			warnings = append(warnings, cpath+".livenessProbe: without an initialDelaySeconds or a startupProbe, "+
+				"a slow starting container may be restarted before it's up")
+		}
+	}
+	return warnings
+}
+
+func probeWarnings(path string, p *corev1.Probe) []string {
+	if p == nil || p.PeriodSeconds == 0 || p.TimeoutSeconds <= p.PeriodSeconds {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s.timeoutSeconds: the timeout of %ds exceeds the period of %ds, so the probes overlap",
+		path, p.TimeoutSeconds, p.PeriodSeconds)}
+}
+
+func trafficWarnings(path string, traffic []v1.TrafficTarget, tmpl *v1.RevisionTemplateSpec) []string {
+	if len(traffic) == 0 {
+		return nil
+	}
+	var warnings []string
+	followsLatest := false
+	for i := range traffic {
+		if traffic[i].LatestRevision != nil && *traffic[i].LatestRevision {
+			followsLatest = true
+		}
+	}
+	for i := range traffic {
+		tt := &traffic[i]
+		if followsLatest && tmpl != nil && tmpl.Name != "" && tt.RevisionName == tmpl.Name {
+			warnings = append(warnings, fmt.Sprintf("%s[%d].revisionName: %s is pinned while it's the latest revision "+
+				"of the template too, so its traffic is split over both targets", path, i, tt.RevisionName))
+		}
+	}
+	if !followsLatest && tmpl != nil {
+		warnings = append(warnings, path+": no traffic target has latestRevision set, "+
+			"so the revisions created from the changes to spec.template receive no traffic")
+	}
+	return warnings
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2021 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/ptr"
+	pkgreconciler "knative.dev/pkg/reconciler"
+	"knative.dev/pkg/webhook"
+	"knative.dev/serving/pkg/apis/autoscaling"
+	"knative.dev/serving/pkg/apis/serving"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+var requests = corev1.ResourceRequirements{
+	Requests: corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("100m"),
+		corev1.ResourceMemory: resource.MustParse("128Mi"),
+	},
+}
+
+func TestWarnings(t *testing.T) {
+	tests := []struct {
+		name string
+		obj  interface{}
+		want []string
+	}{{
+		name: "clean service",
+		obj: &v1.Service{
+			Spec: v1.ServiceSpec{
+				ConfigurationSpec: v1.ConfigurationSpec{
+					Template: v1.RevisionTemplateSpec{
+						Spec: v1.RevisionSpec{
+							PodSpec: corev1.PodSpec{
+								Containers: []corev1.Container{{Resources: requests}},
+							},
+						},
+					},
+				},
+				RouteSpec: v1.RouteSpec{
+					Traffic: []v1.TrafficTarget{{LatestRevision: ptr.Bool(true), Percent: ptr.Int64(100)}},
+				},
+			},
+		},
+	}, {
+		name: "deprecated annotations and missing requests",
+		obj: &v1.Configuration{
+			Spec: v1.ConfigurationSpec{
+				Template: v1.RevisionTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							autoscaling.MinScaleAnnotationKey: "1",
+							autoscaling.ClassAnnotationKey:    autoscaling.KPA,
+						},
+					},
+					Spec: v1.RevisionSpec{
+						PodSpec: corev1.PodSpec{
+							Containers: []corev1.Container{{}},
+						},
+					},
+				},
+			},
+		},
+		want: []string{
+			"spec.template.metadata.annotations: autoscaling.knative.dev/minScale is deprecated, use spec.template.spec.scaling.minScale instead",
+			"spec.template.spec.containers[0].resources.requests: no cpu request is set, so the pods may be starved of cpu and can't be autoscaled on cpu",
+			"spec.template.spec.containers[0].resources.requests: no memory request is set, so the pods may be evicted first under memory pressure",
+		},
+	}, {
+		name: "probes",
+		obj: &v1.Revision{
+			Spec: v1.RevisionSpec{
+				PodSpec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Resources: requests,
+						Ports:     []corev1.ContainerPort{{ContainerPort: 8080}},
+						ReadinessProbe: &corev1.Probe{
+							PeriodSeconds:  1,
+							TimeoutSeconds: 1,
+						},
+						LivenessProbe: &corev1.Probe{
+							PeriodSeconds:  2,
+							TimeoutSeconds: 5,
+						},
+					}},
+				},
+			},
+		},
+		want: []string{
+			"spec.containers[0].livenessProbe.timeoutSeconds: the timeout of 5s exceeds the period of 2s, so the probes overlap",
+			"spec.containers[0].readinessProbe.periodSeconds: a non-zero period opts out of the aggressive probing during startup, which slows down cold starts",
+			"spec.containers[0].livenessProbe: without an initialDelaySeconds or a startupProbe, a slow starting container may be restarted before it's up",
+		},
+	}, {
+		name: "pinned traffic",
+		obj: &v1.Service{
+			Spec: v1.ServiceSpec{
+				ConfigurationSpec: v1.ConfigurationSpec{
+					Template: v1.RevisionTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Name: "svc-v2"},
+						Spec: v1.RevisionSpec{
+							PodSpec: corev1.PodSpec{
+								Containers: []corev1.Container{{Resources: requests}},
+							},
+						},
+					},
+				},
+				RouteSpec: v1.RouteSpec{
+					Traffic: []v1.TrafficTarget{{
+						LatestRevision: ptr.Bool(true),
+						Percent:        ptr.Int64(50),
+					}, {
+						RevisionName: "svc-v2",
+						Percent:      ptr.Int64(50),
+					}},
+				},
+			},
+		},
+		want: []string{
+			"spec.traffic[1].revisionName: svc-v2 is pinned while it's the latest revision of the template too, so its traffic is split over both targets",
+		},
+	}, {
+		name: "no traffic follows the latest revision",
+		obj: &v1.Service{
+			Spec: v1.ServiceSpec{
+				ConfigurationSpec: v1.ConfigurationSpec{
+					Template: v1.RevisionTemplateSpec{
+						Spec: v1.RevisionSpec{
+							PodSpec: corev1.PodSpec{
+								Containers: []corev1.Container{{Resources: requests}},
+							},
+						},
+					},
+				},
+				RouteSpec: v1.RouteSpec{
+					Traffic: []v1.TrafficTarget{{
+						RevisionName: "svc-v1",
+						Percent:      ptr.Int64(100),
+					}},
+				},
+			},
+		},
+		want: []string{
+			"spec.traffic: no traffic target has latestRevision set, so the revisions created from the changes to spec.template receive no traffic",
+		},
+	}, {
+		name: "route rollout duration annotation",
+		obj: &v1.Route{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{serving.RolloutDurationKey: "2m"},
+			},
+			Spec: v1.RouteSpec{
+				Traffic: []v1.TrafficTarget{{RevisionName: "rev", Percent: ptr.Int64(100)}},
+			},
+		},
+		want: []string{
+			"metadata.annotations: serving.knative.dev/rolloutDuration is deprecated, use spec.rolloutDuration instead",
+		},
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Warnings(tc.obj); !cmp.Equal(got, tc.want) {
+				t.Errorf("Warnings (-want, +got): %s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+}
+
+type fakeAdmissionReconciler struct {
+	pkgreconciler.LeaderAwareFuncs
+	allowed bool
+}
+
+func (*fakeAdmissionReconciler) Path() string                            { return "/validation" }
+func (*fakeAdmissionReconciler) Reconcile(context.Context, string) error { return nil }
+func (f *fakeAdmissionReconciler) Admit(context.Context, *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{Allowed: f.allowed}
+}
+
+func TestWithWarnings(t *testing.T) {
+	route := &v1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{serving.RolloutDurationKey: "2m"},
+		},
+	}
+	raw, err := json.Marshal(route)
+	if err != nil {
+		t.Fatal("Marshal() =", err)
+	}
+	req := &admissionv1.AdmissionRequest{
+		Kind:   metav1.GroupVersionKind{Group: serving.GroupName, Version: "v1", Kind: "Route"},
+		Object: runtime.RawExtension{Raw: raw},
+	}
+
+	for _, allowed := range []bool{true, false} {
+		impl := WithWarnings(&controller.Impl{Reconciler: &fakeAdmissionReconciler{allowed: allowed}})
+		ac, ok := impl.Reconciler.(webhook.AdmissionController)
+		if !ok {
+			t.Fatal("The decorated reconciler is not an admission controller")
+		}
+		if _, ok := impl.Reconciler.(webhook.StatelessAdmissionController); !ok {
+			t.Error("The decorated reconciler is not a stateless admission controller")
+		}
+		resp := ac.Admit(context.Background(), req)
+		if got, want := len(resp.Warnings), map[bool]int{true: 1, false: 0}[allowed]; got != want {
+			t.Errorf("Allowed = %v: got %d warnings, want: %d", allowed, got, want)
+		}
+	}
+}